@@ -149,6 +149,13 @@ func shutdown(sigChan chan os.Signal, server *http.Server) {
 		util.Log().Error("Failed to shutdown server: %s", err)
 	}
 
+	// Flush traffic statistics not yet written to database
+	if conf.SystemConfig.Mode == "master" {
+		if err := model.FlushTraffic(); err != nil {
+			util.Log().Warning("Failed to flush traffic statistics: %s", err)
+		}
+	}
+
 	// Persist in-memory cache
 	if err := cache.Store.Persist(filepath.Join(model.GetSettingByName("temp_path"), cache.DefaultCacheFile)); err != nil {
 		util.Log().Warning("Failed to persist cache: %s", err)
@@ -1,6 +1,10 @@
 package middleware
 
 import (
+	"net/http"
+	"strconv"
+	"strings"
+
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/gin-gonic/gin"
@@ -28,3 +32,114 @@ func ValidateSourceLink() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// ValidateTempSourceLink 校验临时外链是否有效，不消耗下载额度
+func ValidateTempSourceLink() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		link, err := model.GetTempSourceLink(c.Param("token"))
+		if err != nil {
+			c.JSON(200, serializer.Err(serializer.CodeFileNotFound, "", err))
+			c.Abort()
+			return
+		}
+
+		files, err := model.GetFilesByIDs([]uint{link.FileID}, 0)
+		if err != nil || len(files) == 0 || files[0].Name != c.Param("name") {
+			c.JSON(200, serializer.Err(serializer.CodeFileNotFound, "", err))
+			c.Abort()
+			return
+		}
+
+		c.Set("source_link", &model.SourceLink{
+			FileID: files[0].ID,
+			Name:   files[0].Name,
+			File:   files[0],
+		})
+		c.Set("temp_source_link", link)
+		c.Next()
+	}
+}
+
+// UseTempSourceLink 按请求的字节数消耗已校验的临时外链的下载额度，需放在对请求的其他检查之后
+func UseTempSourceLink() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		link, ok := c.Get("temp_source_link")
+		sourceLink, sourceOk := c.Get("source_link")
+		if !ok || !sourceOk {
+			c.JSON(200, serializer.Err(serializer.CodeFileNotFound, "", nil))
+			c.Abort()
+			return
+		}
+
+		size := sourceLink.(*model.SourceLink).File.Size
+		if err := link.(*model.TempSourceLink).Use(requestedBytes(c.Request, size), size); err != nil {
+			c.JSON(200, serializer.Err(serializer.CodeFileNotFound, "", err))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// HotlinkProtection 根据文件所在存储策略的防盗链设置校验直链请求的来源
+func HotlinkProtection() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sourceLink, ok := c.Get("source_link")
+		if ok && !sourceLink.(*model.SourceLink).File.GetPolicy().IsRefererAllowed(c.Request.Referer()) {
+			c.JSON(200, serializer.Err(serializer.CodeHotlinkBlocked, "Hotlinking is not allowed", nil))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// requestedBytes 返回请求从大小为 size 的文件中读取的字节数，仅单个可解析的 Range 按其范围计算，
+// 多段 Range 及无法解析的 Range 均按完整文件计算
+func requestedBytes(r *http.Request, size uint64) uint64 {
+	rangeHeader := strings.TrimSpace(r.Header.Get("Range"))
+	if !strings.HasPrefix(rangeHeader, "bytes=") {
+		return size
+	}
+
+	spec := strings.TrimSpace(strings.TrimPrefix(rangeHeader, "bytes="))
+	if strings.Contains(spec, ",") {
+		return size
+	}
+
+	start, end, ok := strings.Cut(spec, "-")
+	if !ok {
+		return size
+	}
+
+	start, end = strings.TrimSpace(start), strings.TrimSpace(end)
+
+	// 后缀 Range，读取末尾的 n 个字节
+	if start == "" {
+		n, err := strconv.ParseUint(end, 10, 64)
+		if err != nil || n == 0 || n > size {
+			return size
+		}
+		return n
+	}
+
+	startPos, err := strconv.ParseUint(start, 10, 64)
+	if err != nil || startPos >= size {
+		return size
+	}
+
+	endPos := size - 1
+	if end != "" {
+		endPos, err = strconv.ParseUint(end, 10, 64)
+		if err != nil || endPos < startPos {
+			return size
+		}
+		if endPos >= size {
+			endPos = size - 1
+		}
+	}
+
+	return endPos - startPos + 1
+}
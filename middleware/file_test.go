@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"net/http/httptest"
@@ -55,3 +56,141 @@ func TestValidateSourceLink(t *testing.T) {
 	}
 
 }
+
+func TestValidateTempSourceLink(t *testing.T) {
+	a := assert.New(t)
+	rec := httptest.NewRecorder()
+	testFunc := ValidateTempSourceLink()
+
+	// 外链不存在
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Params = []gin.Param{{Key: "token", Value: "not_exist"}, {Key: "name", Value: "1.txt"}}
+		testFunc(c)
+		a.True(c.IsAborted())
+	}
+
+	// 文件名不匹配
+	{
+		link, err := model.CreateTempSourceLink(2, 60, 0)
+		a.NoError(err)
+		c, _ := gin.CreateTestContext(rec)
+		c.Params = []gin.Param{{Key: "token", Value: link.Token}, {Key: "name", Value: "2.txt"}}
+		mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "1.txt"))
+		testFunc(c)
+		a.True(c.IsAborted())
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 成功，不消耗下载次数
+	{
+		link, err := model.CreateTempSourceLink(2, 60, 1)
+		a.NoError(err)
+		for i := 0; i < 2; i++ {
+			c, _ := gin.CreateTestContext(rec)
+			c.Params = []gin.Param{{Key: "token", Value: link.Token}, {Key: "name", Value: "1.txt"}}
+			mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "1.txt"))
+			testFunc(c)
+			a.False(c.IsAborted())
+			a.NoError(mock.ExpectationsWereMet())
+			sourceLink, ok := c.Get("source_link")
+			a.True(ok)
+			a.EqualValues(2, sourceLink.(*model.SourceLink).FileID)
+			_, ok = c.Get("temp_source_link")
+			a.True(ok)
+		}
+	}
+}
+
+func TestUseTempSourceLink(t *testing.T) {
+	a := assert.New(t)
+	rec := httptest.NewRecorder()
+	testFunc := UseTempSourceLink()
+	sourceLink := &model.SourceLink{File: model.File{Size: 1000}}
+
+	// 未经校验
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest("GET", "/t/token/1.txt", nil)
+		testFunc(c)
+		a.True(c.IsAborted())
+	}
+
+	link, err := model.CreateTempSourceLink(2, 60, 1)
+	a.NoError(err)
+
+	// 分段下载，计入各段的字节数
+	for _, rangeHeader := range []string{"bytes=0-499", "bytes=500-"} {
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest("GET", "/t/"+link.Token+"/1.txt", nil)
+		c.Request.Header.Set("Range", rangeHeader)
+		c.Set("temp_source_link", link)
+		c.Set("source_link", sourceLink)
+		testFunc(c)
+		a.False(c.IsAborted(), rangeHeader)
+	}
+
+	// 下载额度已用完，续传同样受限
+	for _, rangeHeader := range []string{"", "bytes=1-", "bytes=-1"} {
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest("GET", "/t/"+link.Token+"/1.txt", nil)
+		c.Request.Header.Set("Range", rangeHeader)
+		c.Set("temp_source_link", link)
+		c.Set("source_link", sourceLink)
+		testFunc(c)
+		a.True(c.IsAborted(), rangeHeader)
+	}
+}
+
+func TestRequestedBytes(t *testing.T) {
+	a := assert.New(t)
+	testCases := map[string]uint64{
+		"":                 1000,
+		"bytes=0-":         1000,
+		"bytes=-100":       100,
+		"bytes=-999999999": 1000,
+		"bytes=1-,0-0":     1000,
+		"bytes=100-50":     1000,
+		"bytes=abc-":       1000,
+		"items=100-":       1000,
+		"bytes=2000-":      1000,
+		"bytes=100-":       900,
+		"bytes=100-199":    100,
+		"bytes=900-5000":   100,
+		" bytes=1-1 ":      1,
+	}
+
+	for rangeHeader, expected := range testCases {
+		r := httptest.NewRequest("GET", "/t/token/1.txt", nil)
+		r.Header.Set("Range", rangeHeader)
+		a.Equal(expected, requestedBytes(r, 1000), rangeHeader)
+	}
+}
+
+func TestHotlinkProtection(t *testing.T) {
+	a := assert.New(t)
+	rec := httptest.NewRecorder()
+	testFunc := HotlinkProtection()
+	sourceLink := &model.SourceLink{File: model.File{Policy: model.Policy{Type: "local"}}}
+	sourceLink.File.Policy.ID = 1
+	sourceLink.File.Policy.OptionsSerialized.HotlinkProtection = true
+
+	// 允许的来源
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest("GET", "/f/1/1.txt", nil)
+		c.Set("source_link", sourceLink)
+		testFunc(c)
+		a.False(c.IsAborted())
+	}
+
+	// 不允许的来源
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest("GET", "/f/1/1.txt", nil)
+		c.Request.Header.Set("Referer", "https://evil.com/")
+		c.Set("source_link", sourceLink)
+		testFunc(c)
+		a.True(c.IsAborted())
+	}
+}
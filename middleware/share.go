@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"fmt"
+	"net/url"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
@@ -45,7 +46,9 @@ func ShareAvailable() gin.HandlerFunc {
 
 		share := model.GetShareByHashID(c.Param("id"))
 
-		if share == nil || !share.IsAvailable() {
+		// 未到生效时间的分享只对创建者可见
+		if share == nil || !share.IsAvailable() || !share.IsAccessibleBy(user) || !share.IsVisibleTo(user, c) ||
+			(!share.IsActivated() && share.UserID != user.ID) {
 			c.JSON(200, serializer.Err(serializer.CodeShareLinkNotFound, "", nil))
 			c.Abort()
 			return
@@ -61,7 +64,7 @@ func ShareAvailable() gin.HandlerFunc {
 func ShareCanPreview() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if share, ok := c.Get("share"); ok {
-			if share.(*model.Share).PreviewEnabled {
+			if share.(*model.Share).Can(model.SharePermissionView) {
 				c.Next()
 				return
 			}
@@ -74,6 +77,76 @@ func ShareCanPreview() gin.HandlerFunc {
 	}
 }
 
+// ShareCanEmbed 检查分享能否被嵌入其他站点
+func ShareCanEmbed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if share, ok := c.Get("share"); ok {
+			if share.(*model.Share).IsEmbeddable() {
+				c.Next()
+				return
+			}
+			c.JSON(200, serializer.Err(serializer.CodeNoPermissionErr, "This share cannot be embedded",
+				nil))
+			c.Abort()
+			return
+		}
+		c.Abort()
+	}
+}
+
+// ShareEmbedReferer 检查嵌入页内容的请求是否来自本站同一分享的嵌入页，
+// 避免嵌入页签发的内容地址被其他站点直接引用
+func ShareEmbedReferer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		embedPage := "/api/v3/share/embed/" + c.Param("id")
+		referer, err := url.Parse(c.Request.Referer())
+		if err == nil && referer.Host == c.Request.Host && referer.Path == embedPage {
+			origin, err := url.Parse(c.GetHeader("Origin"))
+			if c.GetHeader("Origin") == "" || (err == nil && origin.Host == c.Request.Host) {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(200, serializer.Err(serializer.CodeNoPermissionErr, "Content can only be loaded by the embed page", nil))
+		c.Abort()
+	}
+}
+
+// ShareCanDownload 检查分享是否允许下载原始文件
+func ShareCanDownload() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if share, ok := c.Get("share"); ok {
+			if share.(*model.Share).Can(model.SharePermissionDownload) {
+				c.Next()
+				return
+			}
+			c.JSON(200, serializer.Err(serializer.CodeDisabledShareDownload, "",
+				nil))
+			c.Abort()
+			return
+		}
+		c.Abort()
+	}
+}
+
+// ShareCanUpload 检查分享是否允许访客上传
+func ShareCanUpload() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if share, ok := c.Get("share"); ok {
+			if share.(*model.Share).IsDir && share.(*model.Share).Can(model.SharePermissionUpload) {
+				c.Next()
+				return
+			}
+			c.JSON(200, serializer.Err(serializer.CodeDisabledShareUpload, "",
+				nil))
+			c.Abort()
+			return
+		}
+		c.Abort()
+	}
+}
+
 // CheckShareUnlocked 检查分享是否已解锁
 func CheckShareUnlocked() gin.HandlerFunc {
 	return func(c *gin.Context) {
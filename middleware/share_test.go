@@ -3,6 +3,7 @@ package middleware
 import (
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	model "github.com/cloudreve/Cloudreve/v3/models"
@@ -35,8 +36,8 @@ func TestShareAvailable(t *testing.T) {
 		mock.ExpectQuery("SELECT(.+)shares(.+)").
 			WillReturnRows(
 				sqlmock.NewRows(
-					[]string{"id", "remain_downloads", "source_id"}).
-					AddRow(1, 1, 2),
+					[]string{"id", "remain_downloads", "remain_views", "source_id"}).
+					AddRow(1, 1, -1, 2),
 			)
 		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
 		c, _ := gin.CreateTestContext(rec)
@@ -49,6 +50,26 @@ func TestShareAvailable(t *testing.T) {
 		asserts.NotNil(c.Get("user"))
 		asserts.NotNil(c.Get("share"))
 	}
+
+	// 未到生效时间
+	{
+		mock.ExpectQuery("SELECT(.+)shares(.+)").
+			WillReturnRows(
+				sqlmock.NewRows(
+					[]string{"id", "remain_downloads", "remain_views", "source_id", "user_id", "activates_at"}).
+					AddRow(1, 1, -1, 2, 1, time.Now().Add(time.Hour)),
+			)
+		mock.ExpectQuery("SELECT(.+)users(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "status", "group_id"}).AddRow(1, model.Active, 3))
+		mock.ExpectQuery("SELECT(.+)groups(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		c, _ := gin.CreateTestContext(rec)
+		c.Params = []gin.Param{
+			{"id", "x9T4"},
+		}
+		testFunc(c)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.True(c.IsAborted())
+	}
 }
 
 func TestShareCanPreview(t *testing.T) {
@@ -80,6 +101,161 @@ func TestShareCanPreview(t *testing.T) {
 	}
 }
 
+func TestShareCanEmbed(t *testing.T) {
+	asserts := assert.New(t)
+	rec := httptest.NewRecorder()
+	testFunc := ShareCanEmbed()
+
+	// 无分享上下文
+	{
+		c, _ := gin.CreateTestContext(rec)
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+
+	// 可嵌入
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("share", &model.Share{EmbedDomains: "example.com", Permissions: model.SharePermissionView})
+		testFunc(c)
+		asserts.False(c.IsAborted())
+	}
+
+	// 未设置允许嵌入的域名
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("share", &model.Share{Permissions: model.SharePermissionView})
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+}
+
+func TestShareEmbedReferer(t *testing.T) {
+	asserts := assert.New(t)
+	rec := httptest.NewRecorder()
+	testFunc := ShareEmbedReferer()
+	newContext := func(referer, origin string) *gin.Context {
+		c, _ := gin.CreateTestContext(rec)
+		c.Params = gin.Params{{Key: "id", Value: "key"}}
+		c.Request = httptest.NewRequest("GET", "http://cloudreve.org/api/v3/share/embed/key/content", nil)
+		if referer != "" {
+			c.Request.Header.Set("Referer", referer)
+		}
+		if origin != "" {
+			c.Request.Header.Set("Origin", origin)
+		}
+		return c
+	}
+
+	// 来自本分享的嵌入页
+	{
+		c := newContext("http://cloudreve.org/api/v3/share/embed/key", "")
+		testFunc(c)
+		asserts.False(c.IsAborted())
+	}
+
+	// 来自本分享的嵌入页，携带同源 Origin
+	{
+		c := newContext("http://cloudreve.org/api/v3/share/embed/key", "http://cloudreve.org")
+		testFunc(c)
+		asserts.False(c.IsAborted())
+	}
+
+	// 无 Referer
+	{
+		c := newContext("", "")
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+
+	// 来自其他站点
+	{
+		c := newContext("http://example.com/api/v3/share/embed/key", "")
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+
+	// 来自其他分享的嵌入页
+	{
+		c := newContext("http://cloudreve.org/api/v3/share/embed/other", "")
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+
+	// Origin 为其他站点
+	{
+		c := newContext("http://cloudreve.org/api/v3/share/embed/key", "http://example.com")
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+}
+
+func TestShareCanDownload(t *testing.T) {
+	asserts := assert.New(t)
+	rec := httptest.NewRecorder()
+	testFunc := ShareCanDownload()
+
+	// 无分享上下文
+	{
+		c, _ := gin.CreateTestContext(rec)
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+
+	// 未设置权限的分享可以下载
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("share", &model.Share{})
+		testFunc(c)
+		asserts.False(c.IsAborted())
+	}
+
+	// 仅可预览
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("share", &model.Share{Permissions: model.SharePermissionView})
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+}
+
+func TestShareCanUpload(t *testing.T) {
+	asserts := assert.New(t)
+	rec := httptest.NewRecorder()
+	testFunc := ShareCanUpload()
+
+	// 无分享上下文
+	{
+		c, _ := gin.CreateTestContext(rec)
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+
+	// 可以上传
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("share", &model.Share{IsDir: true, AllowUpload: true})
+		testFunc(c)
+		asserts.False(c.IsAborted())
+	}
+
+	// 未开启上传
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("share", &model.Share{IsDir: true})
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+
+	// 文件分享
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("share", &model.Share{AllowUpload: true})
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+}
+
 func TestCheckShareUnlocked(t *testing.T) {
 	asserts := assert.New(t)
 	rec := httptest.NewRecorder()
@@ -0,0 +1,88 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 操作动态的类型
+const (
+	ActivityUpload = "upload"
+	ActivityRename = "rename"
+	ActivityMove   = "move"
+	ActivityDelete = "delete"
+	ActivityShare  = "share"
+)
+
+// Activity 用户对自己空间内文件、目录的操作动态
+type Activity struct {
+	gorm.Model
+	UserID   uint   `gorm:"index:activity_user_id"`
+	Type     string `gorm:"size:32"`
+	ObjectID uint
+	IsDir    bool
+	Name     string // 操作时对象的名称
+	Path     string `gorm:"type:text"` // 操作时对象所在的目录，未知时为空
+	Detail   string `gorm:"type:text"` // 重命名后的名称、移动的目的目录等附加信息
+}
+
+// NewFileActivity 生成文件的操作动态
+func NewFileActivity(activityType string, file *File, detail string) Activity {
+	return Activity{
+		UserID:   file.UserID,
+		Type:     activityType,
+		ObjectID: file.ID,
+		Name:     file.Name,
+		Path:     file.Position,
+		Detail:   detail,
+	}
+}
+
+// NewFolderActivity 生成目录的操作动态
+func NewFolderActivity(activityType string, folder *Folder, detail string) Activity {
+	return Activity{
+		UserID:   folder.OwnerID,
+		Type:     activityType,
+		ObjectID: folder.ID,
+		IsDir:    true,
+		Name:     folder.Name,
+		Path:     folder.Position,
+		Detail:   detail,
+	}
+}
+
+// CreateActivities 在同一事务中保存多条操作动态
+func CreateActivities(activities []Activity) error {
+	tx := DB.Begin()
+	for i := range activities {
+		if err := tx.Create(&activities[i]).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// ListActivities 按时间倒序分页列出用户的操作动态
+func ListActivities(uid uint, page, pageSize int) ([]Activity, int) {
+	var (
+		activities []Activity
+		total      int
+	)
+	dbChain := DB.Where("user_id = ?", uid)
+
+	// 计算总数用于分页
+	dbChain.Model(&Activity{}).Count(&total)
+
+	// 查询记录
+	dbChain.Limit(pageSize).Offset((page - 1) * pageSize).Order("id desc").Find(&activities)
+
+	return activities, total
+}
+
+// DeleteActivitiesBefore 删除 before 之前的操作动态
+func DeleteActivitiesBefore(before time.Time) error {
+	return DB.Unscoped().Where("created_at < ?", before).Delete(&Activity{}).Error
+}
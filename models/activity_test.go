@@ -0,0 +1,85 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewActivity(t *testing.T) {
+	a := assert.New(t)
+
+	// 文件
+	{
+		file := &File{Name: "1.txt", UserID: 1, Position: "/docs"}
+		file.ID = 2
+		activity := NewFileActivity(ActivityRename, file, "2.txt")
+		a.EqualValues(1, activity.UserID)
+		a.EqualValues(2, activity.ObjectID)
+		a.False(activity.IsDir)
+		a.Equal("1.txt", activity.Name)
+		a.Equal("/docs", activity.Path)
+		a.Equal("2.txt", activity.Detail)
+	}
+
+	// 目录
+	{
+		folder := &Folder{Name: "docs", OwnerID: 1, Position: "/"}
+		folder.ID = 3
+		activity := NewFolderActivity(ActivityDelete, folder, "")
+		a.EqualValues(1, activity.UserID)
+		a.EqualValues(3, activity.ObjectID)
+		a.True(activity.IsDir)
+		a.Equal(ActivityDelete, activity.Type)
+	}
+}
+
+func TestCreateActivities(t *testing.T) {
+	a := assert.New(t)
+	activities := []Activity{{UserID: 1, Type: ActivityUpload}, {UserID: 1, Type: ActivityDelete}}
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)activities(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT(.+)activities(.+)").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+		a.NoError(CreateActivities(activities))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)activities(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(CreateActivities([]Activity{{UserID: 1}}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestListActivities(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)activities(.+)").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery("SELECT(.+)activities(.+)").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(2))
+	res, total := ListActivities(1, 1, 2)
+	a.NoError(mock.ExpectationsWereMet())
+	a.Equal(3, total)
+	a.Len(res, 2)
+}
+
+func TestDeleteActivitiesBefore(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)activities(.+)").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	a.NoError(DeleteActivitiesBefore(time.Now()))
+	a.NoError(mock.ExpectationsWereMet())
+}
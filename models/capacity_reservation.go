@@ -0,0 +1,97 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// CapacityReservation 上传过程中为用户预留的容量，避免并发上传同时通过容量校验后超出配额。
+// 多个主机实例共享预留记录，过期的预留视为不存在
+type CapacityReservation struct {
+	gorm.Model
+	Token     string `gorm:"unique_index:capacity_reservation_token"`
+	UserID    uint   `gorm:"index:capacity_reservation_user_id"`
+	Size      uint64
+	ExpiresAt time.Time `gorm:"index:capacity_reservation_expires_at"`
+}
+
+// ErrReservationExceeded 已用容量与预留容量之和超出限制
+var ErrReservationExceeded = errors.New("reserved capacity exceeds the limit")
+
+// GetCapacityReservation 获取 token 对应的未过期预留
+func GetCapacityReservation(token string) (*CapacityReservation, error) {
+	var reservation CapacityReservation
+	result := DB.Where("token = ? and expires_at > ?", token, time.Now()).First(&reservation)
+	return &reservation, result.Error
+}
+
+// ReserveCapacity 在事务中检查并为用户预留 size 大小的容量，替换 token 已有的预留。
+// 用户的已用容量、其他未过期的预留与 size 之和超出 max 时返回 ErrReservationExceeded。
+// 返回数据库中用户当前的已用容量
+func ReserveCapacity(uid uint, token string, size, max uint64, ttl time.Duration) (uint64, error) {
+	tx := DB.Begin()
+
+	// 锁定用户记录，同一用户的预留依次进行
+	if err := tx.Model(&User{}).Where("id = ?", uid).UpdateColumn("storage", gorm.Expr("storage")).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	var storage uint64
+	if err := tx.Model(&User{}).Where("id = ?", uid).Select("storage").Row().Scan(&storage); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	now := time.Now()
+	var reserved struct {
+		Size uint64
+	}
+	if err := tx.Model(&CapacityReservation{}).
+		Where("user_id = ? and token <> ? and expires_at > ?", uid, token, now).
+		Select("COALESCE(SUM(size), 0) as size").Scan(&reserved).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if storage+reserved.Size+size > max {
+		tx.Rollback()
+		return storage, ErrReservationExceeded
+	}
+
+	// 顺带清理已过期的预留
+	if err := tx.Unscoped().Where("token = ? or expires_at <= ?", token, now).Delete(&CapacityReservation{}).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := tx.Create(&CapacityReservation{
+		Token:     token,
+		UserID:    uid,
+		Size:      size,
+		ExpiresAt: now.Add(ttl),
+	}).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return storage, tx.Commit().Error
+}
+
+// ConfirmCapacityReservation 已用容量增加 size 后从预留中扣除相应部分，扣完后删除预留
+func ConfirmCapacityReservation(token string, size uint64) error {
+	result := DB.Model(&CapacityReservation{}).Where("token = ? and size > ?", token, size).
+		UpdateColumn("size", gorm.Expr("size - ?", size))
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+
+	return ReleaseCapacityReservation(token)
+}
+
+// ReleaseCapacityReservation 删除 token 对应的预留
+func ReleaseCapacityReservation(token string) error {
+	return DB.Unscoped().Where("token = ?", token).Delete(&CapacityReservation{}).Error
+}
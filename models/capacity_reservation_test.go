@@ -0,0 +1,130 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCapacityReservation(t *testing.T) {
+	a := assert.New(t)
+
+	// 存在
+	{
+		mock.ExpectQuery("SELECT(.+)capacity_reservations(.+)").WithArgs("a", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "size"}).AddRow(1, 2, 3))
+		res, err := GetCapacityReservation("a")
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.EqualValues(2, res.UserID)
+		a.EqualValues(3, res.Size)
+	}
+
+	// 不存在或已过期
+	{
+		mock.ExpectQuery("SELECT(.+)capacity_reservations(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		_, err := GetCapacityReservation("a")
+		a.NoError(mock.ExpectationsWereMet())
+		a.ErrorIs(err, gorm.ErrRecordNotFound)
+	}
+}
+
+func TestReserveCapacity(t *testing.T) {
+	a := assert.New(t)
+
+	// 成功，替换原有预留并清理过期预留
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)users(.+)storage").WithArgs(1).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT(.+)storage(.+)").WillReturnRows(sqlmock.NewRows([]string{"storage"}).AddRow(5))
+		mock.ExpectQuery("SELECT(.+)SUM(.+)capacity_reservations(.+)").WithArgs(1, "a", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"size"}).AddRow(2))
+		mock.ExpectExec("DELETE(.+)capacity_reservations(.+)").WithArgs("a", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT(.+)capacity_reservations(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		storage, err := ReserveCapacity(1, "a", 3, 10, time.Minute)
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.EqualValues(5, storage)
+	}
+
+	// 超出容量
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)users(.+)storage").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT(.+)storage(.+)").WillReturnRows(sqlmock.NewRows([]string{"storage"}).AddRow(5))
+		mock.ExpectQuery("SELECT(.+)SUM(.+)capacity_reservations(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"size"}).AddRow(3))
+		mock.ExpectRollback()
+		storage, err := ReserveCapacity(1, "a", 3, 10, time.Minute)
+		a.NoError(mock.ExpectationsWereMet())
+		a.ErrorIs(err, ErrReservationExceeded)
+		a.EqualValues(5, storage)
+	}
+
+	// 无法读取已用容量
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)users(.+)storage").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT(.+)storage(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		_, err := ReserveCapacity(1, "a", 3, 10, time.Minute)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Error(err)
+	}
+
+	// 创建预留失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)users(.+)storage").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT(.+)storage(.+)").WillReturnRows(sqlmock.NewRows([]string{"storage"}).AddRow(0))
+		mock.ExpectQuery("SELECT(.+)SUM(.+)capacity_reservations(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"size"}).AddRow(0))
+		mock.ExpectExec("DELETE(.+)capacity_reservations(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT(.+)capacity_reservations(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		_, err := ReserveCapacity(1, "a", 3, 10, time.Minute)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Error(err)
+	}
+}
+
+func TestConfirmCapacityReservation(t *testing.T) {
+	a := assert.New(t)
+
+	// 扣除部分预留
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)capacity_reservations(.+)size - (.+)").WithArgs(2, "a", 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		a.NoError(ConfirmCapacityReservation("a", 2))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 扣完后删除
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)capacity_reservations(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)capacity_reservations(.+)").WithArgs("a").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		a.NoError(ConfirmCapacityReservation("a", 5))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 更新失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)capacity_reservations(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(ConfirmCapacityReservation("a", 5))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 变更记录的类型
+const (
+	ChangeCreated  = "created"
+	ChangeModified = "modified"
+	ChangeDeleted  = "deleted"
+)
+
+// Change 用户空间内目录、文件的变更记录，ID 单调递增，作为增量同步的游标
+type Change struct {
+	gorm.Model
+	UserID   uint   `gorm:"index:change_user_id"`
+	Action   string `gorm:"size:16"`
+	IsDir    bool
+	ObjectID uint   // 变更后对象的 ID，复制等无法确定新对象时为 0
+	Path     string `gorm:"type:text"` // 变更后对象的完整路径，删除时为删除前的路径
+}
+
+// NewFileChange 生成文件的变更记录，fullPath 为文件的完整路径
+func NewFileChange(action string, file *File, fullPath string) Change {
+	return Change{
+		UserID:   file.UserID,
+		Action:   action,
+		ObjectID: file.ID,
+		Path:     fullPath,
+	}
+}
+
+// NewFolderChange 生成目录的变更记录，fullPath 为目录的完整路径
+func NewFolderChange(action string, folder *Folder, fullPath string) Change {
+	return Change{
+		UserID:   folder.OwnerID,
+		Action:   action,
+		IsDir:    true,
+		ObjectID: folder.ID,
+		Path:     fullPath,
+	}
+}
+
+// CreateChanges 在同一事务中保存多条变更记录
+func CreateChanges(changes []Change) error {
+	tx := DB.Begin()
+	for i := range changes {
+		if err := tx.Create(&changes[i]).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// ListChangesAfter 按顺序列出用户在游标 cursor 之后的至多 limit 条变更记录
+func ListChangesAfter(uid, cursor uint, limit int) ([]Change, error) {
+	var changes []Change
+	result := DB.Where("user_id = ? and id > ?", uid, cursor).Order("id asc").Limit(limit).Find(&changes)
+	return changes, result.Error
+}
+
+// GetChangeCursorRange 返回现存变更记录中最小和最大的 ID，没有记录时均为 0
+func GetChangeCursorRange() (uint, uint) {
+	var first, last Change
+	DB.Order("id asc").First(&first)
+	DB.Order("id desc").First(&last)
+	return first.ID, last.ID
+}
+
+// DeleteChangesBefore 删除 before 之前的变更记录
+func DeleteChangesBefore(before time.Time) error {
+	return DB.Unscoped().Where("created_at < ?", before).Delete(&Change{}).Error
+}
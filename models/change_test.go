@@ -0,0 +1,104 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewChange(t *testing.T) {
+	a := assert.New(t)
+
+	// 文件
+	{
+		file := &File{Name: "1.txt", UserID: 1}
+		file.ID = 2
+		change := NewFileChange(ChangeModified, file, "/docs/1.txt")
+		a.EqualValues(1, change.UserID)
+		a.EqualValues(2, change.ObjectID)
+		a.False(change.IsDir)
+		a.Equal(ChangeModified, change.Action)
+		a.Equal("/docs/1.txt", change.Path)
+	}
+
+	// 目录
+	{
+		folder := &Folder{Name: "docs", OwnerID: 1}
+		folder.ID = 3
+		change := NewFolderChange(ChangeCreated, folder, "/docs")
+		a.EqualValues(1, change.UserID)
+		a.EqualValues(3, change.ObjectID)
+		a.True(change.IsDir)
+	}
+}
+
+func TestCreateChanges(t *testing.T) {
+	a := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)changes(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT(.+)changes(.+)").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+		a.NoError(CreateChanges([]Change{{UserID: 1, Action: ChangeDeleted}, {UserID: 1, Action: ChangeCreated}}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)changes(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(CreateChanges([]Change{{UserID: 1}}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestListChangesAfter(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)changes(.+)").WithArgs(1, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6).AddRow(8))
+	res, err := ListChangesAfter(1, 5, 10)
+	a.NoError(mock.ExpectationsWereMet())
+	a.NoError(err)
+	a.Len(res, 2)
+}
+
+func TestGetChangeCursorRange(t *testing.T) {
+	a := assert.New(t)
+
+	// 有记录
+	{
+		mock.ExpectQuery("SELECT(.+)changes(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectQuery("SELECT(.+)changes(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+		first, last := GetChangeCursorRange()
+		a.NoError(mock.ExpectationsWereMet())
+		a.EqualValues(3, first)
+		a.EqualValues(9, last)
+	}
+
+	// 无记录
+	{
+		mock.ExpectQuery("SELECT(.+)changes(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)changes(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		first, last := GetChangeCursorRange()
+		a.NoError(mock.ExpectationsWereMet())
+		a.EqualValues(0, first)
+		a.EqualValues(0, last)
+	}
+}
+
+func TestDeleteChangesBefore(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)changes(.+)").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	a.NoError(DeleteChangesBefore(time.Now()))
+	a.NoError(mock.ExpectationsWereMet())
+}
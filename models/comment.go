@@ -0,0 +1,67 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// Comment 文件评论，ParentID 不为 0 时为对顶层评论的回复
+type Comment struct {
+	gorm.Model
+	FileID   uint   `gorm:"index:comment_file_id"`
+	UserID   uint   // 发表评论的用户
+	ParentID uint   // 所属顶层评论的ID
+	ShareID  uint   // 经由分享发表时为分享ID，评论对该分享的访客可见；为 0 时仅文件所有者可见
+	Content  string `gorm:"type:text"`
+
+	// 数据库忽略字段
+	User User `gorm:"PRELOAD:false,association_autoupdate:false"`
+}
+
+// Create 创建评论
+func (comment *Comment) Create() (uint, error) {
+	if err := DB.Create(comment).Error; err != nil {
+		return 0, err
+	}
+	return comment.ID, nil
+}
+
+// Delete 删除评论及其全部回复
+func (comment *Comment) Delete() error {
+	return DB.Where("id = ? or parent_id = ?", comment.ID, comment.ID).Delete(&Comment{}).Error
+}
+
+// GetCommentByID 根据ID查找评论
+func GetCommentByID(id uint) (*Comment, error) {
+	var comment Comment
+	result := DB.First(&comment, id)
+	return &comment, result.Error
+}
+
+// GetCommentsByFileID 按时间顺序列出文件上的全部评论
+func GetCommentsByFileID(fileID uint) ([]Comment, error) {
+	var comments []Comment
+	result := DB.Preload("User").Where("file_id = ?", fileID).Order("id").Find(&comments)
+	return comments, result.Error
+}
+
+// GetShareComments 按时间顺序列出经由分享发表在文件上的评论
+func GetShareComments(fileID, shareID uint) ([]Comment, error) {
+	var comments []Comment
+	result := DB.Preload("User").Where("file_id = ? and share_id = ?", fileID, shareID).Order("id").Find(&comments)
+	return comments, result.Error
+}
+
+// GetCommentParticipants 返回在文件指定可见范围内发表过评论的用户
+func GetCommentParticipants(fileID, shareID uint) ([]User, error) {
+	var users []User
+	result := DB.Where(
+		"id in (?)",
+		DB.Model(&Comment{}).Select("user_id").Where("file_id = ? and share_id = ?", fileID, shareID).QueryExpr(),
+	).Find(&users)
+	return users, result.Error
+}
+
+// DeleteCommentsByFileIDs 删除文件上的全部评论
+func DeleteCommentsByFileIDs(fileIDs []uint) error {
+	return DB.Unscoped().Where("file_id in (?)", fileIDs).Delete(&Comment{}).Error
+}
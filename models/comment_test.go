@@ -0,0 +1,119 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComment_Create(t *testing.T) {
+	a := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)comments(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.ExpectCommit()
+		id, err := (&Comment{FileID: 1, UserID: 1, Content: "hi"}).Create()
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.EqualValues(5, id)
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)comments(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		_, err := (&Comment{FileID: 1, UserID: 1}).Create()
+		a.NoError(mock.ExpectationsWereMet())
+		a.Error(err)
+	}
+}
+
+func TestComment_Delete(t *testing.T) {
+	a := assert.New(t)
+	comment := &Comment{Model: gorm.Model{ID: 3}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)comments(.+)").WithArgs(sqlmock.AnyArg(), 3, 3).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	a.NoError(comment.Delete())
+	a.NoError(mock.ExpectationsWereMet())
+}
+
+func TestGetCommentByID(t *testing.T) {
+	a := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)comments(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "file_id"}).AddRow(3, 1))
+		comment, err := GetCommentByID(3)
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.EqualValues(1, comment.FileID)
+	}
+
+	// 不存在
+	{
+		mock.ExpectQuery("SELECT(.+)comments(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		_, err := GetCommentByID(4)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Error(err)
+	}
+}
+
+func TestGetComments(t *testing.T) {
+	a := assert.New(t)
+
+	// 文件上的全部评论
+	{
+		mock.ExpectQuery("SELECT(.+)comments(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(1, 2).AddRow(2, 3))
+		mock.ExpectQuery("SELECT(.+)users(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "nick"}).AddRow(2, "a").AddRow(3, "b"))
+		comments, err := GetCommentsByFileID(1)
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.Len(comments, 2)
+		a.Equal("b", comments[1].User.Nick)
+	}
+
+	// 经由分享发表的评论
+	{
+		mock.ExpectQuery("SELECT(.+)comments(.+)").WithArgs(1, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(1, 2))
+		mock.ExpectQuery("SELECT(.+)users(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "nick"}).AddRow(2, "a"))
+		comments, err := GetShareComments(1, 5)
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.Len(comments, 1)
+	}
+}
+
+func TestGetCommentParticipants(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)users(.+)comments(.+)").WithArgs(1, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nick"}).AddRow(2, "a"))
+	users, err := GetCommentParticipants(1, 5)
+	a.NoError(mock.ExpectationsWereMet())
+	a.NoError(err)
+	a.Len(users, 1)
+}
+
+func TestDeleteCommentsByFileIDs(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)comments(.+)").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	a.NoError(DeleteCommentsByFileIDs([]uint{1, 2}))
+	a.NoError(mock.ExpectationsWereMet())
+}
@@ -27,6 +27,7 @@ var defaultSettings = []Setting{
 	{Name: "smtpPass", Value: ``, Type: "mail"},
 	{Name: "smtpEncryption", Value: `0`, Type: "mail"},
 	{Name: "maxEditSize", Value: `52428800`, Type: "file_edit"},
+	{Name: "text_preview_max_size", Value: `1048576`, Type: "file_edit"},
 	{Name: "archive_timeout", Value: `600`, Type: "timeout"},
 	{Name: "download_timeout", Value: `600`, Type: "timeout"},
 	{Name: "preview_timeout", Value: `600`, Type: "timeout"},
@@ -37,14 +38,28 @@ var defaultSettings = []Setting{
 	{Name: "slave_ping_interval", Value: `60`, Type: "slave"},
 	{Name: "slave_recover_interval", Value: `120`, Type: "slave"},
 	{Name: "slave_transfer_timeout", Value: `172800`, Type: "timeout"},
+	{Name: "restore_timeout", Value: `172800`, Type: "timeout"},
+	{Name: "restore_poll_interval", Value: `60`, Type: "timeout"},
 	{Name: "onedrive_monitor_timeout", Value: `600`, Type: "timeout"},
 	{Name: "share_download_session_timeout", Value: `2073600`, Type: "timeout"},
+	{Name: "share_anonymous_upload_window", Value: `3600`, Type: "timeout"},
+	{Name: "share_password_attempt_window", Value: `600`, Type: "timeout"},
+	{Name: "share_report_window", Value: `3600`, Type: "timeout"},
+	{Name: "share_embed_timeout", Value: `3600`, Type: "timeout"},
 	{Name: "onedrive_callback_check", Value: `20`, Type: "timeout"},
 	{Name: "folder_props_timeout", Value: `300`, Type: "timeout"},
 	{Name: "chunk_retries", Value: `5`, Type: "retry"},
 	{Name: "onedrive_source_timeout", Value: `1800`, Type: "timeout"},
 	{Name: "reset_after_upload_failed", Value: `0`, Type: "upload"},
 	{Name: "use_temp_chunk_buffer", Value: `1`, Type: "upload"},
+	{Name: "clamd_address", Value: ``, Type: "virus_scan"},
+	{Name: "clamd_max_scan_size", Value: `104857600`, Type: "virus_scan"},
+	{Name: "clamd_skip_oversize", Value: `0`, Type: "virus_scan"},
+	{Name: "clamd_timeout", Value: `60`, Type: "timeout"},
+	{Name: "upload_webhook_urls", Value: ``, Type: "upload_webhook"},
+	{Name: "upload_webhook_secret", Value: ``, Type: "upload_webhook"},
+	{Name: "upload_webhook_blocking", Value: `0`, Type: "upload_webhook"},
+	{Name: "upload_webhook_timeout", Value: `10`, Type: "timeout"},
 	{Name: "login_captcha", Value: `0`, Type: "login"},
 	{Name: "reg_captcha", Value: `0`, Type: "login"},
 	{Name: "email_active", Value: `0`, Type: "register"},
@@ -63,13 +78,32 @@ box-sizing: border-box; font-size: 14px; margin: 0;"><td style="font-family: 'He
 solid #e9e9e9;"bgcolor="#fff"><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size:
 14px; margin: 0;"><td class="alert alert-warning"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 16px; vertical-align: top; color: #fff; font-weight: 500; text-align: center; border-radius: 3px 3px 0 0; background-color: #2196F3; margin: 0; padding: 20px;"align="center"bgcolor="#FF9F00"valign="top">重设{siteTitle}密码</td></tr><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="content-wrap"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0; padding: 20px;"valign="top"><table width="100%"cellpadding="0"cellspacing="0"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="content-block"style="font-family: 'Helvetica
 Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0; padding: 0 0 20px;"valign="top">亲爱的<strong style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;">{userName}</strong>：</td></tr><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="content-block"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0; padding: 0 0 20px;"valign="top">请点击下方按钮完成密码重设。如果非你本人操作，请忽略此邮件。</td></tr><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="content-block"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0; padding: 0 0 20px;"valign="top"><a href="{resetUrl}"class="btn-primary"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; color: #FFF; text-decoration: none; line-height: 2em; font-weight: bold; text-align: center; cursor: pointer; display: inline-block; border-radius: 5px; text-transform: capitalize; background-color: #2196F3; margin: 0; border-color: #2196F3; border-style: solid; border-width: 10px 20px;">重设密码</a></td></tr><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="content-block"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0; padding: 0 0 20px;"valign="top">感谢您选择{siteTitle}。</td></tr></table></td></tr></table><div class="footer"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; width: 100%; clear: both; color: #999; margin: 0; padding: 20px;"><table width="100%"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="aligncenter content-block"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 12px; vertical-align: top; color: #999; text-align: center; margin: 0; padding: 0 0 20px;"align="center"valign="top">此邮件由系统自动发送，请不要直接回复。</td></tr></table></div></div></td><td style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0;"valign="top"></td></tr></table></body></html>`, Type: "mail_template"},
+	{Name: "mail_mention_template", Value: `<!DOCTYPE html><html><body style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; font-size: 14px; color: #333; background-color: #f6f6f6; margin: 0; padding: 20px;"><div style="max-width: 600px; margin: 0 auto; background-color: #fff; border-radius: 3px; padding: 20px;"><p>亲爱的<strong>{userName}</strong>：</p><p><strong>{actorName}</strong> 在文件 <strong>{fileName}</strong> 的评论中提到了你：</p><blockquote style="border-left: 4px solid #2196F3; margin: 0 0 20px; padding: 0 12px; color: #666;">{content}</blockquote><p><a href="{commentUrl}" style="color: #FFF; text-decoration: none; font-weight: bold; display: inline-block; border-radius: 5px; background-color: #2196F3; padding: 8px 20px;">查看评论</a></p><p>感谢您选择{siteTitle}。</p><p style="font-size: 12px; color: #999; text-align: center;">此邮件由系统自动发送，请不要直接回复。</p></div></body></html>`, Type: "mail_template"},
+	{Name: "mail_share_access_template", Value: `<!DOCTYPE html><html><body style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; font-size: 14px; color: #333; background-color: #f6f6f6; margin: 0; padding: 20px;"><div style="max-width: 600px; margin: 0 auto; background-color: #fff; border-radius: 3px; padding: 20px;"><p>亲爱的<strong>{userName}</strong>：</p><p>你的以下分享近期有新的访问：</p><ul style="padding-left: 20px; color: #666;">{shares}</ul><p><a href="{siteUrl}" style="color: #FFF; text-decoration: none; font-weight: bold; display: inline-block; border-radius: 5px; background-color: #2196F3; padding: 8px 20px;">访问{siteTitle}</a></p><p>可在分享管理页面关闭访问通知。</p><p style="font-size: 12px; color: #999; text-align: center;">此邮件由系统自动发送，请不要直接回复。</p></div></body></html>`, Type: "mail_template"},
 	{Name: "db_version_" + conf.RequiredDBVersion, Value: `installed`, Type: "version"},
 	{Name: "hot_share_num", Value: `10`, Type: "share"},
+	{Name: "share_anonymous_upload_limit", Value: `20`, Type: "share"},
+	{Name: "share_password_attempt_limit", Value: `10`, Type: "share"},
+	{Name: "share_report_limit", Value: `10`, Type: "share"},
+	{Name: "share_short_link_provider", Value: ``, Type: "share"},
+	{Name: "share_short_link_domain", Value: ``, Type: "share"},
+	{Name: "share_short_link_api", Value: ``, Type: "share"},
+	{Name: "share_qrcode_size", Value: `256`, Type: "share"},
+	{Name: "share_watermark_font", Value: ``, Type: "share"},
+	{Name: "share_watermark_max_size", Value: `20971520`, Type: "share"},
+	{Name: "share_watermark_pdf_max_pages", Value: `20`, Type: "share"},
+	{Name: "share_watermark_pdf_dpi", Value: `96`, Type: "share"},
+	{Name: "share_slug_reserved", Value: `admin,api,app,download,home,login,s,search,setting,share,signup,static,user`, Type: "share"},
 	{Name: "gravatar_server", Value: `https://www.gravatar.com/`, Type: "avatar"},
 	{Name: "defaultTheme", Value: `#3f51b5`, Type: "basic"},
 	{Name: "themes", Value: `{"#3f51b5":{"palette":{"primary":{"main":"#3f51b5"},"secondary":{"main":"#f50057"}}},"#2196f3":{"palette":{"primary":{"main":"#2196f3"},"secondary":{"main":"#FFC107"}}},"#673AB7":{"palette":{"primary":{"main":"#673AB7"},"secondary":{"main":"#2196F3"}}},"#E91E63":{"palette":{"primary":{"main":"#E91E63"},"secondary":{"main":"#42A5F5","contrastText":"#fff"}}},"#FF5722":{"palette":{"primary":{"main":"#FF5722"},"secondary":{"main":"#3F51B5"}}},"#FFC107":{"palette":{"primary":{"main":"#FFC107"},"secondary":{"main":"#26C6DA"}}},"#8BC34A":{"palette":{"primary":{"main":"#8BC34A","contrastText":"#fff"},"secondary":{"main":"#FF8A65","contrastText":"#fff"}}},"#009688":{"palette":{"primary":{"main":"#009688"},"secondary":{"main":"#4DD0E1","contrastText":"#fff"}}},"#607D8B":{"palette":{"primary":{"main":"#607D8B"},"secondary":{"main":"#F06292"}}},"#795548":{"palette":{"primary":{"main":"#795548"},"secondary":{"main":"#4CAF50","contrastText":"#fff"}}}}`, Type: "basic"},
 	{Name: "max_worker_num", Value: `10`, Type: "task"},
 	{Name: "max_parallel_transfer", Value: `4`, Type: "task"},
+	{Name: "batch_task_threshold", Value: `100`, Type: "task"},
+	{Name: "relocate_concurrency", Value: `4`, Type: "task"},
+	{Name: "file_lock_max_ttl", Value: `3600`, Type: "lock"},
+	{Name: "file_property_max_num", Value: `64`, Type: "property"},
+	{Name: "file_property_max_size", Value: `16384`, Type: "property"},
 	{Name: "secret_key", Value: util.RandStringRunes(256), Type: "auth"},
 	{Name: "temp_path", Value: "temp", Type: "path"},
 	{Name: "avatar_path", Value: "avatar", Type: "path"},
@@ -81,6 +115,16 @@ Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; verti
 	{Name: "share_view_method", Value: "list", Type: "view"},
 	{Name: "cron_garbage_collect", Value: "@hourly", Type: "cron"},
 	{Name: "cron_recycle_upload_session", Value: "@every 1h30m", Type: "cron"},
+	{Name: "cron_storage_tiering", Value: "@daily", Type: "cron"},
+	{Name: "cron_mirror_repair", Value: "@every 30m", Type: "cron"},
+	{Name: "cron_slave_health", Value: "@every 1m", Type: "cron"},
+	{Name: "cron_flush_traffic", Value: "@every 1m", Type: "cron"},
+	{Name: "cron_purge_trash", Value: "@hourly", Type: "cron"},
+	{Name: "cron_purge_activity", Value: "@daily", Type: "cron"},
+	{Name: "cron_expire_objects", Value: "@every 10m", Type: "cron"},
+	{Name: "cron_purge_change_log", Value: "@daily", Type: "cron"},
+	{Name: "cron_purge_share_access", Value: "@daily", Type: "cron"},
+	{Name: "cron_notify_share_access", Value: "@every 30m", Type: "cron"},
 	{Name: "authn_enabled", Value: "0", Type: "authn"},
 	{Name: "captcha_type", Value: "normal", Type: "captcha"},
 	{Name: "captcha_height", Value: "60", Type: "captcha"},
@@ -121,9 +165,31 @@ Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; verti
 	{Name: "thumb_proxy_enabled", Value: "0", Type: "thumb"},
 	{Name: "thumb_proxy_policy", Value: "[]", Type: "thumb"},
 	{Name: "thumb_max_src_size", Value: "31457280", Type: "thumb"},
+	{Name: "hls_enabled", Value: "0", Type: "hls"},
+	{Name: "hls_ffmpeg_path", Value: "ffmpeg", Type: "hls"},
+	{Name: "hls_exts", Value: "3g2,3gp,avi,flv,m2ts,m4v,mkv,mov,mp4,mpeg,mpg,mts,ogv,webm,wmv", Type: "hls"},
+	{Name: "hls_ladder", Value: "1080:5000,720:2800,480:1400", Type: "hls"},
+	{Name: "hls_segment_time", Value: "6", Type: "hls"},
+	{Name: "hls_file_suffix", Value: "._hls", Type: "hls"},
+	{Name: "trash_enabled", Value: "1", Type: "trash"},
+	{Name: "trash_retention_days", Value: "30", Type: "trash"},
+	{Name: "activity_retention_days", Value: "90", Type: "activity"},
+	{Name: "change_log_retention_days", Value: "30", Type: "sync"},
+	{Name: "share_access_retention_days", Value: "90", Type: "share"},
+	{Name: "media_transcode_enabled", Value: "0", Type: "media_transcode"},
+	{Name: "media_transcode_ffmpeg_path", Value: "ffmpeg", Type: "media_transcode"},
+	{Name: "media_transcode_video_exts", Value: "3g2,3gp,avi,flv,m2ts,mkv,mpeg,mpg,mts,ts,wmv", Type: "media_transcode"},
+	{Name: "media_transcode_audio_exts", Value: "ac3,aiff,ape,dts,flac,wma,wv", Type: "media_transcode"},
+	{Name: "media_transcode_timeout", Value: "600", Type: "media_transcode"},
+	{Name: "media_transcode_file_suffix", Value: "._transcoded", Type: "media_transcode"},
 	{Name: "thumb_libraw_path", Value: "simple_dcraw", Type: "thumb"},
 	{Name: "thumb_libraw_enabled", Value: "0", Type: "thumb"},
 	{Name: "thumb_libraw_exts", Value: "arw,raf,dng", Type: "thumb"},
+	{Name: "thumb_pdf_path", Value: "pdftoppm", Type: "thumb"},
+	{Name: "thumb_pdf_enabled", Value: "0", Type: "thumb"},
+	{Name: "thumb_pdf_exts", Value: "pdf", Type: "thumb"},
+	{Name: "thumb_async_enabled", Value: "1", Type: "thumb"},
+	{Name: "photo_exif_enabled", Value: "1", Type: "photo"},
 	{Name: "pwa_small_icon", Value: "/static/img/favicon.ico", Type: "pwa"},
 	{Name: "pwa_medium_icon", Value: "/static/img/logo192.png", Type: "pwa"},
 	{Name: "pwa_large_icon", Value: "/static/img/logo512.png", Type: "pwa"},
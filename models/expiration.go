@@ -0,0 +1,90 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 对象到期后的处理方式
+const (
+	ExpireActionDelete = "delete"
+	ExpireActionTrash  = "trash"
+)
+
+// Expiration 文件或目录的到期规则，到期后由定时任务删除或移入回收站
+type Expiration struct {
+	gorm.Model
+	UserID    uint      `gorm:"index:expiration_user_id"`
+	ObjectID  uint      `gorm:"unique_index:idx_expiration_object"`
+	IsDir     bool      `gorm:"unique_index:idx_expiration_object"`
+	ExpiresAt time.Time `gorm:"index:expiration_expires_at"`
+	Action    string    `gorm:"size:16"`
+}
+
+// SetExpirations 为用户的目录和文件设定到期规则，已有的规则被覆盖
+func SetExpirations(uid uint, dirs, files []uint, expiresAt time.Time, action string) error {
+	tx := DB.Begin()
+	set := func(id uint, isDir bool) error {
+		return tx.Unscoped().Where("object_id = ? and is_dir = ?", id, isDir).
+			Assign(Expiration{UserID: uid, ExpiresAt: expiresAt, Action: action}).
+			FirstOrCreate(&Expiration{ObjectID: id, IsDir: isDir}).Error
+	}
+
+	for _, id := range dirs {
+		if err := set(id, true); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	for _, id := range files {
+		if err := set(id, false); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// RemoveExpirations 移除用户目录和文件上的到期规则
+func RemoveExpirations(uid uint, dirs, files []uint) error {
+	if len(dirs) > 0 {
+		if err := DB.Unscoped().Where("user_id = ? and is_dir = ? and object_id in (?)", uid, true, dirs).
+			Delete(&Expiration{}).Error; err != nil {
+			return err
+		}
+	}
+
+	if len(files) > 0 {
+		return DB.Unscoped().Where("user_id = ? and is_dir = ? and object_id in (?)", uid, false, files).
+			Delete(&Expiration{}).Error
+	}
+
+	return nil
+}
+
+// DeleteExpirationsByObjects 删除已删除对象的到期规则
+func DeleteExpirationsByObjects(ids []uint, isDir bool) error {
+	return DB.Unscoped().Where("is_dir = ? and object_id in (?)", isDir, ids).Delete(&Expiration{}).Error
+}
+
+// GetExpirationsByUser 按到期时间列出用户设定的全部到期规则
+func GetExpirationsByUser(uid uint) ([]Expiration, error) {
+	var expirations []Expiration
+	result := DB.Where("user_id = ?", uid).Order("expires_at").Find(&expirations)
+	return expirations, result.Error
+}
+
+// GetDueExpirations 列出在 before 之前到期的规则，按用户分组排列
+func GetDueExpirations(before time.Time) ([]Expiration, error) {
+	var expirations []Expiration
+	result := DB.Where("expires_at <= ?", before).Order("user_id, expires_at").Find(&expirations)
+	return expirations, result.Error
+}
+
+// Delete 删除到期规则
+func (expiration *Expiration) Delete() error {
+	return DB.Unscoped().Delete(expiration).Error
+}
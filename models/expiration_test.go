@@ -0,0 +1,123 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetExpirations(t *testing.T) {
+	a := assert.New(t)
+	expiresAt := time.Now().Add(time.Hour)
+
+	// 新建与覆盖
+	{
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT(.+)expirations(.+)").
+			WithArgs(1, true).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("INSERT(.+)expirations(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT(.+)expirations(.+)").
+			WithArgs(2, false).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "object_id"}).AddRow(5, 2))
+		mock.ExpectExec("UPDATE(.+)expirations(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		a.NoError(SetExpirations(1, []uint{1}, []uint{2}, expiresAt, ExpireActionTrash))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT(.+)expirations(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(SetExpirations(1, nil, []uint{2}, expiresAt, ExpireActionDelete))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestRemoveExpirations(t *testing.T) {
+	a := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)expirations(.+)").
+			WithArgs(1, true, 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)expirations(.+)").
+			WithArgs(1, false, 4).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		a.NoError(RemoveExpirations(1, []uint{3}, []uint{4}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 目录失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)expirations(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(RemoveExpirations(1, []uint{3}, []uint{4}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 无对象
+	{
+		a.NoError(RemoveExpirations(1, nil, nil))
+	}
+}
+
+func TestDeleteExpirationsByObjects(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)expirations(.+)").
+		WithArgs(true, 1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	a.NoError(DeleteExpirationsByObjects([]uint{1, 2}, true))
+	a.NoError(mock.ExpectationsWereMet())
+}
+
+func TestGetExpirationsByUser(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)expirations(.+)").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "object_id"}).AddRow(1, 2).AddRow(2, 3))
+	res, err := GetExpirationsByUser(1)
+	a.NoError(mock.ExpectationsWereMet())
+	a.NoError(err)
+	a.Len(res, 2)
+}
+
+func TestGetDueExpirations(t *testing.T) {
+	a := assert.New(t)
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT(.+)expirations(.+)").
+		WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(1, 1))
+	res, err := GetDueExpirations(now)
+	a.NoError(mock.ExpectationsWereMet())
+	a.NoError(err)
+	a.Len(res, 1)
+}
+
+func TestExpiration_Delete(t *testing.T) {
+	a := assert.New(t)
+	expiration := &Expiration{}
+	expiration.ID = 1
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)expirations(.+)").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	a.NoError(expiration.Delete())
+	a.NoError(mock.ExpectationsWereMet())
+}
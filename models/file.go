@@ -23,10 +23,17 @@ type File struct {
 	UserID          uint   `gorm:"index:user_id;unique_index:idx_only_one"`
 	Size            uint64
 	PicInfo         string
-	FolderID        uint `gorm:"index:folder_id;unique_index:idx_only_one"`
-	PolicyID        uint
+	FolderID        uint    `gorm:"index:folder_id;unique_index:idx_only_one"`
+	PolicyID        uint    `gorm:"index:policy_hash"`
 	UploadSessionID *string `gorm:"index:session_id;unique_index:session_only_one"`
 	Metadata        string  `gorm:"type:text"`
+	Hash            string  `gorm:"size:64;index:policy_hash"` // 文件内容的 SHA-256，用于秒传
+	MD5             string  `gorm:"size:32"`
+	SHA1            string  `gorm:"size:40"`
+	// 最后访问时间，仅在存储策略按访问时间分层时记录
+	AccessedAt *time.Time
+	// 是否被用户加星标
+	Starred bool `gorm:"index:file_starred"`
 
 	// 关联模型
 	Policy Policy `gorm:"PRELOAD:false,association_autoupdate:false"`
@@ -46,8 +53,37 @@ const (
 	ThumbSidecarMetadataKey = "thumb_sidecar"
 
 	ChecksumMetadataKey = "webdav_checksum"
+
+	// CompressedFromMetadataKey 图片压缩前的原始大小
+	CompressedFromMetadataKey = "compressed_from"
+
+	// StorageClassMetadataKey 文件上传时使用的存储类型
+	StorageClassMetadataKey = "storage_class"
+
+	// HLSMetadataKey 视频转码生成的 HLS 文件列表，逗号分隔，路径相对于 HLS 目录
+	HLSMetadataKey = "hls"
+
+	// TranscodedMetadataKey 在线播放时转换得到的文件格式，文件内容变化后清除
+	TranscodedMetadataKey = "transcoded"
+
+	// AudioTagsMetadataKey 音频文件的标签信息，JSON 格式，文件内容变化后清除
+	AudioTagsMetadataKey = "audio_tags"
+
+	// IntegrityMetadataKey 最近一次完整性校验发现的问题，missing 或 corrupt，文件内容变化后清除
+	IntegrityMetadataKey = "integrity"
 )
 
+// AudioTags 音频文件的标签信息
+type AudioTags struct {
+	Title  string `json:"title"`
+	Artist string `json:"artist"`
+	Album  string `json:"album"`
+	Cover  bool   `json:"cover"`
+}
+
+// ErrFileChanged 文件在迁移期间被修改或删除
+var ErrFileChanged = errors.New("file changed during migration")
+
 func init() {
 	// 注册缓存用到的复杂结构
 	gob.Register(File{})
@@ -73,6 +109,20 @@ func (file *File) Create() error {
 	return tx.Commit().Error
 }
 
+// AfterCreate 创建文件记录后，在同一事务中更新所在目录的统计
+func (file *File) AfterCreate(tx *gorm.DB) error {
+	folder := &Folder{}
+	folder.ID = file.FolderID
+	return folder.ChangeSize(tx, "+", file.Size, 1)
+}
+
+// AfterDelete 删除文件记录后，在同一事务中更新所在目录的统计
+func (file *File) AfterDelete(tx *gorm.DB) error {
+	folder := &Folder{}
+	folder.ID = file.FolderID
+	return folder.ChangeSize(tx, "-", file.Size, 1)
+}
+
 // AfterFind 找到文件后的钩子
 func (file *File) AfterFind() (err error) {
 	// 反序列化文件元数据
@@ -120,6 +170,19 @@ func (folder *Folder) GetChildFiles() ([]File, error) {
 	return files, result.Error
 }
 
+// ListChildFiles 按选项列出目录下的文件
+func (folder *Folder) ListChildFiles(option *ChildListOption) ([]File, error) {
+	var files []File
+	result := option.apply(DB.Where("folder_id = ?", folder.ID)).Find(&files)
+
+	if result.Error == nil {
+		for i := 0; i < len(files); i++ {
+			files[i].Position = path.Join(folder.Position, folder.Name)
+		}
+	}
+	return files, result.Error
+}
+
 // GetFilesByIDs 根据文件ID批量获取文件,
 // UID为0表示忽略用户，只根据文件ID检索
 func GetFilesByIDs(ids []uint, uid uint) ([]File, error) {
@@ -137,6 +200,18 @@ func GetFilesByIDsFromTX(tx *gorm.DB, ids []uint, uid uint) ([]File, error) {
 	return files, result.Error
 }
 
+// GetStarredFiles 获取用户加了星标的全部文件
+func GetStarredFiles(uid uint) ([]File, error) {
+	var files []File
+	result := DB.Where("user_id = ? and starred = ?", uid, true).Order("name").Find(&files)
+	return files, result.Error
+}
+
+// SetFilesStarred 为用户的文件添加或移除星标，不改变文件的修改日期
+func SetFilesStarred(ids []uint, uid uint, starred bool) error {
+	return DB.Model(&File{}).Where("id in (?) and user_id = ?", ids, uid).UpdateColumn("starred", starred).Error
+}
+
 // GetFilesByKeywords 根据关键字搜索文件,
 // UID为0表示忽略用户，只根据文件ID检索. 如果 parents 非空， 则只限制在 parent 包含的目录下搜索
 func GetFilesByKeywords(uid uint, parents []uint, keywords ...interface{}) ([]File, error) {
@@ -202,6 +277,16 @@ func (file *File) GetPolicy() *Policy {
 	return &file.Policy
 }
 
+// IsRetained 返回文件是否处于存储策略设定的保留期内，上传中的占位文件不受限制
+func (file *File) IsRetained() bool {
+	if file.UploadSessionID != nil {
+		return false
+	}
+
+	days := file.GetPolicy().OptionsSerialized.RetentionDays
+	return days > 0 && time.Now().Before(file.CreatedAt.AddDate(0, 0, int(days)))
+}
+
 // RemoveFilesWithSoftLinks 去除给定的文件列表中有软链接的文件
 func RemoveFilesWithSoftLinks(files []File) ([]File, error) {
 	// 结果值
@@ -211,15 +296,32 @@ func RemoveFilesWithSoftLinks(files []File) ([]File, error) {
 		return filteredFiles, nil
 	}
 
-	// 查询软链接的文件
+	// 同批次的文件不视为软链接，以免共用物理文件的文件一同删除时物理文件被遗留
+	ids := make([]uint, len(files))
+	for i := range files {
+		ids[i] = files[i].ID
+	}
+
+	// 查询软链接的文件，回收站中的文件及历史版本同样视为软链接
 	filesWithSoftLinks := make([]File, 0)
 	for _, file := range files {
 		var softLinkFile File
-		res := DB.
-			Where("source_name = ? and policy_id = ? and id != ?", file.SourceName, file.PolicyID, file.ID).
+		res := DB.Unscoped().
+			Where("source_name = ? and policy_id = ? and id not in (?)", file.SourceName, file.PolicyID, ids).
 			First(&softLinkFile)
 		if res.Error == nil {
 			filesWithSoftLinks = append(filesWithSoftLinks, softLinkFile)
+			continue
+		}
+
+		// 同批次文件的历史版本随后一并删除，届时再清理不再被引用的物理文件
+		var count int
+		if err := DB.Model(&FileVersion{}).Where("source_name = ? and policy_id = ?", file.SourceName, file.PolicyID).
+			Count(&count).Error; err != nil {
+			return filteredFiles, err
+		}
+		if count > 0 {
+			filesWithSoftLinks = append(filesWithSoftLinks, file)
 		}
 	}
 
@@ -297,6 +399,214 @@ func GetFilesByUploadSession(sessionID string, uid uint) (*File, error) {
 	return &file, result.Error
 }
 
+// GetFileByHash 根据内容哈希和大小查找用户在同一存储策略下已上传完成的文件
+func GetFileByHash(uid, policyID uint, hash string, size uint64) (*File, error) {
+	file := File{}
+	result := DB.
+		Where("user_id = ? and policy_id = ? and hash = ? and size = ? and upload_session_id is NULL", uid, policyID, hash, size).
+		First(&file)
+	return &file, result.Error
+}
+
+// GetBlobByHash 查找同一存储策略下内容相同、但物理文件不是 source 的已上传完成文件，用于去重存储
+func GetBlobByHash(policyID uint, hash string, size uint64, source string) (*File, error) {
+	file := File{}
+	result := DB.
+		Where("policy_id = ? and hash = ? and size = ? and source_name != ? and upload_session_id is NULL",
+			policyID, hash, size, source).
+		First(&file)
+	return &file, result.Error
+}
+
+// GetTieringCandidates 获取存储策略中满足分层规则、需迁移至目标策略的文件
+func GetTieringCandidates(policyID uint, rule *TieringRule, limit int) ([]File, error) {
+	query := DB.Where("policy_id = ? and upload_session_id is NULL", policyID)
+	now := time.Now()
+	if rule.MinAgeDays > 0 {
+		query = query.Where("created_at < ?", now.AddDate(0, 0, -int(rule.MinAgeDays)))
+	}
+
+	// 从未记录过访问时间的文件以上传时间计算
+	if rule.MinIdleDays > 0 {
+		before := now.AddDate(0, 0, -int(rule.MinIdleDays))
+		query = query.Where("(accessed_at < ? or (accessed_at is NULL and created_at < ?))", before, before)
+	}
+
+	if rule.MinSize > 0 {
+		query = query.Where("size >= ?", rule.MinSize)
+	}
+
+	if rule.MaxSize > 0 {
+		query = query.Where("size <= ?", rule.MaxSize)
+	}
+
+	var files []File
+	result := query.Order("id").Limit(limit).Find(&files)
+	return files, result.Error
+}
+
+// GetFilesByPolicy 按 ID 顺序分批获取存储策略中 ID 大于 after 的文件，uid 为 0 时不限制用户
+func GetFilesByPolicy(policyID, uid, after uint, limit int) ([]File, error) {
+	query := DB.Where("policy_id = ? and upload_session_id is NULL and id > ?", policyID, after)
+	if uid > 0 {
+		query = query.Where("user_id = ?", uid)
+	}
+
+	var files []File
+	result := query.Order("id").Limit(limit).Find(&files)
+	return files, result.Error
+}
+
+// DuplicateDigest 同一存储策略中内容相同的一组文件的统计
+type DuplicateDigest struct {
+	PolicyID uint
+	Hash     string
+	Size     uint64
+	Files    int // 文件数
+	Blobs    int // 引用的不同物理文件数
+}
+
+// GetDuplicateDigests 统计用户在各存储策略中摘要和大小均相同的文件，仅返回包含多个文件的组
+func GetDuplicateDigests(uid uint) ([]DuplicateDigest, error) {
+	var digests []DuplicateDigest
+	result := DB.Model(&File{}).
+		Select("policy_id, hash, size, COUNT(*) as files, COUNT(DISTINCT source_name) as blobs").
+		Where("user_id = ? and hash != '' and size > 0 and upload_session_id is NULL", uid).
+		Group("policy_id, hash, size").
+		Having("COUNT(*) > 1").
+		Order("size desc").
+		Scan(&digests)
+	return digests, result.Error
+}
+
+// GetFilesByHashes 获取用户摘要为 hashes 之一的文件
+func GetFilesByHashes(uid uint, hashes []string) ([]File, error) {
+	var files []File
+	result := DB.Where("user_id = ? and hash in (?) and upload_session_id is NULL", uid, hashes).
+		Order("id").Find(&files)
+	return files, result.Error
+}
+
+// CountFilesWithoutDigest 统计用户尚未计算摘要的非空文件数
+func CountFilesWithoutDigest(uid uint) (int, error) {
+	var count int
+	result := DB.Model(&File{}).
+		Where("user_id = ? and (hash = '' or hash is NULL) and size > 0 and upload_session_id is NULL", uid).
+		Count(&count)
+	return count, result.Error
+}
+
+// GetFilesWithoutDigest 按 ID 顺序获取用户 ID 大于 after 且尚未计算摘要的非空文件
+func GetFilesWithoutDigest(uid, after uint, limit int) ([]File, error) {
+	var files []File
+	result := DB.Where("user_id = ? and (hash = '' or hash is NULL) and size > 0 and upload_session_id is NULL and id > ?", uid, after).
+		Order("id").Limit(limit).Find(&files)
+	return files, result.Error
+}
+
+// GetFilesToVerify 按 ID 顺序获取 ID 大于 after 的非空文件，用于完整性校验，
+// uid 和 policyID 不为 0 时只列出对应用户或存储策略中的文件
+func GetFilesToVerify(uid, policyID, after uint, limit int) ([]File, error) {
+	var files []File
+	dbChain := DB.Where("size > 0 and upload_session_id is NULL and id > ?", after)
+	if uid > 0 {
+		dbChain = dbChain.Where("user_id = ?", uid)
+	}
+	if policyID > 0 {
+		dbChain = dbChain.Where("policy_id = ?", policyID)
+	}
+
+	result := dbChain.Order("id").Limit(limit).Find(&files)
+	return files, result.Error
+}
+
+// GetUserFilesAfter 按 ID 顺序获取用户 ID 大于 after 的已上传完成文件
+func GetUserFilesAfter(uid, after uint, limit int) ([]File, error) {
+	var files []File
+	result := DB.Where("user_id = ? and upload_session_id is NULL and id > ?", uid, after).
+		Order("id").Limit(limit).Find(&files)
+	return files, result.Error
+}
+
+// IsSourceReferenced 返回是否有文件记录（包括回收站中的文件）或历史版本引用存储策略中的给定物理文件
+func IsSourceReferenced(policyID uint, source string) (bool, error) {
+	var count int
+	result := DB.Unscoped().Model(&File{}).Where("policy_id = ? and source_name = ?", policyID, source).Count(&count)
+	if result.Error != nil || count > 0 {
+		return count > 0, result.Error
+	}
+
+	result = DB.Model(&FileVersion{}).Where("policy_id = ? and source_name = ?", policyID, source).Count(&count)
+	return count > 0, result.Error
+}
+
+// Touch 记录文件的访问时间，距上次记录不足一天时不更新
+func (file *File) Touch() error {
+	if file.AccessedAt != nil && time.Since(*file.AccessedAt) < 24*time.Hour {
+		return nil
+	}
+
+	now := time.Now()
+	file.AccessedAt = &now
+	return DB.Model(file).UpdateColumn("accessed_at", now).Error
+}
+
+// MigrateSource 将所有引用此文件物理文件的记录切换至新的存储策略和存储路径，
+// 文件在迁移期间被修改或删除时返回 ErrFileChanged
+func (file *File) MigrateSource(policyID uint, source string) error {
+	// 回收站中的文件同样引用物理文件
+	tx := DB.Begin().Unscoped()
+	var files []File
+	if err := tx.Where("policy_id = ? and source_name = ?", file.PolicyID, file.SourceName).Find(&files).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	changed := true
+	for _, f := range files {
+		if f.ID == file.ID {
+			changed = f.Size != file.Size || !f.UpdatedAt.Equal(file.UpdatedAt)
+			break
+		}
+	}
+
+	if changed {
+		tx.Rollback()
+		return ErrFileChanged
+	}
+
+	for i := range files {
+		// 缩略图位于原存储策略中，需重新生成
+		delete(files[i].MetadataSerialized, ThumbStatusMetadataKey)
+		delete(files[i].MetadataSerialized, ThumbSidecarMetadataKey)
+		delete(files[i].MetadataSerialized, HLSMetadataKey)
+		delete(files[i].MetadataSerialized, TranscodedMetadataKey)
+		metaValue, err := json.Marshal(&files[i].MetadataSerialized)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Model(&files[i]).UpdateColumns(map[string]interface{}{
+			"policy_id":   policyID,
+			"source_name": source,
+			"metadata":    string(metaValue),
+		}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	file.PolicyID = policyID
+	file.SourceName = source
+	file.Policy = Policy{}
+	return nil
+}
+
 // Rename 重命名文件
 func (file *File) Rename(new string) error {
 	if file.MetadataSerialized[ThumbStatusMetadataKey] == ThumbStatusNotAvailable {
@@ -314,6 +624,15 @@ func (file *File) Rename(new string) error {
 	}).Error
 }
 
+// UpdateDigest 更新文件内容的摘要，文件内容变化但无法得到新摘要时传入空值清除
+func (file *File) UpdateDigest(md5, sha1, sha256 string) error {
+	return DB.Model(&file).Set("gorm:association_autoupdate", false).UpdateColumns(map[string]interface{}{
+		"md5":  md5,
+		"sha1": sha1,
+		"hash": sha256,
+	}).Error
+}
+
 // UpdatePicInfo 更新文件的图像信息
 func (file *File) UpdatePicInfo(value string) error {
 	return DB.Model(&file).Set("gorm:association_autoupdate", false).UpdateColumns(File{PicInfo: value}).Error
@@ -372,6 +691,13 @@ func (file *File) UpdateSize(value uint64) error {
 		return err
 	}
 
+	folder := &Folder{}
+	folder.ID = file.FolderID
+	if err := folder.ChangeSize(tx, operator, sizeDelta, 0); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	file.Size = value
 	return tx.Commit().Error
 }
@@ -425,12 +751,20 @@ func (file *File) CreateOrGetSourceLink() (*SourceLink, error) {
 	return res, nil
 }
 
+// resetThumb 清除缩略图状态、完整性校验结果及依赖文件内容解析的音频标签
 func (file *File) resetThumb() error {
-	if _, ok := file.MetadataSerialized[ThumbStatusMetadataKey]; !ok {
+	changed := false
+	for _, key := range []string{ThumbStatusMetadataKey, AudioTagsMetadataKey, TranscodedMetadataKey, IntegrityMetadataKey} {
+		if _, ok := file.MetadataSerialized[key]; ok {
+			delete(file.MetadataSerialized, key)
+			changed = true
+		}
+	}
+
+	if !changed {
 		return nil
 	}
 
-	delete(file.MetadataSerialized, ThumbStatusMetadataKey)
 	metaValue, err := json.Marshal(&file.MetadataSerialized)
 	file.Metadata = string(metaValue)
 	return err
@@ -470,3 +804,71 @@ func (file *File) ShouldLoadThumb() bool {
 func (file *File) ThumbFile() string {
 	return file.SourceName + GetSettingByNameWithDefault("thumb_file_suffix", "._thumb")
 }
+
+// HLSFile 返回视频转码生成的 HLS 文件的存储路径，name 为相对于 HLS 目录的路径
+func (file *File) HLSFile(name string) string {
+	return file.SourceName + GetSettingByNameWithDefault("hls_file_suffix", "._hls") + "/" + name
+}
+
+// HLSFiles 返回视频转码生成的所有 HLS 文件的存储路径
+func (file *File) HLSFiles() []string {
+	if file.MetadataSerialized[HLSMetadataKey] == "" {
+		return nil
+	}
+
+	names := strings.Split(file.MetadataSerialized[HLSMetadataKey], ",")
+	res := make([]string, 0, len(names))
+	for _, name := range names {
+		res = append(res, file.HLSFile(name))
+	}
+
+	return res
+}
+
+// HasHLSFile 返回 name 是否为视频转码生成的 HLS 文件
+func (file *File) HasHLSFile(name string) bool {
+	if file.MetadataSerialized[HLSMetadataKey] == "" {
+		return false
+	}
+
+	return util.ContainsString(strings.Split(file.MetadataSerialized[HLSMetadataKey], ","), name)
+}
+
+// TranscodedFile 返回在线播放时转换为 format 格式的文件的存储路径
+func (file *File) TranscodedFile(format string) string {
+	return file.SourceName + GetSettingByNameWithDefault("media_transcode_file_suffix", "._transcoded") + "." + format
+}
+
+// TranscodedFiles 返回在线播放时转换得到的所有文件的存储路径
+func (file *File) TranscodedFiles() []string {
+	if file.MetadataSerialized[TranscodedMetadataKey] == "" {
+		return nil
+	}
+
+	return []string{file.TranscodedFile(file.MetadataSerialized[TranscodedMetadataKey])}
+}
+
+// AudioTags 返回已解析的音频标签，尚未解析时 ok 为 false
+func (file *File) AudioTags() (tags *AudioTags, ok bool) {
+	raw, ok := file.MetadataSerialized[AudioTagsMetadataKey]
+	if !ok {
+		return nil, false
+	}
+
+	tags = &AudioTags{}
+	if err := json.Unmarshal([]byte(raw), tags); err != nil {
+		return nil, false
+	}
+
+	return tags, true
+}
+
+// UpdateAudioTags 将解析得到的音频标签保存到文件元数据
+func (file *File) UpdateAudioTags(tags *AudioTags) error {
+	raw, err := json.Marshal(tags)
+	if err != nil {
+		return err
+	}
+
+	return file.UpdateMetadata(map[string]string{AudioTagsMetadataKey: string(raw)})
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// FileProperty 用户为文件设定的自定义元数据
+type FileProperty struct {
+	gorm.Model
+	UserID uint   `gorm:"index:file_property_user_id"`
+	FileID uint   `gorm:"unique_index:idx_file_property"`
+	Name   string `gorm:"size:255;unique_index:idx_file_property"`
+	Value  string `gorm:"type:text"`
+}
+
+// GetFileProperties 按名称顺序列出文件的全部自定义元数据
+func GetFileProperties(fileID uint) ([]FileProperty, error) {
+	var props []FileProperty
+	result := DB.Where("file_id = ?", fileID).Order("name").Find(&props)
+	return props, result.Error
+}
+
+// UpdateFileProperties 在同一事务中移除并设定文件的自定义元数据，已有的同名元数据被覆盖
+func UpdateFileProperties(uid, fileID uint, set map[string]string, remove []string) error {
+	tx := DB.Begin()
+	if len(remove) > 0 {
+		if err := tx.Unscoped().Where("file_id = ? and name in (?)", fileID, remove).
+			Delete(&FileProperty{}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	for name, value := range set {
+		if err := tx.Unscoped().Where(FileProperty{FileID: fileID, Name: name}).
+			Assign(FileProperty{UserID: uid, Value: value}).
+			FirstOrCreate(&FileProperty{}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// DeleteFilePropertiesByFileIDs 删除文件的全部自定义元数据
+func DeleteFilePropertiesByFileIDs(fileIDs []uint) error {
+	return DB.Unscoped().Where("file_id in (?)", fileIDs).Delete(&FileProperty{}).Error
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFileProperties(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)file_properties(.+)").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "value"}).AddRow("author", "foo"))
+	props, err := GetFileProperties(1)
+	a.NoError(mock.ExpectationsWereMet())
+	a.NoError(err)
+	a.Len(props, 1)
+	a.Equal("foo", props[0].Value)
+}
+
+func TestUpdateFileProperties(t *testing.T) {
+	a := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)file_properties(.+)").
+			WithArgs(2, "old").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT(.+)file_properties(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("INSERT(.+)file_properties(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		a.NoError(UpdateFileProperties(1, 2, map[string]string{"author": "foo"}, []string{"old"}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT(.+)file_properties(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(UpdateFileProperties(1, 2, map[string]string{"author": "foo"}, nil))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestDeleteFilePropertiesByFileIDs(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)file_properties(.+)").
+		WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	a.NoError(DeleteFilePropertiesByFileIDs([]uint{1, 2}))
+	a.NoError(mock.ExpectationsWereMet())
+}
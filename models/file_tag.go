@@ -0,0 +1,90 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// FileTag 附加在单个文件上的标签
+type FileTag struct {
+	gorm.Model
+	UserID uint   `gorm:"index:file_tag_user_id"`
+	FileID uint   `gorm:"unique_index:idx_file_tag"`
+	Name   string `gorm:"size:255;unique_index:idx_file_tag"`
+}
+
+// FileFilter 文件筛选条件，各项条件需同时满足
+type FileFilter struct {
+	// 文件名需匹配的模式，每组中任一模式匹配即可
+	NameGroups [][]string
+	// 文件需包含的全部标签
+	Tags []string
+}
+
+// AddFileTags 为用户的文件添加标签，已有的标签保持不变
+func AddFileTags(uid uint, fileIDs []uint, names []string) error {
+	tx := DB.Begin()
+	for _, fileID := range fileIDs {
+		for _, name := range names {
+			if err := tx.Where(FileTag{FileID: fileID, Name: name}).
+				Attrs(FileTag{UserID: uid}).
+				FirstOrCreate(&FileTag{}).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// RemoveFileTags 移除用户文件上的标签
+func RemoveFileTags(uid uint, fileIDs []uint, names []string) error {
+	return DB.Unscoped().
+		Where("user_id = ? and file_id in (?) and name in (?)", uid, fileIDs, names).
+		Delete(&FileTag{}).Error
+}
+
+// DeleteFileTagsByFileIDs 删除文件的全部标签
+func DeleteFileTagsByFileIDs(fileIDs []uint) error {
+	return DB.Unscoped().Where("file_id in (?)", fileIDs).Delete(&FileTag{}).Error
+}
+
+// GetFileTagNames 按名称顺序列出用户使用过的全部文件标签
+func GetFileTagNames(uid uint) ([]string, error) {
+	names := make([]string, 0)
+	result := DB.Model(&FileTag{}).Where("user_id = ?", uid).Order("name").Pluck("DISTINCT name", &names)
+	return names, result.Error
+}
+
+// GetFilesByFilter 查找用户在 parents 目录中满足筛选条件的文件，parents 为空时不限制目录
+func GetFilesByFilter(uid uint, parents []uint, filter *FileFilter) ([]File, error) {
+	var files []File
+	query := DB.Where("user_id = ?", uid)
+	if len(parents) > 0 {
+		query = query.Where("folder_id in (?)", parents)
+	}
+
+	for _, group := range filter.NameGroups {
+		if len(group) == 0 {
+			continue
+		}
+
+		conditions := make([]string, len(group))
+		values := make([]interface{}, len(group))
+		for i := range group {
+			conditions[i] = "name like ?"
+			values[i] = group[i]
+		}
+		query = query.Where("("+strings.Join(conditions, " or ")+")", values...)
+	}
+
+	for _, tag := range filter.Tags {
+		query = query.Where("id in ?",
+			DB.Model(&FileTag{}).Select("file_id").Where("user_id = ? and name = ?", uid, tag).SubQuery())
+	}
+
+	result := query.Find(&files)
+	return files, result.Error
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddFileTags(t *testing.T) {
+	a := assert.New(t)
+
+	// 标签已存在时不重复创建
+	{
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT(.+)file_tags(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery("SELECT(.+)file_tags(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("INSERT(.+)file_tags(.+)").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+		a.NoError(AddFileTags(1, []uint{1, 2}, []string{"receipts"}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 创建失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT(.+)file_tags(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("INSERT(.+)file_tags(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(AddFileTags(1, []uint{1}, []string{"receipts"}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestRemoveFileTags(t *testing.T) {
+	a := assert.New(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)file_tags(.+)").WithArgs(1, 2, "receipts").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	a.NoError(RemoveFileTags(1, []uint{2}, []string{"receipts"}))
+	a.NoError(mock.ExpectationsWereMet())
+}
+
+func TestGetFileTagNames(t *testing.T) {
+	a := assert.New(t)
+	mock.ExpectQuery("SELECT DISTINCT name(.+)file_tags(.+)").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("a").AddRow("b"))
+	names, err := GetFileTagNames(1)
+	a.NoError(err)
+	a.NoError(mock.ExpectationsWereMet())
+	a.Equal([]string{"a", "b"}, names)
+}
+
+func TestGetFilesByFilter(t *testing.T) {
+	a := assert.New(t)
+	filter := &FileFilter{
+		NameGroups: [][]string{{"%.jpg", "%.png"}},
+		Tags:       []string{"receipts"},
+	}
+
+	mock.ExpectQuery("SELECT(.+)files(.+)folder_id in(.+)name like(.+)or name like(.+)id in \\(SELECT file_id FROM `file_tags`[^(]+\\(\\(user_id = \\? and name = \\?\\)\\)\\)\\)").
+		WithArgs(1, 2, "%.jpg", "%.png", 1, "receipts").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	files, err := GetFilesByFilter(1, []uint{2}, filter)
+	a.NoError(err)
+	a.NoError(mock.ExpectationsWereMet())
+	a.Len(files, 1)
+}
@@ -155,6 +155,72 @@ func TestFolder_GetChildFiles(t *testing.T) {
 
 }
 
+func TestFolder_ListChildFiles(t *testing.T) {
+	asserts := assert.New(t)
+	folder := &Folder{
+		Model: gorm.Model{
+			ID: 1,
+		},
+		Name: "/",
+	}
+
+	// 游标之后，按大小正序
+	{
+		mock.ExpectQuery("SELECT(.+)folder_id = (.+)size > (.+)size = (.+)id > (.+)ORDER BY size asc,id asc LIMIT 2").
+			WithArgs(1, uint64(10), uint64(10), 3).
+			WillReturnRows(sqlmock.NewRows([]string{"name", "id", "size"}).AddRow("1.txt", 4, 10).AddRow("2.txt", 5, 20))
+		files, err := folder.ListChildFiles(&ChildListOption{OrderBy: "size", Limit: 2, AfterValue: uint64(10), AfterID: 3})
+		asserts.NoError(err)
+		asserts.Len(files, 2)
+		asserts.Equal("/", files[0].Position)
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 出错
+	{
+		mock.ExpectQuery("SELECT(.+)folder_id(.+)").WillReturnError(errors.New("error"))
+		_, err := folder.ListChildFiles(&ChildListOption{})
+		asserts.Error(err)
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestGetFilesToVerify(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 不限制用户和存储策略
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)size > 0(.+)ORDER BY(.+)id").
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+		files, err := GetFilesToVerify(0, 0, 5, 10)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(files, 1)
+	}
+
+	// 限制用户和存储策略
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)user_id = (.+)policy_id = (.+)").
+			WithArgs(0, 1, 2).
+			WillReturnError(errors.New("error"))
+		_, err := GetFilesToVerify(1, 2, 0, 10)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
+func TestGetUserFilesAfter(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)files(.+)user_id = (.+)upload_session_id is NULL(.+)ORDER BY(.+)id").
+		WithArgs(1, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6).AddRow(7))
+	files, err := GetUserFilesAfter(1, 5, 10)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(files, 2)
+}
+
 func TestGetFilesByIDs(t *testing.T) {
 	asserts := assert.New(t)
 
@@ -285,6 +351,31 @@ func TestFile_GetPolicy(t *testing.T) {
 	}
 }
 
+func TestFile_IsRetained(t *testing.T) {
+	a := assert.New(t)
+	sessionID := "session"
+	file := File{
+		Model:  gorm.Model{ID: 1, CreatedAt: time.Now()},
+		Policy: Policy{Model: gorm.Model{ID: 1}},
+	}
+
+	// 未设定保留期
+	a.False(file.IsRetained())
+
+	// 保留期内
+	file.Policy.OptionsSerialized.RetentionDays = 1
+	a.True(file.IsRetained())
+
+	// 上传中的占位文件
+	file.UploadSessionID = &sessionID
+	a.False(file.IsRetained())
+
+	// 已过保留期
+	file.UploadSessionID = nil
+	file.CreatedAt = time.Now().AddDate(0, 0, -2)
+	a.False(file.IsRetained())
+}
+
 func TestRemoveFilesWithSoftLinks_EmptyArg(t *testing.T) {
 	asserts := assert.New(t)
 	// 传入空
@@ -323,11 +414,17 @@ func TestRemoveFilesWithSoftLinks(t *testing.T) {
 	// 全都没有
 	{
 		mock.ExpectQuery("SELECT(.+)files(.+)").
-			WithArgs("1.txt", 23, 1).
+			WithArgs("1.txt", 23, 1, 2).
 			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs("1.txt", 23).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 		mock.ExpectQuery("SELECT(.+)files(.+)").
-			WithArgs("2.txt", 24, 2).
+			WithArgs("2.txt", 24, 1, 2).
 			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs("2.txt", 24).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 		file, err := RemoveFilesWithSoftLinks(files)
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NoError(err)
@@ -337,10 +434,13 @@ func TestRemoveFilesWithSoftLinks(t *testing.T) {
 	// 第二个是软链
 	{
 		mock.ExpectQuery("SELECT(.+)files(.+)").
-			WithArgs("1.txt", 23, 1).
+			WithArgs("1.txt", 23, 1, 2).
 			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs("1.txt", 23).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 		mock.ExpectQuery("SELECT(.+)files(.+)").
-			WithArgs("2.txt", 24, 2).
+			WithArgs("2.txt", 24, 1, 2).
 			WillReturnRows(
 				sqlmock.NewRows([]string{"id", "policy_id", "source_name"}).
 					AddRow(3, 24, "2.txt"),
@@ -354,14 +454,17 @@ func TestRemoveFilesWithSoftLinks(t *testing.T) {
 	// 第一个是软链
 	{
 		mock.ExpectQuery("SELECT(.+)files(.+)").
-			WithArgs("1.txt", 23, 1).
+			WithArgs("1.txt", 23, 1, 2).
 			WillReturnRows(
 				sqlmock.NewRows([]string{"id", "policy_id", "source_name"}).
 					AddRow(3, 23, "1.txt"),
 			)
 		mock.ExpectQuery("SELECT(.+)files(.+)").
-			WithArgs("2.txt", 24, 2).
+			WithArgs("2.txt", 24, 1, 2).
 			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs("2.txt", 24).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 		file, err := RemoveFilesWithSoftLinks(files)
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NoError(err)
@@ -370,13 +473,13 @@ func TestRemoveFilesWithSoftLinks(t *testing.T) {
 	// 全部是软链
 	{
 		mock.ExpectQuery("SELECT(.+)files(.+)").
-			WithArgs("1.txt", 23, 1).
+			WithArgs("1.txt", 23, 1, 2).
 			WillReturnRows(
 				sqlmock.NewRows([]string{"id", "policy_id", "source_name"}).
 					AddRow(3, 23, "1.txt"),
 			)
 		mock.ExpectQuery("SELECT(.+)files(.+)").
-			WithArgs("2.txt", 24, 2).
+			WithArgs("2.txt", 24, 1, 2).
 			WillReturnRows(
 				sqlmock.NewRows([]string{"id", "policy_id", "source_name"}).
 					AddRow(3, 24, "2.txt"),
@@ -386,6 +489,50 @@ func TestRemoveFilesWithSoftLinks(t *testing.T) {
 		asserts.NoError(err)
 		asserts.Len(file, 0)
 	}
+
+	// 同批次的文件共用物理文件
+	{
+		shared := []File{
+			{Model: gorm.Model{ID: 1}, SourceName: "1.txt", PolicyID: 23},
+			{Model: gorm.Model{ID: 2}, SourceName: "1.txt", PolicyID: 23},
+		}
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs("1.txt", 23, 1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs("1.txt", 23).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs("1.txt", 23, 1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs("1.txt", 23).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		file, err := RemoveFilesWithSoftLinks(shared)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(shared, file)
+	}
+
+	// 物理文件被历史版本引用
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs("1.txt", 23, 1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs("1.txt", 23).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs("2.txt", 24, 1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs("2.txt", 24).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		file, err := RemoveFilesWithSoftLinks(files)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(files[1:], file)
+	}
 }
 
 func TestDeleteFiles(t *testing.T) {
@@ -490,6 +637,45 @@ func TestGetFilesByUploadSession(t *testing.T) {
 	a.Equal("4.txt", files.Name)
 }
 
+func TestGetFileByHash(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)").
+		WithArgs(2, 1, "hash", 10).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "source_name"}).AddRow(4, "4.txt"))
+	file, err := GetFileByHash(2, 1, "hash", 10)
+	a.NoError(err)
+	a.NoError(mock.ExpectationsWereMet())
+	a.Equal("4.txt", file.SourceName)
+}
+
+func TestGetBlobByHash(t *testing.T) {
+	a := assert.New(t)
+
+	// 找到
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)source_name != (.+)").
+			WithArgs(1, "hash", 10, "5.txt").
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "source_name"}).AddRow(4, "4.txt"))
+		file, err := GetBlobByHash(1, "hash", 10, "5.txt")
+		a.NoError(err)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Equal("4.txt", file.SourceName)
+	}
+
+	// 未找到
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, "hash", 10, "5.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name"}))
+		_, err := GetBlobByHash(1, "hash", 10, "5.txt")
+		a.Error(err)
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
 func TestFile_Updates(t *testing.T) {
 	asserts := assert.New(t)
 	file := File{Model: gorm.Model{ID: 1}}
@@ -783,3 +969,313 @@ func TestFile_ThumbFile(t *testing.T) {
 
 	a.Equal("test._thumb", file.ThumbFile())
 }
+
+func TestFile_HLSFiles(t *testing.T) {
+	a := assert.New(t)
+	file := &File{
+		SourceName:         "test",
+		MetadataSerialized: map[string]string{},
+	}
+
+	a.Nil(file.HLSFiles())
+	a.False(file.HasHLSFile("master.m3u8"))
+
+	file.MetadataSerialized[HLSMetadataKey] = "master.m3u8,720p/index.m3u8"
+	a.Equal([]string{"test._hls/master.m3u8", "test._hls/720p/index.m3u8"}, file.HLSFiles())
+	a.True(file.HasHLSFile("720p/index.m3u8"))
+	a.False(file.HasHLSFile("../test"))
+}
+
+func TestFile_TranscodedFiles(t *testing.T) {
+	a := assert.New(t)
+	file := &File{
+		SourceName:         "test",
+		MetadataSerialized: map[string]string{},
+	}
+
+	a.Nil(file.TranscodedFiles())
+
+	file.MetadataSerialized[TranscodedMetadataKey] = "mp4"
+	a.Equal([]string{"test._transcoded.mp4"}, file.TranscodedFiles())
+
+	// 文件内容变化后清除
+	a.NoError(file.resetThumb())
+	a.Nil(file.TranscodedFiles())
+}
+
+func TestFile_AudioTags(t *testing.T) {
+	a := assert.New(t)
+	file := &File{MetadataSerialized: map[string]string{}}
+
+	_, ok := file.AudioTags()
+	a.False(ok)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	a.NoError(file.UpdateAudioTags(&AudioTags{Title: "Song", Cover: true}))
+	a.NoError(mock.ExpectationsWereMet())
+
+	tags, ok := file.AudioTags()
+	a.True(ok)
+	a.Equal(&AudioTags{Title: "Song", Cover: true}, tags)
+
+	// 文件内容变化后清除
+	a.NoError(file.resetThumb())
+	_, ok = file.AudioTags()
+	a.False(ok)
+	a.NotContains(file.Metadata, AudioTagsMetadataKey)
+}
+
+func TestGetTieringCandidates(t *testing.T) {
+	a := assert.New(t)
+
+	// 无附加条件
+	{
+		mock.ExpectQuery("SELECT(.+)policy_id(.+)upload_session_id is NULL(.+)").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+		files, err := GetTieringCandidates(1, &TieringRule{}, 10)
+		a.NoError(err)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Len(files, 2)
+	}
+
+	// 所有条件
+	{
+		mock.ExpectQuery("SELECT(.+)created_at(.+)accessed_at(.+)size >=(.+)size <=(.+)").
+			WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 10, 20).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		files, err := GetTieringCandidates(1, &TieringRule{
+			MinAgeDays:  1,
+			MinIdleDays: 2,
+			MinSize:     10,
+			MaxSize:     20,
+		}, 10)
+		a.NoError(err)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Len(files, 1)
+	}
+}
+
+func TestFile_Touch(t *testing.T) {
+	a := assert.New(t)
+	file := &File{Model: gorm.Model{ID: 1}}
+
+	// 首次记录
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)accessed_at(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		a.NoError(file.Touch())
+		a.NoError(mock.ExpectationsWereMet())
+		a.NotNil(file.AccessedAt)
+	}
+
+	// 一天内不重复记录
+	{
+		a.NoError(file.Touch())
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestFile_MigrateSource(t *testing.T) {
+	a := assert.New(t)
+	updatedAt := time.Now()
+	newFile := func() *File {
+		return &File{
+			Model:      gorm.Model{ID: 1, UpdatedAt: updatedAt},
+			Size:       10,
+			PolicyID:   1,
+			SourceName: "1.txt",
+		}
+	}
+
+	// 查询失败
+	{
+		file := newFile()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT(.+)").WithArgs(1, "1.txt").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(file.MigrateSource(2, "2.txt"))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 文件已被删除
+	{
+		file := newFile()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT(.+)").WithArgs(1, "1.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "size", "updated_at"}).AddRow(2, 10, updatedAt))
+		mock.ExpectRollback()
+		a.ErrorIs(file.MigrateSource(2, "2.txt"), ErrFileChanged)
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 文件已被修改
+	{
+		file := newFile()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT(.+)").WithArgs(1, "1.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "size", "updated_at"}).AddRow(1, 20, updatedAt))
+		mock.ExpectRollback()
+		a.ErrorIs(file.MigrateSource(2, "2.txt"), ErrFileChanged)
+		a.NoError(mock.ExpectationsWereMet())
+		a.EqualValues(1, file.PolicyID)
+	}
+
+	// 成功，同时切换副本并清除缩略图状态
+	{
+		file := newFile()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT(.+)").WithArgs(1, "1.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "size", "updated_at", "metadata"}).
+				AddRow(1, 10, updatedAt, `{"thumb_status":"not_available","foo":"bar"}`).
+				AddRow(3, 10, updatedAt, ""))
+		mock.ExpectExec("UPDATE(.+)").WithArgs(`{"foo":"bar"}`, 2, "2.txt", 1).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)").WithArgs(sqlmock.AnyArg(), 2, "2.txt", 3).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		a.NoError(file.MigrateSource(2, "2.txt"))
+		a.NoError(mock.ExpectationsWereMet())
+		a.EqualValues(2, file.PolicyID)
+		a.Equal("2.txt", file.SourceName)
+	}
+}
+
+func TestGetFilesByPolicy(t *testing.T) {
+	a := assert.New(t)
+
+	// 所有用户
+	{
+		mock.ExpectQuery("SELECT(.+)policy_id(.+)id >(.+)").
+			WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(4))
+		files, err := GetFilesByPolicy(1, 0, 2, 10)
+		a.NoError(err)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Len(files, 2)
+	}
+
+	// 指定用户
+	{
+		mock.ExpectQuery("SELECT(.+)policy_id(.+)id >(.+)user_id(.+)").
+			WithArgs(1, 2, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		files, err := GetFilesByPolicy(1, 5, 2, 10)
+		a.NoError(err)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Len(files, 1)
+	}
+}
+
+func TestIsSourceReferenced(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectQuery("SELECT count(.+)").WithArgs(1, "1.txt").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	res, err := IsSourceReferenced(1, "1.txt")
+	a.NoError(err)
+	a.NoError(mock.ExpectationsWereMet())
+	a.True(res)
+
+	mock.ExpectQuery("SELECT count(.+)").WithArgs(1, "2.txt").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT count(.+)file_versions(.+)").WithArgs(1, "2.txt").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	res, err = IsSourceReferenced(1, "2.txt")
+	a.NoError(err)
+	a.NoError(mock.ExpectationsWereMet())
+	a.False(res)
+
+	// 被历史版本引用
+	mock.ExpectQuery("SELECT count(.+)").WithArgs(1, "3.txt").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT count(.+)file_versions(.+)").WithArgs(1, "3.txt").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	res, err = IsSourceReferenced(1, "3.txt")
+	a.NoError(err)
+	a.NoError(mock.ExpectationsWereMet())
+	a.True(res)
+}
+
+func TestGetStarredFiles(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)files(.+)starred(.+)").WithArgs(1, true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "starred"}).AddRow(1, true))
+	res, err := GetStarredFiles(1)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(res, 1)
+	asserts.True(res[0].Starred)
+}
+
+func TestSetFilesStarred(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)starred(.+)").WithArgs(true, 1, 2, 1).
+			WillReturnResult(sqlmock.NewResult(1, 2))
+		mock.ExpectCommit()
+		asserts.NoError(SetFilesStarred([]uint{1, 2}, 1, true))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(SetFilesStarred([]uint{1}, 1, false))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestGetDuplicateDigests(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)COUNT(.+)files(.+)GROUP BY(.+)HAVING(.+)").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"policy_id", "hash", "size", "files", "blobs"}).AddRow(2, "h", 10, 3, 2))
+	res, err := GetDuplicateDigests(1)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(res, 1)
+	asserts.EqualValues(2, res[0].PolicyID)
+	asserts.Equal(3, res[0].Files)
+	asserts.Equal(2, res[0].Blobs)
+}
+
+func TestGetFilesByHashes(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(1, "h1", "h2").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "hash"}).AddRow(1, "h1").AddRow(2, "h2"))
+	res, err := GetFilesByHashes(1, []string{"h1", "h2"})
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(res, 2)
+}
+
+func TestGetFilesWithoutDigest(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)files(.+)hash is NULL(.+)").WithArgs(1, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+	res, err := GetFilesWithoutDigest(1, 5, 10)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(res, 1)
+}
+
+func TestCountFilesWithoutDigest(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectQuery("SELECT count(.+)files(.+)").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	res, err := CountFilesWithoutDigest(1)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Equal(3, res)
+}
@@ -2,7 +2,9 @@ package model
 
 import (
 	"errors"
+	"fmt"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
@@ -16,21 +18,105 @@ type Folder struct {
 	Name     string `gorm:"unique_index:idx_only_one_name"`
 	ParentID *uint  `gorm:"index:parent_id;unique_index:idx_only_one_name"`
 	OwnerID  uint   `gorm:"index:owner_id"`
+	// 目录绑定的存储策略，为 0 时继承上级目录
+	PolicyID uint
+	// 目录下直接包含的文件总大小及个数，不含子目录
+	Size    uint64
+	FileNum uint
+	// 是否被用户加星标
+	Starred bool `gorm:"index:folder_starred"`
+	// 用户自定义的显示颜色、图标及描述
+	Color       string `gorm:"size:16"`
+	Icon        string `gorm:"size:64"`
+	Description string `gorm:"size:1024"`
+	// 挂载的分享ID，不为 0 时此目录为其他用户分享目录的挂载点
+	MountShareID  uint
+	MountPassword string `json:"-"`
 
 	// 数据库忽略字段
-	Position      string `gorm:"-"`
-	WebdavDstName string `gorm:"-"`
+	Position      string  `gorm:"-"`
+	WebdavDstName string  `gorm:"-"`
+	MountPoint    *Folder `gorm:"-"` // 由挂载点解析得到的分享目录所对应的挂载点
 }
 
 // Create 创建目录
 func (folder *Folder) Create() (uint, error) {
-	if err := DB.FirstOrCreate(folder, *folder).Error; err != nil {
+	_, err := folder.CreateIfNotExist()
+	return folder.ID, err
+}
+
+// CreateIfNotExist 创建目录，目录已存在时读取已有的目录。返回目录是否为新建
+func (folder *Folder) CreateIfNotExist() (bool, error) {
+	if err := DB.First(folder, *folder).Error; err == nil {
+		return false, nil
+	}
+
+	if err := DB.Create(folder).Error; err != nil {
+		// 并发创建时读取已有的目录
 		folder.Model = gorm.Model{}
-		err2 := DB.First(folder, *folder).Error
-		return folder.ID, err2
+		return false, DB.First(folder, *folder).Error
+	}
+
+	return true, nil
+}
+
+// IsMount 返回此目录是否为分享挂载点
+func (folder *Folder) IsMount() bool {
+	return folder.MountShareID != 0
+}
+
+// CreateMountPoint 在此目录下创建名为 name 的分享挂载点
+func (folder *Folder) CreateMountPoint(name string, shareID uint, password string) (*Folder, error) {
+	mount := &Folder{
+		Name:          name,
+		ParentID:      &folder.ID,
+		OwnerID:       folder.OwnerID,
+		MountShareID:  shareID,
+		MountPassword: password,
+	}
+	if err := DB.Create(mount).Error; err != nil {
+		return nil, err
 	}
 
-	return folder.ID, nil
+	mount.Position = path.Join(folder.Position, folder.Name)
+	return mount, nil
+}
+
+// CreateTree 在此目录下创建名为 name 的目录，并按 paths 创建其下的各级子目录，paths 为相对于新目录的路径。
+// 全部目录在同一事务中创建，任一目录创建失败时全部回滚
+func (folder *Folder) CreateTree(name string, paths []string) (*Folder, error) {
+	tx := DB.Begin()
+	root := &Folder{Name: name, ParentID: &folder.ID, OwnerID: folder.OwnerID}
+	if err := tx.Create(root).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// 已创建目录的相对路径到ID的映射
+	created := map[string]uint{"": root.ID}
+	for _, p := range paths {
+		parent := ""
+		for _, part := range strings.Split(p, "/") {
+			current := path.Join(parent, part)
+			if _, ok := created[current]; !ok {
+				parentID := created[parent]
+				child := Folder{Name: part, ParentID: &parentID, OwnerID: folder.OwnerID}
+				if err := tx.Create(&child).Error; err != nil {
+					tx.Rollback()
+					return nil, err
+				}
+				created[current] = child.ID
+			}
+			parent = current
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	root.Position = path.Join(folder.Position, folder.Name)
+	return root, nil
 }
 
 // GetChild 返回folder下名为name的子目录，不存在则返回错误
@@ -80,6 +166,67 @@ func (folder *Folder) GetChildFolder() ([]Folder, error) {
 	return folders, result.Error
 }
 
+// ChildListOption 列出目录下子对象时的排序、筛选与分页选项
+type ChildListOption struct {
+	OrderBy    string      // 排序字段，name、size 或 updated_at
+	Desc       bool        // 是否倒序
+	Keyword    string      // 名称中包含的关键字
+	Limit      int         // 最多列出的数量，为 0 时不限制
+	AfterValue interface{} // 游标，上一页最后一个对象的排序字段值
+	AfterID    uint        // 游标，上一页最后一个对象的 ID，为 0 时从头列出
+}
+
+// childOrderColumns 可用于排序的字段
+var childOrderColumns = map[string]bool{"name": true, "size": true, "updated_at": true}
+
+// OrderColumn 返回实际使用的排序字段，未知字段按名称排序
+func (option *ChildListOption) OrderColumn() string {
+	if childOrderColumns[option.OrderBy] {
+		return option.OrderBy
+	}
+	return "name"
+}
+
+// apply 将筛选、排序与游标条件应用到查询上，同一排序值的对象按 ID 排序以保证游标稳定
+func (option *ChildListOption) apply(db *gorm.DB) *gorm.DB {
+	column := option.OrderColumn()
+	direction, cmp := "asc", ">"
+	if option.Desc {
+		direction, cmp = "desc", "<"
+	}
+
+	if option.Keyword != "" {
+		db = db.Where("name like ?", "%"+option.Keyword+"%")
+	}
+
+	if option.AfterID > 0 {
+		db = db.Where(
+			fmt.Sprintf("(%s %s ? or (%s = ? and id %s ?))", column, cmp, column, cmp),
+			option.AfterValue, option.AfterValue, option.AfterID,
+		)
+	}
+
+	db = db.Order(column + " " + direction).Order("id " + direction)
+	if option.Limit > 0 {
+		db = db.Limit(option.Limit)
+	}
+
+	return db
+}
+
+// ListChildFolders 按选项列出子目录
+func (folder *Folder) ListChildFolders(option *ChildListOption) ([]Folder, error) {
+	var folders []Folder
+	result := option.apply(DB.Where("parent_id = ?", folder.ID)).Find(&folders)
+
+	if result.Error == nil {
+		for i := 0; i < len(folders); i++ {
+			folders[i].Position = path.Join(folder.Position, folder.Name)
+		}
+	}
+	return folders, result.Error
+}
+
 // GetRecursiveChildFolder 查找所有递归子目录，包括自身
 func GetRecursiveChildFolder(dirs []uint, uid uint, includeSelf bool) ([]Folder, error) {
 	folders := make([]Folder, 0, len(dirs))
@@ -141,6 +288,18 @@ func GetFoldersByIDs(ids []uint, uid uint) ([]Folder, error) {
 	return folders, result.Error
 }
 
+// GetStarredFolders 获取用户加了星标的全部目录
+func GetStarredFolders(uid uint) ([]Folder, error) {
+	var folders []Folder
+	result := DB.Where("owner_id = ? and starred = ?", uid, true).Order("name").Find(&folders)
+	return folders, result.Error
+}
+
+// SetFoldersStarred 为用户的目录添加或移除星标，不改变目录的修改日期
+func SetFoldersStarred(ids []uint, uid uint, starred bool) error {
+	return DB.Model(&Folder{}).Where("id in (?) and owner_id = ?", ids, uid).UpdateColumn("starred", starred).Error
+}
+
 // MoveOrCopyFileTo 将此目录下的files移动或复制至dstFolder，
 // 返回此操作新增的容量
 func (folder *Folder) MoveOrCopyFileTo(files []uint, dstFolder *Folder, isCopy bool) (uint64, error) {
@@ -169,6 +328,7 @@ func (folder *Folder) MoveOrCopyFileTo(files []uint, dstFolder *Folder, isCopy b
 			oldFile.Model = gorm.Model{}
 			oldFile.FolderID = dstFolder.ID
 			oldFile.UserID = dstFolder.OwnerID
+			oldFile.Starred = false
 
 			// webdav目标名重置
 			if dstFolder.WebdavDstName != "" {
@@ -191,8 +351,22 @@ func (folder *Folder) MoveOrCopyFileTo(files []uint, dstFolder *Folder, isCopy b
 			updates["name"] = dstFolder.WebdavDstName
 		}
 
+		tx := DB.Begin()
+
+		// 统计要移动文件的大小及个数
+		var stats folderStats
+		if err := tx.Model(File{}).Where(
+			"id in (?) and user_id = ? and folder_id = ?",
+			files,
+			folder.OwnerID,
+			folder.ID,
+		).Select("COALESCE(SUM(size), 0) as size, COUNT(*) as file_num").Scan(&stats).Error; err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
 		// 更改顶级要移动文件的父目录指向
-		err := DB.Model(File{}).Where(
+		err := tx.Model(File{}).Where(
 			"id in (?) and user_id = ? and folder_id = ?",
 			files,
 			folder.OwnerID,
@@ -201,9 +375,23 @@ func (folder *Folder) MoveOrCopyFileTo(files []uint, dstFolder *Folder, isCopy b
 			Update(updates).
 			Error
 		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
+		if err := folder.ChangeSize(tx, "-", stats.Size, stats.FileNum); err != nil {
+			tx.Rollback()
 			return 0, err
 		}
 
+		if err := dstFolder.ChangeSize(tx, "+", stats.Size, stats.FileNum); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			return 0, err
+		}
 	}
 
 	return copiedSize, nil
@@ -249,6 +437,10 @@ func (folder *Folder) CopyFolderTo(folderID uint, dstFolder *Folder) (size uint6
 		folder.Model = gorm.Model{}
 		folder.ParentID = &newID
 		folder.OwnerID = dstFolder.OwnerID
+		// 统计值由复制的文件记录重新累加
+		folder.Size = 0
+		folder.FileNum = 0
+		folder.Starred = false
 		if err = DB.Create(&folder).Error; err != nil {
 			return size, err
 		}
@@ -277,6 +469,7 @@ func (folder *Folder) CopyFolderTo(folderID uint, dstFolder *Folder) (size uint6
 		oldFile.Model = gorm.Model{}
 		oldFile.FolderID = newIDCache[oldFile.FolderID]
 		oldFile.UserID = dstFolder.OwnerID
+		oldFile.Starred = false
 		if err := DB.Create(&oldFile).Error; err != nil {
 			return size, err
 		}
@@ -318,11 +511,63 @@ func (folder *Folder) MoveFolderTo(dirs []uint, dstFolder *Folder) error {
 
 }
 
+type folderStats struct {
+	Size    uint64
+	FileNum uint
+}
+
+// ChangeSize 在事务 tx 中变更目录直接包含的文件总大小及个数
+func (folder *Folder) ChangeSize(tx *gorm.DB, operator string, size uint64, num uint) error {
+	if folder.ID == 0 {
+		return nil
+	}
+
+	expr := func(column string, value interface{}) interface{} {
+		if operator == "-" {
+			// 统计值不准确时避免无符号字段下溢
+			return gorm.Expr("CASE WHEN "+column+" > ? THEN "+column+" - ? ELSE 0 END", value, value)
+		}
+		return gorm.Expr(column+" + ?", value)
+	}
+
+	return tx.Model(&Folder{}).Where("id = ?", folder.ID).UpdateColumns(map[string]interface{}{
+		"size":     expr("size", size),
+		"file_num": expr("file_num", num),
+	}).Error
+}
+
+// CalibrateFolderSize 根据文件记录重新计算所有目录的文件总大小及个数
+func CalibrateFolderSize() error {
+	folders := DB.NewScope(&Folder{}).QuotedTableName()
+	files := DB.NewScope(&File{}).QuotedTableName()
+	sub := "(SELECT %s FROM " + files + " WHERE " + files + ".folder_id = " + folders + ".id AND " +
+		files + ".deleted_at IS NULL)"
+
+	return DB.Model(&Folder{}).UpdateColumns(map[string]interface{}{
+		"size":     gorm.Expr(fmt.Sprintf(sub, "COALESCE(SUM("+files+".size), 0)")),
+		"file_num": gorm.Expr(fmt.Sprintf(sub, "COUNT(*)")),
+	}).Error
+}
+
 // Rename 重命名目录
 func (folder *Folder) Rename(new string) error {
 	return DB.Model(&folder).UpdateColumn("name", new).Error
 }
 
+// SetPolicy 设定目录绑定的存储策略，为 0 时解除绑定
+func (folder *Folder) SetPolicy(policyID uint) error {
+	return DB.Model(&folder).UpdateColumn("policy_id", policyID).Error
+}
+
+// SetDisplay 设定目录的显示颜色、图标及描述，不改变目录的修改日期
+func (folder *Folder) SetDisplay(color, icon, description string) error {
+	return DB.Model(&folder).UpdateColumns(map[string]interface{}{
+		"color":       color,
+		"icon":        icon,
+		"description": description,
+	}).Error
+}
+
 /*
 	实现 FileInfo.FileInfo 接口
 	TODO 测试
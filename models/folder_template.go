@@ -0,0 +1,60 @@
+package model
+
+import (
+	"encoding/json"
+
+	"github.com/jinzhu/gorm"
+)
+
+// FolderTemplate 目录结构模板，UserID 为 0 的模板由管理员创建，对所有用户可见
+type FolderTemplate struct {
+	gorm.Model
+	UserID  uint `gorm:"index:folder_template_user_id"`
+	Name    string
+	Folders string `gorm:"type:text" json:"-"`
+
+	// 数据库忽略字段
+	FolderList []string `gorm:"-"` // 模板中各目录相对于实例化时新建目录的路径
+}
+
+// AfterFind 找到模板后的钩子，解析目录列表
+func (template *FolderTemplate) AfterFind() (err error) {
+	if template.Folders != "" {
+		err = json.Unmarshal([]byte(template.Folders), &template.FolderList)
+	}
+	return err
+}
+
+// BeforeSave 保存模板前的钩子，序列化目录列表
+func (template *FolderTemplate) BeforeSave() error {
+	folders, err := json.Marshal(template.FolderList)
+	template.Folders = string(folders)
+	return err
+}
+
+// Create 创建模板记录
+func (template *FolderTemplate) Create() (uint, error) {
+	if err := DB.Create(template).Error; err != nil {
+		return 0, err
+	}
+	return template.ID, nil
+}
+
+// GetFolderTemplatesByUser 列出用户可用的模板，包括管理员创建的公共模板
+func GetFolderTemplatesByUser(uid uint) ([]FolderTemplate, error) {
+	var templates []FolderTemplate
+	result := DB.Where("user_id in (?)", []uint{0, uid}).Order("user_id, id").Find(&templates)
+	return templates, result.Error
+}
+
+// GetFolderTemplateByID 根据ID查找用户可用的模板
+func GetFolderTemplateByID(id, uid uint) (*FolderTemplate, error) {
+	var template FolderTemplate
+	result := DB.Where("id = ? and user_id in (?)", id, []uint{0, uid}).First(&template)
+	return &template, result.Error
+}
+
+// DeleteFolderTemplateByID 删除用户创建的模板，uid 为 0 时删除公共模板
+func DeleteFolderTemplateByID(id, uid uint) error {
+	return DB.Where("id = ? and user_id = ?", id, uid).Delete(&FolderTemplate{}).Error
+}
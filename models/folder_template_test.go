@@ -0,0 +1,86 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFolderTemplate_Create(t *testing.T) {
+	asserts := assert.New(t)
+	template := FolderTemplate{UserID: 1, Name: "Project", FolderList: []string{"Docs", "Design"}}
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)folder_templates(.+)").
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, "Project", `["Docs","Design"]`).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		id, err := template.Create()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(1, id)
+	}
+
+	// 失败
+	{
+		template.ID = 0
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		id, err := template.Create()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.EqualValues(0, id)
+	}
+}
+
+func TestGetFolderTemplatesByUser(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)folder_templates(.+)user_id in(.+)").
+		WithArgs(0, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "folders"}).
+			AddRow(1, 0, `["Docs"]`).
+			AddRow(2, 1, ""))
+	templates, err := GetFolderTemplatesByUser(1)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(templates, 2)
+	asserts.Equal([]string{"Docs"}, templates[0].FolderList)
+	asserts.Empty(templates[1].FolderList)
+}
+
+func TestGetFolderTemplateByID(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 找到
+	{
+		mock.ExpectQuery("SELECT(.+)folder_templates(.+)").
+			WithArgs(2, 0, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "folders"}).AddRow(2, `["Docs","Design/Assets"]`))
+		template, err := GetFolderTemplateByID(2, 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal([]string{"Docs", "Design/Assets"}, template.FolderList)
+	}
+
+	// 未找到
+	{
+		mock.ExpectQuery("SELECT(.+)folder_templates(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		_, err := GetFolderTemplateByID(3, 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
+func TestDeleteFolderTemplateByID(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)folder_templates(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(DeleteFolderTemplateByID(1, 0))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
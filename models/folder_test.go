@@ -46,6 +46,107 @@ func TestFolder_Create(t *testing.T) {
 	asserts.NoError(mock.ExpectationsWereMet())
 }
 
+func TestFolder_CreateMountPoint(t *testing.T) {
+	asserts := assert.New(t)
+	folder := &Folder{Model: gorm.Model{ID: 1}, OwnerID: 1, Name: "/"}
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+		mount, err := folder.CreateMountPoint("Shared", 3, "pwd")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(2, mount.ID)
+		asserts.EqualValues(1, mount.OwnerID)
+		asserts.Equal("/", mount.Position)
+		asserts.True(mount.IsMount())
+	}
+
+	// 同名目录已存在
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)folders(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		_, err := folder.CreateMountPoint("Shared", 3, "")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+
+	asserts.False(folder.IsMount())
+}
+
+func TestFolder_CreateTree(t *testing.T) {
+	asserts := assert.New(t)
+	folder := &Folder{Model: gorm.Model{ID: 1}, OwnerID: 1, Name: "/"}
+
+	// 成功，重复的上级目录只创建一次
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectExec("INSERT(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(3, 1))
+		mock.ExpectExec("INSERT(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(4, 1))
+		mock.ExpectExec("INSERT(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.ExpectCommit()
+		root, err := folder.CreateTree("Project", []string{"Docs", "Design/Assets", "Design"})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(2, root.ID)
+		asserts.Equal("/", root.Position)
+	}
+
+	// 创建子目录失败，全部回滚
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectExec("INSERT(.+)folders(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		_, err := folder.CreateTree("Project", []string{"Docs"})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+
+	// 目录已存在
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)folders(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		_, err := folder.CreateTree("Project", nil)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
+func TestFolder_CreateIfNotExist(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 不存在，新建
+	{
+		folder := &Folder{Name: "new folder"}
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.ExpectCommit()
+		created, err := folder.CreateIfNotExist()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.True(created)
+		asserts.EqualValues(5, folder.ID)
+	}
+
+	// 已存在
+	{
+		folder := &Folder{Name: "new folder"}
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+		created, err := folder.CreateIfNotExist()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.False(created)
+		asserts.EqualValues(5, folder.ID)
+	}
+}
+
 func TestFolder_GetChild(t *testing.T) {
 	asserts := assert.New(t)
 	folder := Folder{
@@ -105,6 +206,51 @@ func TestFolder_GetChildFolder(t *testing.T) {
 	asserts.NoError(mock.ExpectationsWereMet())
 }
 
+func TestFolder_ListChildFolders(t *testing.T) {
+	asserts := assert.New(t)
+	folder := &Folder{
+		Model: gorm.Model{
+			ID: 1,
+		},
+		Position: "/123",
+		Name:     "456",
+	}
+
+	// 第一页，按名称正序
+	{
+		mock.ExpectQuery("SELECT(.+)parent_id = (.+)name like(.+)ORDER BY name asc,id asc LIMIT 3").
+			WithArgs(1, "%doc%").
+			WillReturnRows(sqlmock.NewRows([]string{"name", "id"}).AddRow("doc1", 1).AddRow("doc2", 2))
+		folders, err := folder.ListChildFolders(&ChildListOption{Keyword: "doc", Limit: 3})
+		asserts.NoError(err)
+		asserts.Len(folders, 2)
+		asserts.Equal("/123/456", folders[0].Position)
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 游标之后，按修改时间倒序
+	{
+		after := time.Now()
+		mock.ExpectQuery("SELECT(.+)parent_id = (.+)updated_at < (.+)updated_at = (.+)id < (.+)ORDER BY updated_at desc,id desc").
+			WithArgs(1, after, after, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"name", "id"}))
+		folders, err := folder.ListChildFolders(&ChildListOption{OrderBy: "updated_at", Desc: true, AfterValue: after, AfterID: 5})
+		asserts.NoError(err)
+		asserts.Len(folders, 0)
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 未知排序字段按名称排序
+	{
+		mock.ExpectQuery("SELECT(.+)parent_id = (.+)ORDER BY name asc").
+			WithArgs(1).
+			WillReturnError(errors.New("error"))
+		_, err := folder.ListChildFolders(&ChildListOption{OrderBy: "id; drop table folders"})
+		asserts.Error(err)
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
 func TestGetRecursiveChildFolderSQLite(t *testing.T) {
 	conf.DatabaseConfig.Type = "sqlite"
 	asserts := assert.New(t)
@@ -233,9 +379,11 @@ func TestFolder_MoveOrCopyFileTo(t *testing.T) {
 		)
 		mock.ExpectBegin()
 		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectCommit()
 		mock.ExpectBegin()
 		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectCommit()
 		storage, err := folder.MoveOrCopyFileTo(
 			[]uint{1, 2, 3},
@@ -282,6 +430,7 @@ func TestFolder_MoveOrCopyFileTo(t *testing.T) {
 		)
 		mock.ExpectBegin()
 		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectCommit()
 		mock.ExpectBegin()
 		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
@@ -299,9 +448,18 @@ func TestFolder_MoveOrCopyFileTo(t *testing.T) {
 	// 移动文件 成功
 	{
 		mock.ExpectBegin()
-		mock.ExpectExec("UPDATE(.+)").
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, 2, 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"size", "file_num"}).AddRow(30, 2))
+		mock.ExpectExec("UPDATE(.+)files(.+)").
 			WithArgs(10, sqlmock.AnyArg(), 1, 2, 1, 1).
 			WillReturnResult(sqlmock.NewResult(1, 2))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 10).
+			WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectCommit()
 		storage, err := folder.MoveOrCopyFileTo(
 			[]uint{1, 2},
@@ -313,9 +471,26 @@ func TestFolder_MoveOrCopyFileTo(t *testing.T) {
 		asserts.Equal(uint64(0), storage)
 	}
 
+	// 统计移动文件出错
+	{
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		storage, err := folder.MoveOrCopyFileTo(
+			[]uint{1, 2},
+			&dstFolder,
+			false,
+		)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.Equal(uint64(0), storage)
+	}
+
 	// 移动文件 出错
 	{
 		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"size", "file_num"}).AddRow(30, 2))
 		mock.ExpectExec("UPDATE(.+)").
 			WithArgs(10, sqlmock.AnyArg(), 1, 2, 1, 1).
 			WillReturnError(errors.New("error"))
@@ -381,9 +556,11 @@ func TestFolder_CopyFolderTo(t *testing.T) {
 		// 复制子文件
 		mock.ExpectBegin()
 		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectCommit()
 		mock.ExpectBegin()
 		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(6, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectCommit()
 
 		size, err := parFolder.CopyFolderTo(2, &dstFolder)
@@ -483,6 +660,7 @@ func TestFolder_CopyFolderTo(t *testing.T) {
 		// 复制子文件
 		mock.ExpectBegin()
 		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectCommit()
 		mock.ExpectBegin()
 		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
@@ -620,3 +798,102 @@ func TestFolder_Rename(t *testing.T) {
 		asserts.Error(err)
 	}
 }
+
+func TestFolder_SetPolicy(t *testing.T) {
+	asserts := assert.New(t)
+	folder := Folder{Model: gorm.Model{ID: 1}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)folders(.+)SET(.+)").
+		WithArgs(2, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(folder.SetPolicy(2))
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.EqualValues(2, folder.PolicyID)
+}
+
+func TestFolder_SetDisplay(t *testing.T) {
+	asserts := assert.New(t)
+	folder := Folder{Model: gorm.Model{ID: 1}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)folders(.+)SET(.+)").
+		WithArgs("#ff0000", "a", "music", 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(folder.SetDisplay("#ff0000", "music", "a"))
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Equal("#ff0000", folder.Color)
+	asserts.Equal("music", folder.Icon)
+	asserts.Equal("a", folder.Description)
+}
+
+func TestFolder_ChangeSize(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 未指定目录
+	{
+		folder := Folder{}
+		asserts.NoError(folder.ChangeSize(DB, "+", 10, 1))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	folder := Folder{Model: gorm.Model{ID: 1}}
+
+	// 增加
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)folders(.+)file_num \\+ (.+)size \\+ (.+)").
+			WithArgs(1, 10, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		asserts.NoError(folder.ChangeSize(DB, "+", 10, 1))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 减少
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)folders(.+)CASE WHEN file_num > (.+)CASE WHEN size > (.+)").
+			WithArgs(1, 1, 10, 10, 1).
+			WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(folder.ChangeSize(DB, "-", 10, 1))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestCalibrateFolderSize(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)folders(.+)SELECT COUNT(.+)files(.+)SELECT COALESCE(.+)").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+	asserts.NoError(CalibrateFolderSize())
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
+func TestGetStarredFolders(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)folders(.+)starred(.+)").WithArgs(1, true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "starred"}).AddRow(1, true))
+	res, err := GetStarredFolders(1)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(res, 1)
+	asserts.True(res[0].Starred)
+}
+
+func TestSetFoldersStarred(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)folders(.+)starred(.+)").WithArgs(false, 3, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(SetFoldersStarred([]uint{3}, 1, false))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
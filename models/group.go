@@ -36,6 +36,9 @@ type GroupOption struct {
 	Aria2BatchSize   int                    `json:"aria2_batch,omitempty"`
 	AdvanceDelete    bool                   `json:"advance_delete,omitempty"`
 	WebDAVProxy      bool                   `json:"webdav_proxy,omitempty"`
+	UploadSpeedLimit int                    `json:"upload_speed_limit,omitempty"` // 上传限速，字节/秒
+	CrossUserCopy    bool                   `json:"cross_user_copy,omitempty"`    // 复制文件到其他用户空间
+	ShareSlug        bool                   `json:"share_slug,omitempty"`         // 自定义分享链接
 }
 
 // GetGroupByID 用ID获取用户组
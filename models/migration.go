@@ -40,8 +40,17 @@ func migration() {
 		DB = DB.Set("gorm:table_options", "ENGINE=InnoDB")
 	}
 
+	// 目录统计字段为新增时，需根据已有文件记录计算初始值
+	calibrateFolder := !DB.Dialect().HasColumn(DB.NewScope(&Folder{}).TableName(), "file_num")
+
 	DB.AutoMigrate(&User{}, &Setting{}, &Group{}, &Policy{}, &Folder{}, &File{}, &Share{},
-		&Task{}, &Download{}, &Tag{}, &Webdav{}, &Node{}, &SourceLink{})
+		&Task{}, &Download{}, &Tag{}, &Webdav{}, &Node{}, &SourceLink{}, &Photo{}, &Traffic{}, &Trash{}, &FileVersion{}, &FileTag{}, &Activity{}, &Expiration{}, &FileProperty{}, &CapacityReservation{}, &FolderTemplate{}, &Comment{}, &Notification{}, &Change{}, &ShareAccess{}, &ShareAlias{}, &ShareTarget{}, &ShareItem{}, &ShareReport{}, &ShareSlugBan{})
+
+	if calibrateFolder {
+		if err := CalibrateFolderSize(); err != nil {
+			util.Log().Warning("Failed to calibrate folder size: %s", err)
+		}
+	}
 
 	// 创建初始存储策略
 	addDefaultPolicy()
@@ -112,6 +121,7 @@ func addDefaultGroups() {
 				Aria2BatchSize:   50,
 				RedirectedSource: true,
 				AdvanceDelete:    true,
+				CrossUserCopy:    true,
 			},
 		}
 		if err := DB.Create(&defaultAdminGroup).Error; err != nil {
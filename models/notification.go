@@ -0,0 +1,76 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 站内通知的类型
+const (
+	NotificationComment     = "comment"      // 自己的文件收到评论
+	NotificationReply       = "reply"        // 自己的评论收到回复
+	NotificationMention     = "mention"      // 在评论中被提及
+	NotificationShare       = "share"        // 其他用户将文件、目录分享给自己
+	NotificationShareAccess = "share_access" // 自己的分享被访问，同一时段内的访问汇总为一条
+)
+
+// Notification 站内通知
+type Notification struct {
+	gorm.Model
+	UserID    uint   `gorm:"index:notification_user_id"`
+	Type      string `gorm:"size:32"`
+	ActorID   uint   // 触发通知的用户
+	FileID    uint
+	FileName  string
+	ShareID   uint // 评论经由分享发表时为分享ID
+	CommentID uint
+	Content   string `gorm:"type:text"` // 评论内容摘要，分享访问通知中为 JSON 格式的访问统计
+	ReadAt    *time.Time
+
+	// 数据库忽略字段
+	Actor User `gorm:"PRELOAD:false,association_autoupdate:false"`
+}
+
+// CreateNotifications 在同一事务中保存多条通知
+func CreateNotifications(notifications []Notification) error {
+	tx := DB.Begin()
+	for i := range notifications {
+		if err := tx.Create(&notifications[i]).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// ListNotifications 按时间倒序分页列出用户的通知，unreadOnly 为 true 时只列出未读通知
+func ListNotifications(uid uint, page, pageSize int, unreadOnly bool) ([]Notification, int) {
+	var (
+		notifications []Notification
+		total         int
+	)
+	dbChain := DB.Where("user_id = ?", uid)
+	if unreadOnly {
+		dbChain = dbChain.Where("read_at is NULL")
+	}
+
+	// 计算总数用于分页
+	dbChain.Model(&Notification{}).Count(&total)
+
+	// 查询记录
+	dbChain.Preload("Actor").Limit(pageSize).Offset((page - 1) * pageSize).Order("id desc").Find(&notifications)
+
+	return notifications, total
+}
+
+// MarkNotificationsRead 将用户的通知标记为已读，ids 为空时标记全部通知
+func MarkNotificationsRead(uid uint, ids []uint) error {
+	dbChain := DB.Model(&Notification{}).Where("user_id = ? and read_at is NULL", uid)
+	if len(ids) > 0 {
+		dbChain = dbChain.Where("id in (?)", ids)
+	}
+
+	return dbChain.Update("read_at", time.Now()).Error
+}
@@ -0,0 +1,92 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateNotifications(t *testing.T) {
+	a := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)notifications(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT(.+)notifications(.+)").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+		a.NoError(CreateNotifications([]Notification{
+			{UserID: 1, Type: NotificationComment},
+			{UserID: 2, Type: NotificationMention},
+		}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)notifications(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(CreateNotifications([]Notification{{UserID: 1}}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestListNotifications(t *testing.T) {
+	a := assert.New(t)
+
+	// 全部通知
+	{
+		mock.ExpectQuery("SELECT(.+)notifications(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectQuery("SELECT(.+)notifications(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "actor_id"}).AddRow(3, 2).AddRow(2, 2))
+		mock.ExpectQuery("SELECT(.+)users(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "nick"}).AddRow(2, "a"))
+		res, total := ListNotifications(1, 1, 2, false)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Equal(3, total)
+		a.Len(res, 2)
+		a.Equal("a", res[0].Actor.Nick)
+	}
+
+	// 只列出未读通知
+	{
+		mock.ExpectQuery("SELECT(.+)notifications(.+)read_at is NULL(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery("SELECT(.+)notifications(.+)read_at is NULL(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		res, total := ListNotifications(1, 1, 2, true)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Equal(0, total)
+		a.Len(res, 0)
+	}
+}
+
+func TestMarkNotificationsRead(t *testing.T) {
+	a := assert.New(t)
+
+	// 指定通知
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)notifications(.+)id in(.+)").
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 2, 3).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+		a.NoError(MarkNotificationsRead(1, []uint{2, 3}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 全部通知
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)notifications(.+)").
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 5))
+		mock.ExpectCommit()
+		a.NoError(MarkNotificationsRead(1, nil))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
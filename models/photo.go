@@ -0,0 +1,114 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Photo 从图片 EXIF 中提取的拍摄信息，用于按时间、地点检索照片
+type Photo struct {
+	gorm.Model
+	FileID    uint       `gorm:"unique_index"`
+	UserID    uint       `gorm:"index:user_taken_at"`
+	TakenAt   *time.Time `gorm:"index:user_taken_at"`
+	Camera    string
+	Latitude  *float64
+	Longitude *float64
+
+	// 关联模型
+	File File `gorm:"save_associations:false:false"`
+}
+
+// PhotoFilter 照片查询条件，为空的条件不做限制
+type PhotoFilter struct {
+	From   *time.Time
+	To     *time.Time
+	MinLat *float64
+	MaxLat *float64
+	MinLng *float64
+	MaxLng *float64
+}
+
+// Save 保存照片信息，替换该文件已有的记录
+func (photo *Photo) Save() error {
+	tx := DB.Begin()
+	if err := tx.Unscoped().Where("file_id = ?", photo.FileID).Delete(&Photo{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Create(photo).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// GetPhotoByFileID 查找文件的照片信息
+func GetPhotoByFileID(fileID uint) (*Photo, error) {
+	var photo Photo
+	result := DB.Where("file_id = ?", fileID).First(&photo)
+	return &photo, result.Error
+}
+
+// photoQuery 按条件筛选用户的照片，只包含文件仍存在的记录
+func photoQuery(uid uint, filter *PhotoFilter) *gorm.DB {
+	dbChain := DB.Model(&Photo{}).
+		Joins("INNER JOIN files ON files.id = photos.file_id AND files.deleted_at IS NULL").
+		Where("photos.user_id = ?", uid)
+
+	if filter.From != nil {
+		dbChain = dbChain.Where("photos.taken_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		dbChain = dbChain.Where("photos.taken_at < ?", *filter.To)
+	}
+	if filter.MinLat != nil {
+		dbChain = dbChain.Where("photos.latitude >= ?", *filter.MinLat)
+	}
+	if filter.MaxLat != nil {
+		dbChain = dbChain.Where("photos.latitude <= ?", *filter.MaxLat)
+	}
+	if filter.MinLng != nil {
+		dbChain = dbChain.Where("photos.longitude >= ?", *filter.MinLng)
+	}
+	if filter.MaxLng != nil {
+		dbChain = dbChain.Where("photos.longitude <= ?", *filter.MaxLng)
+	}
+
+	return dbChain
+}
+
+// ListPhotos 按拍摄时间倒序分页列出用户的照片
+func ListPhotos(uid uint, filter *PhotoFilter, page, pageSize int) ([]Photo, int) {
+	var (
+		photos []Photo
+		total  int
+	)
+
+	dbChain := photoQuery(uid, filter)
+
+	// 计算总数用于分页
+	dbChain.Count(&total)
+
+	// 查询记录
+	dbChain.Select("photos.*").Preload("File").Limit(pageSize).Offset((page - 1) * pageSize).
+		Order("photos.taken_at desc, photos.id desc").Find(&photos)
+	return photos, total
+}
+
+// GetPhotoTakenTimes 列出符合条件的照片的拍摄时间，用于生成时间线
+func GetPhotoTakenTimes(uid uint, filter *PhotoFilter) ([]time.Time, error) {
+	var photos []Photo
+	err := photoQuery(uid, filter).Where("photos.taken_at IS NOT NULL").
+		Select("photos.taken_at").Find(&photos).Error
+
+	res := make([]time.Time, 0, len(photos))
+	for _, photo := range photos {
+		res = append(res, *photo.TakenAt)
+	}
+
+	return res, err
+}
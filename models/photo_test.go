@@ -0,0 +1,81 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhoto_Save(t *testing.T) {
+	a := assert.New(t)
+	photo := &Photo{FileID: 2, UserID: 1, Camera: "Canon EOS R5"}
+
+	// 删除旧记录失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)photos(.+)").WithArgs(2).WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(photo.Save())
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)photos(.+)").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT(.+)photos(.+)").WillReturnResult(sqlmock.NewResult(3, 1))
+		mock.ExpectCommit()
+		a.NoError(photo.Save())
+		a.NoError(mock.ExpectationsWereMet())
+		a.EqualValues(3, photo.ID)
+	}
+}
+
+func TestGetPhotoByFileID(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)photos(.+)").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "file_id"}).AddRow(3, 2))
+	photo, err := GetPhotoByFileID(2)
+	a.NoError(mock.ExpectationsWereMet())
+	a.NoError(err)
+	a.EqualValues(3, photo.ID)
+}
+
+func TestListPhotos(t *testing.T) {
+	a := assert.New(t)
+	from := time.Now().Add(-time.Hour)
+	minLat := 30.0
+
+	mock.ExpectQuery("SELECT count(.+)photos(.+)INNER JOIN files(.+)").
+		WithArgs(1, from, minLat).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT(.+)photos(.+)").
+		WithArgs(1, from, minLat).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "file_id"}).AddRow(1, 2))
+	mock.ExpectQuery("SELECT(.+)files(.+)").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "1.jpg"))
+
+	photos, total := ListPhotos(1, &PhotoFilter{From: &from, MinLat: &minLat}, 1, 10)
+	a.NoError(mock.ExpectationsWereMet())
+	a.Equal(1, total)
+	a.Len(photos, 1)
+	a.Equal("1.jpg", photos[0].File.Name)
+}
+
+func TestGetPhotoTakenTimes(t *testing.T) {
+	a := assert.New(t)
+	takenAt := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT(.+)taken_at(.+)photos(.+)").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"taken_at"}).AddRow(takenAt))
+	res, err := GetPhotoTakenTimes(1, &PhotoFilter{})
+	a.NoError(err)
+	a.NoError(mock.ExpectationsWereMet())
+	a.Equal([]time.Time{takenAt}, res)
+}
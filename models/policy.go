@@ -3,8 +3,10 @@ package model
 import (
 	"encoding/gob"
 	"encoding/json"
+	"errors"
 	"github.com/gofrs/uuid"
 	"github.com/samber/lo"
+	"net/url"
 	"path"
 	"path/filepath"
 	"strconv"
@@ -54,12 +56,22 @@ type PolicyOption struct {
 	OdProxy string `json:"od_proxy,omitempty"`
 	// OdDriver OneDrive 驱动器定位符
 	OdDriver string `json:"od_driver,omitempty"`
+	// OdDriveName SharePoint 站点中要使用的文档库名称或 ID，为空时使用站点默认文档库
+	OdDriveName string `json:"od_drive_name,omitempty"`
 	// Region 区域代码
 	Region string `json:"region,omitempty"`
 	// ServerSideEndpoint 服务端请求使用的 Endpoint，为空时使用 Policy.Server 字段
 	ServerSideEndpoint string `json:"server_side_endpoint,omitempty"`
 	// 分片上传的分片大小
 	ChunkSize uint64 `json:"chunk_size,omitempty"`
+	// 是否加密存储文件，仅本机及从机策略可用
+	Encryption bool `json:"encryption,omitempty"`
+	// 被主密钥加密的存储策略密钥
+	EncryptionKey string `json:"encryption_key,omitempty"`
+	// 远程存储上传遇到临时网络错误时的重试次数
+	PutRetries int `json:"put_retries,omitempty"`
+	// 服务端分片上传的并发数
+	UploadConcurrency int `json:"upload_concurrency,omitempty"`
 	// 分片上传时是否需要预留空间
 	PlaceholderWithSize bool `json:"placeholder_with_size,omitempty"`
 	// 每秒对存储端的 API 请求上限
@@ -69,8 +81,93 @@ type PolicyOption struct {
 	// Set this to `true` to force the request to use path-style addressing,
 	// i.e., `http://s3.amazonaws.com/BUCKET/KEY `
 	S3ForcePathStyle bool `json:"s3_path_style"`
+	// 不超过一个分片的文件是否使用预签名的 PUT 请求直接上传，无需分片上传，仅 S3 策略可用
+	S3PresignedPut bool `json:"s3_presigned_put,omitempty"`
+	// S3 服务端加密方式，AES256 (SSE-S3) 或 aws:kms (SSE-KMS)，为空时不加密
+	S3SSE string `json:"s3_sse,omitempty"`
+	// SSE-KMS 使用的密钥 ID，为空时使用存储桶默认的 KMS 密钥
+	S3SSEKMSKeyID string `json:"s3_sse_kms_key_id,omitempty"`
+	// S3 预签名下载地址的有效期，单位秒，为 0 时使用站点设置
+	S3PresignExpiry int64 `json:"s3_presign_expiry,omitempty"`
 	// File extensions that support thumbnail generation using native policy API.
 	ThumbExts []string `json:"thumb_exts,omitempty"`
+	// 上传完成后是否使用 clamd 扫描病毒
+	VirusScan bool `json:"virus_scan,omitempty"`
+	// 上传完成后是否根据文件头部内容校验真实类型
+	SniffContentType bool `json:"sniff_content_type,omitempty"`
+	// 上传完成后是否清除图片中的 EXIF、GPS 等元数据
+	StripMetadata bool `json:"strip_metadata,omitempty"`
+	// 上传完成后是否压缩 jpg、png 图片
+	CompressImage bool `json:"compress_image,omitempty"`
+	// 压缩图片时的最大宽高，超出时等比缩放，为 0 时不缩放
+	CompressMaxSize uint `json:"compress_max_size,omitempty"`
+	// 压缩 jpg 图片时的编码质量，为 0 时使用 85
+	CompressQuality int `json:"compress_quality,omitempty"`
+	// 是否按内容去重存储，内容相同的文件共用同一物理文件，物理文件在不再被引用时删除
+	Dedup bool `json:"dedup,omitempty"`
+	// 文件上传后的保留天数，保留期内不可覆盖、删除或重命名，为 0 时不限制
+	RetentionDays uint `json:"retention_days,omitempty"`
+	// 覆盖文件时保留的历史版本数量，为 0 时不保留历史版本
+	MaxVersions uint `json:"max_versions,omitempty"`
+	// 单个文件所有历史版本的最大总大小，超出时删除最旧的版本，为 0 时不限制
+	MaxVersionSize uint64 `json:"max_version_size,omitempty"`
+	// 本机策略的多个存储根目录，设定后文件按 ShardStrategy 分布于各目录中
+	Shards []string `json:"shards,omitempty"`
+	// 选择存储根目录的方式，most_free 为剩余空间最多，round_robin 为轮流使用，为空时使用 most_free
+	ShardStrategy string `json:"shard_strategy,omitempty"`
+	// 上传时在本机暂存文件的目录，本机策略上传完成后再移动至存储路径，为空时本机策略直接写入存储路径，
+	// 需在本机暂存分片的其他策略使用临时目录设置
+	UploadTempPath string `json:"upload_temp_path,omitempty"`
+	// SFTP 服务器公钥，authorized_keys 格式或 "SHA256:" 开头的指纹，为空时记录首次连接时服务器提供的公钥
+	HostKey string `json:"host_key,omitempty"`
+	// FTP 加密方式，explicit 为 AUTH TLS，implicit 为隐式 TLS，为空时不加密
+	FTPSMode string `json:"ftps_mode,omitempty"`
+	// FTPS 是否跳过服务器证书校验
+	FTPSkipVerify bool `json:"ftp_skip_verify,omitempty"`
+	// 存储端请求使用的代理地址，支持 http、https、socks5，为空时使用系统代理设置
+	Proxy string `json:"proxy,omitempty"`
+	// 分块存储的分块大小，大于 0 时超过此大小的文件按 rclone chunker 格式拆分保存
+	ChunkerSize uint64 `json:"chunker_size,omitempty"`
+	// Swift Keystone v3 认证使用的项目名称
+	SwiftProject string `json:"swift_project,omitempty"`
+	// Swift Keystone v3 认证使用的域名称，为空时使用 Default
+	SwiftDomain string `json:"swift_domain,omitempty"`
+	// Swift 账户或容器的 Temp URL 密钥，用于签名上传、下载地址
+	TempURLKey string `json:"temp_url_key,omitempty"`
+	// 是否开启直链防盗链，仅本机策略可用
+	HotlinkProtection bool `json:"hotlink_protection,omitempty"`
+	// 防盗链允许的来源域名，支持 *.example.com 形式的通配，站点自身始终允许
+	RefererWhitelist []string `json:"referer_whitelist,omitempty"`
+	// 开启防盗链时是否拒绝不带 Referer 的请求
+	BlockEmptyReferer bool `json:"block_empty_referer,omitempty"`
+	// 对象存储上传文件使用的存储类型，如 OSS 的 Archive、S3 的 GLACIER_IR，为空时使用存储桶默认类型
+	StorageClass string `json:"storage_class,omitempty"`
+	// 上传时允许用户指定的存储类型
+	StorageClasses []string `json:"storage_classes,omitempty"`
+	// 归档文件解冻后副本的保留天数，为 0 时保留 1 天
+	RestoreDays int `json:"restore_days,omitempty"`
+	// 存储分层规则，满足规则的文件将定期迁移至目标存储策略
+	Tiering *TieringRule `json:"tiering,omitempty"`
+	// 镜像策略的副本存储策略 ID，按读取优先级排序
+	MirrorPolicies []uint `json:"mirror_policies,omitempty"`
+	// 从机离线时上传使用的备用存储策略 ID，为 0 时拒绝上传
+	BackupPolicyID uint `json:"backup_policy_id,omitempty"`
+	// 移动文件后是否在存储端内部将物理文件复制到按新路径生成的存储路径，仅存储路径规则包含 {path} 时生效
+	RelocateOnMove bool `json:"relocate_on_move,omitempty"`
+}
+
+// TieringRule 存储分层规则，文件需同时满足所有已设定的条件
+type TieringRule struct {
+	// 目标存储策略 ID
+	TargetPolicyID uint `json:"target_policy_id"`
+	// 文件上传后的天数下限，为 0 时不限制
+	MinAgeDays uint `json:"min_age_days,omitempty"`
+	// 文件最后一次被访问后的天数下限，为 0 时不限制
+	MinIdleDays uint `json:"min_idle_days,omitempty"`
+	// 文件大小下限，为 0 时不限制
+	MinSize uint64 `json:"min_size,omitempty"`
+	// 文件大小上限，为 0 时不限制
+	MaxSize uint64 `json:"max_size,omitempty"`
 }
 
 func init() {
@@ -97,6 +194,56 @@ func GetPolicyByID(ID interface{}) (Policy, error) {
 	return policy, result.Error
 }
 
+// GetTieringPolicies 获取所有设定了存储分层规则的存储策略
+func GetTieringPolicies() ([]Policy, error) {
+	var policies []Policy
+	if err := DB.Find(&policies).Error; err != nil {
+		return nil, err
+	}
+
+	res := make([]Policy, 0, len(policies))
+	for _, policy := range policies {
+		if policy.IsTieringEnabled() {
+			res = append(res, policy)
+		}
+	}
+
+	return res, nil
+}
+
+// GetPoliciesByType 获取给定类型的所有存储策略
+func GetPoliciesByType(policyType string) ([]Policy, error) {
+	var policies []Policy
+	result := DB.Where("type = ?", policyType).Find(&policies)
+	return policies, result.Error
+}
+
+// IsTieringEnabled 返回此策略是否启用了存储分层
+func (policy *Policy) IsTieringEnabled() bool {
+	rule := policy.OptionsSerialized.Tiering
+	return rule != nil && rule.TargetPolicyID != 0 && rule.TargetPolicyID != policy.ID
+}
+
+// IsRelocateOnMove 返回移动文件后是否需要同步调整物理文件的存储路径
+func (policy *Policy) IsRelocateOnMove() bool {
+	return policy.OptionsSerialized.RelocateOnMove && !policy.OptionsSerialized.Dedup &&
+		strings.Contains(policy.DirNameRule, "{path}")
+}
+
+// GetStorageClass 返回上传文件使用的存储类型，元数据中未指定时使用存储策略设定的存储类型
+func (policy *Policy) GetStorageClass(metadata map[string]string) string {
+	if class := metadata[StorageClassMetadataKey]; class != "" {
+		return class
+	}
+
+	return policy.OptionsSerialized.StorageClass
+}
+
+// IsStorageClassAllowed 返回上传时是否允许指定存储类型 class
+func (policy *Policy) IsStorageClassAllowed(class string) bool {
+	return class == policy.OptionsSerialized.StorageClass || util.ContainsString(policy.OptionsSerialized.StorageClasses, class)
+}
+
 // AfterFind 找到存储策略后的钩子
 func (policy *Policy) AfterFind() (err error) {
 	// 解析存储策略设置到OptionsSerialized
@@ -186,7 +333,7 @@ func (policy *Policy) IsDirectlyPreview() bool {
 
 // IsTransitUpload 返回此策略上传给定size文件时是否需要服务端中转
 func (policy *Policy) IsTransitUpload(size uint64) bool {
-	return policy.Type == "local"
+	return util.ContainsString([]string{"local", "sftp", "ftp", "webdav", "hdfs", "dropbox", "ipfs", "mirror"}, policy.Type)
 }
 
 // IsThumbGenerateNeeded 返回此策略是否需要在上传后生成缩略图
@@ -200,7 +347,7 @@ func (policy *Policy) IsUploadPlaceholderWithSize() bool {
 		return true
 	}
 
-	if util.ContainsString([]string{"onedrive", "oss", "qiniu", "cos", "s3"}, policy.Type) {
+	if util.ContainsString([]string{"onedrive", "oss", "qiniu", "cos", "s3", "azure", "gcs", "googledrive", "swift"}, policy.Type) {
 		return policy.OptionsSerialized.PlaceholderWithSize
 	}
 
@@ -212,6 +359,15 @@ func (policy *Policy) CanStructureBeListed() bool {
 	return policy.Type != "local" && policy.Type != "remote"
 }
 
+// IsSameBucket 返回两个存储策略是否使用同一存储端的同一存储桶
+func (policy *Policy) IsSameBucket(other *Policy) bool {
+	return policy.Type == other.Type &&
+		policy.Server == other.Server &&
+		policy.BucketName == other.BucketName &&
+		policy.AccessKey == other.AccessKey &&
+		policy.OptionsSerialized.Region == other.OptionsSerialized.Region
+}
+
 // SaveAndClearCache 更新并清理缓存
 func (policy *Policy) SaveAndClearCache() error {
 	err := DB.Save(policy).Error
@@ -226,6 +382,69 @@ func (policy *Policy) UpdateAccessKeyAndClearCache(s string) error {
 	return err
 }
 
+// ErrCredentialChanged 轮换凭证时存储策略的凭证已被修改
+var ErrCredentialChanged = errors.New("policy credential has been changed")
+
+// RotateCredential 将凭证替换为新的访问密钥，仅在数据库中的凭证与当前一致时替换，
+// 避免并发轮换时覆盖其他请求写入的凭证
+func (policy *Policy) RotateCredential(accessKey, secretKey string) error {
+	result := DB.Model(&Policy{}).
+		Where("id = ? and access_key = ? and secret_key = ?", policy.ID, policy.AccessKey, policy.SecretKey).
+		Updates(map[string]interface{}{"access_key": accessKey, "secret_key": secretKey})
+	policy.ClearCache()
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrCredentialChanged
+	}
+
+	policy.AccessKey = accessKey
+	policy.SecretKey = secretKey
+	return nil
+}
+
+// ErrHostKeyChanged 记录服务器公钥时发现其他连接已记录了不同的公钥
+var ErrHostKeyChanged = errors.New("host key has been pinned to a different key")
+
+// PinHostKey 记录首次连接 SFTP 服务器时服务器提供的公钥，此后的连接只接受此公钥。
+// 其他连接已先记录公钥时，仅在两者一致时通过
+func (policy *Policy) PinHostKey(hostKey string) error {
+	var latest Policy
+	if err := DB.First(&latest, policy.ID).Error; err != nil {
+		return err
+	}
+
+	if latest.OptionsSerialized.HostKey != "" {
+		if latest.OptionsSerialized.HostKey != hostKey {
+			return ErrHostKeyChanged
+		}
+		policy.OptionsSerialized.HostKey = hostKey
+		return nil
+	}
+
+	options := latest.Options
+	latest.OptionsSerialized.HostKey = hostKey
+	if err := latest.SerializeOptions(); err != nil {
+		return err
+	}
+
+	result := DB.Model(&Policy{}).Where("id = ? and options = ?", latest.ID, options).UpdateColumn("options", latest.Options)
+	policy.ClearCache()
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrHostKeyChanged
+	}
+
+	policy.OptionsSerialized.HostKey = hostKey
+	return nil
+}
+
 // ClearCache 清空policy缓存
 func (policy *Policy) ClearCache() {
 	cache.Deletes([]string{strconv.FormatUint(uint64(policy.ID), 10)}, "policy_")
@@ -233,7 +452,8 @@ func (policy *Policy) ClearCache() {
 
 // CouldProxyThumb return if proxy thumbs is allowed for this policy.
 func (policy *Policy) CouldProxyThumb() bool {
-	if policy.Type == "local" || !IsTrueVal(GetSettingByName("thumb_proxy_enabled")) {
+	// IPFS 策略无法按路径存储缩略图
+	if policy.Type == "local" || policy.Type == "ipfs" || !IsTrueVal(GetSettingByName("thumb_proxy_enabled")) {
 		return false
 	}
 
@@ -241,3 +461,33 @@ func (policy *Policy) CouldProxyThumb() bool {
 	_ = json.Unmarshal([]byte(GetSettingByName("thumb_proxy_policy")), &allowed)
 	return lo.Contains[uint](allowed, policy.ID)
 }
+
+// IsRefererAllowed 返回直链请求的来源 referer 是否被存储策略的防盗链设置允许
+func (policy *Policy) IsRefererAllowed(referer string) bool {
+	if policy.Type != "local" || !policy.OptionsSerialized.HotlinkProtection {
+		return true
+	}
+
+	if referer == "" {
+		return !policy.OptionsSerialized.BlockEmptyReferer
+	}
+
+	refererURL, err := url.Parse(referer)
+	if err != nil || refererURL.Hostname() == "" {
+		return false
+	}
+
+	host := strings.ToLower(refererURL.Hostname())
+	if host == strings.ToLower(GetSiteURL().Hostname()) {
+		return true
+	}
+
+	for _, allowed := range policy.OptionsSerialized.RefererWhitelist {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return true
+		}
+	}
+
+	return false
+}
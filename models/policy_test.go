@@ -2,6 +2,7 @@ package model
 
 import (
 	"encoding/json"
+	"errors"
 	"strconv"
 	"testing"
 	"time"
@@ -201,6 +202,39 @@ func TestPolicy_UpdateAccessKey(t *testing.T) {
 	asserts.NoError(err)
 }
 
+func TestPolicy_RotateCredential(t *testing.T) {
+	a := assert.New(t)
+	cache.Set("policy_1332", Policy{}, 3600)
+	p := &Policy{AccessKey: "old_ak", SecretKey: "old_sk"}
+	p.ID = 1332
+
+	// 替换成功
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	a.NoError(p.RotateCredential("ak", "sk"))
+	a.NoError(mock.ExpectationsWereMet())
+	a.Equal("ak", p.AccessKey)
+	a.Equal("sk", p.SecretKey)
+	_, ok := cache.Get("policy_1332")
+	a.False(ok)
+
+	// 凭证已被修改
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	a.Equal(ErrCredentialChanged, p.RotateCredential("ak2", "sk2"))
+	a.NoError(mock.ExpectationsWereMet())
+	a.Equal("ak", p.AccessKey)
+
+	// 数据库错误
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)").WillReturnError(errors.New("error"))
+	mock.ExpectRollback()
+	a.Error(p.RotateCredential("ak2", "sk2"))
+	a.NoError(mock.ExpectationsWereMet())
+}
+
 func TestPolicy_Props(t *testing.T) {
 	asserts := assert.New(t)
 	policy := Policy{Type: "onedrive"}
@@ -216,6 +250,27 @@ func TestPolicy_Props(t *testing.T) {
 	asserts.False(policy.IsUploadPlaceholderWithSize())
 	policy.Type = "remote"
 	asserts.True(policy.IsUploadPlaceholderWithSize())
+	policy.Type = "sftp"
+	asserts.True(policy.IsTransitUpload(4))
+	asserts.False(policy.IsThumbGenerateNeeded())
+	policy.Type = "ftp"
+	asserts.True(policy.IsTransitUpload(4))
+	policy.Type = "webdav"
+	asserts.True(policy.IsTransitUpload(4))
+	policy.Type = "hdfs"
+	asserts.True(policy.IsTransitUpload(4))
+	policy.Type = "dropbox"
+	asserts.True(policy.IsTransitUpload(4))
+	policy.Type = "googledrive"
+	asserts.True(policy.IsUploadPlaceholderWithSize())
+	asserts.False(policy.IsTransitUpload(4))
+	policy.Type = "swift"
+	asserts.True(policy.IsUploadPlaceholderWithSize())
+	asserts.False(policy.IsTransitUpload(4))
+	policy.Type = "ipfs"
+	asserts.True(policy.IsTransitUpload(4))
+	policy.Type = "mirror"
+	asserts.True(policy.IsTransitUpload(4))
 }
 
 func TestPolicy_UpdateAccessKeyAndClearCache(t *testing.T) {
@@ -265,5 +320,182 @@ func TestPolicy_CouldProxyThumb(t *testing.T) {
 		a.True(p.CouldProxyThumb())
 	}
 
+	// ipfs policy
+	{
+		p.Type = "ipfs"
+		a.False(p.CouldProxyThumb())
+	}
+
 	cache.Deletes([]string{"thumb_proxy_enabled", "thumb_proxy_policy"}, "setting_")
 }
+
+func TestPolicy_IsTieringEnabled(t *testing.T) {
+	a := assert.New(t)
+	p := &Policy{Model: gorm.Model{ID: 1}}
+
+	// 未设定规则
+	a.False(p.IsTieringEnabled())
+
+	// 目标策略为空
+	p.OptionsSerialized.Tiering = &TieringRule{}
+	a.False(p.IsTieringEnabled())
+
+	// 目标策略为自身
+	p.OptionsSerialized.Tiering.TargetPolicyID = 1
+	a.False(p.IsTieringEnabled())
+
+	// 启用
+	p.OptionsSerialized.Tiering.TargetPolicyID = 2
+	a.True(p.IsTieringEnabled())
+}
+
+func TestPolicy_IsRelocateOnMove(t *testing.T) {
+	a := assert.New(t)
+	p := &Policy{DirNameRule: "uploads/{uid}/{path}"}
+
+	// 未开启
+	a.False(p.IsRelocateOnMove())
+
+	// 开启
+	p.OptionsSerialized.RelocateOnMove = true
+	a.True(p.IsRelocateOnMove())
+
+	// 存储路径与虚拟路径无关
+	p.DirNameRule = "uploads/{uid}/{date}"
+	a.False(p.IsRelocateOnMove())
+
+	// 按内容去重存储
+	p.DirNameRule = "uploads/{uid}/{path}"
+	p.OptionsSerialized.Dedup = true
+	a.False(p.IsRelocateOnMove())
+}
+
+func TestGetTieringPolicies(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "options"}).
+			AddRow(1, `{"tiering":{"target_policy_id":2}}`).
+			AddRow(2, "{}"))
+	res, err := GetTieringPolicies()
+	a.NoError(err)
+	a.NoError(mock.ExpectationsWereMet())
+	a.Len(res, 1)
+	a.EqualValues(1, res[0].ID)
+}
+
+func TestGetPoliciesByType(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)").WithArgs("mirror").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "type"}).AddRow(1, "mirror"))
+	res, err := GetPoliciesByType("mirror")
+	a.NoError(err)
+	a.NoError(mock.ExpectationsWereMet())
+	a.Len(res, 1)
+}
+
+func TestPolicy_GetStorageClass(t *testing.T) {
+	a := assert.New(t)
+	p := &Policy{}
+
+	// 未设定
+	a.Empty(p.GetStorageClass(nil))
+	a.False(p.IsStorageClassAllowed("Archive"))
+
+	// 使用存储策略设定
+	p.OptionsSerialized.StorageClass = "IA"
+	a.Equal("IA", p.GetStorageClass(nil))
+	a.True(p.IsStorageClassAllowed("IA"))
+
+	// 上传时指定
+	p.OptionsSerialized.StorageClasses = []string{"Archive"}
+	a.Equal("Archive", p.GetStorageClass(map[string]string{StorageClassMetadataKey: "Archive"}))
+	a.True(p.IsStorageClassAllowed("Archive"))
+	a.False(p.IsStorageClassAllowed("ColdArchive"))
+}
+
+func TestPolicy_IsSameBucket(t *testing.T) {
+	a := assert.New(t)
+	p := &Policy{Type: "s3", Server: "https://s3.example.com", BucketName: "bucket", AccessKey: "ak"}
+	other := *p
+	other.ID = 2
+	other.OptionsSerialized.StorageClass = "GLACIER"
+	a.True(p.IsSameBucket(&other))
+
+	other.BucketName = "other"
+	a.False(p.IsSameBucket(&other))
+
+	other = *p
+	other.Type = "oss"
+	a.False(p.IsSameBucket(&other))
+
+	other = *p
+	other.OptionsSerialized.Region = "us-west-1"
+	a.False(p.IsSameBucket(&other))
+}
+
+func TestPolicy_IsRefererAllowed(t *testing.T) {
+	a := assert.New(t)
+	cache.Set("setting_siteURL", "https://cloudreve.org", 0)
+
+	// 未开启防盗链
+	p := &Policy{Type: "local"}
+	a.True(p.IsRefererAllowed("https://evil.com/"))
+
+	// 非本机策略
+	p = &Policy{Type: "remote"}
+	p.OptionsSerialized.HotlinkProtection = true
+	a.True(p.IsRefererAllowed("https://evil.com/"))
+
+	p = &Policy{Type: "local"}
+	p.OptionsSerialized.HotlinkProtection = true
+	p.OptionsSerialized.RefererWhitelist = []string{"blog.example.com", " *.Partner.com"}
+	a.True(p.IsRefererAllowed(""))
+	a.True(p.IsRefererAllowed("https://cloudreve.org/home"))
+	a.True(p.IsRefererAllowed("https://blog.example.com/post/1"))
+	a.True(p.IsRefererAllowed("http://img.partner.com:8080/"))
+	a.False(p.IsRefererAllowed("https://partner.com/"))
+	a.False(p.IsRefererAllowed("https://example.com/"))
+	a.False(p.IsRefererAllowed("https://evilpartner.com/"))
+	a.False(p.IsRefererAllowed("not a url"))
+
+	// 拒绝不带 Referer 的请求
+	p.OptionsSerialized.BlockEmptyReferer = true
+	a.False(p.IsRefererAllowed(""))
+}
+
+func TestPolicy_PinHostKey(t *testing.T) {
+	a := assert.New(t)
+	p := &Policy{}
+	p.ID = 1333
+
+	// 首次记录
+	mock.ExpectQuery("SELECT(.+)policies(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "options"}).AddRow(1333, "{}"))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)policies(.+)").WithArgs(sqlmock.AnyArg(), 1333, "{}").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	a.NoError(p.PinHostKey("ssh-ed25519 key"))
+	a.NoError(mock.ExpectationsWereMet())
+	a.Equal("ssh-ed25519 key", p.OptionsSerialized.HostKey)
+
+	// 已记录相同的公钥
+	p.OptionsSerialized.HostKey = ""
+	mock.ExpectQuery("SELECT(.+)policies(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "options"}).AddRow(1333, `{"host_key":"ssh-ed25519 key"}`))
+	a.NoError(p.PinHostKey("ssh-ed25519 key"))
+	a.NoError(mock.ExpectationsWereMet())
+	a.Equal("ssh-ed25519 key", p.OptionsSerialized.HostKey)
+
+	// 已记录不同的公钥
+	mock.ExpectQuery("SELECT(.+)policies(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "options"}).AddRow(1333, `{"host_key":"ssh-ed25519 other"}`))
+	a.Equal(ErrHostKeyChanged, p.PinHostKey("ssh-ed25519 key"))
+	a.NoError(mock.ExpectationsWereMet())
+
+	// 并发记录
+	mock.ExpectQuery("SELECT(.+)policies(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "options"}).AddRow(1333, "{}"))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)policies(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	a.Equal(ErrHostKeyChanged, p.PinHostKey("ssh-ed25519 key"))
+	a.NoError(mock.ExpectationsWereMet())
+}
@@ -31,3 +31,15 @@ func (script UserStorageCalibration) Run(ctx context.Context) {
 		model.DB.Model(&user).Update("storage", total.Total)
 	}
 }
+
+type FolderSizeCalibration int
+
+// Run 运行脚本根据文件记录重建所有目录的大小统计
+func (script FolderSizeCalibration) Run(ctx context.Context) {
+	if err := model.CalibrateFolderSize(); err != nil {
+		util.Log().Warning("Failed to calibrate folder size: %s", err)
+		return
+	}
+
+	util.Log().Info("Folder size calibrated.")
+}
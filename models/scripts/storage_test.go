@@ -59,3 +59,14 @@ func TestUserStorageCalibration_Run(t *testing.T) {
 		asserts.NoError(mock.ExpectationsWereMet())
 	}
 }
+
+func TestFolderSizeCalibration_Run(t *testing.T) {
+	asserts := assert.New(t)
+	script := FolderSizeCalibration(0)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	script.Run(context.Background())
+	asserts.NoError(mock.ExpectationsWereMet())
+}
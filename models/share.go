@@ -3,6 +3,7 @@ package model
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
@@ -13,19 +14,70 @@ import (
 	"github.com/jinzhu/gorm"
 )
 
+var (
+	// ErrShareUploadLimited 同一来源的游客向分享上传文件过于频繁
+	ErrShareUploadLimited = errors.New("too many anonymous uploads")
+	// ErrSharePasswordLimited 同一来源尝试分享密码过于频繁
+	ErrSharePasswordLimited = errors.New("too many password attempts")
+	// ErrShareSlugInvalid 自定义分享链接格式不正确
+	ErrShareSlugInvalid = errors.New("share slug must be 4-64 letters, digits, '-' or '_'")
+	// ErrShareSlugReserved 自定义分享链接为保留字
+	ErrShareSlugReserved = errors.New("share slug is reserved")
+	// ErrShareSlugTaken 自定义分享链接已被使用
+	ErrShareSlugTaken = errors.New("share slug is already taken")
+)
+
+// shareSlugPattern 自定义分享链接允许的格式
+var shareSlugPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{4,64}$`)
+
+// 分享授予访客的权限，可按位组合
+const (
+	// SharePermissionView 在线预览
+	SharePermissionView = 1 << iota
+	// SharePermissionDownload 下载原始文件
+	SharePermissionDownload
+	// SharePermissionUpload 向分享的目录上传文件
+	SharePermissionUpload
+	// SharePermissionEdit 通过在线编辑器修改文档
+	SharePermissionEdit
+)
+
+// 分享被访问时通知创建者的方式，可按位组合
+const (
+	// ShareNotifyInApp 站内通知
+	ShareNotifyInApp = 1 << iota
+	// ShareNotifyEmail 邮件通知
+	ShareNotifyEmail
+)
+
+// SharePermissionAll 全部权限
+const SharePermissionAll = SharePermissionView | SharePermissionDownload | SharePermissionUpload | SharePermissionEdit
+
 // Share 分享模型
 type Share struct {
 	gorm.Model
 	Password        string     // 分享密码，空值为非加密分享
-	IsDir           bool       // 原始资源是否为目录
+	IsDir           bool       // 原始资源是否为目录，合集分享以虚拟目录呈现，同样为 true
 	UserID          uint       // 创建用户ID
 	SourceID        uint       // 原始资源ID
 	Views           int        // 浏览数
 	Downloads       int        // 下载数
 	RemainDownloads int        // 剩余下载配额，负值标识无限制
+	RemainViews     int        `gorm:"default:-1"` // 剩余浏览配额，负值标识无限制，同一访客只计一次
 	Expires         *time.Time // 过期时间，空值表示无过期时间
+	ActivatesAt     *time.Time // 生效时间，空值表示创建后立即生效
 	PreviewEnabled  bool       // 是否允许直接预览
-	SourceName      string     `gorm:"index:source"` // 用于搜索的字段
+	AllowUpload     bool       // 是否允许访客向分享的目录上传文件
+	Permissions     int        // 授予访客的权限，0 表示按预览、上传开关推导
+	Internal        bool       // 是否仅限创建者及分享对象访问
+	Collection      bool       // 是否为合集分享，源对象记录于 ShareItem，SourceID 为 0
+	SourceName      string     `gorm:"index:source"`             // 用于搜索的字段
+	Slug            string     `gorm:"size:64;index:share_slug"` // 自定义链接，空值表示使用随机 key
+	Watermark       string     // 预览图片、PDF 时叠加的水印模板，空值表示不添加水印
+	AccessNotify    int        // 被访问时通知创建者的方式，0 表示不通知
+	AccessNotified  uint       // 已汇总通知的最后一条访问记录的 ID
+	Disabled        bool       // 是否已被管理员停用
+	EmbedDomains    string     `gorm:"type:text"` // 允许嵌入此分享的域名，以空格分隔，空值表示不允许嵌入
 
 	// 数据库忽略字段
 	User   User   `gorm:"PRELOAD:false,association_autoupdate:false"`
@@ -42,11 +94,11 @@ func (share *Share) Create() (uint, error) {
 	return share.ID, nil
 }
 
-// GetShareByHashID 根据HashID查找分享
+// GetShareByHashID 根据HashID、自定义链接或曾用的自定义链接查找分享
 func GetShareByHashID(hashID string) *Share {
 	id, err := hashid.DecodeHashID(hashID, hashid.ShareID)
 	if err != nil {
-		return nil
+		return getShareBySlug(hashID)
 	}
 	var share Share
 	result := DB.First(&share, id)
@@ -57,9 +109,117 @@ func GetShareByHashID(hashID string) *Share {
 	return &share
 }
 
-// IsAvailable 返回此分享是否可用（是否过期）
+// getShareBySlug 根据自定义链接查找分享，当前链接优先于曾用链接
+func getShareBySlug(slug string) *Share {
+	if !shareSlugPattern.MatchString(slug) {
+		return nil
+	}
+
+	var share Share
+	if err := DB.Where("slug = ?", slug).First(&share).Error; err == nil {
+		return &share
+	}
+
+	var alias ShareAlias
+	if err := DB.Where("slug = ?", slug).First(&alias).Error; err != nil {
+		return nil
+	}
+
+	if err := DB.First(&share, alias.ShareID).Error; err != nil {
+		return nil
+	}
+
+	return &share
+}
+
+// GetShareByID 根据ID查找分享
+func GetShareByID(id uint) (*Share, error) {
+	var share Share
+	result := DB.First(&share, id)
+	return &share, result.Error
+}
+
+// Key 返回分享链接中使用的 key，设置了自定义链接时为自定义链接
+func (share *Share) Key() string {
+	if share.Slug != "" {
+		return share.Slug
+	}
+	return hashid.HashID(share.ID, hashid.ShareID)
+}
+
+// ValidateShareSlug 检查自定义链接的格式，可被解析为随机 key 的链接及保留字不可使用
+func ValidateShareSlug(slug string) error {
+	if !shareSlugPattern.MatchString(slug) {
+		return ErrShareSlugInvalid
+	}
+
+	if _, err := hashid.DecodeHashID(slug, hashid.ShareID); err == nil {
+		return ErrShareSlugReserved
+	}
+
+	for _, reserved := range strings.Split(GetSettingByName("share_slug_reserved"), ",") {
+		if strings.EqualFold(strings.TrimSpace(reserved), slug) {
+			return ErrShareSlugReserved
+		}
+	}
+
+	return nil
+}
+
+// SetSlug 设置分享的自定义链接，slug 为空时恢复使用随机 key。
+// 原有的自定义链接作为曾用链接保留，继续指向此分享
+func (share *Share) SetSlug(slug string) error {
+	if slug == share.Slug {
+		return nil
+	}
+
+	if slug != "" {
+		var count int
+		DB.Model(&Share{}).Where("slug = ? and id <> ?", slug, share.ID).Count(&count)
+		if count == 0 {
+			DB.Model(&ShareAlias{}).Where("slug = ? and share_id <> ?", slug, share.ID).Count(&count)
+		}
+		if count > 0 {
+			return ErrShareSlugTaken
+		}
+
+		if IsShareSlugBanned(slug) {
+			return ErrShareSlugReserved
+		}
+	}
+
+	tx := DB.Begin()
+	if share.Slug != "" {
+		if err := tx.Create(&ShareAlias{ShareID: share.ID, Slug: share.Slug}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	// 重新启用曾用链接时不再作为曾用链接保留
+	if slug != "" {
+		if err := tx.Unscoped().Where("slug = ?", slug).Delete(&ShareAlias{}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Model(share).UpdateColumn("slug", slug).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	share.Slug = slug
+	return nil
+}
+
+// IsAvailable 返回此分享是否可用（是否过期、被停用）
 func (share *Share) IsAvailable() bool {
-	if share.RemainDownloads == 0 {
+	if share.Disabled || share.RemainDownloads == 0 {
 		return false
 	}
 	if share.Expires != nil && time.Now().After(*share.Expires) {
@@ -71,7 +231,12 @@ func (share *Share) IsAvailable() bool {
 		return false
 	}
 
-	// 检查源对象是否存在
+	// 检查源对象是否存在，合集分享至少需保留一个源对象
+	if share.Collection {
+		folders, files := share.CollectionSources()
+		return len(folders)+len(files) > 0
+	}
+
 	var sourceID uint
 	if share.IsDir {
 		folder := share.SourceFolder()
@@ -88,6 +253,102 @@ func (share *Share) IsAvailable() bool {
 	return true
 }
 
+// Permission 返回分享授予访客的权限，未设置权限的分享可下载，并按预览、上传开关授予其余权限
+func (share *Share) Permission() int {
+	if share.Permissions != 0 {
+		return share.Permissions
+	}
+
+	permission := SharePermissionDownload
+	if share.PreviewEnabled {
+		permission |= SharePermissionView
+	}
+	if share.AllowUpload {
+		permission |= SharePermissionUpload
+	}
+	return permission
+}
+
+// Can 返回分享是否授予访客 permission 权限
+func (share *Share) Can(permission int) bool {
+	return share.Permission()&permission == permission
+}
+
+// NormalizeSharePermission 规范化权限：编辑需同时可预览，只有目录分享可接收上传
+func NormalizeSharePermission(permission int, isDir bool) int {
+	permission &= SharePermissionAll
+	if permission&SharePermissionEdit != 0 {
+		permission |= SharePermissionView
+	}
+	if !isDir {
+		permission &^= SharePermissionUpload
+	}
+	return permission
+}
+
+// SetPermissions 更新分享的权限，同时同步预览、上传开关
+func (share *Share) SetPermissions(permission int) error {
+	permission = NormalizeSharePermission(permission, share.IsDir && !share.Collection)
+	share.Permissions = permission
+	share.PreviewEnabled = permission&SharePermissionView != 0
+	share.AllowUpload = permission&SharePermissionUpload != 0
+	return share.Update(map[string]interface{}{
+		"permissions":     share.Permissions,
+		"preview_enabled": share.PreviewEnabled,
+		"allow_upload":    share.AllowUpload,
+	})
+}
+
+// IsAccessibleBy 返回 user 能否访问此分享，仅限分享对象访问的分享对其他用户不可见
+func (share *Share) IsAccessibleBy(user *User) bool {
+	if !share.Internal || share.UserID == user.ID {
+		return true
+	}
+
+	if user.IsAnonymous() {
+		return false
+	}
+
+	_, err := GetShareTargetOf(share.ID, user)
+	return err == nil
+}
+
+// CanBeResharedBy 返回 user 能否为此分享添加分享对象
+func (share *Share) CanBeResharedBy(user *User) bool {
+	if share.UserID == user.ID {
+		return true
+	}
+
+	if !share.Internal || user.IsAnonymous() {
+		return false
+	}
+
+	target, err := GetShareTargetOf(share.ID, user)
+	return err == nil && target.CanReshare
+}
+
+// IsActivated 返回分享是否已到生效时间
+func (share *Share) IsActivated() bool {
+	return share.ActivatesAt == nil || !time.Now().Before(*share.ActivatesAt)
+}
+
+// WatermarkText 按水印模板生成 IP 为 ip 的访客 user 看到的水印文字，
+// 支持 {ip}、{user}、{date}、{time} 占位符
+func (share *Share) WatermarkText(user *User, ip string) string {
+	name := "guest"
+	if !user.IsAnonymous() {
+		name = user.Email
+	}
+
+	now := time.Now()
+	return strings.NewReplacer(
+		"{ip}", ip,
+		"{user}", name,
+		"{date}", now.Format("2006-01-02"),
+		"{time}", now.Format("2006-01-02 15:04"),
+	).Replace(share.Watermark)
+}
+
 // Creator 获取分享的创建者
 func (share *Share) Creator() *User {
 	if share.User.ID == 0 {
@@ -104,8 +365,13 @@ func (share *Share) Source() interface{} {
 	return share.SourceFile()
 }
 
-// SourceFolder 获取源目录
+// SourceFolder 获取源目录，合集分享返回以分享名称命名的虚拟目录
 func (share *Share) SourceFolder() *Folder {
+	if share.Collection {
+		share.Folder = Folder{Name: share.SourceName, OwnerID: share.UserID}
+		return &share.Folder
+	}
+
 	if share.Folder.ID == 0 {
 		folders, _ := GetFoldersByIDs([]uint{share.SourceID}, share.UserID)
 		if len(folders) > 0 {
@@ -115,6 +381,37 @@ func (share *Share) SourceFolder() *Folder {
 	return &share.Folder
 }
 
+// CollectionSources 返回合集分享中仍存在的源目录与源文件，已被删除的源对象不包含在结果内
+func (share *Share) CollectionSources() ([]Folder, []File) {
+	items, err := GetShareItems(share.ID)
+	if err != nil {
+		util.Log().Warning("Failed to list share items: %s", err)
+		return nil, nil
+	}
+
+	var folderIDs, fileIDs []uint
+	for _, item := range items {
+		if item.IsDir {
+			folderIDs = append(folderIDs, item.SourceID)
+		} else {
+			fileIDs = append(fileIDs, item.SourceID)
+		}
+	}
+
+	var (
+		folders []Folder
+		files   []File
+	)
+	if len(folderIDs) > 0 {
+		folders, _ = GetFoldersByIDs(folderIDs, share.UserID)
+	}
+	if len(fileIDs) > 0 {
+		files, _ = GetFilesByIDs(fileIDs, share.UserID)
+	}
+
+	return folders, files
+}
+
 // SourceFile 获取源文件
 func (share *Share) SourceFile() *File {
 	if share.File.ID == 0 {
@@ -163,6 +460,70 @@ func (share *Share) DownloadBy(user *User, c *gin.Context) error {
 	return nil
 }
 
+// WasViewedBy 返回分享是否已被用户浏览过
+func (share *Share) WasViewedBy(user *User, c *gin.Context) (exist bool) {
+	key := fmt.Sprintf("share_view_%d_%d", share.ID, user.ID)
+	if user.IsAnonymous() {
+		exist = util.GetSession(c, key) != nil
+	} else {
+		_, exist = cache.Get(key)
+	}
+
+	return exist
+}
+
+// IsVisibleTo 返回浏览配额用尽后用户能否继续访问此分享，已浏览过的访客不受影响
+func (share *Share) IsVisibleTo(user *User, c *gin.Context) bool {
+	return share.RemainViews != 0 || share.WasViewedBy(user, c)
+}
+
+// ViewBy 增加浏览次数，限制浏览次数的分享在用户首次浏览时扣除配额
+func (share *Share) ViewBy(user *User, c *gin.Context) {
+	if share.RemainViews > 0 && !share.WasViewedBy(user, c) {
+		key := fmt.Sprintf("share_view_%d_%d", share.ID, user.ID)
+		share.RemainViews--
+		DB.Model(share).Where("remain_views > ?", 0).
+			UpdateColumn("remain_views", gorm.Expr("remain_views - ?", 1))
+		if !user.IsAnonymous() {
+			cache.Set(key, true, GetIntSetting("share_download_session_timeout", 2073600))
+		} else {
+			util.SetSession(c, map[string]interface{}{key: true})
+		}
+	}
+
+	share.Viewed()
+}
+
+// CountAnonymousUpload 记录同一来源的游客向分享上传文件的次数，时间窗口内超出限制时返回 ErrShareUploadLimited
+func (share *Share) CountAnonymousUpload(ip string) error {
+	key := fmt.Sprintf("share_upload_count_%d_%s", share.ID, ip)
+	count, err := cache.IncrBy(key, 1, GetIntSetting("share_anonymous_upload_window", 3600))
+	if err != nil {
+		return err
+	}
+
+	if count > int64(GetIntSetting("share_anonymous_upload_limit", 20)) {
+		return ErrShareUploadLimited
+	}
+
+	return nil
+}
+
+// CountPasswordAttempt 记录同一来源尝试分享密码的次数，时间窗口内超出限制时返回 ErrSharePasswordLimited
+func (share *Share) CountPasswordAttempt(ip string) error {
+	key := fmt.Sprintf("share_password_count_%d_%s", share.ID, ip)
+	count, err := cache.IncrBy(key, 1, GetIntSetting("share_password_attempt_window", 600))
+	if err != nil {
+		return err
+	}
+
+	if count > int64(GetIntSetting("share_password_attempt_limit", 10)) {
+		return ErrSharePasswordLimited
+	}
+
+	return nil
+}
+
 // Viewed 增加访问次数
 func (share *Share) Viewed() {
 	share.Views++
@@ -196,6 +557,23 @@ func DeleteShareBySourceIDs(sources []uint, isDir bool) error {
 	return DB.Where("source_id in (?) and is_dir = ?", sources, isDir).Delete(&Share{}).Error
 }
 
+// ListSharesWithAccessNotify 列出开启了访问通知的分享
+func ListSharesWithAccessNotify() ([]Share, error) {
+	var shares []Share
+	result := DB.Where("access_notify <> ?", 0).Find(&shares)
+	return shares, result.Error
+}
+
+// SetAccessNotify 设置访问通知方式，开启通知时此前的访问记录不再通知
+func (share *Share) SetAccessNotify(notify int) error {
+	props := map[string]interface{}{"access_notify": notify}
+	if share.AccessNotify == 0 && notify != 0 {
+		props["access_notified"] = GetLatestShareAccessID(share.ID)
+	}
+
+	return share.Update(props)
+}
+
 // ListShares 列出UID下的分享
 func ListShares(uid uint, page, pageSize int, order string, publicOnly bool) ([]Share, int) {
 	var (
@@ -205,7 +583,7 @@ func ListShares(uid uint, page, pageSize int, order string, publicOnly bool) ([]
 	dbChain := DB
 	dbChain = dbChain.Where("user_id = ?", uid)
 	if publicOnly {
-		dbChain = dbChain.Where("password = ?", "")
+		dbChain = dbChain.Where("password = ? and internal = ? and (activates_at is NULL or activates_at <= ?)", "", false, time.Now())
 	}
 
 	// 计算总数用于分页
@@ -235,7 +613,7 @@ func SearchShares(page, pageSize int, order, keywords string) ([]Share, int) {
 	}
 
 	dbChain := DB
-	dbChain = dbChain.Where("password = ? and remain_downloads <> 0 and (expires is NULL or expires > ?) and source_name like ?", "", time.Now(), "%"+strings.Join(availableList, "%")+"%")
+	dbChain = dbChain.Where("password = ? and internal = ? and remain_downloads <> 0 and remain_views <> 0 and (expires is NULL or expires > ?) and (activates_at is NULL or activates_at <= ?) and source_name like ?", "", false, time.Now(), time.Now(), "%"+strings.Join(availableList, "%")+"%")
 
 	// 计算总数用于分页
 	dbChain.Model(&Share{}).Count(&total)
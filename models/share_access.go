@@ -0,0 +1,80 @@
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 分享访问记录的类型
+const (
+	ShareAccessView     = "view"
+	ShareAccessDownload = "download"
+)
+
+// ShareAccess 分享被访问的记录
+type ShareAccess struct {
+	gorm.Model
+	ShareID   uint   `gorm:"index:share_access_share_id"`
+	Action    string `gorm:"size:16"`
+	IPHash    string `gorm:"size:64"` // 访客 IP 的摘要，不保存原始 IP
+	UserAgent string `gorm:"size:255"`
+	FileName  string `gorm:"type:text"` // 被下载的文件在分享中的路径，浏览时为空
+}
+
+// NewShareAccess 生成分享的访问记录
+func NewShareAccess(share *Share, action, ip, userAgent, fileName string) ShareAccess {
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	return ShareAccess{
+		ShareID:   share.ID,
+		Action:    action,
+		IPHash:    hashIP(ip),
+		UserAgent: userAgent,
+		FileName:  fileName,
+	}
+}
+
+// hashIP 以站点密钥计算 IP 的摘要，用于统计独立访客
+func hashIP(ip string) string {
+	mac := hmac.New(sha256.New, []byte(GetSettingByName("secret_key")))
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Create 保存访问记录
+func (access *ShareAccess) Create() error {
+	return DB.Create(access).Error
+}
+
+// ListShareAccesses 按时间顺序列出分享在 since 之后的访问记录
+func ListShareAccesses(shareID uint, since time.Time) ([]ShareAccess, error) {
+	var accesses []ShareAccess
+	result := DB.Where("share_id = ? and created_at >= ?", shareID, since).Order("id asc").Find(&accesses)
+	return accesses, result.Error
+}
+
+// ListShareAccessesAfterID 按顺序列出分享中 ID 大于 afterID 的访问记录，只读取统计所需的字段
+func ListShareAccessesAfterID(shareID, afterID uint) ([]ShareAccess, error) {
+	var accesses []ShareAccess
+	result := DB.Select("id, action, ip_hash").Where("share_id = ? and id > ?", shareID, afterID).
+		Order("id asc").Find(&accesses)
+	return accesses, result.Error
+}
+
+// GetLatestShareAccessID 返回分享最新一条访问记录的 ID，没有记录时为 0
+func GetLatestShareAccessID(shareID uint) uint {
+	var access ShareAccess
+	DB.Select("id").Where("share_id = ?", shareID).Order("id desc").First(&access)
+	return access.ID
+}
+
+// DeleteShareAccessesBefore 删除 before 之前的访问记录
+func DeleteShareAccessesBefore(before time.Time) error {
+	return DB.Unscoped().Where("created_at < ?", before).Delete(&ShareAccess{}).Error
+}
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewShareAccess(t *testing.T) {
+	a := assert.New(t)
+	cache.Set("setting_secret_key", "secret", 0)
+	share := &Share{Model: gorm.Model{ID: 1}}
+
+	access := NewShareAccess(share, ShareAccessDownload, "1.1.1.1", strings.Repeat("a", 300), "/doc.txt")
+	a.EqualValues(1, access.ShareID)
+	a.Equal(ShareAccessDownload, access.Action)
+	a.Len(access.IPHash, 64)
+	a.NotContains(access.IPHash, "1.1.1.1")
+	a.Len(access.UserAgent, 255)
+	a.Equal("/doc.txt", access.FileName)
+
+	// 同一 IP 摘要相同
+	a.Equal(access.IPHash, NewShareAccess(share, ShareAccessView, "1.1.1.1", "", "").IPHash)
+	a.NotEqual(access.IPHash, NewShareAccess(share, ShareAccessView, "2.2.2.2", "", "").IPHash)
+}
+
+func TestShareAccess_Create(t *testing.T) {
+	a := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)share_accesses(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		access := ShareAccess{ShareID: 1, Action: ShareAccessView}
+		a.NoError(access.Create())
+		a.NoError(mock.ExpectationsWereMet())
+		a.EqualValues(1, access.ID)
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)share_accesses(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		access := ShareAccess{ShareID: 1, Action: ShareAccessView}
+		a.Error(access.Create())
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestListShareAccesses(t *testing.T) {
+	a := assert.New(t)
+	since := time.Now().AddDate(0, 0, -7)
+
+	mock.ExpectQuery("SELECT(.+)share_accesses(.+)").WithArgs(1, since).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "share_id", "action"}).
+			AddRow(1, 1, ShareAccessView).
+			AddRow(2, 1, ShareAccessDownload))
+	res, err := ListShareAccesses(1, since)
+	a.NoError(mock.ExpectationsWereMet())
+	a.NoError(err)
+	a.Len(res, 2)
+	a.Equal(ShareAccessDownload, res[1].Action)
+}
+
+func TestListShareAccessesAfterID(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)share_accesses(.+)").WithArgs(1, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "action", "ip_hash"}).
+			AddRow(6, ShareAccessView, "a").
+			AddRow(7, ShareAccessDownload, "a"))
+	res, err := ListShareAccessesAfterID(1, 5)
+	a.NoError(mock.ExpectationsWereMet())
+	a.NoError(err)
+	a.Len(res, 2)
+	a.EqualValues(7, res[1].ID)
+}
+
+func TestGetLatestShareAccessID(t *testing.T) {
+	a := assert.New(t)
+
+	// 存在记录
+	{
+		mock.ExpectQuery("SELECT(.+)share_accesses(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+		a.EqualValues(9, GetLatestShareAccessID(1))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 没有记录
+	{
+		mock.ExpectQuery("SELECT(.+)share_accesses(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		a.EqualValues(0, GetLatestShareAccessID(1))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestDeleteShareAccessesBefore(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)share_accesses(.+)").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+	a.NoError(DeleteShareAccessesBefore(time.Now()))
+	a.NoError(mock.ExpectationsWereMet())
+}
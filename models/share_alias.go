@@ -0,0 +1,10 @@
+package model
+
+import "github.com/jinzhu/gorm"
+
+// ShareAlias 分享曾用的自定义链接，访问时仍指向原分享
+type ShareAlias struct {
+	gorm.Model
+	ShareID uint   `gorm:"index:share_alias_share_id"`
+	Slug    string `gorm:"size:64;unique_index:share_alias_slug"`
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ErrShareEmbedDomainInvalid 允许嵌入的域名格式不正确或数量过多
+var ErrShareEmbedDomainInvalid = errors.New("embedding domains must be at most 20 hosts like example.com, *.example.com or https://example.com:8080")
+
+// embedDomainPattern 允许嵌入的域名格式，与 CSP frame-ancestors 的来源表达式兼容
+var embedDomainPattern = regexp.MustCompile(`^(https?://)?(\*\.)?[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*(:[0-9]{1,5})?$`)
+
+// maxEmbedDomains 单个分享允许嵌入的域名数量上限
+const maxEmbedDomains = 20
+
+// ParseEmbedDomains 解析以逗号或空白分隔的允许嵌入的域名列表，统一为小写并去重，
+// 结果以空格分隔，可直接用于 frame-ancestors 指令
+func ParseEmbedDomains(value string) (string, error) {
+	fields := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+
+	domains := make([]string, 0, len(fields))
+	seen := make(map[string]bool, len(fields))
+	for _, domain := range fields {
+		domain = strings.TrimSuffix(domain, "/")
+		if !embedDomainPattern.MatchString(domain) {
+			return "", ErrShareEmbedDomainInvalid
+		}
+		if !seen[domain] {
+			seen[domain] = true
+			domains = append(domains, domain)
+		}
+	}
+
+	if len(domains) > maxEmbedDomains {
+		return "", ErrShareEmbedDomainInvalid
+	}
+
+	return strings.Join(domains, " "), nil
+}
+
+// IsEmbeddable 返回分享能否被嵌入其他站点。只有设置了允许嵌入的域名、允许预览、
+// 无密码且非内部分享的单个文件分享可被嵌入，嵌入页中的访客无法解锁或登录
+func (share *Share) IsEmbeddable() bool {
+	return share.EmbedDomains != "" && !share.IsDir && share.Password == "" && !share.Internal &&
+		share.Can(SharePermissionView)
+}
+
+// FrameAncestors 返回嵌入页 CSP frame-ancestors 指令的值，站点自身始终允许嵌入
+func (share *Share) FrameAncestors() string {
+	return strings.TrimSpace("'self' " + share.EmbedDomains)
+}
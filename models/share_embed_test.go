@@ -0,0 +1,67 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEmbedDomains(t *testing.T) {
+	a := assert.New(t)
+
+	// 统一格式并去重
+	{
+		domains, err := ParseEmbedDomains(" Example.com, *.blog.example.com\nhttps://a.example.com:8080/ example.com")
+		a.NoError(err)
+		a.Equal("example.com *.blog.example.com https://a.example.com:8080", domains)
+	}
+
+	// 空值
+	{
+		domains, err := ParseEmbedDomains(" , ")
+		a.NoError(err)
+		a.Empty(domains)
+	}
+
+	// 格式不正确
+	for _, value := range []string{"*", "'none'", "example.com/path", "ftp://example.com", "exa mple;com", "-a.com"} {
+		_, err := ParseEmbedDomains(value)
+		a.Equal(ErrShareEmbedDomainInvalid, err, value)
+	}
+
+	// 数量过多
+	{
+		domains := make([]string, 0, 21)
+		for i := 0; i < 21; i++ {
+			domains = append(domains, fmt.Sprintf("%d.example.com", i))
+		}
+		_, err := ParseEmbedDomains(strings.Join(domains[:20], ","))
+		a.NoError(err)
+		_, err = ParseEmbedDomains(strings.Join(domains, ","))
+		a.Equal(ErrShareEmbedDomainInvalid, err)
+	}
+}
+
+func TestShare_IsEmbeddable(t *testing.T) {
+	a := assert.New(t)
+	share := Share{EmbedDomains: "example.com", Permissions: SharePermissionView}
+
+	a.True(share.IsEmbeddable())
+	a.Equal("'self' example.com", share.FrameAncestors())
+
+	// 未设置域名
+	a.False((&Share{Permissions: SharePermissionView}).IsEmbeddable())
+	a.Equal("'self'", (&Share{}).FrameAncestors())
+
+	// 目录、加密、内部或不可预览的分享
+	for _, s := range []Share{
+		{EmbedDomains: "example.com", Permissions: SharePermissionView, IsDir: true},
+		{EmbedDomains: "example.com", Permissions: SharePermissionView, Password: "pwd"},
+		{EmbedDomains: "example.com", Permissions: SharePermissionView, Internal: true},
+		{EmbedDomains: "example.com", Permissions: SharePermissionDownload},
+	} {
+		a.False(s.IsEmbeddable())
+	}
+}
@@ -0,0 +1,31 @@
+package model
+
+import "github.com/jinzhu/gorm"
+
+// ShareItem 合集分享包含的源目录或源文件
+type ShareItem struct {
+	gorm.Model
+	ShareID  uint `gorm:"index:share_item_share_id"`
+	SourceID uint
+	IsDir    bool
+}
+
+// CreateShareItems 在同一事务中保存合集分享的全部条目
+func CreateShareItems(items []ShareItem) error {
+	tx := DB.Begin()
+	for i := range items {
+		if err := tx.Create(&items[i]).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// GetShareItems 按添加顺序列出合集分享的条目
+func GetShareItems(shareID uint) ([]ShareItem, error) {
+	var items []ShareItem
+	result := DB.Where("share_id = ?", shareID).Order("id asc").Find(&items)
+	return items, result.Error
+}
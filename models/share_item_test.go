@@ -0,0 +1,76 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateShareItems(t *testing.T) {
+	a := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)share_items(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT(.+)share_items(.+)").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+		items := []ShareItem{{ShareID: 1, SourceID: 2, IsDir: true}, {ShareID: 1, SourceID: 3}}
+		a.NoError(CreateShareItems(items))
+		a.NoError(mock.ExpectationsWereMet())
+		a.EqualValues(2, items[1].ID)
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)share_items(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(CreateShareItems([]ShareItem{{ShareID: 1, SourceID: 2}}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestShare_CollectionSources(t *testing.T) {
+	a := assert.New(t)
+	share := Share{Collection: true, SourceName: "合集"}
+	share.ID = 1
+	share.UserID = 2
+
+	// 源目录与源文件
+	{
+		mock.ExpectQuery("SELECT(.+)share_items(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_id", "is_dir"}).AddRow(1, 3, true).AddRow(2, 4, false))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(3, "docs"))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(4, "a.txt"))
+		folders, files := share.CollectionSources()
+		a.NoError(mock.ExpectationsWereMet())
+		a.Len(folders, 1)
+		a.Len(files, 1)
+		a.Equal("a.txt", files[0].Name)
+	}
+
+	// 源对象均已删除，分享失效
+	{
+		share.User.ID = 2
+		share.User.Status = Active
+		mock.ExpectQuery("SELECT(.+)share_items(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_id", "is_dir"}).AddRow(1, 3, true))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		share.RemainDownloads = -1
+		a.False(share.IsAvailable())
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 虚拟目录
+	{
+		folder := share.SourceFolder()
+		a.Equal("合集", folder.Name)
+		a.EqualValues(0, folder.ID)
+	}
+}
@@ -0,0 +1,149 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrShareReportLimited 同一来源举报分享过于频繁
+	ErrShareReportLimited = errors.New("too many share reports")
+	// ErrShareReportDuplicated 同一来源对分享的举报尚未处理
+	ErrShareReportDuplicated = errors.New("share already reported")
+)
+
+// 举报的处理状态
+const (
+	// ShareReportPending 待处理
+	ShareReportPending = iota
+	// ShareReportResolved 已处理，分享已被停用或封禁
+	ShareReportResolved
+	// ShareReportDismissed 已驳回
+	ShareReportDismissed
+)
+
+// ShareReport 访客对分享的举报
+type ShareReport struct {
+	gorm.Model
+	ShareID     uint       `gorm:"index:share_report_share_id"`
+	ReporterID  uint       // 举报用户ID，游客为 0
+	IPHash      string     `gorm:"size:64"` // 举报来源 IP 的哈希值
+	Reason      string     `gorm:"size:32"` // 举报原因分类
+	Description string     `gorm:"type:text"`
+	Status      int        `gorm:"index:share_report_status"`
+	HandledBy   uint       // 处理举报的管理员ID
+	HandledAt   *time.Time // 处理时间
+}
+
+// ShareSlugBan 被封禁的自定义分享链接，不可再被任何分享使用
+type ShareSlugBan struct {
+	gorm.Model
+	ShareID uint
+	Slug    string `gorm:"size:64;unique_index:share_slug_ban_slug"`
+}
+
+// NewShareReport 创建来自 ip 的举报，举报原始 IP 不会被保存
+func NewShareReport(shareID, reporterID uint, ip, reason, description string) *ShareReport {
+	return &ShareReport{
+		ShareID:     shareID,
+		ReporterID:  reporterID,
+		IPHash:      hashIP(ip),
+		Reason:      reason,
+		Description: description,
+		Status:      ShareReportPending,
+	}
+}
+
+// CountShareReport 记录同一来源举报分享的次数，时间窗口内超出限制时返回 ErrShareReportLimited
+func CountShareReport(ip string) error {
+	key := fmt.Sprintf("share_report_count_%s", ip)
+	count, err := cache.IncrBy(key, 1, GetIntSetting("share_report_window", 3600))
+	if err != nil {
+		return err
+	}
+
+	if count > int64(GetIntSetting("share_report_limit", 10)) {
+		return ErrShareReportLimited
+	}
+
+	return nil
+}
+
+// Create 保存举报，同一来源对同一分享只保留一条待处理的举报
+func (report *ShareReport) Create() error {
+	var count int
+	DB.Model(&ShareReport{}).Where("share_id = ? and ip_hash = ? and status = ?",
+		report.ShareID, report.IPHash, ShareReportPending).Count(&count)
+	if count > 0 {
+		return ErrShareReportDuplicated
+	}
+
+	return DB.Create(report).Error
+}
+
+// GetShareReportByID 根据ID查找举报
+func GetShareReportByID(id uint) (*ShareReport, error) {
+	var report ShareReport
+	result := DB.First(&report, id)
+	return &report, result.Error
+}
+
+// ResolveShareReports 将分享所有待处理的举报标记为 status，由管理员 adminID 处理
+func ResolveShareReports(shareID, adminID uint, status int) error {
+	now := time.Now()
+	return DB.Model(&ShareReport{}).Where("share_id = ? and status = ?", shareID, ShareReportPending).
+		Updates(map[string]interface{}{"status": status, "handled_by": adminID, "handled_at": &now}).Error
+}
+
+// Disable 停用分享，停用后分享不再可用，创建者也无法自行恢复
+func (share *Share) Disable() error {
+	share.Disabled = true
+	return DB.Model(share).UpdateColumn("disabled", true).Error
+}
+
+// Ban 停用分享并封禁其当前及曾用的自定义链接，被封禁的链接不会再指向任何分享
+func (share *Share) Ban() error {
+	var aliases []ShareAlias
+	if err := DB.Where("share_id = ?", share.ID).Find(&aliases).Error; err != nil {
+		return err
+	}
+
+	slugs := make([]string, 0, len(aliases)+1)
+	if share.Slug != "" {
+		slugs = append(slugs, share.Slug)
+	}
+	for _, alias := range aliases {
+		slugs = append(slugs, alias.Slug)
+	}
+
+	tx := DB.Begin()
+	for _, slug := range slugs {
+		if err := tx.Create(&ShareSlugBan{ShareID: share.ID, Slug: slug}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Model(share).UpdateColumn("disabled", true).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	share.Disabled = true
+	return nil
+}
+
+// IsShareSlugBanned 返回自定义链接是否已被封禁
+func IsShareSlugBanned(slug string) bool {
+	var count int
+	DB.Model(&ShareSlugBan{}).Where("slug = ?", slug).Count(&count)
+	return count > 0
+}
@@ -0,0 +1,103 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewShareReport(t *testing.T) {
+	a := assert.New(t)
+	cache.Set("setting_secret_key", "secret", 0)
+
+	report := NewShareReport(1, 0, "1.1.1.1", "spam", "desc")
+	a.EqualValues(1, report.ShareID)
+	a.Len(report.IPHash, 64)
+	a.NotContains(report.IPHash, "1.1.1.1")
+	a.Equal(ShareReportPending, report.Status)
+}
+
+func TestCountShareReport(t *testing.T) {
+	a := assert.New(t)
+	cache.Set("setting_share_report_limit", "2", 0)
+	cache.Set("setting_share_report_window", "60", 0)
+	cache.Deletes([]string{"1.1.1.1", "2.2.2.2"}, "share_report_count_")
+
+	a.NoError(CountShareReport("1.1.1.1"))
+	a.NoError(CountShareReport("1.1.1.1"))
+	a.ErrorIs(CountShareReport("1.1.1.1"), ErrShareReportLimited)
+
+	// 其他来源不受影响
+	a.NoError(CountShareReport("2.2.2.2"))
+}
+
+func TestShareReport_Create(t *testing.T) {
+	a := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)share_reports(.+)").WithArgs(1, "hash", ShareReportPending).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)share_reports(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		report := ShareReport{ShareID: 1, IPHash: "hash"}
+		a.NoError(report.Create())
+		a.NoError(mock.ExpectationsWereMet())
+		a.EqualValues(1, report.ID)
+	}
+
+	// 已有待处理的举报
+	{
+		mock.ExpectQuery("SELECT(.+)share_reports(.+)").WithArgs(1, "hash", ShareReportPending).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		report := ShareReport{ShareID: 1, IPHash: "hash"}
+		a.Equal(ErrShareReportDuplicated, report.Create())
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestResolveShareReports(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)share_reports(.+)").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	a.NoError(ResolveShareReports(1, 2, ShareReportDismissed))
+	a.NoError(mock.ExpectationsWereMet())
+}
+
+func TestShare_Ban(t *testing.T) {
+	a := assert.New(t)
+
+	// 成功，封禁当前及曾用链接
+	{
+		share := Share{Model: gorm.Model{ID: 1}, Slug: "current"}
+		mock.ExpectQuery("SELECT(.+)share_aliases(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "share_id", "slug"}).AddRow(1, 1, "old"))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)share_slug_bans(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT(.+)share_slug_bans(.+)").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectExec("UPDATE(.+)shares(.+)").WithArgs(true, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		a.NoError(share.Ban())
+		a.NoError(mock.ExpectationsWereMet())
+		a.True(share.Disabled)
+	}
+
+	// 失败
+	{
+		share := Share{Model: gorm.Model{ID: 1}}
+		mock.ExpectQuery("SELECT(.+)share_aliases(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)shares(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(share.Ban())
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// ShareTarget 仅限分享对象访问的分享所指定的用户或用户组
+type ShareTarget struct {
+	gorm.Model
+	ShareID    uint `gorm:"index:share_target_share_id"`
+	UserID     uint `gorm:"index:share_target_user_id"`  // 分享对象为用户组时为 0
+	GroupID    uint `gorm:"index:share_target_group_id"` // 分享对象为用户时为 0
+	CanReshare bool // 能否将分享转给其他用户或用户组
+	GrantedBy  uint // 添加此分享对象的用户
+
+	// 数据库忽略字段
+	User  User  `gorm:"PRELOAD:false,association_autoupdate:false"`
+	Group Group `gorm:"PRELOAD:false,association_autoupdate:false"`
+}
+
+// CreateShareTargets 在同一事务中保存多个分享对象，已存在的分享对象更新其转发权限
+func CreateShareTargets(targets []ShareTarget) error {
+	tx := DB.Begin()
+	for i := range targets {
+		var existed ShareTarget
+		err := tx.Where("share_id = ? and user_id = ? and group_id = ?",
+			targets[i].ShareID, targets[i].UserID, targets[i].GroupID).First(&existed).Error
+		if err == nil {
+			targets[i].ID = existed.ID
+			err = tx.Model(&existed).UpdateColumn("can_reshare", targets[i].CanReshare).Error
+		} else if gorm.IsRecordNotFoundError(err) {
+			err = tx.Create(&targets[i]).Error
+		}
+
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// GetShareTargets 列出分享的全部分享对象
+func GetShareTargets(shareID uint) ([]ShareTarget, error) {
+	var targets []ShareTarget
+	result := DB.Where("share_id = ?", shareID).Order("id asc").Find(&targets)
+	return targets, result.Error
+}
+
+// GetShareTargetOf 返回 user 作为分享对象的记录，直接指定用户的记录优先于所在用户组的记录
+func GetShareTargetOf(shareID uint, user *User) (*ShareTarget, error) {
+	var target ShareTarget
+	result := DB.Where("share_id = ? and (user_id = ? or group_id = ?)", shareID, user.ID, user.GroupID).
+		Order("user_id desc").First(&target)
+	return &target, result.Error
+}
+
+// DeleteShareTargets 撤销分享的指定分享对象
+func DeleteShareTargets(shareID uint, ids []uint) error {
+	return DB.Where("share_id = ? and id in (?)", shareID, ids).Delete(&ShareTarget{}).Error
+}
+
+// ListSharesWithUser 列出其他用户分享给 user 及其所在用户组的分享
+func ListSharesWithUser(user *User, page, pageSize int) ([]Share, int) {
+	var (
+		shares []Share
+		total  int
+	)
+	targets := DB.Model(&ShareTarget{}).Select("share_id").
+		Where("user_id = ? or group_id = ?", user.ID, user.GroupID).QueryExpr()
+	dbChain := DB.Where("internal = ? and user_id <> ? and id in (?)", true, user.ID, targets)
+
+	// 计算总数用于分页
+	dbChain.Model(&Share{}).Count(&total)
+
+	// 查询记录
+	dbChain.Limit(pageSize).Offset((page - 1) * pageSize).Order("id desc").Find(&shares)
+	return shares, total
+}
@@ -0,0 +1,132 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateShareTargets(t *testing.T) {
+	a := assert.New(t)
+
+	// 新增及更新已存在的分享对象
+	{
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT(.+)share_targets(.+)").WithArgs(1, 2, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("INSERT(.+)share_targets(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.ExpectQuery("SELECT(.+)share_targets(.+)").WithArgs(1, 0, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+		mock.ExpectExec("UPDATE(.+)share_targets(.+)can_reshare(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		targets := []ShareTarget{{ShareID: 1, UserID: 2}, {ShareID: 1, GroupID: 3, CanReshare: true}}
+		a.NoError(CreateShareTargets(targets))
+		a.NoError(mock.ExpectationsWereMet())
+		a.EqualValues(5, targets[0].ID)
+		a.EqualValues(4, targets[1].ID)
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT(.+)share_targets(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(CreateShareTargets([]ShareTarget{{ShareID: 1, UserID: 2}}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestGetShareTargets(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)share_targets(.+)").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(1, 2).AddRow(2, 0))
+	targets, err := GetShareTargets(1)
+	a.NoError(mock.ExpectationsWereMet())
+	a.NoError(err)
+	a.Len(targets, 2)
+}
+
+func TestDeleteShareTargets(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)share_targets(.+)").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	a.NoError(DeleteShareTargets(1, []uint{1, 2}))
+	a.NoError(mock.ExpectationsWereMet())
+}
+
+func TestListSharesWithUser(t *testing.T) {
+	a := assert.New(t)
+	user := &User{Model: gorm.Model{ID: 2}, GroupID: 3}
+
+	mock.ExpectQuery("SELECT(.+)count(.+)share_targets(.+)share_targets.(.+)deleted_at. IS NULL(.+)").WithArgs(true, 2, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT(.+)share_targets(.+)").WithArgs(true, 2, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(1, 1))
+	shares, total := ListSharesWithUser(user, 1, 10)
+	a.NoError(mock.ExpectationsWereMet())
+	a.Equal(1, total)
+	a.Len(shares, 1)
+}
+
+func TestShare_IsAccessibleBy(t *testing.T) {
+	a := assert.New(t)
+	user := &User{Model: gorm.Model{ID: 2}, GroupID: 3}
+
+	// 公开分享
+	a.True((&Share{UserID: 1}).IsAccessibleBy(user))
+
+	// 创建者
+	a.True((&Share{UserID: 2, Internal: true}).IsAccessibleBy(user))
+
+	// 匿名用户
+	a.False((&Share{UserID: 1, Internal: true}).IsAccessibleBy(NewAnonymousUser()))
+
+	// 分享对象
+	{
+		mock.ExpectQuery("SELECT(.+)share_targets(.+)").WithArgs(5, 2, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		a.True((&Share{Model: gorm.Model{ID: 5}, UserID: 1, Internal: true}).IsAccessibleBy(user))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 非分享对象
+	{
+		mock.ExpectQuery("SELECT(.+)share_targets(.+)").WithArgs(5, 2, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		a.False((&Share{Model: gorm.Model{ID: 5}, UserID: 1, Internal: true}).IsAccessibleBy(user))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestShare_CanBeResharedBy(t *testing.T) {
+	a := assert.New(t)
+	user := &User{Model: gorm.Model{ID: 2}, GroupID: 3}
+
+	// 创建者
+	a.True((&Share{UserID: 2}).CanBeResharedBy(user))
+
+	// 公开分享
+	a.False((&Share{UserID: 1}).CanBeResharedBy(user))
+
+	// 可转发的分享对象
+	{
+		mock.ExpectQuery("SELECT(.+)share_targets(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "can_reshare"}).AddRow(1, true))
+		a.True((&Share{Model: gorm.Model{ID: 5}, UserID: 1, Internal: true}).CanBeResharedBy(user))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 不可转发的分享对象
+	{
+		mock.ExpectQuery("SELECT(.+)share_targets(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "can_reshare"}).AddRow(1, false))
+		a.False((&Share{Model: gorm.Model{ID: 5}, UserID: 1, Internal: true}).CanBeResharedBy(user))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
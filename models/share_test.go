@@ -63,12 +63,67 @@ func TestGetShareByHashID(t *testing.T) {
 		asserts.Nil(res)
 	}
 
-	// ID解码失败
+	// ID解码失败，自定义链接不存在
 	{
+		mock.ExpectQuery("SELECT(.+)shares(.+)").WithArgs("empty").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)share_aliases(.+)").WithArgs("empty").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
 		res := GetShareByHashID("empty")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(res)
+	}
+
+	// 格式不正确
+	{
+		res := GetShareByHashID("a/b")
 		asserts.Nil(res)
 	}
 
+	// 自定义链接
+	{
+		mock.ExpectQuery("SELECT(.+)shares(.+)").WithArgs("my-share").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "slug"}).AddRow(2, "my-share"))
+		res := GetShareByHashID("my-share")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(2, res.ID)
+	}
+
+	// 曾用的自定义链接
+	{
+		mock.ExpectQuery("SELECT(.+)shares(.+)").WithArgs("old-share").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)share_aliases(.+)").WithArgs("old-share").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "share_id"}).AddRow(1, 2))
+		mock.ExpectQuery("SELECT(.+)shares(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "slug"}).AddRow(2, "my-share"))
+		res := GetShareByHashID("old-share")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("my-share", res.Key())
+	}
+}
+
+func TestGetShareByID(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)shares(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "is_dir"}).AddRow(1, true))
+		res, err := GetShareByID(1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.True(res.IsDir)
+	}
+
+	// 不存在
+	{
+		mock.ExpectQuery("SELECT(.+)shares(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		_, err := GetShareByID(2)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
 }
 
 func TestShare_IsAvailable(t *testing.T) {
@@ -80,6 +135,12 @@ func TestShare_IsAvailable(t *testing.T) {
 		asserts.False(share.IsAvailable())
 	}
 
+	// 已被停用
+	{
+		share := Share{RemainDownloads: -1, Disabled: true}
+		asserts.False(share.IsAvailable())
+	}
+
 	// 时效过期
 	{
 		expires := time.Unix(10, 10)
@@ -312,10 +373,272 @@ func TestSearchShares(t *testing.T) {
 
 	mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectQuery("SELECT(.+)").
-		WithArgs("", sqlmock.AnyArg(), "%1%2%").
+		WithArgs("", false, sqlmock.AnyArg(), sqlmock.AnyArg(), "%1%2%").
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	res, total := SearchShares(1, 10, "id", "1 2")
 	asserts.NoError(mock.ExpectationsWereMet())
 	asserts.Len(res, 1)
 	asserts.Equal(1, total)
 }
+
+func TestShare_CountAnonymousUpload(t *testing.T) {
+	asserts := assert.New(t)
+	share := Share{Model: gorm.Model{ID: 5}}
+	cache.Set("setting_share_anonymous_upload_limit", "2", 0)
+	cache.Set("setting_share_anonymous_upload_window", "60", 0)
+	cache.Deletes([]string{"5_1.1.1.1", "5_2.2.2.2"}, "share_upload_count_")
+
+	asserts.NoError(share.CountAnonymousUpload("1.1.1.1"))
+	asserts.NoError(share.CountAnonymousUpload("1.1.1.1"))
+	asserts.ErrorIs(share.CountAnonymousUpload("1.1.1.1"), ErrShareUploadLimited)
+
+	// 其他来源不受影响
+	asserts.NoError(share.CountAnonymousUpload("2.2.2.2"))
+}
+
+func TestShare_ViewBy(t *testing.T) {
+	asserts := assert.New(t)
+	user := User{Model: gorm.Model{ID: 1}}
+	r := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(r)
+	cache.Deletes([]string{"1_1"}, "share_view_")
+
+	// 不限浏览次数
+	{
+		share := Share{Model: gorm.Model{ID: 1}, RemainViews: -1}
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)views(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		share.ViewBy(&user, c)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(1, share.Views)
+		asserts.EqualValues(-1, share.RemainViews)
+		asserts.False(share.WasViewedBy(&user, c))
+	}
+
+	// 首次浏览扣除配额
+	{
+		share := Share{Model: gorm.Model{ID: 1}, RemainViews: 1}
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)remain_views(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)views(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		share.ViewBy(&user, c)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(0, share.RemainViews)
+		asserts.True(share.WasViewedBy(&user, c))
+
+		// 配额用尽后，已浏览过的用户仍可访问
+		asserts.True(share.IsVisibleTo(&user, c))
+		asserts.False(share.IsVisibleTo(&User{Model: gorm.Model{ID: 2}}, c))
+	}
+
+	// 再次浏览不重复扣除
+	{
+		share := Share{Model: gorm.Model{ID: 1}, RemainViews: 1}
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)views(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		share.ViewBy(&user, c)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(1, share.RemainViews)
+	}
+}
+
+func TestShare_CountPasswordAttempt(t *testing.T) {
+	asserts := assert.New(t)
+	share := Share{Model: gorm.Model{ID: 5}}
+	cache.Set("setting_share_password_attempt_limit", "2", 0)
+	cache.Set("setting_share_password_attempt_window", "60", 0)
+	cache.Deletes([]string{"5_1.1.1.1", "5_2.2.2.2"}, "share_password_count_")
+
+	asserts.NoError(share.CountPasswordAttempt("1.1.1.1"))
+	asserts.NoError(share.CountPasswordAttempt("1.1.1.1"))
+	asserts.ErrorIs(share.CountPasswordAttempt("1.1.1.1"), ErrSharePasswordLimited)
+
+	// 其他来源不受影响
+	asserts.NoError(share.CountPasswordAttempt("2.2.2.2"))
+}
+
+func TestShare_Permission(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 未设置权限，按预览、上传开关推导
+	{
+		share := Share{}
+		asserts.Equal(SharePermissionDownload, share.Permission())
+		asserts.False(share.Can(SharePermissionView))
+
+		share = Share{PreviewEnabled: true, AllowUpload: true, IsDir: true}
+		asserts.Equal(SharePermissionView|SharePermissionDownload|SharePermissionUpload, share.Permission())
+		asserts.False(share.Can(SharePermissionEdit))
+	}
+
+	// 已设置权限
+	{
+		share := Share{Permissions: SharePermissionView | SharePermissionEdit, PreviewEnabled: true}
+		asserts.True(share.Can(SharePermissionView))
+		asserts.True(share.Can(SharePermissionEdit))
+		asserts.False(share.Can(SharePermissionDownload))
+	}
+}
+
+func TestNormalizeSharePermission(t *testing.T) {
+	asserts := assert.New(t)
+
+	asserts.Equal(SharePermissionView|SharePermissionEdit, NormalizeSharePermission(SharePermissionEdit, false))
+	asserts.Equal(SharePermissionDownload, NormalizeSharePermission(SharePermissionDownload|SharePermissionUpload, false))
+	asserts.Equal(SharePermissionDownload|SharePermissionUpload, NormalizeSharePermission(SharePermissionDownload|SharePermissionUpload, true))
+	asserts.Equal(SharePermissionView, NormalizeSharePermission(SharePermissionView|32, true))
+}
+
+func TestShare_SetAccessNotify(t *testing.T) {
+	asserts := assert.New(t)
+	share := Share{Model: gorm.Model{ID: 1}}
+
+	// 开启通知，跳过已有的访问记录
+	{
+		mock.ExpectQuery("SELECT(.+)share_accesses(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)shares(.+)access_notified(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(share.SetAccessNotify(ShareNotifyEmail))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(9, share.AccessNotified)
+		asserts.Equal(ShareNotifyEmail, share.AccessNotify)
+	}
+
+	// 更改通知方式
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)shares(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(share.SetAccessNotify(ShareNotifyInApp | ShareNotifyEmail))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(9, share.AccessNotified)
+	}
+}
+
+func TestShare_SetPermissions(t *testing.T) {
+	asserts := assert.New(t)
+	share := Share{Model: gorm.Model{ID: 1}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)shares(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(share.SetPermissions(SharePermissionEdit | SharePermissionUpload))
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Equal(SharePermissionView|SharePermissionEdit, share.Permissions)
+	asserts.True(share.PreviewEnabled)
+	asserts.False(share.AllowUpload)
+}
+
+func TestValidateShareSlug(t *testing.T) {
+	asserts := assert.New(t)
+	conf.SystemConfig.HashIDSalt = ""
+	cache.Set("setting_share_slug_reserved", "admin, login", 0)
+
+	asserts.NoError(ValidateShareSlug("my-share_1"))
+	asserts.Equal(ErrShareSlugInvalid, ValidateShareSlug("abc"))
+	asserts.Equal(ErrShareSlugInvalid, ValidateShareSlug("my share"))
+	asserts.Equal(ErrShareSlugReserved, ValidateShareSlug("Login"))
+	// 可被解析为随机 key
+	asserts.Equal(ErrShareSlugReserved, ValidateShareSlug("x9T4"))
+}
+
+func TestShare_SetSlug(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 已被其他分享使用
+	{
+		share := Share{Model: gorm.Model{ID: 1}}
+		mock.ExpectQuery("SELECT(.+)shares(.+)").WithArgs("taken", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		asserts.Equal(ErrShareSlugTaken, share.SetSlug("taken"))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Empty(share.Slug)
+	}
+
+	// 链接已被封禁
+	{
+		share := Share{Model: gorm.Model{ID: 1}}
+		mock.ExpectQuery("SELECT(.+)shares(.+)").WithArgs("banned", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery("SELECT(.+)share_aliases(.+)").WithArgs("banned", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery("SELECT(.+)share_slug_bans(.+)").WithArgs("banned").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		asserts.Equal(ErrShareSlugReserved, share.SetSlug("banned"))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Empty(share.Slug)
+	}
+
+	// 设置新链接，原有链接作为曾用链接保留
+	{
+		share := Share{Model: gorm.Model{ID: 1}, Slug: "old-share"}
+		mock.ExpectQuery("SELECT(.+)shares(.+)").WithArgs("new-share", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery("SELECT(.+)share_aliases(.+)").WithArgs("new-share", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery("SELECT(.+)share_slug_bans(.+)").WithArgs("new-share").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)share_aliases(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("DELETE(.+)share_aliases(.+)").WithArgs("new-share").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("UPDATE(.+)shares(.+)").WithArgs("new-share", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		asserts.NoError(share.SetSlug("new-share"))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("new-share", share.Key())
+	}
+
+	// 恢复使用随机 key
+	{
+		share := Share{Model: gorm.Model{ID: 1}, Slug: "old-share"}
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)share_aliases(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)shares(.+)").WithArgs("", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		asserts.NoError(share.SetSlug(""))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Empty(share.Slug)
+	}
+
+	// 保存失败
+	{
+		share := Share{Model: gorm.Model{ID: 1}, Slug: "old-share"}
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)share_aliases(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(share.SetSlug(""))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("old-share", share.Slug)
+	}
+}
+
+func TestShare_IsActivated(t *testing.T) {
+	asserts := assert.New(t)
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	asserts.True((&Share{}).IsActivated())
+	asserts.True((&Share{ActivatesAt: &past}).IsActivated())
+	asserts.False((&Share{ActivatesAt: &future}).IsActivated())
+}
+
+func TestShare_WatermarkText(t *testing.T) {
+	asserts := assert.New(t)
+	share := Share{Watermark: "{user} {ip} {date}"}
+	date := time.Now().Format("2006-01-02")
+
+	// 游客
+	asserts.Equal("guest 1.2.3.4 "+date, share.WatermarkText(&User{}, "1.2.3.4"))
+
+	// 登录用户
+	user := &User{Email: "a@example.com"}
+	user.ID = 1
+	asserts.Equal("a@example.com 1.2.3.4 "+date, share.WatermarkText(user, "1.2.3.4"))
+}
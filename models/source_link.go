@@ -1,10 +1,16 @@
 package model
 
 import (
+	"encoding/gob"
+	"errors"
 	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/jinzhu/gorm"
-	"net/url"
 )
 
 // SourceLink represent a shared file source link
@@ -45,3 +51,88 @@ func (s *SourceLink) Downloaded() {
 	s.Downloads++
 	DB.Model(s).UpdateColumn("downloads", gorm.Expr("downloads + ?", 1))
 }
+
+// ErrTempSourceLinkInvalid 临时外链不存在、已过期或下载次数已用完
+var ErrTempSourceLinkInvalid = errors.New("temporary source link is expired or not exist")
+
+// TempSourceLink 有效期和下载次数受限的临时外链，仅记录在缓存中
+type TempSourceLink struct {
+	Token     string
+	FileID    uint
+	Downloads int       // 最大下载次数，为 0 时不限制
+	Expires   time.Time // 过期时间
+}
+
+func init() {
+	gob.Register(TempSourceLink{})
+}
+
+// CreateTempSourceLink 为文件创建临时外链，ttl 为有效期秒数，downloads 为最大下载次数，0 为不限制
+func CreateTempSourceLink(fileID uint, ttl int, downloads int) (*TempSourceLink, error) {
+	link := &TempSourceLink{
+		Token:     util.RandStringRunes(32),
+		FileID:    fileID,
+		Downloads: downloads,
+		Expires:   time.Now().Add(time.Duration(ttl) * time.Second),
+	}
+
+	if err := cache.Set("temp_source_"+link.Token, *link, ttl); err != nil {
+		return nil, err
+	}
+
+	return link, nil
+}
+
+// GetTempSourceLink 根据 token 获取未过期的临时外链，不消耗下载次数
+func GetTempSourceLink(token string) (*TempSourceLink, error) {
+	raw, ok := cache.Get("temp_source_" + token)
+	if !ok {
+		return nil, ErrTempSourceLinkInvalid
+	}
+
+	link := raw.(TempSourceLink)
+	if !time.Now().Before(link.Expires) {
+		_ = cache.Deletes([]string{token}, "temp_source_")
+		return nil, ErrTempSourceLinkInvalid
+	}
+
+	return &link, nil
+}
+
+// Use 按本次请求传输的字节数 bytes 消耗临时外链的下载额度，总额度为下载次数与文件大小 size 之积。
+// 已用额度记录在缓存计数器中，多个节点共享；续传只计入续传的部分，分段请求无法绕过次数限制
+func (s *TempSourceLink) Use(bytes, size uint64) error {
+	if s.Downloads == 0 {
+		return nil
+	}
+
+	// 空文件按请求次数计数
+	if size == 0 || bytes == 0 {
+		size, bytes = 1, 1
+	}
+
+	ttl := int(time.Until(s.Expires).Seconds()) + 1
+	key := "temp_source_used_" + s.Token
+	used, err := cache.IncrBy(key, int64(bytes), ttl)
+	if err != nil {
+		return err
+	}
+
+	if uint64(used) > uint64(s.Downloads)*size {
+		// 回退本次计数，额度不足时不允许部分下载
+		_, _ = cache.IncrBy(key, -int64(bytes), ttl)
+		return ErrTempSourceLinkInvalid
+	}
+
+	return nil
+}
+
+// Link 获取临时外链的 URL
+func (s *TempSourceLink) Link(name string) (string, error) {
+	baseURL := GetSiteURL()
+	linkPath, err := url.Parse(fmt.Sprintf("/t/%s/%s", s.Token, name))
+	if err != nil {
+		return "", err
+	}
+	return baseURL.ResolveReference(linkPath).String(), nil
+}
@@ -1,9 +1,12 @@
 package model
 
 import (
+	"testing"
+	"time"
+
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/stretchr/testify/assert"
-	"testing"
 )
 
 func TestSourceLink_Link(t *testing.T) {
@@ -50,3 +53,67 @@ func TestSourceLink_Downloaded(t *testing.T) {
 	s.Downloaded()
 	a.NoError(mock.ExpectationsWereMet())
 }
+
+func TestTempSourceLink(t *testing.T) {
+	a := assert.New(t)
+
+	// 不存在
+	{
+		res, err := GetTempSourceLink("not_exist")
+		a.ErrorIs(err, ErrTempSourceLinkInvalid)
+		a.Nil(res)
+	}
+
+	// 不限下载次数
+	{
+		link, err := CreateTempSourceLink(1, 60, 0)
+		a.NoError(err)
+		res, err := GetTempSourceLink(link.Token)
+		a.NoError(err)
+		a.EqualValues(1, res.FileID)
+		for i := 0; i < 3; i++ {
+			a.NoError(res.Use(100, 100))
+		}
+
+		url, err := link.Link("file.txt")
+		a.NoError(err)
+		a.Contains(url, "/t/"+link.Token+"/file.txt")
+	}
+
+	// 下载额度用完
+	{
+		link, err := CreateTempSourceLink(1, 60, 2)
+		a.NoError(err)
+		res, err := GetTempSourceLink(link.Token)
+		a.NoError(err)
+
+		// 续传只计入续传部分
+		a.NoError(res.Use(100, 100))
+		a.NoError(res.Use(40, 100))
+		a.NoError(res.Use(60, 100))
+		a.ErrorIs(res.Use(1, 100), ErrTempSourceLinkInvalid)
+
+		// 额度不足时回退计数
+		_, err = GetTempSourceLink(link.Token)
+		a.NoError(err)
+	}
+
+	// 空文件按请求次数计数
+	{
+		link, err := CreateTempSourceLink(1, 60, 1)
+		a.NoError(err)
+		a.NoError(link.Use(0, 0))
+		a.ErrorIs(link.Use(0, 0), ErrTempSourceLinkInvalid)
+	}
+
+	// 已过期
+	{
+		a.NoError(cache.Set("temp_source_expired", TempSourceLink{
+			Token:   "expired",
+			FileID:  1,
+			Expires: time.Now().Add(-time.Second),
+		}, 60))
+		_, err := GetTempSourceLink("expired")
+		a.ErrorIs(err, ErrTempSourceLinkInvalid)
+	}
+}
@@ -21,6 +21,8 @@ const (
 	FileTagType = iota
 	// DirectoryLinkType 目录快捷方式标签
 	DirectoryLinkType
+	// SmartFilterType 按文件类型、文件标签等条件组合筛选的智能标签
+	SmartFilterType
 )
 
 // Create 创建标签记录
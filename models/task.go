@@ -1,6 +1,9 @@
 package model
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/jinzhu/gorm"
 )
@@ -14,6 +17,7 @@ type Task struct {
 	Progress int    // 进度
 	Error    string `gorm:"type:text"` // 错误信息
 	Props    string `gorm:"type:text"` // 任务属性
+	Log      string `gorm:"type:text"` // 任务日志，如上传失败重试的记录
 }
 
 // Create 创建任务记录
@@ -30,16 +34,36 @@ func (task *Task) SetStatus(status int) error {
 	return DB.Model(task).Select("status").Updates(map[string]interface{}{"status": status}).Error
 }
 
+// GetStatus 从数据库读取任务的最新状态
+func (task *Task) GetStatus() (int, error) {
+	var latest Task
+	if err := DB.Select("status").Where("id = ?", task.ID).First(&latest).Error; err != nil {
+		return 0, err
+	}
+	return latest.Status, nil
+}
+
 // SetProgress 设定任务进度
 func (task *Task) SetProgress(progress int) error {
 	return DB.Model(task).Select("progress").Updates(map[string]interface{}{"progress": progress}).Error
 }
 
+// SetProps 设定任务属性
+func (task *Task) SetProps(props string) error {
+	return DB.Model(task).Select("props").Updates(map[string]interface{}{"props": props}).Error
+}
+
 // SetError 设定错误信息
 func (task *Task) SetError(err string) error {
 	return DB.Model(task).Select("error").Updates(map[string]interface{}{"error": err}).Error
 }
 
+// AppendLog 追加一行任务日志
+func (task *Task) AppendLog(line string) error {
+	task.Log += fmt.Sprintf("[%s] %s\n", time.Now().Format(time.RFC3339), line)
+	return DB.Model(task).Select("log").Updates(map[string]interface{}{"log": task.Log}).Error
+}
+
 // GetTasksByStatus 根据状态检索任务
 func GetTasksByStatus(status ...int) []Task {
 	var tasks []Task
@@ -5,6 +5,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
@@ -59,6 +60,30 @@ func TestTask_SetStatus(t *testing.T) {
 	asserts.NoError(mock.ExpectationsWereMet())
 }
 
+func TestTask_GetStatus(t *testing.T) {
+	asserts := assert.New(t)
+	task := Task{
+		Model: gorm.Model{ID: 1},
+	}
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)tasks(.+)").WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(3))
+		status, err := task.GetStatus()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(3, status)
+	}
+
+	// 失败
+	{
+		mock.ExpectQuery("SELECT(.+)tasks(.+)").WillReturnError(errors.New("error"))
+		_, err := task.GetStatus()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
 func TestTask_SetProgress(t *testing.T) {
 	asserts := assert.New(t)
 	task := Task{
@@ -71,6 +96,33 @@ func TestTask_SetProgress(t *testing.T) {
 	asserts.NoError(mock.ExpectationsWereMet())
 }
 
+func TestTask_SetProps(t *testing.T) {
+	asserts := assert.New(t)
+	task := Task{
+		Model: gorm.Model{ID: 1},
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)props(.+)").WithArgs("{}", sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(task.SetProps("{}"))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
+func TestTask_AppendLog(t *testing.T) {
+	asserts := assert.New(t)
+	task := Task{
+		Model: gorm.Model{ID: 1},
+		Log:   "first\n",
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)log(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(task.AppendLog("second"))
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.True(strings.HasPrefix(task.Log, "first\n["))
+	asserts.True(strings.HasSuffix(task.Log, "] second\n"))
+}
+
 func TestGetTasksByID(t *testing.T) {
 	asserts := assert.New(t)
 	mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
@@ -0,0 +1,131 @@
+package model
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// TrafficDateFormat 流量统计的日期格式
+const TrafficDateFormat = "2006-01-02"
+
+// Traffic 用户在存储策略上每日的上传、下载流量
+type Traffic struct {
+	gorm.Model
+	UserID   uint   `gorm:"unique_index:idx_traffic_user_policy_date"`
+	PolicyID uint   `gorm:"unique_index:idx_traffic_user_policy_date"`
+	Date     string `gorm:"size:10;unique_index:idx_traffic_user_policy_date"`
+	Upload   uint64 // 上传流量，单位字节
+	Download uint64 // 下载流量，单位字节
+}
+
+// trafficKey 内存中累计流量的分组
+type trafficKey struct {
+	UserID   uint
+	PolicyID uint
+	Date     string
+}
+
+var (
+	// trafficBuffer 尚未写入数据库的流量
+	trafficBuffer     = make(map[trafficKey]*Traffic)
+	trafficBufferLock sync.Mutex
+)
+
+// AddTraffic 记录用户在存储策略上产生的流量。流量先在内存中累计，
+// 由 FlushTraffic 定期写入数据库
+func AddTraffic(uid, policyID uint, upload, download uint64) {
+	if upload == 0 && download == 0 {
+		return
+	}
+
+	key := trafficKey{UserID: uid, PolicyID: policyID, Date: time.Now().Format(TrafficDateFormat)}
+
+	trafficBufferLock.Lock()
+	defer trafficBufferLock.Unlock()
+
+	t, ok := trafficBuffer[key]
+	if !ok {
+		t = &Traffic{UserID: uid, PolicyID: policyID, Date: key.Date}
+		trafficBuffer[key] = t
+	}
+	t.Upload += upload
+	t.Download += download
+}
+
+// FlushTraffic 将内存中累计的流量写入数据库，写入失败的部分留待下次重试
+func FlushTraffic() error {
+	trafficBufferLock.Lock()
+	pending := trafficBuffer
+	trafficBuffer = make(map[trafficKey]*Traffic)
+	trafficBufferLock.Unlock()
+
+	var lastErr error
+	for _, t := range pending {
+		if err := t.save(); err != nil {
+			lastErr = err
+			requeueTraffic(t)
+		}
+	}
+
+	return lastErr
+}
+
+// requeueTraffic 将写入失败的流量放回内存中累计
+func requeueTraffic(t *Traffic) {
+	key := trafficKey{UserID: t.UserID, PolicyID: t.PolicyID, Date: t.Date}
+
+	trafficBufferLock.Lock()
+	defer trafficBufferLock.Unlock()
+
+	if existed, ok := trafficBuffer[key]; ok {
+		existed.Upload += t.Upload
+		existed.Download += t.Download
+		return
+	}
+
+	trafficBuffer[key] = &Traffic{UserID: t.UserID, PolicyID: t.PolicyID, Date: t.Date, Upload: t.Upload, Download: t.Download}
+}
+
+// save 将流量累加到对应日期的统计记录中，记录不存在时创建
+func (t *Traffic) save() error {
+	res := DB.Model(&Traffic{}).
+		Where("user_id = ? and policy_id = ? and date = ?", t.UserID, t.PolicyID, t.Date).
+		UpdateColumns(map[string]interface{}{
+			"upload":   gorm.Expr("upload + ?", t.Upload),
+			"download": gorm.Expr("download + ?", t.Download),
+		})
+	if res.Error != nil || res.RowsAffected > 0 {
+		return res.Error
+	}
+
+	return DB.Create(&Traffic{
+		UserID:   t.UserID,
+		PolicyID: t.PolicyID,
+		Date:     t.Date,
+		Upload:   t.Upload,
+		Download: t.Download,
+	}).Error
+}
+
+// GetTraffics 按日期升序列出流量统计，uid、policyID 为 0 或日期为空时不作筛选
+func GetTraffics(uid, policyID uint, from, to string) ([]Traffic, error) {
+	var traffics []Traffic
+	query := DB.Model(&Traffic{})
+	if uid > 0 {
+		query = query.Where("user_id = ?", uid)
+	}
+	if policyID > 0 {
+		query = query.Where("policy_id = ?", policyID)
+	}
+	if from != "" {
+		query = query.Where("date >= ?", from)
+	}
+	if to != "" {
+		query = query.Where("date <= ?", to)
+	}
+
+	result := query.Order("date asc, user_id asc, policy_id asc").Find(&traffics)
+	return traffics, result.Error
+}
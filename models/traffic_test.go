@@ -0,0 +1,83 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddTraffic(t *testing.T) {
+	a := assert.New(t)
+	trafficBuffer = make(map[trafficKey]*Traffic)
+
+	AddTraffic(1, 2, 10, 0)
+	AddTraffic(1, 2, 0, 20)
+	AddTraffic(1, 3, 5, 0)
+	AddTraffic(1, 3, 0, 0)
+
+	a.Len(trafficBuffer, 2)
+	today := time.Now().Format(TrafficDateFormat)
+	a.Equal(&Traffic{UserID: 1, PolicyID: 2, Date: today, Upload: 10, Download: 20}, trafficBuffer[trafficKey{1, 2, today}])
+	trafficBuffer = make(map[trafficKey]*Traffic)
+}
+
+func TestFlushTraffic(t *testing.T) {
+	a := assert.New(t)
+
+	// 已有记录时累加
+	{
+		trafficBuffer = make(map[trafficKey]*Traffic)
+		AddTraffic(1, 2, 10, 20)
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)traffics(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		a.NoError(FlushTraffic())
+		a.NoError(mock.ExpectationsWereMet())
+		a.Empty(trafficBuffer)
+	}
+
+	// 记录不存在时创建
+	{
+		AddTraffic(1, 2, 10, 20)
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)traffics(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)traffics(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		a.NoError(FlushTraffic())
+		a.NoError(mock.ExpectationsWereMet())
+		a.Empty(trafficBuffer)
+	}
+
+	// 写入失败时留待下次重试
+	{
+		AddTraffic(1, 2, 10, 20)
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)traffics(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(FlushTraffic())
+		a.NoError(mock.ExpectationsWereMet())
+		a.Len(trafficBuffer, 1)
+
+		AddTraffic(1, 2, 1, 0)
+		today := time.Now().Format(TrafficDateFormat)
+		a.EqualValues(11, trafficBuffer[trafficKey{1, 2, today}].Upload)
+		trafficBuffer = make(map[trafficKey]*Traffic)
+	}
+}
+
+func TestGetTraffics(t *testing.T) {
+	a := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)traffics(.+)user_id = (.+)policy_id = (.+)date >= (.+)date <= (.+)ORDER BY date asc").
+		WithArgs(1, 2, "2022-01-01", "2022-01-31").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "date", "upload"}).AddRow(1, "2022-01-01", 10))
+	res, err := GetTraffics(1, 2, "2022-01-01", "2022-01-31")
+	a.NoError(err)
+	a.NoError(mock.ExpectationsWereMet())
+	a.Len(res, 1)
+	a.EqualValues(10, res[0].Upload)
+}
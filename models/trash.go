@@ -0,0 +1,288 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+// 回收站中对象的类型
+const (
+	TrashObjectFile   = "file"
+	TrashObjectFolder = "folder"
+)
+
+// ErrTrashObjectNotExist 回收站对象对应的文件或目录记录不存在
+var ErrTrashObjectNotExist = errors.New("trashed object not exist")
+
+// Trash 回收站中的对象。对象及其包含的文件、目录记录均被软删除，删除时间与 CreatedAt 相同，
+// 以此区分同一次删除的子对象与之前单独删除的子对象
+type Trash struct {
+	gorm.Model
+	UserID     uint   `gorm:"index:trash_user_id"`
+	ObjectType string // file 或 folder
+	ObjectID   uint
+	Name       string // 删除前的名称
+	ParentID   uint   // 删除前所在目录的 ID
+	Size       uint64 // 包含的文件总大小
+}
+
+// trashedName 返回对象在回收站中的名称，避免占用原目录中的名称
+func trashedName() string {
+	return ".trashed_" + uuid.Must(uuid.NewV4()).String()
+}
+
+// trashTime 返回软删除时间。去除秒以下的部分，以免数据库精度不同导致无法按时间匹配
+func trashTime() time.Time {
+	return time.Now().Truncate(time.Second)
+}
+
+// TrashFile 将文件移入回收站
+func TrashFile(file *File) (*Trash, error) {
+	now := trashTime()
+	trash := &Trash{
+		UserID:     file.UserID,
+		ObjectType: TrashObjectFile,
+		ObjectID:   file.ID,
+		Name:       file.Name,
+		ParentID:   file.FolderID,
+		Size:       file.Size,
+	}
+	trash.CreatedAt = now
+
+	tx := DB.Begin()
+	// 文件脱离原目录，彻底删除时不再更新原目录的统计
+	if err := tx.Model(file).Set("gorm:association_autoupdate", false).UpdateColumns(map[string]interface{}{
+		"name":       trashedName(),
+		"folder_id":  0,
+		"deleted_at": now,
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	parent := &Folder{}
+	parent.ID = trash.ParentID
+	if err := parent.ChangeSize(tx, "-", file.Size, 1); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Create(trash).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return trash, tx.Commit().Error
+}
+
+// TrashFolder 将目录及其包含的所有文件、子目录移入回收站
+func TrashFolder(folder *Folder) (*Trash, error) {
+	folders, err := GetRecursiveChildFolder([]uint{folder.ID}, folder.OwnerID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	folderIDs := make([]uint, 0, len(folders))
+	for _, f := range folders {
+		folderIDs = append(folderIDs, f.ID)
+	}
+
+	var stats folderStats
+	if err := DB.Model(&File{}).Select("COALESCE(SUM(size), 0) as size").
+		Where("folder_id in (?)", folderIDs).Scan(&stats).Error; err != nil {
+		return nil, err
+	}
+
+	now := trashTime()
+	parentID := uint(0)
+	if folder.ParentID != nil {
+		parentID = *folder.ParentID
+	}
+	trash := &Trash{
+		UserID:     folder.OwnerID,
+		ObjectType: TrashObjectFolder,
+		ObjectID:   folder.ID,
+		Name:       folder.Name,
+		ParentID:   parentID,
+		Size:       stats.Size,
+	}
+	trash.CreatedAt = now
+
+	tx := DB.Begin()
+	if err := tx.Model(&File{}).Where("folder_id in (?)", folderIDs).
+		UpdateColumn("deleted_at", now).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Model(&Folder{}).Where("id in (?)", folderIDs).
+		UpdateColumn("deleted_at", now).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Unscoped().Model(&Folder{}).Where("id = ?", folder.ID).
+		UpdateColumn("name", trashedName()).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Create(trash).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return trash, tx.Commit().Error
+}
+
+// ListTrash 按删除时间倒序列出用户回收站中的对象
+func ListTrash(uid uint) ([]Trash, error) {
+	var trashes []Trash
+	result := DB.Where("user_id = ?", uid).Order("created_at desc").Find(&trashes)
+	return trashes, result.Error
+}
+
+// GetTrashByIDs 根据 ID 获取用户回收站中的对象
+func GetTrashByIDs(ids []uint, uid uint) ([]Trash, error) {
+	var trashes []Trash
+	result := DB.Where("id in (?) and user_id = ?", ids, uid).Find(&trashes)
+	return trashes, result.Error
+}
+
+// GetExpiredTrash 列出在 before 之前移入回收站的对象
+func GetExpiredTrash(before time.Time) ([]Trash, error) {
+	var trashes []Trash
+	result := DB.Where("created_at < ?", before).Order("user_id").Find(&trashes)
+	return trashes, result.Error
+}
+
+// Objects 列出同一次删除中被软删除的所有目录和文件记录，目录中首个为回收站对象本身
+func (trash *Trash) Objects() ([]Folder, []File, error) {
+	db := DB.Unscoped()
+	files := make([]File, 0)
+	if trash.ObjectType == TrashObjectFile {
+		err := db.Where("id = ? and user_id = ? and deleted_at = ?", trash.ObjectID, trash.UserID, trash.CreatedAt).
+			Find(&files).Error
+		return nil, files, err
+	}
+
+	folders := make([]Folder, 0)
+	if err := db.Where("id = ? and owner_id = ? and deleted_at = ?", trash.ObjectID, trash.UserID, trash.CreatedAt).
+		Find(&folders).Error; err != nil {
+		return nil, nil, err
+	}
+
+	// 逐层查询子目录，最大递归 65535 次
+	parentIDs := make([]uint, 0, len(folders))
+	for _, folder := range folders {
+		parentIDs = append(parentIDs, folder.ID)
+	}
+	for i := 0; i < 65535 && len(parentIDs) > 0; i++ {
+		var children []Folder
+		if err := db.Where("parent_id in (?) and owner_id = ? and deleted_at = ?", parentIDs, trash.UserID, trash.CreatedAt).
+			Find(&children).Error; err != nil {
+			return nil, nil, err
+		}
+
+		parentIDs = make([]uint, 0, len(children))
+		for _, folder := range children {
+			parentIDs = append(parentIDs, folder.ID)
+		}
+		folders = append(folders, children...)
+	}
+
+	if len(folders) == 0 {
+		return folders, files, nil
+	}
+
+	folderIDs := make([]uint, 0, len(folders))
+	for _, folder := range folders {
+		folderIDs = append(folderIDs, folder.ID)
+	}
+
+	err := db.Where("folder_id in (?) and user_id = ? and deleted_at = ?", folderIDs, trash.UserID, trash.CreatedAt).
+		Find(&files).Error
+	return folders, files, err
+}
+
+// Restore 将回收站中的对象以原名称恢复到目录 dst 中，并移出回收站
+func (trash *Trash) Restore(dst *Folder) error {
+	folders, files, err := trash.Objects()
+	if err != nil {
+		return err
+	}
+
+	tx := DB.Begin()
+	if trash.ObjectType == TrashObjectFile {
+		if len(files) == 0 {
+			tx.Rollback()
+			return ErrTrashObjectNotExist
+		}
+
+		if err := tx.Unscoped().Model(&File{}).Where("id = ?", trash.ObjectID).UpdateColumns(map[string]interface{}{
+			"name":       trash.Name,
+			"folder_id":  dst.ID,
+			"deleted_at": gorm.Expr("NULL"),
+		}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := dst.ChangeSize(tx, "+", files[0].Size, 1); err != nil {
+			tx.Rollback()
+			return err
+		}
+	} else {
+		if len(folders) == 0 {
+			tx.Rollback()
+			return ErrTrashObjectNotExist
+		}
+
+		folderIDs := make([]uint, 0, len(folders))
+		for _, folder := range folders {
+			folderIDs = append(folderIDs, folder.ID)
+		}
+
+		if err := tx.Unscoped().Model(&Folder{}).Where("id = ?", trash.ObjectID).UpdateColumns(map[string]interface{}{
+			"name":      trash.Name,
+			"parent_id": dst.ID,
+		}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Unscoped().Model(&Folder{}).Where("id in (?)", folderIDs).
+			UpdateColumn("deleted_at", gorm.Expr("NULL")).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if len(files) > 0 {
+			fileIDs := make([]uint, 0, len(files))
+			for _, file := range files {
+				fileIDs = append(fileIDs, file.ID)
+			}
+
+			if err := tx.Unscoped().Model(&File{}).Where("id in (?)", fileIDs).
+				UpdateColumn("deleted_at", gorm.Expr("NULL")).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+
+	if err := tx.Unscoped().Delete(trash).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// Delete 删除回收站对象的记录
+func (trash *Trash) Delete() error {
+	return DB.Unscoped().Delete(trash).Error
+}
@@ -0,0 +1,196 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrashFile(t *testing.T) {
+	a := assert.New(t)
+	file := &File{Name: "1.txt", UserID: 1, FolderID: 2, Size: 10}
+	file.ID = 3
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT(.+)trashes(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		trash, err := TrashFile(file)
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.Equal(TrashObjectFile, trash.ObjectType)
+		a.Equal("1.txt", trash.Name)
+		a.EqualValues(2, trash.ParentID)
+		a.EqualValues(10, trash.Size)
+	}
+
+	// 更新文件失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		_, err := TrashFile(file)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Error(err)
+	}
+}
+
+func TestTrashFolder(t *testing.T) {
+	a := assert.New(t)
+	parent := uint(1)
+	folder := &Folder{Name: "dir", OwnerID: 1, ParentID: &parent}
+	folder.ID = 2
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"size"}).AddRow(30))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 2))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(1, 2))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT(.+)trashes(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		trash, err := TrashFolder(folder)
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.Equal(TrashObjectFolder, trash.ObjectType)
+		a.Equal("dir", trash.Name)
+		a.EqualValues(1, trash.ParentID)
+		a.EqualValues(30, trash.Size)
+	}
+
+	// 创建回收站记录失败
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"size"}).AddRow(0))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 0))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT(.+)trashes(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		_, err := TrashFolder(folder)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Error(err)
+	}
+}
+
+func TestTrash_Objects(t *testing.T) {
+	a := assert.New(t)
+
+	// 文件
+	{
+		trash := &Trash{ObjectType: TrashObjectFile, ObjectID: 1, UserID: 1}
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		folders, files, err := trash.Objects()
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.Nil(folders)
+		a.Len(files, 1)
+	}
+
+	// 目录
+	{
+		trash := &Trash{ObjectType: TrashObjectFolder, ObjectID: 1, UserID: 1}
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2).AddRow(3))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+		folders, files, err := trash.Objects()
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.Len(folders, 3)
+		a.EqualValues(1, folders[0].ID)
+		a.Len(files, 1)
+	}
+
+	// 目录记录不存在
+	{
+		trash := &Trash{ObjectType: TrashObjectFolder, ObjectID: 1, UserID: 1}
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		folders, files, err := trash.Objects()
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.Empty(folders)
+		a.Empty(files)
+	}
+}
+
+func TestTrash_Restore(t *testing.T) {
+	a := assert.New(t)
+	dst := &Folder{}
+	dst.ID = 5
+
+	// 恢复文件
+	{
+		trash := &Trash{ObjectType: TrashObjectFile, ObjectID: 1, UserID: 1, Name: "1.txt"}
+		trash.ID = 1
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "size"}).AddRow(1, 10))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("DELETE(.+)trashes(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		a.NoError(trash.Restore(dst))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 文件记录不存在
+	{
+		trash := &Trash{ObjectType: TrashObjectFile, ObjectID: 1, UserID: 1, Name: "1.txt"}
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+		a.ErrorIs(trash.Restore(dst), ErrTrashObjectNotExist)
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 恢复目录
+	{
+		trash := &Trash{ObjectType: TrashObjectFolder, ObjectID: 1, UserID: 1, Name: "dir"}
+		trash.ID = 2
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("DELETE(.+)trashes(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		a.NoError(trash.Restore(dst))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 恢复目录失败
+	{
+		trash := &Trash{ObjectType: TrashObjectFolder, ObjectID: 1, UserID: 1, Name: "dir"}
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(trash.Restore(dst))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestGetExpiredTrash(t *testing.T) {
+	a := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)trashes(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(1, 1).AddRow(2, 2))
+	trashes, err := GetExpiredTrash(time.Now())
+	a.NoError(mock.ExpectationsWereMet())
+	a.NoError(err)
+	a.Len(trashes, 2)
+}
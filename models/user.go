@@ -55,6 +55,17 @@ func init() {
 type UserOption struct {
 	ProfileOff     bool   `json:"profile_off,omitempty"`
 	PreferredTheme string `json:"preferred_theme,omitempty"`
+	// 管理员为用户单独设置的下载限速，字节/秒，覆盖用户组设置，0 为不限制
+	DownloadSpeedLimit *int `json:"download_speed_limit,omitempty"`
+}
+
+// DownloadSpeedLimit 返回用户的下载限速，字节/秒，0 为不限制
+func (user *User) DownloadSpeedLimit() int {
+	if user.OptionsSerialized.DownloadSpeedLimit != nil {
+		return *user.OptionsSerialized.DownloadSpeedLimit
+	}
+
+	return user.Group.SpeedLimit
 }
 
 // Root 获取用户的根目录
@@ -99,6 +110,13 @@ func (user *User) ChangeStorage(tx *gorm.DB, operator string, size uint64) error
 	return tx.Model(user).Update("storage", gorm.Expr("storage "+operator+" ?", size)).Error
 }
 
+// GetStorage 从数据库读取用户最新的已用容量
+func (user *User) GetStorage() (uint64, error) {
+	var storage uint64
+	err := DB.Model(&User{}).Where("id = ?", user.ID).Select("storage").Row().Scan(&storage)
+	return storage, err
+}
+
 // IncreaseStorageWithoutCheck 忽略可用容量，增加用户已用容量
 func (user *User) IncreaseStorageWithoutCheck(size uint64) {
 	if size == 0 {
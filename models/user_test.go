@@ -221,6 +221,21 @@ func TestUser_GetPolicyID(t *testing.T) {
 	asserts.EqualValues(0, newUser.GetPolicyID(0))
 }
 
+func TestUser_DownloadSpeedLimit(t *testing.T) {
+	asserts := assert.New(t)
+
+	newUser := NewUser()
+	newUser.Group.SpeedLimit = 1024
+	asserts.Equal(1024, newUser.DownloadSpeedLimit())
+
+	limit := 0
+	newUser.OptionsSerialized.DownloadSpeedLimit = &limit
+	asserts.Equal(0, newUser.DownloadSpeedLimit())
+
+	limit = 2048
+	asserts.Equal(2048, newUser.DownloadSpeedLimit())
+}
+
 func TestUser_GetRemainingCapacity(t *testing.T) {
 	asserts := assert.New(t)
 	newUser := NewUser()
@@ -0,0 +1,202 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// FileVersion 文件被覆盖前的历史版本，物理文件以原存储路径保留在原存储策略中
+type FileVersion struct {
+	gorm.Model
+	FileID     uint `gorm:"index:version_file_id"`
+	UserID     uint `gorm:"index:version_user_id"`
+	PolicyID   uint
+	SourceName string `gorm:"type:text"`
+	Size       uint64
+	Hash       string `gorm:"size:64"`
+	MD5        string `gorm:"size:32"`
+	SHA1       string `gorm:"size:40"`
+}
+
+// NewFileVersion 以文件的当前内容创建历史版本
+func NewFileVersion(file *File) *FileVersion {
+	return &FileVersion{
+		FileID:     file.ID,
+		UserID:     file.UserID,
+		PolicyID:   file.PolicyID,
+		SourceName: file.SourceName,
+		Size:       file.Size,
+		Hash:       file.Hash,
+		MD5:        file.MD5,
+		SHA1:       file.SHA1,
+	}
+}
+
+// Create 创建历史版本记录，历史版本继续占用用户容量
+func (version *FileVersion) Create() error {
+	tx := DB.Begin()
+	if err := tx.Create(version).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	user := &User{}
+	user.ID = version.UserID
+	if err := user.ChangeStorage(tx, "+", version.Size); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// GetVersionsByFileID 按创建时间倒序列出用户文件的历史版本
+func GetVersionsByFileID(fileID, uid uint) ([]FileVersion, error) {
+	var versions []FileVersion
+	result := DB.Where("file_id = ? and user_id = ?", fileID, uid).Order("id desc").Find(&versions)
+	return versions, result.Error
+}
+
+// GetVersionsByFileIDs 列出多个文件的全部历史版本
+func GetVersionsByFileIDs(fileIDs []uint) ([]FileVersion, error) {
+	var versions []FileVersion
+	result := DB.Where("file_id in (?)", fileIDs).Find(&versions)
+	return versions, result.Error
+}
+
+// GetVersionByID 获取用户文件的指定历史版本
+func GetVersionByID(id, fileID, uid uint) (*FileVersion, error) {
+	var version FileVersion
+	result := DB.Where("id = ? and file_id = ? and user_id = ?", id, fileID, uid).First(&version)
+	return &version, result.Error
+}
+
+// DeleteVersions 删除历史版本记录，并归还其占用的用户容量
+func DeleteVersions(versions []FileVersion) error {
+	tx := DB.Begin()
+	sizes := make(map[uint]uint64)
+	for i := range versions {
+		if err := tx.Unscoped().Delete(&versions[i]).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		sizes[versions[i].UserID] += versions[i].Size
+	}
+
+	for uid, size := range sizes {
+		user := &User{}
+		user.ID = uid
+		if err := user.ChangeStorage(tx, "-", size); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// ReplaceContentWith 将文件内容替换为已上传完成的占位文件 placeholder 的内容，并删除占位文件记录。
+// 占位文件占用的容量转由文件占用，文件的原内容不再计入，需要保留时应先创建历史版本
+func (file *File) ReplaceContentWith(placeholder *File) error {
+	if err := file.resetThumb(); err != nil {
+		return err
+	}
+
+	folder := &Folder{}
+	folder.ID = file.FolderID
+
+	originSize := file.Size
+	tx := DB.Begin()
+	user := &User{}
+	user.ID = file.UserID
+	if err := user.ChangeStorage(tx, "-", originSize); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Model(file).Set("gorm:association_autoupdate", false).UpdateColumns(map[string]interface{}{
+		"source_name": placeholder.SourceName,
+		"policy_id":   placeholder.PolicyID,
+		"size":        placeholder.Size,
+		"hash":        placeholder.Hash,
+		"md5":         placeholder.MD5,
+		"sha1":        placeholder.SHA1,
+		"metadata":    file.Metadata,
+	}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Unscoped().Delete(placeholder).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// 占位文件的目录统计在删除记录时已扣除，此处按内容变化更新文件所在目录
+	if err := folder.ChangeSize(tx, "-", originSize, 0); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := folder.ChangeSize(tx, "+", placeholder.Size, 0); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// Restore 将文件内容恢复为此历史版本，并移除此版本。keepCurrent 为 true 时，
+// 文件的当前内容保存为新的历史版本，否则当前内容不再计入用户容量
+func (version *FileVersion) Restore(file *File, keepCurrent bool) error {
+	if err := file.resetThumb(); err != nil {
+		return err
+	}
+
+	folder := &Folder{}
+	folder.ID = file.FolderID
+	operator, delta := "+", version.Size-file.Size
+	if version.Size < file.Size {
+		operator, delta = "-", file.Size-version.Size
+	}
+
+	tx := DB.Begin()
+	if keepCurrent {
+		if err := tx.Create(NewFileVersion(file)).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	} else {
+		user := &User{}
+		user.ID = file.UserID
+		if err := user.ChangeStorage(tx, "-", file.Size); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Model(file).Set("gorm:association_autoupdate", false).UpdateColumns(map[string]interface{}{
+		"source_name": version.SourceName,
+		"policy_id":   version.PolicyID,
+		"size":        version.Size,
+		"hash":        version.Hash,
+		"md5":         version.MD5,
+		"sha1":        version.SHA1,
+		"metadata":    file.Metadata,
+	}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// 历史版本本身已占用容量，此处只需更新目录的统计
+	if err := folder.ChangeSize(tx, operator, delta, 0); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Unscoped().Delete(version).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
@@ -0,0 +1,178 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFileVersion(t *testing.T) {
+	a := assert.New(t)
+	file := &File{UserID: 1, PolicyID: 2, SourceName: "1.txt", Size: 10, Hash: "hash", MD5: "md5", SHA1: "sha1"}
+	file.ID = 3
+
+	version := NewFileVersion(file)
+	a.EqualValues(3, version.FileID)
+	a.EqualValues(1, version.UserID)
+	a.EqualValues(2, version.PolicyID)
+	a.Equal("1.txt", version.SourceName)
+	a.EqualValues(10, version.Size)
+	a.Equal("hash", version.Hash)
+}
+
+func TestFileVersion_Create(t *testing.T) {
+	a := assert.New(t)
+	version := &FileVersion{UserID: 1, Size: 10}
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)users(.+)storage(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		a.NoError(version.Create())
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 更新容量失败
+	{
+		version := &FileVersion{UserID: 1, Size: 10}
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)users(.+)storage(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(version.Create())
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestGetVersionsByFileID(t *testing.T) {
+	a := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)file_versions(.+)ORDER BY id desc").
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2).AddRow(1))
+	versions, err := GetVersionsByFileID(1, 2)
+	a.NoError(err)
+	a.NoError(mock.ExpectationsWereMet())
+	a.Len(versions, 2)
+}
+
+func TestDeleteVersions(t *testing.T) {
+	a := assert.New(t)
+	versions := []FileVersion{{UserID: 1, Size: 10}, {UserID: 1, Size: 20}}
+	versions[0].ID = 1
+	versions[1].ID = 2
+
+	// 成功，同一用户的容量合并归还
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("DELETE(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)users(.+)storage(.+)").WithArgs(30, sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		a.NoError(DeleteVersions(versions))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 删除失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)file_versions(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(DeleteVersions(versions))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestFileVersion_Restore(t *testing.T) {
+	a := assert.New(t)
+
+	// 保留当前内容
+	{
+		file := &File{UserID: 1, FolderID: 2, PolicyID: 1, SourceName: "new.txt", Size: 20}
+		file.ID = 3
+		version := &FileVersion{FileID: 3, UserID: 1, PolicyID: 1, SourceName: "old.txt", Size: 10}
+		version.ID = 4
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)CASE WHEN(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("DELETE(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		a.NoError(version.Restore(file, true))
+		a.NoError(mock.ExpectationsWereMet())
+		a.Equal("old.txt", file.SourceName)
+		a.EqualValues(10, file.Size)
+	}
+
+	// 不保留当前内容
+	{
+		file := &File{UserID: 1, PolicyID: 1, SourceName: "new.txt", Size: 20}
+		file.ID = 3
+		version := &FileVersion{FileID: 3, UserID: 1, PolicyID: 1, SourceName: "old.txt", Size: 30}
+		version.ID = 4
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)users(.+)storage(.+)").WithArgs(20, sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("DELETE(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		a.NoError(version.Restore(file, false))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 更新文件失败
+	{
+		file := &File{UserID: 1, PolicyID: 1, SourceName: "new.txt", Size: 20}
+		file.ID = 3
+		version := &FileVersion{FileID: 3, UserID: 1, PolicyID: 1, SourceName: "old.txt", Size: 10}
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(version.Restore(file, true))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestFile_ReplaceContentWith(t *testing.T) {
+	a := assert.New(t)
+
+	// 成功
+	{
+		file := &File{UserID: 1, FolderID: 2, PolicyID: 1, SourceName: "old.txt", Size: 10}
+		file.ID = 3
+		placeholder := &File{UserID: 1, FolderID: 2, PolicyID: 2, SourceName: "new.txt", Size: 20, MD5: "md5"}
+		placeholder.ID = 4
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)users(.+)storage(.+)").WithArgs(10, sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("DELETE(.+)files(.+)").WithArgs(4).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)CASE WHEN(.+)").WithArgs(1, 1, 20, 20, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)CASE WHEN(.+)").WithArgs(0, 0, 10, 10, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WithArgs(0, 20, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		a.NoError(file.ReplaceContentWith(placeholder))
+		a.NoError(mock.ExpectationsWereMet())
+		a.Equal("new.txt", file.SourceName)
+		a.EqualValues(2, file.PolicyID)
+		a.EqualValues(20, file.Size)
+		a.Equal("md5", file.MD5)
+	}
+
+	// 删除占位文件失败
+	{
+		file := &File{UserID: 1, FolderID: 2, PolicyID: 1, SourceName: "old.txt", Size: 10}
+		file.ID = 3
+		placeholder := &File{UserID: 1, FolderID: 2, PolicyID: 2, SourceName: "new.txt", Size: 20}
+		placeholder.ID = 4
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)users(.+)storage(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("DELETE(.+)files(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(file.ReplaceContentWith(placeholder))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
@@ -0,0 +1,107 @@
+package audiotag
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported audio format")
+	ErrInvalidFile       = errors.New("invalid or corrupted audio file")
+	ErrNoTags            = errors.New("no tags found")
+)
+
+// maxTagSize 读入内存解析的标签最大长度
+const maxTagSize = 16 << 20
+
+// Picture 内嵌的封面图片
+type Picture struct {
+	MimeType string
+	Data     []byte
+}
+
+// Tags 音频文件的标签信息
+type Tags struct {
+	Title   string
+	Artist  string
+	Album   string
+	Picture *Picture
+}
+
+// reader 从 src 中读取标签
+type reader func(src io.ReadSeeker) (*Tags, error)
+
+var readers = map[string]reader{
+	"mp3":  readID3,
+	"flac": readFLAC,
+	"m4a":  readMP4,
+	"m4b":  readMP4,
+	"mp4":  readMP4,
+	"aac":  readID3,
+}
+
+func ext(name string) string {
+	return strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+}
+
+// Supported 返回是否支持读取该文件名对应格式的标签
+func Supported(name string) bool {
+	_, ok := readers[ext(name)]
+	return ok
+}
+
+// Read 根据文件名判断音频格式，读取其中的标题、艺术家、专辑及封面
+func Read(name string, src io.ReadSeeker) (*Tags, error) {
+	r, ok := readers[ext(name)]
+	if !ok {
+		return nil, ErrUnsupportedFormat
+	}
+
+	return r(src)
+}
+
+// setPicture 设置封面，优先使用 front 为 true 的图片
+func (t *Tags) setPicture(p *Picture, front bool) {
+	if t.Picture == nil || front {
+		t.Picture = p
+	}
+}
+
+// empty 返回是否未读取到任何标签
+func (t *Tags) empty() bool {
+	return t.Title == "" && t.Artist == "" && t.Album == "" && t.Picture == nil
+}
+
+// readFull 读取 n 字节，超出 maxTagSize 时视为无效文件
+func readFull(r io.Reader, n uint64) ([]byte, error) {
+	if n > maxTagSize {
+		return nil, ErrInvalidFile
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, ErrInvalidFile
+	}
+
+	return b, nil
+}
+
+// imageMimeType 规范化图片 MIME 类型，缺失或无效时根据内容检测
+func imageMimeType(mimeType string, data []byte) string {
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	switch mimeType {
+	case "image/jpg":
+		return "image/jpeg"
+	case "", "image/", "-->":
+		return http.DetectContentType(data)
+	}
+
+	if !strings.HasPrefix(mimeType, "image/") {
+		return http.DetectContentType(data)
+	}
+
+	return mimeType
+}
@@ -0,0 +1,192 @@
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var jpegData = "\xFF\xD8\xFF\xE0jpeg"
+
+func appendBE32(b []byte, v uint32) []byte {
+	res := make([]byte, 4)
+	binary.BigEndian.PutUint32(res, v)
+	return append(b, res...)
+}
+
+func appendLE32(b []byte, v uint32) []byte {
+	res := make([]byte, 4)
+	binary.LittleEndian.PutUint32(res, v)
+	return append(b, res...)
+}
+
+func id3Frame(id string, content string) []byte {
+	res := []byte(id)
+	res = appendBE32(res, uint32(len(content)))
+	res = append(res, 0, 0)
+	return append(res, content...)
+}
+
+func id3Tag(version byte, frames ...[]byte) []byte {
+	content := bytes.Join(frames, nil)
+	size := len(content)
+	return append([]byte{'I', 'D', '3', version, 0, 0,
+		byte(size >> 21 & 0x7F), byte(size >> 14 & 0x7F), byte(size >> 7 & 0x7F), byte(size & 0x7F)}, content...)
+}
+
+func mp4Box(boxType string, payload ...[]byte) []byte {
+	content := bytes.Join(payload, nil)
+	res := appendBE32(nil, uint32(len(content)+8))
+	res = append(res, boxType...)
+	return append(res, content...)
+}
+
+func mp4DataBox(dataType uint32, value string) []byte {
+	return mp4Box("data", appendBE32(nil, dataType), []byte{0, 0, 0, 0}, []byte(value))
+}
+
+func TestSupported(t *testing.T) {
+	a := assert.New(t)
+	a.True(Supported("1.MP3"))
+	a.True(Supported("dir/1.flac"))
+	a.True(Supported("1.m4a"))
+	a.False(Supported("1.wav"))
+
+	_, err := Read("1.wav", strings.NewReader(""))
+	a.Equal(ErrUnsupportedFormat, err)
+}
+
+func TestReadID3v2(t *testing.T) {
+	a := assert.New(t)
+
+	// ID3v2.3，UTF-16 文本及封面
+	{
+		src := id3Tag(3,
+			id3Frame("TIT2", "\x00Song"),
+			id3Frame("TPE1", "\x01\xFF\xFEA\x00r\x00t\x00\x00\x00"),
+			id3Frame("TALB", "\x00Alb\xE9"),
+			id3Frame("APIC", "\x00image/jpg\x00\x00back\x00"+"back"),
+			id3Frame("APIC", "\x01\x00\x03\xFF\xFEd\x00\x00\x00"+jpegData),
+		)
+		src = append(src, "audio frames"...)
+
+		tags, err := Read("1.mp3", bytes.NewReader(src))
+		a.NoError(err)
+		a.Equal("Song", tags.Title)
+		a.Equal("Art", tags.Artist)
+		a.Equal("Albé", tags.Album)
+		a.Equal(jpegData, string(tags.Picture.Data))
+		a.Equal("image/jpeg", tags.Picture.MimeType)
+	}
+
+	// ID3v2.4，UTF-8 文本
+	{
+		src := id3Tag(4, id3Frame("TIT2", "\x03标题\x00"))
+		tags, err := Read("1.mp3", bytes.NewReader(src))
+		a.NoError(err)
+		a.Equal("标题", tags.Title)
+		a.Nil(tags.Picture)
+	}
+
+	// 无可用标签
+	{
+		_, err := Read("1.mp3", bytes.NewReader(id3Tag(3, id3Frame("TXXX", "\x00a\x00b"))))
+		a.Equal(ErrNoTags, err)
+	}
+}
+
+func TestReadID3v1(t *testing.T) {
+	a := assert.New(t)
+	tag := make([]byte, id3v1Size)
+	copy(tag, "TAG")
+	copy(tag[3:], "Title")
+	copy(tag[33:], "Artist")
+	copy(tag[63:], "Album")
+	src := append([]byte("audio frames"), tag...)
+
+	tags, err := Read("1.mp3", bytes.NewReader(src))
+	a.NoError(err)
+	a.Equal("Title", tags.Title)
+	a.Equal("Artist", tags.Artist)
+	a.Equal("Album", tags.Album)
+
+	_, err = Read("1.mp3", strings.NewReader("audio frames"))
+	a.Equal(ErrNoTags, err)
+}
+
+func TestReadFLAC(t *testing.T) {
+	a := assert.New(t)
+
+	comment := func(s string) []byte {
+		return append(appendLE32(nil, uint32(len(s))), s...)
+	}
+	vorbis := bytes.Join([][]byte{comment("vendor"), appendLE32(nil, 3),
+		comment("title=Song"), comment("ARTIST=Artist"), comment("invalid")}, nil)
+
+	picture := appendBE32(nil, flacFrontCover)
+	picture = append(appendBE32(picture, 10), "image/jpeg"...)
+	picture = appendBE32(picture, 0)
+	picture = append(picture, make([]byte, 16)...)
+	picture = append(appendBE32(picture, uint32(len(jpegData))), jpegData...)
+
+	block := func(blockType byte, last bool, content []byte) []byte {
+		if last {
+			blockType |= 0x80
+		}
+		return append([]byte{blockType, byte(len(content) >> 16), byte(len(content) >> 8), byte(len(content))}, content...)
+	}
+
+	var src []byte
+	src = append(src, "fLaC"...)
+	src = append(src, block(0, false, make([]byte, 34))...)
+	src = append(src, block(flacBlockVorbisComment, false, vorbis)...)
+	src = append(src, block(flacBlockPicture, true, picture)...)
+	src = append(src, "audio frames"...)
+
+	tags, err := Read("1.flac", bytes.NewReader(src))
+	a.NoError(err)
+	a.Equal("Song", tags.Title)
+	a.Equal("Artist", tags.Artist)
+	a.Empty(tags.Album)
+	a.Equal("image/jpeg", tags.Picture.MimeType)
+	a.Equal(jpegData, string(tags.Picture.Data))
+
+	_, err = Read("1.flac", strings.NewReader("not flac"))
+	a.Equal(ErrInvalidFile, err)
+}
+
+func TestReadMP4(t *testing.T) {
+	a := assert.New(t)
+	ilst := mp4Box("ilst",
+		mp4Box("\xA9nam", mp4DataBox(mp4DataTypeUTF8, "Song")),
+		mp4Box("aART", mp4DataBox(mp4DataTypeUTF8, "Album Artist")),
+		mp4Box("\xA9alb", mp4DataBox(mp4DataTypeUTF8, "Album")),
+		mp4Box("covr", mp4DataBox(mp4DataTypeJPEG, jpegData)),
+	)
+	moov := mp4Box("moov", mp4Box("mvhd", make([]byte, 8)), mp4Box("udta", mp4Box("meta", []byte{0, 0, 0, 0}, ilst)))
+
+	// moov 位于 mdat 之后
+	var src []byte
+	src = append(src, mp4Box("ftyp", []byte("M4A "))...)
+	src = append(src, mp4Box("mdat", []byte("audio frames"))...)
+	src = append(src, moov...)
+
+	tags, err := Read("1.m4a", bytes.NewReader(src))
+	a.NoError(err)
+	a.Equal("Song", tags.Title)
+	a.Equal("Album Artist", tags.Artist)
+	a.Equal("Album", tags.Album)
+	a.Equal("image/jpeg", tags.Picture.MimeType)
+	a.Equal(jpegData, string(tags.Picture.Data))
+
+	// 无标签
+	src = append(mp4Box("ftyp", []byte("M4A ")), mp4Box("moov", mp4Box("mvhd"))...)
+	_, err = Read("1.m4a", bytes.NewReader(src))
+	a.Equal(ErrNoTags, err)
+
+	_, err = Read("1.m4a", strings.NewReader("not mp4 file"))
+	a.Equal(ErrInvalidFile, err)
+}
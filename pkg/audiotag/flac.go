@@ -0,0 +1,151 @@
+package audiotag
+
+import (
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+const (
+	flacBlockVorbisComment = 4
+	flacBlockPicture       = 6
+	// flacFrontCover PICTURE 块中封面图片的类型
+	flacFrontCover = 3
+)
+
+// readFLAC 读取 FLAC 元数据块中的 Vorbis 注释及图片
+func readFLAC(src io.ReadSeeker) (*Tags, error) {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(src, magic); err != nil || string(magic) != "fLaC" {
+		return nil, ErrInvalidFile
+	}
+
+	tags := &Tags{}
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(src, header); err != nil {
+			return nil, ErrInvalidFile
+		}
+
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+
+		switch blockType {
+		case flacBlockVorbisComment, flacBlockPicture:
+			b, err := readFull(src, uint64(length))
+			if err != nil {
+				return nil, err
+			}
+
+			if blockType == flacBlockVorbisComment {
+				parseVorbisComment(b, tags)
+			} else if p, picType, ok := parseFLACPicture(b); ok {
+				tags.setPicture(p, picType == flacFrontCover)
+			}
+		default:
+			if _, err := src.Seek(length, io.SeekCurrent); err != nil {
+				return nil, ErrInvalidFile
+			}
+		}
+
+		if last {
+			break
+		}
+	}
+
+	if tags.empty() {
+		return nil, ErrNoTags
+	}
+
+	return tags, nil
+}
+
+// parseVorbisComment 解析 Vorbis 注释，长度字段为小端序
+func parseVorbisComment(b []byte, tags *Tags) {
+	if len(b) < 4 {
+		return
+	}
+
+	vendorLength := uint64(binary.LittleEndian.Uint32(b))
+	if 4+vendorLength+4 > uint64(len(b)) {
+		return
+	}
+	b = b[4+vendorLength:]
+
+	count := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+	for i := uint32(0); i < count && len(b) >= 4; i++ {
+		length := uint64(binary.LittleEndian.Uint32(b))
+		if 4+length > uint64(len(b)) {
+			return
+		}
+
+		comment := string(b[4 : 4+length])
+		b = b[4+length:]
+
+		key, value, ok := strings.Cut(comment, "=")
+		if !ok {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		switch strings.ToUpper(key) {
+		case "TITLE":
+			if tags.Title == "" {
+				tags.Title = value
+			}
+		case "ARTIST":
+			if tags.Artist == "" {
+				tags.Artist = value
+			}
+		case "ALBUM":
+			if tags.Album == "" {
+				tags.Album = value
+			}
+		}
+	}
+}
+
+// parseFLACPicture 解析 PICTURE 块，长度字段为大端序
+func parseFLACPicture(b []byte) (*Picture, uint32, bool) {
+	c := &cursor{b: b}
+	picType := c.u32()
+	mimeType := string(c.next(int(c.u32())))
+	c.next(int(c.u32()))
+	// 宽、高、色深、索引颜色数
+	c.next(16)
+	data := c.next(int(c.u32()))
+	if c.err || len(data) == 0 {
+		return nil, 0, false
+	}
+
+	return &Picture{MimeType: imageMimeType(mimeType, data), Data: data}, picType, true
+}
+
+// cursor 按大端序顺序读取字节
+type cursor struct {
+	b   []byte
+	pos int
+	err bool
+}
+
+func (c *cursor) next(n int) []byte {
+	if c.err || n < 0 || c.pos+n > len(c.b) {
+		c.err = true
+		return nil
+	}
+
+	res := c.b[c.pos : c.pos+n]
+	c.pos += n
+	return res
+}
+
+func (c *cursor) u32() uint32 {
+	b := c.next(4)
+	if b == nil {
+		return 0
+	}
+
+	return binary.BigEndian.Uint32(b)
+}
@@ -0,0 +1,272 @@
+package audiotag
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	id3v1Size = 128
+	// id3FrontCover APIC 中封面图片的类型
+	id3FrontCover = 3
+)
+
+// readID3 读取文件开头的 ID3v2 标签，不存在时尝试读取文件末尾的 ID3v1 标签
+func readID3(src io.ReadSeeker) (*Tags, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(src, header); err == nil && string(header[:3]) == "ID3" {
+		return parseID3v2(src, header)
+	}
+
+	return readID3v1(src)
+}
+
+// parseID3v2 解析 ID3v2.2/2.3/2.4 标签
+func parseID3v2(src io.Reader, header []byte) (*Tags, error) {
+	version, flags := header[3], header[5]
+	if version < 2 || version > 4 {
+		return nil, ErrInvalidFile
+	}
+
+	b, err := readFull(src, uint64(syncsafe(header[6:10])))
+	if err != nil {
+		return nil, err
+	}
+
+	// 整个标签的反同步，2.4 中改为每帧单独标记
+	if flags&0x80 != 0 && version < 4 {
+		b = bytes.ReplaceAll(b, []byte{0xFF, 0x00}, []byte{0xFF})
+	}
+
+	// 跳过扩展头
+	if flags&0x40 != 0 && version > 2 {
+		if len(b) < 4 {
+			return nil, ErrInvalidFile
+		}
+
+		size := int(syncsafe(b[:4]))
+		if version == 3 {
+			size = int(be32(b[:4])) + 4
+		}
+
+		if size > len(b) {
+			return nil, ErrInvalidFile
+		}
+		b = b[size:]
+	}
+
+	idSize, headerSize := 4, 10
+	if version == 2 {
+		idSize, headerSize = 3, 6
+	}
+
+	tags := &Tags{}
+	for len(b) >= headerSize && b[0] != 0 {
+		id := string(b[:idSize])
+		var size int
+		switch version {
+		case 2:
+			size = int(b[3])<<16 | int(b[4])<<8 | int(b[5])
+		case 3:
+			size = int(be32(b[4:8]))
+		case 4:
+			size = int(syncsafe(b[4:8]))
+		}
+
+		if size < 0 || headerSize+size > len(b) {
+			break
+		}
+
+		content := b[headerSize : headerSize+size]
+		if version == 4 && b[9]&0x02 != 0 {
+			content = bytes.ReplaceAll(content, []byte{0xFF, 0x00}, []byte{0xFF})
+		}
+		b = b[headerSize+size:]
+
+		switch id {
+		case "TIT2", "TT2":
+			tags.Title = id3Text(content)
+		case "TPE1", "TP1":
+			tags.Artist = id3Text(content)
+		case "TALB", "TAL":
+			tags.Album = id3Text(content)
+		case "APIC":
+			if p, picType, ok := parseAPIC(content); ok {
+				tags.setPicture(p, picType == id3FrontCover)
+			}
+		case "PIC":
+			if p, picType, ok := parsePIC(content); ok {
+				tags.setPicture(p, picType == id3FrontCover)
+			}
+		}
+	}
+
+	if tags.empty() {
+		return nil, ErrNoTags
+	}
+
+	return tags, nil
+}
+
+// readID3v1 读取文件末尾 128 字节的 ID3v1 标签
+func readID3v1(src io.ReadSeeker) (*Tags, error) {
+	if _, err := src.Seek(-id3v1Size, io.SeekEnd); err != nil {
+		return nil, ErrNoTags
+	}
+
+	b := make([]byte, id3v1Size)
+	if _, err := io.ReadFull(src, b); err != nil || string(b[:3]) != "TAG" {
+		return nil, ErrNoTags
+	}
+
+	field := func(b []byte) string {
+		return strings.TrimSpace(latin1(bytes.TrimRight(b, "\x00")))
+	}
+
+	tags := &Tags{
+		Title:  field(b[3:33]),
+		Artist: field(b[33:63]),
+		Album:  field(b[63:93]),
+	}
+	if tags.empty() {
+		return nil, ErrNoTags
+	}
+
+	return tags, nil
+}
+
+// id3Text 解码文本帧，多个值时取第一个
+func id3Text(b []byte) string {
+	if len(b) < 1 {
+		return ""
+	}
+
+	text, _ := id3String(b[0], b[1:], false)
+	return strings.TrimSpace(text)
+}
+
+// parseAPIC 解析 ID3v2.3/2.4 的图片帧
+func parseAPIC(b []byte) (*Picture, byte, bool) {
+	if len(b) < 4 {
+		return nil, 0, false
+	}
+
+	encoding := b[0]
+	mimeEnd := bytes.IndexByte(b[1:], 0)
+	if mimeEnd < 0 || 1+mimeEnd+2 > len(b) {
+		return nil, 0, false
+	}
+
+	mimeType := latin1(b[1 : 1+mimeEnd])
+	picType := b[1+mimeEnd+1]
+	_, data := id3String(encoding, b[1+mimeEnd+2:], true)
+	if len(data) == 0 {
+		return nil, 0, false
+	}
+
+	return &Picture{MimeType: imageMimeType(mimeType, data), Data: data}, picType, true
+}
+
+// parsePIC 解析 ID3v2.2 的图片帧
+func parsePIC(b []byte) (*Picture, byte, bool) {
+	if len(b) < 6 {
+		return nil, 0, false
+	}
+
+	mimeType := "image/" + strings.ToLower(string(b[1:4]))
+	picType := b[4]
+	_, data := id3String(b[0], b[5:], true)
+	if len(data) == 0 {
+		return nil, 0, false
+	}
+
+	return &Picture{MimeType: imageMimeType(mimeType, data), Data: data}, picType, true
+}
+
+// id3String 按编码读取以空字符结尾的字符串，terminated 为 false 时字符串可不以空字符结尾，
+// 返回字符串及其后剩余的内容
+func id3String(encoding byte, b []byte, terminated bool) (string, []byte) {
+	switch encoding {
+	case 1, 2:
+		// UTF-16 的结束符为对齐的两个零字节
+		end := -1
+		for i := 0; i+1 < len(b); i += 2 {
+			if b[i] == 0 && b[i+1] == 0 {
+				end = i
+				break
+			}
+		}
+
+		if end < 0 {
+			if terminated {
+				return "", nil
+			}
+			end = len(b) - len(b)%2
+			return decodeUTF16(b[:end], encoding == 2), nil
+		}
+
+		return decodeUTF16(b[:end], encoding == 2), b[end+2:]
+	default:
+		end := bytes.IndexByte(b, 0)
+		if end < 0 {
+			if terminated {
+				return "", nil
+			}
+			end = len(b)
+		}
+
+		s := b[:end]
+		rest := b[end:]
+		if len(rest) > 0 {
+			rest = rest[1:]
+		}
+
+		if encoding == 3 {
+			return string(s), rest
+		}
+		return latin1(s), rest
+	}
+}
+
+// decodeUTF16 解码 UTF-16 字符串，有 BOM 时以 BOM 为准
+func decodeUTF16(b []byte, bigEndian bool) string {
+	if len(b) >= 2 {
+		switch {
+		case b[0] == 0xFF && b[1] == 0xFE:
+			b, bigEndian = b[2:], false
+		case b[0] == 0xFE && b[1] == 0xFF:
+			b, bigEndian = b[2:], true
+		}
+	}
+
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+		} else {
+			units = append(units, uint16(b[i+1])<<8|uint16(b[i]))
+		}
+	}
+
+	return string(utf16.Decode(units))
+}
+
+// latin1 解码 ISO-8859-1 字符串
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+
+	return string(runes)
+}
+
+func syncsafe(b []byte) uint32 {
+	return uint32(b[0]&0x7F)<<21 | uint32(b[1]&0x7F)<<14 | uint32(b[2]&0x7F)<<7 | uint32(b[3]&0x7F)
+}
+
+func be32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
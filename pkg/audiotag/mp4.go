@@ -0,0 +1,181 @@
+package audiotag
+
+import (
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+const (
+	mp4DataTypeUTF8 = 1
+	mp4DataTypeJPEG = 13
+	mp4DataTypePNG  = 14
+)
+
+// readMP4 在顶层盒中找到 moov，读取 moov/udta/meta/ilst 中的 iTunes 标签
+func readMP4(src io.ReadSeeker) (*Tags, error) {
+	var offset int64
+	first := true
+	for {
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(src, header); err != nil {
+			if first {
+				return nil, ErrInvalidFile
+			}
+			return nil, ErrNoTags
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		boxType := string(header[4:])
+		headerSize := int64(8)
+		if size == 1 {
+			if _, err := io.ReadFull(src, header); err != nil {
+				return nil, ErrInvalidFile
+			}
+			size = int64(binary.BigEndian.Uint64(header))
+			headerSize = 16
+		}
+
+		if first && boxType != "ftyp" {
+			return nil, ErrInvalidFile
+		}
+		first = false
+
+		// 长度为 0 表示延伸至文件末尾
+		if size == 0 && boxType != "moov" {
+			return nil, ErrNoTags
+		}
+
+		if size != 0 && size < headerSize {
+			return nil, ErrInvalidFile
+		}
+
+		if boxType == "moov" {
+			var (
+				moov []byte
+				err  error
+			)
+			if size == 0 {
+				moov, err = io.ReadAll(io.LimitReader(src, maxTagSize))
+			} else {
+				moov, err = readFull(src, uint64(size-headerSize))
+			}
+			if err != nil {
+				return nil, ErrInvalidFile
+			}
+
+			return parseMoov(moov)
+		}
+
+		offset += size
+		if _, err := src.Seek(offset, io.SeekStart); err != nil {
+			return nil, ErrInvalidFile
+		}
+	}
+}
+
+// parseMoov 解析 moov 盒内容
+func parseMoov(moov []byte) (*Tags, error) {
+	udta := findBox(moov, "udta")
+	meta := findBox(udta, "meta")
+	// meta 为 full box，跳过版本及标志
+	if len(meta) < 4 {
+		return nil, ErrNoTags
+	}
+	ilst := findBox(meta[4:], "ilst")
+
+	tags := &Tags{}
+	walkMP4Boxes(ilst, func(boxType string, content []byte) {
+		dataType, value, ok := mp4Data(content)
+		if !ok {
+			return
+		}
+
+		switch boxType {
+		case "\xA9nam":
+			tags.Title = mp4Text(dataType, value)
+		case "\xA9ART":
+			tags.Artist = mp4Text(dataType, value)
+		case "aART":
+			if tags.Artist == "" {
+				tags.Artist = mp4Text(dataType, value)
+			}
+		case "\xA9alb":
+			tags.Album = mp4Text(dataType, value)
+		case "covr":
+			mimeType := ""
+			switch dataType {
+			case mp4DataTypeJPEG:
+				mimeType = "image/jpeg"
+			case mp4DataTypePNG:
+				mimeType = "image/png"
+			}
+			if len(value) > 0 {
+				tags.setPicture(&Picture{MimeType: imageMimeType(mimeType, value), Data: value}, false)
+			}
+		}
+	})
+
+	if tags.empty() {
+		return nil, ErrNoTags
+	}
+
+	return tags, nil
+}
+
+// mp4Data 读取标签项中第一个 data 盒的类型及内容
+func mp4Data(item []byte) (uint32, []byte, bool) {
+	data := findBox(item, "data")
+	if len(data) < 8 {
+		return 0, nil, false
+	}
+
+	// 类型指示及区域设置各 4 字节
+	return binary.BigEndian.Uint32(data[:4]) & 0xFFFFFF, data[8:], true
+}
+
+func mp4Text(dataType uint32, value []byte) string {
+	if dataType != mp4DataTypeUTF8 {
+		return ""
+	}
+
+	return strings.TrimSpace(string(value))
+}
+
+// findBox 返回 b 中第一个类型为 boxType 的子盒内容
+func findBox(b []byte, boxType string) []byte {
+	var res []byte
+	walkMP4Boxes(b, func(t string, content []byte) {
+		if res == nil && t == boxType {
+			res = content
+		}
+	})
+
+	return res
+}
+
+// walkMP4Boxes 遍历 b 中的子盒
+func walkMP4Boxes(b []byte, fn func(boxType string, content []byte)) {
+	for len(b) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(b[:4]))
+		boxType := string(b[4:8])
+		headerSize := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(b))
+		case 1:
+			if len(b) < 16 {
+				return
+			}
+			size = binary.BigEndian.Uint64(b[8:16])
+			headerSize = 16
+		}
+
+		if size < headerSize || size > uint64(len(b)) {
+			return
+		}
+
+		fn(boxType, b[headerSize:size])
+		b = b[size:]
+	}
+}
@@ -59,6 +59,10 @@ type Driver interface {
 	// 删除值
 	Delete(keys []string, prefix string) error
 
+	// 将整数计数器原子地增加 delta 并返回增加后的值，计数器不存在时以 0 创建，
+	// ttl 仅在创建时生效。计数器只能通过 IncrBy 读取
+	IncrBy(key string, delta int64, ttl int) (int64, error)
+
 	// Save in-memory cache to disk
 	Persist(path string) error
 
@@ -76,6 +80,11 @@ func Get(key string) (interface{}, bool) {
 	return Store.Get(key)
 }
 
+// IncrBy 原子地增加计数器
+func IncrBy(key string, delta int64, ttl int) (int64, error) {
+	return Store.IncrBy(key, delta, ttl)
+}
+
 // Deletes 删除值
 func Deletes(keys []string, prefix string) error {
 	return Store.Delete(keys, prefix)
@@ -15,6 +15,9 @@ type MemoStore struct {
 	Store *sync.Map
 }
 
+// memoIncrLock 保证计数器读取与写回之间不被其他 IncrBy 打断
+var memoIncrLock sync.Mutex
+
 // item 存储的对象
 type itemWithTTL struct {
 	Expires int64
@@ -116,6 +119,30 @@ func (store *MemoStore) Delete(keys []string, prefix string) error {
 	return nil
 }
 
+// IncrBy 原子地增加计数器
+func (store *MemoStore) IncrBy(key string, delta int64, ttl int) (int64, error) {
+	memoIncrLock.Lock()
+	defer memoIncrLock.Unlock()
+
+	item := newItem(int64(0), ttl)
+	if value, ok := store.Store.Load(key); ok {
+		if _, ok := getValue(value, ok); ok {
+			if existed, ok := value.(itemWithTTL); ok {
+				item = existed
+			}
+		}
+	}
+
+	current, ok := item.Value.(int64)
+	if !ok {
+		return 0, fmt.Errorf("cache %q is not a counter", key)
+	}
+
+	item.Value = current + delta
+	store.Store.Store(key, item)
+	return item.Value.(int64), nil
+}
+
 // Persist write memory store into cache
 func (store *MemoStore) Persist(path string) error {
 	persisted := make(map[string]itemWithTTL)
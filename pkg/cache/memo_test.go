@@ -137,6 +137,36 @@ func TestMemoStore_Delete(t *testing.T) {
 	asserts.Equal(map[string]interface{}{"3": "3.val", "4": "4.val"}, values)
 }
 
+func TestMemoStore_IncrBy(t *testing.T) {
+	asserts := assert.New(t)
+	store := NewMemoStore()
+
+	// 新建计数器
+	{
+		val, err := store.IncrBy("counter", 1, 10)
+		asserts.NoError(err)
+		asserts.EqualValues(1, val)
+		val, err = store.IncrBy("counter", 2, 0)
+		asserts.NoError(err)
+		asserts.EqualValues(3, val)
+	}
+
+	// 已过期的计数器重新计数
+	{
+		store.Store.Store("expired", itemWithTTL{Value: int64(5), Expires: 1})
+		val, err := store.IncrBy("expired", 1, 10)
+		asserts.NoError(err)
+		asserts.EqualValues(1, val)
+	}
+
+	// 非计数器
+	{
+		_ = store.Set("string", "string_val", -1)
+		_, err := store.IncrBy("string", 1, 0)
+		asserts.Error(err)
+	}
+}
+
 func TestMemoStore_GarbageCollect(t *testing.T) {
 	asserts := assert.New(t)
 	store := NewMemoStore()
@@ -203,6 +203,24 @@ func (store *RedisStore) Delete(keys []string, prefix string) error {
 	return nil
 }
 
+// incrScript 增加计数器，并在计数器新建时设置过期时间
+var incrScript = redis.NewScript(1, `local v = redis.call("INCRBY", KEYS[1], ARGV[1])
+if v == tonumber(ARGV[1]) and tonumber(ARGV[2]) > 0 and redis.call("TTL", KEYS[1]) == -1 then
+	redis.call("EXPIRE", KEYS[1], ARGV[2])
+end
+return v`)
+
+// IncrBy 原子地增加计数器
+func (store *RedisStore) IncrBy(key string, delta int64, ttl int) (int64, error) {
+	rc := store.pool.Get()
+	defer rc.Close()
+	if rc.Err() != nil {
+		return 0, rc.Err()
+	}
+
+	return redis.Int64(incrScript.Do(rc, key, delta, ttl))
+}
+
 // DeleteAll 批量所有键
 func (store *RedisStore) DeleteAll() error {
 	rc := store.pool.Get()
@@ -0,0 +1,128 @@
+package clamav
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// chunkSize INSTREAM 每次发送的数据块大小
+	chunkSize = 64 << 10
+
+	resultOK    = "OK"
+	resultFound = " FOUND"
+)
+
+var (
+	ErrInvalidAddress = errors.New("invalid clamd address, should be tcp://host:port or unix:///path/to/clamd.sock")
+	ErrSizeLimit      = errors.New("stream exceeds clamd StreamMaxLength")
+)
+
+// Result 扫描结果
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// Client clamd 客户端
+type Client struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClient 根据 clamd 地址创建客户端，地址格式为 tcp://host:port 或 unix:///path/to/clamd.sock
+func NewClient(address string, timeout time.Duration) (*Client, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, ErrInvalidAddress
+	}
+
+	client := &Client{network: u.Scheme, timeout: timeout}
+	switch u.Scheme {
+	case "tcp":
+		client.address = u.Host
+	case "unix":
+		client.address = u.Path
+	default:
+		return nil, ErrInvalidAddress
+	}
+
+	if client.address == "" {
+		return nil, ErrInvalidAddress
+	}
+
+	return client, nil
+}
+
+// Scan 使用 INSTREAM 命令将数据流发送至 clamd 扫描
+func (c *Client) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	dialer := net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	if c.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, err
+	}
+
+	// 数据块格式为 4 字节大端序长度 + 数据，以长度为 0 的块结束
+	buf := make([]byte, chunkSize+4)
+	for {
+		n, err := r.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:n+4]); err != nil {
+				return nil, err
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return parseReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseReply 解析形如 "stream: OK" 或 "stream: Eicar-Signature FOUND" 的回复
+func parseReply(reply string) (*Result, error) {
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == resultOK:
+		return &Result{}, nil
+	case strings.HasSuffix(reply, resultFound):
+		return &Result{Infected: true, Signature: strings.TrimSuffix(reply, resultFound)}, nil
+	case strings.HasPrefix(reply, "INSTREAM size limit exceeded"):
+		return nil, ErrSizeLimit
+	default:
+		return nil, fmt.Errorf("unexpected clamd reply: %q", reply)
+	}
+}
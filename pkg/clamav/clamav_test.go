@@ -0,0 +1,120 @@
+package clamav
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockClamd 启动一个模拟的 clamd，收到的数据包含 virus 时报告感染
+func mockClamd(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+				cmd := make([]byte, len("zINSTREAM\x00"))
+				if _, err := io.ReadFull(conn, cmd); err != nil {
+					return
+				}
+
+				var received bytes.Buffer
+				for {
+					var size uint32
+					if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					if _, err := io.CopyN(&received, conn, int64(size)); err != nil {
+						return
+					}
+				}
+
+				if strings.Contains(received.String(), "virus") {
+					conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+				} else {
+					conn.Write([]byte("stream: OK\x00"))
+				}
+			}(conn)
+		}
+	}()
+
+	return "tcp://" + l.Addr().String()
+}
+
+func TestNewClient(t *testing.T) {
+	a := assert.New(t)
+
+	client, err := NewClient("tcp://127.0.0.1:3310", time.Second)
+	a.NoError(err)
+	a.Equal("tcp", client.network)
+	a.Equal("127.0.0.1:3310", client.address)
+
+	client, err = NewClient("unix:///var/run/clamd.sock", time.Second)
+	a.NoError(err)
+	a.Equal("unix", client.network)
+	a.Equal("/var/run/clamd.sock", client.address)
+
+	_, err = NewClient("http://127.0.0.1:3310", time.Second)
+	a.Equal(ErrInvalidAddress, err)
+
+	_, err = NewClient("tcp://", time.Second)
+	a.Equal(ErrInvalidAddress, err)
+}
+
+func TestClient_Scan(t *testing.T) {
+	a := assert.New(t)
+	client, err := NewClient(mockClamd(t), time.Second)
+	a.NoError(err)
+
+	// 正常文件
+	{
+		res, err := client.Scan(context.Background(), strings.NewReader(strings.Repeat("clean", chunkSize)))
+		a.NoError(err)
+		a.False(res.Infected)
+	}
+
+	// 感染文件
+	{
+		res, err := client.Scan(context.Background(), strings.NewReader("this is a virus"))
+		a.NoError(err)
+		a.True(res.Infected)
+		a.Equal("Eicar-Test-Signature", res.Signature)
+	}
+
+	// 无法连接
+	{
+		client, _ := NewClient("tcp://127.0.0.1:1", time.Second)
+		_, err := client.Scan(context.Background(), strings.NewReader(""))
+		a.Error(err)
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	a := assert.New(t)
+
+	_, err := parseReply("INSTREAM size limit exceeded. ERROR")
+	a.Equal(ErrSizeLimit, err)
+
+	_, err = parseReply("UNKNOWN COMMAND")
+	a.Error(err)
+}
@@ -29,6 +29,8 @@ type system struct {
 	HashIDSalt    string
 	GracePeriod   int    `validate:"gte=0"`
 	ProxyHeader   string `validate:"required_with=Listen"`
+	// MasterKey 用于加密存储策略密钥的主密钥，base64 编码的 32 字节
+	MasterKey string
 }
 
 type ssl struct {
@@ -0,0 +1,38 @@
+package crontab
+
+import (
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+func purgeActivity() {
+	days := model.GetIntSetting("activity_retention_days", 90)
+	if err := model.DeleteActivitiesBefore(time.Now().AddDate(0, 0, -days)); err != nil {
+		util.Log().Warning("Failed to purge expired activities: %s", err)
+		return
+	}
+
+	util.Log().Info("Crontab job \"cron_purge_activity\" complete.")
+}
+
+func purgeChangeLog() {
+	days := model.GetIntSetting("change_log_retention_days", 30)
+	if err := model.DeleteChangesBefore(time.Now().AddDate(0, 0, -days)); err != nil {
+		util.Log().Warning("Failed to purge expired change logs: %s", err)
+		return
+	}
+
+	util.Log().Info("Crontab job \"cron_purge_change_log\" complete.")
+}
+
+func purgeShareAccess() {
+	days := model.GetIntSetting("share_access_retention_days", 90)
+	if err := model.DeleteShareAccessesBefore(time.Now().AddDate(0, 0, -days)); err != nil {
+		util.Log().Warning("Failed to purge expired share access records: %s", err)
+		return
+	}
+
+	util.Log().Info("Crontab job \"cron_purge_share_access\" complete.")
+}
@@ -88,6 +88,7 @@ func uploadSessionCollect() {
 			continue
 		}
 
+		util.Log().Debug("Delete %d expired upload sessions of user %d.", len(filesIDs), uid)
 		if err = fs.Delete(context.Background(), []uint{}, filesIDs, false, false); err != nil {
 			util.Log().Warning("Failed to delete upload session: %s", err)
 		}
@@ -0,0 +1,47 @@
+package crontab
+
+import (
+	"context"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+func expireObjects() {
+	expirations, err := model.GetDueExpirations(time.Now())
+	if err != nil {
+		util.Log().Warning("Failed to list due expirations: %s", err)
+		return
+	}
+
+	// 将到期规则按照用户分组
+	userToExpirations := make(map[uint][]model.Expiration)
+	for _, expiration := range expirations {
+		userToExpirations[expiration.UserID] = append(userToExpirations[expiration.UserID], expiration)
+	}
+
+	for uid, items := range userToExpirations {
+		user, err := model.GetUserByID(uid)
+		if err != nil {
+			util.Log().Warning("Owner of the expiration cannot be found: %s", err)
+			continue
+		}
+
+		fs, err := filesystem.NewFileSystem(&user)
+		if err != nil {
+			util.Log().Warning("Failed to initialize filesystem: %s", err)
+			continue
+		}
+
+		util.Log().Debug("Apply %d due expirations of user %d.", len(items), uid)
+		if err = fs.ApplyExpirations(context.Background(), items); err != nil {
+			util.Log().Warning("Failed to apply expirations: %s", err)
+		}
+
+		fs.Recycle()
+	}
+
+	util.Log().Info("Crontab job \"cron_expire_objects\" complete.")
+}
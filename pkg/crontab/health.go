@@ -0,0 +1,24 @@
+package crontab
+
+import (
+	"context"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/remote"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+func slaveHealthCheck() {
+	policies, err := model.GetPoliciesByType("remote")
+	if err != nil {
+		util.Log().Warning("Failed to list storage policies: %s", err)
+		return
+	}
+
+	for i := range policies {
+		health := remote.Probe(context.Background(), &policies[i])
+		util.Log().Debug("Slave node of policy %q: online=%t, latency=%dms.", policies[i].Name, health.Online, health.Latency)
+	}
+
+	util.Log().Debug("Crontab job \"cron_slave_health\" complete.")
+}
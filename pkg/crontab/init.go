@@ -24,6 +24,16 @@ func Init() {
 	options := model.GetSettingByNames(
 		"cron_garbage_collect",
 		"cron_recycle_upload_session",
+		"cron_storage_tiering",
+		"cron_mirror_repair",
+		"cron_slave_health",
+		"cron_flush_traffic",
+		"cron_purge_trash",
+		"cron_purge_activity",
+		"cron_expire_objects",
+		"cron_purge_change_log",
+		"cron_purge_share_access",
+		"cron_notify_share_access",
 	)
 	Cron := cron.New()
 	for k, v := range options {
@@ -33,6 +43,26 @@ func Init() {
 			handler = garbageCollect
 		case "cron_recycle_upload_session":
 			handler = uploadSessionCollect
+		case "cron_storage_tiering":
+			handler = storageTiering
+		case "cron_mirror_repair":
+			handler = mirrorRepair
+		case "cron_slave_health":
+			handler = slaveHealthCheck
+		case "cron_flush_traffic":
+			handler = flushTraffic
+		case "cron_purge_trash":
+			handler = purgeTrash
+		case "cron_purge_activity":
+			handler = purgeActivity
+		case "cron_expire_objects":
+			handler = expireObjects
+		case "cron_purge_change_log":
+			handler = purgeChangeLog
+		case "cron_purge_share_access":
+			handler = purgeShareAccess
+		case "cron_notify_share_access":
+			handler = notifyShareAccess
 		default:
 			util.Log().Warning("Unknown crontab job type %q, skipping...", k)
 			continue
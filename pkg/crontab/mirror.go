@@ -0,0 +1,46 @@
+package crontab
+
+import (
+	"context"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/mirror"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+func mirrorRepair() {
+	policies, err := model.GetPoliciesByType("mirror")
+	if err != nil {
+		util.Log().Warning("Failed to list storage policies: %s", err)
+		return
+	}
+
+	for i := range policies {
+		fs := &filesystem.FileSystem{User: &model.User{}, Policy: &policies[i]}
+		if err := fs.DispatchHandler(); err != nil {
+			util.Log().Warning("Failed to dispatch mirror policy %q: %s", policies[i].Name, err)
+			continue
+		}
+
+		handler, ok := fs.Handler.(*mirror.Driver)
+		if !ok {
+			continue
+		}
+
+		repaired, err := handler.Repair(context.Background(), func(source string) bool {
+			// 查询失败时保留待修复记录
+			referenced, err := model.IsSourceReferenced(policies[i].ID, source)
+			return referenced || err != nil
+		})
+		if err != nil {
+			util.Log().Warning("Failed to repair replicas of policy %q: %s", policies[i].Name, err)
+		}
+
+		if repaired > 0 {
+			util.Log().Info("Repaired %d file(s) in replicas of policy %q.", repaired, policies[i].Name)
+		}
+	}
+
+	util.Log().Info("Crontab job \"cron_mirror_repair\" complete.")
+}
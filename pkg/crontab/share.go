@@ -0,0 +1,111 @@
+package crontab
+
+import (
+	"encoding/json"
+	"net/url"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/email"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// shareAccessStats 分享在一个汇总周期内的访问统计
+type shareAccessStats struct {
+	Views     int `json:"views"`
+	Downloads int `json:"downloads"`
+	Visitors  int `json:"visitors"`
+}
+
+// notifyShareAccess 汇总开启了访问通知的分享自上次通知以来的访问，每个分享生成一条站内通知，
+// 每位用户至多收到一封汇总邮件，避免热门分享频繁打扰创建者
+func notifyShareAccess() {
+	shares, err := model.ListSharesWithAccessNotify()
+	if err != nil {
+		util.Log().Warning("Failed to list shares with access notification: %s", err)
+		return
+	}
+
+	notifications := make([]model.Notification, 0)
+	digests := make(map[uint][]email.ShareAccessDigest)
+	for i := range shares {
+		share := &shares[i]
+		accesses, err := model.ListShareAccessesAfterID(share.ID, share.AccessNotified)
+		if err != nil {
+			util.Log().Warning("Failed to list access records of share %d: %s", share.ID, err)
+			continue
+		}
+
+		if len(accesses) == 0 {
+			continue
+		}
+
+		stats := summarizeShareAccesses(accesses)
+		if share.AccessNotify&model.ShareNotifyInApp != 0 {
+			content, _ := json.Marshal(stats)
+			notification := model.Notification{
+				UserID:   share.UserID,
+				Type:     model.NotificationShareAccess,
+				FileName: share.SourceName,
+				ShareID:  share.ID,
+				Content:  string(content),
+			}
+			if !share.IsDir {
+				notification.FileID = share.SourceID
+			}
+			notifications = append(notifications, notification)
+		}
+
+		if share.AccessNotify&model.ShareNotifyEmail != 0 {
+			shareURL, _ := url.Parse("/s/" + share.Key())
+			digests[share.UserID] = append(digests[share.UserID], email.ShareAccessDigest{
+				Name:      share.SourceName,
+				URL:       model.GetSiteURL().ResolveReference(shareURL).String(),
+				Views:     stats.Views,
+				Downloads: stats.Downloads,
+				Visitors:  stats.Visitors,
+			})
+		}
+
+		if err := share.Update(map[string]interface{}{"access_notified": accesses[len(accesses)-1].ID}); err != nil {
+			util.Log().Warning("Failed to update notified access of share %d: %s", share.ID, err)
+		}
+	}
+
+	if len(notifications) > 0 {
+		if err := model.CreateNotifications(notifications); err != nil {
+			util.Log().Warning("Failed to create share access notifications: %s", err)
+		}
+	}
+
+	for uid, items := range digests {
+		user, err := model.GetUserByID(uid)
+		if err != nil || user.Status != model.Active {
+			continue
+		}
+
+		title, body := email.NewShareAccessEmail(user.Nick, items)
+		if err := email.Send(user.Email, title, body); err != nil {
+			util.Log().Warning("Failed to send share access email to %q: %s", user.Email, err)
+		}
+	}
+
+	util.Log().Info("Crontab job \"cron_notify_share_access\" complete.")
+}
+
+// summarizeShareAccesses 统计浏览、下载次数及以 IP 摘要区分的访客数
+func summarizeShareAccesses(accesses []model.ShareAccess) shareAccessStats {
+	var stats shareAccessStats
+	visitors := make(map[string]bool)
+	for _, access := range accesses {
+		switch access.Action {
+		case model.ShareAccessView:
+			stats.Views++
+		case model.ShareAccessDownload:
+			stats.Downloads++
+		}
+		visitors[access.IPHash] = true
+	}
+
+	stats.Visitors = len(visitors)
+	return stats
+}
@@ -0,0 +1,72 @@
+package crontab
+
+import (
+	"encoding/json"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/task"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// tieringBatchSize 每次执行时每个存储策略最多迁移的文件数
+const tieringBatchSize = 1000
+
+func storageTiering() {
+	policies, err := model.GetTieringPolicies()
+	if err != nil {
+		util.Log().Warning("Failed to list storage policies: %s", err)
+		return
+	}
+
+	// 上次的迁移任务未完成时，跳过对应的存储策略
+	running := make(map[uint]bool)
+	for _, t := range model.GetTasksByStatus(task.Queued, task.Processing) {
+		if t.Type != task.TieringTaskType {
+			continue
+		}
+
+		var props task.TieringProps
+		if err := json.Unmarshal([]byte(t.Props), &props); err == nil {
+			running[props.SrcPolicyID] = true
+		}
+	}
+
+	for _, policy := range policies {
+		if running[policy.ID] {
+			util.Log().Debug("Tiering tasks of policy %q are still running, skipping...", policy.Name)
+			continue
+		}
+
+		rule := policy.OptionsSerialized.Tiering
+		if _, err := model.GetPolicyByID(rule.TargetPolicyID); err != nil {
+			util.Log().Warning("Target policy of tiering rule for %q cannot be found: %s", policy.Name, err)
+			continue
+		}
+
+		files, err := model.GetTieringCandidates(policy.ID, rule, tieringBatchSize)
+		if err != nil {
+			util.Log().Warning("Failed to list tiering candidates of policy %q: %s", policy.Name, err)
+			continue
+		}
+
+		// 将待迁移的文件按照用户分组
+		userToFiles := make(map[uint][]uint)
+		for _, file := range files {
+			userToFiles[file.UserID] = append(userToFiles[file.UserID], file.ID)
+		}
+
+		for uid, fileIDs := range userToFiles {
+			job, err := task.NewTieringTask(uid, policy.ID, rule.TargetPolicyID, fileIDs)
+			if err != nil {
+				util.Log().Warning("Failed to create tiering task for user %d: %s", uid, err)
+				continue
+			}
+
+			task.TaskPoll.Submit(job)
+		}
+
+		util.Log().Debug("Submit %d file(s) of policy %q for tiering.", len(files), policy.Name)
+	}
+
+	util.Log().Info("Crontab job \"cron_storage_tiering\" complete.")
+}
@@ -0,0 +1,14 @@
+package crontab
+
+import (
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+func flushTraffic() {
+	if err := model.FlushTraffic(); err != nil {
+		util.Log().Warning("Failed to flush traffic statistics: %s", err)
+	}
+
+	util.Log().Debug("Crontab job \"cron_flush_traffic\" complete.")
+}
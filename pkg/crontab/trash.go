@@ -0,0 +1,48 @@
+package crontab
+
+import (
+	"context"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+func purgeTrash() {
+	days := model.GetIntSetting("trash_retention_days", 30)
+	trashes, err := model.GetExpiredTrash(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		util.Log().Warning("Failed to list expired trash: %s", err)
+		return
+	}
+
+	// 将过期的回收站对象按照用户分组
+	userToTrashes := make(map[uint][]model.Trash)
+	for _, trash := range trashes {
+		userToTrashes[trash.UserID] = append(userToTrashes[trash.UserID], trash)
+	}
+
+	for uid, items := range userToTrashes {
+		user, err := model.GetUserByID(uid)
+		if err != nil {
+			util.Log().Warning("Owner of the trash cannot be found: %s", err)
+			continue
+		}
+
+		fs, err := filesystem.NewFileSystem(&user)
+		if err != nil {
+			util.Log().Warning("Failed to initialize filesystem: %s", err)
+			continue
+		}
+
+		util.Log().Debug("Purge %d expired trash items of user %d.", len(items), uid)
+		if err = fs.PurgeTrash(context.Background(), items); err != nil {
+			util.Log().Warning("Failed to purge trash: %s", err)
+		}
+
+		fs.Recycle()
+	}
+
+	util.Log().Info("Crontab job \"cron_purge_trash\" complete.")
+}
@@ -2,6 +2,8 @@ package email
 
 import (
 	"fmt"
+	"html"
+	"strings"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
@@ -34,3 +36,50 @@ func NewResetEmail(userName, resetURL string) (string, string) {
 	return fmt.Sprintf("【%s】密码重置", options["siteName"]),
 		util.Replace(replace, options["mail_reset_pwd_template"])
 }
+
+// NewMentionEmail 新建评论中被提及的通知邮件
+func NewMentionEmail(userName, actorName, fileName, content, commentURL string) (string, string) {
+	options := model.GetSettingByNames("siteName", "siteURL", "siteTitle", "mail_mention_template")
+	replace := map[string]string{
+		"{siteTitle}":    options["siteName"],
+		"{userName}":     html.EscapeString(userName),
+		"{actorName}":    html.EscapeString(actorName),
+		"{fileName}":     html.EscapeString(fileName),
+		"{content}":      html.EscapeString(content),
+		"{commentUrl}":   commentURL,
+		"{siteUrl}":      options["siteURL"],
+		"{siteSecTitle}": options["siteTitle"],
+	}
+	return fmt.Sprintf("【%s】%s 在评论中提到了你", options["siteName"], actorName),
+		util.Replace(replace, options["mail_mention_template"])
+}
+
+// ShareAccessDigest 分享访问汇总邮件中的一个分享
+type ShareAccessDigest struct {
+	Name      string
+	URL       string
+	Views     int
+	Downloads int
+	Visitors  int
+}
+
+// NewShareAccessEmail 新建分享被访问的汇总通知邮件
+func NewShareAccessEmail(userName string, digests []ShareAccessDigest) (string, string) {
+	options := model.GetSettingByNames("siteName", "siteURL", "siteTitle", "mail_share_access_template")
+
+	var shares strings.Builder
+	for _, digest := range digests {
+		fmt.Fprintf(&shares, `<li><a href="%s">%s</a>：浏览 %d 次，下载 %d 次，共 %d 位访客</li>`,
+			html.EscapeString(digest.URL), html.EscapeString(digest.Name), digest.Views, digest.Downloads, digest.Visitors)
+	}
+
+	replace := map[string]string{
+		"{siteTitle}":    options["siteName"],
+		"{userName}":     html.EscapeString(userName),
+		"{shares}":       shares.String(),
+		"{siteUrl}":      options["siteURL"],
+		"{siteSecTitle}": options["siteTitle"],
+	}
+	return fmt.Sprintf("【%s】你的分享有新的访问", options["siteName"]),
+		util.Replace(replace, options["mail_share_access_template"])
+}
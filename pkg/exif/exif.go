@@ -0,0 +1,46 @@
+package exif
+
+import (
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrInvalidImage      = errors.New("invalid or corrupted image")
+)
+
+// stripper 从 src 读取图片，将清除元数据后的内容写入 dst，返回是否有元数据被清除
+type stripper func(src io.ReadSeeker, dst io.Writer) (bool, error)
+
+var strippers = map[string]stripper{
+	"jpg":  stripJPEG,
+	"jpeg": stripJPEG,
+	"jpe":  stripJPEG,
+	"png":  stripPNG,
+	"heic": stripHEIF,
+	"heif": stripHEIF,
+}
+
+func ext(name string) string {
+	return strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+}
+
+// Supported 返回是否支持清除该文件名对应格式的元数据
+func Supported(name string) bool {
+	_, ok := strippers[ext(name)]
+	return ok
+}
+
+// Strip 根据文件名判断图片格式，清除 EXIF、GPS 等元数据后写入 dst，
+// 返回是否有元数据被清除。JPEG、PNG 会移除对应的段，HEIF 会将元数据原地填零以保持文件结构不变。
+func Strip(name string, src io.ReadSeeker, dst io.Writer) (bool, error) {
+	s, ok := strippers[ext(name)]
+	if !ok {
+		return false, ErrUnsupportedFormat
+	}
+
+	return s(src, dst)
+}
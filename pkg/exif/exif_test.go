@@ -0,0 +1,180 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func jpegSegment(marker byte, payload string) []byte {
+	res := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(res[2:], uint16(len(payload)+2))
+	return append(res, payload...)
+}
+
+func pngChunk(chunkType, data string) []byte {
+	res := make([]byte, 4)
+	binary.BigEndian.PutUint32(res, uint32(len(data)))
+	res = append(res, chunkType...)
+	res = append(res, data...)
+	return append(res, "CRC!"...)
+}
+
+func box(boxType string, payload ...[]byte) []byte {
+	content := bytes.Join(payload, nil)
+	res := make([]byte, 4)
+	binary.BigEndian.PutUint32(res, uint32(len(content)+8))
+	res = append(res, boxType...)
+	return append(res, content...)
+}
+
+func TestSupported(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.True(Supported("1.JPG"))
+	asserts.True(Supported("dir/1.heic"))
+	asserts.True(Supported("1.png"))
+	asserts.False(Supported("1.gif"))
+	asserts.False(Supported("jpg"))
+
+	_, err := Strip("1.txt", strings.NewReader(""), &bytes.Buffer{})
+	asserts.Equal(ErrUnsupportedFormat, err)
+}
+
+func TestStripJPEG(t *testing.T) {
+	asserts := assert.New(t)
+	jfif := jpegSegment(0xE0, "JFIF\x00")
+	icc := jpegSegment(0xE2, "ICC_PROFILE\x00")
+	scan := append(jpegSegment(markerSOS, "scan"), 0x12, 0xFF, 0x00, 0xFF, 0xE1, 0xFF, markerEOI)
+
+	var src []byte
+	src = append(src, 0xFF, markerSOI)
+	src = append(src, jfif...)
+	src = append(src, jpegSegment(markerAPP1, "Exif\x00\x00GPS")...)
+	src = append(src, icc...)
+	src = append(src, jpegSegment(markerCOM, "comment")...)
+	src = append(src, scan...)
+
+	// 清除元数据，压缩数据原样保留
+	{
+		dst := &bytes.Buffer{}
+		stripped, err := Strip("1.jpg", bytes.NewReader(src), dst)
+		asserts.NoError(err)
+		asserts.True(stripped)
+
+		expected := append([]byte{0xFF, markerSOI}, jfif...)
+		expected = append(expected, icc...)
+		expected = append(expected, scan...)
+		asserts.Equal(expected, dst.Bytes())
+	}
+
+	// 无元数据
+	{
+		dst := &bytes.Buffer{}
+		clean := append(append([]byte{0xFF, markerSOI}, jfif...), scan...)
+		stripped, err := Strip("1.jpg", bytes.NewReader(clean), dst)
+		asserts.NoError(err)
+		asserts.False(stripped)
+		asserts.Equal(clean, dst.Bytes())
+	}
+
+	// 格式错误
+	{
+		_, err := Strip("1.jpg", strings.NewReader("not a jpeg"), &bytes.Buffer{})
+		asserts.Equal(ErrInvalidImage, err)
+		_, err = Strip("1.jpg", bytes.NewReader(src[:10]), &bytes.Buffer{})
+		asserts.Equal(ErrInvalidImage, err)
+	}
+}
+
+func TestStripPNG(t *testing.T) {
+	asserts := assert.New(t)
+	ihdr := pngChunk("IHDR", "header")
+	idat := pngChunk("IDAT", "data")
+	iend := pngChunk("IEND", "")
+
+	var src []byte
+	src = append(src, pngSignature...)
+	src = append(src, ihdr...)
+	src = append(src, pngChunk("eXIf", "MM\x00*")...)
+	src = append(src, pngChunk("tEXt", "Author\x00me")...)
+	src = append(src, idat...)
+	src = append(src, pngChunk("tIME", "1234567")...)
+	src = append(src, iend...)
+
+	dst := &bytes.Buffer{}
+	stripped, err := Strip("1.png", bytes.NewReader(src), dst)
+	asserts.NoError(err)
+	asserts.True(stripped)
+	asserts.Equal(bytes.Join([][]byte{pngSignature, ihdr, idat, iend}, nil), dst.Bytes())
+
+	// 格式错误
+	_, err = Strip("1.png", strings.NewReader("GIF89a"), &bytes.Buffer{})
+	asserts.Equal(ErrInvalidImage, err)
+	_, err = Strip("1.png", bytes.NewReader(src[:len(src)-3]), &bytes.Buffer{})
+	asserts.Equal(ErrInvalidImage, err)
+}
+
+func TestStripHEIF(t *testing.T) {
+	asserts := assert.New(t)
+
+	infe := func(id uint16, itemType string, extra string) []byte {
+		payload := []byte{2, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint16(payload[4:], id)
+		return box("infe", payload, []byte(itemType), []byte(extra))
+	}
+
+	build := func(mdatOffset uint32) []byte {
+		iinf := box("iinf", []byte{0, 0, 0, 0, 0, 3},
+			infe(1, "hvc1", ""),
+			infe(2, "Exif", ""),
+			infe(3, "mime", "\x00application/rdf+xml\x00"),
+		)
+
+		// version 1，偏移量及长度为 4 字节
+		iloc := []byte{1, 0, 0, 0, 0x44, 0x00, 0, 3}
+		for _, item := range []struct {
+			id, method     uint16
+			offset, length uint32
+		}{{1, 0, mdatOffset, 4}, {2, 0, mdatOffset + 4, 6}, {3, 1, 0, 3}} {
+			entry := make([]byte, 18)
+			binary.BigEndian.PutUint16(entry, item.id)
+			binary.BigEndian.PutUint16(entry[2:], item.method)
+			binary.BigEndian.PutUint16(entry[6:], 1)
+			binary.BigEndian.PutUint32(entry[8:], item.offset)
+			binary.BigEndian.PutUint32(entry[12:], item.length)
+			iloc = append(iloc, entry[:16]...)
+		}
+
+		meta := box("meta", []byte{0, 0, 0, 0}, iinf, box("iloc", iloc), box("idat", []byte("xmp")))
+		res := append(box("ftyp", []byte("heic")), meta...)
+		return append(res, box("mdat", []byte("IMAGExifGPS"))...)
+	}
+
+	// 先计算 mdat 内容的偏移量
+	src := build(0)
+	mdatOffset := uint32(len(src) - len("IMAGExifGPS"))
+	src = build(mdatOffset)
+
+	dst := &bytes.Buffer{}
+	stripped, err := Strip("1.HEIC", bytes.NewReader(src), dst)
+	asserts.NoError(err)
+	asserts.True(stripped)
+	asserts.Len(dst.Bytes(), len(src))
+	asserts.Equal("IMAG\x00\x00\x00\x00\x00\x00S", string(dst.Bytes()[mdatOffset:]))
+	asserts.NotContains(dst.String(), "xmp")
+
+	// 不是 HEIF
+	_, err = Strip("1.heic", bytes.NewReader(jpegSegment(0xE0, "JFIF")), &bytes.Buffer{})
+	asserts.Equal(ErrInvalidImage, err)
+
+	// 没有 meta 盒
+	dst.Reset()
+	clean := append(box("ftyp", []byte("heic")), box("mdat", []byte("IMAGE"))...)
+	stripped, err = Strip("1.heic", bytes.NewReader(clean), dst)
+	asserts.NoError(err)
+	asserts.False(stripped)
+	asserts.Equal(clean, dst.Bytes())
+}
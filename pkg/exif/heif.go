@@ -0,0 +1,406 @@
+package exif
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// maxMetaBoxSize 读入内存解析的 meta 盒最大长度
+const maxMetaBoxSize = 16 << 20
+
+// byteRange 文件中需要填零的区间
+type byteRange struct {
+	offset uint64
+	length uint64
+}
+
+// stripHEIF 在 meta 盒中找到 Exif 及 XMP 项目的数据区间，复制文件时将其填零。
+// 修改数据长度需要重写 iloc 中的全部偏移量，因此只填零而不移除。
+func stripHEIF(src io.ReadSeeker, dst io.Writer) (bool, error) {
+	ranges, err := heifMetadataRanges(src)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	if _, err := io.Copy(&zeroWriter{w: dst, ranges: ranges}, src); err != nil {
+		return false, err
+	}
+
+	return len(ranges) > 0, nil
+}
+
+// heifMetadataRanges 解析顶层盒，返回元数据项目在文件中的区间
+func heifMetadataRanges(src io.ReadSeeker) ([]byteRange, error) {
+	var offset uint64
+	first := true
+	for {
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(src, header); err != nil {
+			if err == io.EOF && !first {
+				return nil, nil
+			}
+			return nil, ErrInvalidImage
+		}
+
+		size := uint64(binary.BigEndian.Uint32(header[:4]))
+		boxType := string(header[4:])
+		headerSize := uint64(8)
+		if size == 1 {
+			if _, err := io.ReadFull(src, header); err != nil {
+				return nil, ErrInvalidImage
+			}
+			size = binary.BigEndian.Uint64(header)
+			headerSize = 16
+		}
+
+		if first && boxType != "ftyp" {
+			return nil, ErrInvalidImage
+		}
+		first = false
+
+		// 长度为 0 表示延伸至文件末尾，只可能是最后一个盒
+		if size == 0 {
+			return nil, nil
+		}
+
+		if size < headerSize {
+			return nil, ErrInvalidImage
+		}
+
+		if boxType == "meta" {
+			if size-headerSize > maxMetaBoxSize {
+				return nil, ErrInvalidImage
+			}
+
+			payload := make([]byte, size-headerSize)
+			if _, err := io.ReadFull(src, payload); err != nil {
+				return nil, ErrInvalidImage
+			}
+
+			return parseMeta(payload, offset+headerSize)
+		}
+
+		offset += size
+		if _, err := src.Seek(int64(offset), io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseMeta 解析 meta 盒，payloadOffset 为其内容在文件中的偏移量
+func parseMeta(payload []byte, payloadOffset uint64) ([]byteRange, error) {
+	if len(payload) < 4 {
+		return nil, ErrInvalidImage
+	}
+
+	var (
+		metadataItems map[uint32]bool
+		locations     map[uint32][]itemExtent
+		idatOffset    uint64
+		hasIdat       bool
+	)
+
+	err := walkBoxes(payload[4:], func(boxType string, content []byte, start int) error {
+		var err error
+		switch boxType {
+		case "iinf":
+			metadataItems, err = parseIinf(content)
+		case "iloc":
+			locations, err = parseIloc(content)
+		case "idat":
+			idatOffset, hasIdat = payloadOffset+4+uint64(start), true
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ranges []byteRange
+	for id := range metadataItems {
+		for _, extent := range locations[id] {
+			if extent.length == 0 {
+				continue
+			}
+
+			switch extent.constructionMethod {
+			case 0:
+				ranges = append(ranges, byteRange{offset: extent.offset, length: extent.length})
+			case 1:
+				if hasIdat {
+					ranges = append(ranges, byteRange{offset: idatOffset + extent.offset, length: extent.length})
+				}
+			}
+		}
+	}
+
+	return ranges, nil
+}
+
+// walkBoxes 遍历 b 中的子盒，start 为盒内容在 b 中的偏移量
+func walkBoxes(b []byte, fn func(boxType string, content []byte, start int) error) error {
+	for pos := 0; pos < len(b); {
+		if len(b)-pos < 8 {
+			return ErrInvalidImage
+		}
+
+		size := uint64(binary.BigEndian.Uint32(b[pos:]))
+		boxType := string(b[pos+4 : pos+8])
+		headerSize := uint64(8)
+		if size == 1 {
+			if len(b)-pos < 16 {
+				return ErrInvalidImage
+			}
+			size = binary.BigEndian.Uint64(b[pos+8:])
+			headerSize = 16
+		} else if size == 0 {
+			size = uint64(len(b) - pos)
+		}
+
+		if size < headerSize || size > uint64(len(b)-pos) {
+			return ErrInvalidImage
+		}
+
+		start := pos + int(headerSize)
+		if err := fn(boxType, b[start:pos+int(size)], start); err != nil {
+			return err
+		}
+
+		pos += int(size)
+	}
+
+	return nil
+}
+
+// parseIinf 解析项目信息，返回 Exif 及 XMP 项目 ID
+func parseIinf(b []byte) (map[uint32]bool, error) {
+	c := &cursor{b: b}
+	version := c.u8()
+	c.skip(3)
+	if version == 0 {
+		c.u16()
+	} else {
+		c.u32()
+	}
+
+	if c.err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	items := make(map[uint32]bool)
+	err := walkBoxes(b[c.pos:], func(boxType string, content []byte, start int) error {
+		if boxType != "infe" {
+			return nil
+		}
+
+		c := &cursor{b: content}
+		version := c.u8()
+		c.skip(3)
+		// 旧版本的 infe 没有项目类型
+		if version < 2 {
+			return nil
+		}
+
+		var id uint32
+		if version == 2 {
+			id = uint32(c.u16())
+		} else {
+			id = c.u32()
+		}
+		c.u16()
+		itemType := c.fourCC()
+		if c.err != nil {
+			return ErrInvalidImage
+		}
+
+		switch itemType {
+		case "Exif":
+			items[id] = true
+		case "mime":
+			c.cString()
+			if c.cString() == "application/rdf+xml" {
+				items[id] = true
+			}
+		}
+
+		return nil
+	})
+
+	return items, err
+}
+
+// itemExtent 项目数据的一个区间
+type itemExtent struct {
+	constructionMethod uint16
+	offset             uint64
+	length             uint64
+}
+
+// parseIloc 解析项目位置
+func parseIloc(b []byte) (map[uint32][]itemExtent, error) {
+	c := &cursor{b: b}
+	version := c.u8()
+	c.skip(3)
+	sizes := c.u8()
+	offsetSize, lengthSize := int(sizes>>4), int(sizes&0xF)
+	sizes = c.u8()
+	baseOffsetSize, indexSize := int(sizes>>4), 0
+	if version == 1 || version == 2 {
+		indexSize = int(sizes & 0xF)
+	}
+
+	var count uint32
+	if version < 2 {
+		count = uint32(c.u16())
+	} else {
+		count = c.u32()
+	}
+
+	locations := make(map[uint32][]itemExtent)
+	for i := uint32(0); i < count && c.err == nil; i++ {
+		var id uint32
+		if version < 2 {
+			id = uint32(c.u16())
+		} else {
+			id = c.u32()
+		}
+
+		var method uint16
+		if version == 1 || version == 2 {
+			method = c.u16() & 0xF
+		}
+
+		c.u16()
+		baseOffset := c.uint(baseOffsetSize)
+		extentCount := c.u16()
+		for j := uint16(0); j < extentCount && c.err == nil; j++ {
+			c.uint(indexSize)
+			offset := c.uint(offsetSize)
+			length := c.uint(lengthSize)
+			locations[id] = append(locations[id], itemExtent{
+				constructionMethod: method,
+				offset:             baseOffset + offset,
+				length:             length,
+			})
+		}
+	}
+
+	if c.err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	return locations, nil
+}
+
+// cursor 按大端序顺序读取字节，越界时记录错误
+type cursor struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (c *cursor) next(n int) []byte {
+	if c.err != nil || n > len(c.b)-c.pos {
+		c.err = ErrInvalidImage
+		return make([]byte, n)
+	}
+
+	res := c.b[c.pos : c.pos+n]
+	c.pos += n
+	return res
+}
+
+func (c *cursor) skip(n int) {
+	c.next(n)
+}
+
+func (c *cursor) u8() uint8 {
+	return c.next(1)[0]
+}
+
+func (c *cursor) u16() uint16 {
+	return binary.BigEndian.Uint16(c.next(2))
+}
+
+func (c *cursor) u32() uint32 {
+	return binary.BigEndian.Uint32(c.next(4))
+}
+
+// uint 读取 size 字节的无符号整数，size 可以为 0、4 或 8
+func (c *cursor) uint(size int) uint64 {
+	switch size {
+	case 0:
+		return 0
+	case 4:
+		return uint64(c.u32())
+	case 8:
+		return binary.BigEndian.Uint64(c.next(8))
+	default:
+		c.err = ErrInvalidImage
+		return 0
+	}
+}
+
+func (c *cursor) fourCC() string {
+	return string(c.next(4))
+}
+
+// cString 读取以 0 结尾的字符串
+func (c *cursor) cString() string {
+	if c.err != nil {
+		return ""
+	}
+
+	for i := c.pos; i < len(c.b); i++ {
+		if c.b[i] == 0 {
+			res := string(c.b[c.pos:i])
+			c.pos = i + 1
+			return res
+		}
+	}
+
+	c.err = ErrInvalidImage
+	return ""
+}
+
+// zeroWriter 写入时将指定区间的数据填零
+type zeroWriter struct {
+	w      io.Writer
+	ranges []byteRange
+	pos    uint64
+}
+
+func (z *zeroWriter) Write(p []byte) (int, error) {
+	buf, copied := p, false
+	end := z.pos + uint64(len(p))
+	for _, r := range z.ranges {
+		from, to := r.offset, r.offset+r.length
+		if to <= z.pos || from >= end {
+			continue
+		}
+
+		// 不修改调用方的缓冲区
+		if !copied {
+			buf, copied = append([]byte(nil), p...), true
+		}
+
+		if from < z.pos {
+			from = z.pos
+		}
+		if to > end {
+			to = end
+		}
+
+		for i := from; i < to; i++ {
+			buf[i-z.pos] = 0
+		}
+	}
+
+	n, err := z.w.Write(buf)
+	z.pos += uint64(n)
+	return n, err
+}
@@ -0,0 +1,102 @@
+package exif
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	markerSOI  = 0xD8
+	markerEOI  = 0xD9
+	markerSOS  = 0xDA
+	markerTEM  = 0x01
+	markerRST0 = 0xD0
+	markerRST7 = 0xD7
+	// markerAPP1 Exif（含 GPS）及 XMP
+	markerAPP1 = 0xE1
+	// markerAPP13 Photoshop IPTC
+	markerAPP13 = 0xED
+	markerCOM   = 0xFE
+)
+
+// stripJPEG 移除 JPEG 中的 APP1、APP13 及注释段，保留 JFIF、ICC 色彩配置等其他段
+func stripJPEG(src io.ReadSeeker, dst io.Writer) (bool, error) {
+	r := bufio.NewReader(src)
+	soi := make([]byte, 2)
+	if _, err := io.ReadFull(r, soi); err != nil || soi[0] != 0xFF || soi[1] != markerSOI {
+		return false, ErrInvalidImage
+	}
+
+	if _, err := dst.Write(soi); err != nil {
+		return false, err
+	}
+
+	stripped := false
+	for {
+		marker, err := readMarker(r)
+		if err != nil {
+			return false, err
+		}
+
+		switch {
+		case marker == markerSOS || marker == markerEOI:
+			// 之后为压缩后的图像数据，原样复制
+			if _, err := dst.Write([]byte{0xFF, marker}); err != nil {
+				return false, err
+			}
+			_, err := io.Copy(dst, r)
+			return stripped, err
+		case marker == markerTEM || (marker >= markerRST0 && marker <= markerRST7):
+			if _, err := dst.Write([]byte{0xFF, marker}); err != nil {
+				return false, err
+			}
+			continue
+		}
+
+		lengthBytes := make([]byte, 2)
+		if _, err := io.ReadFull(r, lengthBytes); err != nil {
+			return false, ErrInvalidImage
+		}
+
+		length := int64(binary.BigEndian.Uint16(lengthBytes))
+		if length < 2 {
+			return false, ErrInvalidImage
+		}
+
+		if marker == markerAPP1 || marker == markerAPP13 || marker == markerCOM {
+			if _, err := r.Discard(int(length - 2)); err != nil {
+				return false, ErrInvalidImage
+			}
+			stripped = true
+			continue
+		}
+
+		if _, err := dst.Write([]byte{0xFF, marker, lengthBytes[0], lengthBytes[1]}); err != nil {
+			return false, err
+		}
+
+		if _, err := io.CopyN(dst, r, length-2); err != nil {
+			return false, ErrInvalidImage
+		}
+	}
+}
+
+// readMarker 读取下一个段标记，跳过填充字节
+func readMarker(r *bufio.Reader) (byte, error) {
+	b, err := r.ReadByte()
+	if err != nil || b != 0xFF {
+		return 0, ErrInvalidImage
+	}
+
+	for {
+		b, err = r.ReadByte()
+		if err != nil {
+			return 0, ErrInvalidImage
+		}
+
+		if b != 0xFF {
+			return b, nil
+		}
+	}
+}
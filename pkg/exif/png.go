@@ -0,0 +1,67 @@
+package exif
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadataChunks 需要移除的元数据块
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+// stripPNG 移除 PNG 中的 EXIF、文本及时间块
+func stripPNG(src io.ReadSeeker, dst io.Writer) (bool, error) {
+	r := bufio.NewReader(src)
+	signature := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(r, signature); err != nil || !bytes.Equal(signature, pngSignature) {
+		return false, ErrInvalidImage
+	}
+
+	if _, err := dst.Write(signature); err != nil {
+		return false, err
+	}
+
+	stripped := false
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return stripped, nil
+			}
+			return false, ErrInvalidImage
+		}
+
+		// 数据长度及 CRC
+		length := int64(binary.BigEndian.Uint32(header[:4])) + 4
+		chunkType := string(header[4:])
+		if pngMetadataChunks[chunkType] {
+			if _, err := io.CopyN(io.Discard, r, length); err != nil {
+				return false, ErrInvalidImage
+			}
+			stripped = true
+			continue
+		}
+
+		if _, err := dst.Write(header); err != nil {
+			return false, err
+		}
+
+		if _, err := io.CopyN(dst, r, length); err != nil {
+			return false, ErrInvalidImage
+		}
+
+		if chunkType == "IEND" {
+			_, err := io.Copy(dst, r)
+			return stripped, err
+		}
+	}
+}
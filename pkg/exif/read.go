@@ -0,0 +1,347 @@
+package exif
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNoExif 图片中不包含 EXIF 信息
+var ErrNoExif = errors.New("no exif data found")
+
+// Info 从 EXIF 中提取的拍摄信息
+type Info struct {
+	TakenAt   *time.Time
+	Make      string
+	Model     string
+	Latitude  *float64
+	Longitude *float64
+}
+
+// Camera 返回相机名称
+func (i *Info) Camera() string {
+	if i.Make == "" || strings.HasPrefix(i.Model, i.Make) {
+		return i.Model
+	}
+
+	return strings.TrimSpace(i.Make + " " + i.Model)
+}
+
+// exifReader 从 src 中读取原始的 TIFF 格式 EXIF 数据
+type exifReader func(src io.Reader) ([]byte, error)
+
+var exifReaders = map[string]exifReader{
+	"jpg":  readJPEGExif,
+	"jpeg": readJPEGExif,
+	"jpe":  readJPEGExif,
+	"png":  readPNGExif,
+}
+
+// Readable 返回是否支持读取该文件名对应格式的 EXIF 信息
+func Readable(name string) bool {
+	_, ok := exifReaders[ext(name)]
+	return ok
+}
+
+// Read 根据文件名判断图片格式，读取其中的拍摄时间、相机及 GPS 位置
+func Read(name string, src io.Reader) (*Info, error) {
+	reader, ok := exifReaders[ext(name)]
+	if !ok {
+		return nil, ErrUnsupportedFormat
+	}
+
+	raw, err := reader(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTIFF(raw)
+}
+
+// readJPEGExif 读取 JPEG 中 APP1 段内的 EXIF 数据
+func readJPEGExif(src io.Reader) ([]byte, error) {
+	r := bufio.NewReader(src)
+	soi := make([]byte, 2)
+	if _, err := io.ReadFull(r, soi); err != nil || soi[0] != 0xFF || soi[1] != markerSOI {
+		return nil, ErrInvalidImage
+	}
+
+	exifHeader := []byte("Exif\x00\x00")
+	for {
+		marker, err := readMarker(r)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case marker == markerSOS || marker == markerEOI:
+			return nil, ErrNoExif
+		case marker == markerTEM || (marker >= markerRST0 && marker <= markerRST7):
+			continue
+		}
+
+		lengthBytes := make([]byte, 2)
+		if _, err := io.ReadFull(r, lengthBytes); err != nil {
+			return nil, ErrInvalidImage
+		}
+
+		length := int(binary.BigEndian.Uint16(lengthBytes))
+		if length < 2 {
+			return nil, ErrInvalidImage
+		}
+
+		payload := make([]byte, length-2)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, ErrInvalidImage
+		}
+
+		if marker == markerAPP1 && bytes.HasPrefix(payload, exifHeader) {
+			return payload[len(exifHeader):], nil
+		}
+	}
+}
+
+// readPNGExif 读取 PNG 中 eXIf 块的内容
+func readPNGExif(src io.Reader) ([]byte, error) {
+	r := bufio.NewReader(src)
+	signature := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(r, signature); err != nil || !bytes.Equal(signature, pngSignature) {
+		return nil, ErrInvalidImage
+	}
+
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil, ErrNoExif
+			}
+			return nil, ErrInvalidImage
+		}
+
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		switch string(header[4:]) {
+		case "eXIf":
+			if length > maxMetaBoxSize {
+				return nil, ErrInvalidImage
+			}
+
+			data := make([]byte, length)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, ErrInvalidImage
+			}
+			return data, nil
+		case "IEND":
+			return nil, ErrNoExif
+		}
+
+		// 数据及 CRC
+		if _, err := io.CopyN(io.Discard, r, length+4); err != nil {
+			return nil, ErrInvalidImage
+		}
+	}
+}
+
+const (
+	tagMake               = 0x010F
+	tagModel              = 0x0110
+	tagDateTime           = 0x0132
+	tagExifIFD            = 0x8769
+	tagGPSIFD             = 0x8825
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+	tagGPSLatitudeRef     = 0x0001
+	tagGPSLatitude        = 0x0002
+	tagGPSLongitudeRef    = 0x0003
+	tagGPSLongitude       = 0x0004
+
+	typeASCII    = 2
+	typeShort    = 3
+	typeLong     = 4
+	typeRational = 5
+
+	exifTimeLayout = "2006:01:02 15:04:05"
+)
+
+// tiffTypeSize TIFF 字段类型对应的单个值长度
+var tiffTypeSize = map[uint16]uint32{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 7: 1, 9: 4, 10: 8}
+
+// ifdEntry IFD 中的一个字段
+type ifdEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// tiff 解析中的 TIFF 数据
+type tiff struct {
+	b     []byte
+	order binary.ByteOrder
+}
+
+// parseTIFF 解析 TIFF 格式的 EXIF 数据
+func parseTIFF(b []byte) (*Info, error) {
+	if len(b) < 8 {
+		return nil, ErrInvalidImage
+	}
+
+	t := &tiff{b: b}
+	switch string(b[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, ErrInvalidImage
+	}
+
+	if t.order.Uint16(b[2:4]) != 42 {
+		return nil, ErrInvalidImage
+	}
+
+	ifd0, err := t.readIFD(t.order.Uint32(b[4:8]))
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{
+		Make:  t.ascii(ifd0[tagMake]),
+		Model: t.ascii(ifd0[tagModel]),
+	}
+
+	dateTime, offsetTime := t.ascii(ifd0[tagDateTime]), ""
+	if exifIFD, err := t.subIFD(ifd0[tagExifIFD]); err == nil {
+		if original := t.ascii(exifIFD[tagDateTimeOriginal]); original != "" {
+			dateTime = original
+			offsetTime = t.ascii(exifIFD[tagOffsetTimeOriginal])
+		}
+	}
+	info.TakenAt = parseExifTime(dateTime, offsetTime)
+
+	if gpsIFD, err := t.subIFD(ifd0[tagGPSIFD]); err == nil {
+		lat, latOk := t.coordinate(gpsIFD[tagGPSLatitude], t.ascii(gpsIFD[tagGPSLatitudeRef]), "S", 90)
+		lng, lngOk := t.coordinate(gpsIFD[tagGPSLongitude], t.ascii(gpsIFD[tagGPSLongitudeRef]), "W", 180)
+		if latOk && lngOk {
+			info.Latitude, info.Longitude = &lat, &lng
+		}
+	}
+
+	return info, nil
+}
+
+// readIFD 读取 offset 处的 IFD
+func (t *tiff) readIFD(offset uint32) (map[uint16]ifdEntry, error) {
+	if uint64(offset)+2 > uint64(len(t.b)) {
+		return nil, ErrInvalidImage
+	}
+
+	count := uint64(t.order.Uint16(t.b[offset:]))
+	start := uint64(offset) + 2
+	if start+count*12 > uint64(len(t.b)) {
+		return nil, ErrInvalidImage
+	}
+
+	entries := make(map[uint16]ifdEntry, count)
+	for i := uint64(0); i < count; i++ {
+		raw := t.b[start+i*12 : start+i*12+12]
+		entry := ifdEntry{
+			typ:   t.order.Uint16(raw[2:4]),
+			count: t.order.Uint32(raw[4:8]),
+		}
+
+		size, ok := tiffTypeSize[entry.typ]
+		if !ok {
+			continue
+		}
+
+		length := uint64(size) * uint64(entry.count)
+		if length <= 4 {
+			entry.value = raw[8 : 8+length]
+		} else {
+			valueOffset := uint64(t.order.Uint32(raw[8:12]))
+			if valueOffset+length > uint64(len(t.b)) {
+				continue
+			}
+			entry.value = t.b[valueOffset : valueOffset+length]
+		}
+
+		entries[t.order.Uint16(raw[0:2])] = entry
+	}
+
+	return entries, nil
+}
+
+// subIFD 读取指针字段指向的子 IFD
+func (t *tiff) subIFD(pointer ifdEntry) (map[uint16]ifdEntry, error) {
+	if pointer.count != 1 || (pointer.typ != typeLong && pointer.typ != typeShort) {
+		return nil, ErrNoExif
+	}
+
+	if pointer.typ == typeShort {
+		return t.readIFD(uint32(t.order.Uint16(pointer.value)))
+	}
+
+	return t.readIFD(t.order.Uint32(pointer.value))
+}
+
+// ascii 读取字符串字段
+func (t *tiff) ascii(entry ifdEntry) string {
+	if entry.typ != typeASCII {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.TrimRight(string(entry.value), "\x00"))
+}
+
+// coordinate 读取以度、分、秒表示的 GPS 坐标，ref 为 negativeRef 时取负值
+func (t *tiff) coordinate(entry ifdEntry, ref, negativeRef string, limit float64) (float64, bool) {
+	if entry.typ != typeRational || entry.count != 3 {
+		return 0, false
+	}
+
+	res := 0.0
+	for i, unit := range []float64{1, 60, 3600} {
+		numerator := t.order.Uint32(entry.value[i*8:])
+		denominator := t.order.Uint32(entry.value[i*8+4:])
+		if denominator == 0 {
+			return 0, false
+		}
+		res += float64(numerator) / float64(denominator) / unit
+	}
+
+	if res > limit {
+		return 0, false
+	}
+
+	if strings.EqualFold(ref, negativeRef) {
+		res = -res
+	}
+
+	return res, true
+}
+
+// parseExifTime 解析 EXIF 时间，未记录时区时按服务器所在时区处理
+func parseExifTime(value, offset string) *time.Time {
+	if value == "" {
+		return nil
+	}
+
+	var (
+		res time.Time
+		err error
+	)
+	if offset != "" {
+		res, err = time.Parse(exifTimeLayout+"-07:00", value+offset)
+	} else {
+		res, err = time.ParseInLocation(exifTimeLayout, value, time.Local)
+	}
+
+	if err != nil || res.Year() < 1900 {
+		return nil
+	}
+
+	return &res
+}
@@ -0,0 +1,201 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testEntry 测试用 IFD 字段，ifd 大于 0 时为指向第 ifd 个 IFD 的指针
+type testEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+	ifd   int
+}
+
+func asciiEntry(tag uint16, value string) testEntry {
+	return testEntry{tag: tag, typ: typeASCII, count: uint32(len(value) + 1), value: append([]byte(value), 0)}
+}
+
+func rationalEntry(order binary.ByteOrder, tag uint16, values ...uint32) testEntry {
+	res := make([]byte, 4*len(values))
+	for i, v := range values {
+		order.PutUint32(res[i*4:], v)
+	}
+	return testEntry{tag: tag, typ: typeRational, count: uint32(len(values) / 2), value: res}
+}
+
+func appendUint16(order binary.ByteOrder, b []byte, v uint16) []byte {
+	res := make([]byte, 2)
+	order.PutUint16(res, v)
+	return append(b, res...)
+}
+
+func appendUint32(order binary.ByteOrder, b []byte, v uint32) []byte {
+	res := make([]byte, 4)
+	order.PutUint32(res, v)
+	return append(b, res...)
+}
+
+// buildTIFF 依次排列各 IFD，字段值放置在全部 IFD 之后
+func buildTIFF(order binary.ByteOrder, ifds ...[]testEntry) []byte {
+	offsets := make([]uint32, len(ifds))
+	dataOffset := uint32(8)
+	for i, ifd := range ifds {
+		offsets[i] = dataOffset
+		dataOffset += uint32(2 + 12*len(ifd) + 4)
+	}
+
+	res := make([]byte, 8, dataOffset)
+	if order == binary.LittleEndian {
+		copy(res, "II")
+	} else {
+		copy(res, "MM")
+	}
+	order.PutUint16(res[2:], 42)
+	order.PutUint32(res[4:], offsets[0])
+
+	var data []byte
+	for _, ifd := range ifds {
+		res = appendUint16(order, res, uint16(len(ifd)))
+		for _, entry := range ifd {
+			res = appendUint16(order, res, entry.tag)
+			if entry.ifd > 0 {
+				res = appendUint16(order, res, typeLong)
+				res = appendUint32(order, res, 1)
+				res = appendUint32(order, res, offsets[entry.ifd])
+				continue
+			}
+
+			res = appendUint16(order, res, entry.typ)
+			res = appendUint32(order, res, entry.count)
+			if len(entry.value) <= 4 {
+				res = append(res, entry.value...)
+				res = append(res, make([]byte, 4-len(entry.value))...)
+				continue
+			}
+
+			res = appendUint32(order, res, dataOffset+uint32(len(data)))
+			data = append(data, entry.value...)
+		}
+		res = appendUint32(order, res, 0)
+	}
+
+	return append(res, data...)
+}
+
+func testTIFF(order binary.ByteOrder) []byte {
+	return buildTIFF(order,
+		[]testEntry{
+			asciiEntry(tagMake, "Canon"),
+			asciiEntry(tagModel, "Canon EOS R5"),
+			asciiEntry(tagDateTime, "2023:06:01 00:00:00"),
+			{tag: tagExifIFD, ifd: 1},
+			{tag: tagGPSIFD, ifd: 2},
+		},
+		[]testEntry{
+			asciiEntry(tagDateTimeOriginal, "2023:05:01 10:20:30"),
+			asciiEntry(tagOffsetTimeOriginal, "+08:00"),
+		},
+		[]testEntry{
+			asciiEntry(tagGPSLatitudeRef, "N"),
+			rationalEntry(order, tagGPSLatitude, 31, 1, 12, 1, 36, 1),
+			asciiEntry(tagGPSLongitudeRef, "W"),
+			rationalEntry(order, tagGPSLongitude, 121, 1, 30, 1, 0, 1),
+		},
+	)
+}
+
+func TestReadable(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.True(Readable("1.JPG"))
+	asserts.True(Readable("1.png"))
+	asserts.False(Readable("1.gif"))
+
+	_, err := Read("1.txt", strings.NewReader(""))
+	asserts.Equal(ErrUnsupportedFormat, err)
+}
+
+func TestParseTIFF(t *testing.T) {
+	asserts := assert.New(t)
+
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		info, err := parseTIFF(testTIFF(order))
+		asserts.NoError(err)
+		asserts.Equal("Canon", info.Make)
+		asserts.Equal("Canon EOS R5", info.Camera())
+		asserts.True(time.Date(2023, 5, 1, 2, 20, 30, 0, time.UTC).Equal(*info.TakenAt))
+		asserts.InDelta(31.21, *info.Latitude, 1e-9)
+		asserts.InDelta(-121.5, *info.Longitude, 1e-9)
+	}
+
+	// 无 EXIF 子 IFD 时使用 IFD0 中的时间，无 GPS 信息
+	{
+		info, err := parseTIFF(buildTIFF(binary.LittleEndian, []testEntry{
+			asciiEntry(tagMake, "Apple"),
+			asciiEntry(tagModel, "iPhone 14"),
+			asciiEntry(tagDateTime, "2023:06:01 08:00:00"),
+		}))
+		asserts.NoError(err)
+		asserts.Equal("Apple iPhone 14", info.Camera())
+		asserts.Equal(time.Date(2023, 6, 1, 8, 0, 0, 0, time.Local), *info.TakenAt)
+		asserts.Nil(info.Latitude)
+		asserts.Nil(info.Longitude)
+	}
+
+	// 无效数据
+	for _, raw := range []string{"", "XX*\x00\x08\x00\x00\x00", "II\x2b\x00\x08\x00\x00\x00", "II*\x00\xff\x00\x00\x00"} {
+		_, err := parseTIFF([]byte(raw))
+		asserts.Equal(ErrInvalidImage, err, raw)
+	}
+}
+
+func TestReadJPEG(t *testing.T) {
+	asserts := assert.New(t)
+	scan := append(jpegSegment(markerSOS, "scan"), 0x12, 0xFF, markerEOI)
+
+	var src []byte
+	src = append(src, 0xFF, markerSOI)
+	src = append(src, jpegSegment(0xE0, "JFIF\x00")...)
+	src = append(src, jpegSegment(markerAPP1, "http://ns.adobe.com/xap/1.0/\x00")...)
+	src = append(src, jpegSegment(markerAPP1, "Exif\x00\x00"+string(testTIFF(binary.BigEndian)))...)
+	src = append(src, scan...)
+
+	info, err := Read("1.jpg", bytes.NewReader(src))
+	asserts.NoError(err)
+	asserts.Equal("Canon EOS R5", info.Model)
+
+	// 无 EXIF 段
+	src = append([]byte{0xFF, markerSOI}, scan...)
+	_, err = Read("1.jpg", bytes.NewReader(src))
+	asserts.Equal(ErrNoExif, err)
+
+	// 无效图片
+	_, err = Read("1.jpg", strings.NewReader("not a jpeg"))
+	asserts.Equal(ErrInvalidImage, err)
+}
+
+func TestReadPNG(t *testing.T) {
+	asserts := assert.New(t)
+
+	var src []byte
+	src = append(src, pngSignature...)
+	src = append(src, pngChunk("IHDR", "header")...)
+	src = append(src, pngChunk("eXIf", string(testTIFF(binary.LittleEndian)))...)
+	src = append(src, pngChunk("IEND", "")...)
+
+	info, err := Read("1.png", bytes.NewReader(src))
+	asserts.NoError(err)
+	asserts.Equal("Canon", info.Make)
+
+	// 无 eXIf 块
+	src = append(append([]byte{}, pngSignature...), pngChunk("IEND", "")...)
+	_, err = Read("1.png", bytes.NewReader(src))
+	asserts.Equal(ErrNoExif, err)
+}
@@ -0,0 +1,72 @@
+package filesystem
+
+import (
+	"context"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// NewActivities 查找用户的目录和文件，生成操作动态。parent 为对象所在目录，
+// 删除操作需在对象被删除前调用
+func (fs *FileSystem) NewActivities(activityType, parent, detail string, dirs, files []uint) []model.Activity {
+	activities := make([]model.Activity, 0, len(dirs)+len(files))
+	if len(dirs) > 0 {
+		folders, err := model.GetFoldersByIDs(dirs, fs.User.ID)
+		if err != nil {
+			util.Log().Warning("Failed to list folders for activities: %s", err)
+		}
+
+		for i := range folders {
+			folders[i].Position = parent
+			activities = append(activities, model.NewFolderActivity(activityType, &folders[i], detail))
+		}
+	}
+
+	if len(files) > 0 {
+		fileModels, err := model.GetFilesByIDs(files, fs.User.ID)
+		if err != nil {
+			util.Log().Warning("Failed to list files for activities: %s", err)
+		}
+
+		for i := range fileModels {
+			fileModels[i].Position = parent
+			activities = append(activities, model.NewFileActivity(activityType, &fileModels[i], detail))
+		}
+	}
+
+	return activities
+}
+
+// RecordActivities 保存操作动态，失败时只记录日志，不影响操作本身
+func RecordActivities(activities ...model.Activity) {
+	if len(activities) == 0 {
+		return
+	}
+
+	if err := model.CreateActivities(activities); err != nil {
+		util.Log().Warning("Failed to record activities: %s", err)
+	}
+}
+
+// HookRecordUploadActivity 记录上传或更新文件内容的操作动态
+func HookRecordUploadActivity(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+	fileInfo := fileHeader.Info()
+	file, ok := fileInfo.Model.(*model.File)
+	if !ok {
+		originFile, ok := ctx.Value(fsctx.FileModelCtx).(model.File)
+		if !ok {
+			return nil
+		}
+		file = &originFile
+	}
+
+	activity := model.NewFileActivity(model.ActivityUpload, file, "")
+	activity.UserID = fs.User.ID
+	if fileInfo.VirtualPath != "" {
+		activity.Path = fileInfo.VirtualPath
+	}
+	RecordActivities(activity)
+	return nil
+}
@@ -0,0 +1,98 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_NewActivities(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "owner_id"}).AddRow(1, "dir", 1))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "user_id"}).AddRow(2, "1.txt", 1))
+		res := fs.NewActivities(model.ActivityMove, "/src", "/dst", []uint{1}, []uint{2})
+		a.NoError(mock.ExpectationsWereMet())
+		a.Len(res, 2)
+		a.True(res[0].IsDir)
+		a.Equal("dir", res[0].Name)
+		a.Equal("/src", res[0].Path)
+		a.Equal("/dst", res[0].Detail)
+		a.EqualValues(2, res[1].ObjectID)
+		a.Equal("1.txt", res[1].Name)
+	}
+
+	// 查询失败
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("error"))
+		res := fs.NewActivities(model.ActivityDelete, "", "", nil, []uint{2})
+		a.NoError(mock.ExpectationsWereMet())
+		a.Empty(res)
+	}
+}
+
+func TestRecordActivities(t *testing.T) {
+	a := assert.New(t)
+
+	// 无需记录
+	{
+		RecordActivities()
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 记录失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)activities(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		RecordActivities(model.Activity{UserID: 1})
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestHookRecordUploadActivity(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+
+	// 上传新文件
+	{
+		file := &model.File{Name: "1.txt"}
+		file.ID = 2
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)activities(.+)").
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, model.ActivityUpload, 2, false, "1.txt", "/docs", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		a.NoError(HookRecordUploadActivity(context.Background(), fs, &fsctx.FileStream{Model: file, VirtualPath: "/docs"}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 更新已有文件
+	{
+		file := model.File{Name: "1.txt"}
+		file.ID = 3
+		ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, file)
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)activities(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		a.NoError(HookRecordUploadActivity(ctx, fs, &fsctx.FileStream{}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 文件未知
+	{
+		a.NoError(HookRecordUploadActivity(context.Background(), fs, &fsctx.FileStream{}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
@@ -1,10 +1,13 @@
 package filesystem
 
 import (
+	"archive/tar"
 	"archive/zip"
+	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
@@ -24,8 +27,143 @@ import (
    ===============
 */
 
-// Compress 创建给定目录和文件的压缩文件
+// 打包下载支持的归档格式
+const (
+	ArchiveFormatZip   = "zip"
+	ArchiveFormatTar   = "tar"
+	ArchiveFormatTarGz = "tar.gz"
+)
+
+// IsArchiveFormatSupported 返回是否支持以 format 格式打包下载
+func IsArchiveFormatSupported(format string) bool {
+	return format == ArchiveFormatZip || format == ArchiveFormatTar || format == ArchiveFormatTarGz
+}
+
+// archiveWriter 将文件及目录依次写入压缩包
+type archiveWriter interface {
+	// CreateFile 写入文件头，返回用于写入文件内容的 Writer
+	CreateFile(file *model.File) (io.Writer, error)
+	// CreateFolder 写入目录项
+	CreateFolder(folder *model.Folder) error
+	Flush() error
+	Close() error
+}
+
+// zipArchiveWriter zip 格式的压缩包，不写入目录项
+type zipArchiveWriter struct {
+	w      *zip.Writer
+	method uint16
+}
+
+func (z *zipArchiveWriter) CreateFile(file *model.File) (io.Writer, error) {
+	return z.w.CreateHeader(&zip.FileHeader{
+		Name:               filepath.FromSlash(path.Join(file.Position, file.Name)),
+		Modified:           file.UpdatedAt,
+		UncompressedSize64: file.Size,
+		Method:             z.method,
+	})
+}
+
+func (z *zipArchiveWriter) CreateFolder(folder *model.Folder) error {
+	return nil
+}
+
+func (z *zipArchiveWriter) Flush() error {
+	return z.w.Flush()
+}
+
+func (z *zipArchiveWriter) Close() error {
+	return z.w.Close()
+}
+
+// tarArchiveWriter tar 格式的归档，gz 不为空时使用 gzip 压缩
+type tarArchiveWriter struct {
+	w  *tar.Writer
+	gz *gzip.Writer
+}
+
+func newTarArchiveWriter(writer io.Writer, gzipped bool) *tarArchiveWriter {
+	res := &tarArchiveWriter{}
+	if gzipped {
+		res.gz = gzip.NewWriter(writer)
+		writer = res.gz
+	}
+
+	res.w = tar.NewWriter(writer)
+	return res
+}
+
+func (t *tarArchiveWriter) CreateFile(file *model.File) (io.Writer, error) {
+	err := t.w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     path.Join(file.Position, file.Name),
+		Mode:     0644,
+		Size:     int64(file.Size),
+		ModTime:  file.UpdatedAt,
+	})
+	return t.w, err
+}
+
+func (t *tarArchiveWriter) CreateFolder(folder *model.Folder) error {
+	return t.w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     path.Join(folder.Position, folder.Name) + "/",
+		Mode:     0755,
+		ModTime:  folder.UpdatedAt,
+	})
+}
+
+func (t *tarArchiveWriter) Flush() error {
+	if err := t.w.Flush(); err != nil {
+		return err
+	}
+
+	if t.gz != nil {
+		return t.gz.Flush()
+	}
+
+	return nil
+}
+
+func (t *tarArchiveWriter) Close() error {
+	if err := t.w.Close(); err != nil {
+		return err
+	}
+
+	if t.gz != nil {
+		return t.gz.Close()
+	}
+
+	return nil
+}
+
+// Compress 创建给定目录和文件的 zip 压缩文件，isArchive 为 true 时仅归档不压缩
 func (fs *FileSystem) Compress(ctx context.Context, writer io.Writer, folderIDs, fileIDs []uint, isArchive bool) error {
+	method := zip.Deflate
+	if isArchive {
+		method = zip.Store
+	}
+
+	return fs.compress(ctx, writer, func() archiveWriter {
+		return &zipArchiveWriter{w: zip.NewWriter(writer), method: method}
+	}, folderIDs, fileIDs)
+}
+
+// CompressAs 以 format 格式创建给定目录和文件的归档，用于打包下载。
+// tar 格式保留目录项，文件及目录分别使用 0644、0755 权限，修改时间为其更新时间
+func (fs *FileSystem) CompressAs(ctx context.Context, writer io.Writer, format string, folderIDs, fileIDs []uint) error {
+	switch format {
+	case ArchiveFormatTar, ArchiveFormatTarGz:
+		return fs.compress(ctx, writer, func() archiveWriter {
+			return newTarArchiveWriter(writer, format == ArchiveFormatTarGz)
+		}, folderIDs, fileIDs)
+	default:
+		return fs.Compress(ctx, writer, folderIDs, fileIDs, true)
+	}
+}
+
+// compress 将给定目录和文件写入 newArchive 创建的压缩包
+func (fs *FileSystem) compress(ctx context.Context, writer io.Writer, newArchive func() archiveWriter, folderIDs, fileIDs []uint) error {
 	// 查找待压缩目录
 	folders, err := model.GetFoldersByIDs(folderIDs, fs.User.ID)
 	if err != nil && len(folderIDs) != 0 {
@@ -70,37 +208,33 @@ func (fs *FileSystem) Compress(ctx context.Context, writer io.Writer, folderIDs,
 		files[i].Position = ""
 	}
 
-	// 创建压缩文件Writer
-	zipWriter := zip.NewWriter(writer)
-	defer zipWriter.Close()
-
-	ctx = reqContext
+	// 创建压缩文件Writer，各文件依次从存储端读取并写入，不在本机缓存整个压缩包
+	archive := newArchive()
+	defer archive.Close()
 
 	// 压缩各个目录及文件
 	for i := 0; i < len(folders); i++ {
-		select {
-		case <-reqContext.Done():
-			// 取消压缩请求
-			return ErrClientCanceled
-		default:
-			fs.doCompress(reqContext, nil, &folders[i], zipWriter, isArchive)
+		if err := fs.doCompress(reqContext, nil, &folders[i], archive, writer); err != nil {
+			return err
 		}
-
 	}
 	for i := 0; i < len(files); i++ {
-		select {
-		case <-reqContext.Done():
-			// 取消压缩请求
-			return ErrClientCanceled
-		default:
-			fs.doCompress(reqContext, &files[i], nil, zipWriter, isArchive)
+		if err := fs.doCompress(reqContext, &files[i], nil, archive, writer); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func (fs *FileSystem) doCompress(ctx context.Context, file *model.File, folder *model.Folder, zipWriter *zip.Writer, isArchive bool) {
+// doCompress 将文件或目录写入压缩包，无法读取的文件会被跳过；
+// 写入失败时压缩包已不完整，返回错误以中止后续文件的读取
+func (fs *FileSystem) doCompress(ctx context.Context, file *model.File, folder *model.Folder, archive archiveWriter, writer io.Writer) error {
+	// 取消压缩请求
+	if ctx.Err() != nil {
+		return ErrClientCanceled
+	}
+
 	// 如果对象是文件
 	if file != nil {
 		// 切换上传策略
@@ -108,7 +242,7 @@ func (fs *FileSystem) doCompress(ctx context.Context, file *model.File, folder *
 		err := fs.DispatchHandler()
 		if err != nil {
 			util.Log().Warning("Failed to compress file %q: %s", file.Name, err)
-			return
+			return nil
 		}
 
 		// 获取文件内容
@@ -118,39 +252,45 @@ func (fs *FileSystem) doCompress(ctx context.Context, file *model.File, folder *
 		)
 		if err != nil {
 			util.Log().Debug("Failed to open %q: %s", file.Name, err)
-			return
-		}
-		if closer, ok := fileToZip.(io.Closer); ok {
-			defer closer.Close()
+			return nil
 		}
+		defer fileToZip.Close()
 
 		// 创建压缩文件头
-		header := &zip.FileHeader{
-			Name:               filepath.FromSlash(path.Join(file.Position, file.Name)),
-			Modified:           file.UpdatedAt,
-			UncompressedSize64: file.Size,
+		entry, err := archive.CreateFile(file)
+		if err != nil {
+			return err
 		}
 
-		// 指定是压缩还是归档
-		if isArchive {
-			header.Method = zip.Store
-		} else {
-			header.Method = zip.Deflate
-		}
+		if _, err := io.Copy(entry, fileToZip); err != nil {
+			if ctx.Err() != nil {
+				return ErrClientCanceled
+			}
 
-		writer, err := zipWriter.CreateHeader(header)
-		if err != nil {
-			return
+			util.Log().Warning("Failed to compress file %q: %s", file.Name, err)
+			return err
 		}
 
-		_, err = io.Copy(writer, fileToZip)
+		// 每个文件写入完成后立即发送给客户端
+		if err := archive.Flush(); err != nil {
+			return err
+		}
+		if flusher, ok := writer.(http.Flusher); ok {
+			flusher.Flush()
+		}
 	} else if folder != nil {
 		// 对象是目录
+		if err := archive.CreateFolder(folder); err != nil {
+			return err
+		}
+
 		// 获取子文件
 		subFiles, err := folder.GetChildFiles()
 		if err == nil && len(subFiles) > 0 {
 			for i := 0; i < len(subFiles); i++ {
-				fs.doCompress(ctx, &subFiles[i], nil, zipWriter, isArchive)
+				if err := fs.doCompress(ctx, &subFiles[i], nil, archive, writer); err != nil {
+					return err
+				}
 			}
 
 		}
@@ -158,10 +298,14 @@ func (fs *FileSystem) doCompress(ctx context.Context, file *model.File, folder *
 		subFolders, err := folder.GetChildFolder()
 		if err == nil && len(subFolders) > 0 {
 			for i := 0; i < len(subFolders); i++ {
-				fs.doCompress(ctx, nil, &subFolders[i], zipWriter, isArchive)
+				if err := fs.doCompress(ctx, nil, &subFolders[i], archive, writer); err != nil {
+					return err
+				}
 			}
 		}
 	}
+
+	return nil
 }
 
 // Decompress 解压缩给定压缩文件到dst目录
@@ -189,20 +333,6 @@ func (fs *FileSystem) Decompress(ctx context.Context, src, dst, encoding string)
 
 	defer fileStream.Close()
 
-	tempZipFilePath = filepath.Join(
-		util.RelativePath(model.GetSettingByName("temp_path")),
-		"decompress",
-		fmt.Sprintf("archive_%d.zip", time.Now().UnixNano()),
-	)
-
-	zipFile, err := util.CreatNestedFile(tempZipFilePath)
-	if err != nil {
-		util.Log().Warning("Failed to create temp archive file %q: %s", tempZipFilePath, err)
-		tempZipFilePath = ""
-		return err
-	}
-	defer zipFile.Close()
-
 	// 下载前先判断是否是可解压的格式
 	format, readStream, err := archiver.Identify(fs.FileTarget[0].SourceName, fileStream)
 	if err != nil {
@@ -223,9 +353,30 @@ func (fs *FileSystem) Decompress(ctx context.Context, src, dst, encoding string)
 		isZip = true
 	}
 
-	// 除了zip必须下载到本地，其余的可以边下载边解压
+	// 除了zip必须下载到本地，其余的可以边下载边解压；存储端支持任意位置
+	// 读取时，zip 也可直接读取
 	reader := readStream
-	if isZip {
+	if isZip && fs.Handler.Capabilities().RangeGet {
+		if _, err := fileStream.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		reader = &seekReaderAt{rs: fileStream}
+	} else if isZip {
+		tempZipFilePath = filepath.Join(
+			util.RelativePath(model.GetSettingByName("temp_path")),
+			"decompress",
+			fmt.Sprintf("archive_%d.zip", time.Now().UnixNano()),
+		)
+
+		zipFile, err := util.CreatNestedFile(tempZipFilePath)
+		if err != nil {
+			util.Log().Warning("Failed to create temp archive file %q: %s", tempZipFilePath, err)
+			tempZipFilePath = ""
+			return err
+		}
+		defer zipFile.Close()
+
 		_, err = io.Copy(zipFile, readStream)
 		if err != nil {
 			util.Log().Warning("Failed to write temp archive file %q: %s", tempZipFilePath, err)
@@ -314,3 +465,40 @@ func (fs *FileSystem) Decompress(ctx context.Context, src, dst, encoding string)
 	return err
 
 }
+
+// seekReaderAt 将可定位的文件流包装为 io.ReaderAt，供 zip 解压使用
+type seekReaderAt struct {
+	mu sync.Mutex
+	rs io.ReadSeeker
+}
+
+// Read 从当前位置读取
+func (r *seekReaderAt) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rs.Read(p)
+}
+
+// Seek 定位文件流
+func (r *seekReaderAt) Seek(offset int64, whence int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rs.Seek(offset, whence)
+}
+
+// ReadAt 从 off 处读取，多个文件并行解压时由锁保证读取位置不被打乱
+func (r *seekReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	n, err := io.ReadFull(r.rs, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+
+	return n, err
+}
@@ -1,12 +1,16 @@
 package filesystem
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"errors"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	testMock "github.com/stretchr/testify/mock"
 	"io"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -40,7 +44,7 @@ func TestFileSystem_Compress(t *testing.T) {
 			WillReturnRows(
 				sqlmock.NewRows(
 					[]string{"id", "name", "source_name", "policy_id"}).
-					AddRow(1, "1.txt", "tests/file1.txt", 1),
+					AddRow(1, "1.txt", Path("tests/file1.txt"), 1),
 			)
 		asserts.NoError(cache.Set("setting_temp_path", "tests", -1))
 		// 查找父目录子文件
@@ -56,15 +60,41 @@ func TestFileSystem_Compress(t *testing.T) {
 			WithArgs(2).
 			WillReturnRows(
 				sqlmock.NewRows([]string{"id", "name", "source_name", "policy_id"}).
-					AddRow(2, "2.txt", "tests/file2.txt", 1),
+					AddRow(2, "2.txt", Path("tests/file2.txt"), 1),
 			)
 		// 查找上传策略
 		asserts.NoError(cache.Set("policy_1", model.Policy{Type: "local"}, -1))
-		w := &bytes.Buffer{}
+		w := httptest.NewRecorder()
 
 		err := fs.Compress(ctx, w, []uint{1}, []uint{1}, true)
 		asserts.NoError(err)
-		asserts.NotEmpty(w.Len())
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.True(w.Flushed)
+
+		reader, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		asserts.NoError(err)
+		asserts.Len(reader.File, 2)
+		asserts.Equal(filepath.FromSlash("parent/sub/2.txt"), reader.File[0].Name)
+		asserts.Equal("1.txt", reader.File[1].Name)
+	}
+
+	// 写入失败时中止
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, 2, 1).
+			WillReturnRows(
+				sqlmock.NewRows(
+					[]string{"id", "name", "source_name", "policy_id"}).
+					AddRow(1, "1.txt", Path("tests/file1.txt"), 1).
+					AddRow(2, "2.txt", Path("tests/file2.txt"), 1),
+			)
+		asserts.NoError(cache.Set("policy_1", model.Policy{Type: "local"}, -1))
+		w := &failWriter{}
+
+		err := fs.Compress(ctx, w, nil, []uint{1, 2}, true)
+		asserts.Error(err)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(1, w.calls)
 	}
 
 	// 上下文取消
@@ -119,6 +149,71 @@ func TestFileSystem_Compress(t *testing.T) {
 
 }
 
+func TestFileSystem_CompressAs(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	fs := FileSystem{
+		User: &model.User{Model: gorm.Model{ID: 1}},
+	}
+
+	asserts.True(IsArchiveFormatSupported(ArchiveFormatTarGz))
+	asserts.False(IsArchiveFormatSupported("rar"))
+
+	// tar.gz
+	{
+		// 查找压缩父目录
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "parent"))
+		// 查找顶级待压缩文件
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		// 查找父目录子文件
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "name", "source_name", "policy_id", "size"}).
+					AddRow(2, "2.txt", Path("tests/file2.txt"), 1, 0),
+			)
+		// 查找子目录
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+		asserts.NoError(cache.Set("policy_1", model.Policy{Type: "local"}, -1))
+		w := httptest.NewRecorder()
+
+		err := fs.CompressAs(ctx, w, ArchiveFormatTarGz, []uint{1}, []uint{1})
+		asserts.NoError(err)
+		asserts.NoError(mock.ExpectationsWereMet())
+
+		gz, err := gzip.NewReader(bytes.NewReader(w.Body.Bytes()))
+		asserts.NoError(err)
+		reader := tar.NewReader(gz)
+		header, err := reader.Next()
+		asserts.NoError(err)
+		asserts.Equal("parent/", header.Name)
+		asserts.Equal(byte(tar.TypeDir), header.Typeflag)
+		asserts.EqualValues(0755, header.Mode)
+		header, err = reader.Next()
+		asserts.NoError(err)
+		asserts.Equal("parent/2.txt", header.Name)
+		asserts.EqualValues(0644, header.Mode)
+		_, err = reader.Next()
+		asserts.Equal(io.EOF, err)
+	}
+}
+
+// failWriter 写入总是失败，记录写入次数
+type failWriter struct {
+	calls int
+}
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	w.calls++
+	return 0, errors.New("write error")
+}
+
 type MockNopRSC string
 
 func (m MockNopRSC) Read(b []byte) (int, error) {
@@ -254,3 +349,24 @@ func TestFileSystem_Decompress(t *testing.T) {
 		testHandler.AssertExpectations(t)
 	}
 }
+
+func TestSeekReaderAt(t *testing.T) {
+	a := assert.New(t)
+	r := &seekReaderAt{rs: strings.NewReader("0123456789")}
+
+	buf := make([]byte, 4)
+	n, err := r.ReadAt(buf, 3)
+	a.NoError(err)
+	a.Equal(4, n)
+	a.Equal("3456", string(buf))
+
+	// 读取到末尾
+	n, err = r.ReadAt(buf, 8)
+	a.Equal(io.EOF, err)
+	a.Equal(2, n)
+	a.Equal("89", string(buf[:n]))
+
+	size, err := r.Seek(0, io.SeekEnd)
+	a.NoError(err)
+	a.EqualValues(10, size)
+}
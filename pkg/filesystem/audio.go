@@ -0,0 +1,87 @@
+package filesystem
+
+import (
+	"context"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/audiotag"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+/* ================
+     音频标签相关
+   ================
+*/
+
+// GetAudioTags 获取音频文件的标题、艺术家、专辑信息，首次请求时解析文件内容并保存到文件元数据中
+func (fs *FileSystem) GetAudioTags(ctx context.Context, id uint) (*model.AudioTags, error) {
+	err := fs.resetFileIDIfNotExist(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &fs.FileTarget[0]
+	if tags, ok := file.AudioTags(); ok {
+		return tags, nil
+	}
+
+	parsed, err := fs.readAudioTags(ctx, file)
+	if err != nil && err != audiotag.ErrNoTags && err != audiotag.ErrInvalidFile {
+		return nil, err
+	}
+
+	// 无法解析的文件同样记录为空标签，避免重复读取
+	res := &model.AudioTags{}
+	if parsed != nil {
+		res.Title = parsed.Title
+		res.Artist = parsed.Artist
+		res.Album = parsed.Album
+		res.Cover = parsed.Picture != nil
+	}
+
+	if err := file.UpdateAudioTags(res); err != nil {
+		util.Log().Warning("Failed to save audio tags of file %q: %s", file.Name, err)
+	}
+
+	return res, nil
+}
+
+// GetAudioCover 获取音频文件内嵌的封面图片
+func (fs *FileSystem) GetAudioCover(ctx context.Context, id uint) (*audiotag.Picture, error) {
+	err := fs.resetFileIDIfNotExist(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &fs.FileTarget[0]
+	if tags, ok := file.AudioTags(); ok && !tags.Cover {
+		return nil, ErrObjectNotExist
+	}
+
+	parsed, err := fs.readAudioTags(ctx, file)
+	if err == audiotag.ErrNoTags || err == audiotag.ErrInvalidFile || (err == nil && parsed.Picture == nil) {
+		return nil, ErrObjectNotExist
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parsed.Picture, nil
+}
+
+// readAudioTags 读取文件内容并解析音频标签
+func (fs *FileSystem) readAudioTags(ctx context.Context, file *model.File) (*audiotag.Tags, error) {
+	if !audiotag.Supported(file.Name) {
+		return nil, ErrAudioNotSupported
+	}
+
+	rs, err := fs.Handler.Get(context.WithValue(ctx, fsctx.FileModelCtx, *file), file.SourceName)
+	if err != nil {
+		return nil, ErrIO.WithError(err)
+	}
+	defer rs.Close()
+
+	return audiotag.Read(file.Name, rs)
+}
@@ -0,0 +1,119 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+)
+
+func TestFileSystem_GetAudioTags(t *testing.T) {
+	a := assert.New(t)
+	id3 := "ID3\x03\x00\x00\x00\x00\x00\x0fTIT2\x00\x00\x00\x05\x00\x00\x00Song"
+
+	newFs := func(handler *FileHeaderMock, name string, metadata map[string]string) *FileSystem {
+		fs := &FileSystem{User: &model.User{}, Handler: handler}
+		fs.SetTargetFile(&[]model.File{{
+			Name:               name,
+			SourceName:         name,
+			MetadataSerialized: metadata,
+			Policy:             model.Policy{Type: "mock"},
+		}})
+		fs.FileTarget[0].Policy.ID = 1
+		return fs
+	}
+
+	// 已解析过，不读取文件
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := newFs(mockHandler, "1.mp3", map[string]string{model.AudioTagsMetadataKey: `{"title":"Cached"}`})
+		res, err := fs.GetAudioTags(context.Background(), 1)
+		a.NoError(err)
+		a.Equal("Cached", res.Title)
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 不支持的格式
+	{
+		fs := newFs(&FileHeaderMock{}, "1.wav", nil)
+		_, err := fs.GetAudioTags(context.Background(), 1)
+		a.ErrorIs(err, ErrAudioNotSupported)
+	}
+
+	// 无法读取文件
+	{
+		mockHandler := &FileHeaderMock{}
+		mockHandler.On("Get", testMock.Anything, "1.mp3").Return(MockRSC{}, errors.New("error"))
+		fs := newFs(mockHandler, "1.mp3", nil)
+		_, err := fs.GetAudioTags(context.Background(), 1)
+		a.Error(err)
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 解析并保存
+	{
+		mockHandler := &FileHeaderMock{}
+		mockHandler.On("Get", testMock.Anything, "1.mp3").Return(MockRSC{rs: strings.NewReader(id3)}, nil)
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		fs := newFs(mockHandler, "1.mp3", nil)
+		res, err := fs.GetAudioTags(context.Background(), 1)
+		a.NoError(err)
+		a.NoError(mock.ExpectationsWereMet())
+		mockHandler.AssertExpectations(t)
+		a.Equal(&model.AudioTags{Title: "Song"}, res)
+		a.Contains(fs.FileTarget[0].MetadataSerialized[model.AudioTagsMetadataKey], "Song")
+	}
+}
+
+func TestFileSystem_GetAudioCover(t *testing.T) {
+	a := assert.New(t)
+	id3 := "ID3\x03\x00\x00\x00\x00\x00\x1fAPIC\x00\x00\x00\x15\x00\x00\x00image/png\x00\x03\x00\x89PNG\r\n\x1a\n"
+
+	newFs := func(handler *FileHeaderMock, metadata map[string]string) *FileSystem {
+		fs := &FileSystem{User: &model.User{}, Handler: handler}
+		fs.SetTargetFile(&[]model.File{{
+			Name:               "1.mp3",
+			SourceName:         "1.mp3",
+			MetadataSerialized: metadata,
+			Policy:             model.Policy{Type: "mock"},
+		}})
+		fs.FileTarget[0].Policy.ID = 1
+		return fs
+	}
+
+	// 已知无封面
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := newFs(mockHandler, map[string]string{model.AudioTagsMetadataKey: `{"cover":false}`})
+		_, err := fs.GetAudioCover(context.Background(), 1)
+		a.ErrorIs(err, ErrObjectNotExist)
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 文件中无标签
+	{
+		mockHandler := &FileHeaderMock{}
+		mockHandler.On("Get", testMock.Anything, "1.mp3").Return(MockRSC{rs: strings.NewReader("audio frames")}, nil)
+		_, err := newFs(mockHandler, nil).GetAudioCover(context.Background(), 1)
+		a.ErrorIs(err, ErrObjectNotExist)
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 成功
+	{
+		mockHandler := &FileHeaderMock{}
+		mockHandler.On("Get", testMock.Anything, "1.mp3").Return(MockRSC{rs: strings.NewReader(id3)}, nil)
+		res, err := newFs(mockHandler, nil).GetAudioCover(context.Background(), 1)
+		a.NoError(err)
+		mockHandler.AssertExpectations(t)
+		a.Equal("image/png", res.MimeType)
+		a.Equal("\x89PNG\r\n\x1a\n", string(res.Data))
+	}
+}
@@ -0,0 +1,67 @@
+package filesystem
+
+import (
+	"context"
+	"path"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// IsCameraUpload 返回上传是否为相机上传模式
+func IsCameraUpload(ctx context.Context) bool {
+	camera, _ := ctx.Value(fsctx.CameraUploadCtx).(bool)
+	return camera
+}
+
+// captureDate 返回文件的拍摄日期，无 EXIF 拍摄时间时依次使用文件的最后修改日期及当前时间
+func captureDate(file *model.File, fileInfo *fsctx.UploadTaskInfo) time.Time {
+	if photo, err := model.GetPhotoByFileID(file.ID); err == nil && photo.TakenAt != nil {
+		return *photo.TakenAt
+	}
+
+	if fileInfo.LastModified != nil {
+		return *fileInfo.LastModified
+	}
+
+	return time.Now()
+}
+
+// HookFileByCaptureDate 相机上传模式下，将上传完成的文件按拍摄日期移入上传目录下的 年/月 子目录，
+// 子目录中已有同名文件时重命名。需在 HookExtractPhotoMetadata 之后执行，归档失败时文件保留在上传目录
+func HookFileByCaptureDate(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+	fileInfo := fileHeader.Info()
+	file, ok := fileInfo.Model.(*model.File)
+	if !ok {
+		return nil
+	}
+
+	takenAt := captureDate(file, fileInfo)
+	dst := path.Join(fileInfo.VirtualPath, takenAt.Format("2006"), takenAt.Format("01"))
+	dstFolder, err := fs.CreateDirectory(ctx, dst)
+	if err != nil {
+		util.Log().Warning("Failed to create camera upload folder %q: %s", dst, err)
+		return nil
+	}
+
+	name := file.Name
+	if exist, _ := fs.IsChildFileExist(dstFolder, name); exist {
+		if name, err = fs.AvailableName(dstFolder, name); err != nil {
+			util.Log().Warning("Failed to find available name for %q in %q: %s", file.Name, dst, err)
+			return nil
+		}
+	}
+
+	moveCtx := context.WithValue(ctx, fsctx.WebdavDstName, name)
+	if err := fs.Move(moveCtx, nil, []uint{file.ID}, fileInfo.VirtualPath, dst); err != nil {
+		util.Log().Warning("Failed to move %q into camera upload folder %q: %s", file.Name, dst, err)
+		return nil
+	}
+
+	file.FolderID = dstFolder.ID
+	file.Name = name
+	fileHeader.SetName(name)
+	return nil
+}
@@ -0,0 +1,60 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsCameraUpload(t *testing.T) {
+	a := assert.New(t)
+	a.False(IsCameraUpload(context.Background()))
+	a.True(IsCameraUpload(context.WithValue(context.Background(), fsctx.CameraUploadCtx, true)))
+}
+
+func TestCaptureDate(t *testing.T) {
+	a := assert.New(t)
+	file := &model.File{Model: gorm.Model{ID: 1}}
+	takenAt := time.Date(2021, 5, 3, 10, 0, 0, 0, time.UTC)
+	lastModified := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// 使用 EXIF 拍摄时间
+	{
+		mock.ExpectQuery("SELECT(.+)photos(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "file_id", "taken_at"}).AddRow(1, 1, takenAt))
+		res := captureDate(file, &fsctx.UploadTaskInfo{LastModified: &lastModified})
+		a.NoError(mock.ExpectationsWereMet())
+		a.True(takenAt.Equal(res))
+	}
+
+	// 无 EXIF 时使用最后修改日期
+	{
+		mock.ExpectQuery("SELECT(.+)photos(.+)").WithArgs(1).WillReturnError(errors.New("not found"))
+		res := captureDate(file, &fsctx.UploadTaskInfo{LastModified: &lastModified})
+		a.NoError(mock.ExpectationsWereMet())
+		a.True(lastModified.Equal(res))
+	}
+
+	// 使用当前时间
+	{
+		mock.ExpectQuery("SELECT(.+)photos(.+)").WithArgs(1).WillReturnError(errors.New("not found"))
+		res := captureDate(file, &fsctx.UploadTaskInfo{})
+		a.NoError(mock.ExpectationsWereMet())
+		a.WithinDuration(time.Now(), res, time.Minute)
+	}
+}
+
+func TestHookFileByCaptureDate(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+
+	// 无文件记录
+	a.NoError(HookFileByCaptureDate(context.Background(), fs, &fsctx.FileStream{}))
+}
@@ -0,0 +1,380 @@
+package filesystem
+
+import (
+	"context"
+	"path"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+/* ================
+	 增量同步相关
+   ================
+*/
+
+// DeltaEntry 增量同步结果中的一个目录或文件
+type DeltaEntry struct {
+	Path      string    `json:"path"`
+	Type      string    `json:"type"`
+	Action    string    `json:"action"`
+	Size      uint64    `json:"size,omitempty"`
+	SHA256    string    `json:"sha256,omitempty"`
+	MD5       string    `json:"md5,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Delta 游标之后的变更。删除目录表示删除其下全部内容，新建的目录会同时列出其下现有的内容
+type Delta struct {
+	Entries []DeltaEntry `json:"entries"`
+	Cursor  uint         `json:"cursor"`
+	HasMore bool         `json:"has_more"`
+}
+
+// LatestChangeCursor 返回当前最新的变更游标，客户端完整同步前获取，之后以此请求增量变更
+func LatestChangeCursor() uint {
+	_, last := model.GetChangeCursorRange()
+	return last
+}
+
+// Delta 列出当前用户在 cursor 之后的至多 limit 条变更记录，同一路径只保留最后一次变更，
+// 文件的大小与摘要取自其当前状态
+func (fs *FileSystem) Delta(ctx context.Context, cursor uint, limit int) (*Delta, error) {
+	// 游标之后的记录已被清理，或游标不存在时，客户端需重新完整同步
+	first, last := model.GetChangeCursorRange()
+	if cursor > last || (first > 0 && cursor+1 < first) {
+		return nil, ErrChangeCursorExpired
+	}
+
+	changes, err := model.ListChangesAfter(fs.User.ID, cursor, limit)
+	if err != nil {
+		return nil, ErrDBListObjects.WithError(err)
+	}
+
+	res := &Delta{Cursor: last, Entries: []DeltaEntry{}}
+	if len(changes) > 0 && changes[len(changes)-1].ID > last {
+		res.Cursor = changes[len(changes)-1].ID
+	}
+	if len(changes) >= limit {
+		res.HasMore = true
+		res.Cursor = changes[len(changes)-1].ID
+	}
+
+	// 批量查找变更对象的当前状态
+	var fileIDs, folderIDs []uint
+	for _, change := range changes {
+		if change.Action == model.ChangeDeleted || change.ObjectID == 0 {
+			continue
+		}
+
+		if change.IsDir {
+			folderIDs = append(folderIDs, change.ObjectID)
+		} else {
+			fileIDs = append(fileIDs, change.ObjectID)
+		}
+	}
+
+	files := make(map[uint]*model.File)
+	if len(fileIDs) > 0 {
+		fileModels, err := model.GetFilesByIDs(fileIDs, fs.User.ID)
+		if err != nil {
+			return nil, ErrDBListObjects.WithError(err)
+		}
+		for i := range fileModels {
+			files[fileModels[i].ID] = &fileModels[i]
+		}
+	}
+
+	folders := make(map[uint]*model.Folder)
+	if len(folderIDs) > 0 {
+		folderModels, err := model.GetFoldersByIDs(folderIDs, fs.User.ID)
+		if err != nil {
+			return nil, ErrDBListObjects.WithError(err)
+		}
+		for i := range folderModels {
+			folders[folderModels[i].ID] = &folderModels[i]
+		}
+	}
+
+	builder := newDeltaBuilder()
+	for _, change := range changes {
+		if change.Action == model.ChangeDeleted {
+			entryType := "file"
+			if change.IsDir {
+				entryType = "dir"
+			}
+			builder.add(DeltaEntry{
+				Path:      change.Path,
+				Type:      entryType,
+				Action:    model.ChangeDeleted,
+				UpdatedAt: change.CreatedAt,
+			})
+			continue
+		}
+
+		if !change.IsDir {
+			file, ok := files[change.ObjectID]
+			if change.ObjectID == 0 {
+				ok, file = fs.IsFileExist(change.Path)
+			}
+
+			// 已被删除的文件，其删除会在之后的记录中体现
+			if !ok || file.UploadSessionID != nil {
+				continue
+			}
+
+			builder.addFile(change.Action, change.Path, file)
+			continue
+		}
+
+		folder, ok := folders[change.ObjectID]
+		if change.ObjectID == 0 {
+			ok, folder = fs.IsPathExist(change.Path)
+		}
+		if !ok || folder.OwnerID != fs.User.ID {
+			continue
+		}
+
+		builder.add(DeltaEntry{
+			Path:      change.Path,
+			Type:      "dir",
+			Action:    change.Action,
+			UpdatedAt: folder.UpdatedAt,
+		})
+
+		if change.Action == model.ChangeCreated && !folder.IsMount() {
+			if err := fs.expandDeltaFolder(builder, folder, change.Path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	res.Entries = builder.entries()
+	return res, nil
+}
+
+// expandDeltaFolder 将新建目录下现有的目录和文件作为新建的对象加入结果
+func (fs *FileSystem) expandDeltaFolder(builder *deltaBuilder, folder *model.Folder, folderPath string) error {
+	folders, err := model.GetRecursiveChildFolder([]uint{folder.ID}, fs.User.ID, true)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	paths := make(map[uint]string, len(folders))
+	for _, child := range folders {
+		if child.ID == folder.ID {
+			paths[child.ID] = folderPath
+			continue
+		}
+
+		paths[child.ID] = path.Join(paths[*child.ParentID], child.Name)
+		builder.add(DeltaEntry{
+			Path:      paths[child.ID],
+			Type:      "dir",
+			Action:    model.ChangeCreated,
+			UpdatedAt: child.UpdatedAt,
+		})
+	}
+
+	files, err := model.GetChildFilesOfFolders(&folders)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	for i := range files {
+		if files[i].UploadSessionID == nil {
+			builder.addFile(model.ChangeCreated, path.Join(paths[files[i].FolderID], files[i].Name), &files[i])
+		}
+	}
+
+	return nil
+}
+
+// deltaBuilder 按变更顺序整理结果，同一路径只保留最后一次出现的位置
+type deltaBuilder struct {
+	list  []*DeltaEntry
+	index map[string]int
+}
+
+func newDeltaBuilder() *deltaBuilder {
+	return &deltaBuilder{index: make(map[string]int)}
+}
+
+func (b *deltaBuilder) add(entry DeltaEntry) {
+	if i, ok := b.index[entry.Path]; ok {
+		b.list[i] = nil
+	}
+
+	b.index[entry.Path] = len(b.list)
+	b.list = append(b.list, &entry)
+}
+
+func (b *deltaBuilder) addFile(action, filePath string, file *model.File) {
+	b.add(DeltaEntry{
+		Path:      filePath,
+		Type:      "file",
+		Action:    action,
+		Size:      file.Size,
+		SHA256:    file.Hash,
+		MD5:       file.MD5,
+		UpdatedAt: file.UpdatedAt,
+	})
+}
+
+func (b *deltaBuilder) entries() []DeltaEntry {
+	res := make([]DeltaEntry, 0, len(b.index))
+	for _, entry := range b.list {
+		if entry != nil {
+			res = append(res, *entry)
+		}
+	}
+	return res
+}
+
+// RecordChanges 保存变更记录，失败时只记录日志，不影响操作本身
+func RecordChanges(changes ...model.Change) {
+	if len(changes) == 0 {
+		return
+	}
+
+	if err := model.CreateChanges(changes); err != nil {
+		util.Log().Warning("Failed to record changes: %s", err)
+	}
+}
+
+// folderFullPath 返回已追溯至根目录的目录的完整路径
+func folderFullPath(folder *model.Folder) string {
+	if folder.ParentID == nil {
+		return "/"
+	}
+	return path.Join(folder.Position, folder.Name)
+}
+
+// folderPaths 返回目录的完整路径，已不存在或已不在用户目录树中（如位于回收站中）的目录不包含在结果内
+func folderPaths(ids []uint, owner uint) map[uint]string {
+	res := make(map[uint]string, len(ids))
+	folders, err := model.GetFoldersByIDs(ids, owner)
+	if err != nil {
+		util.Log().Warning("Failed to list folders for changes: %s", err)
+		return res
+	}
+
+	for i := range folders {
+		if err := folders[i].TraceRoot(); err == nil {
+			res[folders[i].ID] = folderFullPath(&folders[i])
+		}
+	}
+
+	return res
+}
+
+// changeTargets 查找 owner 的目录 dirs 和文件 files，生成以对象名称为路径的变更记录模板
+func (fs *FileSystem) changeTargets(owner uint, dirs, files []uint) []model.Change {
+	targets := make([]model.Change, 0, len(dirs)+len(files))
+	if len(dirs) > 0 {
+		folders, err := model.GetFoldersByIDs(dirs, owner)
+		if err != nil {
+			util.Log().Warning("Failed to list folders for changes: %s", err)
+		}
+
+		for i := range folders {
+			targets = append(targets, model.NewFolderChange("", &folders[i], folders[i].Name))
+		}
+	}
+
+	if len(files) > 0 {
+		fileModels, err := model.GetFilesByIDs(files, owner)
+		if err != nil {
+			util.Log().Warning("Failed to list files for changes: %s", err)
+		}
+
+		for i := range fileModels {
+			targets = append(targets, model.NewFileChange("", &fileModels[i], fileModels[i].Name))
+		}
+	}
+
+	return targets
+}
+
+// placeChanges 将变更记录模板置于 parent 目录下，name 不为空时替代对象名称；
+// copied 为 true 时对象为新建的副本，不沿用原对象的 ID
+func (fs *FileSystem) placeChanges(targets []model.Change, action, parent, name string, copied bool) []model.Change {
+	changes := make([]model.Change, 0, len(targets))
+	for _, target := range targets {
+		change := target
+		change.UserID = fs.User.ID
+		change.Action = action
+		if name != "" {
+			change.Path = name
+		}
+		change.Path = path.Join(parent, change.Path)
+		if copied {
+			change.ObjectID = 0
+		}
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// deletedChanges 生成目录和文件被删除的变更记录，位于被删除目录中的对象不单独记录
+func (fs *FileSystem) deletedChanges(folders []model.Folder, files []*model.File) []model.Change {
+	deletedFolders := make(map[uint]bool, len(folders))
+	for i := range folders {
+		deletedFolders[folders[i].ID] = true
+	}
+
+	parents := make([]uint, 0)
+	for i := range folders {
+		if folders[i].ParentID != nil && !deletedFolders[*folders[i].ParentID] {
+			parents = append(parents, *folders[i].ParentID)
+		}
+	}
+	for _, file := range files {
+		if !deletedFolders[file.FolderID] {
+			parents = append(parents, file.FolderID)
+		}
+	}
+
+	if len(parents) == 0 {
+		return nil
+	}
+
+	// 无法确定所在目录路径的对象（如回收站中的对象）此前已记录删除
+	paths := folderPaths(parents, fs.User.ID)
+	changes := make([]model.Change, 0, len(parents))
+	for i := range folders {
+		if folders[i].ParentID == nil || deletedFolders[*folders[i].ParentID] {
+			continue
+		}
+		if parent, ok := paths[*folders[i].ParentID]; ok {
+			changes = append(changes, model.NewFolderChange(model.ChangeDeleted, &folders[i], path.Join(parent, folders[i].Name)))
+		}
+	}
+	for _, file := range files {
+		if deletedFolders[file.FolderID] {
+			continue
+		}
+		if parent, ok := paths[file.FolderID]; ok {
+			changes = append(changes, model.NewFileChange(model.ChangeDeleted, file, path.Join(parent, file.Name)))
+		}
+	}
+
+	return changes
+}
+
+// fileChange 生成文件的变更记录，上传时未指定所在目录的路径时查找之
+func (fs *FileSystem) fileChange(action string, fileInfo *fsctx.UploadTaskInfo, file *model.File) []model.Change {
+	parent := fileInfo.VirtualPath
+	if parent == "" {
+		paths := folderPaths([]uint{file.FolderID}, file.UserID)
+		var ok bool
+		if parent, ok = paths[file.FolderID]; !ok {
+			return nil
+		}
+	}
+
+	return []model.Change{model.NewFileChange(action, file, path.Join(parent, file.Name))}
+}
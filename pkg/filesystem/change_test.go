@@ -0,0 +1,154 @@
+package filesystem
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_Delta(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	ctx := context.Background()
+
+	expectRange := func(first, last uint) {
+		mock.ExpectQuery("SELECT(.+)changes(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(first))
+		mock.ExpectQuery("SELECT(.+)changes(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(last))
+	}
+
+	// 游标超出现有记录
+	{
+		expectRange(1, 10)
+		_, err := fs.Delta(ctx, 20, 10)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Equal(ErrChangeCursorExpired, err)
+	}
+
+	// 游标之后的记录已被清理
+	{
+		expectRange(5, 10)
+		_, err := fs.Delta(ctx, 2, 10)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Equal(ErrChangeCursorExpired, err)
+	}
+
+	// 成功，合并同一路径的变更并展开新建的目录
+	{
+		expectRange(1, 10)
+		mock.ExpectQuery("SELECT(.+)changes(.+)").WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "action", "is_dir", "object_id", "path"}).
+				AddRow(3, model.ChangeCreated, true, 7, "/docs").
+				AddRow(4, model.ChangeCreated, false, 5, "/b.txt").
+				AddRow(5, model.ChangeDeleted, false, 6, "/old.txt").
+				AddRow(6, model.ChangeModified, false, 5, "/b.txt"))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "size", "hash"}).AddRow(5, "b.txt", 10, "abc"))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id", "name"}).AddRow(7, 1, "docs"))
+		// 展开新建的目录
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id", "name"}).AddRow(7, 1, "docs"))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id", "name", "parent_id"}).AddRow(8, 1, "sub", 7))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "folder_id", "size"}).AddRow(9, "c.txt", 8, 3))
+		res, err := fs.Delta(ctx, 2, 10)
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.EqualValues(10, res.Cursor)
+		a.False(res.HasMore)
+		a.Len(res.Entries, 5)
+		a.Equal("/docs", res.Entries[0].Path)
+		a.Equal("/docs/sub", res.Entries[1].Path)
+		a.Equal("/docs/sub/c.txt", res.Entries[2].Path)
+		a.EqualValues(3, res.Entries[2].Size)
+		a.Equal("/old.txt", res.Entries[3].Path)
+		a.Equal(model.ChangeDeleted, res.Entries[3].Action)
+		a.Equal("/b.txt", res.Entries[4].Path)
+		a.Equal(model.ChangeModified, res.Entries[4].Action)
+		a.Equal("abc", res.Entries[4].SHA256)
+	}
+
+	// 超出数量限制，以最后一条记录作为游标
+	{
+		expectRange(1, 10)
+		mock.ExpectQuery("SELECT(.+)changes(.+)").WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "action", "is_dir", "path"}).
+				AddRow(3, model.ChangeDeleted, true, "/a").
+				AddRow(4, model.ChangeDeleted, false, "/b"))
+		res, err := fs.Delta(ctx, 2, 2)
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.EqualValues(4, res.Cursor)
+		a.True(res.HasMore)
+		a.Len(res.Entries, 2)
+		a.Equal("dir", res.Entries[0].Type)
+	}
+}
+
+func TestFileSystem_placeChanges(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	targets := []model.Change{{UserID: 2, IsDir: true, ObjectID: 3, Path: "docs"}}
+
+	// 沿用原名称及ID
+	{
+		res := fs.placeChanges(targets, model.ChangeCreated, "/dst", "", false)
+		a.Len(res, 1)
+		a.Equal("/dst/docs", res[0].Path)
+		a.EqualValues(1, res[0].UserID)
+		a.EqualValues(3, res[0].ObjectID)
+		a.Equal(model.ChangeCreated, res[0].Action)
+	}
+
+	// 指定名称的副本
+	{
+		res := fs.placeChanges(targets, model.ChangeCreated, "/dst", "new", true)
+		a.Equal("/dst/new", res[0].Path)
+		a.EqualValues(0, res[0].ObjectID)
+		a.Equal("docs", targets[0].Path)
+	}
+}
+
+func TestFileSystem_deletedChanges(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	parent := uint(1)
+	sub := uint(2)
+	folders := []model.Folder{
+		{Model: gorm.Model{ID: 2}, Name: "docs", ParentID: &parent, OwnerID: 1},
+		{Model: gorm.Model{ID: 3}, Name: "sub", ParentID: &sub, OwnerID: 1},
+	}
+	files := []*model.File{
+		{Model: gorm.Model{ID: 4}, Name: "in.txt", FolderID: 3, UserID: 1},
+		{Model: gorm.Model{ID: 5}, Name: "out.txt", FolderID: 1, UserID: 1},
+	}
+
+	// 只记录位于未被删除目录中的对象
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id", "name"}).AddRow(1, 1, "/"))
+		res := fs.deletedChanges(folders, files)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Len(res, 2)
+		a.Equal("/docs", res[0].Path)
+		a.True(res[0].IsDir)
+		a.Equal("/out.txt", res[1].Path)
+		a.Equal(model.ChangeDeleted, res[1].Action)
+	}
+
+	// 所在目录已不在目录树中
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		res := fs.deletedChanges(folders, files)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Len(res, 0)
+	}
+}
@@ -45,6 +45,45 @@ func (c *ConstantBackoff) Reset() {
 	c.tried = 0
 }
 
+// ExponentialBackoff implements Backoff interface with sleep time doubled after each retry,
+// starting from Base and capped at MaxSleep if it is set.
+type ExponentialBackoff struct {
+	Base     time.Duration
+	MaxSleep time.Duration
+	Max      int
+
+	tried int
+}
+
+func (e *ExponentialBackoff) Next(err error) bool {
+	e.tried++
+	if e.tried > e.Max {
+		return false
+	}
+
+	sleep := e.Base << (e.tried - 1)
+	if e.MaxSleep > 0 && (sleep > e.MaxSleep || sleep < e.Base) {
+		sleep = e.MaxSleep
+	}
+
+	var re *RetryableError
+	if errors.As(err, &re) && re.RetryAfter > sleep {
+		sleep = re.RetryAfter
+	}
+
+	time.Sleep(sleep)
+	return true
+}
+
+func (e *ExponentialBackoff) Reset() {
+	e.tried = 0
+}
+
+// Tried returns how many retries have been made.
+func (e *ExponentialBackoff) Tried() int {
+	return e.tried
+}
+
 type RetryableError struct {
 	Err        error
 	RetryAfter time.Duration
@@ -43,6 +43,21 @@ func TestConstantBackoff_Next(t *testing.T) {
 
 }
 
+func TestExponentialBackoff_Next(t *testing.T) {
+	a := assert.New(t)
+
+	err := errors.New("error")
+	b := &ExponentialBackoff{Base: time.Duration(1), MaxSleep: time.Duration(2), Max: 3}
+	a.True(b.Next(err))
+	a.True(b.Next(err))
+	a.True(b.Next(err))
+	a.False(b.Next(err))
+	a.Equal(4, b.Tried())
+	b.Reset()
+	a.Equal(0, b.Tried())
+	a.True(b.Next(&RetryableError{RetryAfter: time.Duration(1)}))
+}
+
 func TestNewRetryableErrorFromHeader(t *testing.T) {
 	a := assert.New(t)
 	// no retry-after header
@@ -0,0 +1,158 @@
+package chunk
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/chunk/backoff"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// MaxParallelMemoryBuffer is the maximum total size of chunks buffered in memory by ProcessParallel
+const MaxParallelMemoryBuffer = 256 << 20
+
+// ParallelProcessFunc callback function for processing a buffered chunk, index starts from 0.
+// content is rewound to the chunk start before each attempt
+type ParallelProcessFunc func(ctx context.Context, index int, content io.ReadSeeker, length int64) error
+
+// ProcessParallel reads chunks from file sequentially into buffers and processes them with
+// at most `concurrency` workers, so up to `concurrency` chunks are buffered at the same time.
+// Chunks are buffered in temp files if useTempBuffer is set or a single chunk exceeds
+// MaxParallelMemoryBuffer, otherwise concurrency is lowered to keep memory buffers within it.
+// Each chunk is retried with the backoff created by newBackoff. Reading stops on the first
+// failed chunk or when ctx is canceled, and the first error is returned.
+func ProcessParallel(ctx context.Context, file io.Reader, size, chunkSize uint64, concurrency int, useTempBuffer bool,
+	newBackoff func() backoff.Backoff, processor ParallelProcessFunc) error {
+	if chunkSize == 0 || chunkSize > size {
+		chunkSize = size
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	if chunkSize > MaxParallelMemoryBuffer {
+		useTempBuffer = true
+	}
+
+	if !useTempBuffer && chunkSize > 0 && uint64(concurrency)*chunkSize > MaxParallelMemoryBuffer {
+		concurrency = int(MaxParallelMemoryBuffer / chunkSize)
+	}
+
+	chunkNum := 1
+	if size > 0 {
+		chunkNum = int((size + chunkSize - 1) / chunkSize)
+	}
+
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		sem      = make(chan struct{}, concurrency)
+	)
+
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for index := 0; index < chunkNum; index++ {
+		select {
+		case sem <- struct{}{}:
+		case <-workerCtx.Done():
+		}
+
+		if workerCtx.Err() != nil {
+			break
+		}
+
+		length := chunkSize
+		if index == chunkNum-1 {
+			length = size - chunkSize*uint64(chunkNum-1)
+		}
+
+		content, release, err := bufferChunk(file, int64(length), useTempBuffer)
+		if err != nil {
+			<-sem
+			fail(fmt.Errorf("failed to read chunk #%d: %w", index, err))
+			break
+		}
+
+		wg.Add(1)
+		go func(index int, content io.ReadSeeker, length int64) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			defer release()
+
+			b := newBackoff()
+			for {
+				if _, err := content.Seek(0, io.SeekStart); err != nil {
+					fail(fmt.Errorf("failed to seek back to chunk #%d start: %w", index, err))
+					return
+				}
+
+				err := processor(workerCtx, index, content, length)
+				if err == nil {
+					util.Log().Debug("Chunk %d processed", index)
+					return
+				}
+
+				if workerCtx.Err() != nil || !b.Next(err) {
+					fail(fmt.Errorf("failed to upload chunk #%d: %w", index, err))
+					return
+				}
+
+				util.Log().Debug("Retrying chunk %d, last error: %s", index, err)
+			}
+		}(index, content, int64(length))
+	}
+
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+
+	return ctx.Err()
+}
+
+// bufferChunk reads next length bytes of file into memory or a temp file, release
+// must be called once the buffer is no longer used
+func bufferChunk(file io.Reader, length int64, useTempBuffer bool) (io.ReadSeeker, func(), error) {
+	if !useTempBuffer {
+		content := make([]byte, length)
+		if _, err := io.ReadFull(file, content); err != nil {
+			return nil, nil, err
+		}
+
+		return bytes.NewReader(content), func() {}, nil
+	}
+
+	temp, err := os.CreateTemp("", bufferTempPattern)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	release := func() {
+		temp.Close()
+		os.Remove(temp.Name())
+	}
+
+	if _, err := io.CopyN(temp, file, length); err != nil {
+		release()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, nil, err
+	}
+
+	return temp, release, nil
+}
@@ -0,0 +1,142 @@
+package chunk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/chunk/backoff"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBackoff(max int) func() backoff.Backoff {
+	return func() backoff.Backoff {
+		return &backoff.ConstantBackoff{Max: max}
+	}
+}
+
+func TestProcessParallel(t *testing.T) {
+	a := assert.New(t)
+
+	// 全部成功，分片内容与序号对应
+	{
+		content := "0123456789abcde"
+		var (
+			lock     sync.Mutex
+			received = make(map[int]string)
+		)
+		err := ProcessParallel(context.Background(), strings.NewReader(content), 15, 4, 3, false, newTestBackoff(0),
+			func(ctx context.Context, index int, c io.ReadSeeker, length int64) error {
+				lock.Lock()
+				defer lock.Unlock()
+				content, _ := ioutil.ReadAll(c)
+				received[index] = string(content)
+				return nil
+			})
+		a.NoError(err)
+		a.Equal(map[int]string{0: "0123", 1: "4567", 2: "89ab", 3: "cde"}, received)
+	}
+
+	// 并发数受限
+	{
+		var running, maxRunning int32
+		err := ProcessParallel(context.Background(), strings.NewReader(strings.Repeat("1", 20)), 20, 2, 3, false, newTestBackoff(0),
+			func(ctx context.Context, index int, c io.ReadSeeker, length int64) error {
+				current := atomic.AddInt32(&running, 1)
+				for {
+					old := atomic.LoadInt32(&maxRunning)
+					if current <= old || atomic.CompareAndSwapInt32(&maxRunning, old, current) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return nil
+			})
+		a.NoError(err)
+		a.LessOrEqual(maxRunning, int32(3))
+		a.Greater(maxRunning, int32(1))
+	}
+
+	// 失败后重试成功
+	{
+		var calls int32
+		err := ProcessParallel(context.Background(), strings.NewReader("123"), 3, 0, 2, false, newTestBackoff(1),
+			func(ctx context.Context, index int, c io.ReadSeeker, length int64) error {
+				if atomic.AddInt32(&calls, 1) == 1 {
+					return errors.New("error")
+				}
+				return nil
+			})
+		a.NoError(err)
+		a.EqualValues(2, calls)
+	}
+
+	// 重试耗尽后停止读取后续分片
+	{
+		var calls int32
+		err := ProcessParallel(context.Background(), strings.NewReader(strings.Repeat("1", 10)), 10, 1, 1, false, newTestBackoff(1),
+			func(ctx context.Context, index int, c io.ReadSeeker, length int64) error {
+				atomic.AddInt32(&calls, 1)
+				return errors.New("error")
+			})
+		a.Error(err)
+		a.EqualValues(2, calls)
+	}
+
+	// 读取失败
+	{
+		err := ProcessParallel(context.Background(), strings.NewReader("1"), 10, 5, 1, false, newTestBackoff(0),
+			func(ctx context.Context, index int, c io.ReadSeeker, length int64) error {
+				return nil
+			})
+		a.Error(err)
+	}
+
+	// 使用临时文件缓冲，重试时重新读取分片内容
+	{
+		var (
+			lock     sync.Mutex
+			received = make(map[int][]string)
+		)
+		err := ProcessParallel(context.Background(), strings.NewReader("0123456"), 7, 4, 2, true, newTestBackoff(1),
+			func(ctx context.Context, index int, c io.ReadSeeker, length int64) error {
+				content, _ := ioutil.ReadAll(c)
+				lock.Lock()
+				defer lock.Unlock()
+				received[index] = append(received[index], string(content))
+				if len(received[index]) == 1 {
+					return errors.New("error")
+				}
+				return nil
+			})
+		a.NoError(err)
+		a.Equal(map[int][]string{0: {"0123", "0123"}, 1: {"456", "456"}}, received)
+	}
+
+	// 使用临时文件缓冲时读取失败
+	{
+		err := ProcessParallel(context.Background(), strings.NewReader("1"), 10, 5, 1, true, newTestBackoff(0),
+			func(ctx context.Context, index int, c io.ReadSeeker, length int64) error {
+				return nil
+			})
+		a.Error(err)
+	}
+
+	// 上下文已取消
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := ProcessParallel(ctx, strings.NewReader("123"), 3, 1, 1, false, newTestBackoff(0),
+			func(ctx context.Context, index int, c io.ReadSeeker, length int64) error {
+				return nil
+			})
+		a.ErrorIs(err, context.Canceled)
+	}
+}
@@ -0,0 +1,206 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+)
+
+/* =================
+	 上传文件重名处理
+   =================
+*/
+
+// maxRenameAttempts 寻找可用文件名的最大尝试次数
+const maxRenameAttempts = 1000
+
+// resolveNameConflict 根据上下文中指定的处理方式，解决上传文件与 folder 下已有文件 existing 的重名。
+// 覆盖和保留版本需要修改已有文件，新文件先以未被占用的文件名创建，内容上传完成后再由
+// replaceConflictedFile 替换已有文件
+func (fs *FileSystem) resolveNameConflict(ctx context.Context, folder *model.Folder, existing *model.File, fileHeader fsctx.FileHeader) error {
+	strategy, _ := ctx.Value(fsctx.ConflictStrategyCtx).(fsctx.ConflictStrategy)
+	switch strategy {
+	case fsctx.ConflictOverwrite, fsctx.ConflictVersion:
+		// 提前检查已有文件能否被替换，避免上传完成后才失败
+		if err := fs.validateReplaceable(ctx, existing, fileHeader); err != nil {
+			return err
+		}
+		fallthrough
+	case fsctx.ConflictRename:
+		name, err := fs.AvailableName(folder, existing.Name)
+		if err != nil {
+			return err
+		}
+
+		fileHeader.SetName(name)
+		return nil
+	default:
+		return ErrFileExisted
+	}
+}
+
+// resolveUploadNameConflict 在生成存储路径前解决新上传文件与已有文件的重名，避免存储路径规则包含
+// 原文件名时新文件写入已有文件的物理文件。文件名被修改时在返回的上下文中记录原文件名，供上传完成后
+// 替换已有文件。未指定重名处理方式或更新已有文件时不做处理
+func (fs *FileSystem) resolveUploadNameConflict(ctx context.Context, fileHeader fsctx.FileHeader) (context.Context, error) {
+	if _, ok := ctx.Value(fsctx.ConflictStrategyCtx).(fsctx.ConflictStrategy); !ok {
+		return ctx, nil
+	}
+
+	if _, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
+		return ctx, nil
+	}
+
+	name := fileHeader.Info().FileName
+	exist, folder := fs.IsPathExist(fileHeader.Info().VirtualPath)
+	if !exist {
+		return ctx, nil
+	}
+
+	exist, existing := fs.IsChildFileExist(folder, name)
+	if !exist {
+		return ctx, nil
+	}
+
+	if existing.UploadSessionID != nil {
+		return ctx, ErrFileUploadSessionExisted
+	}
+
+	if err := fs.resolveNameConflict(ctx, folder, existing, fileHeader); err != nil {
+		return ctx, err
+	}
+
+	return context.WithValue(ctx, fsctx.ConflictOriginNameCtx, name), nil
+}
+
+// validateReplaceable 检查已有文件是否处于保留期内
+func (fs *FileSystem) validateReplaceable(ctx context.Context, existing *model.File, fileHeader fsctx.FileHeader) error {
+	originCtx := context.WithValue(ctx, fsctx.FileModelCtx, *existing)
+	return HookValidateRetention(originCtx, fs, fileHeader)
+}
+
+// replaceConflictedFile 新文件内容上传完成后，按重名处理方式用其替换同一目录下名为 name 的已有文件。
+// 覆盖时删除已有文件，新文件改用 name；保留版本时已有文件的原内容经 HookSaveVersion 保存为历史版本，
+// 新内容合并至已有文件，此时 fileHeader 改为指向已有文件
+func (fs *FileSystem) replaceConflictedFile(ctx context.Context, fileHeader fsctx.FileHeader, name string, strategy fsctx.ConflictStrategy) error {
+	newFile, ok := fileHeader.Info().Model.(*model.File)
+	if !ok {
+		return ErrObjectNotExist
+	}
+
+	folder := &model.Folder{}
+	folder.ID = newFile.FolderID
+	exist, existing := fs.IsChildFileExist(folder, name)
+	if !exist {
+		// 已有文件已被删除或改名
+		if err := newFile.Rename(name); err != nil {
+			return ErrInsertFileRecord.WithError(err)
+		}
+		newFile.Name = name
+		fileHeader.SetName(name)
+		return nil
+	}
+
+	if existing.UploadSessionID != nil || existing.ID == newFile.ID {
+		// 同名文件为其他上传中的占位文件，保留新文件当前的文件名
+		return nil
+	}
+
+	if err := fs.validateReplaceable(ctx, existing, fileHeader); err != nil {
+		return err
+	}
+
+	if strategy != fsctx.ConflictVersion {
+		if err := fs.deleteConflictedFile(ctx, existing, fileHeader); err != nil {
+			return err
+		}
+
+		if err := newFile.Rename(name); err != nil {
+			return ErrInsertFileRecord.WithError(err)
+		}
+		newFile.Name = name
+		fileHeader.SetName(name)
+		return nil
+	}
+
+	origin := *existing
+	if err := HookSaveVersion(context.WithValue(ctx, fsctx.FileModelCtx, origin), fs, fileHeader); err != nil {
+		return err
+	}
+
+	if err := existing.ReplaceContentWith(newFile); err != nil {
+		return ErrInsertFileRecord.WithError(err)
+	}
+
+	// 未保留为历史版本的原内容不再被引用时删除
+	if !ShouldKeepVersion(&origin) && origin.SourceName != existing.SourceName {
+		fs.deleteUnreferencedSources(ctx, map[uint][]string{origin.PolicyID: {origin.SourceName}})
+	}
+
+	fileHeader.SetModel(existing)
+	fileHeader.SetName(name)
+	return nil
+}
+
+// HookReplaceConflictedFile 占位文件上传完成后，按创建上传会话时指定的重名处理方式替换同名的已有文件。
+// 需在 HookPopPlaceholderToFile 之前执行
+func HookReplaceConflictedFile(session *serializer.UploadSession) Hook {
+	return func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+		if session.ReplaceName == "" {
+			return nil
+		}
+
+		return fs.replaceConflictedFile(ctx, fileHeader, session.ReplaceName, fsctx.ConflictStrategy(session.Conflict))
+	}
+}
+
+// deleteConflictedFile 删除被覆盖的已有文件
+func (fs *FileSystem) deleteConflictedFile(ctx context.Context, existing *model.File, fileHeader fsctx.FileHeader) error {
+	// 删除文件会切换当前存储策略及目标对象，完成后恢复
+	policy, handler := fs.Policy, fs.Handler
+	files, dirs := fs.FileTarget, fs.DirTarget
+	fs.FileTarget, fs.DirTarget = nil, nil
+	defer func() {
+		fs.Policy, fs.Handler = policy, handler
+		fs.FileTarget, fs.DirTarget = files, dirs
+	}()
+
+	// 新文件与已有文件使用相同的物理路径时，物理文件已被覆盖，只删除记录
+	unlink := policy != nil && existing.PolicyID == policy.ID && existing.SourceName == fileHeader.Info().SavePath
+	return fs.Delete(ctx, nil, []uint{existing.ID}, false, unlink)
+}
+
+// AvailableName 返回 folder 下未被占用的文件名，依次尝试 "name (1).ext"、"name (2).ext"...
+func (fs *FileSystem) AvailableName(folder *model.Folder, name string) (string, error) {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if base == "" {
+		// 以 . 开头且没有其他扩展名的文件，如 .gitignore
+		base, ext = name, ""
+	}
+
+	for i := 1; i <= maxRenameAttempts; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if exist, _ := fs.IsChildFileExist(folder, candidate); !exist {
+			return candidate, nil
+		}
+	}
+
+	return "", ErrFileExisted
+}
+
+// deferredReplaceName 返回新文件因重名而改用其他文件名、需在内容上传完成后替换的已有文件名，
+// name 为新文件原本的文件名，无需替换时返回空
+func deferredReplaceName(ctx context.Context, name string, file fsctx.FileHeader) string {
+	strategy, _ := ctx.Value(fsctx.ConflictStrategyCtx).(fsctx.ConflictStrategy)
+	if (strategy == fsctx.ConflictOverwrite || strategy == fsctx.ConflictVersion) && file.Info().FileName != name {
+		return name
+	}
+
+	return ""
+}
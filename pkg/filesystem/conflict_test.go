@@ -0,0 +1,179 @@
+package filesystem
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_AvailableName(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{}}
+	folder := &model.Folder{Model: gorm.Model{ID: 1}}
+
+	// 第一个候选名称可用
+	mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(1, "b.tar (1).gz").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	name, err := fs.AvailableName(folder, "b.tar.gz")
+	asserts.NoError(err)
+	asserts.Equal("b.tar (1).gz", name)
+	asserts.NoError(mock.ExpectationsWereMet())
+
+	// 跳过已存在的名称
+	mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(1, "a (1).txt").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(1, "a (2).txt").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	name, err = fs.AvailableName(folder, "a.txt")
+	asserts.NoError(err)
+	asserts.Equal("a (2).txt", name)
+	asserts.NoError(mock.ExpectationsWereMet())
+
+	// 没有扩展名
+	mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(1, ".gitignore (1)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	name, err = fs.AvailableName(folder, ".gitignore")
+	asserts.NoError(err)
+	asserts.Equal(".gitignore (1)", name)
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
+func TestFileSystem_ResolveNameConflict(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{}}
+	folder := &model.Folder{Model: gorm.Model{ID: 1}}
+	existing := &model.File{Model: gorm.Model{ID: 2}, Name: "a.txt", FolderID: 1}
+	file := &fsctx.FileStream{Name: "a.txt"}
+
+	// 未指定时返回错误
+	asserts.Equal(ErrFileExisted, fs.resolveNameConflict(context.Background(), folder, existing, file))
+	ctx := context.WithValue(context.Background(), fsctx.ConflictStrategyCtx, fsctx.ConflictFail)
+	asserts.Equal(ErrFileExisted, fs.resolveNameConflict(ctx, folder, existing, file))
+
+	// 重命名上传的文件
+	ctx = context.WithValue(context.Background(), fsctx.ConflictStrategyCtx, fsctx.ConflictRename)
+	mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(1, "a (1).txt").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	asserts.NoError(fs.resolveNameConflict(ctx, folder, existing, file))
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Equal("a (1).txt", file.Name)
+
+	// 覆盖及保留版本时，新文件先使用其他文件名
+	for _, strategy := range []fsctx.ConflictStrategy{fsctx.ConflictOverwrite, fsctx.ConflictVersion} {
+		file.Name = "a.txt"
+		ctx = context.WithValue(context.Background(), fsctx.ConflictStrategyCtx, strategy)
+		mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(1, "a (1).txt").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		asserts.NoError(fs.resolveNameConflict(ctx, folder, existing, file))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("a (1).txt", file.Name)
+		asserts.Equal("a.txt", existing.Name)
+		asserts.Equal("a.txt", deferredReplaceName(ctx, "a.txt", file))
+	}
+	asserts.Equal("", deferredReplaceName(context.Background(), "a.txt", file))
+}
+
+func TestFileSystem_Upload_OriginNameConflict(t *testing.T) {
+	asserts := assert.New(t)
+	root := &model.Folder{Model: gorm.Model{ID: 1}}
+	policy := &model.Policy{Type: "local", DirNameRule: "uploads", FileNameRule: "{originname}"}
+
+	// 存储路径按重名处理后的文件名生成，不与已有文件的物理文件重合
+	for _, strategy := range []fsctx.ConflictStrategy{fsctx.ConflictRename, fsctx.ConflictVersion} {
+		fs := &FileSystem{User: &model.User{}, Policy: policy, Root: root}
+		ctx := context.WithValue(context.Background(), fsctx.ConflictStrategyCtx, strategy)
+		file := &fsctx.FileStream{Name: "a.txt", VirtualPath: "/", Mode: fsctx.Nop}
+		var originName string
+		fs.Use("AfterUpload", func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+			originName, _ = ctx.Value(fsctx.ConflictOriginNameCtx).(string)
+			return nil
+		})
+
+		mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(1, "a.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "source_name"}).AddRow(2, "a.txt", "uploads/a.txt"))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(1, "a (1).txt").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		asserts.NoError(fs.Upload(ctx, file))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("a (1).txt", file.Name)
+		asserts.Equal("uploads/a (1).txt", file.SavePath)
+		asserts.Equal("a.txt", originName)
+	}
+
+	// 未指定重名处理方式时不提前处理
+	{
+		fs := &FileSystem{User: &model.User{}, Policy: policy, Root: root}
+		file := &fsctx.FileStream{Name: "a.txt", VirtualPath: "/", Mode: fsctx.Nop}
+		asserts.NoError(fs.Upload(context.Background(), file))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("uploads/a.txt", file.SavePath)
+	}
+
+	// 已有同名文件且不允许重名
+	{
+		fs := &FileSystem{User: &model.User{}, Policy: policy, Root: root}
+		ctx := context.WithValue(context.Background(), fsctx.ConflictStrategyCtx, fsctx.ConflictFail)
+		file := &fsctx.FileStream{Name: "a.txt", VirtualPath: "/", Mode: fsctx.Nop}
+		mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(1, "a.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "a.txt"))
+		asserts.Equal(ErrFileExisted, fs.Upload(ctx, file))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Empty(file.SavePath)
+	}
+}
+
+func TestFileSystem_ReplaceConflictedFile(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{}}
+	fileColumns := []string{"id", "name", "folder_id", "policy_id", "source_name", "size"}
+
+	// 已有文件已不存在，直接改名
+	{
+		newFile := &model.File{Model: gorm.Model{ID: 3}, Name: "a (1).txt", FolderID: 1}
+		file := &fsctx.FileStream{Name: "a (1).txt", Model: newFile}
+		mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(1, "a.txt").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(fs.replaceConflictedFile(context.Background(), file, "a.txt", fsctx.ConflictOverwrite))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("a.txt", newFile.Name)
+		asserts.Equal("a.txt", file.Name)
+	}
+
+	// 同名文件仍在上传中
+	{
+		newFile := &model.File{Model: gorm.Model{ID: 3}, Name: "a (1).txt", FolderID: 1}
+		file := &fsctx.FileStream{Name: "a (1).txt", Model: newFile}
+		mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(1, "a.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "upload_session_id"}).AddRow(2, "a.txt", "session"))
+		asserts.NoError(fs.replaceConflictedFile(context.Background(), file, "a.txt", fsctx.ConflictOverwrite))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("a (1).txt", file.Name)
+	}
+
+	// 保留版本，存储策略未开启历史版本时原内容不再保留
+	{
+		cache.Set("policy_1", model.Policy{Model: gorm.Model{ID: 1}}, -1)
+		newFile := &model.File{Model: gorm.Model{ID: 3}, Name: "a (1).txt", FolderID: 1, PolicyID: 1, SourceName: "new.txt", Size: 20}
+		file := &fsctx.FileStream{Name: "a (1).txt", Model: newFile, SavePath: "new.txt"}
+		mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(1, "a.txt").
+			WillReturnRows(sqlmock.NewRows(fileColumns).AddRow(2, "a.txt", 1, 1, "old.txt", 10))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)users(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("DELETE(.+)files(.+)").WithArgs(3).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT count(.+)files(.+)").WithArgs(1, "old.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		asserts.NoError(fs.replaceConflictedFile(context.Background(), file, "a.txt", fsctx.ConflictVersion))
+		asserts.NoError(mock.ExpectationsWereMet())
+		merged := file.Model.(*model.File)
+		asserts.EqualValues(2, merged.ID)
+		asserts.Equal("new.txt", merged.SourceName)
+		asserts.EqualValues(20, merged.Size)
+		asserts.Equal("a.txt", file.Name)
+	}
+}
@@ -0,0 +1,570 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/chunk"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/chunk/backoff"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+const (
+	chunkRetrySleep = time.Duration(5) * time.Second
+	// requestTTL 服务端请求使用的 SAS 有效期
+	requestTTL = time.Hour
+)
+
+// ErrFileExisted 上传的目标 Blob 已存在
+var ErrFileExisted = errors.New("file already exist")
+
+// Driver Azure Blob 存储策略适配器，Policy 中 AccessKey 为存储账户名，
+// SecretKey 为账户密钥，BucketName 为容器名
+type Driver struct {
+	Policy *model.Policy
+	client request.Client
+}
+
+// MetaData 文件信息
+type MetaData struct {
+	Size uint64
+	Etag string
+}
+
+// listResult List Blobs 响应
+type listResult struct {
+	Blobs struct {
+		Blob []struct {
+			Name       string `xml:"Name"`
+			Properties struct {
+				LastModified  string `xml:"Last-Modified"`
+				ContentLength uint64 `xml:"Content-Length"`
+			} `xml:"Properties"`
+		} `xml:"Blob"`
+		BlobPrefix []struct {
+			Name string `xml:"Name"`
+		} `xml:"BlobPrefix"`
+	} `xml:"Blobs"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// blockList Put Block List 请求正文
+type blockList struct {
+	XMLName xml.Name `xml:"BlockList"`
+	Latest  []string `xml:"Latest"`
+}
+
+// NewDriver 创建 Azure Blob 适配器
+func NewDriver(policy *model.Policy) (*Driver, error) {
+	if policy.OptionsSerialized.ChunkSize == 0 {
+		policy.OptionsSerialized.ChunkSize = 25 << 20 // 25 MB
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(policy.SecretKey); err != nil {
+		return nil, fmt.Errorf("invalid account key: %w", err)
+	}
+
+	return &Driver{
+		Policy: policy,
+		client: request.NewClient(request.WithProxy(policy.OptionsSerialized.Proxy)),
+	}, nil
+}
+
+// endpoint 返回 Blob 服务地址
+func (handler *Driver) endpoint() (*url.URL, error) {
+	if handler.Policy.Server != "" {
+		return url.Parse(handler.Policy.Server)
+	}
+
+	return url.Parse("https://" + handler.Policy.AccessKey + ".blob.core.windows.net")
+}
+
+// blobURL 返回 Blob 的访问地址，name 为空时返回容器地址
+func (handler *Driver) blobURL(name string) (*url.URL, error) {
+	u, err := handler.endpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	u.Path = path.Join("/", u.Path, handler.Policy.BucketName, name)
+	return u, nil
+}
+
+// signedURL 返回附带服务 SAS 的 Blob 地址，extra 为额外的查询参数
+func (handler *Driver) signedURL(opts sasOptions, extra url.Values) (string, error) {
+	u, err := handler.blobURL(opts.Blob)
+	if err != nil {
+		return "", err
+	}
+
+	query, err := handler.signServiceSAS(opts)
+	if err != nil {
+		return "", err
+	}
+
+	for k, v := range extra {
+		query[k] = v
+	}
+
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// blockID 返回第 index 个分块的 ID，同一 Blob 的分块 ID 长度须一致
+func blockID(index int) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%08d", index)))
+}
+
+// List 列出给定路径下的文件
+func (handler *Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	base = strings.TrimPrefix(base, "/")
+	if base != "" {
+		base += "/"
+	}
+
+	extra := url.Values{
+		"restype": {"container"},
+		"comp":    {"list"},
+		"prefix":  {base},
+	}
+	if !recursive {
+		extra.Set("delimiter", "/")
+	}
+
+	res := make([]response.Object, 0)
+	for {
+		listURL, err := handler.signedURL(sasOptions{
+			Permissions: permList,
+			Expiry:      time.Now().Add(requestTTL),
+		}, extra)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := handler.client.Request(
+			"GET",
+			listURL,
+			nil,
+			request.WithContext(ctx),
+		).CheckHTTPResponse(200).GetResponse()
+		if err != nil {
+			return nil, err
+		}
+
+		var result listResult
+		if err := xml.Unmarshal([]byte(body), &result); err != nil {
+			return nil, err
+		}
+
+		// 处理目录
+		for _, prefix := range result.Blobs.BlobPrefix {
+			rel, err := filepath.Rel(base, prefix.Name)
+			if err != nil {
+				continue
+			}
+			res = append(res, response.Object{
+				Name:         path.Base(prefix.Name),
+				RelativePath: filepath.ToSlash(rel),
+				Size:         0,
+				IsDir:        true,
+				LastModify:   time.Now(),
+			})
+		}
+
+		// 处理文件
+		for _, blob := range result.Blobs.Blob {
+			rel, err := filepath.Rel(base, blob.Name)
+			if err != nil {
+				continue
+			}
+
+			lastModified, err := time.Parse(time.RFC1123, blob.Properties.LastModified)
+			if err != nil {
+				lastModified = time.Now()
+			}
+
+			res = append(res, response.Object{
+				Name:         path.Base(blob.Name),
+				Source:       blob.Name,
+				RelativePath: filepath.ToSlash(rel),
+				Size:         blob.Properties.ContentLength,
+				IsDir:        false,
+				LastModify:   lastModified,
+			})
+		}
+
+		// 如果本次未列取完，则继续使用marker获取结果
+		if result.NextMarker == "" {
+			break
+		}
+		extra.Set("marker", result.NextMarker)
+	}
+
+	return res, nil
+}
+
+// Get 获取文件
+func (handler *Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	// 获取文件源地址
+	downloadURL, err := handler.Source(ctx, path, int64(model.GetIntSetting("preview_timeout", 60)), false, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// 获取文件数据流
+	resp, err := request.NewRangeRSCloser(func(header http.Header) *request.Response {
+		return handler.client.Request(
+			"GET",
+			downloadURL,
+			nil,
+			request.WithContext(ctx),
+			request.WithHeader(
+				http.Header{"Cache-Control": {"no-cache", "no-store", "must-revalidate"}},
+			),
+			request.WithHeader(header),
+			request.WithTimeout(time.Duration(0)),
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 尝试自主获取文件大小
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
+		resp.SetContentLength(int64(file.Size))
+	}
+
+	return resp, nil
+}
+
+// Put 将文件流保存到指定目录，超过分片大小时分块上传后提交分块列表
+func (handler *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
+	defer file.Close()
+	fileInfo := file.Info()
+
+	header := http.Header{}
+	if fileInfo.Mode&fsctx.Overwrite != fsctx.Overwrite {
+		header.Set("If-None-Match", "*")
+	}
+
+	signed := func(extra url.Values) (string, error) {
+		return handler.signedURL(sasOptions{
+			Blob:        fileInfo.SavePath,
+			Permissions: permCreate + permWrite,
+			Expiry:      time.Now().Add(requestTTL),
+		}, extra)
+	}
+
+	// 小文件直接上传
+	chunkSize := handler.Policy.OptionsSerialized.ChunkSize
+	if fileInfo.Size <= chunkSize {
+		putURL, err := signed(nil)
+		if err != nil {
+			return err
+		}
+
+		header.Set("x-ms-blob-type", "BlockBlob")
+		header.Set("Content-Type", fileInfo.DetectMimeType())
+		_, err = handler.client.Request(
+			"PUT",
+			putURL,
+			io.LimitReader(file, int64(fileInfo.Size)),
+			request.WithContext(ctx),
+			request.WithHeader(header),
+			request.WithContentLength(int64(fileInfo.Size)),
+			request.WithTimeout(time.Duration(0)),
+		).CheckHTTPResponse(http.StatusCreated).GetResponse()
+		return err
+	}
+
+	retries := model.GetIntSetting("chunk_retries", 5)
+	newBackoff := func() backoff.Backoff {
+		return &backoff.ConstantBackoff{Max: retries, Sleep: chunkRetrySleep}
+	}
+
+	uploadFunc := func(ctx context.Context, index int, content io.ReadSeeker, length int64) error {
+		blockURL, err := signed(url.Values{"comp": {"block"}, "blockid": {blockID(index)}})
+		if err != nil {
+			return err
+		}
+
+		_, err = handler.client.Request(
+			"PUT",
+			blockURL,
+			content,
+			request.WithContext(ctx),
+			request.WithContentLength(length),
+			request.WithTimeout(time.Duration(0)),
+		).CheckHTTPResponse(http.StatusCreated).GetResponse()
+		return err
+	}
+
+	// 未提交的分块会在一周后由服务端自动清理
+	err := chunk.ProcessParallel(ctx, file, fileInfo.Size, chunkSize, handler.Policy.OptionsSerialized.UploadConcurrency,
+		model.IsTrueVal(model.GetSettingByName("use_temp_chunk_buffer")), newBackoff, uploadFunc)
+	if err != nil {
+		return err
+	}
+
+	blocks := blockList{Latest: make([]string, (fileInfo.Size+chunkSize-1)/chunkSize)}
+	for i := range blocks.Latest {
+		blocks.Latest[i] = blockID(i)
+	}
+
+	body, err := xml.Marshal(blocks)
+	if err != nil {
+		return err
+	}
+
+	commitURL, err := signed(url.Values{"comp": {"blocklist"}})
+	if err != nil {
+		return err
+	}
+
+	header.Set("x-ms-blob-content-type", fileInfo.DetectMimeType())
+	_, err = handler.client.Request(
+		"PUT",
+		commitURL,
+		bytes.NewReader(body),
+		request.WithContext(ctx),
+		request.WithHeader(header),
+		request.WithContentLength(int64(len(body))),
+	).CheckHTTPResponse(http.StatusCreated).GetResponse()
+	return err
+}
+
+// Delete 删除一个或多个文件，
+// 返回未删除的文件，及遇到的最后一个错误
+func (handler *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	failed := make([]string, 0, len(files))
+	var retErr error
+
+	for _, file := range files {
+		deleteURL, err := handler.signedURL(sasOptions{
+			Blob:        file,
+			Permissions: permDelete,
+			Expiry:      time.Now().Add(requestTTL),
+		}, nil)
+		if err != nil {
+			return files, err
+		}
+
+		resp := handler.client.Request(
+			"DELETE",
+			deleteURL,
+			nil,
+			request.WithContext(ctx),
+		)
+		if resp.Err == nil {
+			resp.Response.Body.Close()
+
+			// 文件不存在时视为删除成功
+			if resp.Response.StatusCode == http.StatusAccepted || resp.Response.StatusCode == http.StatusNotFound {
+				continue
+			}
+
+			resp.Err = fmt.Errorf("unexpected status code %d", resp.Response.StatusCode)
+		}
+
+		util.Log().Warning("Failed to delete blob %q: %s", file, resp.Err)
+		failed = append(failed, file)
+		retErr = resp.Err
+	}
+
+	return failed, retErr
+}
+
+// Thumb 获取文件缩略图，Azure Blob 不支持图像处理，由 Cloudreve 代理生成
+func (handler *Driver) Thumb(ctx context.Context, file *model.File) (*response.ContentResponse, error) {
+	return nil, driver.ErrorThumbNotSupported
+}
+
+// Source 获取外链URL
+func (handler *Driver) Source(ctx context.Context, path string, ttl int64, isDownload bool, speed int) (string, error) {
+	// 尝试从上下文获取文件名
+	fileName := ""
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
+		fileName = file.Name
+	}
+
+	var (
+		sourceURL *url.URL
+		err       error
+	)
+
+	// 公有容器无需签名
+	if !handler.Policy.IsPrivate && !isDownload {
+		sourceURL, err = handler.blobURL(path)
+	} else {
+		opts := sasOptions{
+			Blob:        path,
+			Permissions: permRead,
+			Expiry:      time.Now().Add(time.Duration(ttl) * time.Second),
+		}
+		if isDownload {
+			opts.ContentDisposition = "attachment; filename=\"" + url.PathEscape(fileName) + "\""
+		}
+
+		var signed string
+		signed, err = handler.signedURL(opts, nil)
+		if err == nil {
+			sourceURL, err = url.Parse(signed)
+		}
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	// 将最终生成的URL域名换成用户自定义的加速域名（如果有）
+	if handler.Policy.BaseURL != "" {
+		cdnURL, err := url.Parse(handler.Policy.BaseURL)
+		if err != nil {
+			return "", err
+		}
+		sourceURL.Host = cdnURL.Host
+		sourceURL.Scheme = cdnURL.Scheme
+	}
+
+	return sourceURL.String(), nil
+}
+
+// Token 获取上传凭证。客户端使用 UploadURLs 依次上传每个分块，
+// 再向 CompleteURL 提交按顺序排列的分块 ID 列表，最后请求 Callback 完成上传
+func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
+	// 检查文件是否存在
+	fileInfo := file.Info()
+	if _, err := handler.Meta(ctx, fileInfo.SavePath); err == nil {
+		return nil, ErrFileExisted
+	}
+
+	// 生成回调地址
+	siteURL := model.GetSiteURL()
+	apiBaseURI, _ := url.Parse("/api/v3/callback/azure/" + uploadSession.Key)
+	apiURL := siteURL.ResolveReference(apiBaseURI).String()
+
+	opts := sasOptions{
+		Blob:        fileInfo.SavePath,
+		Permissions: permCreate + permWrite,
+		Expiry:      time.Now().Add(time.Duration(ttl) * time.Second),
+	}
+
+	// 为每个分块签名上传 URL
+	chunks := chunk.NewChunkGroup(file, handler.Policy.OptionsSerialized.ChunkSize, &backoff.ConstantBackoff{}, false)
+	urls := make([]string, chunks.Num())
+	for i := range urls {
+		signedURL, err := handler.signedURL(opts, url.Values{"comp": {"block"}, "blockid": {blockID(i)}})
+		if err != nil {
+			return nil, err
+		}
+
+		urls[i] = signedURL
+	}
+
+	completeURL, err := handler.signedURL(opts, url.Values{"comp": {"blocklist"}})
+	if err != nil {
+		return nil, err
+	}
+
+	return &serializer.UploadCredential{
+		SessionID:   uploadSession.Key,
+		ChunkSize:   handler.Policy.OptionsSerialized.ChunkSize,
+		UploadURLs:  urls,
+		CompleteURL: completeURL,
+		Callback:    apiURL,
+	}, nil
+}
+
+// CancelToken 取消上传凭证，未提交的分块会在一周后由服务端自动清理
+func (handler *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	return nil
+}
+
+// Meta 获取文件信息
+func (handler *Driver) Meta(ctx context.Context, path string) (*MetaData, error) {
+	metaURL, err := handler.signedURL(sasOptions{
+		Blob:        path,
+		Permissions: permRead,
+		Expiry:      time.Now().Add(requestTTL),
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := handler.client.Request(
+		"HEAD",
+		metaURL,
+		nil,
+		request.WithContext(ctx),
+	).CheckHTTPResponse(200)
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	resp.Response.Body.Close()
+
+	size, err := strconv.ParseUint(resp.Response.Header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MetaData{
+		Size: size,
+		Etag: resp.Response.Header.Get("ETag"),
+	}, nil
+}
+
+// CORS 设定 Blob 服务的跨域策略
+func (handler *Driver) CORS() error {
+	u, err := handler.endpoint()
+	if err != nil {
+		return err
+	}
+
+	query, err := handler.signAccountSAS(permWrite, time.Now().Add(requestTTL))
+	if err != nil {
+		return err
+	}
+
+	query.Set("restype", "service")
+	query.Set("comp", "properties")
+	u.RawQuery = query.Encode()
+
+	body := `<?xml version="1.0" encoding="utf-8"?><StorageServiceProperties><Cors><CorsRule>` +
+		`<AllowedOrigins>*</AllowedOrigins><AllowedMethods>GET,HEAD,PUT,DELETE,OPTIONS</AllowedMethods>` +
+		`<AllowedHeaders>*</AllowedHeaders><ExposedHeaders>*</ExposedHeaders><MaxAgeInSeconds>3600</MaxAgeInSeconds>` +
+		`</CorsRule></Cors></StorageServiceProperties>`
+	_, err = handler.client.Request(
+		"PUT",
+		u.String(),
+		strings.NewReader(body),
+		request.WithContentLength(int64(len(body))),
+	).CheckHTTPResponse(http.StatusAccepted).GetResponse()
+	return err
+}
+
+// Capabilities 返回存储端支持的特性
+func (handler *Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		RangeGet:        true,
+		PresignedUpload: true,
+		MultipartUpload: true,
+	}
+}
@@ -0,0 +1,231 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/mocks/requestmock"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+)
+
+func newTestDriver(t *testing.T) *Driver {
+	handler, err := NewDriver(&model.Policy{
+		Type:       "azure",
+		AccessKey:  "account",
+		SecretKey:  "c2VjcmV0",
+		BucketName: "container",
+		IsPrivate:  true,
+	})
+	assert.NoError(t, err)
+	return handler
+}
+
+func newResponse(status int, header http.Header, body string) *request.Response {
+	return &request.Response{
+		Response: &http.Response{
+			StatusCode: status,
+			Header:     header,
+			Body:       io.NopCloser(strings.NewReader(body)),
+		},
+	}
+}
+
+func TestNewDriver(t *testing.T) {
+	a := assert.New(t)
+	_, err := NewDriver(&model.Policy{SecretKey: "%"})
+	a.Error(err)
+
+	handler := newTestDriver(t)
+	a.EqualValues(25<<20, handler.Policy.OptionsSerialized.ChunkSize)
+}
+
+func TestDriver_SignServiceSAS(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t)
+
+	query, err := handler.signServiceSAS(sasOptions{
+		Blob:               "dir/a.txt",
+		Permissions:        permRead,
+		Expiry:             time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		ContentDisposition: "attachment",
+	})
+	a.NoError(err)
+	a.Equal("b", query.Get("sr"))
+	a.Equal("2022-01-01T00:00:00Z", query.Get("se"))
+	a.Equal("attachment", query.Get("rscd"))
+	a.Equal("+rb4xOxNHRuwjW2xS9ToTLHNrH70Xxsh8aHbgblXdnQ=", query.Get("sig"))
+
+	query, err = handler.signAccountSAS(permWrite, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	a.NoError(err)
+	a.Equal("TrQj6xMiTRSRvFPt521PhlhkFKxg3Sl1S6OMxMEYmM0=", query.Get("sig"))
+}
+
+func TestDriver_Source(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t)
+
+	// 私有容器
+	res, err := handler.Source(context.Background(), "dir/a.txt", 60, false, 0)
+	a.NoError(err)
+	u, _ := url.Parse(res)
+	a.Equal("account.blob.core.windows.net", u.Host)
+	a.Equal("/container/dir/a.txt", u.Path)
+	a.Equal("r", u.Query().Get("sp"))
+
+	// 公有容器，使用 CDN
+	handler.Policy.IsPrivate = false
+	handler.Policy.BaseURL = "https://cdn.com"
+	res, err = handler.Source(context.Background(), "dir/a.txt", 60, false, 0)
+	a.NoError(err)
+	a.Equal("https://cdn.com/container/dir/a.txt", res)
+
+	// 下载
+	ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, model.File{Name: "a.txt"})
+	res, err = handler.Source(ctx, "dir/a.txt", 60, true, 0)
+	a.NoError(err)
+	u, _ = url.Parse(res)
+	a.Equal("attachment; filename=\"a.txt\"", u.Query().Get("rscd"))
+}
+
+func TestDriver_Token(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t)
+	handler.Policy.OptionsSerialized.ChunkSize = 5
+	file := &fsctx.FileStream{SavePath: "dir/a.txt", Size: 12}
+	session := &serializer.UploadSession{Key: "key"}
+
+	// 文件已存在
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "HEAD", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(200, http.Header{"Content-Length": {"1"}}, ""))
+		handler.client = clientMock
+		_, err := handler.Token(context.Background(), 10, session, file)
+		clientMock.AssertExpectations(t)
+		a.Equal(ErrFileExisted, err)
+	}
+
+	// 成功
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "HEAD", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(404, http.Header{}, ""))
+		handler.client = clientMock
+		res, err := handler.Token(context.Background(), 10, session, file)
+		clientMock.AssertExpectations(t)
+		a.NoError(err)
+		a.Len(res.UploadURLs, 3)
+		a.Contains(res.Callback, "/api/v3/callback/azure/key")
+
+		u, _ := url.Parse(res.UploadURLs[2])
+		a.Equal("block", u.Query().Get("comp"))
+		a.Equal(blockID(2), u.Query().Get("blockid"))
+		a.Equal("cw", u.Query().Get("sp"))
+
+		u, _ = url.Parse(res.CompleteURL)
+		a.Equal("blocklist", u.Query().Get("comp"))
+	}
+}
+
+func TestDriver_Put(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t)
+	handler.Policy.OptionsSerialized.ChunkSize = 5
+
+	// 小文件直接上传
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "PUT", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(201, http.Header{}, ""))
+		handler.client = clientMock
+		err := handler.Put(context.Background(), &fsctx.FileStream{
+			SavePath: "a.txt",
+			Size:     3,
+			File:     io.NopCloser(strings.NewReader("123")),
+		})
+		clientMock.AssertExpectations(t)
+		a.NoError(err)
+	}
+
+	// 分块上传后提交分块列表
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "PUT", testMock.MatchedBy(func(target string) bool {
+			return strings.Contains(target, "comp=blocklist")
+		}), testMock.Anything, testMock.Anything).Return(newResponse(201, http.Header{}, ""))
+		clientMock.On("Request", "PUT", testMock.MatchedBy(func(target string) bool {
+			return strings.Contains(target, "comp=block&")
+		}), testMock.Anything, testMock.Anything).Return(newResponse(201, http.Header{}, ""))
+		handler.client = clientMock
+		err := handler.Put(context.Background(), &fsctx.FileStream{
+			SavePath: "a.txt",
+			Size:     12,
+			File:     io.NopCloser(strings.NewReader("123456789012")),
+		})
+		clientMock.AssertExpectations(t)
+		a.NoError(err)
+	}
+}
+
+func TestDriver_Delete(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t)
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "DELETE", testMock.MatchedBy(func(target string) bool {
+		return strings.Contains(target, "/1.txt")
+	}), testMock.Anything, testMock.Anything).Return(newResponse(202, http.Header{}, ""))
+	clientMock.On("Request", "DELETE", testMock.MatchedBy(func(target string) bool {
+		return strings.Contains(target, "/2.txt")
+	}), testMock.Anything, testMock.Anything).Return(newResponse(404, http.Header{}, ""))
+	clientMock.On("Request", "DELETE", testMock.MatchedBy(func(target string) bool {
+		return strings.Contains(target, "/3.txt")
+	}), testMock.Anything, testMock.Anything).Return(&request.Response{Err: errors.New("error")})
+	handler.client = clientMock
+
+	failed, err := handler.Delete(context.Background(), []string{"1.txt", "2.txt", "3.txt"})
+	clientMock.AssertExpectations(t)
+	a.Error(err)
+	a.Equal([]string{"3.txt"}, failed)
+}
+
+func TestDriver_List(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t)
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "GET", testMock.MatchedBy(func(target string) bool {
+		return !strings.Contains(target, "marker=")
+	}), testMock.Anything, testMock.Anything).Return(newResponse(200, http.Header{}, `<?xml version="1.0" encoding="utf-8"?>
+<EnumerationResults><Blobs>
+<Blob><Name>dir/a.txt</Name><Properties><Last-Modified>Mon, 27 Jan 2020 08:00:00 GMT</Last-Modified><Content-Length>10</Content-Length></Properties></Blob>
+<BlobPrefix><Name>dir/sub/</Name></BlobPrefix>
+</Blobs><NextMarker>next</NextMarker></EnumerationResults>`))
+	clientMock.On("Request", "GET", testMock.MatchedBy(func(target string) bool {
+		return strings.Contains(target, "marker=next")
+	}), testMock.Anything, testMock.Anything).Return(newResponse(200, http.Header{}, `<?xml version="1.0" encoding="utf-8"?>
+<EnumerationResults><Blobs>
+<Blob><Name>dir/b.txt</Name><Properties><Content-Length>5</Content-Length></Properties></Blob>
+</Blobs><NextMarker /></EnumerationResults>`))
+	handler.client = clientMock
+
+	res, err := handler.List(context.Background(), "/dir", false)
+	clientMock.AssertExpectations(t)
+	a.NoError(err)
+	a.Len(res, 3)
+	a.True(res[0].IsDir)
+	a.Equal("sub", res[0].Name)
+	a.Equal("a.txt", res[1].RelativePath)
+	a.EqualValues(10, res[1].Size)
+	a.Equal(2020, res[1].LastModify.Year())
+	a.Equal("dir/b.txt", res[2].Source)
+}
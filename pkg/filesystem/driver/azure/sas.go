@@ -0,0 +1,120 @@
+package azure
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// sasVersion 签名使用的服务版本
+	sasVersion = "2019-12-12"
+	// sasTimeFormat 签名中的时间格式
+	sasTimeFormat = "2006-01-02T15:04:05Z"
+)
+
+// SAS 权限
+const (
+	permRead   = "r"
+	permCreate = "c"
+	permWrite  = "w"
+	permDelete = "d"
+	permList   = "l"
+)
+
+// sasOptions 服务 SAS 签名参数
+type sasOptions struct {
+	// 签名的 Blob 名称，为空时签名整个容器
+	Blob        string
+	Permissions string
+	Expiry      time.Time
+	// 响应头 Content-Disposition 覆盖值
+	ContentDisposition string
+}
+
+// signServiceSAS 为容器或 Blob 签名服务 SAS，返回 URL 查询参数
+func (handler *Driver) signServiceSAS(opts sasOptions) (url.Values, error) {
+	key, err := base64.StdEncoding.DecodeString(handler.Policy.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	resource := "c"
+	canonicalizedResource := "/blob/" + handler.Policy.AccessKey + "/" + handler.Policy.BucketName
+	if opts.Blob != "" {
+		resource = "b"
+		canonicalizedResource += "/" + opts.Blob
+	}
+
+	expiry := opts.Expiry.UTC().Format(sasTimeFormat)
+	stringToSign := strings.Join([]string{
+		opts.Permissions,
+		"", // signedStart
+		expiry,
+		canonicalizedResource,
+		"", // signedIdentifier
+		"", // signedIP
+		"", // signedProtocol
+		sasVersion,
+		resource,
+		"", // signedSnapshotTime
+		"", // rscc
+		opts.ContentDisposition,
+		"", // rsce
+		"", // rscl
+		"", // rsct
+	}, "\n")
+
+	query := url.Values{
+		"sv":  {sasVersion},
+		"sr":  {resource},
+		"sp":  {opts.Permissions},
+		"se":  {expiry},
+		"sig": {sign(key, stringToSign)},
+	}
+	if opts.ContentDisposition != "" {
+		query.Set("rscd", opts.ContentDisposition)
+	}
+
+	return query, nil
+}
+
+// signAccountSAS 签名用于修改 Blob 服务属性的账户 SAS，返回 URL 查询参数
+func (handler *Driver) signAccountSAS(permissions string, expiry time.Time) (url.Values, error) {
+	key, err := base64.StdEncoding.DecodeString(handler.Policy.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	expiryStr := expiry.UTC().Format(sasTimeFormat)
+	stringToSign := strings.Join([]string{
+		handler.Policy.AccessKey,
+		permissions,
+		"b", // signedServices
+		"s", // signedResourceTypes
+		"",  // signedStart
+		expiryStr,
+		"", // signedIP
+		"", // signedProtocol
+		sasVersion,
+		"",
+	}, "\n")
+
+	return url.Values{
+		"sv":  {sasVersion},
+		"ss":  {"b"},
+		"srt": {"s"},
+		"sp":  {permissions},
+		"se":  {expiryStr},
+		"sig": {sign(key, stringToSign)},
+	}, nil
+}
+
+func sign(key []byte, stringToSign string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
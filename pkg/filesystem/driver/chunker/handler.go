@@ -0,0 +1,367 @@
+package chunker
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+const (
+	// metadataVersion 写入的元数据版本，与 rclone chunker 的 simplejson 格式一致
+	metadataVersion = 1
+	// maxMetadataVersion 可读取的最高元数据版本
+	maxMetadataVersion = 2
+	// maxMetadataSize 元数据文件的最大长度，超过此长度的文件视为普通文件
+	maxMetadataSize = 1023
+)
+
+var (
+	// chunkNamePattern 匹配分块文件名，如 a.txt.rclone_chunk.001
+	chunkNamePattern = regexp.MustCompile(`\.rclone_chunk\.\d{3,}$`)
+
+	ErrUnalignedChunk = errors.New("uploaded chunk is not aligned with chunker chunk size")
+)
+
+// metadata rclone chunker 的 simplejson 元数据
+type metadata struct {
+	Version *int   `json:"ver"`
+	Size    *int64 `json:"size"`
+	Chunks  *int   `json:"nchunks"`
+	MD5     string `json:"md5,omitempty"`
+	SHA1    string `json:"sha1,omitempty"`
+}
+
+// Driver 分块存储适配器，超过分块大小的文件被拆分为多个分块文件，
+// 原路径保存记录文件大小及分块数量的元数据，格式与 rclone chunker 兼容
+type Driver struct {
+	driver.Handler
+	Policy    *model.Policy
+	ChunkSize int64
+}
+
+// NewDriver 包装存储适配器，分块大小为存储策略的 ChunkerSize
+func NewDriver(handler driver.Handler, policy *model.Policy) *Driver {
+	return &Driver{
+		Handler:   handler,
+		Policy:    policy,
+		ChunkSize: int64(policy.OptionsSerialized.ChunkerSize),
+	}
+}
+
+// chunkName 返回第 index 个分块的路径，序号从 1 开始
+func chunkName(p string, index int) string {
+	return fmt.Sprintf("%s.rclone_chunk.%03d", p, index+1)
+}
+
+// IsChunk 返回路径是否为分块文件
+func IsChunk(p string) bool {
+	return chunkNamePattern.MatchString(p)
+}
+
+// readMetadata 读取 p 处的元数据，p 为普通文件时返回 nil
+func (d *Driver) readMetadata(ctx context.Context, p string) (*metadata, error) {
+	rs, err := d.Handler.Get(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil || size > maxMetadataSize {
+		return nil, err
+	}
+
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	content, err := io.ReadAll(rs)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseMetadata(content), nil
+}
+
+// parseMetadata 解析元数据，内容不是合法的元数据时返回 nil
+func parseMetadata(content []byte) *metadata {
+	var meta metadata
+	if err := json.Unmarshal(content, &meta); err != nil {
+		return nil
+	}
+
+	if meta.Version == nil || meta.Size == nil || meta.Chunks == nil ||
+		*meta.Version < 1 || *meta.Version > maxMetadataVersion || *meta.Size < 0 || *meta.Chunks < 1 {
+		return nil
+	}
+
+	return &meta
+}
+
+// putMetadata 写入元数据
+func (d *Driver) putMetadata(ctx context.Context, fileInfo *fsctx.UploadTaskInfo, size int64, chunks int, md5Sum string) error {
+	version := metadataVersion
+	content, err := json.Marshal(metadata{Version: &version, Size: &size, Chunks: &chunks, MD5: md5Sum})
+	if err != nil {
+		return err
+	}
+
+	return d.Handler.Put(ctx, &fsctx.FileStream{
+		Mode:         fileInfo.Mode &^ fsctx.Append,
+		LastModified: fileInfo.LastModified,
+		File:         io.NopCloser(strings.NewReader(string(content))),
+		Size:         uint64(len(content)),
+		Name:         fileInfo.FileName,
+		SavePath:     fileInfo.SavePath,
+		MimeType:     "application/json",
+	})
+}
+
+// putChunk 将 r 写入第 index 个分块
+func (d *Driver) putChunk(ctx context.Context, fileInfo *fsctx.UploadTaskInfo, index int, r io.ReadCloser, size uint64) error {
+	return d.Handler.Put(ctx, &fsctx.FileStream{
+		Mode:         fsctx.Overwrite,
+		LastModified: fileInfo.LastModified,
+		File:         r,
+		Size:         size,
+		Name:         fileInfo.FileName,
+		SavePath:     chunkName(fileInfo.SavePath, index),
+	})
+}
+
+// Put 保存文件，不超过分块大小的文件原样保存。
+// 分片上传时每个分片对应一个分块文件，需与分块大小对齐，由 CompleteUpload 写入元数据。
+func (d *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
+	fileInfo := file.Info()
+	if fileInfo.Mode&fsctx.Append == fsctx.Append {
+		if fileInfo.AppendStart%uint64(d.ChunkSize) != 0 || fileInfo.Size > uint64(d.ChunkSize) {
+			file.Close()
+			return ErrUnalignedChunk
+		}
+
+		index := int(fileInfo.AppendStart / uint64(d.ChunkSize))
+		return d.putChunk(ctx, fileInfo, index, file, fileInfo.Size)
+	}
+
+	if fileInfo.Size <= uint64(d.ChunkSize) {
+		return d.Handler.Put(ctx, file)
+	}
+
+	defer file.Close()
+	hash := md5.New()
+	src := io.TeeReader(file, hash)
+	chunks := int((fileInfo.Size + uint64(d.ChunkSize) - 1) / uint64(d.ChunkSize))
+	for i := 0; i < chunks; i++ {
+		size := fileInfo.Size - uint64(i)*uint64(d.ChunkSize)
+		if size > uint64(d.ChunkSize) {
+			size = uint64(d.ChunkSize)
+		}
+		reader := &countReader{r: io.LimitReader(src, int64(size))}
+		err := d.putChunk(ctx, fileInfo, i, io.NopCloser(reader), size)
+		if err == nil && reader.n != int64(size) {
+			err = io.ErrUnexpectedEOF
+		}
+
+		if err != nil {
+			d.removeChunks(ctx, fileInfo.SavePath, 0)
+			return err
+		}
+	}
+
+	if err := d.putMetadata(ctx, fileInfo, int64(fileInfo.Size), chunks, hex.EncodeToString(hash.Sum(nil))); err != nil {
+		d.removeChunks(ctx, fileInfo.SavePath, 0)
+		return err
+	}
+
+	// 清理覆盖前遗留的多余分块
+	d.removeChunks(ctx, fileInfo.SavePath, chunks)
+	return nil
+}
+
+// CompleteUpload 分片上传完成后统计已上传的分块并写入元数据
+func (d *Driver) CompleteUpload(ctx context.Context, dst string) error {
+	chunks, size, err := d.probeChunks(ctx, dst, 0)
+	if err != nil {
+		return err
+	}
+
+	if chunks == 0 {
+		return errors.New("no uploaded chunk found")
+	}
+
+	return d.putMetadata(ctx, &fsctx.UploadTaskInfo{SavePath: dst, Mode: fsctx.Overwrite}, size, chunks, "")
+}
+
+// probeChunks 从第 from 个分块起依次探测存在的分块，返回分块数量及总大小
+func (d *Driver) probeChunks(ctx context.Context, p string, from int) (int, int64, error) {
+	var total int64
+	for i := from; ; i++ {
+		rs, err := d.Handler.Get(ctx, chunkName(p, i))
+		if err != nil {
+			return i, total, nil
+		}
+
+		size, err := rs.Seek(0, io.SeekEnd)
+		rs.Close()
+		if err != nil {
+			return 0, 0, err
+		}
+
+		total += size
+	}
+}
+
+// chunkNames 返回从第 from 个分块起存在的分块路径
+func (d *Driver) chunkNames(ctx context.Context, p string, from int) []string {
+	chunks, _, _ := d.probeChunks(ctx, p, from)
+	names := make([]string, 0, chunks)
+	for i := from; i < chunks; i++ {
+		names = append(names, chunkName(p, i))
+	}
+
+	return names
+}
+
+// removeChunks 删除从第 from 个分块起存在的分块
+func (d *Driver) removeChunks(ctx context.Context, p string, from int) {
+	if names := d.chunkNames(ctx, p, from); len(names) > 0 {
+		if _, err := d.Handler.Delete(ctx, names); err != nil {
+			util.Log().Warning("Failed to delete chunks of %q: %s", p, err)
+		}
+	}
+}
+
+// Get 获取文件内容，分块保存的文件返回拼接各分块的文件流
+func (d *Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	meta, err := d.readMetadata(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if meta == nil {
+		return d.Handler.Get(ctx, path)
+	}
+
+	return &reader{
+		ctx:       ctx,
+		handler:   d.Handler,
+		path:      path,
+		size:      *meta.Size,
+		chunks:    *meta.Chunks,
+		chunkSize: d.ChunkSize,
+	}, nil
+}
+
+// Delete 删除文件及其所有分块
+func (d *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	// 分块路径 -> 所属文件
+	owners := make(map[string]string)
+	targets := make([]string, 0, len(files))
+	for _, file := range files {
+		targets = append(targets, file)
+		owners[file] = file
+
+		var names []string
+		meta, err := d.readMetadata(ctx, file)
+		if meta != nil {
+			for i := 0; i < *meta.Chunks; i++ {
+				names = append(names, chunkName(file, i))
+			}
+		} else if err != nil {
+			// 文件不存在时清理未完成上传的分块
+			names = d.chunkNames(ctx, file, 0)
+		}
+
+		for _, name := range names {
+			targets = append(targets, name)
+			owners[name] = file
+		}
+	}
+
+	failed, err := d.Handler.Delete(ctx, targets)
+	failedFiles := make([]string, 0, len(failed))
+	for _, name := range failed {
+		if owner, ok := owners[name]; ok && !util.ContainsString(failedFiles, owner) {
+			failedFiles = append(failedFiles, owner)
+		}
+	}
+
+	return failedFiles, err
+}
+
+// List 列出文件，隐藏分块文件并返回分块保存文件的实际大小
+func (d *Driver) List(ctx context.Context, path string, recursive bool) ([]response.Object, error) {
+	objects, err := d.Handler.List(ctx, path, recursive)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]response.Object, 0, len(objects))
+	for _, object := range objects {
+		if IsChunk(object.Name) {
+			continue
+		}
+
+		if !object.IsDir && object.Size <= maxMetadataSize {
+			if meta, err := d.readMetadata(ctx, object.Source); err == nil && meta != nil {
+				object.Size = uint64(*meta.Size)
+			}
+		}
+
+		res = append(res, object)
+	}
+
+	return res, nil
+}
+
+// Source 获取外链URL，分块保存的文件需由 Cloudreve 拼接后中转
+func (d *Driver) Source(ctx context.Context, path string, ttl int64, isDownload bool, speed int) (string, error) {
+	return local.Driver{Policy: d.Policy}.Source(ctx, path, ttl, isDownload, speed)
+}
+
+// CancelToken 取消上传凭证，同时删除已上传的分块
+func (d *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	if err := d.Handler.CancelToken(ctx, uploadSession); err != nil {
+		return err
+	}
+
+	if meta, err := d.readMetadata(ctx, uploadSession.SavePath); err != nil || meta == nil {
+		d.removeChunks(ctx, uploadSession.SavePath, 0)
+	}
+
+	return nil
+}
+
+// Capabilities 返回存储端支持的特性，文件需由 Cloudreve 分块后上传
+func (d *Driver) Capabilities() driver.Capabilities {
+	caps := d.Handler.Capabilities()
+	caps.PresignedUpload = false
+	caps.ServerSideCopy = false
+	return caps
+}
+
+// countReader 记录已读取的字节数
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,283 @@
+package chunker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryHandler 将文件保存在内存中的适配器
+type memoryHandler struct {
+	files map[string]string
+}
+
+type readSeekNopCloser struct {
+	*strings.Reader
+}
+
+func (readSeekNopCloser) Close() error {
+	return nil
+}
+
+func (h *memoryHandler) Put(ctx context.Context, file fsctx.FileHeader) error {
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	h.files[file.Info().SavePath] = string(content)
+	return nil
+}
+
+func (h *memoryHandler) Delete(ctx context.Context, files []string) ([]string, error) {
+	for _, f := range files {
+		delete(h.files, f)
+	}
+	return []string{}, nil
+}
+
+func (h *memoryHandler) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	content, ok := h.files[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return readSeekNopCloser{strings.NewReader(content)}, nil
+}
+
+func (h *memoryHandler) Thumb(ctx context.Context, file *model.File) (*response.ContentResponse, error) {
+	return nil, driver.ErrorThumbNotSupported
+}
+
+func (h *memoryHandler) Source(ctx context.Context, path string, ttl int64, isDownload bool, speed int) (string, error) {
+	return "memory://" + path, nil
+}
+
+func (h *memoryHandler) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
+	return &serializer.UploadCredential{SessionID: uploadSession.Key}, nil
+}
+
+func (h *memoryHandler) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	return nil
+}
+
+func (h *memoryHandler) Capabilities() driver.Capabilities {
+	return driver.Capabilities{RangeGet: true, MultipartUpload: true, ServerSideCopy: true}
+}
+
+func (h *memoryHandler) List(ctx context.Context, path string, recursive bool) ([]response.Object, error) {
+	res := make([]response.Object, 0, len(h.files))
+	for name, content := range h.files {
+		res = append(res, response.Object{Name: name, Source: name, Size: uint64(len(content))})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+func newTestDriver() (*Driver, *memoryHandler) {
+	inner := &memoryHandler{files: map[string]string{}}
+	policy := &model.Policy{}
+	policy.OptionsSerialized.ChunkerSize = 4
+	return NewDriver(inner, policy), inner
+}
+
+func newStream(content, savePath string) *fsctx.FileStream {
+	return &fsctx.FileStream{
+		File:     io.NopCloser(strings.NewReader(content)),
+		Size:     uint64(len(content)),
+		SavePath: savePath,
+		Mode:     fsctx.Overwrite,
+	}
+}
+
+func readAll(t *testing.T, handler *Driver, path string) string {
+	rs, err := handler.Get(context.Background(), path)
+	assert.NoError(t, err)
+	defer rs.Close()
+	content, err := io.ReadAll(rs)
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestDriver_Put(t *testing.T) {
+	a := assert.New(t)
+
+	// 小文件原样保存
+	{
+		handler, inner := newTestDriver()
+		a.NoError(handler.Put(context.Background(), newStream("abc", "a.txt")))
+		a.Equal(map[string]string{"a.txt": "abc"}, inner.files)
+		a.Equal("abc", readAll(t, handler, "a.txt"))
+	}
+
+	// 大文件拆分保存
+	{
+		handler, inner := newTestDriver()
+		a.NoError(handler.Put(context.Background(), newStream("0123456789", "a.txt")))
+		a.Equal("0123", inner.files["a.txt.rclone_chunk.001"])
+		a.Equal("4567", inner.files["a.txt.rclone_chunk.002"])
+		a.Equal("89", inner.files["a.txt.rclone_chunk.003"])
+
+		var meta map[string]interface{}
+		a.NoError(json.Unmarshal([]byte(inner.files["a.txt"]), &meta))
+		a.EqualValues(1, meta["ver"])
+		a.EqualValues(10, meta["size"])
+		a.EqualValues(3, meta["nchunks"])
+		a.Equal("781e5e245d69b566979b86e28d23f2c7", meta["md5"])
+		a.Equal("0123456789", readAll(t, handler, "a.txt"))
+
+		// 覆盖为较小的文件时清理多余分块
+		a.NoError(handler.Put(context.Background(), newStream("abcdefg", "a.txt")))
+		a.NotContains(inner.files, "a.txt.rclone_chunk.003")
+		a.Equal("abcdefg", readAll(t, handler, "a.txt"))
+	}
+
+	// 文件流长度不足
+	{
+		handler, inner := newTestDriver()
+		stream := newStream("01234", "a.txt")
+		stream.Size = 10
+		a.ErrorIs(handler.Put(context.Background(), stream), io.ErrUnexpectedEOF)
+		a.Empty(inner.files)
+	}
+}
+
+func TestDriver_ChunkUpload(t *testing.T) {
+	a := assert.New(t)
+	handler, inner := newTestDriver()
+
+	for i, chunk := range []string{"0123", "4567", "89"} {
+		stream := newStream(chunk, "a.txt")
+		stream.Mode = fsctx.Append | fsctx.Overwrite
+		stream.AppendStart = uint64(i * 4)
+		a.NoError(handler.Put(context.Background(), stream))
+	}
+
+	a.NotContains(inner.files, "a.txt")
+	a.NoError(handler.CompleteUpload(context.Background(), "a.txt"))
+	a.Equal("0123456789", readAll(t, handler, "a.txt"))
+
+	meta := parseMetadata([]byte(inner.files["a.txt"]))
+	a.NotNil(meta)
+	a.EqualValues(10, *meta.Size)
+	a.Equal(3, *meta.Chunks)
+
+	// 分片未对齐
+	stream := newStream("45", "b.txt")
+	stream.Mode = fsctx.Append
+	stream.AppendStart = 2
+	a.ErrorIs(handler.Put(context.Background(), stream), ErrUnalignedChunk)
+
+	// 没有已上传的分块
+	a.Error(handler.CompleteUpload(context.Background(), "c.txt"))
+}
+
+func TestDriver_Get(t *testing.T) {
+	a := assert.New(t)
+	handler, inner := newTestDriver()
+	a.NoError(handler.Put(context.Background(), newStream("0123456789", "a.txt")))
+
+	rs, err := handler.Get(context.Background(), "a.txt")
+	a.NoError(err)
+	defer rs.Close()
+
+	size, err := rs.Seek(0, io.SeekEnd)
+	a.NoError(err)
+	a.EqualValues(10, size)
+
+	_, err = rs.Seek(3, io.SeekStart)
+	a.NoError(err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(rs, buf)
+	a.NoError(err)
+	a.Equal("3456", string(buf))
+
+	_, err = rs.Seek(-2, io.SeekEnd)
+	a.NoError(err)
+	content, err := io.ReadAll(rs)
+	a.NoError(err)
+	a.Equal("89", string(content))
+
+	// 分块缺失
+	delete(inner.files, "a.txt.rclone_chunk.002")
+	rs, err = handler.Get(context.Background(), "a.txt")
+	a.NoError(err)
+	_, err = io.ReadAll(rs)
+	a.True(errors.Is(err, os.ErrNotExist))
+
+	// 不存在的文件
+	_, err = handler.Get(context.Background(), "b.txt")
+	a.ErrorIs(err, os.ErrNotExist)
+}
+
+func TestDriver_Delete(t *testing.T) {
+	a := assert.New(t)
+	handler, inner := newTestDriver()
+	a.NoError(handler.Put(context.Background(), newStream("0123456789", "a.txt")))
+	a.NoError(handler.Put(context.Background(), newStream("abc", "b.txt")))
+	inner.files["c.txt.rclone_chunk.001"] = "0123"
+
+	failed, err := handler.Delete(context.Background(), []string{"a.txt", "b.txt", "c.txt"})
+	a.NoError(err)
+	a.Empty(failed)
+	a.Empty(inner.files)
+}
+
+func TestDriver_List(t *testing.T) {
+	a := assert.New(t)
+	handler, _ := newTestDriver()
+	a.NoError(handler.Put(context.Background(), newStream("0123456789", "a.txt")))
+	a.NoError(handler.Put(context.Background(), newStream("abc", "b.txt")))
+
+	objects, err := handler.List(context.Background(), "/", true)
+	a.NoError(err)
+	a.Len(objects, 2)
+	a.Equal("a.txt", objects[0].Name)
+	a.EqualValues(10, objects[0].Size)
+	a.Equal("b.txt", objects[1].Name)
+	a.EqualValues(3, objects[1].Size)
+}
+
+func TestDriver_CancelToken(t *testing.T) {
+	a := assert.New(t)
+	handler, inner := newTestDriver()
+	inner.files["a.txt.rclone_chunk.001"] = "0123"
+	inner.files["a.txt.rclone_chunk.002"] = "4567"
+
+	a.NoError(handler.CancelToken(context.Background(), &serializer.UploadSession{SavePath: "a.txt"}))
+	a.Empty(inner.files)
+}
+
+func TestDriver_Capabilities(t *testing.T) {
+	a := assert.New(t)
+	handler, _ := newTestDriver()
+	caps := handler.Capabilities()
+	a.True(caps.RangeGet)
+	a.True(caps.MultipartUpload)
+	a.False(caps.ServerSideCopy)
+	a.False(caps.PresignedUpload)
+}
+
+func TestParseMetadata(t *testing.T) {
+	a := assert.New(t)
+	a.NotNil(parseMetadata([]byte(`{"ver":1,"size":10,"nchunks":3,"md5":"abc"}`)))
+	a.NotNil(parseMetadata([]byte(`{"ver":2,"size":0,"nchunks":1}`)))
+	a.Nil(parseMetadata([]byte(`{"ver":3,"size":10,"nchunks":3}`)))
+	a.Nil(parseMetadata([]byte(`{"size":10,"nchunks":3}`)))
+	a.Nil(parseMetadata([]byte(`{"ver":1,"size":10,"nchunks":0}`)))
+	a.Nil(parseMetadata([]byte(`plain text`)))
+	a.True(IsChunk("a.txt.rclone_chunk.001"))
+	a.False(IsChunk("a.txt"))
+}
@@ -0,0 +1,111 @@
+package chunker
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+)
+
+// reader 按偏移量依次读取各分块，Seek 后按需打开对应的分块
+type reader struct {
+	ctx       context.Context
+	handler   driver.Handler
+	path      string
+	size      int64
+	chunks    int
+	chunkSize int64
+
+	offset int64
+	// current 当前打开的分块及其序号，currentPos 为其读取位置对应的文件偏移量
+	current    response.RSCloser
+	index      int
+	currentPos int64
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.current == nil || r.currentPos != r.offset {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.current.Read(p)
+	r.offset += int64(n)
+	r.currentPos = r.offset
+	if err == io.EOF {
+		// 分块读取完毕，其长度需与分块大小一致
+		r.closeCurrent()
+		err = nil
+		if r.offset < r.size && r.offset != int64(r.index+1)*r.chunkSize {
+			err = io.ErrUnexpectedEOF
+		} else if n == 0 {
+			return r.Read(p)
+		}
+	}
+
+	return n, err
+}
+
+// open 打开当前偏移量所在的分块并定位
+func (r *reader) open() error {
+	index := int(r.offset / r.chunkSize)
+	if index >= r.chunks {
+		return errors.New("chunk index out of range")
+	}
+
+	if r.current == nil || r.index != index {
+		r.closeCurrent()
+		rs, err := r.handler.Get(r.ctx, chunkName(r.path, index))
+		if err != nil {
+			return err
+		}
+
+		r.current = rs
+		r.index = index
+	}
+
+	if _, err := r.current.Seek(r.offset-int64(index)*r.chunkSize, io.SeekStart); err != nil {
+		return err
+	}
+
+	r.currentPos = r.offset
+	return nil
+}
+
+func (r *reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *reader) closeCurrent() {
+	if r.current != nil {
+		r.current.Close()
+		r.current = nil
+	}
+}
+
+func (r *reader) Close() error {
+	r.closeCurrent()
+	return nil
+}
@@ -17,6 +17,8 @@ import (
 	"time"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/chunk"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/chunk/backoff"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
@@ -27,6 +29,13 @@ import (
 	cossdk "github.com/tencentyun/cos-go-sdk-v5"
 )
 
+const (
+	chunkRetrySleep = time.Duration(5) * time.Second
+
+	// maxCopySize 单次复制请求支持的最大文件大小
+	maxCopySize uint64 = 5 << 30 // 5GB
+)
+
 // UploadPolicy 腾讯云COS上传策略
 type UploadPolicy struct {
 	Expiration string        `json:"expiration"`
@@ -156,19 +165,20 @@ func (handler Driver) Get(ctx context.Context, path string) (response.RSCloser,
 	}
 
 	// 获取文件数据流
-	resp, err := handler.HTTPClient.Request(
-		"GET",
-		downloadURL,
-		nil,
-		request.WithContext(ctx),
-		request.WithTimeout(time.Duration(0)),
-	).CheckHTTPResponse(200).GetRSCloser()
+	resp, err := request.NewRangeRSCloser(func(header http.Header) *request.Response {
+		return handler.HTTPClient.Request(
+			"GET",
+			downloadURL,
+			nil,
+			request.WithContext(ctx),
+			request.WithHeader(header),
+			request.WithTimeout(time.Duration(0)),
+		)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	resp.SetFirstFakeChunk()
-
 	// 尝试自主获取文件大小
 	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
 		resp.SetContentLength(int64(file.Size))
@@ -180,9 +190,63 @@ func (handler Driver) Get(ctx context.Context, path string) (response.RSCloser,
 // Put 将文件流保存到指定目录
 func (handler Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
 	defer file.Close()
+	fileInfo := file.Info()
+
+	// 小文件直接上传
+	chunkSize := handler.Policy.OptionsSerialized.ChunkSize
+	if chunkSize == 0 || fileInfo.Size <= chunkSize {
+		opt := &cossdk.ObjectPutOptions{}
+		_, err := handler.Client.Object.Put(ctx, fileInfo.SavePath, file, opt)
+		return err
+	}
+
+	// 超过分片大小时使用分片上传
+	imur, _, err := handler.Client.Object.InitiateMultipartUpload(ctx, fileInfo.SavePath, nil)
+	if err != nil {
+		return fmt.Errorf("failed to initiate multipart upload: %w", err)
+	}
+
+	retries := model.GetIntSetting("chunk_retries", 5)
+	newBackoff := func() backoff.Backoff {
+		return &backoff.ConstantBackoff{Max: retries, Sleep: chunkRetrySleep}
+	}
+
+	parts := make([]cossdk.Object, (fileInfo.Size+chunkSize-1)/chunkSize)
+	uploadFunc := func(ctx context.Context, index int, content io.ReadSeeker, length int64) error {
+		resp, err := handler.Client.Object.UploadPart(ctx, fileInfo.SavePath, imur.UploadID, index+1,
+			content, &cossdk.ObjectUploadPartOptions{ContentLength: int(length)})
+		if err != nil {
+			return err
+		}
 
-	opt := &cossdk.ObjectPutOptions{}
-	_, err := handler.Client.Object.Put(ctx, file.Info().SavePath, file, opt)
+		parts[index] = cossdk.Object{PartNumber: index + 1, ETag: resp.Header.Get("ETag")}
+		return nil
+	}
+
+	err = chunk.ProcessParallel(ctx, file, fileInfo.Size, chunkSize, handler.Policy.OptionsSerialized.UploadConcurrency,
+		model.IsTrueVal(model.GetSettingByName("use_temp_chunk_buffer")), newBackoff, uploadFunc)
+	if err != nil {
+		// 终止未完成的分片上传，释放已上传的分片
+		if _, abortErr := handler.Client.Object.AbortMultipartUpload(context.Background(), fileInfo.SavePath, imur.UploadID); abortErr != nil {
+			util.Log().Warning("Failed to abort multipart upload %q: %s", imur.UploadID, abortErr)
+		}
+
+		return err
+	}
+
+	_, _, err = handler.Client.Object.CompleteMultipartUpload(ctx, fileInfo.SavePath, imur.UploadID, &cossdk.CompleteMultipartUploadOptions{Parts: parts})
+	return err
+}
+
+// Copy 在存储桶内将 src 复制到 dst 的存储路径
+func (handler Driver) Copy(ctx context.Context, src string, dst fsctx.FileHeader) error {
+	fileInfo := dst.Info()
+	if fileInfo.Size > maxCopySize {
+		return errors.New("file is too large to copy in bucket")
+	}
+
+	sourceURL := handler.Client.BaseURL.BucketURL.Host + "/" + src
+	_, _, err := handler.Client.Object.Copy(ctx, fileInfo.SavePath, sourceURL, nil)
 	return err
 }
 
@@ -425,3 +489,14 @@ func (handler Driver) getUploadCredential(ctx context.Context, policy UploadPoli
 		KeyTime:    keyTime,
 	}, nil
 }
+
+// Capabilities 返回存储端支持的特性
+func (handler Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		RangeGet:        true,
+		ServerSideCopy:  true,
+		PresignedUpload: true,
+		MultipartUpload: true,
+		Thumbnail:       true,
+	}
+}
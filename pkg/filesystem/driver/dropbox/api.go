@@ -0,0 +1,377 @@
+package dropbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/chunk"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/chunk/backoff"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+const (
+	// SmallFileSize 单文件上传接口最大尺寸，超出时使用上传会话
+	SmallFileSize uint64 = 150 * 1024 * 1024
+	// ListLimit 单次列取目录的最大条目数
+	ListLimit       = 2000
+	chunkRetrySleep = time.Second * 5
+)
+
+// ErrObjectNotExist 文件不存在
+var ErrObjectNotExist = errors.New("object not exist")
+
+// apiPath 将存储路径转换为 Dropbox 接口使用的路径，根目录为空字符串
+func apiPath(p string) string {
+	p = path.Clean("/" + filepath.ToSlash(p))
+	if p == "/" {
+		return ""
+	}
+
+	return p
+}
+
+// writeMode 返回提交文件时的写入模式
+func writeMode(overwrite bool) string {
+	if overwrite {
+		return "overwrite"
+	}
+
+	return "add"
+}
+
+// headerArg 将接口参数编码为 Dropbox-API-Arg 请求头，非 ASCII 字符需转义
+func headerArg(arg interface{}) (string, error) {
+	raw, err := json.Marshal(arg)
+	if err != nil {
+		return "", err
+	}
+
+	var buf strings.Builder
+	for _, r := range string(raw) {
+		if r < 0x7f {
+			buf.WriteRune(r)
+			continue
+		}
+
+		if r > 0xffff {
+			r1, r2 := utf16.EncodeRune(r)
+			fmt.Fprintf(&buf, "\\u%04x\\u%04x", r1, r2)
+			continue
+		}
+
+		fmt.Fprintf(&buf, "\\u%04x", r)
+	}
+
+	return buf.String(), nil
+}
+
+// IsNotFound 返回错误是否为文件不存在
+func IsNotFound(err error) bool {
+	if errors.Is(err, ErrObjectNotExist) {
+		return true
+	}
+
+	var apiErr *RespError
+	return errors.As(err, &apiErr) && strings.Contains(apiErr.Summary, "not_found")
+}
+
+// Meta 根据路径获取文件元信息
+func (client *Client) Meta(ctx context.Context, p string) (*Metadata, error) {
+	target := apiPath(p)
+	if target == "" {
+		return &Metadata{Tag: "folder", Name: "/"}, nil
+	}
+
+	var res Metadata
+	if err := client.rpc(ctx, "files/get_metadata", map[string]interface{}{
+		"path": target,
+	}, &res); err != nil {
+		if IsNotFound(err) {
+			return nil, ErrObjectNotExist
+		}
+
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// ListFolder 列取目录下的文件
+func (client *Client) ListFolder(ctx context.Context, p string, recursive bool) ([]Metadata, error) {
+	var (
+		res  []Metadata
+		list ListFolderResult
+	)
+
+	if err := client.rpc(ctx, "files/list_folder", map[string]interface{}{
+		"path":      apiPath(p),
+		"recursive": recursive,
+		"limit":     ListLimit,
+	}, &list); err != nil {
+		return nil, err
+	}
+
+	for {
+		for _, entry := range list.Entries {
+			if entry.Tag != "deleted" {
+				res = append(res, entry)
+			}
+		}
+
+		if !list.HasMore {
+			return res, nil
+		}
+
+		cursor := list.Cursor
+		list = ListFolderResult{}
+		if err := client.rpc(ctx, "files/list_folder/continue", map[string]string{
+			"cursor": cursor,
+		}, &list); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// GetTemporaryLink 获取文件的临时下载地址，有效期为 4 小时
+func (client *Client) GetTemporaryLink(ctx context.Context, p string) (*TemporaryLink, error) {
+	var res TemporaryLink
+	if err := client.rpc(ctx, "files/get_temporary_link", map[string]string{
+		"path": apiPath(p),
+	}, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// Delete 删除文件，返回删除失败的文件及遇到的最后一个错误
+func (client *Client) Delete(ctx context.Context, files []string) ([]string, error) {
+	failed := make([]string, 0, len(files))
+	var retErr error
+
+	for _, file := range files {
+		err := client.rpc(ctx, "files/delete_v2", map[string]string{
+			"path": apiPath(file),
+		}, nil)
+
+		// 文件不存在时视为删除成功
+		if err == nil || IsNotFound(err) {
+			continue
+		}
+
+		util.Log().Warning("Failed to delete file %q: %s", file, err)
+		failed = append(failed, file)
+		retErr = err
+	}
+
+	return failed, retErr
+}
+
+// UploadSmall 使用单文件上传接口上传文件
+func (client *Client) UploadSmall(ctx context.Context, dst string, content io.Reader, size uint64, overwrite bool) error {
+	return client.content(ctx, "files/upload", commitInfo{
+		Path: apiPath(dst),
+		Mode: writeMode(overwrite),
+		Mute: true,
+	}, content, size, nil)
+}
+
+// StartUploadSession 创建上传会话并上传首个分片，返回会话 ID
+func (client *Client) StartUploadSession(ctx context.Context, content io.Reader, size uint64) (string, error) {
+	var res struct {
+		SessionID string `json:"session_id"`
+	}
+
+	if err := client.content(ctx, "files/upload_session/start", map[string]bool{
+		"close": false,
+	}, content, size, &res); err != nil {
+		return "", err
+	}
+
+	return res.SessionID, nil
+}
+
+// AppendUploadSession 向上传会话的 offset 位置追加分片
+func (client *Client) AppendUploadSession(ctx context.Context, sessionID string, offset uint64, content io.Reader, size uint64) error {
+	return client.content(ctx, "files/upload_session/append_v2", map[string]interface{}{
+		"cursor": uploadSessionCursor{SessionID: sessionID, Offset: offset},
+		"close":  false,
+	}, content, size, nil)
+}
+
+// FinishUploadSession 完成上传会话，将已上传的 offset 字节提交至 dst
+func (client *Client) FinishUploadSession(ctx context.Context, sessionID string, offset uint64, dst string, overwrite bool) error {
+	return client.content(ctx, "files/upload_session/finish", map[string]interface{}{
+		"cursor": uploadSessionCursor{SessionID: sessionID, Offset: offset},
+		"commit": commitInfo{
+			Path: apiPath(dst),
+			Mode: writeMode(overwrite),
+			Mute: true,
+		},
+	}, nil, 0, nil)
+}
+
+// Upload 上传文件，大文件使用上传会话分片上传
+func (client *Client) Upload(ctx context.Context, file fsctx.FileHeader) error {
+	fileInfo := file.Info()
+	overwrite := fileInfo.Mode&fsctx.Overwrite == fsctx.Overwrite
+
+	chunkSize := client.Policy.OptionsSerialized.ChunkSize
+	if chunkSize == 0 || chunkSize > SmallFileSize {
+		chunkSize = SmallFileSize
+	}
+
+	if fileInfo.Size <= chunkSize {
+		return client.UploadSmall(ctx, fileInfo.SavePath, file, fileInfo.Size, overwrite)
+	}
+
+	sessionID, err := client.StartUploadSession(ctx, nil, 0)
+	if err != nil {
+		return err
+	}
+
+	chunks := chunk.NewChunkGroup(file, chunkSize, &backoff.ConstantBackoff{
+		Max:   model.GetIntSetting("chunk_retries", 5),
+		Sleep: chunkRetrySleep,
+	}, model.IsTrueVal(model.GetSettingByName("use_temp_chunk_buffer")))
+
+	uploadFunc := func(current *chunk.ChunkGroup, content io.Reader) error {
+		return client.AppendUploadSession(ctx, sessionID, uint64(current.Start()), content, uint64(current.Length()))
+	}
+
+	for chunks.Next() {
+		if err := chunks.Process(uploadFunc); err != nil {
+			return fmt.Errorf("failed to upload chunk #%d: %w", chunks.Index(), err)
+		}
+	}
+
+	return client.FinishUploadSession(ctx, sessionID, fileInfo.Size, fileInfo.SavePath, overwrite)
+}
+
+// rpc 请求 RPC 接口，参数与结果均为 JSON
+func (client *Client) rpc(ctx context.Context, api string, arg interface{}, result interface{}) error {
+	body, err := json.Marshal(arg)
+	if err != nil {
+		return err
+	}
+
+	respBody, err := client.request(ctx, client.Endpoints.APIEndpoint+"/"+api, bytes.NewReader(body),
+		request.WithContentLength(int64(len(body))),
+		request.WithHeader(http.Header{
+			"Content-Type": {"application/json"},
+		}),
+	)
+	if err != nil {
+		return err
+	}
+
+	if result != nil {
+		return json.Unmarshal([]byte(respBody), result)
+	}
+
+	return nil
+}
+
+// content 请求上传接口，参数通过 Dropbox-API-Arg 请求头传递
+func (client *Client) content(ctx context.Context, api string, arg interface{}, content io.Reader, size uint64, result interface{}) error {
+	apiArg, err := headerArg(arg)
+	if err != nil {
+		return err
+	}
+
+	if content == nil {
+		content = strings.NewReader("")
+	}
+
+	respBody, err := client.request(ctx, client.Endpoints.ContentEndpoint+"/"+api, content,
+		request.WithContentLength(int64(size)),
+		request.WithHeader(http.Header{
+			"Content-Type":    {"application/octet-stream"},
+			"Dropbox-API-Arg": {apiArg},
+		}),
+		request.WithTimeout(time.Duration(0)),
+	)
+	if err != nil {
+		return err
+	}
+
+	if result != nil {
+		return json.Unmarshal([]byte(respBody), result)
+	}
+
+	return nil
+}
+
+func sysError(err error) *RespError {
+	return &RespError{Summary: err.Error()}
+}
+
+func (client *Client) request(ctx context.Context, url string, body io.Reader, option ...request.Option) (string, error) {
+	// 获取凭证
+	err := client.UpdateCredential(ctx, conf.SystemConfig.Mode == "slave")
+	if err != nil {
+		return "", sysError(err)
+	}
+
+	option = append(option,
+		request.WithHeader(http.Header{
+			"Authorization": {"Bearer " + client.Credential.AccessToken},
+		}),
+		request.WithContext(ctx),
+		request.WithTPSLimit(
+			fmt.Sprintf("policy_%d", client.Policy.ID),
+			client.Policy.OptionsSerialized.TPSLimit,
+			client.Policy.OptionsSerialized.TPSLimitBurst,
+		),
+	)
+
+	// 发送请求
+	res := client.Request.Request(
+		"POST",
+		url,
+		body,
+		option...,
+	)
+
+	if res.Err != nil {
+		return "", sysError(res.Err)
+	}
+
+	respBody, err := res.GetResponse()
+	if err != nil {
+		return "", sysError(err)
+	}
+
+	// 如果有错误
+	if res.Response.StatusCode < 200 || res.Response.StatusCode >= 300 {
+		errResp := &RespError{StatusCode: res.Response.StatusCode}
+		if decodeErr := json.Unmarshal([]byte(respBody), errResp); decodeErr != nil || errResp.Summary == "" {
+			// 参数错误等情况下返回纯文本
+			util.Log().Debug("Dropbox returns unknown response: %s", respBody)
+			errResp.Summary = strings.TrimSpace(respBody)
+		}
+
+		if res.Response.StatusCode == 429 {
+			util.Log().Warning("Dropbox request is throttled.")
+			return "", backoff.NewRetryableErrorFromHeader(errResp, res.Response.Header)
+		}
+
+		return "", errResp
+	}
+
+	return respBody, nil
+}
@@ -0,0 +1,68 @@
+package dropbox
+
+import (
+	"errors"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cluster"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+)
+
+// Client Dropbox 客户端
+type Client struct {
+	Endpoints  *Endpoints
+	Policy     *model.Policy
+	Credential *Credential
+
+	ClientID     string
+	ClientSecret string
+	Redirect     string
+
+	Request           request.Client
+	ClusterController cluster.Controller
+}
+
+// Endpoints Dropbox 客户端相关设置
+type Endpoints struct {
+	UserConsentEndpoint string // OAuth认证的基URL
+	TokenEndpoint       string // OAuth token 基URL
+	APIEndpoint         string // RPC 接口请求的基URL
+	ContentEndpoint     string // 上传下载接口的基URL
+}
+
+const (
+	TokenCachePrefix = "dropbox_"
+
+	oauthEndpoint     = "https://api.dropboxapi.com/oauth2/token"
+	userConsentBase   = "https://www.dropbox.com/oauth2/authorize"
+	v2APIEndpoint     = "https://api.dropboxapi.com/2"
+	v2ContentEndpoint = "https://content.dropboxapi.com/2"
+)
+
+var (
+	// ErrInvalidRefreshToken 上传策略无有效的RefreshToken
+	ErrInvalidRefreshToken = errors.New("no valid refresh token in this policy")
+)
+
+// NewClient 根据存储策略获取新的client
+func NewClient(policy *model.Policy) (*Client, error) {
+	client := &Client{
+		Endpoints: &Endpoints{
+			TokenEndpoint:       oauthEndpoint,
+			UserConsentEndpoint: userConsentBase,
+			APIEndpoint:         v2APIEndpoint,
+			ContentEndpoint:     v2ContentEndpoint,
+		},
+		Credential: &Credential{
+			RefreshToken: policy.AccessKey,
+		},
+		Policy:            policy,
+		ClientID:          policy.BucketName,
+		ClientSecret:      policy.SecretKey,
+		Redirect:          policy.OptionsSerialized.OauthRedirect,
+		Request:           request.NewClient(request.WithProxy(policy.OptionsSerialized.Proxy)),
+		ClusterController: cluster.DefaultController,
+	}
+
+	return client, nil
+}
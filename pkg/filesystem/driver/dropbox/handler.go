@@ -0,0 +1,248 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+const (
+	// sourceCacheTTL 临时下载地址的缓存时长，需小于其 4 小时的有效期
+	sourceCacheTTL = 3600
+	// uploadStateTTL 中转上传会话状态的缓存时长，与 Dropbox 上传会话的 7 天有效期一致
+	uploadStateTTL = 7 * 24 * 3600
+)
+
+var (
+	ErrFileExisted           = errors.New("file with the same name existed or unavailable")
+	ErrChunkOffset           = errors.New("size of unfinished uploaded chunks is not as expected")
+	ErrUploadSessionNotFound = errors.New("dropbox upload session not found or expired")
+)
+
+// Driver Dropbox 适配器，Policy 中 BucketName 为 App key，SecretKey 为 App secret，
+// AccessKey 为授权后获得的 RefreshToken
+type Driver struct {
+	Policy     *model.Policy
+	Client     *Client
+	HTTPClient request.Client
+}
+
+// NewDriver 从存储策略初始化新的Driver实例
+func NewDriver(policy *model.Policy) (*Driver, error) {
+	client, err := NewClient(policy)
+	if policy.OptionsSerialized.ChunkSize == 0 {
+		policy.OptionsSerialized.ChunkSize = 50 << 20 // 50MB
+	}
+
+	return &Driver{
+		Policy:     policy,
+		Client:     client,
+		HTTPClient: request.NewClient(request.WithProxy(policy.OptionsSerialized.Proxy)),
+	}, err
+}
+
+// uploadStateKey 返回中转上传会话状态的缓存键
+func (handler *Driver) uploadStateKey(savePath string) string {
+	return fmt.Sprintf("dropbox_upload_%d_%s", handler.Policy.ID, savePath)
+}
+
+// List 列取项目
+func (handler *Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	root := apiPath(base)
+	objects, err := handler.Client.ListFolder(ctx, base, recursive)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]response.Object, 0, len(objects))
+	for _, object := range objects {
+		// 返回的路径大小写可能与请求的不同，按前缀长度截取相对路径
+		if len(object.PathDisplay) <= len(root) {
+			continue
+		}
+
+		rel := strings.TrimPrefix(object.PathDisplay[len(root):], "/")
+		if rel == "" {
+			continue
+		}
+
+		res = append(res, response.Object{
+			Name:         object.Name,
+			RelativePath: rel,
+			Source:       path.Join(strings.TrimPrefix(root, "/"), rel),
+			Size:         object.Size,
+			IsDir:        object.IsFolder(),
+			LastModify:   object.ServerModified,
+		})
+	}
+
+	return res, nil
+}
+
+// Get 获取文件
+func (handler *Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	link, err := handler.Client.GetTemporaryLink(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// 获取文件数据流
+	resp, err := request.NewRangeRSCloser(func(header http.Header) *request.Response {
+		return handler.HTTPClient.Request(
+			"GET",
+			link.Link,
+			nil,
+			request.WithContext(ctx),
+			request.WithHeader(header),
+			request.WithTimeout(time.Duration(0)),
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp.SetContentLength(int64(link.Metadata.Size))
+	return resp, nil
+}
+
+// Put 将文件流保存到指定目录。客户端分片上传时，分片由 Cloudreve 中转
+// 追加至 Dropbox 上传会话，最后一个分片上传后由 CompleteUpload 提交
+func (handler *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
+	defer file.Close()
+	fileInfo := file.Info()
+	overwrite := fileInfo.Mode&fsctx.Overwrite == fsctx.Overwrite
+
+	if fileInfo.Mode&fsctx.Append != fsctx.Append {
+		return handler.Client.Upload(ctx, file)
+	}
+
+	key := handler.uploadStateKey(fileInfo.SavePath)
+	if fileInfo.AppendStart == 0 {
+		// 如果非 Overwrite，则检查是否有重名冲突
+		if !overwrite {
+			if _, err := handler.Client.Meta(ctx, fileInfo.SavePath); err == nil {
+				util.Log().Warning("File with the same name existed or unavailable: %s", fileInfo.SavePath)
+				return ErrFileExisted
+			} else if !IsNotFound(err) {
+				return err
+			}
+		}
+
+		sessionID, err := handler.Client.StartUploadSession(ctx, file, fileInfo.Size)
+		if err != nil {
+			return err
+		}
+
+		return cache.Set(key, uploadState{SessionID: sessionID, Offset: fileInfo.Size}, uploadStateTTL)
+	}
+
+	cached, ok := cache.Get(key)
+	if !ok {
+		return ErrUploadSessionNotFound
+	}
+
+	state := cached.(uploadState)
+	if fileInfo.AppendStart+fileInfo.Size == state.Offset {
+		// 分片已被接收，客户端重试时直接返回
+		return nil
+	}
+
+	if fileInfo.AppendStart != state.Offset {
+		return ErrChunkOffset
+	}
+
+	if err := handler.Client.AppendUploadSession(ctx, state.SessionID, state.Offset, file, fileInfo.Size); err != nil {
+		return err
+	}
+
+	state.Offset += fileInfo.Size
+	return cache.Set(key, state, uploadStateTTL)
+}
+
+// CompleteUpload 提交中转上传的上传会话
+func (handler *Driver) CompleteUpload(ctx context.Context, dst string) error {
+	key := handler.uploadStateKey(dst)
+	cached, ok := cache.Get(key)
+	if !ok {
+		return ErrUploadSessionNotFound
+	}
+
+	state := cached.(uploadState)
+	if err := handler.Client.FinishUploadSession(ctx, state.SessionID, state.Offset, dst, true); err != nil {
+		return err
+	}
+
+	return cache.Deletes([]string{key}, "")
+}
+
+// Delete 删除一个或多个文件，
+// 返回未删除的文件，及遇到的最后一个错误
+func (handler *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	return handler.Client.Delete(ctx, files)
+}
+
+// Thumb 获取文件缩略图
+func (handler *Driver) Thumb(ctx context.Context, file *model.File) (*response.ContentResponse, error) {
+	return nil, driver.ErrorThumbNotSupported
+}
+
+// Source 获取外链URL，使用 Dropbox 的临时下载地址
+func (handler *Driver) Source(ctx context.Context, path string, ttl int64, isDownload bool, speed int) (string, error) {
+	cacheKey := fmt.Sprintf("dropbox_source_%d_%s", handler.Policy.ID, path)
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
+		cacheKey = fmt.Sprintf("dropbox_source_file_%d_%d", file.UpdatedAt.Unix(), file.ID)
+	}
+
+	// 尝试从缓存中查找
+	if cachedURL, ok := cache.Get(cacheKey); ok {
+		return cachedURL.(string), nil
+	}
+
+	// 缓存不存在，重新获取
+	link, err := handler.Client.GetTemporaryLink(ctx, path)
+	if err != nil {
+		return "", err
+	}
+
+	cache.Set(cacheKey, link.Link, sourceCacheTTL)
+	return link.Link, nil
+}
+
+// Token 获取上传凭证，分片由 Cloudreve 中转上传
+func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
+	if _, err := handler.Client.Meta(ctx, uploadSession.SavePath); err == nil {
+		return nil, errors.New("placeholder file already exist")
+	} else if !IsNotFound(err) {
+		return nil, err
+	}
+
+	return &serializer.UploadCredential{
+		SessionID: uploadSession.Key,
+		ChunkSize: handler.Policy.OptionsSerialized.ChunkSize,
+	}, nil
+}
+
+// CancelToken 取消上传凭证，未提交的 Dropbox 上传会话会自动过期
+func (handler *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	return cache.Deletes([]string{handler.uploadStateKey(uploadSession.SavePath)}, "")
+}
+
+// Capabilities 返回存储端支持的特性
+func (handler *Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		RangeGet:        true,
+		MultipartUpload: true,
+	}
+}
@@ -0,0 +1,336 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/mocks/requestmock"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+)
+
+const (
+	apiURL     = "https://api.dropboxapi.com/2/"
+	contentURL = "https://content.dropboxapi.com/2/"
+)
+
+func newTestDriver(t *testing.T, clientMock request.Client) *Driver {
+	handler, err := NewDriver(&model.Policy{
+		Model:      gorm.Model{ID: 1},
+		Type:       "dropbox",
+		AccessKey:  "refresh",
+		BucketName: "app_key",
+		SecretKey:  "app_secret",
+	})
+	assert.NoError(t, err)
+	cache.Deletes([]string{"1_/a.txt"}, "dropbox_upload_")
+
+	handler.Client.Credential.AccessToken = "token"
+	handler.Client.Credential.ExpiresIn = time.Now().Add(time.Hour).Unix()
+	handler.Client.Request = clientMock
+	return handler
+}
+
+func newResponse(status int, body string) *request.Response {
+	return &request.Response{
+		Response: &http.Response{
+			StatusCode:    status,
+			Header:        http.Header{},
+			ContentLength: int64(len(body)),
+			Body:          io.NopCloser(strings.NewReader(body)),
+		},
+	}
+}
+
+const notFoundBody = `{"error_summary":"path/not_found/..","error":{".tag":"path","path":{".tag":"not_found"}}}`
+
+func TestApiPath(t *testing.T) {
+	a := assert.New(t)
+	a.Equal("", apiPath("/"))
+	a.Equal("", apiPath(""))
+	a.Equal("/dir/a.txt", apiPath("dir/a.txt"))
+	a.Equal("/dir", apiPath("/dir/"))
+}
+
+func TestHeaderArg(t *testing.T) {
+	a := assert.New(t)
+	res, err := headerArg(map[string]string{"path": "/文件😀.txt"})
+	a.NoError(err)
+	a.Equal(`{"path":"/\u6587\u4ef6\ud83d\ude00.txt"}`, res)
+}
+
+func TestDriver_Token(t *testing.T) {
+	a := assert.New(t)
+
+	// 文件已存在
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "POST", apiURL+"files/get_metadata", testMock.Anything, testMock.Anything).
+			Return(newResponse(200, `{".tag":"file","name":"a.txt"}`))
+		handler := newTestDriver(t, clientMock)
+		_, err := handler.Token(context.Background(), 10, &serializer.UploadSession{SavePath: "/a.txt"}, &fsctx.FileStream{})
+		clientMock.AssertExpectations(t)
+		a.Error(err)
+	}
+
+	// 查询失败
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "POST", apiURL+"files/get_metadata", testMock.Anything, testMock.Anything).
+			Return(newResponse(401, `{"error_summary":"invalid_access_token/..","error":{".tag":"invalid_access_token"}}`))
+		handler := newTestDriver(t, clientMock)
+		_, err := handler.Token(context.Background(), 10, &serializer.UploadSession{SavePath: "/a.txt"}, &fsctx.FileStream{})
+		clientMock.AssertExpectations(t)
+		a.Error(err)
+		a.Equal("invalid_access_token/..", err.Error())
+	}
+
+	// 成功
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "POST", apiURL+"files/get_metadata", testMock.Anything, testMock.Anything).
+			Return(newResponse(409, notFoundBody))
+		handler := newTestDriver(t, clientMock)
+		res, err := handler.Token(context.Background(), 10, &serializer.UploadSession{Key: "key", SavePath: "/a.txt"}, &fsctx.FileStream{})
+		clientMock.AssertExpectations(t)
+		a.NoError(err)
+		a.Equal("key", res.SessionID)
+		a.EqualValues(50<<20, res.ChunkSize)
+		a.Empty(res.UploadURLs)
+	}
+}
+
+func TestDriver_Put(t *testing.T) {
+	a := assert.New(t)
+	cache.Set("setting_chunk_retries", "1", 0)
+	cache.Set("setting_use_temp_chunk_buffer", "false", 0)
+
+	// 小文件
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "POST", contentURL+"files/upload", testMock.Anything, testMock.Anything).
+			Return(newResponse(200, `{".tag":"file","name":"a.txt"}`))
+		handler := newTestDriver(t, clientMock)
+		err := handler.Put(context.Background(), &fsctx.FileStream{
+			File:     io.NopCloser(strings.NewReader("1234")),
+			SavePath: "/a.txt",
+			Size:     4,
+		})
+		clientMock.AssertExpectations(t)
+		a.NoError(err)
+	}
+
+	// 大文件使用上传会话
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "POST", contentURL+"files/upload_session/start", testMock.Anything, testMock.Anything).
+			Return(newResponse(200, `{"session_id":"session"}`))
+		clientMock.On("Request", "POST", contentURL+"files/upload_session/append_v2", testMock.Anything, testMock.Anything).
+			Return(newResponse(200, `null`)).Twice()
+		clientMock.On("Request", "POST", contentURL+"files/upload_session/finish", testMock.Anything, testMock.Anything).
+			Return(newResponse(200, `{".tag":"file","name":"a.txt"}`))
+		handler := newTestDriver(t, clientMock)
+		handler.Policy.OptionsSerialized.ChunkSize = 2
+		err := handler.Put(context.Background(), &fsctx.FileStream{
+			File:     io.NopCloser(strings.NewReader("1234")),
+			SavePath: "/a.txt",
+			Size:     4,
+		})
+		clientMock.AssertExpectations(t)
+		a.NoError(err)
+	}
+
+	// 上传失败
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "POST", contentURL+"files/upload", testMock.Anything, testMock.Anything).
+			Return(newResponse(409, `{"error_summary":"path/conflict/file/..","error":{}}`))
+		handler := newTestDriver(t, clientMock)
+		err := handler.Put(context.Background(), &fsctx.FileStream{
+			File:     io.NopCloser(strings.NewReader("1234")),
+			SavePath: "/a.txt",
+			Size:     4,
+		})
+		clientMock.AssertExpectations(t)
+		a.Error(err)
+	}
+}
+
+func TestDriver_PutChunk(t *testing.T) {
+	a := assert.New(t)
+	chunk := func(start uint64, content string) *fsctx.FileStream {
+		mode := fsctx.Append
+		if start > 0 {
+			mode |= fsctx.Overwrite
+		}
+
+		return &fsctx.FileStream{
+			File:        io.NopCloser(strings.NewReader(content)),
+			SavePath:    "/a.txt",
+			Size:        uint64(len(content)),
+			Mode:        mode,
+			AppendStart: start,
+		}
+	}
+
+	// 会话不存在
+	{
+		handler := newTestDriver(t, &requestmock.RequestMock{})
+		a.ErrorIs(handler.Put(context.Background(), chunk(2, "34")), ErrUploadSessionNotFound)
+		a.ErrorIs(handler.CompleteUpload(context.Background(), "/a.txt"), ErrUploadSessionNotFound)
+	}
+
+	// 同名文件已存在
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "POST", apiURL+"files/get_metadata", testMock.Anything, testMock.Anything).
+			Return(newResponse(200, `{".tag":"file","name":"a.txt"}`))
+		handler := newTestDriver(t, clientMock)
+		a.ErrorIs(handler.Put(context.Background(), chunk(0, "12")), ErrFileExisted)
+		clientMock.AssertExpectations(t)
+	}
+
+	// 成功
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "POST", apiURL+"files/get_metadata", testMock.Anything, testMock.Anything).
+		Return(newResponse(409, notFoundBody))
+	clientMock.On("Request", "POST", contentURL+"files/upload_session/start", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, `{"session_id":"session"}`))
+	clientMock.On("Request", "POST", contentURL+"files/upload_session/append_v2", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, `null`)).Once()
+	clientMock.On("Request", "POST", contentURL+"files/upload_session/finish", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, `{".tag":"file","name":"a.txt"}`))
+	handler := newTestDriver(t, clientMock)
+
+	a.NoError(handler.Put(context.Background(), chunk(0, "12")))
+	a.NoError(handler.Put(context.Background(), chunk(2, "34")))
+	// 重试已接收的分片
+	a.NoError(handler.Put(context.Background(), chunk(2, "34")))
+	// 分片位置不连续
+	a.ErrorIs(handler.Put(context.Background(), chunk(6, "78")), ErrChunkOffset)
+
+	state, ok := cache.Get(handler.uploadStateKey("/a.txt"))
+	a.True(ok)
+	a.Equal(uploadState{SessionID: "session", Offset: 4}, state)
+
+	a.NoError(handler.CompleteUpload(context.Background(), "/a.txt"))
+	clientMock.AssertExpectations(t)
+	_, ok = cache.Get(handler.uploadStateKey("/a.txt"))
+	a.False(ok)
+}
+
+func TestDriver_CancelToken(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t, &requestmock.RequestMock{})
+	cache.Set(handler.uploadStateKey("/a.txt"), uploadState{SessionID: "session"}, 0)
+
+	a.NoError(handler.CancelToken(context.Background(), &serializer.UploadSession{SavePath: "/a.txt"}))
+	_, ok := cache.Get(handler.uploadStateKey("/a.txt"))
+	a.False(ok)
+}
+
+func TestDriver_Delete(t *testing.T) {
+	a := assert.New(t)
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "POST", apiURL+"files/delete_v2", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, `{"metadata":{}}`)).Once()
+	clientMock.On("Request", "POST", apiURL+"files/delete_v2", testMock.Anything, testMock.Anything).
+		Return(newResponse(409, `{"error_summary":"path_lookup/not_found/..","error":{}}`)).Once()
+	clientMock.On("Request", "POST", apiURL+"files/delete_v2", testMock.Anything, testMock.Anything).
+		Return(newResponse(429, `{"error_summary":"too_many_write_operations/..","error":{}}`)).Once()
+	handler := newTestDriver(t, clientMock)
+
+	failed, err := handler.Delete(context.Background(), []string{"/a.txt", "/b.txt", "/c.txt"})
+	clientMock.AssertExpectations(t)
+	a.Error(err)
+	a.Equal([]string{"/c.txt"}, failed)
+}
+
+func TestDriver_List(t *testing.T) {
+	a := assert.New(t)
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "POST", apiURL+"files/list_folder", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, `{"entries":[
+			{".tag":"folder","name":"Dir","path_display":"/Dir"},
+			{".tag":"folder","name":"sub","path_display":"/Dir/sub"}
+		],"cursor":"next","has_more":true}`))
+	clientMock.On("Request", "POST", apiURL+"files/list_folder/continue", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, `{"entries":[
+			{".tag":"file","name":"a.txt","path_display":"/Dir/sub/a.txt","size":4,"server_modified":"2022-01-01T00:00:00Z"},
+			{".tag":"deleted","name":"b.txt","path_display":"/Dir/b.txt"}
+		],"cursor":"end","has_more":false}`))
+	handler := newTestDriver(t, clientMock)
+
+	res, err := handler.List(context.Background(), "/dir", true)
+	clientMock.AssertExpectations(t)
+	a.NoError(err)
+	a.Len(res, 2)
+	a.Equal("sub", res[0].RelativePath)
+	a.True(res[0].IsDir)
+	a.Equal("sub/a.txt", res[1].RelativePath)
+	a.Equal("dir/sub/a.txt", res[1].Source)
+	a.EqualValues(4, res[1].Size)
+	a.False(res[1].IsDir)
+}
+
+func TestDriver_Source(t *testing.T) {
+	a := assert.New(t)
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "POST", apiURL+"files/get_temporary_link", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, `{"metadata":{"size":4},"link":"https://dl.dropboxusercontent.com/a"}`)).Once()
+	handler := newTestDriver(t, clientMock)
+	cache.Deletes([]string{"1_/source.txt"}, "dropbox_source_")
+
+	res, err := handler.Source(context.Background(), "/source.txt", 10, false, 0)
+	a.NoError(err)
+	a.Equal("https://dl.dropboxusercontent.com/a", res)
+
+	// 使用缓存
+	res, err = handler.Source(context.Background(), "/source.txt", 10, false, 0)
+	a.NoError(err)
+	a.Equal("https://dl.dropboxusercontent.com/a", res)
+	clientMock.AssertExpectations(t)
+}
+
+func TestDriver_Get(t *testing.T) {
+	a := assert.New(t)
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "POST", apiURL+"files/get_temporary_link", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, `{"metadata":{"size":4},"link":"https://dl.dropboxusercontent.com/a"}`))
+	httpMock := &requestmock.RequestMock{}
+	httpMock.On("Request", "GET", "https://dl.dropboxusercontent.com/a", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, `1234`))
+	handler := newTestDriver(t, clientMock)
+	handler.HTTPClient = httpMock
+
+	rs, err := handler.Get(context.Background(), "/a.txt")
+	clientMock.AssertExpectations(t)
+	httpMock.AssertExpectations(t)
+	a.NoError(err)
+	size, err := rs.Seek(0, io.SeekEnd)
+	a.NoError(err)
+	a.EqualValues(4, size)
+	_, err = rs.Seek(0, io.SeekStart)
+	a.NoError(err)
+	content := make([]byte, 1024)
+	n, _ := rs.Read(content)
+	a.Equal("1234", string(content[:n]))
+}
+
+func TestDriver_Thumb(t *testing.T) {
+	handler := newTestDriver(t, &requestmock.RequestMock{})
+	_, err := handler.Thumb(context.Background(), &model.File{})
+	assert.True(t, errors.Is(err, driver.ErrorThumbNotSupported))
+}
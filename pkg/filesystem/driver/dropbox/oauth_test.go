@@ -0,0 +1,89 @@
+package dropbox
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/mocks/requestmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+)
+
+func TestClient_OAuthURL(t *testing.T) {
+	a := assert.New(t)
+	client, _ := NewClient(&model.Policy{BucketName: "app_key"})
+	client.Redirect = "http://cloudreve.org/api/v3/callback/dropbox/auth"
+
+	res, err := url.Parse(client.OAuthURL(context.Background()))
+	a.NoError(err)
+	a.Equal("www.dropbox.com", res.Host)
+	a.Equal("app_key", res.Query().Get("client_id"))
+	a.Equal("offline", res.Query().Get("token_access_type"))
+	a.Equal(client.Redirect, res.Query().Get("redirect_uri"))
+}
+
+func TestClient_ObtainToken(t *testing.T) {
+	a := assert.New(t)
+
+	// 成功
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "POST", oauthEndpoint, testMock.Anything, testMock.Anything).
+			Return(newResponse(200, `{"access_token":"at","expires_in":14400,"refresh_token":"rt"}`))
+		client, _ := NewClient(&model.Policy{})
+		client.Request = clientMock
+		res, err := client.ObtainToken(context.Background(), "code", "")
+		clientMock.AssertExpectations(t)
+		a.NoError(err)
+		a.Equal("at", res.AccessToken)
+		a.Equal("rt", res.RefreshToken)
+	}
+
+	// 失败
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "POST", oauthEndpoint, testMock.Anything, testMock.Anything).
+			Return(newResponse(400, `{"error":"invalid_grant","error_description":"code has expired"}`))
+		client, _ := NewClient(&model.Policy{})
+		client.Request = clientMock
+		_, err := client.ObtainToken(context.Background(), "code", "")
+		clientMock.AssertExpectations(t)
+		a.EqualError(err, "code has expired")
+	}
+}
+
+func TestClient_UpdateCredential(t *testing.T) {
+	a := assert.New(t)
+
+	// 无 RefreshToken
+	{
+		client, _ := NewClient(&model.Policy{BucketName: "empty"})
+		a.ErrorIs(client.UpdateCredential(context.Background(), false), ErrInvalidRefreshToken)
+	}
+
+	// 刷新凭证并写入缓存
+	{
+		cache.Deletes([]string{"refresh_test"}, TokenCachePrefix)
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "POST", oauthEndpoint, testMock.Anything, testMock.Anything).
+			Return(newResponse(200, `{"access_token":"at","expires_in":14400}`)).Once()
+		client, _ := NewClient(&model.Policy{Model: gorm.Model{ID: 2}, AccessKey: "rt", BucketName: "refresh_test"})
+		client.Request = clientMock
+		a.NoError(client.UpdateCredential(context.Background(), false))
+		clientMock.AssertExpectations(t)
+		a.Equal("at", client.AccessToken())
+		a.Equal("rt", client.Credential.RefreshToken)
+		a.True(client.Credential.ExpiresIn > time.Now().Unix())
+
+		// 使用缓存
+		client, _ = NewClient(&model.Policy{Model: gorm.Model{ID: 2}, AccessKey: "rt", BucketName: "refresh_test"})
+		client.Request = &requestmock.RequestMock{}
+		a.NoError(client.UpdateCredential(context.Background(), false))
+		a.Equal("at", client.AccessToken())
+	}
+}
@@ -0,0 +1,92 @@
+package dropbox
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"time"
+)
+
+// RespError 接口返回错误
+type RespError struct {
+	StatusCode int             `json:"-"`
+	Summary    string          `json:"error_summary"`
+	Detail     json.RawMessage `json:"error"`
+}
+
+// Error 实现error接口
+func (err *RespError) Error() string {
+	return err.Summary
+}
+
+// Metadata 文件元信息
+type Metadata struct {
+	Tag            string    `json:".tag"`
+	ID             string    `json:"id"`
+	Name           string    `json:"name"`
+	PathDisplay    string    `json:"path_display"`
+	Size           uint64    `json:"size"`
+	ServerModified time.Time `json:"server_modified"`
+}
+
+// IsFolder 是否为目录
+func (meta *Metadata) IsFolder() bool {
+	return meta.Tag == "folder"
+}
+
+// ListFolderResult 列取目录的响应
+type ListFolderResult struct {
+	Entries []Metadata `json:"entries"`
+	Cursor  string     `json:"cursor"`
+	HasMore bool       `json:"has_more"`
+}
+
+// TemporaryLink 文件临时下载地址
+type TemporaryLink struct {
+	Metadata Metadata `json:"metadata"`
+	Link     string   `json:"link"`
+}
+
+// uploadSessionCursor 上传会话位置
+type uploadSessionCursor struct {
+	SessionID string `json:"session_id"`
+	Offset    uint64 `json:"offset"`
+}
+
+// commitInfo 上传完成后提交文件的参数
+type commitInfo struct {
+	Path       string `json:"path"`
+	Mode       string `json:"mode"`
+	Autorename bool   `json:"autorename"`
+	Mute       bool   `json:"mute"`
+}
+
+// uploadState 中转分片上传时的上传会话状态
+type uploadState struct {
+	SessionID string
+	Offset    uint64
+}
+
+// Credential 获取token时返回的凭证
+type Credential struct {
+	ExpiresIn    int64  `json:"expires_in"`
+	Scope        string `json:"scope"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	AccountID    string `json:"account_id"`
+}
+
+// OAuthError OAuth相关接口的错误响应
+type OAuthError struct {
+	ErrorType        string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Error 实现error接口
+func (err OAuthError) Error() string {
+	return err.ErrorDescription
+}
+
+func init() {
+	gob.Register(Credential{})
+	gob.Register(uploadState{})
+}
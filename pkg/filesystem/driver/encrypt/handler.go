@@ -0,0 +1,190 @@
+package encrypt
+
+import (
+	"context"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"io"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+)
+
+// truncater 支持截断文件的存储适配器
+type truncater interface {
+	Truncate(ctx context.Context, src string, size uint64) error
+}
+
+// Driver 透明加密存储适配器，上传时使用存储策略密钥加密文件流，
+// 读取时根据文件头自动解密，未加密的文件原样返回
+type Driver struct {
+	driver.Handler
+	wrappedKey []byte
+	aead       cipher.AEAD
+}
+
+// NewDriver 包装存储适配器，存储策略开启加密时上传的文件将被加密
+func NewDriver(handler driver.Handler, policy *model.Policy) (*Driver, error) {
+	d := &Driver{Handler: handler}
+	if policy == nil || !policy.OptionsSerialized.Encryption {
+		return d, nil
+	}
+
+	wrappedKey, err := base64.StdEncoding.DecodeString(policy.OptionsSerialized.EncryptionKey)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	key, err := unwrapKey(wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.aead, err = newGCM(key); err != nil {
+		return nil, err
+	}
+
+	d.wrappedKey = wrappedKey
+	return d, nil
+}
+
+// Put 加密文件流后交由下层适配器保存，追加写入时沿用已有文件的文件头
+func (d *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
+	if d.aead == nil {
+		return d.Handler.Put(ctx, file)
+	}
+
+	fileInfo := file.Info()
+	offset, err := cipherOffset(fileInfo.AppendStart)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	var (
+		h    *header
+		aead = d.aead
+	)
+	if offset == 0 {
+		h, err = newHeader(d.wrappedKey)
+	} else {
+		h, aead, err = d.existingHeader(ctx, fileInfo.SavePath)
+	}
+
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	size := cipherSize(fileInfo.Size)
+	if offset == 0 {
+		size += uint64(headerSize)
+	}
+
+	return d.Handler.Put(ctx, &fsctx.FileStream{
+		Mode:            fileInfo.Mode,
+		LastModified:    fileInfo.LastModified,
+		Metadata:        fileInfo.Metadata,
+		File:            newEncryptReader(file, h, aead, fileInfo.AppendStart/SegmentSize, offset == 0),
+		Size:            size,
+		VirtualPath:     fileInfo.VirtualPath,
+		Name:            fileInfo.FileName,
+		MimeType:        fileInfo.MimeType,
+		SavePath:        fileInfo.SavePath,
+		UploadSessionID: fileInfo.UploadSessionID,
+		AppendStart:     offset,
+		Model:           fileInfo.Model,
+		Src:             fileInfo.Src,
+		Hash:            fileInfo.Hash,
+	})
+}
+
+// existingHeader 读取已上传部分的文件头
+func (d *Driver) existingHeader(ctx context.Context, path string) (*header, cipher.AEAD, error) {
+	rs, err := d.Handler.Get(ctx, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rs.Close()
+
+	h, err := readHeader(rs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aead, err := h.aead()
+	return h, aead, err
+}
+
+// Get 获取文件内容，加密的文件返回解密流
+func (d *Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	rs, err := d.Handler.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := d.decrypt(rs)
+	if err != nil {
+		rs.Close()
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// decrypt 检查文件头，是加密文件时返回解密流，否则回到文件起始处原样返回
+func (d *Driver) decrypt(rs response.RSCloser) (response.RSCloser, error) {
+	h, err := readHeader(rs)
+	if errors.Is(err, ErrNotHeader) {
+		_, err = rs.Seek(0, io.SeekStart)
+		return rs, err
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newDecryptReader(rs, h)
+}
+
+// Thumb 获取缩略图，由下层适配器直接返回的缩略图内容需要解密
+func (d *Driver) Thumb(ctx context.Context, file *model.File) (*response.ContentResponse, error) {
+	res, err := d.Handler.Thumb(ctx, file)
+	if err != nil || res.Redirect || res.Content == nil {
+		return res, err
+	}
+
+	content, err := d.decrypt(res.Content)
+	if err != nil {
+		res.Content.Close()
+		return nil, err
+	}
+
+	res.Content = content
+	return res, nil
+}
+
+// Truncate 将文件截断至明文长度 size，size 需要与加密分段对齐
+func (d *Driver) Truncate(ctx context.Context, src string, size uint64) error {
+	handler, ok := d.Handler.(truncater)
+	if !ok {
+		return nil
+	}
+
+	offset, err := cipherOffset(size)
+	if err != nil {
+		return err
+	}
+
+	return handler.Truncate(ctx, src, offset)
+}
+
+// Capabilities 返回存储端支持的特性，文件需由本机加密后上传
+func (d *Driver) Capabilities() driver.Capabilities {
+	caps := d.Handler.Capabilities()
+	caps.PresignedUpload = false
+	return caps
+}
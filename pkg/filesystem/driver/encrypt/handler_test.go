@@ -0,0 +1,257 @@
+package encrypt
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryHandler 将文件保存在内存中的存储适配器
+type memoryHandler struct {
+	driver.Handler
+	files map[string][]byte
+}
+
+type memoryFile struct {
+	*bytes.Reader
+}
+
+func (memoryFile) Close() error {
+	return nil
+}
+
+func (m *memoryHandler) Put(ctx context.Context, file fsctx.FileHeader) error {
+	defer file.Close()
+	info := file.Info()
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	if uint64(len(content)) != info.Size {
+		return errors.New("size mismatch")
+	}
+
+	if info.Mode&fsctx.Append == fsctx.Append {
+		m.files[info.SavePath] = append(m.files[info.SavePath][:info.AppendStart], content...)
+	} else {
+		m.files[info.SavePath] = content
+	}
+
+	return nil
+}
+
+func (m *memoryHandler) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	content, ok := m.files[path]
+	if !ok {
+		return nil, errors.New("not exist")
+	}
+
+	return memoryFile{bytes.NewReader(content)}, nil
+}
+
+func (m *memoryHandler) Capabilities() driver.Capabilities {
+	return driver.Capabilities{RangeGet: true, PresignedUpload: true, MultipartUpload: true}
+}
+
+func (m *memoryHandler) Truncate(ctx context.Context, src string, size uint64) error {
+	m.files[src] = m.files[src][:size]
+	return nil
+}
+
+func setMasterKey(t *testing.T) {
+	key := make([]byte, keySize)
+	rand.Read(key)
+	conf.SystemConfig.MasterKey = base64.StdEncoding.EncodeToString(key)
+	t.Cleanup(func() { conf.SystemConfig.MasterKey = "" })
+}
+
+func newTestDriver(t *testing.T) (*Driver, *memoryHandler) {
+	key, err := NewWrappedKey()
+	assert.NoError(t, err)
+
+	handler := &memoryHandler{files: make(map[string][]byte)}
+	policy := &model.Policy{OptionsSerialized: model.PolicyOption{Encryption: true, EncryptionKey: key}}
+	d, err := NewDriver(handler, policy)
+	assert.NoError(t, err)
+	return d, handler
+}
+
+func randomContent(size int) []byte {
+	content := make([]byte, size)
+	rand.Read(content)
+	return content
+}
+
+func TestWrapKey(t *testing.T) {
+	a := assert.New(t)
+
+	// 未配置主密钥
+	_, err := NewWrappedKey()
+	a.Equal(ErrMasterKeyNotSet, err)
+
+	// 主密钥格式错误
+	conf.SystemConfig.MasterKey = "invalid"
+	_, err = NewWrappedKey()
+	a.Equal(ErrInvalidMasterKey, err)
+
+	setMasterKey(t)
+	key := randomContent(keySize)
+	wrapped, err := wrapKey(key)
+	a.NoError(err)
+	a.Len(wrapped, wrappedKeySize)
+
+	unwrapped, err := unwrapKey(wrapped)
+	a.NoError(err)
+	a.Equal(key, unwrapped)
+
+	// 密钥被篡改
+	wrapped[len(wrapped)-1] ^= 1
+	_, err = unwrapKey(wrapped)
+	a.Equal(ErrInvalidKey, err)
+}
+
+func TestDriver_PutGet(t *testing.T) {
+	a := assert.New(t)
+	setMasterKey(t)
+	d, handler := newTestDriver(t)
+	ctx := context.Background()
+
+	for _, size := range []int{0, 1, SegmentSize, SegmentSize*2 + 100} {
+		content := randomContent(size)
+		a.NoError(d.Put(ctx, &fsctx.FileStream{
+			File:     ioutil.NopCloser(bytes.NewReader(content)),
+			Size:     uint64(size),
+			SavePath: "1.txt",
+		}))
+		a.Len(handler.files["1.txt"], headerSize+int(cipherSize(uint64(size))))
+		// 过短的明文可能恰好出现在密文中
+		if size >= 16 {
+			a.NotContains(string(handler.files["1.txt"]), string(content))
+		}
+
+		rs, err := d.Get(ctx, "1.txt")
+		a.NoError(err)
+		res, err := ioutil.ReadAll(rs)
+		a.NoError(err)
+		a.Equal(content, res, "size %d", size)
+
+		// 随机读取
+		end, err := rs.Seek(0, io.SeekEnd)
+		a.NoError(err)
+		a.EqualValues(size, end)
+		if size > 10 {
+			_, err = rs.Seek(int64(size-10), io.SeekStart)
+			a.NoError(err)
+			res, err = ioutil.ReadAll(rs)
+			a.NoError(err)
+			a.Equal(content[size-10:], res)
+		}
+	}
+
+	// 密文被篡改
+	handler.files["1.txt"][headerSize] ^= 1
+	rs, err := d.Get(ctx, "1.txt")
+	a.NoError(err)
+	_, err = ioutil.ReadAll(rs)
+	a.Equal(ErrCorrupted, err)
+}
+
+func TestDriver_Append(t *testing.T) {
+	a := assert.New(t)
+	setMasterKey(t)
+	d, handler := newTestDriver(t)
+	ctx := context.Background()
+	content := randomContent(SegmentSize*2 + 10)
+
+	// 分片上传
+	for start := 0; start < len(content); start += SegmentSize {
+		end := start + SegmentSize
+		if end > len(content) {
+			end = len(content)
+		}
+
+		a.NoError(d.Put(ctx, &fsctx.FileStream{
+			File:        ioutil.NopCloser(bytes.NewReader(content[start:end])),
+			Size:        uint64(end - start),
+			SavePath:    "1.txt",
+			Mode:        fsctx.Append,
+			AppendStart: uint64(start),
+		}))
+	}
+
+	rs, err := d.Get(ctx, "1.txt")
+	a.NoError(err)
+	res, err := ioutil.ReadAll(rs)
+	a.NoError(err)
+	a.Equal(content, res)
+
+	// 截断
+	a.NoError(d.Truncate(ctx, "1.txt", SegmentSize))
+	rs, err = d.Get(ctx, "1.txt")
+	a.NoError(err)
+	res, err = ioutil.ReadAll(rs)
+	a.NoError(err)
+	a.Equal(content[:SegmentSize], res)
+
+	// 未对齐
+	a.Equal(ErrUnaligned, d.Truncate(ctx, "1.txt", 1))
+	a.Equal(ErrUnaligned, d.Put(ctx, &fsctx.FileStream{
+		File:        ioutil.NopCloser(bytes.NewReader(content)),
+		SavePath:    "1.txt",
+		Mode:        fsctx.Append,
+		AppendStart: 1,
+	}))
+	a.Len(handler.files["1.txt"], headerSize+SegmentSize+tagSize)
+}
+
+func TestDriver_PlainFile(t *testing.T) {
+	a := assert.New(t)
+	setMasterKey(t)
+	handler := &memoryHandler{files: map[string][]byte{"1.txt": []byte("plain")}}
+
+	// 未开启加密
+	d, err := NewDriver(handler, &model.Policy{})
+	a.NoError(err)
+	a.NoError(d.Put(context.Background(), &fsctx.FileStream{
+		File:     ioutil.NopCloser(bytes.NewReader([]byte("plain2"))),
+		Size:     6,
+		SavePath: "2.txt",
+	}))
+	a.Equal("plain2", string(handler.files["2.txt"]))
+
+	// 未加密的文件原样返回
+	rs, err := d.Get(context.Background(), "1.txt")
+	a.NoError(err)
+	res, err := ioutil.ReadAll(rs)
+	a.NoError(err)
+	a.Equal("plain", string(res))
+
+	// 策略密钥无效
+	_, err = NewDriver(handler, &model.Policy{OptionsSerialized: model.PolicyOption{Encryption: true, EncryptionKey: "invalid"}})
+	a.Equal(ErrInvalidKey, err)
+}
+
+func TestDriver_Capabilities(t *testing.T) {
+	a := assert.New(t)
+	setMasterKey(t)
+	d, _ := newTestDriver(t)
+
+	// 加密后仍可任意位置读取，但不能由客户端直传
+	caps := d.Capabilities()
+	a.True(caps.RangeGet)
+	a.True(caps.MultipartUpload)
+	a.False(caps.PresignedUpload)
+}
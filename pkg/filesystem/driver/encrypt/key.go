@@ -0,0 +1,113 @@
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+)
+
+const (
+	// keySize 存储策略密钥及主密钥长度，使用 AES-256
+	keySize = 32
+	// wrappedKeySize 被主密钥加密后的策略密钥长度，包含 nonce 和认证标签
+	wrappedKeySize = 12 + keySize + 16
+)
+
+var (
+	ErrMasterKeyNotSet  = errors.New("encryption master key is not configured")
+	ErrInvalidMasterKey = errors.New("encryption master key must be 32 bytes encoded in base64")
+	ErrInvalidKey       = errors.New("invalid wrapped encryption key")
+)
+
+// Enabled 返回是否配置了主密钥
+func Enabled() bool {
+	return conf.SystemConfig.MasterKey != ""
+}
+
+// masterKey 读取配置文件中的主密钥
+func masterKey() ([]byte, error) {
+	if !Enabled() {
+		return nil, ErrMasterKeyNotSet
+	}
+
+	key, err := base64.StdEncoding.DecodeString(conf.SystemConfig.MasterKey)
+	if err != nil || len(key) != keySize {
+		return nil, ErrInvalidMasterKey
+	}
+
+	return key, nil
+}
+
+// newGCM 使用给定密钥创建 AES-GCM 实例
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// NewWrappedKey 生成新的存储策略密钥，返回被主密钥加密后的 base64 编码
+func NewWrappedKey() (string, error) {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+
+	wrapped, err := wrapKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(wrapped), nil
+}
+
+// wrapKey 使用主密钥加密存储策略密钥
+func wrapKey(key []byte) ([]byte, error) {
+	master, err := masterKey()
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newGCM(master)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), wrappedKeySize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, key, nil), nil
+}
+
+// unwrapKey 使用主密钥解密存储策略密钥
+func unwrapKey(wrapped []byte) ([]byte, error) {
+	if len(wrapped) != wrappedKeySize {
+		return nil, ErrInvalidKey
+	}
+
+	master, err := masterKey()
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newGCM(master)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	return key, nil
+}
@@ -0,0 +1,298 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+)
+
+/*
+	加密文件格式：
+	| magic (8) | 被主密钥加密的策略密钥 (60) | nonce 前缀 (4) | 分段 0 | 分段 1 | ...
+	每个分段为最多 SegmentSize 字节的明文经 AES-GCM 加密后的密文及认证标签，
+	nonce 由 nonce 前缀和大端序的分段序号组成，因此可以按分段随机读取和追加写入。
+*/
+
+const (
+	// SegmentSize 每个加密分段的明文长度，分片上传的分片大小需要是其整数倍
+	SegmentSize = 64 << 10
+	// tagSize AES-GCM 认证标签长度
+	tagSize = 16
+	// prefixSize nonce 前缀长度
+	prefixSize = 4
+	// magicSize 加密文件标识长度
+	magicSize = 8
+	// headerSize 加密文件头长度
+	headerSize = magicSize + wrappedKeySize + prefixSize
+)
+
+var magic = []byte("CRENC\x00\x00\x01")
+
+var (
+	ErrCorrupted  = errors.New("encrypted file is corrupted")
+	ErrUnaligned  = errors.New("offset of encrypted file must be aligned to encryption segment size")
+	ErrNotHeader  = errors.New("not an encrypted file")
+	ErrSeekBefore = errors.New("seek before start of file")
+)
+
+// header 加密文件头
+type header struct {
+	wrappedKey []byte
+	prefix     []byte
+}
+
+// newHeader 为新文件生成文件头
+func newHeader(wrappedKey []byte) (*header, error) {
+	prefix := make([]byte, prefixSize)
+	if _, err := io.ReadFull(rand.Reader, prefix); err != nil {
+		return nil, err
+	}
+
+	return &header{wrappedKey: wrappedKey, prefix: prefix}, nil
+}
+
+// readHeader 读取并解析文件头，不是加密文件时返回 ErrNotHeader
+func readHeader(r io.Reader) (*header, error) {
+	buf := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrNotHeader
+		}
+		return nil, err
+	}
+
+	if !bytes.Equal(buf[:magicSize], magic) {
+		return nil, ErrNotHeader
+	}
+
+	return &header{
+		wrappedKey: buf[magicSize : magicSize+wrappedKeySize],
+		prefix:     buf[magicSize+wrappedKeySize:],
+	}, nil
+}
+
+func (h *header) bytes() []byte {
+	buf := make([]byte, 0, headerSize)
+	buf = append(buf, magic...)
+	buf = append(buf, h.wrappedKey...)
+	return append(buf, h.prefix...)
+}
+
+// aead 解密策略密钥并创建 AES-GCM 实例
+func (h *header) aead() (cipher.AEAD, error) {
+	key, err := unwrapKey(h.wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return newGCM(key)
+}
+
+func (h *header) nonce(index uint64) []byte {
+	nonce := make([]byte, prefixSize+8)
+	copy(nonce, h.prefix)
+	binary.BigEndian.PutUint64(nonce[prefixSize:], index)
+	return nonce
+}
+
+// cipherOffset 返回明文偏移量对应的密文偏移量，偏移量需要与分段对齐
+func cipherOffset(plainOffset uint64) (uint64, error) {
+	if plainOffset == 0 {
+		return 0, nil
+	}
+
+	if plainOffset%SegmentSize != 0 {
+		return 0, ErrUnaligned
+	}
+
+	return uint64(headerSize) + plainOffset/SegmentSize*(SegmentSize+tagSize), nil
+}
+
+// cipherSize 返回从分段起始处开始、长度为 size 的明文加密后的长度
+func cipherSize(size uint64) uint64 {
+	segments := (size + SegmentSize - 1) / SegmentSize
+	return size + segments*tagSize
+}
+
+// plainSize 返回密文长度对应的明文长度
+func plainSize(size int64) (int64, error) {
+	body := size - int64(headerSize)
+	if body < 0 {
+		return 0, ErrCorrupted
+	}
+
+	full, rem := body/(SegmentSize+tagSize), body%(SegmentSize+tagSize)
+	if rem > 0 && rem <= tagSize {
+		return 0, ErrCorrupted
+	}
+
+	if rem > 0 {
+		rem -= tagSize
+	}
+
+	return full*SegmentSize + rem, nil
+}
+
+// encryptReader 将明文流加密为密文流
+type encryptReader struct {
+	io.ReadCloser
+	aead    cipher.AEAD
+	header  *header
+	index   uint64
+	plain   []byte
+	out     []byte
+	pending []byte
+	eof     bool
+}
+
+// newEncryptReader 从第 index 个分段开始加密 r，writeHeader 为真时先输出文件头
+func newEncryptReader(r io.ReadCloser, h *header, aead cipher.AEAD, index uint64, writeHeader bool) *encryptReader {
+	reader := &encryptReader{
+		ReadCloser: r,
+		aead:       aead,
+		header:     h,
+		index:      index,
+		plain:      make([]byte, SegmentSize),
+	}
+
+	if writeHeader {
+		reader.pending = h.bytes()
+	}
+
+	return reader
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+
+		n, err := io.ReadFull(r.ReadCloser, r.plain)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			r.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+
+		if n > 0 {
+			r.out = r.aead.Seal(r.out[:0], r.header.nonce(r.index), r.plain[:n], nil)
+			r.pending = r.out
+			r.index++
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// decryptReader 按分段解密密文文件，支持随机读取
+type decryptReader struct {
+	rs      response.RSCloser
+	aead    cipher.AEAD
+	header  *header
+	size    int64
+	offset  int64
+	segment []byte
+	cipher  []byte
+	loaded  int64
+}
+
+// newDecryptReader 在已读取文件头的 rs 上创建解密流
+func newDecryptReader(rs response.RSCloser, h *header) (*decryptReader, error) {
+	aead, err := h.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := plainSize(total)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{
+		rs:     rs,
+		aead:   aead,
+		header: h,
+		size:   size,
+		cipher: make([]byte, SegmentSize+tagSize),
+		loaded: -1,
+	}, nil
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	index := r.offset / SegmentSize
+	if index != r.loaded {
+		if err := r.load(index); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.segment[r.offset-index*SegmentSize:])
+	r.offset += int64(n)
+	return n, nil
+}
+
+// load 读取并解密第 index 个分段
+func (r *decryptReader) load(index int64) error {
+	length := r.size - index*SegmentSize
+	if length > SegmentSize {
+		length = SegmentSize
+	}
+
+	if _, err := r.rs.Seek(int64(headerSize)+index*(SegmentSize+tagSize), io.SeekStart); err != nil {
+		return err
+	}
+
+	buf := r.cipher[:length+tagSize]
+	if _, err := io.ReadFull(r.rs, buf); err != nil {
+		return err
+	}
+
+	segment, err := r.aead.Open(r.segment[:0], r.header.nonce(uint64(index)), buf, nil)
+	if err != nil {
+		return ErrCorrupted
+	}
+
+	r.segment = segment
+	r.loaded = index
+	return nil
+}
+
+func (r *decryptReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, ErrSeekBefore
+	}
+
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *decryptReader) Close() error {
+	return r.rs.Close()
+}
@@ -0,0 +1,494 @@
+package ftp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FTP 响应码，参见 RFC 959
+const (
+	codeRestartMarker   = 350
+	codeFileUnavailable = 550
+)
+
+var pasvAddress = regexp.MustCompile(`(\d+),(\d+),(\d+),(\d+),(\d+),(\d+)`)
+
+// Error FTP 服务端返回的错误响应
+type Error struct {
+	Code int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("ftp: %d %s", e.Code, e.Msg)
+}
+
+// Is 使文件不存在的错误可通过 errors.Is(err, os.ErrNotExist) 判断
+func (e *Error) Is(target error) bool {
+	return e.Code == codeFileUnavailable && target == os.ErrNotExist
+}
+
+// entry 目录中的文件
+type entry struct {
+	Name    string
+	Size    uint64
+	IsDir   bool
+	ModTime time.Time
+}
+
+// client FTP 客户端，同一时间只处理一个请求
+type client struct {
+	conn net.Conn
+	text *textproto.Conn
+	// dataHost 被动模式数据连接的地址，忽略服务端在 PASV 响应中返回的地址
+	dataHost string
+	// tlsConfig 不为空时数据连接使用 TLS
+	tlsConfig *tls.Config
+	features  map[string]string
+	noEPSV    bool
+	broken    bool
+	// lastUsed 最后一次归还连接池的时间
+	lastUsed time.Time
+}
+
+// newClient 在已建立的控制连接上读取欢迎信息
+func newClient(conn net.Conn) (*client, error) {
+	c := &client{features: make(map[string]string)}
+	c.setConn(conn)
+	if _, _, err := c.readResponse(220); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// setConn 设置控制连接，升级为 TLS 连接后需重新设置
+func (c *client) setConn(conn net.Conn) {
+	c.conn = conn
+	c.text = textproto.NewConn(conn)
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		c.dataHost = addr.IP.String()
+	}
+}
+
+// Close 关闭连接
+func (c *client) Close() error {
+	if !c.broken {
+		c.text.PrintfLine("QUIT")
+	}
+
+	c.broken = true
+	return c.conn.Close()
+}
+
+// readResponse 读取响应，响应码与 expect 不符时返回 *Error
+func (c *client) readResponse(expect int) (int, string, error) {
+	code, msg, err := c.text.ReadResponse(expect)
+	if err != nil {
+		if protoErr, ok := err.(*textproto.Error); ok {
+			return code, msg, &Error{Code: protoErr.Code, Msg: protoErr.Msg}
+		}
+
+		c.broken = true
+	}
+
+	return code, msg, err
+}
+
+// cmd 发送命令并读取响应
+func (c *client) cmd(expect int, format string, args ...interface{}) (int, string, error) {
+	if err := c.text.PrintfLine(format, args...); err != nil {
+		c.broken = true
+		return 0, "", err
+	}
+
+	return c.readResponse(expect)
+}
+
+// Login 登录并切换至二进制传输模式
+func (c *client) Login(user, password string) error {
+	code, _, err := c.cmd(0, "USER %s", user)
+	if err != nil {
+		return err
+	}
+
+	switch code {
+	case 230:
+	case 331:
+		if _, _, err := c.cmd(230, "PASS %s", password); err != nil {
+			return err
+		}
+	default:
+		return &Error{Code: code, Msg: "unexpected response to USER"}
+	}
+
+	if _, _, err := c.cmd(200, "TYPE I"); err != nil {
+		return err
+	}
+
+	c.feat()
+	if _, ok := c.features["UTF8"]; ok {
+		c.cmd(0, "OPTS UTF8 ON")
+	}
+
+	return nil
+}
+
+// AuthTLS 使用 AUTH TLS 将控制连接升级为 TLS 连接
+func (c *client) AuthTLS(config *tls.Config) error {
+	if _, _, err := c.cmd(234, "AUTH TLS"); err != nil {
+		return err
+	}
+
+	conn := tls.Client(c.conn, config)
+	if err := conn.Handshake(); err != nil {
+		c.broken = true
+		return err
+	}
+
+	c.setConn(conn)
+	return nil
+}
+
+// ProtectData 登录后要求数据连接同样使用 TLS
+func (c *client) ProtectData(config *tls.Config) error {
+	if _, _, err := c.cmd(200, "PBSZ 0"); err != nil {
+		return err
+	}
+
+	if _, _, err := c.cmd(200, "PROT P"); err != nil {
+		return err
+	}
+
+	c.tlsConfig = config
+	return nil
+}
+
+// feat 获取服务端支持的扩展
+func (c *client) feat() {
+	_, msg, err := c.cmd(211, "FEAT")
+	if err != nil {
+		return
+	}
+
+	lines := strings.Split(msg, "\n")
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.EqualFold(line, "END") {
+			continue
+		}
+
+		name, value, _ := strings.Cut(line, " ")
+		c.features[strings.ToUpper(name)] = value
+	}
+}
+
+// Noop 检查连接是否可用
+func (c *client) Noop() error {
+	_, _, err := c.cmd(200, "NOOP")
+	return err
+}
+
+// passivePort 获取被动模式的数据端口，优先使用 EPSV
+func (c *client) passivePort() (int, error) {
+	if !c.noEPSV {
+		_, msg, err := c.cmd(229, "EPSV")
+		if err == nil {
+			start, end := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+			if start >= 0 && end > start+1 {
+				fields := strings.Split(msg[start+1:end], msg[start+1:start+2])
+				if len(fields) == 5 {
+					return strconv.Atoi(fields[3])
+				}
+			}
+
+			return 0, fmt.Errorf("invalid EPSV response %q", msg)
+		}
+
+		if c.broken {
+			return 0, err
+		}
+		c.noEPSV = true
+	}
+
+	_, msg, err := c.cmd(227, "PASV")
+	if err != nil {
+		return 0, err
+	}
+
+	matches := pasvAddress.FindStringSubmatch(msg)
+	if matches == nil {
+		return 0, fmt.Errorf("invalid PASV response %q", msg)
+	}
+
+	high, _ := strconv.Atoi(matches[5])
+	low, _ := strconv.Atoi(matches[6])
+	return high<<8 | low, nil
+}
+
+// transfer 以被动模式建立数据连接并发送传输命令，传输完成后需调用 finish。
+// offset 大于 0 时在传输命令前发送 REST，服务端不支持时返回错误
+func (c *client) transfer(offset uint64, format string, args ...interface{}) (net.Conn, error) {
+	port, err := c.passivePort()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(c.dataHost, strconv.Itoa(port)), dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset > 0 {
+		if _, _, err := c.cmd(codeRestartMarker, "REST %d", offset); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if _, _, err := c.cmd(1, format, args...); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if c.tlsConfig != nil {
+		tlsConn := tls.Client(conn, c.tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			c.finish(nil)
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	return conn, nil
+}
+
+// finish 关闭数据连接并读取传输结果，传输中途关闭时服务端通常返回 426
+func (c *client) finish(conn net.Conn) error {
+	if conn != nil {
+		conn.Close()
+	}
+
+	_, _, err := c.readResponse(2)
+	return err
+}
+
+// Size 获取文件大小
+func (c *client) Size(p string) (uint64, error) {
+	_, msg, err := c.cmd(213, "SIZE %s", p)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(msg), 10, 64)
+}
+
+// Exists 检查文件是否存在
+func (c *client) Exists(p string) (bool, error) {
+	_, err := c.Size(p)
+	if err == nil {
+		return true, nil
+	}
+
+	if ftpErr, ok := err.(*Error); ok && ftpErr.Code == codeFileUnavailable {
+		return false, nil
+	}
+
+	return false, err
+}
+
+// Store 将 r 的内容写入文件 offset 处，offset 为 0 时覆盖文件
+func (c *client) Store(ctx context.Context, p string, r io.Reader, offset uint64) error {
+	return c.upload(ctx, offset, "STOR %s", p, r)
+}
+
+// Append 将 r 的内容追加至文件末尾
+func (c *client) Append(ctx context.Context, p string, r io.Reader) error {
+	return c.upload(ctx, 0, "APPE %s", p, r)
+}
+
+func (c *client) upload(ctx context.Context, offset uint64, command, p string, r io.Reader) error {
+	conn, err := c.transfer(offset, command, p)
+	if err != nil {
+		return err
+	}
+
+	_, err = copyWithContext(ctx, conn, r)
+	if finishErr := c.finish(conn); err == nil {
+		err = finishErr
+	}
+
+	return err
+}
+
+// Retrieve 从 offset 处读取文件
+func (c *client) Retrieve(p string, offset uint64) (net.Conn, error) {
+	return c.transfer(offset, "RETR %s", p)
+}
+
+// Delete 删除文件
+func (c *client) Delete(p string) error {
+	_, _, err := c.cmd(250, "DELE %s", p)
+	return err
+}
+
+// Rename 重命名文件
+func (c *client) Rename(from, to string) error {
+	if _, _, err := c.cmd(codeRestartMarker, "RNFR %s", from); err != nil {
+		return err
+	}
+
+	_, _, err := c.cmd(250, "RNTO %s", to)
+	return err
+}
+
+// MkdirAll 逐级创建目录，已存在的目录将被忽略
+func (c *client) MkdirAll(dir string) error {
+	if dir == "" || dir == "." || dir == "/" {
+		return nil
+	}
+
+	current := ""
+	if strings.HasPrefix(dir, "/") {
+		current = "/"
+	}
+
+	for _, name := range strings.Split(strings.Trim(dir, "/"), "/") {
+		current = path.Join(current, name)
+		if _, _, err := c.cmd(257, "MKD %s", current); err != nil && c.broken {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadDir 列出目录下的文件，服务端支持时使用 MLSD，否则解析 LIST 的输出
+func (c *client) ReadDir(dir string) ([]entry, error) {
+	_, mlsd := c.features["MLST"]
+	command := "LIST %s"
+	if mlsd {
+		command = "MLSD %s"
+	}
+
+	conn, err := c.transfer(0, command, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := io.ReadAll(conn)
+	if finishErr := c.finish(conn); err == nil {
+		err = finishErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []entry
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+
+		var (
+			e  *entry
+			ok bool
+		)
+		if mlsd {
+			e, ok = parseMLSD(line)
+		} else {
+			e, ok = parseList(line)
+		}
+
+		if ok && e.Name != "." && e.Name != ".." {
+			entries = append(entries, *e)
+		}
+	}
+
+	return entries, nil
+}
+
+// parseMLSD 解析 MLSD 输出的一行，参见 RFC 3659
+func parseMLSD(line string) (*entry, bool) {
+	facts, name, ok := strings.Cut(line, " ")
+	if !ok {
+		return nil, false
+	}
+
+	e := &entry{Name: name}
+	for _, fact := range strings.Split(facts, ";") {
+		key, value, _ := strings.Cut(fact, "=")
+		switch strings.ToLower(key) {
+		case "type":
+			switch strings.ToLower(value) {
+			case "dir":
+				e.IsDir = true
+			case "file":
+			default:
+				// 跳过 cdir、pdir 等
+				return nil, false
+			}
+		case "size":
+			e.Size, _ = strconv.ParseUint(value, 10, 64)
+		case "modify":
+			e.ModTime, _ = time.Parse("20060102150405", value[:min(len(value), 14)])
+		}
+	}
+
+	return e, true
+}
+
+// parseList 解析 Unix 风格的 LIST 输出，如
+// drwxr-xr-x 2 user group 4096 Jan  1 12:00 name
+func parseList(line string) (*entry, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 9 {
+		return nil, false
+	}
+
+	e := &entry{IsDir: strings.HasPrefix(fields[0], "d")}
+	if !e.IsDir && !strings.HasPrefix(fields[0], "-") {
+		return nil, false
+	}
+
+	e.Size, _ = strconv.ParseUint(fields[4], 10, 64)
+
+	// 文件名可能包含空格，取第 8 个字段之后的原始内容
+	rest := line
+	for i := 0; i < 8; i++ {
+		rest = strings.TrimLeft(rest, " ")
+		rest = rest[strings.Index(rest, " "):]
+	}
+	e.Name = strings.TrimLeft(rest, " ")
+
+	stamp := strings.Join(fields[5:8], " ")
+	if strings.Contains(fields[7], ":") {
+		e.ModTime, _ = time.Parse("Jan _2 15:04 2006", stamp+" "+strconv.Itoa(time.Now().Year()))
+		if e.ModTime.After(time.Now()) {
+			e.ModTime = e.ModTime.AddDate(-1, 0, 0)
+		}
+	} else {
+		e.ModTime, _ = time.Parse("Jan _2 2006", stamp)
+	}
+
+	return e, true
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,449 @@
+package ftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// uploadTempSuffix 上传中文件的后缀，上传完成后重命名为目标文件
+const uploadTempSuffix = ".cloudreve_upload"
+
+var (
+	ErrFileExisted = errors.New("file with the same name existed or unavailable")
+	ErrChunkOffset = errors.New("size of unfinished uploaded chunks is not as expected")
+)
+
+// Driver FTP/FTPS 存储策略适配器，Policy 中 Server 为服务器地址，AccessKey 为用户名，
+// SecretKey 为密码，BucketName 为远程存储根目录
+type Driver struct {
+	Policy *model.Policy
+	pool   *pool
+}
+
+// NewDriver 创建 FTP 适配器，同一存储策略的适配器共用连接池
+func NewDriver(policy *model.Policy) (*Driver, error) {
+	if policy.Server == "" {
+		return nil, errors.New("ftp server address is not set")
+	}
+
+	return &Driver{
+		Policy: policy,
+		pool:   getPool(policy),
+	}, nil
+}
+
+// remotePath 返回文件在远程服务器上的路径
+func (handler *Driver) remotePath(p string) string {
+	root := handler.Policy.BucketName
+	if root == "" {
+		root = "."
+	}
+
+	return path.Join(root, filepath.ToSlash(p))
+}
+
+// do 从连接池取出连接执行 fn，完成后归还
+func (handler *Driver) do(fn func(c *client) error) error {
+	c, err := handler.pool.get()
+	if err != nil {
+		return err
+	}
+	defer handler.pool.put(c)
+
+	return fn(c)
+}
+
+// List 列取远程端 path 路径下文件、目录
+func (handler *Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	base = strings.TrimPrefix(base, "/")
+	var res []response.Object
+
+	err := handler.do(func(c *client) error {
+		var walk func(rel string) error
+		walk = func(rel string) error {
+			entries, err := c.ReadDir(handler.remotePath(path.Join(base, rel)))
+			if err != nil {
+				return err
+			}
+
+			for _, entry := range entries {
+				// 跳过未完成的上传
+				if strings.HasSuffix(entry.Name, uploadTempSuffix) {
+					continue
+				}
+
+				relPath := path.Join(rel, entry.Name)
+				res = append(res, response.Object{
+					Name:         entry.Name,
+					RelativePath: relPath,
+					Source:       path.Join(base, relPath),
+					Size:         entry.Size,
+					IsDir:        entry.IsDir,
+					LastModify:   entry.ModTime,
+				})
+
+				if recursive && entry.IsDir {
+					if err := walk(relPath); err != nil {
+						return err
+					}
+				}
+			}
+
+			return nil
+		}
+
+		return walk("")
+	})
+
+	return res, err
+}
+
+// Get 获取文件内容，返回的文件关闭前独占一个连接
+func (handler *Driver) Get(ctx context.Context, p string) (response.RSCloser, error) {
+	c, err := handler.pool.get()
+	if err != nil {
+		return nil, err
+	}
+
+	src := handler.remotePath(p)
+	size, err := c.Size(src)
+	if err != nil {
+		handler.pool.put(c)
+		util.Log().Debug("Failed to open file: %s", err)
+		return nil, err
+	}
+
+	return &file{
+		c:    c,
+		path: src,
+		size: int64(size),
+		onClose: func() {
+			handler.pool.put(c)
+		},
+	}, nil
+}
+
+// Put 将文件流保存到指定目录，内容先写入临时文件，完成后重命名为目标文件。
+// 分片上传的临时文件在最后一个分片上传后由 CompleteUpload 重命名。
+func (handler *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
+	defer file.Close()
+	fileInfo := file.Info()
+	dst := handler.remotePath(fileInfo.SavePath)
+	temp := dst + uploadTempSuffix
+
+	return handler.do(func(c *client) error {
+		// 如果非 Overwrite，则检查是否有重名冲突
+		if fileInfo.Mode&fsctx.Overwrite != fsctx.Overwrite {
+			exist, err := c.Exists(dst)
+			if err != nil {
+				return err
+			}
+
+			if exist {
+				util.Log().Warning("File with the same name existed or unavailable: %s", dst)
+				return ErrFileExisted
+			}
+		}
+
+		if err := c.MkdirAll(path.Dir(dst)); err != nil {
+			util.Log().Warning("Failed to create directory: %s", err)
+			return err
+		}
+
+		if fileInfo.Mode&fsctx.Append == fsctx.Append {
+			return handler.appendChunk(ctx, c, temp, file, fileInfo.AppendStart)
+		}
+
+		err := c.Store(ctx, temp, file, 0)
+		if err == nil {
+			err = replace(c, temp, dst)
+		}
+
+		if err != nil {
+			if !c.broken {
+				c.Delete(temp)
+			}
+			return err
+		}
+
+		return nil
+	})
+}
+
+// appendChunk 将分片写入临时文件的 start 位置，
+// 覆盖已上传的分片需要服务端支持 REST STOR
+func (handler *Driver) appendChunk(ctx context.Context, c *client, temp string, file io.Reader, start uint64) error {
+	size, err := c.Size(temp)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		size = 0
+	}
+
+	if size < start {
+		return ErrChunkOffset
+	}
+
+	if start == 0 {
+		return c.Store(ctx, temp, file, 0)
+	}
+
+	if size == start {
+		return c.Append(ctx, temp, file)
+	}
+
+	util.Log().Info("Trying to overwrite chunk of %q at [%d].", temp, start)
+	if err := c.Store(ctx, temp, file, start); err != nil {
+		return fmt.Errorf("failed to overwrite chunk: %w", err)
+	}
+
+	return nil
+}
+
+// CompleteUpload 将分片上传的临时文件重命名为目标文件
+func (handler *Driver) CompleteUpload(ctx context.Context, dst string) error {
+	dst = handler.remotePath(dst)
+	return handler.do(func(c *client) error {
+		return replace(c, dst+uploadTempSuffix, dst)
+	})
+}
+
+// replace 将 src 重命名为 dst，部分服务端不允许重命名覆盖已有文件，需先删除 dst
+func replace(c *client, src, dst string) error {
+	if err := c.Delete(dst); err != nil && c.broken {
+		return err
+	}
+
+	return c.Rename(src, dst)
+}
+
+// Delete 删除一个或多个文件，
+// 返回未删除的文件，及遇到的最后一个错误
+func (handler *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	deleteFailed := make([]string, 0, len(files))
+	var retErr error
+
+	err := handler.do(func(c *client) error {
+		for _, value := range files {
+			dst := handler.remotePath(value)
+			err := c.Delete(dst)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				util.Log().Warning("Failed to delete file: %s", err)
+				retErr = err
+				deleteFailed = append(deleteFailed, value)
+			}
+
+			if c.broken {
+				return err
+			}
+
+			// 同时清理未完成上传的临时文件
+			_ = c.Delete(dst + uploadTempSuffix)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return files, err
+	}
+
+	return deleteFailed, retErr
+}
+
+// Thumb 获取文件缩略图
+func (handler *Driver) Thumb(ctx context.Context, file *model.File) (*response.ContentResponse, error) {
+	return nil, driver.ErrorThumbNotSupported
+}
+
+// Source 获取外链URL，文件内容由 Cloudreve 中转
+func (handler *Driver) Source(ctx context.Context, p string, ttl int64, isDownload bool, speed int) (string, error) {
+	return local.Driver{Policy: handler.Policy}.Source(ctx, p, ttl, isDownload, speed)
+}
+
+// Token 获取上传凭证，分片由 Cloudreve 中转上传
+func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
+	var exist bool
+	err := handler.do(func(c *client) (err error) {
+		exist, err = c.Exists(handler.remotePath(uploadSession.SavePath))
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if exist {
+		return nil, errors.New("placeholder file already exist")
+	}
+
+	return &serializer.UploadCredential{
+		SessionID: uploadSession.Key,
+		ChunkSize: handler.Policy.OptionsSerialized.ChunkSize,
+	}, nil
+}
+
+// CancelToken 取消上传凭证，删除未完成上传的临时文件
+func (handler *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	return handler.do(func(c *client) error {
+		err := c.Delete(handler.remotePath(uploadSession.SavePath) + uploadTempSuffix)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	})
+}
+
+// Capabilities 返回存储端支持的特性
+func (handler *Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		RangeGet:        true,
+		MultipartUpload: true,
+	}
+}
+
+// file 远程文件，读取时按需建立数据连接，Seek 后从新的位置重新建立
+type file struct {
+	c       *client
+	path    string
+	size    int64
+	offset  int64
+	body    net.Conn
+	onClose func()
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+
+	if f.body == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	if err == io.EOF {
+		finishErr := f.c.finish(f.body)
+		f.body = nil
+		if finishErr != nil {
+			return n, finishErr
+		}
+
+		if f.offset < f.size {
+			return n, io.ErrUnexpectedEOF
+		}
+	}
+
+	return n, err
+}
+
+// open 从当前位置开始读取，服务端不支持 REST 时丢弃 offset 之前的内容
+func (f *file) open() error {
+	body, err := f.c.Retrieve(f.path, uint64(f.offset))
+	if err == nil {
+		f.body = body
+		return nil
+	}
+
+	if f.offset == 0 || f.c.broken {
+		return err
+	}
+
+	util.Log().Debug("Server does not support REST, reading %q from start: %s", f.path, err)
+	body, err = f.c.Retrieve(f.path, 0)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.CopyN(io.Discard, body, f.offset); err != nil {
+		f.c.finish(body)
+		return err
+	}
+
+	f.body = body
+	return nil
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if offset != f.offset && f.body != nil {
+		// 中止当前传输，服务端此时通常返回 426
+		f.c.finish(f.body)
+		f.body = nil
+	}
+
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *file) Close() error {
+	if f.body != nil {
+		f.c.finish(f.body)
+		f.body = nil
+	}
+
+	if f.onClose != nil {
+		f.onClose()
+		f.onClose = nil
+	}
+
+	return nil
+}
+
+// copyWithContext 复制数据，上下文关闭时中止
+func copyWithContext(ctx context.Context, dst io.Writer, src io.Reader) (int64, error) {
+	var written int64
+	buf := make([]byte, 32*1024)
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+
+		n, err := src.Read(buf)
+		if n > 0 {
+			w, writeErr := dst.Write(buf[:n])
+			written += int64(w)
+			if writeErr != nil {
+				return written, writeErr
+			}
+		}
+
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
@@ -0,0 +1,640 @@
+package ftp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeServer 以本地目录模拟的 FTP 服务端
+type fakeServer struct {
+	root string
+	// mlsd 是否支持 MLSD
+	mlsd bool
+	// noREST 不支持断点续传
+	noREST bool
+	// noEPSV 不支持 EPSV
+	noEPSV bool
+	// tlsConfig 不为空时支持 AUTH TLS
+	tlsConfig *tls.Config
+}
+
+// session 单个控制连接的状态
+type session struct {
+	server  *fakeServer
+	conn    net.Conn
+	text    *textproto.Conn
+	data    net.Listener
+	protect bool
+	rest    int64
+	rnfr    string
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	sess := &session{server: s, conn: conn, text: textproto.NewConn(conn)}
+	defer conn.Close()
+
+	sess.reply(220, "ready")
+	for {
+		line, err := sess.text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		command, arg, _ := strings.Cut(line, " ")
+		if !sess.handle(strings.ToUpper(command), arg) {
+			return
+		}
+	}
+}
+
+func (sess *session) reply(code int, format string, args ...interface{}) {
+	sess.text.PrintfLine("%d %s", code, fmt.Sprintf(format, args...))
+}
+
+func (sess *session) local(p string) string {
+	return filepath.Join(sess.server.root, filepath.FromSlash(p))
+}
+
+// accept 接受被动模式的数据连接
+func (sess *session) accept() (net.Conn, bool) {
+	if sess.data == nil {
+		sess.reply(425, "use PASV first")
+		return nil, false
+	}
+
+	defer func() {
+		sess.data.Close()
+		sess.data = nil
+	}()
+
+	conn, err := sess.data.Accept()
+	if err != nil {
+		sess.reply(425, "can not open data connection")
+		return nil, false
+	}
+
+	sess.reply(150, "opening data connection")
+	if sess.protect {
+		conn = tls.Server(conn, sess.server.tlsConfig)
+	}
+
+	return conn, true
+}
+
+func (sess *session) handle(command, arg string) bool {
+	rest := sess.rest
+	sess.rest = 0
+
+	switch command {
+	case "USER":
+		sess.reply(331, "password required")
+	case "PASS":
+		sess.reply(230, "logged in")
+	case "TYPE", "OPTS", "NOOP", "PBSZ":
+		sess.reply(200, "ok")
+	case "PROT":
+		sess.protect = arg == "P"
+		sess.reply(200, "ok")
+	case "AUTH":
+		if sess.server.tlsConfig == nil {
+			sess.reply(502, "not supported")
+			break
+		}
+		sess.reply(234, "ok")
+		sess.conn = tls.Server(sess.conn, sess.server.tlsConfig)
+		sess.text = textproto.NewConn(sess.conn)
+	case "FEAT":
+		features := "211-Features:\r\n UTF8\r\n"
+		if sess.server.mlsd {
+			features += " MLST type*;size*;modify*;\r\n"
+		}
+		sess.text.W.WriteString(features + "211 End\r\n")
+		sess.text.W.Flush()
+	case "EPSV", "PASV":
+		if command == "EPSV" && sess.server.noEPSV {
+			sess.reply(502, "not supported")
+			break
+		}
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			sess.reply(425, "%s", err)
+			break
+		}
+		sess.data = ln
+		port := ln.Addr().(*net.TCPAddr).Port
+		if command == "EPSV" {
+			sess.reply(229, "Entering Extended Passive Mode (|||%d|)", port)
+		} else {
+			// 返回错误的地址，客户端应使用控制连接的地址
+			sess.reply(227, "Entering Passive Mode (10,0,0,1,%d,%d)", port>>8, port&0xff)
+		}
+	case "REST":
+		if sess.server.noREST {
+			sess.reply(502, "not supported")
+			break
+		}
+		sess.rest, _ = strconv.ParseInt(arg, 10, 64)
+		sess.reply(350, "restarting")
+	case "SIZE":
+		info, err := os.Stat(sess.local(arg))
+		if err != nil || info.IsDir() {
+			sess.reply(550, "not a file")
+			break
+		}
+		sess.reply(213, "%d", info.Size())
+	case "STOR", "APPE":
+		flag := os.O_WRONLY | os.O_CREATE
+		if command == "APPE" {
+			flag |= os.O_APPEND
+		} else if rest == 0 {
+			flag |= os.O_TRUNC
+		}
+		f, err := os.OpenFile(sess.local(arg), flag, 0644)
+		if err != nil {
+			sess.reply(550, "%s", err)
+			break
+		}
+		defer f.Close()
+		if rest > 0 {
+			f.Seek(rest, io.SeekStart)
+		}
+		conn, ok := sess.accept()
+		if !ok {
+			break
+		}
+		_, err = io.Copy(f, conn)
+		conn.Close()
+		if err != nil {
+			sess.reply(426, "transfer aborted")
+			break
+		}
+		sess.reply(226, "transfer complete")
+	case "RETR":
+		f, err := os.Open(sess.local(arg))
+		if err != nil {
+			sess.reply(550, "%s", err)
+			break
+		}
+		defer f.Close()
+		f.Seek(rest, io.SeekStart)
+		conn, ok := sess.accept()
+		if !ok {
+			break
+		}
+		_, err = io.Copy(conn, f)
+		conn.Close()
+		if err != nil {
+			sess.reply(426, "transfer aborted")
+			break
+		}
+		sess.reply(226, "transfer complete")
+	case "MLSD", "LIST":
+		entries, err := os.ReadDir(sess.local(arg))
+		if err != nil {
+			sess.reply(550, "%s", err)
+			break
+		}
+		conn, ok := sess.accept()
+		if !ok {
+			break
+		}
+		for _, entry := range entries {
+			info, _ := entry.Info()
+			if command == "MLSD" {
+				typ := "file"
+				if info.IsDir() {
+					typ = "dir"
+				}
+				fmt.Fprintf(conn, "type=%s;size=%d;modify=%s; %s\r\n", typ, info.Size(),
+					info.ModTime().UTC().Format("20060102150405"), info.Name())
+			} else {
+				mode := "-rw-r--r--"
+				if info.IsDir() {
+					mode = "drwxr-xr-x"
+				}
+				fmt.Fprintf(conn, "%s 1 user group %d %s %s\r\n", mode, info.Size(),
+					info.ModTime().Format("Jan _2 15:04"), info.Name())
+			}
+		}
+		conn.Close()
+		sess.reply(226, "transfer complete")
+	case "DELE":
+		info, err := os.Stat(sess.local(arg))
+		if err == nil && info.IsDir() {
+			sess.reply(450, "is a directory")
+			break
+		}
+		if err := os.Remove(sess.local(arg)); err != nil {
+			sess.reply(550, "%s", err)
+			break
+		}
+		sess.reply(250, "deleted")
+	case "RNFR":
+		if _, err := os.Stat(sess.local(arg)); err != nil {
+			sess.reply(550, "%s", err)
+			break
+		}
+		sess.rnfr = arg
+		sess.reply(350, "ready for RNTO")
+	case "RNTO":
+		if err := os.Rename(sess.local(sess.rnfr), sess.local(arg)); err != nil {
+			sess.reply(550, "%s", err)
+			break
+		}
+		sess.reply(250, "renamed")
+	case "MKD":
+		if err := os.Mkdir(sess.local(arg), 0755); err != nil {
+			sess.reply(550, "%s", err)
+			break
+		}
+		sess.reply(257, "created")
+	case "QUIT":
+		sess.reply(221, "bye")
+		return false
+	default:
+		sess.reply(502, "not implemented")
+	}
+
+	return true
+}
+
+// start 在本地端口启动服务端，返回监听地址
+func (s *fakeServer) start(t *testing.T, implicit bool) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if implicit {
+				conn = tls.Server(conn, s.tlsConfig)
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func newTestDriver(t *testing.T, server *fakeServer) (*Driver, string) {
+	server.root = t.TempDir()
+	policy := &model.Policy{Type: "ftp", Server: server.start(t, false), BucketName: "."}
+	handler := &Driver{
+		Policy: policy,
+		pool: &pool{dial: func() (*client, error) {
+			return dial(policy)
+		}},
+	}
+
+	return handler, server.root
+}
+
+// testTLSConfig 使用 httptest 自带的自签名证书
+func testTLSConfig(t *testing.T) *tls.Config {
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return &tls.Config{Certificates: server.TLS.Certificates}
+}
+
+func TestNewDriver(t *testing.T) {
+	a := assert.New(t)
+	_, err := NewDriver(&model.Policy{})
+	a.Error(err)
+
+	policy := &model.Policy{Model: gorm.Model{ID: 1}, Server: "127.0.0.1"}
+	handler, err := NewDriver(policy)
+	a.NoError(err)
+	handler2, _ := NewDriver(policy)
+	a.Equal(handler.pool, handler2.pool)
+
+	// 连接信息变更后使用新的连接池
+	policy3 := &model.Policy{Model: gorm.Model{ID: 1}, Server: "127.0.0.1"}
+	policy3.OptionsSerialized.FTPSMode = FTPSExplicit
+	handler3, _ := NewDriver(policy3)
+	a.NotEqual(handler.pool, handler3.pool)
+}
+
+func TestPool(t *testing.T) {
+	a := assert.New(t)
+	handler, _ := newTestDriver(t, &fakeServer{})
+
+	c, err := handler.pool.get()
+	a.NoError(err)
+	handler.pool.put(c)
+	a.Len(handler.pool.idle, 1)
+
+	// 复用空闲连接
+	c2, err := handler.pool.get()
+	a.NoError(err)
+	a.Equal(c, c2)
+
+	// 长时间空闲的连接检查后复用
+	c2.lastUsed = time.Now().Add(-time.Hour)
+	handler.pool.idle = append(handler.pool.idle, c2)
+	c3, err := handler.pool.get()
+	a.NoError(err)
+	a.Equal(c, c3)
+
+	// 已断开的连接不再复用
+	c3.conn.Close()
+	c3.lastUsed = time.Now().Add(-time.Hour)
+	handler.pool.idle = append(handler.pool.idle, c3)
+	c4, err := handler.pool.get()
+	a.NoError(err)
+	a.NotEqual(c3, c4)
+
+	// 损坏的连接不归还连接池
+	c4.broken = true
+	handler.pool.put(c4)
+	a.Len(handler.pool.idle, 0)
+}
+
+func TestDriver_Put(t *testing.T) {
+	a := assert.New(t)
+	handler, root := newTestDriver(t, &fakeServer{})
+
+	// 完整上传
+	err := handler.Put(context.Background(), &fsctx.FileStream{
+		SavePath: "dir/sub/a.txt",
+		File:     io.NopCloser(strings.NewReader("content")),
+	})
+	a.NoError(err)
+	content, _ := os.ReadFile(filepath.Join(root, "dir", "sub", "a.txt"))
+	a.Equal("content", string(content))
+	a.NoFileExists(filepath.Join(root, "dir", "sub", "a.txt"+uploadTempSuffix))
+
+	// 重名冲突
+	err = handler.Put(context.Background(), &fsctx.FileStream{
+		SavePath: "dir/sub/a.txt",
+		File:     io.NopCloser(strings.NewReader("new")),
+	})
+	a.Equal(ErrFileExisted, err)
+
+	// 覆盖
+	err = handler.Put(context.Background(), &fsctx.FileStream{
+		SavePath: "dir/sub/a.txt",
+		Mode:     fsctx.Overwrite,
+		File:     io.NopCloser(strings.NewReader("new")),
+	})
+	a.NoError(err)
+	content, _ = os.ReadFile(filepath.Join(root, "dir", "sub", "a.txt"))
+	a.Equal("new", string(content))
+
+	// 上下文已取消
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = handler.Put(ctx, &fsctx.FileStream{
+		SavePath: "b.txt",
+		File:     io.NopCloser(strings.NewReader("content")),
+	})
+	a.ErrorIs(err, context.Canceled)
+	a.NoFileExists(filepath.Join(root, "b.txt"+uploadTempSuffix))
+	a.NoFileExists(filepath.Join(root, "b.txt"))
+}
+
+func TestDriver_ChunkUpload(t *testing.T) {
+	a := assert.New(t)
+	server := &fakeServer{}
+	handler, root := newTestDriver(t, server)
+	dst := filepath.Join(root, "a.txt")
+	put := func(start uint64, data string) error {
+		mode := fsctx.Append
+		if start > 0 {
+			mode |= fsctx.Overwrite
+		}
+		return handler.Put(context.Background(), &fsctx.FileStream{
+			SavePath:    "a.txt",
+			Mode:        mode,
+			AppendStart: start,
+			File:        io.NopCloser(strings.NewReader(data)),
+		})
+	}
+
+	a.NoError(put(0, "123"))
+	a.Equal(ErrChunkOffset, put(6, "789"))
+	a.NoError(put(3, "xxx"))
+	// 重传分片
+	a.NoError(put(3, "456"))
+	a.NoFileExists(dst)
+
+	a.NoError(handler.CompleteUpload(context.Background(), "a.txt"))
+	content, _ := os.ReadFile(dst)
+	a.Equal("123456", string(content))
+
+	// 不支持 REST 时无法重传分片，但可继续追加
+	server.noREST = true
+	a.NoError(os.Remove(dst))
+	a.NoError(put(0, "123"))
+	a.NoError(put(3, "456"))
+	a.Error(put(3, "abc"))
+	a.NoError(handler.CompleteUpload(context.Background(), "a.txt"))
+	content, _ = os.ReadFile(dst)
+	a.Equal("123456", string(content))
+}
+
+func TestDriver_Get(t *testing.T) {
+	a := assert.New(t)
+	server := &fakeServer{}
+	handler, root := newTestDriver(t, server)
+	a.NoError(os.WriteFile(filepath.Join(root, "a.txt"), []byte("0123456789"), 0644))
+
+	_, err := handler.Get(context.Background(), "not_exist.txt")
+	a.ErrorIs(err, os.ErrNotExist)
+
+	read := func() {
+		file, err := handler.Get(context.Background(), "a.txt")
+		a.NoError(err)
+		size, err := file.Seek(0, io.SeekEnd)
+		a.NoError(err)
+		a.EqualValues(10, size)
+		_, err = file.Seek(5, io.SeekStart)
+		a.NoError(err)
+		buf := make([]byte, 2)
+		_, err = io.ReadFull(file, buf)
+		a.NoError(err)
+		a.Equal("56", string(buf))
+
+		// 读取中途重新定位
+		_, err = file.Seek(1, io.SeekStart)
+		a.NoError(err)
+		content, err := io.ReadAll(file)
+		a.NoError(err)
+		a.Equal("123456789", string(content))
+		a.NoError(file.Close())
+	}
+
+	read()
+	// 关闭后连接归还连接池
+	a.Len(handler.pool.idle, 1)
+
+	// 不支持 REST 时从头读取并跳过
+	server.noREST = true
+	read()
+	a.Len(handler.pool.idle, 1)
+}
+
+func TestDriver_Delete(t *testing.T) {
+	a := assert.New(t)
+	handler, root := newTestDriver(t, &fakeServer{})
+	a.NoError(os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0644))
+	a.NoError(os.WriteFile(filepath.Join(root, "b.txt"+uploadTempSuffix), []byte("b"), 0644))
+	a.NoError(os.Mkdir(filepath.Join(root, "dir"), 0755))
+
+	failed, err := handler.Delete(context.Background(), []string{"a.txt", "b.txt", "not_exist.txt", "dir"})
+	a.Error(err)
+	a.Equal([]string{"dir"}, failed)
+	a.NoFileExists(filepath.Join(root, "a.txt"))
+	a.NoFileExists(filepath.Join(root, "b.txt"+uploadTempSuffix))
+}
+
+func TestDriver_List(t *testing.T) {
+	a := assert.New(t)
+	for _, server := range []*fakeServer{{mlsd: true}, {noEPSV: true}} {
+		handler, root := newTestDriver(t, server)
+		a.NoError(os.MkdirAll(filepath.Join(root, "dir", "sub"), 0755))
+		a.NoError(os.WriteFile(filepath.Join(root, "dir", "a.txt"), []byte("a"), 0644))
+		a.NoError(os.WriteFile(filepath.Join(root, "dir", "b.txt"+uploadTempSuffix), []byte("b"), 0644))
+		a.NoError(os.WriteFile(filepath.Join(root, "dir", "sub", "c.txt"), []byte("cc"), 0644))
+
+		res, err := handler.List(context.Background(), "/dir", false)
+		a.NoError(err)
+		a.Len(res, 2)
+
+		res, err = handler.List(context.Background(), "/dir", true)
+		a.NoError(err)
+		a.Len(res, 3)
+		for _, object := range res {
+			if object.Name == "c.txt" {
+				a.Equal("sub/c.txt", object.RelativePath)
+				a.Equal("dir/sub/c.txt", object.Source)
+				a.EqualValues(2, object.Size)
+			}
+			if object.Name == "sub" {
+				a.True(object.IsDir)
+			}
+		}
+	}
+}
+
+func TestDriver_Token(t *testing.T) {
+	a := assert.New(t)
+	handler, root := newTestDriver(t, &fakeServer{})
+	handler.Policy.OptionsSerialized.ChunkSize = 10
+	a.NoError(os.WriteFile(filepath.Join(root, "exist.txt"), []byte("a"), 0644))
+
+	_, err := handler.Token(context.Background(), 10, &serializer.UploadSession{SavePath: "exist.txt"}, &fsctx.FileStream{})
+	a.Error(err)
+
+	res, err := handler.Token(context.Background(), 10, &serializer.UploadSession{Key: "key", SavePath: "a.txt"}, &fsctx.FileStream{})
+	a.NoError(err)
+	a.Equal("key", res.SessionID)
+	a.EqualValues(10, res.ChunkSize)
+
+	// 取消上传时删除临时文件
+	a.NoError(os.WriteFile(filepath.Join(root, "a.txt"+uploadTempSuffix), []byte("a"), 0644))
+	a.NoError(handler.CancelToken(context.Background(), &serializer.UploadSession{SavePath: "a.txt"}))
+	a.NoFileExists(filepath.Join(root, "a.txt"+uploadTempSuffix))
+	a.NoError(handler.CancelToken(context.Background(), &serializer.UploadSession{SavePath: "a.txt"}))
+}
+
+func TestDriver_Thumb(t *testing.T) {
+	handler, _ := newTestDriver(t, &fakeServer{})
+	_, err := handler.Thumb(context.Background(), &model.File{})
+	assert.Equal(t, driver.ErrorThumbNotSupported, err)
+}
+
+func TestDriver_FTPS(t *testing.T) {
+	a := assert.New(t)
+	for _, mode := range []string{FTPSExplicit, FTPSImplicit} {
+		server := &fakeServer{root: t.TempDir(), tlsConfig: testTLSConfig(t)}
+		policy := &model.Policy{Type: "ftp", Server: server.start(t, mode == FTPSImplicit), BucketName: "."}
+		policy.OptionsSerialized.FTPSMode = mode
+		handler := &Driver{Policy: policy, pool: &pool{dial: func() (*client, error) {
+			return dial(policy)
+		}}}
+
+		// 未跳过证书校验时自签名证书无法通过
+		_, err := handler.pool.get()
+		a.Error(err)
+
+		policy.OptionsSerialized.FTPSkipVerify = true
+		a.NoError(handler.Put(context.Background(), &fsctx.FileStream{
+			SavePath: "a.txt",
+			File:     io.NopCloser(strings.NewReader("content")),
+		}))
+
+		file, err := handler.Get(context.Background(), "a.txt")
+		a.NoError(err)
+		content, err := io.ReadAll(file)
+		a.NoError(err)
+		a.Equal("content", string(content))
+		a.NoError(file.Close())
+	}
+}
+
+func TestParseList(t *testing.T) {
+	a := assert.New(t)
+
+	e, ok := parseList("-rw-r--r--   1 user  group      1024 Mar  5  2020 file name.txt")
+	a.True(ok)
+	a.Equal("file name.txt", e.Name)
+	a.EqualValues(1024, e.Size)
+	a.False(e.IsDir)
+	a.Equal(time.Date(2020, 3, 5, 0, 0, 0, 0, time.UTC), e.ModTime)
+
+	e, ok = parseList("drwxr-xr-x 2 user group 4096 Jan  1 00:00 dir")
+	a.True(ok)
+	a.True(e.IsDir)
+	a.Equal("dir", e.Name)
+	a.Equal(0, e.ModTime.Hour())
+
+	_, ok = parseList("lrwxrwxrwx 1 user group 4 Jan  1 00:00 link -> dir")
+	a.False(ok)
+	_, ok = parseList("total 8")
+	a.False(ok)
+}
+
+func TestParseMLSD(t *testing.T) {
+	a := assert.New(t)
+
+	e, ok := parseMLSD("type=file;size=10;modify=20200305120000.123; a b.txt")
+	a.True(ok)
+	a.Equal("a b.txt", e.Name)
+	a.EqualValues(10, e.Size)
+	a.Equal(time.Date(2020, 3, 5, 12, 0, 0, 0, time.UTC), e.ModTime)
+
+	e, ok = parseMLSD("Type=dir;Modify=20200305120000; sub")
+	a.True(ok)
+	a.True(e.IsDir)
+
+	_, ok = parseMLSD("type=cdir; .")
+	a.False(ok)
+	_, ok = parseMLSD("invalid")
+	a.False(ok)
+}
@@ -0,0 +1,196 @@
+package ftp
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+)
+
+const (
+	// maxIdleClients 每个存储策略保留的最大空闲连接数
+	maxIdleClients = 4
+	// idleCheckInterval 空闲超过此时长的连接在复用前需检查是否可用
+	idleCheckInterval = 30 * time.Second
+	dialTimeout       = 10 * time.Second
+)
+
+// 加密方式
+const (
+	// FTPSExplicit 连接后使用 AUTH TLS 升级为加密连接
+	FTPSExplicit = "explicit"
+	// FTPSImplicit 直接建立 TLS 连接
+	FTPSImplicit = "implicit"
+)
+
+var (
+	poolsLock sync.Mutex
+	// pools 存储策略 ID -> 连接池
+	pools = make(map[uint]*pool)
+)
+
+// pool FTP 连接池
+type pool struct {
+	mu        sync.Mutex
+	idle      []*client
+	signature string
+	dial      func() (*client, error)
+}
+
+// getPool 获取存储策略对应的连接池，策略连接信息变更后将关闭旧连接池
+func getPool(policy *model.Policy) *pool {
+	signature := fmt.Sprintf("%s|%s|%x|%s|%t", policy.Server, policy.AccessKey,
+		sha256.Sum256([]byte(policy.SecretKey)), policy.OptionsSerialized.FTPSMode,
+		policy.OptionsSerialized.FTPSkipVerify)
+
+	poolsLock.Lock()
+	defer poolsLock.Unlock()
+
+	if p, ok := pools[policy.ID]; ok {
+		if p.signature == signature {
+			return p
+		}
+		p.close()
+	}
+
+	config := *policy
+	p := &pool{
+		signature: signature,
+		dial: func() (*client, error) {
+			return dial(&config)
+		},
+	}
+	pools[policy.ID] = p
+	return p
+}
+
+// get 取出一个可用的连接，没有空闲连接时新建
+func (p *pool) get() (*client, error) {
+	for {
+		p.mu.Lock()
+		if len(p.idle) == 0 {
+			p.mu.Unlock()
+			break
+		}
+
+		c := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.mu.Unlock()
+
+		if time.Since(c.lastUsed) < idleCheckInterval {
+			return c, nil
+		}
+
+		// 长时间空闲的连接可能已被服务端断开
+		if err := c.Noop(); err == nil {
+			return c, nil
+		}
+		c.Close()
+	}
+
+	return p.dial()
+}
+
+// put 归还连接，已损坏或超出空闲上限的连接将被关闭
+func (p *pool) put(c *client) {
+	if c.broken {
+		c.Close()
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.idle) >= maxIdleClients {
+		c.Close()
+		return
+	}
+
+	c.lastUsed = time.Now()
+	p.idle = append(p.idle, c)
+}
+
+// close 关闭所有空闲连接
+func (p *pool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, c := range p.idle {
+		c.Close()
+	}
+	p.idle = nil
+}
+
+// dial 建立控制连接并登录
+func dial(policy *model.Policy) (*client, error) {
+	mode := policy.OptionsSerialized.FTPSMode
+	addr := policy.Server
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		port := "21"
+		if mode == FTPSImplicit {
+			port = "990"
+		}
+		addr = net.JoinHostPort(addr, port)
+	}
+
+	host, _, _ := net.SplitHostPort(addr)
+	var tlsConfig *tls.Config
+	if mode != "" {
+		tlsConfig = &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: policy.OptionsSerialized.FTPSkipVerify,
+			// 部分服务端要求数据连接复用控制连接的 TLS 会话
+			ClientSessionCache: tls.NewLRUClientSessionCache(0),
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if mode == FTPSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := newClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := c.login(policy, tlsConfig); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// login 按加密方式完成握手并登录
+func (c *client) login(policy *model.Policy, tlsConfig *tls.Config) error {
+	if policy.OptionsSerialized.FTPSMode == FTPSExplicit {
+		if err := c.AuthTLS(tlsConfig); err != nil {
+			return fmt.Errorf("failed to upgrade connection: %w", err)
+		}
+	}
+
+	if err := c.Login(policy.AccessKey, policy.SecretKey); err != nil {
+		return err
+	}
+
+	if tlsConfig != nil {
+		return c.ProtectData(tlsConfig)
+	}
+
+	return nil
+}
@@ -0,0 +1,408 @@
+package gcs
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/jwt"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/api/storage/v1"
+)
+
+const defaultEndpoint = "https://storage.googleapis.com"
+
+// ErrFileExisted 上传的目标对象已存在
+var ErrFileExisted = errors.New("file already exist")
+
+// Driver Google Cloud Storage 存储策略适配器，Policy 中 SecretKey 为服务账号 JSON 凭证，
+// BucketName 为存储桶名称
+type Driver struct {
+	Policy     *model.Policy
+	HTTPClient request.Client
+
+	svc         *storage.Service
+	config      *jwt.Config
+	tokenSource oauth2.TokenSource
+	privateKey  *rsa.PrivateKey
+}
+
+// MetaData 文件信息
+type MetaData struct {
+	Size uint64
+	Etag string
+}
+
+// NewDriver 使用服务账号凭证初始化 GCS 适配器
+func NewDriver(policy *model.Policy) (*Driver, error) {
+	if policy.OptionsSerialized.ChunkSize == 0 {
+		policy.OptionsSerialized.ChunkSize = 25 << 20 // 25 MB
+	}
+
+	config, err := google.JWTConfigFromJSON([]byte(policy.SecretKey), storage.DevstorageReadWriteScope)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service account credential: %w", err)
+	}
+
+	privateKey, err := parsePrivateKey(config.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	handler := &Driver{
+		Policy:     policy,
+		HTTPClient: request.NewClient(request.WithProxy(policy.OptionsSerialized.Proxy)),
+		config:     config,
+		privateKey: privateKey,
+	}
+
+	ctx := context.Background()
+	proxy := policy.OptionsSerialized.Proxy
+	if proxy != "" {
+		// 获取访问令牌同样通过代理
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, request.ProxyHTTPClient(proxy))
+	}
+
+	handler.tokenSource = config.TokenSource(ctx)
+	opts := []option.ClientOption{option.WithTokenSource(handler.tokenSource)}
+	if proxy != "" {
+		opts = []option.ClientOption{option.WithHTTPClient(&http.Client{
+			Transport: &oauth2.Transport{Source: handler.tokenSource, Base: request.ProxyTransport(proxy)},
+		})}
+	}
+	if policy.Server != "" {
+		opts = append(opts, option.WithEndpoint(strings.TrimSuffix(policy.Server, "/")+"/storage/v1/"))
+	}
+
+	handler.svc, err = storage.NewService(context.Background(), opts...)
+	return handler, err
+}
+
+// endpoint 返回存储服务地址
+func (handler *Driver) endpoint() string {
+	if handler.Policy.Server != "" {
+		return strings.TrimSuffix(handler.Policy.Server, "/")
+	}
+
+	return defaultEndpoint
+}
+
+// isNotFound 返回错误是否为对象不存在
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+// List 列出给定路径下的文件
+func (handler *Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	base = strings.TrimPrefix(base, "/")
+	if base != "" {
+		base += "/"
+	}
+
+	call := handler.svc.Objects.List(handler.Policy.BucketName).Prefix(base)
+	if !recursive {
+		call = call.Delimiter("/")
+	}
+
+	res := make([]response.Object, 0)
+	err := call.Pages(ctx, func(objects *storage.Objects) error {
+		// 处理目录
+		for _, prefix := range objects.Prefixes {
+			rel, err := filepath.Rel(base, prefix)
+			if err != nil {
+				continue
+			}
+			res = append(res, response.Object{
+				Name:         path.Base(prefix),
+				RelativePath: filepath.ToSlash(rel),
+				Size:         0,
+				IsDir:        true,
+				LastModify:   time.Now(),
+			})
+		}
+
+		// 处理文件
+		for _, object := range objects.Items {
+			rel, err := filepath.Rel(base, object.Name)
+			if err != nil {
+				continue
+			}
+
+			lastModified, err := time.Parse(time.RFC3339, object.Updated)
+			if err != nil {
+				lastModified = time.Now()
+			}
+
+			res = append(res, response.Object{
+				Name:         path.Base(object.Name),
+				Source:       object.Name,
+				RelativePath: filepath.ToSlash(rel),
+				Size:         object.Size,
+				IsDir:        false,
+				LastModify:   lastModified,
+			})
+		}
+
+		return nil
+	})
+
+	return res, err
+}
+
+// Get 获取文件
+func (handler *Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	// 获取文件源地址
+	downloadURL, err := handler.Source(ctx, path, int64(model.GetIntSetting("preview_timeout", 60)), false, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// 获取文件数据流
+	resp, err := request.NewRangeRSCloser(func(header http.Header) *request.Response {
+		return handler.HTTPClient.Request(
+			"GET",
+			downloadURL,
+			nil,
+			request.WithContext(ctx),
+			request.WithHeader(
+				http.Header{"Cache-Control": {"no-cache", "no-store", "must-revalidate"}},
+			),
+			request.WithHeader(header),
+			request.WithTimeout(time.Duration(0)),
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 尝试自主获取文件大小
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
+		resp.SetContentLength(int64(file.Size))
+	}
+
+	return resp, nil
+}
+
+// Put 将文件流保存到指定目录，超过分片大小时使用可续传上传
+func (handler *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
+	defer file.Close()
+	fileInfo := file.Info()
+
+	call := handler.svc.Objects.Insert(handler.Policy.BucketName, &storage.Object{
+		Name:        fileInfo.SavePath,
+		ContentType: fileInfo.DetectMimeType(),
+	}).Media(
+		io.LimitReader(file, int64(fileInfo.Size)),
+		googleapi.ChunkSize(int(handler.Policy.OptionsSerialized.ChunkSize)),
+	).Context(ctx)
+
+	// 不允许覆盖时仅在对象不存在时写入
+	if fileInfo.Mode&fsctx.Overwrite != fsctx.Overwrite {
+		call = call.IfGenerationMatch(0)
+	}
+
+	_, err := call.Do()
+	return err
+}
+
+// Delete 删除一个或多个文件，已被生命周期规则删除的对象视为删除成功，
+// 返回未删除的文件，及遇到的最后一个错误
+func (handler *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	failed := make([]string, 0, len(files))
+	var retErr error
+
+	for _, file := range files {
+		err := handler.svc.Objects.Delete(handler.Policy.BucketName, file).Context(ctx).Do()
+		if err != nil && !isNotFound(err) {
+			util.Log().Warning("Failed to delete object %q: %s", file, err)
+			failed = append(failed, file)
+			retErr = err
+		}
+	}
+
+	return failed, retErr
+}
+
+// Thumb 获取文件缩略图，GCS 不支持图像处理，由 Cloudreve 代理生成
+func (handler *Driver) Thumb(ctx context.Context, file *model.File) (*response.ContentResponse, error) {
+	return nil, driver.ErrorThumbNotSupported
+}
+
+// Source 获取外链URL
+func (handler *Driver) Source(ctx context.Context, path string, ttl int64, isDownload bool, speed int) (string, error) {
+	// 尝试从上下文获取文件名
+	fileName := ""
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
+		fileName = file.Name
+	}
+
+	var (
+		sourceURL string
+		err       error
+	)
+
+	// 公有存储桶无需签名
+	if !handler.Policy.IsPrivate && !isDownload {
+		sourceURL = handler.endpoint() + "/" + handler.Policy.BucketName + "/" + escapePath(path)
+	} else {
+		extra := url.Values{}
+		if isDownload {
+			extra.Set("response-content-disposition", "attachment; filename=\""+url.PathEscape(fileName)+"\"")
+		}
+
+		sourceURL, err = handler.signURL("GET", path, time.Duration(ttl)*time.Second, extra)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// 将最终生成的URL域名换成用户自定义的加速域名（如果有）
+	finalURL, err := url.Parse(sourceURL)
+	if err != nil {
+		return "", err
+	}
+
+	if handler.Policy.BaseURL != "" {
+		cdnURL, err := url.Parse(handler.Policy.BaseURL)
+		if err != nil {
+			return "", err
+		}
+		finalURL.Host = cdnURL.Host
+		finalURL.Scheme = cdnURL.Scheme
+	}
+
+	return finalURL.String(), nil
+}
+
+// Token 创建可续传上传会话。客户端使用 Content-Range 向 UploadURLs 中的会话地址
+// 依次上传每个分片，上传完成后请求 Callback
+func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
+	// 检查文件是否存在
+	fileInfo := file.Info()
+	if _, err := handler.Meta(ctx, fileInfo.SavePath); err == nil {
+		return nil, ErrFileExisted
+	}
+
+	// 生成回调地址
+	siteURL := model.GetSiteURL()
+	apiBaseURI, _ := url.Parse("/api/v3/callback/gcs/" + uploadSession.Key)
+	apiURL := siteURL.ResolveReference(apiBaseURI).String()
+
+	token, err := handler.tokenSource.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	initURL := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=resumable&name=%s",
+		handler.endpoint(), url.PathEscape(handler.Policy.BucketName), url.QueryEscape(fileInfo.SavePath))
+	if fileInfo.Mode&fsctx.Overwrite != fsctx.Overwrite {
+		initURL += "&ifGenerationMatch=0"
+	}
+
+	// 会话地址只接受来自创建时 Origin 的跨域请求
+	resp := handler.HTTPClient.Request(
+		"POST",
+		initURL,
+		nil,
+		request.WithContext(ctx),
+		request.WithHeader(http.Header{
+			"Authorization":           {"Bearer " + token.AccessToken},
+			"X-Upload-Content-Type":   {fileInfo.DetectMimeType()},
+			"X-Upload-Content-Length": {fmt.Sprintf("%d", fileInfo.Size)},
+			"Origin":                  {strings.TrimSuffix(siteURL.String(), "/")},
+		}),
+	).CheckHTTPResponse(200)
+	if resp.Err != nil {
+		return nil, fmt.Errorf("failed to create resumable upload session: %w", resp.Err)
+	}
+	resp.Response.Body.Close()
+
+	uploadSession.UploadURL = resp.Response.Header.Get("Location")
+	if uploadSession.UploadURL == "" {
+		return nil, errors.New("empty resumable upload session url")
+	}
+
+	return &serializer.UploadCredential{
+		SessionID:  uploadSession.Key,
+		ChunkSize:  handler.Policy.OptionsSerialized.ChunkSize,
+		UploadURLs: []string{uploadSession.UploadURL},
+		Callback:   apiURL,
+	}, nil
+}
+
+// CancelToken 取消可续传上传会话
+func (handler *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	if uploadSession.UploadURL == "" {
+		return nil
+	}
+
+	// 取消成功时返回 499
+	resp := handler.HTTPClient.Request(
+		"DELETE",
+		uploadSession.UploadURL,
+		nil,
+		request.WithContext(ctx),
+		request.WithContentLength(0),
+	).CheckHTTPResponse(499)
+	if resp.Err != nil {
+		return resp.Err
+	}
+
+	resp.Response.Body.Close()
+	return nil
+}
+
+// Meta 获取文件信息
+func (handler *Driver) Meta(ctx context.Context, path string) (*MetaData, error) {
+	object, err := handler.svc.Objects.Get(handler.Policy.BucketName, path).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	return &MetaData{
+		Size: object.Size,
+		Etag: object.Etag,
+	}, nil
+}
+
+// CORS 创建跨域策略
+func (handler *Driver) CORS() error {
+	_, err := handler.svc.Buckets.Patch(handler.Policy.BucketName, &storage.Bucket{
+		Cors: []*storage.BucketCors{{
+			MaxAgeSeconds:  3600,
+			Method:         []string{"GET", "POST", "PUT", "DELETE", "HEAD"},
+			Origin:         []string{"*"},
+			ResponseHeader: []string{"*"},
+		}},
+	}).Do()
+
+	return err
+}
+
+// Capabilities 返回存储端支持的特性
+func (handler *Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		RangeGet:        true,
+		PresignedUpload: true,
+		MultipartUpload: true,
+	}
+}
@@ -0,0 +1,208 @@
+package gcs
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/mocks/requestmock"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+)
+
+// newTestDriver 创建使用 server 作为存储服务和令牌服务的适配器
+func newTestDriver(t *testing.T, server *httptest.Server) (*Driver, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	credential, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "test@project.iam.gserviceaccount.com",
+		"private_key": string(pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		})),
+		"token_uri": server.URL + "/token",
+	})
+
+	handler, err := NewDriver(&model.Policy{
+		Type:       "gcs",
+		Server:     server.URL,
+		SecretKey:  string(credential),
+		BucketName: "bucket",
+		IsPrivate:  true,
+	})
+	assert.NoError(t, err)
+	return handler, key
+}
+
+func newTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"token","token_type":"Bearer","expires_in":3600}`))
+		case strings.HasSuffix(r.URL.Path, "/o/exist.txt"):
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"name":"exist.txt","size":"10","etag":"etag"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":404,"message":"Not Found"}}`))
+		}
+	}))
+}
+
+func TestNewDriver(t *testing.T) {
+	a := assert.New(t)
+	_, err := NewDriver(&model.Policy{SecretKey: "{}"})
+	a.Error(err)
+
+	server := newTestServer()
+	defer server.Close()
+	handler, _ := newTestDriver(t, server)
+	a.EqualValues(25<<20, handler.Policy.OptionsSerialized.ChunkSize)
+}
+
+func TestDriver_Source(t *testing.T) {
+	a := assert.New(t)
+	server := newTestServer()
+	defer server.Close()
+	handler, key := newTestDriver(t, server)
+
+	// 私有存储桶，验证签名
+	ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, model.File{Name: "a b.txt"})
+	res, err := handler.Source(ctx, "dir/a b.txt", 60, true, 0)
+	a.NoError(err)
+	u, _ := url.Parse(res)
+	a.Equal("/bucket/dir/a b.txt", u.Path)
+	query := u.Query()
+	a.Equal("60", query.Get("X-Goog-Expires"))
+	a.Equal("attachment; filename=\"a%20b.txt\"", query.Get("response-content-disposition"))
+
+	signature, err := hex.DecodeString(query.Get("X-Goog-Signature"))
+	a.NoError(err)
+	query.Del("X-Goog-Signature")
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		"/bucket/dir/a%20b.txt",
+		canonicalQuery(query),
+		"host:" + u.Host,
+		"",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := strings.TrimPrefix(query.Get("X-Goog-Credential"), "test@project.iam.gserviceaccount.com/")
+	digest := sha256.Sum256([]byte(strings.Join([]string{
+		signAlgorithm,
+		query.Get("X-Goog-Date"),
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")))
+	a.NoError(rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature))
+
+	// 公有存储桶，使用 CDN
+	handler.Policy.IsPrivate = false
+	handler.Policy.BaseURL = "https://cdn.com"
+	res, err = handler.Source(context.Background(), "dir/a.txt", 60, false, 0)
+	a.NoError(err)
+	a.Equal("https://cdn.com/bucket/dir/a.txt", res)
+}
+
+func TestDriver_Meta(t *testing.T) {
+	a := assert.New(t)
+	server := newTestServer()
+	defer server.Close()
+	handler, _ := newTestDriver(t, server)
+
+	res, err := handler.Meta(context.Background(), "exist.txt")
+	a.NoError(err)
+	a.EqualValues(10, res.Size)
+
+	_, err = handler.Meta(context.Background(), "not_exist.txt")
+	a.True(isNotFound(err))
+}
+
+func TestDriver_Delete(t *testing.T) {
+	a := assert.New(t)
+	server := newTestServer()
+	defer server.Close()
+	handler, _ := newTestDriver(t, server)
+
+	// 对象不存在时视为删除成功
+	failed, err := handler.Delete(context.Background(), []string{"not_exist.txt"})
+	a.NoError(err)
+	a.Empty(failed)
+}
+
+func TestDriver_Token(t *testing.T) {
+	a := assert.New(t)
+	server := newTestServer()
+	defer server.Close()
+	handler, _ := newTestDriver(t, server)
+	session := &serializer.UploadSession{Key: "key"}
+
+	// 文件已存在
+	{
+		_, err := handler.Token(context.Background(), 10, session, &fsctx.FileStream{SavePath: "exist.txt"})
+		a.Equal(ErrFileExisted, err)
+	}
+
+	// 成功
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "POST", testMock.MatchedBy(func(target string) bool {
+			return strings.Contains(target, "uploadType=resumable&name=dir%2Fa.txt&ifGenerationMatch=0")
+		}), testMock.Anything, testMock.Anything).Return(&request.Response{
+			Response: &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Location": {"https://upload/session"}},
+				Body:       io.NopCloser(strings.NewReader("")),
+			},
+		})
+		handler.HTTPClient = clientMock
+		res, err := handler.Token(context.Background(), 10, session, &fsctx.FileStream{SavePath: "dir/a.txt", Size: 10})
+		clientMock.AssertExpectations(t)
+		a.NoError(err)
+		a.Equal([]string{"https://upload/session"}, res.UploadURLs)
+		a.Equal("https://upload/session", session.UploadURL)
+		a.Contains(res.Callback, "/api/v3/callback/gcs/key")
+	}
+}
+
+func TestDriver_CancelToken(t *testing.T) {
+	a := assert.New(t)
+	server := newTestServer()
+	defer server.Close()
+	handler, _ := newTestDriver(t, server)
+
+	// 没有上传会话
+	a.NoError(handler.CancelToken(context.Background(), &serializer.UploadSession{}))
+
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "DELETE", "https://upload/session", testMock.Anything, testMock.Anything).Return(&request.Response{
+		Response: &http.Response{
+			StatusCode: 499,
+			Body:       io.NopCloser(strings.NewReader("")),
+		},
+	})
+	handler.HTTPClient = clientMock
+	a.NoError(handler.CancelToken(context.Background(), &serializer.UploadSession{UploadURL: "https://upload/session"}))
+	clientMock.AssertExpectations(t)
+}
@@ -0,0 +1,452 @@
+package googledrive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/chunk"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/chunk/backoff"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+const (
+	folderMimeType = "application/vnd.google-apps.folder"
+	rootFolderID   = "root"
+	fileFields     = "id,name,mimeType,size,modifiedTime,thumbnailLink,imageMediaMetadata(width,height)"
+
+	// folderIDCachePrefix 目录路径 -> 目录 ID 的缓存前缀
+	folderIDCachePrefix = "googledrive_folder_"
+	chunkRetrySleep     = time.Duration(5) * time.Second
+)
+
+var (
+	// ErrObjectNotExist 文件不存在
+	ErrObjectNotExist = errors.New("object not exist")
+	// ErrFileExisted 同名文件已存在
+	ErrFileExisted = errors.New("file with the same name existed")
+	// ErrThumbNotExist 文件没有缩略图
+	ErrThumbNotExist = errors.New("thumb not exist")
+
+	queryEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+)
+
+// getRequestURL 获取接口请求地址
+func (client *Client) getRequestURL(api string, query url.Values) string {
+	base, _ := url.Parse(client.Endpoints.EndpointURL)
+	base.Path = path.Join(base.Path, api)
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("supportsAllDrives", "true")
+	base.RawQuery = query.Encode()
+	return base.String()
+}
+
+// ListChildren 列取目录下的所有文件
+func (client *Client) ListChildren(ctx context.Context, folderID string) ([]FileInfo, error) {
+	var (
+		res       []FileInfo
+		pageToken string
+	)
+
+	for {
+		query := url.Values{
+			"q":                         {fmt.Sprintf("'%s' in parents and trashed = false", queryEscaper.Replace(folderID))},
+			"fields":                    {"nextPageToken,files(" + fileFields + ")"},
+			"pageSize":                  {"1000"},
+			"includeItemsFromAllDrives": {"true"},
+		}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		body, err := client.requestWithStr(ctx, "GET", client.getRequestURL("files", query), "")
+		if err != nil {
+			return nil, err
+		}
+
+		var list ListResponse
+		if err := json.Unmarshal([]byte(body), &list); err != nil {
+			return nil, err
+		}
+
+		res = append(res, list.Files...)
+		if list.NextPageToken == "" {
+			return res, nil
+		}
+		pageToken = list.NextPageToken
+	}
+}
+
+// findChild 在目录下查找给定名称的文件，不存在时返回 ErrObjectNotExist
+func (client *Client) findChild(ctx context.Context, parentID, name string) (*FileInfo, error) {
+	query := url.Values{
+		"q": {fmt.Sprintf("name = '%s' and '%s' in parents and trashed = false",
+			queryEscaper.Replace(name), queryEscaper.Replace(parentID))},
+		"fields":                    {"files(" + fileFields + ")"},
+		"pageSize":                  {"1"},
+		"includeItemsFromAllDrives": {"true"},
+	}
+
+	body, err := client.requestWithStr(ctx, "GET", client.getRequestURL("files", query), "")
+	if err != nil {
+		return nil, err
+	}
+
+	var list ListResponse
+	if err := json.Unmarshal([]byte(body), &list); err != nil {
+		return nil, err
+	}
+
+	if len(list.Files) == 0 {
+		return nil, ErrObjectNotExist
+	}
+
+	return &list.Files[0], nil
+}
+
+// createFolder 在目录下创建子目录
+func (client *Client) createFolder(ctx context.Context, parentID, name string) (*FileInfo, error) {
+	meta, _ := json.Marshal(map[string]interface{}{
+		"name":     name,
+		"mimeType": folderMimeType,
+		"parents":  []string{parentID},
+	})
+
+	body, err := client.requestWithStr(ctx, "POST",
+		client.getRequestURL("files", url.Values{"fields": {fileFields}}), string(meta))
+	if err != nil {
+		return nil, err
+	}
+
+	var info FileInfo
+	if err := json.Unmarshal([]byte(body), &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// GetFolderID 根据路径逐级查找目录 ID，create 为真时创建不存在的目录
+func (client *Client) GetFolderID(ctx context.Context, dir string, create bool) (string, error) {
+	dir = strings.Trim(path.Clean("/"+dir), "/")
+	if dir == "" {
+		return rootFolderID, nil
+	}
+
+	cacheKey := fmt.Sprintf("%s%d_%s", folderIDCachePrefix, client.Policy.ID, dir)
+	if id, ok := cache.Get(cacheKey); ok {
+		return id.(string), nil
+	}
+
+	parentID, err := client.GetFolderID(ctx, path.Dir(dir), create)
+	if err != nil {
+		return "", err
+	}
+
+	name := path.Base(dir)
+	folder, err := client.findChild(ctx, parentID, name)
+	if errors.Is(err, ErrObjectNotExist) && create {
+		folder, err = client.createFolder(ctx, parentID, name)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if !folder.IsFolder() {
+		return "", fmt.Errorf("%q is not a folder", dir)
+	}
+
+	cache.Set(cacheKey, folder.ID, 0)
+	return folder.ID, nil
+}
+
+// Meta 根据路径获取文件元信息
+func (client *Client) Meta(ctx context.Context, p string) (*FileInfo, error) {
+	parentID, err := client.GetFolderID(ctx, path.Dir(path.Clean("/"+p)), false)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.findChild(ctx, parentID, path.Base(p))
+}
+
+// CreateUploadSession 创建可续传的上传会话，返回上传地址
+func (client *Client) CreateUploadSession(ctx context.Context, dst string, size uint64, overwrite bool) (string, error) {
+	dst = path.Clean("/" + dst)
+	existed, err := client.Meta(ctx, dst)
+	if err != nil && !errors.Is(err, ErrObjectNotExist) {
+		return "", err
+	}
+
+	var (
+		method = "POST"
+		api    = "files"
+		meta   = map[string]interface{}{"name": path.Base(dst)}
+	)
+
+	if existed != nil {
+		if !overwrite {
+			return "", ErrFileExisted
+		}
+
+		// 更新已有文件的内容
+		method = "PATCH"
+		api = "files/" + existed.ID
+	} else {
+		parentID, err := client.GetFolderID(ctx, path.Dir(dst), true)
+		if err != nil {
+			return "", err
+		}
+
+		meta["parents"] = []string{parentID}
+	}
+
+	uploadURL, _ := url.Parse(client.Endpoints.UploadEndpointURL)
+	uploadURL.Path = path.Join(uploadURL.Path, api)
+	uploadURL.RawQuery = url.Values{"uploadType": {"resumable"}, "supportsAllDrives": {"true"}}.Encode()
+
+	body, _ := json.Marshal(meta)
+	_, header, err := client.requestWithHeader(ctx, method, uploadURL.String(),
+		io.NopCloser(strings.NewReader(string(body))),
+		request.WithContentLength(int64(len(body))),
+		request.WithHeader(http.Header{
+			"X-Upload-Content-Length": {fmt.Sprintf("%d", size)},
+			// 允许浏览器跨域上传到会话地址
+			"Origin": {strings.TrimSuffix(model.GetSiteURL().String(), "/")},
+		}),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	location := header.Get("Location")
+	if location == "" {
+		return "", errors.New("failed to get upload session url")
+	}
+
+	return location, nil
+}
+
+// UploadChunk 上传分片
+func (client *Client) UploadChunk(ctx context.Context, uploadURL string, content io.Reader, current *chunk.ChunkGroup) error {
+	rangeHeader := current.RangeHeader()
+	if current.Total() == 0 {
+		rangeHeader = "bytes */0"
+	}
+
+	_, err := client.request(
+		ctx, "PUT", uploadURL, content,
+		request.WithContentLength(current.Length()),
+		request.WithHeader(http.Header{
+			"Content-Range": {rangeHeader},
+		}),
+		request.WithoutHeader([]string{"Content-Type"}),
+		request.WithTimeout(0),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to upload Google Drive chunk #%d: %w", current.Index(), err)
+	}
+
+	return nil
+}
+
+// Upload 上传文件
+func (client *Client) Upload(ctx context.Context, file fsctx.FileHeader) error {
+	fileInfo := file.Info()
+	overwrite := fileInfo.Mode&fsctx.Overwrite == fsctx.Overwrite
+
+	uploadURL, err := client.CreateUploadSession(ctx, fileInfo.SavePath, fileInfo.Size, overwrite)
+	if err != nil {
+		return err
+	}
+
+	chunks := chunk.NewChunkGroup(file, client.Policy.OptionsSerialized.ChunkSize, &backoff.ConstantBackoff{
+		Max:   model.GetIntSetting("chunk_retries", 5),
+		Sleep: chunkRetrySleep,
+	}, model.IsTrueVal(model.GetSettingByName("use_temp_chunk_buffer")))
+
+	uploadFunc := func(current *chunk.ChunkGroup, content io.Reader) error {
+		return client.UploadChunk(ctx, uploadURL, content, current)
+	}
+
+	for chunks.Next() {
+		if err := chunks.Process(uploadFunc); err != nil {
+			client.DeleteUploadSession(context.Background(), uploadURL)
+			return fmt.Errorf("failed to upload chunk #%d: %w", chunks.Index(), err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteUploadSession 删除上传会话
+func (client *Client) DeleteUploadSession(ctx context.Context, uploadURL string) error {
+	// 取消成功时返回 499
+	_, err := client.Request.Request(
+		"DELETE",
+		uploadURL,
+		nil,
+		request.WithContext(ctx),
+	).CheckHTTPResponse(499).GetResponse()
+	return err
+}
+
+// Delete 删除文件，返回删除失败的文件及遇到的最后一个错误
+func (client *Client) Delete(ctx context.Context, files []string) ([]string, error) {
+	failed := make([]string, 0, len(files))
+	var retErr error
+
+	for _, file := range files {
+		info, err := client.Meta(ctx, file)
+		if err == nil {
+			_, err = client.requestWithStr(ctx, "DELETE", client.getRequestURL("files/"+info.ID, nil), "")
+		}
+
+		var apiErr *RespError
+		if err == nil || errors.Is(err, ErrObjectNotExist) || (errors.As(err, &apiErr) && apiErr.APIError.Code == http.StatusNotFound) {
+			continue
+		}
+
+		util.Log().Warning("Failed to delete file %q: %s", file, err)
+		failed = append(failed, file)
+		retErr = err
+	}
+
+	return failed, retErr
+}
+
+// GetThumbURL 获取给定尺寸的缩略图地址
+func (client *Client) GetThumbURL(ctx context.Context, dst string, w, h uint) (string, error) {
+	info, err := client.Meta(ctx, dst)
+	if err != nil {
+		return "", err
+	}
+
+	if info.ThumbnailLink == "" {
+		return "", ErrThumbNotExist
+	}
+
+	size := w
+	if h > size {
+		size = h
+	}
+
+	// 缩略图地址以 "=s220" 形式的后缀指定尺寸
+	thumbURL := info.ThumbnailLink
+	if i := strings.LastIndex(thumbURL, "=s"); i > 0 {
+		thumbURL = thumbURL[:i]
+	}
+
+	return fmt.Sprintf("%s=s%d", thumbURL, size), nil
+}
+
+// Download 获取文件内容，header 为额外的请求头，如 Range
+func (client *Client) Download(ctx context.Context, id string, header http.Header) (*request.Response, error) {
+	if err := client.UpdateCredential(ctx, conf.SystemConfig.Mode == "slave"); err != nil {
+		return nil, sysError(err)
+	}
+
+	res := client.Request.Request(
+		"GET",
+		client.getRequestURL("files/"+id, url.Values{"alt": {"media"}}),
+		nil,
+		request.WithContext(ctx),
+		request.WithHeader(http.Header{
+			"Authorization": {"Bearer " + client.Credential.AccessToken},
+		}),
+		request.WithHeader(header),
+		request.WithTimeout(time.Duration(0)),
+	)
+
+	return res, res.Err
+}
+
+func sysError(err error) *RespError {
+	return &RespError{APIError: APIError{
+		Message: err.Error(),
+	}}
+}
+
+func (client *Client) request(ctx context.Context, method string, url string, body io.Reader, option ...request.Option) (string, error) {
+	res, _, err := client.requestWithHeader(ctx, method, url, body, option...)
+	return res, err
+}
+
+func (client *Client) requestWithHeader(ctx context.Context, method string, url string, body io.Reader, option ...request.Option) (string, http.Header, error) {
+	// 获取凭证
+	err := client.UpdateCredential(ctx, conf.SystemConfig.Mode == "slave")
+	if err != nil {
+		return "", nil, sysError(err)
+	}
+
+	option = append([]request.Option{
+		request.WithHeader(http.Header{
+			"Authorization": {"Bearer " + client.Credential.AccessToken},
+			"Content-Type":  {"application/json"},
+		}),
+		request.WithContext(ctx),
+		request.WithTPSLimit(
+			fmt.Sprintf("policy_%d", client.Policy.ID),
+			client.Policy.OptionsSerialized.TPSLimit,
+			client.Policy.OptionsSerialized.TPSLimitBurst,
+		),
+	}, option...)
+
+	// 发送请求
+	res := client.Request.Request(
+		method,
+		url,
+		body,
+		option...,
+	)
+
+	if res.Err != nil {
+		return "", nil, sysError(res.Err)
+	}
+
+	respBody, err := res.GetResponse()
+	if err != nil {
+		return "", nil, sysError(err)
+	}
+
+	// 可续传上传的中间分片返回 308
+	if (res.Response.StatusCode < 200 || res.Response.StatusCode >= 300) && res.Response.StatusCode != 308 {
+		var errResp RespError
+		if decodeErr := json.Unmarshal([]byte(respBody), &errResp); decodeErr != nil {
+			util.Log().Debug("Google Drive returns unknown response: %s", respBody)
+			return "", nil, sysError(decodeErr)
+		}
+
+		if res.Response.StatusCode == 429 {
+			util.Log().Warning("Google Drive request is throttled.")
+			return "", nil, backoff.NewRetryableErrorFromHeader(&errResp, res.Response.Header)
+		}
+
+		return "", nil, &errResp
+	}
+
+	return respBody, res.Response.Header, nil
+}
+
+func (client *Client) requestWithStr(ctx context.Context, method string, url string, body string) (string, error) {
+	// 发送请求
+	bodyReader := io.NopCloser(strings.NewReader(body))
+	return client.request(ctx, method, url, bodyReader,
+		request.WithContentLength(int64(len(body))),
+	)
+}
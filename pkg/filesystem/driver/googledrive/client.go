@@ -27,14 +27,16 @@ type Endpoints struct {
 	UserConsentEndpoint string // OAuth认证的基URL
 	TokenEndpoint       string // OAuth token 基URL
 	EndpointURL         string // 接口请求的基URL
+	UploadEndpointURL   string // 上传接口的基URL
 }
 
 const (
 	TokenCachePrefix = "googledrive_"
 
-	oauthEndpoint   = "https://oauth2.googleapis.com/token"
-	userConsentBase = "https://accounts.google.com/o/oauth2/auth"
-	v3DriveEndpoint = "https://www.googleapis.com/drive/v3"
+	oauthEndpoint    = "https://oauth2.googleapis.com/token"
+	userConsentBase  = "https://accounts.google.com/o/oauth2/auth"
+	v3DriveEndpoint  = "https://www.googleapis.com/drive/v3"
+	v3UploadEndpoint = "https://www.googleapis.com/upload/drive/v3"
 )
 
 var (
@@ -57,6 +59,7 @@ func NewClient(policy *model.Policy) (*Client, error) {
 			TokenEndpoint:       oauthEndpoint,
 			UserConsentEndpoint: userConsentBase,
 			EndpointURL:         v3DriveEndpoint,
+			UploadEndpointURL:   v3UploadEndpoint,
 		},
 		Credential: &Credential{
 			RefreshToken: policy.AccessKey,
@@ -65,7 +68,7 @@ func NewClient(policy *model.Policy) (*Client, error) {
 		ClientID:          policy.BucketName,
 		ClientSecret:      policy.SecretKey,
 		Redirect:          policy.OptionsSerialized.OauthRedirect,
-		Request:           request.NewClient(),
+		Request:           request.NewClient(request.WithProxy(policy.OptionsSerialized.Proxy)),
 		ClusterController: cluster.DefaultController,
 	}
 
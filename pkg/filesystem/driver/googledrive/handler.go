@@ -2,8 +2,15 @@ package googledrive
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
 	"github.com/cloudreve/Cloudreve/v3/pkg/request"
@@ -13,53 +20,166 @@ import (
 // Driver Google Drive 适配器
 type Driver struct {
 	Policy     *model.Policy
+	Client     *Client
 	HTTPClient request.Client
 }
 
 // NewDriver 从存储策略初始化新的Driver实例
 func NewDriver(policy *model.Policy) (driver.Handler, error) {
+	client, err := NewClient(policy)
+	if policy.OptionsSerialized.ChunkSize == 0 {
+		policy.OptionsSerialized.ChunkSize = 50 << 20 // 50MB
+	}
+
 	return &Driver{
 		Policy:     policy,
-		HTTPClient: request.NewClient(),
-	}, nil
+		Client:     client,
+		HTTPClient: request.NewClient(request.WithProxy(policy.OptionsSerialized.Proxy)),
+	}, err
 }
 
+// Put 将文件流保存到指定目录
 func (d *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
-	//TODO implement me
-	panic("implement me")
+	defer file.Close()
+
+	return d.Client.Upload(ctx, file)
 }
 
+// Delete 删除一个或多个文件，
+// 返回未删除的文件，及遇到的最后一个错误
 func (d *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
-	//TODO implement me
-	panic("implement me")
+	return d.Client.Delete(ctx, files)
 }
 
+// Get 获取文件
 func (d *Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
-	//TODO implement me
-	panic("implement me")
+	info, err := d.Client.Meta(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := request.NewRangeRSCloser(func(header http.Header) *request.Response {
+		res, err := d.Client.Download(ctx, info.ID, header)
+		if err != nil {
+			return &request.Response{Err: err}
+		}
+		return res
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp.SetContentLength(int64(info.Size))
+	return resp, nil
 }
 
+// Thumb 获取文件缩略图
 func (d *Driver) Thumb(ctx context.Context, file *model.File) (*response.ContentResponse, error) {
-	//TODO implement me
-	panic("implement me")
+	var (
+		thumbSize = [2]uint{400, 300}
+		ok        = false
+	)
+	if thumbSize, ok = ctx.Value(fsctx.ThumbSizeCtx).([2]uint); !ok {
+		return nil, errors.New("failed to get thumbnail size")
+	}
+
+	res, err := d.Client.GetThumbURL(ctx, file.SourceName, thumbSize[0], thumbSize[1])
+	if err != nil {
+		if errors.Is(err, ErrThumbNotExist) || errors.Is(err, ErrObjectNotExist) {
+			// Google Drive cannot generate thumbnail for this file
+			return nil, driver.ErrorThumbNotSupported
+		}
+
+		return nil, err
+	}
+
+	return &response.ContentResponse{
+		Redirect: true,
+		URL:      res,
+	}, nil
 }
 
+// Source 获取外链URL，Google Drive 的下载地址需要认证，文件内容由 Cloudreve 中转
 func (d *Driver) Source(ctx context.Context, path string, ttl int64, isDownload bool, speed int) (string, error) {
-	//TODO implement me
-	panic("implement me")
+	return local.Driver{Policy: d.Policy}.Source(ctx, path, ttl, isDownload, speed)
 }
 
+// Token 获取上传会话URL
 func (d *Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
-	//TODO implement me
-	panic("implement me")
+	fileInfo := file.Info()
+
+	uploadURL, err := d.Client.CreateUploadSession(ctx, fileInfo.SavePath, fileInfo.Size, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// 生成回调地址
+	siteURL := model.GetSiteURL()
+	apiBaseURI, _ := url.Parse("/api/v3/callback/googledrive/finish/" + uploadSession.Key)
+	apiURL := siteURL.ResolveReference(apiBaseURI)
+
+	uploadSession.UploadURL = uploadURL
+	return &serializer.UploadCredential{
+		SessionID:  uploadSession.Key,
+		ChunkSize:  d.Policy.OptionsSerialized.ChunkSize,
+		UploadURLs: []string{uploadURL},
+		Callback:   apiURL.String(),
+	}, nil
 }
 
+// CancelToken 取消上传凭证
 func (d *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
-	//TODO implement me
-	panic("implement me")
+	return d.Client.DeleteUploadSession(ctx, uploadSession.UploadURL)
+}
+
+// List 列取项目
+func (d *Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	base = strings.Trim(base, "/")
+	folderID, err := d.Client.GetFolderID(ctx, base, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		res  []response.Object
+		walk func(folderID, rel string) error
+	)
+	walk = func(folderID, rel string) error {
+		objects, err := d.Client.ListChildren(ctx, folderID)
+		if err != nil {
+			return err
+		}
+
+		for _, object := range objects {
+			relPath := path.Join(rel, object.Name)
+			res = append(res, response.Object{
+				Name:         object.Name,
+				RelativePath: relPath,
+				Source:       path.Join(base, relPath),
+				Size:         object.Size,
+				IsDir:        object.IsFolder(),
+				LastModify:   object.ModifiedTime,
+			})
+
+			if recursive && object.IsFolder() {
+				if err := walk(object.ID, relPath); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	return res, walk(folderID, "")
 }
 
-func (d *Driver) List(ctx context.Context, path string, recursive bool) ([]response.Object, error) {
-	//TODO implement me
-	panic("implement me")
+// Capabilities 返回存储端支持的特性
+func (d *Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		RangeGet:        true,
+		PresignedUpload: true,
+		MultipartUpload: true,
+		Thumbnail:       true,
+	}
 }
@@ -0,0 +1,343 @@
+package googledrive
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/mocks/requestmock"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+)
+
+func newTestDriver(t *testing.T, clientMock request.Client) *Driver {
+	h, err := NewDriver(&model.Policy{
+		Model:      gorm.Model{ID: 1},
+		Type:       "googledrive",
+		AccessKey:  "refresh",
+		BucketName: "client_id",
+		SecretKey:  "client_secret",
+	})
+	assert.NoError(t, err)
+	cache.Set("setting_siteURL", "http://test.cloudreve.org", 0)
+	cache.Deletes([]string{"1_dir", "1_dir/sub"}, folderIDCachePrefix)
+
+	handler := h.(*Driver)
+	handler.Client.Credential.AccessToken = "token"
+	handler.Client.Credential.ExpiresIn = time.Now().Add(time.Hour).Unix()
+	handler.Client.Request = clientMock
+	return handler
+}
+
+func newResponse(status int, header http.Header, body string) *request.Response {
+	return &request.Response{
+		Response: &http.Response{
+			StatusCode:    status,
+			Header:        header,
+			ContentLength: int64(len(body)),
+			Body:          io.NopCloser(strings.NewReader(body)),
+		},
+	}
+}
+
+// queryContains 匹配查询条件中包含给定片段的列取请求
+func queryContains(s string) interface{} {
+	return testMock.MatchedBy(func(u string) bool {
+		parsed, err := url.Parse(u)
+		return err == nil && strings.Contains(parsed.Query().Get("q"), s)
+	})
+}
+
+func TestNewDriver(t *testing.T) {
+	a := assert.New(t)
+	h, err := NewDriver(&model.Policy{})
+	a.NoError(err)
+	a.EqualValues(50<<20, h.(*Driver).Policy.OptionsSerialized.ChunkSize)
+}
+
+func TestClient_GetFolderID(t *testing.T) {
+	a := assert.New(t)
+
+	// 根目录
+	{
+		handler := newTestDriver(t, &requestmock.RequestMock{})
+		id, err := handler.Client.GetFolderID(context.Background(), "/", false)
+		a.NoError(err)
+		a.Equal(rootFolderID, id)
+	}
+
+	// 目录不存在，自动创建
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "GET", queryContains("name = 'dir' and 'root' in parents"), testMock.Anything, testMock.Anything).
+			Return(newResponse(200, nil, `{"files":[]}`))
+		clientMock.On("Request", "POST", "https://www.googleapis.com/drive/v3/files?fields="+url.QueryEscape(fileFields)+"&supportsAllDrives=true", testMock.Anything, testMock.Anything).
+			Return(newResponse(200, nil, `{"id":"d1","name":"dir","mimeType":"application/vnd.google-apps.folder"}`))
+		handler := newTestDriver(t, clientMock)
+		id, err := handler.Client.GetFolderID(context.Background(), "/dir", true)
+		clientMock.AssertExpectations(t)
+		a.NoError(err)
+		a.Equal("d1", id)
+
+		// 使用缓存
+		id, err = handler.Client.GetFolderID(context.Background(), "dir/", false)
+		a.NoError(err)
+		a.Equal("d1", id)
+	}
+
+	// 目录不存在，不创建
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "GET", queryContains("name = 'dir'"), testMock.Anything, testMock.Anything).
+			Return(newResponse(200, nil, `{"files":[]}`))
+		handler := newTestDriver(t, clientMock)
+		_, err := handler.Client.GetFolderID(context.Background(), "/dir", false)
+		clientMock.AssertExpectations(t)
+		a.ErrorIs(err, ErrObjectNotExist)
+	}
+
+	// 同名文件不是目录
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "GET", queryContains("name = 'dir'"), testMock.Anything, testMock.Anything).
+			Return(newResponse(200, nil, `{"files":[{"id":"f1","name":"dir","mimeType":"text/plain"}]}`))
+		handler := newTestDriver(t, clientMock)
+		_, err := handler.Client.GetFolderID(context.Background(), "/dir", false)
+		clientMock.AssertExpectations(t)
+		a.Error(err)
+	}
+}
+
+func TestDriver_Token(t *testing.T) {
+	a := assert.New(t)
+	file := &fsctx.FileStream{SavePath: "/dir/a.txt", Size: 10}
+
+	// 文件已存在
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "GET", queryContains("name = 'dir'"), testMock.Anything, testMock.Anything).
+			Return(newResponse(200, nil, `{"files":[{"id":"d1","name":"dir","mimeType":"application/vnd.google-apps.folder"}]}`))
+		clientMock.On("Request", "GET", queryContains("name = 'a.txt' and 'd1' in parents"), testMock.Anything, testMock.Anything).
+			Return(newResponse(200, nil, `{"files":[{"id":"f1","name":"a.txt","size":"10"}]}`))
+		handler := newTestDriver(t, clientMock)
+		_, err := handler.Token(context.Background(), 10, &serializer.UploadSession{Key: "key"}, file)
+		clientMock.AssertExpectations(t)
+		a.ErrorIs(err, ErrFileExisted)
+	}
+
+	// 成功
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "GET", queryContains("name = 'dir'"), testMock.Anything, testMock.Anything).
+			Return(newResponse(200, nil, `{"files":[{"id":"d1","name":"dir","mimeType":"application/vnd.google-apps.folder"}]}`))
+		clientMock.On("Request", "GET", queryContains("name = 'a.txt'"), testMock.Anything, testMock.Anything).
+			Return(newResponse(200, nil, `{"files":[]}`))
+		clientMock.On("Request", "POST", "https://www.googleapis.com/upload/drive/v3/files?supportsAllDrives=true&uploadType=resumable", testMock.Anything, testMock.Anything).
+			Return(newResponse(200, http.Header{"Location": {"https://upload.com/session"}}, `{}`))
+		handler := newTestDriver(t, clientMock)
+		uploadSession := &serializer.UploadSession{Key: "key"}
+		res, err := handler.Token(context.Background(), 10, uploadSession, file)
+		clientMock.AssertExpectations(t)
+		a.NoError(err)
+		a.Equal([]string{"https://upload.com/session"}, res.UploadURLs)
+		a.Equal("https://upload.com/session", uploadSession.UploadURL)
+		a.Equal("http://test.cloudreve.org/api/v3/callback/googledrive/finish/key", res.Callback)
+		a.EqualValues(50<<20, res.ChunkSize)
+	}
+
+	// 缺少上传地址
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "GET", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(200, nil, `{"files":[]}`))
+		clientMock.On("Request", "POST", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(200, nil, `{}`))
+		handler := newTestDriver(t, clientMock)
+		_, err := handler.Token(context.Background(), 10, &serializer.UploadSession{Key: "key"}, &fsctx.FileStream{SavePath: "/a.txt"})
+		a.Error(err)
+	}
+}
+
+func TestDriver_Put(t *testing.T) {
+	a := assert.New(t)
+	cache.Set("setting_chunk_retries", "1", 0)
+	cache.Set("setting_use_temp_chunk_buffer", "false", 0)
+
+	// 覆盖已有文件
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "GET", queryContains("name = 'a.txt' and 'root' in parents"), testMock.Anything, testMock.Anything).
+			Return(newResponse(200, nil, `{"files":[{"id":"f1","name":"a.txt","size":"4"}]}`))
+		clientMock.On("Request", "PATCH", "https://www.googleapis.com/upload/drive/v3/files/f1?supportsAllDrives=true&uploadType=resumable", testMock.Anything, testMock.Anything).
+			Return(newResponse(200, http.Header{"Location": {"https://upload.com/session"}}, `{}`))
+		clientMock.On("Request", "PUT", "https://upload.com/session", testMock.Anything, testMock.Anything).
+			Return(newResponse(200, nil, `{"id":"f1"}`))
+		handler := newTestDriver(t, clientMock)
+		err := handler.Put(context.Background(), &fsctx.FileStream{
+			File:     io.NopCloser(strings.NewReader("1234")),
+			SavePath: "/a.txt",
+			Size:     4,
+			Mode:     fsctx.Overwrite,
+		})
+		clientMock.AssertExpectations(t)
+		a.NoError(err)
+	}
+
+	// 上传失败，取消会话
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "GET", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(200, nil, `{"files":[]}`))
+		clientMock.On("Request", "POST", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(200, http.Header{"Location": {"https://upload.com/session"}}, `{}`))
+		clientMock.On("Request", "PUT", "https://upload.com/session", testMock.Anything, testMock.Anything).
+			Return(newResponse(400, nil, `{"error":{"code":400,"message":"bad request"}}`))
+		clientMock.On("Request", "DELETE", "https://upload.com/session", testMock.Anything, testMock.Anything).
+			Return(newResponse(499, nil, ``))
+		handler := newTestDriver(t, clientMock)
+		err := handler.Put(context.Background(), &fsctx.FileStream{
+			File:     io.NopCloser(strings.NewReader("1234")),
+			SavePath: "/a.txt",
+			Size:     4,
+		})
+		clientMock.AssertExpectations(t)
+		a.Error(err)
+	}
+}
+
+func TestDriver_Delete(t *testing.T) {
+	a := assert.New(t)
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "GET", queryContains("name = 'a.txt'"), testMock.Anything, testMock.Anything).
+		Return(newResponse(200, nil, `{"files":[{"id":"f1","name":"a.txt"}]}`))
+	clientMock.On("Request", "GET", queryContains("name = 'b.txt'"), testMock.Anything, testMock.Anything).
+		Return(newResponse(200, nil, `{"files":[]}`))
+	clientMock.On("Request", "GET", queryContains("name = 'c.txt'"), testMock.Anything, testMock.Anything).
+		Return(newResponse(200, nil, `{"files":[{"id":"f3","name":"c.txt"}]}`))
+	clientMock.On("Request", "DELETE", "https://www.googleapis.com/drive/v3/files/f1?supportsAllDrives=true", testMock.Anything, testMock.Anything).
+		Return(newResponse(204, nil, ``))
+	clientMock.On("Request", "DELETE", "https://www.googleapis.com/drive/v3/files/f3?supportsAllDrives=true", testMock.Anything, testMock.Anything).
+		Return(newResponse(403, nil, `{"error":{"code":403,"message":"forbidden"}}`))
+	handler := newTestDriver(t, clientMock)
+
+	failed, err := handler.Delete(context.Background(), []string{"/a.txt", "/b.txt", "/c.txt"})
+	clientMock.AssertExpectations(t)
+	a.Error(err)
+	a.Equal([]string{"/c.txt"}, failed)
+}
+
+func TestDriver_Thumb(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.WithValue(context.Background(), fsctx.ThumbSizeCtx, [2]uint{400, 300})
+
+	// 缺少尺寸
+	{
+		handler := newTestDriver(t, &requestmock.RequestMock{})
+		_, err := handler.Thumb(context.Background(), &model.File{SourceName: "/a.jpg"})
+		a.Error(err)
+	}
+
+	// 无缩略图
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "GET", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(200, nil, `{"files":[{"id":"f1","name":"a.jpg"}]}`))
+		handler := newTestDriver(t, clientMock)
+		_, err := handler.Thumb(ctx, &model.File{SourceName: "/a.jpg"})
+		a.ErrorIs(err, driver.ErrorThumbNotSupported)
+	}
+
+	// 成功
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "GET", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(200, nil, `{"files":[{"id":"f1","name":"a.jpg","thumbnailLink":"https://lh3.google.com/thumb=s220"}]}`))
+		handler := newTestDriver(t, clientMock)
+		res, err := handler.Thumb(ctx, &model.File{SourceName: "/a.jpg"})
+		a.NoError(err)
+		a.True(res.Redirect)
+		a.Equal("https://lh3.google.com/thumb=s400", res.URL)
+	}
+}
+
+func TestDriver_Get(t *testing.T) {
+	a := assert.New(t)
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "GET", queryContains("name = 'a.txt'"), testMock.Anything, testMock.Anything).
+		Return(newResponse(200, nil, `{"files":[{"id":"f1","name":"a.txt","size":"4"}]}`))
+	clientMock.On("Request", "GET", "https://www.googleapis.com/drive/v3/files/f1?alt=media&supportsAllDrives=true", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, nil, `1234`))
+	handler := newTestDriver(t, clientMock)
+
+	rs, err := handler.Get(context.Background(), "/a.txt")
+	clientMock.AssertExpectations(t)
+	a.NoError(err)
+	size, err := rs.Seek(0, io.SeekEnd)
+	a.NoError(err)
+	a.EqualValues(4, size)
+	_, err = rs.Seek(0, io.SeekStart)
+	a.NoError(err)
+	content := make([]byte, 1024)
+	n, _ := rs.Read(content)
+	a.Equal("1234", string(content[:n]))
+}
+
+func TestDriver_List(t *testing.T) {
+	a := assert.New(t)
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "GET", queryContains("name = 'dir'"), testMock.Anything, testMock.Anything).
+		Return(newResponse(200, nil, `{"files":[{"id":"d1","name":"dir","mimeType":"application/vnd.google-apps.folder"}]}`))
+	clientMock.On("Request", "GET", testMock.MatchedBy(func(u string) bool {
+		parsed, _ := url.Parse(u)
+		return parsed.Query().Get("q") == "'d1' in parents and trashed = false" && parsed.Query().Get("pageToken") == ""
+	}), testMock.Anything, testMock.Anything).
+		Return(newResponse(200, nil, `{"nextPageToken":"next","files":[{"id":"d2","name":"sub","mimeType":"application/vnd.google-apps.folder"}]}`))
+	clientMock.On("Request", "GET", testMock.MatchedBy(func(u string) bool {
+		parsed, _ := url.Parse(u)
+		return parsed.Query().Get("pageToken") == "next"
+	}), testMock.Anything, testMock.Anything).
+		Return(newResponse(200, nil, `{"files":[{"id":"f1","name":"a.txt","size":"4"}]}`))
+	clientMock.On("Request", "GET", queryContains("'d2' in parents"), testMock.Anything, testMock.Anything).
+		Return(newResponse(200, nil, `{"files":[{"id":"f2","name":"b.txt","size":"2"}]}`))
+	handler := newTestDriver(t, clientMock)
+
+	res, err := handler.List(context.Background(), "/dir", true)
+	clientMock.AssertExpectations(t)
+	a.NoError(err)
+	a.Len(res, 3)
+	a.Equal("sub", res[0].RelativePath)
+	a.True(res[0].IsDir)
+	a.Equal("sub/b.txt", res[1].RelativePath)
+	a.Equal("dir/sub/b.txt", res[1].Source)
+	a.EqualValues(2, res[1].Size)
+	a.Equal("a.txt", res[2].RelativePath)
+}
+
+func TestDriver_CancelToken(t *testing.T) {
+	a := assert.New(t)
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "DELETE", "https://upload.com/session", testMock.Anything, testMock.Anything).
+		Return(newResponse(499, nil, ``))
+	handler := newTestDriver(t, clientMock)
+
+	a.NoError(handler.CancelToken(context.Background(), &serializer.UploadSession{UploadURL: "https://upload.com/session"}))
+	clientMock.AssertExpectations(t)
+
+	// 未取消
+	clientMock = &requestmock.RequestMock{}
+	clientMock.On("Request", "DELETE", "https://upload.com/session", testMock.Anything, testMock.Anything).
+		Return(newResponse(404, nil, ``))
+	handler.Client.Request = clientMock
+	a.Error(handler.CancelToken(context.Background(), &serializer.UploadSession{UploadURL: "https://upload.com/session"}))
+}
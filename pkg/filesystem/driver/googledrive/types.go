@@ -1,6 +1,9 @@
 package googledrive
 
-import "encoding/gob"
+import (
+	"encoding/gob"
+	"time"
+)
 
 // RespError 接口返回错误
 type RespError struct {
@@ -9,7 +12,7 @@ type RespError struct {
 
 // APIError 接口返回的错误内容
 type APIError struct {
-	Code    string `json:"code"`
+	Code    int    `json:"code"`
 	Message string `json:"message"`
 }
 
@@ -18,6 +21,31 @@ func (err RespError) Error() string {
 	return err.APIError.Message
 }
 
+// FileInfo 文件元信息
+type FileInfo struct {
+	ID                 string    `json:"id"`
+	Name               string    `json:"name"`
+	MimeType           string    `json:"mimeType"`
+	Size               uint64    `json:"size,string"`
+	ModifiedTime       time.Time `json:"modifiedTime"`
+	ThumbnailLink      string    `json:"thumbnailLink"`
+	ImageMediaMetadata *struct {
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	} `json:"imageMediaMetadata"`
+}
+
+// IsFolder 是否为目录
+func (info *FileInfo) IsFolder() bool {
+	return info.MimeType == folderMimeType
+}
+
+// ListResponse 列取文件的响应
+type ListResponse struct {
+	NextPageToken string     `json:"nextPageToken"`
+	Files         []FileInfo `json:"files"`
+}
+
 // Credential 获取token时返回的凭证
 type Credential struct {
 	ExpiresIn    int64  `json:"expires_in"`
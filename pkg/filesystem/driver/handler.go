@@ -48,4 +48,21 @@ type Handler interface {
 	// 返回的对象路径以path作为起始根目录.
 	// recursive - 是否递归列出
 	List(ctx context.Context, path string, recursive bool) ([]response.Object, error)
+
+	// Capabilities 返回存储端支持的特性，供上层选择合适的处理方式
+	Capabilities() Capabilities
+}
+
+// Capabilities 存储策略适配器支持的特性
+type Capabilities struct {
+	// Get 返回的文件流支持任意位置的 Seek
+	RangeGet bool
+	// 支持在存储端内部直接复制文件
+	ServerSideCopy bool
+	// 客户端可使用 Token 返回的凭证直接上传至存储端，无需经由本机中转
+	PresignedUpload bool
+	// 支持分片上传
+	MultipartUpload bool
+	// Thumb 可能返回缩略图，为 false 时直接按不支持缩略图处理
+	Thumbnail bool
 }
@@ -0,0 +1,416 @@
+package hdfs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// uploadTempSuffix 上传中文件的后缀，上传完成后重命名为目标文件
+const uploadTempSuffix = ".cloudreve_upload"
+
+var (
+	ErrFileExisted     = errors.New("file with the same name existed or unavailable")
+	ErrChunkOffset     = errors.New("size of unfinished uploaded chunks is not as expected")
+	ErrTruncatePending = errors.New("hdfs is recovering the last block after truncate, please retry later")
+)
+
+// Driver HDFS 存储策略适配器，通过 WebHDFS REST API 访问。Policy 中 Server 为 NameNode
+// 的 HTTP 地址，BucketName 为存储根目录，AccessKey 为用户名，
+// SecretKey 为委托令牌 (Delegation Token)，设置令牌时使用令牌认证
+type Driver struct {
+	Policy *model.Policy
+	client request.Client
+}
+
+// remoteException WebHDFS 错误响应
+type remoteException struct {
+	RemoteException struct {
+		Exception string `json:"exception"`
+		Message   string `json:"message"`
+	} `json:"RemoteException"`
+}
+
+// fileStatus WebHDFS 文件信息
+type fileStatus struct {
+	PathSuffix       string `json:"pathSuffix"`
+	Type             string `json:"type"`
+	Length           uint64 `json:"length"`
+	ModificationTime int64  `json:"modificationTime"`
+}
+
+// NewDriver 创建 HDFS 适配器
+func NewDriver(policy *model.Policy) (*Driver, error) {
+	if u, err := url.Parse(policy.Server); err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhdfs server address: %q", policy.Server)
+	}
+
+	return &Driver{
+		Policy: policy,
+		client: request.NewClient(request.WithProxy(policy.OptionsSerialized.Proxy)),
+	}, nil
+}
+
+// hdfsPath 返回文件在 HDFS 中的绝对路径
+func (handler *Driver) hdfsPath(p string) string {
+	return path.Join("/", handler.Policy.BucketName, filepath.ToSlash(p))
+}
+
+// url 返回 WebHDFS 操作地址
+func (handler *Driver) url(p, op string, params url.Values) string {
+	query := url.Values{}
+	for k, v := range params {
+		query[k] = v
+	}
+
+	query.Set("op", op)
+	if handler.Policy.SecretKey != "" {
+		query.Set("delegation", handler.Policy.SecretKey)
+	} else if handler.Policy.AccessKey != "" {
+		query.Set("user.name", handler.Policy.AccessKey)
+	}
+
+	target := url.URL{Path: "/webhdfs/v1" + handler.hdfsPath(p)}
+	return strings.TrimSuffix(handler.Policy.Server, "/") + target.EscapedPath() + "?" + query.Encode()
+}
+
+// decodeError 将 WebHDFS 错误响应转换为 error
+func decodeError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var res remoteException
+	if err := json.Unmarshal(body, &res); err != nil || res.RemoteException.Exception == "" {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	switch res.RemoteException.Exception {
+	case "FileNotFoundException":
+		return fmt.Errorf("%w: %s", os.ErrNotExist, res.RemoteException.Message)
+	case "FileAlreadyExistsException":
+		return ErrFileExisted
+	}
+
+	return fmt.Errorf("%s: %s", res.RemoteException.Exception, res.RemoteException.Message)
+}
+
+// call 向 NameNode 发起操作，响应正文解析至 result
+func (handler *Driver) call(ctx context.Context, method, p, op string, params url.Values, result interface{}) error {
+	resp := handler.client.Request(method, handler.url(p, op, params), nil, request.WithContext(ctx))
+	if resp.Err != nil {
+		return resp.Err
+	}
+	defer resp.Response.Body.Close()
+
+	if resp.Response.StatusCode != http.StatusOK {
+		return decodeError(resp.Response)
+	}
+
+	return json.NewDecoder(resp.Response.Body).Decode(result)
+}
+
+// boolean 发起返回 {"boolean": ...} 的操作
+func (handler *Driver) boolean(ctx context.Context, method, p, op string, params url.Values) (bool, error) {
+	var res struct {
+		Boolean bool `json:"boolean"`
+	}
+
+	err := handler.call(ctx, method, p, op, params, &res)
+	return res.Boolean, err
+}
+
+// stat 获取文件信息，文件不存在时返回 os.ErrNotExist
+func (handler *Driver) stat(ctx context.Context, p string) (*fileStatus, error) {
+	var res struct {
+		FileStatus fileStatus `json:"FileStatus"`
+	}
+
+	if err := handler.call(ctx, "GET", p, "GETFILESTATUS", nil, &res); err != nil {
+		return nil, err
+	}
+
+	return &res.FileStatus, nil
+}
+
+// write 写入文件内容。NameNode 返回 DataNode 地址后将内容发送至 DataNode，
+// 支持 noredirect 的 NameNode 在正文中返回地址，否则通过 307 重定向返回
+func (handler *Driver) write(ctx context.Context, method, p, op string, params url.Values, file io.Reader, size uint64, expected int) error {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("noredirect", "true")
+
+	resp := handler.client.Request(method, handler.url(p, op, params), nil,
+		request.WithContext(ctx),
+		request.WithoutRedirect(),
+	)
+	if resp.Err != nil {
+		return resp.Err
+	}
+
+	var location string
+	switch resp.Response.StatusCode {
+	case http.StatusOK:
+		var res struct {
+			Location string `json:"Location"`
+		}
+		err := json.NewDecoder(resp.Response.Body).Decode(&res)
+		resp.Response.Body.Close()
+		if err != nil {
+			return err
+		}
+		location = res.Location
+	case http.StatusTemporaryRedirect:
+		resp.Response.Body.Close()
+		location = resp.Response.Header.Get("Location")
+	default:
+		defer resp.Response.Body.Close()
+		return decodeError(resp.Response)
+	}
+
+	if location == "" {
+		return errors.New("datanode location is empty")
+	}
+
+	resp = handler.client.Request(method, location, io.LimitReader(file, int64(size)),
+		request.WithContext(ctx),
+		request.WithHeader(http.Header{"Content-Type": {"application/octet-stream"}}),
+		request.WithContentLength(int64(size)),
+		request.WithTimeout(time.Duration(0)),
+	)
+	if resp.Err != nil {
+		return resp.Err
+	}
+	defer resp.Response.Body.Close()
+
+	if resp.Response.StatusCode != expected {
+		return decodeError(resp.Response)
+	}
+
+	return nil
+}
+
+// create 创建文件并写入内容，上级目录不存在时自动创建
+func (handler *Driver) create(ctx context.Context, p string, file io.Reader, size uint64, overwrite bool) error {
+	return handler.write(ctx, "PUT", p, "CREATE", url.Values{
+		"overwrite": {strconv.FormatBool(overwrite)},
+	}, file, size, http.StatusCreated)
+}
+
+// List 列取远程端 path 路径下文件、目录
+func (handler *Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	base = strings.Trim(base, "/")
+	var res []response.Object
+
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		var list struct {
+			FileStatuses struct {
+				FileStatus []fileStatus `json:"FileStatus"`
+			} `json:"FileStatuses"`
+		}
+
+		if err := handler.call(ctx, "GET", path.Join(base, rel), "LISTSTATUS", nil, &list); err != nil {
+			return err
+		}
+
+		for _, status := range list.FileStatuses.FileStatus {
+			if strings.HasSuffix(status.PathSuffix, uploadTempSuffix) {
+				continue
+			}
+
+			relPath := path.Join(rel, status.PathSuffix)
+			object := response.Object{
+				Name:         status.PathSuffix,
+				RelativePath: relPath,
+				Source:       path.Join(base, relPath),
+				Size:         status.Length,
+				IsDir:        status.Type == "DIRECTORY",
+				LastModify:   time.UnixMilli(status.ModificationTime),
+			}
+
+			res = append(res, object)
+			if recursive && object.IsDir {
+				if err := walk(relPath); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	return res, walk("")
+}
+
+// Get 获取文件内容，读取时从当前位置发送 OPEN 请求
+func (handler *Driver) Get(ctx context.Context, p string) (response.RSCloser, error) {
+	status, err := handler.stat(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &reader{
+		ctx:     ctx,
+		handler: handler,
+		path:    p,
+		size:    int64(status.Length),
+	}, nil
+}
+
+// Put 将文件流保存到指定目录。分片上传时分片写入临时文件，
+// 最后一个分片上传后由 CompleteUpload 重命名为目标文件
+func (handler *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
+	defer file.Close()
+	fileInfo := file.Info()
+	overwrite := fileInfo.Mode&fsctx.Overwrite == fsctx.Overwrite
+
+	if fileInfo.Mode&fsctx.Append != fsctx.Append {
+		return handler.create(ctx, fileInfo.SavePath, file, fileInfo.Size, overwrite)
+	}
+
+	// 如果非 Overwrite，则检查是否有重名冲突
+	if !overwrite {
+		if _, err := handler.stat(ctx, fileInfo.SavePath); err == nil {
+			util.Log().Warning("File with the same name existed or unavailable: %s", fileInfo.SavePath)
+			return ErrFileExisted
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return handler.appendChunk(ctx, fileInfo.SavePath+uploadTempSuffix, file, fileInfo.Size, fileInfo.AppendStart)
+}
+
+// appendChunk 将分片写入临时文件的 start 位置，覆盖已上传的分片前先截断临时文件
+func (handler *Driver) appendChunk(ctx context.Context, temp string, file io.Reader, size, start uint64) error {
+	if start == 0 {
+		return handler.create(ctx, temp, file, size, true)
+	}
+
+	status, err := handler.stat(ctx, temp)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrChunkOffset
+		}
+		return err
+	}
+
+	if status.Length < start {
+		return ErrChunkOffset
+	}
+
+	if status.Length > start {
+		util.Log().Info("Trying to overwrite chunk of %q at [%d].", temp, start)
+		done, err := handler.boolean(ctx, "POST", temp, "TRUNCATE", url.Values{
+			"newlength": {strconv.FormatUint(start, 10)},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to overwrite chunk: %w", err)
+		}
+
+		if !done {
+			return ErrTruncatePending
+		}
+	}
+
+	return handler.write(ctx, "POST", temp, "APPEND", nil, file, size, http.StatusOK)
+}
+
+// CompleteUpload 将分片上传的临时文件重命名为目标文件
+func (handler *Driver) CompleteUpload(ctx context.Context, dst string) error {
+	// RENAME 不会覆盖已有文件，需先删除目标文件
+	if _, err := handler.boolean(ctx, "DELETE", dst, "DELETE", nil); err != nil {
+		return err
+	}
+
+	renamed, err := handler.boolean(ctx, "PUT", dst+uploadTempSuffix, "RENAME", url.Values{
+		"destination": {handler.hdfsPath(dst)},
+	})
+	if err != nil {
+		return err
+	}
+
+	if !renamed {
+		return fmt.Errorf("failed to rename uploaded file to %q", dst)
+	}
+
+	return nil
+}
+
+// Delete 删除一个或多个文件，
+// 返回未删除的文件，及遇到的最后一个错误
+func (handler *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	failed := make([]string, 0, len(files))
+	var retErr error
+
+	for _, file := range files {
+		// 文件不存在时返回 false，视为删除成功
+		if _, err := handler.boolean(ctx, "DELETE", file, "DELETE", nil); err != nil {
+			util.Log().Warning("Failed to delete file %q: %s", file, err)
+			failed = append(failed, file)
+			retErr = err
+			continue
+		}
+
+		// 同时清理未完成上传的临时文件
+		handler.boolean(ctx, "DELETE", file+uploadTempSuffix, "DELETE", nil)
+	}
+
+	return failed, retErr
+}
+
+// Thumb 获取文件缩略图
+func (handler *Driver) Thumb(ctx context.Context, file *model.File) (*response.ContentResponse, error) {
+	return nil, driver.ErrorThumbNotSupported
+}
+
+// Source 获取外链URL，文件内容由 Cloudreve 中转
+func (handler *Driver) Source(ctx context.Context, p string, ttl int64, isDownload bool, speed int) (string, error) {
+	return local.Driver{Policy: handler.Policy}.Source(ctx, p, ttl, isDownload, speed)
+}
+
+// Token 获取上传凭证，分片由 Cloudreve 中转上传
+func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
+	if _, err := handler.stat(ctx, uploadSession.SavePath); err == nil {
+		return nil, errors.New("placeholder file already exist")
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	return &serializer.UploadCredential{
+		SessionID: uploadSession.Key,
+		ChunkSize: handler.Policy.OptionsSerialized.ChunkSize,
+	}, nil
+}
+
+// CancelToken 取消上传凭证，删除未完成上传的临时文件
+func (handler *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	_, err := handler.boolean(ctx, "DELETE", uploadSession.SavePath+uploadTempSuffix, "DELETE", nil)
+	return err
+}
+
+// Capabilities 返回存储端支持的特性
+func (handler *Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		RangeGet:        true,
+		MultipartUpload: true,
+	}
+}
@@ -0,0 +1,362 @@
+package hdfs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeHDFS 模拟 WebHDFS 的 NameNode 和 DataNode
+type fakeHDFS struct {
+	mu    sync.Mutex
+	files map[string]string
+	// redirect 为 true 时忽略 noredirect 参数，通过 307 返回 DataNode 地址
+	redirect bool
+	// truncatePending 为 true 时 TRUNCATE 返回 false
+	truncatePending bool
+	// queries 记录收到的 NameNode 请求参数
+	queries []string
+	server  *httptest.Server
+}
+
+func newFakeHDFS(t *testing.T) *fakeHDFS {
+	fake := &fakeHDFS{files: map[string]string{}}
+	fake.server = httptest.NewServer(fake)
+	t.Cleanup(fake.server.Close)
+	return fake
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeException(w http.ResponseWriter, status int, exception, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"RemoteException": map[string]string{"exception": exception, "message": message},
+	})
+}
+
+func (f *fakeHDFS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p := strings.TrimPrefix(r.URL.Path, "/webhdfs/v1")
+	query := r.URL.Query()
+	op := query.Get("op")
+
+	// DataNode 请求
+	if query.Get("datanode") == "true" {
+		content, exist := f.files[p]
+		switch op {
+		case "CREATE":
+			if exist && query.Get("overwrite") != "true" {
+				writeException(w, http.StatusForbidden, "FileAlreadyExistsException", p+" already exists")
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			f.files[p] = string(body)
+			w.WriteHeader(http.StatusCreated)
+		case "APPEND":
+			body, _ := io.ReadAll(r.Body)
+			f.files[p] = content + string(body)
+			w.WriteHeader(http.StatusOK)
+		case "OPEN":
+			offset, _ := strconv.Atoi(query.Get("offset"))
+			io.WriteString(w, content[offset:])
+		}
+		return
+	}
+
+	f.queries = append(f.queries, r.URL.RawQuery)
+	switch op {
+	case "CREATE", "APPEND", "OPEN":
+		if op != "CREATE" {
+			if _, ok := f.files[p]; !ok {
+				writeException(w, http.StatusNotFound, "FileNotFoundException", "File does not exist: "+p)
+				return
+			}
+		}
+
+		query.Set("datanode", "true")
+		location := f.server.URL + r.URL.Path + "?" + query.Encode()
+		if op == "OPEN" || f.redirect || query.Get("noredirect") != "true" {
+			http.Redirect(w, r, location, http.StatusTemporaryRedirect)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"Location": location})
+	case "GETFILESTATUS":
+		content, ok := f.files[p]
+		if !ok {
+			writeException(w, http.StatusNotFound, "FileNotFoundException", "File does not exist: "+p)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"FileStatus": map[string]interface{}{"type": "FILE", "length": len(content)},
+		})
+	case "LISTSTATUS":
+		prefix := strings.TrimSuffix(p, "/") + "/"
+		children := map[string]map[string]interface{}{}
+		for name, content := range f.files {
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			rel := strings.TrimPrefix(name, prefix)
+			if i := strings.Index(rel, "/"); i >= 0 {
+				children[rel[:i]] = map[string]interface{}{"pathSuffix": rel[:i], "type": "DIRECTORY"}
+				continue
+			}
+			children[rel] = map[string]interface{}{
+				"pathSuffix": rel, "type": "FILE", "length": len(content), "modificationTime": 1600000000000,
+			}
+		}
+
+		list := make([]map[string]interface{}, 0, len(children))
+		for _, child := range children {
+			list = append(list, child)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			return list[i]["pathSuffix"].(string) < list[j]["pathSuffix"].(string)
+		})
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"FileStatuses": map[string]interface{}{"FileStatus": list},
+		})
+	case "TRUNCATE":
+		length, _ := strconv.Atoi(query.Get("newlength"))
+		f.files[p] = f.files[p][:length]
+		writeJSON(w, http.StatusOK, map[string]bool{"boolean": !f.truncatePending})
+	case "RENAME":
+		dst := query.Get("destination")
+		_, srcExist := f.files[p]
+		_, dstExist := f.files[dst]
+		if !srcExist || dstExist {
+			writeJSON(w, http.StatusOK, map[string]bool{"boolean": false})
+			return
+		}
+		f.files[dst] = f.files[p]
+		delete(f.files, p)
+		writeJSON(w, http.StatusOK, map[string]bool{"boolean": true})
+	case "DELETE":
+		if strings.HasSuffix(p, "forbidden.txt") {
+			writeException(w, http.StatusForbidden, "AccessControlException", "Permission denied")
+			return
+		}
+		_, ok := f.files[p]
+		delete(f.files, p)
+		writeJSON(w, http.StatusOK, map[string]bool{"boolean": ok})
+	default:
+		writeException(w, http.StatusBadRequest, "IllegalArgumentException", fmt.Sprintf("Invalid value for webhdfs parameter \"op\": %s", op))
+	}
+}
+
+func newTestDriver(t *testing.T) (*Driver, *fakeHDFS) {
+	fake := newFakeHDFS(t)
+	handler, err := NewDriver(&model.Policy{
+		Type:       "hdfs",
+		Server:     fake.server.URL,
+		BucketName: "/cloudreve",
+		AccessKey:  "hadoop",
+	})
+	assert.NoError(t, err)
+	return handler, fake
+}
+
+func newStream(content, savePath string, mode fsctx.WriteMode) *fsctx.FileStream {
+	return &fsctx.FileStream{
+		File:     io.NopCloser(strings.NewReader(content)),
+		Size:     uint64(len(content)),
+		SavePath: savePath,
+		Mode:     mode,
+	}
+}
+
+func TestNewDriver(t *testing.T) {
+	a := assert.New(t)
+	_, err := NewDriver(&model.Policy{})
+	a.Error(err)
+
+	handler, err := NewDriver(&model.Policy{Server: "http://namenode:9870/", BucketName: "root", AccessKey: "hadoop"})
+	a.NoError(err)
+	a.Equal("http://namenode:9870/webhdfs/v1/root/dir/a%20b.txt?op=GETFILESTATUS&user.name=hadoop", handler.url("dir/a b.txt", "GETFILESTATUS", nil))
+
+	// 使用委托令牌认证
+	handler.Policy.SecretKey = "token"
+	a.Equal("http://namenode:9870/webhdfs/v1/root/a.txt?delegation=token&offset=1&op=OPEN", handler.url("/a.txt", "OPEN", map[string][]string{"offset": {"1"}}))
+}
+
+func TestDriver_Put(t *testing.T) {
+	a := assert.New(t)
+	handler, fake := newTestDriver(t)
+
+	// 成功
+	a.NoError(handler.Put(context.Background(), newStream("123", "dir/a.txt", 0)))
+	a.Equal("123", fake.files["/cloudreve/dir/a.txt"])
+	a.Contains(fake.queries[0], "noredirect=true")
+	a.Contains(fake.queries[0], "overwrite=false")
+
+	// 文件已存在
+	a.ErrorIs(handler.Put(context.Background(), newStream("456", "dir/a.txt", 0)), ErrFileExisted)
+
+	// 覆盖，NameNode 不支持 noredirect
+	fake.redirect = true
+	a.NoError(handler.Put(context.Background(), newStream("456", "dir/a.txt", fsctx.Overwrite)))
+	a.Equal("456", fake.files["/cloudreve/dir/a.txt"])
+
+	// 空文件
+	a.NoError(handler.Put(context.Background(), newStream("", "empty.txt", 0)))
+	a.Contains(fake.files, "/cloudreve/empty.txt")
+}
+
+func TestDriver_ChunkUpload(t *testing.T) {
+	a := assert.New(t)
+	handler, fake := newTestDriver(t)
+	temp := "/cloudreve/a.txt" + uploadTempSuffix
+
+	for i, chunk := range []string{"012", "345", "67"} {
+		stream := newStream(chunk, "a.txt", fsctx.Append)
+		stream.AppendStart = uint64(i * 3)
+		a.NoError(handler.Put(context.Background(), stream))
+	}
+	a.Equal("01234567", fake.files[temp])
+
+	// 重传分片
+	stream := newStream("abc", "a.txt", fsctx.Append)
+	stream.AppendStart = 3
+	a.NoError(handler.Put(context.Background(), stream))
+	a.Equal("012abc", fake.files[temp])
+
+	// 截断未完成
+	fake.truncatePending = true
+	stream = newStream("345", "a.txt", fsctx.Append)
+	stream.AppendStart = 3
+	a.ErrorIs(handler.Put(context.Background(), stream), ErrTruncatePending)
+	fake.truncatePending = false
+
+	// 分片不连续
+	stream = newStream("xyz", "a.txt", fsctx.Append)
+	stream.AppendStart = 10
+	a.ErrorIs(handler.Put(context.Background(), stream), ErrChunkOffset)
+
+	stream = newStream("345", "a.txt", fsctx.Append)
+	stream.AppendStart = 3
+	a.NoError(handler.Put(context.Background(), stream))
+
+	// 完成上传，覆盖已有文件
+	fake.files["/cloudreve/a.txt"] = "old"
+	a.NoError(handler.CompleteUpload(context.Background(), "a.txt"))
+	a.Equal("012345", fake.files["/cloudreve/a.txt"])
+	a.NotContains(fake.files, temp)
+
+	// 临时文件不存在
+	a.Error(handler.CompleteUpload(context.Background(), "b.txt"))
+
+	// 目标文件已存在
+	fake.files["/cloudreve/b.txt"] = "1"
+	stream = newStream("1", "b.txt", fsctx.Append)
+	a.ErrorIs(handler.Put(context.Background(), stream), ErrFileExisted)
+}
+
+func TestDriver_Get(t *testing.T) {
+	a := assert.New(t)
+	handler, fake := newTestDriver(t)
+	fake.files["/cloudreve/a.txt"] = "0123456789"
+
+	rs, err := handler.Get(context.Background(), "a.txt")
+	a.NoError(err)
+	defer rs.Close()
+
+	size, err := rs.Seek(0, io.SeekEnd)
+	a.NoError(err)
+	a.EqualValues(10, size)
+
+	_, err = rs.Seek(4, io.SeekStart)
+	a.NoError(err)
+	buf := make([]byte, 3)
+	_, err = io.ReadFull(rs, buf)
+	a.NoError(err)
+	a.Equal("456", string(buf))
+
+	_, err = rs.Seek(-2, io.SeekEnd)
+	a.NoError(err)
+	content, err := io.ReadAll(rs)
+	a.NoError(err)
+	a.Equal("89", string(content))
+
+	// 不存在的文件
+	_, err = handler.Get(context.Background(), "b.txt")
+	a.True(errors.Is(err, os.ErrNotExist))
+}
+
+func TestDriver_Delete(t *testing.T) {
+	a := assert.New(t)
+	handler, fake := newTestDriver(t)
+	fake.files["/cloudreve/a.txt"] = "1"
+	fake.files["/cloudreve/b.txt"+uploadTempSuffix] = "1"
+	fake.files["/cloudreve/forbidden.txt"] = "1"
+
+	failed, err := handler.Delete(context.Background(), []string{"a.txt", "b.txt", "forbidden.txt"})
+	a.Error(err)
+	a.Contains(err.Error(), "AccessControlException")
+	a.Equal([]string{"forbidden.txt"}, failed)
+	a.Equal(map[string]string{"/cloudreve/forbidden.txt": "1"}, fake.files)
+}
+
+func TestDriver_List(t *testing.T) {
+	a := assert.New(t)
+	handler, fake := newTestDriver(t)
+	fake.files["/cloudreve/dir/a.txt"] = "123"
+	fake.files["/cloudreve/dir/b.txt"+uploadTempSuffix] = "1"
+	fake.files["/cloudreve/dir/sub/c.txt"] = "1"
+
+	res, err := handler.List(context.Background(), "/dir", false)
+	a.NoError(err)
+	a.Len(res, 2)
+
+	res, err = handler.List(context.Background(), "/dir", true)
+	a.NoError(err)
+	a.Len(res, 3)
+	a.Equal("a.txt", res[0].Name)
+	a.EqualValues(3, res[0].Size)
+	a.Equal(2020, res[0].LastModify.Year())
+	a.True(res[1].IsDir)
+	a.Equal("sub/c.txt", res[2].RelativePath)
+	a.Equal("dir/sub/c.txt", res[2].Source)
+}
+
+func TestDriver_Token(t *testing.T) {
+	a := assert.New(t)
+	handler, fake := newTestDriver(t)
+	handler.Policy.OptionsSerialized.ChunkSize = 10
+	fake.files["/cloudreve/a.txt"] = "1"
+
+	// 文件已存在
+	_, err := handler.Token(context.Background(), 10, &serializer.UploadSession{SavePath: "a.txt"}, &fsctx.FileStream{})
+	a.Error(err)
+
+	// 成功
+	res, err := handler.Token(context.Background(), 10, &serializer.UploadSession{Key: "key", SavePath: "b.txt"}, &fsctx.FileStream{})
+	a.NoError(err)
+	a.Equal("key", res.SessionID)
+	a.EqualValues(10, res.ChunkSize)
+
+	// 取消上传
+	fake.files["/cloudreve/b.txt"+uploadTempSuffix] = "1"
+	a.NoError(handler.CancelToken(context.Background(), &serializer.UploadSession{SavePath: "b.txt"}))
+	a.NotContains(fake.files, "/cloudreve/b.txt"+uploadTempSuffix)
+}
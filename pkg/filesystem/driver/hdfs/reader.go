@@ -0,0 +1,94 @@
+package hdfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+)
+
+// reader 可定位的远程文件，读取时从当前位置发送 OPEN 请求
+type reader struct {
+	ctx     context.Context
+	handler *Driver
+	path    string
+	size    int64
+	offset  int64
+	body    io.ReadCloser
+}
+
+// open 从当前位置开始请求文件内容，NameNode 将请求重定向至 DataNode
+func (r *reader) open() error {
+	target := r.handler.url(r.path, "OPEN", url.Values{"offset": {strconv.FormatInt(r.offset, 10)}})
+	resp := r.handler.client.Request("GET", target, nil,
+		request.WithContext(r.ctx),
+		request.WithTimeout(time.Duration(0)),
+	)
+	if resp.Err != nil {
+		return resp.Err
+	}
+
+	if resp.Response.StatusCode != http.StatusOK {
+		defer resp.Response.Body.Close()
+		return decodeError(resp.Response)
+	}
+
+	r.body = resp.Response.Body
+	return nil
+}
+
+// Read 读取文件内容
+func (r *reader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+// Seek 设置读取位置，位置变化时关闭当前请求
+func (r *reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return r.offset, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return r.offset, errors.New("negative position")
+	}
+
+	if offset != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+
+	r.offset = offset
+	return offset, nil
+}
+
+// Close 关闭当前请求
+func (r *reader) Close() error {
+	if r.body != nil {
+		return r.body.Close()
+	}
+
+	return nil
+}
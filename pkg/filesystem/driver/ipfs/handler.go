@@ -0,0 +1,390 @@
+package ipfs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// defaultGateway 未设置网关地址时使用的公共网关
+const defaultGateway = "https://ipfs.io"
+
+var ErrChunkOffset = driver.ErrChunkOffset
+
+// Driver IPFS 存储策略适配器（实验性），Policy 中 Server 为 IPFS 节点的 HTTP API 地址，
+// BaseURL 为下载使用的网关地址，AccessKey/SecretKey 为 API 前置代理的 Basic 认证凭证（可选）。
+// 文件上传后被固定 (pin) 在节点上，File.SourceName 记录其 CID
+type Driver struct {
+	Policy *model.Policy
+	client request.Client
+}
+
+// addResult /api/v0/add 响应
+type addResult struct {
+	Name string `json:"Name"`
+	Hash string `json:"Hash"`
+	Size string `json:"Size"`
+}
+
+// statResult /api/v0/files/stat 响应
+type statResult struct {
+	Hash string `json:"Hash"`
+	Size uint64 `json:"Size"`
+	Type string `json:"Type"`
+}
+
+// pinList /api/v0/pin/ls 响应
+type pinList struct {
+	Keys map[string]struct {
+		Type string `json:"Type"`
+	} `json:"Keys"`
+}
+
+// apiError API 错误响应
+type apiError struct {
+	Message string `json:"Message"`
+	Code    int    `json:"Code"`
+	Type    string `json:"Type"`
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+// NewDriver 创建 IPFS 适配器
+func NewDriver(policy *model.Policy) (*Driver, error) {
+	if _, err := url.Parse(policy.Server); err != nil || policy.Server == "" {
+		return nil, fmt.Errorf("invalid ipfs api address: %q", policy.Server)
+	}
+
+	return &Driver{
+		Policy: policy,
+		client: request.NewClient(request.WithProxy(policy.OptionsSerialized.Proxy)),
+	}, nil
+}
+
+// isCID 返回存储路径是否可能为 CID。分片上传完成前文件的存储路径为占位路径，
+// 缩略图等附属文件也不会以 CID 存储
+func isCID(p string) bool {
+	if p == "" {
+		return false
+	}
+
+	for _, c := range p {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// api 调用 IPFS HTTP API，所有接口均使用 POST
+func (handler *Driver) api(ctx context.Context, endpoint string, query url.Values, body io.Reader, header http.Header, opts ...request.Option) *request.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+
+	if handler.Policy.AccessKey != "" || handler.Policy.SecretKey != "" {
+		req := http.Request{Header: http.Header{}}
+		req.SetBasicAuth(handler.Policy.AccessKey, handler.Policy.SecretKey)
+		header.Set("Authorization", req.Header.Get("Authorization"))
+	}
+
+	target := strings.TrimSuffix(handler.Policy.Server, "/") + "/api/v0/" + endpoint
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	opts = append([]request.Option{request.WithContext(ctx), request.WithHeader(header)}, opts...)
+	if body == nil {
+		opts = append(opts, request.WithContentLength(0))
+	}
+
+	return handler.client.Request("POST", target, body, opts...)
+}
+
+// decode 检查响应状态码并解析 JSON 响应
+func decode(resp *request.Response, v interface{}) error {
+	if resp.Err != nil {
+		return resp.Err
+	}
+	defer resp.Response.Body.Close()
+
+	if resp.Response.StatusCode != http.StatusOK {
+		var apiErr apiError
+		if err := json.NewDecoder(resp.Response.Body).Decode(&apiErr); err != nil || apiErr.Message == "" {
+			return fmt.Errorf("unexpected status code %d", resp.Response.StatusCode)
+		}
+
+		return &apiErr
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Response.Body).Decode(v)
+}
+
+// add 将文件内容添加至 IPFS 节点并固定，返回 CID
+func (handler *Driver) add(ctx context.Context, name string, file io.Reader) (string, error) {
+	reader, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		part, err := form.CreateFormFile("file", name)
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	var res addResult
+	err := decode(handler.api(ctx, "add", url.Values{
+		"pin":         {"true"},
+		"cid-version": {"1"},
+		"quieter":     {"true"},
+	}, reader, http.Header{"Content-Type": {form.FormDataContentType()}},
+		request.WithTimeout(time.Duration(0)),
+	), &res)
+
+	// 确保写入协程退出
+	reader.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		return "", fmt.Errorf("failed to add file to ipfs: %w", err)
+	}
+
+	if res.Hash == "" {
+		return "", errors.New("cid not found in response")
+	}
+
+	return res.Hash, nil
+}
+
+// stat 获取 CID 对应的文件信息
+func (handler *Driver) stat(ctx context.Context, cid string) (*statResult, error) {
+	var res statResult
+	if err := decode(handler.api(ctx, "files/stat", url.Values{"arg": {"/ipfs/" + cid}}, nil, nil), &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// List 列出节点上递归固定的文件，IPFS 中没有目录结构，仅支持列取根目录
+func (handler *Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	if strings.Trim(base, "/") != "" {
+		return []response.Object{}, nil
+	}
+
+	var pins pinList
+	if err := decode(handler.api(ctx, "pin/ls", url.Values{"type": {"recursive"}}, nil, nil), &pins); err != nil {
+		return nil, err
+	}
+
+	res := make([]response.Object, 0, len(pins.Keys))
+	for cid := range pins.Keys {
+		info, err := handler.stat(ctx, cid)
+		if err != nil {
+			util.Log().Warning("Failed to stat pinned object %q: %s", cid, err)
+			continue
+		}
+
+		if info.Type != "file" {
+			continue
+		}
+
+		res = append(res, response.Object{
+			Name:         cid,
+			Source:       cid,
+			RelativePath: cid,
+			Size:         info.Size,
+			IsDir:        false,
+			LastModify:   time.Now(),
+		})
+	}
+
+	return res, nil
+}
+
+// Get 获取文件内容
+func (handler *Driver) Get(ctx context.Context, cid string) (response.RSCloser, error) {
+	resp := handler.api(ctx, "cat", url.Values{"arg": {cid}}, nil, nil, request.WithTimeout(time.Duration(0)))
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+
+	if resp.Response.StatusCode != http.StatusOK {
+		return nil, decode(resp, nil)
+	}
+
+	rs, err := resp.GetRSCloser()
+	if err != nil {
+		return nil, err
+	}
+
+	rs.SetFirstFakeChunk()
+
+	// 流式响应中没有 Content-Length，尝试自主获取文件大小
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
+		rs.SetContentLength(int64(file.Size))
+	} else if size, err := strconv.ParseInt(resp.Response.Header.Get("X-Content-Length"), 10, 64); err == nil {
+		rs.SetContentLength(size)
+	}
+
+	return rs, nil
+}
+
+// Put 将文件流添加至 IPFS 节点，并将存储路径替换为 CID。分片上传时分片先在本机暂存，
+// 最后一个分片上传后由 CompleteUploadWithSource 添加至节点
+func (handler *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
+	defer file.Close()
+	fileInfo := file.Info()
+
+	if fileInfo.Mode&fsctx.Append == fsctx.Append {
+		return driver.AppendStaging(driver.StagingPath(handler.Policy, fileInfo.SavePath), file, fileInfo.AppendStart, fileInfo.Size)
+	}
+
+	name := fileInfo.FileName
+	if name == "" {
+		name = path.Base(filepath.ToSlash(fileInfo.SavePath))
+	}
+
+	cid, err := handler.add(ctx, name, io.LimitReader(file, int64(fileInfo.Size)))
+	if err != nil {
+		return err
+	}
+
+	file.SetSavePath(cid)
+	return nil
+}
+
+// CompleteUploadWithSource 将暂存的分片上传文件添加至 IPFS 节点，返回文件的 CID
+func (handler *Driver) CompleteUploadWithSource(ctx context.Context, dst string) (string, error) {
+	staging := driver.StagingPath(handler.Policy, dst)
+	file, err := os.Open(staging)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	cid, err := handler.add(ctx, path.Base(filepath.ToSlash(dst)), file)
+	if err != nil {
+		return "", err
+	}
+
+	file.Close()
+	if err := os.Remove(staging); err != nil {
+		util.Log().Warning("Failed to remove staging file %q: %s", staging, err)
+	}
+
+	return cid, nil
+}
+
+// Truncate 将上传中的暂存文件截断至给定大小，已添加至节点的文件无法截断
+func (handler *Driver) Truncate(ctx context.Context, src string, size uint64) error {
+	util.Log().Warning("Truncate file %q to [%d].", src, size)
+	return driver.TruncateStaging(driver.StagingPath(handler.Policy, src), size)
+}
+
+// Delete 取消固定一个或多个文件，节点垃圾回收后文件才会被真正删除，
+// 返回未删除的文件，及遇到的最后一个错误
+func (handler *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	failed := make([]string, 0, len(files))
+	var retErr error
+
+	for _, file := range files {
+		// 非 CID 的路径在节点上不存在
+		if !isCID(file) {
+			continue
+		}
+
+		err := decode(handler.api(ctx, "pin/rm", url.Values{"arg": {file}}, nil, nil), nil)
+		if err == nil {
+			continue
+		}
+
+		// 未固定的文件视为删除成功
+		var apiErr *apiError
+		if errors.As(err, &apiErr) && strings.Contains(apiErr.Message, "not pinned") {
+			continue
+		}
+
+		util.Log().Warning("Failed to unpin %q: %s", file, err)
+		failed = append(failed, file)
+		retErr = err
+	}
+
+	return failed, retErr
+}
+
+// Thumb 获取文件缩略图
+func (handler *Driver) Thumb(ctx context.Context, file *model.File) (*response.ContentResponse, error) {
+	return nil, driver.ErrorThumbNotSupported
+}
+
+// Source 获取经由网关下载的 URL，IPFS 上的内容均为公开，ttl 不生效
+func (handler *Driver) Source(ctx context.Context, cid string, ttl int64, isDownload bool, speed int) (string, error) {
+	gateway := handler.Policy.BaseURL
+	if gateway == "" {
+		gateway = defaultGateway
+	}
+
+	sourceURL, err := url.Parse(strings.TrimSuffix(gateway, "/") + "/ipfs/" + url.PathEscape(cid))
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
+		query.Set("filename", file.Name)
+	}
+	if isDownload {
+		query.Set("download", "true")
+	}
+
+	sourceURL.RawQuery = query.Encode()
+	return sourceURL.String(), nil
+}
+
+// Token 获取上传凭证，分片由 Cloudreve 中转上传
+func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
+	return &serializer.UploadCredential{
+		SessionID: uploadSession.Key,
+		ChunkSize: handler.Policy.OptionsSerialized.ChunkSize,
+	}, nil
+}
+
+// CancelToken 取消上传凭证，删除本机暂存的分片
+func (handler *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	return driver.RemoveStaging(driver.StagingPath(handler.Policy, uploadSession.SavePath))
+}
+
+// Capabilities 返回存储端支持的特性
+func (handler *Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		MultipartUpload: true,
+	}
+}
@@ -0,0 +1,284 @@
+package ipfs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/mocks/requestmock"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+)
+
+const apiURL = "http://127.0.0.1:5001/api/v0/"
+
+func newTestDriver(t *testing.T, clientMock request.Client) *Driver {
+	handler, err := NewDriver(&model.Policy{
+		Model:  gorm.Model{ID: 1},
+		Type:   "ipfs",
+		Server: "http://127.0.0.1:5001/",
+	})
+	assert.NoError(t, err)
+	handler.client = clientMock
+	return handler
+}
+
+func newResponse(status int, body string) *request.Response {
+	return &request.Response{
+		Response: &http.Response{
+			StatusCode:    status,
+			Header:        http.Header{},
+			ContentLength: int64(len(body)),
+			Body:          io.NopCloser(strings.NewReader(body)),
+		},
+	}
+}
+
+func TestNewDriver(t *testing.T) {
+	a := assert.New(t)
+	_, err := NewDriver(&model.Policy{})
+	a.Error(err)
+	_, err = NewDriver(&model.Policy{Server: "http://127.0.0.1:5001"})
+	a.NoError(err)
+}
+
+func TestIsCID(t *testing.T) {
+	a := assert.New(t)
+	a.True(isCID("bafkreigh2akiscaildcqabsyg3dfr6chu3fgpregiymsck7e7aqa4s52zy"))
+	a.True(isCID("QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG"))
+	a.False(isCID(""))
+	a.False(isCID("uploads/1/a.txt"))
+	a.False(isCID("bafkrei._thumb"))
+}
+
+func TestDriver_Put(t *testing.T) {
+	a := assert.New(t)
+
+	// 成功
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "POST", apiURL+"add?cid-version=1&pin=true&quieter=true", testMock.Anything, testMock.Anything).
+			Return(newResponse(200, `{"Name":"a.txt","Hash":"bafycid","Size":"4"}`))
+		handler := newTestDriver(t, clientMock)
+		file := &fsctx.FileStream{
+			File:     io.NopCloser(strings.NewReader("1234")),
+			Name:     "a.txt",
+			SavePath: "uploads/a.txt",
+			Size:     4,
+		}
+		a.NoError(handler.Put(context.Background(), file))
+		clientMock.AssertExpectations(t)
+		a.Equal("bafycid", file.SavePath)
+	}
+
+	// API 返回错误
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "POST", apiURL+"add?cid-version=1&pin=true&quieter=true", testMock.Anything, testMock.Anything).
+			Return(newResponse(500, `{"Message":"add failed","Code":0,"Type":"error"}`))
+		handler := newTestDriver(t, clientMock)
+		file := &fsctx.FileStream{
+			File:     io.NopCloser(strings.NewReader("1234")),
+			SavePath: "uploads/a.txt",
+			Size:     4,
+		}
+		err := handler.Put(context.Background(), file)
+		clientMock.AssertExpectations(t)
+		a.Error(err)
+		a.Contains(err.Error(), "add failed")
+		a.Equal("uploads/a.txt", file.SavePath)
+	}
+}
+
+func TestDriver_Add(t *testing.T) {
+	a := assert.New(t)
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "POST", apiURL+"add?cid-version=1&pin=true&quieter=true", testMock.Anything, testMock.Anything).Return(newResponse(200, `{"Name":"a.txt","Hash":"bafycid","Size":"4"}`)).Run(func(args testMock.Arguments) {
+		// 解析 multipart 正文
+		body := args.Get(2).(io.Reader)
+		content, err := io.ReadAll(body)
+		a.NoError(err)
+		a.Contains(string(content), `filename="a.txt"`)
+		a.Contains(string(content), "1234")
+	})
+	handler := newTestDriver(t, clientMock)
+	cid, err := handler.add(context.Background(), "a.txt", strings.NewReader("1234"))
+	clientMock.AssertExpectations(t)
+	a.NoError(err)
+	a.Equal("bafycid", cid)
+}
+
+func TestDriver_PutChunk(t *testing.T) {
+	a := assert.New(t)
+	cache.Set("setting_temp_path", t.TempDir(), 0)
+	chunk := func(start uint64, content string) *fsctx.FileStream {
+		return &fsctx.FileStream{
+			File:        io.NopCloser(strings.NewReader(content)),
+			Mode:        fsctx.Append,
+			SavePath:    "uploads/chunk.txt",
+			Size:        uint64(len(content)),
+			AppendStart: start,
+		}
+	}
+
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "POST", apiURL+"add?cid-version=1&pin=true&quieter=true", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, `{"Name":"chunk.txt","Hash":"bafychunk","Size":"4"}`)).Run(func(args testMock.Arguments) {
+		content, err := io.ReadAll(args.Get(2).(io.Reader))
+		a.NoError(err)
+		a.Contains(string(content), "1234")
+	})
+	handler := newTestDriver(t, clientMock)
+	staging := driver.StagingPath(handler.Policy, "uploads/chunk.txt")
+	defer os.Remove(staging)
+
+	a.NoError(handler.Put(context.Background(), chunk(0, "12")))
+	// 分片偏移不连续
+	a.ErrorIs(handler.Put(context.Background(), chunk(3, "4")), ErrChunkOffset)
+	// 重传分片
+	a.NoError(handler.Put(context.Background(), chunk(1, "23")))
+	a.NoError(handler.Truncate(context.Background(), "uploads/chunk.txt", 2))
+	a.NoError(handler.Put(context.Background(), chunk(2, "34")))
+
+	cid, err := handler.CompleteUploadWithSource(context.Background(), "uploads/chunk.txt")
+	clientMock.AssertExpectations(t)
+	a.NoError(err)
+	a.Equal("bafychunk", cid)
+	_, err = os.Stat(staging)
+	a.True(os.IsNotExist(err))
+
+	// 暂存文件不存在时无需截断
+	a.NoError(handler.Truncate(context.Background(), "bafychunk", 2))
+}
+
+func TestDriver_CancelToken(t *testing.T) {
+	a := assert.New(t)
+	cache.Set("setting_temp_path", t.TempDir(), 0)
+	handler := newTestDriver(t, &requestmock.RequestMock{})
+	a.NoError(driver.AppendStaging(driver.StagingPath(handler.Policy, "uploads/cancel.txt"), strings.NewReader("1"), 0, 1))
+	a.NoError(handler.CancelToken(context.Background(), &serializer.UploadSession{SavePath: "uploads/cancel.txt"}))
+	a.NoError(handler.CancelToken(context.Background(), &serializer.UploadSession{SavePath: "uploads/cancel.txt"}))
+}
+
+func TestDriver_Token(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t, &requestmock.RequestMock{})
+	handler.Policy.OptionsSerialized.ChunkSize = 10
+	res, err := handler.Token(context.Background(), 10, &serializer.UploadSession{Key: "key"}, &fsctx.FileStream{})
+	a.NoError(err)
+	a.Equal("key", res.SessionID)
+	a.EqualValues(10, res.ChunkSize)
+}
+
+func TestDriver_Delete(t *testing.T) {
+	a := assert.New(t)
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "POST", apiURL+"pin/rm?arg=bafyok", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, `{"Pins":["bafyok"]}`))
+	clientMock.On("Request", "POST", apiURL+"pin/rm?arg=bafyunpinned", testMock.Anything, testMock.Anything).
+		Return(newResponse(500, `{"Message":"not pinned or pinned indirectly","Code":0,"Type":"error"}`))
+	clientMock.On("Request", "POST", apiURL+"pin/rm?arg=bafyfailed", testMock.Anything, testMock.Anything).
+		Return(newResponse(500, `{"Message":"context canceled","Code":0,"Type":"error"}`))
+	handler := newTestDriver(t, clientMock)
+
+	failed, err := handler.Delete(context.Background(), []string{"bafyok", "bafyunpinned", "bafyfailed", "uploads/a.txt", "bafyok._thumb"})
+	clientMock.AssertExpectations(t)
+	a.Error(err)
+	a.Equal([]string{"bafyfailed"}, failed)
+}
+
+func TestDriver_List(t *testing.T) {
+	a := assert.New(t)
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "POST", apiURL+"pin/ls?type=recursive", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, `{"Keys":{"bafyfile":{"Type":"recursive"},"bafydir":{"Type":"recursive"},"bafygone":{"Type":"recursive"}}}`))
+	clientMock.On("Request", "POST", apiURL+"files/stat?arg=%2Fipfs%2Fbafyfile", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, `{"Hash":"bafyfile","Size":4,"Type":"file"}`))
+	clientMock.On("Request", "POST", apiURL+"files/stat?arg=%2Fipfs%2Fbafydir", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, `{"Hash":"bafydir","Size":0,"Type":"directory"}`))
+	clientMock.On("Request", "POST", apiURL+"files/stat?arg=%2Fipfs%2Fbafygone", testMock.Anything, testMock.Anything).
+		Return(newResponse(500, `{"Message":"not found","Code":0,"Type":"error"}`))
+	handler := newTestDriver(t, clientMock)
+
+	res, err := handler.List(context.Background(), "/", true)
+	clientMock.AssertExpectations(t)
+	a.NoError(err)
+	a.Len(res, 1)
+	a.Equal("bafyfile", res[0].Source)
+	a.EqualValues(4, res[0].Size)
+
+	// 子目录为空
+	res, err = handler.List(context.Background(), "/dir", true)
+	a.NoError(err)
+	a.Empty(res)
+}
+
+func TestDriver_Get(t *testing.T) {
+	a := assert.New(t)
+
+	// 成功
+	{
+		clientMock := &requestmock.RequestMock{}
+		resp := newResponse(200, "1234")
+		resp.Response.ContentLength = -1
+		resp.Response.Header.Set("X-Content-Length", "4")
+		clientMock.On("Request", "POST", apiURL+"cat?arg=bafycid", testMock.Anything, testMock.Anything).Return(resp)
+		handler := newTestDriver(t, clientMock)
+
+		rs, err := handler.Get(context.Background(), "bafycid")
+		clientMock.AssertExpectations(t)
+		a.NoError(err)
+		size, err := rs.Seek(0, io.SeekEnd)
+		a.NoError(err)
+		a.EqualValues(4, size)
+		content := make([]byte, 1024)
+		n, _ := rs.Read(content)
+		a.Equal("1234", string(content[:n]))
+	}
+
+	// 失败
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "POST", apiURL+"cat?arg=bafycid", testMock.Anything, testMock.Anything).
+			Return(newResponse(500, `{"Message":"block was not found locally","Code":0,"Type":"error"}`))
+		handler := newTestDriver(t, clientMock)
+
+		_, err := handler.Get(context.Background(), "bafycid")
+		clientMock.AssertExpectations(t)
+		a.EqualError(err, "block was not found locally")
+	}
+}
+
+func TestDriver_Source(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t, &requestmock.RequestMock{})
+
+	// 默认网关
+	res, err := handler.Source(context.Background(), "bafycid", 10, false, 0)
+	a.NoError(err)
+	a.Equal("https://ipfs.io/ipfs/bafycid", res)
+
+	// 自定义网关，下载
+	handler.Policy.BaseURL = "https://gateway.example.com/"
+	ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, model.File{Name: "a b.txt"})
+	res, err = handler.Source(ctx, "bafycid", 10, true, 0)
+	a.NoError(err)
+	a.Equal("https://gateway.example.com/ipfs/bafycid?download=true&filename=a+b.txt", res)
+}
+
+func TestDriver_Thumb(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t, &requestmock.RequestMock{})
+	_, err := handler.Thumb(context.Background(), &model.File{})
+	a.ErrorIs(err, driver.ErrorThumbNotSupported)
+}
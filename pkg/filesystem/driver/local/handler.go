@@ -22,8 +22,13 @@ import (
 
 const (
 	Perm = 0744
+	// uploadTempPattern 暂存上传文件的临时文件名
+	uploadTempPattern = "cloudreve_upload_*"
 )
 
+// ErrInsufficientTempSpace 暂存目录所在磁盘剩余空间不足
+var ErrInsufficientTempSpace = driver.ErrInsufficientTempSpace
+
 // Driver 本地策略适配器
 type Driver struct {
 	Policy *model.Policy
@@ -111,6 +116,16 @@ func (handler Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
 		}
 	}
 
+	// 设定了暂存目录时，完整上传的文件写入暂存目录后移动至存储路径，
+	// 分片在暂存目录中拼接，最后一个分片上传后由 CompleteUpload 移动至存储路径
+	if handler.stagingEnabled() {
+		if fileInfo.Mode&fsctx.Append == fsctx.Append {
+			return driver.AppendStaging(driver.StagingPath(handler.Policy, fileInfo.SavePath), file, fileInfo.AppendStart, fileInfo.Size)
+		}
+
+		return handler.putStaged(file, fileInfo.Size, dst)
+	}
+
 	var (
 		out *os.File
 		err error
@@ -159,8 +174,68 @@ func (handler Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
 	return err
 }
 
+// stagingEnabled 返回上传时是否先写入暂存目录
+func (handler Driver) stagingEnabled() bool {
+	return handler.Policy != nil && handler.Policy.OptionsSerialized.UploadTempPath != ""
+}
+
+// putStaged 将文件写入暂存目录，写入完成后移动至 dst
+func (handler Driver) putStaged(file io.Reader, size uint64, dst string) error {
+	tempDir := driver.StagingDir(handler.Policy)
+	if err := driver.CheckStagingSpace(tempDir, size); err != nil {
+		return err
+	}
+
+	out, err := os.CreateTemp(tempDir, uploadTempPattern)
+	if err != nil {
+		util.Log().Warning("Failed to create temp file: %s", err)
+		return err
+	}
+
+	tempPath := out.Name()
+	_, err = io.Copy(out, file)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(tempPath, Perm)
+	}
+
+	if err == nil {
+		err = util.MoveFile(tempPath, dst)
+	}
+
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	return nil
+}
+
+// CompleteUpload 最后一个分片上传后，将暂存目录中拼接完成的文件移动至存储路径
+func (handler Driver) CompleteUpload(ctx context.Context, dst string) error {
+	if !handler.stagingEnabled() {
+		return nil
+	}
+
+	staging := driver.StagingPath(handler.Policy, dst)
+	if !util.Exists(staging) {
+		return nil
+	}
+
+	return util.MoveFile(staging, util.RelativePath(filepath.FromSlash(dst)))
+}
+
 func (handler Driver) Truncate(ctx context.Context, src string, size uint64) error {
 	util.Log().Warning("Truncate file %q to [%d].", src, size)
+	if handler.stagingEnabled() {
+		if staging := driver.StagingPath(handler.Policy, src); util.Exists(staging) {
+			return driver.TruncateStaging(staging, size)
+		}
+	}
+
 	out, err := os.OpenFile(src, os.O_WRONLY, Perm)
 	if err != nil {
 		util.Log().Warning("Failed to open file: %s", err)
@@ -188,6 +263,13 @@ func (handler Driver) Delete(ctx context.Context, files []string) ([]string, err
 			}
 		}
 
+		// 删除未完成上传的暂存分片
+		if handler.stagingEnabled() {
+			if err := driver.RemoveStaging(driver.StagingPath(handler.Policy, value)); err != nil {
+				util.Log().Warning("Failed to delete staging file: %s", err)
+			}
+		}
+
 		// 尝试删除文件的缩略图（如果有）
 		_ = os.Remove(util.RelativePath(value + model.GetSettingByNameWithDefault("thumb_file_suffix", "._thumb")))
 	}
@@ -290,3 +372,12 @@ func (handler Driver) Token(ctx context.Context, ttl int64, uploadSession *seria
 func (handler Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
 	return nil
 }
+
+// Capabilities 返回存储端支持的特性
+func (handler Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		RangeGet:        true,
+		MultipartUpload: true,
+		Thumbnail:       true,
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
 	"io"
+	"math"
 	"os"
 	"strings"
 	"testing"
@@ -71,6 +72,92 @@ func TestHandler_Put(t *testing.T) {
 	}
 }
 
+func TestHandler_PutStaged(t *testing.T) {
+	a := assert.New(t)
+	handler := Driver{Policy: &model.Policy{
+		OptionsSerialized: model.PolicyOption{UploadTempPath: "TestHandler_PutStaged_temp"},
+	}}
+	defer func() {
+		os.RemoveAll(util.RelativePath("TestHandler_PutStaged_temp"))
+		os.Remove(util.RelativePath("TestHandler_PutStaged.txt"))
+	}()
+
+	// 写入暂存目录后移动至存储路径
+	err := handler.Put(context.Background(), &fsctx.FileStream{
+		SavePath: "TestHandler_PutStaged.txt",
+		Size:     3,
+		File:     io.NopCloser(strings.NewReader("123")),
+	})
+	a.NoError(err)
+	content, err := os.ReadFile(util.RelativePath("TestHandler_PutStaged.txt"))
+	a.NoError(err)
+	a.Equal("123", string(content))
+	empty, err := util.IsEmpty(util.RelativePath("TestHandler_PutStaged_temp"))
+	a.NoError(err)
+	a.True(empty)
+
+	// 暂存目录剩余空间不足
+	err = handler.Put(context.Background(), &fsctx.FileStream{
+		Mode:     fsctx.Overwrite,
+		SavePath: "TestHandler_PutStaged.txt",
+		Size:     math.MaxUint64,
+		File:     io.NopCloser(strings.NewReader("123")),
+	})
+	a.Equal(ErrInsufficientTempSpace, err)
+}
+
+func TestHandler_PutStagedChunk(t *testing.T) {
+	a := assert.New(t)
+	handler := Driver{Policy: &model.Policy{
+		OptionsSerialized: model.PolicyOption{UploadTempPath: "TestHandler_PutStagedChunk_temp"},
+	}}
+	defer func() {
+		os.RemoveAll(util.RelativePath("TestHandler_PutStagedChunk_temp"))
+		os.Remove(util.RelativePath("TestHandler_PutStagedChunk.txt"))
+	}()
+
+	chunk := func(start uint64, content string) fsctx.FileHeader {
+		return &fsctx.FileStream{
+			Mode:        fsctx.Append | fsctx.Overwrite,
+			SavePath:    "TestHandler_PutStagedChunk.txt",
+			AppendStart: start,
+			Size:        uint64(len(content)),
+			File:        io.NopCloser(strings.NewReader(content)),
+		}
+	}
+
+	// 分片在暂存目录中拼接，上传完成前不写入存储路径
+	a.NoError(handler.Put(context.Background(), chunk(0, "123")))
+	a.NoError(handler.Put(context.Background(), chunk(3, "45")))
+	a.False(util.Exists(util.RelativePath("TestHandler_PutStagedChunk.txt")))
+
+	// 分片位置不符
+	a.Equal(driver.ErrChunkOffset, handler.Put(context.Background(), chunk(10, "6")))
+
+	// 重传分片前截断暂存文件
+	a.NoError(handler.Truncate(context.Background(), "TestHandler_PutStagedChunk.txt", 3))
+	a.NoError(handler.Put(context.Background(), chunk(3, "456")))
+
+	// 暂存目录剩余空间不足
+	a.Equal(ErrInsufficientTempSpace, handler.Put(context.Background(), &fsctx.FileStream{
+		Mode:     fsctx.Append | fsctx.Overwrite,
+		SavePath: "TestHandler_PutStagedChunk.txt",
+		Size:     math.MaxUint64,
+		File:     io.NopCloser(strings.NewReader("7")),
+	}))
+
+	// 上传完成后移动至存储路径
+	a.NoError(handler.CompleteUpload(context.Background(), "TestHandler_PutStagedChunk.txt"))
+	content, err := os.ReadFile(util.RelativePath("TestHandler_PutStagedChunk.txt"))
+	a.NoError(err)
+	a.Equal("123456", string(content))
+	a.False(util.Exists(driver.StagingPath(handler.Policy, "TestHandler_PutStagedChunk.txt")))
+
+	// 未启用暂存或暂存文件不存在
+	a.NoError(handler.CompleteUpload(context.Background(), "TestHandler_PutStagedChunk.txt"))
+	a.NoError(Driver{}.CompleteUpload(context.Background(), "TestHandler_PutStagedChunk.txt"))
+}
+
 func TestDriver_TruncateFailed(t *testing.T) {
 	a := assert.New(t)
 	h := Driver{}
@@ -0,0 +1,361 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+var (
+	ErrChunkOffset       = driver.ErrChunkOffset
+	ErrNotEnoughReplicas = errors.New("mirror policy requires at least two replicas")
+	ErrAllReplicasFailed = errors.New("failed to write file to any replica")
+)
+
+// pendingSuffix 待修复记录文件的后缀
+const pendingSuffix = ".pending"
+
+// Replica 镜像策略的一个副本
+type Replica struct {
+	Policy  *model.Policy
+	Handler driver.Handler
+}
+
+// Driver 镜像存储策略适配器，上传的文件被同时写入所有副本，读取时使用第一个可用的副本。
+// 文件先在本机暂存，写入失败的副本会保留暂存文件并记录，由 Repair 重新同步
+type Driver struct {
+	Policy   *model.Policy
+	Replicas []Replica
+}
+
+// pending 待修复的文件
+type pending struct {
+	Source   string `json:"source"`
+	Replicas []uint `json:"replicas"`
+}
+
+// NewDriver 创建镜像策略适配器，replicas 按读取优先级排序
+func NewDriver(policy *model.Policy, replicas []Replica) (*Driver, error) {
+	if len(replicas) < 2 {
+		return nil, ErrNotEnoughReplicas
+	}
+
+	return &Driver{
+		Policy:   policy,
+		Replicas: replicas,
+	}, nil
+}
+
+// putReplica 将暂存文件写入单个副本
+func (handler *Driver) putReplica(ctx context.Context, replica *Replica, staging, dst string, info *fsctx.UploadTaskInfo) error {
+	file, err := os.Open(staging)
+	if err != nil {
+		return err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	stream := &fsctx.FileStream{
+		Mode:     fsctx.Overwrite,
+		File:     file,
+		Seeker:   file,
+		Size:     uint64(stat.Size()),
+		SavePath: dst,
+	}
+	if info != nil {
+		stream.Name = info.FileName
+		stream.MimeType = info.MimeType
+		stream.VirtualPath = info.VirtualPath
+		stream.LastModified = info.LastModified
+	}
+
+	return replica.Handler.Put(ctx, stream)
+}
+
+// replicate 将暂存文件写入所有副本。部分副本失败时保留暂存文件并记录待修复的副本，
+// 所有副本均失败时返回错误
+func (handler *Driver) replicate(ctx context.Context, dst string, info *fsctx.UploadTaskInfo) error {
+	staging := driver.StagingPath(handler.Policy, dst)
+	failed := make([]uint, 0)
+	var lastErr error
+	for i := range handler.Replicas {
+		if err := handler.putReplica(ctx, &handler.Replicas[i], staging, dst, info); err != nil {
+			util.Log().Warning("Failed to write %q to replica %q: %s", dst, handler.Replicas[i].Policy.Name, err)
+			failed = append(failed, handler.Replicas[i].Policy.ID)
+			lastErr = err
+		}
+	}
+
+	if len(failed) == len(handler.Replicas) {
+		handler.removeStaging(dst)
+		return fmt.Errorf("%w: %s", ErrAllReplicasFailed, lastErr)
+	}
+
+	if len(failed) > 0 {
+		return handler.savePending(&pending{Source: dst, Replicas: failed})
+	}
+
+	handler.removeStaging(dst)
+	return nil
+}
+
+// savePending 保存待修复记录
+func (handler *Driver) savePending(p *pending) error {
+	content, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(driver.StagingPath(handler.Policy, p.Source)+pendingSuffix, content, local.Perm)
+}
+
+// removeStaging 删除暂存文件及其待修复记录
+func (handler *Driver) removeStaging(dst string) {
+	staging := driver.StagingPath(handler.Policy, dst)
+	for _, p := range []string{staging, staging + pendingSuffix} {
+		if err := driver.RemoveStaging(p); err != nil {
+			util.Log().Warning("Failed to remove staging file %q: %s", p, err)
+		}
+	}
+}
+
+// replica 根据存储策略 ID 查找副本
+func (handler *Driver) replica(id uint) *Replica {
+	for i := range handler.Replicas {
+		if handler.Replicas[i].Policy.ID == id {
+			return &handler.Replicas[i]
+		}
+	}
+
+	return nil
+}
+
+// Repair 将暂存的文件重新写入此前写入失败的副本，exist 返回文件是否仍被引用，
+// 已被删除的文件不再修复。返回修复完成的文件数，及遇到的最后一个错误
+func (handler *Driver) Repair(ctx context.Context, exist func(source string) bool) (int, error) {
+	records, err := filepath.Glob(filepath.Join(
+		driver.StagingDir(handler.Policy),
+		fmt.Sprintf("%d_*%s", handler.Policy.ID, pendingSuffix),
+	))
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		repaired int
+		lastErr  error
+	)
+	for _, record := range records {
+		content, err := os.ReadFile(record)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		var p pending
+		if err := json.Unmarshal(content, &p); err != nil {
+			util.Log().Warning("Failed to parse pending record %q: %s", record, err)
+			lastErr = err
+			continue
+		}
+
+		if !exist(p.Source) {
+			handler.removeStaging(p.Source)
+			continue
+		}
+
+		failed := make([]uint, 0, len(p.Replicas))
+		for _, id := range p.Replicas {
+			replica := handler.replica(id)
+			if replica == nil {
+				// 副本已从镜像策略中移除
+				continue
+			}
+
+			if err := handler.putReplica(ctx, replica, driver.StagingPath(handler.Policy, p.Source), p.Source, nil); err != nil {
+				util.Log().Warning("Failed to repair %q on replica %q: %s", p.Source, replica.Policy.Name, err)
+				failed = append(failed, id)
+				lastErr = err
+			}
+		}
+
+		if len(failed) > 0 {
+			p.Replicas = failed
+			if err := handler.savePending(&p); err != nil {
+				lastErr = err
+			}
+			continue
+		}
+
+		handler.removeStaging(p.Source)
+		repaired++
+	}
+
+	return repaired, lastErr
+}
+
+// List 列出第一个可用副本中的文件
+func (handler *Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	var lastErr error
+	for _, replica := range handler.Replicas {
+		res, err := replica.Handler.List(ctx, base, recursive)
+		if err == nil {
+			return res, nil
+		}
+
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+// Get 从第一个可用的副本获取文件内容
+func (handler *Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	var lastErr error
+	for _, replica := range handler.Replicas {
+		rs, err := replica.Handler.Get(ctx, path)
+		if err == nil {
+			return rs, nil
+		}
+
+		util.Log().Debug("Failed to read %q from replica %q: %s", path, replica.Policy.Name, err)
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+// Put 将文件暂存至本机后写入所有副本。分片上传时分片先在本机暂存，
+// 最后一个分片上传后由 CompleteUpload 写入副本
+func (handler *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
+	defer file.Close()
+	fileInfo := file.Info()
+	staging := driver.StagingPath(handler.Policy, fileInfo.SavePath)
+
+	if fileInfo.Mode&fsctx.Append == fsctx.Append {
+		return driver.AppendStaging(staging, file, fileInfo.AppendStart, fileInfo.Size)
+	}
+
+	if err := driver.AppendStaging(staging, io.LimitReader(file, int64(fileInfo.Size)), 0, fileInfo.Size); err != nil {
+		handler.removeStaging(fileInfo.SavePath)
+		return err
+	}
+
+	return handler.replicate(ctx, fileInfo.SavePath, fileInfo)
+}
+
+// CompleteUpload 将暂存的分片上传文件写入所有副本
+func (handler *Driver) CompleteUpload(ctx context.Context, dst string) error {
+	return handler.replicate(ctx, dst, nil)
+}
+
+// Truncate 将上传中的暂存文件截断至给定大小
+func (handler *Driver) Truncate(ctx context.Context, src string, size uint64) error {
+	util.Log().Warning("Truncate file %q to [%d].", src, size)
+	return driver.TruncateStaging(driver.StagingPath(handler.Policy, src), size)
+}
+
+// Delete 从所有副本中删除文件，返回任一副本中删除失败的文件，及遇到的最后一个错误
+func (handler *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	failed := make(map[string]bool)
+	var retErr error
+
+	for _, replica := range handler.Replicas {
+		replicaFailed, err := replica.Handler.Delete(ctx, files)
+		if err != nil {
+			util.Log().Warning("Failed to delete files from replica %q: %s", replica.Policy.Name, err)
+			retErr = err
+		}
+
+		for _, f := range replicaFailed {
+			failed[f] = true
+		}
+	}
+
+	res := make([]string, 0, len(failed))
+	for _, f := range files {
+		// 已删除的文件无需再修复
+		handler.removeStaging(f)
+		if failed[f] {
+			res = append(res, f)
+		}
+	}
+
+	return res, retErr
+}
+
+// Thumb 从第一个可用的副本获取缩略图
+func (handler *Driver) Thumb(ctx context.Context, file *model.File) (*response.ContentResponse, error) {
+	var lastErr error
+	for _, replica := range handler.Replicas {
+		res, err := replica.Handler.Thumb(ctx, file)
+		if err == nil || errors.Is(err, driver.ErrorThumbNotExist) || errors.Is(err, driver.ErrorThumbNotSupported) {
+			return res, err
+		}
+
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+// Source 获取第一个可用副本的外链 URL
+func (handler *Driver) Source(ctx context.Context, path string, ttl int64, isDownload bool, speed int) (string, error) {
+	var lastErr error
+	for _, replica := range handler.Replicas {
+		res, err := replica.Handler.Source(ctx, path, ttl, isDownload, speed)
+		if err == nil {
+			return res, nil
+		}
+
+		lastErr = err
+	}
+
+	return "", lastErr
+}
+
+// Token 获取上传凭证，文件由 Cloudreve 中转后写入各副本
+func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
+	return &serializer.UploadCredential{
+		SessionID: uploadSession.Key,
+		ChunkSize: handler.Policy.OptionsSerialized.ChunkSize,
+	}, nil
+}
+
+// CancelToken 取消上传凭证，删除本机暂存的分片
+func (handler *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	handler.removeStaging(uploadSession.SavePath)
+	return nil
+}
+
+// Capabilities 返回存储端支持的特性，读取时可能使用任一副本，
+// 因此只有所有副本均支持时才支持任意位置读取
+func (handler *Driver) Capabilities() driver.Capabilities {
+	caps := driver.Capabilities{
+		RangeGet:        len(handler.Replicas) > 0,
+		MultipartUpload: true,
+	}
+	for _, replica := range handler.Replicas {
+		replicaCaps := replica.Handler.Capabilities()
+		caps.RangeGet = caps.RangeGet && replicaCaps.RangeGet
+		caps.Thumbnail = caps.Thumbnail || replicaCaps.Thumbnail
+	}
+
+	return caps
+}
@@ -0,0 +1,327 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryHandler 将文件保存在内存中的适配器，err 不为空时所有操作均失败
+type memoryHandler struct {
+	files map[string]string
+	err   error
+	caps  driver.Capabilities
+}
+
+type readSeekNopCloser struct {
+	*strings.Reader
+}
+
+func (readSeekNopCloser) Close() error {
+	return nil
+}
+
+func (h *memoryHandler) Put(ctx context.Context, file fsctx.FileHeader) error {
+	defer file.Close()
+	if h.err != nil {
+		return h.err
+	}
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	h.files[file.Info().SavePath] = string(content)
+	return nil
+}
+
+func (h *memoryHandler) Delete(ctx context.Context, files []string) ([]string, error) {
+	if h.err != nil {
+		return files, h.err
+	}
+
+	for _, f := range files {
+		delete(h.files, f)
+	}
+	return []string{}, nil
+}
+
+func (h *memoryHandler) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	if h.err != nil {
+		return nil, h.err
+	}
+
+	content, ok := h.files[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return readSeekNopCloser{strings.NewReader(content)}, nil
+}
+
+func (h *memoryHandler) Thumb(ctx context.Context, file *model.File) (*response.ContentResponse, error) {
+	if h.err != nil {
+		return nil, h.err
+	}
+	return nil, driver.ErrorThumbNotExist
+}
+
+func (h *memoryHandler) Source(ctx context.Context, path string, ttl int64, isDownload bool, speed int) (string, error) {
+	if h.err != nil {
+		return "", h.err
+	}
+	return "memory://" + path, nil
+}
+
+func (h *memoryHandler) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
+	return nil, errors.New("not supported")
+}
+
+func (h *memoryHandler) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	return nil
+}
+
+func (h *memoryHandler) Capabilities() driver.Capabilities {
+	return h.caps
+}
+
+func (h *memoryHandler) List(ctx context.Context, path string, recursive bool) ([]response.Object, error) {
+	if h.err != nil {
+		return nil, h.err
+	}
+
+	res := make([]response.Object, 0, len(h.files))
+	for name := range h.files {
+		res = append(res, response.Object{Name: name, Source: name})
+	}
+	return res, nil
+}
+
+func newTestDriver(t *testing.T) (*Driver, *memoryHandler, *memoryHandler) {
+	cache.Set("setting_temp_path", t.TempDir(), 0)
+	primary := &memoryHandler{files: map[string]string{}}
+	secondary := &memoryHandler{files: map[string]string{}}
+	handler, err := NewDriver(&model.Policy{Model: gorm.Model{ID: 1}, Type: "mirror"}, []Replica{
+		{Policy: &model.Policy{Model: gorm.Model{ID: 2}}, Handler: primary},
+		{Policy: &model.Policy{Model: gorm.Model{ID: 3}}, Handler: secondary},
+	})
+	assert.NoError(t, err)
+	return handler, primary, secondary
+}
+
+func newStream(content, savePath string) *fsctx.FileStream {
+	return &fsctx.FileStream{
+		File:     io.NopCloser(strings.NewReader(content)),
+		Size:     uint64(len(content)),
+		SavePath: savePath,
+	}
+}
+
+func TestNewDriver(t *testing.T) {
+	a := assert.New(t)
+	_, err := NewDriver(&model.Policy{}, []Replica{{Policy: &model.Policy{}, Handler: &memoryHandler{}}})
+	a.ErrorIs(err, ErrNotEnoughReplicas)
+}
+
+func TestDriver_Put(t *testing.T) {
+	a := assert.New(t)
+
+	// 所有副本写入成功
+	{
+		handler, primary, secondary := newTestDriver(t)
+		a.NoError(handler.Put(context.Background(), newStream("content", "1/a.txt")))
+		a.Equal("content", primary.files["1/a.txt"])
+		a.Equal("content", secondary.files["1/a.txt"])
+		a.False(util.Exists(driver.StagingPath(handler.Policy, "1/a.txt")))
+	}
+
+	// 部分副本写入失败，保留暂存文件
+	{
+		handler, primary, secondary := newTestDriver(t)
+		secondary.err = errors.New("error")
+		a.NoError(handler.Put(context.Background(), newStream("content", "1/a.txt")))
+		a.Equal("content", primary.files["1/a.txt"])
+		a.True(util.Exists(driver.StagingPath(handler.Policy, "1/a.txt")))
+		a.True(util.Exists(driver.StagingPath(handler.Policy, "1/a.txt") + pendingSuffix))
+	}
+
+	// 所有副本写入失败
+	{
+		handler, primary, secondary := newTestDriver(t)
+		primary.err = errors.New("error")
+		secondary.err = errors.New("error")
+		a.ErrorIs(handler.Put(context.Background(), newStream("content", "1/a.txt")), ErrAllReplicasFailed)
+		a.False(util.Exists(driver.StagingPath(handler.Policy, "1/a.txt")))
+	}
+}
+
+func TestDriver_ChunkUpload(t *testing.T) {
+	a := assert.New(t)
+	handler, primary, secondary := newTestDriver(t)
+
+	chunk := newStream("12", "1/a.txt")
+	chunk.Mode = fsctx.Append
+	a.NoError(handler.Put(context.Background(), chunk))
+
+	// 分片偏移不连续
+	chunk = newStream("56", "1/a.txt")
+	chunk.Mode = fsctx.Append
+	chunk.AppendStart = 4
+	a.ErrorIs(handler.Put(context.Background(), chunk), ErrChunkOffset)
+
+	chunk = newStream("34", "1/a.txt")
+	chunk.Mode = fsctx.Append
+	chunk.AppendStart = 2
+	a.NoError(handler.Put(context.Background(), chunk))
+	a.Empty(primary.files)
+
+	// 截断暂存文件
+	a.NoError(handler.Truncate(context.Background(), "1/a.txt", 3))
+	a.NoError(handler.Truncate(context.Background(), "1/b.txt", 3))
+
+	a.NoError(handler.CompleteUpload(context.Background(), "1/a.txt"))
+	a.Equal("123", primary.files["1/a.txt"])
+	a.Equal("123", secondary.files["1/a.txt"])
+
+	// 取消上传
+	chunk = newStream("12", "1/c.txt")
+	chunk.Mode = fsctx.Append
+	a.NoError(handler.Put(context.Background(), chunk))
+	a.NoError(handler.CancelToken(context.Background(), &serializer.UploadSession{SavePath: "1/c.txt"}))
+	a.False(util.Exists(driver.StagingPath(handler.Policy, "1/c.txt")))
+}
+
+func TestDriver_Repair(t *testing.T) {
+	a := assert.New(t)
+	handler, _, secondary := newTestDriver(t)
+	secondary.err = errors.New("error")
+	a.NoError(handler.Put(context.Background(), newStream("a", "1/a.txt")))
+	a.NoError(handler.Put(context.Background(), newStream("b", "1/b.txt")))
+
+	// 副本仍不可用
+	repaired, err := handler.Repair(context.Background(), func(string) bool { return true })
+	a.Error(err)
+	a.Equal(0, repaired)
+
+	// 修复成功，已删除的文件不再修复
+	secondary.err = nil
+	repaired, err = handler.Repair(context.Background(), func(source string) bool { return source == "1/a.txt" })
+	a.NoError(err)
+	a.Equal(1, repaired)
+	a.Equal("a", secondary.files["1/a.txt"])
+	a.NotContains(secondary.files, "1/b.txt")
+	a.False(util.Exists(driver.StagingPath(handler.Policy, "1/a.txt")))
+	a.False(util.Exists(driver.StagingPath(handler.Policy, "1/b.txt")))
+}
+
+func TestDriver_Get(t *testing.T) {
+	a := assert.New(t)
+	handler, primary, secondary := newTestDriver(t)
+	secondary.files["1/a.txt"] = "content"
+
+	// 第一个副本不可用时读取下一个副本
+	rs, err := handler.Get(context.Background(), "1/a.txt")
+	a.NoError(err)
+	content, _ := io.ReadAll(rs)
+	a.Equal("content", string(content))
+
+	// 所有副本不可用
+	primary.err = errors.New("error")
+	secondary.err = errors.New("error")
+	_, err = handler.Get(context.Background(), "1/a.txt")
+	a.Error(err)
+}
+
+func TestDriver_Delete(t *testing.T) {
+	a := assert.New(t)
+	handler, primary, secondary := newTestDriver(t)
+	primary.files["1/a.txt"] = "content"
+	secondary.files["1/a.txt"] = "content"
+
+	failed, err := handler.Delete(context.Background(), []string{"1/a.txt"})
+	a.NoError(err)
+	a.Empty(failed)
+	a.Empty(primary.files)
+	a.Empty(secondary.files)
+
+	// 任一副本删除失败
+	secondary.err = errors.New("error")
+	failed, err = handler.Delete(context.Background(), []string{"1/a.txt"})
+	a.Error(err)
+	a.Equal([]string{"1/a.txt"}, failed)
+}
+
+func TestDriver_Thumb(t *testing.T) {
+	a := assert.New(t)
+	handler, primary, _ := newTestDriver(t)
+	primary.err = errors.New("error")
+	_, err := handler.Thumb(context.Background(), &model.File{})
+	a.ErrorIs(err, driver.ErrorThumbNotExist)
+}
+
+func TestDriver_Source(t *testing.T) {
+	a := assert.New(t)
+	handler, primary, secondary := newTestDriver(t)
+	res, err := handler.Source(context.Background(), "1/a.txt", 0, false, 0)
+	a.NoError(err)
+	a.Equal("memory://1/a.txt", res)
+
+	primary.err = errors.New("error")
+	secondary.err = errors.New("error")
+	_, err = handler.Source(context.Background(), "1/a.txt", 0, false, 0)
+	a.Error(err)
+}
+
+func TestDriver_List(t *testing.T) {
+	a := assert.New(t)
+	handler, primary, secondary := newTestDriver(t)
+	primary.err = errors.New("error")
+	secondary.files["1/a.txt"] = "content"
+	res, err := handler.List(context.Background(), "/", true)
+	a.NoError(err)
+	a.Len(res, 1)
+}
+
+func TestDriver_Token(t *testing.T) {
+	a := assert.New(t)
+	handler, _, _ := newTestDriver(t)
+	handler.Policy.OptionsSerialized.ChunkSize = 10
+	res, err := handler.Token(context.Background(), 10, &serializer.UploadSession{Key: "key"}, nil)
+	a.NoError(err)
+	a.Equal("key", res.SessionID)
+	a.EqualValues(10, res.ChunkSize)
+}
+
+func TestDriver_Capabilities(t *testing.T) {
+	a := assert.New(t)
+	handler, primary, secondary := newTestDriver(t)
+
+	// 文件需经由本机写入所有副本
+	caps := handler.Capabilities()
+	a.False(caps.PresignedUpload)
+	a.True(caps.MultipartUpload)
+	a.False(caps.RangeGet)
+	a.False(caps.Thumbnail)
+
+	// 仅当所有副本都支持时才支持任意位置读取
+	primary.caps = driver.Capabilities{RangeGet: true, Thumbnail: true}
+	caps = handler.Capabilities()
+	a.False(caps.RangeGet)
+	a.True(caps.Thumbnail)
+
+	secondary.caps = driver.Capabilities{RangeGet: true}
+	a.True(handler.Capabilities().RangeGet)
+}
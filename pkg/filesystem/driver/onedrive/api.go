@@ -28,6 +28,10 @@ const (
 	SmallFileSize uint64 = 4 * 1024 * 1024
 	// ChunkSize 服务端中转分片上传分片大小
 	ChunkSize uint64 = 10 * 1024 * 1024
+	// chunkSizeUnit 上传会话的分片大小须为 320 KiB 的整数倍
+	chunkSizeUnit uint64 = 320 * 1024
+	// maxChunkSize 上传会话单个请求的最大尺寸
+	maxChunkSize uint64 = 60 * 1024 * 1024
 	// ListRetry 列取请求重试次数
 	ListRetry       = 1
 	chunkRetrySleep = time.Second * 5
@@ -42,13 +46,26 @@ func (info *FileInfo) GetSourcePath() string {
 		return ""
 	}
 
-	return strings.TrimPrefix(
-		path.Join(
-			strings.TrimPrefix(res, "/drive/root:"),
-			info.Name,
-		),
-		"/",
-	)
+	// 通过 drives/{id} 访问时路径前缀为 /drives/{id}/root:
+	if i := strings.Index(res, "/root:"); i >= 0 {
+		res = res[i+len("/root:"):]
+	}
+
+	return strings.TrimPrefix(path.Join(res, info.Name), "/")
+}
+
+// normalizeChunkSize 将分片大小调整为上传会话接受的大小
+func normalizeChunkSize(size uint64) uint64 {
+	if size > maxChunkSize {
+		size = maxChunkSize
+	}
+
+	size -= size % chunkSizeUnit
+	if size == 0 {
+		size = chunkSizeUnit
+	}
+
+	return size
 }
 
 func (client *Client) getRequestURL(api string, opts ...Option) string {
@@ -196,6 +213,22 @@ func (client *Client) GetSiteIDByURL(ctx context.Context, siteUrl string) (strin
 	return siteInfo.ID, nil
 }
 
+// GetSiteDrives 列取 SharePoint 站点下的所有文档库
+func (client *Client) GetSiteDrives(ctx context.Context, siteID string) ([]Drive, error) {
+	requestURL := client.getRequestURL(fmt.Sprintf("sites/%s/drives", siteID), WithDriverResource(false))
+	res, reqErr := client.requestWithStr(ctx, "GET", requestURL, "", 200)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+
+	var drives DriveList
+	if err := json.Unmarshal([]byte(res), &drives); err != nil {
+		return nil, err
+	}
+
+	return drives.Value, nil
+}
+
 // GetUploadSessionStatus 查询上传会话状态
 func (client *Client) GetUploadSessionStatus(ctx context.Context, uploadURL string) (*UploadSessionResponse, error) {
 	res, err := client.requestWithStr(ctx, "GET", uploadURL, "", 200)
@@ -272,7 +305,7 @@ func (client *Client) Upload(ctx context.Context, file fsctx.FileHeader) error {
 	}
 
 	// Initial chunk groups
-	chunks := chunk.NewChunkGroup(file, client.Policy.OptionsSerialized.ChunkSize, &backoff.ConstantBackoff{
+	chunks := chunk.NewChunkGroup(file, normalizeChunkSize(client.Policy.OptionsSerialized.ChunkSize), &backoff.ConstantBackoff{
 		Max:   model.GetIntSetting("chunk_retries", 5),
 		Sleep: chunkRetrySleep,
 	}, model.IsTrueVal(model.GetSettingByName("use_temp_chunk_buffer")))
@@ -575,7 +608,8 @@ func (client *Client) request(ctx context.Context, method string, url string, bo
 			return "", sysError(decodeErr)
 		}
 
-		if res.Response.StatusCode == 429 {
+		// SharePoint 限流时返回 503 并携带 Retry-After
+		if res.Response.StatusCode == 429 || (res.Response.StatusCode == 503 && res.Response.Header.Get("Retry-After") != "") {
 			util.Log().Warning("OneDrive request is throttled.")
 			return "", backoff.NewRetryableErrorFromHeader(&errResp, res.Response.Header)
 		}
@@ -141,6 +141,59 @@ func TestRequest(t *testing.T) {
 		asserts.EqualValues(time.Duration(120)*time.Second, retryErr.RetryAfter)
 	}
 
+	// SharePoint返回503限流
+	{
+		header := http.Header{}
+		header.Add("retry-after", "30")
+		clientMock := ClientMock{}
+		clientMock.On(
+			"Request",
+			"POST",
+			"http://dev.com",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(&request.Response{
+			Err: nil,
+			Response: &http.Response{
+				StatusCode: 503,
+				Header:     header,
+				Body:       ioutil.NopCloser(strings.NewReader(`{"error":{"message":"server too busy"}}`)),
+			},
+		})
+		client.Request = clientMock
+		res, err := client.request(context.Background(), "POST", "http://dev.com", strings.NewReader(""))
+		clientMock.AssertExpectations(t)
+		asserts.Empty(res)
+		var retryErr *backoff.RetryableError
+		asserts.ErrorAs(err, &retryErr)
+		asserts.EqualValues(time.Duration(30)*time.Second, retryErr.RetryAfter)
+	}
+
+	// 不带Retry-After的503不重试
+	{
+		clientMock := ClientMock{}
+		clientMock.On(
+			"Request",
+			"POST",
+			"http://dev.com",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(&request.Response{
+			Err: nil,
+			Response: &http.Response{
+				StatusCode: 503,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(strings.NewReader(`{"error":{"message":"unavailable"}}`)),
+			},
+		})
+		client.Request = clientMock
+		_, err := client.request(context.Background(), "POST", "http://dev.com", strings.NewReader(""))
+		clientMock.AssertExpectations(t)
+		var retryErr *backoff.RetryableError
+		asserts.False(errors.As(err, &retryErr))
+		asserts.Equal("unavailable", err.Error())
+	}
+
 	// OneDrive返回未知响应
 	{
 		clientMock := ClientMock{}
@@ -179,6 +232,28 @@ func TestFileInfo_GetSourcePath(t *testing.T) {
 		asserts.Equal("123/32 1/%e6%96%87%e4%bb%b6%e5%90%8d.jpg", fileInfo.GetSourcePath())
 	}
 
+	// 通过 drives/{id} 访问
+	{
+		fileInfo := FileInfo{
+			Name: "1.jpg",
+			ParentReference: parentReference{
+				Path: "/drives/b!abc/root:/123",
+			},
+		}
+		asserts.Equal("123/1.jpg", fileInfo.GetSourcePath())
+	}
+
+	// 根目录
+	{
+		fileInfo := FileInfo{
+			Name: "1.jpg",
+			ParentReference: parentReference{
+				Path: "/drive/root:",
+			},
+		}
+		asserts.Equal("1.jpg", fileInfo.GetSourcePath())
+	}
+
 	// 失败
 	{
 		fileInfo := FileInfo{
@@ -278,6 +353,69 @@ func TestClient_GetSiteIDByURL(t *testing.T) {
 	}
 }
 
+func TestClient_GetSiteDrives(t *testing.T) {
+	asserts := assert.New(t)
+	client, _ := NewClient(&model.Policy{Server: "https://graph.microsoft.com/v1.0"})
+	client.Credential.AccessToken = "AccessToken"
+	client.Credential.ExpiresIn = time.Now().Add(time.Duration(100) * time.Hour).Unix()
+
+	// 返回未知响应
+	{
+		clientMock := ClientMock{}
+		clientMock.On(
+			"Request",
+			"GET",
+			"https://graph.microsoft.com/v1.0/sites/site/drives",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(&request.Response{
+			Err: nil,
+			Response: &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(strings.NewReader(`???`)),
+			},
+		})
+		client.Request = clientMock
+		res, err := client.GetSiteDrives(context.Background(), "site")
+		clientMock.AssertExpectations(t)
+		asserts.Error(err)
+		asserts.Nil(res)
+	}
+
+	// 返回正常
+	{
+		clientMock := ClientMock{}
+		clientMock.On(
+			"Request",
+			"GET",
+			"https://graph.microsoft.com/v1.0/sites/site/drives",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(&request.Response{
+			Err: nil,
+			Response: &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(strings.NewReader(`{"value":[{"id":"b!1","name":"Documents","driveType":"documentLibrary"},{"id":"b!2","name":"Archive","driveType":"documentLibrary"}]}`)),
+			},
+		})
+		client.Request = clientMock
+		res, err := client.GetSiteDrives(context.Background(), "site")
+		clientMock.AssertExpectations(t)
+		asserts.NoError(err)
+		asserts.Len(res, 2)
+		asserts.Equal("b!2", res[1].ID)
+		asserts.Equal("Archive", res[1].Name)
+	}
+}
+
+func TestNormalizeChunkSize(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.EqualValues(50<<20, normalizeChunkSize(50<<20))
+	asserts.EqualValues(9*chunkSizeUnit, normalizeChunkSize(3<<20))
+	asserts.EqualValues(maxChunkSize, normalizeChunkSize(100<<20))
+	asserts.EqualValues(chunkSizeUnit, normalizeChunkSize(1))
+}
+
 func TestClient_Meta(t *testing.T) {
 	asserts := assert.New(t)
 	client, _ := NewClient(&model.Policy{})
@@ -2,6 +2,8 @@ package onedrive
 
 import (
 	"errors"
+	"strings"
+
 	"github.com/cloudreve/Cloudreve/v3/pkg/cluster"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
@@ -59,7 +61,7 @@ func NewClient(policy *model.Policy) (*Client, error) {
 		ClientID:          policy.BucketName,
 		ClientSecret:      policy.SecretKey,
 		Redirect:          policy.OptionsSerialized.OauthRedirect,
-		Request:           request.NewClient(),
+		Request:           request.NewClient(request.WithProxy(policy.OptionsSerialized.Proxy)),
 		ClusterController: cluster.DefaultController,
 	}
 
@@ -75,3 +77,9 @@ func NewClient(policy *model.Policy) (*Client, error) {
 
 	return client, nil
 }
+
+// IsSharePoint 返回存储策略是否使用 SharePoint 站点的文档库
+func (client *Client) IsSharePoint() bool {
+	resource := client.Endpoints.DriverResource
+	return strings.HasPrefix(resource, "sites/") || strings.Contains(resource, "sharepoint.com") || strings.Contains(resource, "sharepoint.cn")
+}
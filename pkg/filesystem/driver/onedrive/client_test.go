@@ -30,3 +30,20 @@ func TestNewClient(t *testing.T) {
 		asserts.NotNil(res.Endpoints.OAuthEndpoints)
 	}
 }
+
+func TestClient_IsSharePoint(t *testing.T) {
+	asserts := assert.New(t)
+
+	for resource, expected := range map[string]bool{
+		"":                                  false,
+		"me/drive":                          false,
+		"drives/b!abc":                      false,
+		"sites/123/drive":                   true,
+		"sites/123/drives/b!abc":            true,
+		"https://example.sharepoint.com/s/": true,
+	} {
+		client, err := NewClient(&model.Policy{OptionsSerialized: model.PolicyOption{OdDriver: resource}})
+		asserts.NoError(err)
+		asserts.Equal(expected, client.IsSharePoint(), resource)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"path"
 	"path/filepath"
@@ -32,11 +33,12 @@ func NewDriver(policy *model.Policy) (driver.Handler, error) {
 	if policy.OptionsSerialized.ChunkSize == 0 {
 		policy.OptionsSerialized.ChunkSize = 50 << 20 // 50MB
 	}
+	policy.OptionsSerialized.ChunkSize = normalizeChunkSize(policy.OptionsSerialized.ChunkSize)
 
 	return Driver{
 		Policy:     policy,
 		Client:     client,
-		HTTPClient: request.NewClient(),
+		HTTPClient: request.NewClient(request.WithProxy(policy.OptionsSerialized.Proxy)),
 	}, err
 }
 
@@ -100,19 +102,20 @@ func (handler Driver) Get(ctx context.Context, path string) (response.RSCloser,
 	}
 
 	// 获取文件数据流
-	resp, err := handler.HTTPClient.Request(
-		"GET",
-		downloadURL,
-		nil,
-		request.WithContext(ctx),
-		request.WithTimeout(time.Duration(0)),
-	).CheckHTTPResponse(200).GetRSCloser()
+	resp, err := request.NewRangeRSCloser(func(header http.Header) *request.Response {
+		return handler.HTTPClient.Request(
+			"GET",
+			downloadURL,
+			nil,
+			request.WithContext(ctx),
+			request.WithHeader(header),
+			request.WithTimeout(time.Duration(0)),
+		)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	resp.SetFirstFakeChunk()
-
 	// 尝试自主获取文件大小
 	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
 		resp.SetContentLength(int64(file.Size))
@@ -236,3 +239,13 @@ func (handler Driver) Token(ctx context.Context, ttl int64, uploadSession *seria
 func (handler Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
 	return handler.Client.DeleteUploadSession(ctx, uploadSession.UploadURL)
 }
+
+// Capabilities 返回存储端支持的特性
+func (handler Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		RangeGet:        true,
+		PresignedUpload: true,
+		MultipartUpload: true,
+		Thumbnail:       true,
+	}
+}
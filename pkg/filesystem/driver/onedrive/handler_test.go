@@ -352,8 +352,9 @@ func TestDriver_Get(t *testing.T) {
 	).Return(&request.Response{
 		Err: nil,
 		Response: &http.Response{
-			StatusCode: 200,
-			Body:       ioutil.NopCloser(strings.NewReader(`123`)),
+			StatusCode:    200,
+			ContentLength: 3,
+			Body:          ioutil.NopCloser(strings.NewReader(`123`)),
 		},
 	})
 	handler.HTTPClient = driverClientMock
@@ -362,6 +363,8 @@ func TestDriver_Get(t *testing.T) {
 	asserts.NoError(err)
 	_, err = res.Seek(0, io.SeekEnd)
 	asserts.NoError(err)
+	_, err = res.Seek(0, io.SeekStart)
+	asserts.NoError(err)
 	content, err := ioutil.ReadAll(res)
 	asserts.NoError(err)
 	asserts.Equal("123", string(content))
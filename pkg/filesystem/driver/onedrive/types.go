@@ -130,6 +130,19 @@ type Site struct {
 	WebUrl      string `json:"webUrl"`
 }
 
+// Drive 驱动器（SharePoint 文档库）信息
+type Drive struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	DriveType string `json:"driveType"`
+	WebUrl    string `json:"webUrl"`
+}
+
+// DriveList 列取驱动器响应
+type DriveList struct {
+	Value []Drive `json:"value"`
+}
+
 func init() {
 	gob.Register(Credential{})
 }
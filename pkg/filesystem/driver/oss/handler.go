@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"path"
 	"path/filepath"
@@ -53,6 +54,10 @@ const (
 
 	// MultiPartUploadThreshold 服务端使用分片上传的阈值
 	MultiPartUploadThreshold uint64 = 5 * (1 << 30) // 5GB
+	// maxCopySize 单次复制请求支持的最大文件大小，超过时使用分片复制
+	maxCopySize uint64 = 1 << 30 // 1GB
+	// maxParts 分片上传的最大分片数
+	maxParts = 10000
 	// VersionID 文件版本标识
 	VersionID key = iota
 )
@@ -64,7 +69,7 @@ func NewDriver(policy *model.Policy) (*Driver, error) {
 
 	driver := &Driver{
 		Policy:     policy,
-		HTTPClient: request.NewClient(),
+		HTTPClient: request.NewClient(request.WithProxy(policy.OptionsSerialized.Proxy)),
 	}
 
 	return driver, driver.InitOSSClient(false)
@@ -102,7 +107,12 @@ func (handler *Driver) InitOSSClient(forceUsePublicEndpoint bool) error {
 	}
 
 	// 初始化客户端
-	client, err := oss.New(endpoint, handler.Policy.AccessKey, handler.Policy.SecretKey)
+	var options []oss.ClientOption
+	if proxy := handler.Policy.OptionsSerialized.Proxy; proxy != "" {
+		options = append(options, oss.HTTPClient(request.ProxyHTTPClient(proxy)))
+	}
+
+	client, err := oss.New(endpoint, handler.Policy.AccessKey, handler.Policy.SecretKey, options...)
 	if err != nil {
 		return err
 	}
@@ -200,19 +210,20 @@ func (handler *Driver) Get(ctx context.Context, path string) (response.RSCloser,
 	}
 
 	// 获取文件数据流
-	resp, err := handler.HTTPClient.Request(
-		"GET",
-		downloadURL,
-		nil,
-		request.WithContext(ctx),
-		request.WithTimeout(time.Duration(0)),
-	).CheckHTTPResponse(200).GetRSCloser()
+	resp, err := request.NewRangeRSCloser(func(header http.Header) *request.Response {
+		return handler.HTTPClient.Request(
+			"GET",
+			downloadURL,
+			nil,
+			request.WithContext(ctx),
+			request.WithHeader(header),
+			request.WithTimeout(time.Duration(0)),
+		)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	resp.SetFirstFakeChunk()
-
 	// 尝试自主获取文件大小
 	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
 		resp.SetContentLength(int64(file.Size))
@@ -235,38 +246,64 @@ func (handler *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
 		oss.Expires(time.Now().Add(time.Duration(credentialTTL) * time.Second)),
 		oss.ForbidOverWrite(!overwrite),
 	}
+	options = append(options, handler.storageClass(fileInfo)...)
 
 	// 小文件直接上传
-	if fileInfo.Size < MultiPartUploadThreshold {
+	chunkSize := handler.Policy.OptionsSerialized.ChunkSize
+	if fileInfo.Size < MultiPartUploadThreshold && (chunkSize == 0 || fileInfo.Size <= chunkSize) {
 		return handler.bucket.PutObject(fileInfo.SavePath, file, options...)
 	}
 
-	// 超过阈值时使用分片上传
+	// 超过分片大小时使用分片上传
 	imur, err := handler.bucket.InitiateMultipartUpload(fileInfo.SavePath, options...)
 	if err != nil {
 		return fmt.Errorf("failed to initiate multipart upload: %w", err)
 	}
 
-	chunks := chunk.NewChunkGroup(file, handler.Policy.OptionsSerialized.ChunkSize, &backoff.ConstantBackoff{
-		Max:   model.GetIntSetting("chunk_retries", 5),
-		Sleep: chunkRetrySleep,
-	}, model.IsTrueVal(model.GetSettingByName("use_temp_chunk_buffer")))
+	retries := model.GetIntSetting("chunk_retries", 5)
+	newBackoff := func() backoff.Backoff {
+		return &backoff.ConstantBackoff{Max: retries, Sleep: chunkRetrySleep}
+	}
 
-	uploadFunc := func(current *chunk.ChunkGroup, content io.Reader) error {
-		_, err := handler.bucket.UploadPart(imur, content, current.Length(), current.Index()+1)
+	uploadFunc := func(ctx context.Context, index int, content io.ReadSeeker, length int64) error {
+		_, err := handler.bucket.UploadPart(imur, content, length, index+1)
 		return err
 	}
 
-	for chunks.Next() {
-		if err := chunks.Process(uploadFunc); err != nil {
-			return fmt.Errorf("failed to upload chunk #%d: %w", chunks.Index(), err)
+	err = chunk.ProcessParallel(ctx, file, fileInfo.Size, chunkSize, handler.Policy.OptionsSerialized.UploadConcurrency,
+		model.IsTrueVal(model.GetSettingByName("use_temp_chunk_buffer")), newBackoff, uploadFunc)
+	if err != nil {
+		// 终止未完成的分片上传，释放已上传的分片
+		if abortErr := handler.bucket.AbortMultipartUpload(imur); abortErr != nil {
+			util.Log().Warning("Failed to abort multipart upload %q: %s", imur.UploadID, abortErr)
 		}
+
+		return err
 	}
 
 	_, err = handler.bucket.CompleteMultipartUpload(imur, oss.CompleteAll("yes"), oss.ForbidOverWrite(!overwrite))
 	return err
 }
 
+// Copy 在存储桶内将 src 复制到 dst 的存储路径
+func (handler *Driver) Copy(ctx context.Context, src string, dst fsctx.FileHeader) error {
+	fileInfo := dst.Info()
+	overwrite := fileInfo.Mode&fsctx.Overwrite == fsctx.Overwrite
+	options := append([]oss.Option{oss.ForbidOverWrite(!overwrite)}, handler.storageClass(fileInfo)...)
+	if fileInfo.Size <= maxCopySize {
+		_, err := handler.bucket.CopyObject(src, fileInfo.SavePath, options...)
+		return err
+	}
+
+	// 超过单次复制上限时使用分片复制，分片数不能超过上限
+	partSize := handler.Policy.OptionsSerialized.ChunkSize
+	if partSize*maxParts < fileInfo.Size {
+		partSize = (fileInfo.Size + maxParts - 1) / maxParts
+	}
+
+	return handler.bucket.CopyFile(handler.Policy.BucketName, src, fileInfo.SavePath, int64(partSize), options...)
+}
+
 // Delete 删除一个或多个文件，
 // 返回未删除的文件
 func (handler *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
@@ -437,6 +474,7 @@ func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *seri
 		oss.ForbidOverWrite(true),
 		oss.ContentType(fileInfo.DetectMimeType()),
 	}
+	options = append(options, handler.storageClass(fileInfo)...)
 	imur, err := handler.bucket.InitiateMultipartUpload(fileInfo.SavePath, options...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize multipart upload: %w", err)
@@ -485,7 +523,54 @@ func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *seri
 	}, nil
 }
 
+// storageClass 返回指定上传文件存储类型的选项
+func (handler *Driver) storageClass(fileInfo *fsctx.UploadTaskInfo) []oss.Option {
+	if class := handler.Policy.GetStorageClass(fileInfo.Metadata); class != "" {
+		return []oss.Option{oss.ObjectStorageClass(oss.StorageClassType(class))}
+	}
+
+	return nil
+}
+
+// RestoreStatus 返回文件是否处于需解冻的归档存储中，以及解冻是否已完成
+func (handler *Driver) RestoreStatus(ctx context.Context, path string) (bool, bool, error) {
+	header, err := handler.bucket.GetObjectDetailedMeta(path)
+	if err != nil {
+		return false, false, err
+	}
+
+	switch header.Get(oss.HTTPHeaderOssStorageClass) {
+	case string(oss.StorageArchive), "ColdArchive", "DeepColdArchive":
+		return true, strings.Contains(header.Get("X-Oss-Restore"), `ongoing-request="false"`), nil
+	}
+
+	return false, false, nil
+}
+
+// Restore 发起解冻请求，OSS 归档文件解冻后的保留天数由存储端决定
+func (handler *Driver) Restore(ctx context.Context, path string, days int) error {
+	err := handler.bucket.RestoreObject(path)
+
+	var serviceErr oss.ServiceError
+	if errors.As(err, &serviceErr) && serviceErr.Code == "RestoreAlreadyInProgress" {
+		return nil
+	}
+
+	return err
+}
+
 // 取消上传凭证
 func (handler *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
 	return handler.bucket.AbortMultipartUpload(oss.InitiateMultipartUploadResult{UploadID: uploadSession.UploadID, Key: uploadSession.SavePath}, nil)
 }
+
+// Capabilities 返回存储端支持的特性
+func (handler *Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		RangeGet:        true,
+		ServerSideCopy:  true,
+		PresignedUpload: true,
+		MultipartUpload: true,
+		Thumbnail:       true,
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/qiniu/go-sdk/v7/auth/qbox"
+	"github.com/qiniu/go-sdk/v7/client"
 	"github.com/qiniu/go-sdk/v7/storage"
 )
 
@@ -29,6 +30,8 @@ type Driver struct {
 	mac    *qbox.Mac
 	cfg    *storage.Config
 	bucket *storage.BucketManager
+	// client 配置代理时使用的 HTTP 客户端，为空时使用 SDK 默认客户端
+	client *client.Client
 }
 
 func NewDriver(policy *model.Policy) *Driver {
@@ -38,11 +41,17 @@ func NewDriver(policy *model.Policy) *Driver {
 
 	mac := qbox.NewMac(policy.AccessKey, policy.SecretKey)
 	cfg := &storage.Config{UseHTTPS: true}
+	var clt *client.Client
+	if policy.OptionsSerialized.Proxy != "" {
+		clt = &client.Client{Client: request.ProxyHTTPClient(policy.OptionsSerialized.Proxy)}
+	}
+
 	return &Driver{
 		Policy: policy,
 		mac:    mac,
 		cfg:    cfg,
-		bucket: storage.NewBucketManager(mac, cfg),
+		bucket: storage.NewBucketManagerEx(mac, cfg, clt),
+		client: clt,
 	}
 }
 
@@ -125,23 +134,24 @@ func (handler *Driver) Get(ctx context.Context, path string) (response.RSCloser,
 	}
 
 	// 获取文件数据流
-	client := request.NewClient()
-	resp, err := client.Request(
-		"GET",
-		downloadURL,
-		nil,
-		request.WithContext(ctx),
-		request.WithHeader(
-			http.Header{"Cache-Control": {"no-cache", "no-store", "must-revalidate"}},
-		),
-		request.WithTimeout(time.Duration(0)),
-	).CheckHTTPResponse(200).GetRSCloser()
+	client := request.NewClient(request.WithProxy(handler.Policy.OptionsSerialized.Proxy))
+	resp, err := request.NewRangeRSCloser(func(header http.Header) *request.Response {
+		return client.Request(
+			"GET",
+			downloadURL,
+			nil,
+			request.WithContext(ctx),
+			request.WithHeader(
+				http.Header{"Cache-Control": {"no-cache", "no-store", "must-revalidate"}},
+			),
+			request.WithHeader(header),
+			request.WithTimeout(time.Duration(0)),
+		)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	resp.SetFirstFakeChunk()
-
 	// 尝试自主获取文件大小
 	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
 		resp.SetContentLength(int64(file.Size))
@@ -184,7 +194,7 @@ func (handler *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
 
 	// 创建上传表单
 	cfg := storage.Config{}
-	formUploader := storage.NewFormUploader(&cfg)
+	formUploader := storage.NewFormUploaderEx(&cfg, handler.client)
 	ret := storage.PutRet{}
 	putExtra := storage.PutExtra{
 		Params: map[string]string{},
@@ -330,7 +340,7 @@ func (handler *Driver) getUploadCredential(ctx context.Context, policy storage.P
 	upToken := policy.UploadToken(handler.mac)
 
 	// 初始化分片上传
-	resumeUploader := storage.NewResumeUploaderV2(handler.cfg)
+	resumeUploader := storage.NewResumeUploaderV2Ex(handler.cfg, handler.client)
 	upHost, err := resumeUploader.UpHost(handler.Policy.AccessKey, handler.Policy.BucketName)
 	if err != nil {
 		return nil, err
@@ -349,6 +359,16 @@ func (handler *Driver) getUploadCredential(ctx context.Context, policy storage.P
 
 // 取消上传凭证
 func (handler Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
-	resumeUploader := storage.NewResumeUploaderV2(handler.cfg)
+	resumeUploader := storage.NewResumeUploaderV2Ex(handler.cfg, handler.client)
 	return resumeUploader.Client.CallWith(ctx, nil, "DELETE", uploadSession.UploadURL, http.Header{"Authorization": {"UpToken " + uploadSession.Credential}}, nil, 0)
 }
+
+// Capabilities 返回存储端支持的特性
+func (handler Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		RangeGet:        true,
+		PresignedUpload: true,
+		MultipartUpload: true,
+		Thumbnail:       true,
+	}
+}
@@ -24,7 +24,9 @@ import (
 const (
 	basePath        = "/api/v3/slave/"
 	OverwriteHeader = auth.CrHeaderPrefix + "Overwrite"
+	ChecksumHeader  = auth.CrHeaderPrefix + "Upload-Checksum"
 	chunkRetrySleep = time.Duration(5) * time.Second
+	pingTimeout     = time.Duration(10) * time.Second
 )
 
 // Client to operate uploading to remote slave server
@@ -35,8 +37,15 @@ type Client interface {
 	GetUploadURL(ttl int64, sessionID string) (string, string, error)
 	// Upload uploads file to remote server
 	Upload(ctx context.Context, file fsctx.FileHeader) error
+	// UploadAt writes content into remote upload session starting from offset,
+	// checksum is an optional tus Upload-Checksum verified by remote server
+	UploadAt(ctx context.Context, sessionID string, offset uint64, content io.Reader, size int64, checksum string) error
 	// DeleteUploadSession deletes remote upload session
 	DeleteUploadSession(ctx context.Context, sessionID string) error
+	// Ping checks whether remote server is reachable and accepts our credential
+	Ping(ctx context.Context) error
+	// Fetch asks remote server to download file from src and save it to file's save path
+	Fetch(ctx context.Context, src string, file fsctx.FileHeader) error
 }
 
 // NewClient creates new Client from given policy
@@ -57,6 +66,7 @@ func NewClient(policy *model.Policy) (Client, error) {
 			request.WithEndpoint(serverURL.ResolveReference(base).String()),
 			request.WithCredential(authInstance, int64(signTTL)),
 			request.WithMasterMeta(),
+			request.WithProxy(policy.OptionsSerialized.Proxy),
 			request.WithSlaveMeta(policy.AccessKey),
 		),
 	}, nil
@@ -111,6 +121,32 @@ func (c *remoteClient) Upload(ctx context.Context, file fsctx.FileHeader) error
 	return nil
 }
 
+func (c *remoteClient) UploadAt(ctx context.Context, sessionID string, offset uint64, content io.Reader, size int64, checksum string) error {
+	headers := map[string][]string{OverwriteHeader: {fmt.Sprintf("%t", offset > 0)}}
+	if checksum != "" {
+		headers[ChecksumHeader] = []string{checksum}
+	}
+
+	resp, err := c.httpClient.Request(
+		"POST",
+		fmt.Sprintf("upload/%s?offset=%d", sessionID, offset),
+		content,
+		request.WithContext(ctx),
+		request.WithTimeout(time.Duration(0)),
+		request.WithContentLength(size),
+		request.WithHeader(headers),
+	).CheckHTTPResponse(200).DecodeResponse()
+	if err != nil {
+		return err
+	}
+
+	if resp.Code != 0 {
+		return serializer.NewErrorFromResponse(resp)
+	}
+
+	return nil
+}
+
 func (c *remoteClient) DeleteUploadSession(ctx context.Context, sessionID string) error {
 	resp, err := c.httpClient.Request(
 		"DELETE",
@@ -129,6 +165,56 @@ func (c *remoteClient) DeleteUploadSession(ctx context.Context, sessionID string
 	return nil
 }
 
+func (c *remoteClient) Ping(ctx context.Context) error {
+	resp, err := c.httpClient.Request(
+		"GET",
+		"health",
+		nil,
+		request.WithContext(ctx),
+		request.WithTimeout(pingTimeout),
+	).CheckHTTPResponse(200).DecodeResponse()
+	if err != nil {
+		return err
+	}
+
+	if resp.Code != 0 {
+		return serializer.NewErrorFromResponse(resp)
+	}
+
+	return nil
+}
+
+func (c *remoteClient) Fetch(ctx context.Context, src string, file fsctx.FileHeader) error {
+	fileInfo := file.Info()
+	reqBodyEncoded, err := json.Marshal(map[string]interface{}{
+		"src":       src,
+		"dst":       fileInfo.SavePath,
+		"size":      fileInfo.Size,
+		"overwrite": fileInfo.Mode&fsctx.Overwrite == fsctx.Overwrite,
+	})
+	if err != nil {
+		return err
+	}
+
+	bodyReader := strings.NewReader(string(reqBodyEncoded))
+	resp, err := c.httpClient.Request(
+		"POST",
+		"fetch",
+		bodyReader,
+		request.WithContext(ctx),
+		request.WithTimeout(time.Duration(0)),
+	).CheckHTTPResponse(200).DecodeResponse()
+	if err != nil {
+		return err
+	}
+
+	if resp.Code != 0 {
+		return serializer.NewErrorFromResponse(resp)
+	}
+
+	return nil
+}
+
 func (c *remoteClient) CreateUploadSession(ctx context.Context, session *serializer.UploadSession, ttl int64, overwrite bool) error {
 	reqBodyEncoded, err := json.Marshal(map[string]interface{}{
 		"session":   session,
@@ -8,8 +8,10 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/mocks/requestmock"
 	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/stretchr/testify/assert"
 	testMock "github.com/stretchr/testify/mock"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
@@ -238,6 +240,55 @@ func TestRemoteClient_UploadChunkFailed(t *testing.T) {
 	clientMock.AssertExpectations(t)
 }
 
+func TestRemoteClient_UploadAt(t *testing.T) {
+	a := assert.New(t)
+	c, _ := NewClient(&model.Policy{})
+
+	// 从机返回错误
+	{
+		clientMock := requestmock.RequestMock{}
+		c.(*remoteClient).httpClient = &clientMock
+		clientMock.On(
+			"Request",
+			"POST",
+			"upload/1?offset=5",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(&request.Response{
+			Err: nil,
+			Response: &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(strings.NewReader(`{"code":40072,"msg":"error"}`)),
+			},
+		})
+		err := c.UploadAt(context.Background(), "1", 5, strings.NewReader("123"), 3, "md5 ICy5YqxZB1uWSwcVLSNLcA==")
+		a.Error(err)
+		a.Equal(serializer.CodeChecksumMismatch, err.(serializer.AppError).Code)
+		clientMock.AssertExpectations(t)
+	}
+
+	// 成功
+	{
+		clientMock := requestmock.RequestMock{}
+		c.(*remoteClient).httpClient = &clientMock
+		clientMock.On(
+			"Request",
+			"POST",
+			"upload/1?offset=0",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(&request.Response{
+			Err: nil,
+			Response: &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(strings.NewReader(`{"code":0}`)),
+			},
+		})
+		a.NoError(c.UploadAt(context.Background(), "1", 0, strings.NewReader("123"), 3, ""))
+		clientMock.AssertExpectations(t)
+	}
+}
+
 func TestRemoteClient_GetUploadURL(t *testing.T) {
 	a := assert.New(t)
 	c, _ := NewClient(&model.Policy{})
@@ -260,3 +311,131 @@ func TestRemoteClient_GetUploadURL(t *testing.T) {
 		a.NotEmpty(sign)
 	}
 }
+
+func TestRemoteClient_Ping(t *testing.T) {
+	a := assert.New(t)
+	c, _ := NewClient(&model.Policy{})
+
+	// 请求失败
+	{
+		clientMock := requestmock.RequestMock{}
+		c.(*remoteClient).httpClient = &clientMock
+		clientMock.On(
+			"Request",
+			"GET",
+			"health",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(&request.Response{
+			Err: errors.New("error"),
+		})
+		a.Error(c.Ping(context.Background()))
+		clientMock.AssertExpectations(t)
+	}
+
+	// 从机返回错误
+	{
+		clientMock := requestmock.RequestMock{}
+		c.(*remoteClient).httpClient = &clientMock
+		clientMock.On(
+			"Request",
+			"GET",
+			"health",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(&request.Response{
+			Response: &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(strings.NewReader(`{"code":40001}`)),
+			},
+		})
+		a.Error(c.Ping(context.Background()))
+		clientMock.AssertExpectations(t)
+	}
+
+	// 成功
+	{
+		clientMock := requestmock.RequestMock{}
+		c.(*remoteClient).httpClient = &clientMock
+		clientMock.On(
+			"Request",
+			"GET",
+			"health",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(&request.Response{
+			Response: &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(strings.NewReader(`{"code":0}`)),
+			},
+		})
+		a.NoError(c.Ping(context.Background()))
+		clientMock.AssertExpectations(t)
+	}
+}
+
+func TestRemoteClient_Fetch(t *testing.T) {
+	a := assert.New(t)
+	c, _ := NewClient(&model.Policy{})
+	file := &fsctx.FileStream{SavePath: "dst", Size: 10, Mode: fsctx.Overwrite}
+
+	// 请求失败
+	{
+		clientMock := requestmock.RequestMock{}
+		c.(*remoteClient).httpClient = &clientMock
+		clientMock.On(
+			"Request",
+			"POST",
+			"fetch",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(&request.Response{
+			Err: errors.New("error"),
+		})
+		a.Error(c.Fetch(context.Background(), "http://src.com/file", file))
+		clientMock.AssertExpectations(t)
+	}
+
+	// 从机返回错误
+	{
+		clientMock := requestmock.RequestMock{}
+		c.(*remoteClient).httpClient = &clientMock
+		clientMock.On(
+			"Request",
+			"POST",
+			"fetch",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(&request.Response{
+			Response: &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(strings.NewReader(`{"code":40001}`)),
+			},
+		})
+		a.Error(c.Fetch(context.Background(), "http://src.com/file", file))
+		clientMock.AssertExpectations(t)
+	}
+
+	// 成功
+	{
+		clientMock := requestmock.RequestMock{}
+		c.(*remoteClient).httpClient = &clientMock
+		clientMock.On(
+			"Request",
+			"POST",
+			"fetch",
+			testMock.MatchedBy(func(body io.Reader) bool {
+				content, _ := ioutil.ReadAll(body)
+				return string(content) == `{"dst":"dst","overwrite":true,"size":10,"src":"http://src.com/file"}`
+			}),
+			testMock.Anything,
+		).Return(&request.Response{
+			Response: &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(strings.NewReader(`{"code":0}`)),
+			},
+		})
+		a.NoError(c.Fetch(context.Background(), "http://src.com/file", file))
+		clientMock.AssertExpectations(t)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"path"
 	"path/filepath"
@@ -41,7 +42,7 @@ func NewDriver(policy *model.Policy) (*Driver, error) {
 
 	return &Driver{
 		Policy:       policy,
-		Client:       request.NewClient(),
+		Client:       request.NewClient(request.WithProxy(policy.OptionsSerialized.Proxy)),
 		AuthInstance: auth.HMACAuth{[]byte(policy.SecretKey)},
 		uploadClient: client,
 	}, nil
@@ -117,33 +118,28 @@ func (handler *Driver) getAPIUrl(scope string, routes ...string) string {
 
 // Get 获取文件内容
 func (handler *Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
-	// 尝试获取速度限制
-	speedLimit := 0
-	if user, ok := ctx.Value(fsctx.UserCtx).(model.User); ok {
-		speedLimit = user.Group.SpeedLimit
-	}
-
-	// 获取文件源地址
-	downloadURL, err := handler.Source(ctx, path, 0, true, speedLimit)
+	// 获取文件源地址，主从机之间的内部传输不限速，由主机在响应端限速
+	downloadURL, err := handler.Source(ctx, path, 0, true, 0)
 	if err != nil {
 		return nil, err
 	}
 
 	// 获取文件数据流
-	resp, err := handler.Client.Request(
-		"GET",
-		downloadURL,
-		nil,
-		request.WithContext(ctx),
-		request.WithTimeout(time.Duration(0)),
-		request.WithMasterMeta(),
-	).CheckHTTPResponse(200).GetRSCloser()
+	resp, err := request.NewRangeRSCloser(func(header http.Header) *request.Response {
+		return handler.Client.Request(
+			"GET",
+			downloadURL,
+			nil,
+			request.WithContext(ctx),
+			request.WithHeader(header),
+			request.WithTimeout(time.Duration(0)),
+			request.WithMasterMeta(),
+		)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	resp.SetFirstFakeChunk()
-
 	// 尝试获取文件大小
 	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
 		resp.SetContentLength(int64(file.Size))
@@ -159,6 +155,11 @@ func (handler *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
 	return handler.uploadClient.Upload(ctx, file)
 }
 
+// Fetch 由从机直接从 src 下载文件并保存至存储路径，文件内容不经由主机中转
+func (handler *Driver) Fetch(ctx context.Context, src string, file fsctx.FileHeader) error {
+	return handler.uploadClient.Fetch(ctx, src, file)
+}
+
 // Delete 删除一个或多个文件，
 // 返回未删除的文件，及遇到的最后一个错误
 func (handler *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
@@ -232,6 +233,37 @@ func (handler *Driver) Thumb(ctx context.Context, file *model.File) (*response.C
 	}, nil
 }
 
+// GenerateThumb 在存放文件的从机上生成缩略图，保存为 sidecar 文件，主机无需拉取原文件
+func (handler *Driver) GenerateThumb(ctx context.Context, file *model.File) error {
+	reqBody, err := json.Marshal(serializer.RemoteThumbRequest{
+		Src:  file.SourceName,
+		Name: file.Name,
+		Size: file.Size,
+	})
+	if err != nil {
+		return err
+	}
+
+	signTTL := model.GetIntSetting("slave_api_timeout", 60)
+	resp, err := handler.Client.Request(
+		"POST",
+		handler.getAPIUrl("thumb"),
+		strings.NewReader(string(reqBody)),
+		request.WithContext(ctx),
+		request.WithCredential(handler.AuthInstance, int64(signTTL)),
+		request.WithMasterMeta(),
+	).CheckHTTPResponse(200).DecodeResponse()
+	if err != nil {
+		return err
+	}
+
+	if resp.Code != 0 {
+		return serializer.NewErrorFromResponse(resp)
+	}
+
+	return nil
+}
+
 // Source 获取外链URL
 func (handler *Driver) Source(ctx context.Context, path string, ttl int64, isDownload bool, speed int) (string, error) {
 	// 尝试从上下文获取文件名
@@ -309,3 +341,13 @@ func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *seri
 func (handler *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
 	return handler.uploadClient.DeleteUploadSession(ctx, uploadSession.Key)
 }
+
+// Capabilities 返回存储端支持的特性
+func (handler *Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		RangeGet:        true,
+		PresignedUpload: true,
+		MultipartUpload: true,
+		Thumbnail:       true,
+	}
+}
@@ -366,6 +366,20 @@ func TestHandler_Put(t *testing.T) {
 	clientMock.AssertExpectations(t)
 }
 
+func TestHandler_Fetch(t *testing.T) {
+	a := assert.New(t)
+	handler, _ := NewDriver(&model.Policy{
+		Type:      "remote",
+		SecretKey: "test",
+		Server:    "http://test.com",
+	})
+	clientMock := &remoteclientmock.RemoteClientMock{}
+	handler.uploadClient = clientMock
+	clientMock.On("Fetch", testMock.Anything, "http://src.com/file", testMock.Anything).Return(errors.New("error"))
+	a.Error(handler.Fetch(context.Background(), "http://src.com/file", &fsctx.FileStream{}))
+	clientMock.AssertExpectations(t)
+}
+
 func TestHandler_Thumb(t *testing.T) {
 	asserts := assert.New(t)
 	handler := Driver{
@@ -402,6 +416,65 @@ func TestHandler_Thumb(t *testing.T) {
 	}
 }
 
+func TestHandler_GenerateThumb(t *testing.T) {
+	asserts := assert.New(t)
+	handler := Driver{
+		Policy: &model.Policy{
+			SecretKey: "test",
+			Server:    "http://test.com",
+		},
+		AuthInstance: auth.HMACAuth{},
+	}
+	file := &model.File{
+		Name:       "1.mp4",
+		SourceName: "1.mp4",
+	}
+	ctx := context.Background()
+	cache.Set("setting_slave_api_timeout", "60", 0)
+
+	// 成功
+	{
+		clientMock := &ClientMock{}
+		clientMock.On(
+			"Request",
+			"POST",
+			"http://test.com/api/v3/slave/thumb",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(&request.Response{
+			Err: nil,
+			Response: &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(strings.NewReader(`{"code":0}`)),
+			},
+		})
+		handler.Client = clientMock
+		asserts.NoError(handler.GenerateThumb(ctx, file))
+		clientMock.AssertExpectations(t)
+	}
+
+	// 从机生成失败
+	{
+		clientMock := &ClientMock{}
+		clientMock.On(
+			"Request",
+			"POST",
+			"http://test.com/api/v3/slave/thumb",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(&request.Response{
+			Err: nil,
+			Response: &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(strings.NewReader(`{"code":-1,"error":"not available"}`)),
+			},
+		})
+		handler.Client = clientMock
+		asserts.Error(handler.GenerateThumb(ctx, file))
+		clientMock.AssertExpectations(t)
+	}
+}
+
 func TestHandler_Token(t *testing.T) {
 	a := assert.New(t)
 	handler, _ := NewDriver(&model.Policy{})
@@ -0,0 +1,84 @@
+package remote
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// Health 从机存储策略的健康状态
+type Health struct {
+	Online    bool      `json:"online"`
+	Latency   int64     `json:"latency"`  // 最近一次探测的延迟，单位毫秒
+	Failures  int       `json:"failures"` // 连续探测失败的次数
+	LastCheck time.Time `json:"last_check"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+var (
+	healthLock   sync.RWMutex
+	healthStatus = make(map[uint]Health)
+)
+
+// GetHealth 获取存储策略最近一次探测的健康状态
+func GetHealth(policyID uint) (Health, bool) {
+	healthLock.RLock()
+	defer healthLock.RUnlock()
+
+	health, ok := healthStatus[policyID]
+	return health, ok
+}
+
+// IsAvailable 返回存储策略的从机是否可用，尚未探测过的存储策略视为可用
+func IsAvailable(policyID uint) bool {
+	health, ok := GetHealth(policyID)
+	return !ok || health.Online
+}
+
+// Probe 探测存储策略的从机并记录健康状态，连续失败次数达到
+// slave_node_retry 后标记为离线
+func Probe(ctx context.Context, policy *model.Policy) Health {
+	start := time.Now()
+	client, err := NewClient(policy)
+	if err == nil {
+		err = client.Ping(ctx)
+	}
+
+	return recordProbe(policy, time.Since(start), err)
+}
+
+// recordProbe 记录一次探测的结果
+func recordProbe(policy *model.Policy, latency time.Duration, err error) Health {
+	healthLock.Lock()
+	defer healthLock.Unlock()
+
+	health, ok := healthStatus[policy.ID]
+	if !ok {
+		health.Online = true
+	}
+
+	health.LastCheck = time.Now()
+	health.Latency = latency.Milliseconds()
+	if err == nil {
+		if !health.Online {
+			util.Log().Info("Slave node of policy %q recovered.", policy.Name)
+		}
+
+		health.Online = true
+		health.Failures = 0
+		health.LastError = ""
+	} else {
+		health.Failures++
+		health.LastError = err.Error()
+		if health.Online && health.Failures >= model.GetIntSetting("slave_node_retry", 3) {
+			util.Log().Warning("Slave node of policy %q is offline: %s", policy.Name, err)
+			health.Online = false
+		}
+	}
+
+	healthStatus[policy.ID] = health
+	return health
+}
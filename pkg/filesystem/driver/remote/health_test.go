@@ -0,0 +1,52 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordProbe(t *testing.T) {
+	a := assert.New(t)
+	cache.Set("setting_slave_node_retry", "2", 0)
+	policy := &model.Policy{Model: gorm.Model{ID: 101}}
+
+	// 尚未探测
+	_, ok := GetHealth(policy.ID)
+	a.False(ok)
+	a.True(IsAvailable(policy.ID))
+
+	// 失败次数未达到阈值
+	health := recordProbe(policy, 0, errors.New("error"))
+	a.True(health.Online)
+	a.Equal(1, health.Failures)
+	a.Equal("error", health.LastError)
+	a.True(IsAvailable(policy.ID))
+
+	// 标记为离线
+	health = recordProbe(policy, 0, errors.New("error"))
+	a.False(health.Online)
+	a.False(IsAvailable(policy.ID))
+
+	// 恢复
+	health = recordProbe(policy, 1500000, nil)
+	a.True(health.Online)
+	a.Equal(0, health.Failures)
+	a.Empty(health.LastError)
+	a.True(IsAvailable(policy.ID))
+}
+
+func TestProbe(t *testing.T) {
+	a := assert.New(t)
+	cache.Set("setting_slave_node_retry", "1", 0)
+
+	// 无法解析服务端url
+	health := Probe(context.Background(), &model.Policy{Model: gorm.Model{ID: 102}, Server: string([]byte{0x7f})})
+	a.False(health.Online)
+	a.NotEmpty(health.LastError)
+}
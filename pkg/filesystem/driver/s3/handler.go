@@ -18,6 +18,7 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -42,6 +43,17 @@ type UploadPolicy struct {
 	Conditions []interface{} `json:"conditions"`
 }
 
+const (
+	// defaultRegion 未设定区域时使用的区域，MinIO、Ceph RGW 等兼容服务通常接受此区域
+	defaultRegion = "us-east-1"
+	// maxPresignExpiry 签名 V4 预签名地址的最长有效期
+	maxPresignExpiry = 7 * 24 * time.Hour
+	// maxCopySize 单次复制请求支持的最大文件大小，超过时使用分片复制
+	maxCopySize uint64 = 5 << 30 // 5GB
+	// maxParts 分片上传的最大分片数
+	maxParts = 10000
+)
+
 // MetaData 文件信息
 type MetaData struct {
 	Size uint64
@@ -67,12 +79,18 @@ func (handler *Driver) InitS3Client() error {
 	}
 
 	if handler.svc == nil {
+		region := handler.Policy.OptionsSerialized.Region
+		if region == "" {
+			region = defaultRegion
+		}
+
 		// 初始化会话
 		sess, err := session.NewSession(&aws.Config{
 			Credentials:      credentials.NewStaticCredentials(handler.Policy.AccessKey, handler.Policy.SecretKey, ""),
 			Endpoint:         &handler.Policy.Server,
-			Region:           &handler.Policy.OptionsSerialized.Region,
+			Region:           &region,
 			S3ForcePathStyle: &handler.Policy.OptionsSerialized.S3ForcePathStyle,
+			HTTPClient:       request.ProxyHTTPClient(handler.Policy.OptionsSerialized.Proxy),
 		})
 
 		if err != nil {
@@ -170,23 +188,24 @@ func (handler *Driver) Get(ctx context.Context, path string) (response.RSCloser,
 	}
 
 	// 获取文件数据流
-	client := request.NewClient()
-	resp, err := client.Request(
-		"GET",
-		downloadURL,
-		nil,
-		request.WithContext(ctx),
-		request.WithHeader(
-			http.Header{"Cache-Control": {"no-cache", "no-store", "must-revalidate"}},
-		),
-		request.WithTimeout(time.Duration(0)),
-	).CheckHTTPResponse(200).GetRSCloser()
+	client := request.NewClient(request.WithProxy(handler.Policy.OptionsSerialized.Proxy))
+	resp, err := request.NewRangeRSCloser(func(header http.Header) *request.Response {
+		return client.Request(
+			"GET",
+			downloadURL,
+			nil,
+			request.WithContext(ctx),
+			request.WithHeader(
+				http.Header{"Cache-Control": {"no-cache", "no-store", "must-revalidate"}},
+			),
+			request.WithHeader(header),
+			request.WithTimeout(time.Duration(0)),
+		)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	resp.SetFirstFakeChunk()
-
 	// 尝试自主获取文件大小
 	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
 		resp.SetContentLength(int64(file.Size))
@@ -206,16 +225,116 @@ func (handler *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
 
 	uploader := s3manager.NewUploader(handler.sess, func(u *s3manager.Uploader) {
 		u.PartSize = int64(handler.Policy.OptionsSerialized.ChunkSize)
+		if handler.Policy.OptionsSerialized.UploadConcurrency > 0 {
+			u.Concurrency = handler.Policy.OptionsSerialized.UploadConcurrency
+		}
 	})
 
+	// 上传失败或取消时 uploader 会自动终止未完成的分片上传
 	dst := file.Info().SavePath
-	_, err := uploader.Upload(&s3manager.UploadInput{
-		Bucket: &handler.Policy.BucketName,
-		Key:    &dst,
-		Body:   io.LimitReader(file, int64(file.Info().Size)),
+	sse, kmsKeyID := handler.sse()
+	_, err := uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket:               &handler.Policy.BucketName,
+		Key:                  &dst,
+		Body:                 io.LimitReader(file, int64(file.Info().Size)),
+		ServerSideEncryption: sse,
+		SSEKMSKeyId:          kmsKeyID,
+		StorageClass:         handler.storageClass(file.Info()),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Copy 在存储桶内将 src 复制到 dst 的存储路径
+func (handler *Driver) Copy(ctx context.Context, src string, dst fsctx.FileHeader) error {
+	// 初始化客户端
+	if err := handler.InitS3Client(); err != nil {
+		return err
+	}
+
+	fileInfo := dst.Info()
+	copySource := (&url.URL{Path: handler.Policy.BucketName + "/" + src}).EscapedPath()
+	if fileInfo.Size > maxCopySize {
+		return handler.multipartCopy(ctx, copySource, fileInfo)
+	}
+
+	sse, kmsKeyID := handler.sse()
+	_, err := handler.svc.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+		Bucket:               &handler.Policy.BucketName,
+		Key:                  &fileInfo.SavePath,
+		CopySource:           &copySource,
+		ServerSideEncryption: sse,
+		SSEKMSKeyId:          kmsKeyID,
+		StorageClass:         handler.storageClass(fileInfo),
 	})
+	return err
+}
 
+// multipartCopy 按分片复制超过单次复制上限的文件
+func (handler *Driver) multipartCopy(ctx context.Context, copySource string, fileInfo *fsctx.UploadTaskInfo) error {
+	sse, kmsKeyID := handler.sse()
+	res, err := handler.svc.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:               &handler.Policy.BucketName,
+		Key:                  &fileInfo.SavePath,
+		ServerSideEncryption: sse,
+		SSEKMSKeyId:          kmsKeyID,
+		StorageClass:         handler.storageClass(fileInfo),
+	})
 	if err != nil {
+		return fmt.Errorf("failed to initiate multipart upload: %w", err)
+	}
+
+	// 分片数不能超过上限
+	partSize := handler.Policy.OptionsSerialized.ChunkSize
+	if partSize*maxParts < fileInfo.Size {
+		partSize = (fileInfo.Size + maxParts - 1) / maxParts
+	}
+
+	abort := func() {
+		if _, err := handler.svc.AbortMultipartUploadWithContext(context.Background(), &s3.AbortMultipartUploadInput{
+			Bucket:   &handler.Policy.BucketName,
+			Key:      &fileInfo.SavePath,
+			UploadId: res.UploadId,
+		}); err != nil {
+			util.Log().Warning("Failed to abort multipart upload %q: %s", *res.UploadId, err)
+		}
+	}
+
+	parts := make([]*s3.CompletedPart, 0, (fileInfo.Size+partSize-1)/partSize)
+	for offset := uint64(0); offset < fileInfo.Size; offset += partSize {
+		end := offset + partSize
+		if end > fileInfo.Size {
+			end = fileInfo.Size
+		}
+
+		partNumber := aws.Int64(int64(len(parts) + 1))
+		part, err := handler.svc.UploadPartCopyWithContext(ctx, &s3.UploadPartCopyInput{
+			Bucket:          &handler.Policy.BucketName,
+			Key:             &fileInfo.SavePath,
+			UploadId:        res.UploadId,
+			PartNumber:      partNumber,
+			CopySource:      &copySource,
+			CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", offset, end-1)),
+		})
+		if err != nil {
+			abort()
+			return fmt.Errorf("failed to copy part #%d: %w", *partNumber, err)
+		}
+
+		parts = append(parts, &s3.CompletedPart{ETag: part.CopyPartResult.ETag, PartNumber: partNumber})
+	}
+
+	if _, err := handler.svc.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          &handler.Policy.BucketName,
+		Key:             &fileInfo.SavePath,
+		UploadId:        res.UploadId,
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+	}); err != nil {
+		abort()
 		return err
 	}
 
@@ -287,7 +406,11 @@ func (handler *Driver) Source(ctx context.Context, path string, ttl int64, isDow
 			ResponseContentDisposition: contentDescription,
 		})
 
-	signedURL, err := req.Presign(time.Duration(ttl) * time.Second)
+	if handler.Policy.OptionsSerialized.S3PresignExpiry > 0 {
+		ttl = handler.Policy.OptionsSerialized.S3PresignExpiry
+	}
+
+	signedURL, err := req.Presign(presignExpiry(ttl))
 	if err != nil {
 		return "", err
 	}
@@ -323,13 +446,24 @@ func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *seri
 		return nil, fmt.Errorf("file already exist")
 	}
 
+	// 文件可一次上传完成时直接签名 PUT 请求，加密、存储类型头需由客户端携带，
+	// 指定这些属性时改为在创建分片上传时指定
+	sse, kmsKeyID := handler.sse()
+	storageClass := handler.storageClass(fileInfo)
+	if handler.Policy.OptionsSerialized.S3PresignedPut && sse == nil && storageClass == nil && fileInfo.Size <= handler.Policy.OptionsSerialized.ChunkSize {
+		return handler.presignedPut(ttl, uploadSession, fileInfo)
+	}
+
 	// 创建分片上传
 	expires := time.Now().Add(time.Duration(ttl) * time.Second)
 	res, err := handler.svc.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
-		Bucket:      &handler.Policy.BucketName,
-		Key:         &fileInfo.SavePath,
-		Expires:     &expires,
-		ContentType: aws.String(fileInfo.DetectMimeType()),
+		Bucket:               &handler.Policy.BucketName,
+		Key:                  &fileInfo.SavePath,
+		Expires:              &expires,
+		ContentType:          aws.String(fileInfo.DetectMimeType()),
+		ServerSideEncryption: sse,
+		SSEKMSKeyId:          kmsKeyID,
+		StorageClass:         storageClass,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create multipart upload: %w", err)
@@ -349,7 +483,7 @@ func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *seri
 				UploadId:   res.UploadId,
 			})
 
-			signedURL, err := signedReq.Presign(time.Duration(ttl) * time.Second)
+			signedURL, err := signedReq.Presign(presignExpiry(ttl))
 			if err != nil {
 				return err
 			}
@@ -369,7 +503,7 @@ func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *seri
 		UploadId: res.UploadId,
 	})
 
-	signedURL, err := signedReq.Presign(time.Duration(ttl) * time.Second)
+	signedURL, err := signedReq.Presign(presignExpiry(ttl))
 	if err != nil {
 		return nil, err
 	}
@@ -377,6 +511,7 @@ func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *seri
 	// 生成上传凭证
 	return &serializer.UploadCredential{
 		SessionID:   uploadSession.Key,
+		Mode:        serializer.UploadModeMultipart,
 		ChunkSize:   handler.Policy.OptionsSerialized.ChunkSize,
 		UploadID:    *res.UploadId,
 		UploadURLs:  urls,
@@ -384,6 +519,98 @@ func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *seri
 	}, nil
 }
 
+// presignedPut 签名直接上传文件的 PUT 请求，客户端需使用相同的 Content-Type 及 Content-Length，
+// 上传完成后由回调校验文件是否存在及大小
+func (handler *Driver) presignedPut(ttl int64, uploadSession *serializer.UploadSession, fileInfo *fsctx.UploadTaskInfo) (*serializer.UploadCredential, error) {
+	signedReq, _ := handler.svc.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:        &handler.Policy.BucketName,
+		Key:           &fileInfo.SavePath,
+		ContentType:   aws.String(fileInfo.DetectMimeType()),
+		ContentLength: aws.Int64(int64(fileInfo.Size)),
+	})
+
+	signedURL, err := signedReq.Presign(presignExpiry(ttl))
+	if err != nil {
+		return nil, err
+	}
+
+	return &serializer.UploadCredential{
+		SessionID:  uploadSession.Key,
+		Mode:       serializer.UploadModeSingle,
+		UploadURLs: []string{signedURL},
+	}, nil
+}
+
+// sse 返回上传时使用的服务端加密方式及 KMS 密钥 ID，未启用时均为 nil
+func (handler *Driver) sse() (*string, *string) {
+	sse := handler.Policy.OptionsSerialized.S3SSE
+	if sse == "" {
+		return nil, nil
+	}
+
+	if sse == s3.ServerSideEncryptionAwsKms && handler.Policy.OptionsSerialized.S3SSEKMSKeyID != "" {
+		return aws.String(sse), aws.String(handler.Policy.OptionsSerialized.S3SSEKMSKeyID)
+	}
+
+	return aws.String(sse), nil
+}
+
+// storageClass 返回上传文件使用的存储类型，未指定时为 nil
+func (handler *Driver) storageClass(fileInfo *fsctx.UploadTaskInfo) *string {
+	if class := handler.Policy.GetStorageClass(fileInfo.Metadata); class != "" {
+		return aws.String(class)
+	}
+
+	return nil
+}
+
+// RestoreStatus 返回文件是否处于需解冻的归档存储中，以及解冻是否已完成
+func (handler *Driver) RestoreStatus(ctx context.Context, path string) (bool, bool, error) {
+	res, err := handler.svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: &handler.Policy.BucketName,
+		Key:    &path,
+	})
+	if err != nil {
+		return false, false, err
+	}
+
+	// GLACIER_IR 等即时检索的存储类型无需解冻
+	class := aws.StringValue(res.StorageClass)
+	if class != s3.StorageClassGlacier && class != s3.StorageClassDeepArchive {
+		return false, false, nil
+	}
+
+	return true, strings.Contains(aws.StringValue(res.Restore), `ongoing-request="false"`), nil
+}
+
+// Restore 发起解冻请求
+func (handler *Driver) Restore(ctx context.Context, path string, days int) error {
+	_, err := handler.svc.RestoreObjectWithContext(ctx, &s3.RestoreObjectInput{
+		Bucket: &handler.Policy.BucketName,
+		Key:    &path,
+		RestoreRequest: &s3.RestoreRequest{
+			Days: aws.Int64(int64(days)),
+		},
+	})
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == "RestoreAlreadyInProgress" {
+		return nil
+	}
+
+	return err
+}
+
+// presignExpiry 将有效期限制在签名 V4 允许的范围内
+func presignExpiry(ttl int64) time.Duration {
+	expiry := time.Duration(ttl) * time.Second
+	if expiry > maxPresignExpiry {
+		return maxPresignExpiry
+	}
+
+	return expiry
+}
+
 // Meta 获取文件信息
 func (handler *Driver) Meta(ctx context.Context, path string) (*MetaData, error) {
 	res, err := handler.svc.HeadObject(
@@ -431,6 +658,11 @@ func (handler *Driver) CORS() error {
 
 // 取消上传凭证
 func (handler *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	// 直接上传的会话没有需要取消的分片上传
+	if uploadSession.UploadID == "" {
+		return nil
+	}
+
 	_, err := handler.svc.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
 		UploadId: &uploadSession.UploadID,
 		Bucket:   &handler.Policy.BucketName,
@@ -438,3 +670,13 @@ func (handler *Driver) CancelToken(ctx context.Context, uploadSession *serialize
 	})
 	return err
 }
+
+// Capabilities 返回存储端支持的特性
+func (handler *Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		RangeGet:        true,
+		ServerSideCopy:  true,
+		PresignedUpload: true,
+		MultipartUpload: true,
+	}
+}
@@ -0,0 +1,367 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 记录收到的请求并返回最简的 S3 响应
+type fakeS3 struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []string
+	head     http.Header // HEAD 请求返回的响应头，为 nil 时返回 404
+	restore  int         // 解冻请求返回的状态码
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	f.requests = append(f.requests, r)
+	f.bodies = append(f.bodies, string(body))
+	f.mu.Unlock()
+
+	switch {
+	case r.Method == "HEAD" && f.head != nil:
+		for k, v := range f.head {
+			w.Header()[k] = v
+		}
+	case r.Method == "HEAD":
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == "POST" && r.URL.Query().Has("restore"):
+		if f.restore == http.StatusConflict {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`<Error><Code>RestoreAlreadyInProgress</Code><Message>in progress</Message></Error>`))
+			return
+		}
+		w.WriteHeader(f.restore)
+	case r.Method == "POST" && r.URL.Query().Has("uploads"):
+		w.Write([]byte(`<InitiateMultipartUploadResult><Bucket>bucket</Bucket><Key>key</Key><UploadId>upload-id</UploadId></InitiateMultipartUploadResult>`))
+	case r.Method == "POST" && r.URL.Query().Has("uploadId"):
+		w.Write([]byte(`<CompleteMultipartUploadResult><Bucket>bucket</Bucket><Key>key</Key><ETag>"etag"</ETag></CompleteMultipartUploadResult>`))
+	case r.Method == "PUT" && r.Header.Get("X-Amz-Copy-Source") != "" && r.URL.Query().Has("partNumber"):
+		w.Write([]byte(`<CopyPartResult><ETag>"etag"</ETag></CopyPartResult>`))
+	case r.Method == "PUT" && r.Header.Get("X-Amz-Copy-Source") != "":
+		w.Write([]byte(`<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`))
+	default:
+		w.Header().Set("ETag", `"etag"`)
+	}
+}
+
+func newTestDriver(t *testing.T, server string, options model.PolicyOption) *Driver {
+	handler, err := NewDriver(&model.Policy{
+		Model:             gorm.Model{ID: 1},
+		Type:              "s3",
+		Server:            server,
+		BucketName:        "bucket",
+		AccessKey:         "ak",
+		SecretKey:         "sk",
+		IsPrivate:         true,
+		OptionsSerialized: options,
+	})
+	assert.NoError(t, err)
+	return handler
+}
+
+func TestDriver_InitS3Client(t *testing.T) {
+	a := assert.New(t)
+
+	// 未设定区域时使用默认区域
+	{
+		handler := newTestDriver(t, "http://127.0.0.1:9000", model.PolicyOption{})
+		a.Equal(defaultRegion, *handler.svc.Config.Region)
+	}
+
+	// 自定义区域
+	{
+		handler := newTestDriver(t, "http://127.0.0.1:9000", model.PolicyOption{Region: "cn-east-1"})
+		a.Equal("cn-east-1", *handler.svc.Config.Region)
+	}
+}
+
+func TestDriver_Source(t *testing.T) {
+	a := assert.New(t)
+
+	// 路径风格的自定义 Endpoint
+	{
+		handler := newTestDriver(t, "http://127.0.0.1:9000", model.PolicyOption{S3ForcePathStyle: true})
+		res, err := handler.Source(context.Background(), "dir/file.txt", 60, false, 0)
+		a.NoError(err)
+
+		u, err := url.Parse(res)
+		a.NoError(err)
+		a.Equal("127.0.0.1:9000", u.Host)
+		a.Equal("/bucket/dir/file.txt", u.Path)
+		a.Equal("AWS4-HMAC-SHA256", u.Query().Get("X-Amz-Algorithm"))
+		a.Equal("60", u.Query().Get("X-Amz-Expires"))
+		a.Contains(u.Query().Get("X-Amz-Credential"), "/"+defaultRegion+"/s3/")
+	}
+
+	// 虚拟主机风格
+	{
+		handler := newTestDriver(t, "http://s3.example.com", model.PolicyOption{})
+		res, err := handler.Source(context.Background(), "file.txt", 60, false, 0)
+		a.NoError(err)
+		a.True(strings.HasPrefix(res, "http://bucket.s3.example.com/file.txt?"))
+	}
+
+	// 存储策略设定的有效期
+	{
+		handler := newTestDriver(t, "http://127.0.0.1:9000", model.PolicyOption{S3PresignExpiry: 3600})
+		res, err := handler.Source(context.Background(), "file.txt", 60, false, 0)
+		a.NoError(err)
+		a.Contains(res, "X-Amz-Expires=3600")
+	}
+
+	// 超出签名 V4 的有效期上限
+	{
+		handler := newTestDriver(t, "http://127.0.0.1:9000", model.PolicyOption{})
+		res, err := handler.Source(context.Background(), "file.txt", 30*24*3600, false, 0)
+		a.NoError(err)
+		a.Contains(res, "X-Amz-Expires=604800")
+	}
+}
+
+func TestDriver_Put(t *testing.T) {
+	a := assert.New(t)
+
+	// SSE-S3
+	{
+		fake := &fakeS3{}
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		handler := newTestDriver(t, server.URL, model.PolicyOption{S3ForcePathStyle: true, S3SSE: "AES256"})
+		err := handler.Put(context.Background(), &fsctx.FileStream{
+			File:     io.NopCloser(strings.NewReader("content")),
+			Size:     7,
+			SavePath: "file.txt",
+		})
+		a.NoError(err)
+		a.Len(fake.requests, 1)
+		a.Equal("PUT", fake.requests[0].Method)
+		a.Equal("/bucket/file.txt", fake.requests[0].URL.Path)
+		a.Equal("AES256", fake.requests[0].Header.Get("X-Amz-Server-Side-Encryption"))
+		a.Empty(fake.requests[0].Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+		a.Equal("content", fake.bodies[0])
+	}
+
+	// SSE-KMS
+	{
+		fake := &fakeS3{}
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		handler := newTestDriver(t, server.URL, model.PolicyOption{S3ForcePathStyle: true, S3SSE: "aws:kms", S3SSEKMSKeyID: "key-id"})
+		err := handler.Put(context.Background(), &fsctx.FileStream{
+			File:     io.NopCloser(strings.NewReader("content")),
+			Size:     7,
+			SavePath: "file.txt",
+		})
+		a.NoError(err)
+		a.Len(fake.requests, 1)
+		a.Equal("aws:kms", fake.requests[0].Header.Get("X-Amz-Server-Side-Encryption"))
+		a.Equal("key-id", fake.requests[0].Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+	}
+
+	// 上传时指定存储类型
+	{
+		fake := &fakeS3{}
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		handler := newTestDriver(t, server.URL, model.PolicyOption{S3ForcePathStyle: true, StorageClass: "STANDARD_IA"})
+		err := handler.Put(context.Background(), &fsctx.FileStream{
+			File:     io.NopCloser(strings.NewReader("content")),
+			Size:     7,
+			SavePath: "file.txt",
+			Metadata: map[string]string{model.StorageClassMetadataKey: "GLACIER_IR"},
+		})
+		a.NoError(err)
+		a.Len(fake.requests, 1)
+		a.Equal("GLACIER_IR", fake.requests[0].Header.Get("X-Amz-Storage-Class"))
+	}
+}
+
+func TestDriver_RestoreStatus(t *testing.T) {
+	a := assert.New(t)
+	fake := &fakeS3{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	handler := newTestDriver(t, server.URL, model.PolicyOption{S3ForcePathStyle: true})
+
+	// 文件不存在
+	{
+		_, _, err := handler.RestoreStatus(context.Background(), "file.txt")
+		a.Error(err)
+	}
+
+	// 即时检索的存储类型
+	{
+		fake.head = http.Header{"X-Amz-Storage-Class": {"GLACIER_IR"}}
+		archived, _, err := handler.RestoreStatus(context.Background(), "file.txt")
+		a.NoError(err)
+		a.False(archived)
+	}
+
+	// 解冻中
+	{
+		fake.head = http.Header{"X-Amz-Storage-Class": {"GLACIER"}, "X-Amz-Restore": {`ongoing-request="true"`}}
+		archived, restored, err := handler.RestoreStatus(context.Background(), "file.txt")
+		a.NoError(err)
+		a.True(archived)
+		a.False(restored)
+	}
+
+	// 已解冻
+	{
+		fake.head = http.Header{"X-Amz-Storage-Class": {"DEEP_ARCHIVE"}, "X-Amz-Restore": {`ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`}}
+		archived, restored, err := handler.RestoreStatus(context.Background(), "file.txt")
+		a.NoError(err)
+		a.True(archived)
+		a.True(restored)
+	}
+}
+
+func TestDriver_Restore(t *testing.T) {
+	a := assert.New(t)
+	fake := &fakeS3{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	handler := newTestDriver(t, server.URL, model.PolicyOption{S3ForcePathStyle: true})
+
+	// 发起解冻
+	{
+		fake.restore = http.StatusAccepted
+		a.NoError(handler.Restore(context.Background(), "file.txt", 3))
+		a.Contains(fake.bodies[len(fake.bodies)-1], "<Days>3</Days>")
+	}
+
+	// 解冻进行中
+	{
+		fake.restore = http.StatusConflict
+		a.NoError(handler.Restore(context.Background(), "file.txt", 3))
+	}
+
+	// 其他错误
+	{
+		fake.restore = http.StatusForbidden
+		a.Error(handler.Restore(context.Background(), "file.txt", 3))
+	}
+}
+
+func TestDriver_Token(t *testing.T) {
+	a := assert.New(t)
+
+	// 未启用加密时直接签名 PUT 请求
+	{
+		fake := &fakeS3{}
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		handler := newTestDriver(t, server.URL, model.PolicyOption{S3ForcePathStyle: true, S3PresignedPut: true})
+		session := &serializer.UploadSession{Key: "session"}
+		res, err := handler.Token(context.Background(), 3600, session, &fsctx.FileStream{Size: 7, SavePath: "file.txt"})
+		a.NoError(err)
+		a.Equal(serializer.UploadModeSingle, res.Mode)
+		a.Empty(res.UploadID)
+		a.Len(res.UploadURLs, 1)
+		a.Contains(res.UploadURLs[0], "/bucket/file.txt?")
+		a.Len(fake.requests, 1)
+	}
+
+	// 启用加密时在创建分片上传时指定
+	{
+		fake := &fakeS3{}
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		handler := newTestDriver(t, server.URL, model.PolicyOption{S3ForcePathStyle: true, S3PresignedPut: true, S3SSE: "AES256", StorageClass: "GLACIER"})
+		session := &serializer.UploadSession{Key: "session"}
+		res, err := handler.Token(context.Background(), int64((30 * 24 * time.Hour).Seconds()), session, &fsctx.FileStream{Size: 7, SavePath: "file.txt"})
+		a.NoError(err)
+		a.Equal(serializer.UploadModeMultipart, res.Mode)
+		a.Equal("upload-id", res.UploadID)
+		a.Equal("upload-id", session.UploadID)
+		a.Len(res.UploadURLs, 1)
+		a.Contains(res.UploadURLs[0], "uploadId=upload-id")
+		a.Contains(res.UploadURLs[0], "X-Amz-Expires=604800")
+		a.Contains(res.CompleteURL, "uploadId=upload-id")
+		a.Len(fake.requests, 2)
+		a.Equal("POST", fake.requests[1].Method)
+		a.Equal("AES256", fake.requests[1].Header.Get("X-Amz-Server-Side-Encryption"))
+		a.Equal("GLACIER", fake.requests[1].Header.Get("X-Amz-Storage-Class"))
+	}
+}
+
+func TestDriver_presignedPut(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t, "http://127.0.0.1:9000", model.PolicyOption{S3ForcePathStyle: true, ChunkSize: 5 << 20})
+	session := &serializer.UploadSession{Key: "session"}
+
+	res, err := handler.presignedPut(3600, session, &fsctx.UploadTaskInfo{Size: 7, SavePath: "dir/file.txt"})
+	a.NoError(err)
+	a.Equal("session", res.SessionID)
+	a.Equal(serializer.UploadModeSingle, res.Mode)
+	a.Zero(res.ChunkSize)
+	a.Empty(res.UploadID)
+	a.Empty(res.CompleteURL)
+	a.Len(res.UploadURLs, 1)
+
+	signed, err := url.Parse(res.UploadURLs[0])
+	a.NoError(err)
+	a.Equal("/bucket/dir/file.txt", signed.Path)
+	a.Equal("3600", signed.Query().Get("X-Amz-Expires"))
+	a.Contains(signed.Query().Get("X-Amz-SignedHeaders"), "content-type")
+	a.Contains(signed.Query().Get("X-Amz-SignedHeaders"), "content-length")
+}
+
+func TestDriver_Copy(t *testing.T) {
+	a := assert.New(t)
+
+	// 单次复制
+	{
+		fake := &fakeS3{}
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		handler := newTestDriver(t, server.URL, model.PolicyOption{S3ForcePathStyle: true, S3SSE: "AES256", StorageClass: "GLACIER"})
+		err := handler.Copy(context.Background(), "dir/源 文件.txt", &fsctx.FileStream{Size: 7, SavePath: "dst.txt"})
+		a.NoError(err)
+		a.Len(fake.requests, 1)
+		a.Equal("PUT", fake.requests[0].Method)
+		a.Equal("/bucket/dst.txt", fake.requests[0].URL.Path)
+		a.Equal("bucket/dir/%E6%BA%90%20%E6%96%87%E4%BB%B6.txt", fake.requests[0].Header.Get("X-Amz-Copy-Source"))
+		a.Equal("AES256", fake.requests[0].Header.Get("X-Amz-Server-Side-Encryption"))
+		a.Equal("GLACIER", fake.requests[0].Header.Get("X-Amz-Storage-Class"))
+	}
+
+	// 超过单次复制上限时分片复制
+	{
+		fake := &fakeS3{}
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		handler := newTestDriver(t, server.URL, model.PolicyOption{S3ForcePathStyle: true, ChunkSize: 4 << 30})
+		err := handler.Copy(context.Background(), "src.txt", &fsctx.FileStream{Size: 6 << 30, SavePath: "dst.txt"})
+		a.NoError(err)
+		a.Len(fake.requests, 4)
+		a.Equal("bytes=0-4294967295", fake.requests[1].Header.Get("X-Amz-Copy-Source-Range"))
+		a.Equal("bytes=4294967296-6442450943", fake.requests[2].Header.Get("X-Amz-Copy-Source-Range"))
+		a.Equal("2", fake.requests[2].URL.Query().Get("partNumber"))
+		a.Contains(fake.bodies[3], "<PartNumber>2</PartNumber>")
+	}
+}
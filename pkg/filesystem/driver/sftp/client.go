@@ -0,0 +1,621 @@
+package sftp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// SFTP v3 协议，参见 draft-ietf-secsh-filexfer-02
+const (
+	protocolVersion = 3
+
+	fxpInit     = 1
+	fxpVersion  = 2
+	fxpOpen     = 3
+	fxpClose    = 4
+	fxpRead     = 5
+	fxpWrite    = 6
+	fxpFstat    = 8
+	fxpSetstat  = 9
+	fxpOpendir  = 11
+	fxpReaddir  = 12
+	fxpRemove   = 13
+	fxpMkdir    = 14
+	fxpStat     = 17
+	fxpRename   = 18
+	fxpStatus   = 101
+	fxpHandle   = 102
+	fxpData     = 103
+	fxpName     = 104
+	fxpAttrs    = 105
+	fxpExtended = 200
+
+	flagRead   = 0x01
+	flagWrite  = 0x02
+	flagCreate = 0x08
+	flagTrunc  = 0x10
+
+	attrSize        = 0x01
+	attrUIDGID      = 0x02
+	attrPermissions = 0x04
+	attrACModTime   = 0x08
+	attrExtended    = 0x80000000
+
+	statusOK         = 0
+	statusEOF        = 1
+	statusNoSuchFile = 2
+
+	// modeDir 文件权限中的目录类型位
+	modeDir = 0040000
+	// maxPacket 单个读写请求的数据长度
+	maxPacket = 32 * 1024
+	// posixRename OpenSSH 提供的可覆盖目标的重命名扩展
+	posixRename = "posix-rename@openssh.com"
+)
+
+var errUnexpectedPacket = errors.New("unexpected sftp packet")
+
+// StatusError SFTP 服务端返回的错误状态
+type StatusError struct {
+	Code uint32
+	Msg  string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("sftp: %q (code %d)", e.Msg, e.Code)
+}
+
+// Is 使文件不存在的错误可通过 errors.Is(err, os.ErrNotExist) 判断
+func (e *StatusError) Is(target error) bool {
+	return e.Code == statusNoSuchFile && target == os.ErrNotExist
+}
+
+// fileAttr 文件属性
+type fileAttr struct {
+	Size    uint64
+	Mode    uint32
+	ModTime time.Time
+}
+
+// IsDir 是否为目录
+func (a *fileAttr) IsDir() bool {
+	return a.Mode&modeDir == modeDir
+}
+
+// client SFTP 客户端，同一时间只处理一个请求
+type client struct {
+	mu     sync.Mutex
+	r      io.Reader
+	w      io.Writer
+	closer io.Closer
+	nextID uint32
+	exts   map[string]string
+	broken bool
+	// lastUsed 最后一次归还连接池的时间
+	lastUsed time.Time
+}
+
+// newClient 在已建立的 sftp 子系统数据流上完成协议握手
+func newClient(r io.Reader, w io.Writer, closer io.Closer) (*client, error) {
+	c := &client{r: r, w: w, closer: closer, exts: make(map[string]string)}
+	if err := c.writePacket(fxpInit, newBuffer().uint32(protocolVersion)); err != nil {
+		return nil, err
+	}
+
+	typ, data, err := c.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	if typ != fxpVersion {
+		return nil, errUnexpectedPacket
+	}
+
+	reader := &packetReader{data: data}
+	reader.uint32()
+	for len(reader.data) > 0 && reader.err == nil {
+		name, value := reader.string(), reader.string()
+		c.exts[name] = value
+	}
+
+	return c, reader.err
+}
+
+// Close 关闭连接
+func (c *client) Close() error {
+	c.broken = true
+	if c.closer != nil {
+		return c.closer.Close()
+	}
+	return nil
+}
+
+func (c *client) writePacket(typ byte, payload *buffer) error {
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header, uint32(len(payload.data)+1))
+	header[4] = typ
+	_, err := c.w.Write(append(header, payload.data...))
+	return err
+}
+
+func (c *client) readPacket() (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(c.r, header); err != nil {
+		return 0, nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header)
+	if length < 1 || length > 256*1024 {
+		return 0, nil, errUnexpectedPacket
+	}
+
+	data := make([]byte, length-1)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return 0, nil, err
+	}
+
+	return header[4], data, nil
+}
+
+// request 发送请求并等待响应，返回去除请求 ID 后的响应内容
+func (c *client) request(typ byte, build func(*buffer)) (byte, *packetReader, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.broken {
+		return 0, nil, io.ErrClosedPipe
+	}
+
+	c.nextID++
+	id := c.nextID
+	payload := newBuffer().uint32(id)
+	build(payload)
+
+	if err := c.writePacket(typ, payload); err != nil {
+		c.broken = true
+		return 0, nil, err
+	}
+
+	respType, data, err := c.readPacket()
+	if err != nil {
+		c.broken = true
+		return 0, nil, err
+	}
+
+	reader := &packetReader{data: data}
+	if reader.uint32() != id {
+		c.broken = true
+		return 0, nil, errUnexpectedPacket
+	}
+
+	return respType, reader, nil
+}
+
+// status 发送只需返回状态的请求
+func (c *client) status(typ byte, build func(*buffer)) error {
+	respType, reader, err := c.request(typ, build)
+	if err != nil {
+		return err
+	}
+
+	return reader.statusError(respType)
+}
+
+// Stat 获取文件属性
+func (c *client) Stat(p string) (*fileAttr, error) {
+	respType, reader, err := c.request(fxpStat, func(b *buffer) { b.string(p) })
+	if err != nil {
+		return nil, err
+	}
+	if respType != fxpAttrs {
+		return nil, reader.statusError(respType)
+	}
+
+	attr := reader.attr()
+	return attr, reader.err
+}
+
+// Exists 文件是否存在
+func (c *client) Exists(p string) (bool, error) {
+	_, err := c.Stat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Remove 删除文件
+func (c *client) Remove(p string) error {
+	return c.status(fxpRemove, func(b *buffer) { b.string(p) })
+}
+
+// Rename 重命名文件，目标已存在时将被覆盖
+func (c *client) Rename(src, dst string) error {
+	if _, ok := c.exts[posixRename]; ok {
+		return c.status(fxpExtended, func(b *buffer) { b.string(posixRename).string(src).string(dst) })
+	}
+
+	// 标准 RENAME 不允许覆盖已存在的目标
+	if err := c.Remove(dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return c.status(fxpRename, func(b *buffer) { b.string(src).string(dst) })
+}
+
+// Truncate 将文件截断至给定大小
+func (c *client) Truncate(p string, size uint64) error {
+	return c.status(fxpSetstat, func(b *buffer) { b.string(p).uint32(attrSize).uint64(size) })
+}
+
+// Mkdir 创建目录
+func (c *client) Mkdir(p string) error {
+	return c.status(fxpMkdir, func(b *buffer) { b.string(p).uint32(0) })
+}
+
+// MkdirAll 逐级创建目录
+func (c *client) MkdirAll(p string) error {
+	if p == "" || p == "." || p == "/" {
+		return nil
+	}
+
+	attr, err := c.Stat(p)
+	if err == nil {
+		if !attr.IsDir() {
+			return fmt.Errorf("%q is not a directory", p)
+		}
+		return nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := c.MkdirAll(parentDir(p)); err != nil {
+		return err
+	}
+
+	return c.Mkdir(p)
+}
+
+// dirEntry 目录中的条目
+type dirEntry struct {
+	Name string
+	Attr *fileAttr
+}
+
+// ReadDir 列出目录下的条目，不包含 "." 与 ".."
+func (c *client) ReadDir(p string) ([]dirEntry, error) {
+	handle, err := c.handle(fxpOpendir, func(b *buffer) { b.string(p) })
+	if err != nil {
+		return nil, err
+	}
+	defer c.closeHandle(handle)
+
+	var entries []dirEntry
+	for {
+		respType, reader, err := c.request(fxpReaddir, func(b *buffer) { b.string(handle) })
+		if err != nil {
+			return nil, err
+		}
+		if respType != fxpName {
+			if err := reader.statusError(respType); err != nil && err != io.EOF {
+				return nil, err
+			}
+			return entries, nil
+		}
+
+		count := reader.uint32()
+		for i := uint32(0); i < count && reader.err == nil; i++ {
+			name := reader.string()
+			reader.string() // longname
+			attr := reader.attr()
+			if name != "." && name != ".." {
+				entries = append(entries, dirEntry{Name: name, Attr: attr})
+			}
+		}
+
+		if reader.err != nil {
+			return nil, reader.err
+		}
+	}
+}
+
+// Open 以给定的标志打开文件
+func (c *client) Open(p string, flags uint32) (*remoteFile, error) {
+	handle, err := c.handle(fxpOpen, func(b *buffer) { b.string(p).uint32(flags).uint32(0) })
+	if err != nil {
+		return nil, err
+	}
+
+	return &remoteFile{client: c, handle: handle}, nil
+}
+
+func (c *client) handle(typ byte, build func(*buffer)) (string, error) {
+	respType, reader, err := c.request(typ, build)
+	if err != nil {
+		return "", err
+	}
+	if respType != fxpHandle {
+		return "", reader.statusError(respType)
+	}
+
+	handle := reader.string()
+	return handle, reader.err
+}
+
+func (c *client) closeHandle(handle string) error {
+	return c.status(fxpClose, func(b *buffer) { b.string(handle) })
+}
+
+// remoteFile 远程文件
+type remoteFile struct {
+	client *client
+	handle string
+	offset int64
+	// onClose 文件关闭后的回调，用于归还连接
+	onClose func()
+}
+
+// Read 从当前位置读取
+func (f *remoteFile) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// ReadAt 从给定位置读取
+func (f *remoteFile) ReadAt(p []byte, off int64) (int, error) {
+	read := 0
+	for read < len(p) {
+		length := len(p) - read
+		if length > maxPacket {
+			length = maxPacket
+		}
+
+		respType, reader, err := f.client.request(fxpRead, func(b *buffer) {
+			b.string(f.handle).uint64(uint64(off) + uint64(read)).uint32(uint32(length))
+		})
+		if err != nil {
+			return read, err
+		}
+		if respType != fxpData {
+			return read, reader.statusError(respType)
+		}
+
+		data := reader.bytes()
+		if reader.err != nil {
+			return read, reader.err
+		}
+		read += copy(p[read:], data)
+
+		// 短读时直接返回已读取的数据
+		if len(data) < length {
+			break
+		}
+	}
+
+	return read, nil
+}
+
+// WriteAt 写入到给定位置
+func (f *remoteFile) WriteAt(p []byte, off int64) (int, error) {
+	written := 0
+	for written < len(p) {
+		end := written + maxPacket
+		if end > len(p) {
+			end = len(p)
+		}
+
+		err := f.client.status(fxpWrite, func(b *buffer) {
+			b.string(f.handle).uint64(uint64(off) + uint64(written)).bytes(p[written:end])
+		})
+		if err != nil {
+			return written, err
+		}
+		written = end
+	}
+
+	return written, nil
+}
+
+// Write 写入到当前位置
+func (f *remoteFile) Write(p []byte) (int, error) {
+	n, err := f.WriteAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// Seek 设置读写位置
+func (f *remoteFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		attr, err := f.Stat()
+		if err != nil {
+			return f.offset, err
+		}
+		offset += int64(attr.Size)
+	default:
+		return f.offset, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return f.offset, errors.New("negative position")
+	}
+
+	f.offset = offset
+	return offset, nil
+}
+
+// Stat 获取已打开文件的属性
+func (f *remoteFile) Stat() (*fileAttr, error) {
+	respType, reader, err := f.client.request(fxpFstat, func(b *buffer) { b.string(f.handle) })
+	if err != nil {
+		return nil, err
+	}
+	if respType != fxpAttrs {
+		return nil, reader.statusError(respType)
+	}
+
+	attr := reader.attr()
+	return attr, reader.err
+}
+
+// Close 关闭文件
+func (f *remoteFile) Close() error {
+	err := f.client.closeHandle(f.handle)
+	if f.onClose != nil {
+		f.onClose()
+		f.onClose = nil
+	}
+
+	return err
+}
+
+// buffer 构建请求数据
+type buffer struct {
+	data []byte
+}
+
+func newBuffer() *buffer {
+	return &buffer{}
+}
+
+func (b *buffer) uint32(v uint32) *buffer {
+	var p [4]byte
+	binary.BigEndian.PutUint32(p[:], v)
+	b.data = append(b.data, p[:]...)
+	return b
+}
+
+func (b *buffer) uint64(v uint64) *buffer {
+	var p [8]byte
+	binary.BigEndian.PutUint64(p[:], v)
+	b.data = append(b.data, p[:]...)
+	return b
+}
+
+func (b *buffer) string(s string) *buffer {
+	return b.bytes([]byte(s))
+}
+
+func (b *buffer) bytes(p []byte) *buffer {
+	b.uint32(uint32(len(p)))
+	b.data = append(b.data, p...)
+	return b
+}
+
+// packetReader 解析响应数据，遇到的首个错误记录在 err 中
+type packetReader struct {
+	data []byte
+	err  error
+}
+
+func (r *packetReader) uint32() uint32 {
+	if len(r.data) < 4 {
+		r.err = errUnexpectedPacket
+		return 0
+	}
+
+	v := binary.BigEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return v
+}
+
+func (r *packetReader) uint64() uint64 {
+	if len(r.data) < 8 {
+		r.err = errUnexpectedPacket
+		return 0
+	}
+
+	v := binary.BigEndian.Uint64(r.data)
+	r.data = r.data[8:]
+	return v
+}
+
+func (r *packetReader) bytes() []byte {
+	length := r.uint32()
+	if uint32(len(r.data)) < length {
+		r.err = errUnexpectedPacket
+		return nil
+	}
+
+	v := r.data[:length]
+	r.data = r.data[length:]
+	return v
+}
+
+func (r *packetReader) string() string {
+	return string(r.bytes())
+}
+
+func (r *packetReader) attr() *fileAttr {
+	attr := &fileAttr{}
+	flags := r.uint32()
+	if flags&attrSize != 0 {
+		attr.Size = r.uint64()
+	}
+	if flags&attrUIDGID != 0 {
+		r.uint32()
+		r.uint32()
+	}
+	if flags&attrPermissions != 0 {
+		attr.Mode = r.uint32()
+	}
+	if flags&attrACModTime != 0 {
+		r.uint32()
+		attr.ModTime = time.Unix(int64(r.uint32()), 0)
+	}
+	if flags&attrExtended != 0 {
+		count := r.uint32()
+		for i := uint32(0); i < count && r.err == nil; i++ {
+			r.string()
+			r.string()
+		}
+	}
+
+	return attr
+}
+
+// statusError 将 STATUS 响应转换为错误，EOF 状态转换为 io.EOF
+func (r *packetReader) statusError(respType byte) error {
+	if respType != fxpStatus {
+		return errUnexpectedPacket
+	}
+
+	code := r.uint32()
+	msg := r.string()
+	if r.err != nil {
+		return r.err
+	}
+
+	switch code {
+	case statusOK:
+		return nil
+	case statusEOF:
+		return io.EOF
+	default:
+		return &StatusError{Code: code, Msg: msg}
+	}
+}
+
+// parentDir 返回远程路径的上级目录
+func parentDir(p string) string {
+	for i := len(p) - 1; i >= 0; i-- {
+		if p[i] == '/' {
+			if i == 0 {
+				return "/"
+			}
+			return p[:i]
+		}
+	}
+
+	return "."
+}
@@ -0,0 +1,425 @@
+package sftp
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+// fakeServer 以本地目录模拟的 SFTP 服务端
+type fakeServer struct {
+	root    string
+	handles map[string]*os.File
+	next    int
+}
+
+func (s *fakeServer) serve(r io.Reader, w io.Writer) {
+	for {
+		header := make([]byte, 5)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return
+		}
+		data := make([]byte, binary.BigEndian.Uint32(header)-1)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return
+		}
+
+		req := &packetReader{data: data}
+		if header[4] == fxpInit {
+			s.write(w, fxpVersion, newBuffer().uint32(protocolVersion).string(posixRename).string("1"))
+			continue
+		}
+
+		id := req.uint32()
+		typ, resp := s.handle(header[4], req)
+		s.write(w, typ, newBuffer().uint32(id).bytesRaw(resp.data))
+	}
+}
+
+func (s *fakeServer) write(w io.Writer, typ byte, b *buffer) {
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header, uint32(len(b.data)+1))
+	header[4] = typ
+	w.Write(append(header, b.data...))
+}
+
+func (s *fakeServer) local(p string) string {
+	return filepath.Join(s.root, filepath.FromSlash(p))
+}
+
+func status(err error) (byte, *buffer) {
+	code := uint32(statusOK)
+	if errors.Is(err, os.ErrNotExist) {
+		code = statusNoSuchFile
+	} else if err == io.EOF {
+		code = statusEOF
+	} else if err != nil {
+		code = 4
+	}
+
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	return fxpStatus, newBuffer().uint32(code).string(msg).string("")
+}
+
+func attrs(info os.FileInfo) *buffer {
+	mode := uint32(0100644)
+	if info.IsDir() {
+		mode = modeDir | 0755
+	}
+	return newBuffer().uint32(attrSize | attrPermissions | attrACModTime).
+		uint64(uint64(info.Size())).uint32(mode).uint32(0).uint32(uint32(info.ModTime().Unix()))
+}
+
+func (s *fakeServer) newHandle(f *os.File) (byte, *buffer) {
+	s.next++
+	handle := string(rune('a' + s.next))
+	s.handles[handle] = f
+	return fxpHandle, newBuffer().string(handle)
+}
+
+func (s *fakeServer) handle(typ byte, req *packetReader) (byte, *buffer) {
+	switch typ {
+	case fxpStat:
+		info, err := os.Stat(s.local(req.string()))
+		if err != nil {
+			return status(err)
+		}
+		return fxpAttrs, attrs(info)
+	case fxpOpen:
+		p, flags := req.string(), req.uint32()
+		mode := os.O_RDONLY
+		if flags&flagWrite != 0 {
+			mode = os.O_WRONLY
+		}
+		if flags&flagCreate != 0 {
+			mode |= os.O_CREATE
+		}
+		if flags&flagTrunc != 0 {
+			mode |= os.O_TRUNC
+		}
+		f, err := os.OpenFile(s.local(p), mode, 0644)
+		if err != nil {
+			return status(err)
+		}
+		return s.newHandle(f)
+	case fxpOpendir:
+		f, err := os.Open(s.local(req.string()))
+		if err != nil {
+			return status(err)
+		}
+		return s.newHandle(f)
+	case fxpReaddir:
+		infos, err := s.handles[req.string()].Readdir(0)
+		if len(infos) == 0 {
+			return status(io.EOF)
+		}
+		if err != nil {
+			return status(err)
+		}
+		b := newBuffer().uint32(uint32(len(infos)))
+		for _, info := range infos {
+			b.string(info.Name()).string(info.Name()).bytesRaw(attrs(info).data)
+		}
+		return fxpName, b
+	case fxpClose:
+		handle := req.string()
+		err := s.handles[handle].Close()
+		delete(s.handles, handle)
+		return status(err)
+	case fxpRead:
+		f, offset, length := s.handles[req.string()], req.uint64(), req.uint32()
+		buf := make([]byte, length)
+		n, err := f.ReadAt(buf, int64(offset))
+		if n == 0 {
+			return status(err)
+		}
+		return fxpData, newBuffer().bytes(buf[:n])
+	case fxpWrite:
+		f, offset, data := s.handles[req.string()], req.uint64(), req.bytes()
+		_, err := f.WriteAt(data, int64(offset))
+		return status(err)
+	case fxpFstat:
+		info, err := s.handles[req.string()].Stat()
+		if err != nil {
+			return status(err)
+		}
+		return fxpAttrs, attrs(info)
+	case fxpSetstat:
+		p := req.string()
+		req.uint32()
+		return status(os.Truncate(s.local(p), int64(req.uint64())))
+	case fxpRemove:
+		return status(os.Remove(s.local(req.string())))
+	case fxpMkdir:
+		return status(os.Mkdir(s.local(req.string()), 0755))
+	case fxpExtended:
+		req.string()
+		return status(os.Rename(s.local(req.string()), s.local(req.string())))
+	}
+
+	return status(errors.New("unsupported"))
+}
+
+func (b *buffer) bytesRaw(p []byte) *buffer {
+	b.data = append(b.data, p...)
+	return b
+}
+
+func newTestDriver(t *testing.T) (*Driver, string) {
+	root := t.TempDir()
+	handler := &Driver{
+		Policy: &model.Policy{Type: "sftp", BucketName: "."},
+		pool: &pool{dial: func() (*client, error) {
+			server := &fakeServer{root: root, handles: map[string]*os.File{}}
+			clientR, serverW := io.Pipe()
+			serverR, clientW := io.Pipe()
+			go server.serve(serverR, serverW)
+			return newClient(clientR, clientW, clientW)
+		}},
+	}
+
+	return handler, root
+}
+
+func TestNewDriver(t *testing.T) {
+	a := assert.New(t)
+	_, err := NewDriver(&model.Policy{})
+	a.Error(err)
+
+	policy := &model.Policy{Model: gorm.Model{ID: 1}, Server: "127.0.0.1"}
+	handler, err := NewDriver(policy)
+	a.NoError(err)
+	handler2, _ := NewDriver(policy)
+	a.Equal(handler.pool, handler2.pool)
+
+	// 连接信息变更后使用新的连接池
+	handler3, _ := NewDriver(&model.Policy{Model: gorm.Model{ID: 1}, Server: "127.0.0.2"})
+	a.NotEqual(handler.pool, handler3.pool)
+}
+
+func TestDriver_Put(t *testing.T) {
+	a := assert.New(t)
+	handler, root := newTestDriver(t)
+
+	// 完整上传
+	err := handler.Put(context.Background(), &fsctx.FileStream{
+		SavePath: "dir/sub/a.txt",
+		File:     io.NopCloser(strings.NewReader("content")),
+	})
+	a.NoError(err)
+	content, _ := os.ReadFile(filepath.Join(root, "dir", "sub", "a.txt"))
+	a.Equal("content", string(content))
+	a.NoFileExists(filepath.Join(root, "dir", "sub", "a.txt"+uploadTempSuffix))
+
+	// 重名冲突
+	err = handler.Put(context.Background(), &fsctx.FileStream{
+		SavePath: "dir/sub/a.txt",
+		File:     io.NopCloser(strings.NewReader("new")),
+	})
+	a.Equal(ErrFileExisted, err)
+
+	// 覆盖
+	err = handler.Put(context.Background(), &fsctx.FileStream{
+		SavePath: "dir/sub/a.txt",
+		Mode:     fsctx.Overwrite,
+		File:     io.NopCloser(strings.NewReader("new")),
+	})
+	a.NoError(err)
+	content, _ = os.ReadFile(filepath.Join(root, "dir", "sub", "a.txt"))
+	a.Equal("new", string(content))
+
+	// 上下文已取消
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = handler.Put(ctx, &fsctx.FileStream{
+		SavePath: "b.txt",
+		File:     io.NopCloser(strings.NewReader("content")),
+	})
+	a.ErrorIs(err, context.Canceled)
+	a.NoFileExists(filepath.Join(root, "b.txt"+uploadTempSuffix))
+}
+
+func TestDriver_ChunkUpload(t *testing.T) {
+	a := assert.New(t)
+	handler, root := newTestDriver(t)
+	dst := filepath.Join(root, "a.txt")
+	put := func(start uint64, data string) error {
+		mode := fsctx.Append
+		if start > 0 {
+			mode |= fsctx.Overwrite
+		}
+		return handler.Put(context.Background(), &fsctx.FileStream{
+			SavePath:    "a.txt",
+			Mode:        mode,
+			AppendStart: start,
+			File:        io.NopCloser(strings.NewReader(data)),
+		})
+	}
+
+	a.NoError(put(0, "123"))
+	a.Equal(ErrChunkOffset, put(6, "789"))
+	a.NoError(put(3, "xxx"))
+	// 重传分片
+	a.NoError(put(3, "456"))
+	a.NoFileExists(dst)
+
+	a.NoError(handler.CompleteUpload(context.Background(), "a.txt"))
+	content, _ := os.ReadFile(dst)
+	a.Equal("123456", string(content))
+
+	// 完成后校验失败，恢复为临时文件继续上传
+	a.NoError(handler.Truncate(context.Background(), "a.txt", 3))
+	a.NoFileExists(dst)
+	a.NoError(put(3, "abc"))
+	a.NoError(handler.CompleteUpload(context.Background(), "a.txt"))
+	content, _ = os.ReadFile(dst)
+	a.Equal("123abc", string(content))
+}
+
+func TestDriver_Get(t *testing.T) {
+	a := assert.New(t)
+	handler, root := newTestDriver(t)
+	a.NoError(os.WriteFile(filepath.Join(root, "a.txt"), []byte("0123456789"), 0644))
+
+	_, err := handler.Get(context.Background(), "not_exist.txt")
+	a.ErrorIs(err, os.ErrNotExist)
+
+	file, err := handler.Get(context.Background(), "a.txt")
+	a.NoError(err)
+	size, err := file.Seek(0, io.SeekEnd)
+	a.NoError(err)
+	a.EqualValues(10, size)
+	_, err = file.Seek(5, io.SeekStart)
+	a.NoError(err)
+	content, err := io.ReadAll(file)
+	a.NoError(err)
+	a.Equal("56789", string(content))
+	a.NoError(file.Close())
+
+	// 关闭后连接归还连接池
+	a.Len(handler.pool.idle, 1)
+}
+
+func TestDriver_Delete(t *testing.T) {
+	a := assert.New(t)
+	handler, root := newTestDriver(t)
+	a.NoError(os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0644))
+	a.NoError(os.WriteFile(filepath.Join(root, "b.txt"+uploadTempSuffix), []byte("b"), 0644))
+	a.NoError(os.Mkdir(filepath.Join(root, "dir"), 0755))
+	a.NoError(os.WriteFile(filepath.Join(root, "dir", "c.txt"), []byte("c"), 0644))
+
+	failed, err := handler.Delete(context.Background(), []string{"a.txt", "b.txt", "not_exist.txt", "dir"})
+	a.Error(err)
+	a.Equal([]string{"dir"}, failed)
+	a.NoFileExists(filepath.Join(root, "a.txt"))
+	a.NoFileExists(filepath.Join(root, "b.txt"+uploadTempSuffix))
+}
+
+func TestDriver_List(t *testing.T) {
+	a := assert.New(t)
+	handler, root := newTestDriver(t)
+	a.NoError(os.MkdirAll(filepath.Join(root, "dir", "sub"), 0755))
+	a.NoError(os.WriteFile(filepath.Join(root, "dir", "a.txt"), []byte("a"), 0644))
+	a.NoError(os.WriteFile(filepath.Join(root, "dir", "b.txt"+uploadTempSuffix), []byte("b"), 0644))
+	a.NoError(os.WriteFile(filepath.Join(root, "dir", "sub", "c.txt"), []byte("cc"), 0644))
+
+	res, err := handler.List(context.Background(), "/dir", false)
+	a.NoError(err)
+	a.Len(res, 2)
+
+	res, err = handler.List(context.Background(), "/dir", true)
+	a.NoError(err)
+	a.Len(res, 3)
+	for _, object := range res {
+		if object.Name == "c.txt" {
+			a.Equal("sub/c.txt", object.RelativePath)
+			a.Equal("dir/sub/c.txt", object.Source)
+			a.EqualValues(2, object.Size)
+		}
+		if object.Name == "sub" {
+			a.True(object.IsDir)
+		}
+	}
+}
+
+func TestDriver_Token(t *testing.T) {
+	a := assert.New(t)
+	handler, root := newTestDriver(t)
+	handler.Policy.OptionsSerialized.ChunkSize = 10
+	a.NoError(os.WriteFile(filepath.Join(root, "exist.txt"), []byte("a"), 0644))
+
+	_, err := handler.Token(context.Background(), 10, &serializer.UploadSession{SavePath: "exist.txt"}, &fsctx.FileStream{})
+	a.Error(err)
+
+	res, err := handler.Token(context.Background(), 10, &serializer.UploadSession{Key: "key", SavePath: "a.txt"}, &fsctx.FileStream{})
+	a.NoError(err)
+	a.Equal("key", res.SessionID)
+	a.EqualValues(10, res.ChunkSize)
+
+	// 取消上传时删除临时文件
+	a.NoError(os.WriteFile(filepath.Join(root, "a.txt"+uploadTempSuffix), []byte("a"), 0644))
+	a.NoError(handler.CancelToken(context.Background(), &serializer.UploadSession{SavePath: "a.txt"}))
+	a.NoFileExists(filepath.Join(root, "a.txt"+uploadTempSuffix))
+	a.NoError(handler.CancelToken(context.Background(), &serializer.UploadSession{SavePath: "a.txt"}))
+}
+
+func TestDriver_Thumb(t *testing.T) {
+	handler, _ := newTestDriver(t)
+	_, err := handler.Thumb(context.Background(), &model.File{})
+	assert.Equal(t, driver.ErrorThumbNotSupported, err)
+}
+
+func TestNewHostKeyCallback(t *testing.T) {
+	a := assert.New(t)
+	pub, _, err := ed25519.GenerateKey(nil)
+	a.NoError(err)
+	key, err := ssh.NewPublicKey(pub)
+	a.NoError(err)
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	other, _ := ssh.NewPublicKey(otherPub)
+
+	// 指纹
+	{
+		policy := &model.Policy{}
+		policy.OptionsSerialized.HostKey = ssh.FingerprintSHA256(key)
+		callback, err := newHostKeyCallback(policy)
+		a.NoError(err)
+		a.NoError(callback("host", nil, key))
+		a.Error(callback("host", nil, other))
+	}
+
+	// authorized_keys 格式的公钥
+	{
+		policy := &model.Policy{}
+		policy.OptionsSerialized.HostKey = string(ssh.MarshalAuthorizedKey(key))
+		callback, err := newHostKeyCallback(policy)
+		a.NoError(err)
+		a.NoError(callback("host", nil, key))
+		a.Error(callback("host", nil, other))
+	}
+
+	// 无法解析的公钥
+	{
+		policy := &model.Policy{}
+		policy.OptionsSerialized.HostKey = "invalid"
+		_, err := newHostKeyCallback(policy)
+		a.Error(err)
+	}
+}
@@ -0,0 +1,240 @@
+package sftp
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// maxIdleClients 每个存储策略保留的最大空闲连接数
+	maxIdleClients = 4
+	// idleCheckInterval 空闲超过此时长的连接在复用前需检查是否可用
+	idleCheckInterval = 30 * time.Second
+	dialTimeout       = 10 * time.Second
+)
+
+var (
+	poolsLock sync.Mutex
+	// pools 存储策略 ID -> 连接池
+	pools = make(map[uint]*pool)
+)
+
+// pool SFTP 连接池
+type pool struct {
+	mu        sync.Mutex
+	idle      []*client
+	signature string
+	dial      func() (*client, error)
+}
+
+// getPool 获取存储策略对应的连接池，策略连接信息变更后将关闭旧连接池
+func getPool(policy *model.Policy) *pool {
+	signature := fmt.Sprintf("%s|%s|%x|%s", policy.Server, policy.AccessKey,
+		sha256.Sum256([]byte(policy.SecretKey)), policy.OptionsSerialized.HostKey)
+
+	poolsLock.Lock()
+	defer poolsLock.Unlock()
+
+	if p, ok := pools[policy.ID]; ok {
+		if p.signature == signature {
+			return p
+		}
+		p.close()
+	}
+
+	config := *policy
+	p := &pool{
+		signature: signature,
+		dial: func() (*client, error) {
+			return dial(&config)
+		},
+	}
+	pools[policy.ID] = p
+	return p
+}
+
+// get 取出一个可用的连接，没有空闲连接时新建
+func (p *pool) get() (*client, error) {
+	for {
+		p.mu.Lock()
+		if len(p.idle) == 0 {
+			p.mu.Unlock()
+			break
+		}
+
+		c := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.mu.Unlock()
+
+		if time.Since(c.lastUsed) < idleCheckInterval {
+			return c, nil
+		}
+
+		// 长时间空闲的连接可能已被服务端断开
+		if _, err := c.Stat("."); err == nil {
+			return c, nil
+		}
+		c.Close()
+	}
+
+	return p.dial()
+}
+
+// put 归还连接，已损坏或超出空闲上限的连接将被关闭
+func (p *pool) put(c *client) {
+	if c.broken {
+		c.Close()
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.idle) >= maxIdleClients {
+		c.Close()
+		return
+	}
+
+	c.lastUsed = time.Now()
+	p.idle = append(p.idle, c)
+}
+
+// close 关闭所有空闲连接
+func (p *pool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, c := range p.idle {
+		c.Close()
+	}
+	p.idle = nil
+}
+
+// sshCloser 依次关闭 SSH 会话与连接
+type sshCloser struct {
+	session *ssh.Session
+	conn    *ssh.Client
+}
+
+func (c sshCloser) Close() error {
+	c.session.Close()
+	return c.conn.Close()
+}
+
+// newHostKeyCallback 按存储策略中的公钥或指纹校验服务器身份。未指定时记录首次连接时服务器提供的公钥，
+// 此后服务器公钥变化将无法连接
+func newHostKeyCallback(policy *model.Policy) (ssh.HostKeyCallback, error) {
+	expected := strings.TrimSpace(policy.OptionsSerialized.HostKey)
+	if strings.HasPrefix(expected, "SHA256:") {
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if ssh.FingerprintSHA256(key) != expected {
+				return fmt.Errorf("host key fingerprint mismatch: got %s", ssh.FingerprintSHA256(key))
+			}
+			return nil
+		}, nil
+	}
+
+	if expected != "" {
+		hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(expected))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse host key: %w", err)
+		}
+		return ssh.FixedHostKey(hostKey), nil
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		pinned := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+		if err := policy.PinHostKey(pinned); err != nil {
+			return fmt.Errorf("failed to pin host key %s: %w", ssh.FingerprintSHA256(key), err)
+		}
+
+		util.Log().Warning("Host key of SFTP server %q is not configured, pinned to %s.", policy.Server, ssh.FingerprintSHA256(key))
+		return nil
+	}, nil
+}
+
+// dial 建立 SSH 连接并启动 sftp 子系统
+func dial(policy *model.Policy) (*client, error) {
+	config := &ssh.ClientConfig{
+		User:    policy.AccessKey,
+		Timeout: dialTimeout,
+	}
+
+	// SecretKey 为 PEM 格式私钥时使用公钥认证，否则视为密码
+	if strings.Contains(policy.SecretKey, "PRIVATE KEY") {
+		signer, err := ssh.ParsePrivateKey([]byte(policy.SecretKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		config.Auth = []ssh.AuthMethod{ssh.PublicKeys(signer)}
+	} else {
+		password := policy.SecretKey
+		config.Auth = []ssh.AuthMethod{
+			ssh.Password(password),
+			ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = password
+				}
+				return answers, nil
+			}),
+		}
+	}
+
+	hostKeyCallback, err := newHostKeyCallback(policy)
+	if err != nil {
+		return nil, err
+	}
+	config.HostKeyCallback = hostKeyCallback
+
+	addr := policy.Server
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+
+	conn, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := conn.NewSession()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	closer := sshCloser{session: session, conn: conn}
+	w, err := session.StdinPipe()
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+
+	r, err := session.StdoutPipe()
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		closer.Close()
+		return nil, errors.New("sftp subsystem is not available")
+	}
+
+	c, err := newClient(r, w, closer)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
@@ -122,3 +122,8 @@ func (d *Driver) List(ctx context.Context, path string, recursive bool) ([]respo
 func (d *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
 	return nil
 }
+
+// Capabilities 影子存储策略仅用于上传，不提供额外特性
+func (d *Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{}
+}
@@ -0,0 +1,111 @@
+package driver
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// stagingPerm 暂存目录及文件的权限
+const stagingPerm = 0744
+
+var (
+	// ErrChunkOffset 暂存的分片大小与写入位置不符
+	ErrChunkOffset = errors.New("size of unfinished uploaded chunks is not as expected")
+	// ErrInsufficientTempSpace 暂存目录所在磁盘剩余空间不足
+	ErrInsufficientTempSpace = errors.New("insufficient free space in upload temp directory")
+)
+
+// StagingDir 返回存储策略上传时在本机暂存文件的目录，即策略设定的上传暂存目录，
+// 未设定时使用临时目录设置
+func StagingDir(policy *model.Policy) string {
+	dir := policy.OptionsSerialized.UploadTempPath
+	if dir == "" {
+		dir = model.GetSettingByName("temp_path")
+	}
+
+	return util.RelativePath(filepath.FromSlash(dir))
+}
+
+// StagingPath 返回存储策略上传至 savePath 的文件在本机暂存的路径
+func StagingPath(policy *model.Policy, savePath string) string {
+	return filepath.Join(StagingDir(policy), fmt.Sprintf("%d_%x", policy.ID, md5.Sum([]byte(savePath))))
+}
+
+// CheckStagingSpace 创建暂存目录 dir，并检查其所在磁盘能否再写入 size 字节
+func CheckStagingSpace(dir string, size uint64) error {
+	if err := os.MkdirAll(dir, stagingPerm); err != nil {
+		util.Log().Warning("Failed to create upload temp directory: %s", err)
+		return err
+	}
+
+	free, err := util.DiskFreeSpace(dir)
+	if err != nil {
+		util.Log().Warning("Failed to get free space of upload temp directory: %s", err)
+		return err
+	}
+
+	if free < size {
+		return ErrInsufficientTempSpace
+	}
+
+	return nil
+}
+
+// AppendStaging 将 size 字节的分片写入暂存文件 staging 的 start 位置，暂存文件超出 start 的部分被覆盖
+func AppendStaging(staging string, file io.Reader, start, size uint64) error {
+	if err := CheckStagingSpace(filepath.Dir(staging), size); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(staging, os.O_CREATE|os.O_WRONLY, stagingPerm)
+	if err != nil {
+		util.Log().Warning("Failed to open or create file: %s", err)
+		return err
+	}
+	defer out.Close()
+
+	stat, err := out.Stat()
+	if err != nil {
+		return err
+	}
+
+	if uint64(stat.Size()) < start {
+		return ErrChunkOffset
+	} else if uint64(stat.Size()) > start {
+		if err := out.Truncate(int64(start)); err != nil {
+			return fmt.Errorf("failed to overwrite chunk: %w", err)
+		}
+	}
+
+	if _, err := out.Seek(int64(start), io.SeekStart); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, file)
+	return err
+}
+
+// TruncateStaging 将暂存文件截断至给定大小，暂存文件不存在时忽略
+func TruncateStaging(staging string, size uint64) error {
+	if !util.Exists(staging) {
+		return nil
+	}
+
+	return os.Truncate(staging, int64(size))
+}
+
+// RemoveStaging 删除暂存文件，暂存文件不存在时忽略
+func RemoveStaging(staging string) error {
+	if err := os.Remove(staging); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,257 @@
+package swift
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+)
+
+const (
+	// authCachePrefix 认证令牌的缓存前缀
+	authCachePrefix = "swift_auth_"
+	// defaultTokenTTL 认证响应中未给出有效期时令牌的缓存时长
+	defaultTokenTTL = 3600
+)
+
+// authInfo 认证后获得的令牌及对象存储服务地址
+type authInfo struct {
+	Token      string
+	StorageURL string
+}
+
+// keystoneName Keystone 中以名称指定的对象
+type keystoneName struct {
+	Name string `json:"name"`
+}
+
+// keystoneScope Keystone v3 认证的项目范围
+type keystoneScope struct {
+	Project struct {
+		Name   string       `json:"name"`
+		Domain keystoneName `json:"domain"`
+	} `json:"project"`
+}
+
+// keystoneV3Request Keystone v3 密码认证请求
+type keystoneV3Request struct {
+	Auth struct {
+		Identity struct {
+			Methods  []string `json:"methods"`
+			Password struct {
+				User struct {
+					Name     string       `json:"name"`
+					Password string       `json:"password"`
+					Domain   keystoneName `json:"domain"`
+				} `json:"user"`
+			} `json:"password"`
+		} `json:"identity"`
+		Scope *keystoneScope `json:"scope,omitempty"`
+	} `json:"auth"`
+}
+
+// keystoneV3Response Keystone v3 认证响应
+type keystoneV3Response struct {
+	Token struct {
+		ExpiresAt time.Time `json:"expires_at"`
+		Catalog   []struct {
+			Type      string `json:"type"`
+			Endpoints []struct {
+				Interface string `json:"interface"`
+				Region    string `json:"region"`
+				URL       string `json:"url"`
+			} `json:"endpoints"`
+		} `json:"catalog"`
+	} `json:"token"`
+}
+
+func init() {
+	gob.Register(authInfo{})
+}
+
+// isKeystoneV3 返回认证地址是否为 Keystone v3，否则使用 v1 认证
+func isKeystoneV3(authURL string) bool {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasSuffix(strings.TrimSuffix(u.Path, "/"), "/v3")
+}
+
+// auth 获取认证令牌，优先使用缓存
+func (handler *Driver) auth(ctx context.Context) (*authInfo, error) {
+	key := fmt.Sprintf("%s%d", authCachePrefix, handler.Policy.ID)
+	if cached, ok := cache.Get(key); ok {
+		info := cached.(authInfo)
+		return &info, nil
+	}
+
+	var (
+		info *authInfo
+		ttl  int
+		err  error
+	)
+	if isKeystoneV3(handler.Policy.Server) {
+		info, ttl, err = handler.authV3(ctx)
+	} else {
+		info, ttl, err = handler.authV1(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("swift authentication failed: %w", err)
+	}
+
+	if handler.Policy.OptionsSerialized.ServerSideEndpoint != "" {
+		info.StorageURL = handler.Policy.OptionsSerialized.ServerSideEndpoint
+	}
+	info.StorageURL = strings.TrimSuffix(info.StorageURL, "/")
+
+	cache.Set(key, *info, ttl)
+	return info, nil
+}
+
+// clearAuth 清除缓存的认证令牌
+func (handler *Driver) clearAuth() {
+	ClearAuthCache(handler.Policy.ID)
+}
+
+// ClearAuthCache 清除存储策略缓存的认证令牌，凭证变更后需调用
+func ClearAuthCache(policyID uint) {
+	cache.Deletes([]string{strconv.FormatUint(uint64(policyID), 10)}, authCachePrefix)
+}
+
+// authV1 使用 v1 (TempAuth/SwAuth) 认证，AccessKey 一般为 account:user 形式
+func (handler *Driver) authV1(ctx context.Context) (*authInfo, int, error) {
+	resp := handler.client.Request(
+		"GET",
+		handler.Policy.Server,
+		nil,
+		request.WithContext(ctx),
+		request.WithHeader(http.Header{
+			"X-Auth-User": {handler.Policy.AccessKey},
+			"X-Auth-Key":  {handler.Policy.SecretKey},
+		}),
+	)
+	if resp.Err != nil {
+		return nil, 0, resp.Err
+	}
+	resp.Response.Body.Close()
+
+	if resp.Response.StatusCode != http.StatusOK && resp.Response.StatusCode != http.StatusNoContent {
+		return nil, 0, fmt.Errorf("unexpected status code %d", resp.Response.StatusCode)
+	}
+
+	info := &authInfo{
+		Token:      resp.Response.Header.Get("X-Auth-Token"),
+		StorageURL: resp.Response.Header.Get("X-Storage-Url"),
+	}
+	if info.Token == "" || info.StorageURL == "" {
+		return nil, 0, errors.New("token or storage url not found in response")
+	}
+
+	ttl := defaultTokenTTL
+	if expires, err := strconv.Atoi(resp.Response.Header.Get("X-Auth-Token-Expires")); err == nil {
+		ttl = expires
+	}
+
+	return info, tokenTTL(ttl), nil
+}
+
+// authV3 使用 Keystone v3 密码认证，并从服务目录中选取对象存储地址
+func (handler *Driver) authV3(ctx context.Context) (*authInfo, int, error) {
+	options := handler.Policy.OptionsSerialized
+	domain := options.SwiftDomain
+	if domain == "" {
+		domain = "Default"
+	}
+
+	var req keystoneV3Request
+	req.Auth.Identity.Methods = []string{"password"}
+	req.Auth.Identity.Password.User.Name = handler.Policy.AccessKey
+	req.Auth.Identity.Password.User.Password = handler.Policy.SecretKey
+	req.Auth.Identity.Password.User.Domain.Name = domain
+	if options.SwiftProject != "" {
+		req.Auth.Scope = &keystoneScope{}
+		req.Auth.Scope.Project.Name = options.SwiftProject
+		req.Auth.Scope.Project.Domain.Name = domain
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp := handler.client.Request(
+		"POST",
+		strings.TrimSuffix(handler.Policy.Server, "/")+"/auth/tokens",
+		bytes.NewReader(body),
+		request.WithContext(ctx),
+		request.WithContentLength(int64(len(body))),
+		request.WithHeader(http.Header{"Content-Type": {"application/json"}}),
+	).CheckHTTPResponse(http.StatusCreated)
+	if resp.Err != nil {
+		return nil, 0, resp.Err
+	}
+
+	respBody, err := resp.GetResponse()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var res keystoneV3Response
+	if err := json.Unmarshal([]byte(respBody), &res); err != nil {
+		return nil, 0, err
+	}
+
+	info := &authInfo{Token: resp.Response.Header.Get("X-Subject-Token")}
+	if info.Token == "" {
+		return nil, 0, errors.New("token not found in response")
+	}
+
+	for _, service := range res.Token.Catalog {
+		if service.Type != "object-store" {
+			continue
+		}
+
+		for _, endpoint := range service.Endpoints {
+			if endpoint.Interface == "public" && (options.Region == "" || endpoint.Region == options.Region) {
+				info.StorageURL = endpoint.URL
+				break
+			}
+		}
+	}
+
+	if info.StorageURL == "" && options.ServerSideEndpoint == "" {
+		return nil, 0, errors.New("object-store endpoint not found in service catalog")
+	}
+
+	ttl := defaultTokenTTL
+	if !res.Token.ExpiresAt.IsZero() {
+		ttl = int(time.Until(res.Token.ExpiresAt).Seconds())
+	}
+
+	return info, tokenTTL(ttl), nil
+}
+
+// tokenTTL 令牌的缓存时长，提前 5 分钟过期。缓存时长为 0 表示永不过期，需避免
+func tokenTTL(ttl int) int {
+	if ttl > 600 {
+		return ttl - 300
+	}
+
+	if ttl < 2 {
+		return 1
+	}
+
+	return ttl / 2
+}
@@ -0,0 +1,694 @@
+package swift
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/chunk"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/chunk/backoff"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+const (
+	chunkRetrySleep = time.Duration(5) * time.Second
+	// segmentContainerSuffix 大文件分段所在容器的后缀
+	segmentContainerSuffix = "_segments"
+	// sloCachePrefix 集群是否支持 SLO 的缓存前缀
+	sloCachePrefix = "swift_slo_"
+)
+
+// ErrFileExisted 上传的目标对象已存在
+var ErrFileExisted = errors.New("file already exist")
+
+// Driver OpenStack Swift 存储策略适配器，Policy 中 Server 为认证地址（Keystone v3
+// 或 v1），AccessKey 为用户名，SecretKey 为密码，BucketName 为容器名
+type Driver struct {
+	Policy *model.Policy
+	client request.Client
+}
+
+// MetaData 文件信息
+type MetaData struct {
+	Size uint64
+	Etag string
+	// SLO 为真时对象为静态大对象清单
+	SLO bool
+	// Manifest 动态大对象的分段前缀，形如 container/prefix
+	Manifest string
+}
+
+// listEntry 列取容器时返回的条目
+type listEntry struct {
+	Name         string `json:"name"`
+	Bytes        uint64 `json:"bytes"`
+	LastModified string `json:"last_modified"`
+	Subdir       string `json:"subdir"`
+}
+
+// sloSegment 静态大对象清单中的分段
+type sloSegment struct {
+	Path      string `json:"path"`
+	SizeBytes uint64 `json:"size_bytes"`
+}
+
+// NewDriver 创建 Swift 适配器
+func NewDriver(policy *model.Policy) (*Driver, error) {
+	if policy.Server == "" || policy.BucketName == "" {
+		return nil, errors.New("swift auth url or container is not set")
+	}
+
+	if policy.OptionsSerialized.ChunkSize == 0 {
+		policy.OptionsSerialized.ChunkSize = 25 << 20 // 25 MB
+	}
+
+	return &Driver{
+		Policy: policy,
+		client: request.NewClient(request.WithProxy(policy.OptionsSerialized.Proxy)),
+	}, nil
+}
+
+// objectURL 返回对象的访问地址，name 为空时返回容器地址
+func objectURL(storageURL, container, name string) string {
+	res := storageURL + "/" + url.PathEscape(container)
+	if name = strings.Trim(filepath.ToSlash(name), "/"); name != "" {
+		segments := strings.Split(name, "/")
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+		res += "/" + strings.Join(segments, "/")
+	}
+
+	return res
+}
+
+// segmentContainer 返回大文件分段所在的容器
+func (handler *Driver) segmentContainer() string {
+	return handler.Policy.BucketName + segmentContainerSuffix
+}
+
+// segmentName 返回第 index 个分段的对象名
+func segmentName(prefix string, index int) string {
+	return fmt.Sprintf("%s/%08d", prefix, index)
+}
+
+// request 携带认证令牌发送请求，令牌失效时重新认证一次
+func (handler *Driver) request(ctx context.Context, method, container, name string, query url.Values, body io.Reader, header http.Header, opts ...request.Option) *request.Response {
+	for retry := 0; ; retry++ {
+		info, err := handler.auth(ctx)
+		if err != nil {
+			return &request.Response{Err: err}
+		}
+
+		target := objectURL(info.StorageURL, container, name)
+		if len(query) > 0 {
+			target += "?" + query.Encode()
+		}
+
+		reqHeader := http.Header{"X-Auth-Token": {info.Token}}
+		for k, v := range header {
+			reqHeader[k] = v
+		}
+
+		resp := handler.client.Request(method, target, body, append([]request.Option{
+			request.WithContext(ctx),
+			request.WithHeader(reqHeader),
+			request.WithTPSLimit(
+				fmt.Sprintf("policy_%d", handler.Policy.ID),
+				handler.Policy.OptionsSerialized.TPSLimit,
+				handler.Policy.OptionsSerialized.TPSLimitBurst,
+			),
+		}, opts...)...)
+
+		// 请求正文无法重放时不重试
+		if resp.Err == nil && resp.Response.StatusCode == http.StatusUnauthorized && retry == 0 && body == nil {
+			resp.Response.Body.Close()
+			handler.clearAuth()
+			continue
+		}
+
+		return resp
+	}
+}
+
+// ensureContainer 创建容器，容器已存在时不做改动
+func (handler *Driver) ensureContainer(ctx context.Context, container string, header http.Header) error {
+	resp := handler.request(ctx, "PUT", container, "", nil, nil, header,
+		request.WithContentLength(0),
+	)
+	if resp.Err != nil {
+		return resp.Err
+	}
+	resp.Response.Body.Close()
+
+	if resp.Response.StatusCode != http.StatusCreated && resp.Response.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("failed to create container %q: unexpected status code %d", container, resp.Response.StatusCode)
+	}
+
+	return nil
+}
+
+// sloSupported 通过 /info 接口检查集群是否启用了静态大对象，不可用时使用动态大对象
+func (handler *Driver) sloSupported(ctx context.Context) bool {
+	key := fmt.Sprintf("%s%d", sloCachePrefix, handler.Policy.ID)
+	if cached, ok := cache.Get(key); ok {
+		return cached.(bool)
+	}
+
+	supported := false
+	info, err := handler.auth(ctx)
+	if err == nil {
+		var u *url.URL
+		if u, err = url.Parse(info.StorageURL); err == nil {
+			u.Path, u.RawQuery = "/info", ""
+			var body string
+			body, err = handler.client.Request("GET", u.String(), nil, request.WithContext(ctx)).
+				CheckHTTPResponse(200).GetResponse()
+			if err == nil {
+				var capabilities map[string]json.RawMessage
+				if err = json.Unmarshal([]byte(body), &capabilities); err == nil {
+					_, supported = capabilities["slo"]
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		util.Log().Debug("Failed to query Swift capabilities, fallback to DLO: %s", err)
+	}
+
+	cache.Set(key, supported, 3600)
+	return supported
+}
+
+// CommitSegments 为已上传至分段容器 prefix 下的分段创建大对象清单，
+// 集群支持时使用 SLO，否则使用 DLO
+func (handler *Driver) CommitSegments(ctx context.Context, dst, prefix string, size uint64, overwrite bool) error {
+	header := http.Header{}
+	if !overwrite {
+		header.Set("If-None-Match", "*")
+	}
+
+	var (
+		query url.Values
+		body  []byte
+	)
+	if handler.sloSupported(ctx) {
+		chunkSize := handler.Policy.OptionsSerialized.ChunkSize
+		segments := make([]sloSegment, 0, (size+chunkSize-1)/chunkSize)
+		for offset := uint64(0); offset < size; offset += chunkSize {
+			length := chunkSize
+			if size-offset < chunkSize {
+				length = size - offset
+			}
+
+			segments = append(segments, sloSegment{
+				Path:      "/" + handler.segmentContainer() + "/" + segmentName(prefix, len(segments)),
+				SizeBytes: length,
+			})
+		}
+
+		var err error
+		if body, err = json.Marshal(segments); err != nil {
+			return err
+		}
+
+		query = url.Values{"multipart-manifest": {"put"}}
+	} else {
+		header.Set("X-Object-Manifest", url.PathEscape(handler.segmentContainer())+"/"+url.PathEscape(prefix+"/"))
+	}
+
+	resp := handler.request(ctx, "PUT", handler.Policy.BucketName, dst, query, bytes.NewReader(body), header,
+		request.WithContentLength(int64(len(body))),
+	).CheckHTTPResponse(http.StatusCreated)
+	if resp.Err != nil {
+		return fmt.Errorf("failed to create manifest: %w", resp.Err)
+	}
+
+	resp.Response.Body.Close()
+	return nil
+}
+
+// deleteSegments 删除分段容器中 prefix 下的所有分段
+func (handler *Driver) deleteSegments(ctx context.Context, prefix string) error {
+	segments, err := handler.listObjects(ctx, handler.segmentContainer(), prefix+"/", false)
+	if err != nil {
+		return err
+	}
+
+	var retErr error
+	for _, segment := range segments {
+		if err := handler.deleteObject(ctx, handler.segmentContainer(), segment.Name, nil); err != nil {
+			retErr = err
+		}
+	}
+
+	return retErr
+}
+
+// deleteObject 删除对象，对象不存在时视为删除成功
+func (handler *Driver) deleteObject(ctx context.Context, container, name string, query url.Values) error {
+	resp := handler.request(ctx, "DELETE", container, name, query, nil, nil)
+	if resp.Err != nil {
+		return resp.Err
+	}
+	resp.Response.Body.Close()
+
+	switch resp.Response.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	}
+
+	return fmt.Errorf("unexpected status code %d", resp.Response.StatusCode)
+}
+
+// listObjects 列取容器中给定前缀的对象
+func (handler *Driver) listObjects(ctx context.Context, container, prefix string, delimiter bool) ([]listEntry, error) {
+	query := url.Values{
+		"format": {"json"},
+		"prefix": {prefix},
+	}
+	if delimiter {
+		query.Set("delimiter", "/")
+	}
+
+	var res []listEntry
+	for {
+		resp := handler.request(ctx, "GET", container, "", query, nil, nil)
+		if resp.Err != nil {
+			return nil, resp.Err
+		}
+
+		// 容器不存在时视为空
+		if resp.Response.StatusCode == http.StatusNotFound {
+			resp.Response.Body.Close()
+			return res, nil
+		}
+
+		body, err := resp.CheckHTTPResponse(http.StatusOK).GetResponse()
+		if err != nil {
+			return nil, err
+		}
+
+		var entries []listEntry
+		if err := json.Unmarshal([]byte(body), &entries); err != nil {
+			return nil, err
+		}
+
+		if len(entries) == 0 {
+			return res, nil
+		}
+
+		res = append(res, entries...)
+
+		// 使用最后一个条目作为 marker 继续列取
+		last := entries[len(entries)-1]
+		marker := last.Name
+		if marker == "" {
+			marker = last.Subdir
+		}
+		query.Set("marker", marker)
+	}
+}
+
+// List 列出给定路径下的文件
+func (handler *Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	base = strings.TrimPrefix(base, "/")
+	if base != "" {
+		base += "/"
+	}
+
+	entries, err := handler.listObjects(ctx, handler.Policy.BucketName, base, !recursive)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]response.Object, 0, len(entries))
+	for _, entry := range entries {
+		// 处理目录
+		if entry.Subdir != "" {
+			rel, err := filepath.Rel(base, entry.Subdir)
+			if err != nil {
+				continue
+			}
+			res = append(res, response.Object{
+				Name:         path.Base(entry.Subdir),
+				RelativePath: filepath.ToSlash(rel),
+				Size:         0,
+				IsDir:        true,
+				LastModify:   time.Now(),
+			})
+			continue
+		}
+
+		// 处理文件
+		rel, err := filepath.Rel(base, entry.Name)
+		if err != nil {
+			continue
+		}
+
+		lastModified, err := time.Parse("2006-01-02T15:04:05.999999", entry.LastModified)
+		if err != nil {
+			lastModified = time.Now()
+		}
+
+		res = append(res, response.Object{
+			Name:         path.Base(entry.Name),
+			Source:       entry.Name,
+			RelativePath: filepath.ToSlash(rel),
+			Size:         entry.Bytes,
+			IsDir:        false,
+			LastModify:   lastModified,
+		})
+	}
+
+	return res, nil
+}
+
+// Get 获取文件
+func (handler *Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	resp, err := request.NewRangeRSCloser(func(header http.Header) *request.Response {
+		return handler.request(ctx, "GET", handler.Policy.BucketName, path, nil, nil, header,
+			request.WithTimeout(time.Duration(0)),
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 尝试自主获取文件大小
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
+		resp.SetContentLength(int64(file.Size))
+	}
+
+	return resp, nil
+}
+
+// Put 将文件流保存到指定目录，超过分片大小时分段上传后创建大对象清单
+func (handler *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
+	defer file.Close()
+	fileInfo := file.Info()
+	overwrite := fileInfo.Mode&fsctx.Overwrite == fsctx.Overwrite
+
+	// 小文件直接上传
+	chunkSize := handler.Policy.OptionsSerialized.ChunkSize
+	if fileInfo.Size <= chunkSize {
+		header := http.Header{"Content-Type": {fileInfo.DetectMimeType()}}
+		if !overwrite {
+			header.Set("If-None-Match", "*")
+		}
+
+		resp := handler.request(ctx, "PUT", handler.Policy.BucketName, fileInfo.SavePath, nil,
+			io.LimitReader(file, int64(fileInfo.Size)), header,
+			request.WithContentLength(int64(fileInfo.Size)),
+			request.WithTimeout(time.Duration(0)),
+		).CheckHTTPResponse(http.StatusCreated)
+		if resp.Err != nil {
+			return resp.Err
+		}
+
+		resp.Response.Body.Close()
+		return nil
+	}
+
+	if err := handler.ensureContainer(ctx, handler.segmentContainer(), nil); err != nil {
+		return err
+	}
+
+	prefix := path.Join(strings.TrimPrefix(fileInfo.SavePath, "/"), util.RandStringRunes(16))
+	chunks := chunk.NewChunkGroup(file, chunkSize, &backoff.ConstantBackoff{
+		Max:   model.GetIntSetting("chunk_retries", 5),
+		Sleep: chunkRetrySleep,
+	}, model.IsTrueVal(model.GetSettingByName("use_temp_chunk_buffer")))
+
+	uploadFunc := func(current *chunk.ChunkGroup, content io.Reader) error {
+		resp := handler.request(ctx, "PUT", handler.segmentContainer(), segmentName(prefix, current.Index()), nil,
+			content, nil,
+			request.WithContentLength(current.Length()),
+			request.WithTimeout(time.Duration(0)),
+		).CheckHTTPResponse(http.StatusCreated)
+		if resp.Err != nil {
+			return resp.Err
+		}
+
+		resp.Response.Body.Close()
+		return nil
+	}
+
+	for chunks.Next() {
+		if err := chunks.Process(uploadFunc); err != nil {
+			handler.deleteSegments(context.Background(), prefix)
+			return fmt.Errorf("failed to upload chunk #%d: %w", chunks.Index(), err)
+		}
+	}
+
+	if err := handler.CommitSegments(ctx, fileInfo.SavePath, prefix, fileInfo.Size, overwrite); err != nil {
+		handler.deleteSegments(context.Background(), prefix)
+		return err
+	}
+
+	return nil
+}
+
+// Delete 删除一个或多个文件，大对象会同时删除其分段，
+// 返回未删除的文件，及遇到的最后一个错误
+func (handler *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	failed := make([]string, 0, len(files))
+	var retErr error
+
+	for _, file := range files {
+		meta, err := handler.Meta(ctx, file)
+		if err == nil {
+			switch {
+			case meta.SLO:
+				err = handler.deleteObject(ctx, handler.Policy.BucketName, file, url.Values{"multipart-manifest": {"delete"}})
+			case meta.Manifest != "":
+				err = handler.deleteObject(ctx, handler.Policy.BucketName, file, nil)
+				if err == nil {
+					err = handler.deleteDLOSegments(ctx, meta.Manifest)
+				}
+			default:
+				err = handler.deleteObject(ctx, handler.Policy.BucketName, file, nil)
+			}
+		} else if errors.Is(err, errObjectNotExist) {
+			// 文件不存在时视为删除成功
+			err = nil
+		}
+
+		if err == nil {
+			continue
+		}
+
+		util.Log().Warning("Failed to delete object %q: %s", file, err)
+		failed = append(failed, file)
+		retErr = err
+	}
+
+	return failed, retErr
+}
+
+// deleteDLOSegments 删除动态大对象清单指向的分段
+func (handler *Driver) deleteDLOSegments(ctx context.Context, manifest string) error {
+	manifest, err := url.PathUnescape(manifest)
+	if err != nil {
+		return err
+	}
+
+	container, prefix, found := strings.Cut(manifest, "/")
+	if !found || container != handler.segmentContainer() {
+		// 不删除其他容器中的分段
+		return nil
+	}
+
+	return handler.deleteSegments(ctx, strings.TrimSuffix(prefix, "/"))
+}
+
+// Thumb 获取文件缩略图，Swift 不支持图像处理，由 Cloudreve 代理生成
+func (handler *Driver) Thumb(ctx context.Context, file *model.File) (*response.ContentResponse, error) {
+	return nil, driver.ErrorThumbNotSupported
+}
+
+// Source 获取外链URL
+func (handler *Driver) Source(ctx context.Context, path string, ttl int64, isDownload bool, speed int) (string, error) {
+	// 尝试从上下文获取文件名
+	fileName := ""
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
+		fileName = file.Name
+	}
+
+	var (
+		source string
+		err    error
+	)
+
+	// 公有容器无需签名
+	if !handler.Policy.IsPrivate && !isDownload {
+		var info *authInfo
+		if info, err = handler.auth(ctx); err == nil {
+			source = objectURL(info.StorageURL, handler.Policy.BucketName, path)
+		}
+	} else {
+		var extra url.Values
+		if isDownload {
+			extra = url.Values{"filename": {fileName}}
+		}
+
+		source, err = handler.tempURL(ctx, "GET", handler.Policy.BucketName, path,
+			time.Now().Add(time.Duration(ttl)*time.Second), extra)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	sourceURL, err := url.Parse(source)
+	if err != nil {
+		return "", err
+	}
+
+	// 将最终生成的URL域名换成用户自定义的加速域名（如果有）
+	if handler.Policy.BaseURL != "" {
+		cdnURL, err := url.Parse(handler.Policy.BaseURL)
+		if err != nil {
+			return "", err
+		}
+		sourceURL.Host = cdnURL.Host
+		sourceURL.Scheme = cdnURL.Scheme
+	}
+
+	return sourceURL.String(), nil
+}
+
+// Token 获取上传凭证。单个分片的文件由客户端直接上传至目标对象，否则客户端
+// 使用 UploadURLs 依次上传各个分段，最后请求 Callback 由服务端创建大对象清单
+func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
+	// 检查文件是否存在
+	fileInfo := file.Info()
+	if _, err := handler.Meta(ctx, fileInfo.SavePath); err == nil {
+		return nil, ErrFileExisted
+	} else if !errors.Is(err, errObjectNotExist) {
+		return nil, err
+	}
+
+	// 生成回调地址
+	siteURL := model.GetSiteURL()
+	apiBaseURI, _ := url.Parse("/api/v3/callback/swift/" + uploadSession.Key)
+	apiURL := siteURL.ResolveReference(apiBaseURI).String()
+	expires := time.Now().Add(time.Duration(ttl) * time.Second)
+
+	chunks := chunk.NewChunkGroup(file, handler.Policy.OptionsSerialized.ChunkSize, &backoff.ConstantBackoff{}, false)
+	urls := make([]string, chunks.Num())
+	if len(urls) <= 1 {
+		putURL, err := handler.tempURL(ctx, "PUT", handler.Policy.BucketName, fileInfo.SavePath, expires, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		urls = []string{putURL}
+	} else {
+		if err := handler.ensureContainer(ctx, handler.segmentContainer(), nil); err != nil {
+			return nil, err
+		}
+
+		// 为每个分段签名上传 URL
+		uploadSession.UploadID = path.Join(strings.TrimPrefix(fileInfo.SavePath, "/"), uploadSession.Key)
+		for i := range urls {
+			signedURL, err := handler.tempURL(ctx, "PUT", handler.segmentContainer(), segmentName(uploadSession.UploadID, i), expires, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			urls[i] = signedURL
+		}
+	}
+
+	return &serializer.UploadCredential{
+		SessionID:  uploadSession.Key,
+		ChunkSize:  handler.Policy.OptionsSerialized.ChunkSize,
+		UploadURLs: urls,
+		Callback:   apiURL,
+	}, nil
+}
+
+// CancelToken 取消上传凭证，删除已上传的分段
+func (handler *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	if uploadSession.UploadID == "" {
+		return nil
+	}
+
+	return handler.deleteSegments(ctx, uploadSession.UploadID)
+}
+
+// errObjectNotExist 对象不存在
+var errObjectNotExist = errors.New("object not exist")
+
+// Meta 获取文件信息
+func (handler *Driver) Meta(ctx context.Context, path string) (*MetaData, error) {
+	resp := handler.request(ctx, "HEAD", handler.Policy.BucketName, path, nil, nil, nil)
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	resp.Response.Body.Close()
+
+	switch resp.Response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, errObjectNotExist
+	default:
+		return nil, fmt.Errorf("unexpected status code %d", resp.Response.StatusCode)
+	}
+
+	size, err := strconv.ParseUint(resp.Response.Header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MetaData{
+		Size:     size,
+		Etag:     strings.Trim(resp.Response.Header.Get("Etag"), `"`),
+		SLO:      strings.EqualFold(resp.Response.Header.Get("X-Static-Large-Object"), "true"),
+		Manifest: resp.Response.Header.Get("X-Object-Manifest"),
+	}, nil
+}
+
+// CORS 允许浏览器跨域上传、下载容器及分段容器中的对象
+func (handler *Driver) CORS() error {
+	header := http.Header{
+		"X-Container-Meta-Access-Control-Allow-Origin":   {"*"},
+		"X-Container-Meta-Access-Control-Expose-Headers": {"Etag"},
+	}
+
+	for _, container := range []string{handler.Policy.BucketName, handler.segmentContainer()} {
+		if err := handler.ensureContainer(context.Background(), container, header); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Capabilities 返回存储端支持的特性
+func (handler *Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		RangeGet:        true,
+		PresignedUpload: true,
+		MultipartUpload: true,
+	}
+}
@@ -0,0 +1,522 @@
+package swift
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/mocks/requestmock"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+)
+
+const (
+	storageURL = "https://swift.example.com/v1/AUTH_test"
+	objectBase = storageURL + "/bucket/"
+	segmentURL = storageURL + "/bucket_segments"
+)
+
+func newTestDriver(t *testing.T, clientMock request.Client) *Driver {
+	handler, err := NewDriver(&model.Policy{
+		Model:      gorm.Model{ID: 1},
+		Type:       "swift",
+		Server:     "https://keystone.example.com/v3",
+		AccessKey:  "user",
+		SecretKey:  "password",
+		BucketName: "bucket",
+		IsPrivate:  true,
+		OptionsSerialized: model.PolicyOption{
+			TempURLKey: "secret",
+		},
+	})
+	assert.NoError(t, err)
+	cache.Set("swift_auth_1", authInfo{Token: "token", StorageURL: storageURL}, 0)
+
+	handler.client = clientMock
+	return handler
+}
+
+func newResponse(status int, body string, header http.Header) *request.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &request.Response{
+		Response: &http.Response{
+			StatusCode:    status,
+			Header:        header,
+			ContentLength: int64(len(body)),
+			Body:          io.NopCloser(strings.NewReader(body)),
+		},
+	}
+}
+
+func TestNewDriver(t *testing.T) {
+	a := assert.New(t)
+	_, err := NewDriver(&model.Policy{BucketName: "bucket"})
+	a.Error(err)
+
+	handler, err := NewDriver(&model.Policy{Server: "https://keystone.example.com/v3", BucketName: "bucket"})
+	a.NoError(err)
+	a.EqualValues(25<<20, handler.Policy.OptionsSerialized.ChunkSize)
+	a.Equal("bucket_segments", handler.segmentContainer())
+}
+
+func TestObjectURL(t *testing.T) {
+	a := assert.New(t)
+	a.Equal(storageURL+"/bucket", objectURL(storageURL, "bucket", ""))
+	a.Equal(storageURL+"/bucket/dir/a.txt", objectURL(storageURL, "bucket", "/dir/a.txt"))
+	a.Equal(storageURL+"/bucket/dir/a%20b%3F.txt", objectURL(storageURL, "bucket", "dir/a b?.txt"))
+}
+
+func TestIsKeystoneV3(t *testing.T) {
+	a := assert.New(t)
+	a.True(isKeystoneV3("https://keystone.example.com/v3"))
+	a.True(isKeystoneV3("https://keystone.example.com/identity/v3/"))
+	a.False(isKeystoneV3("https://swift.example.com/auth/v1.0"))
+}
+
+func TestTokenTTL(t *testing.T) {
+	a := assert.New(t)
+	a.Equal(3300, tokenTTL(3600))
+	a.Equal(300, tokenTTL(600))
+	a.Equal(1, tokenTTL(0))
+	a.Equal(1, tokenTTL(-10))
+}
+
+func TestDriver_AuthV1(t *testing.T) {
+	a := assert.New(t)
+
+	// 成功
+	{
+		clientMock := &requestmock.RequestMock{}
+		handler := newTestDriver(t, clientMock)
+		handler.Policy.Server = "https://swift.example.com/auth/v1.0"
+		handler.clearAuth()
+		clientMock.On("Request", "GET", "https://swift.example.com/auth/v1.0", testMock.Anything, testMock.Anything).
+			Return(newResponse(200, "", http.Header{
+				"X-Auth-Token":         {"v1token"},
+				"X-Storage-Url":        {storageURL + "/"},
+				"X-Auth-Token-Expires": {"3600"},
+			})).Once()
+
+		info, err := handler.auth(context.Background())
+		a.NoError(err)
+		a.Equal("v1token", info.Token)
+		a.Equal(storageURL, info.StorageURL)
+
+		// 使用缓存
+		info, err = handler.auth(context.Background())
+		a.NoError(err)
+		a.Equal("v1token", info.Token)
+		clientMock.AssertExpectations(t)
+	}
+
+	// 认证失败
+	{
+		clientMock := &requestmock.RequestMock{}
+		handler := newTestDriver(t, clientMock)
+		handler.Policy.Server = "https://swift.example.com/auth/v1.0"
+		handler.clearAuth()
+		clientMock.On("Request", "GET", "https://swift.example.com/auth/v1.0", testMock.Anything, testMock.Anything).
+			Return(newResponse(401, "", nil))
+
+		_, err := handler.auth(context.Background())
+		clientMock.AssertExpectations(t)
+		a.Error(err)
+	}
+}
+
+func TestDriver_AuthV3(t *testing.T) {
+	a := assert.New(t)
+	catalog := `{"token":{"expires_at":"%s","catalog":[
+		{"type":"identity","endpoints":[{"interface":"public","region":"r1","url":"https://keystone.example.com"}]},
+		{"type":"object-store","endpoints":[
+			{"interface":"internal","region":"r1","url":"https://internal.example.com/v1/AUTH_test"},
+			{"interface":"public","region":"r1","url":"https://r1.example.com/v1/AUTH_test"},
+			{"interface":"public","region":"r2","url":"https://r2.example.com/v1/AUTH_test"}
+		]}
+	]}}`
+	catalog = strings.Replace(catalog, "%s", time.Now().Add(time.Hour).UTC().Format(time.RFC3339), 1)
+
+	// 成功，按区域选择端点
+	{
+		clientMock := &requestmock.RequestMock{}
+		handler := newTestDriver(t, clientMock)
+		handler.Policy.OptionsSerialized.Region = "r2"
+		handler.Policy.OptionsSerialized.SwiftProject = "project"
+		handler.clearAuth()
+		clientMock.On("Request", "POST", "https://keystone.example.com/v3/auth/tokens", testMock.Anything, testMock.Anything).
+			Return(newResponse(201, catalog, http.Header{"X-Subject-Token": {"v3token"}}))
+
+		info, err := handler.auth(context.Background())
+		clientMock.AssertExpectations(t)
+		a.NoError(err)
+		a.Equal("v3token", info.Token)
+		a.Equal("https://r2.example.com/v1/AUTH_test", info.StorageURL)
+	}
+
+	// 服务目录中无对象存储
+	{
+		clientMock := &requestmock.RequestMock{}
+		handler := newTestDriver(t, clientMock)
+		handler.clearAuth()
+		clientMock.On("Request", "POST", "https://keystone.example.com/v3/auth/tokens", testMock.Anything, testMock.Anything).
+			Return(newResponse(201, `{"token":{"catalog":[]}}`, http.Header{"X-Subject-Token": {"v3token"}}))
+
+		_, err := handler.auth(context.Background())
+		clientMock.AssertExpectations(t)
+		a.Error(err)
+	}
+
+	// 服务端指定了内网地址
+	{
+		clientMock := &requestmock.RequestMock{}
+		handler := newTestDriver(t, clientMock)
+		handler.Policy.OptionsSerialized.ServerSideEndpoint = "https://internal.example.com/v1/AUTH_test"
+		handler.clearAuth()
+		clientMock.On("Request", "POST", "https://keystone.example.com/v3/auth/tokens", testMock.Anything, testMock.Anything).
+			Return(newResponse(201, `{"token":{"catalog":[]}}`, http.Header{"X-Subject-Token": {"v3token"}}))
+
+		info, err := handler.auth(context.Background())
+		clientMock.AssertExpectations(t)
+		a.NoError(err)
+		a.Equal("https://internal.example.com/v1/AUTH_test", info.StorageURL)
+	}
+}
+
+func TestDriver_Request(t *testing.T) {
+	a := assert.New(t)
+
+	// 令牌失效时重新认证
+	clientMock := &requestmock.RequestMock{}
+	handler := newTestDriver(t, clientMock)
+	handler.Policy.Server = "https://swift.example.com/auth/v1.0"
+	clientMock.On("Request", "HEAD", objectBase+"a.txt", testMock.Anything, testMock.Anything).
+		Return(newResponse(401, "", nil)).Once()
+	clientMock.On("Request", "GET", "https://swift.example.com/auth/v1.0", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, "", http.Header{
+			"X-Auth-Token":  {"new"},
+			"X-Storage-Url": {storageURL},
+		}))
+	clientMock.On("Request", "HEAD", objectBase+"a.txt", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, "", http.Header{"Content-Length": {"4"}, "Etag": {`"etag"`}})).Once()
+
+	meta, err := handler.Meta(context.Background(), "a.txt")
+	clientMock.AssertExpectations(t)
+	a.NoError(err)
+	a.EqualValues(4, meta.Size)
+	a.Equal("etag", meta.Etag)
+}
+
+func TestDriver_TempURL(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t, &requestmock.RequestMock{})
+	expires := time.Unix(1700000000, 0)
+
+	res, err := handler.tempURL(context.Background(), "GET", "bucket", "dir/a.txt", expires, url.Values{"filename": {"a.txt"}})
+	a.NoError(err)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("GET\n1700000000\n/v1/AUTH_test/bucket/dir/a.txt"))
+	u, _ := url.Parse(res)
+	a.Equal("swift.example.com", u.Host)
+	a.Equal(hex.EncodeToString(mac.Sum(nil)), u.Query().Get("temp_url_sig"))
+	a.Equal("1700000000", u.Query().Get("temp_url_expires"))
+	a.Equal("a.txt", u.Query().Get("filename"))
+
+	// 未设置密钥
+	handler.Policy.OptionsSerialized.TempURLKey = ""
+	_, err = handler.tempURL(context.Background(), "GET", "bucket", "dir/a.txt", expires, nil)
+	a.Error(err)
+}
+
+func TestDriver_Token(t *testing.T) {
+	a := assert.New(t)
+	cache.Set("setting_siteURL", "http://test.cloudreve.org", 0)
+
+	// 文件已存在
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "HEAD", objectBase+"a.txt", testMock.Anything, testMock.Anything).
+			Return(newResponse(200, "", http.Header{"Content-Length": {"4"}}))
+		handler := newTestDriver(t, clientMock)
+		_, err := handler.Token(context.Background(), 10, &serializer.UploadSession{SavePath: "/a.txt"}, &fsctx.FileStream{SavePath: "/a.txt"})
+		clientMock.AssertExpectations(t)
+		a.ErrorIs(err, ErrFileExisted)
+	}
+
+	// 单个分片
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "HEAD", objectBase+"a.txt", testMock.Anything, testMock.Anything).
+			Return(newResponse(404, "", nil))
+		handler := newTestDriver(t, clientMock)
+		uploadSession := &serializer.UploadSession{Key: "key", SavePath: "/a.txt"}
+		res, err := handler.Token(context.Background(), 10, uploadSession, &fsctx.FileStream{SavePath: "/a.txt", Size: 4})
+		clientMock.AssertExpectations(t)
+		a.NoError(err)
+		a.Equal("key", res.SessionID)
+		a.Len(res.UploadURLs, 1)
+		a.True(strings.HasPrefix(res.UploadURLs[0], objectBase+"a.txt?"))
+		a.Contains(res.Callback, "/api/v3/callback/swift/key")
+		a.Empty(uploadSession.UploadID)
+	}
+
+	// 多个分段
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "HEAD", objectBase+"a.txt", testMock.Anything, testMock.Anything).
+			Return(newResponse(404, "", nil))
+		clientMock.On("Request", "PUT", segmentURL, testMock.Anything, testMock.Anything).
+			Return(newResponse(202, "", nil))
+		handler := newTestDriver(t, clientMock)
+		handler.Policy.OptionsSerialized.ChunkSize = 2
+		uploadSession := &serializer.UploadSession{Key: "key", SavePath: "/a.txt"}
+		res, err := handler.Token(context.Background(), 10, uploadSession, &fsctx.FileStream{SavePath: "/a.txt", Size: 5})
+		clientMock.AssertExpectations(t)
+		a.NoError(err)
+		a.Len(res.UploadURLs, 3)
+		a.Equal("a.txt/key", uploadSession.UploadID)
+		a.True(strings.HasPrefix(res.UploadURLs[2], segmentURL+"/a.txt/key/00000002?"))
+	}
+}
+
+func TestDriver_Put(t *testing.T) {
+	a := assert.New(t)
+	cache.Set("setting_chunk_retries", "1", 0)
+	cache.Set("setting_use_temp_chunk_buffer", "false", 0)
+
+	// 小文件
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "PUT", objectBase+"a.txt", testMock.Anything, testMock.Anything).
+			Return(newResponse(201, "", nil))
+		handler := newTestDriver(t, clientMock)
+		err := handler.Put(context.Background(), &fsctx.FileStream{
+			File:     io.NopCloser(strings.NewReader("1234")),
+			SavePath: "/a.txt",
+			Size:     4,
+		})
+		clientMock.AssertExpectations(t)
+		a.NoError(err)
+	}
+
+	// 目标已存在
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "PUT", objectBase+"a.txt", testMock.Anything, testMock.Anything).
+			Return(newResponse(412, "", nil))
+		handler := newTestDriver(t, clientMock)
+		err := handler.Put(context.Background(), &fsctx.FileStream{
+			File:     io.NopCloser(strings.NewReader("1234")),
+			SavePath: "/a.txt",
+			Size:     4,
+		})
+		clientMock.AssertExpectations(t)
+		a.Error(err)
+	}
+
+	// 大文件使用 SLO
+	{
+		cache.Set("swift_slo_1", true, 0)
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "PUT", segmentURL, testMock.Anything, testMock.Anything).
+			Return(newResponse(202, "", nil))
+		clientMock.On("Request", "PUT", testMock.MatchedBy(func(u string) bool {
+			return strings.HasPrefix(u, segmentURL+"/a.txt/")
+		}), testMock.Anything, testMock.Anything).
+			Return(newResponse(201, "", nil)).Twice()
+		clientMock.On("Request", "PUT", objectBase+"a.txt?multipart-manifest=put", testMock.Anything, testMock.Anything).
+			Return(newResponse(201, "", nil))
+		handler := newTestDriver(t, clientMock)
+		handler.Policy.OptionsSerialized.ChunkSize = 2
+		err := handler.Put(context.Background(), &fsctx.FileStream{
+			File:     io.NopCloser(strings.NewReader("1234")),
+			SavePath: "/a.txt",
+			Size:     4,
+		})
+		clientMock.AssertExpectations(t)
+		a.NoError(err)
+	}
+
+	// 大文件使用 DLO
+	{
+		cache.Set("swift_slo_1", false, 0)
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "PUT", segmentURL, testMock.Anything, testMock.Anything).
+			Return(newResponse(202, "", nil))
+		clientMock.On("Request", "PUT", testMock.MatchedBy(func(u string) bool {
+			return strings.HasPrefix(u, segmentURL+"/a.txt/")
+		}), testMock.Anything, testMock.Anything).
+			Return(newResponse(201, "", nil)).Twice()
+		clientMock.On("Request", "PUT", objectBase+"a.txt", testMock.Anything, testMock.Anything).
+			Return(newResponse(201, "", nil))
+		handler := newTestDriver(t, clientMock)
+		handler.Policy.OptionsSerialized.ChunkSize = 2
+		err := handler.Put(context.Background(), &fsctx.FileStream{
+			File:     io.NopCloser(strings.NewReader("1234")),
+			SavePath: "/a.txt",
+			Size:     4,
+		})
+		clientMock.AssertExpectations(t)
+		a.NoError(err)
+	}
+}
+
+func TestDriver_SloSupported(t *testing.T) {
+	a := assert.New(t)
+	cache.Deletes([]string{"1"}, "swift_slo_")
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "GET", "https://swift.example.com/info", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, `{"swift":{},"slo":{"max_manifest_segments":1000}}`, nil)).Once()
+	handler := newTestDriver(t, clientMock)
+
+	a.True(handler.sloSupported(context.Background()))
+	// 使用缓存
+	a.True(handler.sloSupported(context.Background()))
+	clientMock.AssertExpectations(t)
+}
+
+func TestDriver_Delete(t *testing.T) {
+	a := assert.New(t)
+
+	// 普通对象、SLO、DLO 及不存在的对象
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "HEAD", objectBase+"a.txt", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, "", http.Header{"Content-Length": {"4"}}))
+	clientMock.On("Request", "DELETE", objectBase+"a.txt", testMock.Anything, testMock.Anything).
+		Return(newResponse(204, "", nil))
+	clientMock.On("Request", "HEAD", objectBase+"slo.txt", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, "", http.Header{"Content-Length": {"4"}, "X-Static-Large-Object": {"True"}}))
+	clientMock.On("Request", "DELETE", objectBase+"slo.txt?multipart-manifest=delete", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, "", nil))
+	clientMock.On("Request", "HEAD", objectBase+"dlo.txt", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, "", http.Header{"Content-Length": {"4"}, "X-Object-Manifest": {"bucket_segments/dlo.txt/key/"}}))
+	clientMock.On("Request", "DELETE", objectBase+"dlo.txt", testMock.Anything, testMock.Anything).
+		Return(newResponse(204, "", nil))
+	clientMock.On("Request", "GET", segmentURL+"?format=json&prefix=dlo.txt%2Fkey%2F", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, `[{"name":"dlo.txt/key/00000000"},{"name":"dlo.txt/key/00000001"}]`, nil))
+	clientMock.On("Request", "GET", segmentURL+"?format=json&marker=dlo.txt%2Fkey%2F00000001&prefix=dlo.txt%2Fkey%2F", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, `[]`, nil))
+	clientMock.On("Request", "DELETE", segmentURL+"/dlo.txt/key/00000000", testMock.Anything, testMock.Anything).
+		Return(newResponse(204, "", nil))
+	clientMock.On("Request", "DELETE", segmentURL+"/dlo.txt/key/00000001", testMock.Anything, testMock.Anything).
+		Return(newResponse(404, "", nil))
+	clientMock.On("Request", "HEAD", objectBase+"not_exist.txt", testMock.Anything, testMock.Anything).
+		Return(newResponse(404, "", nil))
+	clientMock.On("Request", "HEAD", objectBase+"failed.txt", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, "", http.Header{"Content-Length": {"4"}}))
+	clientMock.On("Request", "DELETE", objectBase+"failed.txt", testMock.Anything, testMock.Anything).
+		Return(newResponse(409, "", nil))
+	handler := newTestDriver(t, clientMock)
+
+	failed, err := handler.Delete(context.Background(), []string{"a.txt", "slo.txt", "dlo.txt", "not_exist.txt", "failed.txt"})
+	clientMock.AssertExpectations(t)
+	a.Error(err)
+	a.Equal([]string{"failed.txt"}, failed)
+}
+
+func TestDriver_List(t *testing.T) {
+	a := assert.New(t)
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "GET", storageURL+"/bucket?delimiter=%2F&format=json&prefix=dir%2F", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, `[
+			{"name":"dir/a.txt","bytes":4,"last_modified":"2022-01-02T03:04:05.123456"},
+			{"subdir":"dir/sub/"}
+		]`, nil))
+	clientMock.On("Request", "GET", storageURL+"/bucket?delimiter=%2F&format=json&marker=dir%2Fsub%2F&prefix=dir%2F", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, `[]`, nil))
+	handler := newTestDriver(t, clientMock)
+
+	res, err := handler.List(context.Background(), "/dir", false)
+	clientMock.AssertExpectations(t)
+	a.NoError(err)
+	a.Len(res, 2)
+	a.Equal("a.txt", res[0].Name)
+	a.Equal("dir/a.txt", res[0].Source)
+	a.EqualValues(4, res[0].Size)
+	a.Equal(2022, res[0].LastModify.Year())
+	a.Equal("sub", res[1].Name)
+	a.Equal("sub", res[1].RelativePath)
+	a.True(res[1].IsDir)
+}
+
+func TestDriver_Source(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t, &requestmock.RequestMock{})
+
+	// 私有容器
+	{
+		ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, model.File{Name: "a.txt"})
+		res, err := handler.Source(ctx, "dir/a.txt", 10, true, 0)
+		a.NoError(err)
+		u, _ := url.Parse(res)
+		a.Equal("/v1/AUTH_test/bucket/dir/a.txt", u.Path)
+		a.NotEmpty(u.Query().Get("temp_url_sig"))
+		a.Equal("a.txt", u.Query().Get("filename"))
+	}
+
+	// 公有容器，使用 CDN
+	{
+		handler.Policy.IsPrivate = false
+		handler.Policy.BaseURL = "https://cdn.example.com"
+		res, err := handler.Source(context.Background(), "dir/a.txt", 10, false, 0)
+		a.NoError(err)
+		a.Equal("https://cdn.example.com/v1/AUTH_test/bucket/dir/a.txt", res)
+	}
+}
+
+func TestDriver_Get(t *testing.T) {
+	a := assert.New(t)
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "GET", objectBase+"a.txt", testMock.Anything, testMock.Anything).
+		Return(newResponse(200, "1234", nil))
+	handler := newTestDriver(t, clientMock)
+
+	rs, err := handler.Get(context.Background(), "a.txt")
+	clientMock.AssertExpectations(t)
+	a.NoError(err)
+	size, err := rs.Seek(0, io.SeekEnd)
+	a.NoError(err)
+	a.EqualValues(4, size)
+	_, err = rs.Seek(0, io.SeekStart)
+	a.NoError(err)
+	content := make([]byte, 1024)
+	n, _ := rs.Read(content)
+	a.Equal("1234", string(content[:n]))
+}
+
+func TestDriver_CancelToken(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t, &requestmock.RequestMock{})
+	a.NoError(handler.CancelToken(context.Background(), &serializer.UploadSession{}))
+
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "GET", segmentURL+"?format=json&prefix=a.txt%2Fkey%2F", testMock.Anything, testMock.Anything).
+		Return(newResponse(404, "", nil))
+	handler = newTestDriver(t, clientMock)
+	a.NoError(handler.CancelToken(context.Background(), &serializer.UploadSession{UploadID: "a.txt/key"}))
+	clientMock.AssertExpectations(t)
+}
+
+func TestDriver_Thumb(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t, &requestmock.RequestMock{})
+	_, err := handler.Thumb(context.Background(), &model.File{})
+	a.ErrorIs(err, driver.ErrorThumbNotSupported)
+}
@@ -0,0 +1,45 @@
+package swift
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// tempURL 使用 Temp URL 密钥签名对象地址，签名内容为 "方法\n过期时间\n对象路径"
+func (handler *Driver) tempURL(ctx context.Context, method, container, name string, expires time.Time, extra url.Values) (string, error) {
+	key := handler.Policy.OptionsSerialized.TempURLKey
+	if key == "" {
+		return "", errors.New("temp url key is not set")
+	}
+
+	info, err := handler.auth(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(objectURL(info.StorageURL, container, name))
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "%s\n%d\n%s", method, expires.Unix(), u.Path)
+
+	query := url.Values{
+		"temp_url_sig":     {hex.EncodeToString(mac.Sum(nil))},
+		"temp_url_expires": {strconv.FormatInt(expires.Unix(), 10)},
+	}
+	for k, v := range extra {
+		query[k] = v
+	}
+
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
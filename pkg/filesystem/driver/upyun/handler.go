@@ -43,6 +43,21 @@ type Driver struct {
 	Policy *model.Policy
 }
 
+// newUpYun 创建又拍云 SDK 客户端
+func (handler Driver) newUpYun() *upyun.UpYun {
+	up := upyun.NewUpYun(&upyun.UpYunConfig{
+		Bucket:   handler.Policy.BucketName,
+		Operator: handler.Policy.AccessKey,
+		Password: handler.Policy.SecretKey,
+	})
+
+	if proxy := handler.Policy.OptionsSerialized.Proxy; proxy != "" {
+		up.SetHTTPClient(request.ProxyHTTPClient(proxy))
+	}
+
+	return up
+}
+
 func (handler Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
 	base = strings.TrimPrefix(base, "/")
 
@@ -75,11 +90,7 @@ func (handler Driver) List(ctx context.Context, base string, recursive bool) ([]
 		}
 	}(objChan, &objects, wg)
 
-	up := upyun.NewUpYun(&upyun.UpYunConfig{
-		Bucket:   handler.Policy.BucketName,
-		Operator: handler.Policy.AccessKey,
-		Password: handler.Policy.SecretKey,
-	})
+	up := handler.newUpYun()
 
 	err := up.List(listConf)
 	if err != nil {
@@ -113,23 +124,24 @@ func (handler Driver) Get(ctx context.Context, path string) (response.RSCloser,
 	}
 
 	// 获取文件数据流
-	client := request.NewClient()
-	resp, err := client.Request(
-		"GET",
-		downloadURL,
-		nil,
-		request.WithContext(ctx),
-		request.WithHeader(
-			http.Header{"Cache-Control": {"no-cache", "no-store", "must-revalidate"}},
-		),
-		request.WithTimeout(time.Duration(0)),
-	).CheckHTTPResponse(200).GetRSCloser()
+	client := request.NewClient(request.WithProxy(handler.Policy.OptionsSerialized.Proxy))
+	resp, err := request.NewRangeRSCloser(func(header http.Header) *request.Response {
+		return client.Request(
+			"GET",
+			downloadURL,
+			nil,
+			request.WithContext(ctx),
+			request.WithHeader(
+				http.Header{"Cache-Control": {"no-cache", "no-store", "must-revalidate"}},
+			),
+			request.WithHeader(header),
+			request.WithTimeout(time.Duration(0)),
+		)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	resp.SetFirstFakeChunk()
-
 	// 尝试自主获取文件大小
 	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
 		resp.SetContentLength(int64(file.Size))
@@ -143,11 +155,7 @@ func (handler Driver) Get(ctx context.Context, path string) (response.RSCloser,
 func (handler Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
 	defer file.Close()
 
-	up := upyun.NewUpYun(&upyun.UpYunConfig{
-		Bucket:   handler.Policy.BucketName,
-		Operator: handler.Policy.AccessKey,
-		Password: handler.Policy.SecretKey,
-	})
+	up := handler.newUpYun()
 	err := up.Put(&upyun.PutObjectConfig{
 		Path:   file.Info().SavePath,
 		Reader: file,
@@ -159,11 +167,7 @@ func (handler Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
 // Delete 删除一个或多个文件，
 // 返回未删除的文件，及遇到的最后一个错误
 func (handler Driver) Delete(ctx context.Context, files []string) ([]string, error) {
-	up := upyun.NewUpYun(&upyun.UpYunConfig{
-		Bucket:   handler.Policy.BucketName,
-		Operator: handler.Policy.AccessKey,
-		Password: handler.Policy.SecretKey,
-	})
+	up := handler.newUpYun()
 
 	var (
 		failed       = make([]string, 0, len(files))
@@ -356,3 +360,12 @@ func (handler Driver) Sign(ctx context.Context, elements []string) string {
 	signStr := base64.StdEncoding.EncodeToString((mac.Sum(nil)))
 	return fmt.Sprintf("UPYUN %s:%s", handler.Policy.AccessKey, signStr)
 }
+
+// Capabilities 返回存储端支持的特性
+func (handler Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		RangeGet:        true,
+		PresignedUpload: true,
+		Thumbnail:       true,
+	}
+}
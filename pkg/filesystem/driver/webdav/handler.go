@@ -0,0 +1,416 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// uploadTempSuffix 上传中文件的后缀，上传完成后移动为目标文件
+const uploadTempSuffix = ".cloudreve_upload"
+
+var (
+	ErrFileExisted = errors.New("file with the same name existed or unavailable")
+	ErrChunkOffset = driver.ErrChunkOffset
+)
+
+// Driver WebDAV 存储策略适配器，Policy 中 Server 为 WebDAV 根目录地址，
+// AccessKey 为用户名，SecretKey 为密码
+type Driver struct {
+	Policy *model.Policy
+	client request.Client
+}
+
+// multistatus PROPFIND 响应
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ContentLength uint64 `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+				ResourceType  struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+			} `xml:"prop"`
+			Status string `xml:"status"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/><d:getlastmodified/></d:prop></d:propfind>`
+
+// NewDriver 创建 WebDAV 适配器
+func NewDriver(policy *model.Policy) (*Driver, error) {
+	if _, err := url.Parse(policy.Server); err != nil || policy.Server == "" {
+		return nil, fmt.Errorf("invalid webdav server address: %q", policy.Server)
+	}
+
+	return &Driver{
+		Policy: policy,
+		client: request.NewClient(request.WithProxy(policy.OptionsSerialized.Proxy)),
+	}, nil
+}
+
+// url 返回文件的访问地址
+func (handler *Driver) url(p string) string {
+	segments := strings.Split(strings.Trim(filepath.ToSlash(p), "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return strings.TrimSuffix(handler.Policy.Server, "/") + "/" + strings.Join(segments, "/")
+}
+
+// request 向 WebDAV 服务器发送请求
+func (handler *Driver) request(ctx context.Context, method, target string, body io.Reader, header http.Header, opts ...request.Option) *request.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+
+	if handler.Policy.AccessKey != "" || handler.Policy.SecretKey != "" {
+		req := http.Request{Header: http.Header{}}
+		req.SetBasicAuth(handler.Policy.AccessKey, handler.Policy.SecretKey)
+		header.Set("Authorization", req.Header.Get("Authorization"))
+	}
+
+	opts = append([]request.Option{request.WithContext(ctx), request.WithHeader(header)}, opts...)
+	return handler.client.Request(method, target, body, opts...)
+}
+
+// status 发送请求并返回响应状态码
+func (handler *Driver) status(ctx context.Context, method, target string, header http.Header) (int, error) {
+	resp := handler.request(ctx, method, target, nil, header)
+	if resp.Err != nil {
+		return 0, resp.Err
+	}
+
+	resp.Response.Body.Close()
+	return resp.Response.StatusCode, nil
+}
+
+// stat 获取文件大小，文件不存在时返回 os.ErrNotExist
+func (handler *Driver) stat(ctx context.Context, p string) (int64, error) {
+	resp := handler.request(ctx, "HEAD", handler.url(p), nil, nil)
+	if resp.Err != nil {
+		return 0, resp.Err
+	}
+	resp.Response.Body.Close()
+
+	switch resp.Response.StatusCode {
+	case http.StatusOK:
+		return resp.Response.ContentLength, nil
+	case http.StatusNotFound:
+		return 0, os.ErrNotExist
+	default:
+		return 0, fmt.Errorf("unexpected status code %d", resp.Response.StatusCode)
+	}
+}
+
+// mkcol 逐级创建目录
+func (handler *Driver) mkcol(ctx context.Context, dir string) error {
+	dir = strings.Trim(dir, "/")
+	if dir == "" || dir == "." {
+		return nil
+	}
+
+	for retried := false; ; retried = true {
+		status, err := handler.status(ctx, "MKCOL", handler.url(dir)+"/", nil)
+		if err != nil {
+			return err
+		}
+
+		switch status {
+		case http.StatusCreated, http.StatusMethodNotAllowed:
+			// 405 表示目录已存在
+			return nil
+		case http.StatusConflict:
+			// 上级目录不存在
+			if !retried {
+				if err := handler.mkcol(ctx, path.Dir(dir)); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		return fmt.Errorf("failed to create directory %q: status code %d", dir, status)
+	}
+}
+
+// putFile 将文件内容上传到临时文件后移动为目标文件
+func (handler *Driver) putFile(ctx context.Context, dst string, file io.Reader, size uint64, overwrite bool) error {
+	if err := handler.mkcol(ctx, path.Dir(filepath.ToSlash(dst))); err != nil {
+		return err
+	}
+
+	temp := handler.url(dst + uploadTempSuffix)
+	resp := handler.request(ctx, "PUT", temp, io.LimitReader(file, int64(size)), nil,
+		request.WithContentLength(int64(size)),
+		request.WithTimeout(time.Duration(0)),
+	)
+	if resp.Err != nil {
+		return resp.Err
+	}
+	resp.Response.Body.Close()
+
+	// 覆盖已有的临时文件时返回 204
+	if resp.Response.StatusCode != http.StatusCreated && resp.Response.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("failed to upload file: status code %d", resp.Response.StatusCode)
+	}
+
+	header := http.Header{"Destination": {handler.url(dst)}, "Overwrite": {"F"}}
+	if overwrite {
+		header.Set("Overwrite", "T")
+	}
+
+	status, err := handler.status(ctx, "MOVE", temp, header)
+	if err == nil {
+		switch status {
+		case http.StatusCreated, http.StatusNoContent:
+			return nil
+		case http.StatusPreconditionFailed:
+			err = ErrFileExisted
+		default:
+			err = fmt.Errorf("failed to move uploaded file: status code %d", status)
+		}
+	}
+
+	handler.status(ctx, "DELETE", temp, nil)
+	return err
+}
+
+// List 递归列取远程端 path 路径下文件、目录
+func (handler *Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	base = strings.Trim(base, "/")
+	var res []response.Object
+
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		target := strings.TrimSuffix(handler.url(path.Join(base, rel)), "/") + "/"
+		body, err := handler.request(ctx, "PROPFIND", target, strings.NewReader(propfindBody),
+			http.Header{"Depth": {"1"}, "Content-Type": {"application/xml"}},
+			request.WithContentLength(int64(len(propfindBody))),
+		).CheckHTTPResponse(http.StatusMultiStatus).GetResponse()
+		if err != nil {
+			return err
+		}
+
+		var result multistatus
+		if err := xml.Unmarshal([]byte(body), &result); err != nil {
+			return err
+		}
+
+		targetURL, _ := url.Parse(target)
+		for _, item := range result.Responses {
+			hrefURL, err := url.Parse(item.Href)
+			if err != nil {
+				continue
+			}
+
+			// 跳过目录本身
+			href := strings.TrimSuffix(hrefURL.Path, "/")
+			if href == strings.TrimSuffix(targetURL.Path, "/") {
+				continue
+			}
+
+			name := path.Base(href)
+			if strings.HasSuffix(name, uploadTempSuffix) {
+				continue
+			}
+
+			object := response.Object{
+				Name:         name,
+				RelativePath: path.Join(rel, name),
+				Source:       path.Join(base, rel, name),
+			}
+			for _, propstat := range item.Propstat {
+				if !strings.Contains(propstat.Status, "200") {
+					continue
+				}
+
+				object.Size = propstat.Prop.ContentLength
+				object.IsDir = propstat.Prop.ResourceType.Collection != nil
+				object.LastModify, _ = http.ParseTime(propstat.Prop.LastModified)
+			}
+
+			res = append(res, object)
+			if recursive && object.IsDir {
+				if err := walk(object.RelativePath); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	return res, walk("")
+}
+
+// Get 获取文件内容，读取时按当前位置发送范围请求
+func (handler *Driver) Get(ctx context.Context, p string) (response.RSCloser, error) {
+	size, err := handler.stat(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rangeReader{
+		ctx:     ctx,
+		handler: handler,
+		target:  handler.url(p),
+		size:    size,
+	}, nil
+}
+
+// Put 将文件流保存到指定目录。分片上传时分片先在本机暂存，
+// 最后一个分片上传后由 CompleteUpload 上传至 WebDAV 服务器
+func (handler *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
+	defer file.Close()
+	fileInfo := file.Info()
+	overwrite := fileInfo.Mode&fsctx.Overwrite == fsctx.Overwrite
+
+	// 如果非 Overwrite，则检查是否有重名冲突
+	if !overwrite {
+		if _, err := handler.stat(ctx, fileInfo.SavePath); err == nil {
+			util.Log().Warning("File with the same name existed or unavailable: %s", fileInfo.SavePath)
+			return ErrFileExisted
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	if fileInfo.Mode&fsctx.Append == fsctx.Append {
+		return driver.AppendStaging(driver.StagingPath(handler.Policy, fileInfo.SavePath), file, fileInfo.AppendStart, fileInfo.Size)
+	}
+
+	return handler.putFile(ctx, fileInfo.SavePath, file, fileInfo.Size, overwrite)
+}
+
+// CompleteUpload 将暂存的分片上传文件上传至 WebDAV 服务器
+func (handler *Driver) CompleteUpload(ctx context.Context, dst string) error {
+	staging := driver.StagingPath(handler.Policy, dst)
+	file, err := os.Open(staging)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.putFile(ctx, dst, file, uint64(stat.Size()), true); err != nil {
+		return err
+	}
+
+	file.Close()
+	return os.Remove(staging)
+}
+
+// Truncate 将上传中的文件截断至给定大小，文件已完成上传时将取回本机暂存
+func (handler *Driver) Truncate(ctx context.Context, src string, size uint64) error {
+	util.Log().Warning("Truncate file %q to [%d].", src, size)
+	staging := driver.StagingPath(handler.Policy, src)
+
+	if !util.Exists(staging) {
+		remote, err := handler.Get(ctx, src)
+		if err != nil {
+			return err
+		}
+		defer remote.Close()
+
+		if err := driver.AppendStaging(staging, io.LimitReader(remote, int64(size)), 0, size); err != nil {
+			return err
+		}
+
+		if _, err := handler.Delete(ctx, []string{src}); err != nil {
+			return err
+		}
+	}
+
+	return os.Truncate(staging, int64(size))
+}
+
+// Delete 删除一个或多个文件，
+// 返回未删除的文件，及遇到的最后一个错误
+func (handler *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	failed := make([]string, 0, len(files))
+	var retErr error
+
+	for _, file := range files {
+		status, err := handler.status(ctx, "DELETE", handler.url(file), nil)
+		if err == nil {
+			// 文件不存在时视为删除成功
+			switch status {
+			case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+				continue
+			}
+
+			err = fmt.Errorf("unexpected status code %d", status)
+		}
+
+		util.Log().Warning("Failed to delete file %q: %s", file, err)
+		failed = append(failed, file)
+		retErr = err
+	}
+
+	return failed, retErr
+}
+
+// Thumb 获取文件缩略图
+func (handler *Driver) Thumb(ctx context.Context, file *model.File) (*response.ContentResponse, error) {
+	return nil, driver.ErrorThumbNotSupported
+}
+
+// Source 获取外链URL，文件内容由 Cloudreve 中转
+func (handler *Driver) Source(ctx context.Context, p string, ttl int64, isDownload bool, speed int) (string, error) {
+	return local.Driver{Policy: handler.Policy}.Source(ctx, p, ttl, isDownload, speed)
+}
+
+// Token 获取上传凭证，分片由 Cloudreve 中转上传
+func (handler *Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
+	if _, err := handler.stat(ctx, uploadSession.SavePath); err == nil {
+		return nil, errors.New("placeholder file already exist")
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	return &serializer.UploadCredential{
+		SessionID: uploadSession.Key,
+		ChunkSize: handler.Policy.OptionsSerialized.ChunkSize,
+	}, nil
+}
+
+// CancelToken 取消上传凭证，删除本机暂存的分片
+func (handler *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	return driver.RemoveStaging(driver.StagingPath(handler.Policy, uploadSession.SavePath))
+}
+
+// Capabilities 返回存储端支持的特性
+func (handler *Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		RangeGet:        true,
+		MultipartUpload: true,
+	}
+}
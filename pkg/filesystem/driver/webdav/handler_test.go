@@ -0,0 +1,335 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/mocks/requestmock"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+)
+
+func newTestDriver(t *testing.T) *Driver {
+	handler, err := NewDriver(&model.Policy{
+		Type:      "webdav",
+		Server:    "https://dav.com/remote.php/dav/files/user/",
+		AccessKey: "user",
+		SecretKey: "pass",
+	})
+	assert.NoError(t, err)
+	cache.Set("setting_temp_path", t.TempDir(), 0)
+	return handler
+}
+
+func newResponse(status int, header http.Header, body string) *request.Response {
+	return &request.Response{
+		Response: &http.Response{
+			StatusCode:    status,
+			Header:        header,
+			ContentLength: int64(len(body)),
+			Body:          io.NopCloser(strings.NewReader(body)),
+		},
+	}
+}
+
+func TestNewDriver(t *testing.T) {
+	a := assert.New(t)
+	_, err := NewDriver(&model.Policy{})
+	a.Error(err)
+
+	handler := newTestDriver(t)
+	a.Equal("https://dav.com/remote.php/dav/files/user/dir/a%20b.txt", handler.url("/dir/a b.txt"))
+}
+
+func TestDriver_Put(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t)
+
+	// 文件已存在
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "HEAD", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(200, http.Header{}, ""))
+		handler.client = clientMock
+		err := handler.Put(context.Background(), &fsctx.FileStream{
+			SavePath: "dir/a.txt",
+			File:     io.NopCloser(strings.NewReader("123")),
+		})
+		clientMock.AssertExpectations(t)
+		a.Equal(ErrFileExisted, err)
+	}
+
+	// 上传到临时文件后移动
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "HEAD", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(404, http.Header{}, ""))
+		clientMock.On("Request", "MKCOL", "https://dav.com/remote.php/dav/files/user/dir/", testMock.Anything, testMock.Anything).
+			Return(newResponse(405, http.Header{}, ""))
+		clientMock.On("Request", "PUT", "https://dav.com/remote.php/dav/files/user/dir/a.txt"+uploadTempSuffix, testMock.Anything, testMock.Anything).
+			Return(newResponse(201, http.Header{}, ""))
+		clientMock.On("Request", "MOVE", "https://dav.com/remote.php/dav/files/user/dir/a.txt"+uploadTempSuffix, testMock.Anything, testMock.Anything).
+			Return(newResponse(201, http.Header{}, ""))
+		handler.client = clientMock
+		err := handler.Put(context.Background(), &fsctx.FileStream{
+			SavePath: "dir/a.txt",
+			Size:     3,
+			File:     io.NopCloser(strings.NewReader("123")),
+		})
+		clientMock.AssertExpectations(t)
+		a.NoError(err)
+	}
+
+	// 移动时目标已存在
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "MKCOL", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(201, http.Header{}, ""))
+		clientMock.On("Request", "PUT", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(201, http.Header{}, ""))
+		clientMock.On("Request", "MOVE", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(412, http.Header{}, ""))
+		clientMock.On("Request", "DELETE", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(204, http.Header{}, ""))
+		handler.client = clientMock
+		err := handler.putFile(context.Background(), "dir/a.txt", strings.NewReader("123"), 3, false)
+		clientMock.AssertExpectations(t)
+		a.Equal(ErrFileExisted, err)
+	}
+}
+
+func TestDriver_Mkcol(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t)
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "MKCOL", "https://dav.com/remote.php/dav/files/user/a/b/", testMock.Anything, testMock.Anything).
+		Return(newResponse(409, http.Header{}, "")).Once()
+	clientMock.On("Request", "MKCOL", "https://dav.com/remote.php/dav/files/user/a/", testMock.Anything, testMock.Anything).
+		Return(newResponse(201, http.Header{}, "")).Once()
+	clientMock.On("Request", "MKCOL", "https://dav.com/remote.php/dav/files/user/a/b/", testMock.Anything, testMock.Anything).
+		Return(newResponse(201, http.Header{}, "")).Once()
+	handler.client = clientMock
+
+	a.NoError(handler.mkcol(context.Background(), "a/b"))
+	clientMock.AssertExpectations(t)
+}
+
+func TestDriver_ChunkUpload(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t)
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "HEAD", testMock.Anything, testMock.Anything, testMock.Anything).
+		Return(newResponse(404, http.Header{}, ""))
+	handler.client = clientMock
+	put := func(start uint64, data string) error {
+		mode := fsctx.Append
+		if start > 0 {
+			mode |= fsctx.Overwrite
+		}
+		return handler.Put(context.Background(), &fsctx.FileStream{
+			SavePath:    "a.txt",
+			Mode:        mode,
+			AppendStart: start,
+			File:        io.NopCloser(strings.NewReader(data)),
+		})
+	}
+
+	a.NoError(put(0, "123"))
+	a.Equal(ErrChunkOffset, put(6, "789"))
+	a.NoError(put(3, "xxx"))
+	a.NoError(put(3, "456"))
+	content, _ := os.ReadFile(driver.StagingPath(handler.Policy, "a.txt"))
+	a.Equal("123456", string(content))
+
+	// 上传至服务器后删除暂存文件
+	var uploaded string
+	clientMock.On("Request", "MKCOL", testMock.Anything, testMock.Anything, testMock.Anything).
+		Return(newResponse(405, http.Header{}, ""))
+	clientMock.On("Request", "PUT", testMock.Anything, testMock.MatchedBy(func(body io.Reader) bool {
+		if body == nil {
+			return false
+		}
+		content, _ := io.ReadAll(body)
+		uploaded = string(content)
+		return true
+	}), testMock.Anything).Return(newResponse(201, http.Header{}, ""))
+	clientMock.On("Request", "MOVE", testMock.Anything, testMock.Anything, testMock.Anything).
+		Return(newResponse(204, http.Header{}, ""))
+	a.NoError(handler.CompleteUpload(context.Background(), "a.txt"))
+	a.Equal("123456", uploaded)
+	a.NoFileExists(driver.StagingPath(handler.Policy, "a.txt"))
+
+	// 取消上传时删除暂存文件
+	a.NoError(put(0, "123"))
+	a.NoError(handler.CancelToken(context.Background(), &serializer.UploadSession{SavePath: "a.txt"}))
+	a.NoFileExists(driver.StagingPath(handler.Policy, "a.txt"))
+	a.NoError(handler.CancelToken(context.Background(), &serializer.UploadSession{SavePath: "a.txt"}))
+}
+
+func TestDriver_Truncate(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t)
+
+	// 已上传至服务器的文件取回本机暂存
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "HEAD", testMock.Anything, testMock.Anything, testMock.Anything).
+		Return(newResponse(200, http.Header{}, "123456"))
+	clientMock.On("Request", "GET", testMock.Anything, testMock.Anything, testMock.Anything).
+		Return(newResponse(206, http.Header{}, "123456"))
+	clientMock.On("Request", "DELETE", testMock.Anything, testMock.Anything, testMock.Anything).
+		Return(newResponse(204, http.Header{}, ""))
+	handler.client = clientMock
+
+	a.NoError(handler.Truncate(context.Background(), "a.txt", 3))
+	clientMock.AssertExpectations(t)
+	content, _ := os.ReadFile(driver.StagingPath(handler.Policy, "a.txt"))
+	a.Equal("123", string(content))
+}
+
+func TestDriver_Get(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t)
+
+	// 文件不存在
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "HEAD", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(404, http.Header{}, ""))
+		handler.client = clientMock
+		_, err := handler.Get(context.Background(), "a.txt")
+		a.ErrorIs(err, os.ErrNotExist)
+	}
+
+	// 服务端支持范围请求
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "HEAD", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(200, http.Header{}, "0123456789"))
+		clientMock.On("Request", "GET", testMock.Anything, testMock.Anything, testMock.MatchedBy(func(opts []request.Option) bool {
+			return len(opts) > 0
+		})).Return(newResponse(206, http.Header{}, "56789"))
+		handler.client = clientMock
+
+		file, err := handler.Get(context.Background(), "a.txt")
+		a.NoError(err)
+		size, err := file.Seek(0, io.SeekEnd)
+		a.NoError(err)
+		a.EqualValues(10, size)
+		_, err = file.Seek(5, io.SeekStart)
+		a.NoError(err)
+		content, err := io.ReadAll(file)
+		a.NoError(err)
+		a.Equal("56789", string(content))
+		a.NoError(file.Close())
+	}
+
+	// 服务端不支持范围请求
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "HEAD", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(200, http.Header{}, "0123456789"))
+		clientMock.On("Request", "GET", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(200, http.Header{}, "0123456789"))
+		handler.client = clientMock
+
+		file, err := handler.Get(context.Background(), "a.txt")
+		a.NoError(err)
+		_, err = file.Seek(7, io.SeekStart)
+		a.NoError(err)
+		content, err := io.ReadAll(file)
+		a.NoError(err)
+		a.Equal("789", string(content))
+	}
+}
+
+func TestDriver_Delete(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t)
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "DELETE", testMock.MatchedBy(func(target string) bool {
+		return strings.HasSuffix(target, "/1.txt")
+	}), testMock.Anything, testMock.Anything).Return(newResponse(204, http.Header{}, ""))
+	clientMock.On("Request", "DELETE", testMock.MatchedBy(func(target string) bool {
+		return strings.HasSuffix(target, "/2.txt")
+	}), testMock.Anything, testMock.Anything).Return(newResponse(404, http.Header{}, ""))
+	clientMock.On("Request", "DELETE", testMock.MatchedBy(func(target string) bool {
+		return strings.HasSuffix(target, "/3.txt")
+	}), testMock.Anything, testMock.Anything).Return(&request.Response{Err: errors.New("error")})
+	handler.client = clientMock
+
+	failed, err := handler.Delete(context.Background(), []string{"1.txt", "2.txt", "3.txt"})
+	clientMock.AssertExpectations(t)
+	a.Error(err)
+	a.Equal([]string{"3.txt"}, failed)
+}
+
+func TestDriver_List(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t)
+	clientMock := &requestmock.RequestMock{}
+	clientMock.On("Request", "PROPFIND", "https://dav.com/remote.php/dav/files/user/dir/", testMock.Anything, testMock.Anything).
+		Return(newResponse(207, http.Header{}, `<?xml version="1.0"?>
+<d:multistatus xmlns:d="DAV:">
+<d:response><d:href>/remote.php/dav/files/user/dir/</d:href><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+<d:response><d:href>/remote.php/dav/files/user/dir/a%20b.txt</d:href><d:propstat><d:prop><d:resourcetype/><d:getcontentlength>10</d:getcontentlength><d:getlastmodified>Mon, 27 Jan 2020 08:00:00 GMT</d:getlastmodified></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+<d:response><d:href>/remote.php/dav/files/user/dir/c.txt.cloudreve_upload</d:href><d:propstat><d:prop><d:resourcetype/></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+<d:response><d:href>https://dav.com/remote.php/dav/files/user/dir/sub/</d:href><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+</d:multistatus>`))
+	clientMock.On("Request", "PROPFIND", "https://dav.com/remote.php/dav/files/user/dir/sub/", testMock.Anything, testMock.Anything).
+		Return(newResponse(207, http.Header{}, `<?xml version="1.0"?>
+<d:multistatus xmlns:d="DAV:">
+<d:response><d:href>/remote.php/dav/files/user/dir/sub/</d:href><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+<d:response><d:href>/remote.php/dav/files/user/dir/sub/d.txt</d:href><d:propstat><d:prop><d:getcontentlength>5</d:getcontentlength></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+</d:multistatus>`))
+	handler.client = clientMock
+
+	res, err := handler.List(context.Background(), "/dir", true)
+	clientMock.AssertExpectations(t)
+	a.NoError(err)
+	a.Len(res, 3)
+	a.Equal("a b.txt", res[0].Name)
+	a.EqualValues(10, res[0].Size)
+	a.Equal(2020, res[0].LastModify.Year())
+	a.True(res[1].IsDir)
+	a.Equal("sub/d.txt", res[2].RelativePath)
+	a.Equal("dir/sub/d.txt", res[2].Source)
+}
+
+func TestDriver_Token(t *testing.T) {
+	a := assert.New(t)
+	handler := newTestDriver(t)
+	handler.Policy.OptionsSerialized.ChunkSize = 10
+
+	// 文件已存在
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "HEAD", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(200, http.Header{}, ""))
+		handler.client = clientMock
+		_, err := handler.Token(context.Background(), 10, &serializer.UploadSession{SavePath: "a.txt"}, &fsctx.FileStream{})
+		a.Error(err)
+	}
+
+	// 成功
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "HEAD", testMock.Anything, testMock.Anything, testMock.Anything).
+			Return(newResponse(404, http.Header{}, ""))
+		handler.client = clientMock
+		res, err := handler.Token(context.Background(), 10, &serializer.UploadSession{Key: "key", SavePath: "a.txt"}, &fsctx.FileStream{})
+		a.NoError(err)
+		a.Equal("key", res.SessionID)
+		a.EqualValues(10, res.ChunkSize)
+	}
+}
@@ -0,0 +1,101 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+)
+
+// rangeReader 可定位的远程文件，读取时从当前位置发送范围请求
+type rangeReader struct {
+	ctx     context.Context
+	handler *Driver
+	target  string
+	size    int64
+	offset  int64
+	body    io.ReadCloser
+}
+
+// open 从当前位置开始请求文件内容
+func (r *rangeReader) open() error {
+	resp := r.handler.request(r.ctx, "GET", r.target, nil,
+		http.Header{"Range": {fmt.Sprintf("bytes=%d-", r.offset)}},
+		request.WithTimeout(time.Duration(0)),
+	)
+	if resp.Err != nil {
+		return resp.Err
+	}
+
+	body := resp.Response.Body
+	switch resp.Response.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// 服务端不支持范围请求时跳过已读取的部分
+		if _, err := io.CopyN(io.Discard, body, r.offset); err != nil {
+			body.Close()
+			return err
+		}
+	default:
+		body.Close()
+		return fmt.Errorf("unexpected status code %d", resp.Response.StatusCode)
+	}
+
+	r.body = body
+	return nil
+}
+
+// Read 读取文件内容
+func (r *rangeReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+// Seek 设置读取位置，位置变化时关闭当前请求
+func (r *rangeReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return r.offset, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return r.offset, errors.New("negative position")
+	}
+
+	if offset != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+
+	r.offset = offset
+	return offset, nil
+}
+
+// Close 关闭当前请求
+func (r *rangeReader) Close() error {
+	if r.body != nil {
+		return r.body.Close()
+	}
+
+	return nil
+}
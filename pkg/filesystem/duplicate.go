@@ -0,0 +1,244 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// duplicateGroup 同一存储策略中内容相同的一组文件
+type duplicateGroup struct {
+	model.DuplicateDigest
+	files []model.File
+}
+
+// key 返回组的唯一标识
+func (group *duplicateGroup) key() string {
+	return duplicateKey(group.PolicyID, group.Hash, group.Size)
+}
+
+func duplicateKey(policyID uint, hash string, size uint64) string {
+	return fmt.Sprintf("%d/%s/%d", policyID, hash, size)
+}
+
+// reclaimable 合并为同一物理文件后可释放的存储空间
+func (group *duplicateGroup) reclaimable() uint64 {
+	if group.Blobs < 2 {
+		return 0
+	}
+	return uint64(group.Blobs-1) * group.Size
+}
+
+// duplicateGroups 列出用户空间内内容相同的文件组，hashes 不为空时只列出摘要在其中的组
+func (fs *FileSystem) duplicateGroups(hashes []string) ([]*duplicateGroup, error) {
+	digests, err := model.GetDuplicateDigests(fs.User.ID)
+	if err != nil {
+		return nil, ErrDBListObjects.WithError(err)
+	}
+
+	filter := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		filter[hash] = true
+	}
+
+	groups := make([]*duplicateGroup, 0, len(digests))
+	index := make(map[string]*duplicateGroup, len(digests))
+	groupHashes := make([]string, 0, len(digests))
+	for _, digest := range digests {
+		if len(filter) > 0 && !filter[digest.Hash] {
+			continue
+		}
+
+		group := &duplicateGroup{DuplicateDigest: digest}
+		groups = append(groups, group)
+		index[group.key()] = group
+		groupHashes = append(groupHashes, digest.Hash)
+	}
+
+	if len(groups) == 0 {
+		return groups, nil
+	}
+
+	files, err := model.GetFilesByHashes(fs.User.ID, groupHashes)
+	if err != nil {
+		return nil, ErrDBListObjects.WithError(err)
+	}
+
+	for _, file := range files {
+		if group, ok := index[duplicateKey(file.PolicyID, file.Hash, file.Size)]; ok {
+			group.files = append(group.files, file)
+		}
+	}
+
+	return groups, nil
+}
+
+// FindDuplicates 按文件大小从大到小列出用户空间内内容相同的文件组
+func (fs *FileSystem) FindDuplicates(ctx context.Context) ([]serializer.DuplicateGroup, error) {
+	groups, err := fs.duplicateGroups(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// 各文件所在目录的完整路径
+	parents := make(map[uint]string)
+	for _, group := range groups {
+		for _, file := range group.files {
+			parents[file.FolderID] = ""
+		}
+	}
+
+	folderIDs := make([]uint, 0, len(parents))
+	for id := range parents {
+		folderIDs = append(folderIDs, id)
+	}
+
+	if len(folderIDs) > 0 {
+		folders, err := model.GetFoldersByIDs(folderIDs, fs.User.ID)
+		if err != nil {
+			return nil, ErrDBListObjects.WithError(err)
+		}
+
+		for i := range folders {
+			if err := folders[i].TraceRoot(); err != nil {
+				util.Log().Warning("Failed to trace root of folder %d: %s", folders[i].ID, err)
+			}
+			parents[folders[i].ID] = path.Join(folders[i].Position, folders[i].Name)
+		}
+	}
+
+	res := make([]serializer.DuplicateGroup, 0, len(groups))
+	for _, group := range groups {
+		objects := make([]serializer.Object, 0, len(group.files))
+		for _, file := range group.files {
+			objects = append(objects, serializer.Object{
+				ID:         hashid.HashID(file.ID, hashid.FileID),
+				Name:       file.Name,
+				Path:       parents[file.FolderID],
+				Thumb:      file.ShouldLoadThumb(),
+				Size:       file.Size,
+				Type:       "file",
+				Date:       file.UpdatedAt,
+				CreateDate: file.CreatedAt,
+				Starred:    file.Starred,
+			})
+		}
+
+		res = append(res, serializer.DuplicateGroup{
+			Hash:        group.Hash,
+			Size:        group.Size,
+			Policy:      hashid.HashID(group.PolicyID, hashid.PolicyID),
+			Reclaimable: group.reclaimable(),
+			Files:       objects,
+		})
+	}
+
+	return res, nil
+}
+
+// Deduplicate 将内容相同的文件合并为引用同一物理文件，并删除不再被引用的多余物理文件。
+// hashes 为空时处理所有组。返回释放的存储空间
+func (fs *FileSystem) Deduplicate(ctx context.Context, hashes []string) (uint64, error) {
+	groups, err := fs.duplicateGroups(hashes)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		reclaimed uint64
+		lastErr   error
+	)
+	for _, group := range groups {
+		if group.reclaimable() == 0 {
+			continue
+		}
+
+		freed, err := fs.deduplicateGroup(ctx, group)
+		reclaimed += freed
+		if err != nil {
+			util.Log().Warning("Failed to deduplicate files with hash %q: %s", group.Hash, err)
+			lastErr = err
+		}
+	}
+
+	return reclaimed, lastErr
+}
+
+// deduplicateGroup 将组内文件切换至被引用最多的物理文件
+func (fs *FileSystem) deduplicateGroup(ctx context.Context, group *duplicateGroup) (uint64, error) {
+	if len(group.files) == 0 {
+		return 0, nil
+	}
+
+	// 每个物理文件选取一个代表文件，文件按 ID 排序，相同引用数时保留最早的物理文件
+	references := make(map[string]int)
+	representatives := make([]*model.File, 0, group.Blobs)
+	for i := range group.files {
+		source := group.files[i].SourceName
+		if references[source] == 0 {
+			representatives = append(representatives, &group.files[i])
+		}
+		references[source]++
+	}
+
+	keep := representatives[0]
+	for _, file := range representatives[1:] {
+		if references[file.SourceName] > references[keep.SourceName] {
+			keep = file
+		}
+	}
+
+	dedupFs := &FileSystem{User: fs.User, Policy: keep.GetPolicy()}
+	if err := dedupFs.DispatchHandler(); err != nil {
+		return 0, err
+	}
+
+	var reclaimed uint64
+	for _, file := range representatives {
+		if file.SourceName == keep.SourceName {
+			continue
+		}
+
+		source := file.SourceName
+		sidecars := []string{source}
+		if model.IsTrueVal(file.MetadataSerialized[model.ThumbSidecarMetadataKey]) {
+			sidecars = append(sidecars, file.ThumbFile())
+		}
+		sidecars = append(sidecars, file.HLSFiles()...)
+		sidecars = append(sidecars, file.TranscodedFiles()...)
+
+		// 切换所有引用此物理文件的记录，包括回收站中的文件
+		if err := file.MigrateSource(keep.PolicyID, keep.SourceName); err != nil {
+			return reclaimed, err
+		}
+
+		// 历史版本可能仍引用原物理文件
+		if referenced, err := model.IsSourceReferenced(keep.PolicyID, source); err != nil || referenced {
+			continue
+		}
+
+		if _, err := dedupFs.Handler.Delete(ctx, sidecars); err != nil {
+			util.Log().Warning("Failed to delete duplicated file %q: %s", source, err)
+			continue
+		}
+
+		reclaimed += group.Size
+	}
+
+	return reclaimed, nil
+}
+
+// ComputeDigest 读取文件在所在存储策略中的物理文件，计算并保存摘要
+func (fs *FileSystem) ComputeDigest(ctx context.Context, file *model.File) error {
+	digestFs := &FileSystem{User: fs.User, Policy: file.GetPolicy()}
+	if err := digestFs.DispatchHandler(); err != nil {
+		return err
+	}
+
+	return digestFs.updateDigest(ctx, file)
+}
@@ -0,0 +1,132 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_FindDuplicates(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(
+			sqlmock.NewRows([]string{"policy_id", "hash", "size", "files", "blobs"}).AddRow(1, "h", 10, 3, 2))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(
+			sqlmock.NewRows([]string{"id", "name", "policy_id", "hash", "size", "folder_id", "source_name"}).
+				AddRow(1, "1.txt", 1, "h", 10, 1, "a").
+				AddRow(2, "2.txt", 1, "h", 10, 2, "a").
+				AddRow(3, "3.txt", 1, "h", 10, 2, "b"))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(
+			sqlmock.NewRows([]string{"id", "name", "parent_id"}).AddRow(1, "/", nil).AddRow(2, "docs", 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(
+			sqlmock.NewRows([]string{"id", "name", "parent_id"}).AddRow(1, "/", nil))
+		res, err := fs.FindDuplicates(context.Background())
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.Len(res, 1)
+		a.EqualValues(10, res[0].Reclaimable)
+		a.Equal(hashid.HashID(1, hashid.PolicyID), res[0].Policy)
+		a.Len(res[0].Files, 3)
+		a.Equal("/", res[0].Files[0].Path)
+		a.Equal("/docs", res[0].Files[2].Path)
+	}
+
+	// 没有重复文件
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(
+			sqlmock.NewRows([]string{"policy_id", "hash", "size", "files", "blobs"}))
+		res, err := fs.FindDuplicates(context.Background())
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.Empty(res)
+	}
+
+	// 统计失败
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("error"))
+		_, err := fs.FindDuplicates(context.Background())
+		a.NoError(mock.ExpectationsWereMet())
+		a.Error(err)
+	}
+}
+
+func TestFileSystem_Deduplicate(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	a.NoError(cache.Set("policy_177", model.Policy{Model: gorm.Model{ID: 177}, Type: "local"}, 0))
+	defer cache.Deletes([]string{"177"}, "policy_")
+
+	// 过滤后没有需要合并的组
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(
+			sqlmock.NewRows([]string{"policy_id", "hash", "size", "files", "blobs"}).AddRow(177, "h", 10, 2, 2))
+		reclaimed, err := fs.Deduplicate(context.Background(), []string{"other"})
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.Zero(reclaimed)
+	}
+
+	// 已引用同一物理文件
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(
+			sqlmock.NewRows([]string{"policy_id", "hash", "size", "files", "blobs"}).AddRow(177, "h", 10, 2, 1))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(
+			sqlmock.NewRows([]string{"id", "policy_id", "hash", "size", "source_name"}).
+				AddRow(1, 177, "h", 10, "a").
+				AddRow(2, 177, "h", 10, "a"))
+		reclaimed, err := fs.Deduplicate(context.Background(), nil)
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.Zero(reclaimed)
+	}
+
+	// 合并成功，保留引用最多的物理文件
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(
+			sqlmock.NewRows([]string{"policy_id", "hash", "size", "files", "blobs"}).AddRow(177, "h", 10, 3, 2))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(
+			sqlmock.NewRows([]string{"id", "policy_id", "hash", "size", "source_name"}).
+				AddRow(1, 177, "h", 10, "tests/dedup_b").
+				AddRow(2, 177, "h", 10, "tests/dedup_a").
+				AddRow(3, 177, "h", 10, "tests/dedup_a"))
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(177, "tests/dedup_b").WillReturnRows(
+			sqlmock.NewRows([]string{"id", "size"}).AddRow(1, 10))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WithArgs(sqlmock.AnyArg(), 177, "tests/dedup_a", 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		reclaimed, err := fs.Deduplicate(context.Background(), []string{"h"})
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.EqualValues(10, reclaimed)
+	}
+
+	// 切换物理文件失败
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(
+			sqlmock.NewRows([]string{"policy_id", "hash", "size", "files", "blobs"}).AddRow(177, "h", 10, 2, 2))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(
+			sqlmock.NewRows([]string{"id", "policy_id", "hash", "size", "source_name"}).
+				AddRow(1, 177, "h", 10, "a").
+				AddRow(2, 177, "h", 10, "b"))
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		reclaimed, err := fs.Deduplicate(context.Background(), nil)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Error(err)
+		a.Zero(reclaimed)
+	}
+}
@@ -10,17 +10,51 @@ var (
 	ErrUnknownPolicyType        = serializer.NewError(serializer.CodeInternalSetting, "Unknown policy type", nil)
 	ErrFileSizeTooBig           = serializer.NewError(serializer.CodeFileTooLarge, "File is too large", nil)
 	ErrFileExtensionNotAllowed  = serializer.NewError(serializer.CodeFileTypeNotAllowed, "File type not allowed", nil)
+	ErrContentTypeNotAllowed    = serializer.NewError(serializer.CodeFileTypeNotAllowed, "File content type not allowed", nil)
 	ErrInsufficientCapacity     = serializer.NewError(serializer.CodeInsufficientCapacity, "Insufficient capacity", nil)
 	ErrIllegalObjectName        = serializer.NewError(serializer.CodeIllegalObjectName, "Invalid object name", nil)
 	ErrClientCanceled           = errors.New("Client canceled operation")
+	ErrBlobNotExist             = errors.New("No identical blob found for instant upload")
 	ErrRootProtected            = serializer.NewError(serializer.CodeRootProtected, "Root protected", nil)
 	ErrInsertFileRecord         = serializer.NewError(serializer.CodeDBError, "Failed to create file record", nil)
 	ErrFileExisted              = serializer.NewError(serializer.CodeObjectExist, "Object existed", nil)
 	ErrFileUploadSessionExisted = serializer.NewError(serializer.CodeConflictUploadOngoing, "Upload session existed", nil)
+	ErrUploadSessionNotExist    = serializer.NewError(serializer.CodeUploadSessionExpired, "Upload session not exist or expired", nil)
+	ErrUploadNotResumable       = serializer.NewError(serializer.CodePolicyNotAllowed, "Upload session of this policy cannot be resumed", nil)
+	ErrVirusDetected            = serializer.NewError(serializer.CodeVirusDetected, "Virus detected in uploaded file", nil)
+	ErrVirusScanFailed          = serializer.NewError(serializer.CodeVirusScanFailed, "Failed to scan uploaded file", nil)
+	ErrVirusScanOversize        = serializer.NewError(serializer.CodeUploadRejected, "File is too large to be scanned for viruses", nil)
+	ErrChecksumMismatch         = serializer.NewError(serializer.CodeChecksumMismatch, "Uploaded file checksum mismatch", nil)
+	ErrUploadRejected           = serializer.NewError(serializer.CodeUploadRejected, "Upload rejected by webhook", nil)
+	ErrUploadWebhookFailed      = serializer.NewError(serializer.CodeUploadWebhookFailed, "Failed to request upload webhook", nil)
+	ErrFileRetained             = serializer.NewError(serializer.CodeFileRetained, "File is under retention and cannot be modified", nil)
+	ErrFileArchived             = serializer.NewError(serializer.CodeFileArchived, "File is archived and must be restored before access", nil)
 	ErrPathNotExist             = serializer.NewError(serializer.CodeParentNotExist, "Path not exist", nil)
 	ErrObjectNotExist           = serializer.NewError(serializer.CodeParentNotExist, "Object not exist", nil)
 	ErrIO                       = serializer.NewError(serializer.CodeIOFailed, "Failed to read file data", nil)
 	ErrDBListObjects            = serializer.NewError(serializer.CodeDBError, "Failed to list object records", nil)
 	ErrDBDeleteObjects          = serializer.NewError(serializer.CodeDBError, "Failed to delete object records", nil)
+	ErrDBRestoreObjects         = serializer.NewError(serializer.CodeDBError, "Failed to restore object records", nil)
+	ErrDBGetStorage             = serializer.NewError(serializer.CodeDBError, "Failed to get user storage", nil)
+	ErrPolicyNotExist           = serializer.NewError(serializer.CodePolicyNotExist, "Storage policy not exist", nil)
+	ErrSlaveNodeOffline         = serializer.NewError(serializer.CodeNodeOffline, "Slave node of storage policy is offline", nil)
 	ErrOneObjectOnly            = serializer.ParamErr("You can only copy one object at the same time", nil)
+	ErrThumbGenerating          = serializer.NewError(serializer.CodeNotFound, "Thumbnail is being generated", nil)
+	ErrNotTextFile              = serializer.NewError(serializer.CodeFileTypeNotAllowed, "File is not a text file", nil)
+	ErrAudioNotSupported        = serializer.NewError(serializer.CodeFileTypeNotAllowed, "Audio format not supported", nil)
+	ErrMediaNotTranscodable     = serializer.NewError(serializer.CodeFileTypeNotAllowed, "Media format does not need transcoding", nil)
+	ErrFileLocked               = serializer.NewError(serializer.CodeFileLocked, "File is locked by another client", nil)
+	ErrServerSideCopyNotSupport = serializer.NewError(serializer.CodePolicyNotAllowed, "Storage policy does not support server-side copy", nil)
+	ErrInvalidPropertyName      = serializer.NewError(serializer.CodeParamErr, "Invalid property name", nil)
+	ErrPropertyExceeded         = serializer.NewError(serializer.CodeFilePropertyExceeded, "File properties exceed the limit", nil)
+	ErrInvalidListCursor        = serializer.NewError(serializer.CodeParamErr, "Invalid list cursor", nil)
+	ErrCopyToSameUser           = serializer.NewError(serializer.CodeParamErr, "Cannot copy objects to the same user", nil)
+	ErrTemplateTooLarge         = serializer.NewError(serializer.CodeParamErr, "Folder template contains too many folders", nil)
+	ErrUnknownManifestFormat    = serializer.NewError(serializer.CodeParamErr, "Unknown manifest format", nil)
+	ErrShareNotMountable        = serializer.NewError(serializer.CodeParamErr, "Only folder shares of other users without download limit can be mounted", nil)
+	ErrMountUnavailable         = serializer.NewError(serializer.CodeShareLinkNotFound, "Mounted share is unavailable", nil)
+	ErrMountPassword            = serializer.NewError(serializer.CodeIncorrectPassword, "Incorrect share password", nil)
+	ErrMountReadOnly            = serializer.NewError(serializer.CodeNoPermissionErr, "Mounted share is read-only", nil)
+	ErrChangeCursorExpired      = serializer.NewError(serializer.CodeChangeCursorExpired, "Change cursor expired, full sync required", nil)
+	ErrWatermarkFailed          = serializer.NewError(serializer.CodeIOFailed, "Failed to add watermark", nil)
 )
@@ -0,0 +1,73 @@
+package filesystem
+
+import (
+	"context"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// ApplyExpirations 按到期规则删除对象或将其移入回收站，未开启回收站时直接删除。
+// 对象已不存在时仅删除规则，处理失败的规则保留至下次重试
+func (fs *FileSystem) ApplyExpirations(ctx context.Context, expirations []model.Expiration) error {
+	trashEnabled := model.IsTrueVal(model.GetSettingByName("trash_enabled"))
+
+	var lastErr error
+	for i := range expirations {
+		if err := fs.applyExpiration(ctx, &expirations[i], trashEnabled); err != nil {
+			util.Log().Warning("Failed to apply expiration %d: %s", expirations[i].ID, err)
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+func (fs *FileSystem) applyExpiration(ctx context.Context, expiration *model.Expiration, trashEnabled bool) error {
+	var (
+		dirs, files []uint
+		activities  []model.Activity
+	)
+	if expiration.IsDir {
+		folders, err := model.GetFoldersByIDs([]uint{expiration.ObjectID}, fs.User.ID)
+		if err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+
+		for i := range folders {
+			dirs = append(dirs, folders[i].ID)
+			activities = append(activities, model.NewFolderActivity(model.ActivityDelete, &folders[i], ""))
+		}
+	} else {
+		fileModels, err := model.GetFilesByIDs([]uint{expiration.ObjectID}, fs.User.ID)
+		if err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+
+		for i := range fileModels {
+			files = append(files, fileModels[i].ID)
+			activities = append(activities, model.NewFileActivity(model.ActivityDelete, &fileModels[i], ""))
+		}
+	}
+
+	if len(activities) > 0 {
+		fs.CleanTargets()
+		var err error
+		if trashEnabled && expiration.Action == model.ExpireActionTrash {
+			err = fs.Trash(ctx, dirs, files)
+		} else {
+			err = fs.Delete(ctx, dirs, files, false, false)
+		}
+		if err != nil {
+			return err
+		}
+
+		RecordActivities(activities...)
+	}
+
+	if err := expiration.Delete(); err != nil {
+		return ErrDBDeleteObjects.WithError(err)
+	}
+
+	return nil
+}
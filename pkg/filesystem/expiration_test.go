@@ -0,0 +1,40 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_ApplyExpirations(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	cache.Set("setting_trash_enabled", "1", 0)
+
+	// 对象已不存在，仅删除规则
+	{
+		expiration := model.Expiration{ObjectID: 2, IsDir: true, Action: model.ExpireActionTrash}
+		expiration.ID = 1
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)expirations(.+)").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		a.NoError(fs.ApplyExpirations(context.Background(), []model.Expiration{expiration}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 查询失败，保留规则
+	{
+		expiration := model.Expiration{ObjectID: 3, Action: model.ExpireActionDelete}
+		expiration.ID = 2
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("error"))
+		a.Error(fs.ApplyExpirations(context.Background(), []model.Expiration{expiration}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
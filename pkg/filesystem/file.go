@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
@@ -12,7 +13,8 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
-	"github.com/juju/ratelimit"
+	"github.com/cloudreve/Cloudreve/v3/pkg/watermark"
+	"github.com/gin-gonic/gin"
 )
 
 /* ============
@@ -30,18 +32,49 @@ func (r lrs) Read(p []byte) (int, error) {
 	return r.r.Read(p)
 }
 
-// withSpeedLimit 给原有的ReadSeeker加上限速
-func (fs *FileSystem) withSpeedLimit(rs response.RSCloser) response.RSCloser {
-	// 如果用户组有速度限制，就返回限制流速的ReaderSeeker
-	if fs.User.Group.SpeedLimit != 0 {
-		speed := fs.User.Group.SpeedLimit
-		bucket := ratelimit.NewBucketWithRate(float64(speed), int64(speed))
-		lrs := lrs{rs, ratelimit.Reader(rs, bucket)}
-		return lrs
+// ginWriter 将 gin 响应的写入转交给包装后的 http.ResponseWriter
+type ginWriter struct {
+	gin.ResponseWriter
+	w http.ResponseWriter
+}
+
+func (w ginWriter) Write(p []byte) (int, error) {
+	return w.w.Write(p)
+}
+
+func (w ginWriter) WriteString(s string) (int, error) {
+	return w.w.Write([]byte(s))
+}
+
+// WithDownloadSpeedLimit 按用户下载限速包装 gin 响应并统计下载流量
+func (fs *FileSystem) WithDownloadSpeedLimit(c *gin.Context) {
+	if w := fs.WrapDownloadWriter(c.Writer); w != c.Writer {
+		c.Writer = ginWriter{c.Writer, w}
+	}
+}
+
+// WrapDownloadWriter 按用户下载限速包装响应并统计下载流量，同一用户的所有下载连接共享令牌桶，
+// 匿名用户（如从机收到的下载请求）每个请求单独限速
+func (fs *FileSystem) WrapDownloadWriter(w http.ResponseWriter) http.ResponseWriter {
+	if fs.User == nil {
+		return w
+	}
+
+	w = fs.withDownloadTraffic(w)
+
+	speed := fs.User.DownloadSpeedLimit()
+	if speed <= 0 {
+		return w
 	}
-	// 否则返回原始流
-	return rs
 
+	var bucket *speedBucket
+	if fs.User.ID == 0 {
+		bucket = newSpeedBucket(speed)
+	} else {
+		bucket = downloadBuckets.get(fs.User.ID, speed)
+	}
+
+	return throttledWriter{w, bucket}
 }
 
 // AddFile 新增文件记录
@@ -52,6 +85,11 @@ func (fs *FileSystem) AddFile(ctx context.Context, parent *model.Folder, file fs
 		return nil, err
 	}
 
+	// 记录文件使用的存储类型，归档存储中的文件需解冻后才能访问
+	if class := fs.Policy.GetStorageClass(file.Info().Metadata); class != "" {
+		file.SetMetadata(model.StorageClassMetadataKey, class)
+	}
+
 	uploadInfo := file.Info()
 	newFile := model.File{
 		Name:               uploadInfo.FileName,
@@ -62,6 +100,9 @@ func (fs *FileSystem) AddFile(ctx context.Context, parent *model.Folder, file fs
 		PolicyID:           fs.Policy.ID,
 		MetadataSerialized: uploadInfo.Metadata,
 		UploadSessionID:    uploadInfo.UploadSessionID,
+		Hash:               uploadInfo.Hash,
+		MD5:                uploadInfo.MD5,
+		SHA1:               uploadInfo.SHA1,
 	}
 
 	err = newFile.Create()
@@ -89,7 +130,7 @@ func (fs *FileSystem) GetPhysicalFileContent(ctx context.Context, path string) (
 		return nil, err
 	}
 
-	return fs.withSpeedLimit(rs), nil
+	return rs, nil
 }
 
 // Preview 预览文件
@@ -109,6 +150,12 @@ func (fs *FileSystem) Preview(ctx context.Context, id uint, isText bool) (*respo
 		return nil, ErrFileSizeTooBig
 	}
 
+	// 需要添加水印时，由服务端读取文件并添加水印后返回
+	if text, ok := ctx.Value(fsctx.WatermarkCtx).(string); ok && text != "" && !isText &&
+		watermark.Supported(fs.FileTarget[0].Name) {
+		return fs.previewWatermarked(ctx, text)
+	}
+
 	// 是否直接返回文件内容
 	if isText || fs.Policy.IsDirectlyPreview() {
 		resp, err := fs.GetDownloadContent(ctx, id)
@@ -135,17 +182,9 @@ func (fs *FileSystem) Preview(ctx context.Context, id uint, isText bool) (*respo
 
 }
 
-// GetDownloadContent 获取用于下载的文件流
+// GetDownloadContent 获取用于下载的文件流，下载限速由 WithDownloadSpeedLimit 在响应端处理
 func (fs *FileSystem) GetDownloadContent(ctx context.Context, id uint) (response.RSCloser, error) {
-	// 获取原始文件流
-	rs, err := fs.GetContent(ctx, id)
-	if err != nil {
-		return nil, err
-	}
-
-	// 返回限速处理后的文件流
-	return fs.withSpeedLimit(rs), nil
-
+	return fs.GetContent(ctx, id)
 }
 
 // GetContent 获取文件内容，path为虚拟路径
@@ -162,6 +201,7 @@ func (fs *FileSystem) GetContent(ctx context.Context, id uint) (response.RSClose
 		return nil, ErrIO.WithError(err)
 	}
 
+	fs.touchFile(&fs.FileTarget[0])
 	return rs, nil
 }
 
@@ -171,27 +211,40 @@ func (fs *FileSystem) deleteGroupedFile(ctx context.Context, files map[uint][]*m
 	// 失败的文件列表
 	// TODO 并行删除
 	failed := make(map[uint][]string, len(files))
-	thumbs := make([]string, 0)
+	sidecars := make([]string, 0)
 
 	for policyID, toBeDeletedFiles := range files {
 		// 列举出需要物理删除的文件的物理路径
 		sourceNamesAll := make([]string, 0, len(toBeDeletedFiles))
 		uploadSessions := make([]*serializer.UploadSession, 0, len(toBeDeletedFiles))
+		sourceNamesSeen := make(map[string]bool, len(toBeDeletedFiles))
 
 		for i := 0; i < len(toBeDeletedFiles); i++ {
+			// 去重存储中多个文件可共用同一物理文件，只需删除一次
+			if sourceNamesSeen[toBeDeletedFiles[i].SourceName] {
+				continue
+			}
+			sourceNamesSeen[toBeDeletedFiles[i].SourceName] = true
 			sourceNamesAll = append(sourceNamesAll, toBeDeletedFiles[i].SourceName)
 
 			if toBeDeletedFiles[i].UploadSessionID != nil {
 				if session, ok := cache.Get(UploadSessionCachePrefix + *toBeDeletedFiles[i].UploadSessionID); ok {
 					uploadSession := session.(serializer.UploadSession)
 					uploadSessions = append(uploadSessions, &uploadSession)
+				} else if uploadSession, ok := uploadSessionFromMetadata(toBeDeletedFiles[i]); ok {
+					// 会话已过期，根据占位文件记录的信息取消存储端的未完成上传
+					uploadSessions = append(uploadSessions, uploadSession)
 				}
 			}
 
 			// Check if sidecar thumb file exist
 			if model.IsTrueVal(toBeDeletedFiles[i].MetadataSerialized[model.ThumbSidecarMetadataKey]) {
-				thumbs = append(thumbs, toBeDeletedFiles[i].ThumbFile())
+				sidecars = append(sidecars, toBeDeletedFiles[i].ThumbFile())
 			}
+
+			// 视频转码生成的 HLS 文件
+			sidecars = append(sidecars, toBeDeletedFiles[i].HLSFiles()...)
+			sidecars = append(sidecars, toBeDeletedFiles[i].TranscodedFiles()...)
 		}
 
 		// 切换上传策略
@@ -209,14 +262,15 @@ func (fs *FileSystem) deleteGroupedFile(ctx context.Context, files map[uint][]*m
 			}
 
 			cache.Deletes([]string{upSession.Key}, UploadSessionCachePrefix)
+			ReleaseCapacity(upSession.Key)
 		}
 
 		// 执行删除
-		toBeDeletedSrcs := append(sourceNamesAll, thumbs...)
+		toBeDeletedSrcs := append(sourceNamesAll, sidecars...)
 		failedFile, _ := fs.Handler.Delete(ctx, toBeDeletedSrcs)
 
-		// Exclude failed results related to thumb file
-		failed[policyID] = util.SliceDifference(failedFile, thumbs)
+		// Exclude failed results related to thumb, HLS and transcoded files
+		failed[policyID] = util.SliceDifference(failedFile, sidecars)
 	}
 
 	return failed
@@ -298,13 +352,18 @@ func (fs *FileSystem) SignURL(ctx context.Context, file *model.File, ttl int64,
 		return "", err
 	}
 
+	if err := fs.checkRestored(ctx, &fs.FileTarget[0]); err != nil {
+		return "", err
+	}
+
 	// 签名最终URL
 	// 生成外链地址
-	source, err := fs.Handler.Source(ctx, fs.FileTarget[0].SourceName, ttl, isDownload, fs.User.Group.SpeedLimit)
+	source, err := fs.Handler.Source(ctx, fs.FileTarget[0].SourceName, ttl, isDownload, fs.User.DownloadSpeedLimit())
 	if err != nil {
 		return "", serializer.NewError(serializer.CodeNotSet, "Failed to get source link", err)
 	}
 
+	fs.touchFile(&fs.FileTarget[0])
 	return source, nil
 }
 
@@ -366,6 +425,51 @@ func (fs *FileSystem) resetPolicyToFirstFile(ctx context.Context) error {
 
 // Search 搜索文件
 func (fs *FileSystem) Search(ctx context.Context, keywords ...interface{}) ([]serializer.Object, error) {
+	parents, err := fs.searchParents()
+	if err != nil {
+		return nil, err
+	}
+
+	files, _ := model.GetFilesByKeywords(fs.User.ID, parents, keywords...)
+	fs.SetTargetFile(&files)
+
+	return fs.listObjects(ctx, "/", files, nil, nil), nil
+}
+
+// SearchByFilter 搜索满足筛选条件的文件
+func (fs *FileSystem) SearchByFilter(ctx context.Context, filter *model.FileFilter) ([]serializer.Object, error) {
+	parents, err := fs.searchParents()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := model.GetFilesByFilter(fs.User.ID, parents, filter)
+	if err != nil {
+		return nil, ErrDBListObjects.WithError(err)
+	}
+	fs.SetTargetFile(&files)
+
+	return fs.listObjects(ctx, "/", files, nil, nil), nil
+}
+
+// ListStarred 列出用户加了星标的全部文件和目录
+func (fs *FileSystem) ListStarred(ctx context.Context) ([]serializer.Object, error) {
+	folders, err := model.GetStarredFolders(fs.User.ID)
+	if err != nil {
+		return nil, ErrDBListObjects.WithError(err)
+	}
+
+	files, err := model.GetStarredFiles(fs.User.ID)
+	if err != nil {
+		return nil, ErrDBListObjects.WithError(err)
+	}
+	fs.SetTargetFile(&files)
+
+	return fs.listObjects(ctx, "/", files, folders, nil), nil
+}
+
+// searchParents 返回搜索范围内的所有目录 ID，未限定根目录时返回空列表
+func (fs *FileSystem) searchParents() ([]uint, error) {
 	parents := make([]uint, 0)
 
 	// 如果限定了根目录，则只在这个根目录下搜索。
@@ -380,8 +484,5 @@ func (fs *FileSystem) Search(ctx context.Context, keywords ...interface{}) ([]se
 		}
 	}
 
-	files, _ := model.GetFilesByKeywords(fs.User.ID, parents, keywords...)
-	fs.SetTargetFile(&files)
-
-	return fs.listObjects(ctx, "/", files, nil, nil), nil
+	return parents, nil
 }
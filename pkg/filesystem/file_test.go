@@ -3,6 +3,7 @@ package filesystem
 import (
 	"context"
 	"errors"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
 )
@@ -50,6 +52,7 @@ func TestFileSystem_AddFile(t *testing.T) {
 
 	mock.ExpectBegin()
 	mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectExec("UPDATE(.+)storage(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -188,6 +191,75 @@ func TestFileSystem_GetDownloadContent(t *testing.T) {
 	asserts.NoError(mock.ExpectationsWereMet())
 }
 
+func TestFileSystem_WithDownloadSpeedLimit(t *testing.T) {
+	a := assert.New(t)
+	gin.SetMode(gin.TestMode)
+
+	// 无限速
+	{
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		origin := c.Writer
+		fs := &FileSystem{User: &model.User{}}
+		fs.WithDownloadSpeedLimit(c)
+		a.Equal(ginWriter{origin, trafficWriter{ResponseWriter: origin, fs: fs}}, c.Writer)
+	}
+
+	// 用户组限速
+	{
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		fs := &FileSystem{User: &model.User{Group: model.Group{SpeedLimit: 1024}}}
+		fs.WithDownloadSpeedLimit(c)
+		a.IsType(throttledWriter{}, c.Writer.(ginWriter).w)
+		_, err := c.Writer.WriteString("123")
+		a.NoError(err)
+		a.Equal("123", rec.Body.String())
+	}
+
+	// 管理员设置的限速覆盖用户组设置
+	{
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		origin := c.Writer
+		unlimited := 0
+		fs := &FileSystem{User: &model.User{Group: model.Group{SpeedLimit: 1024}}}
+		fs.User.OptionsSerialized.DownloadSpeedLimit = &unlimited
+		fs.WithDownloadSpeedLimit(c)
+		a.Equal(ginWriter{origin, trafficWriter{ResponseWriter: origin, fs: fs}}, c.Writer)
+	}
+
+	// 未登录用户不包装
+	{
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		origin := c.Writer
+		fs := &FileSystem{}
+		fs.WithDownloadSpeedLimit(c)
+		a.Equal(origin, c.Writer)
+	}
+}
+
+func TestFileSystem_WrapDownloadWriter(t *testing.T) {
+	a := assert.New(t)
+
+	// 匿名用户每个请求单独限速
+	{
+		fs := &FileSystem{User: &model.User{Group: model.Group{SpeedLimit: 1024}}}
+		rec := httptest.NewRecorder()
+		w := fs.WrapDownloadWriter(rec)
+		a.IsType(throttledWriter{}, w)
+		a.NotSame(w.(throttledWriter).bucket, fs.WrapDownloadWriter(rec).(throttledWriter).bucket)
+		_, err := w.Write([]byte("123"))
+		a.NoError(err)
+		a.Equal("123", rec.Body.String())
+	}
+
+	// 同一用户共享令牌桶
+	{
+		fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 20}, Group: model.Group{SpeedLimit: 1024}}}
+		rec := httptest.NewRecorder()
+		a.Same(fs.WrapDownloadWriter(rec).(throttledWriter).bucket, fs.WrapDownloadWriter(rec).(throttledWriter).bucket)
+	}
+}
+
 func TestFileSystem_GroupFileByPolicy(t *testing.T) {
 	asserts := assert.New(t)
 	ctx := context.Background()
@@ -638,6 +710,61 @@ func TestFileSystem_Preview(t *testing.T) {
 		asserts.Equal(ErrFileSizeTooBig, err)
 		asserts.Nil(resp)
 	}
+
+	// 添加水印，大小超出限制
+	{
+		fs := FileSystem{
+			User: &model.User{},
+		}
+		fs.FileTarget = []model.File{
+			{
+				Name:       "photo.png",
+				SourceName: "tests/photo.png",
+				PolicyID:   1,
+				Policy: model.Policy{
+					Model: gorm.Model{ID: 1},
+					Type:  "remote",
+				},
+				Size: 11,
+			},
+		}
+		asserts.NoError(cache.Set("setting_share_watermark_max_size", "10", 0))
+		resp, err := fs.Preview(context.WithValue(ctx, fsctx.WatermarkCtx, "127.0.0.1"), 1, false)
+		asserts.Equal(ErrFileSizeTooBig, err)
+		asserts.Nil(resp)
+	}
+
+	// 添加水印，文件内容无法解析，不回退至原始文件
+	{
+		file, err := util.CreatNestedFile(util.RelativePath("tests/watermark.png"))
+		asserts.NoError(err)
+		file.WriteString("not a png")
+		file.Close()
+		defer os.Remove(util.RelativePath("tests/watermark.png"))
+
+		fs := FileSystem{
+			User: &model.User{},
+		}
+		fs.FileTarget = []model.File{
+			{
+				Name:       "watermark.png",
+				SourceName: "tests/watermark.png",
+				PolicyID:   1,
+				Policy: model.Policy{
+					Model: gorm.Model{ID: 1},
+					Type:  "local",
+				},
+				Size: 9,
+			},
+		}
+		asserts.NoError(cache.Set("setting_share_watermark_font", "", 0))
+		asserts.NoError(cache.Set("setting_thumb_pdf_path", "pdftoppm", 0))
+		asserts.NoError(cache.Set("setting_temp_path", "tests", 0))
+		resp, err := fs.Preview(context.WithValue(ctx, fsctx.WatermarkCtx, "127.0.0.1"), 1, false)
+		asserts.Error(err)
+		asserts.Equal(ErrWatermarkFailed.Msg, err.(serializer.AppError).Msg)
+		asserts.Nil(resp)
+	}
 }
 
 func TestFileSystem_ResetFileIDIfNotExist(t *testing.T) {
@@ -667,3 +794,35 @@ func TestFileSystem_Search(t *testing.T) {
 	asserts.NoError(err)
 	asserts.Len(res, 1)
 }
+
+func TestFileSystem_ListStarred(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	fs := &FileSystem{
+		User: &model.User{},
+	}
+	fs.User.ID = 1
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(1, true).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "starred"}).AddRow(1, "dir", true))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(1, true).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "starred"}).AddRow(2, "file", true))
+		res, err := fs.ListStarred(ctx)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(res, 2)
+		asserts.True(res[0].Starred)
+		asserts.True(res[1].Starred)
+	}
+
+	// 列取目录失败
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnError(errors.New("error"))
+		res, err := fs.ListStarred(ctx)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.Nil(res)
+	}
+}
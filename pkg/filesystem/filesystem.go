@@ -7,23 +7,36 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/cluster"
 	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/azure"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/chunker"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/cos"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/dropbox"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/encrypt"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/ftp"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/gcs"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/googledrive"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/hdfs"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/ipfs"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/onedrive"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/oss"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/qiniu"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/remote"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/s3"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/sftp"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/shadow/masterinslave"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/shadow/slaveinmaster"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/swift"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/upyun"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/webdav"
 	"github.com/cloudreve/Cloudreve/v3/pkg/request"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/gin-gonic/gin"
 	cossdk "github.com/tencentyun/cos-go-sdk-v5"
 	"net/http"
 	"net/url"
+	"path"
 	"sync"
 )
 
@@ -114,14 +127,40 @@ func NewAnonymousFileSystem() (*FileSystem, error) {
 		fs.User.Group = anonymousGroup
 	} else {
 		// 从机模式下，分配本地策略处理器
-		fs.Handler = local.Driver{}
+		handler, err := NewLocalHandler(nil)
+		if err != nil {
+			return nil, err
+		}
+		fs.Handler = handler
 	}
 
 	return fs, nil
 }
 
-// DispatchHandler 根据存储策略分配文件适配器
+// NewLocalHandler 创建本机存储适配器，配置了主密钥时使用透明加密适配器包装
+func NewLocalHandler(policy *model.Policy) (driver.Handler, error) {
+	handler := local.Driver{Policy: policy}
+	if !encrypt.Enabled() && (policy == nil || !policy.OptionsSerialized.Encryption) {
+		return handler, nil
+	}
+
+	return encrypt.NewDriver(handler, policy)
+}
+
+// DispatchHandler 根据存储策略分配文件适配器，开启分块存储时使用 chunker 包装
 func (fs *FileSystem) DispatchHandler() error {
+	if err := fs.dispatchHandler(); err != nil {
+		return err
+	}
+
+	if fs.Handler != nil && fs.Policy.OptionsSerialized.ChunkerSize > 0 {
+		fs.Handler = chunker.NewDriver(fs.Handler, fs.Policy)
+	}
+
+	return nil
+}
+
+func (fs *FileSystem) dispatchHandler() error {
 	if fs.Policy == nil {
 		return errors.New("未设置存储策略")
 	}
@@ -132,10 +171,9 @@ func (fs *FileSystem) DispatchHandler() error {
 	case "mock", "anonymous":
 		return nil
 	case "local":
-		fs.Handler = local.Driver{
-			Policy: currentPolicy,
-		}
-		return nil
+		handler, err := NewLocalHandler(currentPolicy)
+		fs.Handler = handler
+		return err
 	case "remote":
 		handler, err := remote.NewDriver(currentPolicy)
 		if err != nil {
@@ -168,9 +206,10 @@ func (fs *FileSystem) DispatchHandler() error {
 				Transport: &cossdk.AuthorizationTransport{
 					SecretID:  currentPolicy.AccessKey,
 					SecretKey: currentPolicy.SecretKey,
+					Transport: request.ProxyTransport(currentPolicy.OptionsSerialized.Proxy),
 				},
 			}),
-			HTTPClient: request.NewClient(),
+			HTTPClient: request.NewClient(request.WithProxy(currentPolicy.OptionsSerialized.Proxy)),
 		}
 		return nil
 	case "s3":
@@ -181,6 +220,46 @@ func (fs *FileSystem) DispatchHandler() error {
 		handler, err := googledrive.NewDriver(currentPolicy)
 		fs.Handler = handler
 		return err
+	case "azure":
+		handler, err := azure.NewDriver(currentPolicy)
+		fs.Handler = handler
+		return err
+	case "gcs":
+		handler, err := gcs.NewDriver(currentPolicy)
+		fs.Handler = handler
+		return err
+	case "sftp":
+		handler, err := sftp.NewDriver(currentPolicy)
+		fs.Handler = handler
+		return err
+	case "ftp":
+		handler, err := ftp.NewDriver(currentPolicy)
+		fs.Handler = handler
+		return err
+	case "hdfs":
+		handler, err := hdfs.NewDriver(currentPolicy)
+		fs.Handler = handler
+		return err
+	case "webdav":
+		handler, err := webdav.NewDriver(currentPolicy)
+		fs.Handler = handler
+		return err
+	case "dropbox":
+		handler, err := dropbox.NewDriver(currentPolicy)
+		fs.Handler = handler
+		return err
+	case "swift":
+		handler, err := swift.NewDriver(currentPolicy)
+		fs.Handler = handler
+		return err
+	case "ipfs":
+		handler, err := ipfs.NewDriver(currentPolicy)
+		fs.Handler = handler
+		return err
+	case "mirror":
+		handler, err := fs.newMirrorHandler(currentPolicy)
+		fs.Handler = handler
+		return err
 	default:
 		return ErrUnknownPolicyType
 	}
@@ -219,6 +298,75 @@ func NewFileSystemFromCallback(c *gin.Context) (*FileSystem, error) {
 	return fs, err
 }
 
+// SwitchPolicyByPath 沿 virtualPath 的目录链查找最近绑定的存储策略并切换，
+// 未绑定或绑定的策略已不在用户组可用列表中时使用用户默认存储策略
+func (fs *FileSystem) SwitchPolicyByPath(virtualPath string) error {
+	if fs.Root != nil {
+		virtualPath = path.Join(fs.Root.Position, fs.Root.Name, virtualPath)
+	}
+
+	policyID := fs.folderPolicyID(virtualPath)
+	if policyID == 0 || !util.ContainsUint(fs.User.Group.PolicyList, policyID) {
+		policyID = fs.User.Policy.ID
+	}
+
+	if policyID != 0 && (fs.Policy == nil || fs.Policy.ID != policyID) {
+		if err := fs.switchPolicy(policyID); err != nil {
+			return err
+		}
+	}
+
+	fs.failoverPolicy()
+	return nil
+}
+
+// switchPolicy 切换至给定 ID 的存储策略
+func (fs *FileSystem) switchPolicy(policyID uint) error {
+	if policyID == fs.User.Policy.ID {
+		fs.Policy = &fs.User.Policy
+		return fs.DispatchHandler()
+	}
+
+	policy, err := model.GetPolicyByID(policyID)
+	if err != nil {
+		return ErrPolicyNotExist.WithError(err)
+	}
+
+	fs.Policy = &policy
+	return fs.DispatchHandler()
+}
+
+// failoverPolicy 从机存储策略离线且设定了备用存储策略时，切换至备用存储策略
+func (fs *FileSystem) failoverPolicy() {
+	if fs.Policy == nil || fs.Policy.Type != "remote" || remote.IsAvailable(fs.Policy.ID) {
+		return
+	}
+
+	backupID := fs.Policy.OptionsSerialized.BackupPolicyID
+	if backupID == 0 || backupID == fs.Policy.ID {
+		return
+	}
+
+	origin := fs.Policy
+	if err := fs.switchPolicy(backupID); err != nil {
+		util.Log().Warning("Failed to switch to backup policy of %q: %s", origin.Name, err)
+		fs.Policy = origin
+		fs.DispatchHandler()
+		return
+	}
+
+	util.Log().Debug("Slave node of policy %q is offline, switched to backup policy %q.", origin.Name, fs.Policy.Name)
+}
+
+// checkPolicyAvailable 从机存储策略离线时返回 ErrSlaveNodeOffline
+func (fs *FileSystem) checkPolicyAvailable() error {
+	if fs.Policy != nil && fs.Policy.Type == "remote" && !remote.IsAvailable(fs.Policy.ID) {
+		return ErrSlaveNodeOffline
+	}
+
+	return nil
+}
+
 // SwitchToSlaveHandler 将负责上传的 Handler 切换为从机节点
 func (fs *FileSystem) SwitchToSlaveHandler(node cluster.Node) {
 	fs.Handler = slaveinmaster.NewDriver(node, fs.Handler, fs.Policy)
@@ -1,6 +1,9 @@
 package filesystem
 
 import (
+	"context"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cluster"
 	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/shadow/masterinslave"
@@ -13,6 +16,7 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/remote"
 	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
 
 	"testing"
@@ -297,3 +301,92 @@ func TestFileSystem_SwitchToShadowHandler(t *testing.T) {
 		a.IsType(&masterinslave.Driver{}, fs.Handler)
 	}
 }
+
+func TestFileSystem_SwitchPolicyByPath(t *testing.T) {
+	a := assert.New(t)
+	user := &model.User{
+		Policy: model.Policy{Model: gorm.Model{ID: 1}, Type: "local"},
+		Group:  model.Group{PolicyList: []uint{1, 221}},
+	}
+	user.ID = 1
+	fs := &FileSystem{User: user, Policy: &user.Policy}
+
+	// 上级目录绑定了存储策略，子目录不存在
+	mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
+	mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(1, 1, "a").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id", "name", "policy_id"}).AddRow(2, 1, "a", 221))
+	mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(2, 1, "b").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT(.+)policies(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(221, "remote"))
+	a.NoError(fs.SwitchPolicyByPath("/a/b"))
+	a.NoError(mock.ExpectationsWereMet())
+	a.EqualValues(221, fs.Policy.ID)
+	a.IsType(&remote.Driver{}, fs.Handler)
+
+	// 未绑定存储策略时使用用户默认策略
+	mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
+	a.NoError(fs.SwitchPolicyByPath("/"))
+	a.NoError(mock.ExpectationsWereMet())
+	a.Equal(&user.Policy, fs.Policy)
+	a.IsType(local.Driver{}, fs.Handler)
+
+	// 绑定的存储策略不在用户组可用列表中
+	user.Group.PolicyList = []uint{1}
+	mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id", "policy_id"}).AddRow(1, 1, 221))
+	a.NoError(fs.SwitchPolicyByPath("/"))
+	a.NoError(mock.ExpectationsWereMet())
+	a.EqualValues(1, fs.Policy.ID)
+}
+
+func TestFileSystem_FailoverPolicy(t *testing.T) {
+	a := assert.New(t)
+	cache.Set("setting_slave_node_retry", "1", 0)
+	cache.Set("policy_232", model.Policy{Model: gorm.Model{ID: 232}, Type: "local"}, 0)
+	defer cache.Deletes([]string{"232"}, "policy_")
+	offline := &model.Policy{Model: gorm.Model{ID: 231}, Type: "remote", Server: string([]byte{0x7f})}
+	remote.Probe(context.Background(), offline)
+	offline.Server = ""
+
+	// 在线的从机策略
+	{
+		fs := &FileSystem{User: &model.User{}, Policy: &model.Policy{Model: gorm.Model{ID: 230}, Type: "remote"}}
+		fs.failoverPolicy()
+		a.EqualValues(230, fs.Policy.ID)
+		a.NoError(fs.checkPolicyAvailable())
+	}
+
+	// 离线且未设定备用策略
+	{
+		fs := &FileSystem{User: &model.User{}, Policy: offline}
+		fs.failoverPolicy()
+		a.EqualValues(231, fs.Policy.ID)
+		a.ErrorIs(fs.checkPolicyAvailable(), ErrSlaveNodeOffline)
+	}
+
+	// 切换至备用策略
+	{
+		policy := *offline
+		policy.OptionsSerialized.BackupPolicyID = 232
+		fs := &FileSystem{User: &model.User{}, Policy: &policy}
+		fs.failoverPolicy()
+		a.EqualValues(232, fs.Policy.ID)
+		a.IsType(local.Driver{}, fs.Handler)
+		a.NoError(fs.checkPolicyAvailable())
+	}
+
+	// 备用策略不存在
+	{
+		policy := *offline
+		policy.OptionsSerialized.BackupPolicyID = 233
+		mock.ExpectQuery("SELECT(.+)policies(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		fs := &FileSystem{User: &model.User{}, Policy: &policy}
+		fs.failoverPolicy()
+		a.NoError(mock.ExpectationsWereMet())
+		a.EqualValues(231, fs.Policy.ID)
+		a.IsType(&remote.Driver{}, fs.Handler)
+	}
+}
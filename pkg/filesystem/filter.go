@@ -0,0 +1,60 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+)
+
+// FileTypePatterns 各文件类型的文件名匹配模式
+var FileTypePatterns = map[string][]string{
+	"image": {"%.bmp", "%.iff", "%.png", "%.gif", "%.jpg", "%.jpeg", "%.psd", "%.svg", "%.webp"},
+	"video": {"%.mp4", "%.flv", "%.avi", "%.wmv", "%.mkv", "%.rm", "%.rmvb", "%.mov", "%.ogv"},
+	"audio": {"%.mp3", "%.flac", "%.ape", "%.wav", "%.acc", "%.ogg", "%.midi", "%.mid"},
+	"doc":   {"%.txt", "%.md", "%.pdf", "%.doc", "%.docx", "%.ppt", "%.pptx", "%.xls", "%.xlsx", "%.pub"},
+}
+
+// ErrEmptyFilter 筛选表达式为空
+var ErrEmptyFilter = errors.New("empty filter expression")
+
+// filterAndSeparator 分隔筛选表达式中各项条件的 AND，不区分大小写
+var filterAndSeparator = regexp.MustCompile(`(?i)\s+AND\s+`)
+
+// ParseFilterExpression 解析智能标签的筛选表达式，如 type:image AND tag:receipts。
+// 支持的条件有 type（image/video/audio/doc）、tag（文件标签）和 name（文件名，可使用 * 通配），
+// 条件值可用双引号包裹
+func ParseFilterExpression(exp string) (*model.FileFilter, error) {
+	exp = strings.TrimSpace(exp)
+	if exp == "" {
+		return nil, ErrEmptyFilter
+	}
+
+	filter := &model.FileFilter{}
+	for _, term := range filterAndSeparator.Split(exp, -1) {
+		key, value, ok := strings.Cut(strings.TrimSpace(term), ":")
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid filter condition %q", term)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "type":
+			patterns, ok := FileTypePatterns[strings.ToLower(value)]
+			if !ok {
+				return nil, fmt.Errorf("unknown file type %q", value)
+			}
+			filter.NameGroups = append(filter.NameGroups, patterns)
+		case "tag":
+			filter.Tags = append(filter.Tags, value)
+		case "name":
+			filter.NameGroups = append(filter.NameGroups, []string{strings.ReplaceAll(value, "*", "%")})
+		default:
+			return nil, fmt.Errorf("unknown filter condition %q", key)
+		}
+	}
+
+	return filter, nil
+}
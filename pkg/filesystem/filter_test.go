@@ -0,0 +1,44 @@
+package filesystem
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFilterExpression(t *testing.T) {
+	a := assert.New(t)
+
+	// 组合条件
+	{
+		filter, err := ParseFilterExpression(`type:image AND tag:receipts and name:"2022 *"`)
+		a.NoError(err)
+		a.Equal([][]string{FileTypePatterns["image"], {"2022 %"}}, filter.NameGroups)
+		a.Equal([]string{"receipts"}, filter.Tags)
+	}
+
+	// 标签名中包含空格
+	{
+		filter, err := ParseFilterExpression(`tag:"tax receipts"`)
+		a.NoError(err)
+		a.Equal([]string{"tax receipts"}, filter.Tags)
+	}
+
+	// 空表达式
+	{
+		_, err := ParseFilterExpression(" ")
+		a.ErrorIs(err, ErrEmptyFilter)
+	}
+
+	// 无效条件
+	{
+		_, err := ParseFilterExpression("type:unknown")
+		a.Error(err)
+		_, err = ParseFilterExpression("size:10")
+		a.Error(err)
+		_, err = ParseFilterExpression("receipts")
+		a.Error(err)
+		_, err = ParseFilterExpression("tag:")
+		a.Error(err)
+	}
+}
@@ -41,4 +41,32 @@ const (
 	WebDAVCtx
 	// WebDAV反代Url
 	WebDAVProxyUrlCtx
+	// ConflictStrategyCtx 上传文件重名时的处理方式
+	ConflictStrategyCtx
+	// SpeedLimitBucketCtx 限速令牌桶，用于在多个文件间共享限速
+	SpeedLimitBucketCtx
+	// TaskCtx 正在执行的任务，用于记录任务日志
+	TaskCtx
+	// LockTokenCtx 客户端持有的文件锁令牌
+	LockTokenCtx
+	// CameraUploadCtx 相机上传模式，上传完成后按拍摄日期归档
+	CameraUploadCtx
+	// WatermarkCtx 预览时为图片、PDF 添加的水印文字
+	WatermarkCtx
+	// ConflictOriginNameCtx 上传文件因重名改用其他文件名前的原文件名
+	ConflictOriginNameCtx
+)
+
+// ConflictStrategy 上传文件与已有文件重名时的处理方式
+type ConflictStrategy string
+
+const (
+	// ConflictFail 返回文件已存在错误，未指定时的默认行为
+	ConflictFail ConflictStrategy = "fail"
+	// ConflictOverwrite 上传完成后删除已有文件
+	ConflictOverwrite ConflictStrategy = "overwrite"
+	// ConflictRename 为上传的文件添加 " (1)" 等后缀
+	ConflictRename ConflictStrategy = "rename"
+	// ConflictVersion 上传完成后已有文件的原内容保存为历史版本，新内容成为已有文件的当前内容
+	ConflictVersion ConflictStrategy = "version"
 )
@@ -29,6 +29,9 @@ type UploadTaskInfo struct {
 	AppendStart     uint64
 	Model           interface{}
 	Src             string
+	Hash            string
+	MD5             string
+	SHA1            string
 }
 
 // Get mimetype of uploaded file, if it's not defined, detect it from file name
@@ -48,6 +51,10 @@ type FileHeader interface {
 	Info() *UploadTaskInfo
 	SetSize(uint64)
 	SetModel(fileModel interface{})
+	SetSavePath(savePath string)
+	SetName(name string)
+	SetMetadata(key, value string)
+	SetDigest(md5, sha1, sha256 string)
 	Seekable() bool
 }
 
@@ -67,6 +74,9 @@ type FileStream struct {
 	AppendStart     uint64
 	Model           interface{}
 	Src             string
+	Hash            string
+	MD5             string
+	SHA1            string
 }
 
 func (file *FileStream) Read(p []byte) (n int, err error) {
@@ -111,6 +121,9 @@ func (file *FileStream) Info() *UploadTaskInfo {
 		AppendStart:     file.AppendStart,
 		Model:           file.Model,
 		Src:             file.Src,
+		Hash:            file.Hash,
+		MD5:             file.MD5,
+		SHA1:            file.SHA1,
 	}
 }
 
@@ -121,3 +134,22 @@ func (file *FileStream) SetSize(size uint64) {
 func (file *FileStream) SetModel(fileModel interface{}) {
 	file.Model = fileModel
 }
+
+func (file *FileStream) SetSavePath(savePath string) {
+	file.SavePath = savePath
+}
+
+func (file *FileStream) SetName(name string) {
+	file.Name = name
+}
+
+func (file *FileStream) SetMetadata(key, value string) {
+	if file.Metadata == nil {
+		file.Metadata = make(map[string]string)
+	}
+	file.Metadata[key] = value
+}
+
+func (file *FileStream) SetDigest(md5, sha1, sha256 string) {
+	file.MD5, file.SHA1, file.Hash = md5, sha1, sha256
+}
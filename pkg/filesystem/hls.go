@@ -0,0 +1,246 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gofrs/uuid"
+)
+
+/* ================
+     视频转码相关
+   ================
+*/
+
+// HLSMasterPlaylist HLS 主播放列表的文件名
+const HLSMasterPlaylist = "master.m3u8"
+
+// hlsRendition HLS 转码档位
+type hlsRendition struct {
+	Height  int // 视频高度，宽度按比例缩放
+	Bitrate int // 视频码率，单位 kbps
+}
+
+// parseHLSLadder 解析转码档位设置，格式为逗号分隔的 "高度:码率(kbps)"
+func parseHLSLadder(ladder string) ([]hlsRendition, error) {
+	res := make([]hlsRendition, 0)
+	for _, rung := range strings.Split(ladder, ",") {
+		rung = strings.TrimSpace(rung)
+		if rung == "" {
+			continue
+		}
+
+		parts := strings.Split(rung, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid HLS rendition %q", rung)
+		}
+
+		height, err := strconv.Atoi(parts[0])
+		if err != nil || height <= 0 {
+			return nil, fmt.Errorf("invalid height of HLS rendition %q", rung)
+		}
+
+		bitrate, err := strconv.Atoi(parts[1])
+		if err != nil || bitrate <= 0 {
+			return nil, fmt.Errorf("invalid bitrate of HLS rendition %q", rung)
+		}
+
+		res = append(res, hlsRendition{Height: height, Bitrate: bitrate})
+	}
+
+	if len(res) == 0 {
+		return nil, errors.New("no HLS rendition configured")
+	}
+
+	return res, nil
+}
+
+// name 返回档位的目录名
+func (r hlsRendition) name() string {
+	return fmt.Sprintf("%dp", r.Height)
+}
+
+// hlsMasterPlaylist 生成引用各档位播放列表的主播放列表
+func hlsMasterPlaylist(renditions []hlsRendition) string {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for _, r := range renditions {
+		// BANDWIDTH 为峰值码率，计入 128kbps 的音频
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d\n%s/index.m3u8\n", (r.Bitrate+128)*1000, r.name())
+	}
+
+	return b.String()
+}
+
+// TranscodeHLS 调用 ffmpeg 将视频转码为多档位的 HLS 文件，保存到文件所在存储策略的 HLS 目录中
+func (fs *FileSystem) TranscodeHLS(ctx context.Context, file *model.File) error {
+	const (
+		hlsFFMpegPath   = "hls_ffmpeg_path"
+		hlsLadder       = "hls_ladder"
+		hlsSegmentTime  = "hls_segment_time"
+		tempPath        = "temp_path"
+		hlsTempDir      = "hls"
+		hlsAudioBitrate = "128k"
+	)
+	opts := model.GetSettingByNames(hlsFFMpegPath, hlsLadder, hlsSegmentTime, tempPath)
+
+	renditions, err := parseHLSLadder(opts[hlsLadder])
+	if err != nil {
+		return err
+	}
+
+	fs.FileTarget = []model.File{*file}
+	if err := fs.resetPolicyToFirstFile(ctx); err != nil {
+		return err
+	}
+
+	workDir := filepath.Join(util.RelativePath(opts[tempPath]), hlsTempDir, uuid.Must(uuid.NewV4()).String())
+	defer os.RemoveAll(workDir)
+
+	input, err := fs.ffmpegInput(ctx, file, workDir)
+	if err != nil {
+		return err
+	}
+
+	outDir := filepath.Join(workDir, "out")
+	for _, r := range renditions {
+		if err := os.MkdirAll(filepath.Join(outDir, r.name()), 0744); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+
+		cmd := exec.CommandContext(ctx, opts[hlsFFMpegPath], "-y", "-i", input,
+			"-vf", fmt.Sprintf("scale=-2:%d", r.Height),
+			"-c:v", "libx264", "-b:v", fmt.Sprintf("%dk", r.Bitrate),
+			"-c:a", "aac", "-b:a", hlsAudioBitrate,
+			"-f", "hls", "-hls_time", opts[hlsSegmentTime], "-hls_playlist_type", "vod",
+			"-hls_segment_filename", filepath.Join(outDir, r.name(), "%05d.ts"),
+			filepath.Join(outDir, r.name(), "index.m3u8"),
+		)
+
+		var stdErr bytes.Buffer
+		cmd.Stderr = &stdErr
+		if err := cmd.Run(); err != nil {
+			util.Log().Warning("Failed to invoke ffmpeg: %s", stdErr.String())
+			return fmt.Errorf("failed to transcode %q to %s: %w", file.Name, r.name(), err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(outDir, HLSMasterPlaylist), []byte(hlsMasterPlaylist(renditions)), 0644); err != nil {
+		return fmt.Errorf("failed to write master playlist: %w", err)
+	}
+
+	// 上传转码结果
+	names := make([]string, 0)
+	err = filepath.Walk(outDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(outDir, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if err := fs.Handler.Put(ctx, &fsctx.FileStream{
+			Mode:     fsctx.Overwrite,
+			File:     f,
+			Seeker:   f,
+			Size:     uint64(info.Size()),
+			Name:     filepath.Base(p),
+			SavePath: file.HLSFile(name),
+		}); err != nil {
+			return fmt.Errorf("failed to save %q: %w", name, err)
+		}
+
+		names = append(names, name)
+		return nil
+	})
+
+	if err == nil {
+		err = file.UpdateMetadata(map[string]string{model.HLSMetadataKey: strings.Join(names, ",")})
+	}
+
+	if err != nil {
+		// 失败时删除已上传的文件
+		uploaded := make([]string, 0, len(names))
+		for _, name := range names {
+			uploaded = append(uploaded, file.HLSFile(name))
+		}
+		_, _ = fs.Handler.Delete(context.Background(), uploaded)
+		return err
+	}
+
+	return nil
+}
+
+// ffmpegInput 返回 ffmpeg 可读取的原文件路径。本地存储的明文文件可直接读取，
+// 其他情况需先下载到临时目录 workDir 中
+func (fs *FileSystem) ffmpegInput(ctx context.Context, file *model.File, workDir string) (string, error) {
+	policy := file.GetPolicy()
+	if policy.Type == "local" && !policy.OptionsSerialized.Encryption && policy.OptionsSerialized.ChunkerSize == 0 {
+		return util.RelativePath(file.SourceName), nil
+	}
+
+	input := filepath.Join(workDir, "source"+filepath.Ext(file.Name))
+	if err := fs.downloadToTemp(ctx, file, input); err != nil {
+		return "", err
+	}
+
+	return input, nil
+}
+
+// downloadToTemp 将文件内容下载到本地临时文件 dst
+func (fs *FileSystem) downloadToTemp(ctx context.Context, file *model.File, dst string) error {
+	ctx = context.WithValue(ctx, fsctx.FileModelCtx, *file)
+	rs, err := fs.Handler.Get(ctx, file.SourceName)
+	if err != nil {
+		return fmt.Errorf("failed to fetch original file %q: %w", file.SourceName, err)
+	}
+	defer rs.Close()
+
+	out, err := util.CreatNestedFile(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, rs); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	return nil
+}
+
+// GetHLSContent 获取视频转码生成的 HLS 文件内容，name 为相对于 HLS 目录的路径
+func (fs *FileSystem) GetHLSContent(ctx context.Context, id uint, name string) (response.RSCloser, error) {
+	err := fs.resetFileIDIfNotExist(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	file := fs.FileTarget[0]
+	if !file.HasHLSFile(name) {
+		return nil, ErrObjectNotExist
+	}
+
+	return fs.Handler.Get(ctx, file.HLSFile(name))
+}
@@ -0,0 +1,133 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+)
+
+func TestParseHLSLadder(t *testing.T) {
+	a := assert.New(t)
+
+	res, err := parseHLSLadder("1080:5000, 720:2800,")
+	a.NoError(err)
+	a.Equal([]hlsRendition{{Height: 1080, Bitrate: 5000}, {Height: 720, Bitrate: 2800}}, res)
+
+	for _, ladder := range []string{"", "1080", "a:5000", "1080:0", "1080:5000:1"} {
+		_, err := parseHLSLadder(ladder)
+		a.Error(err, ladder)
+	}
+}
+
+func TestHLSMasterPlaylist(t *testing.T) {
+	a := assert.New(t)
+	res := hlsMasterPlaylist([]hlsRendition{{Height: 720, Bitrate: 2800}, {Height: 480, Bitrate: 1400}})
+	a.Equal("#EXTM3U\n#EXT-X-VERSION:3\n"+
+		"#EXT-X-STREAM-INF:BANDWIDTH=2928000\n720p/index.m3u8\n"+
+		"#EXT-X-STREAM-INF:BANDWIDTH=1528000\n480p/index.m3u8\n", res)
+}
+
+func TestFileSystem_TranscodeHLS(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg requires a POSIX shell")
+	}
+
+	a := assert.New(t)
+	file := &model.File{
+		Name:       "video.mp4",
+		SourceName: "video.mp4",
+		Policy:     model.Policy{Type: "mock"},
+	}
+	file.ID = 1
+	file.Policy.ID = 1
+
+	// 模拟 ffmpeg，在最后一个参数指定的位置写入播放列表
+	ffmpeg := filepath.Join(t.TempDir(), "ffmpeg")
+	a.NoError(os.WriteFile(ffmpeg, []byte("#!/bin/sh\nfor last; do :; done\necho '#EXTM3U' > \"$last\"\n"), 0755))
+	a.NoError(cache.Set("setting_hls_ffmpeg_path", ffmpeg, 0))
+	a.NoError(cache.Set("setting_hls_ladder", "720:2800", 0))
+	a.NoError(cache.Set("setting_hls_segment_time", "6", 0))
+	a.NoError(cache.Set("setting_hls_file_suffix", "._hls", 0))
+	a.NoError(cache.Set("setting_temp_path", t.TempDir(), 0))
+
+	// 转码档位设置无效
+	{
+		a.NoError(cache.Set("setting_hls_ladder", "invalid", 0))
+		fs := &FileSystem{User: &model.User{}}
+		a.Error(fs.TranscodeHLS(context.Background(), file))
+		a.NoError(cache.Set("setting_hls_ladder", "720:2800", 0))
+	}
+
+	// 获取原文件失败
+	{
+		mockHandler := &FileHeaderMock{}
+		mockHandler.On("Get", testMock.Anything, "video.mp4").Return(MockRSC{}, errors.New("error"))
+		fs := &FileSystem{User: &model.User{}, Handler: mockHandler}
+		a.Error(fs.TranscodeHLS(context.Background(), file))
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 成功
+	{
+		mockHandler := &FileHeaderMock{}
+		mockHandler.On("Get", testMock.Anything, "video.mp4").Return(MockRSC{rs: strings.NewReader("video")}, nil)
+		mockHandler.On("Put", testMock.Anything, testMock.MatchedBy(func(file fsctx.FileHeader) bool {
+			return file.Info().SavePath == "video.mp4._hls/720p/index.m3u8" || file.Info().SavePath == "video.mp4._hls/master.m3u8"
+		})).Return(nil)
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		fs := &FileSystem{User: &model.User{}, Handler: mockHandler}
+		a.NoError(fs.TranscodeHLS(context.Background(), file))
+		a.NoError(mock.ExpectationsWereMet())
+		mockHandler.AssertExpectations(t)
+		a.Equal("720p/index.m3u8,master.m3u8", file.MetadataSerialized[model.HLSMetadataKey])
+	}
+}
+
+func TestFileSystem_GetHLSContent(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(cache.Set("setting_hls_file_suffix", "._hls", 0))
+
+	newFs := func(handler *FileHeaderMock) *FileSystem {
+		fs := &FileSystem{User: &model.User{}, Handler: handler}
+		fs.SetTargetFile(&[]model.File{{
+			SourceName: "video.mp4",
+			MetadataSerialized: map[string]string{
+				model.HLSMetadataKey: "master.m3u8,720p/index.m3u8",
+			},
+			Policy: model.Policy{Type: "mock"},
+		}})
+		fs.FileTarget[0].Policy.ID = 1
+		return fs
+	}
+
+	// 不属于转码结果的文件
+	{
+		fs := newFs(&FileHeaderMock{})
+		res, err := fs.GetHLSContent(context.Background(), 1, "../video.mp4")
+		a.ErrorIs(err, ErrObjectNotExist)
+		a.Nil(res)
+	}
+
+	// 成功
+	{
+		mockHandler := &FileHeaderMock{}
+		mockHandler.On("Get", testMock.Anything, "video.mp4._hls/720p/index.m3u8").Return(MockRSC{}, nil)
+		fs := newFs(mockHandler)
+		_, err := fs.GetHLSContent(context.Background(), 1, "720p/index.m3u8")
+		a.NoError(err)
+		mockHandler.AssertExpectations(t)
+	}
+}
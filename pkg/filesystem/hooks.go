@@ -2,20 +2,37 @@ package filesystem
 
 import (
 	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/clamav"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cluster"
-	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/cloudreve/Cloudreve/v3/pkg/exif"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/thumb"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 )
 
+const (
+	// stripMetadataTempPattern 清除图片元数据时使用的临时文件名
+	stripMetadataTempPattern = "cdstrip_*"
+	// compressImageTempPattern 压缩图片时使用的临时文件名
+	compressImageTempPattern = "cdcompress_*"
+	// maxExifReadSize 读取图片 EXIF 信息时最多读取的长度
+	maxExifReadSize = 1 << 20
+)
+
 // Hook 钩子函数
 type Hook func(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error
 
@@ -78,6 +95,424 @@ func HookValidateFile(ctx context.Context, fs *FileSystem, file fsctx.FileHeader
 
 }
 
+// HookInstantUpload 用户在同一存储策略下已有相同内容的文件时，复用其物理文件并跳过传输。
+// 仅匹配用户自己的文件，客户端无需证明持有文件内容；新文件的摘要取自已有文件而非客户端提供的值
+func HookInstantUpload(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+	fileInfo := file.Info()
+	if fileInfo.Hash == "" {
+		return ErrBlobNotExist
+	}
+
+	origin, err := model.GetFileByHash(fs.User.ID, fs.Policy.ID, fileInfo.Hash, fileInfo.Size)
+	if err != nil {
+		return ErrBlobNotExist
+	}
+
+	file.SetSavePath(origin.SourceName)
+	file.SetDigest(origin.MD5, origin.SHA1, origin.Hash)
+	return nil
+}
+
+// HookValidateContentType 读取已保存文件的头部，校验其真实类型是否被存储策略允许
+func HookValidateContentType(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+	if !fs.Policy.OptionsSerialized.SniffContentType || len(fs.Policy.OptionsSerialized.FileType) == 0 {
+		return nil
+	}
+
+	rs, err := fs.Handler.Get(ctx, file.Info().SavePath)
+	if err != nil {
+		return ErrIO.WithError(err)
+	}
+	defer rs.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(rs, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return ErrIO.WithError(err)
+	}
+
+	if !fs.ValidateContentType(ctx, head[:n]) {
+		return ErrContentTypeNotAllowed
+	}
+
+	return nil
+}
+
+// HookVirusScan 将已保存的文件发送至 clamd 扫描，发现病毒时拒绝上传
+func HookVirusScan(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+	if !fs.Policy.OptionsSerialized.VirusScan {
+		return nil
+	}
+
+	address := model.GetSettingByName("clamd_address")
+	if address == "" {
+		return nil
+	}
+
+	// 超出扫描大小限制的文件无法扫描，默认拒绝上传，开启 clamd_skip_oversize 时跳过扫描
+	fileInfo := file.Info()
+	maxSize := uint64(model.GetIntSetting("clamd_max_scan_size", 0))
+	if maxSize > 0 && fileInfo.AppendStart+fileInfo.Size > maxSize {
+		if model.IsTrueVal(model.GetSettingByName("clamd_skip_oversize")) {
+			return nil
+		}
+		return ErrVirusScanOversize
+	}
+
+	client, err := clamav.NewClient(address, time.Duration(model.GetIntSetting("clamd_timeout", 60))*time.Second)
+	if err != nil {
+		return ErrVirusScanFailed.WithError(err)
+	}
+
+	rs, err := fs.Handler.Get(ctx, fileInfo.SavePath)
+	if err != nil {
+		return ErrVirusScanFailed.WithError(err)
+	}
+	defer rs.Close()
+
+	res, err := client.Scan(ctx, rs)
+	if err != nil {
+		return ErrVirusScanFailed.WithError(err)
+	}
+
+	if res.Infected {
+		util.Log().Warning("Virus %q detected in file %q uploaded by user %d.", res.Signature, fileInfo.FileName, fs.User.ID)
+		return ErrVirusDetected
+	}
+
+	return nil
+}
+
+// HookVerifyChecksum 读取已保存的文件，校验其 MD5 / SHA-256 是否与客户端提供的一致，不一致时删除文件
+func HookVerifyChecksum(expectedMD5, expectedSHA256 string) Hook {
+	return func(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+		if expectedMD5 == "" && expectedSHA256 == "" {
+			return nil
+		}
+
+		savePath := file.Info().SavePath
+		rs, err := fs.Handler.Get(ctx, savePath)
+		if err != nil {
+			return ErrIO.WithError(err)
+		}
+		defer rs.Close()
+
+		digest := newDigestReader(rs)
+		if _, err := io.Copy(io.Discard, digest); err != nil {
+			return ErrIO.WithError(err)
+		}
+
+		md5Sum, sha1Sum, sha256Sum := hex.EncodeToString(digest.md5.Sum(nil)), hex.EncodeToString(digest.sha1.Sum(nil)),
+			hex.EncodeToString(digest.sha256.Sum(nil))
+		if (expectedMD5 == "" || strings.EqualFold(md5Sum, expectedMD5)) &&
+			(expectedSHA256 == "" || strings.EqualFold(sha256Sum, expectedSHA256)) {
+			// 校验通过后才记录摘要
+			file.SetDigest(md5Sum, sha1Sum, sha256Sum)
+			if fileModel, ok := file.Info().Model.(*model.File); ok {
+				return fileModel.UpdateDigest(md5Sum, sha1Sum, sha256Sum)
+			}
+			return nil
+		}
+
+		return deleteCorruptedUpload(ctx, fs, savePath)
+	}
+}
+
+// deleteCorruptedUpload 删除校验和不一致的已上传文件
+func deleteCorruptedUpload(ctx context.Context, fs *FileSystem, savePath string) error {
+	util.Log().Warning("Checksum of uploaded file %q mismatch, deleting it.", savePath)
+	if _, err := fs.Handler.Delete(ctx, []string{savePath}); err != nil {
+		util.Log().Warning("Failed to delete corrupted file %q: %s", savePath, err)
+	}
+
+	return ErrChecksumMismatch
+}
+
+// HookComputeDigest 分片、tus 等分多次写入的上传完成后，读取已保存的文件计算并记录摘要。
+// 计算失败不影响上传结果
+func HookComputeDigest(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+	fileModel, ok := file.Info().Model.(*model.File)
+	if !ok || fileModel.Hash != "" {
+		return nil
+	}
+
+	if err := fs.updateDigest(ctx, fileModel); err != nil {
+		util.Log().Warning("Failed to compute digest of uploaded file %q: %s", fileModel.SourceName, err)
+		return nil
+	}
+
+	file.SetDigest(fileModel.MD5, fileModel.SHA1, fileModel.Hash)
+	return nil
+}
+
+// HookComputeDigestAsync 回调上传的文件由客户端直接写入存储端，在后台读取计算摘要，避免回调请求超时
+func HookComputeDigestAsync(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+	fileModel, ok := file.Info().Model.(*model.File)
+	if !ok || fileModel.Hash != "" {
+		return nil
+	}
+
+	// 文件系统在请求结束后会被回收，使用独立的实例
+	digestFs := &FileSystem{User: fs.User, Policy: fs.Policy, Handler: fs.Handler}
+	go func() {
+		if err := digestFs.updateDigest(context.Background(), fileModel); err != nil {
+			util.Log().Warning("Failed to compute digest of uploaded file %q: %s", fileModel.SourceName, err)
+		}
+	}()
+
+	return nil
+}
+
+// HookStripImageMetadata 存储策略开启元数据清除时，清除已保存图片中的 EXIF、GPS 等元数据并覆盖原文件
+func HookStripImageMetadata(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+	fileInfo := file.Info()
+	if !fs.Policy.OptionsSerialized.StripMetadata || !exif.Supported(fileInfo.FileName) {
+		return nil
+	}
+
+	rs, err := fs.Handler.Get(ctx, fileInfo.SavePath)
+	if err != nil {
+		return ErrIO.WithError(err)
+	}
+
+	tempFile, err := os.CreateTemp("", stripMetadataTempPattern)
+	if err != nil {
+		rs.Close()
+		return ErrIO.WithError(err)
+	}
+	defer func() {
+		tempFile.Close()
+		os.Remove(tempFile.Name())
+	}()
+
+	stripped, err := exif.Strip(fileInfo.FileName, rs, tempFile)
+	rs.Close()
+	if err != nil {
+		// 无法解析的图片保持原样
+		util.Log().Warning("Failed to strip metadata of file %q: %s", fileInfo.SavePath, err)
+		return nil
+	}
+
+	if !stripped {
+		return nil
+	}
+
+	_, err = replaceFileContent(ctx, fs, file, tempFile)
+	return err
+}
+
+// HookExtractPhotoMetadata 读取已保存图片中的 EXIF 拍摄信息并记录，用于照片时间线。
+// 开启元数据清除的存储策略中图片已不含 EXIF，不会产生记录。提取失败不影响上传结果。
+func HookExtractPhotoMetadata(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+	fileInfo := file.Info()
+	fileModel, ok := fileInfo.Model.(*model.File)
+	if !ok || !exif.Readable(fileInfo.FileName) || !model.IsTrueVal(model.GetSettingByName("photo_exif_enabled")) {
+		return nil
+	}
+
+	rs, err := fs.Handler.Get(ctx, fileInfo.SavePath)
+	if err != nil {
+		util.Log().Warning("Failed to read file %q for EXIF extraction: %s", fileInfo.SavePath, err)
+		return nil
+	}
+
+	info, err := exif.Read(fileInfo.FileName, io.LimitReader(rs, maxExifReadSize))
+	rs.Close()
+	if err != nil {
+		if err != exif.ErrNoExif {
+			util.Log().Debug("Failed to extract EXIF of file %q: %s", fileInfo.SavePath, err)
+		}
+		return nil
+	}
+
+	photo := &model.Photo{
+		FileID:    fileModel.ID,
+		UserID:    fileModel.UserID,
+		TakenAt:   info.TakenAt,
+		Camera:    info.Camera(),
+		Latitude:  info.Latitude,
+		Longitude: info.Longitude,
+	}
+	if err := photo.Save(); err != nil {
+		util.Log().Warning("Failed to save photo metadata of file %q: %s", fileInfo.SavePath, err)
+	}
+
+	return nil
+}
+
+// HookDeduplicateBlob 存储策略开启去重时，若已存在内容相同的物理文件，将文件记录指向该物理文件，
+// 刚保存的物理文件不再被引用时删除。去重失败不影响上传结果。
+func HookDeduplicateBlob(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+	fileModel, ok := file.Info().Model.(*model.File)
+	if !ok || !fs.Policy.OptionsSerialized.Dedup || fileModel.UploadSessionID != nil {
+		return nil
+	}
+
+	source := fileModel.SourceName
+	if fileModel.Hash == "" {
+		// 分片上传等无法在传输时计算摘要，读取已保存的文件计算
+		rs, err := fs.Handler.Get(ctx, source)
+		if err != nil {
+			util.Log().Warning("Failed to read file %q for deduplication: %s", source, err)
+			return nil
+		}
+
+		digest := newDigestReader(rs)
+		_, err = io.Copy(io.Discard, digest)
+		rs.Close()
+		if err != nil {
+			util.Log().Warning("Failed to read file %q for deduplication: %s", source, err)
+			return nil
+		}
+
+		if err := fileModel.UpdateDigest(
+			hex.EncodeToString(digest.md5.Sum(nil)),
+			hex.EncodeToString(digest.sha1.Sum(nil)),
+			hex.EncodeToString(digest.sha256.Sum(nil)),
+		); err != nil {
+			util.Log().Warning("Failed to update digest of file %q: %s", source, err)
+			return nil
+		}
+	}
+
+	blob, err := model.GetBlobByHash(fileModel.PolicyID, fileModel.Hash, fileModel.Size, source)
+	if err != nil {
+		return nil
+	}
+
+	if err := fileModel.UpdateSourceName(blob.SourceName); err != nil {
+		util.Log().Warning("Failed to point file %q to deduplicated blob: %s", source, err)
+		return nil
+	}
+	file.SetSavePath(blob.SourceName)
+
+	// 覆盖上传等情况下原物理文件可能仍被其他文件引用
+	if referenced, err := model.IsSourceReferenced(fileModel.PolicyID, source); err != nil || referenced {
+		return nil
+	}
+
+	if _, err := fs.Handler.Delete(ctx, []string{source}); err != nil {
+		util.Log().Warning("Failed to delete duplicated file %q: %s", source, err)
+	}
+
+	return nil
+}
+
+// HookCompressImage 存储策略开启图片压缩时，缩放并重新编码已保存的图片，压缩后更小时覆盖原文件，
+// 并在文件元数据中记录压缩前的大小
+func HookCompressImage(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+	fileInfo := file.Info()
+	options := fs.Policy.OptionsSerialized
+	if !options.CompressImage || !thumb.CanCompress(fileInfo.FileName) {
+		return nil
+	}
+
+	fileModel, _ := fileInfo.Model.(*model.File)
+	originSize := fileInfo.Size
+	if fileModel != nil {
+		originSize = fileModel.Size
+	}
+
+	rs, err := fs.Handler.Get(ctx, fileInfo.SavePath)
+	if err != nil {
+		return ErrIO.WithError(err)
+	}
+
+	tempFile, err := os.CreateTemp("", compressImageTempPattern)
+	if err != nil {
+		rs.Close()
+		return ErrIO.WithError(err)
+	}
+	defer func() {
+		tempFile.Close()
+		os.Remove(tempFile.Name())
+	}()
+
+	quality := options.CompressQuality
+	if quality <= 0 || quality > 100 {
+		quality = 85
+	}
+
+	err = thumb.Compress(rs, fileInfo.FileName, options.CompressMaxSize, quality, tempFile)
+	rs.Close()
+	if err != nil {
+		// 无法解析的图片保持原样
+		util.Log().Warning("Failed to compress image %q: %s", fileInfo.SavePath, err)
+		return nil
+	}
+
+	// 压缩后没有变小时保留原图
+	if size, err := tempFile.Seek(0, io.SeekCurrent); err != nil || uint64(size) >= originSize {
+		return nil
+	}
+
+	if _, err := replaceFileContent(ctx, fs, file, tempFile); err != nil {
+		return err
+	}
+
+	metadata := map[string]string{model.CompressedFromMetadataKey: strconv.FormatUint(originSize, 10)}
+	if fileModel != nil {
+		return fileModel.UpdateMetadata(metadata)
+	}
+
+	file.SetMetadata(model.CompressedFromMetadataKey, metadata[model.CompressedFromMetadataKey])
+	return nil
+}
+
+// replaceFileContent 用临时文件中已写入的内容覆盖已保存的文件，更新文件大小及摘要，
+// 已创建的文件记录同时更新用户已用容量
+func replaceFileContent(ctx context.Context, fs *FileSystem, file fsctx.FileHeader, tempFile *os.File) (uint64, error) {
+	fileInfo := file.Info()
+	size, err := tempFile.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, ErrIO.WithError(err)
+	}
+
+	// 内容已改变，按新内容重新计算摘要
+	if _, err := tempFile.Seek(0, io.SeekStart); err != nil {
+		return 0, ErrIO.WithError(err)
+	}
+
+	md5Hash, sha1Hash, sha256Hash := md5.New(), sha1.New(), sha256.New()
+	if _, err := io.Copy(io.MultiWriter(md5Hash, sha1Hash, sha256Hash), tempFile); err != nil {
+		return 0, ErrIO.WithError(err)
+	}
+
+	if _, err := tempFile.Seek(0, io.SeekStart); err != nil {
+		return 0, ErrIO.WithError(err)
+	}
+
+	if err := fs.Handler.Put(ctx, &fsctx.FileStream{
+		File:         tempFile,
+		Seeker:       tempFile,
+		Size:         uint64(size),
+		Name:         fileInfo.FileName,
+		VirtualPath:  fileInfo.VirtualPath,
+		MimeType:     fileInfo.MimeType,
+		SavePath:     fileInfo.SavePath,
+		LastModified: fileInfo.LastModified,
+		Mode:         fsctx.Overwrite,
+	}); err != nil {
+		return 0, ErrIO.WithError(err)
+	}
+
+	md5Sum, sha1Sum, sha256Sum := hex.EncodeToString(md5Hash.Sum(nil)), hex.EncodeToString(sha1Hash.Sum(nil)),
+		hex.EncodeToString(sha256Hash.Sum(nil))
+	file.SetSize(uint64(size))
+	file.SetDigest(md5Sum, sha1Sum, sha256Sum)
+	if fileModel, ok := fileInfo.Model.(*model.File); ok && fileModel != nil {
+		if err := fileModel.UpdateSize(uint64(size)); err != nil {
+			return 0, err
+		}
+
+		if err := fileModel.UpdateDigest(md5Sum, sha1Sum, sha256Sum); err != nil {
+			return 0, err
+		}
+	}
+
+	return uint64(size), nil
+}
+
 // HookResetPolicy 重设存储策略为上下文已有文件
 func HookResetPolicy(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
 	originFile, ok := ctx.Value(fsctx.FileModelCtx).(model.File)
@@ -98,6 +533,24 @@ func HookValidateCapacity(ctx context.Context, fs *FileSystem, file fsctx.FileHe
 	return nil
 }
 
+// HookReserveCapacity 为上传预留容量，避免并发上传同时通过容量校验后超出配额
+func HookReserveCapacity(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+	return fs.ReserveCapacity(reservationKey(file), file.Info().Size, reservationTTL())
+}
+
+// HookConfirmCapacity 上传的数据计入已用容量后，从预留中扣除相应容量
+func HookConfirmCapacity(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+	ConfirmCapacity(reservationKey(file), file.Info().Size)
+	return nil
+}
+
+// HookReleaseCapacity 上传结束或失败后释放预留的容量
+func HookReleaseCapacity(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+	ReleaseCapacity(reservationKey(file))
+	streamReservations.Delete(file)
+	return nil
+}
+
 // HookValidateCapacityDiff 根据原有文件和新文件的大小验证用户容量
 func HookValidateCapacityDiff(ctx context.Context, fs *FileSystem, newFile fsctx.FileHeader) error {
 	originFile := ctx.Value(fsctx.FileModelCtx).(model.File)
@@ -110,6 +563,16 @@ func HookValidateCapacityDiff(ctx context.Context, fs *FileSystem, newFile fsctx
 	return nil
 }
 
+// HookValidateRetention 验证被覆盖的原有文件是否处于保留期内
+func HookValidateRetention(ctx context.Context, fs *FileSystem, newFile fsctx.FileHeader) error {
+	originFile := ctx.Value(fsctx.FileModelCtx).(model.File)
+	if originFile.IsRetained() {
+		return ErrFileRetained
+	}
+
+	return nil
+}
+
 // HookDeleteTempFile 删除已保存的临时文件
 func HookDeleteTempFile(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
 	// 删除临时文件
@@ -169,11 +632,25 @@ func GenericAfterUpdate(ctx context.Context, fs *FileSystem, newFile fsctx.FileH
 
 	newFile.SetModel(&originFile)
 
-	err := originFile.UpdateSize(newFile.Info().Size)
+	fileInfo := newFile.Info()
+	err := originFile.UpdateSize(fileInfo.Size)
 	if err != nil {
 		return err
 	}
 
+	// 内容寻址的存储策略中，文件内容变化后存储路径随之改变
+	if fileInfo.SavePath != "" && fileInfo.SavePath != originFile.SourceName {
+		if err := originFile.UpdateSourceName(fileInfo.SavePath); err != nil {
+			return err
+		}
+	}
+
+	// 文件内容已变化，更新摘要
+	if err := originFile.UpdateDigest(fileInfo.MD5, fileInfo.SHA1, fileInfo.Hash); err != nil {
+		return err
+	}
+
+	RecordChanges(fs.fileChange(model.ChangeModified, fileInfo, &originFile)...)
 	return nil
 }
 
@@ -193,6 +670,11 @@ func SlaveAfterUpload(session *serializer.UploadSession) Hook {
 // GenericAfterUpload 文件上传完成后，包含数据库操作
 func GenericAfterUpload(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
 	fileInfo := fileHeader.Info()
+	name := fileInfo.FileName
+	if originName, ok := ctx.Value(fsctx.ConflictOriginNameCtx).(string); ok {
+		// 重名已在生成存储路径前处理
+		name = originName
+	}
 
 	// 创建或查找根目录
 	folder, err := fs.CreateDirectory(ctx, fileInfo.VirtualPath)
@@ -209,7 +691,9 @@ func GenericAfterUpload(ctx context.Context, fs *FileSystem, fileHeader fsctx.Fi
 			return ErrFileUploadSessionExisted
 		}
 
-		return ErrFileExisted
+		if err := fs.resolveNameConflict(ctx, folder, file, fileHeader); err != nil {
+			return err
+		}
 	}
 
 	// 向数据库中插入记录
@@ -219,6 +703,19 @@ func GenericAfterUpload(ctx context.Context, fs *FileSystem, fileHeader fsctx.Fi
 	}
 	fileHeader.SetModel(file)
 
+	// 非占位文件在上传完成时处理重名
+	if file.UploadSessionID == nil {
+		if replaceName := deferredReplaceName(ctx, name, fileHeader); replaceName != "" {
+			strategy, _ := ctx.Value(fsctx.ConflictStrategyCtx).(fsctx.ConflictStrategy)
+			if err := fs.replaceConflictedFile(ctx, fileHeader, replaceName, strategy); err != nil {
+				return err
+			}
+		}
+
+		// 占位文件在提升为正式文件时记录变更
+		RecordChanges(fs.fileChange(model.ChangeCreated, fileInfo, file)...)
+	}
+
 	return nil
 }
 
@@ -231,7 +728,9 @@ func HookClearFileHeaderSize(ctx context.Context, fs *FileSystem, fileHeader fsc
 // HookTruncateFileTo 将物理文件截断至 size
 func HookTruncateFileTo(size uint64) Hook {
 	return func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
-		if handler, ok := fs.Handler.(local.Driver); ok {
+		if handler, ok := fs.Handler.(interface {
+			Truncate(ctx context.Context, src string, size uint64) error
+		}); ok {
 			return handler.Truncate(ctx, fileHeader.Info().SavePath, size)
 		}
 
@@ -239,6 +738,33 @@ func HookTruncateFileTo(size uint64) Hook {
 	}
 }
 
+// HookCompleteUpload 最后一个分片上传后，由暂存分片的适配器将暂存文件移动至存储路径
+func HookCompleteUpload(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+	if handler, ok := fs.Handler.(interface {
+		CompleteUpload(ctx context.Context, dst string) error
+	}); ok {
+		return handler.CompleteUpload(ctx, fileHeader.Info().SavePath)
+	}
+
+	// 内容寻址的适配器在上传完成后才能确定存储路径
+	if handler, ok := fs.Handler.(interface {
+		CompleteUploadWithSource(ctx context.Context, dst string) (string, error)
+	}); ok {
+		fileInfo := fileHeader.Info()
+		source, err := handler.CompleteUploadWithSource(ctx, fileInfo.SavePath)
+		if err != nil {
+			return err
+		}
+
+		fileHeader.SetSavePath(source)
+		if file, ok := fileInfo.Model.(*model.File); ok {
+			return file.UpdateSourceName(source)
+		}
+	}
+
+	return nil
+}
+
 // HookChunkUploadFinished 单个分片上传结束后
 func HookChunkUploaded(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
 	fileInfo := fileHeader.Info()
@@ -260,7 +786,12 @@ func HookPopPlaceholderToFile(picInfo string) Hook {
 	return func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
 		fileInfo := fileHeader.Info()
 		fileModel := fileInfo.Model.(*model.File)
-		return fileModel.PopChunkToFile(fileInfo.LastModified, picInfo)
+		if err := fileModel.PopChunkToFile(fileInfo.LastModified, picInfo); err != nil {
+			return err
+		}
+
+		RecordChanges(fs.fileChange(model.ChangeCreated, fileInfo, fileModel)...)
+		return nil
 	}
 }
 
@@ -302,3 +833,32 @@ func NewWebdavAfterUploadHook(request *http.Request) func(ctx context.Context, f
 		return nil
 	}
 }
+
+// UseAfterUploadHooks 注册各上传方式共用的上传完成后处理钩子：校验内容类型、查毒、处理图片、计算摘要并通知 Webhook，
+// 随后由 commit 创建或更新文件记录，再提取照片元数据、记录上传动态、去重物理文件，camera 为 true 时按拍摄日期归档。
+// 容量、上传会话等因上传方式而异的钩子由调用方注册
+func (fs *FileSystem) UseAfterUploadHooks(camera bool, commit ...Hook) {
+	// 本机存储的文件可直接读取计算摘要，其他存储端在文件记录写入后于后台计算
+	localDigest := fs.Policy == nil || fs.Policy.Type == "local"
+
+	fs.Use("AfterUpload", HookValidateContentType)
+	fs.Use("AfterUpload", HookVirusScan)
+	fs.Use("AfterUpload", HookStripImageMetadata)
+	fs.Use("AfterUpload", HookCompressImage)
+	if localDigest {
+		fs.Use("AfterUpload", HookComputeDigest)
+	}
+	fs.Use("AfterUpload", HookUploadWebhook(WebhookAfterUpload))
+	for _, hook := range commit {
+		fs.Use("AfterUpload", hook)
+	}
+	fs.Use("AfterUpload", HookExtractPhotoMetadata)
+	fs.Use("AfterUpload", HookRecordUploadActivity)
+	fs.Use("AfterUpload", HookDeduplicateBlob)
+	if camera {
+		fs.Use("AfterUpload", HookFileByCaptureDate)
+	}
+	if !localDigest {
+		fs.Use("AfterUpload", HookComputeDigestAsync)
+	}
+}
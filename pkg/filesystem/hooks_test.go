@@ -1,20 +1,31 @@
 package filesystem
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
 	"errors"
+	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/sftp"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/mocks/requestmock"
 	"github.com/cloudreve/Cloudreve/v3/pkg/request"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"image"
+	"image/png"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/jinzhu/gorm"
@@ -123,6 +134,7 @@ func TestGenericAfterUpload(t *testing.T) {
 	mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("not found"))
 	mock.ExpectBegin()
 	mock.ExpectExec("INSERT(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectExec("UPDATE(.+)storage(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -281,6 +293,585 @@ func TestHookValidateCapacity(t *testing.T) {
 	}
 }
 
+func TestHookInstantUpload(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 2}}, Policy: &model.Policy{Model: gorm.Model{ID: 1}}}
+	ctx := context.Background()
+
+	// 未提供哈希
+	{
+		file := &fsctx.FileStream{Size: 10}
+		asserts.Equal(ErrBlobNotExist, HookInstantUpload(ctx, fs, file))
+	}
+
+	// 不存在相同内容的文件
+	{
+		file := &fsctx.FileStream{Size: 10, Hash: "hash"}
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(2, 1, "hash", 10).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		asserts.Equal(ErrBlobNotExist, HookInstantUpload(ctx, fs, file))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Empty(file.SavePath)
+	}
+
+	// 成功复用
+	{
+		file := &fsctx.FileStream{Size: 10, Hash: "hash", MD5: "claimed"}
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(2, 1, "hash", 10).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name", "hash", "md5"}).AddRow(2, "origin", "hash", "md5"))
+		asserts.NoError(HookInstantUpload(ctx, fs, file))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("origin", file.SavePath)
+		asserts.Equal("md5", file.MD5)
+	}
+}
+
+func TestHookValidateContentType(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	file := &fsctx.FileStream{SavePath: "1.txt"}
+	fs := &FileSystem{
+		User: &model.User{},
+		Policy: &model.Policy{
+			OptionsSerialized: model.PolicyOption{FileType: []string{"txt"}},
+		},
+	}
+
+	// 存储策略未开启校验
+	asserts.NoError(HookValidateContentType(ctx, fs, file))
+	fs.Policy.OptionsSerialized.SniffContentType = true
+
+	// 无法读取文件
+	{
+		mockHandler := &FileHeaderMock{}
+		fs.Handler = mockHandler
+		mockHandler.On("Get", testMock.Anything, "1.txt").Return(&os.File{}, errors.New("error"))
+		asserts.Error(HookValidateContentType(ctx, fs, file))
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 真实类型不允许
+	{
+		mockHandler := &FileHeaderMock{}
+		fs.Handler = mockHandler
+		mockHandler.On("Get", testMock.Anything, "1.txt").Return(MockRSC{rs: strings.NewReader("MZ\x90\x00")}, nil)
+		asserts.Equal(ErrContentTypeNotAllowed, HookValidateContentType(ctx, fs, file))
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 成功
+	{
+		mockHandler := &FileHeaderMock{}
+		fs.Handler = mockHandler
+		mockHandler.On("Get", testMock.Anything, "1.txt").Return(MockRSC{rs: strings.NewReader("hello")}, nil)
+		asserts.NoError(HookValidateContentType(ctx, fs, file))
+		mockHandler.AssertExpectations(t)
+	}
+}
+
+func TestHookVirusScan(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	file := &fsctx.FileStream{Size: 10, SavePath: "1.txt"}
+	mockHandler := &FileHeaderMock{}
+	fs := &FileSystem{
+		User:    &model.User{},
+		Policy:  &model.Policy{},
+		Handler: mockHandler,
+	}
+
+	// 存储策略未开启扫描
+	asserts.NoError(HookVirusScan(ctx, fs, file))
+
+	// 未配置 clamd 地址
+	fs.Policy.OptionsSerialized.VirusScan = true
+	cache.Set("setting_clamd_address", "", 0)
+	asserts.NoError(HookVirusScan(ctx, fs, file))
+
+	// 超出扫描大小限制，默认拒绝
+	cache.Set("setting_clamd_address", "tcp://127.0.0.1:3310", 0)
+	cache.Set("setting_clamd_max_scan_size", "5", 0)
+	cache.Set("setting_clamd_skip_oversize", "0", 0)
+	asserts.Equal(ErrVirusScanOversize, HookVirusScan(ctx, fs, file))
+	asserts.True(IsUploadRejected(HookVirusScan(ctx, fs, file)))
+
+	// 超出扫描大小限制，设定为跳过扫描
+	cache.Set("setting_clamd_skip_oversize", "1", 0)
+	asserts.NoError(HookVirusScan(ctx, fs, file))
+
+	// 地址无效
+	cache.Set("setting_clamd_max_scan_size", "0", 0)
+	cache.Set("setting_clamd_address", "http://127.0.0.1:3310", 0)
+	err := HookVirusScan(ctx, fs, file)
+	asserts.Error(err)
+	asserts.Equal(serializer.CodeVirusScanFailed, err.(serializer.AppError).Code)
+
+	// 无法读取文件
+	cache.Set("setting_clamd_address", "tcp://127.0.0.1:3310", 0)
+	mockHandler.On("Get", testMock.Anything, "1.txt").Return(&os.File{}, errors.New("error"))
+	err = HookVirusScan(ctx, fs, file)
+	asserts.Error(err)
+	asserts.Equal(serializer.CodeVirusScanFailed, err.(serializer.AppError).Code)
+	mockHandler.AssertExpectations(t)
+}
+
+func TestHookVerifyChecksum(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	file := &fsctx.FileStream{Size: 3, SavePath: "1.txt"}
+
+	// 客户端未提供校验和
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler}
+		asserts.NoError(HookVerifyChecksum("", "")(ctx, fs, file))
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 无法读取文件
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler}
+		mockHandler.On("Get", testMock.Anything, "1.txt").Return(&os.File{}, errors.New("error"))
+		asserts.Error(HookVerifyChecksum("202cb962ac59075b964b07152d234b70", "")(ctx, fs, file))
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 校验通过
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler}
+		mockHandler.On("Get", testMock.Anything, "1.txt").Return(MockRSC{rs: strings.NewReader("123")}, nil)
+		asserts.NoError(HookVerifyChecksum(
+			"202CB962AC59075B964B07152D234B70",
+			"a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3",
+		)(ctx, fs, file))
+		mockHandler.AssertExpectations(t)
+		asserts.Equal("202cb962ac59075b964b07152d234b70", file.MD5)
+	}
+
+	// 校验通过，记录摘要
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler}
+		file := &fsctx.FileStream{Size: 3, SavePath: "1.txt", Model: &model.File{Model: gorm.Model{ID: 1}}}
+		mockHandler.On("Get", testMock.Anything, "1.txt").Return(MockRSC{rs: strings.NewReader("123")}, nil)
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").
+			WithArgs("a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3", "202cb962ac59075b964b07152d234b70", "40bd001563085fc35165329ea1ff5c5ecbdbbeef", 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(HookVerifyChecksum("202cb962ac59075b964b07152d234b70", "")(ctx, fs, file))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("40bd001563085fc35165329ea1ff5c5ecbdbbeef", file.SHA1)
+	}
+
+	// 校验不通过，删除文件
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler}
+		mockHandler.On("Get", testMock.Anything, "1.txt").Return(MockRSC{rs: strings.NewReader("124")}, nil)
+		mockHandler.On("Delete", testMock.Anything, []string{"1.txt"}).Return([]string{}, nil)
+		err := HookVerifyChecksum("", "a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3")(ctx, fs, file)
+		asserts.Error(err)
+		asserts.Equal(serializer.CodeChecksumMismatch, err.(serializer.AppError).Code)
+		mockHandler.AssertExpectations(t)
+	}
+}
+
+func TestHookComputeDigest(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+
+	// 已有摘要
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler}
+		file := &fsctx.FileStream{Model: &model.File{Hash: "hash"}}
+		asserts.NoError(HookComputeDigest(ctx, fs, file))
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 读取失败不影响上传
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler}
+		file := &fsctx.FileStream{Model: &model.File{SourceName: "1.txt"}}
+		mockHandler.On("Get", testMock.Anything, "1.txt").Return(&os.File{}, errors.New("error"))
+		asserts.NoError(HookComputeDigest(ctx, fs, file))
+		mockHandler.AssertExpectations(t)
+		asserts.Empty(file.Hash)
+	}
+
+	// 计算并记录摘要
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler}
+		file := &fsctx.FileStream{Model: &model.File{Model: gorm.Model{ID: 1}, SourceName: "1.txt"}}
+		mockHandler.On("Get", testMock.Anything, "1.txt").Return(MockRSC{rs: strings.NewReader("123")}, nil)
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").
+			WithArgs("a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3", "202cb962ac59075b964b07152d234b70", "40bd001563085fc35165329ea1ff5c5ecbdbbeef", 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(HookComputeDigest(ctx, fs, file))
+		asserts.NoError(mock.ExpectationsWereMet())
+		mockHandler.AssertExpectations(t)
+		asserts.Equal("a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3", file.Hash)
+		asserts.Equal("40bd001563085fc35165329ea1ff5c5ecbdbbeef", file.SHA1)
+	}
+}
+
+func TestHookExtractPhotoMetadata(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	tiff := "MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x10\x00\x02\x00\x00\x00\x03A7\x00\x00\x00\x00\x00\x00"
+	jpeg := "\xFF\xD8\xFF\xE1\x00\x22Exif\x00\x00" + tiff + "\xFF\xDA\x00\x02data"
+	cache.Set("setting_photo_exif_enabled", "1", 0)
+	fileModel := &model.File{UserID: 1}
+	fileModel.ID = 2
+
+	// 文件记录不存在
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler}
+		asserts.NoError(HookExtractPhotoMetadata(ctx, fs, &fsctx.FileStream{Name: "1.jpg", SavePath: "1.jpg"}))
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 不支持的格式
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler}
+		asserts.NoError(HookExtractPhotoMetadata(ctx, fs, &fsctx.FileStream{Name: "1.txt", SavePath: "1.txt", Model: fileModel}))
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 无法读取文件，不影响上传
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler}
+		mockHandler.On("Get", testMock.Anything, "1.jpg").Return(&os.File{}, errors.New("error"))
+		asserts.NoError(HookExtractPhotoMetadata(ctx, fs, &fsctx.FileStream{Name: "1.jpg", SavePath: "1.jpg", Model: fileModel}))
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 不含 EXIF
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler}
+		mockHandler.On("Get", testMock.Anything, "1.jpg").Return(MockRSC{rs: strings.NewReader("\xFF\xD8\xFF\xDA\x00\x02data")}, nil)
+		asserts.NoError(HookExtractPhotoMetadata(ctx, fs, &fsctx.FileStream{Name: "1.jpg", SavePath: "1.jpg", Model: fileModel}))
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 成功
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler}
+		mockHandler.On("Get", testMock.Anything, "1.jpg").Return(MockRSC{rs: strings.NewReader(jpeg)}, nil)
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)photos(.+)").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT(.+)photos(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(HookExtractPhotoMetadata(ctx, fs, &fsctx.FileStream{Name: "1.jpg", SavePath: "1.jpg", Model: fileModel}))
+		mockHandler.AssertExpectations(t)
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 未开启
+	{
+		cache.Set("setting_photo_exif_enabled", "0", 0)
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler}
+		asserts.NoError(HookExtractPhotoMetadata(ctx, fs, &fsctx.FileStream{Name: "1.jpg", SavePath: "1.jpg", Model: fileModel}))
+		mockHandler.AssertExpectations(t)
+	}
+}
+
+func TestHookDeduplicateBlob(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	policy := &model.Policy{OptionsSerialized: model.PolicyOption{Dedup: true}}
+
+	// 存储策略未开启
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler, Policy: &model.Policy{}}
+		file := &fsctx.FileStream{Model: &model.File{SourceName: "2.txt", Hash: "hash"}}
+		asserts.NoError(HookDeduplicateBlob(ctx, fs, file))
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 无内容相同的物理文件
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler, Policy: policy}
+		file := &fsctx.FileStream{SavePath: "2.txt", Model: &model.File{PolicyID: 1, Size: 10, SourceName: "2.txt", Hash: "hash"}}
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, "hash", 10, "2.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		asserts.NoError(HookDeduplicateBlob(ctx, fs, file))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("2.txt", file.SavePath)
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 指向已有物理文件，并删除不再被引用的物理文件
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler, Policy: policy}
+		fileModel := &model.File{PolicyID: 1, Size: 10, SourceName: "2.txt", Hash: "hash"}
+		fileModel.ID = 2
+		file := &fsctx.FileStream{SavePath: "2.txt", Model: fileModel}
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, "hash", 10, "2.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name"}).AddRow(1, "1.txt"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)source_name(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT count(.+)files(.+)").
+			WithArgs(1, "2.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery("SELECT count(.+)file_versions(.+)").
+			WithArgs(1, "2.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mockHandler.On("Delete", testMock.Anything, []string{"2.txt"}).Return([]string{}, nil)
+		asserts.NoError(HookDeduplicateBlob(ctx, fs, file))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("1.txt", file.SavePath)
+		asserts.Equal("1.txt", fileModel.SourceName)
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 无摘要时读取文件计算，原物理文件仍被引用
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler, Policy: policy}
+		fileModel := &model.File{PolicyID: 1, Size: 7, SourceName: "2.txt"}
+		fileModel.ID = 2
+		file := &fsctx.FileStream{SavePath: "2.txt", Model: fileModel}
+		sha256 := "ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73"
+		mockHandler.On("Get", testMock.Anything, "2.txt").Return(MockRSC{rs: strings.NewReader("content")}, nil)
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)hash(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, sha256, 7, "2.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name"}).AddRow(1, "1.txt"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)source_name(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT count(.+)files(.+)").
+			WithArgs(1, "2.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		asserts.NoError(HookDeduplicateBlob(ctx, fs, file))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(sha256, fileModel.Hash)
+		asserts.Equal("1.txt", file.SavePath)
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 无法读取文件，不影响上传
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler, Policy: policy}
+		file := &fsctx.FileStream{SavePath: "2.txt", Model: &model.File{SourceName: "2.txt"}}
+		mockHandler.On("Get", testMock.Anything, "2.txt").Return(&os.File{}, errors.New("error"))
+		asserts.NoError(HookDeduplicateBlob(ctx, fs, file))
+		mockHandler.AssertExpectations(t)
+	}
+}
+
+func TestHookStripImageMetadata(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	jpeg := "\xFF\xD8\xFF\xE1\x00\x05GPS\xFF\xDA\x00\x02data"
+
+	// 存储策略未开启
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler, Policy: &model.Policy{}}
+		asserts.NoError(HookStripImageMetadata(ctx, fs, &fsctx.FileStream{Name: "1.jpg", SavePath: "1.jpg"}))
+		mockHandler.AssertExpectations(t)
+	}
+
+	policy := &model.Policy{OptionsSerialized: model.PolicyOption{StripMetadata: true}}
+
+	// 不支持的格式
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler, Policy: policy}
+		asserts.NoError(HookStripImageMetadata(ctx, fs, &fsctx.FileStream{Name: "1.txt", SavePath: "1.txt"}))
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 无法读取文件
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler, Policy: policy}
+		mockHandler.On("Get", testMock.Anything, "1.jpg").Return(&os.File{}, errors.New("error"))
+		asserts.Error(HookStripImageMetadata(ctx, fs, &fsctx.FileStream{Name: "1.jpg", SavePath: "1.jpg"}))
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 无法解析的图片保持原样
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler, Policy: policy}
+		mockHandler.On("Get", testMock.Anything, "1.jpg").Return(MockRSC{rs: strings.NewReader("not a jpeg")}, nil)
+		asserts.NoError(HookStripImageMetadata(ctx, fs, &fsctx.FileStream{Name: "1.jpg", SavePath: "1.jpg"}))
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 清除后覆盖原文件
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler, Policy: policy}
+		file := &fsctx.FileStream{Name: "1.jpg", SavePath: "1.jpg", Size: uint64(len(jpeg))}
+		var content []byte
+		mockHandler.On("Get", testMock.Anything, "1.jpg").Return(MockRSC{rs: strings.NewReader(jpeg)}, nil)
+		mockHandler.On("Put", testMock.Anything, testMock.Anything).Run(func(args testMock.Arguments) {
+			header := args.Get(1).(fsctx.FileHeader)
+			asserts.Equal(fsctx.Overwrite, header.Info().Mode)
+			asserts.Equal("1.jpg", header.Info().SavePath)
+			content, _ = ioutil.ReadAll(header)
+		}).Return(nil)
+		asserts.NoError(HookStripImageMetadata(ctx, fs, file))
+		mockHandler.AssertExpectations(t)
+		asserts.Equal("\xFF\xD8\xFF\xDA\x00\x02data", string(content))
+		asserts.EqualValues(len(content), file.Size)
+		asserts.Equal(fmt.Sprintf("%x", md5.Sum(content)), file.MD5)
+		asserts.Equal(fmt.Sprintf("%x", sha256.Sum256(content)), file.Hash)
+	}
+
+	// 已创建的文件记录同时更新大小及摘要
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler, Policy: policy}
+		fileModel := &model.File{Model: gorm.Model{ID: 1}, Size: uint64(len(jpeg)), MD5: "origin"}
+		file := &fsctx.FileStream{Name: "1.jpg", SavePath: "1.jpg", Size: uint64(len(jpeg)), Model: fileModel}
+		stripped := []byte("\xFF\xD8\xFF\xDA\x00\x02data")
+		mockHandler.On("Get", testMock.Anything, "1.jpg").Return(MockRSC{rs: strings.NewReader(jpeg)}, nil)
+		mockHandler.On("Put", testMock.Anything, testMock.Anything).Return(nil)
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)users(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").
+			WithArgs(fmt.Sprintf("%x", sha256.Sum256(stripped)), fmt.Sprintf("%x", md5.Sum(stripped)), fmt.Sprintf("%x", sha1.Sum(stripped)), 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(HookStripImageMetadata(ctx, fs, file))
+		mockHandler.AssertExpectations(t)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(len(stripped), fileModel.Size)
+		asserts.Equal(fmt.Sprintf("%x", md5.Sum(stripped)), fileModel.MD5)
+	}
+}
+
+func TestHookCompressImage(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+
+	img := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	raw := &bytes.Buffer{}
+	asserts.NoError((&png.Encoder{CompressionLevel: png.NoCompression}).Encode(raw, img))
+
+	// 存储策略未开启
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler, Policy: &model.Policy{}}
+		asserts.NoError(HookCompressImage(ctx, fs, &fsctx.FileStream{Name: "1.png", SavePath: "1.png"}))
+		mockHandler.AssertExpectations(t)
+	}
+
+	policy := &model.Policy{OptionsSerialized: model.PolicyOption{CompressImage: true, CompressMaxSize: 16}}
+
+	// 不支持的格式
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler, Policy: policy}
+		asserts.NoError(HookCompressImage(ctx, fs, &fsctx.FileStream{Name: "1.gif", SavePath: "1.gif"}))
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 无法解析的图片保持原样
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler, Policy: policy}
+		mockHandler.On("Get", testMock.Anything, "1.png").Return(MockRSC{rs: strings.NewReader("not a png")}, nil)
+		asserts.NoError(HookCompressImage(ctx, fs, &fsctx.FileStream{Name: "1.png", SavePath: "1.png", Size: 9}))
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 压缩后没有变小
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler, Policy: policy}
+		mockHandler.On("Get", testMock.Anything, "1.png").Return(MockRSC{rs: bytes.NewReader(raw.Bytes())}, nil)
+		asserts.NoError(HookCompressImage(ctx, fs, &fsctx.FileStream{Name: "1.png", SavePath: "1.png", Size: 1}))
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 缩放后覆盖原文件，记录原始大小
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler, Policy: policy}
+		file := &fsctx.FileStream{Name: "1.png", SavePath: "1.png", Size: uint64(raw.Len())}
+		var content []byte
+		mockHandler.On("Get", testMock.Anything, "1.png").Return(MockRSC{rs: bytes.NewReader(raw.Bytes())}, nil)
+		mockHandler.On("Put", testMock.Anything, testMock.Anything).Run(func(args testMock.Arguments) {
+			header := args.Get(1).(fsctx.FileHeader)
+			asserts.Equal(fsctx.Overwrite, header.Info().Mode)
+			content, _ = ioutil.ReadAll(header)
+		}).Return(nil)
+		asserts.NoError(HookCompressImage(ctx, fs, file))
+		mockHandler.AssertExpectations(t)
+
+		config, err := png.DecodeConfig(bytes.NewReader(content))
+		asserts.NoError(err)
+		asserts.Equal(16, config.Width)
+		asserts.Equal(8, config.Height)
+		asserts.EqualValues(len(content), file.Size)
+		asserts.Equal(strconv.Itoa(raw.Len()), file.Metadata[model.CompressedFromMetadataKey])
+		asserts.Equal(fmt.Sprintf("%x", sha256.Sum256(content)), file.Hash)
+	}
+
+	// 已创建的文件记录同时更新大小、摘要及原始大小
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler, Policy: policy}
+		fileModel := &model.File{Model: gorm.Model{ID: 1}, Size: uint64(raw.Len()), Hash: "origin"}
+		file := &fsctx.FileStream{Name: "1.png", SavePath: "1.png", Size: uint64(raw.Len()), Model: fileModel}
+		var content []byte
+		mockHandler.On("Get", testMock.Anything, "1.png").Return(MockRSC{rs: bytes.NewReader(raw.Bytes())}, nil)
+		mockHandler.On("Put", testMock.Anything, testMock.Anything).Run(func(args testMock.Arguments) {
+			content, _ = ioutil.ReadAll(args.Get(1).(fsctx.FileHeader))
+		}).Return(nil)
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)users(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)metadata(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(HookCompressImage(ctx, fs, file))
+		mockHandler.AssertExpectations(t)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(len(content), fileModel.Size)
+		asserts.Equal(fmt.Sprintf("%x", sha256.Sum256(content)), fileModel.Hash)
+		asserts.Equal(fmt.Sprintf("%x", md5.Sum(content)), fileModel.MD5)
+		asserts.Equal(strconv.Itoa(raw.Len()), fileModel.MetadataSerialized[model.CompressedFromMetadataKey])
+	}
+}
+
 func TestHookValidateCapacityDiff(t *testing.T) {
 	a := assert.New(t)
 	fs := &FileSystem{User: &model.User{
@@ -303,6 +894,22 @@ func TestHookValidateCapacityDiff(t *testing.T) {
 
 }
 
+func TestHookValidateRetention(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{}}
+	file := model.File{Policy: model.Policy{Model: gorm.Model{ID: 1}}}
+	file.CreatedAt = time.Now()
+
+	// 未设定保留期
+	ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, file)
+	a.NoError(HookValidateRetention(ctx, fs, &fsctx.FileStream{}))
+
+	// 保留期内
+	file.Policy.OptionsSerialized.RetentionDays = 1
+	ctx = context.WithValue(context.Background(), fsctx.FileModelCtx, file)
+	a.Equal(ErrFileRetained, HookValidateRetention(ctx, fs, &fsctx.FileStream{}))
+}
+
 func TestHookResetPolicy(t *testing.T) {
 	asserts := assert.New(t)
 	fs := &FileSystem{User: &model.User{
@@ -339,7 +946,7 @@ func TestHookCleanFileContent(t *testing.T) {
 	file := &fsctx.FileStream{SavePath: "123/123"}
 	handlerMock := FileHeaderMock{}
 	handlerMock.On("Put", testMock.Anything, testMock.Anything).Return(errors.New("error"))
-	fs.Handler = handlerMock
+	fs.Handler = &handlerMock
 	err := HookCleanFileContent(context.Background(), fs, file)
 	asserts.Error(err)
 	handlerMock.AssertExpectations(t)
@@ -421,12 +1028,42 @@ func TestGenericAfterUpdate(t *testing.T) {
 			Model:   gorm.Model{ID: 1},
 			PicInfo: "1,1",
 		}
-		newFile := &fsctx.FileStream{Size: 10}
+		newFile := &fsctx.FileStream{Size: 10, MD5: "md5", SHA1: "sha1", Hash: "sha256"}
 		ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, originFile)
 
 		handlerMock := FileHeaderMock{}
 		handlerMock.On("Delete", testMock.Anything, []string{"._thumb"}).Return([]string{}, nil)
-		fs.Handler = handlerMock
+		fs.Handler = &handlerMock
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").
+			WithArgs("", 10, sqlmock.AnyArg(), 1, 0).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)users(.+)").
+			WithArgs(10, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		// 更新摘要
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").
+			WithArgs("sha256", "md5", "sha1", 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := GenericAfterUpdate(ctx, fs, newFile)
+
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+	}
+
+	// 成功 存储路径已改变
+	{
+		originFile := model.File{
+			Model:      gorm.Model{ID: 1},
+			SourceName: "old",
+		}
+		newFile := &fsctx.FileStream{Size: 10, SavePath: "new"}
+		ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, originFile)
+
 		mock.ExpectBegin()
 		mock.ExpectExec("UPDATE(.+)files(.+)").
 			WithArgs("", 10, sqlmock.AnyArg(), 1, 0).
@@ -435,6 +1072,18 @@ func TestGenericAfterUpdate(t *testing.T) {
 			WithArgs(10, sqlmock.AnyArg()).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
+		// 更新存储路径
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").
+			WithArgs("", "new", sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		// 更新摘要
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").
+			WithArgs("", "", "", 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 
 		err := GenericAfterUpdate(ctx, fs, newFile)
 
@@ -592,6 +1241,42 @@ func TestHookTruncateFileTo(t *testing.T) {
 	a.Error(HookTruncateFileTo(0)(context.Background(), fs, file))
 }
 
+func TestHookCompleteUpload(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{Handler: local.Driver{}}
+	file := &fsctx.FileStream{SavePath: "a.txt"}
+	a.NoError(HookCompleteUpload(context.Background(), fs, file))
+
+	handler, err := sftp.NewDriver(&model.Policy{Server: "127.0.0.1:1"})
+	a.NoError(err)
+	fs.Handler = handler
+	a.Error(HookCompleteUpload(context.Background(), fs, file))
+
+	// 内容寻址的适配器
+	sourceHandler := &contentAddressedHandlerMock{}
+	sourceHandler.On("CompleteUploadWithSource", testMock.Anything, "a.txt").Return("cid", nil)
+	fs.Handler = sourceHandler
+	file.Model = &model.File{Model: gorm.Model{ID: 1}}
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)files(.+)").
+		WithArgs("", "cid", sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	a.NoError(HookCompleteUpload(context.Background(), fs, file))
+	a.NoError(mock.ExpectationsWereMet())
+	sourceHandler.AssertExpectations(t)
+	a.Equal("cid", file.SavePath)
+}
+
+type contentAddressedHandlerMock struct {
+	FileHeaderMock
+}
+
+func (m *contentAddressedHandlerMock) CompleteUploadWithSource(ctx context.Context, dst string) (string, error) {
+	args := m.Called(ctx, dst)
+	return args.String(0), args.Error(1)
+}
+
 func TestHookChunkUploaded(t *testing.T) {
 	a := assert.New(t)
 	fs := &FileSystem{}
@@ -706,3 +1391,21 @@ func TestNewWebdavAfterUploadHook(t *testing.T) {
 	a.NoError(mock.ExpectationsWereMet())
 
 }
+
+func TestFileSystem_UseAfterUploadHooks(t *testing.T) {
+	a := assert.New(t)
+
+	// 本机存储，同步计算摘要
+	{
+		fs := &FileSystem{Policy: &model.Policy{Type: "local"}}
+		fs.UseAfterUploadHooks(false, GenericAfterUpload)
+		a.Len(fs.Hooks["AfterUpload"], 10)
+	}
+
+	// 其他存储端，相机上传并使用多个写入钩子
+	{
+		fs := &FileSystem{Policy: &model.Policy{Type: "s3"}}
+		fs.UseAfterUploadHooks(true, GenericAfterUpload, HookUpdateSourceName)
+		a.Len(fs.Hooks["AfterUpload"], 12)
+	}
+}
@@ -39,17 +39,26 @@ func (fs *FileSystem) GetThumb(ctx context.Context, id uint) (*response.ContentR
 	w, h := fs.GenerateThumbnailSize(0, 0)
 	ctx = context.WithValue(ctx, fsctx.ThumbSizeCtx, [2]uint{w, h})
 	ctx = context.WithValue(ctx, fsctx.FileModelCtx, file)
-	res, err := fs.Handler.Thumb(ctx, &file)
+	var res *response.ContentResponse
+	if fs.Handler.Capabilities().Thumbnail {
+		res, err = fs.Handler.Thumb(ctx, &file)
+	} else {
+		// 存储端不支持缩略图时无需请求
+		err = driver.ErrorThumbNotSupported
+	}
+
 	if errors.Is(err, driver.ErrorThumbNotExist) {
 		// Regenerate thumb if the thumb is not initialized yet
-		if generateErr := fs.generateThumbnail(ctx, &file); generateErr == nil {
+		if generateErr := fs.ensureThumbnail(ctx, &file); generateErr == nil {
 			res, err = fs.Handler.Thumb(ctx, &file)
 		} else {
 			err = generateErr
 		}
 	} else if errors.Is(err, driver.ErrorThumbNotSupported) {
-		// Policy handler explicitly indicates thumb not available, check if proxy is enabled
-		if fs.Policy.CouldProxyThumb() {
+		// Policy handler explicitly indicates thumb not available, check if proxy is enabled,
+		// or the storage node could generate the sidecar thumb by itself
+		_, nodeGenerated := fs.Handler.(thumbGenerator)
+		if fs.Policy.CouldProxyThumb() || nodeGenerated {
 			// if thumb id marked as existed, redirect to "sidecar" thumb file.
 			if file.MetadataSerialized != nil &&
 				file.MetadataSerialized[model.ThumbStatusMetadataKey] == model.ThumbStatusExist {
@@ -60,7 +69,8 @@ func (fs *FileSystem) GetThumb(ctx context.Context, id uint) (*response.ContentR
 				res.URL, err = fs.Handler.Source(ctx, file.ThumbFile(), int64(model.GetIntSetting("preview_timeout", 60)), false, 0)
 			} else {
 				// if not exist, generate and upload the sidecar thumb.
-				if err = fs.generateThumbnail(ctx, &file); err == nil {
+				if err = fs.ensureThumbnail(ctx, &file); err == nil {
+					fs.FileTarget[0] = file
 					return fs.GetThumb(ctx, id)
 				}
 			}
@@ -110,6 +120,42 @@ func (pool *Pool) releaseWorker() {
 	<-pool.worker
 }
 
+// thumbGenerator 可在存储节点上直接生成 sidecar 缩略图的适配器
+type thumbGenerator interface {
+	GenerateThumb(ctx context.Context, file *model.File) error
+}
+
+// GenerateThumbnail 为文件生成缩略图并保存为 sidecar 文件
+func (fs *FileSystem) GenerateThumbnail(ctx context.Context, file *model.File) error {
+	return fs.generateThumbnail(ctx, file)
+}
+
+// thumbGenerating 正在后台生成缩略图的文件
+var thumbGenerating sync.Map
+
+// ensureThumbnail 生成缩略图，开启异步生成时在后台队列中生成并立即返回 ErrThumbGenerating，
+// 避免列取目录时加载缩略图的请求被阻塞
+func (fs *FileSystem) ensureThumbnail(ctx context.Context, file *model.File) error {
+	if !model.IsTrueVal(model.GetSettingByName("thumb_async_enabled")) {
+		return fs.generateThumbnail(ctx, file)
+	}
+
+	key := fmt.Sprintf("%d/%s", file.PolicyID, file.SourceName)
+	if _, loaded := thumbGenerating.LoadOrStore(key, true); !loaded {
+		// 请求结束后 fs 会被回收，后台任务使用独立的文件系统
+		asyncFs := &FileSystem{User: fs.User, Policy: fs.Policy, Handler: fs.Handler}
+		target := *file
+		go func() {
+			defer thumbGenerating.Delete(key)
+			if err := asyncFs.generateThumbnail(context.Background(), &target); err != nil {
+				util.Log().Debug("Failed to generate thumbnail for %q: %s", target.Name, err)
+			}
+		}()
+	}
+
+	return ErrThumbGenerating
+}
+
 // generateThumbnail generates thumb for given file, upload the thumb file back with given suffix
 func (fs *FileSystem) generateThumbnail(ctx context.Context, file *model.File) error {
 	// 新建上下文
@@ -122,6 +168,16 @@ func (fs *FileSystem) generateThumbnail(ctx context.Context, file *model.File) e
 		return errors.New("file too large")
 	}
 
+	// 存储节点可自行生成缩略图时，无需拉取原文件
+	if handler, ok := fs.Handler.(thumbGenerator); ok {
+		if err := handler.GenerateThumb(newCtx, file); err != nil {
+			_ = updateThumbStatus(file, model.ThumbStatusNotAvailable)
+			return fmt.Errorf("failed to generate thumb for %q on storage node: %w", file.Name, err)
+		}
+
+		return updateThumbStatus(file, model.ThumbStatusExist)
+	}
+
 	getThumbWorker().addWorker()
 	defer getThumbWorker().releaseWorker()
 
@@ -146,6 +202,7 @@ func (fs *FileSystem) generateThumbnail(ctx context.Context, file *model.File) e
 		"thumb_ffmpeg_enabled",
 		"thumb_libreoffice_enabled",
 		"thumb_libraw_enabled",
+		"thumb_pdf_enabled",
 	))
 	if err != nil {
 		_ = updateThumbStatus(file, model.ThumbStatusNotAvailable)
@@ -10,11 +10,22 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/mocks/thumbmock"
 	"github.com/cloudreve/Cloudreve/v3/pkg/thumb"
 	testMock "github.com/stretchr/testify/mock"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// noThumbHandler 不支持缩略图的存储适配器
+type noThumbHandler struct {
+	*FileHeaderMock
+}
+
+func (h noThumbHandler) Capabilities() driver.Capabilities {
+	return driver.Capabilities{}
+}
+
 func TestFileSystem_GetThumb(t *testing.T) {
 	a := assert.New(t)
 	fs := &FileSystem{User: &model.User{}}
@@ -59,6 +70,22 @@ func TestFileSystem_GetThumb(t *testing.T) {
 		a.Nil(res.Content)
 	}
 
+	// handler does not support thumb, skip requesting it
+	{
+		cache.Set("setting_thumb_proxy_enabled", "0", 0)
+		fs.CleanTargets()
+		fs.SetTargetFile(&[]model.File{{
+			Policy: model.Policy{Type: "mock"},
+		}})
+		testHandller2 := new(FileHeaderMock)
+		fs.Handler = noThumbHandler{testHandller2}
+		fs.FileTarget[0].Policy.ID = 1
+		res, err := fs.GetThumb(context.Background(), 1)
+		a.ErrorIs(err, driver.ErrorThumbNotSupported)
+		a.Nil(res)
+		testHandller2.AssertNotCalled(t, "Thumb", testMock.Anything, testMock.Anything)
+	}
+
 	// thumb not initialized, failed to get source
 	{
 		fs.CleanTargets()
@@ -117,6 +144,61 @@ func TestFileSystem_GetThumb(t *testing.T) {
 	}
 }
 
+// nodeThumbHandler 可在存储节点上生成缩略图的存储适配器
+type nodeThumbHandler struct {
+	*FileHeaderMock
+	err error
+}
+
+func (h nodeThumbHandler) Thumb(ctx context.Context, file *model.File) (*response.ContentResponse, error) {
+	return nil, driver.ErrorThumbNotSupported
+}
+
+func (h nodeThumbHandler) GenerateThumb(ctx context.Context, file *model.File) error {
+	return h.err
+}
+
+func TestFileSystem_GetThumbGeneratedOnNode(t *testing.T) {
+	a := assert.New(t)
+	cache.Set("setting_thumb_proxy_enabled", "0", 0)
+	cache.Set("setting_thumb_async_enabled", "0", 0)
+	cache.Set("setting_thumb_max_src_size", "31457280", 0)
+	cache.Set("setting_thumb_file_suffix", "._thumb", 0)
+	cache.Set("setting_preview_timeout", "60", 0)
+	fs := &FileSystem{User: &model.User{}}
+
+	// 存储节点生成失败
+	{
+		fs.SetTargetFile(&[]model.File{{
+			SourceName: "1.mp4",
+			Policy:     model.Policy{Type: "mock"},
+		}})
+		fs.FileTarget[0].Policy.ID = 1
+		fs.Handler = nodeThumbHandler{FileHeaderMock: new(FileHeaderMock), err: errors.New("error")}
+		res, err := fs.GetThumb(context.Background(), 1)
+		a.Error(err)
+		a.Nil(res)
+	}
+
+	// 存储节点生成成功，重定向至 sidecar 文件，不拉取原文件
+	{
+		fs.CleanTargets()
+		fs.SetTargetFile(&[]model.File{{
+			SourceName: "1.mp4",
+			Policy:     model.Policy{Type: "mock"},
+		}})
+		fs.FileTarget[0].Policy.ID = 1
+		testHandller := new(FileHeaderMock)
+		testHandller.On("Source", testMock.Anything, "1.mp4._thumb", int64(60), false, 0).Return("https://cloudreve.org/thumb", nil)
+		fs.Handler = nodeThumbHandler{FileHeaderMock: testHandller}
+		res, err := fs.GetThumb(context.Background(), 1)
+		a.NoError(err)
+		a.True(res.Redirect)
+		a.Equal("https://cloudreve.org/thumb", res.URL)
+		testHandller.AssertNotCalled(t, "Get", testMock.Anything, testMock.Anything)
+	}
+}
+
 func TestFileSystem_ThumbWorker(t *testing.T) {
 	asserts := assert.New(t)
 
@@ -125,3 +207,42 @@ func TestFileSystem_ThumbWorker(t *testing.T) {
 		getThumbWorker().releaseWorker()
 	})
 }
+
+func TestFileSystem_EnsureThumbnail(t *testing.T) {
+	a := assert.New(t)
+	cache.Set("setting_thumb_async_enabled", "1", 0)
+	defer cache.Set("setting_thumb_async_enabled", "0", 0)
+
+	fs := &FileSystem{User: &model.User{}}
+	fs.SetTargetFile(&[]model.File{{
+		SourceName: "async.mp4",
+		Policy:     model.Policy{Type: "mock"},
+	}})
+	fs.FileTarget[0].Policy.ID = 1
+
+	generated := make(chan struct{})
+	var fetched int32
+	testHandller := new(FileHeaderMock)
+	testHandller.On("Thumb", testMock.Anything, testMock.Anything).Return(&response.ContentResponse{}, driver.ErrorThumbNotExist)
+	testHandller.On("Get", testMock.Anything, "async.mp4").Return(MockRSC{}, errors.New("error")).Run(func(args testMock.Arguments) {
+		atomic.AddInt32(&fetched, 1)
+		<-generated
+	})
+	fs.Handler = testHandller
+
+	// 后台生成时立即返回
+	res, err := fs.GetThumb(context.Background(), 1)
+	a.ErrorIs(err, ErrThumbGenerating)
+	a.Nil(res.Content)
+
+	// 同一文件不会重复加入队列
+	_, err = fs.GetThumb(context.Background(), 1)
+	a.ErrorIs(err, ErrThumbGenerating)
+	close(generated)
+
+	a.Eventually(func() bool {
+		_, generating := thumbGenerating.Load("0/async.mp4")
+		return !generating
+	}, time.Second, 10*time.Millisecond)
+	a.EqualValues(1, atomic.LoadInt32(&fetched))
+}
@@ -0,0 +1,148 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/mirror"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// 完整性校验结果
+const (
+	IntegrityOK      = ""
+	IntegrityMissing = "missing"
+	IntegrityCorrupt = "corrupt"
+)
+
+// IntegrityResult 单个文件的完整性校验结果
+type IntegrityResult struct {
+	Status   string // 校验结果，损坏的副本全部修复后为 IntegrityOK
+	Broken   int    // 校验失败的副本数
+	Repaired int    // 修复成功的副本数
+}
+
+// CheckIntegrity 重新读取文件的物理文件，与记录的大小及摘要比对，并将校验结果记录在文件元信息中。
+// 镜像策略中的文件逐个校验各副本，repair 为 true 时使用校验通过的副本覆盖损坏或丢失的副本
+func (fs *FileSystem) CheckIntegrity(ctx context.Context, file *model.File, repair bool) (*IntegrityResult, error) {
+	checkFs := &FileSystem{User: fs.User, Policy: file.GetPolicy()}
+	if err := checkFs.DispatchHandler(); err != nil {
+		return nil, err
+	}
+
+	var res *IntegrityResult
+	if handler, ok := checkFs.Handler.(*mirror.Driver); ok {
+		res = checkReplicas(ctx, handler, file, repair)
+	} else {
+		res = &IntegrityResult{Status: verifySource(ctx, checkFs.Handler, file)}
+		if res.Status != IntegrityOK {
+			res.Broken = 1
+		}
+	}
+
+	if res.Status != file.MetadataSerialized[model.IntegrityMetadataKey] {
+		if err := file.UpdateMetadata(map[string]string{model.IntegrityMetadataKey: res.Status}); err != nil {
+			return res, err
+		}
+	}
+
+	return res, nil
+}
+
+// checkReplicas 校验镜像策略各副本中的文件，所有副本的结果中以损坏优先
+func checkReplicas(ctx context.Context, handler *mirror.Driver, file *model.File, repair bool) *IntegrityResult {
+	res := &IntegrityResult{}
+	var (
+		healthy *mirror.Replica
+		broken  []*mirror.Replica
+	)
+	for i := range handler.Replicas {
+		replica := &handler.Replicas[i]
+		status := verifySource(ctx, replica.Handler, file)
+		if status == IntegrityOK {
+			if healthy == nil {
+				healthy = replica
+			}
+			continue
+		}
+
+		util.Log().Warning("File %q on replica %q is %s.", file.SourceName, replica.Policy.Name, status)
+		broken = append(broken, replica)
+		if res.Status != IntegrityCorrupt {
+			res.Status = status
+		}
+	}
+
+	res.Broken = len(broken)
+	if !repair || healthy == nil || len(broken) == 0 {
+		return res
+	}
+
+	for _, replica := range broken {
+		if err := copyReplica(ctx, healthy, replica, file); err != nil {
+			util.Log().Warning("Failed to repair %q on replica %q: %s", file.SourceName, replica.Policy.Name, err)
+			continue
+		}
+		res.Repaired++
+	}
+
+	if res.Repaired == len(broken) {
+		res.Status = IntegrityOK
+	}
+
+	return res
+}
+
+// copyReplica 使用 src 副本中的文件覆盖 dst 副本
+func copyReplica(ctx context.Context, src, dst *mirror.Replica, file *model.File) error {
+	rs, err := src.Handler.Get(ctx, file.SourceName)
+	if err != nil {
+		return err
+	}
+
+	return dst.Handler.Put(ctx, &fsctx.FileStream{
+		Mode:     fsctx.Overwrite,
+		File:     rs,
+		Seeker:   rs,
+		Size:     file.Size,
+		Name:     file.Name,
+		SavePath: file.SourceName,
+	})
+}
+
+// verifySource 读取存储端中的物理文件并校验，无法读取时视为丢失
+func verifySource(ctx context.Context, handler driver.Handler, file *model.File) string {
+	rs, err := handler.Get(ctx, file.SourceName)
+	if err != nil {
+		util.Log().Debug("Failed to read %q for verification: %s", file.SourceName, err)
+		return IntegrityMissing
+	}
+	defer rs.Close()
+
+	return verifyContent(rs, file)
+}
+
+// verifyContent 比对文件内容的大小及摘要，优先使用 SHA-256，未记录时使用 MD5，均未记录时只比对大小
+func verifyContent(r io.ReadCloser, file *model.File) string {
+	digest := newDigestReader(r)
+	if _, err := io.Copy(io.Discard, digest); err != nil || digest.read != file.Size {
+		return IntegrityCorrupt
+	}
+
+	switch {
+	case file.Hash != "":
+		if hex.EncodeToString(digest.sha256.Sum(nil)) != file.Hash {
+			return IntegrityCorrupt
+		}
+	case file.MD5 != "":
+		if hex.EncodeToString(digest.md5.Sum(nil)) != file.MD5 {
+			return IntegrityCorrupt
+		}
+	}
+
+	return IntegrityOK
+}
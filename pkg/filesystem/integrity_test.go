@@ -0,0 +1,170 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/mirror"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+// replicaHandler 将文件保存在内存中的副本适配器
+type replicaHandler struct {
+	driver.Handler
+	files map[string]string
+}
+
+type replicaReader struct {
+	*strings.Reader
+}
+
+func (replicaReader) Close() error {
+	return nil
+}
+
+func (h *replicaHandler) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	content, ok := h.files[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return replicaReader{strings.NewReader(content)}, nil
+}
+
+func (h *replicaHandler) Put(ctx context.Context, file fsctx.FileHeader) error {
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	h.files[file.Info().SavePath] = string(content)
+	return nil
+}
+
+func TestVerifyContent(t *testing.T) {
+	a := assert.New(t)
+	sha := sha256.Sum256([]byte("hello"))
+	md := md5.Sum([]byte("hello"))
+	reader := func() io.ReadCloser {
+		return io.NopCloser(strings.NewReader("hello"))
+	}
+
+	// 摘要一致
+	a.Equal(IntegrityOK, verifyContent(reader(), &model.File{Size: 5, Hash: hex.EncodeToString(sha[:])}))
+
+	// 未记录 SHA-256，使用 MD5
+	a.Equal(IntegrityOK, verifyContent(reader(), &model.File{Size: 5, MD5: hex.EncodeToString(md[:])}))
+	a.Equal(IntegrityCorrupt, verifyContent(reader(), &model.File{Size: 5, MD5: "mismatch"}))
+
+	// 未记录摘要，只比对大小
+	a.Equal(IntegrityOK, verifyContent(reader(), &model.File{Size: 5}))
+	a.Equal(IntegrityCorrupt, verifyContent(reader(), &model.File{Size: 6}))
+
+	// 摘要不一致
+	a.Equal(IntegrityCorrupt, verifyContent(reader(), &model.File{Size: 5, Hash: "mismatch"}))
+}
+
+func TestFileSystem_CheckIntegrity(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	a.NoError(cache.Set("policy_179", model.Policy{Model: gorm.Model{ID: 179}, Type: "local"}, 0))
+	defer cache.Deletes([]string{"179"}, "policy_")
+
+	a.NoError(os.MkdirAll(util.RelativePath("tests"), 0777))
+	a.NoError(os.WriteFile(util.RelativePath("tests/integrity.txt"), []byte("hello"), 0644))
+	defer os.Remove(util.RelativePath("tests/integrity.txt"))
+	sha := sha256.Sum256([]byte("hello"))
+
+	// 校验通过，无需更新
+	{
+		file := &model.File{Model: gorm.Model{ID: 1}, PolicyID: 179, SourceName: "tests/integrity.txt", Size: 5, Hash: hex.EncodeToString(sha[:])}
+		res, err := fs.CheckIntegrity(context.Background(), file, false)
+		a.NoError(err)
+		a.Equal(IntegrityOK, res.Status)
+		a.Equal(0, res.Broken)
+	}
+
+	// 文件丢失，记录结果
+	{
+		file := &model.File{Model: gorm.Model{ID: 1}, PolicyID: 179, SourceName: "tests/integrity_not_exist.txt", Size: 5}
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		res, err := fs.CheckIntegrity(context.Background(), file, true)
+		a.NoError(err)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Equal(IntegrityMissing, res.Status)
+		a.Equal(1, res.Broken)
+		a.Equal(IntegrityMissing, file.MetadataSerialized[model.IntegrityMetadataKey])
+	}
+
+	// 内容损坏后恢复，清除结果
+	{
+		file := &model.File{
+			Model:              gorm.Model{ID: 1},
+			PolicyID:           179,
+			SourceName:         "tests/integrity.txt",
+			Size:               5,
+			MetadataSerialized: map[string]string{model.IntegrityMetadataKey: IntegrityCorrupt},
+		}
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		res, err := fs.CheckIntegrity(context.Background(), file, false)
+		a.NoError(err)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Equal(IntegrityOK, res.Status)
+	}
+}
+
+func TestCheckReplicas(t *testing.T) {
+	a := assert.New(t)
+	sha := sha256.Sum256([]byte("hello"))
+	file := &model.File{SourceName: "a.txt", Size: 5, Hash: hex.EncodeToString(sha[:])}
+	newDriver := func() (*mirror.Driver, []*replicaHandler) {
+		handlers := []*replicaHandler{
+			{files: map[string]string{"a.txt": "hellx"}},
+			{files: map[string]string{}},
+			{files: map[string]string{"a.txt": "hello"}},
+		}
+		replicas := make([]mirror.Replica, len(handlers))
+		for i := range handlers {
+			replicas[i] = mirror.Replica{Policy: &model.Policy{}, Handler: handlers[i]}
+		}
+		handler, err := mirror.NewDriver(&model.Policy{}, replicas)
+		a.NoError(err)
+		return handler, handlers
+	}
+
+	// 不修复，损坏优先
+	{
+		handler, _ := newDriver()
+		res := checkReplicas(context.Background(), handler, file, false)
+		a.Equal(IntegrityCorrupt, res.Status)
+		a.Equal(2, res.Broken)
+		a.Equal(0, res.Repaired)
+	}
+
+	// 从完好的副本修复
+	{
+		handler, handlers := newDriver()
+		res := checkReplicas(context.Background(), handler, file, true)
+		a.Equal(IntegrityOK, res.Status)
+		a.Equal(2, res.Repaired)
+		a.Equal("hello", handlers[0].files["a.txt"])
+		a.Equal("hello", handlers[1].files["a.txt"])
+	}
+}
@@ -0,0 +1,228 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"path"
+	"strconv"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+)
+
+// 目录列表排序方式
+const (
+	ListOrderByName      = "name"
+	ListOrderBySize      = "size"
+	ListOrderByUpdatedAt = "updated_at"
+	// ListOrderByType 按对象类型排序，正序时目录在前，倒序时文件在前，同类对象按名称排序
+	ListOrderByType = "type"
+)
+
+// ListOption 分页列出目录时的排序、筛选与分页选项
+type ListOption struct {
+	OrderBy  string
+	Desc     bool
+	Keyword  string // 名称中包含的关键字
+	Type     string // 只列出文件(file)或目录(dir)，为空时都列出
+	PageSize int    // 每页数量，为 0 时不分页
+	Cursor   string // 上一页返回的游标，为空时从第一页开始
+}
+
+// listCursor 分页游标，记录上一页最后一个对象；ID 为 0 时表示从该类对象的开头列出
+type listCursor struct {
+	Dir   bool   `json:"d"`
+	Value string `json:"v,omitempty"`
+	ID    uint   `json:"i,omitempty"`
+}
+
+func (cursor *listCursor) encode() string {
+	res, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(res)
+}
+
+func decodeListCursor(raw string) (*listCursor, error) {
+	content, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, ErrInvalidListCursor.WithError(err)
+	}
+
+	var cursor listCursor
+	if err := json.Unmarshal(content, &cursor); err != nil {
+		return nil, ErrInvalidListCursor.WithError(err)
+	}
+
+	return &cursor, nil
+}
+
+// childOption 根据列表选项和游标生成数据库查询选项
+func (option *ListOption) childOption(cursor *listCursor, limit int) (*model.ChildListOption, error) {
+	res := &model.ChildListOption{
+		OrderBy: option.OrderBy,
+		Desc:    option.Desc,
+		Keyword: option.Keyword,
+		Limit:   limit,
+	}
+
+	if option.OrderBy == ListOrderByType {
+		res.OrderBy = ListOrderByName
+		res.Desc = false
+	}
+
+	if cursor == nil || cursor.ID == 0 {
+		return res, nil
+	}
+
+	res.AfterID = cursor.ID
+	switch res.OrderColumn() {
+	case ListOrderBySize:
+		size, err := strconv.ParseUint(cursor.Value, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidListCursor.WithError(err)
+		}
+		res.AfterValue = size
+	case ListOrderByUpdatedAt:
+		updatedAt, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return nil, ErrInvalidListCursor.WithError(err)
+		}
+		res.AfterValue = updatedAt
+	default:
+		res.AfterValue = cursor.Value
+	}
+
+	return res, nil
+}
+
+// cursorValue 返回对象排序字段的游标值
+func (option *ListOption) cursorValue(name string, size uint64, updatedAt time.Time) string {
+	switch option.OrderBy {
+	case ListOrderBySize:
+		return strconv.FormatUint(size, 10)
+	case ListOrderByUpdatedAt:
+		return updatedAt.Format(time.RFC3339Nano)
+	default:
+		return name
+	}
+}
+
+// phases 返回依次列出的对象类型，true 表示目录
+func (option *ListOption) phases() []bool {
+	phases := []bool{true, false}
+	if option.OrderBy == ListOrderByType && option.Desc {
+		phases = []bool{false, true}
+	}
+
+	res := make([]bool, 0, len(phases))
+	for _, dir := range phases {
+		if option.Type == "" || (option.Type == "dir") == dir {
+			res = append(res, dir)
+		}
+	}
+
+	return res
+}
+
+// ListPage 按选项排序、筛选并分页列出目录内容，返回下一页的游标，已是最后一页时游标为空
+func (fs *FileSystem) ListPage(ctx context.Context, dirPath string, option *ListOption) ([]serializer.Object, string, error) {
+	// 获取父目录
+	isExist, folder := fs.IsPathExist(dirPath)
+	if !isExist {
+		return nil, "", ErrPathNotExist
+	}
+	fs.SetTargetDir(&[]model.Folder{*folder})
+
+	var cursor *listCursor
+	if option.Cursor != "" {
+		var err error
+		if cursor, err = decodeListCursor(option.Cursor); err != nil {
+			return nil, "", err
+		}
+	}
+
+	var (
+		childFolders []model.Folder
+		childFiles   []model.File
+		next         *listCursor
+	)
+	remain := option.PageSize
+	started := cursor == nil
+	for _, dir := range option.phases() {
+		if !started && cursor.Dir != dir {
+			continue
+		}
+
+		phaseCursor := cursor
+		if started {
+			phaseCursor = nil
+		}
+		started = true
+
+		// 多查询一个对象，用于判断是否还有下一页
+		limit := 0
+		if option.PageSize > 0 {
+			limit = remain + 1
+		}
+
+		childOption, err := option.childOption(phaseCursor, limit)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if dir {
+			folders, err := folder.ListChildFolders(childOption)
+			if err != nil {
+				return nil, "", ErrDBListObjects.WithError(err)
+			}
+
+			if limit > 0 && len(folders) > remain {
+				folders = folders[:remain]
+				next = &listCursor{Dir: true}
+				if remain > 0 {
+					last := folders[remain-1]
+					next.ID = last.ID
+					next.Value = option.cursorValue(last.Name, last.Size, last.UpdatedAt)
+				}
+			}
+			childFolders = folders
+			remain -= len(folders)
+		} else {
+			files, err := folder.ListChildFiles(childOption)
+			if err != nil {
+				return nil, "", ErrDBListObjects.WithError(err)
+			}
+
+			if limit > 0 && len(files) > remain {
+				files = files[:remain]
+				next = &listCursor{}
+				if remain > 0 {
+					last := files[remain-1]
+					next.ID = last.ID
+					next.Value = option.cursorValue(last.Name, last.Size, last.UpdatedAt)
+				}
+			}
+			childFiles = files
+			remain -= len(files)
+		}
+
+		if next != nil {
+			break
+		}
+	}
+
+	parentPath := path.Join(folder.Position, folder.Name)
+	objects := fs.listObjects(ctx, parentPath, childFiles, childFolders, nil)
+
+	// listObjects 先列出目录，文件在前时需调整顺序
+	if len(childFiles) > 0 && len(childFolders) > 0 && option.OrderBy == ListOrderByType && option.Desc {
+		objects = append(objects[len(childFolders):], objects[:len(childFolders)]...)
+	}
+
+	if next == nil {
+		return objects, "", nil
+	}
+
+	return objects, next.encode(), nil
+}
@@ -0,0 +1,110 @@
+package filesystem
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_ListPage(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	ctx := context.Background()
+	expectRoot := func() {
+		mock.ExpectQuery("SELECT(.+)").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "owner_id"}).AddRow(1, "/", 1))
+	}
+
+	// 目录恰好填满第一页，下一页从文件开始
+	var next string
+	{
+		expectRoot()
+		mock.ExpectQuery("SELECT(.+)folders(.+)LIMIT 3").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "a").AddRow(3, "b"))
+		mock.ExpectQuery("SELECT(.+)files(.+)LIMIT 1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(4, "a.txt"))
+		objects, cursor, err := fs.ListPage(ctx, "/", &ListOption{PageSize: 2})
+		a.NoError(err)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Len(objects, 2)
+		a.Equal("dir", objects[0].Type)
+		a.NotEmpty(cursor)
+		next = cursor
+
+		decoded, err := decodeListCursor(cursor)
+		a.NoError(err)
+		a.False(decoded.Dir)
+		a.EqualValues(0, decoded.ID)
+	}
+
+	// 第二页只列出文件，按大小排序
+	{
+		expectRoot()
+		mock.ExpectQuery("SELECT(.+)files(.+)ORDER BY size asc(.+)LIMIT 3").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "size"}).AddRow(4, "a.txt", 1).AddRow(5, "b.txt", 2).AddRow(6, "c.txt", 3))
+		objects, cursor, err := fs.ListPage(ctx, "/", &ListOption{PageSize: 2, OrderBy: ListOrderBySize, Cursor: next})
+		a.NoError(err)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Len(objects, 2)
+
+		decoded, err := decodeListCursor(cursor)
+		a.NoError(err)
+		a.False(decoded.Dir)
+		a.EqualValues(5, decoded.ID)
+		a.Equal("2", decoded.Value)
+		next = cursor
+	}
+
+	// 从游标继续，最后一页
+	{
+		expectRoot()
+		mock.ExpectQuery("SELECT(.+)files(.+)size > (.+)ORDER BY size asc(.+)LIMIT 3").
+			WithArgs(1, uint64(2), uint64(2), 5).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "size"}).AddRow(6, "c.txt", 3))
+		objects, cursor, err := fs.ListPage(ctx, "/", &ListOption{PageSize: 2, OrderBy: ListOrderBySize, Cursor: next})
+		a.NoError(err)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Len(objects, 1)
+		a.Empty(cursor)
+	}
+
+	// 按类型倒序，文件在前，不分页
+	{
+		expectRoot()
+		mock.ExpectQuery("SELECT(.+)files(.+)ORDER BY name asc").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(4, "a.txt"))
+		mock.ExpectQuery("SELECT(.+)folders(.+)ORDER BY name asc").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "a"))
+		objects, cursor, err := fs.ListPage(ctx, "/", &ListOption{OrderBy: ListOrderByType, Desc: true})
+		a.NoError(err)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Len(objects, 2)
+		a.Equal("file", objects[0].Type)
+		a.Equal("dir", objects[1].Type)
+		a.Empty(cursor)
+	}
+
+	// 只列出目录
+	{
+		expectRoot()
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "a"))
+		objects, _, err := fs.ListPage(ctx, "/", &ListOption{Type: "dir"})
+		a.NoError(err)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Len(objects, 1)
+	}
+
+	// 游标无效
+	{
+		expectRoot()
+		_, _, err := fs.ListPage(ctx, "/", &ListOption{Cursor: "!!"})
+		a.ErrorIs(err, ErrInvalidListCursor)
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
@@ -0,0 +1,122 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+const (
+	// FileLockCachePrefix 文件锁缓存前缀
+	FileLockCachePrefix = "file_lock_"
+	// LockTokenHeader 客户端通过 REST API 保存文件时携带锁令牌的请求头
+	LockTokenHeader = "X-Cr-Lock-Token"
+)
+
+// 加锁的客户端类型
+const (
+	LockAppAPI    = "api"
+	LockAppWebDAV = "webdav"
+	LockAppWOPI   = "wopi"
+)
+
+// ErrLockMismatch 释放或刷新文件锁时提供的令牌与当前锁不符
+var ErrLockMismatch = errors.New("lock token mismatch")
+
+// lockMu 保证同一节点上加锁的检查与写入不会交错
+var lockMu sync.Mutex
+
+// FileLock 协作编辑时的文件建议锁，持有者以外的客户端保存文件时会被拒绝
+type FileLock struct {
+	Token     string    `json:"token"`
+	OwnerID   uint      `json:"owner_id"`
+	App       string    `json:"app"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func init() {
+	gob.Register(FileLock{})
+}
+
+// GetFileLock 获取文件当前有效的锁
+func GetFileLock(fileID uint) (*FileLock, bool) {
+	raw, ok := cache.Get(FileLockCachePrefix + strconv.FormatUint(uint64(fileID), 10))
+	if !ok {
+		return nil, false
+	}
+
+	lock := raw.(FileLock)
+	if time.Now().After(lock.ExpiresAt) {
+		return nil, false
+	}
+
+	return &lock, true
+}
+
+// LockFile 为文件加锁，令牌与当前锁相同时刷新有效期。文件已被其他令牌锁定时，
+// steal 为 true 则夺取该锁，否则返回当前的锁及 ErrFileLocked。ttl 超过站点设定
+// 的上限或不大于 0 时使用上限值
+func LockFile(fileID uint, lock FileLock, ttl int, steal bool) (*FileLock, error) {
+	lockMu.Lock()
+	defer lockMu.Unlock()
+
+	if current, ok := GetFileLock(fileID); ok && current.Token != lock.Token && !steal {
+		return current, ErrFileLocked
+	}
+
+	maxTTL := model.GetIntSetting("file_lock_max_ttl", 3600)
+	if ttl <= 0 || ttl > maxTTL {
+		ttl = maxTTL
+	}
+
+	if lock.Token == "" {
+		lock.Token = util.RandStringRunes(32)
+	}
+	lock.ExpiresAt = time.Now().Add(time.Duration(ttl) * time.Second)
+	if err := cache.Set(FileLockCachePrefix+strconv.FormatUint(uint64(fileID), 10), lock, ttl); err != nil {
+		return nil, err
+	}
+
+	return &lock, nil
+}
+
+// UnlockFile 释放文件锁，force 为 true 时忽略令牌直接解除。文件未被锁定时不做处理
+func UnlockFile(fileID uint, token string, force bool) error {
+	lockMu.Lock()
+	defer lockMu.Unlock()
+
+	current, ok := GetFileLock(fileID)
+	if !ok {
+		return nil
+	}
+
+	if current.Token != token && !force {
+		return ErrLockMismatch
+	}
+
+	return cache.Deletes([]string{strconv.FormatUint(uint64(fileID), 10)}, FileLockCachePrefix)
+}
+
+// CheckFileLock 检查客户端是否可修改文件，文件未被锁定或 token 与当前锁相同时返回 nil
+func CheckFileLock(fileID uint, token string) error {
+	if current, ok := GetFileLock(fileID); ok && current.Token != token {
+		return ErrFileLocked
+	}
+
+	return nil
+}
+
+// HookValidateFileLock 验证被覆盖的原有文件是否被其他客户端锁定
+func HookValidateFileLock(ctx context.Context, fs *FileSystem, newFile fsctx.FileHeader) error {
+	originFile := ctx.Value(fsctx.FileModelCtx).(model.File)
+	token, _ := ctx.Value(fsctx.LockTokenCtx).(string)
+	return CheckFileLock(originFile.ID, token)
+}
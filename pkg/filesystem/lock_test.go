@@ -0,0 +1,95 @@
+package filesystem
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockFile(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(cache.Set("setting_file_lock_max_ttl", "60", 0))
+	defer cache.Deletes([]string{"1"}, FileLockCachePrefix)
+
+	// 未锁定，生成令牌
+	lock, err := LockFile(1, FileLock{OwnerID: 1, App: LockAppAPI}, 0, false)
+	a.NoError(err)
+	a.NotEmpty(lock.Token)
+	a.WithinDuration(time.Now().Add(60*time.Second), lock.ExpiresAt, time.Second)
+
+	// 相同令牌刷新
+	refreshed, err := LockFile(1, FileLock{Token: lock.Token, App: LockAppAPI}, 10, false)
+	a.NoError(err)
+	a.Equal(lock.Token, refreshed.Token)
+	a.WithinDuration(time.Now().Add(10*time.Second), refreshed.ExpiresAt, time.Second)
+
+	// 其他令牌冲突
+	current, err := LockFile(1, FileLock{Token: "other", App: LockAppWOPI}, 10, false)
+	a.Equal(ErrFileLocked, err)
+	a.Equal(lock.Token, current.Token)
+
+	// 夺取
+	stolen, err := LockFile(1, FileLock{Token: "other", App: LockAppWOPI}, 10, true)
+	a.NoError(err)
+	a.Equal("other", stolen.Token)
+	current, ok := GetFileLock(1)
+	a.True(ok)
+	a.Equal(LockAppWOPI, current.App)
+}
+
+func TestGetFileLock(t *testing.T) {
+	a := assert.New(t)
+
+	// 未锁定
+	_, ok := GetFileLock(2)
+	a.False(ok)
+
+	// 已过期
+	a.NoError(cache.Set(FileLockCachePrefix+"2", FileLock{Token: "token", ExpiresAt: time.Now().Add(-time.Second)}, 0))
+	_, ok = GetFileLock(2)
+	a.False(ok)
+	cache.Deletes([]string{"2"}, FileLockCachePrefix)
+}
+
+func TestUnlockFile(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(cache.Set(FileLockCachePrefix+"3", FileLock{Token: "token", ExpiresAt: time.Now().Add(time.Minute)}, 0))
+
+	// 未锁定
+	a.NoError(UnlockFile(4, "token", false))
+
+	// 令牌不符
+	a.Equal(ErrLockMismatch, UnlockFile(3, "other", false))
+	_, ok := GetFileLock(3)
+	a.True(ok)
+
+	// 强制解除
+	a.NoError(UnlockFile(3, "other", true))
+	_, ok = GetFileLock(3)
+	a.False(ok)
+}
+
+func TestHookValidateFileLock(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{}}
+	file := model.File{Model: gorm.Model{ID: 5}}
+	ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, file)
+
+	// 未锁定
+	a.NoError(HookValidateFileLock(ctx, fs, &fsctx.FileStream{}))
+
+	// 被其他客户端锁定
+	a.NoError(cache.Set(FileLockCachePrefix+"5", FileLock{Token: "token", ExpiresAt: time.Now().Add(time.Minute)}, 0))
+	defer cache.Deletes([]string{"5"}, FileLockCachePrefix)
+	a.Equal(ErrFileLocked, HookValidateFileLock(ctx, fs, &fsctx.FileStream{}))
+
+	// 持有锁
+	ctx = context.WithValue(ctx, fsctx.LockTokenCtx, "token")
+	a.NoError(HookValidateFileLock(ctx, fs, &fsctx.FileStream{}))
+}
@@ -32,10 +32,22 @@ func (fs *FileSystem) Rename(ctx context.Context, dir, file []uint, new string)
 			return ErrPathNotExist
 		}
 
+		if fileObject[0].IsRetained() {
+			return ErrFileRetained
+		}
+
+		oldName := fileObject[0].Name
 		err = fileObject[0].Rename(new)
 		if err != nil {
 			return ErrFileExisted
 		}
+
+		if parent, ok := folderPaths([]uint{fileObject[0].FolderID}, fs.User.ID)[fileObject[0].FolderID]; ok {
+			RecordChanges(
+				model.NewFileChange(model.ChangeDeleted, &fileObject[0], path.Join(parent, oldName)),
+				model.NewFileChange(model.ChangeCreated, &fileObject[0], path.Join(parent, new)),
+			)
+		}
 		return nil
 	}
 
@@ -45,10 +57,20 @@ func (fs *FileSystem) Rename(ctx context.Context, dir, file []uint, new string)
 			return ErrPathNotExist
 		}
 
+		oldName := folderObject[0].Name
 		err = folderObject[0].Rename(new)
 		if err != nil {
 			return ErrFileExisted
 		}
+
+		if folderObject[0].ParentID != nil {
+			if parent, ok := folderPaths([]uint{*folderObject[0].ParentID}, fs.User.ID)[*folderObject[0].ParentID]; ok {
+				RecordChanges(
+					model.NewFolderChange(model.ChangeDeleted, &folderObject[0], path.Join(parent, oldName)),
+					model.NewFolderChange(model.ChangeCreated, &folderObject[0], path.Join(parent, new)),
+				)
+			}
+		}
 		return nil
 	}
 
@@ -66,6 +88,18 @@ func (fs *FileSystem) Copy(ctx context.Context, dirs, files []uint, src, dst str
 		return ErrPathNotExist
 	}
 
+	if err := fs.CheckWritable(dstFolder); err != nil {
+		return err
+	}
+
+	// 从挂载的分享中复制目录时，目录须位于分享目录之下
+	if len(dirs) > 0 && srcFolder.OwnerID != fs.User.ID {
+		folders, err := model.GetFoldersByIDs(dirs[:1], srcFolder.OwnerID)
+		if err != nil || len(folders) == 0 || folders[0].ParentID == nil || *folders[0].ParentID != srcFolder.ID {
+			return ErrObjectNotExist
+		}
+	}
+
 	// 记录复制的文件的总容量
 	var newUsedStorage uint64
 
@@ -95,6 +129,11 @@ func (fs *FileSystem) Copy(ctx context.Context, dirs, files []uint, src, dst str
 	// 扣除容量
 	fs.User.IncreaseStorageWithoutCheck(newUsedStorage)
 
+	RecordChanges(fs.placeChanges(
+		fs.changeTargets(srcFolder.OwnerID, dirs, files),
+		model.ChangeCreated, dst, dstFolder.WebdavDstName, true,
+	)...)
+
 	return nil
 }
 
@@ -108,11 +147,21 @@ func (fs *FileSystem) Move(ctx context.Context, dirs, files []uint, src, dst str
 		return ErrPathNotExist
 	}
 
+	if err := fs.CheckWritable(srcFolder); err != nil {
+		return err
+	}
+	if err := fs.CheckWritable(dstFolder); err != nil {
+		return err
+	}
+
 	// 设置webdav目标名
 	if dstName, ok := ctx.Value(fsctx.WebdavDstName).(string); ok {
 		dstFolder.WebdavDstName = dstName
 	}
 
+	// 移动前记录对象的原名称，WebDAV 移动时会同时重命名
+	targets := fs.changeTargets(fs.User.ID, dirs, files)
+
 	// 处理目录及子文件移动
 	err := srcFolder.MoveFolderTo(dirs, dstFolder)
 	if err != nil {
@@ -125,7 +174,11 @@ func (fs *FileSystem) Move(ctx context.Context, dirs, files []uint, src, dst str
 		return ErrFileExisted.WithError(err)
 	}
 
-	// 移动文件
+	// 记录变更，移动视为从原位置删除并在新位置创建
+	RecordChanges(append(
+		fs.placeChanges(targets, model.ChangeDeleted, src, "", false),
+		fs.placeChanges(targets, model.ChangeCreated, dst, dstFolder.WebdavDstName, false)...,
+	)...)
 
 	return err
 }
@@ -133,13 +186,6 @@ func (fs *FileSystem) Move(ctx context.Context, dirs, files []uint, src, dst str
 // Delete 递归删除对象, force 为 true 时强制删除文件记录，忽略物理删除是否成功;
 // unlink 为 true 时只删除虚拟文件系统的文件记录，不删除物理文件。
 func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force, unlink bool) error {
-	// 已删除的文件ID
-	var deletedFiles = make([]*model.File, 0, len(fs.FileTarget))
-	// 删除失败的文件的父目录ID
-
-	// 所有文件的ID
-	var allFiles = make([]*model.File, 0, len(fs.FileTarget))
-
 	// 列出要删除的目录
 	if len(dirs) > 0 {
 		err := fs.ListDeleteDirs(ctx, dirs)
@@ -156,6 +202,26 @@ func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force, unl
 		}
 	}
 
+	// 保留期内的文件不可删除，强制删除时（如管理员删除用户）不做限制
+	if !force {
+		for i := range fs.FileTarget {
+			if fs.FileTarget[i].IsRetained() {
+				return ErrFileRetained
+			}
+		}
+	}
+
+	return fs.deleteTargets(ctx, force, unlink)
+}
+
+// deleteTargets 删除目标文件、目录的物理文件及记录，文件全部删除成功时才删除目录记录
+func (fs *FileSystem) deleteTargets(ctx context.Context, force, unlink bool) error {
+	// 已删除的文件ID
+	var deletedFiles = make([]*model.File, 0, len(fs.FileTarget))
+
+	// 所有文件的ID
+	var allFiles = make([]*model.File, 0, len(fs.FileTarget))
+
 	// 去除待删除文件中包含软连接的部分
 	filesToBeDelete, err := model.RemoveFilesWithSoftLinks(fs.FileTarget)
 	if err != nil {
@@ -202,8 +268,43 @@ func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force, unl
 
 	model.DeleteShareBySourceIDs(deletedFileIDs, false)
 
+	// 删除文件上的标签
+	if len(deletedFileIDs) > 0 {
+		if err := model.DeleteFileTagsByFileIDs(deletedFileIDs); err != nil {
+			util.Log().Warning("Failed to delete file tags: %s", err)
+		}
+	}
+
+	// 删除文件的到期规则
+	if len(deletedFileIDs) > 0 {
+		if err := model.DeleteExpirationsByObjects(deletedFileIDs, false); err != nil {
+			util.Log().Warning("Failed to delete file expirations: %s", err)
+		}
+	}
+
+	// 删除文件的自定义元数据
+	if len(deletedFileIDs) > 0 {
+		if err := model.DeleteFilePropertiesByFileIDs(deletedFileIDs); err != nil {
+			util.Log().Warning("Failed to delete file properties: %s", err)
+		}
+	}
+
+	// 删除文件上的评论
+	if len(deletedFileIDs) > 0 {
+		if err := model.DeleteCommentsByFileIDs(deletedFileIDs); err != nil {
+			util.Log().Warning("Failed to delete file comments: %s", err)
+		}
+	}
+
+	// 删除文件的历史版本
+	if err := fs.deleteFileVersions(ctx, deletedFileIDs); err != nil {
+		util.Log().Warning("Failed to delete file versions: %s", err)
+	}
+
 	// 如果文件全部删除成功，继续删除目录
+	var deletedFolders []model.Folder
 	if len(deletedFiles) == len(allFiles) {
+		deletedFolders = fs.DirTarget
 		var allFolderIDs = make([]uint, 0, len(fs.DirTarget))
 		for _, value := range fs.DirTarget {
 			allFolderIDs = append(allFolderIDs, value.ID)
@@ -215,8 +316,17 @@ func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force, unl
 
 		// 删除目录记录对应的分享记录
 		model.DeleteShareBySourceIDs(allFolderIDs, true)
+
+		// 删除目录的到期规则
+		if len(allFolderIDs) > 0 {
+			if err := model.DeleteExpirationsByObjects(allFolderIDs, true); err != nil {
+				util.Log().Warning("Failed to delete folder expirations: %s", err)
+			}
+		}
 	}
 
+	RecordChanges(fs.deletedChanges(deletedFolders, deletedFiles)...)
+
 	if notDeleted := len(fs.FileTarget) - len(deletedFiles); notDeleted > 0 {
 		return serializer.NewError(
 			serializer.CodeNotFullySuccess,
@@ -291,6 +401,11 @@ func (fs *FileSystem) List(ctx context.Context, dirPath string, pathProcessor fu
 	return fs.listObjects(ctx, parentPath, childFiles, childFolders, pathProcessor), nil
 }
 
+// ListObjects 列出不对应实际目录的虚拟目录（如合集分享的根目录）中的目录与文件，parent 为虚拟目录的路径
+func (fs *FileSystem) ListObjects(ctx context.Context, parent string, files []model.File, folders []model.Folder) []serializer.Object {
+	return fs.listObjects(ctx, parent, files, folders, nil)
+}
+
 // ListPhysical 列出存储策略中的外部目录
 // TODO:测试
 func (fs *FileSystem) ListPhysical(ctx context.Context, dirPath string) ([]serializer.Object, error) {
@@ -355,6 +470,13 @@ func (fs *FileSystem) listObjects(ctx context.Context, parent string, files []mo
 			Type:       "dir",
 			Date:       subFolder.UpdatedAt,
 			CreateDate: subFolder.CreatedAt,
+			// 星标仅对文件所有者可见
+			Starred: subFolder.Starred && shareKey == "",
+			Mount:   subFolder.IsMount() && shareKey == "",
+			// 显示属性
+			Color:       subFolder.Color,
+			Icon:        subFolder.Icon,
+			Description: subFolder.Description,
 		})
 	}
 
@@ -378,6 +500,7 @@ func (fs *FileSystem) listObjects(ctx context.Context, parent string, files []mo
 				Date:          file.UpdatedAt,
 				SourceEnabled: file.GetPolicy().IsOriginLinkEnable,
 				CreateDate:    file.CreatedAt,
+				Starred:       file.Starred && shareKey == "",
 			}
 			if shareKey != "" {
 				newFile.Key = shareKey
@@ -392,6 +515,11 @@ func (fs *FileSystem) listObjects(ctx context.Context, parent string, files []mo
 // CreateDirectory 根据给定的完整创建目录，支持递归创建。如果目录已存在，则直接
 // 返回已存在的目录。
 func (fs *FileSystem) CreateDirectory(ctx context.Context, fullPath string) (*model.Folder, error) {
+	return fs.createDirectory(ctx, fullPath, nil)
+}
+
+// createDirectory 根据给定的完整创建目录，支持递归创建，created 不为空时记录新建的目录
+func (fs *FileSystem) createDirectory(ctx context.Context, fullPath string, created createdFolders) (*model.Folder, error) {
 	if fullPath == "." || fullPath == "" {
 		return nil, ErrRootProtected
 	}
@@ -419,13 +547,17 @@ func (fs *FileSystem) CreateDirectory(ctx context.Context, fullPath string) (*mo
 	// 父目录是否存在
 	isExist, parent := fs.IsPathExist(base)
 	if !isExist {
-		newParent, err := fs.CreateDirectory(ctx, base)
+		newParent, err := fs.createDirectory(ctx, base, created)
 		if err != nil {
 			return nil, err
 		}
 		parent = newParent
 	}
 
+	if err := fs.CheckWritable(parent); err != nil {
+		return nil, err
+	}
+
 	// 是否有同名文件
 	if ok, _ := fs.IsChildFileExist(parent, dir); ok {
 		return nil, ErrFileExisted
@@ -437,15 +569,69 @@ func (fs *FileSystem) CreateDirectory(ctx context.Context, fullPath string) (*mo
 		ParentID: &parent.ID,
 		OwnerID:  fs.User.ID,
 	}
-	_, err := newFolder.Create()
+	isNew, err := newFolder.CreateIfNotExist()
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create folder: %w", err)
 	}
 
+	// 挂载点下不能直接创建内容
+	if newFolder.IsMount() {
+		return nil, ErrMountReadOnly
+	}
+
+	if isNew {
+		if created != nil {
+			created[path.Join(base, dir)] = newFolder.ID
+		}
+		RecordChanges(model.NewFolderChange(model.ChangeCreated, &newFolder, path.Join(base, dir)))
+	}
+
 	return &newFolder, nil
 }
 
+// createdFolders 批量创建目录时新建的目录，路径 -> 目录 ID
+type createdFolders map[string]uint
+
+// CreateDirectories 在 root 下按相对路径批量创建目录，相同的目录只创建一次，已存在的目录直接复用。
+// 任一目录创建失败时删除本次已新建的目录
+func (fs *FileSystem) CreateDirectories(ctx context.Context, root string, relativeDirs []string) error {
+	root = path.Clean("/" + root)
+	created := make(createdFolders)
+	visited := make(map[string]bool)
+	for _, dir := range relativeDirs {
+		fullPath := path.Join(root, dir)
+		if fullPath == root || visited[fullPath] {
+			continue
+		}
+
+		if _, err := fs.createDirectory(ctx, fullPath, created); err != nil {
+			fs.rollbackDirectories(created)
+			return err
+		}
+
+		visited[fullPath] = true
+	}
+
+	return nil
+}
+
+// rollbackDirectories 删除批量创建中已新建的目录
+func (fs *FileSystem) rollbackDirectories(created createdFolders) {
+	if len(created) == 0 {
+		return
+	}
+
+	ids := make([]uint, 0, len(created))
+	for _, id := range created {
+		ids = append(ids, id)
+	}
+
+	if err := model.DeleteFolderByIDs(ids); err != nil {
+		util.Log().Warning("Failed to roll back created folders: %s", err)
+	}
+}
+
 // SaveTo 将别人分享的文件转存到目标路径下
 func (fs *FileSystem) SaveTo(ctx context.Context, path string) error {
 	// 获取父目录
@@ -454,6 +640,10 @@ func (fs *FileSystem) SaveTo(ctx context.Context, path string) error {
 		return ErrPathNotExist
 	}
 
+	if err := fs.CheckWritable(folder); err != nil {
+		return err
+	}
+
 	var (
 		totalSize uint64
 		err       error
@@ -475,5 +665,15 @@ func (fs *FileSystem) SaveTo(ctx context.Context, path string) error {
 		return ErrFileExisted.WithError(err)
 	}
 
+	if len(fs.DirTarget) > 0 {
+		RecordChanges(fs.placeChanges([]model.Change{
+			model.NewFolderChange("", &fs.DirTarget[0], fs.DirTarget[0].Name),
+		}, model.ChangeCreated, path, "", true)...)
+	} else {
+		RecordChanges(fs.placeChanges([]model.Change{
+			model.NewFileChange("", &fs.FileTarget[0], fs.FileTarget[0].Name),
+		}, model.ChangeCreated, path, "", true)...)
+	}
+
 	return nil
 }
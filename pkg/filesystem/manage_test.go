@@ -187,6 +187,53 @@ func TestFileSystem_List(t *testing.T) {
 	asserts.NoError(mock.ExpectationsWereMet())
 }
 
+func TestFileSystem_CreateDirectories(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{
+		Model: gorm.Model{
+			ID: 1,
+		},
+	}}
+	ctx := context.Background()
+
+	// 均为根目录，无需创建
+	asserts.NoError(fs.CreateDirectories(ctx, "/ad", []string{"", ".", ""}))
+
+	// 目录名非法
+	asserts.Equal(ErrIllegalObjectName, fs.CreateDirectories(ctx, "/", []string{"a+?"}))
+
+	// 相同目录只创建一次
+	mock.ExpectQuery("SELECT(.+)").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
+	mock.ExpectQuery("SELECT(.+)files").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	mock.ExpectQuery("SELECT(.+)").
+		WithArgs("ab", 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(2, 1))
+	asserts.NoError(fs.CreateDirectories(ctx, "/", []string{"ab", "ab", ""}))
+	asserts.NoError(mock.ExpectationsWereMet())
+
+	// 后续目录创建失败，删除已新建的目录
+	mock.ExpectQuery("SELECT(.+)").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
+	mock.ExpectQuery("SELECT(.+)files").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	mock.ExpectQuery("SELECT(.+)").
+		WithArgs("ab", 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT(.+)changes").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)folders").WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	asserts.Equal(ErrIllegalObjectName, fs.CreateDirectories(ctx, "/", []string{"ab", "a+?"}))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
 func TestFileSystem_CreateDirectory(t *testing.T) {
 	asserts := assert.New(t)
 	fs := &FileSystem{User: &model.User{
@@ -250,6 +297,9 @@ func TestFileSystem_CreateDirectory(t *testing.T) {
 	mock.ExpectBegin()
 	mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT(.+)changes").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 	_, err = fs.CreateDirectory(ctx, "/ad/ab")
 	asserts.NoError(err)
 	asserts.NoError(mock.ExpectationsWereMet())
@@ -275,6 +325,9 @@ func TestFileSystem_CreateDirectory(t *testing.T) {
 	mock.ExpectBegin()
 	mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(2, 1))
 	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT(.+)changes").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 	mock.ExpectQuery("SELECT(.+)files").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
 	// 创建ab
 	mock.ExpectQuery("SELECT(.+)").
@@ -283,6 +336,9 @@ func TestFileSystem_CreateDirectory(t *testing.T) {
 	mock.ExpectBegin()
 	mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT(.+)changes").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 	_, err = fs.CreateDirectory(ctx, "/ad/ab")
 	asserts.NoError(err)
 	asserts.NoError(mock.ExpectationsWereMet())
@@ -475,8 +531,12 @@ func TestFileSystem_Delete(t *testing.T) {
 		// 两次查询软连接
 		mock.ExpectQuery("SELECT(.+)files(.+)").
 			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 		mock.ExpectQuery("SELECT(.+)files(.+)").
 			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 		// 查询上传策略
 		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(365, "local"))
 		// 删除文件记录
@@ -491,6 +551,26 @@ func TestFileSystem_Delete(t *testing.T) {
 		mock.ExpectExec("UPDATE(.+)shares").
 			WillReturnResult(sqlmock.NewResult(0, 3))
 		mock.ExpectCommit()
+		// 删除文件标签
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)file_tags(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		// 删除文件到期规则
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)expirations(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		// 删除文件自定义元数据
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)file_properties(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		// 删除文件评论
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)comments(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
 		// 删除目录
 		mock.ExpectBegin()
 		mock.ExpectExec("DELETE(.+)").
@@ -501,6 +581,11 @@ func TestFileSystem_Delete(t *testing.T) {
 		mock.ExpectExec("UPDATE(.+)shares").
 			WillReturnResult(sqlmock.NewResult(0, 3))
 		mock.ExpectCommit()
+		// 删除目录到期规则
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)expirations(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
 
 		fs.FileTarget = []model.File{}
 		fs.DirTarget = []model.Folder{}
@@ -532,8 +617,12 @@ func TestFileSystem_Delete(t *testing.T) {
 		// 两次查询软连接
 		mock.ExpectQuery("SELECT(.+)files(.+)").
 			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 		mock.ExpectQuery("SELECT(.+)files(.+)").
 			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 		// 查询上传策略
 		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(602, "local"))
 		// 删除文件记录
@@ -548,6 +637,26 @@ func TestFileSystem_Delete(t *testing.T) {
 		mock.ExpectExec("UPDATE(.+)shares").
 			WillReturnResult(sqlmock.NewResult(0, 3))
 		mock.ExpectCommit()
+		// 删除文件标签
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)file_tags(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		// 删除文件到期规则
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)expirations(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		// 删除文件自定义元数据
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)file_properties(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		// 删除文件评论
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)comments(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
 		// 删除目录
 		mock.ExpectBegin()
 		mock.ExpectExec("DELETE(.+)").
@@ -558,6 +667,11 @@ func TestFileSystem_Delete(t *testing.T) {
 		mock.ExpectExec("UPDATE(.+)shares").
 			WillReturnResult(sqlmock.NewResult(0, 3))
 		mock.ExpectCommit()
+		// 删除目录到期规则
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)expirations(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
 
 		fs.FileTarget = []model.File{}
 		fs.DirTarget = []model.Folder{}
@@ -0,0 +1,186 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"path"
+	"strconv"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+)
+
+// 清单文件格式
+const (
+	ManifestFormatCSV  = "csv"
+	ManifestFormatJSON = "json"
+)
+
+// manifestBatchSize 生成清单时每批读取的文件数
+const manifestBatchSize = 1000
+
+// manifestHeader CSV 清单的表头
+var manifestHeader = []string{"path", "type", "size", "sha256", "md5", "updated_at", "policy"}
+
+// ManifestEntry 清单中的一个目录或文件
+type ManifestEntry struct {
+	Path      string    `json:"path"`
+	Type      string    `json:"type"`
+	Size      uint64    `json:"size"`
+	SHA256    string    `json:"sha256,omitempty"`
+	MD5       string    `json:"md5,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Policy    string    `json:"policy,omitempty"`
+}
+
+// manifestWriter 按格式写出清单条目
+type manifestWriter interface {
+	Write(entry *ManifestEntry) error
+	Close() error
+}
+
+type csvManifestWriter struct {
+	w *csv.Writer
+}
+
+func (w *csvManifestWriter) Write(entry *ManifestEntry) error {
+	return w.w.Write([]string{
+		entry.Path,
+		entry.Type,
+		strconv.FormatUint(entry.Size, 10),
+		entry.SHA256,
+		entry.MD5,
+		entry.UpdatedAt.Format(time.RFC3339),
+		entry.Policy,
+	})
+}
+
+func (w *csvManifestWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// jsonManifestWriter 以 JSON 数组写出清单，逐条编码，不在内存中保留整个清单
+type jsonManifestWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (w *jsonManifestWriter) Write(entry *ManifestEntry) error {
+	prefix := ","
+	if w.count == 0 {
+		prefix = "["
+	}
+	w.count++
+
+	res, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.w.Write(append([]byte(prefix), res...))
+	return err
+}
+
+func (w *jsonManifestWriter) Close() error {
+	if w.count == 0 {
+		_, err := io.WriteString(w.w, "[]")
+		return err
+	}
+
+	_, err := io.WriteString(w.w, "]")
+	return err
+}
+
+func newManifestWriter(w io.Writer, format string) (manifestWriter, error) {
+	switch format {
+	case ManifestFormatCSV:
+		writer := &csvManifestWriter{w: csv.NewWriter(w)}
+		return writer, writer.w.Write(manifestHeader)
+	case ManifestFormatJSON:
+		return &jsonManifestWriter{w: w}, nil
+	default:
+		return nil, ErrUnknownManifestFormat
+	}
+}
+
+// WriteManifest 将用户全部目录和文件的路径、大小、摘要、修改时间及存储策略以 format 格式写入 w，
+// 先列出全部目录，再按 ID 顺序分批列出文件
+func (fs *FileSystem) WriteManifest(ctx context.Context, w io.Writer, format string) error {
+	writer, err := newManifestWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	root, err := fs.User.Root()
+	if err != nil {
+		return ErrObjectNotExist.WithError(err)
+	}
+
+	// 按层级列出目录，父目录总在子目录之前
+	folders, err := model.GetRecursiveChildFolder([]uint{root.ID}, fs.User.ID, true)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	paths := make(map[uint]string, len(folders))
+	for _, folder := range folders {
+		folderPath := "/"
+		if folder.ParentID != nil {
+			folderPath = path.Join(paths[*folder.ParentID], folder.Name)
+		}
+		paths[folder.ID] = folderPath
+
+		if err := writer.Write(&ManifestEntry{
+			Path:      folderPath,
+			Type:      "dir",
+			UpdatedAt: folder.UpdatedAt,
+		}); err != nil {
+			return err
+		}
+	}
+
+	policies := make(map[uint]string)
+	var after uint
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		files, err := model.GetUserFilesAfter(fs.User.ID, after, manifestBatchSize)
+		if err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+
+		for i := range files {
+			policy, ok := policies[files[i].PolicyID]
+			if !ok {
+				policy = files[i].GetPolicy().Name
+				policies[files[i].PolicyID] = policy
+			}
+
+			if err := writer.Write(&ManifestEntry{
+				Path:      path.Join(paths[files[i].FolderID], files[i].Name),
+				Type:      "file",
+				Size:      files[i].Size,
+				SHA256:    files[i].Hash,
+				MD5:       files[i].MD5,
+				UpdatedAt: files[i].UpdatedAt,
+				Policy:    policy,
+			}); err != nil {
+				return err
+			}
+		}
+
+		if len(files) < manifestBatchSize {
+			break
+		}
+		after = files[len(files)-1].ID
+	}
+
+	return writer.Close()
+}
@@ -0,0 +1,98 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+// expectManifestTree 模拟列出根目录、子目录及文件
+func expectManifestTree(updatedAt time.Time) {
+	mock.ExpectQuery("SELECT(.+)folders(.+)parent_id is NULL(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
+	mock.ExpectQuery("SELECT(.+)folders(.+)id in(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id", "name", "updated_at"}).AddRow(1, nil, "/", updatedAt))
+	mock.ExpectQuery("SELECT(.+)folders(.+)parent_id in(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id", "name", "updated_at"}).AddRow(2, 1, "Docs", updatedAt))
+	mock.ExpectQuery("SELECT(.+)folders(.+)parent_id in(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT(.+)files(.+)").
+		WithArgs(1, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "folder_id", "name", "size", "hash", "md5", "policy_id", "updated_at"}).
+			AddRow(3, 2, "a.txt", 5, "sha", "md5", 181, updatedAt))
+}
+
+func TestFileSystem_WriteManifest(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	ctx := context.Background()
+	updatedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	a.NoError(cache.Set("policy_181", model.Policy{Model: gorm.Model{ID: 181}, Name: "Default"}, 0))
+	defer cache.Deletes([]string{"181"}, "policy_")
+
+	// 未知格式
+	{
+		a.Equal(ErrUnknownManifestFormat, fs.WriteManifest(ctx, &bytes.Buffer{}, "xml"))
+	}
+
+	// CSV
+	{
+		expectManifestTree(updatedAt)
+		buf := &bytes.Buffer{}
+		a.NoError(fs.WriteManifest(ctx, buf, ManifestFormatCSV))
+		a.NoError(mock.ExpectationsWereMet())
+		a.Equal([]string{
+			"path,type,size,sha256,md5,updated_at,policy",
+			"/,dir,0,,,2024-01-02T03:04:05Z,",
+			"/Docs,dir,0,,,2024-01-02T03:04:05Z,",
+			"/Docs/a.txt,file,5,sha,md5,2024-01-02T03:04:05Z,Default",
+		}, strings.Split(strings.TrimSpace(buf.String()), "\n"))
+	}
+
+	// JSON
+	{
+		expectManifestTree(updatedAt)
+		buf := &bytes.Buffer{}
+		a.NoError(fs.WriteManifest(ctx, buf, ManifestFormatJSON))
+		a.NoError(mock.ExpectationsWereMet())
+		var entries []ManifestEntry
+		a.NoError(json.Unmarshal(buf.Bytes(), &entries))
+		a.Len(entries, 3)
+		a.Equal("/Docs/a.txt", entries[2].Path)
+		a.Equal("Default", entries[2].Policy)
+		a.EqualValues(5, entries[2].Size)
+	}
+
+	// 列取文件失败
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)parent_id is NULL(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)id in(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "/"))
+		mock.ExpectQuery("SELECT(.+)folders(.+)parent_id in(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("error"))
+		err := fs.WriteManifest(ctx, &bytes.Buffer{}, ManifestFormatJSON)
+		a.Error(err)
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestJsonManifestWriter_Empty(t *testing.T) {
+	a := assert.New(t)
+	buf := &bytes.Buffer{}
+	writer, err := newManifestWriter(buf, ManifestFormatJSON)
+	a.NoError(err)
+	a.NoError(writer.Close())
+	a.Equal("[]", buf.String())
+}
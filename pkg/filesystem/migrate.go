@@ -0,0 +1,158 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/remote"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/juju/ratelimit"
+)
+
+// MigrateFile 将文件的物理文件复制到存储策略 dst 中，切换所有引用该物理文件的记录后删除原物理文件
+func (fs *FileSystem) MigrateFile(ctx context.Context, file *model.File, dst *model.Policy) error {
+	if file.PolicyID == dst.ID {
+		return nil
+	}
+
+	if file.UploadSessionID != nil {
+		return errors.New("file is still uploading")
+	}
+
+	// 创建源、目标存储策略的适配器
+	srcFs := &FileSystem{User: fs.User, Policy: file.GetPolicy()}
+	if err := srcFs.DispatchHandler(); err != nil {
+		return fmt.Errorf("failed to dispatch source policy: %w", err)
+	}
+
+	dstFs := &FileSystem{User: fs.User, Policy: dst}
+	if err := dstFs.DispatchHandler(); err != nil {
+		return fmt.Errorf("failed to dispatch destination policy: %w", err)
+	}
+
+	// 按目标策略的命名规则生成存储路径
+	folders, err := model.GetFoldersByIDs([]uint{file.FolderID}, file.UserID)
+	if err != nil || len(folders) == 0 {
+		return ErrObjectNotExist.WithError(err)
+	}
+
+	if err := folders[0].TraceRoot(); err != nil {
+		return err
+	}
+
+	srcFiles := []string{file.SourceName}
+	if model.IsTrueVal(file.MetadataSerialized[model.ThumbSidecarMetadataKey]) {
+		srcFiles = append(srcFiles, file.ThumbFile())
+	}
+	srcFiles = append(srcFiles, file.HLSFiles()...)
+	srcFiles = append(srcFiles, file.TranscodedFiles()...)
+
+	ctx = context.WithValue(ctx, fsctx.FileModelCtx, *file)
+	stream := &fsctx.FileStream{
+		Size:        file.Size,
+		Name:        file.Name,
+		VirtualPath: path.Join(folders[0].Position, folders[0].Name),
+	}
+	stream.SavePath = dstFs.GenerateSavePath(ctx, stream)
+	if err := transferFile(ctx, srcFs, dstFs, file, stream); err != nil {
+		return err
+	}
+
+	// 内容寻址的适配器会改写存储路径
+	if err := file.MigrateSource(dst.ID, stream.SavePath); err != nil {
+		if _, deleteErr := dstFs.Handler.Delete(context.Background(), []string{stream.SavePath}); deleteErr != nil {
+			util.Log().Warning("Failed to delete migrated copy %q: %s", stream.SavePath, deleteErr)
+		}
+
+		return err
+	}
+
+	// 同一存储桶内复制到原路径时，原文件即为迁移后的文件
+	if srcFs.Policy.IsSameBucket(dst) && stream.SavePath == file.SourceName {
+		srcFiles = srcFiles[1:]
+	}
+
+	// 物理文件已迁移，原文件删除失败时仅记录日志
+	if len(srcFiles) == 0 {
+		return nil
+	}
+
+	if _, err := srcFs.Handler.Delete(context.Background(), srcFiles); err != nil {
+		util.Log().Warning("Failed to delete migrated source file %q: %s", srcFiles[0], err)
+	}
+
+	return nil
+}
+
+// copier 支持在存储端内部复制文件的适配器
+type copier interface {
+	// Copy 将 src 复制到 dst 的存储路径
+	Copy(ctx context.Context, src string, dst fsctx.FileHeader) error
+}
+
+// transferFile 将源存储策略中的文件写入目标存储策略
+func transferFile(ctx context.Context, srcFs, dstFs *FileSystem, file *model.File, stream *fsctx.FileStream) error {
+	bucket, limited := ctx.Value(fsctx.SpeedLimitBucketCtx).(*ratelimit.Bucket)
+
+	// 源、目标位于同一存储桶时，直接在存储端内部复制
+	if handler, ok := dstFs.Handler.(copier); ok && dstFs.Handler.Capabilities().ServerSideCopy &&
+		srcFs.Policy.IsSameBucket(dstFs.Policy) {
+		err := handler.Copy(ctx, file.SourceName, stream)
+		if err == nil {
+			return nil
+		}
+
+		util.Log().Warning("Failed to copy file %q in storage, relay through master instead: %s", file.Name, err)
+	}
+
+	// 源、目标均为从机时，由目标从机直接从源从机拉取文件
+	if handler, ok := dstFs.Handler.(*remote.Driver); ok && srcFs.Policy.Type == "remote" {
+		speed := 0
+		if limited {
+			speed = int(bucket.Rate())
+		}
+
+		src, err := srcFs.Handler.Source(ctx, file.SourceName, int64(model.GetIntSetting("slave_api_timeout", 60)), true, speed)
+		if err == nil {
+			err = handler.Fetch(ctx, src, stream)
+		}
+
+		if err == nil {
+			return nil
+		}
+
+		util.Log().Warning("Failed to transfer file %q between slave nodes, relay through master instead: %s", file.Name, err)
+	}
+
+	rs, err := srcFs.Handler.Get(ctx, file.SourceName)
+	if err != nil {
+		return fmt.Errorf("failed to read source file: %w", err)
+	}
+
+	if limited {
+		rs = lrs{rs, ratelimit.Reader(rs, bucket)}
+	}
+
+	stream.File = rs
+	stream.Seeker = rs
+	if err := dstFs.Handler.Put(ctx, stream); err != nil {
+		return fmt.Errorf("failed to write destination file: %w", err)
+	}
+
+	return nil
+}
+
+// touchFile 存储策略按访问时间分层时，记录文件的访问时间
+func (fs *FileSystem) touchFile(file *model.File) {
+	if fs.Policy == nil || !fs.Policy.IsTieringEnabled() || fs.Policy.OptionsSerialized.Tiering.MinIdleDays == 0 {
+		return
+	}
+
+	if err := file.Touch(); err != nil {
+		util.Log().Debug("Failed to record access time of file %q: %s", file.Name, err)
+	}
+}
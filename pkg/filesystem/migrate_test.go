@@ -0,0 +1,155 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/juju/ratelimit"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+)
+
+func TestTransferFile(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.txt")
+	a.NoError(os.WriteFile(src, []byte("content"), 0644))
+	file := &model.File{Name: "src.txt", SourceName: src, Size: 7}
+
+	// 经由主机中转，同时限速
+	{
+		srcFs := &FileSystem{Policy: &model.Policy{Type: "local"}, Handler: local.Driver{}}
+		dstFs := &FileSystem{Policy: &model.Policy{Type: "local"}, Handler: local.Driver{}}
+		stream := &fsctx.FileStream{Size: 7, SavePath: filepath.Join(dir, "dst.txt")}
+		ctx := context.WithValue(context.Background(), fsctx.SpeedLimitBucketCtx, ratelimit.NewBucketWithRate(1024, 1024))
+		a.NoError(transferFile(ctx, srcFs, dstFs, file, stream))
+		content, err := os.ReadFile(filepath.Join(dir, "dst.txt"))
+		a.NoError(err)
+		a.Equal("content", string(content))
+	}
+
+	// 源文件不存在
+	{
+		srcFs := &FileSystem{Policy: &model.Policy{Type: "local"}, Handler: local.Driver{}}
+		dstFs := &FileSystem{Policy: &model.Policy{Type: "local"}, Handler: local.Driver{}}
+		stream := &fsctx.FileStream{SavePath: filepath.Join(dir, "dst2.txt")}
+		a.Error(transferFile(context.Background(), srcFs, dstFs, &model.File{SourceName: filepath.Join(dir, "not_exist")}, stream))
+	}
+}
+
+func TestTransferFile_BetweenSlaves(t *testing.T) {
+	a := assert.New(t)
+	file := &model.File{Name: "a.txt", SourceName: "uploads/a.txt", Size: 7}
+	fetchRes := `{"code":0}`
+	var fetchReq map[string]interface{}
+	dstServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v3/slave/fetch" {
+			json.NewDecoder(r.Body).Decode(&fetchReq)
+			w.Write([]byte(fetchRes))
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer dstServer.Close()
+	srcServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srcServer.Close()
+
+	newFs := func(server string) *FileSystem {
+		fs := &FileSystem{User: &model.User{}, Policy: &model.Policy{Type: "remote", SecretKey: "secret", Server: server}}
+		a.NoError(fs.DispatchHandler())
+		return fs
+	}
+
+	// 目标从机直接拉取
+	{
+		stream := &fsctx.FileStream{Size: 7, SavePath: "uploads/b.txt"}
+		a.NoError(transferFile(context.Background(), newFs(srcServer.URL), newFs(dstServer.URL), file, stream))
+		a.Equal("uploads/b.txt", fetchReq["dst"])
+		a.Contains(fetchReq["src"], srcServer.URL+"/api/v3/slave/download/0/")
+	}
+
+	// 拉取失败时经由主机中转
+	{
+		fetchRes = `{"code":40001}`
+		stream := &fsctx.FileStream{Size: 7, SavePath: "uploads/b.txt"}
+		err := transferFile(context.Background(), newFs(srcServer.URL), newFs(dstServer.URL), file, stream)
+		a.Error(err)
+		a.Contains(err.Error(), "failed to read source file")
+	}
+}
+
+// copyHandler 支持在存储端内部复制的适配器
+type copyHandler struct {
+	*FileHeaderMock
+	err    error
+	copied []string
+}
+
+func (h *copyHandler) Capabilities() driver.Capabilities {
+	return driver.Capabilities{ServerSideCopy: true}
+}
+
+func (h *copyHandler) Copy(ctx context.Context, src string, dst fsctx.FileHeader) error {
+	h.copied = append(h.copied, src+"->"+dst.Info().SavePath)
+	return h.err
+}
+
+func TestTransferFile_ServerSideCopy(t *testing.T) {
+	a := assert.New(t)
+	file := &model.File{Name: "a.txt", SourceName: "uploads/a.txt", Size: 7}
+	srcPolicy := &model.Policy{Type: "s3", Server: "https://s3.example.com", BucketName: "bucket"}
+
+	// 同一存储桶内直接复制
+	{
+		srcHandler := &FileHeaderMock{}
+		dstHandler := &copyHandler{FileHeaderMock: &FileHeaderMock{}}
+		srcFs := &FileSystem{Policy: srcPolicy, Handler: srcHandler}
+		dstFs := &FileSystem{Policy: &model.Policy{Type: "s3", Server: "https://s3.example.com", BucketName: "bucket"}, Handler: dstHandler}
+		stream := &fsctx.FileStream{Size: 7, SavePath: "uploads/b.txt"}
+		a.NoError(transferFile(context.Background(), srcFs, dstFs, file, stream))
+		a.Equal([]string{"uploads/a.txt->uploads/b.txt"}, dstHandler.copied)
+		srcHandler.AssertNotCalled(t, "Get", testMock.Anything, testMock.Anything)
+	}
+
+	// 复制失败时经由主机中转
+	{
+		srcHandler := &FileHeaderMock{}
+		srcHandler.On("Get", testMock.Anything, "uploads/a.txt").Return(MockRSC{rs: strings.NewReader("content")}, nil)
+		dstHandler := &copyHandler{FileHeaderMock: &FileHeaderMock{}, err: errors.New("error")}
+		dstHandler.FileHeaderMock.On("Put", testMock.Anything, testMock.Anything).Return(nil)
+		srcFs := &FileSystem{Policy: srcPolicy, Handler: srcHandler}
+		dstFs := &FileSystem{Policy: srcPolicy, Handler: dstHandler}
+		stream := &fsctx.FileStream{Size: 7, SavePath: "uploads/b.txt"}
+		a.NoError(transferFile(context.Background(), srcFs, dstFs, file, stream))
+		a.Len(dstHandler.copied, 1)
+		srcHandler.AssertExpectations(t)
+		dstHandler.FileHeaderMock.AssertExpectations(t)
+	}
+
+	// 不同存储桶
+	{
+		srcHandler := &FileHeaderMock{}
+		srcHandler.On("Get", testMock.Anything, "uploads/a.txt").Return(MockRSC{rs: strings.NewReader("content")}, nil)
+		dstHandler := &copyHandler{FileHeaderMock: &FileHeaderMock{}}
+		dstHandler.FileHeaderMock.On("Put", testMock.Anything, testMock.Anything).Return(nil)
+		srcFs := &FileSystem{Policy: srcPolicy, Handler: srcHandler}
+		dstFs := &FileSystem{Policy: &model.Policy{Type: "s3", Server: "https://s3.example.com", BucketName: "other"}, Handler: dstHandler}
+		stream := &fsctx.FileStream{Size: 7, SavePath: "uploads/b.txt"}
+		a.NoError(transferFile(context.Background(), srcFs, dstFs, file, stream))
+		a.Empty(dstHandler.copied)
+		srcHandler.AssertExpectations(t)
+	}
+}
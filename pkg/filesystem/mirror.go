@@ -0,0 +1,33 @@
+package filesystem
+
+import (
+	"fmt"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/mirror"
+)
+
+// newMirrorHandler 创建镜像策略适配器及其所有副本的适配器
+func (fs *FileSystem) newMirrorHandler(policy *model.Policy) (*mirror.Driver, error) {
+	replicas := make([]mirror.Replica, 0, len(policy.OptionsSerialized.MirrorPolicies))
+	for _, id := range policy.OptionsSerialized.MirrorPolicies {
+		replicaPolicy, err := model.GetPolicyByID(id)
+		if err != nil {
+			return nil, fmt.Errorf("replica policy %d not exist: %w", id, err)
+		}
+
+		// 副本需使用与镜像策略一致的存储路径
+		if replicaPolicy.Type == "mirror" || replicaPolicy.Type == "ipfs" {
+			return nil, fmt.Errorf("policy type %q cannot be used as replica", replicaPolicy.Type)
+		}
+
+		replicaFs := &FileSystem{User: fs.User, Policy: &replicaPolicy}
+		if err := replicaFs.DispatchHandler(); err != nil {
+			return nil, fmt.Errorf("failed to dispatch replica policy %d: %w", id, err)
+		}
+
+		replicas = append(replicas, mirror.Replica{Policy: &replicaPolicy, Handler: replicaFs.Handler})
+	}
+
+	return mirror.NewDriver(policy, replicas)
+}
@@ -0,0 +1,42 @@
+package filesystem
+
+import (
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/mirror"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_DispatchMirrorHandler(t *testing.T) {
+	a := assert.New(t)
+	cache.Set("policy_201", model.Policy{Model: gorm.Model{ID: 201}, Type: "local"}, 0)
+	cache.Set("policy_202", model.Policy{Model: gorm.Model{ID: 202}, Type: "remote"}, 0)
+	cache.Set("policy_203", model.Policy{Model: gorm.Model{ID: 203}, Type: "mirror"}, 0)
+	defer cache.Deletes([]string{"201", "202", "203"}, "policy_")
+	fs := &FileSystem{User: &model.User{}}
+
+	// 成功
+	{
+		fs.Policy = &model.Policy{Type: "mirror", OptionsSerialized: model.PolicyOption{MirrorPolicies: []uint{201, 202}}}
+		a.NoError(fs.DispatchHandler())
+		handler, ok := fs.Handler.(*mirror.Driver)
+		a.True(ok)
+		a.Len(handler.Replicas, 2)
+		a.EqualValues(201, handler.Replicas[0].Policy.ID)
+	}
+
+	// 副本数量不足
+	{
+		fs.Policy = &model.Policy{Type: "mirror", OptionsSerialized: model.PolicyOption{MirrorPolicies: []uint{201}}}
+		a.ErrorIs(fs.DispatchHandler(), mirror.ErrNotEnoughReplicas)
+	}
+
+	// 嵌套的镜像策略
+	{
+		fs.Policy = &model.Policy{Type: "mirror", OptionsSerialized: model.PolicyOption{MirrorPolicies: []uint{201, 203}}}
+		a.Error(fs.DispatchHandler())
+	}
+}
@@ -0,0 +1,91 @@
+package filesystem
+
+import (
+	"context"
+	"path"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+)
+
+// MountShare 将其他用户分享的目录以 name 为名挂载至 dst 目录下，挂载的内容只读
+func (fs *FileSystem) MountShare(ctx context.Context, share *model.Share, password, dst, name string) (*model.Folder, error) {
+	if !fs.ValidateLegalName(ctx, name) {
+		return nil, ErrIllegalObjectName
+	}
+
+	if err := fs.checkMountable(share, password); err != nil {
+		return nil, err
+	}
+
+	isExist, parent := fs.IsPathExist(dst)
+	if !isExist {
+		return nil, ErrPathNotExist
+	}
+
+	if err := fs.CheckWritable(parent); err != nil {
+		return nil, err
+	}
+
+	// 是否有同名文件
+	if ok, _ := fs.IsChildFileExist(parent, name); ok {
+		return nil, ErrFileExisted
+	}
+
+	mount, err := parent.CreateMountPoint(name, share.ID, password)
+	if err != nil {
+		return nil, ErrFileExisted.WithError(err)
+	}
+
+	RecordChanges(model.NewFolderChange(model.ChangeCreated, mount, path.Join(dst, name)))
+	return mount, nil
+}
+
+// CheckWritable 检查当前用户能否修改 folder 目录下的内容，挂载的分享目录及其子目录只读
+func (fs *FileSystem) CheckWritable(folder *model.Folder) error {
+	if folder.OwnerID != fs.User.ID || folder.IsMount() {
+		return ErrMountReadOnly
+	}
+	return nil
+}
+
+// checkMountable 检查分享能否被当前用户挂载，只有其他用户创建、允许下载且不限下载及浏览次数的目录分享可被挂载，
+// 合集分享不对应实际目录，不可挂载
+func (fs *FileSystem) checkMountable(share *model.Share, password string) error {
+	if !share.IsDir || share.Collection || share.UserID == fs.User.ID || share.RemainDownloads >= 0 || share.RemainViews >= 0 ||
+		!share.Can(model.SharePermissionDownload) {
+		return ErrShareNotMountable
+	}
+
+	if !share.IsAvailable() || !share.IsActivated() {
+		return ErrMountUnavailable
+	}
+
+	if share.Password != password {
+		return ErrMountPassword
+	}
+
+	if err := share.CanBeDownloadBy(fs.User); err != nil {
+		return serializer.NewError(serializer.CodeGroupNotAllowed, err.Error(), nil)
+	}
+
+	return nil
+}
+
+// resolveMount 返回挂载点对应的分享目录，目录名称及路径沿用挂载点
+func (fs *FileSystem) resolveMount(mount *model.Folder) (*model.Folder, error) {
+	share, err := model.GetShareByID(mount.MountShareID)
+	if err != nil {
+		return nil, ErrMountUnavailable.WithError(err)
+	}
+
+	if err := fs.checkMountable(share, mount.MountPassword); err != nil {
+		return nil, err
+	}
+
+	folder := *share.SourceFolder()
+	folder.Name = mount.Name
+	folder.Position = mount.Position
+	folder.MountPoint = mount
+	return &folder, nil
+}
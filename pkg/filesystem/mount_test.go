@@ -0,0 +1,205 @@
+package filesystem
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func newMountableShare() *model.Share {
+	return &model.Share{
+		Model:           gorm.Model{ID: 3},
+		IsDir:           true,
+		UserID:          2,
+		SourceID:        10,
+		RemainDownloads: -1,
+		RemainViews:     -1,
+		Password:        "pwd",
+		User:            model.User{Model: gorm.Model{ID: 2}, Status: model.Active},
+		Folder:          model.Folder{Model: gorm.Model{ID: 10}, OwnerID: 2, Name: "Docs"},
+	}
+}
+
+func TestFileSystem_MountShare(t *testing.T) {
+	a := assert.New(t)
+	user := &model.User{Model: gorm.Model{ID: 1}}
+	user.Group.OptionsSerialized.ShareDownload = true
+	fs := &FileSystem{User: user}
+	ctx := context.Background()
+
+	// 名称不合法
+	{
+		_, err := fs.MountShare(ctx, newMountableShare(), "pwd", "/", "")
+		a.Equal(ErrIllegalObjectName, err)
+	}
+
+	// 文件分享
+	{
+		share := newMountableShare()
+		share.IsDir = false
+		_, err := fs.MountShare(ctx, share, "pwd", "/", "Shared")
+		a.Equal(ErrShareNotMountable, err)
+	}
+
+	// 自己的分享
+	{
+		share := newMountableShare()
+		share.UserID = 1
+		_, err := fs.MountShare(ctx, share, "pwd", "/", "Shared")
+		a.Equal(ErrShareNotMountable, err)
+	}
+
+	// 限制下载次数的分享
+	{
+		share := newMountableShare()
+		share.RemainDownloads = 5
+		_, err := fs.MountShare(ctx, share, "pwd", "/", "Shared")
+		a.Equal(ErrShareNotMountable, err)
+	}
+
+	// 限制浏览次数的分享
+	{
+		share := newMountableShare()
+		share.RemainViews = 5
+		_, err := fs.MountShare(ctx, share, "pwd", "/", "Shared")
+		a.Equal(ErrShareNotMountable, err)
+	}
+
+	// 合集分享
+	{
+		share := newMountableShare()
+		share.Collection = true
+		_, err := fs.MountShare(ctx, share, "pwd", "/", "Shared")
+		a.Equal(ErrShareNotMountable, err)
+	}
+
+	// 密码错误
+	{
+		_, err := fs.MountShare(ctx, newMountableShare(), "wrong", "/", "Shared")
+		a.Equal(ErrMountPassword, err)
+	}
+
+	// 用户组无权下载分享
+	{
+		noPermissionFs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+		_, err := noPermissionFs.MountShare(ctx, newMountableShare(), "pwd", "/", "Shared")
+		a.Error(err)
+		a.Equal(serializer.CodeGroupNotAllowed, err.(serializer.AppError).Code)
+	}
+
+	// 目标目录不存在
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		_, err := fs.MountShare(ctx, newMountableShare(), "pwd", "/", "Shared")
+		a.NoError(mock.ExpectationsWereMet())
+		a.Equal(ErrPathNotExist, err)
+	}
+
+	// 存在同名文件
+	{
+		mock.ExpectQuery("SELECT(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Shared"))
+		_, err := fs.MountShare(ctx, newMountableShare(), "pwd", "/", "Shared")
+		a.NoError(mock.ExpectationsWereMet())
+		a.Equal(ErrFileExisted, err)
+	}
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.ExpectCommit()
+		mount, err := fs.MountShare(ctx, newMountableShare(), "pwd", "/", "Shared")
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.EqualValues(5, mount.ID)
+		a.EqualValues(3, mount.MountShareID)
+	}
+}
+
+func TestFileSystem_CheckWritable(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+
+	a.NoError(fs.CheckWritable(&model.Folder{OwnerID: 1}))
+	a.Equal(ErrMountReadOnly, fs.CheckWritable(&model.Folder{OwnerID: 2}))
+	a.Equal(ErrMountReadOnly, fs.CheckWritable(&model.Folder{OwnerID: 1, MountShareID: 3}))
+}
+
+func TestFileSystem_IsPathExist_Mount(t *testing.T) {
+	a := assert.New(t)
+	user := &model.User{Model: gorm.Model{ID: 1}}
+	user.Group.OptionsSerialized.ShareDownload = true
+	fs := &FileSystem{User: user}
+
+	expectMount := func() {
+		// 根目录
+		mock.ExpectQuery("SELECT(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id", "name"}).AddRow(1, 1, "/"))
+		// 挂载点
+		mock.ExpectQuery("SELECT(.+)").WithArgs(1, 1, "Shared").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id", "name", "mount_share_id", "mount_password"}).
+				AddRow(2, 1, "Shared", 3, "pwd"))
+		// 分享
+		mock.ExpectQuery("SELECT(.+)shares(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "is_dir", "user_id", "source_id", "remain_downloads", "remain_views", "password"}).
+				AddRow(3, true, 2, 10, -1, -1, "pwd"))
+		// 分享者
+		mock.ExpectQuery("SELECT(.+)users(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(2, model.Active))
+		// 分享的目录
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id", "name"}).AddRow(10, 2, "Docs"))
+	}
+
+	// 解析为分享的目录
+	{
+		expectMount()
+		exist, folder := fs.IsPathExist("/Shared")
+		a.NoError(mock.ExpectationsWereMet())
+		a.True(exist)
+		a.EqualValues(10, folder.ID)
+		a.EqualValues(2, folder.OwnerID)
+		a.Equal("Shared", folder.Name)
+		a.Equal("/", folder.Position)
+		a.EqualValues(2, folder.MountPoint.ID)
+		a.Equal(ErrMountReadOnly, fs.CheckWritable(folder))
+	}
+
+	// 分享目录下的子目录
+	{
+		expectMount()
+		mock.ExpectQuery("SELECT(.+)").WithArgs(10, 2, "Sub").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id", "name"}).AddRow(11, 2, "Sub"))
+		exist, folder := fs.IsPathExist("/Shared/Sub")
+		a.NoError(mock.ExpectationsWereMet())
+		a.True(exist)
+		a.EqualValues(11, folder.ID)
+		a.Equal("/Shared", folder.Position)
+	}
+
+	// 分享已失效
+	{
+		mock.ExpectQuery("SELECT(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
+		mock.ExpectQuery("SELECT(.+)").WithArgs(1, 1, "Shared").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id", "name", "mount_share_id"}).
+				AddRow(2, 1, "Shared", 3))
+		mock.ExpectQuery("SELECT(.+)shares(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		exist, _ := fs.IsPathExist("/Shared")
+		a.NoError(mock.ExpectationsWereMet())
+		a.False(exist)
+	}
+}
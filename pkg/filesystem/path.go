@@ -2,6 +2,7 @@ package filesystem
 
 import (
 	"path"
+	"strings"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
@@ -46,12 +47,48 @@ func (fs *FileSystem) IsPathExist(path string) (bool, *model.Folder) {
 			if err != nil {
 				return false, nil
 			}
+
+			// 步入挂载点时转至挂载的分享目录，分享页面中不展开挂载点
+			if currentFolder.IsMount() && fs.Root == nil {
+				currentFolder, err = fs.resolveMount(currentFolder)
+				if err != nil {
+					return false, nil
+				}
+			}
 		}
 	}
 
 	return true, currentFolder
 }
 
+// folderPolicyID 从用户根目录逐级查找 virtualPath，返回最近的已存在目录链上绑定的存储策略 ID
+func (fs *FileSystem) folderPolicyID(virtualPath string) uint {
+	var (
+		current  *model.Folder
+		policyID uint
+		err      error
+	)
+
+	for _, folderName := range util.SplitPath(virtualPath) {
+		if folderName == "/" {
+			current, err = fs.User.Root()
+		} else {
+			current, err = current.GetChild(folderName)
+		}
+
+		// 尚未创建的目录继承上级目录的策略
+		if err != nil {
+			break
+		}
+
+		if current.PolicyID != 0 {
+			policyID = current.PolicyID
+		}
+	}
+
+	return policyID
+}
+
 // IsFileExist 返回给定路径的文件是否存在
 func (fs *FileSystem) IsFileExist(fullPath string) (bool, *model.File) {
 	basePath := path.Dir(fullPath)
@@ -73,3 +110,14 @@ func (fs *FileSystem) IsChildFileExist(folder *model.Folder, name string) (bool,
 	file, err := folder.GetChildFile(name)
 	return err == nil, file
 }
+
+// SplitRelativePath 将客户端提供的相对路径（如 webkitRelativePath）拆分为目录和文件名，
+// 路径中的 "." 和 ".." 会被消除，结果不会超出上传的根目录
+func SplitRelativePath(relativePath string) (string, string, error) {
+	fullPath := path.Clean("/" + strings.ReplaceAll(relativePath, "\\", "/"))
+	if fullPath == "/" {
+		return "", "", ErrIllegalObjectName
+	}
+
+	return strings.TrimPrefix(path.Dir(fullPath), "/"), path.Base(fullPath), nil
+}
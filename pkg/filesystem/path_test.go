@@ -170,3 +170,28 @@ func TestFileSystem_IsChildFileExist(t *testing.T) {
 	asserts.True(exist)
 	asserts.Equal("/123", childFile.Position)
 }
+
+func TestSplitRelativePath(t *testing.T) {
+	asserts := assert.New(t)
+	testCases := []struct {
+		relativePath string
+		dir          string
+		name         string
+	}{
+		{"1.txt", "", "1.txt"},
+		{"folder/sub/1.txt", "folder/sub", "1.txt"},
+		{"/folder/./1.txt", "folder", "1.txt"},
+		{"../../folder/1.txt", "folder", "1.txt"},
+		{"folder\\sub\\1.txt", "folder/sub", "1.txt"},
+	}
+
+	for _, testCase := range testCases {
+		dir, name, err := SplitRelativePath(testCase.relativePath)
+		asserts.NoError(err)
+		asserts.Equal(testCase.dir, dir, testCase.relativePath)
+		asserts.Equal(testCase.name, name, testCase.relativePath)
+	}
+
+	_, _, err := SplitRelativePath("../")
+	asserts.Equal(ErrIllegalObjectName, err)
+}
@@ -0,0 +1,75 @@
+package filesystem
+
+import (
+	"regexp"
+	"strings"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+)
+
+// 自定义元数据名称由可选的 {命名空间} 前缀和 XML 本地名组成，
+// 以便同时作为 WebDAV 的自定义属性使用
+var propertyLocalName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+const maxPropertyName = 255
+
+// ValidatePropertyName 检查自定义元数据名称是否合法
+func ValidatePropertyName(name string) bool {
+	if name == "" || len(name) > maxPropertyName {
+		return false
+	}
+
+	if strings.HasPrefix(name, "{") {
+		end := strings.Index(name, "}")
+		if end <= 1 {
+			return false
+		}
+		name = name[end+1:]
+	}
+
+	return propertyLocalName.MatchString(name)
+}
+
+// UpdateFileProperties 移除并设定文件的自定义元数据，同时出现时以设定为准。
+// 更新后元数据的数量及名称、值的总长度不能超过系统设定的上限
+func UpdateFileProperties(file *model.File, set map[string]string, remove []string) error {
+	for name := range set {
+		if !ValidatePropertyName(name) {
+			return ErrInvalidPropertyName
+		}
+	}
+
+	existed, err := model.GetFileProperties(file.ID)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	// 计算更新后的元数据
+	props := make(map[string]string, len(existed)+len(set))
+	for _, prop := range existed {
+		props[prop.Name] = prop.Value
+	}
+	for _, name := range remove {
+		delete(props, name)
+	}
+	for name, value := range set {
+		props[name] = value
+	}
+
+	size := 0
+	for name, value := range props {
+		size += len(name) + len(value)
+	}
+
+	if len(props) > model.GetIntSetting("file_property_max_num", 64) ||
+		size > model.GetIntSetting("file_property_max_size", 16384) {
+		return ErrPropertyExceeded
+	}
+
+	if err := model.UpdateFileProperties(file.UserID, file.ID, set, remove); err != nil {
+		return serializer.NewError(serializer.CodeDBError, "Failed to update file properties", err)
+	}
+
+	return nil
+}
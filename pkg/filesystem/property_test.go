@@ -0,0 +1,67 @@
+package filesystem
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePropertyName(t *testing.T) {
+	a := assert.New(t)
+
+	a.True(ValidatePropertyName("author"))
+	a.True(ValidatePropertyName("{http://example.com/ns}camera.model"))
+	a.False(ValidatePropertyName(""))
+	a.False(ValidatePropertyName("my key"))
+	a.False(ValidatePropertyName("1st"))
+	a.False(ValidatePropertyName("{}author"))
+	a.False(ValidatePropertyName("{http://example.com/ns"))
+	a.False(ValidatePropertyName(strings.Repeat("a", 256)))
+}
+
+func TestUpdateFileProperties(t *testing.T) {
+	a := assert.New(t)
+	file := &model.File{Model: gorm.Model{ID: 1}, UserID: 1}
+	cache.Set("setting_file_property_max_num", "2", 0)
+	cache.Set("setting_file_property_max_size", "20", 0)
+	defer cache.Deletes([]string{"file_property_max_num", "file_property_max_size"}, "setting_")
+
+	// 名称不合法
+	{
+		a.Equal(ErrInvalidPropertyName, UpdateFileProperties(file, map[string]string{"a b": "1"}, nil))
+	}
+
+	// 超出数量
+	{
+		mock.ExpectQuery("SELECT(.+)file_properties(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"name", "value"}).AddRow("a", "1").AddRow("b", "2"))
+		a.Equal(ErrPropertyExceeded, UpdateFileProperties(file, map[string]string{"c": "3"}, nil))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 超出大小
+	{
+		mock.ExpectQuery("SELECT(.+)file_properties(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"name", "value"}))
+		a.Equal(ErrPropertyExceeded, UpdateFileProperties(file, map[string]string{"c": strings.Repeat("v", 20)}, nil))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 移除后替换，成功
+	{
+		mock.ExpectQuery("SELECT(.+)file_properties(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"name", "value"}).AddRow("a", "1").AddRow("b", "2"))
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)file_properties(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT(.+)file_properties(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("INSERT(.+)file_properties(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		a.NoError(UpdateFileProperties(file, map[string]string{"c": "3"}, []string{"b"}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
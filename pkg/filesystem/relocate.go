@@ -0,0 +1,87 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// RelocateFile 文件移动后，在存储端内部将物理文件复制到按新的虚拟路径生成的存储路径，
+// 切换所有引用该物理文件的记录后删除原物理文件。存储路径未变化时不做处理
+func (fs *FileSystem) RelocateFile(ctx context.Context, file *model.File) error {
+	if file.UploadSessionID != nil {
+		return errors.New("file is still uploading")
+	}
+
+	policy := file.GetPolicy()
+	if !policy.IsRelocateOnMove() {
+		return nil
+	}
+
+	relocateFs := &FileSystem{User: fs.User, Policy: policy}
+	if err := relocateFs.DispatchHandler(); err != nil {
+		return fmt.Errorf("failed to dispatch policy: %w", err)
+	}
+
+	handler, ok := relocateFs.Handler.(copier)
+	if !ok || !relocateFs.Handler.Capabilities().ServerSideCopy {
+		return ErrServerSideCopyNotSupport
+	}
+
+	folders, err := model.GetFoldersByIDs([]uint{file.FolderID}, file.UserID)
+	if err != nil || len(folders) == 0 {
+		return ErrObjectNotExist.WithError(err)
+	}
+
+	if err := folders[0].TraceRoot(); err != nil {
+		return err
+	}
+
+	// 仅替换目录部分，保留已生成的存储文件名
+	dst := path.Join(
+		policy.GeneratePath(file.UserID, path.Join(folders[0].Position, folders[0].Name)),
+		path.Base(file.SourceName),
+	)
+	if dst == file.SourceName {
+		return nil
+	}
+
+	srcFiles := []string{file.SourceName}
+	if model.IsTrueVal(file.MetadataSerialized[model.ThumbSidecarMetadataKey]) {
+		srcFiles = append(srcFiles, file.ThumbFile())
+	}
+	srcFiles = append(srcFiles, file.HLSFiles()...)
+	srcFiles = append(srcFiles, file.TranscodedFiles()...)
+
+	// 中断后重试时目标文件可能已存在，允许覆盖
+	ctx = context.WithValue(ctx, fsctx.FileModelCtx, *file)
+	stream := &fsctx.FileStream{
+		Size:     file.Size,
+		Name:     file.Name,
+		SavePath: dst,
+		Mode:     fsctx.Overwrite,
+	}
+	if err := handler.Copy(ctx, file.SourceName, stream); err != nil {
+		return fmt.Errorf("failed to copy file in storage: %w", err)
+	}
+
+	if err := file.MigrateSource(policy.ID, dst); err != nil {
+		if _, deleteErr := relocateFs.Handler.Delete(context.Background(), []string{dst}); deleteErr != nil {
+			util.Log().Warning("Failed to delete relocated copy %q: %s", dst, deleteErr)
+		}
+
+		return err
+	}
+
+	// 物理文件已迁移，原文件删除失败时仅记录日志
+	if _, err := relocateFs.Handler.Delete(context.Background(), srcFiles); err != nil {
+		util.Log().Warning("Failed to delete relocated source file %q: %s", srcFiles[0], err)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package filesystem
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_RelocateFile(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{}}
+	newPolicy := func(policyType string) model.Policy {
+		policy := model.Policy{Model: gorm.Model{ID: 1}, Type: policyType, DirNameRule: "uploads/{path}"}
+		policy.OptionsSerialized.RelocateOnMove = true
+		return policy
+	}
+
+	// 上传中的文件
+	{
+		sessionID := "session"
+		a.Error(fs.RelocateFile(context.Background(), &model.File{UploadSessionID: &sessionID}))
+	}
+
+	// 未开启
+	{
+		file := &model.File{SourceName: "uploads/a.txt", Policy: model.Policy{Model: gorm.Model{ID: 1}, DirNameRule: "uploads/{path}"}}
+		a.NoError(fs.RelocateFile(context.Background(), file))
+	}
+
+	// 适配器不支持存储端复制
+	{
+		file := &model.File{SourceName: "uploads/a.txt", Policy: newPolicy("local")}
+		a.Equal(ErrServerSideCopyNotSupport, fs.RelocateFile(context.Background(), file))
+	}
+
+	// 存储路径未变化
+	{
+		file := &model.File{SourceName: "uploads/a.txt", FolderID: 1, UserID: 1, Policy: newPolicy("s3")}
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "position"}).AddRow(1, "/", "."))
+		a.NoError(fs.RelocateFile(context.Background(), file))
+		a.NoError(mock.ExpectationsWereMet())
+		a.Equal("uploads/a.txt", file.SourceName)
+	}
+
+	// 目录不存在
+	{
+		file := &model.File{SourceName: "uploads/a.txt", FolderID: 1, UserID: 1, Policy: newPolicy("s3")}
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		a.Error(fs.RelocateFile(context.Background(), file))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
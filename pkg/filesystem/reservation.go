@@ -0,0 +1,80 @@
+package filesystem
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gofrs/uuid"
+)
+
+/* ================
+	 容量预留
+   ================
+*/
+
+// streamReservations 未使用上传会话的文件流到预留标识的映射
+var streamReservations sync.Map
+
+// reservationKey 返回上传对应的预留标识，上传会话以会话 ID 为标识，其他上传为文件流生成随机标识
+func reservationKey(file fsctx.FileHeader) string {
+	if id := file.Info().UploadSessionID; id != nil {
+		return *id
+	}
+
+	if key, ok := streamReservations.Load(file); ok {
+		return key.(string)
+	}
+
+	key, _ := streamReservations.LoadOrStore(file, uuid.Must(uuid.NewV4()).String())
+	return key.(string)
+}
+
+// ReserveCapacity 为上传预留 size 大小的容量，已用容量、其他上传已预留的容量与 size 之和
+// 超出用户总容量时返回 ErrInsufficientCapacity。同一标识已预留的容量足够时直接返回，
+// 否则以 size 替换原有预留。预留记录保存在数据库中，由所有主机实例共享
+func (fs *FileSystem) ReserveCapacity(key string, size uint64, ttl time.Duration) error {
+	if size == 0 {
+		return nil
+	}
+
+	if r, err := model.GetCapacityReservation(key); err == nil && r.UserID == fs.User.ID && size <= r.Size {
+		return nil
+	}
+
+	// 已用容量需要从数据库读取，其他请求可能已更新
+	storage, err := model.ReserveCapacity(fs.User.ID, key, size, fs.User.Group.MaxStorage, ttl)
+	if errors.Is(err, model.ErrReservationExceeded) {
+		fs.User.Storage = storage
+		return ErrInsufficientCapacity
+	}
+
+	if err != nil {
+		return ErrDBGetStorage.WithError(err)
+	}
+
+	fs.User.Storage = storage
+	return nil
+}
+
+// ConfirmCapacity 已用容量增加 size 后从预留中扣除相应部分，扣完后删除预留
+func ConfirmCapacity(key string, size uint64) {
+	if err := model.ConfirmCapacityReservation(key, size); err != nil {
+		util.Log().Warning("Failed to confirm capacity reservation %q: %s", key, err)
+	}
+}
+
+// ReleaseCapacity 释放预留的容量
+func ReleaseCapacity(key string) {
+	if err := model.ReleaseCapacityReservation(key); err != nil {
+		util.Log().Warning("Failed to release capacity reservation %q: %s", key, err)
+	}
+}
+
+// reservationTTL 预留容量的有效期，超时未确认或释放的预留将被忽略
+func reservationTTL() time.Duration {
+	return time.Duration(model.GetIntSetting("upload_session_timeout", 86400)) * time.Second
+}
@@ -0,0 +1,139 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+// expectReservation 模拟查询已有预留
+func expectReservation(uid uint, size uint64) {
+	rows := sqlmock.NewRows([]string{"id", "user_id", "size"})
+	if size > 0 {
+		rows.AddRow(1, uid, size)
+	}
+	mock.ExpectQuery("SELECT(.+)capacity_reservations(.+)").WillReturnRows(rows)
+}
+
+// expectReserve 模拟预留容量的事务，exceeded 为 true 时预留失败
+func expectReserve(storage, reserved uint64, exceeded bool) {
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)users(.+)storage").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT(.+)storage(.+)").WillReturnRows(sqlmock.NewRows([]string{"storage"}).AddRow(storage))
+	mock.ExpectQuery("SELECT(.+)SUM(.+)capacity_reservations(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"size"}).AddRow(reserved))
+	if exceeded {
+		mock.ExpectRollback()
+		return
+	}
+	mock.ExpectExec("DELETE(.+)capacity_reservations(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT(.+)capacity_reservations(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+}
+
+func TestFileSystem_ReserveCapacity(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{
+		Model: gorm.Model{ID: 1},
+		Group: model.Group{MaxStorage: 10},
+	}}
+
+	// 无需预留
+	asserts.NoError(fs.ReserveCapacity("a", 0, time.Minute))
+
+	// 预留成功，已用容量从数据库更新
+	expectReservation(1, 0)
+	expectReserve(5, 0, false)
+	asserts.NoError(fs.ReserveCapacity("a", 3, time.Minute))
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.EqualValues(5, fs.User.Storage)
+
+	// 其他上传已预留的容量计入
+	expectReservation(1, 0)
+	expectReserve(5, 3, true)
+	asserts.Equal(ErrInsufficientCapacity, fs.ReserveCapacity("b", 3, time.Minute))
+	asserts.NoError(mock.ExpectationsWereMet())
+
+	// 已预留的容量足够时不再检查
+	expectReservation(1, 3)
+	asserts.NoError(fs.ReserveCapacity("a", 2, time.Minute))
+	asserts.NoError(mock.ExpectationsWereMet())
+
+	// 其他用户的预留不可复用
+	expectReservation(2, 3)
+	expectReserve(5, 0, false)
+	asserts.NoError(fs.ReserveCapacity("c", 2, time.Minute))
+	asserts.NoError(mock.ExpectationsWereMet())
+
+	// 数据库操作失败
+	expectReservation(1, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)users(.+)").WillReturnError(errors.New("error"))
+	mock.ExpectRollback()
+	asserts.Error(fs.ReserveCapacity("b", 1, time.Minute))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
+func TestConfirmCapacity(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 扣除部分预留
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)capacity_reservations(.+)").WithArgs(1, "a", 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	ConfirmCapacity("a", 1)
+	asserts.NoError(mock.ExpectationsWereMet())
+
+	// 扣完后删除预留
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)capacity_reservations(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)capacity_reservations(.+)").WithArgs("a").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	ConfirmCapacity("a", 5)
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
+func TestHookReserveCapacity(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	fs := &FileSystem{User: &model.User{
+		Model: gorm.Model{ID: 1},
+		Group: model.Group{MaxStorage: 10},
+	}}
+	sessionID := "session"
+	session := &fsctx.FileStream{Size: 6, UploadSessionID: &sessionID}
+	stream := &fsctx.FileStream{Size: 5}
+
+	// 上传会话以会话 ID 为标识
+	asserts.Equal("session", reservationKey(session))
+	expectReservation(1, 0)
+	expectReserve(0, 0, false)
+	asserts.NoError(HookReserveCapacity(ctx, fs, session))
+	asserts.NoError(mock.ExpectationsWereMet())
+
+	// 其他上传使用固定的随机标识
+	key := reservationKey(stream)
+	asserts.NotEmpty(key)
+	asserts.Equal(key, reservationKey(stream))
+	other := &fsctx.FileStream{}
+	asserts.NotEqual(key, reservationKey(other))
+	streamReservations.Delete(other)
+
+	// 释放后移除文件流的标识
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)capacity_reservations(.+)").WithArgs(key).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	asserts.NoError(HookReleaseCapacity(ctx, fs, stream))
+	asserts.NoError(mock.ExpectationsWereMet())
+	_, ok := streamReservations.Load(stream)
+	asserts.False(ok)
+}
@@ -0,0 +1,91 @@
+package filesystem
+
+import (
+	"context"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+/* ================
+	 归档存储解冻
+   ================
+*/
+
+// restorer 支持归档存储类型的存储策略适配器
+type restorer interface {
+	// RestoreStatus 返回文件是否处于需解冻才能访问的归档存储中，以及是否已有可访问的解冻副本
+	RestoreStatus(ctx context.Context, path string) (archived, restored bool, err error)
+	// Restore 发起解冻请求，解冻副本保留 days 天。已有进行中的解冻请求时不返回错误
+	Restore(ctx context.Context, path string, days int) error
+}
+
+// checkRestored 文件处于归档存储且未解冻时返回 ErrFileArchived
+func (fs *FileSystem) checkRestored(ctx context.Context, file *model.File) error {
+	if file.MetadataSerialized[model.StorageClassMetadataKey] == "" {
+		return nil
+	}
+
+	handler, ok := fs.Handler.(restorer)
+	if !ok {
+		return nil
+	}
+
+	archived, restored, err := handler.RestoreStatus(ctx, file.SourceName)
+	if err != nil {
+		// 无法确定状态时仍尝试签名，由存储端拒绝访问
+		util.Log().Warning("Failed to get restore status of file %q: %s", file.Name, err)
+		return nil
+	}
+
+	if archived && !restored {
+		return ErrFileArchived
+	}
+
+	return nil
+}
+
+// RestoreFile 为归档存储中的文件发起解冻请求，返回文件当前是否已可访问
+func (fs *FileSystem) RestoreFile(ctx context.Context, file *model.File) (bool, error) {
+	fs.FileTarget = []model.File{*file}
+	if err := fs.resetPolicyToFirstFile(ctx); err != nil {
+		return false, err
+	}
+
+	handler, ok := fs.Handler.(restorer)
+	if !ok {
+		return true, nil
+	}
+
+	archived, restored, err := handler.RestoreStatus(ctx, file.SourceName)
+	if err != nil {
+		return false, err
+	}
+
+	if !archived || restored {
+		return true, nil
+	}
+
+	days := fs.Policy.OptionsSerialized.RestoreDays
+	if days <= 0 {
+		days = 1
+	}
+
+	return false, handler.Restore(ctx, file.SourceName, days)
+}
+
+// IsFileRestored 返回文件是否已可访问
+func (fs *FileSystem) IsFileRestored(ctx context.Context, file *model.File) (bool, error) {
+	fs.FileTarget = []model.File{*file}
+	if err := fs.resetPolicyToFirstFile(ctx); err != nil {
+		return false, err
+	}
+
+	handler, ok := fs.Handler.(restorer)
+	if !ok {
+		return true, nil
+	}
+
+	archived, restored, err := handler.RestoreStatus(ctx, file.SourceName)
+	return !archived || restored, err
+}
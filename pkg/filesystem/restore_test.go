@@ -0,0 +1,111 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+// restorerMock 支持归档存储的适配器
+type restorerMock struct {
+	FileHeaderMock
+	archived    bool
+	restored    bool
+	statusErr   error
+	restoreErr  error
+	restoreDays int
+}
+
+func (m *restorerMock) RestoreStatus(ctx context.Context, path string) (bool, bool, error) {
+	return m.archived, m.restored, m.statusErr
+}
+
+func (m *restorerMock) Restore(ctx context.Context, path string, days int) error {
+	m.restoreDays = days
+	return m.restoreErr
+}
+
+func TestFileSystem_CheckRestored(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{}}
+	file := &model.File{Name: "1.txt", SourceName: "1.txt"}
+	handler := &restorerMock{archived: true}
+
+	// 未记录存储类型
+	fs.Handler = handler
+	asserts.NoError(fs.checkRestored(context.Background(), file))
+
+	// 适配器不支持归档存储
+	file.MetadataSerialized = map[string]string{model.StorageClassMetadataKey: "Archive"}
+	fs.Handler = &FileHeaderMock{}
+	asserts.NoError(fs.checkRestored(context.Background(), file))
+
+	// 未解冻
+	fs.Handler = handler
+	asserts.ErrorIs(fs.checkRestored(context.Background(), file), ErrFileArchived)
+
+	// 无法获取状态
+	handler.statusErr = errors.New("error")
+	asserts.NoError(fs.checkRestored(context.Background(), file))
+
+	// 已解冻
+	handler.statusErr = nil
+	handler.restored = true
+	asserts.NoError(fs.checkRestored(context.Background(), file))
+}
+
+func TestFileSystem_RestoreFile(t *testing.T) {
+	asserts := assert.New(t)
+	cache.Set("policy_31", model.Policy{Model: gorm.Model{ID: 31}, Type: "mock"}, 0)
+	file := &model.File{Name: "1.txt", SourceName: "1.txt", PolicyID: 31}
+
+	// 适配器不支持归档存储
+	{
+		fs := &FileSystem{User: &model.User{}}
+		restored, err := fs.RestoreFile(context.Background(), file)
+		asserts.NoError(err)
+		asserts.True(restored)
+	}
+
+	// 未归档
+	{
+		handler := &restorerMock{}
+		fs := &FileSystem{User: &model.User{}, Handler: handler}
+		restored, err := fs.RestoreFile(context.Background(), file)
+		asserts.NoError(err)
+		asserts.True(restored)
+		asserts.Zero(handler.restoreDays)
+	}
+
+	// 发起解冻
+	{
+		handler := &restorerMock{archived: true}
+		fs := &FileSystem{User: &model.User{}, Handler: handler}
+		restored, err := fs.RestoreFile(context.Background(), file)
+		asserts.NoError(err)
+		asserts.False(restored)
+		asserts.Equal(1, handler.restoreDays)
+
+		restored, err = fs.IsFileRestored(context.Background(), file)
+		asserts.NoError(err)
+		asserts.False(restored)
+
+		handler.restored = true
+		restored, err = fs.IsFileRestored(context.Background(), file)
+		asserts.NoError(err)
+		asserts.True(restored)
+	}
+
+	// 解冻失败
+	{
+		handler := &restorerMock{archived: true, restoreErr: errors.New("error")}
+		fs := &FileSystem{User: &model.User{}, Handler: handler}
+		_, err := fs.RestoreFile(context.Background(), file)
+		asserts.Error(err)
+	}
+}
@@ -0,0 +1,36 @@
+package filesystem
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+)
+
+// RevisionHeader 编辑器读取及保存文本文件时携带文件修订号的请求头/响应头
+const RevisionHeader = "X-Cr-File-Revision"
+
+// revisionLocks 按文件 ID 加锁，保证同一节点上修订号的校验与内容写入不会交错
+var revisionLocks sync.Map
+
+// FileRevision 返回文件当前的修订号，文件内容被更新后修订号随之改变
+func FileRevision(file *model.File) string {
+	return fmt.Sprintf("%x%x", file.UpdatedAt.UnixNano(), file.Size)
+}
+
+// MatchRevision 返回 expected 是否与文件当前的修订号或内容 SHA-256 相符
+func MatchRevision(file *model.File, expected string) bool {
+	if expected == FileRevision(file) {
+		return true
+	}
+
+	return file.Hash != "" && strings.EqualFold(expected, file.Hash)
+}
+
+// LockRevision 锁定文件的修订号，返回用于解锁的函数
+func LockRevision(fileID uint) func() {
+	lock, _ := revisionLocks.LoadOrStore(fileID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	return lock.(*sync.Mutex).Unlock
+}
@@ -0,0 +1,58 @@
+package filesystem
+
+import (
+	"testing"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileRevision(t *testing.T) {
+	a := assert.New(t)
+	file := &model.File{Model: gorm.Model{UpdatedAt: time.Unix(10, 0)}, Size: 5}
+	revision := FileRevision(file)
+
+	// 修订号相符
+	a.True(MatchRevision(file, revision))
+
+	// 内容更新后修订号改变
+	{
+		updated := *file
+		updated.UpdatedAt = time.Unix(11, 0)
+		a.NotEqual(revision, FileRevision(&updated))
+		a.False(MatchRevision(&updated, revision))
+	}
+
+	// 按内容哈希匹配
+	{
+		a.False(MatchRevision(file, ""))
+		file.Hash = "abcdef"
+		a.True(MatchRevision(file, "ABCDEF"))
+		a.False(MatchRevision(file, "123456"))
+	}
+}
+
+func TestLockRevision(t *testing.T) {
+	a := assert.New(t)
+	unlock := LockRevision(1)
+
+	locked := make(chan struct{})
+	go func() {
+		defer LockRevision(1)()
+		close(locked)
+	}()
+
+	// 其他文件不受影响
+	LockRevision(2)()
+
+	select {
+	case <-locked:
+		a.Fail("revision lock should block")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	unlock()
+	<-locked
+}
@@ -0,0 +1,75 @@
+package filesystem
+
+import (
+	"path/filepath"
+	"sync"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+/* ================
+	 本机存储分片
+   ================
+*/
+
+const (
+	// ShardMostFree 选择剩余空间最多的存储根目录
+	ShardMostFree = "most_free"
+	// ShardRoundRobin 轮流使用各存储根目录
+	ShardRoundRobin = "round_robin"
+)
+
+var (
+	// shardCursors 存储策略 ID 到下一个轮流使用的存储根目录序号的映射
+	shardCursors     = make(map[uint]int)
+	shardCursorsLock sync.Mutex
+)
+
+// diskFreeSpace 获取存储根目录所在磁盘的剩余空间，便于测试时替换
+var diskFreeSpace = func(root string) (uint64, error) {
+	return util.DiskFreeSpace(util.RelativePath(filepath.FromSlash(root)))
+}
+
+// pickShard 为大小为 size 的文件选择本机策略的存储根目录，未设定多个根目录时返回空字符串。
+// 剩余空间不足的根目录会被跳过，所有根目录均不足时返回第一个根目录
+func pickShard(policy *model.Policy, size uint64) string {
+	shards := policy.OptionsSerialized.Shards
+	if policy.Type != "local" || len(shards) == 0 {
+		return ""
+	}
+
+	if policy.OptionsSerialized.ShardStrategy == ShardRoundRobin {
+		shardCursorsLock.Lock()
+		start := shardCursors[policy.ID]
+		shardCursors[policy.ID] = (start + 1) % len(shards)
+		shardCursorsLock.Unlock()
+
+		for i := range shards {
+			shard := shards[(start+i)%len(shards)]
+			if free, err := diskFreeSpace(shard); err == nil && free >= size {
+				return shard
+			}
+		}
+
+		return shards[0]
+	}
+
+	var (
+		best     = shards[0]
+		bestFree uint64
+	)
+	for _, shard := range shards {
+		free, err := diskFreeSpace(shard)
+		if err != nil {
+			util.Log().Debug("Failed to get free space of storage root %q: %s", shard, err)
+			continue
+		}
+
+		if free > bestFree {
+			best, bestFree = shard, free
+		}
+	}
+
+	return best
+}
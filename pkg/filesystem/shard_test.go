@@ -0,0 +1,86 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockDiskFreeSpace(t *testing.T, free map[string]uint64) {
+	origin := diskFreeSpace
+	diskFreeSpace = func(root string) (uint64, error) {
+		if size, ok := free[root]; ok {
+			return size, nil
+		}
+
+		return 0, errors.New("not exist")
+	}
+	t.Cleanup(func() { diskFreeSpace = origin })
+}
+
+func TestPickShard(t *testing.T) {
+	asserts := assert.New(t)
+	mockDiskFreeSpace(t, map[string]uint64{
+		"/mnt/disk1": 100,
+		"/mnt/disk2": 300,
+		"/mnt/disk3": 200,
+	})
+
+	// 未设定多个根目录
+	{
+		asserts.Empty(pickShard(&model.Policy{Type: "local"}, 10))
+		asserts.Empty(pickShard(&model.Policy{Type: "s3", OptionsSerialized: model.PolicyOption{Shards: []string{"/mnt/disk1"}}}, 10))
+	}
+
+	// 剩余空间最多
+	{
+		policy := &model.Policy{Type: "local", OptionsSerialized: model.PolicyOption{
+			Shards: []string{"/mnt/disk1", "/mnt/broken", "/mnt/disk2", "/mnt/disk3"},
+		}}
+		asserts.Equal("/mnt/disk2", pickShard(policy, 10))
+		asserts.Equal("/mnt/disk2", pickShard(policy, 10))
+	}
+
+	// 所有根目录均不可用
+	{
+		policy := &model.Policy{Type: "local", OptionsSerialized: model.PolicyOption{
+			Shards: []string{"/mnt/broken", "/mnt/broken2"},
+		}}
+		asserts.Equal("/mnt/broken", pickShard(policy, 10))
+	}
+
+	// 轮流使用，跳过空间不足的根目录
+	{
+		policy := &model.Policy{Model: gorm.Model{ID: 42}, Type: "local", OptionsSerialized: model.PolicyOption{
+			Shards:        []string{"/mnt/disk1", "/mnt/disk2", "/mnt/disk3"},
+			ShardStrategy: ShardRoundRobin,
+		}}
+		asserts.Equal("/mnt/disk1", pickShard(policy, 10))
+		asserts.Equal("/mnt/disk2", pickShard(policy, 10))
+		asserts.Equal("/mnt/disk3", pickShard(policy, 10))
+		asserts.Equal("/mnt/disk2", pickShard(policy, 150))
+		asserts.Equal("/mnt/disk2", pickShard(policy, 250))
+		asserts.Equal("/mnt/disk1", pickShard(policy, 1000))
+	}
+}
+
+func TestFileSystem_GenerateSavePath_Shard(t *testing.T) {
+	asserts := assert.New(t)
+	mockDiskFreeSpace(t, map[string]uint64{"/mnt/disk1": 100, "/mnt/disk2": 300})
+	fs := FileSystem{
+		User: &model.User{Model: gorm.Model{ID: 1}},
+		Policy: &model.Policy{
+			Type:              "local",
+			DirNameRule:       "uploads/{path}",
+			FileNameRule:      "{originname}",
+			OptionsSerialized: model.PolicyOption{Shards: []string{"/mnt/disk1", "/mnt/disk2"}},
+		},
+	}
+	file := &fsctx.FileStream{Name: "1.txt", VirtualPath: "/sub"}
+	asserts.Equal("/mnt/disk2/uploads/sub/1.txt", fs.GenerateSavePath(context.Background(), file))
+}
@@ -0,0 +1,112 @@
+package filesystem
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/juju/ratelimit"
+)
+
+// speedBucketIdleTimeout 令牌桶闲置超过此时长后被清理
+const speedBucketIdleTimeout = 10 * time.Minute
+
+var (
+	// uploadBuckets 用户 ID 到上传令牌桶的映射
+	uploadBuckets = newSpeedBuckets()
+	// downloadBuckets 用户 ID 到下载令牌桶的映射
+	downloadBuckets = newSpeedBuckets()
+)
+
+// speedBucket 用户共享的限速令牌桶
+type speedBucket struct {
+	speed  int
+	bucket *ratelimit.Bucket
+	// lastUsed 最近一次使用的 Unix 时间戳
+	lastUsed int64
+}
+
+func newSpeedBucket(speed int) *speedBucket {
+	return &speedBucket{
+		speed:    speed,
+		bucket:   ratelimit.NewBucketWithRate(float64(speed), int64(speed)),
+		lastUsed: time.Now().Unix(),
+	}
+}
+
+// wait 记录使用时间并等待 n 字节对应的令牌
+func (b *speedBucket) wait(n int) {
+	atomic.StoreInt64(&b.lastUsed, time.Now().Unix())
+	if n > 0 {
+		b.bucket.Wait(int64(n))
+	}
+}
+
+// speedBuckets 按用户 ID 管理的令牌桶，闲置的令牌桶会被定期清理
+type speedBuckets struct {
+	lock      sync.Mutex
+	buckets   map[uint]*speedBucket
+	lastSweep time.Time
+}
+
+func newSpeedBuckets() *speedBuckets {
+	return &speedBuckets{buckets: make(map[uint]*speedBucket)}
+}
+
+// get 获取用户的令牌桶，限速设置变化时重新创建
+func (s *speedBuckets) get(uid uint, speed int) *speedBucket {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) >= speedBucketIdleTimeout {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[uid]
+	if !ok || b.speed != speed {
+		b = newSpeedBucket(speed)
+		s.buckets[uid] = b
+	}
+
+	atomic.StoreInt64(&b.lastUsed, now.Unix())
+	return b
+}
+
+// sweep 清理闲置的令牌桶
+func (s *speedBuckets) sweep(now time.Time) {
+	deadline := now.Add(-speedBucketIdleTimeout).Unix()
+	for uid, b := range s.buckets {
+		if atomic.LoadInt64(&b.lastUsed) < deadline {
+			delete(s.buckets, uid)
+		}
+	}
+
+	s.lastSweep = now
+}
+
+// throttledReader 限速后的ReadCloser
+type throttledReader struct {
+	io.ReadCloser
+	bucket *speedBucket
+	replay replayCounter
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.bucket.wait(r.replay.fresh(n))
+	return n, err
+}
+
+// throttledWriter 限速后的响应Writer
+type throttledWriter struct {
+	http.ResponseWriter
+	bucket *speedBucket
+}
+
+func (w throttledWriter) Write(p []byte) (int, error) {
+	w.bucket.wait(len(p))
+	return w.ResponseWriter.Write(p)
+}
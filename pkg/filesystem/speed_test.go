@@ -0,0 +1,52 @@
+package filesystem
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpeedBuckets_Get(t *testing.T) {
+	a := assert.New(t)
+	buckets := newSpeedBuckets()
+
+	// 同一用户共享令牌桶
+	bucket := buckets.get(10, 1024)
+	a.Same(bucket, buckets.get(10, 1024))
+	a.NotSame(bucket, buckets.get(11, 1024))
+
+	// 限速变化后重新创建
+	newBucket := buckets.get(10, 2048)
+	a.NotSame(bucket, newBucket)
+	a.Same(newBucket, buckets.get(10, 2048))
+}
+
+func TestSpeedBuckets_Sweep(t *testing.T) {
+	a := assert.New(t)
+	buckets := newSpeedBuckets()
+
+	idle := buckets.get(10, 1024)
+	active := buckets.get(11, 1024)
+	idle.lastUsed = time.Now().Add(-2 * speedBucketIdleTimeout).Unix()
+
+	// 清理间隔未到时保留
+	buckets.get(12, 1024)
+	a.Len(buckets.buckets, 3)
+
+	// 清理闲置的令牌桶
+	buckets.lastSweep = time.Now().Add(-speedBucketIdleTimeout)
+	buckets.get(12, 1024)
+	a.Len(buckets.buckets, 2)
+	a.Same(active, buckets.get(11, 1024))
+	a.NotSame(idle, buckets.get(10, 1024))
+}
+
+func TestSpeedBucket_Wait(t *testing.T) {
+	a := assert.New(t)
+	bucket := newSpeedBucket(1024)
+	bucket.lastUsed = 0
+	bucket.wait(10)
+	a.NotZero(bucket.lastUsed)
+	a.EqualValues(1014, bucket.bucket.Available())
+}
@@ -0,0 +1,76 @@
+package filesystem
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+)
+
+// MaxTemplateFolders 目录模板中最多包含的目录数
+const MaxTemplateFolders = 1000
+
+// NormalizeTemplateFolders 校验模板中的目录路径，返回去除重复项并统一格式后的相对路径
+func (fs *FileSystem) NormalizeTemplateFolders(ctx context.Context, folders []string) ([]string, error) {
+	if len(folders) > MaxTemplateFolders {
+		return nil, ErrTemplateTooLarge
+	}
+
+	res := make([]string, 0, len(folders))
+	seen := make(map[string]bool, len(folders))
+	for _, folder := range folders {
+		// 消除路径中的 "." 和 ".."，结果不会超出新建的目录
+		folder = strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(folder, "\\", "/")), "/")
+		if folder == "" {
+			return nil, ErrIllegalObjectName
+		}
+
+		for _, name := range strings.Split(folder, "/") {
+			if !fs.ValidateLegalName(ctx, name) {
+				return nil, ErrIllegalObjectName
+			}
+		}
+
+		if !seen[folder] {
+			seen[folder] = true
+			res = append(res, folder)
+		}
+	}
+
+	return res, nil
+}
+
+// InstantiateTemplate 在 dst 目录下创建名为 name 的目录，并在同一事务中创建模板中的全部子目录
+func (fs *FileSystem) InstantiateTemplate(ctx context.Context, template *model.FolderTemplate, dst, name string) (*model.Folder, error) {
+	if !fs.ValidateLegalName(ctx, name) {
+		return nil, ErrIllegalObjectName
+	}
+
+	folders, err := fs.NormalizeTemplateFolders(ctx, template.FolderList)
+	if err != nil {
+		return nil, err
+	}
+
+	isExist, parent := fs.IsPathExist(dst)
+	if !isExist {
+		return nil, ErrPathNotExist
+	}
+
+	if err := fs.CheckWritable(parent); err != nil {
+		return nil, err
+	}
+
+	// 是否有同名文件
+	if ok, _ := fs.IsChildFileExist(parent, name); ok {
+		return nil, ErrFileExisted
+	}
+
+	folder, err := parent.CreateTree(name, folders)
+	if err != nil {
+		return nil, ErrFileExisted.WithError(err)
+	}
+
+	RecordChanges(model.NewFolderChange(model.ChangeCreated, folder, path.Join(dst, name)))
+	return folder, nil
+}
@@ -0,0 +1,98 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_NormalizeTemplateFolders(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{}}
+	ctx := context.Background()
+
+	// 统一格式并去除重复项
+	folders, err := fs.NormalizeTemplateFolders(ctx, []string{"Docs", "/Design/", "Design\\Assets", "Docs/../Docs", "../Deliverables"})
+	a.NoError(err)
+	a.Equal([]string{"Docs", "Design", "Design/Assets", "Deliverables"}, folders)
+
+	// 空路径
+	_, err = fs.NormalizeTemplateFolders(ctx, []string{"Docs", ".."})
+	a.Equal(ErrIllegalObjectName, err)
+
+	// 非法名称
+	_, err = fs.NormalizeTemplateFolders(ctx, []string{"Docs/a:b"})
+	a.Equal(ErrIllegalObjectName, err)
+
+	// 目录过多
+	_, err = fs.NormalizeTemplateFolders(ctx, make([]string, MaxTemplateFolders+1))
+	a.Equal(ErrTemplateTooLarge, err)
+}
+
+func TestFileSystem_InstantiateTemplate(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	ctx := context.Background()
+	template := &model.FolderTemplate{FolderList: []string{"Docs", "Design/Assets"}}
+
+	// 非法名称
+	{
+		_, err := fs.InstantiateTemplate(ctx, template, "/", "a:b")
+		a.Equal(ErrIllegalObjectName, err)
+	}
+
+	// 目录不存在
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		_, err := fs.InstantiateTemplate(ctx, template, "/", "Project")
+		a.Equal(ErrPathNotExist, err)
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 存在同名文件
+	{
+		mock.ExpectQuery("SELECT(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Project"))
+		_, err := fs.InstantiateTemplate(ctx, template, "/", "Project")
+		a.Equal(ErrFileExisted, err)
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 创建失败
+	{
+		mock.ExpectQuery("SELECT(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)folders(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		_, err := fs.InstantiateTemplate(ctx, template, "/", "Project")
+		a.Error(err)
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectExec("INSERT(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(3, 1))
+		mock.ExpectExec("INSERT(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(4, 1))
+		mock.ExpectExec("INSERT(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.ExpectCommit()
+		folder, err := fs.InstantiateTemplate(ctx, template, "/", "Project")
+		a.NoError(err)
+		a.EqualValues(2, folder.ID)
+		a.Equal("Project", folder.Name)
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
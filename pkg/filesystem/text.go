@@ -0,0 +1,222 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+/* ================
+     文本预览相关
+   ================
+*/
+
+const (
+	CharsetUTF8    = "utf-8"
+	CharsetUTF16LE = "utf-16le"
+	CharsetUTF16BE = "utf-16be"
+	CharsetGB18030 = "gb18030"
+
+	// charsetSniffSize 检测 UTF-16 编码时采样的长度
+	charsetSniffSize = 1024
+)
+
+// TextPreview 文本文件开头部分的预览内容
+type TextPreview struct {
+	Content   string `json:"content"`
+	Charset   string `json:"charset"`
+	Language  string `json:"language"`
+	Size      uint64 `json:"size"`
+	Truncated bool   `json:"truncated"`
+}
+
+// textLanguages 扩展名对应的语法高亮类型
+var textLanguages = map[string]string{
+	"md": "markdown", "markdown": "markdown",
+	"go": "go", "py": "python", "rb": "ruby", "php": "php", "java": "java", "kt": "kotlin",
+	"js": "javascript", "mjs": "javascript", "jsx": "javascript", "ts": "typescript", "tsx": "typescript",
+	"c": "c", "h": "c", "cpp": "cpp", "cc": "cpp", "hpp": "cpp", "cs": "csharp", "rs": "rust",
+	"swift": "swift", "lua": "lua", "sh": "shell", "bash": "shell", "ps1": "powershell", "bat": "bat",
+	"html": "html", "htm": "html", "css": "css", "scss": "scss", "less": "less", "vue": "html",
+	"json": "json", "xml": "xml", "yaml": "yaml", "yml": "yaml", "toml": "ini", "ini": "ini", "conf": "ini",
+	"sql": "sql", "log": "log", "csv": "csv", "txt": "plaintext",
+}
+
+// textFileLanguages 无扩展名的常见文件对应的语法高亮类型
+var textFileLanguages = map[string]string{
+	"dockerfile": "dockerfile",
+	"makefile":   "makefile",
+}
+
+// TextLanguage 根据文件名返回语法高亮类型提示，未知类型返回 plaintext
+func TextLanguage(name string) string {
+	name = strings.ToLower(path.Base(name))
+	if lang, ok := textFileLanguages[name]; ok {
+		return lang
+	}
+
+	if lang, ok := textLanguages[strings.TrimPrefix(path.Ext(name), ".")]; ok {
+		return lang
+	}
+
+	return "plaintext"
+}
+
+// PreviewTextHead 读取文本文件开头最多 limit 字节，检测编码并转换为 UTF-8，
+// 用于预览大文件而无需下载全部内容
+func (fs *FileSystem) PreviewTextHead(ctx context.Context, id uint, limit int) (*TextPreview, error) {
+	maxSize := model.GetIntSetting("text_preview_max_size", 1<<20)
+	if limit <= 0 || limit > maxSize {
+		limit = maxSize
+	}
+
+	err := fs.resetFileIDIfNotExist(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	file := fs.FileTarget[0]
+	rs, err := fs.Handler.Get(context.WithValue(ctx, fsctx.FileModelCtx, file), file.SourceName)
+	if err != nil {
+		return nil, ErrIO.WithError(err)
+	}
+	defer rs.Close()
+
+	head, err := io.ReadAll(io.LimitReader(rs, int64(limit)))
+	if err != nil {
+		return nil, ErrIO.WithError(err)
+	}
+
+	truncated := uint64(len(head)) < file.Size
+	content, charset, err := decodeText(head, truncated)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TextPreview{
+		Content:   content,
+		Charset:   charset,
+		Language:  TextLanguage(file.Name),
+		Size:      file.Size,
+		Truncated: truncated,
+	}, nil
+}
+
+// decodeText 检测文本编码并转换为 UTF-8，truncated 表示内容是否被截断，
+// 截断时丢弃末尾不完整的字符
+func decodeText(b []byte, truncated bool) (string, string, error) {
+	switch {
+	case bytes.HasPrefix(b, []byte{0xEF, 0xBB, 0xBF}):
+		return decodeUTF8(b[3:], truncated)
+	case bytes.HasPrefix(b, []byte{0xFF, 0xFE}):
+		return decodeWith(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), b[2:], truncated, CharsetUTF16LE)
+	case bytes.HasPrefix(b, []byte{0xFE, 0xFF}):
+		return decodeWith(unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), b[2:], truncated, CharsetUTF16BE)
+	}
+
+	if charset := sniffUTF16(b); charset == CharsetUTF16LE {
+		return decodeWith(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), b, truncated, charset)
+	} else if charset == CharsetUTF16BE {
+		return decodeWith(unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), b, truncated, charset)
+	}
+
+	if bytes.IndexByte(b, 0) >= 0 {
+		return "", "", ErrNotTextFile
+	}
+
+	if content, charset, err := decodeUTF8(b, truncated); err == nil {
+		return content, charset, nil
+	}
+
+	return decodeWith(simplifiedchinese.GB18030, b, truncated, CharsetGB18030)
+}
+
+// decodeUTF8 校验 UTF-8 文本，截断时忽略末尾不完整的字符
+func decodeUTF8(b []byte, truncated bool) (string, string, error) {
+	if truncated {
+		b = trimIncompleteRune(b)
+	}
+
+	if !utf8.Valid(b) {
+		return "", "", ErrNotTextFile
+	}
+
+	return string(b), CharsetUTF8, nil
+}
+
+// decodeWith 使用给定编码解码文本
+func decodeWith(e encoding.Encoding, b []byte, truncated bool, charset string) (string, string, error) {
+	if truncated && (charset == CharsetUTF16LE || charset == CharsetUTF16BE) && len(b)%2 == 1 {
+		b = b[:len(b)-1]
+	}
+
+	res, err := e.NewDecoder().Bytes(b)
+	if err != nil {
+		return "", "", ErrNotTextFile.WithError(err)
+	}
+
+	content := string(res)
+	if truncated {
+		// 截断的多字节字符被解码为替换字符，UTF-16 截断的代理对同理
+		content = strings.TrimSuffix(content, string(utf8.RuneError))
+	}
+
+	return content, charset, nil
+}
+
+// sniffUTF16 根据零字节的分布判断无 BOM 的 UTF-16 文本，
+// 以 ASCII 字符为主的 UTF-16 文本中，每个字符的高位字节为零
+func sniffUTF16(b []byte) string {
+	if len(b) > charsetSniffSize {
+		b = b[:charsetSniffSize]
+	}
+
+	if len(b) < 4 {
+		return ""
+	}
+
+	var even, odd int
+	for i, c := range b {
+		if c != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+
+	half := len(b) / 2
+	switch {
+	case odd > half*3/10 && even == 0:
+		return CharsetUTF16LE
+	case even > half*3/10 && odd == 0:
+		return CharsetUTF16BE
+	}
+
+	return ""
+}
+
+// trimIncompleteRune 移除末尾被截断的 UTF-8 字符
+func trimIncompleteRune(b []byte) []byte {
+	for i := 1; i <= utf8.UTFMax && i <= len(b); i++ {
+		if utf8.RuneStart(b[len(b)-i]) {
+			if !utf8.FullRune(b[len(b)-i:]) {
+				return b[:len(b)-i]
+			}
+			break
+		}
+	}
+
+	return b
+}
@@ -0,0 +1,135 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestTextLanguage(t *testing.T) {
+	a := assert.New(t)
+	a.Equal("markdown", TextLanguage("README.MD"))
+	a.Equal("go", TextLanguage("/src/main.go"))
+	a.Equal("dockerfile", TextLanguage("Dockerfile"))
+	a.Equal("log", TextLanguage("server.log"))
+	a.Equal("plaintext", TextLanguage("unknown.xyz"))
+}
+
+func TestDecodeText(t *testing.T) {
+	a := assert.New(t)
+	text := "中文内容 text"
+
+	// UTF-8，截断的字符被丢弃
+	{
+		res, charset, err := decodeText([]byte(text)[:3+2], true)
+		a.NoError(err)
+		a.Equal(CharsetUTF8, charset)
+		a.Equal("中", res)
+
+		res, charset, err = decodeText(append([]byte{0xEF, 0xBB, 0xBF}, text...), false)
+		a.NoError(err)
+		a.Equal(CharsetUTF8, charset)
+		a.Equal(text, res)
+	}
+
+	// GBK
+	{
+		gbk, _ := simplifiedchinese.GBK.NewEncoder().String(text)
+		res, charset, err := decodeText([]byte(gbk), false)
+		a.NoError(err)
+		a.Equal(CharsetGB18030, charset)
+		a.Equal(text, res)
+
+		res, _, err = decodeText([]byte(gbk)[:3], true)
+		a.NoError(err)
+		a.Equal("中", res)
+	}
+
+	// UTF-16，带 BOM
+	{
+		utf16, _ := unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewEncoder().String(text)
+		res, charset, err := decodeText([]byte(utf16), false)
+		a.NoError(err)
+		a.Equal(CharsetUTF16BE, charset)
+		a.Equal(text, res)
+
+		res, _, err = decodeText([]byte(utf16)[:5], true)
+		a.NoError(err)
+		a.Equal("中", res)
+	}
+
+	// UTF-16，无 BOM
+	{
+		utf16, _ := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder().String("plain ascii log line")
+		res, charset, err := decodeText([]byte(utf16), false)
+		a.NoError(err)
+		a.Equal(CharsetUTF16LE, charset)
+		a.Equal("plain ascii log line", res)
+	}
+
+	// 二进制文件
+	{
+		_, _, err := decodeText([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), false)
+		a.ErrorIs(err, ErrNotTextFile)
+	}
+}
+
+func TestFileSystem_PreviewTextHead(t *testing.T) {
+	a := assert.New(t)
+	cache.Set("setting_text_preview_max_size", "8", 0)
+
+	newFs := func(handler *FileHeaderMock) *FileSystem {
+		fs := &FileSystem{User: &model.User{}, Handler: handler}
+		fs.SetTargetFile(&[]model.File{{
+			Name:       "app.log",
+			SourceName: "app.log",
+			Size:       12,
+			Policy:     model.Policy{Type: "mock"},
+		}})
+		fs.FileTarget[0].Policy.ID = 1
+		return fs
+	}
+
+	// 无法读取文件
+	{
+		mockHandler := &FileHeaderMock{}
+		mockHandler.On("Get", testMock.Anything, "app.log").Return(MockRSC{}, errors.New("error"))
+		res, err := newFs(mockHandler).PreviewTextHead(context.Background(), 1, 0)
+		a.Error(err)
+		a.Nil(res)
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 请求长度超过上限时按上限截断
+	{
+		mockHandler := &FileHeaderMock{}
+		mockHandler.On("Get", testMock.Anything, "app.log").Return(MockRSC{rs: strings.NewReader("line1\nline2\n")}, nil)
+		res, err := newFs(mockHandler).PreviewTextHead(context.Background(), 1, 1024)
+		a.NoError(err)
+		mockHandler.AssertExpectations(t)
+		a.Equal("line1\nli", res.Content)
+		a.Equal(CharsetUTF8, res.Charset)
+		a.Equal("log", res.Language)
+		a.EqualValues(12, res.Size)
+		a.True(res.Truncated)
+	}
+
+	// 完整读取
+	{
+		cache.Set("setting_text_preview_max_size", "1024", 0)
+		mockHandler := &FileHeaderMock{}
+		mockHandler.On("Get", testMock.Anything, "app.log").Return(MockRSC{rs: strings.NewReader("line1\nline2\n")}, nil)
+		res, err := newFs(mockHandler).PreviewTextHead(context.Background(), 1, 0)
+		a.NoError(err)
+		a.Equal("line1\nline2\n", res.Content)
+		a.False(res.Truncated)
+	}
+}
@@ -0,0 +1,87 @@
+package filesystem
+
+import (
+	"io"
+	"net/http"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/gin-gonic/gin"
+)
+
+/* ================
+     流量统计相关
+   ================
+*/
+
+// trafficReader 统计上传时从客户端读取的字节数
+type trafficReader struct {
+	io.ReadCloser
+	uid      uint
+	policyID uint
+	replay   replayCounter
+}
+
+func (r *trafficReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if fresh := r.replay.fresh(n); fresh > 0 {
+		model.AddTraffic(r.uid, r.policyID, uint64(fresh), 0)
+	}
+	return n, err
+}
+
+// trafficWriter 统计下载时写出到客户端的字节数
+type trafficWriter struct {
+	http.ResponseWriter
+	uid uint
+	fs  *FileSystem
+}
+
+func (w trafficWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.record(n)
+	return n, err
+}
+
+// record 将流量计入当前文件所在的存储策略，打包下载时存储策略会随文件切换
+func (w trafficWriter) record(n int) {
+	if policy := w.fs.Policy; policy != nil {
+		model.AddTraffic(w.uid, policy.ID, 0, uint64(n))
+	}
+}
+
+// shouldMeterTraffic 返回是否需要统计流量，从机不记录流量
+func (fs *FileSystem) shouldMeterTraffic() bool {
+	return fs.User != nil && conf.SystemConfig.Mode == "master"
+}
+
+// withUploadTraffic 包装上传文件流以统计上传流量，replayed 为重试前已读取过的字节数，不再重复统计
+func (fs *FileSystem) withUploadTraffic(file *fsctx.FileStream, replayed uint64) {
+	if !fs.shouldMeterTraffic() || fs.Policy == nil || file.File == nil {
+		return
+	}
+
+	file.File = &trafficReader{
+		ReadCloser: file.File,
+		uid:        fs.User.ID,
+		policyID:   fs.Policy.ID,
+		replay:     replayCounter{skip: replayed},
+	}
+}
+
+// WithDownloadTraffic 包装 gin 响应以统计下载流量，用于缩略图等不限速的响应
+func (fs *FileSystem) WithDownloadTraffic(c *gin.Context) {
+	if w := fs.withDownloadTraffic(c.Writer); w != c.Writer {
+		c.Writer = ginWriter{c.Writer, w}
+	}
+}
+
+// withDownloadTraffic 包装响应以统计下载流量
+func (fs *FileSystem) withDownloadTraffic(w http.ResponseWriter) http.ResponseWriter {
+	if !fs.shouldMeterTraffic() {
+		return w
+	}
+
+	return trafficWriter{ResponseWriter: w, uid: fs.User.ID, fs: fs}
+}
@@ -0,0 +1,120 @@
+package filesystem
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_withUploadTraffic(t *testing.T) {
+	a := assert.New(t)
+	today := time.Now().Format(model.TrafficDateFormat)
+
+	// 从机不统计
+	{
+		conf.SystemConfig.Mode = "slave"
+		origin := io.NopCloser(strings.NewReader("123"))
+		file := &fsctx.FileStream{File: origin}
+		fs := &FileSystem{User: &model.User{}, Policy: &model.Policy{}}
+		fs.withUploadTraffic(file, 0)
+		a.Equal(origin, file.File)
+		conf.SystemConfig.Mode = "master"
+	}
+
+	// 统计读取的字节数
+	{
+		file := &fsctx.FileStream{File: io.NopCloser(strings.NewReader("12345"))}
+		fs := &FileSystem{User: &model.User{}, Policy: &model.Policy{}}
+		fs.User.ID = 1
+		fs.Policy.ID = 2
+		fs.withUploadTraffic(file, 0)
+		a.IsType(&trafficReader{}, file.File)
+		_, err := io.ReadAll(file.File)
+		a.NoError(err)
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)traffics(.+)").WithArgs(0, 5, 1, 2, today).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		a.NoError(model.FlushTraffic())
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 重试时不重复统计此前已读取的数据
+	{
+		file := &fsctx.FileStream{File: io.NopCloser(strings.NewReader("12345"))}
+		fs := &FileSystem{User: &model.User{}, Policy: &model.Policy{}}
+		fs.User.ID = 1
+		fs.Policy.ID = 2
+		fs.withUploadTraffic(file, 3)
+		_, err := io.ReadAll(file.File)
+		a.NoError(err)
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)traffics(.+)").WithArgs(0, 2, 1, 2, today).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		a.NoError(model.FlushTraffic())
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestFileSystem_withDownloadTraffic(t *testing.T) {
+	a := assert.New(t)
+	today := time.Now().Format(model.TrafficDateFormat)
+
+	rec := httptest.NewRecorder()
+	fs := &FileSystem{User: &model.User{}}
+	fs.User.ID = 1
+	w := fs.withDownloadTraffic(rec)
+
+	// 尚未确定存储策略时不统计
+	_, err := w.Write([]byte("123"))
+	a.NoError(err)
+
+	fs.Policy = &model.Policy{}
+	fs.Policy.ID = 2
+	_, err = w.Write([]byte("4567"))
+	a.NoError(err)
+	a.Equal("1234567", rec.Body.String())
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)traffics(.+)").WithArgs(4, 0, 1, 2, today).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	a.NoError(model.FlushTraffic())
+	a.NoError(mock.ExpectationsWereMet())
+}
+
+func TestFileSystem_WithDownloadTraffic(t *testing.T) {
+	a := assert.New(t)
+	gin.SetMode(gin.TestMode)
+
+	// 未登录用户不统计
+	{
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		origin := c.Writer
+		fs := &FileSystem{}
+		fs.WithDownloadTraffic(c)
+		a.Equal(origin, c.Writer)
+	}
+
+	// 包装 gin 响应
+	{
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		origin := c.Writer
+		fs := &FileSystem{User: &model.User{}}
+		fs.WithDownloadTraffic(c)
+		a.Equal(ginWriter{origin, trafficWriter{ResponseWriter: origin, fs: fs}}, c.Writer)
+		_, err := c.Writer.WriteString("123")
+		a.NoError(err)
+		a.Equal("123", rec.Body.String())
+	}
+}
@@ -0,0 +1,187 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gofrs/uuid"
+)
+
+/* ================
+     在线播放转换相关
+   ================
+*/
+
+const (
+	// TranscodeFormatVideo 视频转换的目标格式
+	TranscodeFormatVideo = "mp4"
+	// TranscodeFormatAudio 音频转换的目标格式
+	TranscodeFormatAudio = "m4a"
+)
+
+// transcodeLocks 按文件 ID 加锁，避免同一文件被并发重复转换
+var transcodeLocks sync.Map
+
+// InlineTranscodeFormat 返回文件在线播放时需转换为的格式，无需转换时返回空
+func InlineTranscodeFormat(name string) string {
+	opts := model.GetSettingByNames("media_transcode_video_exts", "media_transcode_audio_exts")
+	if util.IsInExtensionList(strings.Split(opts["media_transcode_video_exts"], ","), name) {
+		return TranscodeFormatVideo
+	}
+
+	if util.IsInExtensionList(strings.Split(opts["media_transcode_audio_exts"], ","), name) {
+		return TranscodeFormatAudio
+	}
+
+	return ""
+}
+
+// inlineTranscodeArgs 生成转换为 format 格式的 ffmpeg 参数，remux 为 true 时
+// 视频流只转封装不重新编码
+func inlineTranscodeArgs(input, output, format string, remux bool) []string {
+	args := []string{"-y", "-i", input}
+	switch format {
+	case TranscodeFormatVideo:
+		args = append(args, "-map", "0:v:0", "-map", "0:a:0?")
+		if remux {
+			args = append(args, "-c:v", "copy")
+		} else {
+			args = append(args, "-c:v", "libx264", "-preset", "veryfast", "-pix_fmt", "yuv420p")
+		}
+		args = append(args, "-c:a", "aac", "-b:a", "192k")
+	case TranscodeFormatAudio:
+		args = append(args, "-vn", "-c:a", "aac", "-b:a", "256k")
+	}
+
+	// 将索引前置，便于浏览器边下载边播放
+	return append(args, "-movflags", "+faststart", "-f", "mp4", output)
+}
+
+// GetTranscodedContent 获取文件转换为浏览器可播放格式后的内容，首次访问时调用 ffmpeg
+// 转换并缓存到文件所在的存储策略中
+func (fs *FileSystem) GetTranscodedContent(ctx context.Context, id uint) (response.RSCloser, error) {
+	err := fs.resetFileIDIfNotExist(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &fs.FileTarget[0]
+	format := InlineTranscodeFormat(file.Name)
+	if format == "" {
+		return nil, ErrMediaNotTranscodable
+	}
+
+	if file.MetadataSerialized[model.TranscodedMetadataKey] != format {
+		lock, _ := transcodeLocks.LoadOrStore(file.ID, &sync.Mutex{})
+		lock.(*sync.Mutex).Lock()
+		defer lock.(*sync.Mutex).Unlock()
+
+		// 等待锁期间其他请求可能已完成转换
+		files, err := model.GetFilesByIDs([]uint{file.ID}, file.UserID)
+		if err != nil || len(files) == 0 {
+			return nil, ErrObjectNotExist
+		}
+		file.MetadataSerialized = files[0].MetadataSerialized
+
+		if file.MetadataSerialized[model.TranscodedMetadataKey] != format {
+			if err := fs.transcodeInline(ctx, file, format); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return fs.Handler.Get(ctx, file.TranscodedFile(format))
+}
+
+// transcodeInline 调用 ffmpeg 将文件转换为 format 格式并保存。视频优先尝试转封装，
+// 视频编码不受目标容器支持时再重新编码
+func (fs *FileSystem) transcodeInline(ctx context.Context, file *model.File, format string) error {
+	const (
+		ffmpegPath = "media_transcode_ffmpeg_path"
+		timeout    = "media_transcode_timeout"
+		tempPath   = "temp_path"
+		tempDir    = "transcode"
+	)
+	opts := model.GetSettingByNames(ffmpegPath, tempPath)
+
+	workDir := filepath.Join(util.RelativePath(opts[tempPath]), tempDir, uuid.Must(uuid.NewV4()).String())
+	defer os.RemoveAll(workDir)
+
+	input, err := fs.ffmpegInput(ctx, file, workDir)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(workDir, 0744); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	ffmpegCtx, cancel := context.WithTimeout(ctx, time.Duration(model.GetIntSetting(timeout, 600))*time.Second)
+	defer cancel()
+
+	output := filepath.Join(workDir, "output."+format)
+	err = runFFMpeg(ffmpegCtx, opts[ffmpegPath], inlineTranscodeArgs(input, output, format, true))
+	if err != nil && format == TranscodeFormatVideo {
+		util.Log().Debug("Failed to remux %q, fallback to transcoding: %s", file.Name, err)
+		err = runFFMpeg(ffmpegCtx, opts[ffmpegPath], inlineTranscodeArgs(input, output, format, false))
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to transcode %q to %s: %w", file.Name, format, err)
+	}
+
+	f, err := os.Open(output)
+	if err != nil {
+		return fmt.Errorf("failed to open transcoded file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat transcoded file: %w", err)
+	}
+
+	savePath := file.TranscodedFile(format)
+	if err := fs.Handler.Put(ctx, &fsctx.FileStream{
+		Mode:     fsctx.Overwrite,
+		File:     f,
+		Seeker:   f,
+		Size:     uint64(info.Size()),
+		Name:     filepath.Base(savePath),
+		SavePath: savePath,
+	}); err != nil {
+		return fmt.Errorf("failed to save transcoded file: %w", err)
+	}
+
+	if err := file.UpdateMetadata(map[string]string{model.TranscodedMetadataKey: format}); err != nil {
+		_, _ = fs.Handler.Delete(context.Background(), []string{savePath})
+		return err
+	}
+
+	return nil
+}
+
+// runFFMpeg 执行 ffmpeg 命令，失败时记录其错误输出
+func runFFMpeg(ctx context.Context, ffmpeg string, args []string) error {
+	cmd := exec.CommandContext(ctx, ffmpeg, args...)
+
+	var stdErr bytes.Buffer
+	cmd.Stderr = &stdErr
+	if err := cmd.Run(); err != nil {
+		util.Log().Warning("Failed to invoke ffmpeg: %s", stdErr.String())
+		return err
+	}
+
+	return nil
+}
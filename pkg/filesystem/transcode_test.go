@@ -0,0 +1,123 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+)
+
+func TestInlineTranscodeFormat(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(cache.Set("setting_media_transcode_video_exts", "mkv,avi", 0))
+	a.NoError(cache.Set("setting_media_transcode_audio_exts", "flac", 0))
+
+	a.Equal(TranscodeFormatVideo, InlineTranscodeFormat("movie.MKV"))
+	a.Equal(TranscodeFormatAudio, InlineTranscodeFormat("song.flac"))
+	a.Equal("", InlineTranscodeFormat("movie.mp4"))
+}
+
+func TestInlineTranscodeArgs(t *testing.T) {
+	a := assert.New(t)
+
+	args := strings.Join(inlineTranscodeArgs("in.mkv", "out.mp4", TranscodeFormatVideo, true), " ")
+	a.Contains(args, "-c:v copy")
+	a.True(strings.HasSuffix(args, "-f mp4 out.mp4"))
+
+	args = strings.Join(inlineTranscodeArgs("in.mkv", "out.mp4", TranscodeFormatVideo, false), " ")
+	a.Contains(args, "-c:v libx264")
+
+	args = strings.Join(inlineTranscodeArgs("in.flac", "out.m4a", TranscodeFormatAudio, true), " ")
+	a.Contains(args, "-vn -c:a aac")
+}
+
+func TestFileSystem_GetTranscodedContent(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg requires a POSIX shell")
+	}
+
+	a := assert.New(t)
+	a.NoError(cache.Set("setting_media_transcode_video_exts", "mkv", 0))
+	a.NoError(cache.Set("setting_media_transcode_audio_exts", "flac", 0))
+	a.NoError(cache.Set("setting_media_transcode_file_suffix", "._transcoded", 0))
+	a.NoError(cache.Set("setting_media_transcode_timeout", "60", 0))
+	a.NoError(cache.Set("setting_temp_path", t.TempDir(), 0))
+
+	// 模拟 ffmpeg，转封装失败，重新编码时在最后一个参数指定的位置写入文件
+	ffmpeg := filepath.Join(t.TempDir(), "ffmpeg")
+	a.NoError(os.WriteFile(ffmpeg, []byte("#!/bin/sh\ncase \"$*\" in *\"-c:v copy\"*) exit 1;; esac\nfor last; do :; done\necho 'mp4' > \"$last\"\n"), 0755))
+	a.NoError(cache.Set("setting_media_transcode_ffmpeg_path", ffmpeg, 0))
+
+	newFs := func(handler *FileHeaderMock, name string, metadata map[string]string) *FileSystem {
+		fs := &FileSystem{User: &model.User{}, Handler: handler}
+		fs.SetTargetFile(&[]model.File{{
+			Name:               name,
+			SourceName:         name,
+			MetadataSerialized: metadata,
+			Policy:             model.Policy{Type: "mock"},
+		}})
+		fs.FileTarget[0].ID = 1
+		fs.FileTarget[0].Policy.ID = 1
+		return fs
+	}
+
+	// 无需转换的格式
+	{
+		fs := newFs(&FileHeaderMock{}, "video.mp4", map[string]string{})
+		res, err := fs.GetTranscodedContent(context.Background(), 1)
+		a.ErrorIs(err, ErrMediaNotTranscodable)
+		a.Nil(res)
+	}
+
+	// 已有转换结果
+	{
+		mockHandler := &FileHeaderMock{}
+		mockHandler.On("Get", testMock.Anything, "video.mkv._transcoded.mp4").Return(MockRSC{}, nil)
+		fs := newFs(mockHandler, "video.mkv", map[string]string{model.TranscodedMetadataKey: "mp4"})
+		_, err := fs.GetTranscodedContent(context.Background(), 1)
+		a.NoError(err)
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 获取原文件失败
+	{
+		mockHandler := &FileHeaderMock{}
+		mockHandler.On("Get", testMock.Anything, "video.mkv").Return(MockRSC{}, errors.New("error"))
+		fs := newFs(mockHandler, "video.mkv", map[string]string{})
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "metadata"}).AddRow(1, "{}"))
+		_, err := fs.GetTranscodedContent(context.Background(), 1)
+		a.Error(err)
+		a.NoError(mock.ExpectationsWereMet())
+		mockHandler.AssertExpectations(t)
+	}
+
+	// 转封装失败后重新编码成功
+	{
+		mockHandler := &FileHeaderMock{}
+		mockHandler.On("Get", testMock.Anything, "video.mkv").Return(MockRSC{rs: strings.NewReader("video")}, nil)
+		mockHandler.On("Put", testMock.Anything, testMock.MatchedBy(func(file fsctx.FileHeader) bool {
+			return file.Info().SavePath == "video.mkv._transcoded.mp4"
+		})).Return(nil)
+		mockHandler.On("Get", testMock.Anything, "video.mkv._transcoded.mp4").Return(MockRSC{}, nil)
+		fs := newFs(mockHandler, "video.mkv", map[string]string{})
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "metadata"}).AddRow(1, "{}"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		_, err := fs.GetTranscodedContent(context.Background(), 1)
+		a.NoError(err)
+		a.NoError(mock.ExpectationsWereMet())
+		mockHandler.AssertExpectations(t)
+		a.Equal("mp4", fs.FileTarget[0].MetadataSerialized[model.TranscodedMetadataKey])
+	}
+}
@@ -0,0 +1,96 @@
+package filesystem
+
+import (
+	"context"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/gofrs/uuid"
+)
+
+// CopyToUser 将 src 目录下的目录和文件复制到 dstFs 所属用户的 dst 目录中，副本与原文件共用物理文件，
+// 占用的容量计入目标用户。transfer 为 true 时复制完成后删除原对象，即将所有权转移给目标用户
+func (fs *FileSystem) CopyToUser(ctx context.Context, dstFs *FileSystem, dirs, files []uint, src, dst string, transfer bool) error {
+	if fs.User.ID == dstFs.User.ID {
+		return ErrCopyToSameUser
+	}
+
+	isSrcExist, srcFolder := fs.IsPathExist(src)
+	isDstExist, dstFolder := dstFs.IsPathExist(dst)
+	if !isSrcExist || !isDstExist {
+		return ErrPathNotExist
+	}
+
+	if err := fs.CheckWritable(srcFolder); err != nil {
+		return err
+	}
+	if err := dstFs.CheckWritable(dstFolder); err != nil {
+		return err
+	}
+
+	// 统计要复制的文件总大小
+	if len(dirs) > 0 {
+		if err := fs.ListDeleteDirs(ctx, dirs); err != nil {
+			return err
+		}
+	}
+
+	if len(files) > 0 {
+		if err := fs.ListDeleteFiles(ctx, files); err != nil {
+			return err
+		}
+	}
+
+	var size uint64
+	for i := range fs.FileTarget {
+		// 转移所有权需删除原文件，保留期内的文件不可转移
+		if transfer && fs.FileTarget[i].IsRetained() {
+			fs.CleanTargets()
+			return ErrFileRetained
+		}
+		size += fs.FileTarget[i].Size
+	}
+	fs.CleanTargets()
+
+	// 在目标用户处预留容量，避免与进行中的上传一同超出容量
+	key := uuid.Must(uuid.NewV4()).String()
+	if err := dstFs.ReserveCapacity(key, size, reservationTTL()); err != nil {
+		return err
+	}
+	defer ReleaseCapacity(key)
+
+	// 复制过程中出错时，已复制的部分仍计入目标用户容量
+	var copied uint64
+	for _, dir := range dirs {
+		subFileSizes, err := srcFolder.CopyFolderTo(dir, dstFolder)
+		copied += subFileSizes
+		if err != nil {
+			dstFs.User.IncreaseStorageWithoutCheck(copied)
+			return ErrObjectNotExist.WithError(err)
+		}
+	}
+
+	if len(files) > 0 {
+		subFileSizes, err := srcFolder.MoveOrCopyFileTo(files, dstFolder, true)
+		copied += subFileSizes
+		if err != nil {
+			dstFs.User.IncreaseStorageWithoutCheck(copied)
+			return ErrObjectNotExist.WithError(err)
+		}
+	}
+
+	dstFs.User.IncreaseStorageWithoutCheck(copied)
+	RecordChanges(dstFs.placeChanges(fs.changeTargets(fs.User.ID, dirs, files), model.ChangeCreated, dst, "", true)...)
+
+	if !transfer {
+		return nil
+	}
+
+	// 删除原对象，物理文件仍被副本引用，不会被删除
+	activities := fs.NewActivities(model.ActivityDelete, src, "", dirs, files)
+	if err := fs.Delete(ctx, dirs, files, false, false); err != nil {
+		return err
+	}
+	RecordActivities(activities...)
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package filesystem
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_CopyToUser(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(cache.Set("setting_upload_session_timeout", "60", 0))
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	dstFs := &FileSystem{User: &model.User{
+		Model:   gorm.Model{ID: 2},
+		Storage: 5,
+		Group:   model.Group{MaxStorage: 8},
+	}}
+	ctx := context.Background()
+
+	// 目标用户与源用户相同
+	a.Equal(ErrCopyToSameUser, fs.CopyToUser(ctx, fs, nil, []uint{1}, "/", "/", false))
+
+	// 目录不存在
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"name"}))
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"name"}))
+		a.Equal(ErrPathNotExist, fs.CopyToUser(ctx, dstFs, nil, []uint{1}, "/src", "/dst", false))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 目标用户容量不足
+	{
+		mock.ExpectQuery("SELECT(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
+		mock.ExpectQuery("SELECT(.+)").WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(2, 2))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "size", "folder_id", "user_id"}).AddRow(1, 5, 1, 1))
+		expectReservation(2, 0)
+		expectReserve(5, 0, true)
+		a.Equal(ErrInsufficientCapacity, fs.CopyToUser(ctx, dstFs, nil, []uint{1}, "/", "/", false))
+		a.NoError(mock.ExpectationsWereMet())
+		a.Empty(fs.FileTarget)
+	}
+
+	// 转移保留期内的文件
+	{
+		policy := model.Policy{Model: gorm.Model{ID: 99}}
+		policy.OptionsSerialized.RetentionDays = 1
+		a.NoError(cache.Set("policy_99", policy, 0))
+		defer cache.Deletes([]string{"99"}, "policy_")
+		mock.ExpectQuery("SELECT(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
+		mock.ExpectQuery("SELECT(.+)").WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(2, 2))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "size", "folder_id", "user_id", "policy_id", "created_at"}).
+				AddRow(1, 1, 1, 1, 99, time.Now()))
+		a.Equal(ErrFileRetained, fs.CopyToUser(ctx, dstFs, nil, []uint{1}, "/", "/", true))
+		a.NoError(mock.ExpectationsWereMet())
+		a.Empty(fs.FileTarget)
+	}
+
+	// 复制成功，容量计入目标用户
+	{
+		mock.ExpectQuery("SELECT(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
+		mock.ExpectQuery("SELECT(.+)").WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(2, 2))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "size", "folder_id", "user_id"}).AddRow(1, 2, 1, 1))
+		expectReservation(2, 0)
+		expectReserve(5, 0, false)
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "size", "folder_id", "user_id"}).AddRow(1, 2, 1, 1))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)files(.+)").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)users(.+)storage(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)capacity_reservations(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		a.NoError(fs.CopyToUser(ctx, dstFs, nil, []uint{1}, "/", "/", false))
+		a.NoError(mock.ExpectationsWereMet())
+		a.EqualValues(7, dstFs.User.Storage)
+	}
+}
@@ -0,0 +1,162 @@
+package filesystem
+
+import (
+	"context"
+	"path"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+/* ================
+	 回收站相关
+   ================
+*/
+
+// Trash 将对象移入回收站，回收站中的对象在彻底删除前仍占用用户容量。
+// 上传中的占位文件无法恢复，直接删除
+func (fs *FileSystem) Trash(ctx context.Context, dirs, files []uint) error {
+	// 列出要删除的对象，以检查包含的文件是否处于保留期内
+	if len(dirs) > 0 {
+		if err := fs.ListDeleteDirs(ctx, dirs); err != nil {
+			return err
+		}
+	}
+
+	if len(files) > 0 {
+		if err := fs.ListDeleteFiles(ctx, files); err != nil {
+			return err
+		}
+	}
+
+	for i := range fs.FileTarget {
+		if fs.FileTarget[i].IsRetained() {
+			return ErrFileRetained
+		}
+	}
+
+	placeholders := make([]uint, 0)
+	trashedFiles := make([]*model.File, 0, len(files))
+	for i := range fs.FileTarget {
+		file := &fs.FileTarget[i]
+		if !util.ContainsUint(files, file.ID) {
+			continue
+		}
+
+		if file.UploadSessionID != nil {
+			placeholders = append(placeholders, file.ID)
+			continue
+		}
+
+		// 移入回收站会修改对象的名称和所在目录，事先记录
+		trashed := *file
+		if _, err := model.TrashFile(file); err != nil {
+			return ErrDBDeleteObjects.WithError(err)
+		}
+		trashedFiles = append(trashedFiles, &trashed)
+	}
+
+	trashedFolders := make([]model.Folder, 0, len(dirs))
+	for i := range fs.DirTarget {
+		folder := &fs.DirTarget[i]
+		if !util.ContainsUint(dirs, folder.ID) {
+			continue
+		}
+
+		trashed := *folder
+		if _, err := model.TrashFolder(folder); err != nil {
+			return ErrDBDeleteObjects.WithError(err)
+		}
+		trashedFolders = append(trashedFolders, trashed)
+	}
+
+	RecordChanges(fs.deletedChanges(trashedFolders, trashedFiles)...)
+
+	if len(placeholders) > 0 {
+		fs.CleanTargets()
+		return fs.Delete(ctx, nil, placeholders, false, false)
+	}
+
+	return nil
+}
+
+// RestoreTrash 将回收站中的对象恢复到原目录，原目录已不存在时恢复到根目录
+func (fs *FileSystem) RestoreTrash(ctx context.Context, ids []uint) error {
+	trashes, err := model.GetTrashByIDs(ids, fs.User.ID)
+	if err != nil || len(trashes) != len(ids) {
+		return ErrObjectNotExist.WithError(err)
+	}
+
+	for i := range trashes {
+		dst, err := fs.restoreDestination(&trashes[i])
+		if err != nil {
+			return err
+		}
+
+		// 检查目标目录中是否已有同名对象
+		if exist, _ := fs.IsChildFileExist(dst, trashes[i].Name); exist {
+			return ErrFileExisted
+		}
+		if _, err := dst.GetChild(trashes[i].Name); err == nil {
+			return ErrFileExisted
+		}
+
+		if err := trashes[i].Restore(dst); err != nil {
+			return ErrDBRestoreObjects.WithError(err)
+		}
+
+		RecordChanges(model.Change{
+			UserID:   fs.User.ID,
+			Action:   model.ChangeCreated,
+			IsDir:    trashes[i].ObjectType == model.TrashObjectFolder,
+			ObjectID: trashes[i].ObjectID,
+			Path:     path.Join(folderFullPath(dst), trashes[i].Name),
+		})
+	}
+
+	return nil
+}
+
+// restoreDestination 返回回收站对象要恢复到的目录
+func (fs *FileSystem) restoreDestination(trash *model.Trash) (*model.Folder, error) {
+	folders, err := model.GetFoldersByIDs([]uint{trash.ParentID}, fs.User.ID)
+	if err == nil && len(folders) > 0 {
+		// 原目录的上级目录也可能在回收站中
+		if err := folders[0].TraceRoot(); err == nil {
+			return &folders[0], nil
+		}
+	}
+
+	root, err := fs.User.Root()
+	if err != nil {
+		return nil, ErrObjectNotExist.WithError(err)
+	}
+
+	return root, nil
+}
+
+// PurgeTrash 彻底删除回收站中的对象及其物理文件
+func (fs *FileSystem) PurgeTrash(ctx context.Context, trashes []model.Trash) error {
+	for i := range trashes {
+		folders, files, err := trashes[i].Objects()
+		if err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+
+		// 对象记录已不存在时仅删除回收站记录
+		if len(folders) > 0 || len(files) > 0 {
+			fs.CleanTargets()
+			fs.SetTargetFile(&files)
+			fs.SetTargetDir(&folders)
+			if err := fs.deleteTargets(ctx, false, false); err != nil {
+				return err
+			}
+		}
+
+		if err := trashes[i].Delete(); err != nil {
+			return ErrDBDeleteObjects.WithError(err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_RestoreTrash(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{}}
+	fs.User.ID = 1
+
+	// 回收站对象不存在
+	{
+		mock.ExpectQuery("SELECT(.+)trashes(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		err := fs.RestoreTrash(context.Background(), []uint{1})
+		a.NoError(mock.ExpectationsWereMet())
+		a.ErrorIs(err, ErrObjectNotExist)
+	}
+
+	// 原目录中已有同名文件
+	{
+		mock.ExpectQuery("SELECT(.+)trashes(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "object_type", "name", "parent_id"}).AddRow(1, model.TrashObjectFile, "1.txt", 2))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(2, nil))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		err := fs.RestoreTrash(context.Background(), []uint{1})
+		a.NoError(mock.ExpectationsWereMet())
+		a.ErrorIs(err, ErrFileExisted)
+	}
+}
+
+func TestFileSystem_PurgeTrash(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{}}
+	fs.User.ID = 1
+
+	// 列出对象失败
+	{
+		trash := model.Trash{ObjectType: model.TrashObjectFile, ObjectID: 1, UserID: 1}
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("error"))
+		err := fs.PurgeTrash(context.Background(), []model.Trash{trash})
+		a.NoError(mock.ExpectationsWereMet())
+		a.ErrorIs(err, ErrDBListObjects)
+	}
+
+	// 对象记录已不存在，仅删除回收站记录
+	{
+		trash := model.Trash{ObjectType: model.TrashObjectFile, ObjectID: 1, UserID: 1}
+		trash.ID = 1
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)trashes(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		err := fs.PurgeTrash(context.Background(), []model.Trash{trash})
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+	}
+}
@@ -2,13 +2,28 @@ package filesystem
 
 import (
 	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net"
 	"os"
 	"path"
+	"syscall"
 	"time"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cluster"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/chunk/backoff"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/mq"
 	"github.com/cloudreve/Cloudreve/v3/pkg/request"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
@@ -26,10 +41,36 @@ const (
 	UploadSessionCtx         = "uploadSession"
 	UserCtx                  = "user"
 	UploadSessionCachePrefix = "callback_"
+	// UploadProgressTopicPrefix 上传进度事件的消息主题前缀
+	UploadProgressTopicPrefix = "upload_progress_"
+	// uploadProgressInterval 上传进度事件的最小发布间隔
+	uploadProgressInterval = time.Second
+	// putRetryBaseSleep 上传失败重试的初始等待时间，之后每次翻倍
+	putRetryBaseSleep = time.Second
+	// putRetryMaxSleep 上传失败重试的最长等待时间
+	putRetryMaxSleep = 30 * time.Second
 )
 
+// uploadSessionMeta 记录在占位文件元数据中的上传会话，会话过期后据此取消存储端未完成的上传
+type uploadSessionMeta struct {
+	Key       string `json:"key"`
+	SavePath  string `json:"save_path"`
+	UploadID  string `json:"upload_id,omitempty"`
+	UploadURL string `json:"upload_url,omitempty"`
+	Expires   int64  `json:"expires"`
+}
+
 // Upload 上传文件
 func (fs *FileSystem) Upload(ctx context.Context, file *fsctx.FileStream) (err error) {
+	// 重名时先确定最终文件名，存储路径按最终文件名生成
+	if file.SavePath == "" {
+		ctx, err = fs.resolveUploadNameConflict(ctx, file)
+		if err != nil {
+			request.BlackHole(file)
+			return err
+		}
+	}
+
 	// 上传前的钩子
 	err = fs.Trigger(ctx, "BeforeUpload", file)
 	if err != nil {
@@ -54,11 +95,16 @@ func (fs *FileSystem) Upload(ctx context.Context, file *fsctx.FileStream) (err e
 		// 处理客户端未完成上传时，关闭连接
 		go fs.CancelUpload(ctx, savePath, file)
 
-		err = fs.Handler.Put(ctx, file)
+		var digest *digestReader
+		digest, err = fs.putWithRetry(ctx, file)
 		if err != nil {
 			fs.Trigger(ctx, "AfterUploadFailed", file)
 			return err
 		}
+
+		if digest != nil {
+			digest.apply(file, file.Size)
+		}
 	}
 
 	// 上传完成后的钩子
@@ -78,14 +124,315 @@ func (fs *FileSystem) Upload(ctx context.Context, file *fsctx.FileStream) (err e
 	return nil
 }
 
+// putWithRetry 包装文件流后调用存储端上传文件，远程存储遇到临时网络错误且文件流可回溯时按策略配置重试。
+// 返回最后一次上传时计算摘要的 digestReader，无需计算摘要时为 nil
+func (fs *FileSystem) putWithRetry(ctx context.Context, file *fsctx.FileStream) (*digestReader, error) {
+	retries := 0
+	if fs.Policy != nil && fs.Policy.Type != "local" {
+		retries = fs.Policy.OptionsSerialized.PutRetries
+	}
+
+	if retries <= 0 || !file.Seekable() || file.File == nil {
+		digest := fs.wrapUploadStream(file, 0)
+		return digest, fs.Handler.Put(ctx, file)
+	}
+
+	// 存储端上传完成后会关闭文件流，重试期间由此处负责关闭
+	origin := file.File
+	defer func() {
+		file.File = origin
+		origin.Close()
+	}()
+
+	var replayed uint64
+	b := &backoff.ExponentialBackoff{Base: putRetryBaseSleep, MaxSleep: putRetryMaxSleep, Max: retries}
+	for {
+		// 每次尝试重新包装文件流，使进度和摘要从头计算，已读取过的数据不再计入限速
+		attempt := &attemptReader{ReadCloser: ioutil.NopCloser(origin)}
+		file.File = attempt
+		digest := fs.wrapUploadStream(file, replayed)
+		err := fs.Handler.Put(ctx, file)
+		if err == nil || ctx.Err() != nil || !isTransientError(err) {
+			return digest, err
+		}
+
+		if attempt.read > replayed {
+			replayed = attempt.read
+		}
+
+		if !b.Next(err) {
+			util.Log().Warning("Failed to upload file %q after %d retries: %s", file.SavePath, retries, err)
+			return nil, err
+		}
+
+		if _, seekErr := file.Seek(0, io.SeekStart); seekErr != nil {
+			util.Log().Warning("Failed to rewind file %q for retry: %s", file.SavePath, seekErr)
+			return nil, err
+		}
+
+		fs.recordPutRetry(ctx, file, b.Tried(), retries, err)
+	}
+}
+
+// wrapUploadStream 为文件流安装限速、进度、流量统计及摘要计算，replayed 为此前的上传尝试已读取的字节数，
+// 这部分数据不再计入限速。返回计算摘要的 digestReader，无需计算时为 nil
+func (fs *FileSystem) wrapUploadStream(file *fsctx.FileStream, replayed uint64) *digestReader {
+	fs.withUploadSpeedLimit(file, replayed)
+	fs.withUploadProgress(file)
+	fs.withUploadTraffic(file, replayed)
+	return fs.withUploadDigest(file)
+}
+
+// recordPutRetry 记录上传重试，在任务中上传时同时写入任务日志
+func (fs *FileSystem) recordPutRetry(ctx context.Context, file *fsctx.FileStream, tried, retries int, err error) {
+	util.Log().Warning("Failed to upload file %q, retrying (%d/%d): %s", file.SavePath, tried, retries, err)
+
+	if task, ok := ctx.Value(fsctx.TaskCtx).(*model.Task); ok {
+		msg := fmt.Sprintf("Failed to upload file %q, retrying (%d/%d): %s", path.Join(file.VirtualPath, file.Name), tried, retries, err)
+		if logErr := task.AppendLog(msg); logErr != nil {
+			util.Log().Warning("Failed to record upload retry in task log: %s", logErr)
+		}
+	}
+}
+
+// attemptReader 统计单次上传尝试从文件流读取的字节数
+type attemptReader struct {
+	io.ReadCloser
+	read uint64
+}
+
+func (r *attemptReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.read += uint64(n)
+	return n, err
+}
+
+// replayCounter 区分重试上传时重复读取的数据，skip 为此前的上传尝试已读取过的字节数
+type replayCounter struct {
+	skip uint64
+	read uint64
+}
+
+// fresh 记录读取的 n 字节，返回其中此前的上传尝试未读取过的字节数
+func (c *replayCounter) fresh(n int) int {
+	start := c.read
+	c.read += uint64(n)
+	switch {
+	case c.read <= c.skip:
+		return 0
+	case start < c.skip:
+		return int(c.read - c.skip)
+	default:
+		return n
+	}
+}
+
+// isTransientError 判断上传错误是否为可重试的临时网络错误
+func isTransientError(err error) bool {
+	var (
+		netErr       net.Error
+		retryableErr *backoff.RetryableError
+	)
+
+	return errors.As(err, &netErr) ||
+		errors.As(err, &retryableErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// withUploadSpeedLimit 按用户组上传限速包装文件流，同一用户的所有连接共享令牌桶，
+// replayed 为重试前已读取过的字节数，不再重复限速
+func (fs *FileSystem) withUploadSpeedLimit(file *fsctx.FileStream, replayed uint64) {
+	if fs.User == nil || file.File == nil {
+		return
+	}
+
+	speed := fs.User.Group.OptionsSerialized.UploadSpeedLimit
+	if speed <= 0 {
+		return
+	}
+
+	file.File = &throttledReader{
+		ReadCloser: file.File,
+		bucket:     uploadBuckets.get(fs.User.ID, speed),
+		replay:     replayCounter{skip: replayed},
+	}
+}
+
+// uploadProgressReader 统计存储端已读取的字节数，并通过消息队列发布上传进度
+type uploadProgressReader struct {
+	io.ReadCloser
+	sessionID string
+	masterID  string
+	total     uint64
+	start     uint64
+	end       uint64
+	written   uint64
+	startTime time.Time
+	lastSent  time.Time
+}
+
+func (r *uploadProgressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.written += uint64(n)
+	if err == io.EOF || r.written >= r.end || time.Since(r.lastSent) >= uploadProgressInterval {
+		r.publish()
+	}
+
+	return n, err
+}
+
+func (r *uploadProgressReader) publish() {
+	r.lastSent = time.Now()
+	event := serializer.UploadProgressEvent{
+		SessionID: r.sessionID,
+		Written:   r.written,
+		Total:     r.total,
+		ETA:       -1,
+	}
+
+	if elapsed := time.Since(r.startTime).Seconds(); elapsed > 0 {
+		event.Speed = float64(r.written-r.start) / elapsed
+	}
+
+	if event.Speed > 0 && r.total >= r.written {
+		event.ETA = int64(float64(r.total-r.written) / event.Speed)
+	}
+
+	msg := mq.Message{
+		TriggeredBy: r.sessionID,
+		Event:       "progress",
+		Content:     event,
+	}
+
+	// 从机上的会话将进度转发至主机，由主机推送给客户端
+	if r.masterID != "" {
+		if err := cluster.DefaultController.SendNotification(r.masterID, UploadProgressTopicPrefix+r.sessionID, msg); err != nil {
+			util.Log().Debug("Failed to relay upload progress to master node: %s", err)
+		}
+		return
+	}
+
+	mq.GlobalMQ.Publish(UploadProgressTopicPrefix+r.sessionID, msg)
+}
+
+// withUploadProgress 为属于上传会话的文件流发布写入进度
+func (fs *FileSystem) withUploadProgress(file *fsctx.FileStream) {
+	if file.UploadSessionID == nil || file.File == nil {
+		return
+	}
+
+	total := file.AppendStart + file.Size
+	masterID := ""
+	if sessionRaw, ok := cache.Get(UploadSessionCachePrefix + *file.UploadSessionID); ok {
+		total = sessionRaw.(serializer.UploadSession).Size
+		masterID = sessionRaw.(serializer.UploadSession).MasterID
+	}
+
+	file.File = &uploadProgressReader{
+		ReadCloser: file.File,
+		sessionID:  *file.UploadSessionID,
+		masterID:   masterID,
+		total:      total,
+		start:      file.AppendStart,
+		end:        file.AppendStart + file.Size,
+		written:    file.AppendStart,
+		startTime:  time.Now(),
+	}
+}
+
+// digestReader 在存储端读取文件流的同时计算 MD5、SHA-1 及 SHA-256 摘要，避免上传后再次读取文件
+type digestReader struct {
+	io.ReadCloser
+	md5    hash.Hash
+	sha1   hash.Hash
+	sha256 hash.Hash
+	read   uint64
+}
+
+func newDigestReader(r io.ReadCloser) *digestReader {
+	return &digestReader{
+		ReadCloser: r,
+		md5:        md5.New(),
+		sha1:       sha1.New(),
+		sha256:     sha256.New(),
+	}
+}
+
+func (r *digestReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.md5.Write(p[:n])
+		r.sha1.Write(p[:n])
+		r.sha256.Write(p[:n])
+		r.read += uint64(n)
+	}
+
+	return n, err
+}
+
+// apply 文件流恰好被完整读取一次时将摘要写入 file，重试等情况下读取的数据不完整或有重复，不写入
+func (r *digestReader) apply(file fsctx.FileHeader, size uint64) {
+	if r.read != size {
+		return
+	}
+
+	file.SetDigest(
+		hex.EncodeToString(r.md5.Sum(nil)),
+		hex.EncodeToString(r.sha1.Sum(nil)),
+		hex.EncodeToString(r.sha256.Sum(nil)),
+	)
+}
+
+// withUploadDigest 为完整上传的文件流计算摘要，分片及追加上传在完成后由 HookComputeDigest 计算
+func (fs *FileSystem) withUploadDigest(file *fsctx.FileStream) *digestReader {
+	if file.File == nil || file.UploadSessionID != nil || file.Mode&fsctx.Append == fsctx.Append {
+		return nil
+	}
+
+	digest := newDigestReader(file.File)
+	file.File = digest
+	return digest
+}
+
+// updateDigest 使用当前存储策略的适配器读取文件的物理文件，计算并记录内容摘要
+func (fs *FileSystem) updateDigest(ctx context.Context, file *model.File) error {
+	rs, err := fs.Handler.Get(ctx, file.SourceName)
+	if err != nil {
+		return err
+	}
+
+	digest := newDigestReader(rs)
+	_, err = io.Copy(io.Discard, digest)
+	rs.Close()
+	if err != nil {
+		return err
+	}
+
+	return file.UpdateDigest(
+		hex.EncodeToString(digest.md5.Sum(nil)),
+		hex.EncodeToString(digest.sha1.Sum(nil)),
+		hex.EncodeToString(digest.sha256.Sum(nil)),
+	)
+}
+
 // GenerateSavePath 生成要存放文件的路径
 // TODO 完善测试
 func (fs *FileSystem) GenerateSavePath(ctx context.Context, file fsctx.FileHeader) string {
 	fileInfo := file.Info()
-	return path.Join(
+	virtualPath := fileInfo.VirtualPath
+
+	// 访客向分享目录上传时，路径相对于分享的目录，需转换为分享者文件系统中的完整路径
+	if _, ok := ctx.Value(fsctx.ShareKeyCtx).(string); ok && fs.Root != nil {
+		virtualPath = path.Join(fs.Root.Position, fs.Root.Name, virtualPath)
+	}
+
+	savePath := path.Join(
 		fs.Policy.GeneratePath(
 			fs.User.Model.ID,
-			fileInfo.VirtualPath,
+			virtualPath,
 		),
 		fs.Policy.GenerateFileName(
 			fs.User.Model.ID,
@@ -93,6 +440,12 @@ func (fs *FileSystem) GenerateSavePath(ctx context.Context, file fsctx.FileHeade
 		),
 	)
 
+	// 存储路径中包含所选的存储根目录，后续读写直接使用此路径
+	if shard := pickShard(fs.Policy, fileInfo.Size); shard != "" {
+		savePath = path.Join(shard, savePath)
+	}
+
+	return savePath
 }
 
 // CancelUpload 监测客户端取消上传
@@ -126,13 +479,65 @@ func (fs *FileSystem) CancelUpload(ctx context.Context, path string, file fsctx.
 	}
 }
 
+// InstantUpload 尝试秒传，返回值表示是否已直接创建文件记录
+func (fs *FileSystem) InstantUpload(ctx context.Context, file *fsctx.FileStream) (bool, error) {
+	defer fs.CleanHooks("BeforeUpload")
+	defer fs.CleanHooks("AfterUpload")
+	defer fs.CleanHooks("AfterValidateFailed")
+
+	// 秒传时不传输文件内容
+	file.Mode = fsctx.Nop
+
+	fs.Use("BeforeUpload", HookValidateFile)
+	fs.Use("BeforeUpload", HookInstantUpload)
+	fs.Use("BeforeUpload", HookUploadWebhook(WebhookBeforeUpload))
+	fs.Use("BeforeUpload", HookReserveCapacity)
+	fs.Use("AfterUpload", HookUploadWebhook(WebhookAfterUpload))
+	fs.Use("AfterUpload", GenericAfterUpload)
+	fs.Use("AfterUpload", HookExtractPhotoMetadata)
+	fs.Use("AfterUpload", HookRecordUploadActivity)
+	if IsCameraUpload(ctx) {
+		fs.Use("AfterUpload", HookFileByCaptureDate)
+	}
+	fs.Use("AfterUpload", HookReleaseCapacity)
+	fs.Use("AfterValidateFailed", HookReleaseCapacity)
+
+	err := fs.Upload(ctx, file)
+	if err == ErrBlobNotExist {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
 // CreateUploadSession 创建上传会话
 func (fs *FileSystem) CreateUploadSession(ctx context.Context, file *fsctx.FileStream) (*serializer.UploadCredential, error) {
+	if err := fs.checkPolicyAvailable(); err != nil {
+		return nil, err
+	}
+
+	// 客户端提供了文件哈希时先尝试秒传
+	if file.Hash != "" {
+		ok, err := fs.InstantUpload(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			return &serializer.UploadCredential{Instant: true}, nil
+		}
+	}
+
 	// 获取相关有效期设置
 	callBackSessionTTL := model.GetIntSetting("upload_session_timeout", 86400)
 
 	callbackKey := uuid.Must(uuid.NewV4()).String()
 	fileSize := file.Size
+	name := file.Name
+
+	// 客户端提供的摘要仅用于上传完成后校验，校验通过前不写入文件记录
+	expectedMD5, expectedHash := file.MD5, file.Hash
+	file.SetDigest("", "", "")
 
 	// 创建占位的文件，同时校验文件信息
 	file.Mode = fsctx.Nop
@@ -141,9 +546,10 @@ func (fs *FileSystem) CreateUploadSession(ctx context.Context, file *fsctx.FileS
 	}
 
 	fs.Use("BeforeUpload", HookValidateFile)
-	fs.Use("BeforeUpload", HookValidateCapacity)
+	fs.Use("BeforeUpload", HookUploadWebhook(WebhookBeforeUpload))
+	fs.Use("BeforeUpload", HookReserveCapacity)
 
-	// 验证文件规格
+	// 验证文件规格，同时为上传会话预留容量
 	if err := fs.Upload(ctx, file); err != nil {
 		return nil, err
 	}
@@ -158,24 +564,53 @@ func (fs *FileSystem) CreateUploadSession(ctx context.Context, file *fsctx.FileS
 		SavePath:       file.SavePath,
 		LastModified:   file.LastModified,
 		CallbackSecret: util.RandStringRunes(32),
+		SpeedLimit:     fs.User.Group.OptionsSerialized.UploadSpeedLimit,
+		MD5:            expectedMD5,
+		Hash:           expectedHash,
+		Expires:        time.Now().Add(time.Duration(callBackSessionTTL) * time.Second).Unix(),
+		CameraUpload:   IsCameraUpload(ctx),
 	}
 
 	// 获取上传凭证
 	credential, err := fs.Handler.Token(ctx, int64(callBackSessionTTL), uploadSession, file)
 	if err != nil {
+		ReleaseCapacity(callbackKey)
 		return nil, err
 	}
 
-	// 创建占位符
+	// 在占位文件中记录会话信息，会话过期后仍可清理存储端的未完成上传
+	if meta, err := json.Marshal(uploadSessionMeta{
+		Key:       uploadSession.Key,
+		SavePath:  uploadSession.SavePath,
+		UploadID:  uploadSession.UploadID,
+		UploadURL: uploadSession.UploadURL,
+		Expires:   uploadSession.Expires,
+	}); err == nil {
+		if file.Metadata == nil {
+			file.Metadata = make(map[string]string)
+		}
+		file.Metadata[UploadSessionMetaKey] = string(meta)
+	}
+
+	// 创建占位符，上传前的钩子已执行过
+	fs.CleanHooks("BeforeUpload")
 	if !fs.Policy.IsUploadPlaceholderWithSize() {
 		fs.Use("AfterUpload", HookClearFileHeaderSize)
 	}
 	fs.Use("AfterUpload", GenericAfterUpload)
+	fs.Use("AfterUpload", HookConfirmCapacity)
 	ctx = context.WithValue(ctx, fsctx.IgnoreDirectoryConflictCtx, true)
 	if err := fs.Upload(ctx, file); err != nil {
+		ReleaseCapacity(callbackKey)
 		return nil, err
 	}
 
+	// 重名时文件名可能已被修改，覆盖或保留版本在上传完成后处理
+	uploadSession.Name = file.Name
+	if uploadSession.ReplaceName = deferredReplaceName(ctx, name, file); uploadSession.ReplaceName != "" {
+		uploadSession.Conflict = string(ctx.Value(fsctx.ConflictStrategyCtx).(fsctx.ConflictStrategy))
+	}
+
 	// 创建回调会话
 	err = cache.Set(
 		UploadSessionCachePrefix+callbackKey,
@@ -183,15 +618,126 @@ func (fs *FileSystem) CreateUploadSession(ctx context.Context, file *fsctx.FileS
 		callBackSessionTTL,
 	)
 	if err != nil {
+		ReleaseCapacity(callbackKey)
 		return nil, err
 	}
 
 	// 补全上传凭证其他信息
-	credential.Expires = time.Now().Add(time.Duration(callBackSessionTTL) * time.Second).Unix()
+	credential.Expires = uploadSession.Expires
 
 	return credential, nil
 }
 
+// uploadSessionFromMetadata 从占位文件元数据中恢复已过期的上传会话
+func uploadSessionFromMetadata(file *model.File) (*serializer.UploadSession, bool) {
+	raw, ok := file.MetadataSerialized[UploadSessionMetaKey]
+	if !ok {
+		return nil, false
+	}
+
+	var meta uploadSessionMeta
+	if err := json.Unmarshal([]byte(raw), &meta); err != nil {
+		return nil, false
+	}
+
+	return &serializer.UploadSession{
+		Key:       meta.Key,
+		UID:       file.UserID,
+		Name:      file.Name,
+		Size:      file.Size,
+		SavePath:  meta.SavePath,
+		Policy:    *file.GetPolicy(),
+		UploadID:  meta.UploadID,
+		UploadURL: meta.UploadURL,
+		Expires:   meta.Expires,
+	}, true
+}
+
+// IsUploadRejected 判断上传完成后的处理是否因文件内容被拒绝而失败，此时续传也无法完成上传。
+// Webhook 会返回携带自定义信息的错误，因此按错误代码判断
+func IsUploadRejected(err error) bool {
+	var appErr serializer.AppError
+	if !errors.As(err, &appErr) {
+		return false
+	}
+
+	switch appErr.Code {
+	case serializer.CodeVirusDetected, serializer.CodeUploadRejected, serializer.CodeFileTypeNotAllowed, serializer.CodeChecksumMismatch:
+		return true
+	}
+
+	return false
+}
+
+// GetUploadProgress 获取中转上传会话的续传进度，客户端据此从中断的分片继续上传
+func (fs *FileSystem) GetUploadProgress(ctx context.Context, sessionID string) (*serializer.UploadProgress, error) {
+	sessionRaw, ok := cache.Get(UploadSessionCachePrefix + sessionID)
+	if !ok {
+		return nil, ErrUploadSessionNotExist
+	}
+
+	session := sessionRaw.(serializer.UploadSession)
+	if session.UID != fs.User.ID {
+		return nil, ErrUploadSessionNotExist
+	}
+
+	// 只有经由服务端中转的上传才能获知已上传的大小
+	if !session.Policy.IsTransitUpload(session.Size) {
+		return nil, ErrUploadNotResumable
+	}
+
+	// 占位文件的大小会在每个分片上传完成后更新
+	file, err := model.GetFilesByUploadSession(sessionID, fs.User.ID)
+	if err != nil {
+		return nil, ErrUploadSessionNotExist.WithError(err)
+	}
+
+	chunkSize := session.Policy.OptionsSerialized.ChunkSize
+	progress := &serializer.UploadProgress{
+		SessionID: session.Key,
+		ChunkSize: chunkSize,
+		Size:      session.Size,
+		Expires:   session.Expires,
+	}
+
+	// 未启用分片时，中断的上传只能从头开始
+	if chunkSize > 0 {
+		progress.NextChunk = int(file.Size / chunkSize)
+		progress.Uploaded = uint64(progress.NextChunk) * chunkSize
+	}
+
+	return progress, nil
+}
+
+// ListUploadSessions 列出当前用户尚未过期的上传会话
+func (fs *FileSystem) ListUploadSessions() []serializer.UploadSessionItem {
+	files := model.GetUploadPlaceholderFiles(fs.User.ID)
+	sessions := make([]serializer.UploadSessionItem, 0, len(files))
+	for _, file := range files {
+		sessionRaw, ok := cache.Get(UploadSessionCachePrefix + *file.UploadSessionID)
+		if !ok {
+			continue
+		}
+
+		session := sessionRaw.(serializer.UploadSession)
+		if session.UID != fs.User.ID {
+			continue
+		}
+
+		sessions = append(sessions, serializer.UploadSessionItem{
+			SessionID: session.Key,
+			Name:      session.Name,
+			Path:      session.VirtualPath,
+			Size:      session.Size,
+			Uploaded:  file.Size,
+			Policy:    session.Policy.Name,
+			Expires:   session.Expires,
+		})
+	}
+
+	return sessions
+}
+
 // UploadFromStream 从文件流上传文件
 func (fs *FileSystem) UploadFromStream(ctx context.Context, file *fsctx.FileStream, resetPolicy bool) error {
 	if resetPolicy {
@@ -201,16 +747,30 @@ func (fs *FileSystem) UploadFromStream(ctx context.Context, file *fsctx.FileStre
 		if err != nil {
 			return err
 		}
+
+		// 使用目标目录绑定的存储策略
+		if err := fs.SwitchPolicyByPath(file.VirtualPath); err != nil {
+			return err
+		}
+	}
+
+	if err := fs.checkPolicyAvailable(); err != nil {
+		return err
 	}
 
 	// 给文件系统分配钩子
 	fs.Lock.Lock()
 	if fs.Hooks == nil {
 		fs.Use("BeforeUpload", HookValidateFile)
-		fs.Use("BeforeUpload", HookValidateCapacity)
+		fs.Use("BeforeUpload", HookUploadWebhook(WebhookBeforeUpload))
+		fs.Use("BeforeUpload", HookReserveCapacity)
 		fs.Use("AfterUploadCanceled", HookDeleteTempFile)
-		fs.Use("AfterUpload", GenericAfterUpload)
+		fs.Use("AfterUploadCanceled", HookReleaseCapacity)
+		fs.Use("AfterUploadFailed", HookReleaseCapacity)
+		fs.UseAfterUploadHooks(false, GenericAfterUpload)
+		fs.Use("AfterUpload", HookReleaseCapacity)
 		fs.Use("AfterValidateFailed", HookDeleteTempFile)
+		fs.Use("AfterValidateFailed", HookReleaseCapacity)
 	}
 	fs.Lock.Unlock()
 
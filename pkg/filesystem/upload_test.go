@@ -6,8 +6,12 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cluster"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/mocks/controllermock"
+	"github.com/cloudreve/Cloudreve/v3/pkg/mq"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
@@ -17,6 +21,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"syscall"
 	"testing"
 )
 
@@ -39,6 +44,10 @@ func (m FileHeaderMock) CancelToken(ctx context.Context, uploadSession *serializ
 	return args.Error(0)
 }
 
+func (m *FileHeaderMock) Capabilities() driver.Capabilities {
+	return driver.Capabilities{MultipartUpload: true, Thumbnail: true}
+}
+
 func (m FileHeaderMock) List(ctx context.Context, path string, recursive bool) ([]response.Object, error) {
 	args := m.Called(ctx, path, recursive)
 	return args.Get(0).([]response.Object), args.Error(1)
@@ -180,6 +189,7 @@ func TestFileSystem_GetUploadToken(t *testing.T) {
 		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("not found"))
 		mock.ExpectBegin()
 		mock.ExpectExec("INSERT(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectExec("UPDATE(.+)storage(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 		res, err := fs.CreateUploadSession(ctx, &fsctx.FileStream{
@@ -208,6 +218,7 @@ func TestFileSystem_GetUploadToken(t *testing.T) {
 		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("not found"))
 		mock.ExpectBegin()
 		mock.ExpectExec("INSERT(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectExec("UPDATE(.+)storage(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 		_, err := fs.CreateUploadSession(ctx, &fsctx.FileStream{
@@ -221,6 +232,42 @@ func TestFileSystem_GetUploadToken(t *testing.T) {
 	}
 }
 
+func TestFileSystem_CreateUploadSession_InstantFailed(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{
+		User:   &model.User{Model: gorm.Model{ID: 1}},
+		Policy: &model.Policy{MaxSize: 1},
+	}
+
+	// 秒传校验失败时不返回上传凭证
+	res, err := fs.CreateUploadSession(context.Background(), &fsctx.FileStream{
+		Name:        "1.txt",
+		VirtualPath: "/",
+		Size:        10,
+		Hash:        "hash",
+	})
+	a.Error(err)
+	a.Nil(res)
+}
+
+func TestFileSystem_GenerateSavePath(t *testing.T) {
+	asserts := assert.New(t)
+	fs := FileSystem{
+		User:   &model.User{Model: gorm.Model{ID: 1}},
+		Policy: &model.Policy{DirNameRule: "uploads/{path}", FileNameRule: "{originname}"},
+	}
+	file := &fsctx.FileStream{Name: "1.txt", VirtualPath: "/sub"}
+
+	// 普通上传
+	asserts.Equal("uploads/sub/1.txt", fs.GenerateSavePath(context.Background(), file))
+
+	// 向分享目录上传，使用分享者文件系统中的完整路径
+	fs.Root = &model.Folder{Name: "shared", Position: "/docs"}
+	asserts.Equal("uploads/sub/1.txt", fs.GenerateSavePath(context.Background(), file))
+	ctx := context.WithValue(context.Background(), fsctx.ShareKeyCtx, "key")
+	asserts.Equal("uploads/docs/shared/sub/1.txt", fs.GenerateSavePath(ctx, file))
+}
+
 func TestFileSystem_UploadFromStream(t *testing.T) {
 	asserts := assert.New(t)
 	fs := FileSystem{
@@ -261,3 +308,392 @@ func TestFileSystem_UploadFromPath(t *testing.T) {
 		asserts.Error(err)
 	}
 }
+
+func TestIsUploadRejected(t *testing.T) {
+	a := assert.New(t)
+	a.True(IsUploadRejected(ErrVirusDetected))
+	a.True(IsUploadRejected(ErrUploadRejected))
+	a.True(IsUploadRejected(ErrContentTypeNotAllowed))
+	a.True(IsUploadRejected(ErrChecksumMismatch))
+	a.True(IsUploadRejected(serializer.NewError(serializer.CodeUploadRejected, "custom message", nil)))
+	a.False(IsUploadRejected(ErrVirusScanFailed.WithError(errors.New("error"))))
+	a.False(IsUploadRejected(ErrIO))
+	a.False(IsUploadRejected(nil))
+}
+
+func TestFileSystem_GetUploadProgress(t *testing.T) {
+	asserts := assert.New(t)
+	fs := FileSystem{
+		User: &model.User{Model: gorm.Model{ID: 1}},
+	}
+	ctx := context.Background()
+
+	// 会话不存在
+	{
+		_, err := fs.GetUploadProgress(ctx, "TestFileSystem_GetUploadProgress_not_exist")
+		asserts.Equal(ErrUploadSessionNotExist, err)
+	}
+
+	// 会话不属于当前用户
+	{
+		cache.Set(UploadSessionCachePrefix+"TestFileSystem_GetUploadProgress", serializer.UploadSession{
+			Key: "TestFileSystem_GetUploadProgress",
+			UID: 2,
+		}, 0)
+		_, err := fs.GetUploadProgress(ctx, "TestFileSystem_GetUploadProgress")
+		asserts.Equal(ErrUploadSessionNotExist, err)
+	}
+
+	// 非中转上传策略
+	{
+		cache.Set(UploadSessionCachePrefix+"TestFileSystem_GetUploadProgress", serializer.UploadSession{
+			Key:    "TestFileSystem_GetUploadProgress",
+			UID:    1,
+			Policy: model.Policy{Type: "onedrive"},
+		}, 0)
+		_, err := fs.GetUploadProgress(ctx, "TestFileSystem_GetUploadProgress")
+		asserts.Equal(ErrUploadNotResumable, err)
+	}
+
+	// 占位文件不存在
+	{
+		cache.Set(UploadSessionCachePrefix+"TestFileSystem_GetUploadProgress", serializer.UploadSession{
+			Key:    "TestFileSystem_GetUploadProgress",
+			UID:    1,
+			Policy: model.Policy{Type: "local"},
+		}, 0)
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("not found"))
+		_, err := fs.GetUploadProgress(ctx, "TestFileSystem_GetUploadProgress")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+
+	// 成功
+	{
+		cache.Set(UploadSessionCachePrefix+"TestFileSystem_GetUploadProgress", serializer.UploadSession{
+			Key:  "TestFileSystem_GetUploadProgress",
+			UID:  1,
+			Size: 25,
+			Policy: model.Policy{
+				Type:              "local",
+				OptionsSerialized: model.PolicyOption{ChunkSize: 10},
+			},
+		}, 0)
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "size"}).AddRow(1, 20))
+		res, err := fs.GetUploadProgress(ctx, "TestFileSystem_GetUploadProgress")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(2, res.NextChunk)
+		asserts.EqualValues(20, res.Uploaded)
+		asserts.EqualValues(25, res.Size)
+	}
+}
+
+func TestFileSystem_WithUploadSpeedLimit(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+
+	// 未设置限速
+	{
+		file := &fsctx.FileStream{File: ioutil.NopCloser(strings.NewReader("123"))}
+		origin := file.File
+		fs.withUploadSpeedLimit(file, 0)
+		a.Equal(origin, file.File)
+	}
+
+	// 设置限速
+	{
+		fs.User.Group.OptionsSerialized.UploadSpeedLimit = 1024
+		file := &fsctx.FileStream{File: ioutil.NopCloser(strings.NewReader("123"))}
+		fs.withUploadSpeedLimit(file, 0)
+		a.IsType(&throttledReader{}, file.File)
+		content, err := ioutil.ReadAll(file)
+		a.NoError(err)
+		a.Equal("123", string(content))
+		a.NoError(file.Close())
+	}
+}
+
+func TestFileSystem_WithUploadProgress(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{}
+
+	// 不属于上传会话
+	{
+		file := &fsctx.FileStream{File: ioutil.NopCloser(strings.NewReader("123"))}
+		origin := file.File
+		fs.withUploadProgress(file)
+		a.Equal(origin, file.File)
+	}
+
+	// 发布进度
+	{
+		sessionID := "TestFileSystem_WithUploadProgress"
+		cache.Set(UploadSessionCachePrefix+sessionID, serializer.UploadSession{Size: 10}, 0)
+		events := mq.GlobalMQ.Subscribe(UploadProgressTopicPrefix+sessionID, 1)
+		defer mq.GlobalMQ.Unsubscribe(UploadProgressTopicPrefix+sessionID, events)
+
+		file := &fsctx.FileStream{
+			File:            ioutil.NopCloser(strings.NewReader("123")),
+			Size:            3,
+			AppendStart:     5,
+			UploadSessionID: &sessionID,
+		}
+		fs.withUploadProgress(file)
+		content, err := ioutil.ReadAll(file)
+		a.NoError(err)
+		a.Equal("123", string(content))
+
+		msg := <-events
+		event := msg.Content.(serializer.UploadProgressEvent)
+		a.Equal(sessionID, event.SessionID)
+		a.EqualValues(8, event.Written)
+		a.EqualValues(10, event.Total)
+	}
+
+	// 从机会话转发进度至主机
+	{
+		sessionID := "TestFileSystem_WithUploadProgress_Slave"
+		cache.Set(UploadSessionCachePrefix+sessionID, serializer.UploadSession{Size: 3, MasterID: "master"}, 0)
+		mockController := &controllermock.SlaveControllerMock{}
+		mockController.On("SendNotification", "master", UploadProgressTopicPrefix+sessionID, testMock.MatchedBy(func(msg mq.Message) bool {
+			return msg.Content.(serializer.UploadProgressEvent).Written == 3
+		})).Return(nil)
+		origin := cluster.DefaultController
+		cluster.DefaultController = mockController
+		defer func() { cluster.DefaultController = origin }()
+
+		file := &fsctx.FileStream{
+			File:            ioutil.NopCloser(strings.NewReader("123")),
+			Size:            3,
+			UploadSessionID: &sessionID,
+		}
+		fs.withUploadProgress(file)
+		_, err := ioutil.ReadAll(file)
+		a.NoError(err)
+		mockController.AssertExpectations(t)
+	}
+}
+
+func TestFileSystem_WithUploadDigest(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{}
+
+	// 分片上传不计算摘要
+	{
+		sessionID := "TestFileSystem_WithUploadDigest"
+		file := &fsctx.FileStream{File: ioutil.NopCloser(strings.NewReader("123")), UploadSessionID: &sessionID}
+		origin := file.File
+		a.Nil(fs.withUploadDigest(file))
+		a.Equal(origin, file.File)
+	}
+
+	// 完整读取
+	{
+		file := &fsctx.FileStream{File: ioutil.NopCloser(strings.NewReader("123")), Size: 3}
+		digest := fs.withUploadDigest(file)
+		a.NotNil(digest)
+		content, err := ioutil.ReadAll(file)
+		a.NoError(err)
+		a.Equal("123", string(content))
+		digest.apply(file, file.Size)
+		a.Equal("202cb962ac59075b964b07152d234b70", file.MD5)
+		a.Equal("40bd001563085fc35165329ea1ff5c5ecbdbbeef", file.SHA1)
+		a.Equal("a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3", file.Hash)
+	}
+
+	// 读取不完整
+	{
+		file := &fsctx.FileStream{File: ioutil.NopCloser(strings.NewReader("123")), Size: 3}
+		digest := fs.withUploadDigest(file)
+		_, err := file.Read(make([]byte, 1))
+		a.NoError(err)
+		digest.apply(file, file.Size)
+		a.Empty(file.MD5)
+		a.Empty(file.Hash)
+	}
+}
+
+func TestFileSystem_PutWithRetry(t *testing.T) {
+	a := assert.New(t)
+	policy := &model.Policy{Type: "remote", OptionsSerialized: model.PolicyOption{PutRetries: 1}}
+	calls := 0
+	count := func(testMock.Arguments) { calls++ }
+
+	// 临时网络错误，重试后成功
+	{
+		calls = 0
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Put", testMock.Anything, testMock.Anything).Return(syscall.ECONNRESET).Run(count).Once()
+		testHandler.On("Put", testMock.Anything, testMock.Anything).Return(nil).Run(count).Once()
+		fs := &FileSystem{Handler: testHandler, Policy: policy}
+		reader := strings.NewReader("123")
+		reader.Seek(2, 0)
+		file := &fsctx.FileStream{File: ioutil.NopCloser(reader), Seeker: reader}
+		_, err := fs.putWithRetry(context.Background(), file)
+		a.NoError(err)
+		a.Equal(2, calls)
+		a.Equal(3, reader.Len())
+	}
+
+	// 重试时重新计算摘要，并写入任务日志
+	{
+		calls = 0
+		readPart := func(args testMock.Arguments) {
+			calls++
+			args.Get(1).(*fsctx.FileStream).Read(make([]byte, 2))
+		}
+		readAll := func(args testMock.Arguments) {
+			calls++
+			ioutil.ReadAll(args.Get(1).(*fsctx.FileStream))
+		}
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Put", testMock.Anything, testMock.Anything).Return(syscall.ECONNRESET).Run(readPart).Once()
+		testHandler.On("Put", testMock.Anything, testMock.Anything).Return(nil).Run(readAll).Once()
+		fs := &FileSystem{Handler: testHandler, Policy: policy}
+		reader := strings.NewReader("123")
+		file := &fsctx.FileStream{File: ioutil.NopCloser(reader), Seeker: reader, Size: 3, Name: "1.txt", VirtualPath: "/dir"}
+		task := &model.Task{Model: gorm.Model{ID: 1}}
+		ctx := context.WithValue(context.Background(), fsctx.TaskCtx, task)
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)tasks(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		digest, err := fs.putWithRetry(ctx, file)
+		a.NoError(err)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Equal(2, calls)
+		a.Contains(task.Log, "/dir/1.txt")
+		a.Contains(task.Log, "(1/1)")
+
+		digest.apply(file, file.Size)
+		a.Equal("202cb962ac59075b964b07152d234b70", file.MD5)
+	}
+
+	// 非临时错误，不重试
+	{
+		calls = 0
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Put", testMock.Anything, testMock.Anything).Return(errors.New("error")).Run(count)
+		fs := &FileSystem{Handler: testHandler, Policy: policy}
+		reader := strings.NewReader("123")
+		file := &fsctx.FileStream{File: ioutil.NopCloser(reader), Seeker: reader}
+		_, err := fs.putWithRetry(context.Background(), file)
+		a.Error(err)
+		a.Equal(1, calls)
+	}
+
+	// 文件流不可回溯，不重试
+	{
+		calls = 0
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Put", testMock.Anything, testMock.Anything).Return(syscall.ECONNRESET).Run(count)
+		fs := &FileSystem{Handler: testHandler, Policy: policy}
+		file := &fsctx.FileStream{File: ioutil.NopCloser(strings.NewReader("123"))}
+		_, err := fs.putWithRetry(context.Background(), file)
+		a.Error(err)
+		a.Equal(1, calls)
+	}
+
+	// 本地策略，不重试
+	{
+		calls = 0
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Put", testMock.Anything, testMock.Anything).Return(syscall.ECONNRESET).Run(count)
+		fs := &FileSystem{Handler: testHandler, Policy: &model.Policy{Type: "local", OptionsSerialized: policy.OptionsSerialized}}
+		reader := strings.NewReader("123")
+		file := &fsctx.FileStream{File: ioutil.NopCloser(reader), Seeker: reader}
+		_, err := fs.putWithRetry(context.Background(), file)
+		a.Error(err)
+		a.Equal(1, calls)
+	}
+}
+
+func TestReplayCounter_Fresh(t *testing.T) {
+	a := assert.New(t)
+
+	// 首次上传
+	{
+		c := replayCounter{}
+		a.Equal(3, c.fresh(3))
+		a.Equal(2, c.fresh(2))
+	}
+
+	// 重试时跳过此前已读取的部分
+	{
+		c := replayCounter{skip: 4}
+		a.Equal(0, c.fresh(3))
+		a.Equal(2, c.fresh(3))
+		a.Equal(3, c.fresh(3))
+	}
+}
+
+func TestFileSystem_ListUploadSessions(t *testing.T) {
+	a := assert.New(t)
+	fs := FileSystem{
+		User: &model.User{Model: gorm.Model{ID: 1}},
+	}
+
+	cache.Set(UploadSessionCachePrefix+"TestFileSystem_ListUploadSessions", serializer.UploadSession{
+		Key:         "TestFileSystem_ListUploadSessions",
+		UID:         1,
+		Name:        "1.txt",
+		VirtualPath: "/dir",
+		Size:        10,
+		Policy:      model.Policy{Name: "local"},
+		Expires:     100,
+	}, 0)
+	mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "size", "upload_session_id"}).
+			AddRow(1, 5, "TestFileSystem_ListUploadSessions").
+			AddRow(2, 0, "TestFileSystem_ListUploadSessions_expired"),
+	)
+	sessions := fs.ListUploadSessions()
+	a.NoError(mock.ExpectationsWereMet())
+	a.Equal([]serializer.UploadSessionItem{{
+		SessionID: "TestFileSystem_ListUploadSessions",
+		Name:      "1.txt",
+		Path:      "/dir",
+		Size:      10,
+		Uploaded:  5,
+		Policy:    "local",
+		Expires:   100,
+	}}, sessions)
+}
+
+func TestUploadSessionFromMetadata(t *testing.T) {
+	a := assert.New(t)
+	file := &model.File{
+		Name:   "1.txt",
+		UserID: 1,
+		Policy: model.Policy{Model: gorm.Model{ID: 1}, Type: "oss"},
+	}
+
+	// 无会话信息
+	{
+		file.MetadataSerialized = map[string]string{}
+		_, ok := uploadSessionFromMetadata(file)
+		a.False(ok)
+	}
+
+	// 会话信息格式错误
+	{
+		file.MetadataSerialized = map[string]string{UploadSessionMetaKey: "{"}
+		_, ok := uploadSessionFromMetadata(file)
+		a.False(ok)
+	}
+
+	// 成功
+	{
+		file.MetadataSerialized = map[string]string{
+			UploadSessionMetaKey: `{"key":"session","save_path":"1/1.txt","upload_id":"upload","expires":1}`,
+		}
+		session, ok := uploadSessionFromMetadata(file)
+		a.True(ok)
+		a.Equal("session", session.Key)
+		a.Equal("1/1.txt", session.SavePath)
+		a.Equal("upload", session.UploadID)
+		a.EqualValues(1, session.UID)
+		a.Equal("oss", session.Policy.Type)
+	}
+}
@@ -1,7 +1,9 @@
 package filesystem
 
 import (
+	"bytes"
 	"context"
+	"net/http"
 	"strings"
 
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
@@ -15,6 +17,44 @@ import (
 // 文件/路径名保留字符
 var reservedCharacter = []string{"\\", "?", "*", "<", "\"", ":", ">", "/", "|"}
 
+// 可执行文件等 http.DetectContentType 无法识别的文件头
+var magicNumbers = []struct {
+	magic    []byte
+	mimeType string
+}{
+	{[]byte("MZ"), "application/vnd.microsoft.portable-executable"},
+	{[]byte("\x7fELF"), "application/x-elf"},
+	{[]byte("\xfe\xed\xfa\xce"), "application/x-mach-binary"},
+	{[]byte("\xfe\xed\xfa\xcf"), "application/x-mach-binary"},
+	{[]byte("\xce\xfa\xed\xfe"), "application/x-mach-binary"},
+	{[]byte("\xcf\xfa\xed\xfe"), "application/x-mach-binary"},
+	{[]byte("\xca\xfe\xba\xbe"), "application/java-vm"},
+	{[]byte("\x00asm"), "application/wasm"},
+	{[]byte("#!"), "text/x-shellscript"},
+}
+
+// 可识别的文件类型对应的扩展名，不在此列表中的类型不做校验
+var contentTypeExtensions = map[string][]string{
+	"application/vnd.microsoft.portable-executable": {"exe", "dll", "sys", "scr", "com", "cpl", "ocx", "efi"},
+	"application/x-elf":                             {"so", "elf", "bin", "out", "o", "ko"},
+	"application/x-mach-binary":                     {"dylib", "bundle", "bin", "o"},
+	"application/java-vm":                           {"class", "dylib", "bin"},
+	"application/wasm":                              {"wasm"},
+	"text/x-shellscript":                            {"sh", "bash", "zsh", "py", "pl", "rb", "php"},
+	"image/jpeg":                                    {"jpg", "jpeg", "jpe", "jfif"},
+	"image/png":                                     {"png", "apng"},
+	"image/gif":                                     {"gif"},
+	"image/webp":                                    {"webp"},
+	"image/bmp":                                     {"bmp"},
+	"image/x-icon":                                  {"ico", "cur"},
+	"application/pdf":                               {"pdf"},
+	"audio/mpeg":                                    {"mp3"},
+	"audio/wave":                                    {"wav"},
+	"video/webm":                                    {"webm", "mkv"},
+	"video/avi":                                     {"avi"},
+	"video/mp4":                                     {"mp4", "m4v", "m4a", "m4b", "mov", "3gp", "heic", "heif", "avif"},
+}
+
 // ValidateLegalName 验证文件名/文件夹名是否合法
 func (fs *FileSystem) ValidateLegalName(ctx context.Context, name string) bool {
 	// 是否包含保留字符
@@ -55,6 +95,36 @@ func (fs *FileSystem) ValidateCapacity(ctx context.Context, size uint64) bool {
 	return fs.User.IncreaseStorage(size)
 }
 
+// ValidateContentType 根据文件头部内容验证真实类型是否在允许的扩展名内
+func (fs *FileSystem) ValidateContentType(ctx context.Context, head []byte) bool {
+	// 不需要验证
+	if len(fs.Policy.OptionsSerialized.FileType) == 0 {
+		return true
+	}
+
+	mimeType := http.DetectContentType(head)
+	for _, magic := range magicNumbers {
+		if bytes.HasPrefix(head, magic.magic) {
+			mimeType = magic.mimeType
+			break
+		}
+	}
+
+	// 无法确定真实类型
+	exts, ok := contentTypeExtensions[mimeType]
+	if !ok {
+		return true
+	}
+
+	for _, ext := range exts {
+		if util.ContainsString(fs.Policy.OptionsSerialized.FileType, ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // ValidateExtension 验证文件扩展名
 func (fs *FileSystem) ValidateExtension(ctx context.Context, fileName string) bool {
 	// 不需要验证
@@ -110,3 +110,35 @@ func TestFileSystem_ValidateExtension(t *testing.T) {
 	asserts.True(fs.ValidateExtension(ctx, "1.png.jpG"))
 	asserts.False(fs.ValidateExtension(ctx, "1.png"))
 }
+
+func TestFileSystem_ValidateContentType(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	fs := FileSystem{
+		User: &model.User{},
+		Policy: &model.Policy{
+			OptionsSerialized: model.PolicyOption{
+				FileType: nil,
+			},
+		},
+	}
+
+	// 无扩展名限制
+	asserts.True(fs.ValidateContentType(ctx, []byte("MZ\x90\x00")))
+
+	fs.Policy.OptionsSerialized.FileType = []string{"txt", "jpg"}
+	// 改名的可执行文件
+	asserts.False(fs.ValidateContentType(ctx, []byte("MZ\x90\x00")))
+	asserts.False(fs.ValidateContentType(ctx, []byte("\x7fELF\x02\x01")))
+	asserts.False(fs.ValidateContentType(ctx, []byte("#!/bin/sh\nrm -rf /")))
+	// 类型不符的图片
+	asserts.False(fs.ValidateContentType(ctx, []byte("\x89PNG\x0D\x0A\x1A\x0A")))
+	// 允许的类型
+	asserts.True(fs.ValidateContentType(ctx, []byte("\xFF\xD8\xFF\xE0")))
+	// 无法确定的类型
+	asserts.True(fs.ValidateContentType(ctx, []byte("hello world")))
+	asserts.True(fs.ValidateContentType(ctx, []byte{}))
+
+	fs.Policy.OptionsSerialized.FileType = []string{"exe"}
+	asserts.True(fs.ValidateContentType(ctx, []byte("MZ\x90\x00")))
+}
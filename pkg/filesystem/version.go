@@ -0,0 +1,222 @@
+package filesystem
+
+import (
+	"context"
+	"path"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+/* ================
+	 历史版本相关
+   ================
+*/
+
+// ShouldKeepVersion 返回覆盖文件时是否需要保留原内容作为历史版本
+func ShouldKeepVersion(file *model.File) bool {
+	return file.Size > 0 && file.GetPolicy().OptionsSerialized.MaxVersions > 0
+}
+
+// HookGenerateVersionPath 覆盖需保留历史版本的文件时，将新内容写入新的存储路径，
+// 原文件在上传完成前保持不变。需在 HookResetPolicy 之后执行
+func HookGenerateVersionPath(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+	originFile, ok := ctx.Value(fsctx.FileModelCtx).(model.File)
+	if !ok {
+		return ErrObjectNotExist
+	}
+
+	if !ShouldKeepVersion(&originFile) || file.Info().SavePath != "" {
+		return nil
+	}
+
+	// 历史版本继续占用容量，新内容需完整计入
+	if err := HookValidateCapacity(ctx, fs, file); err != nil {
+		return err
+	}
+
+	// 未开启自动重命名等情况下生成的路径可能与原路径相同，为文件名添加随机前缀
+	savePath := fs.GenerateSavePath(ctx, file)
+	if savePath == originFile.SourceName {
+		savePath = path.Join(path.Dir(savePath), util.RandStringRunes(8)+"_"+path.Base(savePath))
+	}
+
+	file.SetSavePath(savePath)
+	return nil
+}
+
+// HookDeleteVersionTempFile 上传失败时删除写入新存储路径的内容
+func HookDeleteVersionTempFile(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+	originFile, ok := ctx.Value(fsctx.FileModelCtx).(model.File)
+	if !ok {
+		return ErrObjectNotExist
+	}
+
+	if savePath := file.Info().SavePath; savePath == "" || savePath == originFile.SourceName {
+		return nil
+	}
+
+	return HookDeleteTempFile(ctx, fs, file)
+}
+
+// HookSaveVersion 上传完成后将被覆盖的原内容保存为历史版本，并按存储策略的限制清理旧版本。
+// 此时文件已指向新内容，保存失败不再回滚上传
+func HookSaveVersion(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+	originFile, ok := ctx.Value(fsctx.FileModelCtx).(model.File)
+	if !ok {
+		return ErrObjectNotExist
+	}
+
+	return fs.saveVersion(ctx, &originFile, file)
+}
+
+// HookSaveSharedVersion 覆盖与其他文件共用物理文件的文件时，新内容已写入新的存储路径，
+// 上传完成后将原存储路径 sourceName 的内容保存为历史版本
+func HookSaveSharedVersion(sourceName string) Hook {
+	return func(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+		originFile, ok := ctx.Value(fsctx.FileModelCtx).(model.File)
+		if !ok {
+			return ErrObjectNotExist
+		}
+
+		originFile.SourceName = sourceName
+		return fs.saveVersion(ctx, &originFile, file)
+	}
+}
+
+// saveVersion 将 originFile 被覆盖前的内容保存为历史版本，并清理超出限制的旧版本
+func (fs *FileSystem) saveVersion(ctx context.Context, originFile *model.File, file fsctx.FileHeader) error {
+	if !ShouldKeepVersion(originFile) || file.Info().SavePath == originFile.SourceName {
+		return nil
+	}
+
+	if err := model.NewFileVersion(originFile).Create(); err != nil {
+		util.Log().Warning("Failed to save version of file %q: %s", originFile.Name, err)
+		return nil
+	}
+
+	if err := fs.pruneVersions(ctx, originFile); err != nil {
+		util.Log().Warning("Failed to prune versions of file %q: %s", originFile.Name, err)
+	}
+
+	return nil
+}
+
+// pruneVersions 按存储策略限制的版本数量和总大小删除文件最旧的历史版本
+func (fs *FileSystem) pruneVersions(ctx context.Context, file *model.File) error {
+	versions, err := model.GetVersionsByFileID(file.ID, file.UserID)
+	if err != nil {
+		return err
+	}
+
+	options := file.GetPolicy().OptionsSerialized
+	expired := make([]model.FileVersion, 0)
+	var total uint64
+	for i := range versions {
+		total += versions[i].Size
+		if uint(i) >= options.MaxVersions || (options.MaxVersionSize > 0 && total > options.MaxVersionSize) {
+			expired = append(expired, versions[i])
+		}
+	}
+
+	return fs.DeleteVersions(ctx, expired)
+}
+
+// DeleteVersions 删除历史版本，及不再被引用的物理文件
+func (fs *FileSystem) DeleteVersions(ctx context.Context, versions []model.FileVersion) error {
+	if len(versions) == 0 {
+		return nil
+	}
+
+	if err := model.DeleteVersions(versions); err != nil {
+		return ErrDBDeleteObjects.WithError(err)
+	}
+
+	// 按存储策略分组待删除的物理文件
+	sources := make(map[uint][]string)
+	for _, version := range versions {
+		sources[version.PolicyID] = append(sources[version.PolicyID], version.SourceName)
+	}
+
+	fs.deleteUnreferencedSources(ctx, sources)
+	return nil
+}
+
+// deleteUnreferencedSources 删除不再被文件或历史版本引用的物理文件，sources 按存储策略 ID 分组
+func (fs *FileSystem) deleteUnreferencedSources(ctx context.Context, sources map[uint][]string) {
+	for policyID, names := range sources {
+		unreferenced := make([]string, 0, len(names))
+		for _, name := range names {
+			if util.ContainsString(unreferenced, name) {
+				continue
+			}
+
+			if referenced, err := model.IsSourceReferenced(policyID, name); err != nil || referenced {
+				continue
+			}
+
+			unreferenced = append(unreferenced, name)
+		}
+		sources[policyID] = unreferenced
+	}
+
+	// 删除完成后恢复原有存储策略
+	originPolicy, originHandler := fs.Policy, fs.Handler
+	defer func() {
+		fs.Policy, fs.Handler = originPolicy, originHandler
+	}()
+
+	for policyID, names := range sources {
+		if len(names) == 0 {
+			continue
+		}
+
+		policy, err := model.GetPolicyByID(policyID)
+		if err != nil {
+			util.Log().Warning("Failed to get policy %d of versions: %s", policyID, err)
+			continue
+		}
+
+		fs.Policy = &policy
+		if err := fs.DispatchHandler(); err != nil {
+			util.Log().Warning("Failed to dispatch policy %d of versions: %s", policyID, err)
+			continue
+		}
+
+		if failed, err := fs.Handler.Delete(ctx, names); err != nil {
+			util.Log().Warning("Failed to delete %d version file(s): %s", len(failed), err)
+		}
+	}
+}
+
+// deleteFileVersions 删除文件的全部历史版本
+func (fs *FileSystem) deleteFileVersions(ctx context.Context, fileIDs []uint) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	versions, err := model.GetVersionsByFileIDs(fileIDs)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	return fs.DeleteVersions(ctx, versions)
+}
+
+// RestoreVersion 将文件内容恢复为指定的历史版本，文件的当前内容保存为新的历史版本
+func (fs *FileSystem) RestoreVersion(ctx context.Context, file *model.File, version *model.FileVersion) error {
+	if file.IsRetained() {
+		return ErrFileRetained
+	}
+
+	// 当前内容即为所恢复的版本时无需保留，否则无论是否被其他文件引用均保存为历史版本
+	keepCurrent := file.SourceName != version.SourceName || file.PolicyID != version.PolicyID
+
+	if err := version.Restore(file, keepCurrent); err != nil {
+		return ErrDBRestoreObjects.WithError(err)
+	}
+
+	RecordChanges(fs.fileChange(model.ChangeModified, &fsctx.UploadTaskInfo{}, file)...)
+	return nil
+}
@@ -0,0 +1,236 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+)
+
+func newVersionedFile(maxVersions uint, maxSize uint64) model.File {
+	file := model.File{
+		Name:       "1.txt",
+		SourceName: "1.txt",
+		UserID:     1,
+		Size:       10,
+		PolicyID:   1,
+		Policy: model.Policy{
+			Model:             gorm.Model{ID: 1},
+			OptionsSerialized: model.PolicyOption{MaxVersions: maxVersions, MaxVersionSize: maxSize},
+		},
+	}
+	file.ID = 2
+	return file
+}
+
+func TestShouldKeepVersion(t *testing.T) {
+	a := assert.New(t)
+
+	file := newVersionedFile(2, 0)
+	a.True(ShouldKeepVersion(&file))
+
+	file.Size = 0
+	a.False(ShouldKeepVersion(&file))
+
+	file = newVersionedFile(0, 0)
+	a.False(ShouldKeepVersion(&file))
+}
+
+func TestHookGenerateVersionPath(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{
+		User:   &model.User{Model: gorm.Model{ID: 1}, Group: model.Group{MaxStorage: 20}},
+		Policy: &model.Policy{DirNameRule: "uploads", AutoRename: true, FileNameRule: "new_{originname}"},
+	}
+
+	// 上下文中无文件
+	{
+		a.ErrorIs(HookGenerateVersionPath(context.Background(), fs, &fsctx.FileStream{}), ErrObjectNotExist)
+	}
+
+	// 未开启历史版本
+	{
+		ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, newVersionedFile(0, 0))
+		file := &fsctx.FileStream{Name: "1.txt", Size: 5}
+		a.NoError(HookGenerateVersionPath(ctx, fs, file))
+		a.Empty(file.SavePath)
+	}
+
+	// 容量不足
+	{
+		ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, newVersionedFile(2, 0))
+		file := &fsctx.FileStream{Name: "1.txt", Size: 30}
+		a.ErrorIs(HookGenerateVersionPath(ctx, fs, file), ErrInsufficientCapacity)
+	}
+
+	// 写入新路径
+	{
+		ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, newVersionedFile(2, 0))
+		file := &fsctx.FileStream{Name: "1.txt", Size: 5}
+		a.NoError(HookGenerateVersionPath(ctx, fs, file))
+		a.Equal("uploads/new_1.txt", file.SavePath)
+	}
+
+	// 生成的路径与原路径相同
+	{
+		fs.Policy = &model.Policy{}
+		ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, newVersionedFile(2, 0))
+		file := &fsctx.FileStream{Name: "1.txt", Size: 5}
+		a.NoError(HookGenerateVersionPath(ctx, fs, file))
+		a.NotEqual("1.txt", file.SavePath)
+		a.True(strings.HasSuffix(file.SavePath, "_1.txt"))
+	}
+}
+
+func TestHookDeleteVersionTempFile(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, newVersionedFile(2, 0))
+
+	// 仍为原路径，不删除
+	{
+		mockHandler := &FileHeaderMock{}
+		fs := &FileSystem{Handler: mockHandler}
+		a.NoError(HookDeleteVersionTempFile(ctx, fs, &fsctx.FileStream{SavePath: "1.txt"}))
+		mockHandler.AssertNotCalled(t, "Delete", testMock.Anything, testMock.Anything)
+	}
+
+	// 删除新路径中的内容
+	{
+		mockHandler := &FileHeaderMock{}
+		mockHandler.On("Delete", testMock.Anything, []string{"new.txt"}).Return([]string{}, nil)
+		fs := &FileSystem{Handler: mockHandler}
+		a.NoError(HookDeleteVersionTempFile(ctx, fs, &fsctx.FileStream{SavePath: "new.txt"}))
+		mockHandler.AssertExpectations(t)
+	}
+}
+
+func TestHookSaveVersion(t *testing.T) {
+	a := assert.New(t)
+
+	// 原地覆盖，不保存版本
+	{
+		ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, newVersionedFile(2, 0))
+		a.NoError(HookSaveVersion(ctx, &FileSystem{}, &fsctx.FileStream{SavePath: "1.txt"}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 保存失败时不影响上传结果
+	{
+		ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, newVersionedFile(2, 0))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.NoError(HookSaveVersion(ctx, &FileSystem{}, &fsctx.FileStream{SavePath: "new.txt"}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 保存后按数量清理最旧的版本
+	{
+		ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, newVersionedFile(1, 0))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(3, 1))
+		mock.ExpectExec("UPDATE(.+)users(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name", "size"}).
+				AddRow(3, 1, "1.txt", 10).
+				AddRow(1, 1, "0.txt", 10))
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)file_versions(.+)").WithArgs(1).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)users(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT count(.+)files(.+)").WithArgs(1, "0.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery("SELECT count(.+)file_versions(.+)").WithArgs(1, "0.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		a.NoError(HookSaveVersion(ctx, &FileSystem{}, &fsctx.FileStream{SavePath: "new.txt"}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestHookSaveSharedVersion(t *testing.T) {
+	a := assert.New(t)
+
+	// 覆盖有软链接的文件，新内容写入新路径，原路径保存为历史版本
+	{
+		file := newVersionedFile(2, 0)
+		file.SourceName = "new.txt"
+		ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, file)
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 2, 1, 1, "1.txt", 10, "", "", "").
+			WillReturnResult(sqlmock.NewResult(3, 1))
+		mock.ExpectExec("UPDATE(.+)users(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name", "size"}).AddRow(3, 1, "1.txt", 10))
+		a.NoError(HookSaveSharedVersion("1.txt")(ctx, &FileSystem{}, &fsctx.FileStream{SavePath: "new.txt"}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 未开启历史版本，不保存
+	{
+		file := newVersionedFile(0, 0)
+		file.SourceName = "new.txt"
+		ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, file)
+		a.NoError(HookSaveSharedVersion("1.txt")(ctx, &FileSystem{}, &fsctx.FileStream{SavePath: "new.txt"}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 上下文中无文件
+	{
+		a.Equal(ErrObjectNotExist, HookSaveSharedVersion("1.txt")(context.Background(), &FileSystem{}, &fsctx.FileStream{}))
+	}
+}
+
+func TestFileSystem_pruneVersions(t *testing.T) {
+	a := assert.New(t)
+	file := newVersionedFile(5, 25)
+	fs := &FileSystem{}
+
+	// 超出总大小的旧版本被删除
+	mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name", "size"}).
+			AddRow(3, 1, "3.txt", 10).
+			AddRow(2, 1, "2.txt", 10).
+			AddRow(1, 1, "1.txt", 10))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)file_versions(.+)").WithArgs(1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE(.+)users(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT count(.+)files(.+)").WithArgs(1, "1.txt").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	a.NoError(fs.pruneVersions(context.Background(), &file))
+	a.NoError(mock.ExpectationsWereMet())
+}
+
+func TestFileSystem_RestoreVersion(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{}
+	version := &model.FileVersion{FileID: 2, UserID: 1, PolicyID: 1, SourceName: "old.txt", Size: 10}
+
+	// 处于保留期内
+	{
+		file := newVersionedFile(2, 0)
+		file.Policy.OptionsSerialized.RetentionDays = 1
+		file.CreatedAt = file.CreatedAt.AddDate(3000, 0, 0)
+		a.ErrorIs(fs.RestoreVersion(context.Background(), &file, version), ErrFileRetained)
+	}
+
+	// 数据库操作失败
+	{
+		file := newVersionedFile(2, 0)
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.ErrorIs(fs.RestoreVersion(context.Background(), &file, version), ErrDBRestoreObjects)
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
@@ -0,0 +1,44 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/watermark"
+)
+
+// watermarkedContent 添加水印后的文件内容
+type watermarkedContent struct {
+	*bytes.Reader
+}
+
+func (c watermarkedContent) Close() error {
+	return nil
+}
+
+// previewWatermarked 读取当前目标文件，添加文字为 text 的水印后返回，
+// 处理失败时不回退至原始文件
+func (fs *FileSystem) previewWatermarked(ctx context.Context, text string) (*response.ContentResponse, error) {
+	file := &fs.FileTarget[0]
+	if file.Size > uint64(model.GetIntSetting("share_watermark_max_size", 20<<20)) {
+		return nil, ErrFileSizeTooBig
+	}
+
+	rs, err := fs.GetContent(ctx, file.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	var buf bytes.Buffer
+	if err := watermark.NewWatermark(text).Apply(ctx, &buf, rs, file.Name); err != nil {
+		return nil, ErrWatermarkFailed.WithError(err)
+	}
+
+	return &response.ContentResponse{
+		Redirect: false,
+		Content:  watermarkedContent{bytes.NewReader(buf.Bytes())},
+	}, nil
+}
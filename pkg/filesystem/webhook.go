@@ -0,0 +1,176 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+/* ================
+	 上传 Webhook
+   ================
+*/
+
+const (
+	// WebhookBeforeUpload 开始上传前
+	WebhookBeforeUpload = "before_upload"
+	// WebhookAfterUpload 文件内容上传完成，创建文件记录前
+	WebhookAfterUpload = "after_upload"
+
+	// webhookSignatureHeader 请求正文 HMAC-SHA256 签名所在的请求头
+	webhookSignatureHeader = "X-Cloudreve-Signature"
+)
+
+// UploadWebhookPayload 发送给上传 Webhook 的请求正文
+type UploadWebhookPayload struct {
+	Event string            `json:"event"`
+	User  UploadWebhookUser `json:"user"`
+	File  UploadWebhookFile `json:"file"`
+}
+
+// UploadWebhookUser 上传文件的用户
+type UploadWebhookUser struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+	Group string `json:"group"`
+}
+
+// UploadWebhookFile 上传的文件
+type UploadWebhookFile struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Size     uint64 `json:"size"`
+	MimeType string `json:"mime_type"`
+	Policy   string `json:"policy"`
+	SavePath string `json:"save_path"`
+}
+
+// UploadWebhookResponse 上传 Webhook 的响应，Allow 为 false 时拒绝上传，Msg 为返回给用户的原因
+type UploadWebhookResponse struct {
+	Allow *bool  `json:"allow"`
+	Msg   string `json:"msg"`
+}
+
+// HookUploadWebhook 将上传事件发送至管理员设定的 Webhook。开启阻塞模式时等待全部 Webhook 响应，
+// 任一 Webhook 请求失败或拒绝时中止上传；否则在后台发送，忽略响应。
+func HookUploadWebhook(event string) Hook {
+	return func(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+		settings := model.GetSettingByNames(
+			"upload_webhook_urls",
+			"upload_webhook_secret",
+			"upload_webhook_blocking",
+		)
+
+		urls := webhookURLs(settings["upload_webhook_urls"])
+		if len(urls) == 0 {
+			return nil
+		}
+
+		fileInfo := file.Info()
+		payload := UploadWebhookPayload{
+			Event: event,
+			User: UploadWebhookUser{
+				ID:    hashid.HashID(fs.User.ID, hashid.UserID),
+				Email: fs.User.Email,
+				Group: fs.User.Group.Name,
+			},
+			File: UploadWebhookFile{
+				Name:     fileInfo.FileName,
+				Path:     path.Join(fileInfo.VirtualPath, fileInfo.FileName),
+				Size:     fileInfo.Size,
+				MimeType: fileInfo.MimeType,
+				Policy:   fs.Policy.Name,
+				SavePath: fileInfo.SavePath,
+			},
+		}
+
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return ErrUploadWebhookFailed.WithError(err)
+		}
+
+		secret := settings["upload_webhook_secret"]
+		if !model.IsTrueVal(settings["upload_webhook_blocking"]) {
+			go func() {
+				for _, url := range urls {
+					if err := sendUploadWebhook(url, secret, body); err != nil {
+						util.Log().Warning("Failed to send upload webhook to %q: %s", url, err)
+					}
+				}
+			}()
+			return nil
+		}
+
+		for _, url := range urls {
+			if err := sendUploadWebhook(url, secret, body); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// webhookURLs 解析设置中每行一个的 Webhook 地址
+func webhookURLs(raw string) []string {
+	var urls []string
+	for _, url := range strings.Split(raw, "\n") {
+		if url = strings.TrimSpace(url); url != "" {
+			urls = append(urls, url)
+		}
+	}
+
+	return urls
+}
+
+// signUploadWebhook 计算请求正文的 HMAC-SHA256 签名
+func signUploadWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// sendUploadWebhook 发送请求正文至 url，设定了密钥时附带签名，Webhook 拒绝时返回 ErrUploadRejected
+func sendUploadWebhook(url, secret string, body []byte) error {
+	header := http.Header{"Content-Type": {"application/json"}}
+	if secret != "" {
+		header.Set(webhookSignatureHeader, signUploadWebhook(secret, body))
+	}
+
+	timeout := time.Duration(model.GetIntSetting("upload_webhook_timeout", 10)) * time.Second
+	res, err := request.GeneralClient.Request(
+		"POST",
+		url,
+		bytes.NewReader(body),
+		request.WithHeader(header),
+		request.WithTimeout(timeout),
+	).CheckHTTPResponse(200).GetResponse()
+	if err != nil {
+		return ErrUploadWebhookFailed.WithError(err)
+	}
+
+	var resp UploadWebhookResponse
+	if err := json.Unmarshal([]byte(res), &resp); err != nil || resp.Allow == nil || *resp.Allow {
+		return nil
+	}
+
+	if resp.Msg != "" {
+		return serializer.NewError(serializer.CodeUploadRejected, resp.Msg, nil)
+	}
+
+	return ErrUploadRejected
+}
@@ -0,0 +1,110 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/mocks/requestmock"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+)
+
+func webhookResponse(status int, body string) *request.Response {
+	return &request.Response{
+		Response: &http.Response{
+			StatusCode: status,
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		},
+	}
+}
+
+func TestWebhookURLs(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.Empty(webhookURLs(""))
+	asserts.Equal([]string{"http://a", "http://b"}, webhookURLs(" http://a\r\n\nhttp://b\n"))
+}
+
+func TestHookUploadWebhook(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	fs := &FileSystem{
+		User:   &model.User{Model: gorm.Model{ID: 1}},
+		Policy: &model.Policy{Name: "local"},
+	}
+	file := &fsctx.FileStream{Name: "1.txt", VirtualPath: "/dir", Size: 5}
+	hook := HookUploadWebhook(WebhookBeforeUpload)
+	t.Cleanup(func() {
+		cache.Set("setting_upload_webhook_urls", "", 0)
+	})
+
+	// 未设定 Webhook
+	cache.Set("setting_upload_webhook_urls", "", 0)
+	asserts.NoError(hook(ctx, fs, file))
+
+	cache.Set("setting_upload_webhook_urls", "http://a\nhttp://b", 0)
+	cache.Set("setting_upload_webhook_secret", "secret", 0)
+	cache.Set("setting_upload_webhook_blocking", "1", 0)
+
+	// 全部允许
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "POST", "http://a", testMock.Anything, testMock.Anything).Return(webhookResponse(200, ""))
+		clientMock.On("Request", "POST", "http://b", testMock.Anything, testMock.Anything).Return(webhookResponse(200, `{"allow":true}`))
+		request.GeneralClient = clientMock
+		asserts.NoError(hook(ctx, fs, file))
+		clientMock.AssertExpectations(t)
+	}
+
+	// 拒绝上传
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "POST", "http://a", testMock.Anything, testMock.Anything).Return(webhookResponse(200, `{"allow":false,"msg":"sensitive"}`))
+		request.GeneralClient = clientMock
+		err := hook(ctx, fs, file)
+		clientMock.AssertExpectations(t)
+		asserts.Equal(serializer.CodeUploadRejected, err.(serializer.AppError).Code)
+		asserts.Equal("sensitive", err.(serializer.AppError).Msg)
+	}
+
+	// 请求失败
+	{
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "POST", "http://a", testMock.Anything, testMock.Anything).Return(&request.Response{Err: errors.New("error")})
+		request.GeneralClient = clientMock
+		err := hook(ctx, fs, file)
+		clientMock.AssertExpectations(t)
+		asserts.Equal(serializer.CodeUploadWebhookFailed, err.(serializer.AppError).Code)
+	}
+
+	// 非阻塞模式下忽略响应
+	{
+		cache.Set("setting_upload_webhook_blocking", "0", 0)
+		done := make(chan struct{})
+		clientMock := &requestmock.RequestMock{}
+		clientMock.On("Request", "POST", "http://a", testMock.Anything, testMock.Anything).Return(webhookResponse(200, `{"allow":false}`))
+		clientMock.On("Request", "POST", "http://b", testMock.Anything, testMock.Anything).Run(func(args testMock.Arguments) {
+			close(done)
+		}).Return(webhookResponse(500, ""))
+		request.GeneralClient = clientMock
+		asserts.NoError(hook(ctx, fs, file))
+		<-done
+	}
+}
+
+func TestSignUploadWebhook(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.Equal(
+		"sha256=77325902caca812dc259733aacd046b73817372c777b8d95b402647474516e13",
+		signUploadWebhook("secret", []byte("{}")),
+	)
+}
@@ -16,6 +16,11 @@ const (
 	TagID           // 标签ID
 	PolicyID        // 存储策略ID
 	SourceLinkID
+	TrashID          // 回收站对象ID
+	VersionID        // 文件历史版本ID
+	FolderTemplateID // 目录模板ID
+	CommentID        // 文件评论ID
+	NotificationID   // 站内通知ID
 )
 
 var (
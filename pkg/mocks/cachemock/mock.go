@@ -35,3 +35,8 @@ func (c CacheClientMock) Persist(path string) error {
 func (c CacheClientMock) Restore(path string) error {
 	return c.Called(path).Error(0)
 }
+
+func (c CacheClientMock) IncrBy(key string, delta int64, ttl int) (int64, error) {
+	args := c.Called(key, delta, ttl)
+	return args.Get(0).(int64), args.Error(1)
+}
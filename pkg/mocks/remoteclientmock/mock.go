@@ -5,6 +5,7 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/stretchr/testify/mock"
+	"io"
 )
 
 type RemoteClientMock struct {
@@ -26,6 +27,21 @@ func (r *RemoteClientMock) Upload(ctx context.Context, file fsctx.FileHeader) er
 	return args.Error(0)
 }
 
+func (r *RemoteClientMock) UploadAt(ctx context.Context, sessionID string, offset uint64, content io.Reader, size int64, checksum string) error {
+	args := r.Called(ctx, sessionID, offset, content, size, checksum)
+	return args.Error(0)
+}
+
+func (r *RemoteClientMock) Ping(ctx context.Context) error {
+	args := r.Called(ctx)
+	return args.Error(0)
+}
+
+func (r *RemoteClientMock) Fetch(ctx context.Context, src string, file fsctx.FileHeader) error {
+	args := r.Called(ctx, src, file)
+	return args.Error(0)
+}
+
 func (r *RemoteClientMock) DeleteUploadSession(ctx context.Context, sessionID string) error {
 	args := r.Called(ctx, sessionID)
 	return args.Error(0)
@@ -116,6 +116,12 @@ func (i *inMemoryMQ) Unsubscribe(topic string, sub <-chan Message) {
 		newSubs = append(newSubs, subscriber)
 	}
 
+	// 没有订阅者时删除主题，避免已结束的任务残留在映射中
+	if len(newSubs) == 0 {
+		delete(i.topics, topic)
+		return
+	}
+
 	i.topics[topic] = newSubs
 }
 
@@ -101,6 +101,25 @@ func TestPublishAndSubscribe(t *testing.T) {
 	}
 }
 
+func TestInMemoryMQ_Unsubscribe(t *testing.T) {
+	asserts := assert.New(t)
+	mq := NewMQ().(*inMemoryMQ)
+
+	// 不存在的主题
+	mq.Unsubscribe("not exist", make(chan Message))
+	asserts.Empty(mq.topics)
+
+	// 仍有其他订阅者时保留主题
+	notifier := mq.Subscribe("topic", 0)
+	notifier2 := mq.Subscribe("topic", 0)
+	mq.Unsubscribe("topic", notifier)
+	asserts.Len(mq.topics["topic"], 1)
+
+	// 最后一个订阅者取消订阅后删除主题
+	mq.Unsubscribe("topic", notifier2)
+	asserts.NotContains(mq.topics, "topic")
+}
+
 func TestAria2Interface(t *testing.T) {
 	t.Parallel()
 	asserts := assert.New(t)
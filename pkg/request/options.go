@@ -27,6 +27,8 @@ type options struct {
 	tpsLimiterToken string
 	tps             float64
 	tpsBurst        int
+	transport       http.RoundTripper
+	noRedirect      bool
 }
 
 type optionFunc func(*options)
@@ -135,3 +137,19 @@ func WithTPSLimit(token string, tps float64, burst int) Option {
 		o.tpsBurst = burst
 	})
 }
+
+// WithProxy 通过代理发送请求，proxy 为空时使用系统代理设置
+func WithProxy(proxy string) Option {
+	return optionFunc(func(o *options) {
+		if proxy != "" {
+			o.transport = ProxyTransport(proxy)
+		}
+	})
+}
+
+// WithoutRedirect 不跟随重定向，直接返回 3xx 响应
+func WithoutRedirect() Option {
+	return optionFunc(func(o *options) {
+		o.noRedirect = true
+	})
+}
@@ -0,0 +1,148 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// sniffLen http.ServeContent 判断内容类型时读取的长度
+const sniffLen = 512
+
+// RangeRSCloser 可定位的远程文件流，读取位置变化后通过 Range 头重新请求。
+// 开头部分的内容会被保留，http.ServeContent 判断内容类型后 Seek 回开头时无需重新请求
+type RangeRSCloser struct {
+	open   func(header http.Header) *Response
+	body   io.ReadCloser
+	size   int64
+	offset int64
+	// bodyOffset 当前请求的读取位置
+	bodyOffset int64
+	// prefix 首个请求读取的开头部分，超过 sniffLen 后不再保留
+	prefix []byte
+}
+
+// NewRangeRSCloser 使用 open 发送首个请求，Seek 后再次调用 open 发送带有 Range 头的请求。
+// 文件大小默认为首个响应的 Content-Length
+func NewRangeRSCloser(open func(header http.Header) *Response) (*RangeRSCloser, error) {
+	resp := open(nil).CheckHTTPResponse(http.StatusOK)
+	if resp.Err != nil {
+		if resp.Response != nil {
+			resp.Response.Body.Close()
+		}
+		return nil, resp.Err
+	}
+
+	return &RangeRSCloser{
+		open:   open,
+		body:   resp.Response.Body,
+		size:   resp.Response.ContentLength,
+		prefix: []byte{},
+	}, nil
+}
+
+// SetContentLength 设置文件大小
+func (r *RangeRSCloser) SetContentLength(size int64) {
+	r.size = size
+}
+
+// reopen 从当前位置重新请求文件内容，服务端忽略 Range 头时跳过已读取的部分
+func (r *RangeRSCloser) reopen() error {
+	r.Close()
+	r.prefix = nil
+
+	var header http.Header
+	if r.offset > 0 {
+		header = http.Header{"Range": {fmt.Sprintf("bytes=%d-", r.offset)}}
+	}
+
+	resp := r.open(header)
+	if resp.Err != nil {
+		return resp.Err
+	}
+
+	body := resp.Response.Body
+	switch resp.Response.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		if _, err := io.CopyN(io.Discard, body, r.offset); err != nil {
+			body.Close()
+			return err
+		}
+	default:
+		body.Close()
+		return fmt.Errorf("服务器返回非正常HTTP状态%d", resp.Response.StatusCode)
+	}
+
+	r.body = body
+	r.bodyOffset = r.offset
+	return nil
+}
+
+// Read 读取文件内容
+func (r *RangeRSCloser) Read(p []byte) (int, error) {
+	if r.size >= 0 && r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	// 重放首个请求已读取的开头部分
+	if r.prefix != nil && r.offset < int64(len(r.prefix)) {
+		n := copy(p, r.prefix[r.offset:])
+		r.offset += int64(n)
+		return n, nil
+	}
+
+	if r.body == nil || r.bodyOffset != r.offset {
+		if err := r.reopen(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.body.Read(p)
+	if r.prefix != nil {
+		if r.bodyOffset+int64(n) <= sniffLen {
+			r.prefix = append(r.prefix, p[:n]...)
+		} else {
+			r.prefix = nil
+		}
+	}
+
+	r.bodyOffset += int64(n)
+	r.offset += int64(n)
+	return n, err
+}
+
+// Seek 设置读取位置，实际请求在下次读取时发送
+func (r *RangeRSCloser) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		if r.size < 0 {
+			return r.offset, errors.New("unknown content length")
+		}
+		offset += r.size
+	default:
+		return r.offset, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return r.offset, errors.New("negative position")
+	}
+
+	r.offset = offset
+	return offset, nil
+}
+
+// Close 关闭当前请求
+func (r *RangeRSCloser) Close() error {
+	if r.body == nil {
+		return nil
+	}
+
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
@@ -0,0 +1,114 @@
+package request
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newRangeServer(content string, ignoreRange bool) (*httptest.Server, *int) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/404" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if ignoreRange {
+			r.Header.Del("Range")
+		}
+		http.ServeContent(w, r, "a.bin", time.Time{}, strings.NewReader(content))
+	}))
+	return server, &requests
+}
+
+func openRange(target string) func(header http.Header) *Response {
+	return func(header http.Header) *Response {
+		return NewClient().Request("GET", target, nil, WithHeader(header))
+	}
+}
+
+func TestRangeRSCloser(t *testing.T) {
+	a := assert.New(t)
+	content := strings.Repeat("0123456789", 100)
+
+	for _, ignoreRange := range []bool{false, true} {
+		server, requests := newRangeServer(content, ignoreRange)
+
+		rs, err := NewRangeRSCloser(openRange(server.URL))
+		a.NoError(err)
+
+		size, err := rs.Seek(0, io.SeekEnd)
+		a.NoError(err)
+		a.EqualValues(1000, size)
+
+		// 读取开头后回到开头，不重新请求
+		_, err = rs.Seek(0, io.SeekStart)
+		a.NoError(err)
+		buf := make([]byte, sniffLen)
+		_, err = io.ReadFull(rs, buf)
+		a.NoError(err)
+		_, err = rs.Seek(0, io.SeekStart)
+		a.NoError(err)
+		all, err := io.ReadAll(rs)
+		a.NoError(err)
+		a.Equal(content, string(all))
+		a.Equal(1, *requests)
+
+		// 从中间位置读取
+		_, err = rs.Seek(995, io.SeekStart)
+		a.NoError(err)
+		all, err = io.ReadAll(rs)
+		a.NoError(err)
+		a.Equal("56789", string(all))
+		a.Equal(2, *requests)
+		a.NoError(rs.Close())
+
+		server.Close()
+	}
+
+	// 请求失败
+	server, _ := newRangeServer(content, false)
+	defer server.Close()
+	_, err := NewRangeRSCloser(openRange(server.URL + "/404"))
+	a.Error(err)
+
+	// 文件大小未知
+	rs, err := NewRangeRSCloser(func(header http.Header) *Response {
+		resp := openRange(server.URL)(header)
+		resp.Response.ContentLength = -1
+		return resp
+	})
+	a.NoError(err)
+	_, err = rs.Seek(0, io.SeekEnd)
+	a.Error(err)
+	rs.SetContentLength(1000)
+	size, err := rs.Seek(-1, io.SeekEnd)
+	a.NoError(err)
+	a.EqualValues(999, size)
+}
+
+func TestRangeRSCloser_ServeContent(t *testing.T) {
+	a := assert.New(t)
+	content := strings.Repeat("0123456789", 100)
+	upstream, _ := newRangeServer(content, false)
+	defer upstream.Close()
+
+	rs, err := NewRangeRSCloser(openRange(upstream.URL))
+	a.NoError(err)
+	defer rs.Close()
+
+	req := httptest.NewRequest("GET", "/a.bin", nil)
+	req.Header.Set("Range", "bytes=100-109")
+	w := httptest.NewRecorder()
+	http.ServeContent(w, req, "a.bin", time.Time{}, rs)
+	a.Equal(http.StatusPartialContent, w.Code)
+	a.Equal("bytes 100-109/1000", w.Header().Get("Content-Range"))
+	a.True(bytes.Equal([]byte("0123456789"), w.Body.Bytes()))
+}
@@ -63,7 +63,12 @@ func (c *HTTPClient) Request(method, target string, body io.Reader, opts ...Opti
 	}
 
 	// 创建请求客户端
-	client := &http.Client{Timeout: options.timeout}
+	client := &http.Client{Timeout: options.timeout, Transport: options.transport}
+	if options.noRedirect {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
 
 	// size为0时将body设为nil
 	if options.contentLength == 0 {
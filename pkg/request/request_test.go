@@ -7,6 +7,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -53,6 +54,27 @@ func TestWithContext(t *testing.T) {
 	asserts.NotNil(options.ctx)
 }
 
+func TestWithoutRedirect(t *testing.T) {
+	asserts := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/target", http.StatusTemporaryRedirect)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	resp := NewClient().Request("GET", server.URL+"/redirect", nil)
+	asserts.NoError(resp.Err)
+	asserts.Equal(http.StatusOK, resp.Response.StatusCode)
+
+	resp = NewClient().Request("GET", server.URL+"/redirect", nil, WithoutRedirect())
+	asserts.NoError(resp.Err)
+	asserts.Equal(http.StatusTemporaryRedirect, resp.Response.StatusCode)
+	asserts.Equal("/target", resp.Response.Header.Get("Location"))
+}
+
 func TestHTTPClient_Request(t *testing.T) {
 	asserts := assert.New(t)
 	client := NewClient(WithSlaveMeta("test"))
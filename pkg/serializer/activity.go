@@ -0,0 +1,45 @@
+package serializer
+
+import (
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+)
+
+// Activity 操作动态
+type Activity struct {
+	Type       string    `json:"type"`
+	ObjectID   string    `json:"object_id"`
+	ObjectType string    `json:"object_type"`
+	Name       string    `json:"name"`
+	Path       string    `json:"path,omitempty"`
+	Detail     string    `json:"detail,omitempty"`
+	Date       time.Time `json:"date"`
+}
+
+// BuildActivityList 构建操作动态列表响应
+func BuildActivityList(activities []model.Activity, total int) Response {
+	res := make([]Activity, 0, len(activities))
+	for _, activity := range activities {
+		item := Activity{
+			Type:       activity.Type,
+			ObjectID:   hashid.HashID(activity.ObjectID, hashid.FileID),
+			ObjectType: "file",
+			Name:       activity.Name,
+			Path:       activity.Path,
+			Detail:     activity.Detail,
+			Date:       activity.CreatedAt,
+		}
+		if activity.IsDir {
+			item.ObjectID = hashid.HashID(activity.ObjectID, hashid.FolderID)
+			item.ObjectType = "dir"
+		}
+		res = append(res, item)
+	}
+
+	return Response{Data: map[string]interface{}{
+		"total": total,
+		"items": res,
+	}}
+}
@@ -0,0 +1,29 @@
+package serializer
+
+import (
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildActivityList(t *testing.T) {
+	a := assert.New(t)
+	activities := []model.Activity{
+		{Type: model.ActivityUpload, ObjectID: 1, Name: "1.txt", Path: "/docs"},
+		{Type: model.ActivityMove, ObjectID: 2, IsDir: true, Name: "docs", Path: "/", Detail: "/archive"},
+	}
+
+	res := BuildActivityList(activities, 5)
+	data := res.Data.(map[string]interface{})
+	items := data["items"].([]Activity)
+	a.Equal(5, data["total"])
+	a.Len(items, 2)
+	a.Equal(hashid.HashID(1, hashid.FileID), items[0].ObjectID)
+	a.Equal("file", items[0].ObjectType)
+	a.Equal("/docs", items[0].Path)
+	a.Equal(hashid.HashID(2, hashid.FolderID), items[1].ObjectID)
+	a.Equal("dir", items[1].ObjectType)
+	a.Equal("/archive", items[1].Detail)
+}
@@ -0,0 +1,100 @@
+package serializer
+
+import (
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+)
+
+// Comment 文件评论
+type Comment struct {
+	ID      string      `json:"id"`
+	Content string      `json:"content"`
+	User    CommentUser `json:"user"`
+	Shared  bool        `json:"shared"` // 是否对分享的访客可见
+	Date    time.Time   `json:"date"`
+	Replies []Comment   `json:"replies,omitempty"`
+}
+
+// CommentUser 发表评论的用户
+type CommentUser struct {
+	ID   string `json:"id"`
+	Nick string `json:"nick"`
+}
+
+// Notification 站内通知
+type Notification struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Actor     CommentUser `json:"actor"`
+	FileID    string      `json:"file_id"`
+	FileName  string      `json:"file_name"`
+	ShareKey  string      `json:"share_key,omitempty"`
+	CommentID string      `json:"comment_id"`
+	Content   string      `json:"content"`
+	Read      bool        `json:"read"`
+	Date      time.Time   `json:"date"`
+}
+
+// BuildComment 序列化单条评论
+func BuildComment(comment *model.Comment) Comment {
+	return Comment{
+		ID:      hashid.HashID(comment.ID, hashid.CommentID),
+		Content: comment.Content,
+		User: CommentUser{
+			ID:   hashid.HashID(comment.UserID, hashid.UserID),
+			Nick: comment.User.Nick,
+		},
+		Shared: comment.ShareID != 0,
+		Date:   comment.CreatedAt,
+	}
+}
+
+// BuildCommentList 将按时间顺序排列的评论整理为评论串，回复归入所属的顶层评论
+func BuildCommentList(comments []model.Comment) Response {
+	res := make([]Comment, 0, len(comments))
+	threads := make(map[uint]int, len(comments))
+	for i := range comments {
+		item := BuildComment(&comments[i])
+		if index, ok := threads[comments[i].ParentID]; ok && comments[i].ParentID != 0 {
+			res[index].Replies = append(res[index].Replies, item)
+			continue
+		}
+
+		threads[comments[i].ID] = len(res)
+		res = append(res, item)
+	}
+
+	return Response{Data: res}
+}
+
+// BuildNotificationList 构建站内通知列表响应
+func BuildNotificationList(notifications []model.Notification, total int) Response {
+	res := make([]Notification, 0, len(notifications))
+	for _, notification := range notifications {
+		item := Notification{
+			ID:   hashid.HashID(notification.ID, hashid.NotificationID),
+			Type: notification.Type,
+			Actor: CommentUser{
+				ID:   hashid.HashID(notification.ActorID, hashid.UserID),
+				Nick: notification.Actor.Nick,
+			},
+			FileID:    hashid.HashID(notification.FileID, hashid.FileID),
+			FileName:  notification.FileName,
+			CommentID: hashid.HashID(notification.CommentID, hashid.CommentID),
+			Content:   notification.Content,
+			Read:      notification.ReadAt != nil,
+			Date:      notification.CreatedAt,
+		}
+		if notification.ShareID != 0 {
+			item.ShareKey = hashid.HashID(notification.ShareID, hashid.ShareID)
+		}
+		res = append(res, item)
+	}
+
+	return Response{Data: map[string]interface{}{
+		"total": total,
+		"items": res,
+	}}
+}
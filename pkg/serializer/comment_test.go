@@ -0,0 +1,55 @@
+package serializer
+
+import (
+	"testing"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildCommentList(t *testing.T) {
+	a := assert.New(t)
+	comments := []model.Comment{
+		{Model: gorm.Model{ID: 1}, UserID: 1, Content: "first", User: model.User{Nick: "a"}},
+		{Model: gorm.Model{ID: 2}, UserID: 2, Content: "second", ShareID: 3},
+		{Model: gorm.Model{ID: 3}, UserID: 2, ParentID: 1, Content: "reply"},
+		{Model: gorm.Model{ID: 4}, UserID: 1, ParentID: 2, Content: "reply to share", ShareID: 3},
+		// 顶层评论已被删除的回复
+		{Model: gorm.Model{ID: 5}, UserID: 1, ParentID: 9, Content: "orphan"},
+	}
+
+	res := BuildCommentList(comments).Data.([]Comment)
+	a.Len(res, 3)
+	a.Equal(hashid.HashID(1, hashid.CommentID), res[0].ID)
+	a.Equal("a", res[0].User.Nick)
+	a.False(res[0].Shared)
+	a.Len(res[0].Replies, 1)
+	a.Equal("reply", res[0].Replies[0].Content)
+	a.True(res[1].Shared)
+	a.Len(res[1].Replies, 1)
+	a.Equal("orphan", res[2].Content)
+}
+
+func TestBuildNotificationList(t *testing.T) {
+	a := assert.New(t)
+	now := time.Now()
+	notifications := []model.Notification{
+		{Model: gorm.Model{ID: 1}, Type: model.NotificationMention, ActorID: 2, FileID: 3, ShareID: 4, Actor: model.User{Nick: "a"}},
+		{Model: gorm.Model{ID: 2}, Type: model.NotificationComment, ActorID: 2, FileID: 3, ReadAt: &now},
+	}
+
+	res := BuildNotificationList(notifications, 5)
+	data := res.Data.(map[string]interface{})
+	items := data["items"].([]Notification)
+	a.Equal(5, data["total"])
+	a.Len(items, 2)
+	a.Equal(hashid.HashID(1, hashid.NotificationID), items[0].ID)
+	a.Equal(hashid.HashID(4, hashid.ShareID), items[0].ShareKey)
+	a.Equal("a", items[0].Actor.Nick)
+	a.False(items[0].Read)
+	a.Empty(items[1].ShareKey)
+	a.True(items[1].Read)
+}
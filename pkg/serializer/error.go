@@ -192,6 +192,44 @@ const (
 	CodeDisabledSharePreview = 40070
 	// 签名无效
 	CodeInvalidSign = 40071
+	// 校验和不匹配
+	CodeChecksumMismatch = 40072
+	// 文件包含病毒
+	CodeVirusDetected = 40073
+	// 分享未开启上传
+	CodeDisabledShareUpload = 40074
+	// 上传被 Webhook 拒绝
+	CodeUploadRejected = 40075
+	// 文件处于存储策略设定的保留期内
+	CodeFileRetained = 40076
+	// 文件处于归档存储中，需解冻后才能访问
+	CodeFileArchived = 40077
+	// 存储策略新凭证校验失败
+	CodePolicyCredentialInvalid = 40078
+	// 直链请求来源未被防盗链设置允许
+	CodeHotlinkBlocked = 40079
+	// 任务已结束或不支持取消
+	CodeTaskNotCancelable = 40080
+	// 文件已被其他客户端锁定
+	CodeFileLocked = 40081
+	// 文件自定义元数据超出数量或大小限制
+	CodeFilePropertyExceeded = 40082
+	// 游客向分享上传过于频繁
+	CodeShareUploadLimited = 40085
+	// 文件已被其他客户端修改，保存时提供的修订号已过期
+	CodeStaleRevision = 40086
+	// 增量同步的游标已过期，需重新完整同步
+	CodeChangeCursorExpired = 40087
+	// 尝试分享密码过于频繁
+	CodeSharePasswordLimited = 40088
+	// 分享未开启下载
+	CodeDisabledShareDownload = 40089
+	// 自定义分享链接已被使用
+	CodeShareSlugTaken = 40090
+	// 举报分享过于频繁
+	CodeShareReportLimited = 40091
+	// 已举报过此分享，举报尚未处理
+	CodeShareReportDuplicated = 40092
 	// CodeDBError 数据库操作失败
 	CodeDBError = 50001
 	// CodeEncryptError 加密失败
@@ -212,6 +250,10 @@ const (
 	CodeNodeOffline = 50010
 	// 文件元信息查询失败
 	CodeQueryMetaFailed = 50011
+	// 病毒扫描失败
+	CodeVirusScanFailed = 50012
+	// 上传 Webhook 请求失败
+	CodeUploadWebhookFailed = 50013
 	//CodeParamErr 各种奇奇怪怪的参数错误
 	CodeParamErr = 40001
 	// CodeNotSet 未定错误，后续尝试从error中获取
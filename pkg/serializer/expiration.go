@@ -0,0 +1,56 @@
+package serializer
+
+import (
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+)
+
+// ExpirationItem 设定了到期规则的对象
+type ExpirationItem struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Action    string    `json:"action"`
+}
+
+// BuildExpirationList 构建到期规则列表响应，对象已不存在的规则不列出
+func BuildExpirationList(expirations []model.Expiration, folders []model.Folder, files []model.File) Response {
+	folderNames := make(map[uint]string, len(folders))
+	for _, folder := range folders {
+		folderNames[folder.ID] = folder.Name
+	}
+
+	fileNames := make(map[uint]string, len(files))
+	for _, file := range files {
+		fileNames[file.ID] = file.Name
+	}
+
+	res := make([]ExpirationItem, 0, len(expirations))
+	for _, expiration := range expirations {
+		item := ExpirationItem{ExpiresAt: expiration.ExpiresAt, Action: expiration.Action}
+		if expiration.IsDir {
+			name, ok := folderNames[expiration.ObjectID]
+			if !ok {
+				continue
+			}
+			item.ID = hashid.HashID(expiration.ObjectID, hashid.FolderID)
+			item.Name = name
+			item.Type = "dir"
+		} else {
+			name, ok := fileNames[expiration.ObjectID]
+			if !ok {
+				continue
+			}
+			item.ID = hashid.HashID(expiration.ObjectID, hashid.FileID)
+			item.Name = name
+			item.Type = "file"
+		}
+
+		res = append(res, item)
+	}
+
+	return Response{Data: res}
+}
@@ -0,0 +1,34 @@
+package serializer
+
+import (
+	"testing"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildExpirationList(t *testing.T) {
+	a := assert.New(t)
+	expiresAt := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	expirations := []model.Expiration{
+		{ObjectID: 1, IsDir: true, ExpiresAt: expiresAt, Action: model.ExpireActionTrash},
+		{ObjectID: 2, ExpiresAt: expiresAt, Action: model.ExpireActionDelete},
+		{ObjectID: 3, ExpiresAt: expiresAt, Action: model.ExpireActionDelete},
+	}
+	folders := []model.Folder{{Model: gorm.Model{ID: 1}, Name: "camera"}}
+	files := []model.File{{Model: gorm.Model{ID: 2}, Name: "tmp.zip"}}
+
+	res := BuildExpirationList(expirations, folders, files)
+	items := res.Data.([]ExpirationItem)
+	a.Len(items, 2)
+	a.Equal(hashid.HashID(1, hashid.FolderID), items[0].ID)
+	a.Equal("camera", items[0].Name)
+	a.Equal("dir", items[0].Type)
+	a.Equal(model.ExpireActionTrash, items[0].Action)
+	a.Equal(hashid.HashID(2, hashid.FileID), items[1].ID)
+	a.Equal("file", items[1].Type)
+	a.Equal(expiresAt, items[1].ExpiresAt)
+}
@@ -29,6 +29,8 @@ type ObjectList struct {
 	Parent  string         `json:"parent,omitempty"`
 	Objects []Object       `json:"objects"`
 	Policy  *PolicySummary `json:"policy,omitempty"`
+	// 下一页的游标，已是最后一页时为空
+	Next string `json:"next,omitempty"`
 }
 
 // Object 文件或者目录
@@ -43,6 +45,20 @@ type Object struct {
 	CreateDate    time.Time `json:"create_date"`
 	Key           string    `json:"key,omitempty"`
 	SourceEnabled bool      `json:"source_enabled"`
+	Starred       bool      `json:"starred,omitempty"`
+	Mount         bool      `json:"mount,omitempty"`
+	Color         string    `json:"color,omitempty"`
+	Icon          string    `json:"icon,omitempty"`
+	Description   string    `json:"description,omitempty"`
+}
+
+// DuplicateGroup 同一存储策略中内容相同的一组文件，Reclaimable 为合并后可释放的存储空间
+type DuplicateGroup struct {
+	Hash        string   `json:"hash"`
+	Size        uint64   `json:"size"`
+	Policy      string   `json:"policy"`
+	Reclaimable uint64   `json:"reclaimable"`
+	Files       []Object `json:"files"`
 }
 
 // PolicySummary 用于前端组件使用的存储策略概况
@@ -126,6 +142,8 @@ type WopiFileInfo struct {
 	UserCanReview bool
 	UserCanWrite  bool
 
+	UserCanNotWriteRelative bool
+
 	SupportsRename    bool
 	SupportsReviewing bool
 	SupportsUpdate    bool
@@ -0,0 +1,46 @@
+package serializer
+
+import (
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+)
+
+// Photo 照片及其拍摄信息
+type Photo struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Size      uint64     `json:"size"`
+	TakenAt   *time.Time `json:"taken_at"`
+	Camera    string     `json:"camera,omitempty"`
+	Latitude  *float64   `json:"latitude,omitempty"`
+	Longitude *float64   `json:"longitude,omitempty"`
+}
+
+// PhotoTimelineItem 时间线中一天内拍摄的照片数量
+type PhotoTimelineItem struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+// BuildPhotoList 构建照片列表响应，ID 为照片对应文件的 ID
+func BuildPhotoList(photos []model.Photo, total int) Response {
+	res := make([]Photo, 0, len(photos))
+	for _, photo := range photos {
+		res = append(res, Photo{
+			ID:        hashid.HashID(photo.FileID, hashid.FileID),
+			Name:      photo.File.Name,
+			Size:      photo.File.Size,
+			TakenAt:   photo.TakenAt,
+			Camera:    photo.Camera,
+			Latitude:  photo.Latitude,
+			Longitude: photo.Longitude,
+		})
+	}
+
+	return Response{Data: map[string]interface{}{
+		"total": total,
+		"items": res,
+	}}
+}
@@ -0,0 +1,28 @@
+package serializer
+
+import (
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildPhotoList(t *testing.T) {
+	a := assert.New(t)
+	lat, lng := 31.2, 121.5
+	photo := model.Photo{FileID: 2, Camera: "Canon EOS R5", Latitude: &lat, Longitude: &lng}
+	photo.File.Name = "1.jpg"
+	photo.File.Size = 10
+
+	res := BuildPhotoList([]model.Photo{photo}, 1)
+	data := res.Data.(map[string]interface{})
+	a.Equal(1, data["total"])
+	items := data["items"].([]Photo)
+	a.Len(items, 1)
+	a.Equal(hashid.HashID(2, hashid.FileID), items[0].ID)
+	a.Equal("1.jpg", items[0].Name)
+	a.EqualValues(10, items[0].Size)
+	a.Equal("Canon EOS R5", items[0].Camera)
+	a.Equal(&lat, items[0].Latitude)
+}
@@ -27,11 +27,14 @@ type SiteConfig struct {
 }
 
 type task struct {
+	ID         uint      `json:"id"`
 	Status     int       `json:"status"`
 	Type       int       `json:"type"`
 	CreateDate time.Time `json:"create_date"`
 	Progress   int       `json:"progress"`
 	Error      string    `json:"error"`
+	Log        string    `json:"log,omitempty"`
+	Props      string    `json:"props,omitempty"`
 }
 
 // BuildTaskList 构建任务列表响应
@@ -39,11 +42,14 @@ func BuildTaskList(tasks []model.Task, total int) Response {
 	res := make([]task, 0, len(tasks))
 	for _, t := range tasks {
 		res = append(res, task{
+			ID:         t.ID,
 			Status:     t.Status,
 			Type:       t.Type,
 			CreateDate: t.CreatedAt,
 			Progress:   t.Progress,
 			Error:      t.Error,
+			Log:        t.Log,
+			Props:      t.Props,
 		})
 	}
 
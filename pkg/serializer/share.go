@@ -1,6 +1,7 @@
 package serializer
 
 import (
+	"sort"
 	"time"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
@@ -9,16 +10,22 @@ import (
 
 // Share 分享信息序列化
 type Share struct {
-	Key        string        `json:"key"`
-	Locked     bool          `json:"locked"`
-	IsDir      bool          `json:"is_dir"`
-	CreateDate time.Time     `json:"create_date,omitempty"`
-	Downloads  int           `json:"downloads"`
-	Views      int           `json:"views"`
-	Expire     int64         `json:"expire"`
-	Preview    bool          `json:"preview"`
-	Creator    *shareCreator `json:"creator,omitempty"`
-	Source     *shareSource  `json:"source,omitempty"`
+	Key             string        `json:"key"`
+	Locked          bool          `json:"locked"`
+	IsDir           bool          `json:"is_dir"`
+	CreateDate      time.Time     `json:"create_date,omitempty"`
+	Downloads       int           `json:"downloads"`
+	Views           int           `json:"views"`
+	RemainDownloads int           `json:"remain_downloads"`
+	RemainViews     int           `json:"remain_views"`
+	Expire          int64         `json:"expire"`
+	Activate        int64         `json:"activate"`
+	Preview         bool          `json:"preview"`
+	Upload          bool          `json:"upload"`
+	Permissions     int           `json:"permissions"`
+	Watermark       bool          `json:"watermark"`
+	Creator         *shareCreator `json:"creator,omitempty"`
+	Source          *shareSource  `json:"source,omitempty"`
 }
 
 type shareCreator struct {
@@ -32,6 +39,13 @@ type shareSource struct {
 	Size uint64 `json:"size"`
 }
 
+// ShareLink 创建分享后返回的链接
+type ShareLink struct {
+	URL      string `json:"url"`
+	ShortURL string `json:"short_url,omitempty"`
+	QRCode   string `json:"qrcode,omitempty"`
+}
+
 // myShareItem 我的分享列表条目
 type myShareItem struct {
 	Key             string       `json:"key"`
@@ -41,8 +55,18 @@ type myShareItem struct {
 	Downloads       int          `json:"downloads"`
 	RemainDownloads int          `json:"remain_downloads"`
 	Views           int          `json:"views"`
+	RemainViews     int          `json:"remain_views"`
 	Expire          int64        `json:"expire"`
+	Activate        int64        `json:"activate"`
 	Preview         bool         `json:"preview"`
+	Upload          bool         `json:"upload"`
+	Permissions     int          `json:"permissions"`
+	Internal        bool         `json:"internal"`
+	Collection      bool         `json:"collection"`
+	Watermark       string       `json:"watermark"`
+	Notify          int          `json:"notify"`
+	Disabled        bool         `json:"disabled"`
+	Embed           string       `json:"embed"`
 	Source          *shareSource `json:"source,omitempty"`
 }
 
@@ -52,15 +76,24 @@ func BuildShareList(shares []model.Share, total int) Response {
 	now := time.Now().Unix()
 	for i := 0; i < len(shares); i++ {
 		item := myShareItem{
-			Key:             hashid.HashID(shares[i].ID, hashid.ShareID),
+			Key:             shares[i].Key(),
 			IsDir:           shares[i].IsDir,
 			Password:        shares[i].Password,
 			CreateDate:      shares[i].CreatedAt,
 			Downloads:       shares[i].Downloads,
 			Views:           shares[i].Views,
 			Preview:         shares[i].PreviewEnabled,
+			Upload:          shares[i].AllowUpload,
+			Permissions:     shares[i].Permission(),
+			Internal:        shares[i].Internal,
+			Collection:      shares[i].Collection,
+			Watermark:       shares[i].Watermark,
+			Notify:          shares[i].AccessNotify,
+			Disabled:        shares[i].Disabled,
+			Embed:           shares[i].EmbedDomains,
 			Expire:          -1,
 			RemainDownloads: shares[i].RemainDownloads,
+			RemainViews:     shares[i].RemainViews,
 		}
 		if shares[i].Expires != nil {
 			item.Expire = shares[i].Expires.Unix() - now
@@ -68,7 +101,14 @@ func BuildShareList(shares []model.Share, total int) Response {
 				item.Expire = 0
 			}
 		}
-		if shares[i].File.ID != 0 {
+		if !shares[i].IsActivated() {
+			item.Activate = shares[i].ActivatesAt.Unix() - now
+		}
+		if shares[i].Collection {
+			item.Source = &shareSource{
+				Name: shares[i].SourceName,
+			}
+		} else if shares[i].File.ID != 0 {
 			item.Source = &shareSource{
 				Name: shares[i].File.Name,
 				Size: shares[i].File.Size,
@@ -92,7 +132,7 @@ func BuildShareList(shares []model.Share, total int) Response {
 func BuildShareResponse(share *model.Share, unlocked bool) Share {
 	creator := share.Creator()
 	resp := Share{
-		Key:    hashid.HashID(share.ID, hashid.ShareID),
+		Key:    share.Key(),
 		Locked: !unlocked,
 		Creator: &shareCreator{
 			Key:       hashid.HashID(creator.ID, hashid.UserID),
@@ -110,13 +150,22 @@ func BuildShareResponse(share *model.Share, unlocked bool) Share {
 	resp.IsDir = share.IsDir
 	resp.Downloads = share.Downloads
 	resp.Views = share.Views
+	resp.RemainDownloads = share.RemainDownloads
+	resp.RemainViews = share.RemainViews
 	resp.Preview = share.PreviewEnabled
+	resp.Upload = share.AllowUpload
+	resp.Permissions = share.Permission()
+	resp.Watermark = share.Watermark != ""
 
 	if share.Expires != nil {
 		resp.Expire = share.Expires.Unix() - time.Now().Unix()
 	}
+	if !share.IsActivated() {
+		resp.Activate = share.ActivatesAt.Unix() - time.Now().Unix()
+	}
 
 	if share.IsDir {
+		// 合集分享的虚拟目录以分享名称命名
 		source := share.SourceFolder()
 		resp.Source = &shareSource{
 			Name: source.Name,
@@ -133,3 +182,179 @@ func BuildShareResponse(share *model.Share, unlocked bool) Share {
 	return resp
 
 }
+
+// maxShareStatsFiles 访问统计中列出的下载最多的文件数
+const maxShareStatsFiles = 10
+
+// ShareStats 分享访问统计
+type ShareStats struct {
+	Views          int              `json:"views"`
+	UniqueVisitors int              `json:"unique_visitors"`
+	Downloads      int              `json:"downloads"`
+	Days           []shareStatsDay  `json:"days"`
+	Files          []shareStatsFile `json:"files"`
+}
+
+type shareStatsDay struct {
+	Date           string `json:"date"`
+	Views          int    `json:"views"`
+	UniqueVisitors int    `json:"unique_visitors"`
+	Downloads      int    `json:"downloads"`
+}
+
+type shareStatsFile struct {
+	Name      string `json:"name"`
+	Downloads int    `json:"downloads"`
+}
+
+// BuildShareStats 按天汇总自 since 起 days 天内的访问记录，访客以 IP 摘要区分
+func BuildShareStats(accesses []model.ShareAccess, since time.Time, days int) ShareStats {
+	res := ShareStats{
+		Days:  make([]shareStatsDay, days),
+		Files: []shareStatsFile{},
+	}
+	dayIndex := make(map[string]int, days)
+	for i := 0; i < days; i++ {
+		date := since.AddDate(0, 0, i).Format("2006-01-02")
+		res.Days[i].Date = date
+		dayIndex[date] = i
+	}
+
+	visitors := make(map[string]bool)
+	dayVisitors := make([]map[string]bool, days)
+	fileIndex := make(map[string]int)
+	for _, access := range accesses {
+		i, ok := dayIndex[access.CreatedAt.In(since.Location()).Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+
+		if !visitors[access.IPHash] {
+			visitors[access.IPHash] = true
+			res.UniqueVisitors++
+		}
+		if dayVisitors[i] == nil {
+			dayVisitors[i] = make(map[string]bool)
+		}
+		if !dayVisitors[i][access.IPHash] {
+			dayVisitors[i][access.IPHash] = true
+			res.Days[i].UniqueVisitors++
+		}
+
+		switch access.Action {
+		case model.ShareAccessView:
+			res.Views++
+			res.Days[i].Views++
+		case model.ShareAccessDownload:
+			res.Downloads++
+			res.Days[i].Downloads++
+			if j, ok := fileIndex[access.FileName]; ok {
+				res.Files[j].Downloads++
+			} else {
+				fileIndex[access.FileName] = len(res.Files)
+				res.Files = append(res.Files, shareStatsFile{Name: access.FileName, Downloads: 1})
+			}
+		}
+	}
+
+	sort.SliceStable(res.Files, func(i, j int) bool {
+		return res.Files[i].Downloads > res.Files[j].Downloads
+	})
+	if len(res.Files) > maxShareStatsFiles {
+		res.Files = res.Files[:maxShareStatsFiles]
+	}
+
+	return res
+}
+
+// ShareTarget 分享对象
+type ShareTarget struct {
+	ID         uint      `json:"id"`
+	Type       string    `json:"type"`
+	Name       string    `json:"name"`
+	Email      string    `json:"email,omitempty"`
+	CanReshare bool      `json:"can_reshare"`
+	CreateDate time.Time `json:"create_date"`
+}
+
+// BuildShareTargets 构建分享对象列表，用户及用户组需已加载
+func BuildShareTargets(targets []model.ShareTarget) []ShareTarget {
+	res := make([]ShareTarget, 0, len(targets))
+	for _, target := range targets {
+		item := ShareTarget{
+			ID:         target.ID,
+			Type:       "user",
+			Name:       target.User.Nick,
+			Email:      target.User.Email,
+			CanReshare: target.CanReshare,
+			CreateDate: target.CreatedAt,
+		}
+		if target.UserID == 0 {
+			item.Type = "group"
+			item.Name = target.Group.Name
+			item.Email = ""
+		}
+		res = append(res, item)
+	}
+
+	return res
+}
+
+// sharedWithMeItem 分享给我的分享列表条目
+type sharedWithMeItem struct {
+	Key        string        `json:"key"`
+	IsDir      bool          `json:"is_dir"`
+	CreateDate time.Time     `json:"create_date"`
+	Creator    *shareCreator `json:"creator"`
+	Source     *shareSource  `json:"source,omitempty"`
+}
+
+// BuildSharedWithMeList 构建分享给我的分享列表响应，创建者及源对象需已加载
+func BuildSharedWithMeList(shares []model.Share, total int) Response {
+	res := make([]sharedWithMeItem, 0, len(shares))
+	for i := range shares {
+		item := sharedWithMeItem{
+			Key:        shares[i].Key(),
+			IsDir:      shares[i].IsDir,
+			CreateDate: shares[i].CreatedAt,
+			Creator: &shareCreator{
+				Key:       hashid.HashID(shares[i].User.ID, hashid.UserID),
+				Nick:      shares[i].User.Nick,
+				GroupName: shares[i].User.Group.Name,
+			},
+		}
+		if shares[i].Collection {
+			item.Source = &shareSource{
+				Name: shares[i].SourceName,
+			}
+		} else if shares[i].File.ID != 0 {
+			item.Source = &shareSource{
+				Name: shares[i].File.Name,
+				Size: shares[i].File.Size,
+			}
+		} else if shares[i].Folder.ID != 0 {
+			item.Source = &shareSource{
+				Name: shares[i].Folder.Name,
+			}
+		}
+
+		res = append(res, item)
+	}
+
+	return Response{Data: map[string]interface{}{
+		"total": total,
+		"items": res,
+	}}
+}
+
+// OEmbed oEmbed 协议的分享嵌入信息
+type OEmbed struct {
+	Type         string `json:"type"`
+	Version      string `json:"version"`
+	Title        string `json:"title"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
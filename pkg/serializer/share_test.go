@@ -83,3 +83,70 @@ func TestBuildShareResponse(t *testing.T) {
 		asserts.NotNil(res.Creator)
 	}
 }
+
+func TestBuildShareStats(t *testing.T) {
+	asserts := assert.New(t)
+	since := time.Date(2022, 1, 1, 0, 0, 0, 0, time.Local)
+	accesses := []model.ShareAccess{
+		{Model: gorm.Model{CreatedAt: since.Add(time.Hour)}, Action: model.ShareAccessView, IPHash: "a"},
+		{Model: gorm.Model{CreatedAt: since.Add(2 * time.Hour)}, Action: model.ShareAccessView, IPHash: "a"},
+		{Model: gorm.Model{CreatedAt: since.Add(3 * time.Hour)}, Action: model.ShareAccessDownload, IPHash: "a", FileName: "/a.txt"},
+		{Model: gorm.Model{CreatedAt: since.Add(26 * time.Hour)}, Action: model.ShareAccessView, IPHash: "b"},
+		{Model: gorm.Model{CreatedAt: since.Add(27 * time.Hour)}, Action: model.ShareAccessDownload, IPHash: "b", FileName: "/b.txt"},
+		{Model: gorm.Model{CreatedAt: since.Add(28 * time.Hour)}, Action: model.ShareAccessDownload, IPHash: "a", FileName: "/b.txt"},
+		// 超出统计范围
+		{Model: gorm.Model{CreatedAt: since.AddDate(0, 0, 5)}, Action: model.ShareAccessView, IPHash: "c"},
+	}
+
+	res := BuildShareStats(accesses, since, 3)
+	asserts.Equal(3, res.Views)
+	asserts.Equal(2, res.UniqueVisitors)
+	asserts.Equal(3, res.Downloads)
+
+	asserts.Len(res.Days, 3)
+	asserts.Equal("2022-01-01", res.Days[0].Date)
+	asserts.Equal(2, res.Days[0].Views)
+	asserts.Equal(1, res.Days[0].UniqueVisitors)
+	asserts.Equal(1, res.Days[0].Downloads)
+	asserts.Equal(1, res.Days[1].Views)
+	asserts.Equal(2, res.Days[1].UniqueVisitors)
+	asserts.Equal(2, res.Days[1].Downloads)
+	asserts.Equal("2022-01-03", res.Days[2].Date)
+	asserts.Equal(0, res.Days[2].Views)
+
+	asserts.Len(res.Files, 2)
+	asserts.Equal("/b.txt", res.Files[0].Name)
+	asserts.Equal(2, res.Files[0].Downloads)
+}
+
+func TestBuildShareTargets(t *testing.T) {
+	asserts := assert.New(t)
+
+	res := BuildShareTargets([]model.ShareTarget{
+		{Model: gorm.Model{ID: 1}, UserID: 2, CanReshare: true, User: model.User{Nick: "nick", Email: "a@b.com"}},
+		{Model: gorm.Model{ID: 2}, GroupID: 3, Group: model.Group{Name: "group"}},
+	})
+	asserts.Len(res, 2)
+	asserts.Equal("user", res[0].Type)
+	asserts.Equal("nick", res[0].Name)
+	asserts.Equal("a@b.com", res[0].Email)
+	asserts.True(res[0].CanReshare)
+	asserts.Equal("group", res[1].Type)
+	asserts.Equal("group", res[1].Name)
+	asserts.Empty(res[1].Email)
+}
+
+func TestBuildSharedWithMeList(t *testing.T) {
+	asserts := assert.New(t)
+
+	res := BuildSharedWithMeList([]model.Share{
+		{Slug: "my-share", User: model.User{Nick: "nick"}, File: model.File{Model: gorm.Model{ID: 1}, Name: "a.txt"}},
+		{IsDir: true, Folder: model.Folder{Model: gorm.Model{ID: 1}, Name: "dir"}},
+	}, 2)
+	items := res.Data.(map[string]interface{})["items"].([]sharedWithMeItem)
+	asserts.Len(items, 2)
+	asserts.Equal("my-share", items[0].Key)
+	asserts.Equal("nick", items[0].Creator.Nick)
+	asserts.Equal("a.txt", items[0].Source.Name)
+	asserts.Equal("dir", items[1].Source.Name)
+}
@@ -13,6 +13,13 @@ type RemoteDeleteRequest struct {
 	Files []string `json:"files"`
 }
 
+// RemoteThumbRequest 远程策略在从机生成缩略图请求正文
+type RemoteThumbRequest struct {
+	Src  string `json:"src"`
+	Name string `json:"name"`
+	Size uint64 `json:"size"`
+}
+
 // ListRequest 远程策略列文件请求正文
 type ListRequest struct {
 	Path      string `json:"path"`
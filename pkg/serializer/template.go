@@ -0,0 +1,34 @@
+package serializer
+
+import (
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+)
+
+// FolderTemplate 目录模板
+type FolderTemplate struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Folders []string `json:"folders"`
+	Global  bool     `json:"global"`
+}
+
+// BuildFolderTemplateList 构建目录模板列表响应
+func BuildFolderTemplateList(templates []model.FolderTemplate) Response {
+	res := make([]FolderTemplate, 0, len(templates))
+	for _, template := range templates {
+		folders := template.FolderList
+		if folders == nil {
+			folders = []string{}
+		}
+
+		res = append(res, FolderTemplate{
+			ID:      hashid.HashID(template.ID, hashid.FolderTemplateID),
+			Name:    template.Name,
+			Folders: folders,
+			Global:  template.UserID == 0,
+		})
+	}
+
+	return Response{Data: res}
+}
@@ -0,0 +1,48 @@
+package serializer
+
+import model "github.com/cloudreve/Cloudreve/v3/models"
+
+// TrafficItem 流量统计条目，单位字节
+type TrafficItem struct {
+	Date     string `json:"date"`
+	UserID   uint   `json:"user_id,omitempty"`
+	PolicyID uint   `json:"policy_id,omitempty"`
+	Upload   uint64 `json:"upload"`
+	Download uint64 `json:"download"`
+}
+
+// BuildTrafficReport 构建流量统计响应，traffics 需按日期排序。daily 为 true 时
+// 合并同一天内不同用户、存储策略的流量
+func BuildTrafficReport(traffics []model.Traffic, daily bool) Response {
+	items := make([]TrafficItem, 0, len(traffics))
+	var upload, download uint64
+	for _, t := range traffics {
+		upload += t.Upload
+		download += t.Download
+
+		if daily {
+			if len(items) > 0 && items[len(items)-1].Date == t.Date {
+				items[len(items)-1].Upload += t.Upload
+				items[len(items)-1].Download += t.Download
+				continue
+			}
+
+			items = append(items, TrafficItem{Date: t.Date, Upload: t.Upload, Download: t.Download})
+			continue
+		}
+
+		items = append(items, TrafficItem{
+			Date:     t.Date,
+			UserID:   t.UserID,
+			PolicyID: t.PolicyID,
+			Upload:   t.Upload,
+			Download: t.Download,
+		})
+	}
+
+	return Response{Data: map[string]interface{}{
+		"items":    items,
+		"upload":   upload,
+		"download": download,
+	}}
+}
@@ -0,0 +1,33 @@
+package serializer
+
+import (
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildTrafficReport(t *testing.T) {
+	a := assert.New(t)
+	traffics := []model.Traffic{
+		{UserID: 1, PolicyID: 1, Date: "2022-01-01", Upload: 1, Download: 2},
+		{UserID: 1, PolicyID: 2, Date: "2022-01-01", Upload: 3, Download: 4},
+		{UserID: 2, PolicyID: 1, Date: "2022-01-02", Upload: 5, Download: 6},
+	}
+
+	res := BuildTrafficReport(traffics, false)
+	data := res.Data.(map[string]interface{})
+	a.EqualValues(9, data["upload"])
+	a.EqualValues(12, data["download"])
+	a.Len(data["items"], 3)
+	a.Equal(TrafficItem{Date: "2022-01-01", UserID: 1, PolicyID: 2, Upload: 3, Download: 4}, data["items"].([]TrafficItem)[1])
+
+	// 按日期合并
+	res = BuildTrafficReport(traffics, true)
+	data = res.Data.(map[string]interface{})
+	a.Equal([]TrafficItem{
+		{Date: "2022-01-01", Upload: 4, Download: 6},
+		{Date: "2022-01-02", Upload: 5, Download: 6},
+	}, data["items"])
+	a.EqualValues(9, data["upload"])
+}
@@ -0,0 +1,35 @@
+package serializer
+
+import (
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+)
+
+// TrashItem 回收站中的对象
+type TrashItem struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Size      uint64    `json:"size"`
+	DeletedAt time.Time `json:"deleted_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// BuildTrashList 构建回收站对象列表响应，retentionDays 为对象被彻底删除前保留的天数
+func BuildTrashList(trashes []model.Trash, retentionDays int) Response {
+	res := make([]TrashItem, 0, len(trashes))
+	for _, trash := range trashes {
+		res = append(res, TrashItem{
+			ID:        hashid.HashID(trash.ID, hashid.TrashID),
+			Name:      trash.Name,
+			Type:      trash.ObjectType,
+			Size:      trash.Size,
+			DeletedAt: trash.CreatedAt,
+			ExpiresAt: trash.CreatedAt.AddDate(0, 0, retentionDays),
+		})
+	}
+
+	return Response{Data: res}
+}
@@ -0,0 +1,26 @@
+package serializer
+
+import (
+	"testing"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildTrashList(t *testing.T) {
+	a := assert.New(t)
+	trash := model.Trash{ObjectType: model.TrashObjectFolder, Name: "docs", Size: 10}
+	trash.ID = 2
+	trash.CreatedAt = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	res := BuildTrashList([]model.Trash{trash}, 30)
+	items := res.Data.([]TrashItem)
+	a.Len(items, 1)
+	a.Equal(hashid.HashID(2, hashid.TrashID), items[0].ID)
+	a.Equal("docs", items[0].Name)
+	a.Equal("folder", items[0].Type)
+	a.EqualValues(10, items[0].Size)
+	a.Equal(time.Date(2022, 1, 31, 0, 0, 0, 0, time.UTC), items[0].ExpiresAt)
+}
@@ -30,8 +30,16 @@ type UploadCredential struct {
 	KeyTime     string   `json:"keyTime,omitempty"` // COS用有效期
 	Policy      string   `json:"policy,omitempty"`
 	CompleteURL string   `json:"completeURL,omitempty"`
+	Instant     bool     `json:"instant,omitempty"` // 是否已秒传完成，无需再上传
+	Mode        string   `json:"mode,omitempty"`    // 上传方式，为空时按存储策略类型的默认方式上传
 }
 
+// 客户端上传方式
+const (
+	UploadModeMultipart = "multipart" // 按 UploadURLs 逐个上传分片后请求 CompleteURL 完成上传
+	UploadModeSingle    = "single"    // 使用单个 PUT 请求将整个文件上传至 UploadURLs[0]
+)
+
 // UploadSession 上传会话
 type UploadSession struct {
 	Key            string     // 上传会话 GUID
@@ -47,6 +55,52 @@ type UploadSession struct {
 	UploadURL      string
 	UploadID       string
 	Credential     string
+	Expires        int64  // 会话过期时间， Unix 时间戳
+	SpeedLimit     int    // 上传限速，字节/秒，0 为不限制
+	MD5            string // 客户端提供的文件 MD5，上传完成后校验
+	Hash           string // 客户端提供的文件 SHA-256，上传完成后校验
+	ReplaceName    string // 上传完成后需替换的同名已有文件
+	Conflict       string // 替换同名文件的方式，覆盖或保留版本
+	MasterID       string // 从机上的会话所属主机节点 ID，用于向主机转发上传进度
+	CameraUpload   bool   // 上传完成后按拍摄日期归档至上传目录下的 年/月 子目录
+}
+
+// FolderUploadCredential 文件夹上传中单个文件的上传凭证，创建失败时包含错误信息
+type FolderUploadCredential struct {
+	RelativePath string            `json:"relative_path"`
+	Credential   *UploadCredential `json:"credential,omitempty"`
+	Code         int               `json:"code,omitempty"`
+	Msg          string            `json:"msg,omitempty"`
+}
+
+// UploadProgressEvent 服务端向存储端写入数据的进度
+type UploadProgressEvent struct {
+	SessionID string  `json:"sessionID"`
+	Written   uint64  `json:"written"` // 已写入存储端的字节数
+	Total     uint64  `json:"total"`
+	Speed     float64 `json:"speed"` // 写入速度，字节/秒
+	ETA       int64   `json:"eta"`   // 预计剩余秒数，-1 为未知
+}
+
+// UploadProgress 中转上传会话的续传进度
+type UploadProgress struct {
+	SessionID string `json:"sessionID"`
+	ChunkSize uint64 `json:"chunkSize"`
+	Size      uint64 `json:"size"`
+	Uploaded  uint64 `json:"uploaded"`  // 已完成上传的字节数
+	NextChunk int    `json:"nextChunk"` // 续传时下一个应上传的分片序号
+	Expires   int64  `json:"expires"`
+}
+
+// UploadSessionItem 用户进行中的上传会话
+type UploadSessionItem struct {
+	SessionID string `json:"sessionID"`
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Size      uint64 `json:"size"`
+	Uploaded  uint64 `json:"uploaded"` // 服务端已写入的字节数，仅中转上传有效
+	Policy    string `json:"policy"`
+	Expires   int64  `json:"expires"`
 }
 
 // UploadCallback 上传回调正文
@@ -61,4 +115,5 @@ type GeneralUploadCallbackFailed struct {
 
 func init() {
 	gob.Register(UploadSession{})
+	gob.Register(UploadProgressEvent{})
 }
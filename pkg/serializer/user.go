@@ -43,6 +43,7 @@ type group struct {
 	SourceBatchSize      int    `json:"sourceBatch"`
 	AdvanceDelete        bool   `json:"advanceDelete"`
 	AllowWebDAVProxy     bool   `json:"allowWebDAVProxy"`
+	CrossUserCopy        bool   `json:"crossUserCopy"`
 }
 
 type tag struct {
@@ -104,6 +105,7 @@ func BuildUser(user model.User) User {
 			AllowWebDAVProxy:     user.Group.OptionsSerialized.WebDAVProxy,
 			SourceBatchSize:      user.Group.OptionsSerialized.SourceBatchSize,
 			AdvanceDelete:        user.Group.OptionsSerialized.AdvanceDelete,
+			CrossUserCopy:        user.Group.OptionsSerialized.CrossUserCopy,
 		},
 		Tags: buildTagRes(tags),
 	}
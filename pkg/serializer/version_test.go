@@ -0,0 +1,21 @@
+package serializer
+
+import (
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildFileVersionList(t *testing.T) {
+	a := assert.New(t)
+	version := model.FileVersion{Size: 10}
+	version.ID = 2
+
+	res := BuildFileVersionList([]model.FileVersion{version})
+	items := res.Data.([]FileVersion)
+	a.Len(items, 1)
+	a.Equal(hashid.HashID(2, hashid.VersionID), items[0].ID)
+	a.EqualValues(10, items[0].Size)
+}
@@ -0,0 +1,121 @@
+package sharelink
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"image/png"
+	"net/url"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+)
+
+// 短链接服务类型
+const (
+	// ProviderNone 不生成短链接
+	ProviderNone = ""
+	// ProviderBuiltin 使用指向本站的短域名
+	ProviderBuiltin = "builtin"
+	// ProviderCustom 使用第三方短链接服务
+	ProviderCustom = "custom"
+)
+
+var (
+	ErrProviderDisabled = errors.New("short link provider is not configured")
+	ErrInvalidResponse  = errors.New("short link provider returned an invalid url")
+)
+
+// QRCode 生成内容为 content、边长为 size 像素的二维码 PNG 图片
+func QRCode(content string, size int) ([]byte, error) {
+	code, err := qr.Encode(content, qr.M, qr.Auto)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err = barcode.Scale(code, size, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, code); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// QRCodeDataURI 生成二维码，以 data URI 的形式返回
+func QRCodeDataURI(content string, size int) (string, error) {
+	img, err := QRCode(content, size)
+	if err != nil {
+		return "", err
+	}
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(img), nil
+}
+
+// Shortener 短链接生成器
+type Shortener struct {
+	Provider string
+	// Domain 内置短链接使用的域名，需指向本站
+	Domain string
+	// API 第三方短链接服务地址，{url} 被替换为转义后的原始链接，响应正文为短链接
+	API    string
+	Client request.Client
+}
+
+// NewShortener 根据站点设置创建短链接生成器
+func NewShortener() *Shortener {
+	options := model.GetSettingByNames("share_short_link_provider", "share_short_link_domain", "share_short_link_api")
+	return &Shortener{
+		Provider: options["share_short_link_provider"],
+		Domain:   options["share_short_link_domain"],
+		API:      options["share_short_link_api"],
+		Client:   request.GeneralClient,
+	}
+}
+
+// Enabled 返回是否配置了短链接服务
+func (s *Shortener) Enabled() bool {
+	return s.Provider == ProviderBuiltin || s.Provider == ProviderCustom
+}
+
+// Shorten 返回 key 对应的分享链接 longURL 的短链接
+func (s *Shortener) Shorten(ctx context.Context, longURL, key string) (string, error) {
+	switch s.Provider {
+	case ProviderBuiltin:
+		base, err := url.Parse(s.Domain)
+		if err != nil || base.Host == "" {
+			return "", ErrProviderDisabled
+		}
+
+		sharePath, _ := url.Parse("/s/" + url.PathEscape(key))
+		return base.ResolveReference(sharePath).String(), nil
+	case ProviderCustom:
+		if s.API == "" {
+			return "", ErrProviderDisabled
+		}
+
+		target := strings.ReplaceAll(s.API, "{url}", url.QueryEscape(longURL))
+		body, err := s.Client.Request("GET", target, nil, request.WithContext(ctx)).
+			CheckHTTPResponse(200).GetResponse()
+		if err != nil {
+			return "", err
+		}
+
+		shortURL := strings.TrimSpace(body)
+		if u, err := url.Parse(shortURL); err != nil || u.Host == "" {
+			return "", ErrInvalidResponse
+		}
+
+		return shortURL, nil
+	}
+
+	return "", ErrProviderDisabled
+}
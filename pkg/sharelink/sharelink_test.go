@@ -0,0 +1,106 @@
+package sharelink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/png"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/mocks/requestmock"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+)
+
+func TestQRCode(t *testing.T) {
+	a := assert.New(t)
+
+	img, err := QRCode("https://example.com/s/abcd", 200)
+	a.NoError(err)
+	decoded, err := png.Decode(bytes.NewReader(img))
+	a.NoError(err)
+	a.Equal(200, decoded.Bounds().Dx())
+	a.Equal(200, decoded.Bounds().Dy())
+
+	uri, err := QRCodeDataURI("https://example.com/s/abcd", 200)
+	a.NoError(err)
+	a.True(strings.HasPrefix(uri, "data:image/png;base64,"))
+
+	// 尺寸过小
+	_, err = QRCode("https://example.com/s/abcd", 1)
+	a.Error(err)
+}
+
+func TestShortener_Shorten(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+
+	// 未配置
+	{
+		s := &Shortener{}
+		a.False(s.Enabled())
+		_, err := s.Shorten(ctx, "https://example.com/s/abcd", "abcd")
+		a.Equal(ErrProviderDisabled, err)
+	}
+
+	// 内置短域名
+	{
+		s := &Shortener{Provider: ProviderBuiltin, Domain: "https://s.example.com"}
+		a.True(s.Enabled())
+		res, err := s.Shorten(ctx, "https://example.com/s/abcd", "abcd")
+		a.NoError(err)
+		a.Equal("https://s.example.com/s/abcd", res)
+	}
+
+	// 内置短域名未设置
+	{
+		s := &Shortener{Provider: ProviderBuiltin}
+		_, err := s.Shorten(ctx, "https://example.com/s/abcd", "abcd")
+		a.Equal(ErrProviderDisabled, err)
+	}
+
+	// 第三方服务
+	{
+		mockHttp := &requestmock.RequestMock{}
+		s := &Shortener{Provider: ProviderCustom, API: "https://short.example/api?url={url}", Client: mockHttp}
+		mockHttp.On(
+			"Request",
+			"GET",
+			"https://short.example/api?url=https%3A%2F%2Fexample.com%2Fs%2Fabcd",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(&request.Response{
+			Response: &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("https://short.example/x1\n"))},
+		})
+		res, err := s.Shorten(ctx, "https://example.com/s/abcd", "abcd")
+		mockHttp.AssertExpectations(t)
+		a.NoError(err)
+		a.Equal("https://short.example/x1", res)
+	}
+
+	// 第三方服务返回无效链接
+	{
+		mockHttp := &requestmock.RequestMock{}
+		s := &Shortener{Provider: ProviderCustom, API: "https://short.example/api?url={url}", Client: mockHttp}
+		mockHttp.On("Request", "GET", testMock.Anything, testMock.Anything, testMock.Anything).Return(&request.Response{
+			Response: &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("error"))},
+		})
+		_, err := s.Shorten(ctx, "https://example.com/s/abcd", "abcd")
+		a.Equal(ErrInvalidResponse, err)
+	}
+
+	// 请求失败
+	{
+		mockHttp := &requestmock.RequestMock{}
+		s := &Shortener{Provider: ProviderCustom, API: "https://short.example/api?url={url}", Client: mockHttp}
+		mockHttp.On("Request", "GET", testMock.Anything, testMock.Anything, testMock.Anything).Return(&request.Response{
+			Err: errors.New("error"),
+		})
+		_, err := s.Shorten(ctx, "https://example.com/s/abcd", "abcd")
+		a.Error(err)
+	}
+}
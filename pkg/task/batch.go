@@ -0,0 +1,237 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// 批量操作类型
+const (
+	// BatchActionMove 移动
+	BatchActionMove = "move"
+	// BatchActionCopy 复制
+	BatchActionCopy = "copy"
+	// BatchActionDelete 删除
+	BatchActionDelete = "delete"
+)
+
+// batchProgressInterval 每处理多少个对象持久化一次进度并检查任务是否被取消
+const batchProgressInterval = 10
+
+// BatchTask 批量移动/复制/删除任务，逐个处理对象并记录进度
+type BatchTask struct {
+	User      *model.User
+	TaskModel *model.Task
+	TaskProps BatchProps
+	Err       *JobError
+}
+
+// BatchProps 批量操作任务属性
+type BatchProps struct {
+	Action string `json:"action"`            // 操作类型
+	Dirs   []uint `json:"dirs,omitempty"`    // 待处理的目录
+	Items  []uint `json:"items,omitempty"`   // 待处理的文件
+	SrcDir string `json:"src_dir,omitempty"` // 移动/复制的源目录
+	Dst    string `json:"dst,omitempty"`     // 移动/复制的目标目录
+	Force  bool   `json:"force,omitempty"`   // 删除时强制删除
+	Unlink bool   `json:"unlink,omitempty"`  // 删除时仅解除关联
+	Trash  bool   `json:"trash,omitempty"`   // 删除时移入回收站
+
+	// 进度
+	Total    int            `json:"total"`              // 对象总数
+	Done     int            `json:"done"`               // 已处理的对象数
+	Failed   int            `json:"failed"`             // 处理失败的对象数
+	Failures []BatchFailure `json:"failures,omitempty"` // 处理失败的对象
+}
+
+// BatchFailure 批量操作中处理失败的对象
+type BatchFailure struct {
+	ID    uint   `json:"id"`
+	IsDir bool   `json:"is_dir,omitempty"`
+	Error string `json:"error"`
+}
+
+// Props 获取任务属性
+func (job *BatchTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 获取任务状态
+func (job *BatchTask) Type() int {
+	return BatchTaskType
+}
+
+// Creator 获取创建者ID
+func (job *BatchTask) Creator() uint {
+	return job.User.ID
+}
+
+// Model 获取任务的数据库模型
+func (job *BatchTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 设定状态
+func (job *BatchTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 设定任务失败信息
+func (job *BatchTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+// SetErrorMsg 设定任务失败信息
+func (job *BatchTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任务失败信息
+func (job *BatchTask) GetError() *JobError {
+	return job.Err
+}
+
+// Canceled 返回任务是否已被用户取消
+func (job *BatchTask) Canceled() bool {
+	status, err := job.TaskModel.GetStatus()
+	return err == nil && status == Canceled
+}
+
+// Do 开始执行任务
+func (job *BatchTask) Do() {
+	job.TaskModel.SetProgress(BatchingProgress)
+
+	// 移动完成或被取消后，为已移动的文件调整存储路径，未移动的文件路径不变，不会被调整
+	if job.TaskProps.Action == BatchActionMove {
+		defer func() {
+			if err := SubmitRelocateTask(job.User, job.TaskProps.Dirs, job.TaskProps.Items); err != nil {
+				util.Log().Warning("Batch task %d failed to submit relocate task: %s", job.TaskModel.ID, err)
+			}
+		}()
+	}
+
+	// 从数据库恢复的任务跳过已处理的对象
+	done := job.TaskProps.Done
+	job.TaskProps.Total = len(job.TaskProps.Dirs) + len(job.TaskProps.Items)
+
+	var lastErr error
+	for i := done; i < job.TaskProps.Total; i++ {
+		if (i-done)%batchProgressInterval == 0 && i > done {
+			job.TaskModel.SetProps(job.Props())
+			if job.Canceled() {
+				util.Log().Info("Batch task %d canceled by user.", job.TaskModel.ID)
+				return
+			}
+		}
+
+		var (
+			dirs, files []uint
+			failure     BatchFailure
+		)
+		if i < len(job.TaskProps.Dirs) {
+			dirs = []uint{job.TaskProps.Dirs[i]}
+			failure = BatchFailure{ID: dirs[0], IsDir: true}
+		} else {
+			files = []uint{job.TaskProps.Items[i-len(job.TaskProps.Dirs)]}
+			failure = BatchFailure{ID: files[0]}
+		}
+
+		if err := job.process(dirs, files); err != nil {
+			util.Log().Warning("Batch task %d failed to %s object %d: %s", job.TaskModel.ID, job.TaskProps.Action, failure.ID, err)
+			failure.Error = err.Error()
+			job.TaskProps.Failures = append(job.TaskProps.Failures, failure)
+			job.TaskProps.Failed++
+			lastErr = err
+		}
+
+		job.TaskProps.Done++
+	}
+
+	job.TaskModel.SetProps(job.Props())
+	if job.TaskProps.Failed > 0 {
+		job.SetErrorMsg(fmt.Sprintf("Failed to %s %d object(s).", job.TaskProps.Action, job.TaskProps.Failed), lastErr)
+	}
+}
+
+// process 处理单个对象
+func (job *BatchTask) process(dirs, files []uint) error {
+	fs, err := filesystem.NewFileSystem(job.User)
+	if err != nil {
+		return err
+	}
+	defer fs.Recycle()
+
+	ctx := context.Background()
+	switch job.TaskProps.Action {
+	case BatchActionMove:
+		if err := fs.Move(ctx, dirs, files, job.TaskProps.SrcDir, job.TaskProps.Dst); err != nil {
+			return err
+		}
+		filesystem.RecordActivities(fs.NewActivities(model.ActivityMove, job.TaskProps.SrcDir, job.TaskProps.Dst, dirs, files)...)
+		return nil
+	case BatchActionCopy:
+		return fs.Copy(ctx, dirs, files, job.TaskProps.SrcDir, job.TaskProps.Dst)
+	case BatchActionDelete:
+		activities := fs.NewActivities(model.ActivityDelete, "", "", dirs, files)
+		if job.TaskProps.Trash {
+			err = fs.Trash(ctx, dirs, files)
+		} else {
+			err = fs.Delete(ctx, dirs, files, job.TaskProps.Force, job.TaskProps.Unlink)
+		}
+		if err != nil {
+			return err
+		}
+		filesystem.RecordActivities(activities...)
+		return nil
+	default:
+		return ErrUnknownBatchAction
+	}
+}
+
+// NewBatchTask 新建批量操作任务
+func NewBatchTask(user *model.User, props BatchProps) (Job, error) {
+	props.Total = len(props.Dirs) + len(props.Items)
+	newTask := &BatchTask{
+		User:      user,
+		TaskProps: props,
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewBatchTaskFromModel 从数据库记录中恢复批量操作任务
+func NewBatchTaskFromModel(task *model.Task) (Job, error) {
+	user, err := model.GetUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	newTask := &BatchTask{
+		User:      &user,
+		TaskModel: task,
+	}
+
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
@@ -0,0 +1,176 @@
+package task
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchTask_Props(t *testing.T) {
+	asserts := assert.New(t)
+	task := &BatchTask{
+		User: &model.User{},
+	}
+	asserts.NotEmpty(task.Props())
+	asserts.Equal(BatchTaskType, task.Type())
+	asserts.EqualValues(0, task.Creator())
+	asserts.Nil(task.Model())
+}
+
+func TestBatchTask_Canceled(t *testing.T) {
+	asserts := assert.New(t)
+	task := &BatchTask{
+		User:      &model.User{},
+		TaskModel: &model.Task{Model: gorm.Model{ID: 1}},
+	}
+
+	// 已取消
+	{
+		mock.ExpectQuery("SELECT(.+)tasks(.+)").WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(Canceled))
+		asserts.True(task.Canceled())
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 执行中
+	{
+		mock.ExpectQuery("SELECT(.+)tasks(.+)").WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(Processing))
+		asserts.False(task.Canceled())
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 查询失败
+	{
+		mock.ExpectQuery("SELECT(.+)tasks(.+)").WillReturnError(errors.New("error"))
+		asserts.False(task.Canceled())
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestBatchTask_Do(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 未知操作，逐个记录失败
+	{
+		task := &BatchTask{
+			User:      &model.User{Policy: model.Policy{Type: "local"}},
+			TaskModel: &model.Task{Model: gorm.Model{ID: 1}},
+			TaskProps: BatchProps{Action: "unknown", Dirs: []uint{1}, Items: []uint{2}},
+		}
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(2, task.TaskProps.Done)
+		asserts.Equal(2, task.TaskProps.Failed)
+		asserts.Len(task.TaskProps.Failures, 2)
+		asserts.True(task.TaskProps.Failures[0].IsDir)
+		asserts.EqualValues(2, task.TaskProps.Failures[1].ID)
+		asserts.NotEmpty(task.GetError().Msg)
+	}
+
+	// 从数据库恢复，已全部处理完成
+	{
+		task := &BatchTask{
+			User:      &model.User{Policy: model.Policy{Type: "local"}},
+			TaskModel: &model.Task{Model: gorm.Model{ID: 1}},
+			TaskProps: BatchProps{Action: "unknown", Items: []uint{2}, Done: 1},
+		}
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(task.GetError())
+	}
+}
+
+func TestNewBatchTask(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		job, err := NewBatchTask(&model.User{}, BatchProps{Action: BatchActionDelete, Items: []uint{1, 2}})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(2, job.(*BatchTask).TaskProps.Total)
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		job, err := NewBatchTask(&model.User{}, BatchProps{Action: BatchActionDelete})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(job)
+		asserts.Error(err)
+	}
+}
+
+func TestNewBatchTaskFromModel(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		job, err := NewBatchTaskFromModel(&model.Task{Props: `{"action":"move","items":[1],"done":1}`})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(BatchActionMove, job.(*BatchTask).TaskProps.Action)
+		asserts.Equal(1, job.(*BatchTask).TaskProps.Done)
+	}
+
+	// JSON解析失败
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		job, err := NewBatchTaskFromModel(&model.Task{Props: "?"})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.Nil(job)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 任务已结束
+	{
+		asserts.Equal(ErrTaskFinished, Cancel(&model.Task{Status: Complete, Type: BatchTaskType}))
+	}
+
+	// 不支持取消
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		err := Cancel(&model.Task{Status: Queued, Type: MigrateTaskType, Props: "{}"})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(ErrTaskNotCancelable, err)
+	}
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		err := Cancel(&model.Task{Model: gorm.Model{ID: 1}, Status: Processing, Type: BatchTaskType, Props: "{}"})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+	}
+}
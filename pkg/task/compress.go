@@ -10,6 +10,7 @@ import (
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 )
 
@@ -113,7 +114,7 @@ func (job *CompressTask) Do() {
 	defer zipFile.Close()
 
 	// 开始压缩
-	ctx := context.Background()
+	ctx := context.WithValue(context.Background(), fsctx.TaskCtx, job.TaskModel)
 	err = fs.Compress(ctx, zipFile, job.TaskProps.Dirs, job.TaskProps.Files, false)
 	if err != nil {
 		job.SetErrorMsg(err.Error())
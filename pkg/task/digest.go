@@ -0,0 +1,158 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// digestBatchSize 每批读取的文件数，每批处理完成后持久化进度
+const digestBatchSize = 100
+
+// DigestTask 摘要计算任务，为未记录内容摘要的旧文件计算摘要，以便查找重复文件
+type DigestTask struct {
+	User      *model.User
+	TaskModel *model.Task
+	TaskProps DigestProps
+	Err       *JobError
+}
+
+// DigestProps 摘要计算任务属性，任务恢复后从上次处理的文件之后继续
+type DigestProps struct {
+	After uint `json:"after"` // 最后处理的文件 ID
+
+	// 进度
+	Done   int `json:"done"`   // 已处理的文件数
+	Failed int `json:"failed"` // 计算失败的文件数
+}
+
+// Props 获取任务属性
+func (job *DigestTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 获取任务状态
+func (job *DigestTask) Type() int {
+	return DigestTaskType
+}
+
+// Creator 获取创建者ID
+func (job *DigestTask) Creator() uint {
+	return job.User.ID
+}
+
+// Model 获取任务的数据库模型
+func (job *DigestTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 设定状态
+func (job *DigestTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 设定任务失败信息
+func (job *DigestTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+// SetErrorMsg 设定任务失败信息
+func (job *DigestTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任务失败信息
+func (job *DigestTask) GetError() *JobError {
+	return job.Err
+}
+
+// Canceled 返回任务是否已被用户取消
+func (job *DigestTask) Canceled() bool {
+	status, err := job.TaskModel.GetStatus()
+	return err == nil && status == Canceled
+}
+
+// Do 开始执行任务
+func (job *DigestTask) Do() {
+	job.TaskModel.SetProgress(HashingProgress)
+
+	fs := &filesystem.FileSystem{User: job.User}
+	var lastErr error
+	for {
+		files, err := model.GetFilesWithoutDigest(job.User.ID, job.TaskProps.After, digestBatchSize)
+		if err != nil {
+			job.SetErrorMsg("Failed to list files.", err)
+			return
+		}
+
+		if len(files) == 0 {
+			break
+		}
+
+		for i := range files {
+			if err := fs.ComputeDigest(context.Background(), &files[i]); err != nil {
+				util.Log().Warning("Digest task %d failed to compute digest of file %q: %s", job.TaskModel.ID, files[i].Name, err)
+				job.TaskProps.Failed++
+				lastErr = err
+			}
+
+			job.TaskProps.After = files[i].ID
+			job.TaskProps.Done++
+		}
+
+		job.TaskModel.SetProps(job.Props())
+		if job.Canceled() {
+			util.Log().Info("Digest task %d canceled by user.", job.TaskModel.ID)
+			return
+		}
+	}
+
+	if job.TaskProps.Failed > 0 {
+		job.SetErrorMsg(fmt.Sprintf("Failed to compute digest of %d file(s).", job.TaskProps.Failed), lastErr)
+	}
+}
+
+// NewDigestTask 新建摘要计算任务
+func NewDigestTask(user *model.User) (Job, error) {
+	newTask := &DigestTask{
+		User: user,
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewDigestTaskFromModel 从数据库记录中恢复摘要计算任务
+func NewDigestTaskFromModel(task *model.Task) (Job, error) {
+	user, err := model.GetUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	newTask := &DigestTask{
+		User:      &user,
+		TaskModel: task,
+	}
+
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
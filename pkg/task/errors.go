@@ -5,4 +5,10 @@ import "errors"
 var (
 	// ErrUnknownTaskType 未知任务类型
 	ErrUnknownTaskType = errors.New("unknown task type")
+	// ErrUnknownBatchAction 未知批量操作类型
+	ErrUnknownBatchAction = errors.New("unknown batch action")
+	// ErrTaskNotCancelable 任务不支持取消
+	ErrTaskNotCancelable = errors.New("task cannot be canceled")
+	// ErrTaskFinished 任务已结束
+	ErrTaskFinished = errors.New("task already finished")
 )
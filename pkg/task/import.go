@@ -161,7 +161,7 @@ func (job *ImportTask) Do() {
 			}
 
 			// 插入文件记录
-			_, err := fs.AddFile(context.Background(), parentFolder, &fileHeader)
+			file, err := fs.AddFile(context.Background(), parentFolder, &fileHeader)
 			if err != nil {
 				util.Log().Warning("Importing task cannot insert user file %q: %s",
 					object.RelativePath, err)
@@ -169,8 +169,11 @@ func (job *ImportTask) Do() {
 					job.SetErrorMsg("Insufficient storage capacity.", err)
 					return
 				}
+				continue
 			}
 
+			filesystem.RecordChanges(model.NewFileChange(model.ChangeCreated, file, path.Join(virtualPath, file.Name)))
+
 		}
 	}
 }
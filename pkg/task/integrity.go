@@ -0,0 +1,195 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+const (
+	// integrityBatchSize 每批读取的文件数，每批处理完成后持久化进度
+	integrityBatchSize = 100
+	// integrityMaxFlagged 任务属性中最多记录的问题文件数
+	integrityMaxFlagged = 1000
+)
+
+// IntegrityTask 文件完整性校验任务，重新读取物理文件并与记录的摘要比对，
+// 标记丢失或损坏的文件，并可从镜像策略的其他副本修复
+type IntegrityTask struct {
+	User      *model.User
+	TaskModel *model.Task
+	TaskProps IntegrityProps
+	Err       *JobError
+}
+
+// IntegrityProps 完整性校验任务属性，任务恢复后从上次处理的文件之后继续
+type IntegrityProps struct {
+	UserID   uint `json:"user_id,omitempty"`   // 只校验此用户的文件，为 0 时不限制
+	PolicyID uint `json:"policy_id,omitempty"` // 只校验此存储策略中的文件，为 0 时不限制
+	Repair   bool `json:"repair"`              // 是否从镜像策略的其他副本修复
+	After    uint `json:"after"`               // 最后处理的文件 ID
+
+	// 进度
+	Checked  int    `json:"checked"`           // 已校验的文件数
+	Missing  int    `json:"missing"`           // 丢失的文件数
+	Corrupt  int    `json:"corrupt"`           // 损坏的文件数
+	Repaired int    `json:"repaired"`          // 修复的副本数
+	Failed   int    `json:"failed"`            // 校验出错的文件数
+	Flagged  []uint `json:"flagged,omitempty"` // 丢失或损坏的文件 ID
+}
+
+// Props 获取任务属性
+func (job *IntegrityTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 获取任务状态
+func (job *IntegrityTask) Type() int {
+	return IntegrityTaskType
+}
+
+// Creator 获取创建者ID
+func (job *IntegrityTask) Creator() uint {
+	return job.User.ID
+}
+
+// Model 获取任务的数据库模型
+func (job *IntegrityTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 设定状态
+func (job *IntegrityTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 设定任务失败信息
+func (job *IntegrityTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+// SetErrorMsg 设定任务失败信息
+func (job *IntegrityTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任务失败信息
+func (job *IntegrityTask) GetError() *JobError {
+	return job.Err
+}
+
+// Canceled 返回任务是否已被用户取消
+func (job *IntegrityTask) Canceled() bool {
+	status, err := job.TaskModel.GetStatus()
+	return err == nil && status == Canceled
+}
+
+// Do 开始执行任务
+func (job *IntegrityTask) Do() {
+	job.TaskModel.SetProgress(VerifyingProgress)
+
+	fs := &filesystem.FileSystem{User: job.User}
+	var lastErr error
+	for {
+		files, err := model.GetFilesToVerify(job.TaskProps.UserID, job.TaskProps.PolicyID, job.TaskProps.After, integrityBatchSize)
+		if err != nil {
+			job.SetErrorMsg("Failed to list files.", err)
+			return
+		}
+
+		if len(files) == 0 {
+			break
+		}
+
+		for i := range files {
+			if err := job.verify(fs, &files[i]); err != nil {
+				util.Log().Warning("Integrity task %d failed to verify file %q: %s", job.TaskModel.ID, files[i].Name, err)
+				job.TaskProps.Failed++
+				lastErr = err
+			}
+		}
+
+		job.TaskModel.SetProps(job.Props())
+		if job.Canceled() {
+			util.Log().Info("Integrity task %d canceled by user.", job.TaskModel.ID)
+			return
+		}
+	}
+
+	if job.TaskProps.Failed > 0 {
+		job.SetErrorMsg(fmt.Sprintf("Failed to verify %d file(s).", job.TaskProps.Failed), lastErr)
+	}
+}
+
+// verify 校验单个文件并统计结果
+func (job *IntegrityTask) verify(fs *filesystem.FileSystem, file *model.File) error {
+	job.TaskProps.After = file.ID
+	job.TaskProps.Checked++
+
+	res, err := fs.CheckIntegrity(context.Background(), file, job.TaskProps.Repair)
+	if res == nil {
+		return err
+	}
+
+	job.TaskProps.Repaired += res.Repaired
+	switch res.Status {
+	case filesystem.IntegrityMissing:
+		job.TaskProps.Missing++
+	case filesystem.IntegrityCorrupt:
+		job.TaskProps.Corrupt++
+	default:
+		return err
+	}
+
+	if len(job.TaskProps.Flagged) < integrityMaxFlagged {
+		job.TaskProps.Flagged = append(job.TaskProps.Flagged, file.ID)
+	}
+
+	return err
+}
+
+// NewIntegrityTask 新建完整性校验任务
+func NewIntegrityTask(user *model.User, props IntegrityProps) (Job, error) {
+	newTask := &IntegrityTask{
+		User:      user,
+		TaskProps: props,
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewIntegrityTaskFromModel 从数据库记录中恢复完整性校验任务
+func NewIntegrityTaskFromModel(task *model.Task) (Job, error) {
+	user, err := model.GetUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	newTask := &IntegrityTask{
+		User:      &user,
+		TaskModel: task,
+	}
+
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
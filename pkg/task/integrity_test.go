@@ -0,0 +1,126 @@
+package task
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntegrityTask_Props(t *testing.T) {
+	asserts := assert.New(t)
+	task := &IntegrityTask{
+		User: &model.User{},
+	}
+	asserts.NotEmpty(task.Props())
+	asserts.Equal(IntegrityTaskType, task.Type())
+	asserts.EqualValues(0, task.Creator())
+	asserts.Nil(task.Model())
+}
+
+func TestIntegrityTask_Do(t *testing.T) {
+	asserts := assert.New(t)
+	newTask := func() *IntegrityTask {
+		return &IntegrityTask{
+			User:      &model.User{Model: gorm.Model{ID: 1}},
+			TaskModel: &model.Task{Model: gorm.Model{ID: 1}},
+			TaskProps: IntegrityProps{UserID: 1},
+		}
+	}
+
+	// 列取文件失败
+	{
+		task := newTask()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NotEmpty(task.GetError().Msg)
+	}
+
+	// 文件丢失，标记并记录进度
+	{
+		task := newTask()
+		asserts.NoError(cache.Set("policy_180", model.Policy{Model: gorm.Model{ID: 180}, Type: "local"}, 0))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(0, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name", "size"}).AddRow(3, 180, "tests/integrity_not_exist", 1))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)tasks(.+)").WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(Processing))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(3, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(task.GetError())
+		asserts.EqualValues(3, task.TaskProps.After)
+		asserts.Equal(1, task.TaskProps.Checked)
+		asserts.Equal(1, task.TaskProps.Missing)
+		asserts.Equal([]uint{3}, task.TaskProps.Flagged)
+		cache.Deletes([]string{"180"}, "policy_")
+	}
+}
+
+func TestNewIntegrityTask(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		job, err := NewIntegrityTask(&model.User{}, IntegrityProps{PolicyID: 1, Repair: true})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.True(job.(*IntegrityTask).TaskProps.Repair)
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		job, err := NewIntegrityTask(&model.User{}, IntegrityProps{})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(job)
+		asserts.Error(err)
+	}
+}
+
+func TestNewIntegrityTaskFromModel(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		job, err := NewIntegrityTaskFromModel(&model.Task{Props: `{"policy_id":2,"after":5,"checked":2}`})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(2, job.(*IntegrityTask).TaskProps.PolicyID)
+		asserts.EqualValues(5, job.(*IntegrityTask).TaskProps.After)
+	}
+
+	// JSON解析失败
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		job, err := NewIntegrityTaskFromModel(&model.Task{Props: "?"})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.Nil(job)
+	}
+}
@@ -17,6 +17,24 @@ const (
 	ImportTaskType
 	// RecycleTaskType 回收任务
 	RecycleTaskType
+	// TieringTaskType 存储分层迁移任务
+	TieringTaskType
+	// MigrateTaskType 存储策略迁移任务
+	MigrateTaskType
+	// RestoreTaskType 归档文件解冻任务
+	RestoreTaskType
+	// TranscodeTaskType 视频转码任务
+	TranscodeTaskType
+	// BatchTaskType 批量移动/复制/删除任务
+	BatchTaskType
+	// RelocateTaskType 存储路径调整任务
+	RelocateTaskType
+	// DigestTaskType 摘要计算任务
+	DigestTaskType
+	// IntegrityTaskType 文件完整性校验任务
+	IntegrityTaskType
+	// ManifestTaskType 文件清单导出任务
+	ManifestTaskType
 )
 
 // 任务状态
@@ -49,6 +67,14 @@ const (
 	ListingProgress
 	// InsertingProgress 插入中
 	InsertingProgress
+	// TranscodingProgress 转码中
+	TranscodingProgress
+	// BatchingProgress 批量处理中
+	BatchingProgress
+	// HashingProgress 计算摘要中
+	HashingProgress
+	// VerifyingProgress 校验中
+	VerifyingProgress
 )
 
 // Job 任务接口
@@ -63,6 +89,11 @@ type Job interface {
 	GetError() *JobError // 获取任务执行结果，返回nil表示成功完成执行
 }
 
+// Cancelable 可被用户取消的任务
+type Cancelable interface {
+	Canceled() bool // 返回任务是否已被取消
+}
+
 // JobError 任务失败信息
 type JobError struct {
 	Msg   string `json:"msg,omitempty"`
@@ -117,7 +148,43 @@ func GetJobFromModel(task *model.Task) (Job, error) {
 		return NewImportTaskFromModel(task)
 	case RecycleTaskType:
 		return NewRecycleTaskFromModel(task)
+	case TieringTaskType:
+		return NewTieringTaskFromModel(task)
+	case MigrateTaskType:
+		return NewMigrateTaskFromModel(task)
+	case RestoreTaskType:
+		return NewRestoreTaskFromModel(task)
+	case TranscodeTaskType:
+		return NewTranscodeTaskFromModel(task)
+	case BatchTaskType:
+		return NewBatchTaskFromModel(task)
+	case RelocateTaskType:
+		return NewRelocateTaskFromModel(task)
+	case DigestTaskType:
+		return NewDigestTaskFromModel(task)
+	case IntegrityTaskType:
+		return NewIntegrityTaskFromModel(task)
+	case ManifestTaskType:
+		return NewManifestTaskFromModel(task)
 	default:
 		return nil, ErrUnknownTaskType
 	}
 }
+
+// Cancel 取消排队中或执行中的任务，执行中的任务会在处理完当前对象后停止
+func Cancel(task *model.Task) error {
+	if task.Status != Queued && task.Status != Processing {
+		return ErrTaskFinished
+	}
+
+	job, err := GetJobFromModel(task)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := job.(Cancelable); !ok {
+		return ErrTaskNotCancelable
+	}
+
+	return task.SetStatus(Canceled)
+}
@@ -0,0 +1,169 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// ManifestTask 文件清单导出任务，生成用户全部目录和文件的清单并保存至用户空间
+type ManifestTask struct {
+	User      *model.User
+	TaskModel *model.Task
+	TaskProps ManifestProps
+	Err       *JobError
+
+	manifestPath string
+}
+
+// ManifestProps 文件清单导出任务属性
+type ManifestProps struct {
+	Format string `json:"format"` // 清单格式，csv 或 json
+	Dst    string `json:"dst"`    // 清单文件在用户空间中的完整路径
+}
+
+// Props 获取任务属性
+func (job *ManifestTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 获取任务状态
+func (job *ManifestTask) Type() int {
+	return ManifestTaskType
+}
+
+// Creator 获取创建者ID
+func (job *ManifestTask) Creator() uint {
+	return job.User.ID
+}
+
+// Model 获取任务的数据库模型
+func (job *ManifestTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 设定状态
+func (job *ManifestTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 设定任务失败信息
+func (job *ManifestTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+
+	// 删除临时清单文件
+	job.removeManifestFile()
+}
+
+func (job *ManifestTask) removeManifestFile() {
+	if job.manifestPath != "" {
+		if err := os.Remove(job.manifestPath); err != nil {
+			util.Log().Warning("Failed to delete temp manifest file %q: %s", job.manifestPath, err)
+		}
+	}
+}
+
+// SetErrorMsg 设定任务失败信息
+func (job *ManifestTask) SetErrorMsg(msg string) {
+	job.SetError(&JobError{Msg: msg})
+}
+
+// GetError 返回任务失败信息
+func (job *ManifestTask) GetError() *JobError {
+	return job.Err
+}
+
+// Do 开始执行任务
+func (job *ManifestTask) Do() {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystem(job.User)
+	if err != nil {
+		job.SetErrorMsg(err.Error())
+		return
+	}
+	defer fs.Recycle()
+
+	job.TaskModel.SetProgress(ListingProgress)
+
+	// 创建临时清单文件
+	manifestPath := filepath.Join(
+		util.RelativePath(model.GetSettingByName("temp_path")),
+		"manifest",
+		fmt.Sprintf("manifest_%d.%s", time.Now().UnixNano(), job.TaskProps.Format),
+	)
+	manifestFile, err := util.CreatNestedFile(manifestPath)
+	if err != nil {
+		util.Log().Warning("%s", err)
+		job.SetErrorMsg(err.Error())
+		return
+	}
+
+	defer manifestFile.Close()
+	job.manifestPath = manifestPath
+
+	ctx := context.WithValue(context.Background(), fsctx.TaskCtx, job.TaskModel)
+	if err := fs.WriteManifest(ctx, manifestFile, job.TaskProps.Format); err != nil {
+		job.SetErrorMsg(err.Error())
+		return
+	}
+
+	manifestFile.Close()
+	job.TaskModel.SetProgress(TransferringProgress)
+
+	// 上传清单文件
+	if err := fs.UploadFromPath(ctx, manifestPath, job.TaskProps.Dst, 0); err != nil {
+		job.SetErrorMsg(err.Error())
+		return
+	}
+
+	job.removeManifestFile()
+}
+
+// NewManifestTask 新建文件清单导出任务
+func NewManifestTask(user *model.User, format, dst string) (Job, error) {
+	newTask := &ManifestTask{
+		User: user,
+		TaskProps: ManifestProps{
+			Format: format,
+			Dst:    dst,
+		},
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewManifestTaskFromModel 从数据库记录中恢复文件清单导出任务
+func NewManifestTaskFromModel(task *model.Task) (Job, error) {
+	user, err := model.GetActiveUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	newTask := &ManifestTask{
+		User:      &user,
+		TaskModel: task,
+	}
+
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
@@ -0,0 +1,213 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/juju/ratelimit"
+)
+
+// migrateBatchSize 每批处理的文件数，每批处理完成后更新一次进度
+const migrateBatchSize = 100
+
+// MigrateTask 存储策略迁移任务，将用户或整个存储策略的文件迁移至另一存储策略
+type MigrateTask struct {
+	User      *model.User
+	TaskModel *model.Task
+	TaskProps MigrateProps
+	Err       *JobError
+}
+
+// MigrateProps 存储策略迁移任务属性
+type MigrateProps struct {
+	SrcPolicyID uint `json:"src_policy_id"`         // 源存储策略
+	DstPolicyID uint `json:"dst_policy_id"`         // 目标存储策略
+	UserID      uint `json:"user_id,omitempty"`     // 仅迁移此用户的文件，为 0 时迁移所有用户的文件
+	SpeedLimit  int  `json:"speed_limit,omitempty"` // 读取源文件的速度上限，单位字节每秒，为 0 时不限制
+	DryRun      bool `json:"dry_run,omitempty"`     // 仅统计待迁移的文件，不实际迁移
+
+	// 进度
+	Total    int    `json:"total"`    // 已处理的文件数
+	Size     uint64 `json:"size"`     // 已处理的文件大小
+	Migrated int    `json:"migrated"` // 迁移成功的文件数
+	Failed   int    `json:"failed"`   // 迁移失败的文件数
+}
+
+// Props 获取任务属性
+func (job *MigrateTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 获取任务状态
+func (job *MigrateTask) Type() int {
+	return MigrateTaskType
+}
+
+// Creator 获取创建者ID
+func (job *MigrateTask) Creator() uint {
+	return job.User.ID
+}
+
+// Model 获取任务的数据库模型
+func (job *MigrateTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 设定状态
+func (job *MigrateTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 设定任务失败信息
+func (job *MigrateTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+// SetErrorMsg 设定任务失败信息
+func (job *MigrateTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任务失败信息
+func (job *MigrateTask) GetError() *JobError {
+	return job.Err
+}
+
+// Do 开始执行任务
+func (job *MigrateTask) Do() {
+	dst, err := model.GetPolicyByID(job.TaskProps.DstPolicyID)
+	if err != nil {
+		job.SetErrorMsg("Destination policy not exist.", err)
+		return
+	}
+
+	if job.TaskProps.DryRun {
+		job.TaskModel.SetProgress(ListingProgress)
+	} else {
+		job.TaskModel.SetProgress(TransferringProgress)
+	}
+
+	// 所有文件共享同一个限速令牌桶
+	ctx := context.Background()
+	if speed := job.TaskProps.SpeedLimit; speed > 0 {
+		ctx = context.WithValue(ctx, fsctx.SpeedLimitBucketCtx, ratelimit.NewBucketWithRate(float64(speed), int64(speed)))
+	}
+
+	// 从数据库恢复的任务重新统计进度
+	job.TaskProps.Total, job.TaskProps.Size = 0, 0
+	job.TaskProps.Migrated, job.TaskProps.Failed = 0, 0
+
+	var (
+		after   uint
+		lastErr error
+	)
+	users := make(map[uint]*model.User)
+	// 同一物理文件的所有副本会被一并迁移
+	migrated := make(map[string]bool)
+	for {
+		files, err := model.GetFilesByPolicy(job.TaskProps.SrcPolicyID, job.TaskProps.UserID, after, migrateBatchSize)
+		if err != nil {
+			job.SetErrorMsg("Failed to list files.", err)
+			return
+		}
+
+		if len(files) == 0 {
+			break
+		}
+
+		for i := range files {
+			job.TaskProps.Total++
+			job.TaskProps.Size += files[i].Size
+			if job.TaskProps.DryRun {
+				continue
+			}
+
+			if migrated[files[i].SourceName] {
+				job.TaskProps.Migrated++
+				continue
+			}
+
+			if err := job.migrate(ctx, users, &files[i], &dst); err != nil {
+				util.Log().Warning("Migrate task %d failed to migrate file %q: %s", job.TaskModel.ID, files[i].Name, err)
+				job.TaskProps.Failed++
+				lastErr = err
+				continue
+			}
+
+			migrated[files[i].SourceName] = true
+			job.TaskProps.Migrated++
+		}
+
+		after = files[len(files)-1].ID
+		job.TaskModel.SetProps(job.Props())
+	}
+
+	job.TaskModel.SetProps(job.Props())
+	if job.TaskProps.Failed > 0 {
+		job.SetErrorMsg(fmt.Sprintf("Failed to migrate %d file(s).", job.TaskProps.Failed), lastErr)
+	}
+}
+
+// migrate 以文件所有者的身份迁移单个文件
+func (job *MigrateTask) migrate(ctx context.Context, users map[uint]*model.User, file *model.File, dst *model.Policy) error {
+	owner, ok := users[file.UserID]
+	if !ok {
+		user, err := model.GetUserByID(file.UserID)
+		if err != nil {
+			return err
+		}
+
+		owner = &user
+		users[file.UserID] = owner
+	}
+
+	fs := &filesystem.FileSystem{User: owner}
+	return fs.MigrateFile(ctx, file, dst)
+}
+
+// NewMigrateTask 新建存储策略迁移任务
+func NewMigrateTask(user *model.User, props MigrateProps) (Job, error) {
+	newTask := &MigrateTask{
+		User:      user,
+		TaskProps: props,
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewMigrateTaskFromModel 从数据库记录中恢复存储策略迁移任务
+func NewMigrateTaskFromModel(task *model.Task) (Job, error) {
+	user, err := model.GetUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	newTask := &MigrateTask{
+		User:      &user,
+		TaskModel: task,
+	}
+
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
@@ -0,0 +1,214 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// RelocateTask 存储路径调整任务，文件移动后在存储端内部将物理文件复制到按新路径生成的存储路径
+type RelocateTask struct {
+	User      *model.User
+	TaskModel *model.Task
+	TaskProps RelocateProps
+	Err       *JobError
+}
+
+// RelocateProps 存储路径调整任务属性，已处理的文件数随进度持久化，任务恢复后从中断处继续
+type RelocateProps struct {
+	FileIDs []uint `json:"file_ids"` // 待调整的文件
+
+	// 进度
+	Done      int `json:"done"`      // 已处理的文件数
+	Relocated int `json:"relocated"` // 调整成功的文件数
+	Failed    int `json:"failed"`    // 调整失败的文件数
+}
+
+// Props 获取任务属性
+func (job *RelocateTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 获取任务状态
+func (job *RelocateTask) Type() int {
+	return RelocateTaskType
+}
+
+// Creator 获取创建者ID
+func (job *RelocateTask) Creator() uint {
+	return job.User.ID
+}
+
+// Model 获取任务的数据库模型
+func (job *RelocateTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 设定状态
+func (job *RelocateTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 设定任务失败信息
+func (job *RelocateTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+// SetErrorMsg 设定任务失败信息
+func (job *RelocateTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任务失败信息
+func (job *RelocateTask) GetError() *JobError {
+	return job.Err
+}
+
+// Do 开始执行任务
+func (job *RelocateTask) Do() {
+	job.TaskModel.SetProgress(TransferringProgress)
+
+	concurrency := model.GetIntSetting("relocate_concurrency", 4)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	fs := &filesystem.FileSystem{User: job.User}
+	var lastErr error
+	for job.TaskProps.Done < len(job.TaskProps.FileIDs) {
+		end := job.TaskProps.Done + concurrency
+		if end > len(job.TaskProps.FileIDs) {
+			end = len(job.TaskProps.FileIDs)
+		}
+
+		// 已被删除的文件无需处理
+		files, err := model.GetFilesByIDs(job.TaskProps.FileIDs[job.TaskProps.Done:end], job.User.ID)
+		if err != nil {
+			job.SetErrorMsg("Failed to list files.", err)
+			return
+		}
+
+		errs := make([]error, len(files))
+		wg := sync.WaitGroup{}
+		for i := range files {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = fs.RelocateFile(context.Background(), &files[i])
+			}(i)
+		}
+		wg.Wait()
+
+		for i, err := range errs {
+			if err != nil {
+				util.Log().Warning("Relocate task %d failed to relocate file %q: %s", job.TaskModel.ID, files[i].Name, err)
+				job.TaskProps.Failed++
+				lastErr = err
+				continue
+			}
+
+			job.TaskProps.Relocated++
+		}
+
+		job.TaskProps.Done = end
+		job.TaskModel.SetProps(job.Props())
+	}
+
+	if job.TaskProps.Failed > 0 {
+		job.SetErrorMsg(fmt.Sprintf("Failed to relocate %d file(s).", job.TaskProps.Failed), lastErr)
+	}
+}
+
+// SubmitRelocateTask 列出移动后的对象中所在存储策略需同步调整存储路径的文件，
+// 并提交存储路径调整任务，没有需要调整的文件时不创建任务
+func SubmitRelocateTask(user *model.User, dirs, files []uint) error {
+	var candidates []model.File
+	if len(files) > 0 {
+		res, err := model.GetFilesByIDs(files, user.ID)
+		if err != nil {
+			return err
+		}
+		candidates = append(candidates, res...)
+	}
+
+	if len(dirs) > 0 {
+		folders, err := model.GetRecursiveChildFolder(dirs, user.ID, true)
+		if err != nil {
+			return err
+		}
+
+		res, err := model.GetChildFilesOfFolders(&folders)
+		if err != nil {
+			return err
+		}
+		candidates = append(candidates, res...)
+	}
+
+	ids := make([]uint, 0, len(candidates))
+	for i := range candidates {
+		if candidates[i].GetPolicy().IsRelocateOnMove() {
+			ids = append(ids, candidates[i].ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	job, err := NewRelocateTask(user, ids)
+	if err != nil {
+		return err
+	}
+
+	TaskPoll.Submit(job)
+	return nil
+}
+
+// NewRelocateTask 新建存储路径调整任务
+func NewRelocateTask(user *model.User, fileIDs []uint) (Job, error) {
+	newTask := &RelocateTask{
+		User: user,
+		TaskProps: RelocateProps{
+			FileIDs: fileIDs,
+		},
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewRelocateTaskFromModel 从数据库记录中恢复存储路径调整任务
+func NewRelocateTaskFromModel(task *model.Task) (Job, error) {
+	user, err := model.GetUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	newTask := &RelocateTask{
+		User:      &user,
+		TaskModel: task,
+	}
+
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
@@ -0,0 +1,147 @@
+package task
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelocateTask_Props(t *testing.T) {
+	asserts := assert.New(t)
+	task := &RelocateTask{
+		User: &model.User{},
+	}
+	asserts.NotEmpty(task.Props())
+	asserts.Equal(RelocateTaskType, task.Type())
+	asserts.EqualValues(0, task.Creator())
+	asserts.Nil(task.Model())
+}
+
+func TestRelocateTask_Do(t *testing.T) {
+	asserts := assert.New(t)
+	newTask := func() *RelocateTask {
+		return &RelocateTask{
+			User:      &model.User{Model: gorm.Model{ID: 1}},
+			TaskModel: &model.Task{Model: gorm.Model{ID: 1}},
+			TaskProps: RelocateProps{FileIDs: []uint{1, 2}},
+		}
+	}
+
+	// 列取文件失败
+	{
+		task := newTask()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NotEmpty(task.GetError().Msg)
+		asserts.Equal(0, task.TaskProps.Done)
+	}
+
+	// 文件已被删除，记录进度
+	{
+		task := newTask()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(task.GetError())
+		asserts.Equal(2, task.TaskProps.Done)
+	}
+
+	// 从数据库恢复，已全部处理完成
+	{
+		task := newTask()
+		task.TaskProps.Done = 2
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(task.GetError())
+	}
+}
+
+func TestSubmitRelocateTask(t *testing.T) {
+	asserts := assert.New(t)
+	user := &model.User{Model: gorm.Model{ID: 1}}
+
+	// 列取文件失败
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("error"))
+		asserts.Error(SubmitRelocateTask(user, nil, []uint{1}))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 没有需要调整的文件
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id"}).AddRow(1, 1))
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "dir_name_rule"}).AddRow(1, "uploads/{path}"))
+		asserts.NoError(SubmitRelocateTask(user, nil, []uint{1}))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestNewRelocateTask(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		job, err := NewRelocateTask(&model.User{}, []uint{1})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NotNil(job)
+		asserts.NoError(err)
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		job, err := NewRelocateTask(&model.User{}, []uint{1})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(job)
+		asserts.Error(err)
+	}
+}
+
+func TestNewRelocateTaskFromModel(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		job, err := NewRelocateTaskFromModel(&model.Task{Props: `{"file_ids":[1,2],"done":1}`})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(1, job.(*RelocateTask).TaskProps.Done)
+	}
+
+	// JSON解析失败
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		job, err := NewRelocateTaskFromModel(&model.Task{Props: "?"})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.Nil(job)
+	}
+}
@@ -0,0 +1,140 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+)
+
+// RestoreTask 归档文件解冻任务，发起解冻请求并等待解冻完成
+type RestoreTask struct {
+	User      *model.User
+	TaskModel *model.Task
+	TaskProps RestoreProps
+	Err       *JobError
+}
+
+// RestoreProps 归档文件解冻任务属性
+type RestoreProps struct {
+	FileID uint `json:"file_id"` // 待解冻的文件
+}
+
+// Props 获取任务属性
+func (job *RestoreTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 获取任务状态
+func (job *RestoreTask) Type() int {
+	return RestoreTaskType
+}
+
+// Creator 获取创建者ID
+func (job *RestoreTask) Creator() uint {
+	return job.User.ID
+}
+
+// Model 获取任务的数据库模型
+func (job *RestoreTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 设定状态
+func (job *RestoreTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 设定任务失败信息
+func (job *RestoreTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+// SetErrorMsg 设定任务失败信息
+func (job *RestoreTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任务失败信息
+func (job *RestoreTask) GetError() *JobError {
+	return job.Err
+}
+
+// Do 开始执行任务
+func (job *RestoreTask) Do() {
+	files, err := model.GetFilesByIDs([]uint{job.TaskProps.FileID}, job.User.ID)
+	if err != nil || len(files) == 0 {
+		job.SetErrorMsg("File not exist.", err)
+		return
+	}
+
+	fs := &filesystem.FileSystem{User: job.User}
+
+	// 从数据库恢复的任务重新发起解冻请求，已有进行中的请求时不会重复解冻
+	ctx := context.Background()
+	restored, err := fs.RestoreFile(ctx, &files[0])
+	if err != nil {
+		job.SetErrorMsg("Failed to restore file.", err)
+		return
+	}
+
+	interval := time.Duration(model.GetIntSetting("restore_poll_interval", 60)) * time.Second
+	deadline := time.Now().Add(time.Duration(model.GetIntSetting("restore_timeout", 172800)) * time.Second)
+	for !restored {
+		if time.Now().After(deadline) {
+			job.SetErrorMsg("Timed out waiting for file to be restored.", nil)
+			return
+		}
+
+		time.Sleep(interval)
+		restored, err = fs.IsFileRestored(ctx, &files[0])
+		if err != nil {
+			job.SetErrorMsg("Failed to query restore status.", err)
+			return
+		}
+	}
+}
+
+// NewRestoreTask 新建归档文件解冻任务
+func NewRestoreTask(user *model.User, fileID uint) (Job, error) {
+	newTask := &RestoreTask{
+		User:      user,
+		TaskProps: RestoreProps{FileID: fileID},
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewRestoreTaskFromModel 从数据库记录中恢复归档文件解冻任务
+func NewRestoreTaskFromModel(task *model.Task) (Job, error) {
+	user, err := model.GetUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	newTask := &RestoreTask{
+		User:      &user,
+		TaskModel: task,
+	}
+
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
@@ -0,0 +1,65 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestoreTask_Props(t *testing.T) {
+	asserts := assert.New(t)
+	task := &RestoreTask{
+		User:      &model.User{},
+		TaskProps: RestoreProps{FileID: 3},
+	}
+	asserts.Equal(`{"file_id":3}`, task.Props())
+	asserts.Equal(RestoreTaskType, task.Type())
+	asserts.EqualValues(0, task.Creator())
+	asserts.Nil(task.Model())
+}
+
+func TestRestoreTask_Do(t *testing.T) {
+	asserts := assert.New(t)
+	task := &RestoreTask{
+		User: &model.User{},
+		TaskModel: &model.Task{
+			Model: gorm.Model{ID: 1},
+		},
+		TaskProps: RestoreProps{FileID: 3},
+	}
+
+	// 文件不存在
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NotEmpty(task.GetError().Msg)
+	}
+
+	// 存储策略不支持归档存储，无需解冻
+	{
+		task.Err = nil
+		cache.Set("policy_31", model.Policy{Model: gorm.Model{ID: 31}, Type: "mock"}, 0)
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id"}).AddRow(3, 31))
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(task.GetError())
+	}
+}
+
+func TestNewRestoreTaskFromModel(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)users(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	job, err := NewRestoreTaskFromModel(&model.Task{UserID: 1, Props: `{"file_id":3}`})
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.EqualValues(3, job.(*RestoreTask).TaskProps.FileID)
+}
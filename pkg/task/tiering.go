@@ -0,0 +1,156 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// TieringTask 存储分层迁移任务，将用户的文件从源存储策略迁移至目标存储策略
+type TieringTask struct {
+	User      *model.User
+	TaskModel *model.Task
+	TaskProps TieringProps
+	Err       *JobError
+}
+
+// TieringProps 存储分层迁移任务属性
+type TieringProps struct {
+	SrcPolicyID uint   `json:"src_policy_id"` // 源存储策略
+	DstPolicyID uint   `json:"dst_policy_id"` // 目标存储策略
+	FileIDs     []uint `json:"file_ids"`      // 待迁移的文件
+}
+
+// Props 获取任务属性
+func (job *TieringTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 获取任务状态
+func (job *TieringTask) Type() int {
+	return TieringTaskType
+}
+
+// Creator 获取创建者ID
+func (job *TieringTask) Creator() uint {
+	return job.User.ID
+}
+
+// Model 获取任务的数据库模型
+func (job *TieringTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 设定状态
+func (job *TieringTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 设定任务失败信息
+func (job *TieringTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+// SetErrorMsg 设定任务失败信息
+func (job *TieringTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任务失败信息
+func (job *TieringTask) GetError() *JobError {
+	return job.Err
+}
+
+// Do 开始执行任务
+func (job *TieringTask) Do() {
+	dst, err := model.GetPolicyByID(job.TaskProps.DstPolicyID)
+	if err != nil {
+		job.SetErrorMsg("Destination policy not exist.", err)
+		return
+	}
+
+	files, err := model.GetFilesByIDs(job.TaskProps.FileIDs, job.User.ID)
+	if err != nil {
+		job.SetErrorMsg("Failed to find files.", err)
+		return
+	}
+
+	job.TaskModel.SetProgress(TransferringProgress)
+	fs := &filesystem.FileSystem{User: job.User}
+
+	var (
+		failed  int
+		lastErr error
+	)
+	for i := range files {
+		// 文件已被迁移或删除
+		if files[i].PolicyID != job.TaskProps.SrcPolicyID {
+			continue
+		}
+
+		if err := fs.MigrateFile(context.Background(), &files[i], &dst); err != nil {
+			util.Log().Warning("Tiering task %d failed to migrate file %q: %s", job.TaskModel.ID, files[i].Name, err)
+			failed++
+			lastErr = err
+		}
+	}
+
+	if failed > 0 {
+		job.SetErrorMsg(fmt.Sprintf("Failed to migrate %d file(s).", failed), lastErr)
+	}
+}
+
+// NewTieringTask 新建存储分层迁移任务
+func NewTieringTask(user, src, dst uint, files []uint) (Job, error) {
+	creator, err := model.GetUserByID(user)
+	if err != nil {
+		return nil, err
+	}
+
+	newTask := &TieringTask{
+		User: &creator,
+		TaskProps: TieringProps{
+			SrcPolicyID: src,
+			DstPolicyID: dst,
+			FileIDs:     files,
+		},
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewTieringTaskFromModel 从数据库记录中恢复存储分层迁移任务
+func NewTieringTaskFromModel(task *model.Task) (Job, error) {
+	user, err := model.GetUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	newTask := &TieringTask{
+		User:      &user,
+		TaskModel: task,
+	}
+
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
@@ -94,6 +94,7 @@ func (job *TransferTask) Do() {
 		return
 	}
 
+	ctx := context.WithValue(context.Background(), fsctx.TaskCtx, job.TaskModel)
 	successCount := 0
 	errorList := make([]string, 0, len(job.TaskProps.Src))
 	for _, file := range job.TaskProps.Src {
@@ -116,7 +117,7 @@ func (job *TransferTask) Do() {
 
 			// 切换为从机节点处理上传
 			fs.SwitchToSlaveHandler(node)
-			err = fs.UploadFromStream(context.Background(), &fsctx.FileStream{
+			err = fs.UploadFromStream(ctx, &fsctx.FileStream{
 				File:        nil,
 				Size:        job.TaskProps.SrcSizes[file],
 				Name:        path.Base(dst),
@@ -125,7 +126,7 @@ func (job *TransferTask) Do() {
 			}, false)
 		} else {
 			// 主机节点中转
-			err = fs.UploadFromPath(context.Background(), file, dst, 0)
+			err = fs.UploadFromPath(ctx, file, dst, 0)
 		}
 
 		if err != nil {
@@ -0,0 +1,126 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+)
+
+// TranscodeTask 视频转码任务，将视频转码为 HLS 文件用于在线播放
+type TranscodeTask struct {
+	User      *model.User
+	TaskModel *model.Task
+	TaskProps TranscodeProps
+	Err       *JobError
+}
+
+// TranscodeProps 视频转码任务属性
+type TranscodeProps struct {
+	FileID uint `json:"file_id"` // 待转码的视频文件
+}
+
+// Props 获取任务属性
+func (job *TranscodeTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 获取任务状态
+func (job *TranscodeTask) Type() int {
+	return TranscodeTaskType
+}
+
+// Creator 获取创建者ID
+func (job *TranscodeTask) Creator() uint {
+	return job.User.ID
+}
+
+// Model 获取任务的数据库模型
+func (job *TranscodeTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 设定状态
+func (job *TranscodeTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 设定任务失败信息
+func (job *TranscodeTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+// SetErrorMsg 设定任务失败信息
+func (job *TranscodeTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任务失败信息
+func (job *TranscodeTask) GetError() *JobError {
+	return job.Err
+}
+
+// Do 开始执行任务
+func (job *TranscodeTask) Do() {
+	files, err := model.GetFilesByIDs([]uint{job.TaskProps.FileID}, job.User.ID)
+	if err != nil || len(files) == 0 {
+		job.SetErrorMsg("File not exist.", err)
+		return
+	}
+
+	fs, err := filesystem.NewFileSystem(job.User)
+	if err != nil {
+		job.SetErrorMsg(err.Error(), nil)
+		return
+	}
+	defer fs.Recycle()
+
+	job.TaskModel.SetProgress(TranscodingProgress)
+	if err := fs.TranscodeHLS(context.Background(), &files[0]); err != nil {
+		job.SetErrorMsg("Failed to transcode video.", err)
+		return
+	}
+}
+
+// NewTranscodeTask 新建视频转码任务
+func NewTranscodeTask(user *model.User, fileID uint) (Job, error) {
+	newTask := &TranscodeTask{
+		User:      user,
+		TaskProps: TranscodeProps{FileID: fileID},
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewTranscodeTaskFromModel 从数据库记录中恢复视频转码任务
+func NewTranscodeTaskFromModel(task *model.Task) (Job, error) {
+	user, err := model.GetUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	newTask := &TranscodeTask{
+		User:      &user,
+		TaskModel: task,
+	}
+
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
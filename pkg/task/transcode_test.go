@@ -0,0 +1,52 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranscodeTask_Props(t *testing.T) {
+	asserts := assert.New(t)
+	task := &TranscodeTask{
+		User:      &model.User{},
+		TaskProps: TranscodeProps{FileID: 3},
+	}
+	asserts.Equal(`{"file_id":3}`, task.Props())
+	asserts.Equal(TranscodeTaskType, task.Type())
+	asserts.EqualValues(0, task.Creator())
+	asserts.Nil(task.Model())
+}
+
+func TestTranscodeTask_Do(t *testing.T) {
+	asserts := assert.New(t)
+	task := &TranscodeTask{
+		User: &model.User{},
+		TaskModel: &model.Task{
+			Model: gorm.Model{ID: 1},
+		},
+		TaskProps: TranscodeProps{FileID: 3},
+	}
+
+	// 文件不存在
+	mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	task.Do()
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NotEmpty(task.GetError().Msg)
+}
+
+func TestNewTranscodeTaskFromModel(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)users(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	job, err := NewTranscodeTaskFromModel(&model.Task{UserID: 1, Props: `{"file_id":3}`})
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.EqualValues(3, job.(*TranscodeTask).TaskProps.FileID)
+}
@@ -16,6 +16,12 @@ type GeneralWorker struct {
 
 // Do 执行任务
 func (worker *GeneralWorker) Do(job Job) {
+	// 排队期间已被取消的任务不再执行
+	if c, ok := job.(Cancelable); ok && c.Canceled() {
+		util.Log().Debug("Task canceled before execution.")
+		return
+	}
+
 	util.Log().Debug("Start executing task.")
 	job.SetStatus(Processing)
 
@@ -31,6 +37,12 @@ func (worker *GeneralWorker) Do(job Job) {
 	// 开始执行任务
 	job.Do()
 
+	// 执行中被取消的任务保持取消状态
+	if c, ok := job.(Cancelable); ok && c.Canceled() {
+		util.Log().Debug("Task canceled.")
+		return
+	}
+
 	// 任务执行失败
 	if err := job.GetError(); err != nil {
 		util.Log().Debug("Failed to execute task.")
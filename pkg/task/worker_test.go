@@ -79,3 +79,38 @@ func TestGeneralWorker_Do(t *testing.T) {
 	}
 
 }
+
+type MockCancelableJob struct {
+	MockJob
+	IsCanceled bool
+}
+
+func (job *MockCancelableJob) Canceled() bool {
+	return job.IsCanceled
+}
+
+func TestGeneralWorker_DoCanceled(t *testing.T) {
+	asserts := assert.New(t)
+	worker := &GeneralWorker{}
+
+	// 排队期间被取消
+	{
+		job := &MockCancelableJob{IsCanceled: true}
+		job.Status = Canceled
+		job.DoFunc = func() {
+			panic("should not be executed")
+		}
+		worker.Do(job)
+		asserts.Equal(Canceled, job.Status)
+	}
+
+	// 执行中被取消
+	{
+		job := &MockCancelableJob{}
+		job.DoFunc = func() {
+			job.IsCanceled = true
+		}
+		worker.Do(job)
+		asserts.Equal(Processing, job.Status)
+	}
+}
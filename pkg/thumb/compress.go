@@ -0,0 +1,59 @@
+package thumb
+
+import (
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// maxCompressPixels 压缩图片时允许解码的最大像素数，避免占用过多内存
+const maxCompressPixels = 100 << 20
+
+var ErrImageTooLarge = errors.New("image is too large to compress")
+
+// CanCompress 返回给定文件名的图片是否可以压缩，动图等格式压缩后会丢失内容，不做处理
+func CanCompress(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	}
+
+	return false
+}
+
+// Compress 将图片等比缩放至 maxSize 以内并以原格式重新编码写入 w，
+// maxSize 为 0 时不缩放，jpg 图片使用 quality 质量编码
+func Compress(file io.ReadSeeker, name string, maxSize uint, quality int, w io.Writer) error {
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return err
+	}
+
+	if config.Width*config.Height > maxCompressPixels {
+		return ErrImageTooLarge
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	img, err := NewThumbFromFile(file, name)
+	if err != nil {
+		return err
+	}
+
+	if maxSize > 0 {
+		img.GetThumb(maxSize, maxSize)
+	}
+
+	if img.ext == "png" {
+		encoder := png.Encoder{CompressionLevel: png.BestCompression}
+		return encoder.Encode(w, img.src)
+	}
+
+	return jpeg.Encode(w, img.src, &jpeg.Options{Quality: quality})
+}
@@ -0,0 +1,106 @@
+package thumb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gofrs/uuid"
+)
+
+func init() {
+	RegisterGenerator(&PdfGenerator{})
+}
+
+// PdfGenerator renders the first page of PDF files using pdftoppm from poppler.
+type PdfGenerator struct {
+	exts        []string
+	lastRawExts string
+}
+
+func (p *PdfGenerator) Generate(ctx context.Context, file io.Reader, src string, name string, options map[string]string) (*Result, error) {
+	const (
+		thumbPdfPath = "thumb_pdf_path"
+		thumbPdfExts = "thumb_pdf_exts"
+		tempPath     = "temp_path"
+	)
+	pdfOpts := model.GetSettingByNames(thumbPdfPath, thumbPdfExts, tempPath)
+
+	if p.lastRawExts != pdfOpts[thumbPdfExts] {
+		p.exts = strings.Split(pdfOpts[thumbPdfExts], ",")
+		p.lastRawExts = pdfOpts[thumbPdfExts]
+	}
+
+	if !util.IsInExtensionList(p.exts, name) {
+		return nil, fmt.Errorf("unsupported document format: %w", ErrPassThrough)
+	}
+
+	tempOutputPath := filepath.Join(
+		util.RelativePath(pdfOpts[tempPath]),
+		"thumb",
+		fmt.Sprintf("pdf_%s", uuid.Must(uuid.NewV4()).String()),
+	)
+
+	tempInputPath := src
+	if tempInputPath == "" {
+		// If not local policy files, download to temp folder
+		tempInputPath = filepath.Join(
+			util.RelativePath(pdfOpts[tempPath]),
+			"thumb",
+			fmt.Sprintf("pdf_%s%s", uuid.Must(uuid.NewV4()).String(), filepath.Ext(name)),
+		)
+
+		tempInputFile, err := util.CreatNestedFile(tempInputPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temp file: %w", err)
+		}
+
+		defer os.Remove(tempInputPath)
+		defer tempInputFile.Close()
+
+		if _, err = io.Copy(tempInputFile, file); err != nil {
+			return nil, fmt.Errorf("failed to write input file: %w", err)
+		}
+
+		tempInputFile.Close()
+	}
+
+	// Render the first page, scaled so that its longer side fits the thumbnail
+	w, h := thumbSize(options)
+	if h > w {
+		w = h
+	}
+
+	cmd := exec.CommandContext(ctx, pdfOpts[thumbPdfPath], "-f", "1", "-l", "1", "-singlefile",
+		"-scale-to", strconv.Itoa(int(w)), "-png", tempInputPath, tempOutputPath)
+
+	var stdErr bytes.Buffer
+	cmd.Stderr = &stdErr
+
+	if err := cmd.Run(); err != nil {
+		util.Log().Warning("Failed to invoke pdftoppm: %s", stdErr.String())
+		return nil, fmt.Errorf("failed to invoke pdftoppm: %w", err)
+	}
+
+	return &Result{
+		Path:     tempOutputPath + ".png",
+		Continue: true,
+		Cleanup:  []func(){func() { _ = os.Remove(tempOutputPath + ".png") }},
+	}, nil
+}
+
+func (p *PdfGenerator) Priority() int {
+	return 75
+}
+
+func (p *PdfGenerator) EnableFlag() string {
+	return "thumb_pdf_enabled"
+}
@@ -25,6 +25,8 @@ func TestGenerator(ctx context.Context, name, executable string) (string, error)
 		return testLibreOfficeGenerator(ctx, executable)
 	case "libRaw":
 		return testLibRawGenerator(ctx, executable)
+	case "pdf":
+		return testPdfGenerator(ctx, executable)
 	default:
 		return "", ErrUnknownGenerator
 	}
@@ -89,3 +91,18 @@ func testLibRawGenerator(ctx context.Context, executable string) (string, error)
 
 	return output.String(), nil
 }
+
+func testPdfGenerator(ctx context.Context, executable string) (string, error) {
+	cmd := exec.CommandContext(ctx, executable, "-v")
+	var output bytes.Buffer
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to invoke pdftoppm executable: %w", err)
+	}
+
+	if !strings.Contains(output.String(), "pdftoppm") {
+		return "", ErrUnknownOutput
+	}
+
+	return output.String(), nil
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package util
+
+import "golang.org/x/sys/unix"
+
+// DiskFreeSpace 返回给定路径所在磁盘对非特权用户可用的剩余空间，单位为字节
+func DiskFreeSpace(path string) (uint64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
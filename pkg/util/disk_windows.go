@@ -0,0 +1,18 @@
+package util
+
+import "golang.org/x/sys/windows"
+
+// DiskFreeSpace 返回给定路径所在磁盘对当前用户可用的剩余空间，单位为字节
+func DiskFreeSpace(path string) (uint64, error) {
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var free uint64
+	if err := windows.GetDiskFreeSpaceEx(pathPtr, &free, nil, nil); err != nil {
+		return 0, err
+	}
+
+	return free, nil
+}
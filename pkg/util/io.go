@@ -44,3 +44,35 @@ func IsEmpty(name string) (bool, error) {
 	}
 	return false, err // Either not empty or error, suits both cases
 }
+
+// MoveFile 将文件 src 移动至 dst，两者位于不同磁盘时复制后删除 src
+func MoveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0744)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	in.Close()
+	return os.Remove(src)
+}
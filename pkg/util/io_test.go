@@ -2,6 +2,7 @@ package util
 
 import (
 	"github.com/stretchr/testify/assert"
+	"os"
 	"testing"
 )
 
@@ -37,3 +38,30 @@ func TestIsEmpty(t *testing.T) {
 	asserts.False(IsEmpty(""))
 	asserts.False(IsEmpty("not_exist"))
 }
+
+func TestMoveFile(t *testing.T) {
+	a := assert.New(t)
+	defer os.RemoveAll("test/move")
+
+	a.NoError(os.MkdirAll("test/move", 0744))
+	a.NoError(os.WriteFile("test/move/src.txt", []byte("content"), 0644))
+	a.NoError(MoveFile("test/move/src.txt", "test/move/dst.txt"))
+	a.NoFileExists("test/move/src.txt")
+	content, err := os.ReadFile("test/move/dst.txt")
+	a.NoError(err)
+	a.Equal("content", string(content))
+
+	// 源文件不存在
+	a.Error(MoveFile("test/move/not_exist.txt", "test/move/dst.txt"))
+}
+
+func TestDiskFreeSpace(t *testing.T) {
+	a := assert.New(t)
+
+	free, err := DiskFreeSpace(".")
+	a.NoError(err)
+	a.NotZero(free)
+
+	_, err = DiskFreeSpace("not_exist")
+	a.Error(err)
+}
@@ -0,0 +1,159 @@
+package watermark
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gofrs/uuid"
+)
+
+// pdfPage 渲染并添加水印后的 PDF 页面
+type pdfPage struct {
+	width  int
+	height int
+	jpeg   []byte
+}
+
+// PDF 使用 pdftoppm 将文档前 MaxPages 页渲染为图片，添加水印后重新组合为 PDF 文档写入 dst。
+// 生成的文档只包含页面图片，原文档中可选择的文字等内容不会保留
+func (w *Watermark) PDF(ctx context.Context, dst io.Writer, src io.Reader) error {
+	dir := filepath.Join(w.TempPath, "watermark", uuid.Must(uuid.NewV4()).String())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create temp folder: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input.pdf")
+	inputFile, err := os.Create(input)
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+
+	_, err = io.Copy(inputFile, src)
+	inputFile.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write input file: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, w.PdftoppmPath, "-f", "1", "-l", strconv.Itoa(w.MaxPages),
+		"-r", strconv.Itoa(w.DPI), "-jpeg", input, filepath.Join(dir, "page"))
+
+	var stdErr bytes.Buffer
+	cmd.Stderr = &stdErr
+
+	if err := cmd.Run(); err != nil {
+		util.Log().Warning("Failed to invoke pdftoppm: %s", stdErr.String())
+		return fmt.Errorf("failed to invoke pdftoppm: %w", err)
+	}
+
+	// 输出文件名中的页码按总页数补零，字典序即为页面顺序
+	rendered, _ := filepath.Glob(filepath.Join(dir, "page-*.jpg"))
+	if len(rendered) == 0 {
+		return ErrNoPages
+	}
+	sort.Strings(rendered)
+
+	pages := make([]pdfPage, 0, len(rendered))
+	for _, name := range rendered {
+		page, err := w.pdfPage(name)
+		if err != nil {
+			return err
+		}
+		pages = append(pages, page)
+	}
+
+	return writePDF(dst, pages, w.DPI)
+}
+
+// pdfPage 为 pdftoppm 渲染的页面图片添加水印
+func (w *Watermark) pdfPage(name string) (pdfPage, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return pdfPage{}, err
+	}
+	defer f.Close()
+
+	img, err := jpeg.Decode(f)
+	if err != nil {
+		return pdfPage{}, fmt.Errorf("failed to decode rendered page: %w", err)
+	}
+
+	marked := w.Draw(img)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, marked, &jpeg.Options{Quality: 85}); err != nil {
+		return pdfPage{}, err
+	}
+
+	return pdfPage{
+		width:  marked.Bounds().Dx(),
+		height: marked.Bounds().Dy(),
+		jpeg:   buf.Bytes(),
+	}, nil
+}
+
+// writePDF 生成每页铺满一张 JPEG 图片的 PDF 文档，页面尺寸按渲染时的 dpi 还原
+func writePDF(dst io.Writer, pages []pdfPage, dpi int) error {
+	var (
+		buf     bytes.Buffer
+		offsets []int
+	)
+
+	// 对象编号依次为：1 目录，2 页面树，之后每页依次为页面、内容流及图片
+	beginObject := func() {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n", len(offsets))
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	beginObject()
+	buf.WriteString("<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+
+	kids := make([]string, 0, len(pages))
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 3+3*i))
+	}
+	beginObject()
+	fmt.Fprintf(&buf, "<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", strings.Join(kids, " "), len(pages))
+
+	for i, page := range pages {
+		id := 3 + 3*i
+		width := float64(page.width) * 72 / float64(dpi)
+		height := float64(page.height) * 72 / float64(dpi)
+
+		beginObject()
+		fmt.Fprintf(&buf, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] "+
+			"/Resources << /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>\nendobj\n",
+			width, height, id+2, id+1)
+
+		content := fmt.Sprintf("q %.2f 0 0 %.2f 0 0 cm /Im0 Do Q", width, height)
+		beginObject()
+		fmt.Fprintf(&buf, "<< /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(content), content)
+
+		beginObject()
+		fmt.Fprintf(&buf, "<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB "+
+			"/BitsPerComponent 8 /Filter /DCTDecode /Length %d >>\nstream\n", page.width, page.height, len(page.jpeg))
+		buf.Write(page.jpeg)
+		buf.WriteString("\nendstream\nendobj\n")
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := buf.WriteTo(dst)
+	return err
+}
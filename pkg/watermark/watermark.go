@@ -0,0 +1,208 @@
+package watermark
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported watermark format")
+	ErrNoPages           = errors.New("no page rendered from pdf")
+)
+
+// DefaultColor 水印文字的默认颜色，半透明的灰色在深浅背景上均可辨认
+var DefaultColor = color.NRGBA{R: 128, G: 128, B: 128, A: 96}
+
+// fontSize 渲染文字时使用的字号，绘制时再按图片尺寸缩放
+const fontSize = 48
+
+// Watermark 在图片及 PDF 文档上平铺半透明的水印文字
+type Watermark struct {
+	Text  string
+	Face  font.Face
+	Color color.Color
+
+	// PDF 文档相关
+	PdftoppmPath string
+	TempPath     string
+	MaxPages     int
+	DPI          int
+}
+
+// NewWatermark 根据站点设置创建文字为 text 的水印
+func NewWatermark(text string) *Watermark {
+	options := model.GetSettingByNames("share_watermark_font", "thumb_pdf_path", "temp_path")
+	return &Watermark{
+		Text:         text,
+		Face:         loadFace(options["share_watermark_font"]),
+		Color:        DefaultColor,
+		PdftoppmPath: options["thumb_pdf_path"],
+		TempPath:     util.RelativePath(options["temp_path"]),
+		MaxPages:     model.GetIntSetting("share_watermark_pdf_max_pages", 20),
+		DPI:          model.GetIntSetting("share_watermark_pdf_dpi", 96),
+	}
+}
+
+var (
+	fontMu   sync.Mutex
+	fontPath string
+	fontFace font.Face
+)
+
+// loadFace 加载 path 处的字体文件，未设置或加载失败时使用只包含 ASCII 字符的内置字体
+func loadFace(path string) font.Face {
+	if path == "" {
+		return basicfont.Face7x13
+	}
+
+	fontMu.Lock()
+	defer fontMu.Unlock()
+
+	if path == fontPath && fontFace != nil {
+		return fontFace
+	}
+
+	data, err := os.ReadFile(util.RelativePath(path))
+	if err != nil {
+		util.Log().Warning("Failed to read watermark font %q: %s", path, err)
+		return basicfont.Face7x13
+	}
+
+	f, err := opentype.Parse(data)
+	if err != nil {
+		util.Log().Warning("Failed to parse watermark font %q: %s", path, err)
+		return basicfont.Face7x13
+	}
+
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: fontSize, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		util.Log().Warning("Failed to load watermark font %q: %s", path, err)
+		return basicfont.Face7x13
+	}
+
+	fontPath, fontFace = path, face
+	return face
+}
+
+// Supported 返回能否为文件名为 name 的文件添加水印
+func Supported(name string) bool {
+	switch fileExt(name) {
+	case "jpg", "jpeg", "png", "gif", "pdf":
+		return true
+	}
+	return false
+}
+
+// Apply 为文件名为 name 的图片或 PDF 文档添加水印，结果写入 dst
+func (w *Watermark) Apply(ctx context.Context, dst io.Writer, src io.Reader, name string) error {
+	if fileExt(name) == "pdf" {
+		return w.PDF(ctx, dst, src)
+	}
+	return w.Image(dst, src, fileExt(name))
+}
+
+// Image 为扩展名为 ext 的图片添加水印，以原格式编码后写入 dst，动态 GIF 只保留第一帧
+func (w *Watermark) Image(dst io.Writer, src io.Reader, ext string) error {
+	var (
+		img image.Image
+		err error
+	)
+	switch ext {
+	case "jpg", "jpeg":
+		img, err = jpeg.Decode(src)
+	case "png":
+		img, err = png.Decode(src)
+	case "gif":
+		img, err = gif.Decode(src)
+	default:
+		return ErrUnsupportedFormat
+	}
+	if err != nil {
+		return err
+	}
+
+	marked := w.Draw(img)
+	switch ext {
+	case "png":
+		return png.Encode(dst, marked)
+	case "gif":
+		return gif.Encode(dst, marked, nil)
+	default:
+		return jpeg.Encode(dst, marked, &jpeg.Options{Quality: 90})
+	}
+}
+
+// Draw 返回平铺绘制了水印文字的图片副本，文字高度随图片尺寸缩放，相邻行错开排列
+func (w *Watermark) Draw(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, img, b.Min, draw.Src)
+
+	mask := w.textMask()
+	if mask.Bounds().Empty() {
+		return dst
+	}
+
+	// 文字高度约为图片短边的 1/24
+	short := b.Dx()
+	if b.Dy() < short {
+		short = b.Dy()
+	}
+	height := short / 24
+	if height < mask.Bounds().Dy() {
+		height = mask.Bounds().Dy()
+	}
+	width := mask.Bounds().Dx() * height / mask.Bounds().Dy()
+
+	scaled := image.NewAlpha(image.Rect(0, 0, width, height))
+	draw.BiLinear.Scale(scaled, scaled.Bounds(), mask, mask.Bounds(), draw.Src, nil)
+
+	src := image.NewUniform(w.Color)
+	stepX, stepY := width+width/2, height*4
+	for row, y := 0, b.Min.Y+height; y < b.Max.Y; row, y = row+1, y+stepY {
+		for x := b.Min.X - (stepX/2)*(row%2); x < b.Max.X; x += stepX {
+			draw.DrawMask(dst, image.Rect(x, y, x+width, y+height), src, image.Point{}, scaled, image.Point{}, draw.Over)
+		}
+	}
+
+	return dst
+}
+
+// textMask 以水印字体渲染文字，返回文字形状的蒙版
+func (w *Watermark) textMask() *image.Alpha {
+	drawer := &font.Drawer{Face: w.Face, Src: image.Opaque}
+	metrics := w.Face.Metrics()
+	width := drawer.MeasureString(w.Text).Ceil()
+	height := (metrics.Ascent + metrics.Descent).Ceil()
+	if width <= 0 || height <= 0 {
+		return image.NewAlpha(image.Rectangle{})
+	}
+
+	mask := image.NewAlpha(image.Rect(0, 0, width, height))
+	drawer.Dst = mask
+	drawer.Dot = fixed.Point26_6{Y: metrics.Ascent}
+	drawer.DrawString(w.Text)
+	return mask
+}
+
+func fileExt(name string) string {
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+}
@@ -0,0 +1,141 @@
+package watermark
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/font/basicfont"
+)
+
+func newTestWatermark(text string) *Watermark {
+	return &Watermark{
+		Text:  text,
+		Face:  basicfont.Face7x13,
+		Color: DefaultColor,
+		DPI:   96,
+	}
+}
+
+func newTestImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.White)
+		}
+	}
+	return img
+}
+
+func TestSupported(t *testing.T) {
+	a := assert.New(t)
+
+	a.True(Supported("photo.JPG"))
+	a.True(Supported("a/b/c.png"))
+	a.True(Supported("doc.pdf"))
+	a.False(Supported("doc.docx"))
+	a.False(Supported("noext"))
+}
+
+func TestWatermark_Draw(t *testing.T) {
+	a := assert.New(t)
+	src := newTestImage(480, 320)
+
+	// 绘制水印，原图不变
+	{
+		marked := newTestWatermark("127.0.0.1 2026-10-15").Draw(src)
+		a.Equal(src.Bounds(), marked.Bounds())
+		a.NotEqual(src.Pix, marked.Pix)
+		a.Equal(color.RGBA{R: 255, G: 255, B: 255, A: 255}, src.RGBAAt(10, 10))
+	}
+
+	// 空文字
+	{
+		marked := newTestWatermark("").Draw(src)
+		a.Equal(src.Pix, marked.Pix)
+	}
+
+	// 图片小于文字
+	{
+		marked := newTestWatermark("a long watermark text").Draw(newTestImage(8, 8))
+		a.Equal(8, marked.Bounds().Dx())
+	}
+}
+
+func TestWatermark_Image(t *testing.T) {
+	a := assert.New(t)
+	w := newTestWatermark("watermark")
+
+	var original bytes.Buffer
+	a.NoError(png.Encode(&original, newTestImage(200, 100)))
+
+	// PNG
+	{
+		var out bytes.Buffer
+		a.NoError(w.Image(&out, bytes.NewReader(original.Bytes()), "png"))
+		img, err := png.Decode(&out)
+		a.NoError(err)
+		a.Equal(200, img.Bounds().Dx())
+	}
+
+	// 格式与扩展名不符
+	{
+		var out bytes.Buffer
+		a.Error(w.Image(&out, bytes.NewReader(original.Bytes()), "jpg"))
+	}
+
+	// 不支持的格式
+	{
+		var out bytes.Buffer
+		a.Equal(ErrUnsupportedFormat, w.Image(&out, bytes.NewReader(original.Bytes()), "bmp"))
+	}
+
+	// 按文件名分派
+	{
+		var out bytes.Buffer
+		a.NoError(w.Apply(context.Background(), &out, bytes.NewReader(original.Bytes()), "a.PNG"))
+		a.NotZero(out.Len())
+	}
+}
+
+func TestWritePDF(t *testing.T) {
+	a := assert.New(t)
+
+	var page bytes.Buffer
+	a.NoError(jpeg.Encode(&page, newTestImage(96, 192), nil))
+	pages := []pdfPage{
+		{width: 96, height: 192, jpeg: page.Bytes()},
+		{width: 96, height: 192, jpeg: page.Bytes()},
+	}
+
+	var out bytes.Buffer
+	a.NoError(writePDF(&out, pages, 96))
+	doc := out.String()
+
+	a.True(strings.HasPrefix(doc, "%PDF-1.4\n"))
+	a.True(strings.HasSuffix(doc, "%%EOF\n"))
+	a.Contains(doc, "/Kids [3 0 R 6 0 R] /Count 2")
+	a.Contains(doc, "/MediaBox [0 0 72.00 144.00]")
+
+	// 交叉引用表中的偏移量指向对应的对象
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(doc)
+	a.Len(startxref, 2)
+	xref, _ := strconv.Atoi(startxref[1])
+	a.True(strings.HasPrefix(doc[xref:], "xref\n0 9\n"))
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(doc[xref:], -1)
+	a.Len(entries, 8)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(entry[1])
+		a.True(strings.HasPrefix(doc[offset:], fmt.Sprintf("%d 0 obj\n", i+1)))
+	}
+}
@@ -15,6 +15,8 @@ import (
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/task"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 )
 
 // slashClean is equivalent to but slightly more efficient than
@@ -81,14 +83,24 @@ func moveFiles(ctx context.Context, fs *filesystem.FileSystem, src FileInfo, dst
 			src.GetPosition(),
 			path.Dir(dst),
 		)
+		if err == nil {
+			filesystem.RecordActivities(webdavActivity(model.ActivityMove, src, path.Dir(dst)))
+			if err := task.SubmitRelocateTask(fs.User, folderIDs, fileIDs); err != nil {
+				util.Log().Warning("Failed to submit relocate task: %s", err)
+			}
+		}
 	} else if src.GetName() != path.Base(dst) {
 		// 判断是否需要重命名
+		activity := webdavActivity(model.ActivityRename, src, path.Base(dst))
 		err = fs.Rename(
 			ctx,
 			folderIDs,
 			fileIDs,
 			path.Base(dst),
 		)
+		if err == nil {
+			filesystem.RecordActivities(activity)
+		}
 	}
 
 	if err != nil {
@@ -97,6 +109,14 @@ func moveFiles(ctx context.Context, fs *filesystem.FileSystem, src FileInfo, dst
 	return http.StatusNoContent, nil
 }
 
+// webdavActivity 生成 WebDAV 操作对象的动态
+func webdavActivity(activityType string, src FileInfo, detail string) model.Activity {
+	if src.IsDir() {
+		return model.NewFolderActivity(activityType, src.(*model.Folder), detail)
+	}
+	return model.NewFileActivity(activityType, src.(*model.File), detail)
+}
+
 // copyFiles copies files and/or directories from src to dst.
 //
 // See section 9.8.5 for when various HTTP status codes apply.
@@ -14,6 +14,7 @@ import (
 	"net/http"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
@@ -24,26 +25,95 @@ type FileDeadProps struct {
 	*model.File
 }
 
+// customPropertyNamespace 未指定命名空间的自定义元数据在 WebDAV 中使用的命名空间
+const customPropertyNamespace = "http://cloudreve.org/ns/property"
+
+// 客户端附带设置的属性命名空间，不作为自定义元数据保存
+var ignoredPropertyNamespaces = map[string]bool{
+	"DAV:":                       true,
+	"urn:schemas-microsoft-com:": true,
+	"http://owncloud.org/ns":     true,
+}
+
+// propertyXMLName 将自定义元数据名称转换为 WebDAV 属性名
+func propertyXMLName(name string) xml.Name {
+	if strings.HasPrefix(name, "{") {
+		if end := strings.Index(name, "}"); end > 0 {
+			return xml.Name{Space: name[1:end], Local: name[end+1:]}
+		}
+	}
+	return xml.Name{Space: customPropertyNamespace, Local: name}
+}
+
+// propertyName 将 WebDAV 属性名转换为自定义元数据名称
+func propertyName(name xml.Name) string {
+	if name.Space == customPropertyNamespace || name.Space == "" {
+		return name.Local
+	}
+	return "{" + name.Space + "}" + name.Local
+}
+
+// propertyText 提取属性值中的文本内容
+func propertyText(innerXML []byte) string {
+	var text struct {
+		Value string `xml:",chardata"`
+	}
+	if err := xml.Unmarshal([]byte("<v>"+string(innerXML)+"</v>"), &text); err != nil {
+		return string(innerXML)
+	}
+	return text.Value
+}
+
 // 实现 webdav.DeadPropsHolder 接口，不能在models.file里面定义
 func (file *FileDeadProps) DeadProps() (map[xml.Name]Property, error) {
-	return map[xml.Name]Property{
-		xml.Name{Space: "http://owncloud.org/ns", Local: "checksums"}: {
-			XMLName: xml.Name{
-				Space: "http://owncloud.org/ns", Local: "checksums",
-			},
-			InnerXML: []byte("<checksum>" + file.MetadataSerialized[model.ChecksumMetadataKey] + "</checksum>"),
+	props, err := model.GetFileProperties(file.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[xml.Name]Property, len(props)+1)
+	for _, prop := range props {
+		name := propertyXMLName(prop.Name)
+		res[name] = Property{
+			XMLName:  name,
+			InnerXML: []byte(escapeXML(prop.Value)),
+		}
+	}
+
+	res[xml.Name{Space: "http://owncloud.org/ns", Local: "checksums"}] = Property{
+		XMLName: xml.Name{
+			Space: "http://owncloud.org/ns", Local: "checksums",
 		},
-	}, nil
+		InnerXML: []byte("<checksum>" + file.MetadataSerialized[model.ChecksumMetadataKey] + "</checksum>"),
+	}
+	return res, nil
 }
 
 func (file *FileDeadProps) Patch(proppatches []Proppatch) ([]Propstat, error) {
 	var (
-		stat Propstat
-		err  error
+		stat       Propstat
+		customStat Propstat
+		err        error
 	)
 	stat.Status = http.StatusOK
+	customStat.Status = http.StatusOK
+	set := make(map[string]string)
+	remove := make([]string, 0)
 	for _, patch := range proppatches {
 		for _, prop := range patch.Props {
+			// 其他命名空间的属性作为自定义元数据保存
+			if !ignoredPropertyNamespaces[prop.XMLName.Space] {
+				customStat.Props = append(customStat.Props, Property{XMLName: prop.XMLName})
+				name := propertyName(prop.XMLName)
+				if patch.Remove {
+					delete(set, name)
+					remove = append(remove, name)
+				} else {
+					set[name] = propertyText(prop.InnerXML)
+				}
+				continue
+			}
+
 			stat.Props = append(stat.Props, Property{XMLName: prop.XMLName})
 			if prop.XMLName.Space == "DAV:" && prop.XMLName.Local == "lastmodified" {
 				var modtimeUnix int64
@@ -54,7 +124,20 @@ func (file *FileDeadProps) Patch(proppatches []Proppatch) ([]Propstat, error) {
 			}
 		}
 	}
-	return []Propstat{stat}, err
+
+	if len(customStat.Props) > 0 {
+		switch updateErr := filesystem.UpdateFileProperties(file.File, set, remove); updateErr {
+		case nil:
+		case filesystem.ErrPropertyExceeded:
+			customStat.Status = http.StatusInsufficientStorage
+		case filesystem.ErrInvalidPropertyName:
+			customStat.Status = http.StatusForbidden
+		default:
+			return nil, updateErr
+		}
+	}
+
+	return makePropstats(stat, customStat), err
 }
 
 type FolderDeadProps struct {
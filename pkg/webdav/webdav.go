@@ -22,6 +22,7 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gofrs/uuid"
 )
 
 type Handler struct {
@@ -61,6 +62,17 @@ func isPathExist(ctx context.Context, fs *filesystem.FileSystem, path string) (b
 	return false, nil
 }
 
+// isReadOnly 返回对象是否位于挂载的分享中，挂载的内容不能被当前用户修改
+func isReadOnly(fs *filesystem.FileSystem, info FileInfo) bool {
+	switch obj := info.(type) {
+	case *model.File:
+		return obj.UserID != fs.User.ID
+	case *model.Folder:
+		return obj.OwnerID != fs.User.ID
+	}
+	return false
+}
+
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request, fs *filesystem.FileSystem) {
 	status, err := http.StatusBadRequest, errUnsupportedMethod
 	h.Mutex.Lock()
@@ -293,7 +305,7 @@ func (h *Handler) handleGetHeadPost(w http.ResponseWriter, r *http.Request, fs *
 	if !rs.Redirect {
 		defer rs.Content.Close()
 		// 获取文件内容
-		http.ServeContent(w, r, reqPath, fs.FileTarget[0].UpdatedAt, rs.Content)
+		http.ServeContent(fs.WrapDownloadWriter(w), r, reqPath, fs.FileTarget[0].UpdatedAt, rs.Content)
 		return 0, nil
 	}
 
@@ -310,7 +322,7 @@ func (h *Handler) handleGetHeadPost(w http.ResponseWriter, r *http.Request, fs *
 				panic(err)
 			}
 		}()
-		proxy.ServeHTTP(w, r)
+		proxy.ServeHTTP(fs.WrapDownloadWriter(w), r)
 	} else {
 		http.Redirect(w, r, rs.URL, 301)
 	}
@@ -337,17 +349,29 @@ func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, fs *files
 
 	// 尝试作为文件删除
 	if ok, file := fs.IsFileExist(reqPath); ok {
+		if isReadOnly(fs, file) {
+			return http.StatusForbidden, filesystem.ErrMountReadOnly
+		}
 		if err := fs.Delete(ctx, []uint{}, []uint{file.ID}, false, false); err != nil {
 			return http.StatusMethodNotAllowed, err
 		}
+		filesystem.RecordActivities(model.NewFileActivity(model.ActivityDelete, file, ""))
 		return http.StatusNoContent, nil
 	}
 
 	// 尝试作为目录删除
 	if ok, folder := fs.IsPathExist(reqPath); ok {
+		// 删除挂载点时只删除挂载点本身
+		if folder.MountPoint != nil {
+			folder = folder.MountPoint
+		}
+		if isReadOnly(fs, folder) {
+			return http.StatusForbidden, filesystem.ErrMountReadOnly
+		}
 		if err := fs.Delete(ctx, []uint{folder.ID}, []uint{}, false, false); err != nil {
 			return http.StatusMethodNotAllowed, err
 		}
+		filesystem.RecordActivities(model.NewFolderActivity(model.ActivityDelete, folder, ""))
 		return http.StatusNoContent, nil
 	}
 
@@ -388,39 +412,81 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, fs *filesyst
 
 	// 判断文件是否已存在
 	exist, originFile := fs.IsFileExist(reqPath)
+	if exist && isReadOnly(fs, originFile) {
+		return http.StatusForbidden, filesystem.ErrMountReadOnly
+	}
 	if exist {
 		// 已存在，为更新操作
 
 		// 检查此文件是否有软链接
 		fileList, err := model.RemoveFilesWithSoftLinks([]model.File{*originFile})
+		keepVersion, keepSharedVersion := false, false
+		sharedSource := originFile.SourceName
 		if err == nil && len(fileList) == 0 {
 			// 如果包含软连接，应重新生成新文件副本，并更新source_name
+			keepSharedVersion = filesystem.ShouldKeepVersion(originFile)
 			originFile.SourceName = fs.GenerateSavePath(ctx, &fileData)
 			fileData.Mode &= ^fsctx.Overwrite
 			fs.Use("AfterUpload", filesystem.HookUpdateSourceName)
 			fs.Use("AfterUploadCanceled", filesystem.HookUpdateSourceName)
 			fs.Use("AfterValidateFailed", filesystem.HookUpdateSourceName)
+		} else {
+			// 原内容未被其他文件引用时可保留为历史版本
+			keepVersion = filesystem.ShouldKeepVersion(originFile)
 		}
 
+		fs.Use("BeforeUpload", filesystem.HookValidateRetention)
+		fs.Use("BeforeUpload", filesystem.HookValidateFileLock)
 		fs.Use("BeforeUpload", filesystem.HookResetPolicy)
+		if keepVersion {
+			// 新内容写入新的存储路径，上传失败时原文件保持不变
+			fs.Use("BeforeUpload", filesystem.HookGenerateVersionPath)
+			fs.Use("AfterUploadCanceled", filesystem.HookDeleteVersionTempFile)
+			fs.Use("AfterUploadFailed", filesystem.HookDeleteVersionTempFile)
+			fs.Use("AfterValidateFailed", filesystem.HookDeleteVersionTempFile)
+		} else {
+			fs.Use("AfterUploadCanceled", filesystem.HookCleanFileContent)
+			fs.Use("AfterUploadCanceled", filesystem.HookClearFileSize)
+			fs.Use("AfterValidateFailed", filesystem.HookCleanFileContent)
+			fs.Use("AfterValidateFailed", filesystem.HookClearFileSize)
+		}
+		if keepSharedVersion {
+			// 历史版本继续占用容量，新内容需完整计入
+			fs.Use("BeforeUpload", filesystem.HookValidateCapacity)
+		}
 		fs.Use("BeforeUpload", filesystem.HookValidateFile)
 		fs.Use("BeforeUpload", filesystem.HookValidateCapacityDiff)
-		fs.Use("AfterUploadCanceled", filesystem.HookCleanFileContent)
-		fs.Use("AfterUploadCanceled", filesystem.HookClearFileSize)
+		fs.Use("BeforeUpload", filesystem.HookUploadWebhook(filesystem.WebhookBeforeUpload))
 		fs.Use("AfterUploadCanceled", filesystem.HookCancelContext)
-		fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
-		fs.Use("AfterValidateFailed", filesystem.HookCleanFileContent)
-		fs.Use("AfterValidateFailed", filesystem.HookClearFileSize)
+		fs.UseAfterUploadHooks(false, filesystem.GenericAfterUpdate)
+		if keepVersion {
+			fs.Use("AfterUpload", filesystem.HookSaveVersion)
+		}
+		if keepSharedVersion {
+			// 原内容仍被其他文件引用，作为历史版本保留其存储路径
+			fs.Use("AfterUpload", filesystem.HookSaveSharedVersion(sharedSource))
+		}
+		ctx = context.WithValue(ctx, fsctx.LockTokenCtx, lockToken(r))
 		ctx = context.WithValue(ctx, fsctx.FileModelCtx, *originFile)
 		fileData.Mode |= fsctx.Overwrite
 	} else {
+		// 使用目标目录绑定的存储策略
+		if err := fs.SwitchPolicyByPath(filePath); err != nil {
+			return http.StatusInternalServerError, err
+		}
+
 		// 给文件系统分配钩子
 		fs.Use("BeforeUpload", filesystem.HookValidateFile)
-		fs.Use("BeforeUpload", filesystem.HookValidateCapacity)
+		fs.Use("BeforeUpload", filesystem.HookReserveCapacity)
+		fs.Use("BeforeUpload", filesystem.HookUploadWebhook(filesystem.WebhookBeforeUpload))
 		fs.Use("AfterUploadCanceled", filesystem.HookDeleteTempFile)
 		fs.Use("AfterUploadCanceled", filesystem.HookCancelContext)
-		fs.Use("AfterUpload", filesystem.GenericAfterUpload)
+		fs.Use("AfterUploadCanceled", filesystem.HookReleaseCapacity)
+		fs.Use("AfterUploadFailed", filesystem.HookReleaseCapacity)
+		fs.UseAfterUploadHooks(false, filesystem.GenericAfterUpload)
+		fs.Use("AfterUpload", filesystem.HookReleaseCapacity)
 		fs.Use("AfterValidateFailed", filesystem.HookDeleteTempFile)
+		fs.Use("AfterValidateFailed", filesystem.HookReleaseCapacity)
 	}
 
 	// rclone 请求
@@ -542,6 +608,10 @@ func (h *Handler) handleCopyMove(w http.ResponseWriter, r *http.Request, fs *fil
 		return status, nil
 	}
 
+	if isReadOnly(fs, target) {
+		return http.StatusForbidden, filesystem.ErrMountReadOnly
+	}
+
 	// windows下，某些情况下（网盘根目录下）Office保存文件时附带的锁token只包含源文件，
 	// 此处暂时去除了对dst锁的检查
 	release, status, err := h.confirmLocks(r, src, "", fs)
@@ -665,7 +735,38 @@ func (h *Handler) handleLock(w http.ResponseWriter, r *http.Request, fs *filesys
 	//	w.WriteHeader(http.StatusCreated)
 	//}
 
-	writeLockInfo(w, fmt.Sprintf("%d", time.Now().UnixNano()), LockDetails{
+	// 对文件加锁时同时写入文件锁，使 REST API 及 WOPI 编辑器遵循此锁
+	token := fmt.Sprintf("%d", time.Now().UnixNano())
+	if exist, file := fs.IsFileExist(reqPath); exist {
+		if isReadOnly(fs, file) {
+			return http.StatusForbidden, filesystem.ErrMountReadOnly
+		}
+
+		// 请求体为空时为刷新已有的锁
+		token = ""
+		if r.ContentLength == 0 {
+			token = lockToken(r)
+		}
+		if token == "" {
+			token = "opaquelocktoken:" + uuid.Must(uuid.NewV4()).String()
+		}
+
+		_, err := filesystem.LockFile(file.ID, filesystem.FileLock{
+			Token:   token,
+			OwnerID: fs.User.ID,
+			App:     filesystem.LockAppWebDAV,
+		}, int(duration/time.Second), false)
+		if err == filesystem.ErrFileLocked {
+			return StatusLocked, err
+		}
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		w.Header().Set("Lock-Token", "<"+token+">")
+	}
+
+	writeLockInfo(w, token, LockDetails{
 		Duration: duration,
 		OwnerXML: fs.User.Email,
 		Root:     reqPath,
@@ -673,10 +774,50 @@ func (h *Handler) handleLock(w http.ResponseWriter, r *http.Request, fs *filesys
 	return 0, nil
 }
 
+// lockToken 返回 If 请求头中携带的第一个锁令牌，未携带时返回空
+func lockToken(r *http.Request) string {
+	ih, ok := parseIfHeader(r.Header.Get("If"))
+	if !ok {
+		return ""
+	}
+
+	for _, l := range ih.lists {
+		for _, c := range l.conditions {
+			if c.Token != "" && !c.Not {
+				return c.Token
+			}
+		}
+	}
+
+	return ""
+}
+
 // OK
 func (h *Handler) handleUnlock(w http.ResponseWriter, r *http.Request, fs *filesystem.FileSystem, ls LockSystem) (status int, err error) {
 	defer fs.Recycle()
-	return http.StatusNoContent, err
+
+	reqPath, status, err := h.stripPrefix(r.URL.Path, fs.User.ID)
+	if err != nil {
+		return status, err
+	}
+
+	// 释放 LOCK 时写入的文件锁
+	if exist, file := fs.IsFileExist(reqPath); exist {
+		t := r.Header.Get("Lock-Token")
+		if len(t) < 2 || t[0] != '<' || t[len(t)-1] != '>' {
+			return http.StatusBadRequest, errInvalidLockToken
+		}
+
+		switch err = filesystem.UnlockFile(file.ID, t[1:len(t)-1], false); err {
+		case nil:
+		case filesystem.ErrLockMismatch:
+			return http.StatusConflict, err
+		default:
+			return http.StatusInternalServerError, err
+		}
+	}
+
+	return http.StatusNoContent, nil
 
 	//// http://www.webdav.org/specs/rfc4918.html#HEADER_Lock-Token says that the
 	//// Lock-Token value is a Coded-URL. We strip its angle brackets.
@@ -785,9 +926,13 @@ func (h *Handler) handleProppatch(w http.ResponseWriter, r *http.Request, fs *fi
 
 	ctx := r.Context()
 
-	if exist, _ := isPathExist(ctx, fs, reqPath); !exist {
+	exist, info := isPathExist(ctx, fs, reqPath)
+	if !exist {
 		return http.StatusNotFound, nil
 	}
+	if isReadOnly(fs, info) {
+		return http.StatusForbidden, filesystem.ErrMountReadOnly
+	}
 	patches, status, err := readProppatch(r.Body)
 	if err != nil {
 		return status, err
@@ -51,10 +51,15 @@ const (
 	OverwriteHeader     = wopiHeaderPrefix + "Override"
 	ServerErrorHeader   = wopiHeaderPrefix + "ServerError"
 	RenameRequestHeader = wopiHeaderPrefix + "RequestedName"
+	ItemVersionHeader   = wopiHeaderPrefix + "ItemVersion"
+	LockHeader          = wopiHeaderPrefix + "Lock"
+	OldLockHeader       = wopiHeaderPrefix + "OldLock"
+	LockFailureHeader   = wopiHeaderPrefix + "LockFailureReason"
 
 	MethodLock        = "LOCK"
 	MethodUnlock      = "UNLOCK"
 	MethodRefreshLock = "REFRESH_LOCK"
+	MethodGetLock     = "GET_LOCK"
 	MethodRename      = "RENAME_FILE"
 
 	wopiSrcPlaceholder    = "WOPI_SOURCE"
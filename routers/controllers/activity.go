@@ -0,0 +1,17 @@
+package controllers
+
+import (
+	"github.com/cloudreve/Cloudreve/v3/service/explorer"
+	"github.com/gin-gonic/gin"
+)
+
+// ListActivities 列出用户最近的操作动态
+func ListActivities(c *gin.Context) {
+	var service explorer.ActivityListService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.List(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"context"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cluster"
 	"github.com/cloudreve/Cloudreve/v3/pkg/mq"
 	"io"
@@ -12,6 +13,7 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/wopi"
 	"github.com/cloudreve/Cloudreve/v3/service/admin"
+	"github.com/cloudreve/Cloudreve/v3/service/explorer"
 	"github.com/gin-gonic/gin"
 )
 
@@ -72,6 +74,17 @@ func AdminGetGroups(c *gin.Context) {
 	}
 }
 
+// AdminTrafficReport 获取流量统计
+func AdminTrafficReport(c *gin.Context) {
+	var service admin.TrafficReportService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Report()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // AdminReloadService 重新加载子服务
 func AdminReloadService(c *gin.Context) {
 	service := c.Param("service")
@@ -216,6 +229,36 @@ func AdminGetPolicy(c *gin.Context) {
 	}
 }
 
+// AdminPolicyHealth 探测从机存储策略的健康状态
+func AdminPolicyHealth(c *gin.Context) {
+	var service admin.PolicyService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Health(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminRotatePolicyCredential 轮换存储策略凭证
+func AdminRotatePolicyCredential(c *gin.Context) {
+	var (
+		policy  admin.PolicyService
+		service admin.RotateCredentialService
+	)
+	if err := c.ShouldBindUri(&policy); err != nil {
+		c.JSON(200, ErrorResponse(err))
+		return
+	}
+
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Rotate(c, policy.ID)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // AdminDeletePolicy 删除存储策略
 func AdminDeletePolicy(c *gin.Context) {
 	var service admin.PolicyService
@@ -304,6 +347,29 @@ func AdminGetUser(c *gin.Context) {
 	}
 }
 
+// AdminDownloadUserManifest 下载用户的文件清单
+func AdminDownloadUserManifest(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service admin.UserService
+	if err := c.ShouldBindUri(&service); err != nil {
+		c.JSON(200, ErrorResponse(err))
+		return
+	}
+
+	var manifest explorer.ManifestService
+	if err := c.ShouldBindQuery(&manifest); err == nil {
+		res := service.Manifest(ctx, c, &manifest)
+		if res.Code != 0 && !c.Writer.Written() {
+			c.JSON(200, res)
+		}
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // AdminDeleteUser 批量删除用户
 func AdminDeleteUser(c *gin.Context) {
 	var service admin.UserBatchService
@@ -367,6 +433,50 @@ func AdminDeleteFile(c *gin.Context) {
 	}
 }
 
+// AdminCopyFile 复制用户的文件或目录到其他用户空间
+func AdminCopyFile(c *gin.Context) {
+	var service admin.FileCopyService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Copy(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminListFolderTemplates 列出公共目录模板
+func AdminListFolderTemplates(c *gin.Context) {
+	var service admin.NoParamService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.FolderTemplates()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminAddFolderTemplate 创建公共目录模板
+func AdminAddFolderTemplate(c *gin.Context) {
+	var service admin.FolderTemplateService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminDeleteFolderTemplate 删除公共目录模板
+func AdminDeleteFolderTemplate(c *gin.Context) {
+	var service admin.FolderTemplateIDService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Delete()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // AdminListShare 列出分享
 func AdminListShare(c *gin.Context) {
 	var service admin.AdminListService
@@ -389,6 +499,33 @@ func AdminDeleteShare(c *gin.Context) {
 	}
 }
 
+// AdminListShareReport 列出分享举报
+func AdminListShareReport(c *gin.Context) {
+	var service admin.AdminListService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.ShareReports()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminHandleShareReport 处理分享举报
+func AdminHandleShareReport(c *gin.Context) {
+	var service admin.ShareReportHandleService
+	if err := c.ShouldBindUri(&service); err != nil {
+		c.JSON(200, ErrorResponse(err))
+		return
+	}
+
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Handle(CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // AdminListDownload 列出离线下载任务
 func AdminListDownload(c *gin.Context) {
 	var service admin.AdminListService
@@ -444,6 +581,28 @@ func AdminCreateImportTask(c *gin.Context) {
 	}
 }
 
+// AdminCreateMigrateTask 新建存储策略迁移任务
+func AdminCreateMigrateTask(c *gin.Context) {
+	var service admin.MigrateTaskService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminCreateIntegrityTask 新建文件完整性校验任务
+func AdminCreateIntegrityTask(c *gin.Context) {
+	var service admin.IntegrityTaskService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // AdminListFolders 列出用户或外部文件系统目录
 func AdminListFolders(c *gin.Context) {
 	var service admin.ListFolderService
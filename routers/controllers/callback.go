@@ -99,6 +99,24 @@ func OneDriveOAuth(c *gin.Context) {
 	}
 }
 
+// DropboxOAuth Dropbox 授权回调
+func DropboxOAuth(c *gin.Context) {
+	var callbackBody callback.OauthService
+	if err := c.ShouldBindQuery(&callbackBody); err == nil {
+		res := callbackBody.DropboxAuth(c)
+		redirect := model.GetSiteURL()
+		redirect.Path = path.Join(redirect.Path, "/admin/policy")
+		queries := redirect.Query()
+		queries.Add("code", strconv.Itoa(res.Code))
+		queries.Add("msg", res.Msg)
+		queries.Add("err", res.Error)
+		redirect.RawQuery = queries.Encode()
+		c.Redirect(303, redirect.String())
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // GoogleDriveOAuth Google Drive 授权回调
 func GoogleDriveOAuth(c *gin.Context) {
 	var callbackBody callback.OauthService
@@ -128,6 +146,50 @@ func COSCallback(c *gin.Context) {
 	}
 }
 
+// AzureCallback Azure Blob上传完成客户端回调
+func AzureCallback(c *gin.Context) {
+	var callbackBody callback.AzureCallback
+	if err := c.ShouldBindQuery(&callbackBody); err == nil {
+		res := callbackBody.PreProcess(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// GCSCallback GCS上传完成客户端回调
+func GCSCallback(c *gin.Context) {
+	var callbackBody callback.GCSCallback
+	if err := c.ShouldBindQuery(&callbackBody); err == nil {
+		res := callbackBody.PreProcess(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// SwiftCallback Swift上传完成客户端回调
+func SwiftCallback(c *gin.Context) {
+	var callbackBody callback.SwiftCallback
+	if err := c.ShouldBindQuery(&callbackBody); err == nil {
+		res := callbackBody.PreProcess(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// GoogleDriveCallback Google Drive上传完成客户端回调
+func GoogleDriveCallback(c *gin.Context) {
+	var callbackBody callback.GoogleDriveCallback
+	if err := c.ShouldBindQuery(&callbackBody); err == nil {
+		res := callbackBody.PreProcess(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // S3Callback S3上传完成客户端回调
 func S3Callback(c *gin.Context) {
 	var callbackBody callback.S3Callback
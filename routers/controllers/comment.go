@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"github.com/cloudreve/Cloudreve/v3/service/explorer"
+	"github.com/cloudreve/Cloudreve/v3/service/share"
+	"github.com/gin-gonic/gin"
+)
+
+// ListComments 列出自己文件上的评论
+func ListComments(c *gin.Context) {
+	var service explorer.CommentListService
+	res := service.List(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
+// CreateComment 在自己的文件上发表评论
+func CreateComment(c *gin.Context) {
+	var service explorer.CommentCreateService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DeleteComment 删除评论
+func DeleteComment(c *gin.Context) {
+	var service explorer.CommentDeleteService
+	res := service.Delete(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
+// ListShareComments 列出分享文件上的评论
+func ListShareComments(c *gin.Context) {
+	var service share.CommentListService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.List(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// CreateShareComment 在分享的文件上发表评论
+func CreateShareComment(c *gin.Context) {
+	var service share.CommentCreateService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListNotifications 列出站内通知
+func ListNotifications(c *gin.Context) {
+	var service explorer.NotificationListService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.List(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ReadNotifications 将站内通知标记为已读
+func ReadNotifications(c *gin.Context) {
+	var service explorer.NotificationReadService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Read(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
@@ -19,10 +19,81 @@ func CreateDirectory(c *gin.Context) {
 // ListDirectory 列出目录下内容
 func ListDirectory(c *gin.Context) {
 	var service explorer.DirectoryService
-	if err := c.ShouldBindUri(&service); err == nil {
+	if err := c.ShouldBindUri(&service); err != nil {
+		c.JSON(200, ErrorResponse(err))
+		return
+	}
+
+	if err := c.ShouldBindQuery(&service); err == nil {
 		res := service.ListDirectory(c)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// SetDirectoryPolicy 设定目录绑定的存储策略
+func SetDirectoryPolicy(c *gin.Context) {
+	var service explorer.DirectoryPolicyService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.SetPolicy(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// SetDirectoryDisplay 设定目录的显示颜色、图标及描述
+func SetDirectoryDisplay(c *gin.Context) {
+	var service explorer.DirectoryDisplayService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.SetDisplay(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListFolderTemplates 列出可用的目录模板
+func ListFolderTemplates(c *gin.Context) {
+	var service explorer.FolderTemplateService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.List(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// CreateFolderTemplate 创建目录模板
+func CreateFolderTemplate(c *gin.Context) {
+	var service explorer.FolderTemplateCreateService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// InstantiateFolderTemplate 按模板创建目录结构
+func InstantiateFolderTemplate(c *gin.Context) {
+	var service explorer.FolderTemplateInstantiateService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Instantiate(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DeleteFolderTemplate 删除目录模板
+func DeleteFolderTemplate(c *gin.Context) {
+	var service explorer.FolderTemplateService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Delete(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
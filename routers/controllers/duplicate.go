@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/cloudreve/Cloudreve/v3/service/explorer"
+	"github.com/gin-gonic/gin"
+)
+
+// ListDuplicates 列出内容相同的文件组
+func ListDuplicates(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.DuplicateService
+	res := service.List(ctx, c)
+	c.JSON(200, res)
+}
+
+// DeduplicateFiles 合并内容相同的文件
+func DeduplicateFiles(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.DuplicateService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Deduplicate(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ScanDuplicates 创建摘要计算任务，为旧文件补充内容摘要
+func ScanDuplicates(c *gin.Context) {
+	var service explorer.DuplicateService
+	res := service.Scan(c, CurrentUser(c))
+	c.JSON(200, res)
+}
@@ -19,7 +19,12 @@ func DownloadArchive(c *gin.Context) {
 	defer cancel()
 
 	var service explorer.ArchiveService
-	if err := c.ShouldBindUri(&service); err == nil {
+	if err := c.ShouldBindUri(&service); err != nil {
+		c.JSON(200, ErrorResponse(err))
+		return
+	}
+
+	if err := c.ShouldBindQuery(&service); err == nil {
 		service.DownloadArchived(ctx, c)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -183,6 +188,7 @@ func Thumb(c *gin.Context) {
 	}
 
 	defer resp.Content.Close()
+	fs.WithDownloadTraffic(c)
 	http.ServeContent(c.Writer, c.Request, "thumb."+model.GetSettingByNameWithDefault("thumb_encode_method", "jpg"), fs.FileTarget[0].UpdatedAt, resp.Content)
 
 }
@@ -228,6 +234,45 @@ func PreviewText(c *gin.Context) {
 	}
 }
 
+// PreviewTextHead 预览文本文件的开头部分
+func PreviewTextHead(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.TextPreviewService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Preview(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// GetAudioTags 获取音频文件的标签信息
+func GetAudioTags(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.FileIDService
+	res := service.AudioTags(ctx, c)
+	c.JSON(200, res)
+}
+
+// GetAudioCover 获取音频文件的封面图片
+func GetAudioCover(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.FileIDService
+	res := service.AudioCover(ctx, c)
+	if res.Code != 0 {
+		c.JSON(200, res)
+	}
+}
+
 // GetDocPreview 获取DOC文件预览地址
 func GetDocPreview(c *gin.Context) {
 	// 创建上下文
@@ -258,6 +303,169 @@ func CreateDownloadSession(c *gin.Context) {
 	}
 }
 
+// CreateTempSource 创建临时外链
+func CreateTempSource(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.TempSourceService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// TranscodeVideo 创建视频转码任务
+func TranscodeVideo(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.FileIDService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Transcode(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// PreviewHLS 获取视频转码生成的 HLS 播放列表或分片
+func PreviewHLS(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.HLSService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Serve(ctx, c)
+		if res.Code != 0 {
+			c.JSON(200, res)
+		}
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// PreviewTranscoded 获取转换为浏览器可播放格式的媒体文件
+func PreviewTranscoded(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.FileIDService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.ServeTranscoded(ctx, c)
+		if res.Code != 0 {
+			c.JSON(200, res)
+		}
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// RestoreFile 解冻归档存储中的文件
+func RestoreFile(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.FileIDService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Restore(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListFileVersions 列出文件的历史版本
+func ListFileVersions(c *gin.Context) {
+	var service explorer.FileIDService
+	res := service.ListVersions(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
+// RestoreFileVersion 恢复文件的历史版本
+func RestoreFileVersion(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.FileVersionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Restore(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DeleteFileVersion 删除文件的历史版本
+func DeleteFileVersion(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.FileVersionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Delete(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListFileProperties 列出文件的自定义元数据
+func ListFileProperties(c *gin.Context) {
+	var service explorer.FileIDService
+	res := service.ListProperties(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
+// UpdateFileProperties 设定并移除文件的自定义元数据
+func UpdateFileProperties(c *gin.Context) {
+	var service explorer.FilePropertyService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Update(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// GetFileLock 获取文件当前的锁
+func GetFileLock(c *gin.Context) {
+	var service explorer.FileUnlockService
+	res := service.GetLock(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
+// LockFile 为文件加锁
+func LockFile(c *gin.Context) {
+	var service explorer.FileLockService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Lock(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// UnlockFile 释放文件锁
+func UnlockFile(c *gin.Context) {
+	var service explorer.FileUnlockService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Unlock(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // Download 文件下载
 func Download(c *gin.Context) {
 	// 创建上下文
@@ -275,6 +483,21 @@ func Download(c *gin.Context) {
 	}
 }
 
+// SaveContent 编辑器保存文件内容，校验修订号
+func SaveContent(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.FileIDService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.SaveContent(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // PutContent 更新文件内容
 func PutContent(c *gin.Context) {
 	// 创建上下文
@@ -352,6 +575,38 @@ func FileUpload(c *gin.Context) {
 	//})
 }
 
+// GetUploadSessionProgress 获取上传会话续传进度
+func GetUploadSessionProgress(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.UploadSessionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Progress(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// UploadProgressSocket 通过 WebSocket 订阅上传会话的服务端写入进度
+func UploadProgressSocket(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.UploadSessionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.SubscribeProgress(ctx, c)
+		if res.Code != 0 {
+			c.JSON(200, res)
+		}
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // DeleteUploadSession 删除上传会话
 func DeleteUploadSession(c *gin.Context) {
 	// 创建上下文
@@ -367,6 +622,16 @@ func DeleteUploadSession(c *gin.Context) {
 	}
 }
 
+// ListUploadSessions 列出进行中的上传会话
+func ListUploadSessions(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	res := explorer.ListUploadSessions(ctx, c)
+	c.JSON(200, res)
+}
+
 // DeleteAllUploadSession 删除全部上传会话
 func DeleteAllUploadSession(c *gin.Context) {
 	// 创建上下文
@@ -392,6 +657,21 @@ func GetUploadSession(c *gin.Context) {
 	}
 }
 
+// GetFolderUploadSession 文件夹上传，批量创建上传会话
+func GetFolderUploadSession(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.CreateFolderUploadSessionService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // SearchFile 搜索文件
 func SearchFile(c *gin.Context) {
 	var service explorer.ItemSearchService
@@ -419,3 +699,53 @@ func CreateFile(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// VerifyFiles 创建文件完整性校验任务
+func VerifyFiles(c *gin.Context) {
+	var service explorer.IntegrityService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Scan(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DownloadManifest 下载用户的文件清单
+func DownloadManifest(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.ManifestService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Download(ctx, c, CurrentUser(c))
+		if res.Code != 0 && !c.Writer.Written() {
+			c.JSON(200, res)
+		}
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ExportManifest 创建文件清单导出任务
+func ExportManifest(c *gin.Context) {
+	var service explorer.ManifestTaskService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Export(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListDelta 列出游标之后的文件变更，供同步客户端增量同步
+func ListDelta(c *gin.Context) {
+	var service explorer.DeltaService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Delta(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
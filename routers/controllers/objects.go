@@ -52,6 +52,21 @@ func Copy(c *gin.Context) {
 	}
 }
 
+// CopyToUser 复制文件或目录到其他用户空间
+func CopyToUser(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.CrossUserCopyService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Copy(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // Rename 重命名文件或目录
 func Rename(c *gin.Context) {
 	// 创建上下文
@@ -82,3 +97,65 @@ func GetProperty(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// ListStarred 列出加了星标的文件和目录
+func ListStarred(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.StarredListService
+	res := service.List(ctx, c)
+	c.JSON(200, res)
+}
+
+// StarObjects 为文件和目录添加星标
+func StarObjects(c *gin.Context) {
+	var service explorer.ItemIDService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Star(c, CurrentUser(c), true)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// UnstarObjects 移除文件和目录的星标
+func UnstarObjects(c *gin.Context) {
+	var service explorer.ItemIDService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Star(c, CurrentUser(c), false)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListExpirations 列出设定了到期规则的文件和目录
+func ListExpirations(c *gin.Context) {
+	var service explorer.ExpirationListService
+	res := service.List(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
+// SetExpirations 为文件和目录设定到期规则
+func SetExpirations(c *gin.Context) {
+	var service explorer.ExpirationSetService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Set(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ClearExpirations 移除文件和目录的到期规则
+func ClearExpirations(c *gin.Context) {
+	var service explorer.ItemIDService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.ClearExpiration(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"github.com/cloudreve/Cloudreve/v3/service/explorer"
+	"github.com/gin-gonic/gin"
+)
+
+// ListPhotos 按拍摄时间列出照片
+func ListPhotos(c *gin.Context) {
+	var service explorer.PhotoListService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.List(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// PhotoTimeline 获取照片时间线
+func PhotoTimeline(c *gin.Context) {
+	var service explorer.PhotoTimelineService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Timeline(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
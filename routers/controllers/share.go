@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/cloudreve/Cloudreve/v3/service/share"
@@ -34,6 +35,17 @@ func GetShare(c *gin.Context) {
 	}
 }
 
+// GetShareStats 查看自己分享的访问统计
+func GetShareStats(c *gin.Context) {
+	var service share.ShareStatsService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Stats(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // ListShare 列出分享
 func ListShare(c *gin.Context) {
 	var service share.ShareListService
@@ -78,6 +90,70 @@ func DeleteShare(c *gin.Context) {
 	}
 }
 
+// GetShareQRCode 获取分享链接的二维码
+func GetShareQRCode(c *gin.Context) {
+	var service share.ShareQRCodeService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.QRCode(c)
+		if res.Code >= 0 {
+			c.JSON(200, res)
+		}
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListShareTargets 列出分享的分享对象
+func ListShareTargets(c *gin.Context) {
+	var service share.ShareTargetListService
+	res := service.List(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
+// CreateShareTargets 为分享添加分享对象
+func CreateShareTargets(c *gin.Context) {
+	var service share.ShareTargetCreateService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DeleteShareTargets 撤销分享对象
+func DeleteShareTargets(c *gin.Context) {
+	var service share.ShareTargetDeleteService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Delete(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListSharedWithMe 列出分享给我的分享
+func ListSharedWithMe(c *gin.Context) {
+	var service share.SharedWithMeService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.List(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// MountShare 将分享的目录挂载至自己的目录下
+func MountShare(c *gin.Context) {
+	var service share.ShareMountService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Mount(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // GetShareDownload 创建分享下载会话
 func GetShareDownload(c *gin.Context) {
 	var service share.Service
@@ -184,6 +260,22 @@ func ListSharedFolder(c *gin.Context) {
 	}
 }
 
+// UploadToShare 访客向分享的目录上传文件
+func UploadToShare(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service share.UploadService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Upload(ctx, c)
+		c.JSON(200, res)
+		request.BlackHole(c.Request.Body)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // SearchSharedFolder 搜索分享的目录下的对象
 func SearchSharedFolder(c *gin.Context) {
 	var service share.SearchService
@@ -235,3 +327,39 @@ func GetUserShare(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// ReportShare 举报分享
+func ReportShare(c *gin.Context) {
+	var service share.ReportService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Report(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// EmbedShare 输出嵌入其他站点的分享播放页
+func EmbedShare(c *gin.Context) {
+	var service share.EmbedService
+	res := service.Page(c)
+	// 是否有错误发生
+	if res.Code != 0 {
+		c.JSON(200, res)
+	}
+}
+
+// ShareOEmbed 获取分享链接的 oEmbed 嵌入信息
+func ShareOEmbed(c *gin.Context) {
+	var service share.OEmbedService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.OEmbed(c)
+		if res.Code != 0 {
+			c.JSON(404, res)
+			return
+		}
+		c.JSON(200, res.Data)
+	} else {
+		c.JSON(400, ErrorResponse(err))
+	}
+}
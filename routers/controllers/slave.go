@@ -124,6 +124,21 @@ func SlaveDelete(c *gin.Context) {
 	}
 }
 
+// SlaveGenerateThumb 从机生成缩略图
+func SlaveGenerateThumb(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.SlaveThumbService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Generate(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // SlavePing 从机测试
 func SlavePing(c *gin.Context) {
 	var service admin.SlavePingService
@@ -135,6 +150,25 @@ func SlavePing(c *gin.Context) {
 	}
 }
 
+// SlaveFetch 从机从给定地址拉取文件
+func SlaveFetch(c *gin.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.SlaveFetchService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Fetch(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// SlaveHealth 从机健康检查
+func SlaveHealth(c *gin.Context) {
+	c.JSON(200, serializer.Response{})
+}
+
 // SlaveList 从机列出文件
 func SlaveList(c *gin.Context) {
 	var service explorer.SlaveListService
@@ -37,3 +37,43 @@ func DeleteTag(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// CreateSmartTag 创建智能标签
+func CreateSmartTag(c *gin.Context) {
+	var service explorer.SmartTagCreateService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListFileTags 列出用户使用过的文件标签
+func ListFileTags(c *gin.Context) {
+	var service explorer.TagService
+	res := service.ListFileTags(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
+// AddFileTags 为文件添加标签
+func AddFileTags(c *gin.Context) {
+	var service explorer.FileTagService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Add(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// RemoveFileTags 移除文件上的标签
+func RemoveFileTags(c *gin.Context) {
+	var service explorer.FileTagService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Remove(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/service/explorer"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	tusVersion            = "1.0.0"
+	tusExtensions         = "creation,termination,checksum"
+	tusChecksumAlgorithms = "md5,sha1,sha256"
+	tusContentType        = "application/offset+octet-stream"
+
+	// statusChecksumMismatch tus checksum 扩展定义的校验失败状态码
+	statusChecksumMismatch = 460
+)
+
+// TusOptions 返回 tus 服务端支持的协议版本和扩展
+func TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Checksum-Algorithm", tusChecksumAlgorithms)
+	c.Status(http.StatusNoContent)
+}
+
+// TusCreate 创建 tus 上传资源
+func TusCreate(c *gin.Context) {
+	if !tusPrecondition(c) {
+		return
+	}
+
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.TusService
+	res := service.Create(ctx, c)
+	if res.Code != 0 {
+		tusError(c, res)
+		return
+	}
+
+	progress := res.Data.(serializer.UploadProgress)
+	c.Header("Location", c.Request.URL.Path+"/"+progress.SessionID)
+	c.Status(http.StatusCreated)
+}
+
+// TusHead 查询 tus 上传资源的当前偏移量
+func TusHead(c *gin.Context) {
+	if !tusPrecondition(c) {
+		return
+	}
+
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.TusService
+	if err := c.ShouldBindUri(&service); err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	res := service.Offset(ctx, c)
+	if res.Code != 0 {
+		tusError(c, res)
+		return
+	}
+
+	progress := res.Data.(serializer.UploadProgress)
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatUint(progress.Uploaded, 10))
+	c.Header("Upload-Length", strconv.FormatUint(progress.Size, 10))
+	c.Status(http.StatusOK)
+}
+
+// TusPatch 向 tus 上传资源追加数据
+func TusPatch(c *gin.Context) {
+	if !tusPrecondition(c) {
+		return
+	}
+
+	if c.ContentType() != tusContentType {
+		c.Status(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.TusService
+	if err := c.ShouldBindUri(&service); err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	res := service.Patch(ctx, c)
+	if res.Code != 0 {
+		tusError(c, res)
+		return
+	}
+
+	progress := res.Data.(serializer.UploadProgress)
+	c.Header("Upload-Offset", strconv.FormatUint(progress.Uploaded, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// TusTerminate 终止 tus 上传并删除已上传的数据
+func TusTerminate(c *gin.Context) {
+	if !tusPrecondition(c) {
+		return
+	}
+
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.UploadSessionService
+	if err := c.ShouldBindUri(&service); err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	res := service.Delete(ctx, c)
+	if res.Code != 0 {
+		tusError(c, res)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// tusPrecondition 校验客户端协议版本
+func tusPrecondition(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.Status(http.StatusPreconditionFailed)
+		return false
+	}
+
+	return true
+}
+
+// tusError 将业务错误码转换为 tus 协议状态码
+func tusError(c *gin.Context, res serializer.Response) {
+	status := http.StatusInternalServerError
+	switch res.Code {
+	case serializer.CodeUploadSessionExpired:
+		status = http.StatusNotFound
+	case serializer.CodeConflict, serializer.CodeObjectExist, serializer.CodeConflictUploadOngoing:
+		status = http.StatusConflict
+	case serializer.CodeChecksumMismatch:
+		status = statusChecksumMismatch
+	case serializer.CodeFileTooLarge, serializer.CodeInsufficientCapacity:
+		status = http.StatusRequestEntityTooLarge
+	case serializer.CodePolicyNotAllowed, serializer.CodeFileTypeNotAllowed, serializer.CodeVirusDetected:
+		status = http.StatusForbidden
+	case serializer.CodeParamErr, serializer.CodeInvalidContentLength, serializer.CodeIllegalObjectName:
+		status = http.StatusBadRequest
+	}
+
+	c.String(status, res.Msg)
+}
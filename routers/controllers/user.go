@@ -233,6 +233,17 @@ func UserStorage(c *gin.Context) {
 	c.JSON(200, res)
 }
 
+// UserTraffic 获取用户流量统计
+func UserTraffic(c *gin.Context) {
+	var service user.TrafficService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Report(CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // UserTasks 获取任务队列
 func UserTasks(c *gin.Context) {
 	var service user.SettingListService
@@ -244,6 +255,17 @@ func UserTasks(c *gin.Context) {
 	}
 }
 
+// UserCancelTask 取消任务
+func UserCancelTask(c *gin.Context) {
+	var service user.TaskCancelService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Cancel(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // UserSetting 获取用户设定
 func UserSetting(c *gin.Context) {
 	var service user.SettingService
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"context"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/wopi"
 	"github.com/cloudreve/Cloudreve/v3/service/explorer"
@@ -38,8 +39,8 @@ func PutFile(c *gin.Context) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	service := &explorer.FileIDService{}
-	res := service.PutContent(ctx, c)
+	var service explorer.WopiService
+	res := service.PutFile(ctx, c)
 	switch res.Code {
 	case serializer.CodeFileTooLarge:
 		c.Status(http.StatusRequestEntityTooLarge)
@@ -47,7 +48,11 @@ func PutFile(c *gin.Context) {
 	case serializer.CodeNotFound:
 		c.Status(http.StatusNotFound)
 		c.Header(wopi.ServerErrorHeader, res.Error)
+	case serializer.CodeFileLocked:
+		lock, _ := filesystem.GetFileLock(c.MustGet("object_id").(uint))
+		wopiLockConflict(c, lock)
 	case 0:
+		c.Header(wopi.ItemVersionHeader, res.Data.(string))
 		c.Status(http.StatusOK)
 	default:
 		c.Status(http.StatusInternalServerError)
@@ -59,7 +64,23 @@ func PutFile(c *gin.Context) {
 func ModifyFile(c *gin.Context) {
 	action := c.GetHeader(wopi.OverwriteHeader)
 	switch action {
-	case wopi.MethodLock, wopi.MethodRefreshLock, wopi.MethodUnlock:
+	case wopi.MethodLock, wopi.MethodRefreshLock, wopi.MethodUnlock, wopi.MethodGetLock:
+		var service explorer.WopiService
+		lock, err := service.Lock(c, action)
+		if err == filesystem.ErrFileLocked {
+			wopiLockConflict(c, lock)
+			return
+		}
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			c.Header(wopi.ServerErrorHeader, err.Error())
+			return
+		}
+
+		if action == wopi.MethodGetLock {
+			c.Header(wopi.LockHeader, wopiLockToken(lock))
+		}
 		c.Status(http.StatusOK)
 		return
 	case wopi.MethodRename:
@@ -75,3 +96,21 @@ func ModifyFile(c *gin.Context) {
 		return
 	}
 }
+
+// wopiLockToken 返回 WOPI 客户端可见的锁令牌，其他客户端持有的锁以空令牌表示
+func wopiLockToken(lock *filesystem.FileLock) string {
+	if lock == nil || lock.App != filesystem.LockAppWOPI {
+		return ""
+	}
+
+	return lock.Token
+}
+
+// wopiLockConflict 返回锁冲突响应
+func wopiLockConflict(c *gin.Context, lock *filesystem.FileLock) {
+	c.Header(wopi.LockHeader, wopiLockToken(lock))
+	if lock != nil && lock.App != filesystem.LockAppWOPI {
+		c.Header(wopi.LockFailureHeader, "File is locked by another client")
+	}
+	c.Status(http.StatusConflict)
+}
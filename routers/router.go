@@ -47,6 +47,8 @@ func InitSlaveRouter() *gin.Engine {
 		v3.POST("ping", controllers.SlavePing)
 		// 测试 Aria2 RPC 连接
 		v3.POST("ping/aria2", controllers.AdminTestAria2)
+		// 健康检查
+		v3.GET("health", controllers.SlaveHealth)
 		// 接收主机心跳包
 		v3.POST("heartbeat", controllers.SlaveHeartbeat)
 		// 上传
@@ -65,10 +67,14 @@ func InitSlaveRouter() *gin.Engine {
 		v3.GET("source/:speed/:path/:name", controllers.SlavePreview)
 		// 缩略图
 		v3.GET("thumb/:path/:ext", controllers.SlaveThumb)
+		// 生成缩略图
+		v3.POST("thumb", controllers.SlaveGenerateThumb)
 		// 删除文件
 		v3.POST("delete", controllers.SlaveDelete)
 		// 列出文件
 		v3.POST("list", controllers.SlaveList)
+		// 从其他从机拉取文件
+		v3.POST("fetch", controllers.SlaveFetch)
 
 		// 离线下载
 		aria2 := v3.Group("aria2")
@@ -153,6 +159,17 @@ func InitMasterRouter() *gin.Engine {
 			source.GET(":id/:name",
 				middleware.HashID(hashid.SourceLinkID),
 				middleware.ValidateSourceLink(),
+				middleware.HotlinkProtection(),
+				controllers.AnonymousPermLink)
+		}
+
+		// Redirect temporary file source link
+		tempSource := r.Group("t")
+		{
+			tempSource.GET(":token/:name",
+				middleware.ValidateTempSourceLink(),
+				middleware.HotlinkProtection(),
+				middleware.UseTempSourceLink(),
 				controllers.AnonymousPermLink)
 		}
 
@@ -313,12 +330,23 @@ func InitMasterRouter() *gin.Engine {
 			// Google Drive related
 			gdrive := callback.Group("googledrive")
 			{
+				// 文件上传完成
+				gdrive.GET(
+					"finish/:sessionID",
+					middleware.UseUploadSession("googledrive"),
+					controllers.GoogleDriveCallback,
+				)
 				// OAuth 完成
 				gdrive.GET(
 					"auth",
 					controllers.GoogleDriveOAuth,
 				)
 			}
+			// Dropbox 授权回调
+			callback.GET(
+				"dropbox/auth",
+				controllers.DropboxOAuth,
+			)
 			// 腾讯云COS策略上传回调
 			callback.GET(
 				"cos/:sessionID",
@@ -331,6 +359,24 @@ func InitMasterRouter() *gin.Engine {
 				middleware.UseUploadSession("s3"),
 				controllers.S3Callback,
 			)
+			// Azure Blob策略上传回调
+			callback.GET(
+				"azure/:sessionID",
+				middleware.UseUploadSession("azure"),
+				controllers.AzureCallback,
+			)
+			// Google Cloud Storage策略上传回调
+			callback.GET(
+				"gcs/:sessionID",
+				middleware.UseUploadSession("gcs"),
+				controllers.GCSCallback,
+			)
+			// OpenStack Swift策略上传回调
+			callback.GET(
+				"swift/:sessionID",
+				middleware.UseUploadSession("swift"),
+				controllers.SwiftCallback,
+			)
 		}
 
 		// 分享相关
@@ -341,6 +387,7 @@ func InitMasterRouter() *gin.Engine {
 			// 创建文件下载会话
 			share.PUT("download/:id",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareCanDownload(),
 				middleware.BeforeShareDownload(),
 				controllers.GetShareDownload,
 			)
@@ -362,6 +409,7 @@ func InitMasterRouter() *gin.Engine {
 			// 获取文本文件内容
 			share.GET("content/:id",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareCanPreview(),
 				middleware.BeforeShareDownload(),
 				controllers.PreviewShareText,
 			)
@@ -378,6 +426,7 @@ func InitMasterRouter() *gin.Engine {
 			// 归档打包下载
 			share.POST("archive/:id",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareCanDownload(),
 				middleware.BeforeShareDownload(),
 				controllers.ArchiveShare,
 			)
@@ -386,6 +435,37 @@ func InitMasterRouter() *gin.Engine {
 				middleware.CheckShareUnlocked(),
 				controllers.PreviewShareReadme,
 			)
+			// 访客向分享的目录上传文件
+			share.POST("upload/:id",
+				middleware.CheckShareUnlocked(),
+				middleware.ShareCanUpload(),
+				controllers.UploadToShare,
+			)
+			// 列出分享文件的评论
+			share.GET("comment/:id",
+				middleware.CheckShareUnlocked(),
+				controllers.ListShareComments,
+			)
+			// 在分享的文件上发表评论
+			share.POST("comment/:id",
+				middleware.CheckShareUnlocked(),
+				controllers.CreateShareComment,
+			)
+			// 举报分享
+			share.POST("report/:id", controllers.ReportShare)
+			// 嵌入其他站点的播放页
+			share.GET("embed/:id",
+				middleware.ShareCanEmbed(),
+				controllers.EmbedShare,
+			)
+			// 嵌入页中的文件内容，地址由播放页签发，只能由播放页加载
+			share.GET("embed/:id/content",
+				middleware.SignRequired(auth.General),
+				middleware.ShareCanEmbed(),
+				middleware.ShareEmbedReferer(),
+				middleware.BeforeShareDownload(),
+				controllers.PreviewShare,
+			)
 			// 获取缩略图
 			share.GET("thumb/:id/:file",
 				middleware.CheckShareUnlocked(),
@@ -394,6 +474,8 @@ func InitMasterRouter() *gin.Engine {
 			)
 			// 搜索公共分享
 			v3.Group("share").GET("search", controllers.SearchShare)
+			// 获取分享链接的 oEmbed 嵌入信息
+			v3.Group("share").GET("oembed", controllers.ShareOEmbed)
 		}
 
 		wopi := v3.Group(
@@ -429,6 +511,8 @@ func InitMasterRouter() *gin.Engine {
 				admin.POST("setting", controllers.AdminGetSetting)
 				// 获取用户组列表
 				admin.GET("groups", controllers.AdminGetGroups)
+				// 获取流量统计
+				admin.GET("traffic", controllers.AdminTrafficReport)
 				// 重新加载子服务
 				admin.GET("reload/:service", controllers.AdminReloadService)
 				// 测试设置
@@ -469,10 +553,16 @@ func InitMasterRouter() *gin.Engine {
 						oauth.GET("onedrive", controllers.AdminOAuthURL("onedrive"))
 						// 获取 Google Drive OAuth URL
 						oauth.GET("googledrive", controllers.AdminOAuthURL("googledrive"))
+						// 获取 Dropbox OAuth URL
+						oauth.GET("dropbox", controllers.AdminOAuthURL("dropbox"))
 					}
 
 					// 获取 存储策略
 					policy.GET(":id", controllers.AdminGetPolicy)
+					// 探测从机存储策略的健康状态
+					policy.GET(":id/health", controllers.AdminPolicyHealth)
+					// 轮换存储策略凭证
+					policy.POST(":id/credential", controllers.AdminRotatePolicyCredential)
 					// 删除 存储策略
 					policy.DELETE(":id", controllers.AdminDeletePolicy)
 				}
@@ -502,6 +592,8 @@ func InitMasterRouter() *gin.Engine {
 					user.POST("delete", controllers.AdminDeleteUser)
 					// 封禁/解封用户
 					user.PATCH("ban/:id", controllers.AdminBanUser)
+					// 下载用户的文件清单
+					user.GET("manifest/:id", controllers.AdminDownloadUserManifest)
 				}
 
 				file := admin.Group("file")
@@ -512,17 +604,33 @@ func InitMasterRouter() *gin.Engine {
 					file.GET("preview/:id", middleware.Sandbox(), controllers.AdminGetFile)
 					// 删除
 					file.POST("delete", controllers.AdminDeleteFile)
+					// 复制到其他用户空间
+					file.POST("copy", controllers.AdminCopyFile)
 					// 列出用户或外部文件系统目录
 					file.GET("folders/:type/:id/*path",
 						controllers.AdminListFolders)
 				}
 
+				template := admin.Group("template")
+				{
+					// 列出公共目录模板
+					template.GET("", controllers.AdminListFolderTemplates)
+					// 创建公共目录模板
+					template.POST("", controllers.AdminAddFolderTemplate)
+					// 删除公共目录模板
+					template.DELETE(":id", controllers.AdminDeleteFolderTemplate)
+				}
+
 				share := admin.Group("share")
 				{
 					// 列出分享
 					share.POST("list", controllers.AdminListShare)
 					// 删除
 					share.POST("delete", controllers.AdminDeleteShare)
+					// 列出举报
+					share.POST("report/list", controllers.AdminListShareReport)
+					// 处理举报
+					share.PATCH("report/:id", controllers.AdminHandleShareReport)
 				}
 
 				download := admin.Group("download")
@@ -541,6 +649,10 @@ func InitMasterRouter() *gin.Engine {
 					task.POST("delete", controllers.AdminDeleteTask)
 					// 新建文件导入任务
 					task.POST("import", controllers.AdminCreateImportTask)
+					// 新建存储策略迁移任务
+					task.POST("migrate", controllers.AdminCreateMigrateTask)
+					// 新建文件完整性校验任务
+					task.POST("integrity", controllers.AdminCreateIntegrityTask)
 				}
 
 				node := admin.Group("node")
@@ -568,6 +680,8 @@ func InitMasterRouter() *gin.Engine {
 				user.GET("me", controllers.UserMe)
 				// 存储信息
 				user.GET("storage", controllers.UserStorage)
+				// 流量统计
+				user.GET("traffic", controllers.UserTraffic)
 				// 退出登录
 				user.DELETE("session", controllers.UserSignOut)
 				// Generate temp URL for copying client-side session, used in adding accounts
@@ -587,6 +701,8 @@ func InitMasterRouter() *gin.Engine {
 				{
 					// 任务队列
 					setting.GET("tasks", controllers.UserTasks)
+					// 取消任务
+					setting.DELETE("tasks/:id", controllers.UserCancelTask)
 					// 获取当前用户设定
 					setting.GET("", controllers.UserSetting)
 					// 从文件上传头像
@@ -610,27 +726,87 @@ func InitMasterRouter() *gin.Engine {
 					upload.POST(":sessionId/:index", controllers.FileUpload)
 					// 创建上传会话
 					upload.PUT("", controllers.GetUploadSession)
+					// 文件夹上传，按相对路径批量创建上传会话
+					upload.PUT("folder", controllers.GetFolderUploadSession)
+					// 列出进行中的上传会话
+					upload.GET("", controllers.ListUploadSessions)
+					// 获取上传会话续传进度
+					upload.GET(":sessionId", controllers.GetUploadSessionProgress)
+					// 订阅上传会话服务端写入进度
+					upload.GET(":sessionId/progress", controllers.UploadProgressSocket)
 					// 删除给定上传会话
 					upload.DELETE(":sessionId", controllers.DeleteUploadSession)
 					// 删除全部上传会话
 					upload.DELETE("", controllers.DeleteAllUploadSession)
 				}
+				// tus 协议上传
+				tus := file.Group("tus")
+				{
+					// 查询服务端支持的协议扩展
+					tus.OPTIONS("", controllers.TusOptions)
+					// 创建上传资源
+					tus.POST("", controllers.TusCreate)
+					// 查询上传偏移量
+					tus.HEAD(":sessionId", controllers.TusHead)
+					// 追加上传数据
+					tus.PATCH(":sessionId", controllers.TusPatch)
+					// 终止上传
+					tus.DELETE(":sessionId", controllers.TusTerminate)
+				}
 				// 更新文件
 				file.PUT("update/:id", controllers.PutContent)
+				// 编辑器保存文件，拒绝基于过期修订号的修改
+				file.PUT("save/:id", controllers.SaveContent)
+				// 列出文件评论
+				file.GET("comment/:id", controllers.ListComments)
+				// 发表文件评论
+				file.POST("comment/:id", controllers.CreateComment)
 				// 创建空白文件
 				file.POST("create", controllers.CreateFile)
 				// 创建文件下载会话
 				file.PUT("download/:id", controllers.CreateDownloadSession)
+				// 解冻归档存储中的文件
+				file.POST("restore/:id", controllers.RestoreFile)
+				// 列出文件的历史版本
+				file.GET("versions/:id", controllers.ListFileVersions)
+				// 恢复文件的历史版本
+				file.POST("versions/:id/:version", controllers.RestoreFileVersion)
+				// 删除文件的历史版本
+				file.DELETE("versions/:id/:version", controllers.DeleteFileVersion)
+				// 列出文件的自定义元数据
+				file.GET("metadata/:id", controllers.ListFileProperties)
+				// 设定并移除文件的自定义元数据
+				file.PATCH("metadata/:id", controllers.UpdateFileProperties)
+				// 获取文件锁
+				file.GET("lock/:id", controllers.GetFileLock)
+				// 为文件加锁
+				file.PUT("lock/:id", controllers.LockFile)
+				// 释放文件锁
+				file.DELETE("lock/:id", controllers.UnlockFile)
+				// 创建视频转码任务
+				file.POST("transcode/:id", controllers.TranscodeVideo)
 				// 预览文件
 				file.GET("preview/:id", middleware.Sandbox(), controllers.Preview)
 				// 获取文本文件内容
 				file.GET("content/:id", middleware.Sandbox(), controllers.PreviewText)
+				// 获取文本文件开头部分的预览
+				file.GET("text/:id", controllers.PreviewTextHead)
+				// 获取视频转码生成的 HLS 播放列表或分片
+				file.GET("hls/:id/*path", controllers.PreviewHLS)
+				// 获取转换为浏览器可播放格式的媒体文件
+				file.GET("playable/:id", controllers.PreviewTranscoded)
 				// 取得Office文档预览地址
 				file.GET("doc/:id", controllers.GetDocPreview)
 				// 获取缩略图
 				file.GET("thumb/:id", controllers.Thumb)
+				// 获取音频文件的标签信息
+				file.GET("audio/:id", controllers.GetAudioTags)
+				// 获取音频文件的封面图片
+				file.GET("audio/:id/cover", controllers.GetAudioCover)
 				// 取得文件外链
 				file.POST("source", controllers.GetSource)
+				// 创建有效期和下载次数受限的临时外链
+				file.POST("source/:id", controllers.CreateTempSource)
 				// 打包要下载的文件
 				file.POST("archive", controllers.Archive)
 				// 创建文件压缩任务
@@ -639,6 +815,55 @@ func InitMasterRouter() *gin.Engine {
 				file.POST("decompress", controllers.Decompress)
 				// 创建文件解压缩任务
 				file.GET("search/:type/:keywords", controllers.SearchFile)
+				// 列出内容相同的文件
+				file.GET("duplicates", controllers.ListDuplicates)
+				// 合并内容相同的文件
+				file.POST("duplicates", controllers.DeduplicateFiles)
+				// 为旧文件计算内容摘要
+				file.POST("duplicates/scan", controllers.ScanDuplicates)
+				// 创建文件完整性校验任务
+				file.POST("integrity", controllers.VerifyFiles)
+				// 下载文件清单
+				file.GET("manifest", controllers.DownloadManifest)
+				// 创建文件清单导出任务
+				file.POST("manifest", controllers.ExportManifest)
+				// 列出游标之后的文件变更
+				file.GET("delta", controllers.ListDelta)
+			}
+
+			// 照片
+			photo := auth.Group("photo")
+			{
+				// 按拍摄时间、地点列出照片
+				photo.GET("", controllers.ListPhotos)
+				// 获取照片时间线
+				photo.GET("timeline", controllers.PhotoTimeline)
+			}
+
+			// 操作动态
+			activity := auth.Group("activity")
+			{
+				// 列出最近的操作动态
+				activity.GET("", controllers.ListActivities)
+			}
+
+			// 文件评论
+			comment := auth.Group("comment")
+			{
+				// 删除评论
+				comment.DELETE(":id",
+					middleware.HashID(hashid.CommentID),
+					controllers.DeleteComment,
+				)
+			}
+
+			// 站内通知
+			notification := auth.Group("notification")
+			{
+				// 列出通知
+				notification.GET("", controllers.ListNotifications)
+				// 标记通知已读
+				notification.PUT("read", controllers.ReadNotifications)
 			}
 
 			// 离线下载任务
@@ -665,6 +890,23 @@ func InitMasterRouter() *gin.Engine {
 				directory.PUT("", controllers.CreateDirectory)
 				// 列出目录下内容
 				directory.GET("*path", controllers.ListDirectory)
+				// 设定目录绑定的存储策略
+				directory.PATCH("policy", controllers.SetDirectoryPolicy)
+				// 设定目录的显示属性
+				directory.PATCH("display", controllers.SetDirectoryDisplay)
+			}
+
+			// 回收站
+			trash := auth.Group("trash")
+			{
+				// 列出回收站中的对象
+				trash.GET("", controllers.ListTrash)
+				// 恢复对象
+				trash.POST("restore", controllers.RestoreTrash)
+				// 彻底删除对象
+				trash.DELETE("", controllers.PurgeTrash)
+				// 清空回收站
+				trash.DELETE("all", controllers.EmptyTrash)
 			}
 
 			// 对象，文件和目录的抽象
@@ -676,10 +918,24 @@ func InitMasterRouter() *gin.Engine {
 				object.PATCH("", controllers.Move)
 				// 复制对象
 				object.POST("copy", controllers.Copy)
+				// 复制对象到其他用户空间
+				object.POST("copy/user", controllers.CopyToUser)
 				// 重命名对象
 				object.POST("rename", controllers.Rename)
 				// 获取对象属性
 				object.GET("property/:id", controllers.GetProperty)
+				// 列出星标对象
+				object.GET("star", controllers.ListStarred)
+				// 添加星标
+				object.PUT("star", controllers.StarObjects)
+				// 移除星标
+				object.DELETE("star", controllers.UnstarObjects)
+				// 列出到期规则
+				object.GET("expiration", controllers.ListExpirations)
+				// 设定到期规则
+				object.PUT("expiration", controllers.SetExpirations)
+				// 移除到期规则
+				object.DELETE("expiration", controllers.ClearExpirations)
 			}
 
 			// 分享
@@ -699,6 +955,36 @@ func InitMasterRouter() *gin.Engine {
 				share.DELETE(":id",
 					controllers.DeleteShare,
 				)
+				// 查看分享的访问统计
+				share.GET("stats/:id", controllers.GetShareStats)
+				// 获取分享链接的二维码
+				share.GET("qrcode/:id",
+					middleware.ShareAvailable(),
+					middleware.ShareOwner(),
+					controllers.GetShareQRCode,
+				)
+				// 列出分享给我的分享
+				share.GET("received", controllers.ListSharedWithMe)
+				// 列出分享对象
+				share.GET("target/:id",
+					middleware.ShareAvailable(),
+					controllers.ListShareTargets,
+				)
+				// 添加分享对象
+				share.POST("target/:id",
+					middleware.ShareAvailable(),
+					controllers.CreateShareTargets,
+				)
+				// 撤销分享对象
+				share.DELETE("target/:id",
+					middleware.ShareAvailable(),
+					controllers.DeleteShareTargets,
+				)
+				// 挂载分享至自己的目录
+				share.POST("mount/:id",
+					middleware.ShareAvailable(),
+					controllers.MountShare,
+				)
 			}
 
 			// 用户标签
@@ -708,10 +994,31 @@ func InitMasterRouter() *gin.Engine {
 				tag.POST("filter", controllers.CreateFilterTag)
 				// 创建目录快捷方式标签
 				tag.POST("link", controllers.CreateLinkTag)
+				// 创建智能标签
+				tag.POST("smart", controllers.CreateSmartTag)
+				// 列出文件标签
+				tag.GET("files", controllers.ListFileTags)
+				// 为文件添加标签
+				tag.POST("files", controllers.AddFileTags)
+				// 移除文件上的标签
+				tag.DELETE("files", controllers.RemoveFileTags)
 				// 删除标签
 				tag.DELETE(":id", middleware.HashID(hashid.TagID), controllers.DeleteTag)
 			}
 
+			// 目录模板
+			template := auth.Group("template")
+			{
+				// 列出可用的目录模板
+				template.GET("", controllers.ListFolderTemplates)
+				// 创建目录模板
+				template.POST("", controllers.CreateFolderTemplate)
+				// 按模板创建目录结构
+				template.POST(":id", middleware.HashID(hashid.FolderTemplateID), controllers.InstantiateFolderTemplate)
+				// 删除目录模板
+				template.DELETE(":id", middleware.HashID(hashid.FolderTemplateID), controllers.DeleteFolderTemplate)
+			}
+
 			// WebDAV管理相关
 			webdav := auth.Group("webdav")
 			{
@@ -24,6 +24,17 @@ type FileBatchService struct {
 	UnlinkOnly bool   `json:"unlink"`
 }
 
+// FileCopyService 复制用户对象到其他用户空间服务
+type FileCopyService struct {
+	SrcUID   uint   `json:"src_uid" binding:"required"`
+	SrcDir   string `json:"src_dir" binding:"required,min=1,max=65535"`
+	Dirs     []uint `json:"dirs"`
+	Items    []uint `json:"items"`
+	DstUID   uint   `json:"dst_uid" binding:"required"`
+	Dst      string `json:"dst" binding:"required,min=1,max=65535"`
+	Transfer bool   `json:"transfer"`
+}
+
 // ListFolderService 列目录结构
 type ListFolderService struct {
 	Path string `uri:"path" binding:"required,max=65535"`
@@ -206,3 +217,36 @@ func (service *AdminListService) Files() serializer.Response {
 		"users": users,
 	}}
 }
+
+// Copy 将源用户的对象复制到目标用户空间，Transfer 为 true 时同时删除原对象
+func (service *FileCopyService) Copy(c *gin.Context) serializer.Response {
+	srcUser, err := model.GetUserByID(service.SrcUID)
+	if err != nil {
+		return serializer.Err(serializer.CodeUserNotFound, "", err)
+	}
+
+	dstUser, err := model.GetUserByID(service.DstUID)
+	if err != nil {
+		return serializer.Err(serializer.CodeUserNotFound, "", err)
+	}
+
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystem(&srcUser)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	dstFs, err := filesystem.NewFileSystem(&dstUser)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer dstFs.Recycle()
+
+	err = fs.CopyToUser(c.Request.Context(), dstFs, service.Dirs, service.Items, service.SrcDir, service.Dst, service.Transfer)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/dropbox"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/googledrive"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -18,10 +21,17 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/azure"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/cos"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/encrypt"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/gcs"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/onedrive"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/oss"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/remote"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/s3"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/swift"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/request"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
@@ -56,6 +66,15 @@ type PolicyService struct {
 	Region string `json:"region"`
 }
 
+// RotateCredentialService 存储策略凭证轮换服务
+type RotateCredentialService struct {
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key" binding:"required"`
+}
+
+// credentialRotatableTypes 可通过访问密钥轮换凭证的存储策略类型
+var credentialRotatableTypes = []string{"oss", "cos", "s3", "qiniu", "upyun", "azure", "gcs", "swift"}
+
 // Delete 删除存储策略
 func (service *PolicyService) Delete() serializer.Response {
 	// 禁止删除默认策略
@@ -104,6 +123,84 @@ func (service *PolicyService) Get() serializer.Response {
 	return serializer.Response{Data: policy}
 }
 
+// Health 立即探测从机存储策略的健康状态
+func (service *PolicyService) Health(c *gin.Context) serializer.Response {
+	policy, err := model.GetPolicyByID(service.ID)
+	if err != nil || policy.Type != "remote" {
+		return serializer.Err(serializer.CodePolicyNotExist, "", err)
+	}
+
+	return serializer.Response{Data: remote.Probe(c, &policy)}
+}
+
+// Rotate 使用新凭证写入并删除测试文件，校验通过后替换存储策略的凭证。
+// 替换前已创建的适配器及上传会话仍使用旧凭证，旧凭证需在其过期后再停用。
+func (service *RotateCredentialService) Rotate(c *gin.Context, id uint) serializer.Response {
+	policy, err := model.GetPolicyByID(id)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotExist, "", err)
+	}
+
+	if !util.ContainsString(credentialRotatableTypes, policy.Type) {
+		return serializer.Err(serializer.CodePolicyNotAllowed, "This policy does not support credential rotation", nil)
+	}
+
+	accessKey := service.AccessKey
+	if accessKey == "" {
+		accessKey = policy.AccessKey
+	}
+
+	candidate := policy
+	candidate.AccessKey = accessKey
+	candidate.SecretKey = service.SecretKey
+	if err := testCredential(c, &candidate); err != nil {
+		return serializer.Err(serializer.CodePolicyCredentialInvalid, "Failed to validate new credential", err)
+	}
+
+	if err := policy.RotateCredential(accessKey, service.SecretKey); err != nil {
+		if errors.Is(err, model.ErrCredentialChanged) {
+			return serializer.Err(serializer.CodeConflict, "Policy credential has been changed, please retry", err)
+		}
+
+		return serializer.DBErr("Failed to update policy credential", err)
+	}
+
+	// 清理由旧凭证派生的缓存
+	if policy.Type == "swift" {
+		swift.ClearAuthCache(policy.ID)
+	}
+
+	return serializer.Response{}
+}
+
+// testCredential 使用存储策略写入并删除测试文件
+func testCredential(ctx context.Context, policy *model.Policy) error {
+	fs := &filesystem.FileSystem{Policy: policy}
+	if err := fs.DispatchHandler(); err != nil {
+		return err
+	}
+
+	content := "Cloudreve credential test"
+	savePath := "cloudreve_credential_test_" + util.RandStringRunes(16)
+	err := fs.Handler.Put(ctx, &fsctx.FileStream{
+		File:     io.NopCloser(strings.NewReader(content)),
+		Size:     uint64(len(content)),
+		Name:     savePath,
+		SavePath: savePath,
+		Mode:     fsctx.Overwrite,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put test file: %w", err)
+	}
+
+	failed, err := fs.Handler.Delete(ctx, []string{savePath})
+	if err != nil || len(failed) > 0 {
+		return fmt.Errorf("failed to delete test file: %w", err)
+	}
+
+	return nil
+}
+
 // GetOAuth 获取 OneDrive OAuth 地址
 func (service *PolicyService) GetOAuth(c *gin.Context, policyType string) serializer.Response {
 	policy, err := model.GetPolicyByID(service.ID)
@@ -134,6 +231,13 @@ func (service *PolicyService) GetOAuth(c *gin.Context, policyType string) serial
 		}
 
 		redirect = client.OAuthURL(context.Background(), googledrive.RequiredScope)
+	case "dropbox":
+		client, err := dropbox.NewClient(&policy)
+		if err != nil {
+			return serializer.Err(serializer.CodeInternalSetting, "Failed to initialize Dropbox client", err)
+		}
+
+		redirect = client.OAuthURL(context.Background())
 	}
 
 	// Delete token cache
@@ -177,11 +281,12 @@ func (service *PolicyService) AddCORS() serializer.Response {
 		b := &cossdk.BaseURL{BucketURL: u}
 		handler := cos.Driver{
 			Policy:     &policy,
-			HTTPClient: request.NewClient(),
+			HTTPClient: request.NewClient(request.WithProxy(policy.OptionsSerialized.Proxy)),
 			Client: cossdk.NewClient(b, &http.Client{
 				Transport: &cossdk.AuthorizationTransport{
 					SecretID:  policy.AccessKey,
 					SecretKey: policy.SecretKey,
+					Transport: request.ProxyTransport(policy.OptionsSerialized.Proxy),
 				},
 			}),
 		}
@@ -195,6 +300,33 @@ func (service *PolicyService) AddCORS() serializer.Response {
 			return serializer.Err(serializer.CodeAddCORS, "", err)
 		}
 
+		if err := handler.CORS(); err != nil {
+			return serializer.Err(serializer.CodeAddCORS, "", err)
+		}
+	case "azure":
+		handler, err := azure.NewDriver(&policy)
+		if err != nil {
+			return serializer.Err(serializer.CodeAddCORS, "", err)
+		}
+
+		if err := handler.CORS(); err != nil {
+			return serializer.Err(serializer.CodeAddCORS, "", err)
+		}
+	case "gcs":
+		handler, err := gcs.NewDriver(&policy)
+		if err != nil {
+			return serializer.Err(serializer.CodeAddCORS, "", err)
+		}
+
+		if err := handler.CORS(); err != nil {
+			return serializer.Err(serializer.CodeAddCORS, "", err)
+		}
+	case "swift":
+		handler, err := swift.NewDriver(&policy)
+		if err != nil {
+			return serializer.Err(serializer.CodeAddCORS, "", err)
+		}
+
 		if err := handler.CORS(); err != nil {
 			return serializer.Err(serializer.CodeAddCORS, "", err)
 		}
@@ -280,6 +412,63 @@ func (service *AddPolicyService) Add() serializer.Response {
 		service.Policy.DirNameRule = strings.TrimPrefix(service.Policy.DirNameRule, "/")
 	}
 
+	// 分块存储仅支持由 Cloudreve 中转上传的存储策略，上传分片大小需与分块大小一致
+	if service.Policy.OptionsSerialized.ChunkerSize > 0 {
+		switch service.Policy.Type {
+		case "local", "sftp", "ftp", "webdav", "hdfs", "dropbox":
+			service.Policy.OptionsSerialized.ChunkSize = service.Policy.OptionsSerialized.ChunkerSize
+		default:
+			return serializer.ParamErr("Chunker is not supported by policy type: "+service.Policy.Type, nil)
+		}
+	}
+
+	// 开启加密时生成存储策略密钥
+	if service.Policy.OptionsSerialized.Encryption {
+		if service.Policy.Type != "local" && service.Policy.Type != "remote" {
+			return serializer.ParamErr("Only local and slave policies support encryption", nil)
+		}
+
+		if service.Policy.OptionsSerialized.ChunkSize%encrypt.SegmentSize != 0 {
+			return serializer.ParamErr(fmt.Sprintf("Chunk size must be a multiple of %d when encryption is enabled", encrypt.SegmentSize), nil)
+		}
+
+		if service.Policy.OptionsSerialized.EncryptionKey == "" {
+			key, err := encrypt.NewWrappedKey()
+			if err != nil {
+				return serializer.Err(serializer.CodeInternalSetting, "Failed to generate encryption key", err)
+			}
+			service.Policy.OptionsSerialized.EncryptionKey = key
+		}
+	}
+
+	if len(service.Policy.OptionsSerialized.Shards) > 0 && service.Policy.Type != "local" {
+		return serializer.ParamErr("Only local policies support multiple storage roots", nil)
+	}
+
+	switch service.Policy.OptionsSerialized.ShardStrategy {
+	case "", filesystem.ShardMostFree, filesystem.ShardRoundRobin:
+	default:
+		return serializer.ParamErr("Unsupported storage root strategy: "+service.Policy.OptionsSerialized.ShardStrategy, nil)
+	}
+
+	switch service.Policy.OptionsSerialized.S3SSE {
+	case "", "AES256", "aws:kms":
+	default:
+		return serializer.ParamErr("Unsupported server side encryption: "+service.Policy.OptionsSerialized.S3SSE, nil)
+	}
+
+	switch service.Policy.OptionsSerialized.FTPSMode {
+	case "", "explicit", "implicit":
+	default:
+		return serializer.ParamErr("Unsupported FTPS mode: "+service.Policy.OptionsSerialized.FTPSMode, nil)
+	}
+
+	if proxy := service.Policy.OptionsSerialized.Proxy; proxy != "" {
+		if _, err := request.ParseProxy(proxy); err != nil {
+			return serializer.ParamErr("Invalid proxy: "+proxy, err)
+		}
+	}
+
 	if service.Policy.ID > 0 {
 		if err := model.DB.Save(&service.Policy).Error; err != nil {
 			return serializer.DBErr("Failed to save policy", err)
@@ -349,9 +538,18 @@ func (service *AdminListService) Policies() serializer.Response {
 		statics[policyId] = total
 	}
 
+	// 从机存储策略的健康状态
+	health := make(map[uint]remote.Health)
+	for i := 0; i < len(res); i++ {
+		if status, ok := remote.GetHealth(res[i].ID); ok && res[i].Type == "remote" {
+			health[res[i].ID] = status
+		}
+	}
+
 	return serializer.Response{Data: map[string]interface{}{
 		"total":   total,
 		"items":   res,
 		"statics": statics,
+		"health":  health,
 	}}
 }
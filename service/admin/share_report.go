@@ -0,0 +1,121 @@
+package admin
+
+import (
+	"strings"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+)
+
+// ShareReportHandleService 处理分享举报服务
+type ShareReportHandleService struct {
+	ID           uint   `uri:"id" json:"-" binding:"required"`
+	Action       string `json:"action" binding:"required,eq=dismiss|eq=disable|eq=ban"`
+	SuspendOwner bool   `json:"suspend_owner"`
+}
+
+// ShareReports 列出分享举报
+func (service *AdminListService) ShareReports() serializer.Response {
+	var res []model.ShareReport
+	total := 0
+
+	tx := model.DB.Model(&model.ShareReport{})
+	if service.OrderBy != "" {
+		tx = tx.Order(service.OrderBy)
+	}
+
+	for k, v := range service.Conditions {
+		tx = tx.Where(k+" = ?", v)
+	}
+
+	if len(service.Searches) > 0 {
+		search := ""
+		for k, v := range service.Searches {
+			search += k + " like '%" + v + "%' OR "
+		}
+		search = strings.TrimSuffix(search, " OR ")
+		tx = tx.Where(search)
+	}
+
+	// 计算总数用于分页
+	tx.Count(&total)
+
+	// 查询记录
+	tx.Limit(service.PageSize).Offset((service.Page - 1) * service.PageSize).Find(&res)
+
+	// 查询对应分享，同时计算HashID
+	shares := make(map[uint]model.Share)
+	hashIDs := make(map[uint]string, len(res))
+	for _, report := range res {
+		shares[report.ShareID] = model.Share{}
+		hashIDs[report.ShareID] = hashid.HashID(report.ShareID, hashid.ShareID)
+	}
+
+	shareIDs := make([]uint, 0, len(shares))
+	for k := range shares {
+		shareIDs = append(shareIDs, k)
+	}
+
+	var shareList []model.Share
+	model.DB.Unscoped().Where("id in (?)", shareIDs).Find(&shareList)
+
+	for _, v := range shareList {
+		shares[v.ID] = v
+	}
+
+	return serializer.Response{Data: map[string]interface{}{
+		"total":  total,
+		"items":  res,
+		"shares": shares,
+		"ids":    hashIDs,
+	}}
+}
+
+// Handle 处理举报，分享所有待处理的举报一并结案。停用或封禁分享时可同时封禁分享的创建者
+func (service *ShareReportHandleService) Handle(admin *model.User) serializer.Response {
+	report, err := model.GetShareReportByID(service.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "Report not exist", err)
+	}
+
+	if service.Action == "dismiss" {
+		if err := model.ResolveShareReports(report.ShareID, admin.ID, model.ShareReportDismissed); err != nil {
+			return serializer.DBErr("Failed to update report", err)
+		}
+		return serializer.Response{}
+	}
+
+	share, err := model.GetShareByID(report.ShareID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "Share not exist", err)
+	}
+
+	// 不能封禁初始用户
+	if service.SuspendOwner && share.UserID == 1 {
+		return serializer.Err(serializer.CodeInvalidActionOnDefaultUser, "", nil)
+	}
+
+	if service.Action == "ban" {
+		err = share.Ban()
+	} else {
+		err = share.Disable()
+	}
+	if err != nil {
+		return serializer.DBErr("Failed to disable share", err)
+	}
+
+	if service.SuspendOwner {
+		owner, err := model.GetUserByID(share.UserID)
+		if err != nil {
+			return serializer.Err(serializer.CodeUserNotFound, "", err)
+		}
+		owner.SetStatus(model.Baned)
+	}
+
+	if err := model.ResolveShareReports(share.ID, admin.ID, model.ShareReportResolved); err != nil {
+		return serializer.DBErr("Failed to update report", err)
+	}
+
+	return serializer.Response{}
+}
@@ -34,6 +34,76 @@ func (service *ImportTaskService) Create(c *gin.Context, user *model.User) seria
 	return serializer.Response{}
 }
 
+// MigrateTaskService 存储策略迁移任务
+type MigrateTaskService struct {
+	SrcPolicyID uint `json:"src_policy_id" binding:"required"`
+	DstPolicyID uint `json:"dst_policy_id" binding:"required,nefield=SrcPolicyID"`
+	UID         uint `json:"uid"`
+	SpeedLimit  int  `json:"speed_limit" binding:"min=0"`
+	DryRun      bool `json:"dry_run"`
+}
+
+// Create 新建存储策略迁移任务
+func (service *MigrateTaskService) Create(c *gin.Context, user *model.User) serializer.Response {
+	for _, id := range []uint{service.SrcPolicyID, service.DstPolicyID} {
+		if _, err := model.GetPolicyByID(id); err != nil {
+			return serializer.Err(serializer.CodePolicyNotExist, "", err)
+		}
+	}
+
+	if service.UID > 0 {
+		if _, err := model.GetUserByID(service.UID); err != nil {
+			return serializer.Err(serializer.CodeUserNotFound, "", err)
+		}
+	}
+
+	job, err := task.NewMigrateTask(user, task.MigrateProps{
+		SrcPolicyID: service.SrcPolicyID,
+		DstPolicyID: service.DstPolicyID,
+		UserID:      service.UID,
+		SpeedLimit:  service.SpeedLimit,
+		DryRun:      service.DryRun,
+	})
+	if err != nil {
+		return serializer.DBErr("Failed to create task record.", err)
+	}
+	task.TaskPoll.Submit(job)
+	return serializer.Response{Data: job.Model().ID}
+}
+
+// IntegrityTaskService 文件完整性校验任务，UID 和 PolicyID 为 0 时不限制用户和存储策略
+type IntegrityTaskService struct {
+	UID      uint `json:"uid"`
+	PolicyID uint `json:"policy_id"`
+	Repair   bool `json:"repair"`
+}
+
+// Create 新建文件完整性校验任务
+func (service *IntegrityTaskService) Create(c *gin.Context, user *model.User) serializer.Response {
+	if service.PolicyID > 0 {
+		if _, err := model.GetPolicyByID(service.PolicyID); err != nil {
+			return serializer.Err(serializer.CodePolicyNotExist, "", err)
+		}
+	}
+
+	if service.UID > 0 {
+		if _, err := model.GetUserByID(service.UID); err != nil {
+			return serializer.Err(serializer.CodeUserNotFound, "", err)
+		}
+	}
+
+	job, err := task.NewIntegrityTask(user, task.IntegrityProps{
+		UserID:   service.UID,
+		PolicyID: service.PolicyID,
+		Repair:   service.Repair,
+	})
+	if err != nil {
+		return serializer.DBErr("Failed to create task record.", err)
+	}
+	task.TaskPoll.Submit(job)
+	return serializer.Response{Data: job.Model().ID}
+}
+
 // Delete 删除任务
 func (service *TaskBatchService) Delete(c *gin.Context) serializer.Response {
 	if err := model.DB.Where("id in (?)", service.ID).Delete(&model.Download{}).Error; err != nil {
@@ -0,0 +1,63 @@
+package admin
+
+import (
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// FolderTemplateService 公共目录模板服务
+type FolderTemplateService struct {
+	Name    string   `json:"name" binding:"required,min=1,max=255"`
+	Folders []string `json:"folders" binding:"required,min=1,dive,min=1,max=65535"`
+}
+
+// FolderTemplateIDService 公共目录模板ID服务
+type FolderTemplateIDService struct {
+	ID uint `uri:"id" binding:"required"`
+}
+
+// FolderTemplates 列出公共目录模板
+func (service *NoParamService) FolderTemplates() serializer.Response {
+	templates, err := model.GetFolderTemplatesByUser(0)
+	if err != nil {
+		return serializer.DBErr("Failed to list folder templates", err)
+	}
+
+	return serializer.Response{Data: templates}
+}
+
+// Create 创建公共目录模板
+func (service *FolderTemplateService) Create(c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewAnonymousFileSystem()
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	folders, err := fs.NormalizeTemplateFolders(c, service.Folders)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	template := model.FolderTemplate{
+		Name:       service.Name,
+		FolderList: folders,
+	}
+	id, err := template.Create()
+	if err != nil {
+		return serializer.DBErr("Failed to create folder template", err)
+	}
+
+	return serializer.Response{Data: id}
+}
+
+// Delete 删除公共目录模板
+func (service *FolderTemplateIDService) Delete() serializer.Response {
+	if err := model.DeleteFolderTemplateByID(service.ID, 0); err != nil {
+		return serializer.DBErr("Failed to delete folder template", err)
+	}
+
+	return serializer.Response{}
+}
@@ -0,0 +1,24 @@
+package admin
+
+import (
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+)
+
+// TrafficReportService 流量统计查询服务
+type TrafficReportService struct {
+	UserID   uint   `form:"user"`
+	PolicyID uint   `form:"policy"`
+	From     string `form:"from" binding:"omitempty,datetime=2006-01-02"`
+	To       string `form:"to" binding:"omitempty,datetime=2006-01-02"`
+}
+
+// Report 按用户、存储策略和日期列出流量统计
+func (service *TrafficReportService) Report() serializer.Response {
+	traffics, err := model.GetTraffics(service.UserID, service.PolicyID, service.From, service.To)
+	if err != nil {
+		return serializer.DBErr("Failed to list traffic statistics", err)
+	}
+
+	return serializer.BuildTrafficReport(traffics, false)
+}
@@ -7,6 +7,8 @@ import (
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/service/explorer"
+	"github.com/gin-gonic/gin"
 )
 
 // AddUserService 用户添加服务
@@ -68,6 +70,11 @@ func (service *UserBatchService) Delete() serializer.Response {
 		}
 		fs.Delete(context.Background(), []uint{root.ID}, []uint{}, false, false)
 
+		// 清空回收站
+		if trashes, err := model.ListTrash(uid); err == nil {
+			fs.PurgeTrash(context.Background(), trashes)
+		}
+
 		// 删除相关任务
 		model.DB.Where("user_id = ?", uid).Delete(&model.Download{})
 		model.DB.Where("user_id = ?", uid).Delete(&model.Task{})
@@ -95,8 +102,22 @@ func (service *UserService) Get() serializer.Response {
 	return serializer.Response{Data: group}
 }
 
+// Manifest 下载用户的文件清单
+func (service *UserService) Manifest(ctx context.Context, c *gin.Context, manifest *explorer.ManifestService) serializer.Response {
+	user, err := model.GetUserByID(service.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeUserNotFound, "", err)
+	}
+
+	return manifest.Download(ctx, c, &user)
+}
+
 // Add 添加用户
 func (service *AddUserService) Add() serializer.Response {
+	if limit := service.User.OptionsSerialized.DownloadSpeedLimit; limit != nil && *limit < 0 {
+		return serializer.ParamErr("Download speed limit cannot be negative", nil)
+	}
+
 	if service.User.ID > 0 {
 
 		user, _ := model.GetUserByID(service.User.ID)
@@ -110,6 +131,7 @@ func (service *AddUserService) Add() serializer.Response {
 		user.GroupID = service.User.GroupID
 		user.Status = service.User.Status
 		user.TwoFactor = service.User.TwoFactor
+		user.OptionsSerialized.DownloadSpeedLimit = service.User.OptionsSerialized.DownloadSpeedLimit
 
 		// 检查愚蠢操作
 		if user.ID == 1 {
@@ -6,6 +6,7 @@ import (
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/dropbox"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/googledrive"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/onedrive"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
@@ -68,6 +69,44 @@ func (service *OauthService) GDriveAuth(c *gin.Context) serializer.Response {
 	return serializer.Response{}
 }
 
+// DropboxAuth Dropbox 更新认证信息
+func (service *OauthService) DropboxAuth(c *gin.Context) serializer.Response {
+	if service.Error != "" {
+		return serializer.ParamErr(service.ErrorMsg, nil)
+	}
+
+	policyID, ok := util.GetSession(c, "dropbox_oauth_policy").(uint)
+	if !ok {
+		return serializer.Err(serializer.CodeNotFound, "", nil)
+	}
+
+	util.DeleteSession(c, "dropbox_oauth_policy")
+
+	policy, err := model.GetPolicyByID(policyID)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotExist, "", nil)
+	}
+
+	client, err := dropbox.NewClient(&policy)
+	if err != nil {
+		return serializer.Err(serializer.CodeInternalSetting, "Failed to initialize Dropbox client", err)
+	}
+
+	credential, err := client.ObtainToken(c, service.Code, "")
+	if err != nil {
+		return serializer.Err(serializer.CodeInternalSetting, "Failed to fetch AccessToken", err)
+	}
+
+	// 更新存储策略的 RefreshToken
+	client.Policy.AccessKey = credential.RefreshToken
+	if err := client.Policy.SaveAndClearCache(); err != nil {
+		return serializer.DBErr("Failed to update RefreshToken", err)
+	}
+
+	cache.Deletes([]string{client.ClientID}, dropbox.TokenCachePrefix)
+	return serializer.Response{}
+}
+
 // OdAuth OneDrive 更新认证信息
 func (service *OauthService) OdAuth(c *gin.Context) serializer.Response {
 	if service.Error != "" {
@@ -124,6 +163,28 @@ func querySharePointSiteID(ctx context.Context, policy *model.Policy) error {
 	}
 
 	client.Policy.OptionsSerialized.OdDriver = fmt.Sprintf("sites/%s/drive", id)
+
+	// 使用指定的文档库
+	if name := client.Policy.OptionsSerialized.OdDriveName; name != "" {
+		drives, err := client.GetSiteDrives(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		found := false
+		for _, drive := range drives {
+			if drive.ID == name || drive.Name == name {
+				client.Policy.OptionsSerialized.OdDriver = fmt.Sprintf("sites/%s/drives/%s", id, drive.ID)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("document library %q not found in site", name)
+		}
+	}
+
 	if err := client.Policy.SaveAndClearCache(); err != nil {
 		return err
 	}
@@ -7,11 +7,16 @@ import (
 	"strings"
 
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/azure"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/cos"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/gcs"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/googledrive"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/onedrive"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/s3"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/swift"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/gin-gonic/gin"
 )
 
@@ -98,6 +103,55 @@ func (service COSCallback) GetBody() serializer.UploadCallback {
 	}
 }
 
+// AzureCallback Azure Blob 客户端回调正文
+type AzureCallback struct {
+}
+
+// GetBody 返回回调正文
+func (service AzureCallback) GetBody() serializer.UploadCallback {
+	return serializer.UploadCallback{
+		PicInfo: "",
+	}
+}
+
+// GCSCallback GCS 客户端回调正文
+type GCSCallback struct {
+}
+
+// GetBody 返回回调正文
+func (service GCSCallback) GetBody() serializer.UploadCallback {
+	return serializer.UploadCallback{
+		PicInfo: "",
+	}
+}
+
+// SwiftCallback Swift 客户端回调正文
+type SwiftCallback struct {
+}
+
+// GetBody 返回回调正文
+func (service SwiftCallback) GetBody() serializer.UploadCallback {
+	return serializer.UploadCallback{
+		PicInfo: "",
+	}
+}
+
+// GoogleDriveCallback Google Drive 客户端回调正文
+type GoogleDriveCallback struct {
+	Meta *googledrive.FileInfo
+}
+
+// GetBody 返回回调正文
+func (service GoogleDriveCallback) GetBody() serializer.UploadCallback {
+	var picInfo = "0,0"
+	if service.Meta.ImageMediaMetadata != nil && service.Meta.ImageMediaMetadata.Width != 0 {
+		picInfo = fmt.Sprintf("%d,%d", service.Meta.ImageMediaMetadata.Width, service.Meta.ImageMediaMetadata.Height)
+	}
+	return serializer.UploadCallback{
+		PicInfo: picInfo,
+	}
+}
+
 // GetBody 返回回调正文
 func (service S3Callback) GetBody() serializer.UploadCallback {
 	return serializer.UploadCallback{
@@ -126,23 +180,28 @@ func ProcessCallback(service CallbackProcessService, c *gin.Context) serializer.
 	}
 
 	fileData := fsctx.FileStream{
-		Size:         uploadSession.Size,
-		Name:         uploadSession.Name,
-		VirtualPath:  uploadSession.VirtualPath,
-		SavePath:     uploadSession.SavePath,
-		Mode:         fsctx.Nop,
-		Model:        file,
-		LastModified: uploadSession.LastModified,
+		Size:            uploadSession.Size,
+		Name:            uploadSession.Name,
+		VirtualPath:     uploadSession.VirtualPath,
+		SavePath:        uploadSession.SavePath,
+		Mode:            fsctx.Nop,
+		Model:           file,
+		LastModified:    uploadSession.LastModified,
+		UploadSessionID: &uploadSession.Key,
 	}
 
-	// 占位符未扣除容量需要校验和扣除
+	// 占位符未扣除容量需要校验和扣除，创建会话时已预留的容量足够时直接通过
 	if !fs.Policy.IsUploadPlaceholderWithSize() {
-		fs.Use("AfterUpload", filesystem.HookValidateCapacity)
+		fs.Use("AfterUpload", filesystem.HookReserveCapacity)
 		fs.Use("AfterUpload", filesystem.HookChunkUploaded)
 	}
 
-	fs.Use("AfterUpload", filesystem.HookPopPlaceholderToFile(callbackBody.PicInfo))
+	fs.Use("AfterUpload", filesystem.HookVerifyChecksum(uploadSession.MD5, uploadSession.Hash))
+	fs.UseAfterUploadHooks(uploadSession.CameraUpload,
+		filesystem.HookReplaceConflictedFile(uploadSession), filesystem.HookPopPlaceholderToFile(callbackBody.PicInfo))
+	fs.Use("AfterUpload", filesystem.HookReleaseCapacity)
 	fs.Use("AfterValidateFailed", filesystem.HookDeleteTempFile)
+	fs.Use("AfterValidateFailed", filesystem.HookReleaseCapacity)
 	err = fs.Upload(context.Background(), &fileData)
 	if err != nil {
 		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
@@ -175,7 +234,7 @@ func (service *OneDriveCallback) PreProcess(c *gin.Context) serializer.Response
 
 	// SharePoint 会对 Office 文档增加 meta data 导致文件大小不一致，这里增加 1 MB 宽容
 	// See: https://github.com/OneDrive/onedrive-api-docs/issues/935
-	if (strings.Contains(fs.Policy.OptionsSerialized.OdDriver, "sharepoint.com") || strings.Contains(fs.Policy.OptionsSerialized.OdDriver, "sharepoint.cn")) && isSizeCheckFailed && (info.Size > uploadSession.Size) && (info.Size-uploadSession.Size <= 1048576) {
+	if fs.Handler.(onedrive.Driver).Client.IsSharePoint() && isSizeCheckFailed && (info.Size > uploadSession.Size) && (info.Size-uploadSession.Size <= 1048576) {
 		isSizeCheckFailed = false
 	}
 
@@ -231,11 +290,140 @@ func (service *S3Callback) PreProcess(c *gin.Context) serializer.Response {
 		return serializer.Err(serializer.CodeMetaMismatch, "", err)
 	}
 
-	// 验证实际文件信息与回调会话中是否一致
+	// 验证实际文件信息与回调会话中是否一致，不一致时删除已上传的对象，避免超出容量的文件残留
+	if uploadSession.Size != info.Size {
+		if _, err := fs.Handler.Delete(context.Background(), []string{uploadSession.SavePath}); err != nil {
+			util.Log().Warning("Failed to delete mismatched object %q: %s", uploadSession.SavePath, err)
+		}
+		return serializer.Err(serializer.CodeMetaMismatch, "", nil)
+	}
+
+	return ProcessCallback(service, c)
+}
+
+// PreProcess 对Azure Blob客户端回调进行预处理
+func (service *AzureCallback) PreProcess(c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromCallback(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	// 获取回调会话
+	uploadSession := c.MustGet(filesystem.UploadSessionCtx).(*serializer.UploadSession)
+
+	// 获取文件信息
+	info, err := fs.Handler.(*azure.Driver).Meta(context.Background(), uploadSession.SavePath)
+	if err != nil {
+		return serializer.Err(serializer.CodeMetaMismatch, "", err)
+	}
+
+	// 验证实际文件信息与回调会话中是否一致，不一致时删除已上传的文件
+	if uploadSession.Size != info.Size {
+		if _, err := fs.Handler.Delete(context.Background(), []string{uploadSession.SavePath}); err != nil {
+			util.Log().Warning("Failed to delete mismatched blob %q: %s", uploadSession.SavePath, err)
+		}
+		return serializer.Err(serializer.CodeMetaMismatch, "", nil)
+	}
+
+	return ProcessCallback(service, c)
+}
+
+// PreProcess 对GCS客户端回调进行预处理
+func (service *GCSCallback) PreProcess(c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromCallback(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	// 获取回调会话
+	uploadSession := c.MustGet(filesystem.UploadSessionCtx).(*serializer.UploadSession)
+
+	// 获取文件信息
+	info, err := fs.Handler.(*gcs.Driver).Meta(context.Background(), uploadSession.SavePath)
+	if err != nil {
+		return serializer.Err(serializer.CodeMetaMismatch, "", err)
+	}
+
+	// 验证实际文件信息与回调会话中是否一致，不一致时删除已上传的对象
+	if uploadSession.Size != info.Size {
+		if _, err := fs.Handler.Delete(context.Background(), []string{uploadSession.SavePath}); err != nil {
+			util.Log().Warning("Failed to delete mismatched object %q: %s", uploadSession.SavePath, err)
+		}
+		return serializer.Err(serializer.CodeMetaMismatch, "", nil)
+	}
+
+	return ProcessCallback(service, c)
+}
+
+// PreProcess 对Swift客户端回调进行预处理，分段上传时创建大对象清单
+func (service *SwiftCallback) PreProcess(c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromCallback(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	// 获取回调会话
+	uploadSession := c.MustGet(filesystem.UploadSessionCtx).(*serializer.UploadSession)
+	handler := fs.Handler.(*swift.Driver)
+
+	// 合并已上传的分段
+	if uploadSession.UploadID != "" {
+		if err := handler.CommitSegments(context.Background(), uploadSession.SavePath, uploadSession.UploadID, uploadSession.Size, false); err != nil {
+			handler.CancelToken(context.Background(), uploadSession)
+			return serializer.Err(serializer.CodeMetaMismatch, "", err)
+		}
+	}
+
+	// 获取文件信息
+	info, err := handler.Meta(context.Background(), uploadSession.SavePath)
+	if err != nil {
+		return serializer.Err(serializer.CodeMetaMismatch, "", err)
+	}
+
+	// 验证实际文件信息与回调会话中是否一致，不一致时删除已上传的对象及分段
 	if uploadSession.Size != info.Size {
+		if _, err := fs.Handler.Delete(context.Background(), []string{uploadSession.SavePath}); err != nil {
+			util.Log().Warning("Failed to delete mismatched object %q: %s", uploadSession.SavePath, err)
+		}
+		return serializer.Err(serializer.CodeMetaMismatch, "", nil)
+	}
+
+	return ProcessCallback(service, c)
+}
+
+// PreProcess 对Google Drive客户端回调进行预处理验证
+func (service *GoogleDriveCallback) PreProcess(c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromCallback(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	// 获取回调会话
+	uploadSession := c.MustGet(filesystem.UploadSessionCtx).(*serializer.UploadSession)
+
+	// 获取文件信息
+	info, err := fs.Handler.(*googledrive.Driver).Client.Meta(context.Background(), uploadSession.SavePath)
+	if err != nil {
 		return serializer.Err(serializer.CodeMetaMismatch, "", err)
 	}
 
+	// 验证与回调会话中是否一致，不一致时删除已上传的文件
+	if uploadSession.Size != info.Size {
+		if _, err := fs.Handler.Delete(context.Background(), []string{uploadSession.SavePath}); err != nil {
+			util.Log().Warning("Failed to delete mismatched file %q: %s", uploadSession.SavePath, err)
+		}
+		return serializer.Err(serializer.CodeMetaMismatch, "", nil)
+	}
+
+	service.Meta = info
 	return ProcessCallback(service, c)
 }
 
@@ -0,0 +1,24 @@
+package explorer
+
+import (
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// ActivityListService 操作动态列表服务
+type ActivityListService struct {
+	Page     uint `form:"page" binding:"required,min=1"`
+	PageSize int  `form:"page_size" binding:"omitempty,min=1,max=200"`
+}
+
+// List 按时间倒序列出用户的操作动态
+func (service *ActivityListService) List(c *gin.Context, user *model.User) serializer.Response {
+	pageSize := service.PageSize
+	if pageSize == 0 {
+		pageSize = 50
+	}
+
+	activities, total := model.ListActivities(user.ID, int(service.Page), pageSize)
+	return serializer.BuildActivityList(activities, total)
+}
@@ -0,0 +1,222 @@
+package explorer
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/email"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxCommentMentions 单条评论最多通知的被提及用户数
+	maxCommentMentions = 10
+	// notificationExcerptLength 通知中评论摘要的最大长度
+	notificationExcerptLength = 100
+)
+
+// mentionPattern 匹配评论中以 @ 开头的用户昵称
+var mentionPattern = regexp.MustCompile(`@([^\s@]+)`)
+
+// CommentCreateService 发表评论服务
+type CommentCreateService struct {
+	Content string `json:"content" binding:"required,min=1,max=4096"`
+	ReplyTo string `json:"reply_to"`
+}
+
+// CommentListService 列出文件评论服务
+type CommentListService struct {
+}
+
+// CommentDeleteService 删除评论服务
+type CommentDeleteService struct {
+}
+
+// List 列出自己文件上的全部评论
+func (service *CommentListService) List(c *gin.Context, user *model.User) serializer.Response {
+	fileID, _ := c.Get("object_id")
+	files, err := model.GetFilesByIDs([]uint{fileID.(uint)}, user.ID)
+	if err != nil || len(files) == 0 {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	comments, err := model.GetCommentsByFileID(files[0].ID)
+	if err != nil {
+		return serializer.DBErr("Failed to list comments", err)
+	}
+
+	return serializer.BuildCommentList(comments)
+}
+
+// Create 在自己的文件上发表评论
+func (service *CommentCreateService) Create(c *gin.Context, user *model.User) serializer.Response {
+	fileID, _ := c.Get("object_id")
+	files, err := model.GetFilesByIDs([]uint{fileID.(uint)}, user.ID)
+	if err != nil || len(files) == 0 {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	return service.Post(&files[0], user, 0)
+}
+
+// Post 以 user 的身份在 file 上发表评论，shareID 不为 0 时评论对该分享的访客可见。
+// 回复与被回复的评论可见范围相同
+func (service *CommentCreateService) Post(file *model.File, user *model.User, shareID uint) serializer.Response {
+	comment := model.Comment{
+		FileID:  file.ID,
+		UserID:  user.ID,
+		ShareID: shareID,
+		Content: service.Content,
+	}
+
+	var parent *model.Comment
+	if service.ReplyTo != "" {
+		parentID, err := hashid.DecodeHashID(service.ReplyTo, hashid.CommentID)
+		if err != nil {
+			return serializer.Err(serializer.CodeNotFound, "Comment not exist", err)
+		}
+
+		parent, err = model.GetCommentByID(parentID)
+		if err != nil || parent.FileID != file.ID || (shareID != 0 && parent.ShareID != shareID) {
+			return serializer.Err(serializer.CodeNotFound, "Comment not exist", err)
+		}
+
+		// 回复统一归入顶层评论
+		comment.ParentID = parent.ID
+		if parent.ParentID != 0 {
+			comment.ParentID = parent.ParentID
+		}
+		comment.ShareID = parent.ShareID
+	}
+
+	if _, err := comment.Create(); err != nil {
+		return serializer.DBErr("Failed to create comment", err)
+	}
+
+	notifyComment(&comment, parent, file, user)
+
+	comment.User = *user
+	return serializer.Response{Data: serializer.BuildComment(&comment)}
+}
+
+// Delete 删除评论，评论者及文件所有者可删除
+func (service *CommentDeleteService) Delete(c *gin.Context, user *model.User) serializer.Response {
+	commentID, _ := c.Get("object_id")
+	comment, err := model.GetCommentByID(commentID.(uint))
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "Comment not exist", err)
+	}
+
+	if comment.UserID != user.ID {
+		if files, err := model.GetFilesByIDs([]uint{comment.FileID}, user.ID); err != nil || len(files) == 0 {
+			return serializer.Err(serializer.CodeNotFound, "Comment not exist", err)
+		}
+	}
+
+	if err := comment.Delete(); err != nil {
+		return serializer.DBErr("Failed to delete comment", err)
+	}
+
+	return serializer.Response{}
+}
+
+// notifyComment 为文件所有者、被回复者及被提及的用户生成站内通知，并向被提及的用户发送邮件。
+// 只有能看到此评论的用户（文件所有者及同一可见范围内的评论者）可被提及
+func notifyComment(comment *model.Comment, parent *model.Comment, file *model.File, actor *model.User) {
+	recipients := make(map[uint]string)
+	if file.UserID != actor.ID {
+		recipients[file.UserID] = model.NotificationComment
+	}
+	if parent != nil && parent.UserID != actor.ID {
+		recipients[parent.UserID] = model.NotificationReply
+	}
+
+	// 解析被提及的用户
+	var mentioned []model.User
+	if nicks := parseMentions(comment.Content); len(nicks) > 0 {
+		participants, err := model.GetCommentParticipants(file.ID, comment.ShareID)
+		if err != nil {
+			util.Log().Warning("Failed to list comment participants: %s", err)
+		}
+
+		if owner, err := model.GetUserByID(file.UserID); err == nil {
+			participants = append(participants, owner)
+		}
+
+		seen := make(map[uint]bool)
+		for _, participant := range participants {
+			if participant.ID == actor.ID || seen[participant.ID] || !nicks[participant.Nick] {
+				continue
+			}
+
+			seen[participant.ID] = true
+			recipients[participant.ID] = model.NotificationMention
+			mentioned = append(mentioned, participant)
+			if len(mentioned) >= maxCommentMentions {
+				break
+			}
+		}
+	}
+
+	if len(recipients) == 0 {
+		return
+	}
+
+	excerpt := []rune(comment.Content)
+	if len(excerpt) > notificationExcerptLength {
+		excerpt = excerpt[:notificationExcerptLength]
+	}
+
+	notifications := make([]model.Notification, 0, len(recipients))
+	for uid, notificationType := range recipients {
+		notifications = append(notifications, model.Notification{
+			UserID:    uid,
+			Type:      notificationType,
+			ActorID:   actor.ID,
+			FileID:    file.ID,
+			FileName:  file.Name,
+			ShareID:   comment.ShareID,
+			CommentID: comment.ID,
+			Content:   string(excerpt),
+		})
+	}
+
+	if err := model.CreateNotifications(notifications); err != nil {
+		util.Log().Warning("Failed to create comment notifications: %s", err)
+	}
+
+	// 邮件通知被提及的用户
+	commentURL := commentLink(comment)
+	for _, user := range mentioned {
+		title, body := email.NewMentionEmail(user.Nick, actor.Nick, file.Name, comment.Content, commentURL)
+		if err := email.Send(user.Email, title, body); err != nil {
+			util.Log().Warning("Failed to send mention email to %q: %s", user.Email, err)
+		}
+	}
+}
+
+// parseMentions 返回评论中被提及的昵称
+func parseMentions(content string) map[string]bool {
+	res := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		res[strings.TrimRight(match[1], ",.;:!?，。；：！？")] = true
+	}
+	return res
+}
+
+// commentLink 返回评论所在页面的地址，经由分享发表的评论指向分享页
+func commentLink(comment *model.Comment) string {
+	target := "/home"
+	if comment.ShareID != 0 {
+		target = fmt.Sprintf("/s/%s", hashid.HashID(comment.ShareID, hashid.ShareID))
+	}
+
+	controller, _ := url.Parse(target)
+	return model.GetSiteURL().ResolveReference(controller).String()
+}
@@ -0,0 +1,53 @@
+package explorer
+
+import (
+	"context"
+	"strconv"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultDeltaLimit 单次增量同步默认返回的变更记录数
+const defaultDeltaLimit = 500
+
+// DeltaService 增量同步服务
+type DeltaService struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"min=0,max=1000"`
+}
+
+// Delta 列出游标之后的变更。未指定游标时只返回当前最新的游标，客户端应在完整同步前获取
+func (service *DeltaService) Delta(c *gin.Context, user *model.User) serializer.Response {
+	if service.Cursor == "" {
+		return serializer.Response{Data: &filesystem.Delta{
+			Entries: []filesystem.DeltaEntry{},
+			Cursor:  filesystem.LatestChangeCursor(),
+		}}
+	}
+
+	cursor, err := strconv.ParseUint(service.Cursor, 10, 64)
+	if err != nil {
+		return serializer.ParamErr("Invalid cursor", err)
+	}
+
+	limit := service.Limit
+	if limit == 0 {
+		limit = defaultDeltaLimit
+	}
+
+	fs, err := filesystem.NewFileSystem(user)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	delta, err := fs.Delta(context.Background(), uint(cursor), limit)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{Data: delta}
+}
@@ -3,14 +3,39 @@ package explorer
 import (
 	"context"
 
+	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/gin-gonic/gin"
 )
 
 // DirectoryService 创建新目录服务
 type DirectoryService struct {
 	Path string `uri:"path" json:"path" binding:"required,min=1,max=65535"`
+
+	// 列出目录时的排序、筛选与分页参数
+	Cursor         string `form:"cursor" json:"-"`
+	PageSize       int    `form:"page_size" json:"-" binding:"min=0,max=1000"`
+	OrderBy        string `form:"order_by" json:"-" binding:"omitempty,eq=name|eq=size|eq=updated_at|eq=type"`
+	OrderDirection string `form:"order_direction" json:"-" binding:"omitempty,eq=asc|eq=desc"`
+	Keyword        string `form:"keyword" json:"-" binding:"max=255"`
+	Type           string `form:"type" json:"-" binding:"omitempty,eq=file|eq=dir"`
+}
+
+// DirectoryPolicyService 设定目录存储策略服务
+type DirectoryPolicyService struct {
+	ID       string `json:"id" binding:"required"`
+	PolicyID string `json:"policy_id"`
+}
+
+// DirectoryDisplayService 设定目录显示属性服务
+type DirectoryDisplayService struct {
+	ID          string `json:"id" binding:"required"`
+	Color       string `json:"color" binding:"omitempty,hexcolor"`
+	Icon        string `json:"icon" binding:"max=64"`
+	Description string `json:"description" binding:"max=1024"`
 }
 
 // ListDirectory 列出目录内容
@@ -27,7 +52,14 @@ func (service *DirectoryService) ListDirectory(c *gin.Context) serializer.Respon
 	defer cancel()
 
 	// 获取子项目
-	objects, err := fs.List(ctx, service.Path, nil)
+	objects, next, err := fs.ListPage(ctx, service.Path, &filesystem.ListOption{
+		OrderBy:  service.OrderBy,
+		Desc:     service.OrderDirection == "desc",
+		Keyword:  service.Keyword,
+		Type:     service.Type,
+		PageSize: service.PageSize,
+		Cursor:   service.Cursor,
+	})
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
@@ -37,9 +69,16 @@ func (service *DirectoryService) ListDirectory(c *gin.Context) serializer.Respon
 		parentID = fs.DirTarget[0].ID
 	}
 
+	// 返回此目录下上传时使用的存储策略
+	if err := fs.SwitchPolicyByPath(service.Path); err != nil {
+		return serializer.Err(serializer.CodePolicyNotExist, "", err)
+	}
+
+	res := serializer.BuildObjectList(parentID, objects, fs.Policy)
+	res.Next = next
 	return serializer.Response{
 		Code: 0,
-		Data: serializer.BuildObjectList(parentID, objects, fs.Policy),
+		Data: res,
 	}
 }
 
@@ -66,3 +105,57 @@ func (service *DirectoryService) CreateDirectory(c *gin.Context) serializer.Resp
 	}
 
 }
+
+// SetPolicy 为目录绑定存储策略，策略 ID 为空时解除绑定
+func (service *DirectoryPolicyService) SetPolicy(c *gin.Context, user *model.User) serializer.Response {
+	folderID, err := hashid.DecodeHashID(service.ID, hashid.FolderID)
+	if err != nil {
+		return serializer.Err(serializer.CodeParentNotExist, "", err)
+	}
+
+	folders, err := model.GetFoldersByIDs([]uint{folderID}, user.ID)
+	if err != nil || len(folders) == 0 {
+		return serializer.Err(serializer.CodeParentNotExist, "", err)
+	}
+
+	var policyID uint
+	if service.PolicyID != "" {
+		policyID, err = hashid.DecodeHashID(service.PolicyID, hashid.PolicyID)
+		if err != nil {
+			return serializer.Err(serializer.CodePolicyNotExist, "", err)
+		}
+
+		if !util.ContainsUint(user.Group.PolicyList, policyID) {
+			return serializer.Err(serializer.CodePolicyNotAllowed, "", nil)
+		}
+
+		if _, err := model.GetPolicyByID(policyID); err != nil {
+			return serializer.Err(serializer.CodePolicyNotExist, "", err)
+		}
+	}
+
+	if err := folders[0].SetPolicy(policyID); err != nil {
+		return serializer.DBErr("Failed to update folder policy", err)
+	}
+
+	return serializer.Response{}
+}
+
+// SetDisplay 设定目录的显示颜色、图标及描述，属性为空时清除
+func (service *DirectoryDisplayService) SetDisplay(c *gin.Context, user *model.User) serializer.Response {
+	folderID, err := hashid.DecodeHashID(service.ID, hashid.FolderID)
+	if err != nil {
+		return serializer.Err(serializer.CodeParentNotExist, "", err)
+	}
+
+	folders, err := model.GetFoldersByIDs([]uint{folderID}, user.ID)
+	if err != nil || len(folders) == 0 {
+		return serializer.Err(serializer.CodeParentNotExist, "", err)
+	}
+
+	if err := folders[0].SetDisplay(service.Color, service.Icon, service.Description); err != nil {
+		return serializer.DBErr("Failed to update folder display attributes", err)
+	}
+
+	return serializer.Response{}
+}
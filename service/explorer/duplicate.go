@@ -0,0 +1,80 @@
+package explorer
+
+import (
+	"context"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/task"
+	"github.com/gin-gonic/gin"
+)
+
+// DuplicateService 重复文件服务，Hashes 为要合并的文件组的摘要，为空时合并全部
+type DuplicateService struct {
+	Hashes []string `json:"hashes"`
+}
+
+// List 列出内容相同的文件组及合并后可释放的存储空间
+func (service *DuplicateService) List(ctx context.Context, c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	groups, err := fs.FindDuplicates(ctx)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	var reclaimable uint64
+	for _, group := range groups {
+		reclaimable += group.Reclaimable
+	}
+
+	// 未计算摘要的文件无法参与比较，需先扫描
+	pending, err := model.CountFilesWithoutDigest(fs.User.ID)
+	if err != nil {
+		return serializer.DBErr("Failed to count files without digest", err)
+	}
+
+	return serializer.Response{
+		Data: map[string]interface{}{
+			"groups":      groups,
+			"reclaimable": reclaimable,
+			"pending":     pending,
+		},
+	}
+}
+
+// Deduplicate 将内容相同的文件合并为同一物理文件
+func (service *DuplicateService) Deduplicate(ctx context.Context, c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	reclaimed, err := fs.Deduplicate(ctx, service.Hashes)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Data: map[string]interface{}{
+			"reclaimed": reclaimed,
+		},
+	}
+}
+
+// Scan 创建摘要计算任务，为旧文件补充内容摘要
+func (service *DuplicateService) Scan(c *gin.Context, user *model.User) serializer.Response {
+	job, err := task.NewDigestTask(user)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateTaskError, "", err)
+	}
+	task.TaskPoll.Submit(job)
+
+	return serializer.Response{Data: job.Model().ID}
+}
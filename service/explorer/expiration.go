@@ -0,0 +1,104 @@
+package explorer
+
+import (
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// ExpirationListService 到期规则列表服务
+type ExpirationListService struct {
+}
+
+// ExpirationSetService 设定到期规则服务
+type ExpirationSetService struct {
+	Src       ItemIDService `json:"src"`
+	ExpiresAt int64         `json:"expires_at" binding:"required,min=1"`
+	Action    string        `json:"action" binding:"required,eq=delete|eq=trash"`
+}
+
+// List 列出用户设定的全部到期规则
+func (service *ExpirationListService) List(c *gin.Context, user *model.User) serializer.Response {
+	expirations, err := model.GetExpirationsByUser(user.ID)
+	if err != nil {
+		return serializer.DBErr("Failed to list expirations", err)
+	}
+
+	var dirs, files []uint
+	for _, expiration := range expirations {
+		if expiration.IsDir {
+			dirs = append(dirs, expiration.ObjectID)
+		} else {
+			files = append(files, expiration.ObjectID)
+		}
+	}
+
+	var (
+		folderModels []model.Folder
+		fileModels   []model.File
+	)
+	if len(dirs) > 0 {
+		if folderModels, err = model.GetFoldersByIDs(dirs, user.ID); err != nil {
+			return serializer.DBErr("Failed to list folders", err)
+		}
+	}
+
+	if len(files) > 0 {
+		if fileModels, err = model.GetFilesByIDs(files, user.ID); err != nil {
+			return serializer.DBErr("Failed to list files", err)
+		}
+	}
+
+	return serializer.BuildExpirationList(expirations, folderModels, fileModels)
+}
+
+// Set 为文件和目录设定到期规则，到期后删除或移入回收站
+func (service *ExpirationSetService) Set(c *gin.Context, user *model.User) serializer.Response {
+	expiresAt := time.Unix(service.ExpiresAt, 0)
+	if !expiresAt.After(time.Now()) {
+		return serializer.ParamErr("Expiration time must be in the future", nil)
+	}
+
+	items := service.Src.Raw()
+	if err := checkObjectsOwned(user, items); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	if err := model.SetExpirations(user.ID, items.Dirs, items.Items, expiresAt, service.Action); err != nil {
+		return serializer.DBErr("Failed to set expirations", err)
+	}
+
+	return serializer.Response{}
+}
+
+// ClearExpiration 移除文件和目录上的到期规则
+func (service *ItemIDService) ClearExpiration(c *gin.Context, user *model.User) serializer.Response {
+	items := service.Raw()
+	if err := model.RemoveExpirations(user.ID, items.Dirs, items.Items); err != nil {
+		return serializer.DBErr("Failed to remove expirations", err)
+	}
+
+	return serializer.Response{}
+}
+
+// checkObjectsOwned 检查目录和文件均存在且属于用户
+func checkObjectsOwned(user *model.User, items *ItemService) error {
+	if len(items.Dirs) > 0 {
+		folders, err := model.GetFoldersByIDs(items.Dirs, user.ID)
+		if err != nil || len(folders) != len(items.Dirs) {
+			return filesystem.ErrObjectNotExist.WithError(err)
+		}
+	}
+
+	if len(items.Items) > 0 {
+		files, err := model.GetFilesByIDs(items.Items, user.ID)
+		if err != nil || len(files) != len(items.Items) {
+			return filesystem.ErrObjectNotExist.WithError(err)
+		}
+	}
+
+	return nil
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/task"
 	"github.com/cloudreve/Cloudreve/v3/pkg/wopi"
 	"github.com/gin-gonic/gin"
 )
@@ -31,6 +32,11 @@ type SingleFileService struct {
 type FileIDService struct {
 }
 
+// TextPreviewService 文本文件开头部分预览服务
+type TextPreviewService struct {
+	Size int `form:"size" binding:"min=0"`
+}
+
 // FileAnonymousGetService 匿名（外链）获取文件服务
 type FileAnonymousGetService struct {
 	ID   uint   `uri:"id" binding:"required,min=1"`
@@ -42,9 +48,22 @@ type DownloadService struct {
 	ID string `uri:"id" binding:"required"`
 }
 
+// TempSourceService 临时外链创建服务
+type TempSourceService struct {
+	TTL       int `json:"ttl" binding:"required,min=1,max=2592000"`
+	Downloads int `json:"downloads" binding:"min=0"`
+}
+
+// HLSService 视频转码结果播放服务
+type HLSService struct {
+	Path string `uri:"path" binding:"required"`
+}
+
 // ArchiveService 文件流式打包下載服务
 type ArchiveService struct {
 	ID string `uri:"sessionID" binding:"required"`
+	// 归档格式，zip、tar 或 tar.gz，为空时使用 zip
+	Format string `uri:"-" form:"format" binding:"omitempty,eq=zip|eq=tar|eq=tar.gz"`
 }
 
 // New 创建新文件
@@ -119,13 +138,31 @@ func (service *ArchiveService) DownloadArchived(ctx context.Context, c *gin.Cont
 		return serializer.Err(serializer.CodeNotFound, "Archive session not exist", nil)
 	}
 
-	// 开始打包
-	c.Header("Content-Disposition", "attachment;")
-	c.Header("Content-Type", "application/zip")
+	if service.Format == "" {
+		service.Format = filesystem.ArchiveFormatZip
+	}
+
+	// 开始打包，压缩包以分块传输编码边打包边发送，
+	// 并通知反向代理不要缓冲响应，避免在代理服务器上缓存整个压缩包
+	switch service.Format {
+	case filesystem.ArchiveFormatZip:
+		c.Header("Content-Disposition", "attachment;")
+		c.Header("Content-Type", "application/zip")
+	case filesystem.ArchiveFormatTar:
+		c.Header("Content-Disposition", "attachment; filename=\"archive.tar\"")
+		c.Header("Content-Type", "application/x-tar")
+	case filesystem.ArchiveFormatTarGz:
+		c.Header("Content-Disposition", "attachment; filename=\"archive.tar.gz\"")
+		c.Header("Content-Type", "application/gzip")
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
 	itemService := archiveSession.(ItemIDService)
 	items := itemService.Raw()
 	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
-	err = fs.Compress(ctx, c.Writer, items.Dirs, items.Items, true)
+	fs.WithDownloadSpeedLimit(c)
+	err = fs.CompressAs(ctx, c.Writer, service.Format, items.Dirs, items.Items)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, "Failed to compress file", err)
 	}
@@ -149,6 +186,11 @@ func (service *FileAnonymousGetService) Download(ctx context.Context, c *gin.Con
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
 
+	// 直链跳转后的签名地址同样受防盗链限制
+	if !fs.FileTarget[0].GetPolicy().IsRefererAllowed(c.Request.Referer()) {
+		return serializer.Err(serializer.CodeHotlinkBlocked, "Hotlinking is not allowed", nil)
+	}
+
 	// 获取文件流
 	rs, err := fs.GetDownloadContent(ctx, 0)
 	defer rs.Close()
@@ -157,6 +199,7 @@ func (service *FileAnonymousGetService) Download(ctx context.Context, c *gin.Con
 	}
 
 	// 发送文件
+	fs.WithDownloadSpeedLimit(c)
 	http.ServeContent(c.Writer, c.Request, service.Name, fs.FileTarget[0].UpdatedAt, rs)
 
 	return serializer.Response{
@@ -241,8 +284,7 @@ func (service *FileIDService) CreateDocPreviewSession(ctx context.Context, c *gi
 
 	// Use WOPI preview if available
 	if model.IsTrueVal(model.GetSettingByName("wopi_enabled")) && wopi.Default != nil {
-		maxSize := model.GetIntSetting("maxEditSize", 0)
-		if maxSize > 0 && fs.FileTarget[0].Size > uint64(maxSize) {
+		if WopiSizeExceeded(fs.FileTarget[0].Size) {
 			return serializer.Err(serializer.CodeFileTooLarge, "", nil)
 		}
 
@@ -305,6 +347,174 @@ func (service *FileIDService) CreateDownloadSession(ctx context.Context, c *gin.
 	}
 }
 
+// Create 创建有效期和下载次数受限的临时外链
+func (service *TempSourceService) Create(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	// 获取对象id
+	objectID, _ := c.Get("object_id")
+
+	// 检查文件及其存储策略是否允许获取外链
+	if _, err := fs.GetSource(ctx, objectID.(uint)); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	link, err := model.CreateTempSourceLink(fs.FileTarget[0].ID, service.TTL, service.Downloads)
+	if err != nil {
+		return serializer.Err(serializer.CodeCacheOperation, "Failed to create temporary source link", err)
+	}
+
+	linkURL, err := link.Link(fs.FileTarget[0].Name)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: linkURL,
+	}
+}
+
+// Restore 为归档存储中的文件发起解冻，返回文件当前是否已可访问，
+// 尚不可访问时创建任务等待解冻完成
+func (service *FileIDService) Restore(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	// 获取对象id
+	objectID, _ := c.Get("object_id")
+	files, err := model.GetFilesByIDs([]uint{objectID.(uint)}, fs.User.ID)
+	if err != nil || len(files) == 0 {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	restored, err := fs.RestoreFile(ctx, &files[0])
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "Failed to restore file", err)
+	}
+
+	if !restored {
+		job, err := task.NewRestoreTask(fs.User, files[0].ID)
+		if err != nil {
+			return serializer.Err(serializer.CodeCreateTaskError, "", err)
+		}
+		task.TaskPoll.Submit(job)
+	}
+
+	return serializer.Response{Data: restored}
+}
+
+// Transcode 创建视频转码任务，生成用于在线播放的 HLS 文件
+func (service *FileIDService) Transcode(ctx context.Context, c *gin.Context) serializer.Response {
+	if !model.IsTrueVal(model.GetSettingByName("hls_enabled")) {
+		return serializer.Err(serializer.CodeFeatureNotEnabled, "", nil)
+	}
+
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	// 获取对象id
+	objectID, _ := c.Get("object_id")
+	files, err := model.GetFilesByIDs([]uint{objectID.(uint)}, fs.User.ID)
+	if err != nil || len(files) == 0 {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	if !util.IsInExtensionList(strings.Split(model.GetSettingByName("hls_exts"), ","), files[0].Name) {
+		return serializer.ParamErr("Unsupported video format", nil)
+	}
+
+	job, err := task.NewTranscodeTask(fs.User, files[0].ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateTaskError, "", err)
+	}
+	task.TaskPoll.Submit(job)
+
+	return serializer.Response{}
+}
+
+// ServeTranscoded 输出转换为浏览器可播放格式的媒体文件，首次访问时进行转换
+func (service *FileIDService) ServeTranscoded(ctx context.Context, c *gin.Context) serializer.Response {
+	if !model.IsTrueVal(model.GetSettingByName("media_transcode_enabled")) {
+		return serializer.Err(serializer.CodeFeatureNotEnabled, "", nil)
+	}
+
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	// 获取对象id
+	objectID, _ := c.Get("object_id")
+	rs, err := fs.GetTranscodedContent(ctx, objectID.(uint))
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "Failed to transcode media", err)
+	}
+	defer rs.Close()
+
+	name := strings.TrimSuffix(fs.FileTarget[0].Name, path.Ext(fs.FileTarget[0].Name))
+	switch filesystem.InlineTranscodeFormat(fs.FileTarget[0].Name) {
+	case filesystem.TranscodeFormatVideo:
+		c.Header("Content-Type", "video/mp4")
+		name += ".mp4"
+	case filesystem.TranscodeFormatAudio:
+		c.Header("Content-Type", "audio/mp4")
+		name += ".m4a"
+	}
+
+	fs.WithDownloadSpeedLimit(c)
+	http.ServeContent(c.Writer, c.Request, name, fs.FileTarget[0].UpdatedAt, rs)
+
+	return serializer.Response{}
+}
+
+// Serve 输出视频转码生成的 HLS 播放列表或分片
+func (service *HLSService) Serve(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	// 获取对象id
+	objectID, _ := c.Get("object_id")
+	name := strings.TrimPrefix(service.Path, "/")
+	rs, err := fs.GetHLSContent(ctx, objectID.(uint), name)
+	if err != nil {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+	defer rs.Close()
+
+	switch path.Ext(name) {
+	case ".m3u8":
+		c.Header("Content-Type", "application/vnd.apple.mpegurl")
+		c.Header("Cache-Control", "no-cache")
+	case ".ts":
+		c.Header("Content-Type", "video/mp2t")
+	}
+
+	fs.WithDownloadSpeedLimit(c)
+	http.ServeContent(c.Writer, c.Request, path.Base(name), fs.FileTarget[0].UpdatedAt, rs)
+
+	return serializer.Response{}
+}
+
 // Download 通过签名URL的文件下载，无需登录
 func (service *DownloadService) Download(ctx context.Context, c *gin.Context) serializer.Response {
 	// 创建文件系统
@@ -338,6 +548,7 @@ func (service *DownloadService) Download(ctx context.Context, c *gin.Context) se
 	}
 
 	// 发送文件
+	fs.WithDownloadSpeedLimit(c)
 	http.ServeContent(c.Writer, c.Request, fs.FileTarget[0].Name, fs.FileTarget[0].UpdatedAt, rs)
 
 	return serializer.Response{
@@ -345,6 +556,64 @@ func (service *DownloadService) Download(ctx context.Context, c *gin.Context) se
 	}
 }
 
+// Preview 读取文本文件开头最多 Size 字节并转换为 UTF-8 返回
+func (service *TextPreviewService) Preview(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	objectID, _ := c.Get("object_id")
+	res, err := fs.PreviewTextHead(ctx, objectID.(uint), service.Size)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	return serializer.Response{Data: res}
+}
+
+// AudioTags 获取音频文件的标签信息
+func (service *FileIDService) AudioTags(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	objectID, _ := c.Get("object_id")
+	tags, err := fs.GetAudioTags(ctx, objectID.(uint))
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{Data: tags}
+}
+
+// AudioCover 获取音频文件内嵌的封面图片
+func (service *FileIDService) AudioCover(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	objectID, _ := c.Get("object_id")
+	cover, err := fs.GetAudioCover(ctx, objectID.(uint))
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, err.Error(), err)
+	}
+
+	c.Header("Cache-Control", "private, max-age=3600")
+	fs.WithDownloadTraffic(c)
+	c.Data(http.StatusOK, cover.MimeType, cover.Data)
+	return serializer.Response{}
+}
+
 // PreviewContent 预览文件，需要登录会话, isText - 是否为文本文件，文本文件会
 // 强制经由服务端中转
 func (service *FileIDService) PreviewContent(ctx context.Context, c *gin.Context, isText bool) serializer.Response {
@@ -395,8 +664,10 @@ func (service *FileIDService) PreviewContent(ctx context.Context, c *gin.Context
 
 	if isText {
 		c.Header("Cache-Control", "no-cache")
+		c.Header(filesystem.RevisionHeader, filesystem.FileRevision(&fs.FileTarget[0]))
 	}
 
+	fs.WithDownloadSpeedLimit(c)
 	http.ServeContent(c.Writer, c.Request, fs.FileTarget[0].Name, fs.FileTarget[0].UpdatedAt, resp.Content)
 
 	return serializer.Response{
@@ -406,6 +677,36 @@ func (service *FileIDService) PreviewContent(ctx context.Context, c *gin.Context
 
 // PutContent 更新文件内容
 func (service *FileIDService) PutContent(ctx context.Context, c *gin.Context) serializer.Response {
+	return service.putContent(ctx, c, "")
+}
+
+// SaveContent 编辑器保存文件内容，请求头须携带编辑前读取到的修订号或内容哈希，
+// 文件已被其他客户端修改时拒绝保存。成功时返回新的修订号
+func (service *FileIDService) SaveContent(ctx context.Context, c *gin.Context) serializer.Response {
+	expected := c.GetHeader(filesystem.RevisionHeader)
+	if expected == "" {
+		return serializer.ParamErr("Expected revision is required", nil)
+	}
+
+	fileID, _ := c.Get("object_id")
+	defer filesystem.LockRevision(fileID.(uint))()
+
+	res := service.putContent(ctx, c, expected)
+	if res.Code != 0 {
+		return res
+	}
+
+	userCtx, _ := c.Get("user")
+	files, err := model.GetFilesByIDs([]uint{fileID.(uint)}, userCtx.(*model.User).ID)
+	if err != nil || len(files) == 0 {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	return serializer.Response{Data: filesystem.FileRevision(&files[0])}
+}
+
+// putContent 更新文件内容，expected 不为空时须与文件当前的修订号相符
+func (service *FileIDService) putContent(ctx context.Context, c *gin.Context, expected string) serializer.Response {
 	// 创建上下文
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -439,10 +740,20 @@ func (service *FileIDService) PutContent(ctx context.Context, c *gin.Context) se
 	}
 	fileData.Name = originFile[0].Name
 
+	// 校验修订号，避免覆盖其他客户端已保存的内容
+	if expected != "" && !filesystem.MatchRevision(&originFile[0], expected) {
+		res := serializer.Err(serializer.CodeStaleRevision, "File has been modified by another client", nil)
+		res.Data = filesystem.FileRevision(&originFile[0])
+		return res
+	}
+
 	// 检查此文件是否有软链接
 	fileList, err := model.RemoveFilesWithSoftLinks([]model.File{originFile[0]})
+	keepVersion, keepSharedVersion := false, false
+	sharedSource := originFile[0].SourceName
 	if err == nil && len(fileList) == 0 {
 		// 如果包含软连接，应重新生成新文件副本，并更新source_name
+		keepSharedVersion = filesystem.ShouldKeepVersion(&originFile[0])
 		originFile[0].SourceName = fs.GenerateSavePath(uploadCtx, &fileData)
 		fileData.Mode &= ^fsctx.Overwrite
 		fs.Use("AfterUpload", filesystem.HookUpdateSourceName)
@@ -452,15 +763,44 @@ func (service *FileIDService) PutContent(ctx context.Context, c *gin.Context) se
 		fs.Use("AfterValidateFailed", filesystem.HookUpdateSourceName)
 		fs.Use("AfterValidateFailed", filesystem.HookCleanFileContent)
 		fs.Use("AfterValidateFailed", filesystem.HookClearFileSize)
+	} else {
+		// 原内容未被其他文件引用时可保留为历史版本
+		keepVersion = filesystem.ShouldKeepVersion(&originFile[0])
 	}
 
 	// 给文件系统分配钩子
+	fs.Use("BeforeUpload", filesystem.HookValidateRetention)
+	fs.Use("BeforeUpload", filesystem.HookValidateFileLock)
 	fs.Use("BeforeUpload", filesystem.HookResetPolicy)
+	if keepVersion {
+		// 新内容写入新的存储路径，上传失败时原文件保持不变
+		fs.Use("BeforeUpload", filesystem.HookGenerateVersionPath)
+		fs.Use("AfterUploadCanceled", filesystem.HookDeleteVersionTempFile)
+		fs.Use("AfterUploadFailed", filesystem.HookDeleteVersionTempFile)
+		fs.Use("AfterValidateFailed", filesystem.HookDeleteVersionTempFile)
+	}
+	if keepSharedVersion {
+		// 历史版本继续占用容量，新内容需完整计入
+		fs.Use("BeforeUpload", filesystem.HookValidateCapacity)
+	}
 	fs.Use("BeforeUpload", filesystem.HookValidateFile)
 	fs.Use("BeforeUpload", filesystem.HookValidateCapacityDiff)
-	fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
+	fs.Use("BeforeUpload", filesystem.HookUploadWebhook(filesystem.WebhookBeforeUpload))
+	fs.UseAfterUploadHooks(false, filesystem.GenericAfterUpdate)
+	if keepVersion {
+		fs.Use("AfterUpload", filesystem.HookSaveVersion)
+	}
+	if keepSharedVersion {
+		// 原内容仍被其他文件引用，作为历史版本保留其存储路径
+		fs.Use("AfterUpload", filesystem.HookSaveSharedVersion(sharedSource))
+	}
 
-	// 执行上传
+	// 执行上传，WOPI 客户端通过 X-WOPI-Lock 携带锁令牌
+	lockToken := c.GetHeader(filesystem.LockTokenHeader)
+	if token := c.GetHeader(wopi.LockHeader); token != "" {
+		lockToken = token
+	}
+	uploadCtx = context.WithValue(uploadCtx, fsctx.LockTokenCtx, lockToken)
 	uploadCtx = context.WithValue(uploadCtx, fsctx.FileModelCtx, originFile[0])
 	err = fs.Upload(uploadCtx, &fileData)
 	if err != nil {
@@ -0,0 +1,27 @@
+package explorer
+
+import (
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/task"
+	"github.com/gin-gonic/gin"
+)
+
+// IntegrityService 文件完整性校验服务
+type IntegrityService struct {
+	Repair bool `json:"repair"`
+}
+
+// Scan 创建完整性校验任务，校验用户的全部文件
+func (service *IntegrityService) Scan(c *gin.Context, user *model.User) serializer.Response {
+	job, err := task.NewIntegrityTask(user, task.IntegrityProps{
+		UserID: user.ID,
+		Repair: service.Repair,
+	})
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateTaskError, "", err)
+	}
+	task.TaskPoll.Submit(job)
+
+	return serializer.Response{Data: job.Model().ID}
+}
@@ -0,0 +1,92 @@
+package explorer
+
+import (
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// FileLockService 文件加锁服务，Token 为已持有的锁令牌，用于刷新有效期
+type FileLockService struct {
+	TTL   int    `json:"ttl" binding:"min=0"`
+	Token string `json:"token"`
+	Steal bool   `json:"steal"`
+}
+
+// FileUnlockService 文件解锁服务，Force 为 true 时强制解除其他客户端持有的锁
+type FileUnlockService struct {
+	Token string `form:"token"`
+	Force bool   `form:"force"`
+}
+
+// lockTargetFile 获取当前用户要操作的文件
+func lockTargetFile(c *gin.Context, user *model.User) (*model.File, error) {
+	objectID, _ := c.Get("object_id")
+	files, err := model.GetFilesByIDs([]uint{objectID.(uint)}, user.ID)
+	if err != nil || len(files) == 0 {
+		return nil, filesystem.ErrObjectNotExist.WithError(err)
+	}
+
+	return &files[0], nil
+}
+
+// GetLock 获取文件当前的锁，未被锁定时返回空
+func (service *FileUnlockService) GetLock(c *gin.Context, user *model.User) serializer.Response {
+	file, err := lockTargetFile(c, user)
+	if err != nil {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	lock, ok := filesystem.GetFileLock(file.ID)
+	if !ok {
+		return serializer.Response{}
+	}
+
+	// 令牌仅返回给加锁的客户端
+	lock.Token = ""
+	return serializer.Response{Data: lock}
+}
+
+// Lock 为文件加锁或刷新已持有的锁
+func (service *FileLockService) Lock(c *gin.Context, user *model.User) serializer.Response {
+	file, err := lockTargetFile(c, user)
+	if err != nil {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	lock, err := filesystem.LockFile(file.ID, filesystem.FileLock{
+		Token:   service.Token,
+		OwnerID: user.ID,
+		App:     filesystem.LockAppAPI,
+	}, service.TTL, service.Steal)
+	if err == filesystem.ErrFileLocked {
+		lock.Token = ""
+		res := serializer.Err(serializer.CodeNotSet, "", err)
+		res.Data = lock
+		return res
+	}
+
+	if err != nil {
+		return serializer.Err(serializer.CodeCacheOperation, "Failed to lock file", err)
+	}
+
+	return serializer.Response{Data: lock}
+}
+
+// Unlock 释放或强制解除文件锁
+func (service *FileUnlockService) Unlock(c *gin.Context, user *model.User) serializer.Response {
+	file, err := lockTargetFile(c, user)
+	if err != nil {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	if err := filesystem.UnlockFile(file.ID, service.Token, service.Force); err != nil {
+		if err == filesystem.ErrLockMismatch {
+			return serializer.Err(serializer.CodeFileLocked, err.Error(), err)
+		}
+		return serializer.Err(serializer.CodeCacheOperation, "Failed to unlock file", err)
+	}
+
+	return serializer.Response{}
+}
@@ -0,0 +1,76 @@
+package explorer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/task"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// ManifestService 文件清单下载服务
+type ManifestService struct {
+	Format string `form:"format" binding:"required,eq=csv|eq=json"`
+}
+
+// ManifestTaskService 文件清单导出任务服务
+type ManifestTaskService struct {
+	Format string `json:"format" binding:"required,eq=csv|eq=json"`
+	Dst    string `json:"dst" binding:"required,min=1,max=65535"`
+}
+
+// Download 以分块传输编码边生成边发送用户的文件清单
+func (service *ManifestService) Download(ctx context.Context, c *gin.Context, user *model.User) serializer.Response {
+	fs, err := filesystem.NewFileSystem(user)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	switch service.Format {
+	case filesystem.ManifestFormatCSV:
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	case filesystem.ManifestFormatJSON:
+		c.Header("Content-Type", "application/json; charset=utf-8")
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"manifest.%s\"", service.Format))
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if err := fs.WriteManifest(ctx, c.Writer, service.Format); err != nil {
+		util.Log().Warning("Failed to write manifest of user %d: %s", user.ID, err)
+		return serializer.Err(serializer.CodeNotSet, "Failed to write manifest", err)
+	}
+
+	return serializer.Response{}
+}
+
+// Export 创建文件清单导出任务，清单保存至用户的 Dst 目录
+func (service *ManifestTaskService) Export(c *gin.Context, user *model.User) serializer.Response {
+	fs, err := filesystem.NewFileSystem(user)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	if exist, _ := fs.IsPathExist(service.Dst); !exist {
+		return serializer.Err(serializer.CodeParentNotExist, "", nil)
+	}
+
+	name := fmt.Sprintf("manifest_%s.%s", time.Now().Format("20060102150405"), service.Format)
+	job, err := task.NewManifestTask(user, service.Format, path.Join(service.Dst, name))
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateTaskError, "", err)
+	}
+	task.TaskPoll.Submit(job)
+
+	return serializer.Response{Data: job.Model().ID}
+}
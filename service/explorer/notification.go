@@ -0,0 +1,49 @@
+package explorer
+
+import (
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationListService 站内通知列表服务
+type NotificationListService struct {
+	Page       uint `form:"page" binding:"required,min=1"`
+	PageSize   int  `form:"page_size" binding:"omitempty,min=1,max=200"`
+	UnreadOnly bool `form:"unread"`
+}
+
+// NotificationReadService 标记通知已读服务
+type NotificationReadService struct {
+	IDs []string `json:"ids"`
+}
+
+// List 按时间倒序列出用户的站内通知
+func (service *NotificationListService) List(c *gin.Context, user *model.User) serializer.Response {
+	pageSize := service.PageSize
+	if pageSize == 0 {
+		pageSize = 50
+	}
+
+	notifications, total := model.ListNotifications(user.ID, int(service.Page), pageSize, service.UnreadOnly)
+	return serializer.BuildNotificationList(notifications, total)
+}
+
+// Read 将给定的通知标记为已读，未指定通知时标记全部通知
+func (service *NotificationReadService) Read(c *gin.Context, user *model.User) serializer.Response {
+	ids := make([]uint, 0, len(service.IDs))
+	for _, raw := range service.IDs {
+		id, err := hashid.DecodeHashID(raw, hashid.NotificationID)
+		if err != nil {
+			return serializer.ParamErr("Invalid notification ID", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := model.MarkNotificationsRead(user.ID, ids); err != nil {
+		return serializer.DBErr("Failed to update notifications", err)
+	}
+
+	return serializer.Response{}
+}
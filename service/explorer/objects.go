@@ -25,6 +25,7 @@ type ItemMoveService struct {
 	SrcDir string        `json:"src_dir" binding:"required,min=1,max=65535"`
 	Src    ItemIDService `json:"src"`
 	Dst    string        `json:"dst" binding:"required,min=1,max=65535"`
+	Async  bool          `json:"async"`
 }
 
 // ItemRenameService 处理多文件/目录重命名
@@ -46,6 +47,7 @@ type ItemIDService struct {
 	Source     *ItemService
 	Force      bool `json:"force"`
 	UnlinkOnly bool `json:"unlink"`
+	Async      bool `json:"async"`
 }
 
 // ItemCompressService 文件压缩任务服务
@@ -99,6 +101,23 @@ func (service *ItemIDService) Raw() *ItemService {
 	return service.Source
 }
 
+// runAsync 客户端要求或对象数量达到阈值时，批量操作转为后台任务执行
+func runAsync(async bool, items *ItemService) bool {
+	threshold := model.GetIntSetting("batch_task_threshold", 100)
+	return async || (threshold > 0 && len(items.Dirs)+len(items.Items) >= threshold)
+}
+
+// submitBatchTask 创建并提交批量操作任务，返回任务ID
+func submitBatchTask(user *model.User, props task.BatchProps) serializer.Response {
+	job, err := task.NewBatchTask(user, props)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateTaskError, "", err)
+	}
+	task.TaskPoll.Submit(job)
+
+	return serializer.Response{Data: job.Model().ID}
+}
+
 // CreateDecompressTask 创建文件解压缩任务
 func (service *ItemDecompressService) CreateDecompressTask(c *gin.Context) serializer.Response {
 	// 创建文件系统
@@ -248,6 +267,12 @@ func (service *ItemIDService) Archive(ctx context.Context, c *gin.Context) seria
 		return serializer.Err(serializer.CodeGroupNotAllowed, "", nil)
 	}
 
+	// 归档格式，zip 以外的格式通过下载地址的 format 参数指定
+	format := c.Query("format")
+	if format != "" && !filesystem.IsArchiveFormatSupported(format) {
+		return serializer.ParamErr("Unsupported archive format", nil)
+	}
+
 	// 创建打包下载会话
 	ttl := model.GetIntSetting("archive_timeout", 30)
 	downloadSessionID := util.RandStringRunes(16)
@@ -258,6 +283,11 @@ func (service *ItemIDService) Archive(ctx context.Context, c *gin.Context) seria
 		fmt.Sprintf("/api/v3/file/archive/%s/archive.zip", downloadSessionID),
 		int64(ttl),
 	)
+	if err == nil && format != "" && format != filesystem.ArchiveFormatZip {
+		query := signURL.Query()
+		query.Set("format", format)
+		signURL.RawQuery = query.Encode()
+	}
 
 	return serializer.Response{
 		Code: 0,
@@ -280,12 +310,30 @@ func (service *ItemIDService) Delete(ctx context.Context, c *gin.Context) serial
 		unlink = service.UnlinkOnly
 	}
 
-	// 删除对象
+	// 删除对象，开启回收站时非强制删除的对象移入回收站
 	items := service.Raw()
-	err = fs.Delete(ctx, items.Dirs, items.Items, force, unlink)
+	trash := !force && !unlink && model.IsTrueVal(model.GetSettingByName("trash_enabled"))
+	if runAsync(service.Async, items) {
+		return submitBatchTask(fs.User, task.BatchProps{
+			Action: task.BatchActionDelete,
+			Dirs:   items.Dirs,
+			Items:  items.Items,
+			Force:  force,
+			Unlink: unlink,
+			Trash:  trash,
+		})
+	}
+
+	activities := fs.NewActivities(model.ActivityDelete, "", "", items.Dirs, items.Items)
+	if trash {
+		err = fs.Trash(ctx, items.Dirs, items.Items)
+	} else {
+		err = fs.Delete(ctx, items.Dirs, items.Items, force, unlink)
+	}
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
+	filesystem.RecordActivities(activities...)
 
 	return serializer.Response{
 		Code: 0,
@@ -304,10 +352,26 @@ func (service *ItemMoveService) Move(ctx context.Context, c *gin.Context) serial
 
 	// 移动对象
 	items := service.Src.Raw()
+	if runAsync(service.Async, items) {
+		return submitBatchTask(fs.User, task.BatchProps{
+			Action: task.BatchActionMove,
+			Dirs:   items.Dirs,
+			Items:  items.Items,
+			SrcDir: service.SrcDir,
+			Dst:    service.Dst,
+		})
+	}
+
 	err = fs.Move(ctx, items.Dirs, items.Items, service.SrcDir, service.Dst)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
+	filesystem.RecordActivities(fs.NewActivities(model.ActivityMove, service.SrcDir, service.Dst, items.Dirs, items.Items)...)
+
+	// 移动已完成，存储路径调整失败时仅记录日志
+	if err := task.SubmitRelocateTask(fs.User, items.Dirs, items.Items); err != nil {
+		util.Log().Warning("Failed to submit relocate task: %s", err)
+	}
 
 	return serializer.Response{
 		Code: 0,
@@ -330,6 +394,16 @@ func (service *ItemMoveService) Copy(ctx context.Context, c *gin.Context) serial
 	defer fs.Recycle()
 
 	// 复制对象
+	if runAsync(service.Async, service.Src.Raw()) {
+		return submitBatchTask(fs.User, task.BatchProps{
+			Action: task.BatchActionCopy,
+			Dirs:   service.Src.Raw().Dirs,
+			Items:  service.Src.Raw().Items,
+			SrcDir: service.SrcDir,
+			Dst:    service.Dst,
+		})
+	}
+
 	err = fs.Copy(ctx, service.Src.Raw().Dirs, service.Src.Raw().Items, service.SrcDir, service.Dst)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
@@ -356,10 +430,12 @@ func (service *ItemRenameService) Rename(ctx context.Context, c *gin.Context) se
 	defer fs.Recycle()
 
 	// 重命名对象
+	activities := fs.NewActivities(model.ActivityRename, "", service.NewName, service.Src.Raw().Dirs, service.Src.Raw().Items)
 	err = fs.Rename(ctx, service.Src.Raw().Dirs, service.Src.Raw().Items, service.NewName)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
+	filesystem.RecordActivities(activities...)
 
 	return serializer.Response{
 		Code: 0,
@@ -434,16 +510,10 @@ func (service *ItemPropertyService) GetProperty(ctx context.Context, c *gin.Cont
 		}
 		props.ChildFolderNum = len(childFolders) - 1
 
-		// 统计子文件
-		files, err := model.GetChildFilesOfFolders(&childFolders)
-		if err != nil {
-			return serializer.DBErr("Failed to list child files", err)
-		}
-
-		// 统计子文件个数和大小
-		props.ChildFileNum = len(files)
-		for i := 0; i < len(files); i++ {
-			props.Size += files[i].Size
+		// 汇总各子目录维护的文件个数和大小
+		for i := 0; i < len(childFolders); i++ {
+			props.ChildFileNum += int(childFolders[i].FileNum)
+			props.Size += childFolders[i].Size
 		}
 
 		// 查找父目录
@@ -0,0 +1,95 @@
+package explorer
+
+import (
+	"sort"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// PhotoFilterService 照片筛选条件，时间为 Unix 时间戳
+type PhotoFilterService struct {
+	From   *int64   `form:"from"`
+	To     *int64   `form:"to"`
+	MinLat *float64 `form:"min_lat" binding:"omitempty,min=-90,max=90"`
+	MaxLat *float64 `form:"max_lat" binding:"omitempty,min=-90,max=90"`
+	MinLng *float64 `form:"min_lng" binding:"omitempty,min=-180,max=180"`
+	MaxLng *float64 `form:"max_lng" binding:"omitempty,min=-180,max=180"`
+}
+
+// PhotoListService 照片列表服务
+type PhotoListService struct {
+	PhotoFilterService
+	Page     uint `form:"page" binding:"required,min=1"`
+	PageSize int  `form:"page_size" binding:"omitempty,min=1,max=200"`
+}
+
+// PhotoTimelineService 照片时间线服务
+type PhotoTimelineService struct {
+	PhotoFilterService
+	// 客户端所在时区相对 UTC 的偏移分钟数，用于按日期分组
+	TimezoneOffset int `form:"tz_offset" binding:"min=-840,max=840"`
+}
+
+// filter 转换为数据库查询条件
+func (service *PhotoFilterService) filter() *model.PhotoFilter {
+	res := &model.PhotoFilter{
+		MinLat: service.MinLat,
+		MaxLat: service.MaxLat,
+		MinLng: service.MinLng,
+		MaxLng: service.MaxLng,
+	}
+
+	if service.From != nil {
+		from := time.Unix(*service.From, 0)
+		res.From = &from
+	}
+
+	if service.To != nil {
+		to := time.Unix(*service.To, 0)
+		res.To = &to
+	}
+
+	return res
+}
+
+// List 按拍摄时间倒序列出用户的照片
+func (service *PhotoListService) List(c *gin.Context, user *model.User) serializer.Response {
+	pageSize := service.PageSize
+	if pageSize == 0 {
+		pageSize = 50
+	}
+
+	photos, total := model.ListPhotos(user.ID, service.filter(), int(service.Page), pageSize)
+	return serializer.BuildPhotoList(photos, total)
+}
+
+// Timeline 统计用户每天拍摄的照片数量，按日期倒序排列
+func (service *PhotoTimelineService) Timeline(c *gin.Context, user *model.User) serializer.Response {
+	takenTimes, err := model.GetPhotoTakenTimes(user.ID, service.filter())
+	if err != nil {
+		return serializer.DBErr("Failed to list photos", err)
+	}
+
+	zone := time.FixedZone("", service.TimezoneOffset*60)
+	res := make([]serializer.PhotoTimelineItem, 0)
+	index := make(map[string]int)
+	for _, takenAt := range takenTimes {
+		date := takenAt.In(zone).Format("2006-01-02")
+		if i, ok := index[date]; ok {
+			res[i].Count++
+			continue
+		}
+
+		index[date] = len(res)
+		res = append(res, serializer.PhotoTimelineItem{Date: date, Count: 1})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Date > res[j].Date
+	})
+
+	return serializer.Response{Data: res}
+}
@@ -0,0 +1,48 @@
+package explorer
+
+import (
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// FilePropertyService 更新文件自定义元数据服务
+type FilePropertyService struct {
+	Set    map[string]string `json:"set"`
+	Remove []string          `json:"remove"`
+}
+
+// ListProperties 列出文件的自定义元数据
+func (service *FileIDService) ListProperties(c *gin.Context, user *model.User) serializer.Response {
+	file, err := lockTargetFile(c, user)
+	if err != nil {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	props, err := model.GetFileProperties(file.ID)
+	if err != nil {
+		return serializer.DBErr("Failed to list file properties", err)
+	}
+
+	res := make(map[string]string, len(props))
+	for _, prop := range props {
+		res[prop.Name] = prop.Value
+	}
+
+	return serializer.Response{Data: res}
+}
+
+// Update 设定并移除文件的自定义元数据
+func (service *FilePropertyService) Update(c *gin.Context, user *model.User) serializer.Response {
+	file, err := lockTargetFile(c, user)
+	if err != nil {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	if err := filesystem.UpdateFileProperties(file, service.Set, service.Remove); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
@@ -39,14 +39,15 @@ func (service *ItemSearchService) Search(c *gin.Context) serializer.Response {
 	switch service.Type {
 	case "keywords":
 		return service.SearchKeywords(c, fs, "%"+service.Keywords+"%")
-	case "image":
-		return service.SearchKeywords(c, fs, "%.bmp", "%.iff", "%.png", "%.gif", "%.jpg", "%.jpeg", "%.psd", "%.svg", "%.webp")
-	case "video":
-		return service.SearchKeywords(c, fs, "%.mp4", "%.flv", "%.avi", "%.wmv", "%.mkv", "%.rm", "%.rmvb", "%.mov", "%.ogv")
-	case "audio":
-		return service.SearchKeywords(c, fs, "%.mp3", "%.flac", "%.ape", "%.wav", "%.acc", "%.ogg", "%.midi", "%.mid")
-	case "doc":
-		return service.SearchKeywords(c, fs, "%.txt", "%.md", "%.pdf", "%.doc", "%.docx", "%.ppt", "%.pptx", "%.xls", "%.xlsx", "%.pub")
+	case "image", "video", "audio", "doc":
+		patterns := filesystem.FileTypePatterns[service.Type]
+		keywords := make([]interface{}, len(patterns))
+		for i := range patterns {
+			keywords[i] = patterns[i]
+		}
+		return service.SearchKeywords(c, fs, keywords...)
+	case "file_tag":
+		return service.SearchFilter(c, fs, &model.FileFilter{Tags: []string{service.Keywords}})
 	case "tag":
 		if tid, err := hashid.DecodeHashID(service.Keywords, hashid.TagID); err == nil {
 			if tag, err := model.GetTagsByID(tid, fs.User.ID); err == nil {
@@ -58,6 +59,14 @@ func (service *ItemSearchService) Search(c *gin.Context) serializer.Response {
 					}
 					return service.SearchKeywords(c, fs, expInput...)
 				}
+
+				if tag.Type == model.SmartFilterType {
+					filter, err := filesystem.ParseFilterExpression(tag.Expression)
+					if err != nil {
+						return serializer.ParamErr(err.Error(), err)
+					}
+					return service.SearchFilter(c, fs, filter)
+				}
 			}
 		}
 		return serializer.Err(serializer.CodeNotFound, "", nil)
@@ -66,6 +75,26 @@ func (service *ItemSearchService) Search(c *gin.Context) serializer.Response {
 	}
 }
 
+// SearchFilter 搜索满足筛选条件的文件
+func (service *ItemSearchService) SearchFilter(c *gin.Context, fs *filesystem.FileSystem, filter *model.FileFilter) serializer.Response {
+	// 上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	objects, err := fs.SearchByFilter(ctx, filter)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: map[string]interface{}{
+			"parent":  0,
+			"objects": objects,
+		},
+	}
+}
+
 // SearchKeywords 根据关键字搜索文件
 func (service *ItemSearchService) SearchKeywords(c *gin.Context, fs *filesystem.FileSystem, keywords ...interface{}) serializer.Response {
 	// 上下文
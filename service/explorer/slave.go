@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"os"
 	"time"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
@@ -14,6 +15,7 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/cluster"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/task"
 	"github.com/cloudreve/Cloudreve/v3/pkg/task/slavetask"
@@ -35,6 +37,13 @@ type SlaveFileService struct {
 	Ext         string `uri:"ext"`
 }
 
+// SlaveThumbService 从机生成缩略图服务
+type SlaveThumbService struct {
+	Src  string `json:"src" binding:"required,min=1,max=65535"`
+	Name string `json:"name" binding:"required,min=1,max=255"`
+	Size uint64 `json:"size"`
+}
+
 // SlaveFilesService 从机多文件相关服务
 type SlaveFilesService struct {
 	Files []string `json:"files" binding:"required,gt=0"`
@@ -46,6 +55,57 @@ type SlaveListService struct {
 	Recursive bool   `json:"recursive"`
 }
 
+// SlaveFetchService 从机拉取文件服务
+type SlaveFetchService struct {
+	Src       string `json:"src" binding:"required"`
+	Dst       string `json:"dst" binding:"required,min=1,max=65535"`
+	Size      uint64 `json:"size"`
+	Overwrite bool   `json:"overwrite"`
+}
+
+// Fetch 从给定地址下载文件并保存至从机的存储路径
+func (service *SlaveFetchService) Fetch(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewAnonymousFileSystem()
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	rs, err := request.NewClient().Request(
+		"GET",
+		service.Src,
+		nil,
+		request.WithContext(ctx),
+		request.WithTimeout(time.Duration(0)),
+	).CheckHTTPResponse(http.StatusOK).GetRSCloser()
+	if err != nil {
+		return serializer.Err(serializer.CodeIOFailed, "Failed to download source file", err)
+	}
+
+	mode := fsctx.WriteMode(0)
+	if service.Overwrite {
+		mode = fsctx.Overwrite
+	}
+
+	if err := fs.Handler.Put(ctx, &fsctx.FileStream{
+		File:     rs,
+		Size:     service.Size,
+		SavePath: service.Dst,
+		Mode:     mode,
+	}); err != nil {
+		return serializer.Err(serializer.CodeIOFailed, "Failed to save file", err)
+	}
+
+	// 校验文件大小，避免源文件下载中断时保存不完整的文件
+	if info, err := os.Stat(util.RelativePath(service.Dst)); err != nil || uint64(info.Size()) != service.Size {
+		fs.Handler.Delete(ctx, []string{service.Dst})
+		return serializer.Err(serializer.CodeIOFailed, "Size of downloaded file mismatch", err)
+	}
+
+	return serializer.Response{}
+}
+
 // ServeFile 通过签名的URL下载从机文件
 func (service *SlaveDownloadService) ServeFile(ctx context.Context, c *gin.Context, isDownload bool) serializer.Response {
 	// 创建文件系统
@@ -89,6 +149,7 @@ func (service *SlaveDownloadService) ServeFile(ctx context.Context, c *gin.Conte
 	}
 
 	// 发送文件
+	fs.WithDownloadSpeedLimit(c)
 	http.ServeContent(c.Writer, c.Request, fs.FileTarget[0].Name, time.Now(), rs)
 
 	return serializer.Response{}
@@ -147,6 +208,23 @@ func (service *SlaveFileService) Thumb(ctx context.Context, c *gin.Context) seri
 	return serializer.Response{}
 }
 
+// Generate 为从机上的文件生成缩略图，保存为 sidecar 文件
+func (service *SlaveThumbService) Generate(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewAnonymousFileSystem()
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	file := &model.File{SourceName: service.Src, Name: service.Name, Size: service.Size}
+	if err := fs.GenerateThumbnail(ctx, file); err != nil {
+		return serializer.Err(serializer.CodeNotSet, "Failed to generate thumb", err)
+	}
+
+	return serializer.Response{}
+}
+
 // CreateTransferTask 创建从机文件转存任务
 func CreateTransferTask(c *gin.Context, req *serializer.SlaveTransferReq) serializer.Response {
 	if id, ok := c.Get("MasterSiteID"); ok {
@@ -180,6 +258,11 @@ func (service *SlaveCreateUploadSessionService) Create(ctx context.Context, c *g
 		return serializer.Err(serializer.CodeConflict, "placeholder file already exist", nil)
 	}
 
+	// 记录会话所属的主机节点，上传进度将转发至该主机
+	if id, ok := c.Get("MasterSiteID"); ok {
+		service.Session.MasterID = id.(string)
+	}
+
 	err := cache.Set(
 		filesystem.UploadSessionCachePrefix+service.Session.Key,
 		service.Session,
@@ -0,0 +1,54 @@
+package explorer
+
+import (
+	"context"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// StarredListService 星标列表服务
+type StarredListService struct {
+}
+
+// List 列出用户加了星标的全部文件和目录
+func (service *StarredListService) List(ctx context.Context, c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	objects, err := fs.ListStarred(ctx)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Data: map[string]interface{}{
+			"parent":  0,
+			"objects": objects,
+		},
+	}
+}
+
+// Star 为文件和目录添加或移除星标
+func (service *ItemIDService) Star(c *gin.Context, user *model.User, starred bool) serializer.Response {
+	items := service.Raw()
+
+	if len(items.Items) > 0 {
+		if err := model.SetFilesStarred(items.Items, user.ID, starred); err != nil {
+			return serializer.DBErr("Failed to update starred files", err)
+		}
+	}
+
+	if len(items.Dirs) > 0 {
+		if err := model.SetFoldersStarred(items.Dirs, user.ID, starred); err != nil {
+			return serializer.DBErr("Failed to update starred folders", err)
+		}
+	}
+
+	return serializer.Response{}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/gin-gonic/gin"
@@ -24,6 +25,20 @@ type LinkTagCreateService struct {
 	Name string `json:"name" binding:"required,min=1,max=255"`
 }
 
+// SmartTagCreateService 智能标签创建服务
+type SmartTagCreateService struct {
+	Expression string `json:"expression" binding:"required,min=1,max=65535"`
+	Icon       string `json:"icon" binding:"required,min=1,max=255"`
+	Name       string `json:"name" binding:"required,min=1,max=255"`
+	Color      string `json:"color" binding:"hexcolor|rgb|rgba|hsl"`
+}
+
+// FileTagService 文件标签服务，Items 为文件的 HashID
+type FileTagService struct {
+	Items []string `json:"items" binding:"required,min=1"`
+	Tags  []string `json:"tags" binding:"required,min=1,dive,min=1,max=255"`
+}
+
 // TagService 标签服务
 type TagService struct {
 }
@@ -86,3 +101,85 @@ func (service *FilterTagCreateService) Create(c *gin.Context, user *model.User)
 		Data: hashid.HashID(id, hashid.TagID),
 	}
 }
+
+// Create 创建智能标签
+func (service *SmartTagCreateService) Create(c *gin.Context, user *model.User) serializer.Response {
+	if _, err := filesystem.ParseFilterExpression(service.Expression); err != nil {
+		return serializer.ParamErr(err.Error(), err)
+	}
+
+	// 创建标签
+	tag := model.Tag{
+		Name:       service.Name,
+		Icon:       service.Icon,
+		Color:      service.Color,
+		Type:       model.SmartFilterType,
+		Expression: strings.TrimSpace(service.Expression),
+		UserID:     user.ID,
+	}
+	id, err := tag.Create()
+	if err != nil {
+		return serializer.DBErr("Failed to create a tag", err)
+	}
+
+	return serializer.Response{
+		Data: hashid.HashID(id, hashid.TagID),
+	}
+}
+
+// files 获取要添加或移除标签的用户文件 ID
+func (service *FileTagService) files(user *model.User) ([]uint, error) {
+	ids := make([]uint, 0, len(service.Items))
+	for _, item := range service.Items {
+		id, err := hashid.DecodeHashID(item, hashid.FileID)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	files, err := model.GetFilesByIDs(ids, user.ID)
+	if err != nil || len(files) != len(ids) {
+		return nil, filesystem.ErrObjectNotExist.WithError(err)
+	}
+
+	return ids, nil
+}
+
+// Add 为文件添加标签
+func (service *FileTagService) Add(c *gin.Context, user *model.User) serializer.Response {
+	ids, err := service.files(user)
+	if err != nil {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	if err := model.AddFileTags(user.ID, ids, service.Tags); err != nil {
+		return serializer.DBErr("Failed to add file tags", err)
+	}
+
+	return serializer.Response{}
+}
+
+// Remove 移除文件上的标签
+func (service *FileTagService) Remove(c *gin.Context, user *model.User) serializer.Response {
+	ids, err := service.files(user)
+	if err != nil {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	if err := model.RemoveFileTags(user.ID, ids, service.Tags); err != nil {
+		return serializer.DBErr("Failed to remove file tags", err)
+	}
+
+	return serializer.Response{}
+}
+
+// ListFileTags 列出用户使用过的全部文件标签
+func (service *TagService) ListFileTags(c *gin.Context, user *model.User) serializer.Response {
+	names, err := model.GetFileTagNames(user.ID)
+	if err != nil {
+		return serializer.DBErr("Failed to list file tags", err)
+	}
+
+	return serializer.Response{Data: names}
+}
@@ -0,0 +1,98 @@
+package explorer
+
+import (
+	"path"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// FolderTemplateCreateService 目录模板创建服务
+type FolderTemplateCreateService struct {
+	Name    string   `json:"name" binding:"required,min=1,max=255"`
+	Folders []string `json:"folders" binding:"required,min=1,dive,min=1,max=65535"`
+}
+
+// FolderTemplateInstantiateService 目录模板实例化服务
+type FolderTemplateInstantiateService struct {
+	Path string `json:"path" binding:"required,min=1,max=65535"`
+	Name string `json:"name" binding:"required,min=1,max=255"`
+}
+
+// FolderTemplateService 目录模板服务
+type FolderTemplateService struct {
+}
+
+// List 列出用户可用的目录模板
+func (service *FolderTemplateService) List(c *gin.Context, user *model.User) serializer.Response {
+	templates, err := model.GetFolderTemplatesByUser(user.ID)
+	if err != nil {
+		return serializer.DBErr("Failed to list folder templates", err)
+	}
+
+	return serializer.BuildFolderTemplateList(templates)
+}
+
+// Delete 删除用户创建的目录模板
+func (service *FolderTemplateService) Delete(c *gin.Context, user *model.User) serializer.Response {
+	id, _ := c.Get("object_id")
+	if err := model.DeleteFolderTemplateByID(id.(uint), user.ID); err != nil {
+		return serializer.DBErr("Failed to delete folder template", err)
+	}
+	return serializer.Response{}
+}
+
+// Create 创建用户的目录模板
+func (service *FolderTemplateCreateService) Create(c *gin.Context, user *model.User) serializer.Response {
+	fs, err := filesystem.NewFileSystem(user)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	folders, err := fs.NormalizeTemplateFolders(c, service.Folders)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	template := model.FolderTemplate{
+		UserID:     user.ID,
+		Name:       service.Name,
+		FolderList: folders,
+	}
+	id, err := template.Create()
+	if err != nil {
+		return serializer.DBErr("Failed to create folder template", err)
+	}
+
+	return serializer.Response{
+		Data: hashid.HashID(id, hashid.FolderTemplateID),
+	}
+}
+
+// Instantiate 在指定目录下按模板创建目录结构
+func (service *FolderTemplateInstantiateService) Instantiate(c *gin.Context, user *model.User) serializer.Response {
+	id, _ := c.Get("object_id")
+	template, err := model.GetFolderTemplateByID(id.(uint), user.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "Folder template not exist", err)
+	}
+
+	fs, err := filesystem.NewFileSystem(user)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	folder, err := fs.InstantiateTemplate(c, template, service.Path, service.Name)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Data: path.Join(folder.Position, folder.Name),
+	}
+}
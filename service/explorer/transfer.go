@@ -0,0 +1,62 @@
+package explorer
+
+import (
+	"context"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// CrossUserCopyService 复制对象到其他用户空间服务
+type CrossUserCopyService struct {
+	SrcDir   string        `json:"src_dir" binding:"required,min=1,max=65535"`
+	Src      ItemIDService `json:"src"`
+	Email    string        `json:"email" binding:"required,email"`
+	Transfer bool          `json:"transfer"`
+}
+
+// crossUserCopyDst 对象复制到目标用户空间中的位置，用户无法指定目标用户的其他目录
+const crossUserCopyDst = "/"
+
+// crossUserCopyFailed 无法复制到目标用户时的统一错误，不携带底层错误，避免暴露目标用户是否存在及其空间状态
+func crossUserCopyFailed() serializer.Response {
+	return serializer.Err(serializer.CodeNotSet, "Cannot copy objects to this user", nil)
+}
+
+// Copy 将对象复制到目标用户的根目录，Transfer 为 true 时同时删除原对象
+func (service *CrossUserCopyService) Copy(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	if !fs.User.Group.OptionsSerialized.CrossUserCopy {
+		return serializer.Err(serializer.CodeGroupNotAllowed, "", nil)
+	}
+
+	target, err := model.GetActiveUserByEmail(service.Email)
+	if err != nil {
+		return crossUserCopyFailed()
+	}
+
+	dstFs, err := filesystem.NewFileSystem(&target)
+	if err != nil {
+		return crossUserCopyFailed()
+	}
+	defer dstFs.Recycle()
+
+	items := service.Src.Raw()
+	err = fs.CopyToUser(ctx, dstFs, items.Dirs, items.Items, service.SrcDir, crossUserCopyDst, service.Transfer)
+	if err == filesystem.ErrFileRetained {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+	if err != nil {
+		return crossUserCopyFailed()
+	}
+
+	return serializer.Response{}
+}
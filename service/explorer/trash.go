@@ -0,0 +1,101 @@
+package explorer
+
+import (
+	"context"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// TrashListService 回收站列表服务
+type TrashListService struct {
+}
+
+// TrashService 回收站对象服务，字段值为HashID
+type TrashService struct {
+	Items []string `json:"items" binding:"required,min=1"`
+}
+
+// Raw 获取回收站对象的原始ID
+func (service *TrashService) Raw() []uint {
+	ids := make([]uint, 0, len(service.Items))
+	for _, item := range service.Items {
+		id, err := hashid.DecodeHashID(item, hashid.TrashID)
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// List 列出回收站中的对象
+func (service *TrashListService) List(c *gin.Context, user *model.User) serializer.Response {
+	trashes, err := model.ListTrash(user.ID)
+	if err != nil {
+		return serializer.DBErr("Failed to list trash", err)
+	}
+
+	return serializer.BuildTrashList(trashes, model.GetIntSetting("trash_retention_days", 30))
+}
+
+// Restore 将回收站中的对象恢复到原目录
+func (service *TrashService) Restore(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	if err := fs.RestoreTrash(ctx, service.Raw()); err != nil {
+		return serializer.Err(serializer.CodeNotSet, "Failed to restore object", err)
+	}
+
+	return serializer.Response{}
+}
+
+// Purge 彻底删除回收站中的对象
+func (service *TrashService) Purge(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	trashes, err := model.GetTrashByIDs(service.Raw(), fs.User.ID)
+	if err != nil || len(trashes) == 0 {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	if err := fs.PurgeTrash(ctx, trashes); err != nil {
+		return serializer.Err(serializer.CodeNotSet, "Failed to purge trash", err)
+	}
+
+	return serializer.Response{}
+}
+
+// Empty 清空回收站
+func (service *TrashListService) Empty(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	trashes, err := model.ListTrash(fs.User.ID)
+	if err != nil {
+		return serializer.DBErr("Failed to list trash", err)
+	}
+
+	if err := fs.PurgeTrash(ctx, trashes); err != nil {
+		return serializer.Err(serializer.CodeNotSet, "Failed to purge trash", err)
+	}
+
+	return serializer.Response{}
+}
@@ -0,0 +1,437 @@
+package explorer
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/remote"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrTusChecksumMismatch tus 分片校验和不匹配
+var ErrTusChecksumMismatch = serializer.NewError(serializer.CodeChecksumMismatch, "Checksum mismatch", nil)
+
+const (
+	// tusOffsetCachePrefix 从机策略上传会话已中转字节数的缓存前缀
+	tusOffsetCachePrefix = "tus_offset_"
+	// tusLockCachePrefix 上传会话写入锁的缓存前缀
+	tusLockCachePrefix = "tus_lock_"
+	// tusLockTTL 写入锁的最长持有时间，单位为秒，防止实例异常退出后会话一直被锁定
+	tusLockTTL = 3600
+)
+
+// TusService tus 协议上传服务。本机写入的存储策略由本机写入上传会话的占位文件，
+// 从机策略的上传数据由本机中转至从机，客户端无需为从机签名请求
+type TusService struct {
+	ID string `uri:"sessionId"`
+}
+
+// Create 根据 Upload-Length 和 Upload-Metadata 创建上传会话
+func (service *TusService) Create(ctx context.Context, c *gin.Context) serializer.Response {
+	size, err := strconv.ParseUint(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil {
+		return serializer.ParamErr("Invalid Upload-Length", err)
+	}
+
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	meta := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	file := &fsctx.FileStream{
+		Size:        size,
+		Name:        tusMetaValue(meta, "filename", "name"),
+		VirtualPath: tusMetaValue(meta, "path"),
+		File:        ioutil.NopCloser(strings.NewReader("")),
+		MimeType:    tusMetaValue(meta, "filetype", "type"),
+	}
+	if file.VirtualPath == "" {
+		file.VirtualPath = "/"
+	}
+
+	// 使用目标目录绑定的存储策略
+	if err := fs.SwitchPolicyByPath(file.VirtualPath); err != nil {
+		return serializer.Err(serializer.CodePolicyNotExist, "", err)
+	}
+
+	// tus 上传需要由本机写入或中转至从机
+	if fs.Handler.Capabilities().PresignedUpload && fs.Policy.Type != "remote" {
+		return serializer.Err(serializer.CodePolicyNotAllowed, "Current storage policy does not support tus upload", nil)
+	}
+
+	ctx = context.WithValue(ctx, fsctx.CameraUploadCtx, model.IsTrueVal(tusMetaValue(meta, "camera_upload")))
+	credential, err := fs.CreateUploadSession(ctx, file)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: serializer.UploadProgress{
+			SessionID: credential.SessionID,
+			ChunkSize: credential.ChunkSize,
+			Size:      size,
+			Expires:   credential.Expires,
+		},
+	}
+}
+
+// Offset 获取上传会话当前已接收的偏移量
+func (service *TusService) Offset(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	session, file, err := service.loadSession(fs)
+	if err != nil {
+		return serializer.Err(serializer.CodeUploadSessionExpired, "", err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: serializer.UploadProgress{
+			SessionID: session.Key,
+			ChunkSize: session.Policy.OptionsSerialized.ChunkSize,
+			Size:      session.Size,
+			Uploaded:  tusOffset(session, file),
+			Expires:   session.Expires,
+		},
+	}
+}
+
+// Patch 从 Upload-Offset 处追加写入请求正文，从机策略的请求正文由 relayTusPatch 中转至从机写入
+func (service *TusService) Patch(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	offset, err := strconv.ParseUint(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil {
+		return serializer.ParamErr("Invalid Upload-Offset", err)
+	}
+
+	// 同一会话同时只允许一个请求写入，避免并发请求都通过偏移量校验
+	if !lockTusSession(service.ID) {
+		return serializer.Err(serializer.CodeConflictUploadOngoing, "Upload session is being written by another request", nil)
+	}
+	defer unlockTusSession(service.ID)
+
+	session, file, err := service.loadSession(fs)
+	if err != nil {
+		return serializer.Err(serializer.CodeUploadSessionExpired, "", err)
+	}
+
+	if offset != tusOffset(session, file) {
+		return serializer.Err(serializer.CodeConflict, "Upload-Offset does not match current offset", nil)
+	}
+
+	length := c.Request.ContentLength
+	if length < 0 || offset+uint64(length) > session.Size {
+		return serializer.Err(serializer.CodeInvalidContentLength, "Invalid Content-Length", nil)
+	}
+
+	// 从机策略由本机将数据中转至从机，校验和由从机校验
+	if session.Policy.Type == "remote" {
+		return relayTusPatch(ctx, c, session, offset, length)
+	}
+
+	hasher, checksum, err := parseTusChecksum(c.GetHeader("Upload-Checksum"))
+	if err != nil {
+		return serializer.ParamErr(err.Error(), err)
+	}
+
+	// 重设 fs 存储策略
+	fs.Policy = &session.Policy
+	if err := fs.DispatchHandler(); err != nil {
+		return serializer.Err(serializer.CodePolicyNotExist, "", err)
+	}
+
+	var body io.Reader = c.Request.Body
+	if hasher != nil {
+		body = io.TeeReader(body, hasher)
+	}
+
+	mode := fsctx.Append
+	if offset > 0 {
+		mode |= fsctx.Overwrite
+	}
+
+	fileData := fsctx.FileStream{
+		MimeType:        c.Request.Header.Get("Content-Type"),
+		File:            ioutil.NopCloser(body),
+		Size:            uint64(length),
+		Name:            session.Name,
+		VirtualPath:     session.VirtualPath,
+		SavePath:        session.SavePath,
+		Mode:            mode,
+		AppendStart:     offset,
+		UploadSessionID: &session.Key,
+		Model:           file,
+		LastModified:    session.LastModified,
+	}
+
+	// 给文件系统分配钩子
+	fs.Use("AfterUploadCanceled", filesystem.HookTruncateFileTo(offset))
+	fs.Use("AfterValidateFailed", filesystem.HookTruncateFileTo(offset))
+	fs.Use("BeforeUpload", filesystem.HookReserveCapacity)
+	if hasher != nil {
+		fs.Use("AfterUpload", hookVerifyTusChecksum(hasher, checksum))
+	}
+	fs.Use("AfterUpload", filesystem.HookChunkUploaded)
+	fs.Use("AfterUpload", filesystem.HookConfirmCapacity)
+	fs.Use("AfterValidateFailed", filesystem.HookChunkUploadFailed)
+	if offset+uint64(length) == session.Size {
+		fs.Use("AfterUpload", filesystem.HookCompleteUpload)
+		fs.Use("AfterUpload", filesystem.HookVerifyChecksum(session.MD5, session.Hash))
+		fs.UseAfterUploadHooks(session.CameraUpload,
+			filesystem.HookReplaceConflictedFile(session), filesystem.HookPopPlaceholderToFile(""))
+		fs.Use("AfterUpload", filesystem.HookDeleteUploadSession(session.Key))
+		fs.Use("AfterUpload", filesystem.HookReleaseCapacity)
+	}
+
+	// 执行上传
+	uploadCtx := context.WithValue(ctx, fsctx.GinCtx, c)
+	if err := fs.Upload(uploadCtx, &fileData); err != nil {
+		if offset+uint64(length) == session.Size {
+			abortRejectedUpload(uploadCtx, fs, file, err)
+		}
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: serializer.UploadProgress{
+			SessionID: session.Key,
+			ChunkSize: session.Policy.OptionsSerialized.ChunkSize,
+			Size:      session.Size,
+			Uploaded:  offset + uint64(length),
+			Expires:   session.Expires,
+		},
+	}
+}
+
+// relayTusPatch 将 PATCH 请求正文从 offset 处写入从机上的上传会话，写满文件后由从机回调本机完成上传
+func relayTusPatch(ctx context.Context, c *gin.Context, session *serializer.UploadSession, offset uint64, length int64) serializer.Response {
+	client, err := remote.NewClient(&session.Policy)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotExist, "", err)
+	}
+
+	if err := client.UploadAt(ctx, session.Key, offset, c.Request.Body, length, c.GetHeader("Upload-Checksum")); err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+
+	uploaded := offset + uint64(length)
+	if uploaded < session.Size {
+		cache.Set(tusOffsetCachePrefix+session.Key, uploaded, tusSessionTTL(session))
+	} else {
+		cache.Deletes([]string{session.Key}, tusOffsetCachePrefix)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: serializer.UploadProgress{
+			SessionID: session.Key,
+			ChunkSize: session.Policy.OptionsSerialized.ChunkSize,
+			Size:      session.Size,
+			Uploaded:  uploaded,
+			Expires:   session.Expires,
+		},
+	}
+}
+
+// processSlaveOffsetUpload 从机从 offset 处写入主机中转的 tus 上传数据，写满文件后回调主机
+func processSlaveOffsetUpload(ctx context.Context, c *gin.Context, fs *filesystem.FileSystem, session *serializer.UploadSession, offset uint64, mode fsctx.WriteMode) serializer.Response {
+	length := c.Request.ContentLength
+	if length < 0 || offset+uint64(length) > session.Size {
+		return serializer.Err(serializer.CodeInvalidContentLength, "Invalid Content-Length", nil)
+	}
+
+	hasher, checksum, err := parseTusChecksum(c.GetHeader(remote.ChecksumHeader))
+	if err != nil {
+		return serializer.ParamErr(err.Error(), err)
+	}
+
+	var body io.Reader = c.Request.Body
+	if hasher != nil {
+		body = io.TeeReader(body, hasher)
+	}
+
+	fileData := fsctx.FileStream{
+		MimeType:        c.Request.Header.Get("Content-Type"),
+		File:            ioutil.NopCloser(body),
+		Size:            uint64(length),
+		Name:            session.Name,
+		VirtualPath:     session.VirtualPath,
+		SavePath:        session.SavePath,
+		Mode:            mode,
+		AppendStart:     offset,
+		UploadSessionID: &session.Key,
+		LastModified:    session.LastModified,
+	}
+
+	fs.Use("AfterUploadCanceled", filesystem.HookTruncateFileTo(offset))
+	fs.Use("AfterValidateFailed", filesystem.HookTruncateFileTo(offset))
+	if hasher != nil {
+		fs.Use("AfterUpload", hookVerifyTusChecksum(hasher, checksum))
+	}
+	if offset+uint64(length) == session.Size {
+		fs.Use("AfterUpload", filesystem.HookCompleteUpload)
+		fs.Use("AfterUpload", filesystem.SlaveAfterUpload(session))
+		fs.Use("AfterUpload", filesystem.HookDeleteUploadSession(session.Key))
+	}
+
+	uploadCtx := context.WithValue(ctx, fsctx.GinCtx, c)
+	if err := fs.Upload(uploadCtx, &fileData); err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
+
+// tusOffset 返回上传会话已接收的字节数。从机策略的占位文件创建时即为完整大小，已中转的字节数单独记录
+func tusOffset(session *serializer.UploadSession, file *model.File) uint64 {
+	if session.Policy.Type != "remote" {
+		return file.Size
+	}
+
+	if offset, ok := cache.Get(tusOffsetCachePrefix + session.Key); ok {
+		return offset.(uint64)
+	}
+
+	return 0
+}
+
+// tusSessionTTL 返回上传会话剩余的有效秒数
+func tusSessionTTL(session *serializer.UploadSession) int {
+	if ttl := int(time.Until(time.Unix(session.Expires, 0)).Seconds()); ttl > 0 {
+		return ttl
+	}
+
+	return 1
+}
+
+// lockTusSession 尝试获取上传会话的写入锁，多个实例间通过缓存共享
+func lockTusSession(id string) bool {
+	count, err := cache.IncrBy(tusLockCachePrefix+id, 1, tusLockTTL)
+	return err == nil && count == 1
+}
+
+// unlockTusSession 释放上传会话的写入锁
+func unlockTusSession(id string) {
+	cache.Deletes([]string{id}, tusLockCachePrefix)
+}
+
+// loadSession 查找当前用户的上传会话及其占位文件
+func (service *TusService) loadSession(fs *filesystem.FileSystem) (*serializer.UploadSession, *model.File, error) {
+	sessionRaw, ok := cache.Get(filesystem.UploadSessionCachePrefix + service.ID)
+	if !ok {
+		return nil, nil, filesystem.ErrUploadSessionNotExist
+	}
+
+	session := sessionRaw.(serializer.UploadSession)
+	if session.UID != fs.User.ID {
+		return nil, nil, filesystem.ErrUploadSessionNotExist
+	}
+
+	file, err := model.GetFilesByUploadSession(service.ID, fs.User.ID)
+	if err != nil {
+		return nil, nil, filesystem.ErrUploadSessionNotExist.WithError(err)
+	}
+
+	return &session, file, nil
+}
+
+// parseTusMetadata 解析 Upload-Metadata，格式为逗号分隔的 "key base64(value)"
+func parseTusMetadata(raw string) map[string]string {
+	meta := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), " ", 2)
+		if kv[0] == "" {
+			continue
+		}
+
+		meta[kv[0]] = ""
+		if len(kv) == 2 {
+			if value, err := base64.StdEncoding.DecodeString(kv[1]); err == nil {
+				meta[kv[0]] = string(value)
+			}
+		}
+	}
+
+	return meta
+}
+
+// tusMetaValue 按顺序取第一个非空的元数据值
+func tusMetaValue(meta map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if value := meta[key]; value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// hookVerifyTusChecksum 校验本次写入数据的 tus 校验和
+func hookVerifyTusChecksum(hasher hash.Hash, checksum []byte) filesystem.Hook {
+	return func(ctx context.Context, fs *filesystem.FileSystem, fileHeader fsctx.FileHeader) error {
+		if !bytes.Equal(hasher.Sum(nil), checksum) {
+			return ErrTusChecksumMismatch
+		}
+		return nil
+	}
+}
+
+// parseTusChecksum 解析 Upload-Checksum，格式为 "算法 base64(摘要)"
+func parseTusChecksum(raw string) (hash.Hash, []byte, error) {
+	if raw == "" {
+		return nil, nil, nil
+	}
+
+	parts := strings.SplitN(raw, " ", 2)
+	if len(parts) != 2 {
+		return nil, nil, errors.New("invalid Upload-Checksum")
+	}
+
+	checksum, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, errors.New("invalid Upload-Checksum")
+	}
+
+	switch parts[0] {
+	case "md5":
+		return md5.New(), checksum, nil
+	case "sha1":
+		return sha1.New(), checksum, nil
+	case "sha256":
+		return sha256.New(), checksum, nil
+	default:
+		return nil, nil, errors.New("unsupported checksum algorithm")
+	}
+}
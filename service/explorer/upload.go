@@ -7,18 +7,22 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
-	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/mq"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"io/ioutil"
+	"path"
 	"strconv"
 	"strings"
 	"time"
 )
 
+var progressUpgrader = websocket.Upgrader{}
+
 // CreateUploadSessionService 获取上传凭证服务
 type CreateUploadSessionService struct {
 	Path         string `json:"path" binding:"required"`
@@ -27,6 +31,29 @@ type CreateUploadSessionService struct {
 	PolicyID     string `json:"policy_id" binding:"required"`
 	LastModified int64  `json:"last_modified"`
 	MimeType     string `json:"mime_type"`
+	Hash         string `json:"hash" binding:"omitempty,len=64,hexadecimal"`
+	MD5          string `json:"md5" binding:"omitempty,len=32,hexadecimal"`
+	Conflict     string `json:"conflict" binding:"omitempty,eq=fail|eq=overwrite|eq=rename|eq=version"`
+	StorageClass string `json:"storage_class"`
+	CameraUpload bool   `json:"camera_upload"`
+}
+
+// FolderUploadFile 文件夹上传中的单个文件
+type FolderUploadFile struct {
+	RelativePath string `json:"relative_path" binding:"required"`
+	Size         uint64 `json:"size" binding:"min=0"`
+	LastModified int64  `json:"last_modified"`
+	MimeType     string `json:"mime_type"`
+	Hash         string `json:"hash" binding:"omitempty,len=64,hexadecimal"`
+	MD5          string `json:"md5" binding:"omitempty,len=32,hexadecimal"`
+}
+
+// CreateFolderUploadSessionService 文件夹上传服务，按相对路径批量创建上传会话
+type CreateFolderUploadSessionService struct {
+	Path     string             `json:"path" binding:"required"`
+	PolicyID string             `json:"policy_id" binding:"required"`
+	Files    []FolderUploadFile `json:"files" binding:"required,min=1,dive"`
+	Conflict string             `json:"conflict" binding:"omitempty,eq=fail|eq=overwrite|eq=rename|eq=version"`
 }
 
 // Create 创建新的上传会话
@@ -37,6 +64,11 @@ func (service *CreateUploadSessionService) Create(ctx context.Context, c *gin.Co
 		return serializer.Err(serializer.CodeCreateFSError, "", err)
 	}
 
+	// 使用目标目录绑定的存储策略
+	if err := fs.SwitchPolicyByPath(service.Path); err != nil {
+		return serializer.Err(serializer.CodePolicyNotExist, "", err)
+	}
+
 	// 取得存储策略的ID
 	rawID, err := hashid.DecodeHashID(service.PolicyID, hashid.PolicyID)
 	if err != nil {
@@ -53,11 +85,25 @@ func (service *CreateUploadSessionService) Create(ctx context.Context, c *gin.Co
 		VirtualPath: service.Path,
 		File:        ioutil.NopCloser(strings.NewReader("")),
 		MimeType:    service.MimeType,
+		Hash:        strings.ToLower(service.Hash),
+		MD5:         strings.ToLower(service.MD5),
 	}
 	if service.LastModified > 0 {
 		lastModified := time.UnixMilli(service.LastModified)
 		file.LastModified = &lastModified
 	}
+
+	// 使用指定的存储类型
+	if service.StorageClass != "" {
+		if !fs.Policy.IsStorageClassAllowed(service.StorageClass) {
+			return serializer.Err(serializer.CodePolicyNotAllowed, "Storage class not allowed", nil)
+		}
+
+		file.SetMetadata(model.StorageClassMetadataKey, service.StorageClass)
+	}
+
+	ctx = context.WithValue(ctx, fsctx.ConflictStrategyCtx, fsctx.ConflictStrategy(service.Conflict))
+	ctx = context.WithValue(ctx, fsctx.CameraUploadCtx, service.CameraUpload)
 	credential, err := fs.CreateUploadSession(ctx, file)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
@@ -69,6 +115,86 @@ func (service *CreateUploadSessionService) Create(ctx context.Context, c *gin.Co
 	}
 }
 
+// Create 预先创建全部中间目录，再为每个文件创建上传会话
+func (service *CreateFolderUploadSessionService) Create(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	// 使用目标目录绑定的存储策略
+	if err := fs.SwitchPolicyByPath(service.Path); err != nil {
+		return serializer.Err(serializer.CodePolicyNotExist, "", err)
+	}
+
+	// 取得存储策略的ID
+	rawID, err := hashid.DecodeHashID(service.PolicyID, hashid.PolicyID)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotExist, "", err)
+	}
+
+	if fs.Policy.ID != rawID {
+		return serializer.Err(serializer.CodePolicyNotAllowed, "存储策略发生变化，请刷新文件列表并重新添加此任务", nil)
+	}
+
+	// 解析相对路径
+	dirs := make([]string, len(service.Files))
+	names := make([]string, len(service.Files))
+	for i, file := range service.Files {
+		dirs[i], names[i], err = filesystem.SplitRelativePath(file.RelativePath)
+		if err != nil {
+			return serializer.Err(serializer.CodeIllegalObjectName, file.RelativePath, err)
+		}
+	}
+
+	// 创建中间目录
+	if err := fs.CreateDirectories(ctx, service.Path, dirs); err != nil {
+		return serializer.Err(serializer.CodeCreateFolderFailed, err.Error(), err)
+	}
+
+	ctx = context.WithValue(ctx, fsctx.ConflictStrategyCtx, fsctx.ConflictStrategy(service.Conflict))
+	credentials := make([]serializer.FolderUploadCredential, len(service.Files))
+	for i, file := range service.Files {
+		fs.CleanHooks("")
+		fileData := &fsctx.FileStream{
+			Size:        file.Size,
+			Name:        names[i],
+			VirtualPath: path.Join(service.Path, dirs[i]),
+			File:        ioutil.NopCloser(strings.NewReader("")),
+			MimeType:    file.MimeType,
+			Hash:        strings.ToLower(file.Hash),
+			MD5:         strings.ToLower(file.MD5),
+		}
+		if file.LastModified > 0 {
+			lastModified := time.UnixMilli(file.LastModified)
+			fileData.LastModified = &lastModified
+		}
+
+		credentials[i].RelativePath = file.RelativePath
+		if err := fs.SwitchPolicyByPath(fileData.VirtualPath); err != nil {
+			res := serializer.Err(serializer.CodePolicyNotExist, "", err)
+			credentials[i].Code, credentials[i].Msg = res.Code, res.Msg
+			continue
+		}
+
+		credential, err := fs.CreateUploadSession(ctx, fileData)
+		if err != nil {
+			res := serializer.Err(serializer.CodeNotSet, err.Error(), err)
+			credentials[i].Code, credentials[i].Msg = res.Code, res.Msg
+			continue
+		}
+
+		credentials[i].Credential = credential
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: credentials,
+	}
+}
+
 // UploadService 本机及从机策略上传服务
 type UploadService struct {
 	ID    string `uri:"sessionId" binding:"required"`
@@ -100,15 +226,16 @@ func (service *UploadService) LocalUpload(ctx context.Context, c *gin.Context) s
 	}
 
 	// 重设 fs 存储策略
-	if !uploadSession.Policy.IsTransitUpload(uploadSession.Size) {
-		return serializer.Err(serializer.CodePolicyNotAllowed, "", err)
-	}
-
 	fs.Policy = &uploadSession.Policy
 	if err := fs.DispatchHandler(); err != nil {
 		return serializer.Err(serializer.CodePolicyNotExist, "", err)
 	}
 
+	// 可直传至存储端的策略不接受中转上传
+	if fs.Handler.Capabilities().PresignedUpload {
+		return serializer.Err(serializer.CodePolicyNotAllowed, "", nil)
+	}
+
 	expectedSizeStart := file.Size
 	actualSizeStart := uint64(service.Index) * uploadSession.Policy.OptionsSerialized.ChunkSize
 	if uploadSession.Policy.OptionsSerialized.ChunkSize == 0 && service.Index > 0 {
@@ -140,7 +267,14 @@ func (service *UploadService) SlaveUpload(ctx context.Context, c *gin.Context) s
 		return serializer.Err(serializer.CodeCreateFSError, "", err)
 	}
 
-	fs.Handler = local.Driver{}
+	// 使用主机端存储策略的加密设置
+	if fs.Handler, err = filesystem.NewLocalHandler(&uploadSession.Policy); err != nil {
+		return serializer.Err(serializer.CodePolicyNotExist, "", err)
+	}
+
+	// 使用主机端用户组的上传限速
+	fs.User.ID = uploadSession.UID
+	fs.User.Group.OptionsSerialized.UploadSpeedLimit = uploadSession.SpeedLimit
 
 	// 解析需要的参数
 	service.Index, _ = strconv.Atoi(c.Query("chunk"))
@@ -149,6 +283,16 @@ func (service *UploadService) SlaveUpload(ctx context.Context, c *gin.Context) s
 		mode |= fsctx.Overwrite
 	}
 
+	// 主机中转的 tus 上传按偏移量写入
+	if rawOffset := c.Query("offset"); rawOffset != "" {
+		offset, err := strconv.ParseUint(rawOffset, 10, 64)
+		if err != nil {
+			return serializer.ParamErr("Invalid offset", err)
+		}
+
+		return processSlaveOffsetUpload(ctx, c, fs, &uploadSession, offset, mode)
+	}
+
 	return processChunkUpload(ctx, c, fs, &uploadSession, service.Index, nil, mode)
 }
 
@@ -176,16 +320,17 @@ func processChunkUpload(ctx context.Context, c *gin.Context, fs *filesystem.File
 	}
 
 	fileData := fsctx.FileStream{
-		MimeType:     c.Request.Header.Get("Content-Type"),
-		File:         c.Request.Body,
-		Size:         fileSize,
-		Name:         session.Name,
-		VirtualPath:  session.VirtualPath,
-		SavePath:     session.SavePath,
-		Mode:         mode,
-		AppendStart:  chunkSize * uint64(index),
-		Model:        file,
-		LastModified: session.LastModified,
+		MimeType:        c.Request.Header.Get("Content-Type"),
+		File:            c.Request.Body,
+		Size:            fileSize,
+		Name:            session.Name,
+		VirtualPath:     session.VirtualPath,
+		SavePath:        session.SavePath,
+		Mode:            mode,
+		AppendStart:     chunkSize * uint64(index),
+		UploadSessionID: &session.Key,
+		Model:           file,
+		LastModified:    session.LastModified,
 	}
 
 	// 给文件系统分配钩子
@@ -193,15 +338,21 @@ func processChunkUpload(ctx context.Context, c *gin.Context, fs *filesystem.File
 	fs.Use("AfterValidateFailed", filesystem.HookTruncateFileTo(fileData.AppendStart))
 
 	if file != nil {
-		fs.Use("BeforeUpload", filesystem.HookValidateCapacity)
+		fs.Use("BeforeUpload", filesystem.HookReserveCapacity)
 		fs.Use("AfterUpload", filesystem.HookChunkUploaded)
+		fs.Use("AfterUpload", filesystem.HookConfirmCapacity)
 		fs.Use("AfterValidateFailed", filesystem.HookChunkUploadFailed)
 		if isLastChunk {
-			fs.Use("AfterUpload", filesystem.HookPopPlaceholderToFile(""))
+			fs.Use("AfterUpload", filesystem.HookCompleteUpload)
+			fs.Use("AfterUpload", filesystem.HookVerifyChecksum(session.MD5, session.Hash))
+			fs.UseAfterUploadHooks(session.CameraUpload,
+				filesystem.HookReplaceConflictedFile(session), filesystem.HookPopPlaceholderToFile(""))
 			fs.Use("AfterUpload", filesystem.HookDeleteUploadSession(session.Key))
+			fs.Use("AfterUpload", filesystem.HookReleaseCapacity)
 		}
 	} else {
 		if isLastChunk {
+			fs.Use("AfterUpload", filesystem.HookCompleteUpload)
 			fs.Use("AfterUpload", filesystem.SlaveAfterUpload(session))
 			fs.Use("AfterUpload", filesystem.HookDeleteUploadSession(session.Key))
 		}
@@ -211,17 +362,111 @@ func processChunkUpload(ctx context.Context, c *gin.Context, fs *filesystem.File
 	uploadCtx := context.WithValue(ctx, fsctx.GinCtx, c)
 	err = fs.Upload(uploadCtx, &fileData)
 	if err != nil {
+		if file != nil && isLastChunk {
+			abortRejectedUpload(uploadCtx, fs, file, err)
+		}
 		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
 	}
 
 	return serializer.Response{}
 }
 
+// abortRejectedUpload 文件内容被拒绝时无法通过续传完成上传，删除占位文件、已上传的数据及上传会话
+func abortRejectedUpload(ctx context.Context, fs *filesystem.FileSystem, file *model.File, err error) {
+	if !filesystem.IsUploadRejected(err) {
+		return
+	}
+
+	if err := fs.Delete(ctx, []uint{}, []uint{file.ID}, false, false); err != nil {
+		util.Log().Warning("Failed to delete rejected upload %q: %s", file.Name, err)
+	}
+}
+
 // UploadSessionService 上传会话服务
 type UploadSessionService struct {
 	ID string `uri:"sessionId" binding:"required"`
 }
 
+// Progress 获取指定上传会话的续传进度
+func (service *UploadSessionService) Progress(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	progress, err := fs.GetUploadProgress(ctx, service.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: progress,
+	}
+}
+
+// SubscribeProgress 通过 WebSocket 推送上传会话在服务端的写入进度
+func (service *UploadSessionService) SubscribeProgress(ctx context.Context, c *gin.Context) serializer.Response {
+	uploadSessionRaw, ok := cache.Get(filesystem.UploadSessionCachePrefix + service.ID)
+	if !ok {
+		return serializer.Err(serializer.CodeUploadSessionExpired, "", nil)
+	}
+
+	uploadSession := uploadSessionRaw.(serializer.UploadSession)
+	userCtx, _ := c.Get("user")
+	if uploadSession.UID != userCtx.(*model.User).ID {
+		return serializer.Err(serializer.CodeUploadSessionExpired, "", nil)
+	}
+
+	conn, err := progressUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		util.Log().Debug("Failed to upgrade upload progress connection: %s", err)
+		return serializer.Response{}
+	}
+	defer conn.Close()
+
+	topic := filesystem.UploadProgressTopicPrefix + service.ID
+	events := mq.GlobalMQ.Subscribe(topic, 1)
+	defer mq.GlobalMQ.Unsubscribe(topic, events)
+
+	// 客户端断开连接时结束推送
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	expired := time.After(time.Until(time.Unix(uploadSession.Expires, 0)))
+	for {
+		select {
+		case msg := <-events:
+			event, ok := msg.Content.(serializer.UploadProgressEvent)
+			if !ok {
+				continue
+			}
+
+			if err := conn.WriteJSON(event); err != nil {
+				return serializer.Response{}
+			}
+
+			if event.Written >= event.Total {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return serializer.Response{}
+			}
+		case <-closed:
+			return serializer.Response{}
+		case <-expired:
+			return serializer.Response{}
+		}
+	}
+}
+
 // Delete 删除指定上传会话
 func (service *UploadSessionService) Delete(ctx context.Context, c *gin.Context) serializer.Response {
 	// 创建文件系统
@@ -267,6 +512,21 @@ func (service *UploadSessionService) SlaveDelete(ctx context.Context, c *gin.Con
 	return serializer.Response{}
 }
 
+// ListUploadSessions 列出当前用户进行中的上传会话
+func ListUploadSessions(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	return serializer.Response{
+		Code: 0,
+		Data: fs.ListUploadSessions(),
+	}
+}
+
 // DeleteAllUploadSession 删除当前用户的全部上传绘会话
 func DeleteAllUploadSession(ctx context.Context, c *gin.Context) serializer.Response {
 	// 创建文件系统
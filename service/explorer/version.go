@@ -0,0 +1,95 @@
+package explorer
+
+import (
+	"context"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// FileVersionService 文件历史版本服务，Version 为历史版本的 HashID
+type FileVersionService struct {
+	Version string `uri:"version" binding:"required"`
+}
+
+// ListVersions 列出文件的历史版本
+func (service *FileIDService) ListVersions(c *gin.Context, user *model.User) serializer.Response {
+	objectID, _ := c.Get("object_id")
+	files, err := model.GetFilesByIDs([]uint{objectID.(uint)}, user.ID)
+	if err != nil || len(files) == 0 {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	versions, err := model.GetVersionsByFileID(files[0].ID, user.ID)
+	if err != nil {
+		return serializer.DBErr("Failed to list file versions", err)
+	}
+
+	return serializer.BuildFileVersionList(versions)
+}
+
+// get 获取当前文件及指定的历史版本
+func (service *FileVersionService) get(fs *filesystem.FileSystem, c *gin.Context) (*model.File, *model.FileVersion, error) {
+	versionID, err := hashid.DecodeHashID(service.Version, hashid.VersionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	objectID, _ := c.Get("object_id")
+	files, err := model.GetFilesByIDs([]uint{objectID.(uint)}, fs.User.ID)
+	if err != nil || len(files) == 0 {
+		return nil, nil, filesystem.ErrObjectNotExist.WithError(err)
+	}
+
+	version, err := model.GetVersionByID(versionID, files[0].ID, fs.User.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &files[0], version, nil
+}
+
+// Restore 将文件内容恢复为指定的历史版本
+func (service *FileVersionService) Restore(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	file, version, err := service.get(fs, c)
+	if err != nil {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	if err := fs.RestoreVersion(ctx, file, version); err != nil {
+		return serializer.Err(serializer.CodeNotSet, "Failed to restore file version", err)
+	}
+
+	return serializer.Response{}
+}
+
+// Delete 删除指定的历史版本
+func (service *FileVersionService) Delete(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	_, version, err := service.get(fs, c)
+	if err != nil {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	if err := fs.DeleteVersions(ctx, []model.FileVersion{*version}); err != nil {
+		return serializer.Err(serializer.CodeNotSet, "Failed to delete file version", err)
+	}
+
+	return serializer.Response{}
+}
@@ -1,6 +1,7 @@
 package explorer
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/cloudreve/Cloudreve/v3/middleware"
@@ -11,6 +12,7 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/wopi"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -50,10 +52,32 @@ func (service *WopiService) GetFile(c *gin.Context) error {
 	defer resp.Content.Close()
 
 	c.Header("Cache-Control", "no-cache")
+	fs.WithDownloadSpeedLimit(c)
 	http.ServeContent(c.Writer, c.Request, fs.FileTarget[0].Name, fs.FileTarget[0].UpdatedAt, resp.Content)
 	return nil
 }
 
+// PutFile 保存编辑器提交的文件内容，成功后返回新的文件版本
+func (service *WopiService) PutFile(ctx context.Context, c *gin.Context) serializer.Response {
+	size, err := strconv.ParseUint(c.Request.Header.Get("Content-Length"), 10, 64)
+	if err == nil && WopiSizeExceeded(size) {
+		return serializer.Err(serializer.CodeFileTooLarge, "", nil)
+	}
+
+	res := (&FileIDService{}).PutContent(ctx, c)
+	if res.Code != 0 {
+		return res
+	}
+
+	session := c.MustGet(middleware.WopiSessionCtx).(*wopi.SessionCache)
+	files, err := model.GetFilesByIDs([]uint{session.FileID}, session.UserID)
+	if err != nil || len(files) == 0 {
+		return serializer.Err(serializer.CodeNotFound, "", err)
+	}
+
+	return serializer.Response{Data: files[0].Model.UpdatedAt.String()}
+}
+
 func (service *WopiService) FileInfo(c *gin.Context) (*serializer.WopiFileInfo, error) {
 	fs, session, err := service.prepareFs(c)
 	if err != nil {
@@ -82,19 +106,20 @@ func (service *WopiService) FileInfo(c *gin.Context) (*serializer.WopiFileInfo,
 	parentUrl.RawQuery = query.Encode()
 
 	info := &serializer.WopiFileInfo{
-		BaseFileName:           fs.FileTarget[0].Name,
-		Version:                fs.FileTarget[0].Model.UpdatedAt.String(),
-		BreadcrumbBrandName:    model.GetSettingByName("siteName"),
-		BreadcrumbBrandUrl:     siteUrl.String(),
-		FileSharingPostMessage: false,
-		PostMessageOrigin:      "*",
-		FileNameMaxLength:      256,
-		LastModifiedTime:       fs.FileTarget[0].Model.UpdatedAt.Format(time.RFC3339),
-		IsAnonymousUser:        true,
-		ReadOnly:               true,
-		ClosePostMessage:       true,
-		Size:                   int64(fs.FileTarget[0].Size),
-		OwnerId:                hashid.HashID(fs.FileTarget[0].UserID, hashid.UserID),
+		BaseFileName:            fs.FileTarget[0].Name,
+		Version:                 fs.FileTarget[0].Model.UpdatedAt.String(),
+		BreadcrumbBrandName:     model.GetSettingByName("siteName"),
+		BreadcrumbBrandUrl:      siteUrl.String(),
+		FileSharingPostMessage:  false,
+		PostMessageOrigin:       "*",
+		FileNameMaxLength:       256,
+		LastModifiedTime:        fs.FileTarget[0].Model.UpdatedAt.Format(time.RFC3339),
+		IsAnonymousUser:         true,
+		ReadOnly:                true,
+		ClosePostMessage:        true,
+		UserCanNotWriteRelative: true,
+		Size:                    int64(fs.FileTarget[0].Size),
+		OwnerId:                 hashid.HashID(fs.FileTarget[0].UserID, hashid.UserID),
 	}
 
 	if session.Action == wopi.ActionEdit {
@@ -116,6 +141,49 @@ func (service *WopiService) FileInfo(c *gin.Context) (*serializer.WopiFileInfo,
 	return info, nil
 }
 
+// wopiLockTTL WOPI 协议规定的锁有效期，单位秒
+const wopiLockTTL = 1800
+
+// Lock 处理 WOPI 的加锁、刷新锁、解锁、获取锁请求，锁冲突时返回当前的锁及 ErrFileLocked
+func (service *WopiService) Lock(c *gin.Context, method string) (*filesystem.FileLock, error) {
+	session := c.MustGet(middleware.WopiSessionCtx).(*wopi.SessionCache)
+	token := c.GetHeader(wopi.LockHeader)
+	current, locked := filesystem.GetFileLock(session.FileID)
+	newLock := filesystem.FileLock{Token: token, OwnerID: session.UserID, App: filesystem.LockAppWOPI}
+
+	switch method {
+	case wopi.MethodGetLock:
+		return current, nil
+	case wopi.MethodLock:
+		// 携带 X-WOPI-OldLock 时为解锁后以新的令牌重新加锁
+		if oldToken := c.GetHeader(wopi.OldLockHeader); oldToken != "" {
+			if !locked || current.Token != oldToken {
+				return current, filesystem.ErrFileLocked
+			}
+			return filesystem.LockFile(session.FileID, newLock, wopiLockTTL, true)
+		}
+		return filesystem.LockFile(session.FileID, newLock, wopiLockTTL, false)
+	case wopi.MethodRefreshLock:
+		if !locked || current.Token != token {
+			return current, filesystem.ErrFileLocked
+		}
+		return filesystem.LockFile(session.FileID, newLock, wopiLockTTL, false)
+	case wopi.MethodUnlock:
+		if !locked || current.Token != token {
+			return current, filesystem.ErrFileLocked
+		}
+		return nil, filesystem.UnlockFile(session.FileID, token, false)
+	default:
+		return nil, fmt.Errorf("unknown lock method %q", method)
+	}
+}
+
+// WopiSizeExceeded 返回文件大小是否超出 WOPI 在线编辑的限制
+func WopiSizeExceeded(size uint64) bool {
+	maxSize := model.GetIntSetting("wopi_max_size", 0)
+	return maxSize > 0 && size > uint64(maxSize)
+}
+
 func (service *WopiService) prepareFs(c *gin.Context) (*filesystem.FileSystem, *wopi.SessionCache, error) {
 	// 创建文件系统
 	fs, err := filesystem.NewFileSystemFromContext(c)
@@ -129,8 +197,8 @@ func (service *WopiService) prepareFs(c *gin.Context) (*filesystem.FileSystem, *
 		return nil, nil, fmt.Errorf("failed to find file: %w", err)
 	}
 
-	maxSize := model.GetIntSetting("maxEditSize", 0)
-	if maxSize > 0 && fs.FileTarget[0].Size > uint64(maxSize) {
+	if WopiSizeExceeded(fs.FileTarget[0].Size) {
+		fs.Recycle()
 		return nil, nil, errors.New("file too large")
 	}
 
@@ -5,6 +5,7 @@ import (
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cluster"
 	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/dropbox"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/googledrive"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/onedrive"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/oauth"
@@ -64,6 +65,11 @@ func (s *OauthCredentialService) Get(c *gin.Context) serializer.Response {
 		if err != nil {
 			return serializer.Err(serializer.CodeInternalSetting, "Cannot initialize Google Drive client", err)
 		}
+	case "dropbox":
+		client, err = dropbox.NewClient(&policy)
+		if err != nil {
+			return serializer.Err(serializer.CodeInternalSetting, "Cannot initialize Dropbox client", err)
+		}
 	default:
 		return serializer.Err(serializer.CodePolicyNotExist, "", nil)
 	}
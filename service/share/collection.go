@@ -0,0 +1,112 @@
+package share
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+)
+
+// collectionPath 合集分享中路径的解析结果，合集以虚拟目录呈现，其下为各源目录与源文件
+type collectionPath struct {
+	// Root 路径所在的源目录，以 "/源目录名" 命名，使其下对象的路径以合集的虚拟根目录为起点
+	Root *model.Folder
+	// Path 路径在源目录中的部分
+	Path string
+	// File 路径指向的源文件
+	File *model.File
+}
+
+// IsRoot 返回路径是否为合集的虚拟根目录
+func (p *collectionPath) IsRoot() bool {
+	return p.Root == nil && p.File == nil
+}
+
+// resolveCollectionPath 解析合集分享中的路径 p，首段路径为合集中源目录或源文件的名称
+func resolveCollectionPath(share *model.Share, p string) (*collectionPath, error) {
+	if !path.IsAbs(p) {
+		return nil, filesystem.ErrPathNotExist
+	}
+
+	segments := strings.SplitN(strings.Trim(path.Clean(p), "/"), "/", 2)
+	if segments[0] == "" {
+		return &collectionPath{}, nil
+	}
+
+	folders, files := share.CollectionSources()
+	for i := range folders {
+		if folders[i].Name != segments[0] {
+			continue
+		}
+
+		root := folders[i]
+		root.Name = "/" + root.Name
+		root.Position = ""
+		target := &collectionPath{Root: &root, Path: "/"}
+		if len(segments) > 1 {
+			target.Path += segments[1]
+		}
+		return target, nil
+	}
+
+	if len(segments) == 1 {
+		for i := range files {
+			if files[i].Name == segments[0] {
+				return &collectionPath{File: &files[i]}, nil
+			}
+		}
+	}
+
+	return nil, filesystem.ErrObjectNotExist
+}
+
+// collectionContains 返回合集分享是否直接包含全部以 HashID 表示的目录 dirs 与文件 files
+func collectionContains(share *model.Share, dirs, files []string) bool {
+	folderModels, fileModels := share.CollectionSources()
+	sources := make(map[string]bool, len(folderModels)+len(fileModels))
+	for _, folder := range folderModels {
+		sources[hashid.HashID(folder.ID, hashid.FolderID)] = true
+	}
+	for _, file := range fileModels {
+		sources[hashid.HashID(file.ID, hashid.FileID)] = true
+	}
+
+	for _, id := range append(append([]string{}, dirs...), files...) {
+		if !sources[id] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// withShareSource 在上下文中设置路径 p 处的分享文件，或设置其所在的分享目录及在目录中的路径，供下层 service 使用
+func withShareSource(ctx context.Context, share *model.Share, p string) (context.Context, error) {
+	if !share.Collection {
+		if share.IsDir {
+			ctx = context.WithValue(ctx, fsctx.FolderModelCtx, share.Source())
+			return context.WithValue(ctx, fsctx.PathCtx, p), nil
+		}
+		return context.WithValue(ctx, fsctx.FileModelCtx, share.Source()), nil
+	}
+
+	target, err := resolveCollectionPath(share, p)
+	if err != nil {
+		return ctx, err
+	}
+
+	if target.File != nil {
+		return context.WithValue(ctx, fsctx.FileModelCtx, target.File), nil
+	}
+
+	if target.IsRoot() {
+		return ctx, filesystem.ErrObjectNotExist
+	}
+
+	ctx = context.WithValue(ctx, fsctx.FolderModelCtx, target.Root)
+	return context.WithValue(ctx, fsctx.PathCtx, target.Path), nil
+}
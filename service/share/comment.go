@@ -0,0 +1,100 @@
+package share
+
+import (
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/service/explorer"
+	"github.com/gin-gonic/gin"
+)
+
+// CommentListService 列出分享文件评论服务，path 为目录分享下文件的完整路径
+type CommentListService struct {
+	Path string `form:"path" binding:"max=65535"`
+}
+
+// CommentCreateService 在分享的文件上发表评论服务
+type CommentCreateService struct {
+	Path string `json:"path" binding:"max=65535"`
+	explorer.CommentCreateService
+}
+
+// List 列出经由此分享发表在文件上的评论
+func (service *CommentListService) List(c *gin.Context) serializer.Response {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+
+	file, err := shareTargetFile(share, service.Path)
+	if err != nil {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	comments, err := model.GetShareComments(file.ID, share.ID)
+	if err != nil {
+		return serializer.DBErr("Failed to list comments", err)
+	}
+
+	return serializer.BuildCommentList(comments)
+}
+
+// Create 在分享的文件上发表评论，评论对此分享的访客可见
+func (service *CommentCreateService) Create(c *gin.Context) serializer.Response {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+	userCtx, _ := c.Get("user")
+	user := userCtx.(*model.User)
+
+	if user.IsAnonymous() {
+		return serializer.Err(serializer.CodeCheckLogin, "Login required", nil)
+	}
+
+	file, err := shareTargetFile(share, service.Path)
+	if err != nil {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	return service.Post(file, user, share.ID)
+}
+
+// shareTargetFile 返回分享的文件，目录分享时返回目录下 path 处的文件
+func shareTargetFile(share *model.Share, path string) (*model.File, error) {
+	if !share.IsDir {
+		file := share.SourceFile()
+		if file.ID == 0 {
+			return nil, filesystem.ErrObjectNotExist
+		}
+		return file, nil
+	}
+
+	fs, err := filesystem.NewFileSystem(share.Creator())
+	if err != nil {
+		return nil, err
+	}
+	defer fs.Recycle()
+
+	if share.Collection {
+		target, err := resolveCollectionPath(share, path)
+		if err != nil || target.IsRoot() {
+			return nil, filesystem.ErrObjectNotExist
+		}
+
+		if target.File != nil {
+			return target.File, nil
+		}
+
+		fs.Root = target.Root
+		path = target.Path
+	} else {
+		// 重设根目录
+		root := *share.SourceFolder()
+		root.Name = "/"
+		fs.Root = &root
+	}
+
+	exist, file := fs.IsFileExist(path)
+	if !exist {
+		return nil, filesystem.ErrObjectNotExist
+	}
+
+	return file, nil
+}
@@ -0,0 +1,176 @@
+package share
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"net/url"
+	"path"
+	"strings"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// EmbedService 嵌入其他站点的分享播放页服务
+type EmbedService struct {
+}
+
+// OEmbedService 获取分享 oEmbed 嵌入信息服务
+type OEmbedService struct {
+	URL       string `form:"url" binding:"required,max=2048"`
+	MaxWidth  int    `form:"maxwidth" binding:"min=0"`
+	MaxHeight int    `form:"maxheight" binding:"min=0"`
+	Format    string `form:"format" binding:"omitempty,eq=json"`
+}
+
+// embedMediaTypes 嵌入页中可直接由浏览器播放的文件类型
+var embedMediaTypes = map[string]string{
+	"jpg": "image", "jpeg": "image", "png": "image", "gif": "image", "webp": "image", "svg": "image", "bmp": "image",
+	"mp4": "video", "webm": "video", "ogv": "video", "mov": "video", "m4v": "video",
+	"mp3": "audio", "ogg": "audio", "wav": "audio", "flac": "audio", "m4a": "audio", "aac": "audio",
+}
+
+// embedSizes 各类型嵌入页的默认尺寸
+var embedSizes = map[string][2]int{
+	"image": {640, 480},
+	"video": {640, 360},
+	"audio": {480, 96},
+	"file":  {480, 96},
+}
+
+// embedPage 嵌入页模板，页面不包含脚本，媒体地址为带签名的临时地址
+var embedPage = template.Must(template.New("embed").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Name}}</title>
+<style>
+html,body{margin:0;height:100%;background:#000;color:#fff;font-family:sans-serif;overflow:hidden}
+body{display:flex;align-items:center;justify-content:center;flex-direction:column}
+img,video{max-width:100%;max-height:100%}
+audio{width:100%}
+p{margin:8px 12px;font-size:14px;white-space:nowrap;overflow:hidden;text-overflow:ellipsis;max-width:calc(100% - 24px)}
+a{color:#fff}
+</style>
+</head>
+<body>
+{{- if eq .Type "image"}}
+<img src="{{.Src}}" alt="{{.Name}}">
+{{- else if eq .Type "video"}}
+<video src="{{.Src}}" controls playsinline preload="metadata"></video>
+{{- else if eq .Type "audio"}}
+<p>{{.Name}}</p>
+<audio src="{{.Src}}" controls preload="metadata"></audio>
+{{- else}}
+<p><a href="{{.Link}}" target="_blank" rel="noopener">{{.Name}}</a></p>
+{{- end}}
+</body>
+</html>
+`))
+
+// embedMediaType 返回文件名为 name 的文件在嵌入页中的呈现类型
+func embedMediaType(name string) string {
+	if t, ok := embedMediaTypes[strings.TrimPrefix(strings.ToLower(path.Ext(name)), ".")]; ok {
+		return t
+	}
+	return "file"
+}
+
+// embedURL 返回分享嵌入页的地址
+func embedURL(key string) string {
+	embedPath, _ := url.Parse("/api/v3/share/embed/" + key)
+	return model.GetSiteURL().ResolveReference(embedPath).String()
+}
+
+// Page 输出分享的嵌入页，页面只允许被站点自身及分享设置的域名嵌入
+func (service *EmbedService) Page(c *gin.Context) serializer.Response {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+	userCtx, _ := c.Get("user")
+
+	file := share.SourceFile()
+	if file.ID == 0 {
+		return serializer.Err(serializer.CodeFileNotFound, "", nil)
+	}
+
+	// 媒体地址需携带签名，且只接受来自此嵌入页的请求，见 middleware.ShareEmbedReferer
+	src, err := auth.SignURI(auth.General, fmt.Sprintf("/api/v3/share/embed/%s/content", c.Param("id")),
+		int64(model.GetIntSetting("share_embed_timeout", 3600)))
+	if err != nil {
+		return serializer.Err(serializer.CodeEncryptError, "Failed to sign content URL", err)
+	}
+
+	var page bytes.Buffer
+	if err := embedPage.Execute(&page, map[string]string{
+		"Name": file.Name,
+		"Type": embedMediaType(file.Name),
+		"Src":  src.String(),
+		"Link": shareLink(share.Key()),
+	}); err != nil {
+		return serializer.Err(serializer.CodeInternalSetting, "Failed to render embed page", err)
+	}
+
+	share.ViewBy(userCtx.(*model.User), c)
+	recordAccess(c, share, model.ShareAccessView, "")
+
+	c.Header("Content-Security-Policy", fmt.Sprintf("default-src 'none'; img-src * data:; media-src *; "+
+		"style-src 'unsafe-inline'; frame-ancestors %s", share.FrameAncestors()))
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "same-origin")
+	c.Data(200, "text/html; charset=utf-8", page.Bytes())
+	return serializer.Response{}
+}
+
+// OEmbed 返回分享链接 url 的 oEmbed 嵌入信息，只支持本站可嵌入的分享
+func (service *OEmbedService) OEmbed(c *gin.Context) serializer.Response {
+	siteURL := model.GetSiteURL()
+	target, err := url.Parse(service.URL)
+	if err != nil || !strings.EqualFold(target.Host, siteURL.Host) || path.Base(path.Dir(target.Path)) != "s" {
+		return serializer.Err(serializer.CodeNotFound, "Not a share link of this site", err)
+	}
+
+	share := model.GetShareByHashID(path.Base(target.Path))
+	if share == nil || !share.IsAvailable() || !share.IsActivated() || !share.IsEmbeddable() {
+		return serializer.Err(serializer.CodeNotFound, "Share not exist or cannot be embedded", nil)
+	}
+
+	name := share.SourceFile().Name
+	mediaType := embedMediaType(name)
+	width, height := fitEmbedSize(embedSizes[mediaType], service.MaxWidth, service.MaxHeight)
+
+	oembedType := "rich"
+	if mediaType == "video" {
+		oembedType = "video"
+	}
+
+	return serializer.Response{Data: serializer.OEmbed{
+		Type:         oembedType,
+		Version:      "1.0",
+		Title:        name,
+		ProviderName: model.GetSettingByName("siteName"),
+		ProviderURL:  siteURL.String(),
+		HTML: fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" frameborder="0" allow="autoplay; fullscreen" allowfullscreen></iframe>`,
+			html.EscapeString(embedURL(share.Key())), width, height),
+		Width:  width,
+		Height: height,
+	}}
+}
+
+// fitEmbedSize 按比例缩小尺寸 size，使其不超过 maxWidth、maxHeight，值为 0 时不限制
+func fitEmbedSize(size [2]int, maxWidth, maxHeight int) (int, int) {
+	width, height := size[0], size[1]
+	if maxWidth > 0 && width > maxWidth {
+		height = height * maxWidth / width
+		width = maxWidth
+	}
+	if maxHeight > 0 && height > maxHeight {
+		width = width * maxHeight / height
+		height = maxHeight
+	}
+	return width, height
+}
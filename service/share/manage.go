@@ -1,31 +1,88 @@
 package share
 
 import (
+	"context"
 	"net/url"
+	"strconv"
 	"time"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/sharelink"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/gin-gonic/gin"
 )
 
 // ShareCreateService 创建新分享服务
 type ShareCreateService struct {
-	SourceID        string `json:"id" binding:"required"`
-	IsDir           bool   `json:"is_dir"`
-	Password        string `json:"password" binding:"max=255"`
-	RemainDownloads int    `json:"downloads"`
-	Expire          int    `json:"expire"`
-	Preview         bool   `json:"preview"`
+	SourceID        string   `json:"id" binding:"required_without_all=Dirs Items"`
+	IsDir           bool     `json:"is_dir"`
+	Dirs            []string `json:"dirs" binding:"max=100"`
+	Items           []string `json:"items" binding:"max=100"`
+	Name            string   `json:"name" binding:"max=255"`
+	Password        string   `json:"password" binding:"max=255"`
+	RemainDownloads int      `json:"downloads"`
+	RemainViews     int      `json:"views"`
+	Expire          int      `json:"expire"`
+	Activate        int      `json:"activate" binding:"min=0"`
+	Preview         bool     `json:"preview"`
+	Upload          bool     `json:"upload"`
+	Permissions     int      `json:"permissions" binding:"min=0,max=15"`
+	Slug            string   `json:"slug" binding:"max=64"`
+	Internal        bool     `json:"internal"`
+	ShortLink       bool     `json:"short_link"`
+	QRCode          bool     `json:"qrcode"`
+	Watermark       string   `json:"watermark" binding:"max=255"`
+	Notify          int      `json:"notify" binding:"min=0,max=3"`
+	Embed           string   `json:"embed" binding:"max=1024"`
+}
+
+// ShareQRCodeService 获取分享链接二维码服务
+type ShareQRCodeService struct {
+	Size  int  `form:"size" binding:"min=0,max=1024"`
+	Short bool `form:"short"`
 }
 
 // ShareUpdateService 分享更新服务
 type ShareUpdateService struct {
-	Prop  string `json:"prop" binding:"required,eq=password|eq=preview_enabled"`
+	Prop  string `json:"prop" binding:"required,eq=password|eq=preview_enabled|eq=allow_upload|eq=permissions|eq=slug|eq=activate|eq=watermark|eq=notify|eq=embed"`
 	Value string `json:"value" binding:"max=255"`
 }
 
+// ShareStatsService 分享访问统计服务
+type ShareStatsService struct {
+	Days int `form:"days" binding:"min=0,max=90"`
+}
+
+// ShareMountService 挂载分享服务
+type ShareMountService struct {
+	Password string `json:"password" binding:"max=255"`
+	Path     string `json:"path" binding:"required,min=1,max=65535"`
+	Name     string `json:"name" binding:"required,min=1,max=255"`
+}
+
+// Mount 将其他用户分享的目录挂载至自己的目录下
+func (service *ShareMountService) Mount(c *gin.Context, user *model.User) serializer.Response {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystem(user)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	mount, err := fs.MountShare(c, share, service.Password, service.Path, service.Name)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{Data: hashid.HashID(mount.ID, hashid.FolderID)}
+}
+
 // Delete 删除分享
 func (service *Service) Delete(c *gin.Context, user *model.User) serializer.Response {
 	share := model.GetShareByHashID(c.Param("id"))
@@ -40,6 +97,28 @@ func (service *Service) Delete(c *gin.Context, user *model.User) serializer.Resp
 	return serializer.Response{}
 }
 
+// Stats 按天统计最近 Days 天内分享的访问情况，已失效的分享仍可查看
+func (service *ShareStatsService) Stats(c *gin.Context, user *model.User) serializer.Response {
+	share := model.GetShareByHashID(c.Param("id"))
+	if share == nil || share.Creator().ID != user.ID {
+		return serializer.Err(serializer.CodeShareLinkNotFound, "", nil)
+	}
+
+	days := service.Days
+	if days == 0 {
+		days = 30
+	}
+
+	now := time.Now()
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1-days)
+	accesses, err := model.ListShareAccesses(share.ID, since)
+	if err != nil {
+		return serializer.DBErr("Failed to list share access records", err)
+	}
+
+	return serializer.Response{Data: serializer.BuildShareStats(accesses, since, days)}
+}
+
 // Update 更新分享属性
 func (service *ShareUpdateService) Update(c *gin.Context) serializer.Response {
 	shareCtx, _ := c.Get("share")
@@ -53,19 +132,208 @@ func (service *ShareUpdateService) Update(c *gin.Context) serializer.Response {
 		}
 	case "preview_enabled":
 		value := service.Value == "true"
-		err := share.Update(map[string]interface{}{"preview_enabled": value})
-		if err != nil {
+		// 撤销预览时一并撤销编辑
+		permission := togglePermission(share.Permission(), model.SharePermissionView|model.SharePermissionEdit, false)
+		if value {
+			permission |= model.SharePermissionView
+		}
+		if res := setPermissions(share, permission); res.Code != 0 {
+			return res
+		}
+		return serializer.Response{
+			Data: value,
+		}
+	case "allow_upload":
+		if !share.IsDir || share.Collection {
+			return serializer.ParamErr("Only shared folders can accept uploads", nil)
+		}
+
+		value := service.Value == "true"
+		if res := setPermissions(share, togglePermission(share.Permission(), model.SharePermissionUpload, value)); res.Code != 0 {
+			return res
+		}
+		return serializer.Response{
+			Data: value,
+		}
+	case "permissions":
+		value, err := strconv.Atoi(service.Value)
+		if err != nil || value&^model.SharePermissionAll != 0 {
+			return serializer.ParamErr("Invalid share permissions", err)
+		}
+
+		if res := setPermissions(share, value); res.Code != 0 {
+			return res
+		}
+		return serializer.Response{
+			Data: share.Permissions,
+		}
+	case "activate":
+		value, err := strconv.Atoi(service.Value)
+		if err != nil || value < 0 {
+			return serializer.ParamErr("Invalid activation delay", err)
+		}
+
+		var activatesAt *time.Time
+		if value > 0 {
+			t := time.Now().Add(time.Duration(value) * time.Second)
+			activatesAt = &t
+		}
+
+		if activatesAt != nil && share.Expires != nil && !activatesAt.Before(*share.Expires) {
+			return serializer.ParamErr("Share would expire before activation", nil)
+		}
+
+		if err := share.Update(map[string]interface{}{"activates_at": activatesAt}); err != nil {
 			return serializer.DBErr("Failed to update share record", err)
 		}
 		return serializer.Response{
 			Data: value,
 		}
+	case "notify":
+		value, err := strconv.Atoi(service.Value)
+		if err != nil || value&^(model.ShareNotifyInApp|model.ShareNotifyEmail) != 0 {
+			return serializer.ParamErr("Invalid notification method", err)
+		}
+
+		if err := share.SetAccessNotify(value); err != nil {
+			return serializer.DBErr("Failed to update share record", err)
+		}
+		return serializer.Response{
+			Data: value,
+		}
+	case "watermark":
+		if err := share.Update(map[string]interface{}{"watermark": service.Value}); err != nil {
+			return serializer.DBErr("Failed to update share record", err)
+		}
+	case "embed":
+		if service.Value != "" && share.IsDir {
+			return serializer.ParamErr("Only shared files can be embedded", nil)
+		}
+
+		domains, err := model.ParseEmbedDomains(service.Value)
+		if err != nil {
+			return serializer.ParamErr(err.Error(), err)
+		}
+
+		if err := share.Update(map[string]interface{}{"embed_domains": domains}); err != nil {
+			return serializer.DBErr("Failed to update share record", err)
+		}
+		return serializer.Response{
+			Data: domains,
+		}
+	case "slug":
+		userCtx, _ := c.Get("user")
+		if res := setSlug(share, userCtx.(*model.User), service.Value); res.Code != 0 {
+			return res
+		}
+		return serializer.Response{
+			Data: share.Key(),
+		}
 	}
 	return serializer.Response{
 		Data: service.Value,
 	}
 }
 
+// togglePermission 授予或撤销 permission 中的 bit 权限
+func togglePermission(permission, bit int, grant bool) int {
+	if grant {
+		return permission | bit
+	}
+	return permission &^ bit
+}
+
+// setPermissions 更新分享的权限，分享至少需授予一项权限
+func setPermissions(share *model.Share, permission int) serializer.Response {
+	if model.NormalizeSharePermission(permission, share.IsDir && !share.Collection) == 0 {
+		return serializer.ParamErr("Share must grant at least one permission", nil)
+	}
+
+	if err := share.SetPermissions(permission); err != nil {
+		return serializer.DBErr("Failed to update share record", err)
+	}
+
+	return serializer.Response{}
+}
+
+// checkSlug 检查用户能否使用自定义链接 slug，slug 为空表示恢复使用随机 key
+func checkSlug(user *model.User, slug string) serializer.Response {
+	if !user.Group.OptionsSerialized.ShareSlug {
+		return serializer.Err(serializer.CodeGroupNotAllowed, "", nil)
+	}
+
+	if slug != "" {
+		if err := model.ValidateShareSlug(slug); err != nil {
+			return serializer.ParamErr(err.Error(), err)
+		}
+	}
+
+	return serializer.Response{}
+}
+
+// setSlug 设置分享的自定义链接
+func setSlug(share *model.Share, user *model.User, slug string) serializer.Response {
+	if res := checkSlug(user, slug); res.Code != 0 {
+		return res
+	}
+
+	if err := share.SetSlug(slug); err != nil {
+		if err == model.ErrShareSlugTaken {
+			return serializer.Err(serializer.CodeShareSlugTaken, "", err)
+		}
+		return serializer.DBErr("Failed to update share record", err)
+	}
+
+	return serializer.Response{}
+}
+
+// shareLink 返回 key 对应的分享链接
+func shareLink(key string) string {
+	sharePath, _ := url.Parse("/s/" + key)
+	return model.GetSiteURL().ResolveReference(sharePath).String()
+}
+
+// shortenShareLink 生成分享的短链接，短链接服务未配置或失败时返回空值，不影响分享本身
+func shortenShareLink(ctx context.Context, shareURL, key string) string {
+	shortener := sharelink.NewShortener()
+	if !shortener.Enabled() {
+		return ""
+	}
+
+	shortURL, err := shortener.Shorten(ctx, shareURL, key)
+	if err != nil {
+		util.Log().Warning("Failed to shorten share link %q: %s", shareURL, err)
+	}
+	return shortURL
+}
+
+// QRCode 返回分享链接的二维码图片，Short 为 true 时二维码内容为短链接
+func (service *ShareQRCodeService) QRCode(c *gin.Context) serializer.Response {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+
+	size := service.Size
+	if size == 0 {
+		size = model.GetIntSetting("share_qrcode_size", 256)
+	}
+
+	content := shareLink(share.Key())
+	if service.Short {
+		if shortURL := shortenShareLink(c, content, share.Key()); shortURL != "" {
+			content = shortURL
+		}
+	}
+
+	img, err := sharelink.QRCode(content, size)
+	if err != nil {
+		return serializer.Err(serializer.CodeInternalSetting, "Failed to generate QR code", err)
+	}
+
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.Data(200, "image/png", img)
+	return serializer.Response{Code: -1}
+}
+
 // Create 创建新分享
 func (service *ShareCreateService) Create(c *gin.Context) serializer.Response {
 	userCtx, _ := c.Get("user")
@@ -76,13 +344,25 @@ func (service *ShareCreateService) Create(c *gin.Context) serializer.Response {
 		return serializer.Err(serializer.CodeGroupNotAllowed, "", nil)
 	}
 
+	// 合集分享包含多个源对象，以虚拟目录呈现
+	collection := len(service.Dirs)+len(service.Items) > 0
+	if collection {
+		service.IsDir = true
+	}
+
 	// 源对象真实ID
 	var (
 		sourceID   uint
 		sourceName string
+		items      []model.ShareItem
 		err        error
 	)
-	if service.IsDir {
+	if collection {
+		var res serializer.Response
+		if items, sourceName, res = service.collectionItems(user); res.Code != 0 {
+			return res
+		}
+	} else if service.IsDir {
 		sourceID, err = hashid.DecodeHashID(service.SourceID, hashid.FolderID)
 	} else {
 		sourceID, err = hashid.DecodeHashID(service.SourceID, hashid.FileID)
@@ -91,16 +371,49 @@ func (service *ShareCreateService) Create(c *gin.Context) serializer.Response {
 		return serializer.Err(serializer.CodeNotFound, "", nil)
 	}
 
+	// 未指定权限时按预览、上传开关推导
+	permission := service.Permissions
+	if permission == 0 {
+		permission = model.SharePermissionDownload
+		if service.Preview {
+			permission |= model.SharePermissionView
+		}
+		if service.Upload {
+			permission |= model.SharePermissionUpload
+		}
+	}
+
+	// 只有目录分享可以接收上传，合集分享不对应实际目录
+	if permission&model.SharePermissionUpload != 0 && (!service.IsDir || collection) {
+		return serializer.ParamErr("Only shared folders can accept uploads", nil)
+	}
+
+	// 自定义链接
+	if service.Slug != "" {
+		if res := checkSlug(user, service.Slug); res.Code != 0 {
+			return res
+		}
+	}
+
+	// 允许嵌入的域名，只有单个文件分享可被嵌入
+	if service.Embed != "" && service.IsDir {
+		return serializer.ParamErr("Only shared files can be embedded", nil)
+	}
+	embedDomains, err := model.ParseEmbedDomains(service.Embed)
+	if err != nil {
+		return serializer.ParamErr(err.Error(), err)
+	}
+
 	// 对象是否存在
 	exist := true
-	if service.IsDir {
+	if service.IsDir && !collection {
 		folder, err := model.GetFoldersByIDs([]uint{sourceID}, user.ID)
 		if err != nil || len(folder) == 0 {
 			exist = false
 		} else {
 			sourceName = folder[0].Name
 		}
-	} else {
+	} else if !collection {
 		file, err := model.GetFilesByIDs([]uint{sourceID}, user.ID)
 		if err != nil || len(file) == 0 {
 			exist = false
@@ -118,33 +431,182 @@ func (service *ShareCreateService) Create(c *gin.Context) serializer.Response {
 		UserID:          user.ID,
 		SourceID:        sourceID,
 		RemainDownloads: -1,
-		PreviewEnabled:  service.Preview,
+		RemainViews:     -1,
+		Permissions:     model.NormalizeSharePermission(permission, service.IsDir && !collection),
+		Internal:        service.Internal,
+		Collection:      collection,
+		Watermark:       service.Watermark,
+		AccessNotify:    service.Notify,
+		EmbedDomains:    embedDomains,
 		SourceName:      sourceName,
 	}
 
-	// 如果开启了自动过期
+	newShare.PreviewEnabled = newShare.Can(model.SharePermissionView)
+	newShare.AllowUpload = newShare.Can(model.SharePermissionUpload)
+
+	// 下载次数、浏览次数及时间的过期条件相互独立，任一达到即失效
 	if service.RemainDownloads > 0 {
-		expires := time.Now().Add(time.Duration(service.Expire) * time.Second)
 		newShare.RemainDownloads = service.RemainDownloads
+	}
+	if service.RemainViews > 0 {
+		newShare.RemainViews = service.RemainViews
+	}
+	if service.Expire > 0 {
+		expires := time.Now().Add(time.Duration(service.Expire) * time.Second)
 		newShare.Expires = &expires
 	}
 
+	// 延迟生效，可在公布前准备好分享链接
+	if service.Activate > 0 {
+		if service.Expire > 0 && service.Activate >= service.Expire {
+			return serializer.ParamErr("Share would expire before activation", nil)
+		}
+
+		activatesAt := time.Now().Add(time.Duration(service.Activate) * time.Second)
+		newShare.ActivatesAt = &activatesAt
+	}
+
 	// 创建分享
-	id, err := newShare.Create()
-	if err != nil {
+	if _, err := newShare.Create(); err != nil {
 		return serializer.DBErr("Failed to create share link record", err)
 	}
 
+	// 保存合集条目
+	if collection {
+		for i := range items {
+			items[i].ShareID = newShare.ID
+		}
+		if err := model.CreateShareItems(items); err != nil {
+			newShare.Delete()
+			return serializer.DBErr("Failed to create share items", err)
+		}
+	}
+
+	// 设置自定义链接，链接已被占用时撤销创建
+	if service.Slug != "" {
+		if res := setSlug(&newShare, user, service.Slug); res.Code != 0 {
+			newShare.Delete()
+			return res
+		}
+	}
+
 	// 获取分享的唯一id
-	uid := hashid.HashID(id, hashid.ShareID)
+	uid := newShare.Key()
+	filesystem.RecordActivities(model.Activity{
+		UserID:   user.ID,
+		Type:     model.ActivityShare,
+		ObjectID: sourceID,
+		IsDir:    service.IsDir,
+		Name:     sourceName,
+		Detail:   uid,
+	})
 	// 最终得到分享链接
-	siteURL := model.GetSiteURL()
-	sharePath, _ := url.Parse("/s/" + uid)
-	shareURL := siteURL.ResolveReference(sharePath)
+	shareURL := shareLink(uid)
+
+	// 未要求短链接及二维码时只返回分享链接
+	if !service.ShortLink && !service.QRCode {
+		return serializer.Response{
+			Code: 0,
+			Data: shareURL,
+		}
+	}
+
+	link := serializer.ShareLink{URL: shareURL}
+	if service.ShortLink {
+		link.ShortURL = shortenShareLink(c, shareURL, uid)
+	}
+
+	if service.QRCode {
+		content := link.URL
+		if link.ShortURL != "" {
+			content = link.ShortURL
+		}
+
+		qrcode, err := sharelink.QRCodeDataURI(content, model.GetIntSetting("share_qrcode_size", 256))
+		if err != nil {
+			util.Log().Warning("Failed to generate QR code for share %q: %s", uid, err)
+		}
+		link.QRCode = qrcode
+	}
 
 	return serializer.Response{
 		Code: 0,
-		Data: shareURL.String(),
+		Data: link,
+	}
+
+}
+
+// collectionItems 检查合集包含的目录与文件均属于 user 且名称互不相同，返回合集条目及合集名称。
+// 未指定名称时以第一个源对象的名称命名
+func (service *ShareCreateService) collectionItems(user *model.User) ([]model.ShareItem, string, serializer.Response) {
+	dirIDs, err := decodeUniqueIDs(service.Dirs, hashid.FolderID)
+	if err != nil {
+		return nil, "", serializer.Err(serializer.CodeNotFound, "", err)
+	}
+	fileIDs, err := decodeUniqueIDs(service.Items, hashid.FileID)
+	if err != nil {
+		return nil, "", serializer.Err(serializer.CodeNotFound, "", err)
+	}
+
+	var (
+		folders []model.Folder
+		files   []model.File
+	)
+	if len(dirIDs) > 0 {
+		folders, err = model.GetFoldersByIDs(dirIDs, user.ID)
+		if err != nil || len(folders) != len(dirIDs) {
+			return nil, "", serializer.Err(serializer.CodeNotFound, "", err)
+		}
+	}
+	if len(fileIDs) > 0 {
+		files, err = model.GetFilesByIDs(fileIDs, user.ID)
+		if err != nil || len(files) != len(fileIDs) {
+			return nil, "", serializer.Err(serializer.CodeNotFound, "", err)
+		}
+	}
+
+	// 源对象以名称区分，同名的对象无法在虚拟目录中共存
+	items := make([]model.ShareItem, 0, len(folders)+len(files))
+	names := make([]string, 0, len(folders)+len(files))
+	seen := make(map[string]bool, len(folders)+len(files))
+	for _, folder := range folders {
+		items = append(items, model.ShareItem{SourceID: folder.ID, IsDir: true})
+		names = append(names, folder.Name)
+	}
+	for _, file := range files {
+		items = append(items, model.ShareItem{SourceID: file.ID})
+		names = append(names, file.Name)
+	}
+	for _, name := range names {
+		if seen[name] {
+			return nil, "", serializer.ParamErr("Items in a collection must have distinct names", nil)
+		}
+		seen[name] = true
+	}
+
+	name := service.Name
+	if name == "" {
+		name = names[0]
+	}
+
+	return items, name, serializer.Response{}
+}
+
+// decodeUniqueIDs 解码 HashID 列表并去除重复的 ID
+func decodeUniqueIDs(ids []string, t int) ([]uint, error) {
+	res := make([]uint, 0, len(ids))
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		decoded, err := hashid.DecodeHashID(id, t)
+		if err != nil {
+			return nil, filesystem.ErrObjectNotExist
+		}
+
+		if !seen[decoded] {
+			seen[decoded] = true
+			res = append(res, decoded)
+		}
 	}
 
+	return res, nil
 }
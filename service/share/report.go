@@ -0,0 +1,35 @@
+package share
+
+import (
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// ReportService 访客举报分享服务
+type ReportService struct {
+	Reason      string `json:"reason" binding:"required,eq=copyright|eq=malware|eq=illegal|eq=spam|eq=other"`
+	Description string `json:"description" binding:"max=1000"`
+}
+
+// Report 举报分享，举报进入管理员的处理队列，同一来源的举报次数受限
+func (service *ReportService) Report(c *gin.Context) serializer.Response {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+	userCtx, _ := c.Get("user")
+	user := userCtx.(*model.User)
+
+	if err := model.CountShareReport(c.ClientIP()); err != nil {
+		return serializer.Err(serializer.CodeShareReportLimited, "Too many reports, please retry later", err)
+	}
+
+	report := model.NewShareReport(share.ID, user.ID, c.ClientIP(), service.Reason, service.Description)
+	if err := report.Create(); err != nil {
+		if err == model.ErrShareReportDuplicated {
+			return serializer.Err(serializer.CodeShareReportDuplicated, "You have already reported this share", err)
+		}
+		return serializer.DBErr("Failed to save report", err)
+	}
+
+	return serializer.Response{}
+}
@@ -0,0 +1,190 @@
+package share
+
+import (
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// ShareTargetCreateService 添加分享对象服务
+type ShareTargetCreateService struct {
+	Users      []string `json:"users" binding:"max=50,dive,email"`
+	Groups     []uint   `json:"groups" binding:"max=50"`
+	CanReshare bool     `json:"can_reshare"`
+}
+
+// ShareTargetListService 列出分享对象服务
+type ShareTargetListService struct {
+}
+
+// ShareTargetDeleteService 撤销分享对象服务
+type ShareTargetDeleteService struct {
+	Targets []uint `json:"targets" binding:"required,min=1,max=50"`
+}
+
+// SharedWithMeService 列出分享给我的分享服务
+type SharedWithMeService struct {
+	Page uint `form:"page" binding:"required,min=1"`
+}
+
+// Create 为仅限分享对象访问的分享添加用户或用户组，只有创建者可授予转发权限
+func (service *ShareTargetCreateService) Create(c *gin.Context, user *model.User) serializer.Response {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+
+	if !share.Internal {
+		return serializer.ParamErr("Only internal shares can have share targets", nil)
+	}
+
+	if !share.CanBeResharedBy(user) {
+		return serializer.Err(serializer.CodeNoPermissionErr, "", nil)
+	}
+
+	if len(service.Users) == 0 && len(service.Groups) == 0 {
+		return serializer.ParamErr("No share target specified", nil)
+	}
+
+	canReshare := service.CanReshare && share.UserID == user.ID
+	targets := make([]model.ShareTarget, 0, len(service.Users)+len(service.Groups))
+	recipients := make([]model.User, 0, len(service.Users))
+	for _, email := range service.Users {
+		recipient, err := model.GetActiveUserByEmail(email)
+		if err != nil {
+			return serializer.Err(serializer.CodeUserNotFound, email, err)
+		}
+
+		// 创建者始终可以访问
+		if recipient.ID == share.UserID {
+			continue
+		}
+
+		targets = append(targets, model.ShareTarget{
+			ShareID:    share.ID,
+			UserID:     recipient.ID,
+			CanReshare: canReshare,
+			GrantedBy:  user.ID,
+		})
+		recipients = append(recipients, recipient)
+	}
+
+	for _, groupID := range service.Groups {
+		group, err := model.GetGroupByID(groupID)
+		if err != nil {
+			return serializer.Err(serializer.CodeGroupNotFound, "", err)
+		}
+
+		targets = append(targets, model.ShareTarget{
+			ShareID:    share.ID,
+			GroupID:    group.ID,
+			CanReshare: canReshare,
+			GrantedBy:  user.ID,
+		})
+	}
+
+	if err := model.CreateShareTargets(targets); err != nil {
+		return serializer.DBErr("Failed to create share targets", err)
+	}
+
+	notifyShareTargets(share, recipients, user)
+	return (&ShareTargetListService{}).List(c, user)
+}
+
+// List 列出分享的分享对象，创建者及可转发此分享的用户可查看
+func (service *ShareTargetListService) List(c *gin.Context, user *model.User) serializer.Response {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+
+	if !share.CanBeResharedBy(user) {
+		return serializer.Err(serializer.CodeNoPermissionErr, "", nil)
+	}
+
+	targets, err := model.GetShareTargets(share.ID)
+	if err != nil {
+		return serializer.DBErr("Failed to list share targets", err)
+	}
+
+	for i := range targets {
+		if targets[i].UserID != 0 {
+			targets[i].User, _ = model.GetUserByID(targets[i].UserID)
+		} else {
+			targets[i].Group, _ = model.GetGroupByID(targets[i].GroupID)
+		}
+	}
+
+	return serializer.Response{Data: serializer.BuildShareTargets(targets)}
+}
+
+// Delete 撤销分享对象，创建者可撤销全部分享对象，其他用户只能撤销自己添加的分享对象或退出分享
+func (service *ShareTargetDeleteService) Delete(c *gin.Context, user *model.User) serializer.Response {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+
+	ids := service.Targets
+	if share.UserID != user.ID {
+		targets, err := model.GetShareTargets(share.ID)
+		if err != nil {
+			return serializer.DBErr("Failed to list share targets", err)
+		}
+
+		allowed := make(map[uint]bool, len(targets))
+		for _, target := range targets {
+			if target.GrantedBy == user.ID || target.UserID == user.ID {
+				allowed[target.ID] = true
+			}
+		}
+
+		for _, id := range ids {
+			if !allowed[id] {
+				return serializer.Err(serializer.CodeNoPermissionErr, "", nil)
+			}
+		}
+	}
+
+	if err := model.DeleteShareTargets(share.ID, ids); err != nil {
+		return serializer.DBErr("Failed to delete share targets", err)
+	}
+
+	return serializer.Response{}
+}
+
+// List 列出其他用户分享给当前用户及其所在用户组的分享
+func (service *SharedWithMeService) List(c *gin.Context, user *model.User) serializer.Response {
+	shares, total := model.ListSharesWithUser(user, int(service.Page), 18)
+	for i := range shares {
+		shares[i].Creator()
+		shares[i].Source()
+	}
+
+	return serializer.BuildSharedWithMeList(shares, total)
+}
+
+// notifyShareTargets 通知被直接指定的用户有新的分享
+func notifyShareTargets(share *model.Share, recipients []model.User, actor *model.User) {
+	if len(recipients) == 0 {
+		return
+	}
+
+	notifications := make([]model.Notification, 0, len(recipients))
+	for _, recipient := range recipients {
+		if recipient.ID == actor.ID {
+			continue
+		}
+
+		notification := model.Notification{
+			UserID:   recipient.ID,
+			Type:     model.NotificationShare,
+			ActorID:  actor.ID,
+			FileName: share.SourceName,
+			ShareID:  share.ID,
+		}
+		if !share.IsDir {
+			notification.FileID = share.SourceID
+		}
+		notifications = append(notifications, notification)
+	}
+
+	if err := model.CreateNotifications(notifications); err != nil {
+		util.Log().Warning("Failed to create share notifications: %s", err)
+	}
+}
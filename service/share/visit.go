@@ -2,9 +2,11 @@ package share
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"net/http"
 	"path"
+	"strings"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
@@ -40,6 +42,12 @@ type ArchiveService struct {
 	Dirs  []string `json:"dirs"`
 }
 
+// UploadService 访客向分享的目录上传文件服务
+type UploadService struct {
+	Path string `form:"path" binding:"required,max=65535"`
+	Name string `form:"name" binding:"required,max=255"`
+}
+
 // ShareListService 列出分享
 type ShareListService struct {
 	Page     uint   `form:"page" binding:"required,min=1"`
@@ -125,8 +133,12 @@ func (service *ShareGetService) Get(c *gin.Context) serializer.Response {
 		sessionKey := fmt.Sprintf("share_unlock_%d", share.ID)
 		unlocked = util.GetSession(c, sessionKey) != nil
 		if !unlocked && service.Password != "" {
-			// 如果未解锁，且指定了密码，则尝试解锁
-			if service.Password == share.Password {
+			// 如果未解锁，且指定了密码，则尝试解锁，同一来源的尝试次数受限
+			if err := share.CountPasswordAttempt(c.ClientIP()); err != nil {
+				return serializer.Err(serializer.CodeSharePasswordLimited, "Too many password attempts, please retry later", err)
+			}
+
+			if subtle.ConstantTimeCompare([]byte(service.Password), []byte(share.Password)) == 1 {
 				unlocked = true
 				util.SetSession(c, map[string]interface{}{sessionKey: true})
 			}
@@ -134,7 +146,9 @@ func (service *ShareGetService) Get(c *gin.Context) serializer.Response {
 	}
 
 	if unlocked {
-		share.Viewed()
+		userCtx, _ := c.Get("user")
+		share.ViewBy(userCtx.(*model.User), c)
+		recordAccess(c, share, model.ShareAccessView, "")
 	}
 
 	return serializer.Response{
@@ -143,6 +157,14 @@ func (service *ShareGetService) Get(c *gin.Context) serializer.Response {
 	}
 }
 
+// recordAccess 保存分享的访问记录，失败时只记录日志
+func recordAccess(c *gin.Context, share *model.Share, action, fileName string) {
+	access := model.NewShareAccess(share, action, c.ClientIP(), c.Request.UserAgent(), fileName)
+	if err := access.Create(); err != nil {
+		util.Log().Warning("Failed to record share access: %s", err)
+	}
+}
+
 // CreateDownloadSession 创建下载会话
 func (service *Service) CreateDownloadSession(c *gin.Context) serializer.Response {
 	shareCtx, _ := c.Get("share")
@@ -157,22 +179,39 @@ func (service *Service) CreateDownloadSession(c *gin.Context) serializer.Respons
 	}
 	defer fs.Recycle()
 
-	// 重设文件系统处理目标为源文件
-	err = fs.SetTargetByInterface(share.Source())
-	if err != nil {
-		return serializer.Err(serializer.CodeFileNotFound, "", err)
-	}
-
 	ctx := context.Background()
 
-	// 重设根目录
-	if share.IsDir {
-		fs.Root = &fs.DirTarget[0]
+	if share.Collection {
+		// 合集分享中的文件位于某个源目录下，或本身即为源文件
+		target, err := resolveCollectionPath(share, service.Path)
+		if err != nil || target.IsRoot() {
+			return serializer.Err(serializer.CodeFileNotFound, "", err)
+		}
 
-		// 找到目标文件
-		err = fs.ResetFileIfNotExist(ctx, service.Path)
+		if target.File != nil {
+			fs.SetTargetFile(&[]model.File{*target.File})
+		} else {
+			fs.Root = target.Root
+			if err := fs.ResetFileIfNotExist(ctx, target.Path); err != nil {
+				return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+			}
+		}
+	} else {
+		// 重设文件系统处理目标为源文件
+		err = fs.SetTargetByInterface(share.Source())
 		if err != nil {
-			return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+			return serializer.Err(serializer.CodeFileNotFound, "", err)
+		}
+
+		// 重设根目录
+		if share.IsDir {
+			fs.Root = &fs.DirTarget[0]
+
+			// 找到目标文件
+			err = fs.ResetFileIfNotExist(ctx, service.Path)
+			if err != nil {
+				return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+			}
 		}
 	}
 
@@ -182,6 +221,12 @@ func (service *Service) CreateDownloadSession(c *gin.Context) serializer.Respons
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
 
+	fileName := fs.FileTarget[0].Name
+	if share.IsDir {
+		fileName = path.Join("/", service.Path)
+	}
+	recordAccess(c, share, model.ShareAccessDownload, fileName)
+
 	return serializer.Response{
 		Code: 0,
 		Data: downloadURL,
@@ -195,33 +240,39 @@ func (service *Service) PreviewContent(ctx context.Context, c *gin.Context, isTe
 	share := shareCtx.(*model.Share)
 
 	// 用于调下层service
-	if share.IsDir {
-		ctx = context.WithValue(ctx, fsctx.FolderModelCtx, share.Source())
-		ctx = context.WithValue(ctx, fsctx.PathCtx, service.Path)
-	} else {
-		ctx = context.WithValue(ctx, fsctx.FileModelCtx, share.Source())
+	ctx, err := withShareSource(ctx, share, service.Path)
+	if err != nil {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	// 为图片、PDF 添加标识访客的水印
+	if share.Watermark != "" {
+		userCtx, _ := c.Get("user")
+		ctx = context.WithValue(ctx, fsctx.WatermarkCtx, share.WatermarkText(userCtx.(*model.User), c.ClientIP()))
 	}
 	subService := explorer.FileIDService{}
 
 	return subService.PreviewContent(ctx, c, isText)
 }
 
-// CreateDocPreviewSession 创建Office预览会话，返回预览地址
+// CreateDocPreviewSession 创建Office预览会话，返回预览地址，授予编辑权限的分享可在线编辑。
+// 第三方预览服务读取的是原始文件，设置了水印的分享不提供此类预览
 func (service *Service) CreateDocPreviewSession(c *gin.Context) serializer.Response {
 	shareCtx, _ := c.Get("share")
 	share := shareCtx.(*model.Share)
 
+	if share.Watermark != "" {
+		return serializer.Err(serializer.CodeNoPermissionErr, "Document preview is not available for watermarked shares", nil)
+	}
+
 	// 用于调下层service
-	ctx := context.Background()
-	if share.IsDir {
-		ctx = context.WithValue(ctx, fsctx.FolderModelCtx, share.Source())
-		ctx = context.WithValue(ctx, fsctx.PathCtx, service.Path)
-	} else {
-		ctx = context.WithValue(ctx, fsctx.FileModelCtx, share.Source())
+	ctx, err := withShareSource(context.Background(), share, service.Path)
+	if err != nil {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
 	}
 	subService := explorer.FileIDService{}
 
-	return subService.CreateDocPreviewSession(ctx, c, false)
+	return subService.CreateDocPreviewSession(ctx, c, share.Can(model.SharePermissionEdit))
 }
 
 // List 列出分享的目录下的对象
@@ -248,15 +299,35 @@ func (service *Service) List(c *gin.Context) serializer.Response {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// 重设根目录
-	fs.Root = share.Source().(*model.Folder)
-	fs.Root.Name = "/"
-
 	// 分享Key上下文
 	ctx = context.WithValue(ctx, fsctx.ShareKeyCtx, hashid.HashID(share.ID, hashid.ShareID))
 
+	// 重设根目录
+	dirPath := service.Path
+	if share.Collection {
+		target, err := resolveCollectionPath(share, service.Path)
+		if err != nil || target.File != nil {
+			return serializer.Err(serializer.CodeParentNotExist, "", err)
+		}
+
+		// 合集的根目录下为各源目录与源文件
+		if target.IsRoot() {
+			folders, files := share.CollectionSources()
+			return serializer.Response{
+				Code: 0,
+				Data: serializer.BuildObjectList(0, fs.ListObjects(ctx, "/", files, folders), nil),
+			}
+		}
+
+		fs.Root = target.Root
+		dirPath = target.Path
+	} else {
+		fs.Root = share.Source().(*model.Folder)
+		fs.Root.Name = "/"
+	}
+
 	// 获取子项目
-	objects, err := fs.List(ctx, service.Path, nil)
+	objects, err := fs.List(ctx, dirPath, nil)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
@@ -283,23 +354,42 @@ func (service *Service) Thumb(c *gin.Context) serializer.Response {
 	}
 	defer fs.Recycle()
 
-	// 重设根目录
-	fs.Root = share.Source().(*model.Folder)
-
-	// 找到缩略图的父目录
-	exist, parent := fs.IsPathExist(service.Path)
-	if !exist {
-		return serializer.Err(serializer.CodeParentNotExist, "", nil)
-	}
-
-	ctx := context.WithValue(context.Background(), fsctx.LimitParentCtx, parent)
-
 	// 获取文件ID
 	fileID, err := hashid.DecodeHashID(c.Param("file"), hashid.FileID)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotFound, "", err)
 	}
 
+	// 找到缩略图的父目录，合集根目录下的文件须为合集的源文件
+	parentPath := service.Path
+	if share.Collection {
+		target, err := resolveCollectionPath(share, service.Path)
+		if err != nil || target.File != nil {
+			return serializer.Err(serializer.CodeParentNotExist, "", err)
+		}
+
+		if target.IsRoot() {
+			if !collectionContains(share, nil, []string{c.Param("file")}) {
+				return serializer.Err(serializer.CodeNotFound, "", nil)
+			}
+		} else {
+			fs.Root = target.Root
+			parentPath = target.Path
+		}
+	} else {
+		fs.Root = share.Source().(*model.Folder)
+	}
+
+	ctx := context.Background()
+	if fs.Root != nil {
+		exist, parent := fs.IsPathExist(parentPath)
+		if !exist {
+			return serializer.Err(serializer.CodeParentNotExist, "", nil)
+		}
+
+		ctx = context.WithValue(ctx, fsctx.LimitParentCtx, parent)
+	}
+
 	// 获取缩略图
 	resp, err := fs.GetThumb(ctx, uint(fileID))
 	if err != nil {
@@ -313,6 +403,7 @@ func (service *Service) Thumb(c *gin.Context) serializer.Response {
 	}
 
 	defer resp.Content.Close()
+	fs.WithDownloadTraffic(c)
 	http.ServeContent(c.Writer, c.Request, "thumb.png", fs.FileTarget[0].UpdatedAt, resp.Content)
 
 	return serializer.Response{Code: -1}
@@ -342,17 +433,36 @@ func (service *ArchiveService) Archive(c *gin.Context) serializer.Response {
 	}
 	defer fs.Recycle()
 
-	// 重设根目录
-	fs.Root = share.Source().(*model.Folder)
+	// 找到要打包文件的父目录，合集根目录下只能打包合集的源对象
+	parentPath := service.Path
+	if share.Collection {
+		target, err := resolveCollectionPath(share, service.Path)
+		if err != nil || target.File != nil {
+			return serializer.Err(serializer.CodeParentNotExist, "", err)
+		}
 
-	// 找到要打包文件的父目录
-	exist, parent := fs.IsPathExist(service.Path)
-	if !exist {
-		return serializer.Err(serializer.CodeParentNotExist, "", nil)
+		if target.IsRoot() {
+			if !collectionContains(share, service.Dirs, service.Items) {
+				return serializer.Err(serializer.CodeNotFound, "", nil)
+			}
+		} else {
+			fs.Root = target.Root
+			parentPath = target.Path
+		}
+	} else {
+		fs.Root = share.Source().(*model.Folder)
 	}
 
-	// 限制操作范围为父目录下
-	ctx := context.WithValue(context.Background(), fsctx.LimitParentCtx, parent)
+	ctx := context.Background()
+	if fs.Root != nil {
+		exist, parent := fs.IsPathExist(parentPath)
+		if !exist {
+			return serializer.Err(serializer.CodeParentNotExist, "", nil)
+		}
+
+		// 限制操作范围为父目录下
+		ctx = context.WithValue(ctx, fsctx.LimitParentCtx, parent)
+	}
 
 	// 用于调下层service
 	tempUser := share.Creator()
@@ -396,6 +506,13 @@ func (service *SearchService) Search(c *gin.Context) serializer.Response {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// 分享Key上下文
+	ctx = context.WithValue(ctx, fsctx.ShareKeyCtx, hashid.HashID(share.ID, hashid.ShareID))
+
+	if share.Collection {
+		return service.searchCollection(ctx, c, fs, share)
+	}
+
 	// 重设根目录
 	fs.Root = share.Source().(*model.Folder)
 	fs.Root.Name = "/"
@@ -408,8 +525,125 @@ func (service *SearchService) Search(c *gin.Context) serializer.Response {
 		fs.Root = parent
 	}
 
-	// 分享Key上下文
+	return service.SearchKeywords(c, fs, "%"+service.Keywords+"%")
+}
+
+// searchCollection 在合集分享中搜索，位于合集根目录时搜索全部源目录及源文件
+func (service *SearchService) searchCollection(ctx context.Context, c *gin.Context, fs *filesystem.FileSystem, share *model.Share) serializer.Response {
+	target, err := resolveCollectionPath(share, path.Join("/", service.Path))
+	if err != nil || target.File != nil {
+		return serializer.Err(serializer.CodeParentNotExist, "Cannot find parent folder", err)
+	}
+
+	if !target.IsRoot() {
+		fs.Root = target.Root
+		ok, parent := fs.IsPathExist(target.Path)
+		if !ok {
+			return serializer.Err(serializer.CodeParentNotExist, "Cannot find parent folder", nil)
+		}
+
+		fs.Root = parent
+		return service.SearchKeywords(c, fs, "%"+service.Keywords+"%")
+	}
+
+	folders, files := share.CollectionSources()
+	matched := make([]model.File, 0)
+	for _, file := range files {
+		if strings.Contains(strings.ToLower(file.Name), strings.ToLower(service.Keywords)) {
+			matched = append(matched, file)
+		}
+	}
+	objects := fs.ListObjects(ctx, "/", matched, nil)
+
+	for i := range folders {
+		fs.Root = &folders[i]
+		res, err := fs.Search(ctx, "%"+service.Keywords+"%")
+		if err != nil {
+			return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+		}
+		objects = append(objects, res...)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: map[string]interface{}{
+			"parent":  0,
+			"objects": objects,
+		},
+	}
+}
+
+// Upload 访客向分享的目录上传文件，文件归属分享者并占用其容量
+func (service *UploadService) Upload(ctx context.Context, c *gin.Context) serializer.Response {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+
+	// 路径须为根目录下规范的绝对路径，不能包含 "." 或 ".."，避免上传至分享的目录之外
+	if !isCleanSharePath(service.Path) || service.Name == "." || service.Name == ".." ||
+		strings.ContainsAny(service.Name, "/\\") {
+		return serializer.ParamErr("Invalid path", nil)
+	}
+
+	// 需要预先知道文件大小以校验容量
+	if c.Request.ContentLength < 0 {
+		return serializer.ParamErr("Content-Length is required", nil)
+	}
+
+	// 限制游客的上传频率
+	userCtx, _ := c.Get("user")
+	if user, ok := userCtx.(*model.User); !ok || user.IsAnonymous() {
+		if err := share.CountAnonymousUpload(c.ClientIP()); err != nil {
+			return serializer.Err(serializer.CodeShareUploadLimited, "Too many uploads, please retry later", err)
+		}
+	}
+
+	// 使用分享者的文件系统
+	fs, err := filesystem.NewFileSystem(share.Creator())
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	// 重设根目录
+	folder := share.Source().(*model.Folder)
+	if err := folder.TraceRoot(); err != nil {
+		return serializer.Err(serializer.CodeParentNotExist, "", err)
+	}
+	fs.Root = folder
+
+	// 使用目标目录绑定的存储策略
+	if err := fs.SwitchPolicyByPath(service.Path); err != nil {
+		return serializer.Err(serializer.CodePolicyNotExist, "", err)
+	}
+
+	fileData := &fsctx.FileStream{
+		File:        c.Request.Body,
+		Size:        uint64(c.Request.ContentLength),
+		Name:        service.Name,
+		VirtualPath: service.Path,
+		MimeType:    c.Request.Header.Get("Content-Type"),
+	}
+
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
 	ctx = context.WithValue(ctx, fsctx.ShareKeyCtx, hashid.HashID(share.ID, hashid.ShareID))
+	if err := fs.UploadFromStream(ctx, fileData, false); err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
 
-	return service.SearchKeywords(c, fs, "%"+service.Keywords+"%")
+	return serializer.Response{}
+}
+
+// isCleanSharePath 返回 p 是否为不含 "."、".." 及空路径段的绝对路径，此类路径解析后不会超出分享的根目录
+func isCleanSharePath(p string) bool {
+	if !path.IsAbs(p) || strings.Contains(p, "\\") {
+		return false
+	}
+
+	for _, segment := range strings.Split(strings.TrimSuffix(p, "/"), "/")[1:] {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+
+	return true
 }
@@ -11,6 +11,7 @@ import (
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/task"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/gin-gonic/gin"
 	"github.com/pquerna/otp/totp"
@@ -25,6 +26,11 @@ type SettingListService struct {
 	Page int `form:"page" binding:"required,min=1"`
 }
 
+// TaskCancelService 任务取消服务
+type TaskCancelService struct {
+	ID uint `uri:"id" binding:"required"`
+}
+
 // AvatarService 头像服务
 type AvatarService struct {
 	Size string `uri:"size" binding:"required,eq=l|eq=m|eq=s"`
@@ -241,6 +247,23 @@ func (service *SettingListService) ListTasks(c *gin.Context, user *model.User) s
 	return serializer.BuildTaskList(tasks, total)
 }
 
+// Cancel 取消用户所属的任务
+func (service *TaskCancelService) Cancel(c *gin.Context, user *model.User) serializer.Response {
+	record, err := model.GetTasksByID(service.ID)
+	if err != nil || record.UserID != user.ID {
+		return serializer.Err(serializer.CodeNotFound, "Task not exist", err)
+	}
+
+	if err := task.Cancel(record); err != nil {
+		if err == task.ErrTaskFinished || err == task.ErrTaskNotCancelable {
+			return serializer.Err(serializer.CodeTaskNotCancelable, err.Error(), err)
+		}
+		return serializer.DBErr("Failed to cancel task", err)
+	}
+
+	return serializer.Response{}
+}
+
 // Settings 获取用户设定
 func (service *SettingService) Settings(c *gin.Context, user *model.User) serializer.Response {
 	return serializer.Response{
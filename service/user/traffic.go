@@ -0,0 +1,22 @@
+package user
+
+import (
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+)
+
+// TrafficService 用户流量统计查询服务
+type TrafficService struct {
+	From string `form:"from" binding:"omitempty,datetime=2006-01-02"`
+	To   string `form:"to" binding:"omitempty,datetime=2006-01-02"`
+}
+
+// Report 按日期列出用户在所有存储策略上的流量
+func (service *TrafficService) Report(user *model.User) serializer.Response {
+	traffics, err := model.GetTraffics(user.ID, 0, service.From, service.To)
+	if err != nil {
+		return serializer.DBErr("Failed to list traffic statistics", err)
+	}
+
+	return serializer.BuildTrafficReport(traffics, true)
+}